@@ -18,6 +18,8 @@ package v1
 import (
 	"fmt"
 
+	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -78,6 +80,225 @@ type PgclusterSpec struct {
 	TLSOnly            bool                     `json:"tlsOnly"`
 	Standby            bool                     `json:"standby"`
 	Shutdown           bool                     `json:"shutdown"`
+	// StartupGate, if set, is the type of a Pod condition that must be "True"
+	// before PostgreSQL is started in any of the cluster's Pods, e.g. a condition
+	// that is set by a secret injector once it has written out credentials. The
+	// condition is also added as a readiness gate on the Pods
+	StartupGate string `json:"startupGate"`
+	// SchedulingGate, if set, holds back the Pods of the cluster until an
+	// external coordinator, e.g. one that serializes provisioning against a
+	// quota, removes the "crunchydata.com/scheduling-gate" annotation from them.
+	// The annotation is set to the name of the gate
+	SchedulingGate string `json:"schedulingGate"`
+	// Maintenance sets up routine maintenance, i.e. VACUUM and REINDEX, that is
+	// run against the cluster on a schedule
+	Maintenance MaintenanceSpec `json:"maintenance"`
+	// ServiceDiscovery optionally publishes the primary Service to a consumer
+	// namespace by means of an ExternalName Service
+	ServiceDiscovery ServiceDiscoverySpec `json:"serviceDiscovery"`
+	// EphemeralStorage sets the ephemeral storage request and limit of the
+	// PostgreSQL containers as well as the pgBackRest repository container
+	EphemeralStorage PgEphemeralStorageSpec `json:"ephemeralStorage"`
+	// DeletionProtection guards the cluster against being deleted without a
+	// final backup
+	DeletionProtection DeletionProtectionSpec `json:"deletionProtection"`
+	// MinReadyReplicas is the number of ready replicas the cluster should not
+	// drop below, e.g. while nodes are drained. If the number of ready replicas
+	// drops below it, temporary replicas are added until the other replicas are
+	// ready again
+	MinReadyReplicas int `json:"minReadyReplicas"`
+	// Audit configures pgaudit based audit logging for the cluster
+	Audit AuditSpec `json:"audit"`
+	// Autoscale has the Operator add replicas when the cluster is saturated
+	// for a sustained period of time, and remove them again once it is
+	// underutilized
+	Autoscale AutoscaleSpec `json:"autoscale"`
+	// HealthCheck is a custom query that needs to succeed for the cluster to be
+	// considered ready
+	HealthCheck HealthCheckSpec `json:"healthCheck"`
+	// NodeSelector is merged into the default node selector of the Operator,
+	// taking precedence over it. A key with an empty value removes that key
+	// from the defaults
+	NodeSelector map[string]string `json:"nodeSelector"`
+	// Tolerations are added to the default tolerations of the Operator. A
+	// toleration replaces a default toleration with the same key and effect
+	Tolerations []v1.Toleration `json:"tolerations"`
+	// ClearDefaultTolerations, if set to true, has only the Tolerations of the
+	// cluster applied, and none of the defaults of the Operator
+	ClearDefaultTolerations bool `json:"clearDefaultTolerations"`
+	// NodeAffinity is applied to every Pod of the cluster, i.e. its instances
+	// as well as its pgBackRest repository, pgBouncer and jobs, unless a
+	// component overrides it
+	NodeAffinity *v1.NodeAffinity `json:"nodeAffinity,omitempty"`
+	// PriorityClassName is the PriorityClass of every Pod of the cluster,
+	// unless a component overrides it
+	PriorityClassName string `json:"priorityClassName"`
+	// BackrestRepoScheduling overrides how the pgBackRest repository of the
+	// cluster is scheduled
+	BackrestRepoScheduling SchedulingSpec `json:"backrestRepoScheduling"`
+	// TopologySpreadConstraints are applied to the pods of the instances of
+	// the cluster. A constraint without a label selector spreads the
+	// instances of the cluster
+	TopologySpreadConstraints []v1.TopologySpreadConstraint `json:"topologySpreadConstraints"`
+	// ZoneAntiAffinity keeps the instances of the cluster in different
+	// availability zones, either "preferred" or "required". The instances are
+	// not kept apart by zone if it is not set or "disabled"
+	ZoneAntiAffinity PodAntiAffinityType `json:"zoneAntiAffinity"`
+	// RuntimeClassName is the RuntimeClass the pods of the PostgreSQL instances
+	// run with, e.g. one that uses gVisor. A replica can override it with a
+	// RuntimeClass of its own
+	RuntimeClassName string `json:"runtimeClassName"`
+	// BackrestS3 tunes how pgBackRest transfers files to and from a repository
+	// that is stored in S3 or other object storage
+	BackrestS3 BackrestS3Spec `json:"backrestS3"`
+	// BackrestRepos are the pgBackRest repositories that the cluster is backed
+	// up to in addition to its own, which is repository 1. Up to three more
+	// repositories can be added, each stored in S3, GCS or Azure
+	BackrestRepos []BackrestRepoSpec `json:"backrestRepos,omitempty"`
+	// BackrestGCS is the Google Cloud Storage bucket that the pgBackRest
+	// repository of the cluster is stored in if its storage type is "gcs"
+	BackrestGCS BackrestGCSSpec `json:"backrestGCS"`
+	// BackrestAzure is the Azure Blob Storage container that the pgBackRest
+	// repository of the cluster is stored in if its storage type is "azure"
+	BackrestAzure BackrestAzureSpec `json:"backrestAzure"`
+	// BackrestIdentity has pgBackRest authenticate to S3 or GCS with the
+	// workload identity of its ServiceAccount rather than with static keys
+	BackrestIdentity BackrestIdentitySpec `json:"backrestIdentity"`
+	// BackrestSecretStore has pgBackRest read the credentials of a repository
+	// in S3 or GCS from a secret store rather than from the pgBackRest Secret
+	// of the cluster
+	BackrestSecretStore BackrestSecretStoreSpec `json:"backrestSecretStore"`
+	// BackrestRetention has the pgBackRest repository of the cluster expire
+	// its backups and WAL archive periodically according to its retention
+	BackrestRetention BackrestRetentionSpec `json:"backrestRetention"`
+	// BackrestVerification has the pgBackRest repository of the cluster
+	// verified periodically, so that a corrupt backup or WAL archive is
+	// detected before it is needed for a restore
+	BackrestVerification BackrestVerificationSpec `json:"backrestVerification"`
+	// Clone has the cluster bootstrapped from the pgBackRest repository of
+	// another cluster, rather than initialized empty
+	Clone CloneSpec `json:"clone"`
+	// StandbyStreaming has a standby cluster stream the WAL of a remote primary
+	// over TLS, falling back to its pgBackRest repository when the remote
+	// primary cannot be reached
+	StandbyStreaming StandbyStreamingSpec `json:"standbyStreaming"`
+	// TrafficRamp has the connections to a newly promoted primary let through
+	// gradually, so that its cold caches are not hit by the full traffic
+	TrafficRamp TrafficRampSpec `json:"trafficRamp"`
+	// Fencing has the primary made read-only while it cannot reach a quorum
+	// of its replicas, so that it does not accept writes that cannot be
+	// replicated
+	Fencing FencingSpec `json:"fencing"`
+	// PasswordRotation has the passwords of the PostgreSQL users of the
+	// cluster rotated periodically, or on demand with the
+	// "crunchydata.com/rotate-password" annotation
+	PasswordRotation PasswordRotationSpec `json:"passwordRotation"`
+	// PasswordEncryption is how the passwords of the PostgreSQL users are
+	// encrypted, i.e. "md5" or "scram-sha-256". It defaults to "md5". The
+	// password rules of pg_hba.conf stay at md5, which authenticates users
+	// with either
+	PasswordEncryption PasswordEncryptionType `json:"passwordEncryption"`
+	// HBA contains rules that are added to the pg_hba.conf of the cluster, or
+	// that replace its default rules
+	HBA HBASpec `json:"hba"`
+	// PostgresParams are PostgreSQL parameters, i.e. the settings of
+	// postgresql.conf, that are applied to every instance of the cluster.
+	// Parameters that can be reloaded are applied right away, while the
+	// cluster is restarted for those that can only be set at server start.
+	// Parameters that the Operator manages itself cannot be set
+	PostgresParams map[string]string `json:"postgresParams"`
+	// Tuning, if set to "auto", has the Operator derive the memory settings
+	// of PostgreSQL, e.g. "shared_buffers", from the memory limit of the
+	// database container, and apply them like PostgresParams. They are
+	// derived again whenever the memory changes. A parameter that is set in
+	// PostgresParams takes precedence over its derived value
+	Tuning PostgresTuningMode `json:"tuning"`
+	// DataChecksums, if set to true, has the cluster initialized with data
+	// checksums. As checksums can only be turned on when the data directory
+	// is initialized, an existing cluster needs to have them enabled with an
+	// "enable-data-checksums" pgtask
+	DataChecksums bool `json:"dataChecksums"`
+	// PgBouncer contains how many pgBouncer connection poolers the cluster has
+	// and how they are rolled out
+	PgBouncer PgBouncerSpec `json:"pgBouncer"`
+	// Locale is the locale the cluster is initialized with. It is fixed once
+	// the data directory is initialized, so changing it afterwards is refused
+	Locale LocaleSpec `json:"locale"`
+	// Timezone is the "timezone" of PostgreSQL, which is applied with a reload.
+	// If it is not set, the time zone is left as it is
+	Timezone string `json:"timezone"`
+	// Exporter contains whether the pgMonitor exporter is run alongside the
+	// instances of the cluster, and how
+	Exporter ExporterSpec `json:"exporter"`
+	// Monitor has the Operator create a Prometheus Operator ServiceMonitor or
+	// PodMonitor for the exporter while it is enabled, so that Prometheus
+	// scrapes the cluster without a scrape configuration of its own
+	Monitor MonitorSpec `json:"monitor"`
+	// Alerts has the Operator create a Prometheus Operator PrometheusRule with
+	// the standard PostgreSQL alerts for the cluster while the exporter is
+	// enabled
+	Alerts AlertsSpec `json:"alerts"`
+	// NetworkPolicy has the Operator create NetworkPolicies that only let the
+	// traffic of the cluster itself reach its instances and its pgBackRest
+	// repository
+	NetworkPolicy NetworkPolicySpec `json:"networkPolicy"`
+	// PodDisruptionBudget has the Operator create PodDisruptionBudgets that
+	// protect the primary and the replicas of the cluster from voluntary
+	// disruptions, e.g. the drain of a node
+	PodDisruptionBudget PodDisruptionBudgetSpec `json:"podDisruptionBudget"`
+	// Metadata contains labels and annotations that are set on the resources
+	// of the cluster, e.g. for cost allocation or a service mesh
+	Metadata MetadataSpec `json:"metadata"`
+	// InstancePod contains containers, init containers, volumes and volume
+	// mounts that are added to the Pods of the instances, e.g. a log shipper
+	InstancePod InstancePodSpec `json:"instancePod"`
+	// StorageAutogrow has the Operator grow the volumes of the instances once
+	// they fill up
+	StorageAutogrow StorageAutogrowSpec `json:"storageAutogrow"`
+	// WALStorage, if it has a storage type, puts the write-ahead log of each
+	// instance on a volume of its own rather than in its data directory
+	WALStorage PgStorageSpec `json:"walStorage"`
+	// Tablespaces are tablespaces that each get a volume of their own on every
+	// instance, and that the Operator creates in PostgreSQL. They are in
+	// addition to those of TablespaceMounts
+	Tablespaces []TablespaceSpec `json:"tablespaces"`
+	// VolumeSnapshots has the Operator take CSI VolumeSnapshots of the volume
+	// of the primary to create the volumes of new instances from, rather than
+	// restoring them from the pgBackRest repository
+	VolumeSnapshots VolumeSnapshotSpec `json:"volumeSnapshots"`
+	// HibernationSchedule has the Operator shut the cluster down and start it
+	// back up on a schedule, e.g. to save the resources of a development
+	// cluster overnight
+	HibernationSchedule HibernationScheduleSpec `json:"hibernationSchedule"`
+	// ResourceRecommendation has the Operator observe the CPU and memory
+	// usage of the database containers, and recommend requests and limits
+	// for them, or apply them on a schedule
+	ResourceRecommendation ResourceRecommendationSpec `json:"resourceRecommendation"`
+	// MaintenanceWindow is when the Operator carries out the operations that
+	// disrupt the cluster, such as restarting it for new resources, updating
+	// it to a new image tag or resizing its volumes. Such an operation that
+	// comes up outside of the window waits for it to open
+	MaintenanceWindow MaintenanceWindowSpec `json:"maintenanceWindow"`
+	// FailoverHealthCheck has the Operator probe the primary with SQL, and
+	// fail the cluster over to its best replica once the primary failed the
+	// probe a number of times in a row
+	FailoverHealthCheck FailoverHealthCheckSpec `json:"failoverHealthCheck"`
+	// SyncReplicationQuorum is the number of replicas that each commit waits
+	// for when synchronous replication is enabled, any of which can confirm
+	// it. It defaults to 0, which has Patroni wait for a single synchronous
+	// replica of its choosing. It requires PostgreSQL 10 or later
+	SyncReplicationQuorum int `json:"syncReplicationQuorum"`
+	// MaxReplicaLag, if set, has the Operator take the replicas that lag
+	// further behind the primary out of the replica Service until they catch
+	// up
+	MaxReplicaLag MaxReplicaLagSpec `json:"maxReplicaLag"`
+	// ReadOnlyService, if enabled, has the Operator maintain a Service for
+	// read-only connections that sends them to the replicas that are ready
+	// and do not lag too far behind the primary
+	ReadOnlyService ReadOnlyServiceSpec `json:"readOnlyService"`
+	// Services contains the type and annotations of each of the Services of
+	// the cluster, which are applied to the Services whenever they change
+	Services ServicesSpec `json:"services"`
 }
 
 // PgclusterList is the CRD that defines a Crunchy PG Cluster List
@@ -93,14 +314,120 @@ type PgclusterList struct {
 // PgclusterStatus is the CRD that defines PG Cluster Status
 // swagger:ignore
 type PgclusterStatus struct {
-	State   PgclusterState `json:"state,omitempty"`
-	Message string         `json:"message,omitempty"`
+	State       PgclusterState    `json:"state,omitempty"`
+	Message     string            `json:"message,omitempty"`
+	Maintenance MaintenanceStatus `json:"maintenance,omitempty"`
+	// ServiceDiscovery contains where the primary can be reached from within
+	// the Kubernetes cluster
+	ServiceDiscovery ServiceDiscoveryStatus `json:"serviceDiscovery,omitempty"`
+	// Autoscale contains the connection and CPU signals the autoscaler acts on
+	Autoscale AutoscaleStatus `json:"autoscale,omitempty"`
+	// HealthCheck contains the outcome of the custom health check
+	HealthCheck HealthCheckStatus `json:"healthCheck,omitempty"`
+	// TrafficRamp contains the progress of the traffic ramp after a failover
+	TrafficRamp TrafficRampStatus `json:"trafficRamp,omitempty"`
+	// ConnectionLogging contains until when the connections to the cluster
+	// are logged, if they temporarily are
+	ConnectionLogging ConnectionLoggingStatus `json:"connectionLogging,omitempty"`
+	// Fencing contains whether the primary is fenced as it lost the quorum of
+	// its replicas
+	Fencing FencingStatus `json:"fencing,omitempty"`
+	// ReconcileToken is the last value of the "crunchydata.com/reconcile-now"
+	// annotation that the cluster was reconciled for
+	ReconcileToken string `json:"reconcileToken,omitempty"`
+	// DataChecksums is whether data checksums are enabled on the primary, as
+	// reported by PostgreSQL, i.e. "on" or "off"
+	DataChecksums string `json:"dataChecksums,omitempty"`
+	// Databases is the number of databases of the cluster that accept
+	// connections, as last counted on the primary. The connection budget of
+	// pgBouncer is split among them
+	Databases int `json:"databases,omitempty"`
+	// TLSClientCAHash is the hash of the bundle of trusted CAs that PostgreSQL
+	// was last reloaded with for client certificate authentication
+	TLSClientCAHash string `json:"tlsClientCAHash,omitempty"`
+	// TLSCertHash is the hash of the certificate of the server that
+	// PostgreSQL was last reloaded with, when it is issued by cert-manager
+	TLSCertHash string `json:"tlsCertHash,omitempty"`
+	// TLSCertificates contains when the certificates that the Operator
+	// generates for the cluster expire
+	TLSCertificates TLSCertificatesStatus `json:"tlsCertificates,omitempty"`
+	// Locale contains the locale the cluster was initialized with
+	Locale LocaleStatus `json:"locale,omitempty"`
+	// BackrestRetention contains the outcome of the last expiry of the
+	// pgBackRest repository according to its retention
+	BackrestRetention BackrestRetentionStatus `json:"backrestRetention,omitempty"`
+	// BackrestVerification contains the outcome of the last verification of
+	// the pgBackRest repository
+	BackrestVerification BackrestVerificationStatus `json:"backrestVerification,omitempty"`
+	// PasswordRotation contains when the passwords of the PostgreSQL users
+	// were last rotated
+	PasswordRotation PasswordRotationStatus `json:"passwordRotation,omitempty"`
+	// PasswordEncryption is the password encryption that the users of the
+	// cluster were last moved to
+	PasswordEncryption PasswordEncryptionType `json:"passwordEncryption,omitempty"`
+	// PostgresParams contains the PostgreSQL parameters of the spec that were
+	// last applied, and whether the cluster needs to be restarted for them
+	PostgresParams PostgresParamsStatus `json:"postgresParams,omitempty"`
+	// MinorUpgrade contains the progress of the rolling update of the
+	// instances of the cluster to its image tag
+	MinorUpgrade MinorUpgradeStatus `json:"minorUpgrade,omitempty"`
+	// StorageAutogrow contains how full the volumes of the instances were
+	// when they were last checked, and when they were last grown
+	StorageAutogrow StorageAutogrowStatus `json:"storageAutogrow,omitempty"`
+	// PrimaryStorage contains the progress of the resize of the volume of
+	// the primary once its size in the spec changed
+	PrimaryStorage VolumeResizeStatus `json:"primaryStorage,omitempty"`
+	// Tablespaces are the names of the tablespaces that the Operator created
+	// in PostgreSQL, or found there
+	Tablespaces []string `json:"tablespaces,omitempty"`
+	// VolumeSnapshots contains the snapshots that the Operator takes of the
+	// volume of the primary on the schedule of the cluster
+	VolumeSnapshots VolumeSnapshotScheduleStatus `json:"volumeSnapshots,omitempty"`
+	// Hibernation contains when the cluster was last shut down or started up
+	// on its hibernation schedule
+	Hibernation HibernationStatus `json:"hibernation,omitempty"`
+	// ResourceRecommendation contains the CPU and memory usage of the
+	// database containers that the recommended resources are derived from
+	ResourceRecommendation ResourceRecommendationStatus `json:"resourceRecommendation,omitempty"`
+	// MaintenanceWindow contains the operations that wait for the
+	// maintenance window of the cluster to open
+	MaintenanceWindow MaintenanceWindowStatus `json:"maintenanceWindow,omitempty"`
+	// FailoverHealthCheck contains the outcome of the probes of the primary,
+	// and the failovers they triggered
+	FailoverHealthCheck FailoverHealthCheckStatus `json:"failoverHealthCheck,omitempty"`
+	// SyncReplication contains the replicas that the commits on the primary
+	// wait for, when synchronous replication is enabled
+	SyncReplication SyncReplicationStatus `json:"syncReplication,omitempty"`
+	// Conditions are the conditions of the cluster, such as whether its
+	// primary is ready, as they were last observed
+	Conditions []PgclusterCondition `json:"conditions,omitempty"`
 }
 
 // PgclusterState is the crd that defines PG Cluster Stage
 // swagger:ignore
 type PgclusterState string
 
+// PgclusterConditionType is the type of a condition of a cluster
+// swagger:ignore
+type PgclusterConditionType string
+
+// PgclusterCondition is a condition of a cluster, in the style of the
+// conditions of the Kubernetes API, so that e.g. "kubectl wait" can wait for
+// it
+// swagger:ignore
+type PgclusterCondition struct {
+	Type   PgclusterConditionType `json:"type"`
+	Status v1.ConditionStatus     `json:"status"`
+	// ObservedGeneration is the generation of the cluster that the condition
+	// was observed for
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// LastTransitionTime is when the status of the condition last changed
+	LastTransitionTime metav1.Time `json:"lastTransitionTime"`
+	// Reason is why the condition has its status, in CamelCase
+	Reason  string `json:"reason"`
+	Message string `json:"message"`
+}
+
 // PodAntiAffinityDeployment distinguishes between the different types of
 // Deployments that can leverage PodAntiAffinity
 type PodAntiAffinityDeployment int
@@ -139,12 +466,1674 @@ type TLSSpec struct {
 	// This follows the Kubernetes secret format ("kubernetes.io/tls") which has
 	// two keys: tls.crt and tls.key
 	TLSSecret string `json:"tlsSecret"`
+	// ClientAuth sets up users to authenticate with a client certificate
+	// instead of a password
+	ClientAuth TLSClientAuthSpec `json:"clientAuth"`
+	// CertManager has the Operator request the certificates of the cluster
+	// from cert-manager instead of TLSSecret and CASecret being created by
+	// hand
+	CertManager TLSCertManagerSpec `json:"certManager"`
+	// Generate has the Operator generate a CA for the cluster and issue the
+	// certificates of the cluster from it, for when cert-manager is not
+	// available
+	Generate TLSGenerateSpec `json:"generate"`
+}
+
+// TLSGenerateSpec contains how long the certificates that the Operator
+// generates for a cluster are valid for. The certificates are issued from a CA
+// of the cluster, and are issued again before they expire
+type TLSGenerateSpec struct {
+	// Enabled has the Operator generate the certificates of the cluster
+	Enabled bool `json:"enabled"`
+	// ValidityDays is how many days the certificates are valid for. Defaults
+	// to 90
+	ValidityDays int `json:"validityDays"`
+	// RenewBeforeDays is how many days before they expire that the
+	// certificates are issued again. Defaults to 30
+	RenewBeforeDays int `json:"renewBeforeDays"`
+}
+
+// TLSCertManagerSpec contains the cert-manager issuer that the certificates of
+// the PostgreSQL server, of the replication user and of pgBouncer are
+// requested from, and how long they are valid for. cert-manager renews the
+// certificates in their Secrets, which the Operator then reloads
+type TLSCertManagerSpec struct {
+	// IssuerName is the name of the Issuer or ClusterIssuer that signs the
+	// certificates. It has to provide its CA in "ca.crt", e.g. a CA or Vault
+	// issuer, as that is the CA the server is trusted with
+	IssuerName string `json:"issuerName"`
+	// IssuerKind is the kind of the issuer, i.e. "Issuer" or "ClusterIssuer".
+	// Defaults to "Issuer"
+	IssuerKind string `json:"issuerKind"`
+	// Duration is how long the certificates are valid for, e.g. "2160h". If it
+	// is not set, the default of cert-manager applies
+	Duration string `json:"duration"`
+	// RenewBefore is how long before they expire that the certificates are
+	// renewed, e.g. "360h". If it is not set, the default of cert-manager
+	// applies
+	RenewBefore string `json:"renewBefore"`
+}
+
+// TLSClientAuthSpec contains the users that authenticate with a client
+// certificate, i.e. "cert" authentication in pg_hba.conf, and the CA that the
+// client certificates are verified against
+type TLSClientAuthSpec struct {
+	// Users are the PostgreSQL users that authenticate with a client
+	// certificate. The common name of the certificate must match the name of
+	// the user
+	Users []string `json:"users"`
+	// CASecret contains the name of the secret with the CA that the client
+	// certificates are verified against, in the same format as the CASecret of
+	// the server. If it also contains a "tls.key", the CA can be used to issue
+	// client certificates
+	CASecret string `json:"caSecret"`
+	// PreviousCASecret contains the name of the secret with a CA that is still
+	// trusted while the clients migrate to certificates issued by CASecret.
+	// Once all of the clients have migrated, it should be removed
+	PreviousCASecret string `json:"previousCASecret"`
+	// IssueCertificates, if set, has the Operator issue a client certificate
+	// for each of the users from CASecret
+	IssueCertificates bool `json:"issueCertificates"`
+	// Replication, if set, has the instances replicate from each other with
+	// the client certificate of the replication user instead of its password.
+	// The certificate is issued along with the other certificates when the
+	// Operator manages them, and from CASecret otherwise, which then requires
+	// IssueCertificates
+	Replication bool `json:"replication"`
+	// Superuser, if set, has the superuser authenticate with a client
+	// certificate when connecting over the network
+	Superuser bool `json:"superuser"`
+	// MTLSOnly, if set, has every connection over the network authenticate
+	// with a client certificate, except for those of pgBouncer and those over
+	// the loopback interface
+	MTLSOnly bool `json:"mtlsOnly"`
+}
+
+// MaintenanceSpec contains the policy for the routine maintenance of a
+// PostgreSQL cluster, which consists of a "VACUUM (ANALYZE)" of every database
+// and optionally a "REINDEX DATABASE CONCURRENTLY"
+type MaintenanceSpec struct {
+	// Schedule is a cron formatted schedule of when to run the maintenance, which
+	// should be set for when the cluster is seeing low traffic. If it is not set,
+	// no maintenance is scheduled
+	Schedule string `json:"schedule"`
+	// Reindex, if set to true, rebuilds the indexes of each database after the
+	// vacuum. This requires PostgreSQL 12 or greater
+	Reindex bool `json:"reindex"`
+	// MaxActiveConnections is the number of active connections above which the
+	// cluster is considered to be too busy and maintenance is skipped. If it is
+	// not set, the Operator default is used
+	MaxActiveConnections int `json:"maxActiveConnections"`
+}
+
+// MaintenanceStatus contains information about the last time routine
+// maintenance was run against a PostgreSQL cluster
+type MaintenanceStatus struct {
+	// LastRun is when the maintenance was last started, in RFC3339 format
+	LastRun string `json:"lastRun,omitempty"`
+	// Duration is how long the last run of the maintenance took
+	Duration string `json:"duration,omitempty"`
+	// Result contains the outcome of the last run of the maintenance
+	Result string `json:"result,omitempty"`
+}
+
+// AutoscaleSpec contains the settings for adding replicas to a PostgreSQL
+// cluster and removing them again based on the number of active connections or
+// on the CPU usage of the instances
+type AutoscaleSpec struct {
+	// Enabled, if set to true, has the Operator periodically check the metric
+	// of the cluster, every 30 seconds, and add or remove replicas as needed
+	Enabled bool `json:"enabled"`
+	// Metric is what the autoscaler scales the cluster on, "connections" or
+	// "cpu". It defaults to connections
+	Metric AutoscaleMetric `json:"metric"`
+	// MinReplicas is the number of replicas the autoscaler does not scale the
+	// cluster below. Replicas are added until the cluster has that many, and
+	// only the replicas that the autoscaler added are ever removed
+	MinReplicas int `json:"minReplicas"`
+	// MaxReplicas is the number of replicas the autoscaler does not scale the
+	// cluster beyond. If it is not set, the connection signal is still
+	// published in the status of the cluster, but no replicas are added
+	MaxReplicas int `json:"maxReplicas"`
+	// ConnectionThreshold is the percentage of "max_connections" that the
+	// active connections per instance need to exceed for the cluster to be
+	// considered saturated
+	ConnectionThreshold int `json:"connectionThreshold"`
+	// CPUThreshold is the percentage of the CPU request of the database
+	// container that the CPU usage per instance needs to exceed for the
+	// cluster to be considered saturated when it is scaled on CPU. It
+	// defaults to 80
+	CPUThreshold int `json:"cpuThreshold"`
+	// ScaleDownPercent is the percentage of the threshold that the metric
+	// needs to stay below for the cluster to be considered underutilized, in
+	// which case a replica that the autoscaler added is removed. It defaults
+	// to 50
+	ScaleDownPercent int `json:"scaleDownPercent"`
+	// SustainedSeconds is how long the cluster needs to be saturated before a
+	// replica is added, or underutilized before one is removed, so that bursts
+	// are ignored
+	SustainedSeconds int `json:"sustainedSeconds"`
+	// CooldownSeconds is how long the autoscaler waits after adding or
+	// removing a replica before it adds or removes another one
+	CooldownSeconds int `json:"cooldownSeconds"`
+}
+
+// AutoscaleMetric is what a cluster is autoscaled on
+type AutoscaleMetric string
+
+const (
+	// AutoscaleMetricConnections scales on the active connections per
+	// instance, as a percentage of "max_connections"
+	AutoscaleMetricConnections AutoscaleMetric = "connections"
+	// AutoscaleMetricCPU scales on the CPU usage per instance, as a
+	// percentage of the CPU request of the database container, which is read
+	// from the resource metrics API, e.g. of the metrics-server
+	AutoscaleMetricCPU AutoscaleMetric = "cpu"
+)
+
+// default settings of the autoscaling of a cluster
+const (
+	DefaultAutoscaleConnectionThreshold = 80
+	DefaultAutoscaleCPUThreshold        = 80
+	DefaultAutoscaleScaleDownPercent    = 50
+)
+
+// GetMetric returns the metric that the cluster is scaled on, or its default if
+// it is not set
+func (s AutoscaleSpec) GetMetric() AutoscaleMetric {
+	if s.Metric == "" {
+		return AutoscaleMetricConnections
+	}
+	return s.Metric
+}
+
+// GetThreshold returns the percentage of the metric of the cluster above
+// which it is saturated, or its default if it is not set
+func (s AutoscaleSpec) GetThreshold() int {
+	threshold, fallback := s.ConnectionThreshold, DefaultAutoscaleConnectionThreshold
+	if s.GetMetric() == AutoscaleMetricCPU {
+		threshold, fallback = s.CPUThreshold, DefaultAutoscaleCPUThreshold
+	}
+
+	if threshold <= 0 {
+		return fallback
+	}
+	return threshold
+}
+
+// GetScaleDownThreshold returns the percentage of the metric of the cluster
+// below which it is underutilized
+func (s AutoscaleSpec) GetScaleDownThreshold() int {
+	percent := s.ScaleDownPercent
+	if percent <= 0 {
+		percent = DefaultAutoscaleScaleDownPercent
+	}
+	return s.GetThreshold() * percent / 100
+}
+
+// AutoscaleStatus contains the most recent connection and CPU signals of a
+// PostgreSQL cluster, which can be consumed by the Operator's autoscaler as well as any
+// other consumer of the pgcluster, such as a HorizontalPodAutoscaler adapter
+type AutoscaleStatus struct {
+	// ActiveConnectionsPerInstance is the average number of active connections
+	// across the ready instances of the cluster
+	ActiveConnectionsPerInstance int `json:"activeConnectionsPerInstance,omitempty"`
+	// Saturation is ActiveConnectionsPerInstance as a percentage of
+	// "max_connections"
+	Saturation int `json:"saturation,omitempty"`
+	// SaturatedSince is when the cluster became saturated, in RFC3339 format.
+	// It is empty when the cluster is not saturated
+	SaturatedSince string `json:"saturatedSince,omitempty"`
+	// CPUUtilization is the average CPU usage of the ready instances, as a
+	// percentage of the CPU request of the database container. It is only
+	// measured for a cluster that is scaled on CPU
+	CPUUtilization int `json:"cpuUtilization,omitempty"`
+	// UnderutilizedSince is when the cluster became underutilized, in RFC3339
+	// format. It is empty when the cluster is not underutilized
+	UnderutilizedSince string `json:"underutilizedSince,omitempty"`
+	// LastScaleTime is when the autoscaler last added or removed a replica,
+	// in RFC3339 format
+	LastScaleTime string `json:"lastScaleTime,omitempty"`
+}
+
+// AuditSpec contains the pgaudit settings of a PostgreSQL cluster
+type AuditSpec struct {
+	// Enabled, if set to true, loads pgaudit, which requires a restart of the
+	// cluster, and creates the pgaudit extension in every database
+	Enabled bool `json:"enabled"`
+	// Log contains the classes of statements to log, e.g. "ddl" or "write",
+	// which is set as "pgaudit.log". Changing it only requires a reload
+	Log []string `json:"log"`
+	// Role is the role used for object audit logging, which is set as
+	// "pgaudit.role"
+	Role string `json:"role"`
+	// ObjectRules are the privileges granted to the audit role, which determine
+	// what is logged by object audit logging
+	ObjectRules []AuditObjectRule `json:"objectRules"`
+}
+
+// AuditObjectRule is a privilege on an object that is granted to the audit
+// role, e.g. "SELECT, UPDATE" on "TABLE public.accounts", so that statements
+// using that privilege on the object are logged
+type AuditObjectRule struct {
+	// Database is the database that contains the object. It defaults to the
+	// database of the cluster
+	Database string `json:"database"`
+	// Privileges are the privileges on a table that are granted, separated by
+	// commas, e.g. "SELECT, UPDATE"
+	Privileges string `json:"privileges"`
+	// Object is the table that the privileges are granted on, which may be
+	// qualified by its schema and be preceded by "TABLE"
+	Object string `json:"object"`
+}
+
+// HealthCheckSpec contains a custom SQL health check of a PostgreSQL cluster,
+// which the Operator runs on the primary every 30 seconds
+type HealthCheckSpec struct {
+	// Query is run in the database of the cluster. It passes if it returns at
+	// least one row, and the first value of that row is not false, e.g.
+	// "SELECT EXISTS (SELECT 1 FROM accounts)"
+	Query string `json:"query"`
+	// TimeoutSeconds is how long the query may run before it is considered
+	// failed. It defaults to 10 seconds
+	TimeoutSeconds int `json:"timeoutSeconds"`
+	// FailureThreshold is the number of consecutive failures after which the
+	// cluster is marked as degraded. It defaults to 3
+	FailureThreshold int `json:"failureThreshold"`
+}
+
+// HealthCheckStatus contains the outcome of the custom health check of a
+// PostgreSQL cluster
+type HealthCheckStatus struct {
+	// Ready is true when the most recent health check passed
+	Ready bool `json:"ready"`
+	// LastTransitionTime is when the health check last started to pass or to
+	// fail, in RFC3339 format
+	LastTransitionTime string `json:"lastTransitionTime,omitempty"`
+	// ConsecutiveFailures is the number of times in a row the health check
+	// has failed
+	ConsecutiveFailures int `json:"consecutiveFailures,omitempty"`
+	// Message contains the reason the most recent health check failed
+	Message string `json:"message,omitempty"`
+	// Degraded is true once the health check failed as many times in a row as
+	// its failure threshold, until it passes again
+	Degraded bool `json:"degraded,omitempty"`
+}
+
+// DeletionProtectionSpec contains the safeguards that are applied when a
+// PostgreSQL cluster is deleted
+type DeletionProtectionSpec struct {
+	// BackupFirst, if set to true, has the Operator take a final pgBackRest
+	// backup of the cluster, and only remove the cluster once that backup has
+	// completed successfully. If the backup fails, the deletion is blocked until
+	// the force annotation is set on the cluster
+	BackupFirst bool `json:"backupFirst"`
+	// Cleanup, if set to true, has the Operator remove the resources of the
+	// cluster when its pgcluster is deleted directly, e.g. with kubectl. The
+	// pgcluster is held on to with a finalizer until they are removed
+	Cleanup bool `json:"cleanup"`
+	// RemoveData, if set to true along with Cleanup, also removes the PVCs and
+	// the Secrets of the cluster when its pgcluster is deleted directly.
+	// Otherwise they are kept, so that the cluster can be recreated from its
+	// data
+	RemoveData bool `json:"removeData"`
+}
+
+// ServiceDiscoverySpec contains the settings for publishing the primary
+// Service of a PostgreSQL cluster to another namespace
+type ServiceDiscoverySpec struct {
+	// Namespace is the consumer namespace in which the ExternalName Service is
+	// maintained. If it is not set, no ExternalName Service is created
+	Namespace string `json:"namespace"`
+	// ServiceName is the name of the ExternalName Service. It defaults to the
+	// name of the cluster
+	ServiceName string `json:"serviceName"`
+}
+
+// ServiceDiscoveryStatus contains the in-cluster address of the primary
+type ServiceDiscoveryStatus struct {
+	// Host is the fully qualified domain name of the primary Service
+	Host string `json:"host,omitempty"`
+	// Port is the port the primary Service listens on
+	Port string `json:"port,omitempty"`
+}
+
+// ServicesSpec contains the settings of each of the Services through which a
+// PostgreSQL cluster is reached
+type ServicesSpec struct {
+	// Primary is the Service of the primary, named after the cluster
+	Primary ServiceSpec `json:"primary"`
+	// Replica is the "<clusterName>-replica" Service of the replicas
+	Replica ServiceSpec `json:"replica"`
+	// PgBouncer is the Service of pgBouncer, as well as that of the read-only
+	// pgBouncer if it is enabled
+	PgBouncer ServiceSpec `json:"pgBouncer"`
+}
+
+// ServiceSpec contains how a Service of a PostgreSQL cluster is exposed
+type ServiceSpec struct {
+	// ServiceType is the type of the Service, i.e. ClusterIP, NodePort or
+	// LoadBalancer. It defaults to the service type of the cluster, or to
+	// ClusterIP for pgBouncer
+	ServiceType string `json:"serviceType"`
+	// NodePort is the port on the nodes that the PostgreSQL port of a NodePort
+	// or LoadBalancer Service is exposed on. It defaults to 0, which has
+	// Kubernetes pick one
+	NodePort int32 `json:"nodePort"`
+	// LoadBalancerSourceRanges are the CIDRs of the clients that a
+	// LoadBalancer Service accepts connections from. All clients are accepted
+	// if there are none
+	LoadBalancerSourceRanges []string `json:"loadBalancerSourceRanges,omitempty"`
+	// Annotations are set on the Service, e.g. to have a cloud provider create
+	// an internal load balancer for it
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// BackrestS3Spec contains the tuning of a pgBackRest repository that is stored
+// in S3 or other object storage. None of these settings change what is stored
+// in the repository, so backups taken with one set of values can be restored
+// with any other
+type BackrestS3Spec struct {
+	// URIStyle is either "host" or "path". Many S3 compatible object stores
+	// require the latter. It defaults to "host"
+	URIStyle string `json:"uriStyle"`
+	// PartSize is the size of the parts of a multipart upload, e.g. "64Mi". It
+	// has to be between 5Mi and 5Gi
+	PartSize string `json:"partSize"`
+	// Concurrency is the number of processes that transfer files at the same
+	// time
+	Concurrency int `json:"concurrency"`
+	// TimeoutSeconds is how long a request to the object storage may stall
+	// before it is considered failed
+	TimeoutSeconds int `json:"timeoutSeconds"`
+}
+
+// BackrestGCSSpec contains where a pgBackRest repository that is stored in
+// Google Cloud Storage is found. The service account key that the repository
+// is accessed with is the "gcs-key" of the pgBackRest Secret of the cluster
+type BackrestGCSSpec struct {
+	// Bucket is the GCS bucket of the repository
+	Bucket string `json:"bucket"`
+	// Endpoint is the endpoint of the object storage. It defaults to that of
+	// Google Cloud Storage
+	Endpoint string `json:"endpoint"`
+}
+
+// BackrestAzureSpec contains where a pgBackRest repository that is stored in
+// Azure Blob Storage is found. The storage account and its shared key that the
+// repository is accessed with are the "azure-account" and "azure-key" of the
+// pgBackRest Secret of the cluster
+type BackrestAzureSpec struct {
+	// Container is the Azure container of the repository
+	Container string `json:"container"`
+	// Endpoint is the endpoint of the object storage. It defaults to that of
+	// Azure Blob Storage
+	Endpoint string `json:"endpoint"`
+}
+
+// BackrestIdentitySpec contains the cloud identity that pgBackRest accesses a
+// repository in S3 or GCS with, i.e. IAM Roles for Service Accounts on EKS or
+// Workload Identity on GKE. The ServiceAccounts of the PostgreSQL instances
+// and of pgBackRest are annotated with the identity, and the keys of the
+// pgBackRest Secret of the cluster are not used
+type BackrestIdentitySpec struct {
+	// UseWorkloadIdentity has pgBackRest use the identity rather than keys
+	UseWorkloadIdentity bool `json:"useWorkloadIdentity"`
+	// AWSRoleARN is the IAM role that is assumed for a repository in S3
+	AWSRoleARN string `json:"awsRoleARN"`
+	// GCPServiceAccount is the Google service account that is impersonated
+	// for a repository in GCS, e.g. "backup@project.iam.gserviceaccount.com"
+	GCPServiceAccount string `json:"gcpServiceAccount"`
+}
+
+// BackrestSecretStoreSpec contains the secret store that the credentials of a
+// pgBackRest repository in S3 or GCS are mounted from, which is either a
+// SecretProviderClass of the Secrets Store CSI Driver or an ExternalSecret of
+// the External Secrets Operator. The store provides a pgBackRest
+// configuration file with the credentials, e.g. "credentials.conf", and for
+// GCS the service account key as "gcs-key"
+type BackrestSecretStoreSpec struct {
+	// SecretProviderClass is the SecretProviderClass the credentials are
+	// mounted from with the Secrets Store CSI Driver
+	SecretProviderClass string `json:"secretProviderClass"`
+	// ExternalSecret is the ExternalSecret whose Secret the credentials are
+	// mounted from. Its target Secret has to have the same name
+	ExternalSecret string `json:"externalSecret"`
+}
+
+// BackrestRetentionSpec contains how many backups the pgBackRest repository of
+// a cluster keeps. If any of the retention is set, the Operator periodically
+// runs "pgbackrest expire" with it, so that the backups and WAL archive beyond
+// the retention are removed even if no backup is taken to trigger the expiry
+type BackrestRetentionSpec struct {
+	// Full is the number of full backups to keep
+	Full int `json:"full"`
+	// Diff is the number of differential backups to keep
+	Diff int `json:"diff"`
+	// Archive is the number of backups to keep the WAL archive of, which are
+	// of the ArchiveType. It defaults to the full retention in pgBackRest
+	Archive int `json:"archive"`
+	// ArchiveType is the type of the backups the Archive retention counts:
+	// full, diff or incr. It defaults to full
+	ArchiveType string `json:"archiveType"`
+	// IntervalMinutes is how often the repository is expired. It defaults
+	// to 60 minutes
+	IntervalMinutes int `json:"intervalMinutes"`
+}
+
+// BackrestRetentionStatus contains the outcome of the last expiry of the
+// pgBackRest repository of a cluster
+type BackrestRetentionStatus struct {
+	// LastExpire is when the repository was last expired, in RFC 3339 format
+	LastExpire string `json:"lastExpire,omitempty"`
+	// Succeeded is whether the last expiry succeeded
+	Succeeded bool `json:"succeeded"`
+	// Message is why the last expiry failed, if it did
+	Message string `json:"message,omitempty"`
+}
+
+// PasswordRotationSpec contains how often the passwords of the PostgreSQL
+// users of a cluster are rotated. The postgres superuser and the user of the
+// cluster are given new generated passwords, which are set in PostgreSQL and
+// in their Secrets. The replication user is left as is, as the instances of
+// the cluster replicate with it
+type PasswordRotationSpec struct {
+	// IntervalDays is how often the passwords are rotated. The passwords are
+	// only rotated on demand if it is not set
+	IntervalDays int `json:"intervalDays"`
+	// PgBouncer, if set to true, has the password of the pgBouncer user
+	// rotated along with the others, which pgBouncer is given as well
+	PgBouncer bool `json:"pgBouncer"`
+}
+
+// PasswordRotationStatus contains when the passwords of the PostgreSQL users
+// of a cluster were last rotated
+type PasswordRotationStatus struct {
+	// LastRotation is when the passwords were last rotated, in RFC 3339
+	// format
+	LastRotation string `json:"lastRotation,omitempty"`
+	// LastAttempt is when the passwords were last tried to be rotated, in RFC
+	// 3339 format
+	LastAttempt string `json:"lastAttempt,omitempty"`
+	// RotateToken is the last value of the "crunchydata.com/rotate-password"
+	// annotation that the passwords were rotated for
+	RotateToken string `json:"rotateToken,omitempty"`
+	// Message is why the passwords could not be rotated, if they could not
+	Message string `json:"message,omitempty"`
+}
+
+// HBASpec contains custom host-based authentication rules of a cluster, in the
+// format of pg_hba.conf, e.g. "hostssl all all 10.0.0.0/8 scram-sha-256". The
+// rules are matched before the default rules, so they take precedence over
+// them
+type HBASpec struct {
+	// Rules are the rules that are added to pg_hba.conf, in the order they
+	// are matched
+	Rules []string `json:"rules"`
+	// Override, if set to true, has the Rules replace the default rules for
+	// connections over the network. The rules for local connections, which
+	// the Operator connects with, those for client certificate authentication
+	// and those for the replication user are kept
+	Override bool `json:"override"`
+}
+
+// PostgresTuningMode is whether the memory settings of PostgreSQL are derived
+// from the memory of the database container
+type PostgresTuningMode string
+
+const (
+	// PostgresTuningOff leaves the memory settings to PostgresParams and to
+	// the defaults of PostgreSQL
+	PostgresTuningOff PostgresTuningMode = "off"
+	// PostgresTuningAuto derives the memory settings from the memory limit of
+	// the database container, or from its memory request if it has no limit
+	PostgresTuningAuto PostgresTuningMode = "auto"
+)
+
+// PostgresParamsStatus contains the PostgreSQL parameters of the spec of a
+// cluster that were last applied
+type PostgresParamsStatus struct {
+	// Applied are the names of the parameters that were last applied. A
+	// parameter that is removed from the spec is reset to the value it
+	// replaced, or else to its default
+	Applied []string `json:"applied,omitempty"`
+	// Replaced are the values that the applied parameters replaced in the
+	// configuration of the cluster, e.g. those it was bootstrapped with
+	Replaced map[string]string `json:"replaced,omitempty"`
+	// PendingRestart are the names of the applied parameters that only take
+	// effect once the cluster is restarted, which it is being
+	PendingRestart []string `json:"pendingRestart,omitempty"`
+	// Message is why the parameters could not be applied, if they could not
+	Message string `json:"message,omitempty"`
+}
+
+// MinorUpgradeStatus contains the progress of the rolling update of the
+// instances of a cluster to the image tag of its spec, which the Operator
+// carries out when the image tag changes
+type MinorUpgradeStatus struct {
+	// ImageTag is the image tag that the instances are being, or were last,
+	// updated to
+	ImageTag string `json:"imageTag,omitempty"`
+	// Instances is the number of instances of the cluster
+	Instances int `json:"instances,omitempty"`
+	// UpdatedInstances are the names of the instances that run the image tag
+	UpdatedInstances []string `json:"updatedInstances,omitempty"`
+	// InProgress is whether the instances are being updated
+	InProgress bool `json:"inProgress,omitempty"`
+	// Message is why the update failed, if it did
+	Message string `json:"message,omitempty"`
+}
+
+// PasswordEncryptionType is how the passwords of the PostgreSQL users are
+// encrypted, which follows the "password_encryption" parameter of PostgreSQL
+type PasswordEncryptionType string
+
+const (
+	// PasswordEncryptionMD5 has the passwords stored as MD5 hashes
+	PasswordEncryptionMD5 PasswordEncryptionType = "md5"
+	// PasswordEncryptionSCRAMSHA256 has the passwords stored as SCRAM-SHA-256
+	// verifiers, which requires PostgreSQL 10 or later
+	PasswordEncryptionSCRAMSHA256 PasswordEncryptionType = "scram-sha-256"
+)
+
+// GetPasswordEncryption returns how the passwords of the PostgreSQL users of
+// the cluster are encrypted, which is "md5" unless it is set otherwise
+func (p PgclusterSpec) GetPasswordEncryption() PasswordEncryptionType {
+	if p.PasswordEncryption == "" {
+		return PasswordEncryptionMD5
+	}
+	return p.PasswordEncryption
+}
+
+// BackrestVerificationSpec contains whether and how often the pgBackRest
+// repository of a cluster is verified. Once enabled, the Operator periodically
+// runs a Job that runs "pgbackrest verify" against the repository, which checks
+// the backups and WAL archive in it against their checksums
+type BackrestVerificationSpec struct {
+	// Enabled is whether the repository is verified
+	Enabled bool `json:"enabled"`
+	// IntervalMinutes is how often the repository is verified. It defaults
+	// to 1440 minutes, i.e. once a day
+	IntervalMinutes int `json:"intervalMinutes"`
+}
+
+// BackrestVerificationStatus contains the outcome of the last verification of
+// the pgBackRest repository of a cluster
+type BackrestVerificationStatus struct {
+	// LastVerified is when the repository was last verified, in RFC 3339
+	// format
+	LastVerified string `json:"lastVerified,omitempty"`
+	// Succeeded is whether the last verification succeeded
+	Succeeded bool `json:"succeeded"`
+	// Message is why the last verification failed, if it did
+	Message string `json:"message,omitempty"`
+}
+
+// CloneSpec is the cluster that a new cluster is cloned from. A cluster that is
+// created with a source is not initialized empty: the pgBackRest repository of
+// the source is copied to the new cluster and restored, optionally to a point in
+// time, and only then is the cluster added. It has no effect once the cluster
+// is added
+type CloneSpec struct {
+	// SourceClusterName is the cluster in the same Namespace whose repository
+	// is restored
+	SourceClusterName string `json:"sourceClusterName"`
+	// BackrestStorageSource is the storage type of the repository of the
+	// source to restore from, "local" or "s3". It defaults to local
+	BackrestStorageSource string `json:"backrestStorageSource"`
+	// TargetTime is the time to recover to, e.g. "2020-06-01 12:00:00+00". If
+	// neither it nor TargetLSN is set, all of the WAL in the repository is
+	// replayed
+	TargetTime string `json:"targetTime"`
+	// TargetLSN is the log sequence number to recover to
+	TargetLSN string `json:"targetLSN"`
+	// TargetExclusive is whether the recovery stops just before the target
+	// rather than just after it
+	TargetExclusive bool `json:"targetExclusive"`
+	// Method is how the data of the source is copied, "pgbackrest" or
+	// "snapshot". A clone from a snapshot has the volume of the new primary
+	// created from a CSI VolumeSnapshot of the volume of the primary of the
+	// source, and falls back to a restore of the repository if it cannot be.
+	// It defaults to pgbackrest
+	Method CloneMethod `json:"method"`
+	// SnapshotName is a scheduled snapshot of the source to create the volume
+	// of the new primary from, rather than taking a new one. It requires the
+	// snapshot method
+	SnapshotName string `json:"snapshotName"`
+}
+
+// CloneMethod is how the data of the source of a clone is copied
+type CloneMethod string
+
+const (
+	// CloneMethodPgBackRest restores the pgBackRest repository of the source
+	CloneMethodPgBackRest CloneMethod = "pgbackrest"
+	// CloneMethodSnapshot creates the volume of the new primary from a
+	// VolumeSnapshot of the volume of the primary of the source
+	CloneMethodSnapshot CloneMethod = "snapshot"
+)
+
+// StandbyStreamingSpec is the remote primary a standby cluster streams the WAL
+// of. The standby cluster is still bootstrapped from its pgBackRest repository,
+// which is also where the WAL is replayed from while the stream is down
+type StandbyStreamingSpec struct {
+	// Host is the host name or address of the remote primary, e.g. that of
+	// its LoadBalancer Service. Streaming is disabled if it is not set
+	Host string `json:"host"`
+	// Port is the port of the remote primary. It defaults to 5432
+	Port string `json:"port"`
+	// ReplicationSecretName is the Secret in the Namespace of the cluster
+	// with the "username" and "password" of the replication user of the
+	// remote primary, which the cluster takes as its own replication user
+	ReplicationSecretName string `json:"replicationSecretName"`
+	// SSLMode is the libpq sslmode the WAL is streamed with: "require", the
+	// default, or "verify-ca" or "verify-full", which verify the remote
+	// primary against the CA of the cluster
+	SSLMode string `json:"sslMode"`
+}
+
+// BackrestRepoSpec is a pgBackRest repository of a cluster in addition to its
+// own. The WAL of the cluster is archived to every repository, while each
+// repository keeps its own backups on its own schedule and retention
+type BackrestRepoSpec struct {
+	// Index is the number of the repository to pgBackRest, from 2 to 4
+	Index int `json:"index"`
+	// Type is where the repository is stored: "s3", "gcs" or "azure"
+	Type string `json:"type"`
+	// Bucket is the S3 or GCS bucket, or the Azure container, that the
+	// repository is stored in
+	Bucket string `json:"bucket"`
+	// Endpoint is the endpoint of the object storage. It defaults to that of
+	// the storage type
+	Endpoint string `json:"endpoint"`
+	// Region is the region of an S3 bucket
+	Region string `json:"region"`
+	// Path is where the repository is stored within the bucket. It defaults
+	// to the path of repository 1
+	Path string `json:"path"`
+	// SecretName is the Secret with the credentials of the repository: "key"
+	// and "keySecret" for S3, "key" with the service account key for GCS, and
+	// "account" and "key" for Azure
+	SecretName string `json:"secretName"`
+	// RetentionFull is the number of full backups the repository keeps. If it
+	// is not set, the full backups are kept until they are expired manually
+	RetentionFull int `json:"retentionFull"`
+	// RetentionDiff is the number of differential backups the repository
+	// keeps
+	RetentionDiff int `json:"retentionDiff"`
+	// Schedule is when backups are taken to the repository, in cron syntax.
+	// If it is not set, backups are only taken to the repository on request
+	Schedule string `json:"schedule"`
+	// BackupType is the type of the scheduled backups: full, diff or incr.
+	// It defaults to full
+	BackupType string `json:"backupType"`
+}
+
+// TrafficRampSpec contains the settings for ramping up the connections to a
+// primary after a failover. The ramp limits the connections to each database
+// of the cluster, which does not apply to superusers, so the Operator and its
+// health checks can always connect
+type TrafficRampSpec struct {
+	// Enabled, if set to true, has the Operator ramp up the connections that
+	// are allowed to each database of a newly promoted primary
+	Enabled bool `json:"enabled"`
+	// DurationSeconds is how long it takes to ramp up to the full number of
+	// connections. It defaults to 300 seconds
+	DurationSeconds int `json:"durationSeconds"`
+	// InitialPercent is the percentage of the available connections that are
+	// allowed when the ramp starts. It defaults to 10, and at least one
+	// connection is always allowed
+	InitialPercent int `json:"initialPercent"`
+	// ReservedConnections are kept free of application connections during the
+	// ramp, on top of those PostgreSQL reserves for superusers. It defaults
+	// to 5
+	ReservedConnections int `json:"reservedConnections"`
+}
+
+// TrafficRampStatus contains the progress of the traffic ramp of a newly
+// promoted primary
+type TrafficRampStatus struct {
+	// Primary is the instance the connections are ramped up on
+	Primary string `json:"primary,omitempty"`
+	// StartTime is when the ramp started, in RFC3339 format. It is empty when
+	// no ramp is in progress
+	StartTime string `json:"startTime,omitempty"`
+	// ConnectionLimit is the number of connections that each database
+	// currently allows
+	ConnectionLimit int `json:"connectionLimit,omitempty"`
+	// Halted is true if the ramp stopped because the primary became
+	// unhealthy. A halted ramp stays where it is until it is disabled, or
+	// until the next failover starts a new one
+	Halted bool `json:"halted,omitempty"`
+	// Message describes why the ramp halted
+	Message string `json:"message,omitempty"`
+	// ConnectionLimits are the connection limits the databases had before the
+	// ramp, which are restored once it is done
+	ConnectionLimits map[string]int `json:"connectionLimits,omitempty"`
+}
+
+// FencingSpec contains the fencing policy of a PostgreSQL cluster, which has
+// the Operator make the primary read-only while fewer of its replicas than a
+// quorum are connected to it. The replicas are checked every 30 seconds
+type FencingSpec struct {
+	// Enabled, if set to true, has the Operator fence the primary when it
+	// loses the quorum of its replicas
+	Enabled bool `json:"enabled"`
+	// MinReplicas is the number of replicas that need to be connected to the
+	// primary. It defaults to a majority of the members of the cluster, not
+	// counting the primary, i.e. 1 out of 2 replicas and 2 out of 3
+	MinReplicas int `json:"minReplicas"`
+	// MaxLagBytes is how far behind the primary a replica can be and still
+	// count towards the quorum. It defaults to 0, which does not take the lag
+	// into account
+	MaxLagBytes int64 `json:"maxLagBytes"`
+	// GracePeriodSeconds is how long the quorum must be lost before the
+	// primary is fenced, so that a brief loss of connectivity does not fence
+	// it. It defaults to 60 seconds
+	GracePeriodSeconds int `json:"gracePeriodSeconds"`
+}
+
+// FailoverHealthCheckSpec contains how the Operator probes the primary of a
+// PostgreSQL cluster, which it does every 30 seconds, and when it fails the
+// cluster over. Unlike the failover of Patroni, which follows the leader lock
+// of the primary, this catches a primary that holds on to its lock while it
+// does not answer queries
+type FailoverHealthCheckSpec struct {
+	// Enabled, if set to true, has the Operator probe the primary and fail
+	// the cluster over once the probe fails the threshold of times in a row
+	Enabled bool `json:"enabled"`
+	// TimeoutSeconds is how long the probe may take before it is considered
+	// failed. It defaults to 10 seconds
+	TimeoutSeconds int `json:"timeoutSeconds"`
+	// FailureThreshold is the number of probes in a row that fail before the
+	// cluster is failed over. It defaults to 3
+	FailureThreshold int `json:"failureThreshold"`
+	// CooldownSeconds is how long after a failover the probes do not trigger
+	// another one, so that a cluster that is failing as a whole is not failed
+	// over again and again. It defaults to 600 seconds
+	CooldownSeconds int `json:"cooldownSeconds"`
+}
+
+// MaxReplicaLagSpec is how far the replicas of a PostgreSQL cluster may lag
+// behind the primary and still serve reads. A replica that is further behind
+// on either is taken out of the replica Service
+type MaxReplicaLagSpec struct {
+	// Bytes is how many bytes of WAL a replica may be behind the primary. It
+	// defaults to 0, which is no limit
+	Bytes int64 `json:"bytes"`
+	// Seconds is how long ago the last transaction that a replica replayed
+	// may have been committed, while it is behind the primary. It defaults to
+	// 0, which is no limit
+	Seconds int64 `json:"seconds"`
+}
+
+// ReadOnlyServiceSpec contains the settings of the "<clusterName>-replicas"
+// Service of a PostgreSQL cluster, whose Endpoints the Operator keeps to the
+// replicas that can serve reads
+type ReadOnlyServiceSpec struct {
+	// Enabled has the Operator create the Service, and remove it once it is
+	// disabled
+	Enabled bool `json:"enabled"`
+	// ServiceSpec contains the type, node port, load balancer source ranges
+	// and annotations of the Service. Its type defaults to the service type
+	// of the Operator
+	ServiceSpec `json:",inline"`
+	// SessionAffinity is "ClientIP" to keep sending the connections of a
+	// client to the same replica, or "None", the default, to spread them
+	// across the replicas
+	SessionAffinity string `json:"sessionAffinity"`
+	// SessionAffinityTimeoutSeconds is how long the connections of a client
+	// stick to the same replica when the session affinity is "ClientIP", up
+	// to 10800, i.e. 3 hours, which is the default
+	SessionAffinityTimeoutSeconds int32 `json:"sessionAffinityTimeoutSeconds"`
+	// IncludePrimary has the Service send the connections to the primary
+	// while none of the replicas can serve them
+	IncludePrimary bool `json:"includePrimary"`
+}
+
+// the pool modes of pgBouncer
+const (
+	// PgBouncerPoolModeSession returns a server connection to the pool once
+	// the client disconnects
+	PgBouncerPoolModeSession = "session"
+	// PgBouncerPoolModeTransaction returns a server connection to the pool
+	// once the transaction of the client finishes
+	PgBouncerPoolModeTransaction = "transaction"
+	// PgBouncerPoolModeStatement returns a server connection to the pool once
+	// the statement of the client finishes
+	PgBouncerPoolModeStatement = "statement"
+)
+
+// the connection poolers that a PostgreSQL cluster can be pooled through
+const (
+	// PoolerPgBouncer is pgBouncer, which is run from the crunchy-pgbouncer
+	// image
+	PoolerPgBouncer = "pgbouncer"
+	// PoolerOdyssey is Odyssey, which is run from the image of the spec
+	PoolerOdyssey = "odyssey"
+)
+
+// PgBouncerSpec contains the settings of the pgBouncer connection pooler of a
+// PostgreSQL cluster
+type PgBouncerSpec struct {
+	// GracefulRollout, if set to true, has a new pgBouncer image rolled out
+	// without dropping the connections in the middle of a transaction: a
+	// temporary pgBouncer takes on the new connections while the existing
+	// pgBouncer is drained with "PAUSE" before it is replaced
+	GracefulRollout bool `json:"gracefulRollout"`
+	// DrainTimeoutSeconds is how long "PAUSE" is given to drain a pgBouncer
+	// Pod, after which the rollout proceeds regardless, dropping whatever
+	// connections are left. It defaults to 30 seconds
+	DrainTimeoutSeconds int `json:"drainTimeoutSeconds"`
+	// Replicas is the number of pgBouncer Pods behind the pgBouncer Service.
+	// The pool sizes of the Pods are scaled down as Pods are added, so that
+	// together they stay within "max_connections". It defaults to 1
+	Replicas int `json:"replicas"`
+	// Resources are the CPU and memory requests and limits of the pgBouncer
+	// container. If none are set, the default pgBouncer resources of the
+	// Operator apply
+	Resources PgContainerResources `json:"resources"`
+	// PoolMode is the "pool_mode" of pgBouncer, i.e. "session", "transaction"
+	// or "statement". It defaults to "session"
+	PoolMode string `json:"poolMode"`
+	// MaxClientConn is the "max_client_conn" of each pgBouncer Pod, i.e. how
+	// many clients it accepts. It defaults to 100
+	MaxClientConn int `json:"maxClientConn"`
+	// Config contains additional settings of the "[pgbouncer]" section of
+	// pgbouncer.ini, e.g. "server_idle_timeout". The settings that the
+	// Operator manages itself cannot be set
+	Config map[string]string `json:"config"`
+	// TLSSecret is the name of a Secret with the keypair that pgBouncer
+	// accepts TLS connections with, in "tls.crt" and "tls.key", and its CA in
+	// "ca.crt". If it is not set, the keypair of pgBouncer is only issued
+	// when the certificates of the cluster are managed by the Operator
+	TLSSecret string `json:"tlsSecret"`
+	// Scheduling overrides how the pgBouncer Pods are scheduled
+	Scheduling SchedulingSpec `json:"scheduling"`
+	// ReadOnly deploys a second pgBouncer that pools connections to the
+	// replicas of the cluster, behind the "<clusterName>-pgbouncer-ro"
+	// Service. It has as many Pods and the same settings as the pgBouncer
+	// of the primary
+	ReadOnly bool `json:"readOnly"`
+	// Pooler is the connection pooler that is run, i.e. "pgbouncer" or
+	// "odyssey". It defaults to "pgbouncer", and can only be changed while
+	// the cluster has no connection pooler. Either pooler is reached through
+	// the same Services, and is configured from the same settings, except
+	// that Config holds settings of the global section of odyssey.conf
+	Pooler string `json:"pooler"`
+	// Image is the image of the connection pooler. It defaults to the
+	// crunchy-pgbouncer image of the image tag of the cluster, and has to be
+	// set for Odyssey
+	Image string `json:"image"`
+}
+
+// ExporterSpec contains the settings of the pgMonitor exporter, i.e. the
+// crunchy-collect container that is run alongside each instance and that
+// Prometheus scrapes the metrics of the instance from
+type ExporterSpec struct {
+	// Enabled runs the exporter alongside each instance. The exporter is run
+	// as well if the cluster has the "crunchy_collect" user label, which is
+	// how it was enabled before
+	Enabled bool `json:"enabled"`
+	// Resources are the CPU and memory requests and limits of the exporter
+	// container. If none are set, it runs without any
+	Resources PgContainerResources `json:"resources"`
+	// CustomQueries is the name of a ConfigMap with the queries that the
+	// exporter runs in place of its default queries, in "queries.yml". The
+	// exporter is restarted once they change
+	CustomQueries string `json:"customQueries"`
+}
+
+// the kinds of Prometheus Operator resources that can scrape the exporter
+const (
+	// MonitorKindServiceMonitor scrapes the exporter through the Services of
+	// the primary and the replicas
+	MonitorKindServiceMonitor = "ServiceMonitor"
+	// MonitorKindPodMonitor scrapes the exporter of the instance Pods directly
+	MonitorKindPodMonitor = "PodMonitor"
+)
+
+// MonitorSpec contains the Prometheus Operator resource that is created for
+// the exporter of a cluster
+type MonitorSpec struct {
+	// Kind is the kind of resource that is created, i.e. "ServiceMonitor" or
+	// "PodMonitor". None is created if it is not set
+	Kind string `json:"kind"`
+	// Labels are added to the labels of the resource, e.g. the labels that
+	// Prometheus selects its ServiceMonitors or PodMonitors by
+	Labels map[string]string `json:"labels"`
+	// Interval is how often Prometheus scrapes the exporter, e.g. "30s". If
+	// it is not set, the interval of Prometheus applies
+	Interval string `json:"interval"`
+	// TLS is how Prometheus verifies the exporter, if its metrics are served
+	// over HTTPS
+	TLS MonitorTLSSpec `json:"tls"`
+}
+
+// MonitorTLSSpec contains how Prometheus verifies an exporter that serves its
+// metrics over HTTPS. The exporter is scraped over HTTPS once either a CA or
+// InsecureSkipVerify is set
+type MonitorTLSSpec struct {
+	// CASecret is the name of a Secret with the CA, in "ca.crt", that the
+	// certificate of the exporter is verified with
+	CASecret string `json:"caSecret"`
+	// ServerName is the name that the certificate of the exporter is
+	// verified for, if it is not the address that is scraped
+	ServerName string `json:"serverName"`
+	// InsecureSkipVerify scrapes the exporter without verifying its
+	// certificate
+	InsecureSkipVerify bool `json:"insecureSkipVerify"`
+}
+
+// IsEnabled returns whether Prometheus scrapes the exporter over HTTPS
+func (t MonitorTLSSpec) IsEnabled() bool {
+	return t.CASecret != "" || t.InsecureSkipVerify
+}
+
+// NetworkPolicySpec contains whether the traffic to the instances and the
+// pgBackRest repository of a cluster is restricted with NetworkPolicies. Once
+// it is, the instances only accept the connections of one another, of the
+// pgBouncer and the pgBackRest repository of the cluster, and the scrapes of
+// the exporter, while the repository only accepts those of the instances
+type NetworkPolicySpec struct {
+	// Enabled creates the NetworkPolicies of the cluster
+	Enabled bool `json:"enabled"`
+	// AllowFrom are the peers that may connect to PostgreSQL as well, e.g. the
+	// applications that do not connect through pgBouncer
+	AllowFrom []networkingv1.NetworkPolicyPeer `json:"allowFrom"`
+}
+
+// PodDisruptionBudgetSpec contains whether the instances of a cluster are
+// protected by PodDisruptionBudgets. The primary is never evicted, while the
+// replicas are evicted one at a time, as the budget of the replicas is sized
+// from their number
+type PodDisruptionBudgetSpec struct {
+	// Enabled creates the PodDisruptionBudgets of the cluster
+	Enabled bool `json:"enabled"`
+}
+
+// SchedulingSpec contains how the Pods of a component of a cluster, e.g. its
+// pgBackRest repository, are scheduled where they differ from the Pods of the
+// rest of the cluster
+type SchedulingSpec struct {
+	// NodeAffinity replaces the node affinity of the cluster
+	NodeAffinity *v1.NodeAffinity `json:"nodeAffinity,omitempty"`
+	// Tolerations are added to the tolerations of the cluster. A toleration
+	// replaces a toleration of the cluster with the same key and effect
+	Tolerations []v1.Toleration `json:"tolerations,omitempty"`
+	// PriorityClassName replaces the PriorityClass of the cluster
+	PriorityClassName string `json:"priorityClassName,omitempty"`
+}
+
+// MetadataSpec contains the labels and annotations that are set on the
+// Deployments, Pods, Services, Secrets, ConfigMaps and PersistentVolumeClaims
+// of a cluster. Those of a component, e.g. pgBouncer, are set on its own
+// resources on top of those of the cluster, taking precedence over them. A
+// label or an annotation that a resource already has for another reason is
+// never overwritten
+type MetadataSpec struct {
+	// Labels are set on every resource of the cluster
+	Labels map[string]string `json:"labels,omitempty"`
+	// Annotations are set on every resource of the cluster
+	Annotations map[string]string `json:"annotations,omitempty"`
+	// Postgres is set on the resources of the PostgreSQL instances
+	Postgres ObjectMetadata `json:"postgres,omitempty"`
+	// PgBackRest is set on the resources of the pgBackRest repository
+	PgBackRest ObjectMetadata `json:"pgbackrest,omitempty"`
+	// PgBouncer is set on the resources of pgBouncer
+	PgBouncer ObjectMetadata `json:"pgbouncer,omitempty"`
+}
+
+// InstancePodSpec contains what is added to the Pods of the instances of a
+// cluster on top of what the Operator puts into them. It is kept in the Pods
+// as the instances are reconciled, and the instances are rolled out again
+// once it changes
+type InstancePodSpec struct {
+	// Containers are run alongside PostgreSQL, e.g. a log shipper or a proxy
+	Containers []v1.Container `json:"containers,omitempty"`
+	// InitContainers are run before PostgreSQL is started
+	InitContainers []v1.Container `json:"initContainers,omitempty"`
+	// Volumes are added to the volumes of the Pods, so that the containers
+	// or the volume mounts can mount them
+	Volumes []v1.Volume `json:"volumes,omitempty"`
+	// VolumeMounts are added to the PostgreSQL container
+	VolumeMounts []v1.VolumeMount `json:"volumeMounts,omitempty"`
+}
+
+// StorageAutogrowSpec contains whether and how the volumes of the instances of
+// a cluster are grown once they fill up. The Operator periodically reads how
+// full the volumes are from the Kubelets of the nodes they are mounted on, and
+// requests a larger size for a volume whose usage crosses the threshold, which
+// the StorageClass of the volume has to allow to be expanded
+type StorageAutogrowSpec struct {
+	// Enabled is whether the volumes are grown
+	Enabled bool `json:"enabled"`
+	// ThresholdPercent is how full a volume is, in percent, before it is
+	// grown. Defaults to 80
+	ThresholdPercent int `json:"thresholdPercent"`
+	// IncrementPercent is by how much a volume is grown, in percent of its
+	// current size. Defaults to 20, and a volume is grown by at least 1Gi
+	IncrementPercent int `json:"incrementPercent"`
+	// MaxSize is the size that a volume is never grown beyond, e.g. "500Gi"
+	MaxSize string `json:"maxSize"`
+}
+
+// default settings of the growth of the volumes of a cluster
+const (
+	DefaultStorageAutogrowThresholdPercent = 80
+	DefaultStorageAutogrowIncrementPercent = 20
+)
+
+// GetThresholdPercent returns the usage that a volume is grown at, or its
+// default if it is not set
+func (s StorageAutogrowSpec) GetThresholdPercent() int {
+	if s.ThresholdPercent < 1 || s.ThresholdPercent > 99 {
+		return DefaultStorageAutogrowThresholdPercent
+	}
+	return s.ThresholdPercent
+}
+
+// GetIncrementPercent returns by how much a volume is grown, or its default if
+// it is not set
+func (s StorageAutogrowSpec) GetIncrementPercent() int {
+	if s.IncrementPercent < 1 {
+		return DefaultStorageAutogrowIncrementPercent
+	}
+	return s.IncrementPercent
+}
+
+// StorageAutogrowStatus contains how full the volumes of the instances of a
+// cluster were when they were last checked
+type StorageAutogrowStatus struct {
+	Volumes []VolumeUsageStatus `json:"volumes,omitempty"`
+}
+
+// VolumeUsageStatus contains how full a volume of a cluster was when it was
+// last checked, and when it was last grown
+type VolumeUsageStatus struct {
+	// Name is the name of the PersistentVolumeClaim of the volume
+	Name string `json:"name"`
+	// UsedPercent is how full the volume was, in percent
+	UsedPercent int `json:"usedPercent"`
+	// Capacity is the size of the volume, e.g. "10Gi"
+	Capacity string `json:"capacity"`
+	// LastGrown is when a larger size was last requested for the volume, in
+	// RFC 3339 format
+	LastGrown string `json:"lastGrown,omitempty"`
+	// Message is why the volume is not grown even though it is full, if it
+	// is not
+	Message string `json:"message,omitempty"`
+}
+
+// VolumeResizeStatus contains the progress of the resize of the volume of an
+// instance, which is resized once its storage size in the spec changes
+type VolumeResizeStatus struct {
+	// Name is the name of the PersistentVolumeClaim of the volume
+	Name string `json:"name,omitempty"`
+	// Requested is the size that the volume requests, e.g. "20Gi"
+	Requested string `json:"requested,omitempty"`
+	// Capacity is the size that the volume has, which is lower than the
+	// requested size while the volume is being expanded
+	Capacity string `json:"capacity,omitempty"`
+	// Resizing is whether the volume is being expanded
+	Resizing bool `json:"resizing,omitempty"`
+	// FileSystemResizePending is whether the volume is expanded, but its file
+	// system is only resized once the Pod of the instance is restarted
+	FileSystemResizePending bool `json:"fileSystemResizePending,omitempty"`
+	// Message is why the volume is not resized, if it is not
+	Message string `json:"message,omitempty"`
+}
+
+// TablespaceSpec is a tablespace of a cluster, which is kept on a volume of its
+// own on each instance and mounted at "/tablespaces/<name>"
+type TablespaceSpec struct {
+	// Name is the name of the tablespace in PostgreSQL, which is also part of
+	// the names of its volumes
+	Name string `json:"name"`
+	// Storage is the storage of the volumes of the tablespace
+	Storage PgStorageSpec `json:"storage"`
+}
+
+// GetTablespaces returns the storage of the tablespaces of the cluster by
+// name, which are those of TablespaceMounts as well as those of Tablespaces
+func (p PgclusterSpec) GetTablespaces() map[string]PgStorageSpec {
+	tablespaces := map[string]PgStorageSpec{}
+
+	for name, storage := range p.TablespaceMounts {
+		tablespaces[name] = storage
+	}
+
+	for _, tablespace := range p.Tablespaces {
+		tablespaces[tablespace.Name] = tablespace.Storage
+	}
+
+	return tablespaces
+}
+
+// VolumeSnapshotSpec is how the Operator uses CSI VolumeSnapshots of the
+// volumes of a cluster, which requires the volumes to be provisioned by a CSI
+// driver that supports snapshots
+type VolumeSnapshotSpec struct {
+	// ClassName is the VolumeSnapshotClass that the snapshots are taken with.
+	// No snapshots are taken unless it is set
+	ClassName string `json:"className"`
+	// Replicas has the volume of a new replica created from a snapshot of the
+	// volume of the primary, rather than restored from the pgBackRest
+	// repository. If the snapshot cannot be taken or used, the replica is
+	// restored from the repository
+	Replicas bool `json:"replicas"`
+	// Schedule is when a snapshot is taken of the volume of the primary, in
+	// cron syntax. The scheduled snapshots are kept alongside the pgBackRest
+	// repository for a clone to be restored from quickly. If it is not set, no
+	// snapshots are scheduled
+	Schedule string `json:"schedule"`
+	// Retention is the number of scheduled snapshots that are kept, the
+	// oldest of which are removed once there are more. It defaults to 7
+	Retention int `json:"retention"`
+}
+
+// DefaultVolumeSnapshotRetention is the number of scheduled snapshots that are
+// kept of a cluster if its retention is not set
+const DefaultVolumeSnapshotRetention = 7
+
+// GetRetention returns the number of scheduled snapshots that are kept, or its
+// default if it is not set
+func (s VolumeSnapshotSpec) GetRetention() int {
+	if s.Retention < 1 {
+		return DefaultVolumeSnapshotRetention
+	}
+	return s.Retention
+}
+
+// VolumeSnapshotScheduleStatus contains the snapshots that the Operator took
+// of the volume of the primary of a cluster on its schedule
+type VolumeSnapshotScheduleStatus struct {
+	// LastScheduleTime is when a snapshot was last scheduled to be taken, in
+	// RFC 3339 format
+	LastScheduleTime string `json:"lastScheduleTime,omitempty"`
+	// Snapshots are the scheduled snapshots that are kept, newest first
+	Snapshots []ScheduledVolumeSnapshotStatus `json:"snapshots,omitempty"`
+	// Message is why the last scheduled snapshot could not be taken, if it
+	// could not
+	Message string `json:"message,omitempty"`
+}
+
+// ScheduledVolumeSnapshotStatus contains the state of a scheduled snapshot of
+// the volume of the primary of a cluster
+type ScheduledVolumeSnapshotStatus struct {
+	// Name is the name of the VolumeSnapshot, which a clone can be created
+	// from by its SnapshotName
+	Name string `json:"name"`
+	// PVC is the name of the PersistentVolumeClaim the snapshot is of
+	PVC string `json:"pvc"`
+	// CreationTime is when the snapshot was taken, in RFC 3339 format
+	CreationTime string `json:"creationTime,omitempty"`
+	// ReadyToUse is whether a volume can be provisioned from the snapshot
+	ReadyToUse bool `json:"readyToUse"`
+	// RestoreSize is the smallest size of a volume created from the snapshot
+	RestoreSize string `json:"restoreSize,omitempty"`
+	// Error is why the snapshot could not be taken, if it could not
+	Error string `json:"error,omitempty"`
+}
+
+// HibernationScheduleSpec contains when a cluster is shut down and started back
+// up, which is done by setting its Shutdown. A cluster that is started or shut
+// down by hand in between stays so until the next time it is scheduled to be
+type HibernationScheduleSpec struct {
+	// Shutdown is when the cluster is shut down, in cron syntax, e.g.
+	// "0 20 * * 1-5" for 20:00 on weekdays
+	Shutdown string `json:"shutdown"`
+	// Startup is when the cluster is started back up, in cron syntax, e.g.
+	// "0 7 * * 1-5" for 07:00 on weekdays
+	Startup string `json:"startup"`
+	// TimeZone is the time zone that the schedules are in, e.g.
+	// "Europe/Paris". It defaults to UTC
+	TimeZone string `json:"timeZone"`
+}
+
+// DefaultHibernationTimeZone is the time zone of the hibernation schedule of a
+// cluster if it does not have one
+const DefaultHibernationTimeZone = "UTC"
+
+// IsEnabled returns whether the cluster is shut down or started up on a
+// schedule
+func (s HibernationScheduleSpec) IsEnabled() bool {
+	return s.Shutdown != "" || s.Startup != ""
+}
+
+// GetTimeZone returns the time zone of the schedules, or its default if it is
+// not set
+func (s HibernationScheduleSpec) GetTimeZone() string {
+	if s.TimeZone == "" {
+		return DefaultHibernationTimeZone
+	}
+	return s.TimeZone
+}
+
+// HibernationStatus contains when a cluster was last shut down or started up
+// on its hibernation schedule
+type HibernationStatus struct {
+	// LastScheduleTime is when the schedule was last acted on, in RFC 3339
+	// format
+	LastScheduleTime string `json:"lastScheduleTime,omitempty"`
+	// LastAction is what the schedule last called for, "shutdown" or
+	// "startup"
+	LastAction HibernationAction `json:"lastAction,omitempty"`
+}
+
+// HibernationAction is what the hibernation schedule of a cluster calls for
+type HibernationAction string
+
+const (
+	// HibernationActionShutdown shuts the cluster down
+	HibernationActionShutdown HibernationAction = "shutdown"
+	// HibernationActionStartup starts the cluster back up
+	HibernationActionStartup HibernationAction = "startup"
+)
+
+// MaintenanceWindowSpec contains when the operations that disrupt a cluster
+// are carried out, which is between the start and the end time on each of the
+// days. A window that ends before it starts ends on the next day
+type MaintenanceWindowSpec struct {
+	// Days are the days of the week that the window opens on, e.g. "Sat" and
+	// "Sun". The window opens every day if there are none
+	Days []string `json:"days"`
+	// StartTime is when the window opens, e.g. "02:00"
+	StartTime string `json:"startTime"`
+	// EndTime is when the window closes, e.g. "04:00"
+	EndTime string `json:"endTime"`
+	// TimeZone is the time zone that the times are in, e.g. "Europe/Paris".
+	// It defaults to UTC
+	TimeZone string `json:"timeZone"`
+}
+
+// DefaultMaintenanceWindowTimeZone is the time zone of the maintenance window
+// of a cluster if it does not have one
+const DefaultMaintenanceWindowTimeZone = "UTC"
+
+// IsEnabled returns whether the cluster has a maintenance window, outside of
+// which its disruptive operations wait
+func (s MaintenanceWindowSpec) IsEnabled() bool {
+	return s.StartTime != "" || s.EndTime != ""
+}
+
+// GetTimeZone returns the time zone of the window, or its default if it is not
+// set
+func (s MaintenanceWindowSpec) GetTimeZone() string {
+	if s.TimeZone == "" {
+		return DefaultMaintenanceWindowTimeZone
+	}
+	return s.TimeZone
+}
+
+// MaintenanceWindowStatus contains the operations of a cluster that wait for
+// its maintenance window to open
+type MaintenanceWindowStatus struct {
+	// PendingActions are the operations that wait for the window, e.g.
+	// "resources" or "minor-upgrade"
+	PendingActions []string `json:"pendingActions,omitempty"`
+}
+
+// ResourceRecommendationSpec contains how the resources of the database
+// containers of a cluster are recommended, and whether they are applied
+type ResourceRecommendationSpec struct {
+	// Mode is "off", the default, "recommend", which annotates the cluster
+	// with the recommended resources, or "auto", which also applies them
+	Mode ResourceRecommendationMode `json:"mode"`
+	// MarginPercent is how much headroom is recommended on top of the
+	// observed peak usage, as a percentage of it. It defaults to 20
+	MarginPercent int `json:"marginPercent"`
+	// HalfLifeHours is how quickly a past peak of the usage is forgotten,
+	// i.e. the number of hours after which it counts for half. It defaults to
+	// 24
+	HalfLifeHours int `json:"halfLifeHours"`
+	// ApplySchedule is when the recommended resources are applied in "auto"
+	// mode, in cron syntax, e.g. "0 3 * * 0" for the maintenance window of
+	// 03:00 on Sundays. Applying them restarts the instances one at a time
+	ApplySchedule string `json:"applySchedule"`
+}
+
+// ResourceRecommendationMode is whether the resources of a cluster are
+// recommended, and whether they are applied
+type ResourceRecommendationMode string
+
+const (
+	// ResourceRecommendationOff neither observes nor recommends resources
+	ResourceRecommendationOff ResourceRecommendationMode = "off"
+	// ResourceRecommendationRecommend annotates the cluster with the
+	// recommended resources, which are left to be applied by hand
+	ResourceRecommendationRecommend ResourceRecommendationMode = "recommend"
+	// ResourceRecommendationAuto also applies the recommended resources on
+	// the apply schedule
+	ResourceRecommendationAuto ResourceRecommendationMode = "auto"
+)
+
+// default settings of the resource recommendation of a cluster
+const (
+	DefaultResourceRecommendationMarginPercent = 20
+	DefaultResourceRecommendationHalfLifeHours = 24
+)
+
+// IsEnabled returns whether the resources of the cluster are recommended
+func (s ResourceRecommendationSpec) IsEnabled() bool {
+	return s.Mode == ResourceRecommendationRecommend || s.Mode == ResourceRecommendationAuto
+}
+
+// GetMarginPercent returns the headroom that is recommended on top of the peak
+// usage, or its default if it is not set
+func (s ResourceRecommendationSpec) GetMarginPercent() int {
+	if s.MarginPercent <= 0 {
+		return DefaultResourceRecommendationMarginPercent
+	}
+	return s.MarginPercent
+}
+
+// GetHalfLifeHours returns the number of hours after which a past peak of the
+// usage counts for half, or its default if it is not set
+func (s ResourceRecommendationSpec) GetHalfLifeHours() int {
+	if s.HalfLifeHours <= 0 {
+		return DefaultResourceRecommendationHalfLifeHours
+	}
+	return s.HalfLifeHours
+}
+
+// ResourceRecommendationStatus contains the decaying peak CPU and memory usage
+// of the database containers of a cluster, and when the recommended resources
+// were last applied
+type ResourceRecommendationStatus struct {
+	// CPUPeak is the peak CPU usage of a database container, e.g. "250m"
+	CPUPeak string `json:"cpuPeak,omitempty"`
+	// MemoryPeak is the peak memory usage of a database container, e.g.
+	// "512Mi"
+	MemoryPeak string `json:"memoryPeak,omitempty"`
+	// ObservedSince is when the usage was first sampled, in RFC 3339 format
+	ObservedSince string `json:"observedSince,omitempty"`
+	// LastSampleTime is when the usage was last sampled, in RFC 3339 format
+	LastSampleTime string `json:"lastSampleTime,omitempty"`
+	// LastApplyTime is when the recommended resources were last applied, or
+	// when the apply schedule last came up, in RFC 3339 format
+	LastApplyTime string `json:"lastApplyTime,omitempty"`
+}
+
+// ObjectMetadata contains labels and annotations that are set on the
+// resources of a component of a cluster
+type ObjectMetadata struct {
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// AlertsSpec contains whether a PrometheusRule with the standard alerts is
+// created for a cluster, and the thresholds the alerts fire at. A threshold
+// that is not set falls back to its default
+type AlertsSpec struct {
+	// Enabled creates the PrometheusRule while the exporter is enabled
+	Enabled bool `json:"enabled"`
+	// Labels are added to the labels of every alert, e.g. the labels that
+	// Alertmanager routes the alerts of the cluster by
+	Labels map[string]string `json:"labels"`
+	// ReplicationLagSeconds is how far behind the primary a replica replays,
+	// in seconds, before it is alerted on. Defaults to 300
+	ReplicationLagSeconds int `json:"replicationLagSeconds"`
+	// DiskUsagePercent is how full a volume of the cluster is, in percent,
+	// before it is alerted on. Defaults to 80
+	DiskUsagePercent int `json:"diskUsagePercent"`
+	// ConnectionsPercent is how much of "max_connections" is in use, in
+	// percent, before it is alerted on. Defaults to 80
+	ConnectionsPercent int `json:"connectionsPercent"`
+	// BackupAgeHours is how long ago the last backup completed, in hours,
+	// before it is alerted on. Defaults to 25, i.e. a missed daily backup
+	BackupAgeHours int `json:"backupAgeHours"`
+}
+
+// default thresholds of the alerts of a cluster
+const (
+	DefaultAlertReplicationLagSeconds = 300
+	DefaultAlertDiskUsagePercent      = 80
+	DefaultAlertConnectionsPercent    = 80
+	DefaultAlertBackupAgeHours        = 25
+)
+
+// GetReplicationLagSeconds returns the replication lag threshold, or its
+// default if it is not set
+func (a AlertsSpec) GetReplicationLagSeconds() int {
+	if a.ReplicationLagSeconds < 1 {
+		return DefaultAlertReplicationLagSeconds
+	}
+	return a.ReplicationLagSeconds
+}
+
+// GetDiskUsagePercent returns the disk usage threshold, or its default if it
+// is not set
+func (a AlertsSpec) GetDiskUsagePercent() int {
+	if a.DiskUsagePercent < 1 {
+		return DefaultAlertDiskUsagePercent
+	}
+	return a.DiskUsagePercent
+}
+
+// GetConnectionsPercent returns the connections threshold, or its default if
+// it is not set
+func (a AlertsSpec) GetConnectionsPercent() int {
+	if a.ConnectionsPercent < 1 {
+		return DefaultAlertConnectionsPercent
+	}
+	return a.ConnectionsPercent
+}
+
+// GetBackupAgeHours returns the backup age threshold, or its default if it is
+// not set
+func (a AlertsSpec) GetBackupAgeHours() int {
+	if a.BackupAgeHours < 1 {
+		return DefaultAlertBackupAgeHours
+	}
+	return a.BackupAgeHours
+}
+
+// GetReplicas returns the number of pgBouncer Pods, which is at least one
+func (p PgBouncerSpec) GetReplicas() int {
+	if p.Replicas < 1 {
+		return 1
+	}
+
+	return p.Replicas
+}
+
+// GetPooler returns the connection pooler that is run, which defaults to
+// pgBouncer
+func (p PgBouncerSpec) GetPooler() string {
+	if p.Pooler == "" {
+		return PoolerPgBouncer
+	}
+
+	return p.Pooler
+}
+
+// GetPoolMode returns the "pool_mode" of pgBouncer, which defaults to "session"
+func (p PgBouncerSpec) GetPoolMode() string {
+	if p.PoolMode == "" {
+		return PgBouncerPoolModeSession
+	}
+
+	return p.PoolMode
+}
+
+// GetMaxClientConn returns the "max_client_conn" of each pgBouncer Pod, which
+// defaults to 100
+func (p PgBouncerSpec) GetMaxClientConn() int {
+	if p.MaxClientConn == 0 {
+		return 100
+	}
+
+	return p.MaxClientConn
+}
+
+// LocaleSpec contains the locale of a PostgreSQL cluster, which is set when
+// its data directory is initialized. Any of the settings that is left empty
+// takes the default of initdb
+type LocaleSpec struct {
+	// Collate is the "lc_collate" of the databases
+	Collate string `json:"collate"`
+	// Ctype is the "lc_ctype" of the databases
+	Ctype string `json:"ctype"`
+	// Encoding is the character set encoding of the databases, e.g. "UTF8"
+	Encoding string `json:"encoding"`
+}
+
+// LocaleStatus contains the locale a PostgreSQL cluster was initialized with
+type LocaleStatus struct {
+	// Initialized is the locale the data directory was initialized with
+	Initialized LocaleSpec `json:"initialized"`
+	// Error explains why the locale of the spec is not applied, which is the
+	// case if it was changed once the cluster was initialized
+	Error string `json:"error,omitempty"`
+}
+
+// FencingStatus contains the state of the fencing of the primary of a
+// PostgreSQL cluster
+type FencingStatus struct {
+	// Fenced is true while the primary is read-only as it lost the quorum of
+	// its replicas
+	Fenced bool `json:"fenced,omitempty"`
+	// Instance is the instance that is fenced
+	Instance string `json:"instance,omitempty"`
+	// ConnectedReplicas is the number of replicas that counted towards the
+	// quorum when the replicas were last checked
+	ConnectedReplicas int `json:"connectedReplicas,omitempty"`
+	// RequiredReplicas is the number of replicas that make up the quorum
+	RequiredReplicas int `json:"requiredReplicas,omitempty"`
+	// QuorumLostSince is when the quorum was lost, in RFC3339 format. It is
+	// empty while the primary has the quorum
+	QuorumLostSince string `json:"quorumLostSince,omitempty"`
+	// FencedSince is when the primary was fenced, in RFC3339 format
+	FencedSince string `json:"fencedSince,omitempty"`
+}
+
+// FailoverHealthCheckStatus contains the outcome of the probes of the primary
+// of a PostgreSQL cluster
+type FailoverHealthCheckStatus struct {
+	// LastCheck is when the primary was last probed, in RFC3339 format
+	LastCheck string `json:"lastCheck,omitempty"`
+	// ConsecutiveFailures is the number of times in a row the probe failed
+	ConsecutiveFailures int `json:"consecutiveFailures,omitempty"`
+	// Message is why the last probe failed, if it did
+	Message string `json:"message,omitempty"`
+	// LastFailoverTime is when the probes last triggered a failover, in
+	// RFC3339 format
+	LastFailoverTime string `json:"lastFailoverTime,omitempty"`
+	// FencedInstance is the old primary that was fenced when the cluster was
+	// failed over, until it is no longer the primary
+	FencedInstance string `json:"fencedInstance,omitempty"`
+}
+
+// SyncReplicationStatus contains the synchronous replication of a PostgreSQL
+// cluster as it was last applied
+type SyncReplicationStatus struct {
+	// Quorum is the number of replicas that each commit waits for, which is
+	// no more than the cluster has. It is 0 while Patroni picks the
+	// synchronous replica
+	Quorum int `json:"quorum,omitempty"`
+	// StandbyNames is the "synchronous_standby_names" that the Operator set
+	// for the quorum
+	StandbyNames string `json:"standbyNames,omitempty"`
+	// Replicas are the instances that are synchronous replicas, which are the
+	// only ones that the cluster fails over to unless the failover is forced.
+	// With a quorum of fewer than all of them, only the one that received the
+	// most WAL is failed over to, as any other can be missing commits
+	Replicas []string `json:"replicas,omitempty"`
+}
+
+// ConnectionLoggingStatus contains the state of the temporary connection
+// logging of a PostgreSQL cluster, which is turned on with the
+// "connection-logging-until" annotation
+type ConnectionLoggingStatus struct {
+	// ActiveUntil is when the connection logging is turned off again, in
+	// RFC3339 format. It is empty when the connection logging is not on
+	ActiveUntil string `json:"activeUntil,omitempty"`
+	// LogConnections is the value "log_connections" had before the
+	// connection logging was turned on, which it is reverted to
+	LogConnections string `json:"logConnections,omitempty"`
+	// LogDisconnections is the value "log_disconnections" had before the
+	// connection logging was turned on, which it is reverted to
+	LogDisconnections string `json:"logDisconnections,omitempty"`
+}
+
+// TLSCertificatesStatus contains when the certificates that the Operator
+// generates for a PostgreSQL cluster expire, in RFC3339 format
+type TLSCertificatesStatus struct {
+	// CANotAfter is when the CA of the cluster expires
+	CANotAfter string `json:"caNotAfter,omitempty"`
+	// NotAfter is when the first of the certificates issued from the CA
+	// expires
+	NotAfter string `json:"notAfter,omitempty"`
 }
 
 // IsTLSEnabled returns true if the cluster is TLS enabled, i.e. both the TLS
-// secret name and the CA secret name are available
+// secret name and the CA secret name are available, or the certificates are
+// managed by the Operator
 func (t TLSSpec) IsTLSEnabled() bool {
-	return (t.TLSSecret != "" && t.CASecret != "")
+	return t.IsManaged() || (t.TLSSecret != "" && t.CASecret != "")
+}
+
+// IsManaged returns true if the certificates of the cluster are managed by the
+// Operator, i.e. requested from cert-manager or generated by the Operator
+func (t TLSSpec) IsManaged() bool {
+	return t.CertManager.IsEnabled() || t.Generate.Enabled
+}
+
+// GetValidityDays returns how many days the generated certificates are valid
+// for, which defaults to 90
+func (t TLSGenerateSpec) GetValidityDays() int {
+	if t.ValidityDays == 0 {
+		return 90
+	}
+
+	return t.ValidityDays
+}
+
+// GetRenewBeforeDays returns how many days before they expire that the
+// generated certificates are issued again, which defaults to 30
+func (t TLSGenerateSpec) GetRenewBeforeDays() int {
+	if t.RenewBeforeDays == 0 {
+		return 30
+	}
+
+	return t.RenewBeforeDays
+}
+
+// IsEnabled returns true if the certificates of the cluster are requested from
+// cert-manager, i.e. there is an issuer to request them from
+func (t TLSCertManagerSpec) IsEnabled() bool {
+	return t.IssuerName != ""
+}
+
+// IsEnabled returns true if client certificate authentication is set up, i.e.
+// there is a CA to verify the client certificates against
+func (t TLSClientAuthSpec) IsEnabled() bool {
+	return t.CASecret != ""
+}
+
+// GetUsers returns the users that authenticate with a client certificate, which
+// include the superuser if it is set to
+func (t TLSClientAuthSpec) GetUsers() []string {
+	users := append([]string{}, t.Users...)
+
+	if t.Superuser {
+		for _, user := range users {
+			if user == PGUserSuperuser {
+				return users
+			}
+		}
+		users = append(users, PGUserSuperuser)
+	}
+
+	return users
+}
+
+// UsesCertAuth returns true if any connection to the cluster authenticates
+// with a client certificate, i.e. there is a client CA or the instances
+// replicate with a client certificate
+func (t TLSClientAuthSpec) UsesCertAuth() bool {
+	return t.IsEnabled() || t.Replication
 }
 
 const (
@@ -156,10 +2145,34 @@ const (
 	PgclusterStateInitialized PgclusterState = "pgcluster Initialized"
 	// PgclusterStateRestore ...
 	PgclusterStateRestore PgclusterState = "pgcluster Restoring"
+	// PgclusterStateCloning indicates that the cluster is being bootstrapped from
+	// the pgBackRest repository of the cluster it is cloned from
+	PgclusterStateCloning PgclusterState = "pgcluster Cloning"
 	// PgclusterStateShutdown indicates that the cluster has been shut down (i.e. the primary)
 	// deployment has been scaled to 0
 	PgclusterStateShutdown PgclusterState = "pgcluster Shutdown"
 
+	// PgclusterConditionInitialized is whether the cluster has been initialized
+	PgclusterConditionInitialized PgclusterConditionType = "Initialized"
+	// PgclusterConditionPrimaryReady is whether the primary of the cluster is
+	// ready
+	PgclusterConditionPrimaryReady PgclusterConditionType = "PrimaryReady"
+	// PgclusterConditionReplicasReady is whether all of the replicas of the
+	// cluster are ready
+	PgclusterConditionReplicasReady PgclusterConditionType = "ReplicasReady"
+	// PgclusterConditionBackupRepoReady is whether the pgBackRest repository of
+	// the cluster is ready
+	PgclusterConditionBackupRepoReady PgclusterConditionType = "BackupRepoReady"
+	// PgclusterConditionDegraded is whether a running cluster is missing any
+	// of its primary, replicas or pgBackRest repository
+	PgclusterConditionDegraded PgclusterConditionType = "Degraded"
+	// PgclusterConditionLastBackupVerified is whether the last verification of
+	// the pgBackRest repository of the cluster succeeded
+	PgclusterConditionLastBackupVerified PgclusterConditionType = "LastBackupVerified"
+	// PgclusterConditionUpgrading is whether the instances of the cluster are
+	// being updated to its image tag
+	PgclusterConditionUpgrading PgclusterConditionType = "Upgrading"
+
 	// PodAntiAffinityRequired results in requiredDuringSchedulingIgnoredDuringExecution for any
 	// default pod anti-affinity rules applied to pg custers
 	PodAntiAffinityRequired PodAntiAffinityType = "required"
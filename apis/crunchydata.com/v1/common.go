@@ -131,6 +131,14 @@ type PgContainerResources struct {
 	LimitsCPU      string `json:"limitscpu"`
 }
 
+// PgEphemeralStorageSpec contains the ephemeral storage request and limit of
+// a container, e.g. "1Gi"
+// swagger:ignore
+type PgEphemeralStorageSpec struct {
+	Requests string `json:"requests"`
+	Limits   string `json:"limits"`
+}
+
 // CompletedStatus -
 const CompletedStatus = "completed"
 
@@ -0,0 +1,94 @@
+package v1
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PgdatabaseResourcePlural ...
+const PgdatabaseResourcePlural = "pgdatabases"
+
+// PgdatabaseSpec is the database that is kept in a cluster, along with its
+// owner and the extensions and schemas that are created in it
+// swagger:ignore
+type PgdatabaseSpec struct {
+	// ClusterName is the name of the cluster the database is kept in
+	ClusterName string `json:"clustername"`
+	// Name is the name of the database. It defaults to the name of the
+	// pgdatabase
+	Name string `json:"name"`
+	// Owner is the role that owns the database and the schemas that are
+	// created in it. It defaults to the superuser
+	Owner string `json:"owner"`
+	// Encoding is the character set encoding of the database, e.g. "UTF8".
+	// It defaults to the encoding of the cluster, and can only be set when
+	// the database is created
+	Encoding string `json:"encoding"`
+	// Extensions are the extensions that are created in the database if they
+	// do not exist
+	Extensions []string `json:"extensions"`
+	// Schemas are the schemas that are created in the database if they do not
+	// exist, owned by the owner of the database
+	Schemas []string `json:"schemas"`
+}
+
+// Pgdatabase is a database of a cluster that is created and updated by the
+// Operator to match its spec
+// swagger:ignore
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+type Pgdatabase struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata"`
+
+	Spec   PgdatabaseSpec   `json:"spec"`
+	Status PgdatabaseStatus `json:"status,omitempty"`
+}
+
+// PgdatabaseList ...
+// swagger:ignore
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+type PgdatabaseList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+
+	Items []Pgdatabase `json:"items"`
+}
+
+// PgdatabaseStatus is whether the database of a pgdatabase matches its spec
+// swagger:ignore
+type PgdatabaseStatus struct {
+	// State is whether the database was reconciled, or failed to be
+	State PgdatabaseState `json:"state,omitempty"`
+	// Message is why the database could not be reconciled, if it could not
+	Message string `json:"message,omitempty"`
+	// LastReconciled is when the database was last reconciled, in RFC 3339
+	// format
+	LastReconciled string `json:"lastReconciled,omitempty"`
+}
+
+// PgdatabaseState is the state of the database of a pgdatabase
+type PgdatabaseState string
+
+const (
+	// PgdatabaseStateReconciled is the state of a database that matches its
+	// spec
+	PgdatabaseStateReconciled PgdatabaseState = "reconciled"
+	// PgdatabaseStateFailed is the state of a database that could not be
+	// reconciled, which is tried again periodically
+	PgdatabaseStateFailed PgdatabaseState = "failed"
+)
@@ -51,12 +51,18 @@ func addKnownTypes(scheme *runtime.Scheme) error {
 	scheme.AddKnownTypes(SchemeGroupVersion,
 		&Pgcluster{},
 		&PgclusterList{},
+		&Pgdatabase{},
+		&PgdatabaseList{},
 		&Pgreplica{},
 		&PgreplicaList{},
+		&Pgschedule{},
+		&PgscheduleList{},
 		&Pgpolicy{},
 		&PgpolicyList{},
 		&Pgtask{},
 		&PgtaskList{},
+		&Pguser{},
+		&PguserList{},
 	)
 	metav1.AddToGroupVersion(scheme, SchemeGroupVersion)
 	return nil
@@ -43,6 +43,14 @@ type PgreplicaSpec struct {
 	ContainerResources PgContainerResources `json:"containerresources"`
 	Status             string               `json:"status"`
 	UserLabels         map[string]string    `json:"userlabels"`
+	RuntimeClassName   string               `json:"runtimeClassName"`
+	// RecoveryMinApplyDelay, e.g. "1h", holds the replica back from applying
+	// the changes of the primary until they are at least that old. A delayed
+	// replica is neither failed over to nor serves reads
+	RecoveryMinApplyDelay string `json:"recoveryMinApplyDelay"`
+	// Upstream is another replica of the cluster that the replica streams
+	// from rather than from the primary
+	Upstream string `json:"upstream"`
 }
 
 // PgreplicaList ...
@@ -60,6 +68,40 @@ type PgreplicaList struct {
 type PgreplicaStatus struct {
 	State   PgreplicaState `json:"state,omitempty"`
 	Message string         `json:"message,omitempty"`
+	// Node is the node that the replica was last seen running on
+	Node string `json:"node,omitempty"`
+	// Zone is the availability zone of the node, if the node is labeled with
+	// one
+	Zone string `json:"zone,omitempty"`
+	// ReplicaStorage contains the progress of the resize of the volume of the
+	// replica once its size in the spec changed
+	ReplicaStorage VolumeResizeStatus `json:"replicaStorage,omitempty"`
+	// RecoveryMinApplyDelay is the delay that was last applied to the
+	// replica
+	RecoveryMinApplyDelay string `json:"recoveryMinApplyDelay,omitempty"`
+	// Upstream is the replica that the replica streams from, which is its
+	// upstream or, while that is not available, the nearest upstream of it
+	// that is. It is empty while the replica streams from the primary
+	Upstream string `json:"upstream,omitempty"`
+	// Lag is how far the replica was behind the primary when it was last
+	// checked
+	Lag ReplicaLagStatus `json:"lag,omitempty"`
+}
+
+// ReplicaLagStatus contains how far a replica is behind the primary of its
+// cluster
+// swagger:ignore
+type ReplicaLagStatus struct {
+	// Bytes is how many bytes of WAL the replica has yet to replay
+	Bytes int64 `json:"bytes"`
+	// Seconds is how long ago the last transaction that the replica replayed
+	// was committed, or 0 if it has replayed all the WAL it received
+	Seconds int64 `json:"seconds"`
+	// LastCheck is when the lag was last checked
+	LastCheck string `json:"lastCheck,omitempty"`
+	// Evicted is whether the replica is out of the replica Service because
+	// it lags further behind than the cluster allows
+	Evicted bool `json:"evicted,omitempty"`
 }
 
 // PgreplicaState ...
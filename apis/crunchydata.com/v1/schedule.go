@@ -0,0 +1,91 @@
+package v1
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PgscheduleResourcePlural ...
+const PgscheduleResourcePlural = "pgschedules"
+
+// PgscheduleSpec is the schedule on which pgBackRest backups of a cluster
+// are taken
+// swagger:ignore
+type PgscheduleSpec struct {
+	// ClusterName is the name of the cluster that is backed up
+	ClusterName string `json:"clustername"`
+	// Schedule is when the backups are taken, in cron syntax, e.g. "0 1 * * *"
+	// for every day at 1am
+	Schedule string `json:"schedule"`
+	// BackupType is the type of the pgBackRest backups: full, diff or incr
+	BackupType string `json:"backupType"`
+	// Retention is the number of full backups that pgBackRest keeps. If it is
+	// not set, the retention of the pgBackRest configuration applies
+	Retention int `json:"retention"`
+	// StorageType is where the backups are stored: local, s3 or both, e.g.
+	// "local,s3". It defaults to the pgBackRest storage type of the cluster
+	StorageType string `json:"storageType"`
+	// Options are additional options of the pgBackRest backups, which are
+	// validated like those of "pgo backup"
+	Options string `json:"options"`
+	// Suspend, if set to true, stops backups from being taken on the schedule
+	Suspend bool `json:"suspend"`
+}
+
+// Pgschedule is a schedule on which pgBackRest backups of a cluster are
+// taken
+// swagger:ignore
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+type Pgschedule struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata"`
+
+	Spec   PgscheduleSpec   `json:"spec"`
+	Status PgscheduleStatus `json:"status,omitempty"`
+}
+
+// PgscheduleList ...
+// swagger:ignore
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+type PgscheduleList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+
+	Items []Pgschedule `json:"items"`
+}
+
+// PgscheduleStatus is when a backup was last taken on the schedule
+// swagger:ignore
+type PgscheduleStatus struct {
+	// LastScheduleTime is when the last backup was scheduled, in RFC 3339
+	// format
+	LastScheduleTime string `json:"lastScheduleTime,omitempty"`
+	// LastTask is the name of the pgtask of the last backup
+	LastTask string `json:"lastTask,omitempty"`
+	// Message is why the last backup could not be scheduled, if it could not
+	Message string `json:"message,omitempty"`
+}
+
+const (
+	// PgscheduleBackupTypeFull takes full pgBackRest backups
+	PgscheduleBackupTypeFull = "full"
+	// PgscheduleBackupTypeDiff takes differential pgBackRest backups
+	PgscheduleBackupTypeDiff = "diff"
+	// PgscheduleBackupTypeIncr takes incremental pgBackRest backups
+	PgscheduleBackupTypeIncr = "incr"
+)
@@ -31,6 +31,102 @@ const PgtaskFailover = "failover"
 const PgtaskAutoFailover = "autofailover"
 const PgtaskAddPolicies = "addpolicies"
 const PgtaskMinorUpgrade = "minorupgradecluster"
+const PgtaskMaintenance = "maintenance"
+const PgtaskMajorUpgrade = "majorupgradecluster"
+
+// the parameters accepted by a major upgrade pgtask. "force" allows the
+// upgrade to proceed even if the pg_upgrade precheck reports a problem
+const PgtaskMajorUpgradeForce = "force"
+const PgtaskMajorUpgradeCCPImageTag = "CCPImageTag"
+const PgtaskMajorUpgradeCurrentVersion = "currentPGVersion"
+const PgtaskMajorUpgradeTargetVersion = "targetPGVersion"
+
+// the statuses a major upgrade pgtask moves through while the precheck, i.e.
+// "pg_upgrade --check", is run against a clone of the primary's data
+const PgtaskMajorUpgradePrecheckInProgress = "precheck in progress"
+const PgtaskMajorUpgradePrecheckPassed = "precheck passed"
+const PgtaskMajorUpgradePrecheckFailed = "precheck failed"
+const PgtaskMajorUpgradePrecheckForced = "precheck failed, proceeding with force"
+
+// the statuses a major upgrade pgtask moves through once the precheck is cleared:
+// the cluster is stopped and "pg_upgrade --link" is run against the data of the
+// primary, after which the primary is started on the new version and the
+// replicas are rebuilt from a new backup. The primary of the cluster when it was
+// stopped is recorded, so it is the instance that is started again
+const PgtaskMajorUpgradeInProgress = "upgrade in progress"
+const PgtaskMajorUpgradeRebuildingReplicas = "rebuilding replicas"
+const PgtaskMajorUpgradeCompleted = "upgrade completed"
+const PgtaskMajorUpgradeFailed = "upgrade failed"
+const PgtaskMajorUpgradePrimaryInstance = "primaryInstance"
+
+const PgtaskEnableDataChecksums = "enable-data-checksums"
+
+// the parameters of an enable data checksums pgtask. The strategy is either
+// "offline", which requires the cluster to be shut down, or "replica-first",
+// which keeps the cluster up by enabling the checksums on one replica at a
+// time and switching over to one of them before the primary is done. The
+// instances that are done are recorded as the task progresses
+const PgtaskDataChecksumsStrategy = "strategy"
+const PgtaskDataChecksumsStrategyOffline = "offline"
+const PgtaskDataChecksumsStrategyReplicaFirst = "replica-first"
+const PgtaskDataChecksumsCurrentInstance = "currentInstance"
+const PgtaskDataChecksumsCompletedInstances = "completedInstances"
+
+// the statuses of an enable data checksums pgtask
+const PgtaskDataChecksumsInProgress = "enabling data checksums"
+const PgtaskDataChecksumsCompleted = "data checksums enabled"
+const PgtaskDataChecksumsFailed = "enabling data checksums failed"
+
+const PgtaskRollingRestart = "rolling-restart"
+
+// the parameters of a rolling restart pgtask. The replicas are restarted one
+// at a time, after which the primary is switched over to one of them and
+// restarted as well. The instances that are done are recorded as the task
+// progresses
+const PgtaskRollingRestartCompletedInstances = "completedInstances"
+
+// the statuses of a rolling restart pgtask
+const PgtaskRollingRestartInProgress = "rolling restart in progress"
+const PgtaskRollingRestartCompleted = "rolling restart completed"
+const PgtaskRollingRestartFailed = "rolling restart failed"
+
+const PgtaskSwitchover = "switchover"
+
+// the statuses of a switchover pgtask. The replica that takes over is named by
+// the "target" label of the pgtask, the same as for a failover, and is
+// otherwise the replica that is least behind the primary, which is then
+// recorded in the label
+const PgtaskSwitchoverInProgress = "switchover in progress"
+const PgtaskSwitchoverCompleted = "switchover completed"
+const PgtaskSwitchoverFailed = "switchover failed"
+
+const PgtaskBackrestPITR = "backrest-pitr"
+
+// the parameters of a point-in-time recovery pgtask. Exactly one of the target
+// time, being a PostgreSQL timestamp such as "2020-06-01 11:25:42.582117-04",
+// or the target LSN, such as "0/3000060", is given. "targetExclusive" stops
+// the recovery just before the target rather than just after it
+const PgtaskPITRTargetTime = "targetTime"
+const PgtaskPITRTargetLSN = "targetLSN"
+const PgtaskPITRTargetExclusive = "targetExclusive"
+
+// the statuses of a point-in-time recovery pgtask. The steps of the restore
+// workflow are also recorded in its parameters as they are reached
+const PgtaskPITRInProgress = "point-in-time recovery in progress"
+const PgtaskPITRCompleted = "point-in-time recovery completed"
+const PgtaskPITRFailed = "point-in-time recovery failed"
+
+const PgtaskPromoteStandby = "promote-standby"
+
+// the parameters of a promote standby pgtask. "serviceType" optionally changes
+// the type of the Services of the promoted cluster, e.g. to "LoadBalancer" so
+// that it can be reached from outside of the Kubernetes cluster it runs in
+const PgtaskPromoteStandbyServiceType = "serviceType"
+
+// the statuses of a promote standby pgtask
+const PgtaskPromoteStandbyInProgress = "standby promotion in progress"
+const PgtaskPromoteStandbyCompleted = "standby promotion completed"
+const PgtaskPromoteStandbyFailed = "standby promotion failed"
 
 const PgtaskWorkflow = "workflow"
 const PgtaskWorkflowCloneType = "cloneworkflow"
@@ -55,6 +151,7 @@ const PgtaskBackrestBackup = "backup"
 const PgtaskBackrestInfo = "info"
 const PgtaskBackrestRestore = "restore"
 const PgtaskBackrestStanzaCreate = "stanza-create"
+const PgtaskBackrestVerify = "verify"
 
 const PgtaskpgDump = "pgdump"
 const PgtaskpgDumpBackup = "pgdumpbackup"
@@ -75,11 +172,18 @@ const (
 	BackupTypeFailover string = "failover"
 	// this type of backup is taken when a new cluster is being bootstrapped
 	BackupTypeBootstrap string = "bootstrap"
+	// this type of backup is taken before a cluster with deletion protection
+	// is deleted
+	BackupTypeFinal string = "final"
+	// this type of backup is taken once a cluster is upgraded to a new major
+	// version, so that its replicas can be rebuilt from it
+	BackupTypeMajorUpgrade string = "major-upgrade"
 )
 
 // BackrestStorageTypes defines the valid types of storage that can be utilized
-// with pgBackRest
-var BackrestStorageTypes = []string{"local", "s3"}
+// with pgBackRest. Unlike "local" and "s3", "gcs" and "azure" cannot be
+// combined with any other type
+var BackrestStorageTypes = []string{"local", "s3", "gcs", "azure"}
 
 // PgtaskSpec ...
 // swagger:ignore
@@ -90,6 +194,10 @@ type PgtaskSpec struct {
 	TaskType    string            `json:"tasktype"`
 	Status      string            `json:"status"`
 	Parameters  map[string]string `json:"parameters"`
+	// IdempotencyKey, if set, keeps the task from running when another task in
+	// the namespace with the same key is in progress or recently completed.
+	// A failed task does not keep a task with the same key from running
+	IdempotencyKey string `json:"idempotencyKey"`
 }
 
 // Pgtask ...
@@ -119,6 +227,59 @@ type PgtaskList struct {
 type PgtaskStatus struct {
 	State   PgtaskState `json:"state,omitempty"`
 	Message string      `json:"message,omitempty"`
+	// Phase is where the task is in its lifecycle, which automation can poll
+	// for the outcome of the task. It is empty until the task is picked up
+	Phase PgtaskPhase `json:"phase,omitempty"`
+	// StartTime is when the task was last started
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+	// CompletionTime is when the task succeeded or failed
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+	// Attempts is the number of times the task was started
+	Attempts int `json:"attempts,omitempty"`
+}
+
+// PgtaskPhase is where a task is in its lifecycle
+type PgtaskPhase string
+
+const (
+	// PgtaskPhasePending is the phase of a task that is waiting to be started
+	PgtaskPhasePending PgtaskPhase = "Pending"
+	// PgtaskPhaseRunning is the phase of a task that is being carried out
+	PgtaskPhaseRunning PgtaskPhase = "Running"
+	// PgtaskPhaseSucceeded is the phase of a task that was carried out
+	PgtaskPhaseSucceeded PgtaskPhase = "Succeeded"
+	// PgtaskPhaseFailed is the phase of a task that could not be carried out
+	PgtaskPhaseFailed PgtaskPhase = "Failed"
+)
+
+// SetPhase moves the status of a task into a phase at a point in time, along
+// with a message if there is one. Starting the task counts an attempt and
+// records when it started, while finishing it records when it completed
+func (s *PgtaskStatus) SetPhase(phase PgtaskPhase, message string, now metav1.Time) {
+	switch phase {
+	case PgtaskPhaseRunning:
+		if s.Phase != PgtaskPhaseRunning {
+			s.Attempts++
+			s.StartTime = &now
+			s.CompletionTime = nil
+		}
+	case PgtaskPhaseSucceeded, PgtaskPhaseFailed:
+		if s.StartTime == nil {
+			s.StartTime = &now
+		}
+		s.CompletionTime = &now
+	}
+
+	s.Phase = phase
+
+	if message != "" {
+		s.Message = message
+	}
+}
+
+// IsFinished returns whether the task succeeded or failed
+func (s PgtaskStatus) IsFinished() bool {
+	return s.Phase == PgtaskPhaseSucceeded || s.Phase == PgtaskPhaseFailed
 }
 
 // PgtaskState ...
@@ -130,4 +291,7 @@ const (
 	PgtaskStateCreated PgtaskState = "pgtask Created"
 	// PgtaskStateProcessed ...
 	PgtaskStateProcessed PgtaskState = "pgtask Processed"
+	// PgtaskStateDuplicate is the state of a task that was not run because of
+	// another task with the same idempotency key
+	PgtaskStateDuplicate PgtaskState = "pgtask Duplicate"
 )
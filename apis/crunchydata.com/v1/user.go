@@ -0,0 +1,121 @@
+package v1
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PguserResourcePlural ...
+const PguserResourcePlural = "pgusers"
+
+// PguserSpec is the PostgreSQL role that is kept in a cluster, along with its
+// options and the privileges it is granted on the databases of the cluster
+// swagger:ignore
+type PguserSpec struct {
+	// ClusterName is the name of the cluster the role is kept in
+	ClusterName string `json:"clustername"`
+	// Username is the name of the role. It defaults to the name of the pguser
+	Username string `json:"username"`
+	// SecretName is the Secret with the "username" and "password" of the
+	// role. It defaults to "<cluster>-<username>-secret", and is created with
+	// a generated password if it does not exist
+	SecretName string `json:"secretName"`
+	// Login is whether the role can log in
+	Login bool `json:"login"`
+	// Superuser is whether the role is a superuser
+	Superuser bool `json:"superuser"`
+	// CreateDB is whether the role can create databases
+	CreateDB bool `json:"createDB"`
+	// CreateRole is whether the role can create other roles
+	CreateRole bool `json:"createRole"`
+	// ConnectionLimit is how many connections the role can make at once. It
+	// is unlimited if it is not set
+	ConnectionLimit *int `json:"connectionLimit,omitempty"`
+	// Databases are the privileges the role is granted on each database.
+	// Privileges on databases that are not listed are not managed, unless
+	// they were granted by the pguser before
+	Databases []PguserDatabaseSpec `json:"databases"`
+	// PasswordRotationIntervalDays is how often the password of the role is
+	// rotated. It is only rotated on demand, with the
+	// "crunchydata.com/rotate-password" annotation, if it is not set
+	PasswordRotationIntervalDays int `json:"passwordRotationIntervalDays"`
+}
+
+// PguserDatabaseSpec is the privileges a role is granted on a database
+// swagger:ignore
+type PguserDatabaseSpec struct {
+	// Name is the name of the database
+	Name string `json:"name"`
+	// Privileges are the privileges on the database: CONNECT, CREATE,
+	// TEMPORARY or ALL
+	Privileges []string `json:"privileges"`
+}
+
+// Pguser is a PostgreSQL role of a cluster that is created, updated and
+// dropped by the Operator to match its spec
+// swagger:ignore
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+type Pguser struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata"`
+
+	Spec   PguserSpec   `json:"spec"`
+	Status PguserStatus `json:"status,omitempty"`
+}
+
+// PguserList ...
+// swagger:ignore
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+type PguserList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+
+	Items []Pguser `json:"items"`
+}
+
+// PguserStatus is whether the role of a pguser matches its spec
+// swagger:ignore
+type PguserStatus struct {
+	// State is whether the role was reconciled, or failed to be
+	State PguserState `json:"state,omitempty"`
+	// Message is why the role could not be reconciled, if it could not
+	Message string `json:"message,omitempty"`
+	// Databases are the databases the role was last granted privileges on,
+	// which have their privileges revoked once they are no longer listed
+	Databases []string `json:"databases,omitempty"`
+	// LastReconciled is when the role was last reconciled, in RFC 3339 format
+	LastReconciled string `json:"lastReconciled,omitempty"`
+	// LastPasswordRotation is when the password of the role was last rotated,
+	// in RFC 3339 format
+	LastPasswordRotation string `json:"lastPasswordRotation,omitempty"`
+	// PasswordRotateToken is the last value of the
+	// "crunchydata.com/rotate-password" annotation that the password was
+	// rotated for
+	PasswordRotateToken string `json:"passwordRotateToken,omitempty"`
+}
+
+// PguserState is the state of the role of a pguser
+type PguserState string
+
+const (
+	// PguserStateReconciled is the state of a role that matches its spec
+	PguserStateReconciled PguserState = "reconciled"
+	// PguserStateFailed is the state of a role that could not be reconciled,
+	// which is tried again periodically
+	PguserStateFailed PguserState = "failed"
+)
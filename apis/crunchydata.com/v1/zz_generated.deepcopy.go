@@ -20,484 +20,2323 @@ limitations under the License.
 package v1
 
 import (
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AlertsSpec) DeepCopyInto(out *AlertsSpec) {
+	*out = *in
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AlertsSpec.
+func (in *AlertsSpec) DeepCopy() *AlertsSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(AlertsSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AuditObjectRule) DeepCopyInto(out *AuditObjectRule) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AuditObjectRule.
+func (in *AuditObjectRule) DeepCopy() *AuditObjectRule {
+	if in == nil {
+		return nil
+	}
+	out := new(AuditObjectRule)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AuditSpec) DeepCopyInto(out *AuditSpec) {
+	*out = *in
+	if in.Log != nil {
+		in, out := &in.Log, &out.Log
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ObjectRules != nil {
+		in, out := &in.ObjectRules, &out.ObjectRules
+		*out = make([]AuditObjectRule, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AuditSpec.
+func (in *AuditSpec) DeepCopy() *AuditSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(AuditSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AutoscaleSpec) DeepCopyInto(out *AutoscaleSpec) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AutoscaleSpec.
+func (in *AutoscaleSpec) DeepCopy() *AutoscaleSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(AutoscaleSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AutoscaleStatus) DeepCopyInto(out *AutoscaleStatus) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AutoscaleStatus.
+func (in *AutoscaleStatus) DeepCopy() *AutoscaleStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(AutoscaleStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BackrestAzureSpec) DeepCopyInto(out *BackrestAzureSpec) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackrestAzureSpec.
+func (in *BackrestAzureSpec) DeepCopy() *BackrestAzureSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(BackrestAzureSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BackrestGCSSpec) DeepCopyInto(out *BackrestGCSSpec) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackrestGCSSpec.
+func (in *BackrestGCSSpec) DeepCopy() *BackrestGCSSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(BackrestGCSSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BackrestIdentitySpec) DeepCopyInto(out *BackrestIdentitySpec) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackrestIdentitySpec.
+func (in *BackrestIdentitySpec) DeepCopy() *BackrestIdentitySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(BackrestIdentitySpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BackrestRepoSpec) DeepCopyInto(out *BackrestRepoSpec) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackrestRepoSpec.
+func (in *BackrestRepoSpec) DeepCopy() *BackrestRepoSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(BackrestRepoSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BackrestRetentionSpec) DeepCopyInto(out *BackrestRetentionSpec) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackrestRetentionSpec.
+func (in *BackrestRetentionSpec) DeepCopy() *BackrestRetentionSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(BackrestRetentionSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BackrestRetentionStatus) DeepCopyInto(out *BackrestRetentionStatus) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackrestRetentionStatus.
+func (in *BackrestRetentionStatus) DeepCopy() *BackrestRetentionStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(BackrestRetentionStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BackrestS3Spec) DeepCopyInto(out *BackrestS3Spec) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackrestS3Spec.
+func (in *BackrestS3Spec) DeepCopy() *BackrestS3Spec {
+	if in == nil {
+		return nil
+	}
+	out := new(BackrestS3Spec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BackrestSecretStoreSpec) DeepCopyInto(out *BackrestSecretStoreSpec) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackrestSecretStoreSpec.
+func (in *BackrestSecretStoreSpec) DeepCopy() *BackrestSecretStoreSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(BackrestSecretStoreSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BackrestVerificationSpec) DeepCopyInto(out *BackrestVerificationSpec) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackrestVerificationSpec.
+func (in *BackrestVerificationSpec) DeepCopy() *BackrestVerificationSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(BackrestVerificationSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BackrestVerificationStatus) DeepCopyInto(out *BackrestVerificationStatus) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackrestVerificationStatus.
+func (in *BackrestVerificationStatus) DeepCopy() *BackrestVerificationStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(BackrestVerificationStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CloneSpec) DeepCopyInto(out *CloneSpec) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloneSpec.
+func (in *CloneSpec) DeepCopy() *CloneSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(CloneSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ConnectionLoggingStatus) DeepCopyInto(out *ConnectionLoggingStatus) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConnectionLoggingStatus.
+func (in *ConnectionLoggingStatus) DeepCopy() *ConnectionLoggingStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ConnectionLoggingStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DeletionProtectionSpec) DeepCopyInto(out *DeletionProtectionSpec) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DeletionProtectionSpec.
+func (in *DeletionProtectionSpec) DeepCopy() *DeletionProtectionSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(DeletionProtectionSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ExporterSpec) DeepCopyInto(out *ExporterSpec) {
+	*out = *in
+	out.Resources = in.Resources
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ExporterSpec.
+func (in *ExporterSpec) DeepCopy() *ExporterSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ExporterSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FailoverHealthCheckSpec) DeepCopyInto(out *FailoverHealthCheckSpec) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FailoverHealthCheckSpec.
+func (in *FailoverHealthCheckSpec) DeepCopy() *FailoverHealthCheckSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(FailoverHealthCheckSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FailoverHealthCheckStatus) DeepCopyInto(out *FailoverHealthCheckStatus) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FailoverHealthCheckStatus.
+func (in *FailoverHealthCheckStatus) DeepCopy() *FailoverHealthCheckStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(FailoverHealthCheckStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FencingSpec) DeepCopyInto(out *FencingSpec) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FencingSpec.
+func (in *FencingSpec) DeepCopy() *FencingSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(FencingSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FencingStatus) DeepCopyInto(out *FencingStatus) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FencingStatus.
+func (in *FencingStatus) DeepCopy() *FencingStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(FencingStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HBASpec) DeepCopyInto(out *HBASpec) {
+	*out = *in
+	if in.Rules != nil {
+		in, out := &in.Rules, &out.Rules
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HBASpec.
+func (in *HBASpec) DeepCopy() *HBASpec {
+	if in == nil {
+		return nil
+	}
+	out := new(HBASpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HealthCheckSpec) DeepCopyInto(out *HealthCheckSpec) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HealthCheckSpec.
+func (in *HealthCheckSpec) DeepCopy() *HealthCheckSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(HealthCheckSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HealthCheckStatus) DeepCopyInto(out *HealthCheckStatus) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HealthCheckStatus.
+func (in *HealthCheckStatus) DeepCopy() *HealthCheckStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(HealthCheckStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HibernationScheduleSpec) DeepCopyInto(out *HibernationScheduleSpec) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HibernationScheduleSpec.
+func (in *HibernationScheduleSpec) DeepCopy() *HibernationScheduleSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(HibernationScheduleSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HibernationStatus) DeepCopyInto(out *HibernationStatus) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HibernationStatus.
+func (in *HibernationStatus) DeepCopy() *HibernationStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(HibernationStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *InstancePodSpec) DeepCopyInto(out *InstancePodSpec) {
+	*out = *in
+	if in.Containers != nil {
+		in, out := &in.Containers, &out.Containers
+		*out = make([]corev1.Container, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.InitContainers != nil {
+		in, out := &in.InitContainers, &out.InitContainers
+		*out = make([]corev1.Container, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Volumes != nil {
+		in, out := &in.Volumes, &out.Volumes
+		*out = make([]corev1.Volume, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.VolumeMounts != nil {
+		in, out := &in.VolumeMounts, &out.VolumeMounts
+		*out = make([]corev1.VolumeMount, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new InstancePodSpec.
+func (in *InstancePodSpec) DeepCopy() *InstancePodSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(InstancePodSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LocaleSpec) DeepCopyInto(out *LocaleSpec) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LocaleSpec.
+func (in *LocaleSpec) DeepCopy() *LocaleSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(LocaleSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LocaleStatus) DeepCopyInto(out *LocaleStatus) {
+	*out = *in
+	out.Initialized = in.Initialized
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LocaleStatus.
+func (in *LocaleStatus) DeepCopy() *LocaleStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(LocaleStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MaintenanceSpec) DeepCopyInto(out *MaintenanceSpec) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MaintenanceSpec.
+func (in *MaintenanceSpec) DeepCopy() *MaintenanceSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(MaintenanceSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MaintenanceStatus) DeepCopyInto(out *MaintenanceStatus) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MaintenanceStatus.
+func (in *MaintenanceStatus) DeepCopy() *MaintenanceStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(MaintenanceStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MaintenanceWindowSpec) DeepCopyInto(out *MaintenanceWindowSpec) {
+	*out = *in
+	if in.Days != nil {
+		in, out := &in.Days, &out.Days
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MaintenanceWindowSpec.
+func (in *MaintenanceWindowSpec) DeepCopy() *MaintenanceWindowSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(MaintenanceWindowSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MaintenanceWindowStatus) DeepCopyInto(out *MaintenanceWindowStatus) {
+	*out = *in
+	if in.PendingActions != nil {
+		in, out := &in.PendingActions, &out.PendingActions
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MaintenanceWindowStatus.
+func (in *MaintenanceWindowStatus) DeepCopy() *MaintenanceWindowStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(MaintenanceWindowStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MaxReplicaLagSpec) DeepCopyInto(out *MaxReplicaLagSpec) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MaxReplicaLagSpec.
+func (in *MaxReplicaLagSpec) DeepCopy() *MaxReplicaLagSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(MaxReplicaLagSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MetadataSpec) DeepCopyInto(out *MetadataSpec) {
+	*out = *in
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	in.Postgres.DeepCopyInto(&out.Postgres)
+	in.PgBackRest.DeepCopyInto(&out.PgBackRest)
+	in.PgBouncer.DeepCopyInto(&out.PgBouncer)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MetadataSpec.
+func (in *MetadataSpec) DeepCopy() *MetadataSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(MetadataSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MinorUpgradeStatus) DeepCopyInto(out *MinorUpgradeStatus) {
+	*out = *in
+	if in.UpdatedInstances != nil {
+		in, out := &in.UpdatedInstances, &out.UpdatedInstances
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MinorUpgradeStatus.
+func (in *MinorUpgradeStatus) DeepCopy() *MinorUpgradeStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(MinorUpgradeStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MonitorSpec) DeepCopyInto(out *MonitorSpec) {
+	*out = *in
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	out.TLS = in.TLS
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MonitorSpec.
+func (in *MonitorSpec) DeepCopy() *MonitorSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(MonitorSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MonitorTLSSpec) DeepCopyInto(out *MonitorTLSSpec) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MonitorTLSSpec.
+func (in *MonitorTLSSpec) DeepCopy() *MonitorTLSSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(MonitorTLSSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NetworkPolicySpec) DeepCopyInto(out *NetworkPolicySpec) {
+	*out = *in
+	if in.AllowFrom != nil {
+		in, out := &in.AllowFrom, &out.AllowFrom
+		*out = make([]networkingv1.NetworkPolicyPeer, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetworkPolicySpec.
+func (in *NetworkPolicySpec) DeepCopy() *NetworkPolicySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(NetworkPolicySpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ObjectMetadata) DeepCopyInto(out *ObjectMetadata) {
+	*out = *in
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ObjectMetadata.
+func (in *ObjectMetadata) DeepCopy() *ObjectMetadata {
+	if in == nil {
+		return nil
+	}
+	out := new(ObjectMetadata)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PasswordRotationSpec) DeepCopyInto(out *PasswordRotationSpec) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PasswordRotationSpec.
+func (in *PasswordRotationSpec) DeepCopy() *PasswordRotationSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PasswordRotationSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PasswordRotationStatus) DeepCopyInto(out *PasswordRotationStatus) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PasswordRotationStatus.
+func (in *PasswordRotationStatus) DeepCopy() *PasswordRotationStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PasswordRotationStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PgBouncerSpec) DeepCopyInto(out *PgBouncerSpec) {
+	*out = *in
+	out.Resources = in.Resources
+	if in.Config != nil {
+		in, out := &in.Config, &out.Config
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	in.Scheduling.DeepCopyInto(&out.Scheduling)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PgBouncerSpec.
+func (in *PgBouncerSpec) DeepCopy() *PgBouncerSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PgBouncerSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PgContainerResources) DeepCopyInto(out *PgContainerResources) {
 	*out = *in
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PgContainerResources.
-func (in *PgContainerResources) DeepCopy() *PgContainerResources {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PgContainerResources.
+func (in *PgContainerResources) DeepCopy() *PgContainerResources {
+	if in == nil {
+		return nil
+	}
+	out := new(PgContainerResources)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PgEphemeralStorageSpec) DeepCopyInto(out *PgEphemeralStorageSpec) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PgEphemeralStorageSpec.
+func (in *PgEphemeralStorageSpec) DeepCopy() *PgEphemeralStorageSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PgEphemeralStorageSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PgStorageSpec) DeepCopyInto(out *PgStorageSpec) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PgStorageSpec.
+func (in *PgStorageSpec) DeepCopy() *PgStorageSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PgStorageSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Pgcluster) DeepCopyInto(out *Pgcluster) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Pgcluster.
+func (in *Pgcluster) DeepCopy() *Pgcluster {
+	if in == nil {
+		return nil
+	}
+	out := new(Pgcluster)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *Pgcluster) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PgclusterCondition) DeepCopyInto(out *PgclusterCondition) {
+	*out = *in
+	in.LastTransitionTime.DeepCopyInto(&out.LastTransitionTime)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PgclusterCondition.
+func (in *PgclusterCondition) DeepCopy() *PgclusterCondition {
+	if in == nil {
+		return nil
+	}
+	out := new(PgclusterCondition)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PgclusterList) DeepCopyInto(out *PgclusterList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]Pgcluster, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PgclusterList.
+func (in *PgclusterList) DeepCopy() *PgclusterList {
+	if in == nil {
+		return nil
+	}
+	out := new(PgclusterList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *PgclusterList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PgclusterSpec) DeepCopyInto(out *PgclusterSpec) {
+	*out = *in
+	out.PrimaryStorage = in.PrimaryStorage
+	out.ArchiveStorage = in.ArchiveStorage
+	out.ReplicaStorage = in.ReplicaStorage
+	out.BackrestStorage = in.BackrestStorage
+	out.ContainerResources = in.ContainerResources
+	if in.UserLabels != nil {
+		in, out := &in.UserLabels, &out.UserLabels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	out.PodAntiAffinity = in.PodAntiAffinity
+	if in.SyncReplication != nil {
+		in, out := &in.SyncReplication, &out.SyncReplication
+		*out = new(bool)
+		**out = **in
+	}
+	if in.TablespaceMounts != nil {
+		in, out := &in.TablespaceMounts, &out.TablespaceMounts
+		*out = make(map[string]PgStorageSpec, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	in.TLS.DeepCopyInto(&out.TLS)
+	out.Maintenance = in.Maintenance
+	out.ServiceDiscovery = in.ServiceDiscovery
+	out.EphemeralStorage = in.EphemeralStorage
+	out.DeletionProtection = in.DeletionProtection
+	in.Audit.DeepCopyInto(&out.Audit)
+	out.Autoscale = in.Autoscale
+	out.HealthCheck = in.HealthCheck
+	if in.NodeSelector != nil {
+		in, out := &in.NodeSelector, &out.NodeSelector
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Tolerations != nil {
+		in, out := &in.Tolerations, &out.Tolerations
+		*out = make([]corev1.Toleration, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.NodeAffinity != nil {
+		in, out := &in.NodeAffinity, &out.NodeAffinity
+		*out = new(corev1.NodeAffinity)
+		(*in).DeepCopyInto(*out)
+	}
+	in.BackrestRepoScheduling.DeepCopyInto(&out.BackrestRepoScheduling)
+	if in.TopologySpreadConstraints != nil {
+		in, out := &in.TopologySpreadConstraints, &out.TopologySpreadConstraints
+		*out = make([]corev1.TopologySpreadConstraint, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	out.BackrestS3 = in.BackrestS3
+	if in.BackrestRepos != nil {
+		in, out := &in.BackrestRepos, &out.BackrestRepos
+		*out = make([]BackrestRepoSpec, len(*in))
+		copy(*out, *in)
+	}
+	out.BackrestGCS = in.BackrestGCS
+	out.BackrestAzure = in.BackrestAzure
+	out.BackrestIdentity = in.BackrestIdentity
+	out.BackrestSecretStore = in.BackrestSecretStore
+	out.BackrestRetention = in.BackrestRetention
+	out.BackrestVerification = in.BackrestVerification
+	out.Clone = in.Clone
+	out.StandbyStreaming = in.StandbyStreaming
+	out.TrafficRamp = in.TrafficRamp
+	out.Fencing = in.Fencing
+	out.PasswordRotation = in.PasswordRotation
+	in.HBA.DeepCopyInto(&out.HBA)
+	if in.PostgresParams != nil {
+		in, out := &in.PostgresParams, &out.PostgresParams
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	in.PgBouncer.DeepCopyInto(&out.PgBouncer)
+	out.Locale = in.Locale
+	out.Exporter = in.Exporter
+	in.Monitor.DeepCopyInto(&out.Monitor)
+	in.Alerts.DeepCopyInto(&out.Alerts)
+	in.NetworkPolicy.DeepCopyInto(&out.NetworkPolicy)
+	out.PodDisruptionBudget = in.PodDisruptionBudget
+	in.Metadata.DeepCopyInto(&out.Metadata)
+	in.InstancePod.DeepCopyInto(&out.InstancePod)
+	out.StorageAutogrow = in.StorageAutogrow
+	out.WALStorage = in.WALStorage
+	if in.Tablespaces != nil {
+		in, out := &in.Tablespaces, &out.Tablespaces
+		*out = make([]TablespaceSpec, len(*in))
+		copy(*out, *in)
+	}
+	out.VolumeSnapshots = in.VolumeSnapshots
+	out.HibernationSchedule = in.HibernationSchedule
+	out.ResourceRecommendation = in.ResourceRecommendation
+	in.MaintenanceWindow.DeepCopyInto(&out.MaintenanceWindow)
+	out.FailoverHealthCheck = in.FailoverHealthCheck
+	out.MaxReplicaLag = in.MaxReplicaLag
+	in.ReadOnlyService.DeepCopyInto(&out.ReadOnlyService)
+	in.Services.DeepCopyInto(&out.Services)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PgclusterSpec.
+func (in *PgclusterSpec) DeepCopy() *PgclusterSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PgclusterSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PgclusterStatus) DeepCopyInto(out *PgclusterStatus) {
+	*out = *in
+	out.Maintenance = in.Maintenance
+	out.ServiceDiscovery = in.ServiceDiscovery
+	out.Autoscale = in.Autoscale
+	out.HealthCheck = in.HealthCheck
+	in.TrafficRamp.DeepCopyInto(&out.TrafficRamp)
+	out.ConnectionLogging = in.ConnectionLogging
+	out.Fencing = in.Fencing
+	out.TLSCertificates = in.TLSCertificates
+	out.Locale = in.Locale
+	out.BackrestRetention = in.BackrestRetention
+	out.BackrestVerification = in.BackrestVerification
+	out.PasswordRotation = in.PasswordRotation
+	in.PostgresParams.DeepCopyInto(&out.PostgresParams)
+	in.MinorUpgrade.DeepCopyInto(&out.MinorUpgrade)
+	in.StorageAutogrow.DeepCopyInto(&out.StorageAutogrow)
+	out.PrimaryStorage = in.PrimaryStorage
+	if in.Tablespaces != nil {
+		in, out := &in.Tablespaces, &out.Tablespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	in.VolumeSnapshots.DeepCopyInto(&out.VolumeSnapshots)
+	out.Hibernation = in.Hibernation
+	out.ResourceRecommendation = in.ResourceRecommendation
+	in.MaintenanceWindow.DeepCopyInto(&out.MaintenanceWindow)
+	out.FailoverHealthCheck = in.FailoverHealthCheck
+	in.SyncReplication.DeepCopyInto(&out.SyncReplication)
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]PgclusterCondition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PgclusterStatus.
+func (in *PgclusterStatus) DeepCopy() *PgclusterStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PgclusterStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Pgdatabase) DeepCopyInto(out *Pgdatabase) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	out.Status = in.Status
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Pgdatabase.
+func (in *Pgdatabase) DeepCopy() *Pgdatabase {
+	if in == nil {
+		return nil
+	}
+	out := new(Pgdatabase)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *Pgdatabase) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PgdatabaseList) DeepCopyInto(out *PgdatabaseList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]Pgdatabase, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PgdatabaseList.
+func (in *PgdatabaseList) DeepCopy() *PgdatabaseList {
+	if in == nil {
+		return nil
+	}
+	out := new(PgdatabaseList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *PgdatabaseList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PgdatabaseSpec) DeepCopyInto(out *PgdatabaseSpec) {
+	*out = *in
+	if in.Extensions != nil {
+		in, out := &in.Extensions, &out.Extensions
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Schemas != nil {
+		in, out := &in.Schemas, &out.Schemas
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PgdatabaseSpec.
+func (in *PgdatabaseSpec) DeepCopy() *PgdatabaseSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PgdatabaseSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PgdatabaseStatus) DeepCopyInto(out *PgdatabaseStatus) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PgdatabaseStatus.
+func (in *PgdatabaseStatus) DeepCopy() *PgdatabaseStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PgdatabaseStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Pgpolicy) DeepCopyInto(out *Pgpolicy) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	out.Spec = in.Spec
+	out.Status = in.Status
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Pgpolicy.
+func (in *Pgpolicy) DeepCopy() *Pgpolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(Pgpolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *Pgpolicy) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PgpolicyList) DeepCopyInto(out *PgpolicyList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]Pgpolicy, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PgpolicyList.
+func (in *PgpolicyList) DeepCopy() *PgpolicyList {
+	if in == nil {
+		return nil
+	}
+	out := new(PgpolicyList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *PgpolicyList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PgpolicySpec) DeepCopyInto(out *PgpolicySpec) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PgpolicySpec.
+func (in *PgpolicySpec) DeepCopy() *PgpolicySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PgpolicySpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PgpolicyStatus) DeepCopyInto(out *PgpolicyStatus) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PgpolicyStatus.
+func (in *PgpolicyStatus) DeepCopy() *PgpolicyStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PgpolicyStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Pgreplica) DeepCopyInto(out *Pgreplica) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	out.Status = in.Status
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Pgreplica.
+func (in *Pgreplica) DeepCopy() *Pgreplica {
+	if in == nil {
+		return nil
+	}
+	out := new(Pgreplica)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *Pgreplica) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PgreplicaList) DeepCopyInto(out *PgreplicaList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]Pgreplica, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PgreplicaList.
+func (in *PgreplicaList) DeepCopy() *PgreplicaList {
+	if in == nil {
+		return nil
+	}
+	out := new(PgreplicaList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *PgreplicaList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PgreplicaSpec) DeepCopyInto(out *PgreplicaSpec) {
+	*out = *in
+	out.ReplicaStorage = in.ReplicaStorage
+	out.ContainerResources = in.ContainerResources
+	if in.UserLabels != nil {
+		in, out := &in.UserLabels, &out.UserLabels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PgreplicaSpec.
+func (in *PgreplicaSpec) DeepCopy() *PgreplicaSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PgreplicaSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PgreplicaStatus) DeepCopyInto(out *PgreplicaStatus) {
+	*out = *in
+	out.ReplicaStorage = in.ReplicaStorage
+	out.Lag = in.Lag
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PgreplicaStatus.
+func (in *PgreplicaStatus) DeepCopy() *PgreplicaStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PgreplicaStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Pgschedule) DeepCopyInto(out *Pgschedule) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	out.Spec = in.Spec
+	out.Status = in.Status
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Pgschedule.
+func (in *Pgschedule) DeepCopy() *Pgschedule {
+	if in == nil {
+		return nil
+	}
+	out := new(Pgschedule)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *Pgschedule) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PgscheduleList) DeepCopyInto(out *PgscheduleList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]Pgschedule, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PgscheduleList.
+func (in *PgscheduleList) DeepCopy() *PgscheduleList {
+	if in == nil {
+		return nil
+	}
+	out := new(PgscheduleList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *PgscheduleList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PgscheduleSpec) DeepCopyInto(out *PgscheduleSpec) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PgscheduleSpec.
+func (in *PgscheduleSpec) DeepCopy() *PgscheduleSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PgscheduleSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PgscheduleStatus) DeepCopyInto(out *PgscheduleStatus) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PgscheduleStatus.
+func (in *PgscheduleStatus) DeepCopy() *PgscheduleStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PgscheduleStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Pgtask) DeepCopyInto(out *Pgtask) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Pgtask.
+func (in *Pgtask) DeepCopy() *Pgtask {
+	if in == nil {
+		return nil
+	}
+	out := new(Pgtask)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *Pgtask) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PgtaskList) DeepCopyInto(out *PgtaskList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]Pgtask, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PgtaskList.
+func (in *PgtaskList) DeepCopy() *PgtaskList {
+	if in == nil {
+		return nil
+	}
+	out := new(PgtaskList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *PgtaskList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PgtaskSpec) DeepCopyInto(out *PgtaskSpec) {
+	*out = *in
+	out.StorageSpec = in.StorageSpec
+	if in.Parameters != nil {
+		in, out := &in.Parameters, &out.Parameters
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PgtaskSpec.
+func (in *PgtaskSpec) DeepCopy() *PgtaskSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PgtaskSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PgtaskStatus) DeepCopyInto(out *PgtaskStatus) {
+	*out = *in
+	if in.StartTime != nil {
+		in, out := &in.StartTime, &out.StartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PgtaskStatus.
+func (in *PgtaskStatus) DeepCopy() *PgtaskStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PgtaskStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Pguser) DeepCopyInto(out *Pguser) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Pguser.
+func (in *Pguser) DeepCopy() *Pguser {
+	if in == nil {
+		return nil
+	}
+	out := new(Pguser)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *Pguser) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PguserDatabaseSpec) DeepCopyInto(out *PguserDatabaseSpec) {
+	*out = *in
+	if in.Privileges != nil {
+		in, out := &in.Privileges, &out.Privileges
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PguserDatabaseSpec.
+func (in *PguserDatabaseSpec) DeepCopy() *PguserDatabaseSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PguserDatabaseSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PguserList) DeepCopyInto(out *PguserList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]Pguser, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PguserList.
+func (in *PguserList) DeepCopy() *PguserList {
+	if in == nil {
+		return nil
+	}
+	out := new(PguserList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *PguserList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PguserSpec) DeepCopyInto(out *PguserSpec) {
+	*out = *in
+	if in.ConnectionLimit != nil {
+		in, out := &in.ConnectionLimit, &out.ConnectionLimit
+		*out = new(int)
+		**out = **in
+	}
+	if in.Databases != nil {
+		in, out := &in.Databases, &out.Databases
+		*out = make([]PguserDatabaseSpec, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PguserSpec.
+func (in *PguserSpec) DeepCopy() *PguserSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PguserSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PguserStatus) DeepCopyInto(out *PguserStatus) {
+	*out = *in
+	if in.Databases != nil {
+		in, out := &in.Databases, &out.Databases
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PguserStatus.
+func (in *PguserStatus) DeepCopy() *PguserStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PguserStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PodAntiAffinitySpec) DeepCopyInto(out *PodAntiAffinitySpec) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PodAntiAffinitySpec.
+func (in *PodAntiAffinitySpec) DeepCopy() *PodAntiAffinitySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PodAntiAffinitySpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PodDisruptionBudgetSpec) DeepCopyInto(out *PodDisruptionBudgetSpec) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PodDisruptionBudgetSpec.
+func (in *PodDisruptionBudgetSpec) DeepCopy() *PodDisruptionBudgetSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PodDisruptionBudgetSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PostgresParamsStatus) DeepCopyInto(out *PostgresParamsStatus) {
+	*out = *in
+	if in.Applied != nil {
+		in, out := &in.Applied, &out.Applied
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Replaced != nil {
+		in, out := &in.Replaced, &out.Replaced
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.PendingRestart != nil {
+		in, out := &in.PendingRestart, &out.PendingRestart
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgresParamsStatus.
+func (in *PostgresParamsStatus) DeepCopy() *PostgresParamsStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PostgresParamsStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ReadOnlyServiceSpec) DeepCopyInto(out *ReadOnlyServiceSpec) {
+	*out = *in
+	in.ServiceSpec.DeepCopyInto(&out.ServiceSpec)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReadOnlyServiceSpec.
+func (in *ReadOnlyServiceSpec) DeepCopy() *ReadOnlyServiceSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ReadOnlyServiceSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ReplicaLagStatus) DeepCopyInto(out *ReplicaLagStatus) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReplicaLagStatus.
+func (in *ReplicaLagStatus) DeepCopy() *ReplicaLagStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ReplicaLagStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResourceRecommendationSpec) DeepCopyInto(out *ResourceRecommendationSpec) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourceRecommendationSpec.
+func (in *ResourceRecommendationSpec) DeepCopy() *ResourceRecommendationSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ResourceRecommendationSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResourceRecommendationStatus) DeepCopyInto(out *ResourceRecommendationStatus) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourceRecommendationStatus.
+func (in *ResourceRecommendationStatus) DeepCopy() *ResourceRecommendationStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ResourceRecommendationStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ScheduledVolumeSnapshotStatus) DeepCopyInto(out *ScheduledVolumeSnapshotStatus) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ScheduledVolumeSnapshotStatus.
+func (in *ScheduledVolumeSnapshotStatus) DeepCopy() *ScheduledVolumeSnapshotStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(PgContainerResources)
+	out := new(ScheduledVolumeSnapshotStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *PgStorageSpec) DeepCopyInto(out *PgStorageSpec) {
+func (in *SchedulingSpec) DeepCopyInto(out *SchedulingSpec) {
 	*out = *in
+	if in.NodeAffinity != nil {
+		in, out := &in.NodeAffinity, &out.NodeAffinity
+		*out = new(corev1.NodeAffinity)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Tolerations != nil {
+		in, out := &in.Tolerations, &out.Tolerations
+		*out = make([]corev1.Toleration, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PgStorageSpec.
-func (in *PgStorageSpec) DeepCopy() *PgStorageSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SchedulingSpec.
+func (in *SchedulingSpec) DeepCopy() *SchedulingSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(PgStorageSpec)
+	out := new(SchedulingSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *Pgcluster) DeepCopyInto(out *Pgcluster) {
+func (in *ServiceDiscoverySpec) DeepCopyInto(out *ServiceDiscoverySpec) {
 	*out = *in
-	out.TypeMeta = in.TypeMeta
-	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Pgcluster.
-func (in *Pgcluster) DeepCopy() *Pgcluster {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServiceDiscoverySpec.
+func (in *ServiceDiscoverySpec) DeepCopy() *ServiceDiscoverySpec {
 	if in == nil {
 		return nil
 	}
-	out := new(Pgcluster)
+	out := new(ServiceDiscoverySpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
-// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *Pgcluster) DeepCopyObject() runtime.Object {
-	if c := in.DeepCopy(); c != nil {
-		return c
-	}
-	return nil
-}
-
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *PgclusterList) DeepCopyInto(out *PgclusterList) {
+func (in *ServiceDiscoveryStatus) DeepCopyInto(out *ServiceDiscoveryStatus) {
 	*out = *in
-	out.TypeMeta = in.TypeMeta
-	in.ListMeta.DeepCopyInto(&out.ListMeta)
-	if in.Items != nil {
-		in, out := &in.Items, &out.Items
-		*out = make([]Pgcluster, len(*in))
-		for i := range *in {
-			(*in)[i].DeepCopyInto(&(*out)[i])
-		}
-	}
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PgclusterList.
-func (in *PgclusterList) DeepCopy() *PgclusterList {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServiceDiscoveryStatus.
+func (in *ServiceDiscoveryStatus) DeepCopy() *ServiceDiscoveryStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(PgclusterList)
+	out := new(ServiceDiscoveryStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
-// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *PgclusterList) DeepCopyObject() runtime.Object {
-	if c := in.DeepCopy(); c != nil {
-		return c
-	}
-	return nil
-}
-
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *PgclusterSpec) DeepCopyInto(out *PgclusterSpec) {
+func (in *ServiceSpec) DeepCopyInto(out *ServiceSpec) {
 	*out = *in
-	out.PrimaryStorage = in.PrimaryStorage
-	out.ArchiveStorage = in.ArchiveStorage
-	out.ReplicaStorage = in.ReplicaStorage
-	out.BackrestStorage = in.BackrestStorage
-	out.ContainerResources = in.ContainerResources
-	if in.UserLabels != nil {
-		in, out := &in.UserLabels, &out.UserLabels
-		*out = make(map[string]string, len(*in))
-		for key, val := range *in {
-			(*out)[key] = val
-		}
-	}
-	out.PodAntiAffinity = in.PodAntiAffinity
-	if in.SyncReplication != nil {
-		in, out := &in.SyncReplication, &out.SyncReplication
-		*out = new(bool)
-		**out = **in
+	if in.LoadBalancerSourceRanges != nil {
+		in, out := &in.LoadBalancerSourceRanges, &out.LoadBalancerSourceRanges
+		*out = make([]string, len(*in))
+		copy(*out, *in)
 	}
-	if in.TablespaceMounts != nil {
-		in, out := &in.TablespaceMounts, &out.TablespaceMounts
-		*out = make(map[string]PgStorageSpec, len(*in))
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make(map[string]string, len(*in))
 		for key, val := range *in {
 			(*out)[key] = val
 		}
 	}
-	out.TLS = in.TLS
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PgclusterSpec.
-func (in *PgclusterSpec) DeepCopy() *PgclusterSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServiceSpec.
+func (in *ServiceSpec) DeepCopy() *ServiceSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(PgclusterSpec)
+	out := new(ServiceSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *PgclusterStatus) DeepCopyInto(out *PgclusterStatus) {
+func (in *ServicesSpec) DeepCopyInto(out *ServicesSpec) {
 	*out = *in
+	in.Primary.DeepCopyInto(&out.Primary)
+	in.Replica.DeepCopyInto(&out.Replica)
+	in.PgBouncer.DeepCopyInto(&out.PgBouncer)
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PgclusterStatus.
-func (in *PgclusterStatus) DeepCopy() *PgclusterStatus {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServicesSpec.
+func (in *ServicesSpec) DeepCopy() *ServicesSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(PgclusterStatus)
+	out := new(ServicesSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *Pgpolicy) DeepCopyInto(out *Pgpolicy) {
+func (in *StandbyStreamingSpec) DeepCopyInto(out *StandbyStreamingSpec) {
 	*out = *in
-	out.TypeMeta = in.TypeMeta
-	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	out.Spec = in.Spec
-	out.Status = in.Status
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Pgpolicy.
-func (in *Pgpolicy) DeepCopy() *Pgpolicy {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StandbyStreamingSpec.
+func (in *StandbyStreamingSpec) DeepCopy() *StandbyStreamingSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(Pgpolicy)
+	out := new(StandbyStreamingSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
-// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *Pgpolicy) DeepCopyObject() runtime.Object {
-	if c := in.DeepCopy(); c != nil {
-		return c
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StorageAutogrowSpec) DeepCopyInto(out *StorageAutogrowSpec) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StorageAutogrowSpec.
+func (in *StorageAutogrowSpec) DeepCopy() *StorageAutogrowSpec {
+	if in == nil {
+		return nil
 	}
-	return nil
+	out := new(StorageAutogrowSpec)
+	in.DeepCopyInto(out)
+	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *PgpolicyList) DeepCopyInto(out *PgpolicyList) {
+func (in *StorageAutogrowStatus) DeepCopyInto(out *StorageAutogrowStatus) {
 	*out = *in
-	out.TypeMeta = in.TypeMeta
-	in.ListMeta.DeepCopyInto(&out.ListMeta)
-	if in.Items != nil {
-		in, out := &in.Items, &out.Items
-		*out = make([]Pgpolicy, len(*in))
-		for i := range *in {
-			(*in)[i].DeepCopyInto(&(*out)[i])
-		}
+	if in.Volumes != nil {
+		in, out := &in.Volumes, &out.Volumes
+		*out = make([]VolumeUsageStatus, len(*in))
+		copy(*out, *in)
 	}
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PgpolicyList.
-func (in *PgpolicyList) DeepCopy() *PgpolicyList {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StorageAutogrowStatus.
+func (in *StorageAutogrowStatus) DeepCopy() *StorageAutogrowStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(PgpolicyList)
+	out := new(StorageAutogrowStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
-// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *PgpolicyList) DeepCopyObject() runtime.Object {
-	if c := in.DeepCopy(); c != nil {
-		return c
-	}
-	return nil
-}
-
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *PgpolicySpec) DeepCopyInto(out *PgpolicySpec) {
+func (in *SyncReplicationStatus) DeepCopyInto(out *SyncReplicationStatus) {
 	*out = *in
+	if in.Replicas != nil {
+		in, out := &in.Replicas, &out.Replicas
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PgpolicySpec.
-func (in *PgpolicySpec) DeepCopy() *PgpolicySpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SyncReplicationStatus.
+func (in *SyncReplicationStatus) DeepCopy() *SyncReplicationStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(PgpolicySpec)
+	out := new(SyncReplicationStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *PgpolicyStatus) DeepCopyInto(out *PgpolicyStatus) {
+func (in *TLSCertManagerSpec) DeepCopyInto(out *TLSCertManagerSpec) {
 	*out = *in
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PgpolicyStatus.
-func (in *PgpolicyStatus) DeepCopy() *PgpolicyStatus {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TLSCertManagerSpec.
+func (in *TLSCertManagerSpec) DeepCopy() *TLSCertManagerSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(PgpolicyStatus)
+	out := new(TLSCertManagerSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *Pgreplica) DeepCopyInto(out *Pgreplica) {
+func (in *TLSCertificatesStatus) DeepCopyInto(out *TLSCertificatesStatus) {
 	*out = *in
-	out.TypeMeta = in.TypeMeta
-	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Pgreplica.
-func (in *Pgreplica) DeepCopy() *Pgreplica {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TLSCertificatesStatus.
+func (in *TLSCertificatesStatus) DeepCopy() *TLSCertificatesStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(Pgreplica)
+	out := new(TLSCertificatesStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
-// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *Pgreplica) DeepCopyObject() runtime.Object {
-	if c := in.DeepCopy(); c != nil {
-		return c
-	}
-	return nil
-}
-
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *PgreplicaList) DeepCopyInto(out *PgreplicaList) {
+func (in *TLSClientAuthSpec) DeepCopyInto(out *TLSClientAuthSpec) {
 	*out = *in
-	out.TypeMeta = in.TypeMeta
-	in.ListMeta.DeepCopyInto(&out.ListMeta)
-	if in.Items != nil {
-		in, out := &in.Items, &out.Items
-		*out = make([]Pgreplica, len(*in))
-		for i := range *in {
-			(*in)[i].DeepCopyInto(&(*out)[i])
-		}
+	if in.Users != nil {
+		in, out := &in.Users, &out.Users
+		*out = make([]string, len(*in))
+		copy(*out, *in)
 	}
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PgreplicaList.
-func (in *PgreplicaList) DeepCopy() *PgreplicaList {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TLSClientAuthSpec.
+func (in *TLSClientAuthSpec) DeepCopy() *TLSClientAuthSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(PgreplicaList)
+	out := new(TLSClientAuthSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
-// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *PgreplicaList) DeepCopyObject() runtime.Object {
-	if c := in.DeepCopy(); c != nil {
-		return c
-	}
-	return nil
-}
-
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *PgreplicaSpec) DeepCopyInto(out *PgreplicaSpec) {
+func (in *TLSGenerateSpec) DeepCopyInto(out *TLSGenerateSpec) {
 	*out = *in
-	out.ReplicaStorage = in.ReplicaStorage
-	out.ContainerResources = in.ContainerResources
-	if in.UserLabels != nil {
-		in, out := &in.UserLabels, &out.UserLabels
-		*out = make(map[string]string, len(*in))
-		for key, val := range *in {
-			(*out)[key] = val
-		}
-	}
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PgreplicaSpec.
-func (in *PgreplicaSpec) DeepCopy() *PgreplicaSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TLSGenerateSpec.
+func (in *TLSGenerateSpec) DeepCopy() *TLSGenerateSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(PgreplicaSpec)
+	out := new(TLSGenerateSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *PgreplicaStatus) DeepCopyInto(out *PgreplicaStatus) {
+func (in *TLSSpec) DeepCopyInto(out *TLSSpec) {
 	*out = *in
+	in.ClientAuth.DeepCopyInto(&out.ClientAuth)
+	out.CertManager = in.CertManager
+	out.Generate = in.Generate
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PgreplicaStatus.
-func (in *PgreplicaStatus) DeepCopy() *PgreplicaStatus {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TLSSpec.
+func (in *TLSSpec) DeepCopy() *TLSSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(PgreplicaStatus)
+	out := new(TLSSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *Pgtask) DeepCopyInto(out *Pgtask) {
+func (in *TablespaceSpec) DeepCopyInto(out *TablespaceSpec) {
 	*out = *in
-	out.TypeMeta = in.TypeMeta
-	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	out.Storage = in.Storage
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Pgtask.
-func (in *Pgtask) DeepCopy() *Pgtask {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TablespaceSpec.
+func (in *TablespaceSpec) DeepCopy() *TablespaceSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(Pgtask)
+	out := new(TablespaceSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
-// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *Pgtask) DeepCopyObject() runtime.Object {
-	if c := in.DeepCopy(); c != nil {
-		return c
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TrafficRampSpec) DeepCopyInto(out *TrafficRampSpec) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TrafficRampSpec.
+func (in *TrafficRampSpec) DeepCopy() *TrafficRampSpec {
+	if in == nil {
+		return nil
 	}
-	return nil
+	out := new(TrafficRampSpec)
+	in.DeepCopyInto(out)
+	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *PgtaskList) DeepCopyInto(out *PgtaskList) {
+func (in *TrafficRampStatus) DeepCopyInto(out *TrafficRampStatus) {
 	*out = *in
-	out.TypeMeta = in.TypeMeta
-	in.ListMeta.DeepCopyInto(&out.ListMeta)
-	if in.Items != nil {
-		in, out := &in.Items, &out.Items
-		*out = make([]Pgtask, len(*in))
-		for i := range *in {
-			(*in)[i].DeepCopyInto(&(*out)[i])
+	if in.ConnectionLimits != nil {
+		in, out := &in.ConnectionLimits, &out.ConnectionLimits
+		*out = make(map[string]int, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
 		}
 	}
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PgtaskList.
-func (in *PgtaskList) DeepCopy() *PgtaskList {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TrafficRampStatus.
+func (in *TrafficRampStatus) DeepCopy() *TrafficRampStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(PgtaskList)
+	out := new(TrafficRampStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
-// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *PgtaskList) DeepCopyObject() runtime.Object {
-	if c := in.DeepCopy(); c != nil {
-		return c
-	}
-	return nil
-}
-
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *PgtaskSpec) DeepCopyInto(out *PgtaskSpec) {
+func (in *VolumeResizeStatus) DeepCopyInto(out *VolumeResizeStatus) {
 	*out = *in
-	out.StorageSpec = in.StorageSpec
-	if in.Parameters != nil {
-		in, out := &in.Parameters, &out.Parameters
-		*out = make(map[string]string, len(*in))
-		for key, val := range *in {
-			(*out)[key] = val
-		}
-	}
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PgtaskSpec.
-func (in *PgtaskSpec) DeepCopy() *PgtaskSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VolumeResizeStatus.
+func (in *VolumeResizeStatus) DeepCopy() *VolumeResizeStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(PgtaskSpec)
+	out := new(VolumeResizeStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *PgtaskStatus) DeepCopyInto(out *PgtaskStatus) {
+func (in *VolumeSnapshotScheduleStatus) DeepCopyInto(out *VolumeSnapshotScheduleStatus) {
 	*out = *in
+	if in.Snapshots != nil {
+		in, out := &in.Snapshots, &out.Snapshots
+		*out = make([]ScheduledVolumeSnapshotStatus, len(*in))
+		copy(*out, *in)
+	}
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PgtaskStatus.
-func (in *PgtaskStatus) DeepCopy() *PgtaskStatus {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VolumeSnapshotScheduleStatus.
+func (in *VolumeSnapshotScheduleStatus) DeepCopy() *VolumeSnapshotScheduleStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(PgtaskStatus)
+	out := new(VolumeSnapshotScheduleStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *PodAntiAffinitySpec) DeepCopyInto(out *PodAntiAffinitySpec) {
+func (in *VolumeSnapshotSpec) DeepCopyInto(out *VolumeSnapshotSpec) {
 	*out = *in
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PodAntiAffinitySpec.
-func (in *PodAntiAffinitySpec) DeepCopy() *PodAntiAffinitySpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VolumeSnapshotSpec.
+func (in *VolumeSnapshotSpec) DeepCopy() *VolumeSnapshotSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(PodAntiAffinitySpec)
+	out := new(VolumeSnapshotSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *TLSSpec) DeepCopyInto(out *TLSSpec) {
+func (in *VolumeUsageStatus) DeepCopyInto(out *VolumeUsageStatus) {
 	*out = *in
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TLSSpec.
-func (in *TLSSpec) DeepCopy() *TLSSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VolumeUsageStatus.
+func (in *VolumeUsageStatus) DeepCopy() *VolumeUsageStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(TLSSpec)
+	out := new(VolumeUsageStatus)
 	in.DeepCopyInto(out)
 	return out
 }
@@ -99,6 +99,14 @@ func DeleteCluster(name, selector string, deleteData, deleteBackups bool, ns, pg
 			return response
 		}
 
+		// a cluster with deletion protection is not removed until its final backup completes
+		if cluster.Spec.DeletionProtection.BackupFirst &&
+			cluster.Annotations[config.ANNOTATION_DELETION_PROTECTION_FORCE] != "true" {
+			response.Results = append(response.Results, "pgcluster "+cluster.Spec.Name+
+				" will be deleted once its final backup completes")
+			continue
+		}
+
 		response.Results = append(response.Results, "deleted pgcluster "+cluster.Spec.Name)
 
 	}
@@ -709,6 +717,12 @@ func CreateCluster(request *msgs.CreateClusterRequest, ns, pgouser string) msgs.
 		}
 	}
 
+	if request.WALStorageConfig != "" && !apiserver.IsValidStorageName(request.WALStorageConfig) {
+		resp.Status.Code = msgs.Error
+		resp.Status.Msg = fmt.Sprintf("\"%s\" storage config was not found", request.WALStorageConfig)
+		return resp
+	}
+
 	if request.ContainerResources != "" {
 		if apiserver.IsValidContainerResource(request.ContainerResources) == false {
 			resp.Status.Code = msgs.Error
@@ -1066,6 +1080,11 @@ func getClusterParams(request *msgs.CreateClusterRequest, name string, userLabel
 		spec.ReplicaStorage.Size = request.PVCSize
 	}
 
+	// the write-ahead log is kept on volumes of its own only if the user asked for it
+	if request.WALStorageConfig != "" {
+		spec.WALStorage, _ = apiserver.Pgo.GetStorageSpec(request.WALStorageConfig)
+	}
+
 	spec.BackrestStorage, _ = apiserver.Pgo.GetStorageSpec(apiserver.Pgo.BackrestStorage)
 
 	// if the user passed in a value to override the pgBackRest storage
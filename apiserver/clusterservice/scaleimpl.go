@@ -331,6 +331,14 @@ func ScaleDown(deleteData bool, clusterName, replicaName, ns string) msgs.ScaleD
 		return response
 	}
 
+	// temporary replicas that were added to maintain the replica floor of the cluster have to be
+	// removed before any of the replicas that were configured for the cluster
+	if err := validateScaleDownTemporaryReplicas(clusterName, replicaName, ns); err != nil {
+		response.Status.Code = msgs.Error
+		response.Status.Msg = err.Error()
+		return response
+	}
+
 	//create the rmdata task which does the cleanup
 
 	clusterPGHAScope := cluster.ObjectMeta.Labels[config.LABEL_PGHA_SCOPE]
@@ -348,3 +356,32 @@ func ScaleDown(deleteData bool, clusterName, replicaName, ns string) msgs.ScaleD
 	response.Results = append(response.Results, "deleted replica "+replicaName)
 	return response
 }
+
+// validateScaleDownTemporaryReplicas returns an error if the replica being removed is one that was
+// configured for the cluster while the cluster still has temporary replicas, which are meant to
+// be removed first
+func validateScaleDownTemporaryReplicas(clusterName, replicaName, ns string) error {
+	replicas := crv1.PgreplicaList{}
+	selector := fmt.Sprintf("%s=%s", config.LABEL_PG_CLUSTER, clusterName)
+
+	if err := kubeapi.GetpgreplicasBySelector(apiserver.RESTClient, &replicas, selector, ns); err != nil {
+		return err
+	}
+
+	temporary := []string{}
+	isTemporary := false
+
+	for _, replica := range replicas.Items {
+		if replica.Labels[config.LABEL_TEMPORARY_REPLICA] == config.LABEL_TRUE {
+			temporary = append(temporary, replica.Name)
+			isTemporary = isTemporary || replica.Name == replicaName
+		}
+	}
+
+	if !isTemporary && len(temporary) > 0 {
+		return fmt.Errorf("cluster %s has temporary replicas (%s) that must be removed before "+
+			"replica %s", clusterName, strings.Join(temporary, ", "), replicaName)
+	}
+
+	return nil
+}
@@ -41,7 +41,7 @@ const (
 )
 
 var (
-	backrestStorageTypes = []string{"local", "s3"}
+	backrestStorageTypes = []string{"local", "s3", "gcs", "azure"}
 	// ErrDBContainerNotFound is an error that indicates that a "database" container
 	// could not be found in a specific pod
 	ErrDBContainerNotFound = errors.New("\"database\" container not found in pod")
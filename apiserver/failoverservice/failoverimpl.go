@@ -75,6 +75,11 @@ func CreateFailover(request *msgs.CreateFailoverRequest, ns, pgouser string) msg
 	labels[config.LABEL_PG_CLUSTER] = request.ClusterName
 	labels[config.LABEL_PGOUSER] = pgouser
 
+	// a forced failover may promote a replica that is not synchronous
+	if request.Force {
+		labels[config.LABEL_FORCE_FAILOVER] = "true"
+	}
+
 	newInstance := &crv1.Pgtask{
 		ObjectMeta: meta_v1.ObjectMeta{
 			Name:   spec.Name,
@@ -218,7 +218,18 @@ func CreateUser(request *msgs.CreateUserRequest, pgouser string) msgs.CreateUser
 
 		// Set the password. We want a password to be generated if the user did not
 		// set a password
-		_, password, hashedPassword := generatePassword(result.Username, request.Password, true, request.PasswordLength)
+		_, password, hashedPassword, err := generatePassword(result.Username, request.Password, true,
+			request.PasswordLength, cluster.Spec.GetPasswordEncryption())
+		if err != nil {
+			log.Error(err)
+
+			result.Error = true
+			result.ErrorMessage = err.Error()
+
+			response.Results = append(response.Results, result)
+			continue
+		}
+
 		result.Password = password
 
 		// attempt to set the password!
@@ -649,12 +660,13 @@ func executeSQL(pod *v1.Pod, sql string, extraCommandArgs []string) (string, err
 // "password" is empty, then a password will be generated. If both are set,
 // then "password" is used.
 //
-// In the future, this can be mdofifed to also support a password hashing type
-// e.g. SCRAM :)
-func generatePassword(username, password string, generatePassword bool, generatedPasswordLength int) (bool, string, string) {
+// The password is hashed with the password encryption of the cluster, i.e. as
+// a MD5 hash or as a SCRAM-SHA-256 verifier
+func generatePassword(username, password string, generatePassword bool, generatedPasswordLength int,
+	encryption crv1.PasswordEncryptionType) (bool, string, string, error) {
 	// first, an early exit: nothing is updated
 	if password == "" && !generatePassword {
-		return false, "", ""
+		return false, "", "", nil
 	}
 
 	// give precedence to the user customized password
@@ -672,10 +684,13 @@ func generatePassword(username, password string, generatePassword bool, generate
 	}
 
 	// finally, hash the password
-	hashedPassword := util.GeneratePostgreSQLMD5Password(username, password)
+	hashedPassword, err := util.GeneratePostgreSQLPassword(encryption, username, password)
+	if err != nil {
+		return false, "", "", err
+	}
 
 	// return!
-	return true, password, hashedPassword
+	return true, password, hashedPassword, nil
 }
 
 // generateValidUntilDateString returns a RFC3339 string that is computed by
@@ -872,7 +887,15 @@ func rotateExpiredPasswords(request *msgs.UpdateUserRequest, cluster *crv1.Pgclu
 		// generate a new password. Check to see if the user passed in a particular
 		// length of the password, or passed in a password to rotate (though that
 		// is not advised...). This forced the password to change
-		_, password, hashedPassword := generatePassword(result.Username, request.Password, true, request.PasswordLength)
+		_, password, hashedPassword, err := generatePassword(result.Username, request.Password, true,
+			request.PasswordLength, cluster.Spec.GetPasswordEncryption())
+		if err != nil {
+			result.Error = true
+			result.ErrorMessage = err.Error()
+
+			results = append(results, result)
+			continue
+		}
 
 		result.Password = password
 		sql = fmt.Sprintf("%s %s", sql,
@@ -939,8 +962,17 @@ func updateUser(request *msgs.UpdateUserRequest, cluster *crv1.Pgcluster) msgs.U
 	// Speaking of passwords...let's first determine if the user updated their
 	// password. See generatePassword for how precedence is given for password
 	// updates
-	isChanged, password, hashedPassword := generatePassword(result.Username,
-		request.Password, request.RotatePassword, request.PasswordLength)
+	isChanged, password, hashedPassword, err := generatePassword(result.Username,
+		request.Password, request.RotatePassword, request.PasswordLength, cluster.Spec.GetPasswordEncryption())
+
+	if err != nil {
+		log.Error(err)
+
+		result.Error = true
+		result.ErrorMessage = err.Error()
+
+		return result
+	}
 
 	if isChanged {
 		result.Password = password
@@ -122,6 +122,9 @@ type CreateClusterRequest struct {
 	// BackrestS3CASecretName specifies the name of a secret to use for the
 	// pgBackRest S3 CA instead of the default
 	BackrestS3CASecretName string
+	// WALStorageConfig, if set, is the storage configuration that the PVCs the
+	// write-ahead log of the instances is kept on are created with
+	WALStorageConfig string
 }
 
 // CreateClusterDetail provides details about the PostgreSQL cluster that is
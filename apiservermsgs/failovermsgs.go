@@ -47,6 +47,7 @@ type CreateFailoverRequest struct {
 	Namespace     string
 	ClusterName   string
 	Target        string
+	Force         bool
 	ClientVersion string
 }
 
@@ -18,10 +18,44 @@ package config
 // annotations used by the operator
 const (
 	ANNOTATION_PGHA_BOOTSTRAP_REPLICA    = "pgo-pgha-bootstrap-replica"
+	ANNOTATION_CHARGEBACK_LABELS         = "crunchydata.com/chargeback-labels"
+	ANNOTATION_BACKREST_REPOS_CHECKSUM   = "crunchydata.com/backrest-repos-checksum"
 	ANNOTATION_CLONE_BACKREST_PVC_SIZE   = "clone-backrest-pvc-size"
 	ANNOTATION_CLONE_ENABLE_METRICS      = "clone-enable-metrics"
 	ANNOTATION_CLONE_PVC_SIZE            = "clone-pvc-size"
 	ANNOTATION_CLONE_SOURCE_CLUSTER_NAME = "clone-source-cluster-name"
 	ANNOTATION_CLONE_TARGET_CLUSTER_NAME = "clone-target-cluster-name"
+	ANNOTATION_CONNECTION_LOGGING_UNTIL  = "connection-logging-until"
+	ANNOTATION_CUSTOM_ANNOTATIONS        = "crunchydata.com/custom-annotations"
+	ANNOTATION_CUSTOM_LABELS             = "crunchydata.com/custom-labels"
+	ANNOTATION_DELETION_PROTECTION_FORCE = "deletion-protection-force"
+	ANNOTATION_EXPORTER_QUERIES_HASH     = "crunchydata.com/exporter-queries-hash"
+	ANNOTATION_EXPORTER_SPEC_HASH        = "crunchydata.com/exporter-spec-hash"
+	ANNOTATION_INSTANCE_POD              = "crunchydata.com/instance-pod"
 	ANNOTATION_PRIMARY_DEPLOYMENT        = "primary-deployment"
+	ANNOTATION_PAUSED                    = "crunchydata.com/paused"
+	ANNOTATION_PGBOUNCER_SPEC_HASH       = "crunchydata.com/pgbouncer-spec-hash"
+	ANNOTATION_RECOMMENDED_RESOURCES     = "crunchydata.com/recommended-resources"
+	ANNOTATION_RECONCILE_NOW             = "crunchydata.com/reconcile-now"
+	ANNOTATION_ROTATE_PASSWORD           = "crunchydata.com/rotate-password"
+	ANNOTATION_SAFE_MODE_RELEASE         = "crunchydata.com/safe-mode-release"
+	ANNOTATION_SCHEDULING_GATE           = "crunchydata.com/scheduling-gate"
+	ANNOTATION_SERVICE_ANNOTATIONS       = "crunchydata.com/service-annotations"
+	ANNOTATION_SNAPSHOT_DATA_DIRECTORY   = "crunchydata.com/snapshot-data-directory"
+	ANNOTATION_TLS_CERT_HASH             = "crunchydata.com/tls-cert-hash"
+	ANNOTATION_TRANSIENT_FAILURE         = "crunchydata.com/transient-failure"
+)
+
+// finalizers used by the operator
+const (
+	// FINALIZER_CLEANUP holds on to a pgcluster that is deleted until the
+	// Operator has removed the resources of the cluster
+	FINALIZER_CLEANUP = "crunchydata.com/cleanup"
+	// FINALIZER_DROP_ROLE holds on to a pguser that is deleted until the
+	// Operator has dropped its role from the cluster
+	FINALIZER_DROP_ROLE = "crunchydata.com/drop-role"
+	// FINALIZER_SERVICE_DISCOVERY holds on to a pgcluster that is deleted until
+	// the Operator has removed its ExternalName Service, which is in a
+	// namespace of its own and so cannot be owned by the cluster
+	FINALIZER_SERVICE_DISCOVERY = "crunchydata.com/service-discovery"
 )
@@ -27,6 +27,9 @@ const LABEL_PGTASK = "pg-task"
 
 const LABEL_AUTOFAIL = "autofail"
 const LABEL_FAILOVER = "failover"
+const LABEL_FORCE_FAILOVER = "force-failover"
+const LABEL_DELAYED_REPLICA = "delayed-replica"
+const LABEL_LAGGING_REPLICA = "lagging-replica"
 
 const LABEL_TARGET = "target"
 const LABEL_RMDATA = "pgrmdata"
@@ -43,6 +46,10 @@ const LABEL_CUSTOM_CONFIG = "custom-config"
 const LABEL_NODE_LABEL_KEY = "NodeLabelKey"
 const LABEL_NODE_LABEL_VALUE = "NodeLabelValue"
 const LABEL_REPLICA_NAME = "replica-name"
+const LABEL_TEMPORARY_REPLICA = "temporary-replica"
+const LABEL_AUTOSCALED_REPLICA = "autoscaled-replica"
+const LABEL_RESTART_PRIORITY = "restart-priority"
+const LABEL_ZONE = "pgo-zone"
 const LABEL_CCP_IMAGE_TAG_KEY = "ccp-image-tag"
 const LABEL_CCP_IMAGE_KEY = "ccp-image"
 const LABEL_SERVICE_TYPE = "service-type"
@@ -58,6 +65,7 @@ const LABEL_PGO_VERSION = "pgo-version"
 const LABEL_UPGRADE_DATE = "operator-upgrade-date"
 const LABEL_DELETE_DATA = "delete-data"
 const LABEL_DELETE_DATA_STARTED = "delete-data-started"
+const LABEL_FINAL_BACKUP_COMPLETED = "final-backup-completed"
 const LABEL_DELETE_BACKUPS = "delete-backups"
 const LABEL_IS_REPLICA = "is-replica"
 const LABEL_IS_BACKUP = "is-backup"
@@ -70,6 +78,9 @@ const LABEL_UPGRADE_COMPLETED = "upgrade-complete"
 const LABEL_UPGRADE_REPLICA = "upgrade-replicas"
 const LABEL_UPGRADE_PRIMARY = "upgrade-primary"
 const LABEL_UPGRADE_BACKREST = "upgrade-backrest"
+const LABEL_MAJOR_UPGRADE_PRECHECK = "major-upgrade-precheck"
+const LABEL_MAJOR_UPGRADE = "major-upgrade"
+const LABEL_DATA_CHECKSUMS = "data-checksums"
 
 const LABEL_BACKREST = "pgo-backrest"
 const LABEL_BACKREST_JOB = "pgo-backrest-job"
@@ -136,6 +147,13 @@ const LABEL_PGBACKREST_DB_PATH = "pgbackrest-db-path"
 const LABEL_PGBACKREST_REPO_PATH = "pgbackrest-repo-path"
 const LABEL_PGBACKREST_REPO_HOST = "pgbackrest-repo-host"
 
+// the index of the additional pgBackRest repository that a pgschedule backs up to
+const LABEL_PGBACKREST_REPO_INDEX = "pgbackrest-repo-index"
+
+// marks the stanza-create job of the additional pgBackRest repositories, which
+// unlike that of a new cluster is not followed by the initial backup
+const LABEL_PGBACKREST_REPOS = "pgbackrest-repos"
+
 const LABEL_PGO_BACKREST_REPO = "pgo-backrest-repo"
 
 // a general label for grouping all the tasks...helps with cleanups
@@ -146,6 +164,14 @@ const LABEL_PGO_CLONE_STEP_1 = "pgo-clone-step-1"
 const LABEL_PGO_CLONE_STEP_2 = "pgo-clone-step-2"
 const LABEL_PGO_CLONE_STEP_3 = "pgo-clone-step-3"
 
+// marks the VolumeSnapshots that are taken on the schedule of a cluster, which
+// are kept according to its retention
+const LABEL_PGO_SCHEDULED_SNAPSHOT = "pgo-scheduled-snapshot"
+
+// marks the CA Secret that the Operator generated for a cluster, which is the
+// only CA Secret that it rotates
+const LABEL_PGO_GENERATED_CA = "pgo-generated-ca"
+
 const LABEL_DEPLOYMENT_NAME = "deployment-name"
 const LABEL_SERVICE_NAME = "service-name"
 const LABEL_CURRENT_PRIMARY = "current-primary"
@@ -168,6 +194,10 @@ const LABEL_PGO_CREATED_BY = "pgo-created-by"
 const LABEL_PGO_UPDATED_BY = "pgo-updated-by"
 
 const LABEL_PGO_DEFAULT_SC = "pgo-default-sc"
+const LABEL_CRUNCHY_SCHEDULER = "crunchy-scheduler"
+
+const LABEL_SERVICE_DISCOVERY_CLUSTER = "service-discovery-cluster"
+const LABEL_SERVICE_DISCOVERY_NAMESPACE = "service-discovery-namespace"
 const LABEL_FAILOVER_STARTED = "failover-started"
 
 const GLOBAL_CUSTOM_CONFIGMAP = "pgo-custom-pg-config"
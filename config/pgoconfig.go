@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"regexp"
 	"strconv"
 	"strings"
 	"text/template"
@@ -29,6 +30,7 @@ import (
 	log "github.com/sirupsen/logrus"
 	"gopkg.in/yaml.v2"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/client-go/kubernetes"
 )
@@ -137,6 +139,14 @@ var PgbouncerHBATemplate *template.Template
 
 const pgbouncerHBATemplatePath = "pgbouncer_hba.conf"
 
+var OdysseyTemplate *template.Template
+
+const odysseyTemplatePath = "odyssey-template.json"
+
+var OdysseyConfTemplate *template.Template
+
+const odysseyConfTemplatePath = "odyssey.conf"
+
 var ServiceTemplate *template.Template
 
 const serviceTemplatePath = "cluster-service.json"
@@ -173,6 +183,10 @@ var CollectTemplate *template.Template
 
 const collectTemplatePath = "collect.json"
 
+var PrometheusRulesTemplate *template.Template
+
+const prometheusRulesTemplatePath = "prometheus-rules.json"
+
 var BadgerTemplate *template.Template
 
 const badgerTemplatePath = "pgbadger.json"
@@ -211,6 +225,31 @@ type ClusterStruct struct {
 	PodAntiAffinityPgBackRest     string `yaml:"PodAntiAffinityPgBackRest"`
 	PodAntiAffinityPgBouncer      string `yaml:"PodAntiAffinityPgBouncer"`
 	SyncReplication               bool   `yaml:"SyncReplication"`
+	// BackrestVersion is the version of pgBackRest in the images of the
+	// clusters, which the pgBackRest features that need a later version than
+	// the images of this release ship are held to
+	BackrestVersion string `yaml:"BackrestVersion"`
+	// NodeSelector and Tolerations are applied to the Pods of every cluster,
+	// and can be overridden per cluster
+	NodeSelector map[string]string  `yaml:"NodeSelector"`
+	Tolerations  []TolerationStruct `yaml:"Tolerations"`
+	// ProvisioningConcurrency, if set, is how many PostgreSQL Pods the
+	// Operator lets start at once, holding back the rest behind its scheduling
+	// gate until the ones that started are ready
+	ProvisioningConcurrency int `yaml:"ProvisioningConcurrency"`
+	// ClusterDomain is the DNS domain of the Kubernetes cluster, which the
+	// fully qualified domain names of the primaries are built with
+	ClusterDomain string `yaml:"ClusterDomain"`
+}
+
+// TolerationStruct is a toleration that is applied to the Pods of every
+// cluster
+type TolerationStruct struct {
+	Key               string `yaml:"Key"`
+	Operator          string `yaml:"Operator"`
+	Value             string `yaml:"Value"`
+	Effect            string `yaml:"Effect"`
+	TolerationSeconds *int64 `yaml:"TolerationSeconds"`
 }
 
 type StorageStruct struct {
@@ -233,6 +272,79 @@ type PgoStruct struct {
 	Audit          bool   `yaml:"Audit"`
 	PGOImagePrefix string `yaml:"PGOImagePrefix"`
 	PGOImageTag    string `yaml:"PGOImageTag"`
+	// RestartBudget is the maximum number of clusters, across all namespaces,
+	// that the Operator restarts at the same time. Zero means there is no limit
+	RestartBudget int `yaml:"RestartBudget"`
+	// RestartBudgetTimeoutSeconds is how long a restart waits for its turn
+	// before a warning is logged, after which it keeps its place in the queue
+	RestartBudgetTimeoutSeconds int `yaml:"RestartBudgetTimeoutSeconds"`
+	// IdempotencyWindowSeconds is how long after it completed a
+	// pgtask keeps another pgtask with the same idempotency key from running
+	IdempotencyWindowSeconds int `yaml:"IdempotencyWindowSeconds"`
+	// TaskRetryLimits are the number of times a pgtask of a type, e.g.
+	// "backrest", is retried after it failed for a transient reason, such as
+	// an eviction of its Pod. The pgtasks of the other types are not retried
+	TaskRetryLimits map[string]int `yaml:"TaskRetryLimits"`
+	// TaskRetryBackoffSeconds is how long the first retry of a pgtask waits,
+	// which doubles with each retry that follows
+	TaskRetryBackoffSeconds int `yaml:"TaskRetryBackoffSeconds"`
+	// TaskRetryMaxBackoffSeconds is the longest that a retry of a pgtask waits
+	TaskRetryMaxBackoffSeconds int `yaml:"TaskRetryMaxBackoffSeconds"`
+	// TaskPendingTimeoutSeconds is how long the Pod of a Job of the Operator
+	// can wait for a transient reason, e.g. as its image cannot be pulled,
+	// before the Job is failed, so that its pgtask is retried or failed
+	TaskPendingTimeoutSeconds int `yaml:"TaskPendingTimeoutSeconds"`
+	// TaskTTLSeconds, if set, is how long after they finished the pgtasks and
+	// the Jobs of the Operator, along with their Pods, are kept before they
+	// are removed. Zero keeps them until they are removed by hand
+	TaskTTLSeconds int `yaml:"TaskTTLSeconds"`
+	// ChargebackLabels are the keys of the labels of a Namespace that are
+	// copied onto the resources that the Operator creates in that Namespace
+	ChargebackLabels []string `yaml:"ChargebackLabels"`
+	// SafeModeSeconds, if set, has the Operator start in safe mode: for this
+	// many seconds, or until it is given the go-ahead, its reconciles are only
+	// logged, and are carried out once safe mode ends
+	SafeModeSeconds int `yaml:"SafeModeSeconds"`
+	// SafeModeExemptions are the reconciles that are carried out even in safe
+	// mode, e.g. "failover", so that an upgrade does not hold up a recovery
+	SafeModeExemptions []string `yaml:"SafeModeExemptions"`
+	// NamespaceSelector, if set, is the label selector of the namespaces the
+	// Operator watches, e.g. "pgo-enabled=true". The namespaces are picked up
+	// and dropped as they are labeled, rather than fixed when it starts
+	NamespaceSelector string `yaml:"NamespaceSelector"`
+	// LeaderElection, if set, has the replicas of the Operator elect a leader
+	// with a Lease in the namespace of the Operator, so that only the leader
+	// runs the controllers while the others stand by
+	LeaderElection bool `yaml:"LeaderElection"`
+	// LeaseDurationSeconds is how long the replicas that stand by wait before
+	// they attempt to take over a Lease that has not been renewed
+	LeaseDurationSeconds int `yaml:"LeaseDurationSeconds"`
+	// RenewDeadlineSeconds is how long the leader keeps trying to renew its
+	// Lease before it gives up its leadership
+	RenewDeadlineSeconds int `yaml:"RenewDeadlineSeconds"`
+	// RetryPeriodSeconds is how often the Lease is attempted to be acquired or
+	// renewed
+	RetryPeriodSeconds int `yaml:"RetryPeriodSeconds"`
+	// MetricsPort, if set, is the port on which the Operator serves its
+	// metrics, i.e. those of the leader election and of the work queues and
+	// informers of its controllers, at /metrics
+	MetricsPort string `yaml:"MetricsPort"`
+	// ControllerWorkerCount is the number of workers that each of the pgtask,
+	// pgcluster and pgreplica controllers runs in each namespace
+	ControllerWorkerCount int `yaml:"ControllerWorkerCount"`
+	// HealthPort is the port on which the Operator serves its health at
+	// /healthz and its readiness at /readyz, for the probes of its Pod
+	HealthPort string `yaml:"HealthPort"`
+	// ShutdownTimeoutSeconds is how long the Operator lets its controllers
+	// finish the work they have queued as it shuts down, which has to be less
+	// than the termination grace period of its Pod
+	ShutdownTimeoutSeconds int `yaml:"ShutdownTimeoutSeconds"`
+	// WebhookPort, if set, is the port on which the Operator serves the
+	// validating admission webhook of the pgclusters
+	WebhookPort string `yaml:"WebhookPort"`
+	// WebhookTLSSecret is the TLS Secret in the namespace of the Operator that
+	// the admission webhook is served with
+	WebhookTLSSecret string `yaml:"WebhookTLSSecret"`
 }
 
 type PgoConfig struct {
@@ -261,10 +373,40 @@ const CONFIG_PATH = "pgo.yaml"
 var log_statement_values = []string{"ddl", "none", "mod", "all"}
 
 const DEFAULT_BACKREST_PORT = 2022
+
+// DEFAULT_BACKREST_VERSION is the version of pgBackRest in the images of this release
+const DEFAULT_BACKREST_VERSION = "2.24"
+
+// backrestVersionPattern is the format of a version of pgBackRest, e.g. "2.33" or "2.33.1"
+var backrestVersionPattern = regexp.MustCompile(`^[0-9]+(\.[0-9]+){1,2}$`)
+
+// DEFAULT_CLUSTER_DOMAIN is the DNS domain of a Kubernetes cluster unless it is configured
+// otherwise
+const DEFAULT_CLUSTER_DOMAIN = "cluster.local"
+
 const DEFAULT_PGBADGER_PORT = "10000"
 const DEFAULT_EXPORTER_PORT = "9187"
 const DEFAULT_POSTGRES_PORT = "5432"
 const DEFAULT_PATRONI_PORT = "8009"
+const DEFAULT_CONTROLLER_WORKER_COUNT = 1
+const DEFAULT_HEALTH_PORT = "8081"
+const DEFAULT_SHUTDOWN_TIMEOUT_SECONDS = 20
+const DEFAULT_WEBHOOK_TLS_SECRET = "pgo-webhook.tls"
+const DEFAULT_TASK_RETRY_BACKOFF_SECONDS = 30
+const DEFAULT_TASK_RETRY_MAX_BACKOFF_SECONDS = 600
+const DEFAULT_TASK_PENDING_TIMEOUT_SECONDS = 300
+
+// DEFAULT_TASK_RETRY_LIMITS are the retries of the pgtasks whose Jobs can be run again without
+// harm, i.e. backups
+var DEFAULT_TASK_RETRY_LIMITS = map[string]int{
+	crv1.PgtaskBackrest: 3,
+	crv1.PgtaskpgDump:   3,
+}
+
+// the defaults of the leader election, which are those of the controllers of Kubernetes itself
+const DEFAULT_LEASE_DURATION_SECONDS = 15
+const DEFAULT_RENEW_DEADLINE_SECONDS = 10
+const DEFAULT_RETRY_PERIOD_SECONDS = 2
 
 func (c *PgoConfig) Validate() error {
 	var err error
@@ -274,6 +416,12 @@ func (c *PgoConfig) Validate() error {
 		c.Cluster.BackrestPort = DEFAULT_BACKREST_PORT
 		log.Infof("setting BackrestPort to default %d", c.Cluster.BackrestPort)
 	}
+	if c.Cluster.BackrestVersion == "" {
+		c.Cluster.BackrestVersion = DEFAULT_BACKREST_VERSION
+		log.Infof("setting BackrestVersion to default %s", c.Cluster.BackrestVersion)
+	} else if !backrestVersionPattern.MatchString(c.Cluster.BackrestVersion) {
+		return errors.New(errPrefix + "Invalid BackrestVersion: " + c.Cluster.BackrestVersion)
+	}
 	if c.Cluster.PGBadgerPort == "" {
 		c.Cluster.PGBadgerPort = DEFAULT_PGBADGER_PORT
 		log.Infof("setting PGBadgerPort to default %s", c.Cluster.PGBadgerPort)
@@ -344,6 +492,92 @@ func (c *PgoConfig) Validate() error {
 	if c.Pgo.PGOImageTag == "" {
 		return errors.New(errPrefix + "Pgo.PGOImageTag is required")
 	}
+	if c.Pgo.ControllerWorkerCount == 0 {
+		c.Pgo.ControllerWorkerCount = DEFAULT_CONTROLLER_WORKER_COUNT
+		log.Infof("setting ControllerWorkerCount to default %d", c.Pgo.ControllerWorkerCount)
+	} else if c.Pgo.ControllerWorkerCount < 0 {
+		return errors.New(errPrefix + "Pgo.ControllerWorkerCount must not be negative")
+	}
+	if c.Pgo.LeaderElection {
+		if c.Pgo.LeaseDurationSeconds == 0 {
+			c.Pgo.LeaseDurationSeconds = DEFAULT_LEASE_DURATION_SECONDS
+			log.Infof("setting LeaseDurationSeconds to default %d", c.Pgo.LeaseDurationSeconds)
+		}
+		if c.Pgo.RenewDeadlineSeconds == 0 {
+			c.Pgo.RenewDeadlineSeconds = DEFAULT_RENEW_DEADLINE_SECONDS
+			log.Infof("setting RenewDeadlineSeconds to default %d", c.Pgo.RenewDeadlineSeconds)
+		}
+		if c.Pgo.RetryPeriodSeconds == 0 {
+			c.Pgo.RetryPeriodSeconds = DEFAULT_RETRY_PERIOD_SECONDS
+			log.Infof("setting RetryPeriodSeconds to default %d", c.Pgo.RetryPeriodSeconds)
+		}
+		if c.Pgo.LeaseDurationSeconds <= c.Pgo.RenewDeadlineSeconds ||
+			c.Pgo.RenewDeadlineSeconds <= c.Pgo.RetryPeriodSeconds {
+			return errors.New(errPrefix + "Pgo.LeaseDurationSeconds must be greater than " +
+				"Pgo.RenewDeadlineSeconds, which must be greater than Pgo.RetryPeriodSeconds")
+		}
+	}
+	if c.Pgo.HealthPort == "" {
+		c.Pgo.HealthPort = DEFAULT_HEALTH_PORT
+		log.Infof("setting HealthPort to default %s", c.Pgo.HealthPort)
+	} else if _, err := strconv.Atoi(c.Pgo.HealthPort); err != nil {
+		return errors.New(errPrefix + "Invalid Pgo.HealthPort: " + err.Error())
+	}
+	if c.Pgo.ShutdownTimeoutSeconds == 0 {
+		c.Pgo.ShutdownTimeoutSeconds = DEFAULT_SHUTDOWN_TIMEOUT_SECONDS
+		log.Infof("setting ShutdownTimeoutSeconds to default %d", c.Pgo.ShutdownTimeoutSeconds)
+	} else if c.Pgo.ShutdownTimeoutSeconds < 0 {
+		return errors.New(errPrefix + "Pgo.ShutdownTimeoutSeconds must not be negative")
+	}
+	if c.Pgo.TaskRetryLimits == nil {
+		c.Pgo.TaskRetryLimits = DEFAULT_TASK_RETRY_LIMITS
+		log.Infof("setting TaskRetryLimits to default %v", c.Pgo.TaskRetryLimits)
+	}
+	for taskType, limit := range c.Pgo.TaskRetryLimits {
+		if limit < 0 {
+			return errors.New(errPrefix + "Pgo.TaskRetryLimits of " + taskType + " must not be negative")
+		}
+	}
+	if c.Pgo.TaskRetryBackoffSeconds == 0 {
+		c.Pgo.TaskRetryBackoffSeconds = DEFAULT_TASK_RETRY_BACKOFF_SECONDS
+		log.Infof("setting TaskRetryBackoffSeconds to default %d", c.Pgo.TaskRetryBackoffSeconds)
+	} else if c.Pgo.TaskRetryBackoffSeconds < 0 {
+		return errors.New(errPrefix + "Pgo.TaskRetryBackoffSeconds must not be negative")
+	}
+	if c.Pgo.TaskRetryMaxBackoffSeconds == 0 {
+		c.Pgo.TaskRetryMaxBackoffSeconds = DEFAULT_TASK_RETRY_MAX_BACKOFF_SECONDS
+		log.Infof("setting TaskRetryMaxBackoffSeconds to default %d", c.Pgo.TaskRetryMaxBackoffSeconds)
+	} else if c.Pgo.TaskRetryMaxBackoffSeconds < 0 {
+		return errors.New(errPrefix + "Pgo.TaskRetryMaxBackoffSeconds must not be negative")
+	}
+	if c.Pgo.TaskPendingTimeoutSeconds == 0 {
+		c.Pgo.TaskPendingTimeoutSeconds = DEFAULT_TASK_PENDING_TIMEOUT_SECONDS
+		log.Infof("setting TaskPendingTimeoutSeconds to default %d", c.Pgo.TaskPendingTimeoutSeconds)
+	} else if c.Pgo.TaskPendingTimeoutSeconds < 0 {
+		return errors.New(errPrefix + "Pgo.TaskPendingTimeoutSeconds must not be negative")
+	}
+	if c.Pgo.TaskTTLSeconds < 0 {
+		return errors.New(errPrefix + "Pgo.TaskTTLSeconds must not be negative")
+	}
+	if c.Pgo.WebhookPort != "" {
+		if _, err := strconv.Atoi(c.Pgo.WebhookPort); err != nil {
+			return errors.New(errPrefix + "Invalid Pgo.WebhookPort: " + err.Error())
+		}
+		if c.Pgo.WebhookTLSSecret == "" {
+			c.Pgo.WebhookTLSSecret = DEFAULT_WEBHOOK_TLS_SECRET
+			log.Infof("setting WebhookTLSSecret to default %s", c.Pgo.WebhookTLSSecret)
+		}
+	}
+	if c.Pgo.MetricsPort != "" {
+		if _, err := strconv.Atoi(c.Pgo.MetricsPort); err != nil {
+			return errors.New(errPrefix + "Invalid Pgo.MetricsPort: " + err.Error())
+		}
+	}
+	if c.Pgo.NamespaceSelector != "" {
+		if _, err := labels.Parse(c.Pgo.NamespaceSelector); err != nil {
+			return errors.New(errPrefix + "Invalid Pgo.NamespaceSelector: " + err.Error())
+		}
+	}
 
 	if c.DefaultContainerResources != "" {
 		_, ok = c.ContainerResources[c.DefaultContainerResources]
@@ -433,9 +667,66 @@ func (c *PgoConfig) Validate() error {
 		return errors.New(errPrefix + "Invalid value provided for Cluster.PodAntiAffinityPgBouncer")
 	}
 
+	// if provided, ensure that the default node selector and tolerations are valid
+	for key := range c.Cluster.NodeSelector {
+		if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+			return errors.New(errPrefix + "Invalid key provided for Cluster.NodeSelector: " +
+				strings.Join(errs, ", "))
+		}
+	}
+
+	for _, toleration := range c.Cluster.Tolerations {
+		switch v1.TolerationOperator(toleration.Operator) {
+		case "", v1.TolerationOpEqual:
+		case v1.TolerationOpExists:
+			if toleration.Value != "" {
+				return errors.New(errPrefix + "Cluster.Tolerations with the Exists operator cannot have a Value")
+			}
+		default:
+			return errors.New(errPrefix + "Invalid Operator provided for Cluster.Tolerations: " +
+				toleration.Operator)
+		}
+
+		switch v1.TaintEffect(toleration.Effect) {
+		case "", v1.TaintEffectNoSchedule, v1.TaintEffectPreferNoSchedule, v1.TaintEffectNoExecute:
+		default:
+			return errors.New(errPrefix + "Invalid Effect provided for Cluster.Tolerations: " +
+				toleration.Effect)
+		}
+	}
+
+	if c.Cluster.ClusterDomain == "" {
+		c.Cluster.ClusterDomain = DEFAULT_CLUSTER_DOMAIN
+		log.Infof("setting ClusterDomain to default %s", c.Cluster.ClusterDomain)
+	} else if errs := validation.IsDNS1123Subdomain(c.Cluster.ClusterDomain); len(errs) > 0 {
+		return errors.New(errPrefix + "Invalid Cluster.ClusterDomain: " + strings.Join(errs, ", "))
+	}
+
+	if c.Cluster.ProvisioningConcurrency < 0 {
+		return errors.New(errPrefix + "Cluster.ProvisioningConcurrency must not be negative")
+	}
+
 	return err
 }
 
+// GetTolerations returns the default tolerations that are applied to the Pods
+// of every cluster
+func (c *PgoConfig) GetTolerations() []v1.Toleration {
+	tolerations := []v1.Toleration{}
+
+	for _, toleration := range c.Cluster.Tolerations {
+		tolerations = append(tolerations, v1.Toleration{
+			Key:               toleration.Key,
+			Operator:          v1.TolerationOperator(toleration.Operator),
+			Value:             toleration.Value,
+			Effect:            v1.TaintEffect(toleration.Effect),
+			TolerationSeconds: toleration.TolerationSeconds,
+		})
+	}
+
+	return tolerations
+}
+
 func (c *PgoConfig) GetConf() *PgoConfig {
 
 	yamlFile, err := ioutil.ReadFile(CONFIG_PATH)
@@ -720,6 +1011,16 @@ func (c *PgoConfig) GetConfig(clientset *kubernetes.Clientset, namespace string)
 		return err
 	}
 
+	OdysseyTemplate, err = c.LoadTemplate(cMap, rootPath, odysseyTemplatePath)
+	if err != nil {
+		return err
+	}
+
+	OdysseyConfTemplate, err = c.LoadTemplate(cMap, rootPath, odysseyConfTemplatePath)
+	if err != nil {
+		return err
+	}
+
 	ServiceTemplate, err = c.LoadTemplate(cMap, rootPath, serviceTemplatePath)
 	if err != nil {
 		return err
@@ -775,6 +1076,11 @@ func (c *PgoConfig) GetConfig(clientset *kubernetes.Clientset, namespace string)
 		return err
 	}
 
+	PrometheusRulesTemplate, err = c.LoadTemplate(cMap, rootPath, prometheusRulesTemplatePath)
+	if err != nil {
+		return err
+	}
+
 	BadgerTemplate, err = c.LoadTemplate(cMap, rootPath, badgerTemplatePath)
 	if err != nil {
 		return err
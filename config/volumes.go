@@ -19,6 +19,13 @@ package config
 const VOLUME_POSTGRESQL_DATA = "pgdata"
 const VOLUME_POSTGRESQL_DATA_MOUNT_PATH = "/pgdata"
 
+// volume configuration settings used by the write-ahead log when it is kept on
+// a volume of its own. The PVC follows "<instanceName>-wal", as does the
+// directory on it that the WAL is in
+const VOLUME_POSTGRESQL_WAL = "pgwal-volume"
+const VOLUME_POSTGRESQL_WAL_MOUNT_PATH = "/pgwal"
+const VOLUME_POSTGRESQL_WAL_PVC_NAME_FORMAT = "%s-wal"
+
 // volume configuration settings used by the pgBackRest repo mount
 const VOLUME_PGBACKREST_REPO_NAME = "backrestrepo"
 const VOLUME_PGBACKREST_REPO_MOUNT_PATH = "/backrestrepo"
@@ -26,6 +26,13 @@ import (
 // WorkerRunner is an interface for controllers the have worker queues that need to be run
 type WorkerRunner interface {
 	RunWorker()
+	// GetWorkerCount returns the number of workers that are run for the queue
+	GetWorkerCount() int
+}
+
+// PeriodicRunner is an interface for controllers that have work that needs to be run periodically
+type PeriodicRunner interface {
+	RunPeriodic()
 }
 
 // ManagerInterface defines the interface for a controller manager
@@ -38,6 +45,8 @@ type ManagerInterface interface {
 	StopGroup(namespace string)
 	RemoveAll()
 	RemoveGroup(namespace string)
+	PauseGroup(namespace string)
+	ResumeGroup(namespace string)
 }
 
 // InitializeReplicaCreation initializes the creation of replicas for a cluster.  For a regular
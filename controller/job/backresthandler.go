@@ -26,6 +26,7 @@ import (
 	"github.com/crunchydata/postgres-operator/kubeapi"
 	backrestoperator "github.com/crunchydata/postgres-operator/operator/backrest"
 	clusteroperator "github.com/crunchydata/postgres-operator/operator/cluster"
+	taskoperator "github.com/crunchydata/postgres-operator/operator/task"
 	"github.com/crunchydata/postgres-operator/util"
 	log "github.com/sirupsen/logrus"
 	apiv1 "k8s.io/api/batch/v1"
@@ -34,6 +35,52 @@ import (
 // backrestUpdateHandler is responsible for handling updates to backrest jobs
 func (c *Controller) handleBackrestUpdate(job *apiv1.Job) error {
 
+	// a failed final backup blocks the removal of the cluster, which needs to be reflected in the
+	// cluster status
+	if isJobFailed(job) &&
+		job.GetObjectMeta().GetLabels()[config.LABEL_PGHA_BACKUP_TYPE] == crv1.BackupTypeFinal {
+		return taskoperator.UpdateFinalBackup(c.JobClientset, c.JobClient,
+			job.GetObjectMeta().GetLabels()[config.LABEL_PG_CLUSTER], job.Namespace, false)
+	}
+
+	// a failed backup after a major upgrade leaves the replicas of the cluster stopped, which
+	// needs to be reflected in the major upgrade pgtask
+	if isJobFailed(job) &&
+		job.GetObjectMeta().GetLabels()[config.LABEL_PGHA_BACKUP_TYPE] == crv1.BackupTypeMajorUpgrade {
+		if err := clusteroperator.UpdateMajorUpgradeBackup(c.JobClientset, c.JobClient, c.JobConfig,
+			job.GetObjectMeta().GetLabels()[config.LABEL_PG_CLUSTER], job.Namespace, false); err != nil {
+			log.Error(err)
+		}
+	}
+
+	// record that a backup failed in its pgtask, which allows a backup with the same idempotency
+	// key to be submitted again
+	if isJobFailed(job) &&
+		job.GetObjectMeta().GetLabels()[config.LABEL_BACKREST_COMMAND] == "backup" {
+		if err := util.Patch(c.JobClient, patchURL, crv1.JobErrorStatus, patchResource, job.Name,
+			job.ObjectMeta.Namespace); err != nil {
+			log.Errorf("error in patching pgtask %s: %s", job.ObjectMeta.SelfLink, err.Error())
+		}
+	}
+
+	// record that a restore failed in the point-in-time recovery it is part of, if it is
+	if isJobFailed(job) &&
+		job.GetObjectMeta().GetLabels()[config.LABEL_BACKREST_RESTORE] == "true" &&
+		job.GetObjectMeta().GetLabels()[config.LABEL_PGO_CLONE_STEP_2] != "true" {
+		if err := backrestoperator.FailPITRRestore(c.JobClient,
+			job.GetObjectMeta().GetLabels()[crv1.PgtaskWorkflowID], job.Namespace,
+			"restore job "+job.Name+" failed"); err != nil {
+			log.Error(err)
+		}
+	}
+
+	// record the outcome of a verification of the repository, which matters whether it
+	// succeeded or failed
+	if (isJobFailed(job) || isJobSuccessful(job)) &&
+		job.GetObjectMeta().GetLabels()[config.LABEL_BACKREST_COMMAND] == crv1.PgtaskBackrestVerify {
+		return c.handleBackrestVerifyUpdate(job)
+	}
+
 	// return if job wasn't successful
 	if !isJobSuccessful(job) {
 		log.Debugf("jobController onUpdate job %s was unsuccessful and will be ignored",
@@ -181,6 +228,22 @@ func (c *Controller) handleBackrestBackupUpdate(job *apiv1.Job) error {
 			log.Error(err)
 			return err
 		}
+	} else if labels[config.LABEL_PGHA_BACKUP_TYPE] == crv1.BackupTypeFinal {
+		// the final backup of a cluster with deletion protection is done, so the removal of the
+		// cluster can proceed
+		if err := taskoperator.UpdateFinalBackup(c.JobClientset, c.JobClient,
+			labels[config.LABEL_PG_CLUSTER], job.ObjectMeta.Namespace, true); err != nil {
+			log.Error(err)
+			return err
+		}
+	} else if labels[config.LABEL_PGHA_BACKUP_TYPE] == crv1.BackupTypeMajorUpgrade {
+		// the backup after a major upgrade is done, so the replicas of the cluster can be
+		// rebuilt from it
+		if err := clusteroperator.UpdateMajorUpgradeBackup(c.JobClientset, c.JobClient, c.JobConfig,
+			labels[config.LABEL_PG_CLUSTER], job.ObjectMeta.Namespace, true); err != nil {
+			log.Error(err)
+			return err
+		}
 	}
 	return nil
 }
@@ -195,6 +258,14 @@ func (c *Controller) handleBackrestStanzaCreateUpdate(job *apiv1.Job) error {
 	clusterName := labels[config.LABEL_PG_CLUSTER]
 	namespace := job.Namespace
 
+	// the stanza of additional repositories is created for a cluster that has already been
+	// backed up, so there is nothing more to do
+	if job.Status.Succeeded == 1 && labels[config.LABEL_PGBACKREST_REPOS] == "true" {
+		log.Debugf("backrest stanza of the additional repositories created for cluster %s",
+			clusterName)
+		return nil
+	}
+
 	if job.Status.Succeeded == 1 {
 		log.Debugf("backrest stanza successfully created for cluster %s", clusterName)
 		log.Debugf("proceeding with the initial full backup for cluster %s as needed for replica creation",
@@ -231,3 +302,29 @@ func (c *Controller) handleBackrestStanzaCreateUpdate(job *apiv1.Job) error {
 	}
 	return nil
 }
+
+// handleBackrestVerifyUpdate is responsible for handling updates to backrest verify jobs, which
+// record whether the verification of the repository succeeded in the cluster
+func (c *Controller) handleBackrestVerifyUpdate(job *apiv1.Job) error {
+
+	clusterName := job.GetObjectMeta().GetLabels()[config.LABEL_PG_CLUSTER]
+	log.Debugf("jobController onUpdate backrest verify job case")
+
+	if isJobSuccessful(job) {
+		return clusteroperator.RecordBackrestVerification(c.JobClientset, c.JobClient,
+			clusterName, job.Namespace, job.Status.CompletionTime.Time, nil)
+	}
+
+	// a failed job has no completion time, so the time it failed at is taken from its
+	// condition
+	failed := time.Now()
+	for _, condition := range job.Status.Conditions {
+		if condition.Type == apiv1.JobFailed {
+			failed = condition.LastTransitionTime.Time
+		}
+	}
+
+	return clusteroperator.RecordBackrestVerification(c.JobClientset, c.JobClient,
+		clusterName, job.Namespace, failed,
+		fmt.Errorf("pgbackrest verify failed, see the logs of job %s", job.Name))
+}
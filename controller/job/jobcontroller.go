@@ -16,9 +16,15 @@ limitations under the License.
 */
 
 import (
+	"time"
+
 	"github.com/crunchydata/postgres-operator/config"
+	"github.com/crunchydata/postgres-operator/controller"
+	"github.com/crunchydata/postgres-operator/kubeapi"
+	"github.com/crunchydata/postgres-operator/operator"
 	log "github.com/sirupsen/logrus"
 	apiv1 "k8s.io/api/batch/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	batchinformers "k8s.io/client-go/informers/batch/v1"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
@@ -31,6 +37,8 @@ type Controller struct {
 	JobClient    *rest.RESTClient
 	JobClientset *kubernetes.Clientset
 	Informer     batchinformers.JobInformer
+	// PauseGate holds back the controller while the namespace or the cluster it acts on is paused
+	PauseGate *controller.PauseGate
 }
 
 const (
@@ -70,6 +78,9 @@ func (c *Controller) onUpdate(oldObj, newObj interface{}) {
 		job.ObjectMeta.Namespace, job.ObjectMeta.SelfLink, job.Status.Active, job.Status.Succeeded,
 		job.Status.Conditions)
 
+	// tell the story of the backups and restores of the cluster in its events and their pgtasks
+	c.recordJobOutcome(oldObj.(*apiv1.Job), job)
+
 	// determine determine which handler to route the update event to
 	switch {
 	case labels[config.LABEL_RMDATA] == "true":
@@ -85,6 +96,12 @@ func (c *Controller) onUpdate(oldObj, newObj interface{}) {
 		err = c.handleLoadUpdate(job)
 	case labels[config.LABEL_PGO_CLONE_STEP_1] == "true":
 		err = c.handleRepoSyncUpdate(job)
+	case labels[config.LABEL_MAJOR_UPGRADE_PRECHECK] == "true":
+		err = c.handleMajorUpgradePrecheckUpdate(job)
+	case labels[config.LABEL_MAJOR_UPGRADE] == "true":
+		err = c.handleMajorUpgradeUpdate(job)
+	case labels[config.LABEL_DATA_CHECKSUMS] == "true":
+		err = c.handleDataChecksumsUpdate(job)
 	}
 
 	if err != nil {
@@ -107,14 +124,88 @@ func (c *Controller) onDelete(obj interface{}) {
 	log.Debugf("[Job Controller] onDelete ns=%s %s", job.ObjectMeta.Namespace, job.ObjectMeta.SelfLink)
 }
 
+// RunPeriodic carries out the periodic work of the controller, which is failing the jobs of the
+// Operator whose pods have waited too long for a transient reason, and removing the jobs, along
+// with their pods, that finished longer ago than the TTL of the tasks, if one is configured
+func (c *Controller) RunPeriodic() {
+	jobs, err := c.Informer.Lister().List(labels.Everything())
+	if err != nil {
+		log.Error(err)
+		return
+	}
+
+	now := time.Now()
+	ttl := operator.GetTaskTTL()
+
+	for _, job := range jobs {
+		if c.PauseGate.IsClusterPaused(job.Namespace, job.Labels[config.LABEL_PG_CLUSTER]) {
+			continue
+		}
+
+		if job.Labels[config.LABEL_VENDOR] == config.LABEL_CRUNCHY &&
+			!isJobSuccessful(job) && !isJobFailed(job) {
+			c.failPendingJob(job, operator.GetTaskPendingTimeout(), now)
+			continue
+		}
+
+		if !operator.IsJobExpired(job, ttl, now) {
+			continue
+		}
+
+		log.Debugf("removing job %s, which finished more than %s ago", job.Name, ttl)
+
+		if err := kubeapi.DeleteJob(c.JobClientset, job.Name, job.Namespace); err != nil {
+			log.Error(err)
+		}
+	}
+}
+
+// failPendingJob fails a job of the Operator whose pod has waited for longer than the timeout for
+// a transient reason, e.g. as its image cannot be pulled, which would otherwise keep the job from
+// ever finishing. The reason is recorded on the job, so that its pgtask is retried, or failed, as
+// the job fails. The job is failed by giving it a deadline that has already passed
+func (c *Controller) failPendingJob(job *apiv1.Job, timeout time.Duration, now time.Time) {
+	if timeout <= 0 || job.Annotations[config.ANNOTATION_TRANSIENT_FAILURE] != "" {
+		return
+	}
+
+	pods, err := kubeapi.GetPods(c.JobClientset, config.LABEL_JOB_NAME+"="+job.Name, job.Namespace)
+	if err != nil {
+		return
+	}
+
+	reason := operator.GetPendingPodFailure(pods.Items, timeout, now)
+	if reason == "" {
+		return
+	}
+
+	log.Infof("failing job %s, whose pod has waited as %s for longer than %s", job.Name, reason,
+		timeout)
+
+	job = job.DeepCopy()
+	if job.Annotations == nil {
+		job.Annotations = map[string]string{}
+	}
+	job.Annotations[config.ANNOTATION_TRANSIENT_FAILURE] = reason
+
+	deadline := int64(1)
+	job.Spec.ActiveDeadlineSeconds = &deadline
+
+	if err := kubeapi.UpdateJob(c.JobClientset, job, job.Namespace); err != nil {
+		log.Error(err)
+	}
+}
+
 // AddJobEventHandler adds the job event handler to the job informer
 func (c *Controller) AddJobEventHandler() {
 
-	c.Informer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
-		AddFunc:    c.onAdd,
-		UpdateFunc: c.onUpdate,
-		DeleteFunc: c.onDelete,
-	})
+	c.Informer.Informer().AddEventHandler(c.PauseGate.EventHandler("job", controller.ClusterLabel,
+		operator.SafeEventHandler("job", nil,
+			cache.ResourceEventHandlerFuncs{
+				AddFunc:    c.onAdd,
+				UpdateFunc: c.onUpdate,
+				DeleteFunc: c.onDelete,
+			})))
 
 	log.Debugf("Job Controller: added event handler to informer")
 }
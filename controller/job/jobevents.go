@@ -16,12 +16,123 @@ limitations under the License.
 */
 
 import (
+	"fmt"
 	"time"
 
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/config"
 	"github.com/crunchydata/postgres-operator/events"
+	"github.com/crunchydata/postgres-operator/kubeapi"
+	"github.com/crunchydata/postgres-operator/operator"
 	log "github.com/sirupsen/logrus"
+	apiv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
 )
 
+// recordJobOutcome records the outcome of a backup or restore job once the job completes or
+// fails: an event is recorded for its cluster, so that the backups and restores of the cluster
+// show up when it is described, and the pgtask of the job is moved into the phase the job
+// finished in, unless the job failed for a transient reason and its pgtask is retried. The
+// outcome is only recorded as the job finishes, as a finished job can still be updated, e.g. as
+// it is removed
+func (c *Controller) recordJobOutcome(oldJob, job *apiv1.Job) {
+	labels := job.GetObjectMeta().GetLabels()
+
+	var completed, failed, description string
+	switch {
+	case labels[config.LABEL_BACKREST_COMMAND] == "backup":
+		completed, failed = operator.EventReasonBackupCompleted, operator.EventReasonBackupFailed
+		description = "pgBackRest backup"
+	case labels[config.LABEL_BACKREST_RESTORE] == "true" && labels[config.LABEL_PGO_CLONE_STEP_2] != "true":
+		completed, failed = operator.EventReasonRestoreCompleted, operator.EventReasonRestoreFailed
+		description = "pgBackRest restore"
+	case labels[config.LABEL_BACKUP_TYPE_PGDUMP] == "true":
+		completed, failed = operator.EventReasonBackupCompleted, operator.EventReasonBackupFailed
+		description = "pg_dump backup"
+	case labels[config.LABEL_RESTORE_TYPE_PGRESTORE] == "true":
+		completed, failed = operator.EventReasonRestoreCompleted, operator.EventReasonRestoreFailed
+		description = "pg_restore"
+	default:
+		return
+	}
+
+	var eventType, reason, message string
+	var phase crv1.PgtaskPhase
+	switch {
+	case isJobSuccessful(job) && !isJobSuccessful(oldJob):
+		eventType, reason, phase = v1.EventTypeNormal, completed, crv1.PgtaskPhaseSucceeded
+		message = fmt.Sprintf("%s job %s completed", description, job.Name)
+	case isJobFailed(job) && !isJobFailed(oldJob):
+		eventType, reason, phase = v1.EventTypeWarning, failed, crv1.PgtaskPhaseFailed
+		message = fmt.Sprintf("%s job %s failed, see its logs", description, job.Name)
+	default:
+		return
+	}
+
+	// the pgtask of a job is named after the job, unless the job has a label of its own for it
+	taskName := job.Name
+	if labels[config.LABEL_PGTASK] != "" {
+		taskName = labels[config.LABEL_PGTASK]
+	}
+
+	// a job that failed for a transient reason is run again by its pgtask, if the pgtask has
+	// retries left, in which case the pgtask is not marked as failed
+	retried := false
+	if phase == crv1.PgtaskPhaseFailed {
+		if retryReason := c.getTransientJobFailure(job); retryReason != "" &&
+			operator.RetryTask(c.JobClient, taskName, job.Namespace, retryReason) {
+			retried = true
+			reason = operator.EventReasonTaskRetrying
+			message = fmt.Sprintf("%s job %s failed as %s and is retried", description, job.Name,
+				retryReason)
+
+			// the retry creates a job of the same name, so the failed one is removed
+			if err := kubeapi.DeleteJob(c.JobClientset, job.Name, job.Namespace); err != nil {
+				log.Error(err)
+			}
+		}
+	}
+
+	if !retried {
+		operator.UpdateTaskPhase(c.JobClient, taskName, job.Namespace, phase, message)
+	}
+
+	cluster := crv1.Pgcluster{}
+	if found, err := kubeapi.Getpgcluster(c.JobClient, &cluster, labels[config.LABEL_PG_CLUSTER],
+		job.Namespace); !found {
+		log.Debugf("not recording %s for job %s: %v", reason, job.Name, err)
+		return
+	}
+
+	if eventType == v1.EventTypeWarning {
+		operator.RecordWarningEvent(c.JobClientset, &cluster, reason, message)
+	} else {
+		operator.RecordNormalEvent(c.JobClientset, &cluster, reason, message)
+	}
+}
+
+// getTransientJobFailure returns the transient reason a job failed for, e.g. as its Pod was
+// evicted or was failed by the Operator as it waited for its image, or an empty reason if it
+// failed at its own work. A backup that a cluster waits on as it is removed or upgraded is not
+// retried, as its failure is handled by the removal or upgrade
+func (c *Controller) getTransientJobFailure(job *apiv1.Job) string {
+	switch job.GetObjectMeta().GetLabels()[config.LABEL_PGHA_BACKUP_TYPE] {
+	case crv1.BackupTypeFinal, crv1.BackupTypeMajorUpgrade:
+		return ""
+	}
+
+	if reason := job.Annotations[config.ANNOTATION_TRANSIENT_FAILURE]; reason != "" {
+		return reason
+	}
+
+	pods, err := kubeapi.GetPods(c.JobClientset, config.LABEL_JOB_NAME+"="+job.Name, job.Namespace)
+	if err != nil {
+		return ""
+	}
+
+	return operator.GetTransientPodFailure(pods.Items)
+}
+
 func publishBackupComplete(clusterName, clusterIdentifier, username, backuptype, namespace, path string) {
 	topics := make([]string, 2)
 	topics[0] = events.EventTopicCluster
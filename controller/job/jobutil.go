@@ -39,3 +39,15 @@ func isJobInForegroundDeletion(job *apiv1.Job) bool {
 	}
 	return false
 }
+
+// isJobFailed returns true if the job provided has failed, which for the jobs
+// created by the Operator means that its single Pod failed, given they are all
+// created with a backoff limit of 0
+func isJobFailed(job *apiv1.Job) bool {
+	for _, condition := range job.Status.Conditions {
+		if condition.Type == apiv1.JobFailed && condition.Status == "True" {
+			return true
+		}
+	}
+	return false
+}
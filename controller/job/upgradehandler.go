@@ -0,0 +1,72 @@
+package job
+
+/*
+Copyright 2020 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+import (
+	clusteroperator "github.com/crunchydata/postgres-operator/operator/cluster"
+	log "github.com/sirupsen/logrus"
+	apiv1 "k8s.io/api/batch/v1"
+)
+
+// handleMajorUpgradePrecheckUpdate is responsible for handling updates to the
+// jobs that run "pg_upgrade --check" prior to a major upgrade. Unlike most of
+// the other job handlers, a failed job is not ignored, as it is the failure of
+// the precheck that needs to be reflected on the upgrade pgtask
+func (c *Controller) handleMajorUpgradePrecheckUpdate(job *apiv1.Job) error {
+
+	// return if job is being deleted
+	if isJobInForegroundDeletion(job) {
+		log.Debugf("jobController onUpdate job %s is being deleted and will be ignored",
+			job.Name)
+		return nil
+	}
+
+	succeeded := isJobSuccessful(job)
+
+	// return if the job is still running
+	if !succeeded && !isJobFailed(job) {
+		return nil
+	}
+
+	log.Debugf("jobController onUpdate major upgrade precheck job %s succeeded=%t", job.Name, succeeded)
+
+	return clusteroperator.UpdateMajorUpgradePrecheck(c.JobClientset, c.JobClient, c.JobConfig, job, succeeded)
+}
+
+// handleMajorUpgradeUpdate is responsible for handling updates to the jobs
+// that run pg_upgrade against the data directory of the primary during a major
+// upgrade. As with the precheck, a failed job is not ignored, as the cluster is
+// rolled back when pg_upgrade fails
+func (c *Controller) handleMajorUpgradeUpdate(job *apiv1.Job) error {
+
+	// return if job is being deleted
+	if isJobInForegroundDeletion(job) {
+		log.Debugf("jobController onUpdate job %s is being deleted and will be ignored",
+			job.Name)
+		return nil
+	}
+
+	succeeded := isJobSuccessful(job)
+
+	// return if the job is still running
+	if !succeeded && !isJobFailed(job) {
+		return nil
+	}
+
+	log.Debugf("jobController onUpdate major upgrade job %s succeeded=%t", job.Name, succeeded)
+
+	return clusteroperator.UpdateMajorUpgrade(c.JobClientset, c.JobClient, c.JobConfig, job, succeeded)
+}
@@ -23,19 +23,27 @@ import (
 	"github.com/crunchydata/postgres-operator/controller"
 	"github.com/crunchydata/postgres-operator/controller/job"
 	"github.com/crunchydata/postgres-operator/controller/pgcluster"
+	"github.com/crunchydata/postgres-operator/controller/pgdatabase"
 	"github.com/crunchydata/postgres-operator/controller/pgpolicy"
 	"github.com/crunchydata/postgres-operator/controller/pgreplica"
+	"github.com/crunchydata/postgres-operator/controller/pgschedule"
 	"github.com/crunchydata/postgres-operator/controller/pgtask"
+	"github.com/crunchydata/postgres-operator/controller/pguser"
 	"github.com/crunchydata/postgres-operator/controller/pod"
 	"github.com/crunchydata/postgres-operator/kubeapi"
+	"github.com/crunchydata/postgres-operator/operator"
 	informers "github.com/crunchydata/postgres-operator/pkg/generated/informers/externalversions"
 	log "github.com/sirupsen/logrus"
 
 	"k8s.io/apimachinery/pkg/util/wait"
 	kubeinformers "k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/util/workqueue"
 )
 
+// periodicInterval is how often the periodic work of the controllers is run
+const periodicInterval = 30 * time.Second
+
 // ControllerManager manages a map of controller groups, each of which is comprised of the various
 // controllers needed to handle events within a specific namespace.  Only one controllerGroup is
 // allowed per namespace.
@@ -44,6 +52,9 @@ type ControllerManager struct {
 	cancelFunc  context.CancelFunc
 	mgrMutex    sync.Mutex
 	controllers map[string]*controllerGroup
+	// clients are shared by all of the controller groups, so that they share one rate limiter
+	// for their requests to the Kubernetes API no matter how many namespaces are watched
+	clients *kubeapi.ControllerClients
 }
 
 // controllerGroup is a struct for managing the various controllers created to handle events
@@ -56,11 +67,31 @@ type controllerGroup struct {
 	pgoInformerFactory     informers.SharedInformerFactory
 	kubeInformerFactory    kubeinformers.SharedInformerFactory
 	controllersWithWorkers []controller.WorkerRunner
+	periodicControllers    []controller.PeriodicRunner
+	// cacheSyncs report whether the informers of the group have synced their caches, and queues
+	// are the work queues of the group, which together make up the health of the group
+	cacheSyncs []cache.InformerSynced
+	queues     []*trackedQueue
+	// startTime is when the group was started
+	startTime time.Time
+	// informerContext stops the informers and the periodic work of the group, i.e. whatever
+	// queues new work, ahead of its workers as the Operator shuts down
+	informerContext    context.Context
+	informerCancelFunc context.CancelFunc
+	// paused is whether the controllers of the group are held back by pauseGate, along with its
+	// periodic work
+	paused    bool
+	pauseGate *controller.PauseGate
 }
 
 // NewControllerManager returns a new ControllerManager comprised of controllerGroups for each
-// namespace included in the 'namespaces' parameter.
-func NewControllerManager(namespaces []string) (*ControllerManager, error) {
+// namespace included in the 'namespaces' parameter. The controllers of every group share the
+// clients provided
+func NewControllerManager(clients *kubeapi.ControllerClients, namespaces []string) (*ControllerManager, error) {
+
+	// the work queues record their metrics with the provider that is set before any of them is
+	// created
+	workqueue.SetProvider(operator.QueueMetricsProvider())
 
 	ctx, cancelFunc := context.WithCancel(context.Background())
 
@@ -68,6 +99,7 @@ func NewControllerManager(namespaces []string) (*ControllerManager, error) {
 		context:     ctx,
 		cancelFunc:  cancelFunc,
 		controllers: make(map[string]*controllerGroup),
+		clients:     clients,
 	}
 
 	// create controller groups for each namespace provided
@@ -89,13 +121,16 @@ func NewControllerManager(namespaces []string) (*ControllerManager, error) {
 // - pods
 // - jobs
 // - pgclusters
+// - pgdatabases
 // - pgpolicys
+// - pgschedules
+// - pgusers
 // - pgtasks
 // Two SharedInformerFactory's are utilized (one for Kube resources and one for PosgreSQL Operator
 // resources) to create and track the informers for each type of resource, while any controllers
 // utilizing worker queues are also tracked (this allows all informers and worker queues to be
-// easily started as needed). Each controller group also recieves its own clients, which can then
-// be utilized by the various controllers within that controller group.
+// easily started as needed). The clients of the controller manager are shared by the controllers
+// of all of the controller groups, while the informers of each group only watch its namespace.
 func (c *ControllerManager) AddControllerGroup(namespace string) error {
 
 	c.mgrMutex.Lock()
@@ -104,23 +139,26 @@ func (c *ControllerManager) AddControllerGroup(namespace string) error {
 		return nil
 	}
 
-	// create a client for kube resources
-	clients, err := kubeapi.NewControllerClients()
-	if err != nil {
-		log.Error(err)
-		return err
-	}
-
-	config := clients.Config
-	pgoClientset := clients.PGOClientset
-	pgoRESTClient := clients.PGORestclient
-	kubeClientset := clients.Kubeclientset
+	config := c.clients.Config
+	pgoClientset := c.clients.PGOClientset
+	pgoRESTClient := c.clients.PGORestclient
+	kubeClientset := c.clients.Kubeclientset
 
 	ctx, cancelFunc := context.WithCancel(c.context)
+	informerCtx, informerCancelFunc := context.WithCancel(ctx)
 
 	pgoInformerFactory := informers.NewSharedInformerFactoryWithOptions(pgoClientset, 0,
 		informers.WithNamespace(namespace))
 
+	// the controllers of the group, along with its queues, hold back while the namespace or the
+	// cluster they act on is paused
+	pauseGate := controller.NewPauseGate(pgoInformerFactory.Crunchydata().V1().Pgclusters().Lister(),
+		ctx.Done())
+
+	pgTaskQueue := newTrackedQueue("pgtask", namespace, pauseGate)
+	pgClusterQueue := newTrackedQueue("pgcluster", namespace, pauseGate)
+	pgReplicaQueue := newTrackedQueue("pgreplica", namespace, pauseGate)
+
 	kubeInformerFactory := kubeinformers.NewSharedInformerFactoryWithOptions(kubeClientset, 0,
 		kubeinformers.WithNamespace(namespace))
 
@@ -128,29 +166,66 @@ func (c *ControllerManager) AddControllerGroup(namespace string) error {
 		PgtaskConfig:    config,
 		PgtaskClient:    pgoRESTClient,
 		PgtaskClientset: kubeClientset,
-		Queue:           workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter()),
+		Queue:           pgTaskQueue,
 		Informer:        pgoInformerFactory.Crunchydata().V1().Pgtasks(),
+		PauseGate:       pauseGate,
+		WorkerCount:     operator.Pgo.Pgo.ControllerWorkerCount,
 	}
 
+	// the credentials of the cluster users and of the pgusers are kept in the same store
+	credentialStore := operator.NewCachedCredentialStore(
+		operator.NewKubernetesCredentialStore(kubeClientset), operator.DefaultCredentialCacheTTL)
+
 	pgClustercontroller := &pgcluster.Controller{
 		PgclusterClient:    pgoRESTClient,
 		PgclusterClientset: kubeClientset,
 		PgclusterConfig:    config,
-		Queue:              workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter()),
+		Queue:              pgClusterQueue,
 		Informer:           pgoInformerFactory.Crunchydata().V1().Pgclusters(),
+		PauseGate:          pauseGate,
+		CredentialStore:    credentialStore,
+		WorkerCount:        operator.Pgo.Pgo.ControllerWorkerCount,
+	}
+
+	pgDatabasecontroller := &pgdatabase.Controller{
+		PgdatabaseClient:    pgoRESTClient,
+		PgdatabaseClientset: kubeClientset,
+		PgdatabaseConfig:    config,
+		Informer:            pgoInformerFactory.Crunchydata().V1().Pgdatabases(),
+		PauseGate:           pauseGate,
 	}
 
 	pgReplicacontroller := &pgreplica.Controller{
 		PgreplicaClient:    pgoRESTClient,
 		PgreplicaClientset: kubeClientset,
-		Queue:              workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter()),
+		PgreplicaConfig:    config,
+		Queue:              pgReplicaQueue,
 		Informer:           pgoInformerFactory.Crunchydata().V1().Pgreplicas(),
+		PauseGate:          pauseGate,
+		WorkerCount:        operator.Pgo.Pgo.ControllerWorkerCount,
 	}
 
 	pgPolicycontroller := &pgpolicy.Controller{
 		PgpolicyClient:    pgoRESTClient,
 		PgpolicyClientset: kubeClientset,
 		Informer:          pgoInformerFactory.Crunchydata().V1().Pgpolicies(),
+		PauseGate:         pauseGate,
+	}
+
+	pgSchedulecontroller := &pgschedule.Controller{
+		PgscheduleClient:    pgoRESTClient,
+		PgscheduleClientset: kubeClientset,
+		Informer:            pgoInformerFactory.Crunchydata().V1().Pgschedules(),
+		PauseGate:           pauseGate,
+	}
+
+	pgUsercontroller := &pguser.Controller{
+		PguserClient:    pgoRESTClient,
+		PguserClientset: kubeClientset,
+		PguserConfig:    config,
+		CredentialStore: credentialStore,
+		Informer:        pgoInformerFactory.Crunchydata().V1().Pgusers(),
+		PauseGate:       pauseGate,
 	}
 
 	podcontroller := &pod.Controller{
@@ -158,6 +233,7 @@ func (c *ControllerManager) AddControllerGroup(namespace string) error {
 		PodClientset: kubeClientset,
 		PodClient:    pgoRESTClient,
 		Informer:     kubeInformerFactory.Core().V1().Pods(),
+		PauseGate:    pauseGate,
 	}
 
 	jobcontroller := &job.Controller{
@@ -165,21 +241,28 @@ func (c *ControllerManager) AddControllerGroup(namespace string) error {
 		JobClientset: kubeClientset,
 		JobClient:    pgoRESTClient,
 		Informer:     kubeInformerFactory.Batch().V1().Jobs(),
+		PauseGate:    pauseGate,
 	}
 
 	// add the proper event handler to the informer in each controller
 	pgTaskcontroller.AddPGTaskEventHandler()
 	pgClustercontroller.AddPGClusterEventHandler()
+	pgDatabasecontroller.AddPGDatabaseEventHandler()
 	pgReplicacontroller.AddPGReplicaEventHandler()
 	pgPolicycontroller.AddPGPolicyEventHandler()
+	pgSchedulecontroller.AddPGScheduleEventHandler()
+	pgUsercontroller.AddPGUserEventHandler()
 	podcontroller.AddPodEventHandler()
 	jobcontroller.AddJobEventHandler()
 
 	group := &controllerGroup{
 		context:             ctx,
 		cancelFunc:          cancelFunc,
+		informerContext:     informerCtx,
+		informerCancelFunc:  informerCancelFunc,
 		pgoInformerFactory:  pgoInformerFactory,
 		kubeInformerFactory: kubeInformerFactory,
+		pauseGate:           pauseGate,
 	}
 
 	// store the controllers containing worker queues so that the queues can also be started
@@ -187,6 +270,39 @@ func (c *ControllerManager) AddControllerGroup(namespace string) error {
 	group.controllersWithWorkers = append(group.controllersWithWorkers,
 		pgTaskcontroller, pgClustercontroller, pgReplicacontroller)
 
+	// store the controllers with periodic work so that it can be started along with the workers
+	group.periodicControllers = append(group.periodicControllers, pgClustercontroller,
+		pgDatabasecontroller, pgSchedulecontroller, pgUsercontroller, pgTaskcontroller,
+		jobcontroller, pgReplicacontroller, podcontroller)
+
+	// keep track of the informers and queues of the controllers for the health of the group
+	group.cacheSyncs = append(group.cacheSyncs,
+		pgTaskcontroller.Informer.Informer().HasSynced,
+		pgClustercontroller.Informer.Informer().HasSynced,
+		pgDatabasecontroller.Informer.Informer().HasSynced,
+		pgReplicacontroller.Informer.Informer().HasSynced,
+		pgPolicycontroller.Informer.Informer().HasSynced,
+		pgSchedulecontroller.Informer.Informer().HasSynced,
+		pgUsercontroller.Informer.Informer().HasSynced,
+		podcontroller.Informer.Informer().HasSynced,
+		jobcontroller.Informer.Informer().HasSynced)
+	group.queues = append(group.queues, pgTaskQueue, pgClusterQueue, pgReplicaQueue)
+
+	// serve whether the informers have synced their caches along with the metrics of the queues
+	for controller, informer := range map[string]cache.SharedIndexInformer{
+		"pgtask":     pgTaskcontroller.Informer.Informer(),
+		"pgcluster":  pgClustercontroller.Informer.Informer(),
+		"pgdatabase": pgDatabasecontroller.Informer.Informer(),
+		"pgreplica":  pgReplicacontroller.Informer.Informer(),
+		"pgpolicy":   pgPolicycontroller.Informer.Informer(),
+		"pgschedule": pgSchedulecontroller.Informer.Informer(),
+		"pguser":     pgUsercontroller.Informer.Informer(),
+		"pod":        podcontroller.Informer.Informer(),
+		"job":        jobcontroller.Informer.Informer(),
+	} {
+		operator.RegisterInformerMetric(controller, namespace, informer.HasSynced)
+	}
+
 	c.controllers[namespace] = group
 
 	log.Debugf("Controller Manager: added controller group for namespace %s", namespace)
@@ -197,7 +313,10 @@ func (c *ControllerManager) AddControllerGroup(namespace string) error {
 // AddAndRunControllerGroup is a convenience function that adds a controller group for the
 // namespace specified, and then immediately runs the controllers in that group.
 func (c *ControllerManager) AddAndRunControllerGroup(namespace string) {
-	c.AddControllerGroup(namespace)
+	if err := c.AddControllerGroup(namespace); err != nil {
+		log.Error(err)
+		return
+	}
 	c.RunGroup(namespace)
 }
 
@@ -212,7 +331,13 @@ func (c *ControllerManager) RunAll() {
 // RunGroup runs the controllers within the controller group for the namespace specified.
 func (c *ControllerManager) RunGroup(namespace string) {
 
-	instance := c.controllers[namespace]
+	c.mgrMutex.Lock()
+	instance, ok := c.controllers[namespace]
+	c.mgrMutex.Unlock()
+
+	if !ok {
+		return
+	}
 
 	instance.instanceMutex.Lock()
 	defer instance.instanceMutex.Unlock()
@@ -220,12 +345,31 @@ func (c *ControllerManager) RunGroup(namespace string) {
 	if instance.started {
 		return
 	}
+	instance.started = true
+	instance.startTime = time.Now()
+
+	instance.kubeInformerFactory.Start(instance.informerContext.Done())
+	instance.pgoInformerFactory.Start(instance.informerContext.Done())
 
-	instance.kubeInformerFactory.Start(instance.context.Done())
-	instance.pgoInformerFactory.Start(instance.context.Done())
+	// the queues hand out each key to one worker at a time, so a resource is never processed by
+	// more than one worker at once
+	for _, worker := range instance.controllersWithWorkers {
+		for i := 0; i < worker.GetWorkerCount(); i++ {
+			go wait.Until(worker.RunWorker, time.Second, instance.context.Done())
+		}
+	}
 
-	for _, worker := range c.controllers[namespace].controllersWithWorkers {
-		go wait.Until(worker.RunWorker, time.Second, instance.context.Done())
+	for _, runner := range instance.periodicControllers {
+		runner := runner
+
+		// the periodic work is carried out again on the next interval anyway, so rather than
+		// being deferred, it is skipped while it is held back by safe mode
+		go wait.Until(func() {
+			if operator.InSafeMode("periodic") || instance.isPaused() {
+				return
+			}
+			runner.RunPeriodic()
+		}, periodicInterval, instance.informerContext.Done())
 	}
 
 	log.Debugf("Controller Manager: the controller group for ns %s is now running", namespace)
@@ -239,7 +383,23 @@ func (c *ControllerManager) StopAll() {
 
 // StopGroup stops the controllers within the controller group for the namespace specified.
 func (c *ControllerManager) StopGroup(namespace string) {
-	c.controllers[namespace].cancelFunc()
+	c.mgrMutex.Lock()
+	instance, ok := c.controllers[namespace]
+	c.mgrMutex.Unlock()
+
+	// namespaces are removed as they are deleted or stop matching the namespace selector, which
+	// may well be before a controller group was ever added for them
+	if !ok {
+		return
+	}
+
+	instance.cancelFunc()
+
+	// shutting down the queues lets the workers that wait on them return
+	for _, queue := range instance.queues {
+		queue.ShutDown()
+	}
+
 	log.Debugf("Controller Manager: the controller group for ns %s has been stopped", namespace)
 }
 
@@ -255,6 +415,12 @@ func (c *ControllerManager) RemoveAll() {
 // controllers within that group
 func (c *ControllerManager) RemoveGroup(namespace string) {
 	c.StopGroup(namespace)
+
+	c.mgrMutex.Lock()
 	delete(c.controllers, namespace)
+	c.mgrMutex.Unlock()
+
+	operator.DeleteNamespaceMetrics(namespace)
+
 	log.Debugf("Controller Manager: the controller group for ns %s has been removed", namespace)
 }
@@ -0,0 +1,169 @@
+package manager
+
+/*
+Copyright 2020 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/crunchydata/postgres-operator/controller"
+	"k8s.io/client-go/util/workqueue"
+)
+
+const (
+	// informerSyncTimeout is how long the informers of a controller group may take to sync their
+	// caches once the group is started before the Operator is considered stuck
+	informerSyncTimeout = 5 * time.Minute
+	// queueStallTimeout is how long a queue may hold items without any of them being processed
+	// before the Operator is considered stuck
+	queueStallTimeout = 10 * time.Minute
+)
+
+// trackedQueue is a work queue that keeps track of when it last made progress, i.e. when it was
+// last found empty or finished processing an item, so that a queue that is stuck can be told
+// apart from one that is busy
+type trackedQueue struct {
+	workqueue.RateLimitingInterface
+	mutex        sync.Mutex
+	name         string
+	lastProgress time.Time
+	// processing are the items that the workers are processing, and waiting are those that wait
+	// to be added back to the queue to retry, neither of which the queue counts in its length
+	processing map[interface{}]struct{}
+	waiting    map[interface{}]struct{}
+	// abandoned is set once the Operator shuts down without the queue being drained, after which
+	// the workers get no more items, and undrained are the items the queue was left with
+	abandoned bool
+	undrained []interface{}
+	// pauseGate holds back the workers of the queue while its namespace is paused
+	pauseGate *controller.PauseGate
+}
+
+// newTrackedQueue returns a rate limited work queue of a controller for a namespace that keeps
+// track of its progress. The queue is named after both, which its metrics are labeled with
+func newTrackedQueue(controller, namespace string, pauseGate *controller.PauseGate) *trackedQueue {
+	return &trackedQueue{
+		RateLimitingInterface: workqueue.NewNamedRateLimitingQueue(
+			workqueue.DefaultControllerRateLimiter(), controller+"/"+namespace),
+		name:         controller,
+		lastProgress: time.Now(),
+		processing:   map[interface{}]struct{}{},
+		waiting:      map[interface{}]struct{}{},
+		pauseGate:    pauseGate,
+	}
+}
+
+// Done marks an item as processed, which is progress of the queue
+func (q *trackedQueue) Done(item interface{}) {
+	q.RateLimitingInterface.Done(item)
+
+	q.mutex.Lock()
+	q.lastProgress = time.Now()
+	delete(q.processing, item)
+	q.mutex.Unlock()
+}
+
+// stalled returns whether the queue holds items but has not made progress for the given timeout
+func (q *trackedQueue) stalled(now time.Time, timeout time.Duration) bool {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	// a queue that is paused holds on to its items on purpose
+	if q.Len() == 0 || q.pauseGate.IsPaused() {
+		q.lastProgress = now
+		return false
+	}
+
+	return now.Sub(q.lastProgress) > timeout
+}
+
+// Healthy returns an error if any of the running controller groups is stuck, i.e. its informers
+// have not synced their caches long after it was started, or one of its queues is not drained
+func (c *ControllerManager) Healthy() error {
+	now := time.Now()
+
+	return c.checkGroups(func(group *controllerGroup) string {
+		if !group.hasSynced() && now.Sub(group.startTime) > informerSyncTimeout {
+			return fmt.Sprintf("informers not synced after %s", informerSyncTimeout)
+		}
+
+		for _, queue := range group.queues {
+			if queue.stalled(now, queueStallTimeout) {
+				return fmt.Sprintf("%s queue not drained for %s", queue.name, queueStallTimeout)
+			}
+		}
+
+		return ""
+	})
+}
+
+// Ready returns an error if the informers of any of the running controller groups have not
+// synced their caches yet
+func (c *ControllerManager) Ready() error {
+	return c.checkGroups(func(group *controllerGroup) string {
+		if !group.hasSynced() {
+			return "informers not synced"
+		}
+		return ""
+	})
+}
+
+// checkGroups runs a check against each of the running controller groups, returning an error
+// that lists the namespaces of the groups that fail it along with why they do
+func (c *ControllerManager) checkGroups(check func(group *controllerGroup) string) error {
+	c.mgrMutex.Lock()
+	groups := make(map[string]*controllerGroup, len(c.controllers))
+	for namespace, group := range c.controllers {
+		groups[namespace] = group
+	}
+	c.mgrMutex.Unlock()
+
+	failed := []string{}
+
+	for namespace, group := range groups {
+		group.instanceMutex.Lock()
+		reason := ""
+		if group.started {
+			reason = check(group)
+		}
+		group.instanceMutex.Unlock()
+
+		if reason != "" {
+			failed = append(failed, fmt.Sprintf("%s: %s", namespace, reason))
+		}
+	}
+
+	if len(failed) == 0 {
+		return nil
+	}
+
+	sort.Strings(failed)
+
+	return fmt.Errorf("controller groups failing: %s", strings.Join(failed, "; "))
+}
+
+// hasSynced returns whether all of the informers of the controller group have synced their caches
+func (g *controllerGroup) hasSynced() bool {
+	for _, synced := range g.cacheSyncs {
+		if !synced() {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,78 @@
+package manager
+
+/*
+Copyright 2020 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+import (
+	log "github.com/sirupsen/logrus"
+)
+
+// PauseGroup pauses the controller group for the namespace specified, e.g. during maintenance.
+// Its informers keep running, but the event handlers and the workers of its controllers hold on
+// to the events and its periodic work is skipped until the group is resumed, so that no event is
+// lost in the meantime
+func (c *ControllerManager) PauseGroup(namespace string) {
+	c.mgrMutex.Lock()
+	instance, ok := c.controllers[namespace]
+	c.mgrMutex.Unlock()
+
+	if !ok {
+		return
+	}
+
+	instance.instanceMutex.Lock()
+	defer instance.instanceMutex.Unlock()
+
+	if instance.paused {
+		return
+	}
+	instance.paused = true
+
+	instance.pauseGate.Pause()
+
+	log.Infof("Controller Manager: the controller group for ns %s is now paused", namespace)
+}
+
+// ResumeGroup resumes the controller group for the namespace specified, whose workers then
+// process the events that were queued while it was paused
+func (c *ControllerManager) ResumeGroup(namespace string) {
+	c.mgrMutex.Lock()
+	instance, ok := c.controllers[namespace]
+	c.mgrMutex.Unlock()
+
+	if !ok {
+		return
+	}
+
+	instance.instanceMutex.Lock()
+	defer instance.instanceMutex.Unlock()
+
+	if !instance.paused {
+		return
+	}
+	instance.paused = false
+
+	instance.pauseGate.Resume()
+
+	log.Infof("Controller Manager: the controller group for ns %s is now resumed", namespace)
+}
+
+// isPaused returns whether the controller group is paused
+func (g *controllerGroup) isPaused() bool {
+	g.instanceMutex.Lock()
+	defer g.instanceMutex.Unlock()
+
+	return g.paused
+}
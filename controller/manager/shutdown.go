@@ -0,0 +1,210 @@
+package manager
+
+/*
+Copyright 2020 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+import (
+	"fmt"
+	"time"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/operator"
+	log "github.com/sirupsen/logrus"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// drainPollInterval is how often the queues are checked on while they are drained
+const drainPollInterval = 250 * time.Millisecond
+
+// queueKinds are the kinds of the custom resources that the queues of the controllers hold the
+// keys of, by the name of the controller
+var queueKinds = map[string]string{
+	"pgtask":    "Pgtask",
+	"pgcluster": "Pgcluster",
+	"pgreplica": "Pgreplica",
+}
+
+// ShutdownWithTimeout shuts down all of the controller groups managed by the controller manager.
+// It stops the informers and the periodic work of each group first, so that no new work is
+// queued, and then lets the workers drain the queues and finish the work they are processing
+// until the timeout expires. Any work that is left once the controllers are stopped is recorded
+// as a Warning event of the custom resource it is for, so that it is not dropped unnoticed
+func (c *ControllerManager) ShutdownWithTimeout(timeout time.Duration) {
+	deadline := time.Now().Add(timeout)
+
+	c.mgrMutex.Lock()
+	groups := make(map[string]*controllerGroup, len(c.controllers))
+	for namespace, group := range c.controllers {
+		groups[namespace] = group
+	}
+	c.mgrMutex.Unlock()
+
+	for _, group := range groups {
+		group.informerCancelFunc()
+	}
+
+	log.Infof("Controller Manager: draining the queues of all controller groups for up to %s", timeout)
+
+	for !drained(groups) && time.Now().Before(deadline) {
+		time.Sleep(drainPollInterval)
+	}
+
+	c.StopAll()
+
+	for namespace, group := range groups {
+		for _, queue := range group.queues {
+			for _, item := range queue.abandon() {
+				c.recordUndrained(namespace, queue.name, item)
+			}
+		}
+	}
+
+	log.Info("Controller Manager: all controller groups are now shut down")
+}
+
+// drained returns whether all of the queues of the controller groups are drained
+func drained(groups map[string]*controllerGroup) bool {
+	for _, group := range groups {
+		for _, queue := range group.queues {
+			if !queue.drained() {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// recordUndrained records a Warning event for the custom resource that a queue was left with an
+// item for as the Operator shut down
+func (c *ControllerManager) recordUndrained(namespace, controller string, item interface{}) {
+	key, ok := item.(string)
+	if !ok {
+		return
+	}
+
+	_, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+
+	log.Warnf("Controller Manager: the Operator shut down before the %s controller processed %s",
+		controller, key)
+
+	operator.RecordObjectWarningEvent(c.clients.Kubeclientset, v1.ObjectReference{
+		APIVersion: crv1.SchemeGroupVersion.String(),
+		Kind:       queueKinds[controller],
+		Name:       name,
+		Namespace:  namespace,
+	}, "OperatorShutdown", fmt.Sprintf("the Operator shut down before the %s controller finished "+
+		"processing %s", controller, key))
+}
+
+// Get returns the next item of the queue for a worker to process once the queue is not paused,
+// unless the queue is abandoned, in which case the worker is told that the queue is shut down
+// and the item is kept as undrained
+func (q *trackedQueue) Get() (interface{}, bool) {
+	item, shutdown := q.RateLimitingInterface.Get()
+	if shutdown {
+		return item, shutdown
+	}
+
+	q.mutex.Lock()
+	delete(q.waiting, item)
+	q.processing[item] = struct{}{}
+	q.mutex.Unlock()
+
+	// a paused queue holds on to the item until it is resumed, or is kept as undrained if the
+	// controllers are stopped in the meantime
+	resumed := q.pauseGate.Wait()
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if q.abandoned || !resumed {
+		delete(q.processing, item)
+		q.undrained = append(q.undrained, item)
+		q.RateLimitingInterface.Done(item)
+		return nil, true
+	}
+
+	return item, false
+}
+
+// AddRateLimited adds an item back to the queue once the rate limiter allows it to
+func (q *trackedQueue) AddRateLimited(item interface{}) {
+	q.mutex.Lock()
+	q.waiting[item] = struct{}{}
+	q.mutex.Unlock()
+
+	q.RateLimitingInterface.AddRateLimited(item)
+}
+
+// AddAfter adds an item to the queue once the duration passes
+func (q *trackedQueue) AddAfter(item interface{}, duration time.Duration) {
+	q.mutex.Lock()
+	q.waiting[item] = struct{}{}
+	q.mutex.Unlock()
+
+	q.RateLimitingInterface.AddAfter(item, duration)
+}
+
+// drained returns whether the queue holds no items and none are processed or waiting to retry
+func (q *trackedQueue) drained() bool {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	return q.Len() == 0 && len(q.processing) == 0 && len(q.waiting) == 0
+}
+
+// abandon shuts down the queue without it being drained and returns the items it was left with,
+// i.e. those it holds as well as those that are processed or waiting to retry
+func (q *trackedQueue) abandon() []interface{} {
+	q.mutex.Lock()
+	q.abandoned = true
+	for item := range q.processing {
+		q.undrained = append(q.undrained, item)
+	}
+	for item := range q.waiting {
+		q.undrained = append(q.undrained, item)
+	}
+	q.mutex.Unlock()
+
+	// the workers that a paused queue holds back give up their items, as the controllers are
+	// stopped by now
+	q.ShutDown()
+
+	// once the queue is shut down, it hands out the items it holds without waiting for more
+	for q.Len() > 0 {
+		q.Get()
+	}
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	// an item that is added again while it is processed is held by the queue as well
+	seen := map[interface{}]bool{}
+	items := []interface{}{}
+	for _, item := range q.undrained {
+		if !seen[item] {
+			seen[item] = true
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
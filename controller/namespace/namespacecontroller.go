@@ -18,12 +18,15 @@ limitations under the License.
 import (
 	"github.com/crunchydata/postgres-operator/config"
 	"github.com/crunchydata/postgres-operator/controller"
+	"github.com/crunchydata/postgres-operator/ns"
 	"github.com/crunchydata/postgres-operator/operator"
 
 	log "github.com/sirupsen/logrus"
 	v1 "k8s.io/api/core/v1"
 	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	coreinformers "k8s.io/client-go/informers/core/v1"
+	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/cache"
 )
 
@@ -31,17 +34,27 @@ import (
 type Controller struct {
 	ControllerManager controller.ManagerInterface
 	Informer          coreinformers.NamespaceInformer
+	// NamespaceClientset is used to take ownership of the namespaces that are discovered by the
+	// namespace selector
+	NamespaceClientset *kubernetes.Clientset
+	// Selector, if set, selects the namespaces that are watched by their labels, rather than by
+	// whether they belong to this Operator installation
+	Selector labels.Selector
 }
 
 // NewNamespaceController creates a new namespace controller that will watch for namespace events
 // as responds accordingly.  This adding and removing controller groups as namespaces watched by the
-// PostgreSQL Operator are added and deleted.
+// PostgreSQL Operator are added and deleted.  If a selector is given, the namespaces that match it
+// are watched, and are no longer watched once they are deleted or stop matching it.
 func NewNamespaceController(controllerManager controller.ManagerInterface,
-	informer coreinformers.NamespaceInformer) (*Controller, error) {
+	informer coreinformers.NamespaceInformer, clientset *kubernetes.Clientset,
+	selector labels.Selector) (*Controller, error) {
 
 	controller := &Controller{
-		ControllerManager: controllerManager,
-		Informer:          informer,
+		ControllerManager:  controllerManager,
+		Informer:           informer,
+		NamespaceClientset: clientset,
+		Selector:           selector,
 	}
 
 	return controller, nil
@@ -65,6 +78,14 @@ func (c *Controller) onAdd(obj interface{}) {
 	newNs := obj.(*v1.Namespace)
 
 	log.Debugf("[namespace Controller] OnAdd ns=%s", newNs.ObjectMeta.SelfLink)
+
+	if c.Selector != nil {
+		if c.Selector.Matches(labels.Set(newNs.Labels)) {
+			c.discoverNamespace(newNs)
+		}
+		return
+	}
+
 	labels := newNs.GetObjectMeta().GetLabels()
 	if labels[config.LABEL_VENDOR] != config.LABEL_CRUNCHY || labels[config.LABEL_PGO_INSTALLATION_NAME] != operator.InstallationName {
 		log.Debugf("namespace Controller: onAdd skipping namespace that is not crunchydata or not belonging to this Operator installation %s", newNs.ObjectMeta.SelfLink)
@@ -72,7 +93,7 @@ func (c *Controller) onAdd(obj interface{}) {
 	}
 
 	log.Debugf("namespace Controller: onAdd crunchy namespace %s created", newNs.ObjectMeta.SelfLink)
-	c.ControllerManager.AddAndRunControllerGroup(newNs.Name)
+	c.runGroup(newNs)
 }
 
 // onUpdate is called when a namespace is updated
@@ -82,6 +103,18 @@ func (c *Controller) onUpdate(oldObj, newObj interface{}) {
 
 	log.Debugf("[namespace Controller] onUpdate ns=%s", newNs.ObjectMeta.SelfLink)
 
+	if c.Selector != nil {
+		oldNs := oldObj.(*v1.Namespace)
+
+		if c.Selector.Matches(labels.Set(newNs.Labels)) {
+			c.discoverNamespace(newNs)
+		} else if c.Selector.Matches(labels.Set(oldNs.Labels)) {
+			log.Infof("namespace Controller: namespace %s no longer matches the namespace selector", newNs.Name)
+			c.ControllerManager.RemoveGroup(newNs.Name)
+		}
+		return
+	}
+
 	labels := newNs.GetObjectMeta().GetLabels()
 	if labels[config.LABEL_VENDOR] != config.LABEL_CRUNCHY || labels[config.LABEL_PGO_INSTALLATION_NAME] != operator.InstallationName {
 		log.Debugf("namespace Controller: onUpdate skipping namespace that is not crunchydata %s", newNs.ObjectMeta.SelfLink)
@@ -89,7 +122,7 @@ func (c *Controller) onUpdate(oldObj, newObj interface{}) {
 	}
 
 	log.Debugf("namespace Controller: onUpdate crunchy namespace updated %s", newNs.ObjectMeta.SelfLink)
-	c.ControllerManager.AddAndRunControllerGroup(newNs.Name)
+	c.runGroup(newNs)
 }
 
 func (c *Controller) onDelete(obj interface{}) {
@@ -97,6 +130,15 @@ func (c *Controller) onDelete(obj interface{}) {
 	ns := obj.(*v1.Namespace)
 
 	log.Debugf("[namespace Controller] onDelete ns=%s", ns.ObjectMeta.SelfLink)
+
+	// the informer only watches the namespaces that match the selector, and a namespace that
+	// stops matching it is reported as deleted, so any namespace it reports is removed
+	if c.Selector != nil {
+		log.Debugf("namespace Controller: onDelete namespace %s is deleted or no longer selected", ns.Name)
+		c.ControllerManager.RemoveGroup(ns.Name)
+		return
+	}
+
 	labels := ns.GetObjectMeta().GetLabels()
 	if labels[config.LABEL_VENDOR] != config.LABEL_CRUNCHY {
 		log.Debugf("namespace Controller: onDelete skipping namespace that is not crunchydata %s", ns.ObjectMeta.SelfLink)
@@ -108,6 +150,57 @@ func (c *Controller) onDelete(obj interface{}) {
 	log.Debugf("namespace Controller: instance removed for ns %s", ns.Name)
 }
 
+// discoverNamespace watches a namespace that matches the namespace selector. A namespace that does
+// not belong to this Operator installation yet is taken over first, which labels it as belonging
+// to it and installs the RBAC that the Operator needs in it, as "pgo update namespace" would
+func (c *Controller) discoverNamespace(namespace *v1.Namespace) {
+	// a namespace that is being deleted is not taken over, as it is about to be removed anyway
+	if namespace.DeletionTimestamp != nil {
+		return
+	}
+
+	labels := namespace.GetObjectMeta().GetLabels()
+
+	if labels[config.LABEL_VENDOR] == config.LABEL_CRUNCHY &&
+		labels[config.LABEL_PGO_INSTALLATION_NAME] != "" &&
+		labels[config.LABEL_PGO_INSTALLATION_NAME] != operator.InstallationName {
+		log.Errorf("namespace Controller: namespace %s matches the namespace selector but is owned "+
+			"by another installation, will not watch it", namespace.Name)
+		return
+	}
+
+	if labels[config.LABEL_VENDOR] != config.LABEL_CRUNCHY ||
+		labels[config.LABEL_PGO_INSTALLATION_NAME] != operator.InstallationName {
+		log.Infof("namespace Controller: namespace %s matches the namespace selector and will be "+
+			"updated to be owned by this installation", namespace.Name)
+
+		if err := ns.UpdateNamespace(c.NamespaceClientset, operator.InstallationName,
+			operator.PgoNamespace, "operator-discovery", namespace.Name); err != nil {
+			log.Error(err)
+			return
+		}
+	}
+
+	c.runGroup(namespace)
+}
+
+// runGroup adds and runs the controller group for a watched namespace. The group is paused for
+// as long as the namespace is annotated as paused, and resumed once it no longer is
+func (c *Controller) runGroup(namespace *v1.Namespace) {
+	if err := c.ControllerManager.AddControllerGroup(namespace.Name); err != nil {
+		log.Error(err)
+		return
+	}
+
+	if namespace.Annotations[config.ANNOTATION_PAUSED] == "true" {
+		c.ControllerManager.PauseGroup(namespace.Name)
+	} else {
+		c.ControllerManager.ResumeGroup(namespace.Name)
+	}
+
+	c.ControllerManager.RunGroup(namespace.Name)
+}
+
 // isNamespaceInForegroundDeletion determines if a namespace is currently being deleted using
 // foreground cascading deletion, as indicated by the presence of value “foregroundDeletion” in
 // the namespace's metadata.finalizers.
@@ -0,0 +1,221 @@
+package controller
+
+/*
+Copyright 2020 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+import (
+	"sync"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/config"
+	listers "github.com/crunchydata/postgres-operator/pkg/generated/listers/crunchydata.com/v1"
+	log "github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/client-go/tools/cache"
+)
+
+// PauseGate is what the controllers of a namespace check before they act, so that they hold back
+// while the namespace or the cluster they act on is paused. No event is lost in the meantime: the
+// controllers wait while the namespace is paused, and the events of a paused cluster are held
+// until it is resumed
+type PauseGate struct {
+	mutex sync.Mutex
+	// clusters are the clusters of the namespace, whose annotation pauses them
+	clusters listers.PgclusterLister
+	// stop is closed as the controllers of the namespace are stopped, which lets go of what waits
+	// on the gate
+	stop <-chan struct{}
+	// resumed is set while the namespace is paused, and is closed as it is resumed
+	resumed chan struct{}
+	// held are the events of the paused clusters, by their name, in the order they happened
+	held map[string][]func()
+}
+
+// NewPauseGate returns the gate of the controllers of a namespace, which is not paused
+func NewPauseGate(clusters listers.PgclusterLister, stop <-chan struct{}) *PauseGate {
+	return &PauseGate{
+		clusters: clusters,
+		stop:     stop,
+		held:     map[string][]func(){},
+	}
+}
+
+// IsClusterPaused returns whether the reconciliation of a cluster is paused by its annotation
+func IsClusterPaused(cluster *crv1.Pgcluster) bool {
+	return cluster.Annotations[config.ANNOTATION_PAUSED] == "true"
+}
+
+// ClusterLabel returns the cluster of a resource by its label, which is how the pods, jobs,
+// pgtasks and pgreplicas of a cluster are tied to it
+func ClusterLabel(obj interface{}) string {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+
+	object, err := meta.Accessor(obj)
+	if err != nil {
+		return ""
+	}
+	return object.GetLabels()[config.LABEL_PG_CLUSTER]
+}
+
+// Pause holds back the controllers of the namespace until it is resumed
+func (g *PauseGate) Pause() {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	if g.resumed == nil {
+		g.resumed = make(chan struct{})
+	}
+}
+
+// Resume lets the controllers of the namespace that wait on the gate carry on
+func (g *PauseGate) Resume() {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	if g.resumed != nil {
+		close(g.resumed)
+		g.resumed = nil
+	}
+}
+
+// IsPaused returns whether the namespace is paused
+func (g *PauseGate) IsPaused() bool {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	return g.resumed != nil
+}
+
+// Wait waits until the namespace is resumed if it is paused. It returns false if the controllers
+// of the namespace are stopped in the meantime, in which case they should not act
+func (g *PauseGate) Wait() bool {
+	g.mutex.Lock()
+	resumed := g.resumed
+	g.mutex.Unlock()
+
+	if resumed == nil {
+		return true
+	}
+
+	select {
+	case <-resumed:
+		return true
+	case <-g.stop:
+		return false
+	}
+}
+
+// IsClusterPaused returns whether the cluster of the namespace named is paused. A cluster that is
+// not in the cache is not paused
+func (g *PauseGate) IsClusterPaused(namespace, name string) bool {
+	if name == "" {
+		return false
+	}
+
+	cluster, err := g.clusters.Pgclusters(namespace).Get(name)
+	if err != nil {
+		return false
+	}
+	return IsClusterPaused(cluster)
+}
+
+// ResumeCluster handles the events that were held for a cluster while it was paused, in the
+// order they happened
+func (g *PauseGate) ResumeCluster(name string) {
+	g.mutex.Lock()
+	events := g.held[name]
+	delete(g.held, name)
+	g.mutex.Unlock()
+
+	if len(events) > 0 {
+		log.Infof("handling %d events held while cluster %s was paused", len(events), name)
+	}
+
+	for _, handle := range events {
+		handle()
+	}
+}
+
+// ForgetCluster drops the events that were held for a cluster that is deleted while it is paused
+func (g *PauseGate) ForgetCluster(name string) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	delete(g.held, name)
+}
+
+// EventHandler wraps the event handlers of a controller so that they wait while the namespace is
+// paused, and so that the events of a resource whose cluster is paused, which clusterName returns
+// the name of, are held until the cluster is resumed. The events of a controller that keeps track
+// of paused clusters itself, i.e. that of the pgclusters, are only held back by the namespace, as
+// is the case when clusterName is nil
+func (g *PauseGate) EventHandler(controller string, clusterName func(obj interface{}) string,
+	handler cache.ResourceEventHandlerFuncs) cache.ResourceEventHandlerFuncs {
+	handle := func(namespace string, obj interface{}, event func()) {
+		if !g.Wait() {
+			return
+		}
+
+		if clusterName != nil && g.hold(controller, namespace, clusterName(obj), event) {
+			return
+		}
+
+		event()
+	}
+
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			handle(objectNamespace(obj), obj, func() { handler.OnAdd(obj) })
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			handle(objectNamespace(newObj), newObj, func() { handler.OnUpdate(oldObj, newObj) })
+		},
+		DeleteFunc: func(obj interface{}) {
+			handle(objectNamespace(obj), obj, func() { handler.OnDelete(obj) })
+		},
+	}
+}
+
+// hold holds the event of a controller for a cluster until the cluster is resumed, returning
+// whether it did, i.e. whether the cluster is paused. The cluster is checked while the events are
+// locked, so that an event is not held once the cluster is resumed and its events are handled
+func (g *PauseGate) hold(controller, namespace, cluster string, event func()) bool {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	if !g.IsClusterPaused(namespace, cluster) {
+		return false
+	}
+
+	log.Debugf("%s controller: holding event of paused cluster %s", controller, cluster)
+
+	g.held[cluster] = append(g.held[cluster], event)
+	return true
+}
+
+// objectNamespace returns the namespace of the object of an event
+func objectNamespace(obj interface{}) string {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+
+	object, err := meta.Accessor(obj)
+	if err != nil {
+		return ""
+	}
+	return object.GetNamespace()
+}
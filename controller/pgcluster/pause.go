@@ -0,0 +1,71 @@
+package pgcluster
+
+/*
+Copyright 2020 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+import (
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	log "github.com/sirupsen/logrus"
+)
+
+// pausedCluster is what the controller holds on to for a cluster while it is paused
+type pausedCluster struct {
+	// cluster is the cluster as it was before it was paused, which the cluster is compared with
+	// once it is resumed so that whatever changed in the meantime is reconciled
+	cluster *crv1.Pgcluster
+	// queued is whether the cluster was taken off the queue while it was paused, in which case
+	// it is added back to the queue once it is resumed
+	queued bool
+}
+
+// holdPaused keeps track of a cluster that is paused. The cluster it was before it was paused is
+// only kept the first time, as are the changes that are made to it afterwards
+func (c *Controller) holdPaused(key string, cluster *crv1.Pgcluster, queued bool) {
+	c.pausedMutex.Lock()
+	defer c.pausedMutex.Unlock()
+
+	if c.paused == nil {
+		c.paused = map[string]*pausedCluster{}
+	}
+
+	held, ok := c.paused[key]
+	if !ok {
+		log.Infof("reconciliation of cluster %s is paused", key)
+		held = &pausedCluster{}
+		c.paused[key] = held
+	}
+
+	if held.cluster == nil && cluster != nil {
+		held.cluster = cluster.DeepCopy()
+	}
+	held.queued = held.queued || queued
+}
+
+// releasePaused stops keeping track of a cluster that is resumed, returning what was held for it,
+// if anything
+func (c *Controller) releasePaused(key string) *pausedCluster {
+	c.pausedMutex.Lock()
+	defer c.pausedMutex.Unlock()
+
+	held, ok := c.paused[key]
+	if !ok {
+		return nil
+	}
+	delete(c.paused, key)
+
+	log.Infof("reconciliation of cluster %s is resumed", key)
+
+	return held
+}
@@ -0,0 +1,420 @@
+package pgcluster
+
+/*
+Copyright 2020 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+import (
+	"reflect"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/config"
+	"github.com/crunchydata/postgres-operator/operator"
+	clusteroperator "github.com/crunchydata/postgres-operator/operator/cluster"
+	log "github.com/sirupsen/logrus"
+)
+
+// periodicExecWorkers is how many clusters at most have the periodic work that runs commands in
+// their Pods carried out at once
+const periodicExecWorkers = 4
+
+// periodicReconciler is a piece of the periodic work of the controller
+type periodicReconciler struct {
+	// failure is the message that a failure is logged with, given the name of the cluster
+	failure string
+	// exec is whether the work runs commands in the Pods of the cluster, which can take a while,
+	// so it is carried out apart from the sweep over the clusters
+	exec bool
+	// applies returns whether the work is carried out for a cluster. Without it, it always is
+	applies func(cluster *crv1.Pgcluster) bool
+	// reconcile carries out the work for a copy of a cluster
+	reconcile func(c *Controller, cluster *crv1.Pgcluster) error
+}
+
+// isInitialized returns whether a cluster is up, which most of the work that runs commands in
+// its Pods needs
+func isInitialized(cluster *crv1.Pgcluster) bool {
+	return cluster.Status.State == crv1.PgclusterStateInitialized
+}
+
+// periodicReconcilers are the pieces of the periodic work of the controller, in the order they
+// are carried out for each cluster
+var periodicReconcilers = []periodicReconciler{
+	{
+		// clusters that opted into or out of the cleanup or the service discovery since they
+		// were created are given or relieved of their finalizers. Each patch updates the copy,
+		// so that the next one builds on it
+		failure: "could not reconcile finalizers of cluster %s",
+		reconcile: func(c *Controller, cluster *crv1.Pgcluster) error {
+			if err := clusteroperator.ReconcileCleanupFinalizer(c.PgclusterClient, cluster); err != nil {
+				return err
+			}
+			return clusteroperator.ReconcileServiceDiscoveryFinalizer(c.PgclusterClient, cluster)
+		},
+	},
+	{
+		failure: "could not record health check of cluster %s",
+		exec:    true,
+		applies: func(cluster *crv1.Pgcluster) bool { return cluster.Spec.HealthCheck.Query != "" },
+		reconcile: func(c *Controller, cluster *crv1.Pgcluster) error {
+			return clusteroperator.ReconcileHealthCheck(c.PgclusterClientset, c.PgclusterClient,
+				c.PgclusterConfig, cluster)
+		},
+	},
+	{
+		failure: "failover health check: could not probe cluster %s",
+		exec:    true,
+		applies: func(cluster *crv1.Pgcluster) bool {
+			return cluster.Spec.FailoverHealthCheck.Enabled ||
+				cluster.Status.FailoverHealthCheck != (crv1.FailoverHealthCheckStatus{})
+		},
+		reconcile: func(c *Controller, cluster *crv1.Pgcluster) error {
+			return clusteroperator.ReconcileFailoverHealthCheck(c.PgclusterClientset,
+				c.PgclusterClient, c.PgclusterConfig, cluster)
+		},
+	},
+	{
+		failure: "autoscale: could not evaluate cluster %s",
+		exec:    true,
+		applies: func(cluster *crv1.Pgcluster) bool { return cluster.Spec.Autoscale.Enabled },
+		reconcile: func(c *Controller, cluster *crv1.Pgcluster) error {
+			return clusteroperator.ReconcileAutoscale(c.PgclusterClientset, c.PgclusterClient,
+				c.PgclusterConfig, cluster)
+		},
+	},
+	{
+		failure: "traffic ramp: could not advance cluster %s",
+		exec:    true,
+		applies: func(cluster *crv1.Pgcluster) bool { return cluster.Status.TrafficRamp.StartTime != "" },
+		reconcile: func(c *Controller, cluster *crv1.Pgcluster) error {
+			return clusteroperator.ReconcileTrafficRamp(c.PgclusterClientset, c.PgclusterClient,
+				c.PgclusterConfig, cluster)
+		},
+	},
+	{
+		failure: "could not expire connection logging of cluster %s",
+		exec:    true,
+		applies: func(cluster *crv1.Pgcluster) bool { return cluster.Status.ConnectionLogging.ActiveUntil != "" },
+		reconcile: func(c *Controller, cluster *crv1.Pgcluster) error {
+			return clusteroperator.ExpireConnectionLogging(c.PgclusterClientset, c.PgclusterClient,
+				c.PgclusterConfig, cluster)
+		},
+	},
+	{
+		failure: "sync replication: could not update cluster %s",
+		exec:    true,
+		applies: func(cluster *crv1.Pgcluster) bool {
+			return operator.GetSyncReplication(cluster.Spec.SyncReplication) ||
+				!reflect.DeepEqual(cluster.Status.SyncReplication, crv1.SyncReplicationStatus{})
+		},
+		reconcile: func(c *Controller, cluster *crv1.Pgcluster) error {
+			return clusteroperator.ReconcileSyncReplication(c.PgclusterClientset, c.PgclusterClient,
+				c.PgclusterConfig, cluster)
+		},
+	},
+	{
+		failure: "replica lag: could not check cluster %s",
+		exec:    true,
+		applies: isInitialized,
+		reconcile: func(c *Controller, cluster *crv1.Pgcluster) error {
+			return clusteroperator.ReconcileReplicaLag(c.PgclusterClientset, c.PgclusterClient,
+				c.PgclusterConfig, cluster)
+		},
+	},
+	{
+		failure: "read-only service: could not update cluster %s",
+		applies: func(cluster *crv1.Pgcluster) bool {
+			return cluster.Spec.ReadOnlyService.Enabled && isInitialized(cluster)
+		},
+		reconcile: func(c *Controller, cluster *crv1.Pgcluster) error {
+			return clusteroperator.ReconcileReadOnlyService(c.PgclusterClientset, cluster)
+		},
+	},
+	{
+		failure: "pgbouncer: could not count the databases of cluster %s",
+		exec:    true,
+		applies: func(cluster *crv1.Pgcluster) bool {
+			return cluster.Labels[config.LABEL_PGBOUNCER] == "true" && isInitialized(cluster)
+		},
+		reconcile: func(c *Controller, cluster *crv1.Pgcluster) error {
+			return clusteroperator.ReconcilePgBouncerDatabases(c.PgclusterClientset,
+				c.PgclusterClient, c.PgclusterConfig, cluster)
+		},
+	},
+	{
+		failure: "fencing: could not check quorum of cluster %s",
+		exec:    true,
+		applies: func(cluster *crv1.Pgcluster) bool {
+			return cluster.Spec.Fencing.Enabled || cluster.Status.Fencing != (crv1.FencingStatus{})
+		},
+		reconcile: func(c *Controller, cluster *crv1.Pgcluster) error {
+			return clusteroperator.ReconcileFencing(c.PgclusterClientset, c.PgclusterClient,
+				c.PgclusterConfig, cluster)
+		},
+	},
+	{
+		failure: "could not check data checksums of cluster %s",
+		exec:    true,
+		applies: func(cluster *crv1.Pgcluster) bool {
+			return isInitialized(cluster) && cluster.Status.DataChecksums == ""
+		},
+		reconcile: func(c *Controller, cluster *crv1.Pgcluster) error {
+			return clusteroperator.ReconcileDataChecksums(c.PgclusterClientset, c.PgclusterClient,
+				c.PgclusterConfig, cluster)
+		},
+	},
+	{
+		failure: "could not reload trusted CAs of cluster %s",
+		exec:    true,
+		applies: func(cluster *crv1.Pgcluster) bool {
+			return isInitialized(cluster) &&
+				(cluster.Spec.TLS.ClientAuth.IsEnabled() || cluster.Status.TLSClientCAHash != "")
+		},
+		reconcile: func(c *Controller, cluster *crv1.Pgcluster) error {
+			return clusteroperator.ReloadTLSClientCA(c.PgclusterClientset, c.PgclusterClient,
+				c.PgclusterConfig, cluster)
+		},
+	},
+	{
+		failure: "could not issue certificates of cluster %s",
+		applies: hasManagedCertificates,
+		reconcile: func(c *Controller, cluster *crv1.Pgcluster) error {
+			return clusteroperator.ReconcileTLSCertificates(c.PgclusterClientset, c.PgclusterClient,
+				cluster)
+		},
+	},
+	{
+		failure: "could not reload certificates of cluster %s",
+		exec:    true,
+		applies: hasManagedCertificates,
+		reconcile: func(c *Controller, cluster *crv1.Pgcluster) error {
+			return clusteroperator.ReloadTLSCertificates(c.PgclusterClientset, c.PgclusterClient,
+				c.PgclusterConfig, cluster)
+		},
+	},
+	{
+		failure: "could not expire pgBackRest repository of cluster %s",
+		exec:    true,
+		reconcile: func(c *Controller, cluster *crv1.Pgcluster) error {
+			return clusteroperator.ReconcileBackrestRetention(c.PgclusterClientset, c.PgclusterClient,
+				c.PgclusterConfig, cluster)
+		},
+	},
+	{
+		failure: "could not verify pgBackRest repository of cluster %s",
+		reconcile: func(c *Controller, cluster *crv1.Pgcluster) error {
+			return clusteroperator.ReconcileBackrestVerification(c.PgclusterClientset,
+				c.PgclusterClient, cluster)
+		},
+	},
+	{
+		failure: "could not rotate passwords of cluster %s",
+		exec:    true,
+		reconcile: func(c *Controller, cluster *crv1.Pgcluster) error {
+			return clusteroperator.ReconcilePasswordRotation(c.PgclusterClientset, c.PgclusterClient,
+				c.PgclusterConfig, c.CredentialStore, cluster)
+		},
+	},
+	{
+		failure: "could not change password encryption of cluster %s",
+		exec:    true,
+		reconcile: func(c *Controller, cluster *crv1.Pgcluster) error {
+			return clusteroperator.ReconcilePasswordEncryption(c.PgclusterClientset,
+				c.PgclusterClient, c.PgclusterConfig, c.CredentialStore, cluster)
+		},
+	},
+	{
+		failure: "could not apply postgres parameters of cluster %s",
+		exec:    true,
+		reconcile: func(c *Controller, cluster *crv1.Pgcluster) error {
+			return clusteroperator.ReconcilePostgresParams(c.PgclusterClientset, c.PgclusterClient,
+				c.PgclusterConfig, cluster)
+		},
+	},
+	{
+		failure: "could not update cluster %s to its image tag",
+		exec:    true,
+		applies: func(cluster *crv1.Pgcluster) bool { return cluster.Status.MinorUpgrade.InProgress },
+		reconcile: func(c *Controller, cluster *crv1.Pgcluster) error {
+			return clusteroperator.ReconcileMinorUpgrade(c.PgclusterClientset, c.PgclusterClient,
+				c.PgclusterConfig, cluster)
+		},
+	},
+	{
+		failure: "could not set up standby streaming of cluster %s",
+		applies: func(cluster *crv1.Pgcluster) bool { return cluster.Spec.Standby },
+		reconcile: func(c *Controller, cluster *crv1.Pgcluster) error {
+			return clusteroperator.ReconcileStandbyStreaming(c.PgclusterClientset, cluster)
+		},
+	},
+	{
+		failure: "exporter: could not apply custom queries of cluster %s",
+		exec:    true,
+		applies: func(cluster *crv1.Pgcluster) bool {
+			return isInitialized(cluster) && operator.GetCollectQueries(&cluster.Spec) != ""
+		},
+		reconcile: func(c *Controller, cluster *crv1.Pgcluster) error {
+			return clusteroperator.ReconcileExporterQueries(c.PgclusterClientset, c.PgclusterConfig,
+				cluster)
+		},
+	},
+	{
+		failure: "chargeback: could not label the resources of cluster %s",
+		reconcile: func(c *Controller, cluster *crv1.Pgcluster) error {
+			return clusteroperator.ReconcileChargebackLabels(c.PgclusterClientset, cluster)
+		},
+	},
+	{
+		failure: "metadata: could not update the resources of cluster %s",
+		applies: func(cluster *crv1.Pgcluster) bool {
+			return !reflect.DeepEqual(cluster.Spec.Metadata, crv1.MetadataSpec{})
+		},
+		reconcile: func(c *Controller, cluster *crv1.Pgcluster) error {
+			return clusteroperator.ReconcileCustomMetadata(c.PgclusterClientset, cluster)
+		},
+	},
+	{
+		failure: "could not reconcile pod disruption budgets of cluster %s",
+		applies: func(cluster *crv1.Pgcluster) bool { return cluster.Spec.PodDisruptionBudget.Enabled },
+		reconcile: func(c *Controller, cluster *crv1.Pgcluster) error {
+			return clusteroperator.ReconcilePodDisruptionBudgets(c.PgclusterClientset,
+				c.PgclusterClient, cluster)
+		},
+	},
+	{
+		failure: "storage autogrow: could not grow the volumes of cluster %s",
+		applies: func(cluster *crv1.Pgcluster) bool { return cluster.Spec.StorageAutogrow.Enabled },
+		reconcile: func(c *Controller, cluster *crv1.Pgcluster) error {
+			return clusteroperator.ReconcileStorageAutogrow(c.PgclusterClientset, c.PgclusterClient,
+				cluster)
+		},
+	},
+	{
+		failure: "could not resize the volume of the primary of cluster %s",
+		applies: func(cluster *crv1.Pgcluster) bool {
+			return cluster.Status.PrimaryStorage.Resizing ||
+				cluster.Status.PrimaryStorage.FileSystemResizePending
+		},
+		reconcile: func(c *Controller, cluster *crv1.Pgcluster) error {
+			return clusteroperator.ReconcilePrimaryStorage(c.PgclusterClientset, c.PgclusterClient,
+				cluster)
+		},
+	},
+	{
+		failure: "could not create the tablespaces of cluster %s",
+		exec:    true,
+		applies: func(cluster *crv1.Pgcluster) bool { return len(cluster.Spec.GetTablespaces()) > 0 },
+		reconcile: func(c *Controller, cluster *crv1.Pgcluster) error {
+			return clusteroperator.ReconcileTablespaces(c.PgclusterClientset, c.PgclusterClient,
+				c.PgclusterConfig, cluster)
+		},
+	},
+	{
+		failure: "could not take the scheduled snapshots of cluster %s",
+		applies: func(cluster *crv1.Pgcluster) bool { return cluster.Spec.VolumeSnapshots.Schedule != "" },
+		reconcile: func(c *Controller, cluster *crv1.Pgcluster) error {
+			return clusteroperator.ReconcileVolumeSnapshotSchedule(c.PgclusterClientset,
+				c.PgclusterClient, cluster)
+		},
+	},
+	{
+		failure: "hibernation: could not schedule cluster %s",
+		applies: func(cluster *crv1.Pgcluster) bool { return cluster.Spec.HibernationSchedule.IsEnabled() },
+		reconcile: func(c *Controller, cluster *crv1.Pgcluster) error {
+			return clusteroperator.ReconcileHibernationSchedule(c.PgclusterClientset,
+				c.PgclusterClient, cluster)
+		},
+	},
+	{
+		failure: "resource recommendation: could not sample cluster %s",
+		exec:    true,
+		applies: func(cluster *crv1.Pgcluster) bool { return cluster.Spec.ResourceRecommendation.IsEnabled() },
+		reconcile: func(c *Controller, cluster *crv1.Pgcluster) error {
+			return clusteroperator.ReconcileResourceRecommendation(c.PgclusterClientset,
+				c.PgclusterClient, c.PgclusterConfig, cluster)
+		},
+	},
+	{
+		failure: "maintenance window: could not carry out the pending actions of cluster %s",
+		exec:    true,
+		applies: func(cluster *crv1.Pgcluster) bool {
+			return len(cluster.Status.MaintenanceWindow.PendingActions) > 0
+		},
+		reconcile: func(c *Controller, cluster *crv1.Pgcluster) error {
+			return clusteroperator.ReconcileMaintenanceWindow(c.PgclusterClientset,
+				c.PgclusterClient, c.PgclusterConfig, cluster)
+		},
+	},
+	{
+		failure: "could not record conditions of cluster %s",
+		reconcile: func(c *Controller, cluster *crv1.Pgcluster) error {
+			return clusteroperator.ReconcileConditions(c.PgclusterClientset, c.PgclusterClient,
+				cluster)
+		},
+	},
+}
+
+// hasManagedCertificates returns whether the Operator requests or generates the certificates of
+// a cluster that is up
+func hasManagedCertificates(cluster *crv1.Pgcluster) bool {
+	return isInitialized(cluster) && (cluster.Spec.TLS.IsManaged() || cluster.Status.TLSCertHash != "")
+}
+
+// reconcilePeriodic carries out the periodic work of a cluster, either the work that runs
+// commands in its Pods or the rest of it. The cluster is owned by the informer cache, so each
+// piece of work is given a copy of it
+func (c *Controller) reconcilePeriodic(cluster *crv1.Pgcluster, exec bool) {
+	for _, reconciler := range periodicReconcilers {
+		if reconciler.exec != exec || (reconciler.applies != nil && !reconciler.applies(cluster)) {
+			continue
+		}
+
+		if err := reconciler.reconcile(c, cluster.DeepCopy()); err != nil {
+			log.Errorf(reconciler.failure+": %s", cluster.Name, err)
+		}
+	}
+}
+
+// startPeriodicExec carries out the periodic work of a cluster that runs commands in its Pods in
+// the background, unless the work of the previous interval is still underway for the cluster. At
+// most periodicExecWorkers clusters have the work carried out at once, so that one cluster that is
+// slow to answer only holds back itself
+func (c *Controller) startPeriodicExec(cluster *crv1.Pgcluster) {
+	key := cluster.Namespace + "/" + cluster.Name
+
+	c.periodicMutex.Lock()
+	defer c.periodicMutex.Unlock()
+
+	if c.periodicExecs == nil {
+		c.periodicExecs = map[string]bool{}
+		c.periodicExecSlots = make(chan struct{}, periodicExecWorkers)
+	}
+
+	if c.periodicExecs[key] {
+		log.Debugf("periodic work of cluster %s is still underway, skipping it", key)
+		return
+	}
+	c.periodicExecs[key] = true
+
+	go func() {
+		c.periodicExecSlots <- struct{}{}
+		defer func() { <-c.periodicExecSlots }()
+
+		c.reconcilePeriodic(cluster, true)
+
+		c.periodicMutex.Lock()
+		delete(c.periodicExecs, key)
+		c.periodicMutex.Unlock()
+	}()
+}
@@ -16,25 +16,36 @@ limitations under the License.
 */
 
 import (
+	"errors"
 	"io/ioutil"
 	"reflect"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
 	"github.com/crunchydata/postgres-operator/config"
+	"github.com/crunchydata/postgres-operator/controller"
 	"github.com/crunchydata/postgres-operator/kubeapi"
+	"github.com/crunchydata/postgres-operator/operator"
 	"github.com/crunchydata/postgres-operator/util"
 
 	clusteroperator "github.com/crunchydata/postgres-operator/operator/cluster"
+	taskoperator "github.com/crunchydata/postgres-operator/operator/task"
 	informers "github.com/crunchydata/postgres-operator/pkg/generated/informers/externalversions/crunchydata.com/v1"
 	log "github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/util/workqueue"
 )
 
+// finalizeRequeueDelay is how long to wait before carrying on with removing the resources of a
+// deleted cluster, e.g. while its Pods terminate
+const finalizeRequeueDelay = 5 * time.Second
+
 // Controller holds the connections for the controller
 type Controller struct {
 	PgclusterClient    *rest.RESTClient
@@ -42,6 +53,20 @@ type Controller struct {
 	PgclusterConfig    *rest.Config
 	Queue              workqueue.RateLimitingInterface
 	Informer           informers.PgclusterInformer
+	// PauseGate holds back the controller while the namespace or the cluster it acts on is paused
+	PauseGate *controller.PauseGate
+	// CredentialStore is where the credentials of the clusters are kept
+	CredentialStore operator.CredentialStore
+	// WorkerCount is the number of workers that process the queue
+	WorkerCount int
+	// paused holds on to the clusters whose reconciliation is paused, by their key
+	pausedMutex sync.Mutex
+	paused      map[string]*pausedCluster
+	// periodicExecs holds the clusters whose periodic work that runs commands in their Pods is
+	// underway, by their key, and periodicExecSlots limits how many of them there are at once
+	periodicMutex     sync.Mutex
+	periodicExecs     map[string]bool
+	periodicExecSlots chan struct{}
 }
 
 // onAdd is called when a pgcluster is added
@@ -49,6 +74,15 @@ func (c *Controller) onAdd(obj interface{}) {
 	cluster := obj.(*crv1.Pgcluster)
 	log.Debugf("[pgcluster Controller] ns %s onAdd %s", cluster.ObjectMeta.Namespace, cluster.ObjectMeta.SelfLink)
 
+	// a cluster that was deleted while the operator was down still has its resources removed
+	if cluster.DeletionTimestamp != nil {
+		if key, err := cache.MetaNamespaceKeyFunc(obj); err == nil &&
+			clusteroperator.HasOperatorFinalizer(cluster) {
+			c.Queue.Add(key)
+		}
+		return
+	}
+
 	//handle the case when the operator restarts and don't
 	//process already processed pgclusters
 	if cluster.Status.State == crv1.PgclusterStateProcessed {
@@ -71,6 +105,34 @@ func (c *Controller) RunWorker() {
 	}
 }
 
+// GetWorkerCount returns the number of workers that process the queue, which is at least one
+func (c *Controller) GetWorkerCount() int {
+	if c.WorkerCount < 1 {
+		return 1
+	}
+	return c.WorkerCount
+}
+
+// RunPeriodic carries out the periodic work of the controller for each cluster that is neither
+// paused nor deleted. The work that runs commands in the Pods of a cluster is carried out in the
+// background, so that a cluster that is slow to answer does not hold back the others
+func (c *Controller) RunPeriodic() {
+	clusters, err := c.Informer.Lister().List(labels.Everything())
+	if err != nil {
+		log.Error(err)
+		return
+	}
+
+	for _, cluster := range clusters {
+		if controller.IsClusterPaused(cluster) || cluster.DeletionTimestamp != nil {
+			continue
+		}
+
+		c.reconcilePeriodic(cluster, false)
+		c.startPeriodicExec(cluster)
+	}
+}
+
 func (c *Controller) processNextItem() bool {
 	// Wait until there is a new item in the working queue
 	key, quit := c.Queue.Get()
@@ -90,6 +152,21 @@ func (c *Controller) processNextItem() bool {
 	// parallel.
 	defer c.Queue.Done(key)
 
+	// a paused cluster is taken off the queue, and is added back to it once it is resumed
+	if cluster, err := c.Informer.Lister().Pgclusters(keyNamespace).Get(keyResourceName); err == nil &&
+		controller.IsClusterPaused(cluster) {
+		c.holdPaused(key.(string), nil, true)
+		c.Queue.Forget(key)
+		return true
+	}
+
+	// a cluster that is deleted has its resources removed before it is let go
+	if cluster, err := c.Informer.Lister().Pgclusters(keyNamespace).Get(keyResourceName); err == nil &&
+		cluster.DeletionTimestamp != nil {
+		c.finalizeCluster(key.(string), cluster)
+		return true
+	}
+
 	// Invoke the method containing the business logic
 	// in this case, the de-dupe logic is to test whether a cluster
 	// deployment exists , if so, then we don't create another
@@ -97,6 +174,14 @@ func (c *Controller) processNextItem() bool {
 
 	if found {
 		log.Debugf("cluster add - dep already found, not creating again")
+
+		// a cluster that already exists is in the queue if it has been asked to be reconciled
+		cluster := crv1.Pgcluster{}
+		if found, _ := kubeapi.Getpgcluster(c.PgclusterClient, &cluster, keyResourceName,
+			keyNamespace); found {
+			c.reconcileNow(&cluster)
+		}
+
 		return true
 	}
 
@@ -110,6 +195,43 @@ func (c *Controller) processNextItem() bool {
 
 	addIdentifier(&cluster)
 
+	// a cluster that is cloned from another cluster is added by the clone workflow once the
+	// repository of the other cluster is restored into it
+	if cluster.Status.State == crv1.PgclusterStateCloning {
+		log.Debugf("cluster add - cluster %s is being cloned", cluster.Name)
+		c.Queue.Forget(key)
+		return true
+	}
+
+	if clusteroperator.IsClone(&cluster) {
+		c.Queue.Forget(key)
+
+		if err := clusteroperator.StartClone(c.PgclusterClientset, c.PgclusterClient, &cluster); err != nil {
+			log.Errorf("could not clone cluster %s: %s", cluster.Name, err)
+		}
+		return true
+	}
+
+	// the credentials need to be in place before the cluster is processed. If the credential
+	// store is not available at the moment, try again later instead of failing the cluster
+	if err := clusteroperator.CreateClusterCredentials(c.CredentialStore, &cluster); operator.IsCredentialStoreUnavailable(err) {
+		log.Warnf("cluster add - requeueing cluster %s: %s", cluster.Name, err)
+		c.Queue.AddRateLimited(key)
+		return true
+	} else if err != nil {
+		log.Error(err)
+	}
+
+	c.Queue.Forget(key)
+
+	if err := clusteroperator.ReconcileCleanupFinalizer(c.PgclusterClient, &cluster); err != nil {
+		log.Errorf("could not reconcile cleanup finalizer of cluster %s: %s", cluster.Name, err)
+	}
+
+	if err := clusteroperator.ReconcileServiceDiscoveryFinalizer(c.PgclusterClient, &cluster); err != nil {
+		log.Errorf("could not reconcile service discovery finalizer of cluster %s: %s", cluster.Name, err)
+	}
+
 	state := crv1.PgclusterStateProcessed
 	message := "Successfully processed Pgcluster by controller"
 	err = kubeapi.PatchpgclusterStatus(c.PgclusterClient, state, message, &cluster, keyNamespace)
@@ -131,14 +253,63 @@ func (c *Controller) onUpdate(oldObj, newObj interface{}) {
 	newcluster := newObj.(*crv1.Pgcluster)
 	//	log.Debugf("pgcluster ns=%s %s onUpdate", newcluster.ObjectMeta.Namespace, newcluster.ObjectMeta.Name)
 
+	// a paused cluster is not reconciled. Once it is resumed, it is compared with the cluster it
+	// was before it was paused, so that whatever changed in the meantime is reconciled then
+	if key, err := cache.MetaNamespaceKeyFunc(newObj); err == nil {
+		if controller.IsClusterPaused(newcluster) {
+			c.holdPaused(key, oldcluster, false)
+			return
+		}
+
+		if held := c.releasePaused(key); held != nil {
+			if held.cluster != nil {
+				oldcluster = held.cluster
+			}
+			if held.queued {
+				c.Queue.Add(key)
+			}
+		}
+
+		// the other controllers held on to the events of the cluster's resources while it was
+		// paused, which are handled now
+		c.PauseGate.ResumeCluster(newcluster.Name)
+
+		// a cluster that is deleted is no longer reconciled, but has its resources removed
+		if newcluster.DeletionTimestamp != nil {
+			if clusteroperator.HasOperatorFinalizer(newcluster) {
+				c.Queue.Add(key)
+			}
+			return
+		}
+	}
+
+	// if a new reconcile token is set, queue the cluster to be reconciled, regardless of whether
+	// anything else about the cluster changed. Only a cluster that is up or shut down is queued,
+	// as the queue creates the clusters that do not have a primary Deployment, e.g. one that
+	// is being restored. Such a cluster is queued once it is up again, as the token is only
+	// recorded once the cluster is reconciled
+	if token := newcluster.Annotations[config.ANNOTATION_RECONCILE_NOW]; token != "" &&
+		token != newcluster.Status.ReconcileToken &&
+		(newcluster.Status.State == crv1.PgclusterStateInitialized ||
+			newcluster.Status.State == crv1.PgclusterStateShutdown) {
+		if key, err := cache.MetaNamespaceKeyFunc(newObj); err == nil {
+			log.Debugf("cluster putting key in queue %s for reconcile token %s", key, token)
+			c.Queue.Add(key)
+		}
+	}
+
 	// if the 'shutdown' parameter in the pgcluster update shows that the cluster should be either
 	// shutdown or started but its current status does not properly reflect that it is, then
 	// proceed with the logic needed to either shutdown or start the cluster
 	if newcluster.Spec.Shutdown && newcluster.Status.State != crv1.PgclusterStateShutdown {
-		clusteroperator.ShutdownCluster(c.PgclusterClientset, c.PgclusterClient, *newcluster)
-	} else if !newcluster.Spec.Shutdown &&
-		newcluster.Status.State != crv1.PgclusterStateInitialized {
-		clusteroperator.StartupCluster(c.PgclusterClientset, *newcluster)
+		if err := clusteroperator.ShutdownCluster(c.PgclusterClientset, c.PgclusterClient,
+			*newcluster); err != nil {
+			log.Error(err)
+		}
+	} else if !newcluster.Spec.Shutdown && newcluster.Status.State == crv1.PgclusterStateShutdown {
+		if err := clusteroperator.StartupCluster(c.PgclusterClientset, *newcluster); err != nil {
+			log.Error(err)
+		}
 	}
 
 	// check to see if the "autofail" label on the pgcluster CR has been changed from either true to false, or from
@@ -165,7 +336,9 @@ func (c *Controller) onUpdate(oldObj, newObj interface{}) {
 
 	// handle standby being enabled and disabled for the cluster
 	if oldcluster.Spec.Standby && !newcluster.Spec.Standby {
-		if err := clusteroperator.DisableStandby(c.PgclusterClientset, *newcluster); err != nil {
+		// a promote standby pgtask disables standby mode before it updates the spec
+		if err := clusteroperator.DisableStandby(c.PgclusterClientset, *newcluster); err != nil &&
+			!errors.Is(err, clusteroperator.ErrStandbyNotEnabled) {
 			log.Error(err)
 			return
 		}
@@ -174,20 +347,360 @@ func (c *Controller) onUpdate(oldObj, newObj interface{}) {
 			log.Error(err)
 			return
 		}
+	} else if newcluster.Spec.Standby &&
+		oldcluster.Spec.StandbyStreaming != newcluster.Spec.StandbyStreaming {
+		if err := clusteroperator.ReconcileStandbyStreaming(c.PgclusterClientset, newcluster); err != nil {
+			log.Error(err)
+		}
+	}
+
+	// see if any of the resource values have changed, and if so, update them. As this restarts
+	// the instances of the cluster, it is done within the restart budget and the maintenance
+	// window
+	if oldcluster.Spec.ContainerResources != newcluster.Spec.ContainerResources {
+		if err := clusteroperator.ApplyResources(c.PgclusterClientset, c.PgclusterClient,
+			c.PgclusterConfig, newcluster); err != nil {
+			log.Error(err)
+		}
 	}
 
-	// see if any of the resource values have changed, and if so, update them
-	if oldcluster.Spec.ContainerResources.RequestsCPU != newcluster.Spec.ContainerResources.RequestsCPU ||
-		oldcluster.Spec.ContainerResources.RequestsMemory != newcluster.Spec.ContainerResources.RequestsMemory {
-		if err := clusteroperator.UpdateResources(c.PgclusterClientset, c.PgclusterConfig, newcluster); err != nil {
+	// if the storage size of the primary has changed, resize its volume. The storage size of the
+	// replicas is set on their pgreplicas, which resize the volumes of the replicas in turn
+	if oldcluster.Spec.PrimaryStorage.Size != newcluster.Spec.PrimaryStorage.Size {
+		if err := clusteroperator.ReconcilePrimaryStorage(c.PgclusterClientset, c.PgclusterClient,
+			newcluster); err != nil {
+			log.Error(err)
+		}
+	}
+
+	if oldcluster.Spec.ReplicaStorage.Size != newcluster.Spec.ReplicaStorage.Size {
+		if err := clusteroperator.UpdateReplicaStorageSize(c.PgclusterClientset, c.PgclusterClient,
+			newcluster); err != nil {
+			log.Error(err)
+		}
+	}
+
+	// if the S3 settings of the pgBackRest repository have changed, apply them to the
+	// pgBackRest configuration of the cluster
+	if oldcluster.Spec.BackrestS3Endpoint != newcluster.Spec.BackrestS3Endpoint ||
+		oldcluster.Spec.BackrestS3Region != newcluster.Spec.BackrestS3Region ||
+		oldcluster.Spec.BackrestS3 != newcluster.Spec.BackrestS3 {
+		if err := clusteroperator.UpdateBackrestS3(c.PgclusterClientset, c.PgclusterConfig,
+			newcluster); err != nil {
+			log.Error(err)
+		}
+	}
+
+	// if the GCS or Azure settings of the pgBackRest repository have changed, apply them to the
+	// pgBackRest configuration of the cluster
+	if oldcluster.Spec.BackrestGCS != newcluster.Spec.BackrestGCS ||
+		oldcluster.Spec.BackrestAzure != newcluster.Spec.BackrestAzure {
+		if err := clusteroperator.UpdateBackrestCloud(c.PgclusterClientset, c.PgclusterConfig,
+			newcluster); err != nil {
+			log.Error(err)
+		}
+	}
+
+	// if the workload identity of the pgBackRest repository has changed, annotate the
+	// ServiceAccounts with it. Whether keys are used at all is only set as the cluster is created
+	if oldcluster.Spec.BackrestIdentity != newcluster.Spec.BackrestIdentity {
+		if err := operator.ReconcileBackrestIdentity(c.PgclusterClientset, newcluster); err != nil {
+			log.Error(err)
+			operator.RecordWarningEvent(c.PgclusterClientset, newcluster,
+				operator.EventReasonInvalidBackrestIdentity, err.Error())
+		}
+	}
+
+	// if the additional pgBackRest repositories have changed, apply them to the pgBackRest
+	// configuration of the cluster
+	if !reflect.DeepEqual(oldcluster.Spec.BackrestRepos, newcluster.Spec.BackrestRepos) {
+		if err := clusteroperator.UpdateBackrestRepos(c.PgclusterClientset, c.PgclusterClient,
+			c.PgclusterConfig, oldcluster.Spec.BackrestRepos, newcluster); err != nil {
+			log.Error(err)
+		}
+	}
+
+	// if the runtime class has changed, roll it out to the instances of the cluster. As this
+	// restarts them, it is done within the restart budget
+	if oldcluster.Spec.RuntimeClassName != newcluster.Spec.RuntimeClassName {
+		clusteroperator.RestartWithinBudget(c.PgclusterClientset, newcluster, "runtime class", func() error {
+			return clusteroperator.UpdateRuntimeClass(c.PgclusterClientset, c.PgclusterClient,
+				c.PgclusterConfig, newcluster)
+		})
+	}
+
+	// if the maintenance schedule has changed, update the schedule that is
+	// used by the pgo-scheduler
+	if oldcluster.Spec.Maintenance.Schedule != newcluster.Spec.Maintenance.Schedule {
+		if err := clusteroperator.UpdateMaintenanceSchedule(c.PgclusterClientset, newcluster,
+			newcluster.Namespace); err != nil {
 			log.Error(err)
 			return
 		}
 	}
 
+	// if the removal of the cluster is blocked on a final backup and has now been forced, proceed
+	// with the removal
+	if newcluster.Spec.DeletionProtection.BackupFirst &&
+		oldcluster.Annotations[config.ANNOTATION_DELETION_PROTECTION_FORCE] != "true" &&
+		newcluster.Annotations[config.ANNOTATION_DELETION_PROTECTION_FORCE] == "true" {
+		taskoperator.ResumeRemoveData(c.PgclusterClientset, c.PgclusterClient, newcluster)
+		return
+	}
+
+	// if the connection logging annotation has changed, turn the logging of the connections on
+	// or off accordingly
+	if oldcluster.Annotations[config.ANNOTATION_CONNECTION_LOGGING_UNTIL] !=
+		newcluster.Annotations[config.ANNOTATION_CONNECTION_LOGGING_UNTIL] {
+		if err := clusteroperator.ReconcileConnectionLogging(c.PgclusterClientset, c.PgclusterClient,
+			c.PgclusterConfig, newcluster.DeepCopy()); err != nil {
+			log.Error(err)
+		}
+	}
+
+	// if the replica floor has changed, add or remove temporary replicas as needed
+	if oldcluster.Spec.MinReadyReplicas != newcluster.Spec.MinReadyReplicas {
+		if err := clusteroperator.ReconcileReplicaFloor(c.PgclusterClientset, c.PgclusterClient,
+			newcluster); err != nil {
+			log.Error(err)
+		}
+	}
+
+	// if the audit settings have changed, reconcile pgaudit with the new settings
+	if !reflect.DeepEqual(oldcluster.Spec.Audit, newcluster.Spec.Audit) {
+		clusteroperator.UpdateAudit(c.PgclusterClientset, c.PgclusterConfig, newcluster,
+			oldcluster.Spec.Audit)
+	}
+
+	// if the service discovery settings have changed, remove the ExternalName
+	// Service from its previous location and reconcile it with the new settings
+	if oldcluster.Spec.ServiceDiscovery != newcluster.Spec.ServiceDiscovery {
+		if err := clusteroperator.DeleteServiceDiscovery(c.PgclusterClientset, oldcluster,
+			oldcluster.Spec.ServiceDiscovery); err != nil {
+			log.Error(err)
+		}
+
+		if err := clusteroperator.UpdateServiceDiscovery(c.PgclusterClientset, c.PgclusterClient,
+			newcluster); err != nil {
+			log.Error(err)
+		}
+
+		if err := clusteroperator.ReconcileServiceDiscoveryFinalizer(c.PgclusterClient,
+			newcluster.DeepCopy()); err != nil {
+			log.Error(err)
+		}
+	}
+
+	// if the read-only Service settings have changed, create, update or remove the read-only
+	// Service accordingly
+	if !reflect.DeepEqual(oldcluster.Spec.ReadOnlyService, newcluster.Spec.ReadOnlyService) {
+		if err := clusteroperator.ReconcileReadOnlyService(c.PgclusterClientset,
+			newcluster.DeepCopy()); err != nil {
+			log.Error(err)
+		}
+	}
+
+	// if the settings of the Services have changed, apply them to the Services
+	if !reflect.DeepEqual(oldcluster.Spec.Services, newcluster.Spec.Services) {
+		if err := clusteroperator.ReconcileServices(c.PgclusterClientset, newcluster,
+			oldcluster.Spec.Services); err != nil {
+			log.Error(err)
+		}
+	}
+
+	// if the client certificate authentication has changed, update the trusted CAs, the client
+	// certificates and the pg_hba rules. If it was turned on or off, the instances are first
+	// moved to or from the bundle of trusted CAs, and if the replication user was moved to its
+	// client certificate, it is first mounted into the instances. Both restart the instances,
+	// so this is done within the restart budget
+	if !reflect.DeepEqual(oldcluster.Spec.TLS.ClientAuth, newcluster.Spec.TLS.ClientAuth) {
+		if oldcluster.Spec.TLS.ClientAuth.IsEnabled() != newcluster.Spec.TLS.ClientAuth.IsEnabled() ||
+			(newcluster.Spec.TLS.ClientAuth.Replication && !oldcluster.Spec.TLS.ClientAuth.Replication) {
+			clusteroperator.RestartWithinBudget(c.PgclusterClientset, newcluster, "tls client auth", func() error {
+				if err := clusteroperator.UpdateTLSClientCAVolume(c.PgclusterClientset, c.PgclusterConfig,
+					newcluster); err != nil {
+					return err
+				}
+				if err := clusteroperator.UpdateTLSReplicationVolume(c.PgclusterClientset, c.PgclusterConfig,
+					newcluster); err != nil {
+					return err
+				}
+				return clusteroperator.ReconcileTLSClientAuth(c.PgclusterClientset, c.PgclusterClient,
+					c.PgclusterConfig, newcluster)
+			})
+		} else if err := clusteroperator.ReconcileTLSClientAuth(c.PgclusterClientset, c.PgclusterClient,
+			c.PgclusterConfig, newcluster); err != nil {
+			log.Error(err)
+		}
+	}
+
+	// if the settings of the certificates that the Operator manages have changed, update the
+	// certificates of the cluster, which are then issued again and reloaded periodically
+	if oldcluster.Spec.TLS.CertManager != newcluster.Spec.TLS.CertManager ||
+		oldcluster.Spec.TLS.Generate != newcluster.Spec.TLS.Generate {
+		if err := clusteroperator.ReconcileTLSCertificates(c.PgclusterClientset, c.PgclusterClient,
+			newcluster.DeepCopy()); err != nil {
+			log.Error(err)
+		}
+	}
+
+	// if the custom pg_hba rules have changed, apply them, which Patroni reloads PostgreSQL for
+	if !reflect.DeepEqual(oldcluster.Spec.HBA, newcluster.Spec.HBA) {
+		if err := clusteroperator.ReconcileHBA(c.PgclusterClientset, newcluster); err != nil {
+			log.Error(err)
+		}
+	}
+
+	// if the password encryption has changed, move the users of the cluster to it
+	if oldcluster.Spec.GetPasswordEncryption() != newcluster.Spec.GetPasswordEncryption() {
+		if err := clusteroperator.ReconcilePasswordEncryption(c.PgclusterClientset, c.PgclusterClient,
+			c.PgclusterConfig, c.CredentialStore, newcluster.DeepCopy()); err != nil {
+			log.Error(err)
+		}
+	}
+
+	// if the PostgreSQL parameters have changed, apply them, restarting the cluster in the
+	// background if any of them require it. The memory settings of a tuned cluster follow its
+	// resources
+	if !reflect.DeepEqual(oldcluster.Spec.PostgresParams, newcluster.Spec.PostgresParams) ||
+		oldcluster.Spec.Tuning != newcluster.Spec.Tuning ||
+		(newcluster.Spec.Tuning == crv1.PostgresTuningAuto &&
+			oldcluster.Spec.ContainerResources != newcluster.Spec.ContainerResources) {
+		if err := clusteroperator.ReconcilePostgresParams(c.PgclusterClientset, c.PgclusterClient,
+			c.PgclusterConfig, newcluster.DeepCopy()); err != nil {
+			log.Error(err)
+		}
+	}
+
+	// if synchronous replication or its quorum has changed, apply it, which is reloaded
+	if operator.GetSyncReplication(oldcluster.Spec.SyncReplication) !=
+		operator.GetSyncReplication(newcluster.Spec.SyncReplication) ||
+		oldcluster.Spec.SyncReplicationQuorum != newcluster.Spec.SyncReplicationQuorum {
+		if err := clusteroperator.ReconcileSyncReplication(c.PgclusterClientset, c.PgclusterClient,
+			c.PgclusterConfig, newcluster.DeepCopy()); err != nil {
+			log.Error(err)
+		}
+	}
+
+	// the locale is fixed once the cluster is initialized, so a change of it is only recorded
+	// as refused in the status of the cluster
+	if newcluster.Spec.Locale != oldcluster.Spec.Locale {
+		if err := clusteroperator.ReconcileLocale(c.PgclusterClientset, c.PgclusterClient,
+			newcluster.DeepCopy()); err != nil {
+			log.Error(err)
+		}
+	}
+
+	// if the time zone has changed, apply it with a reload
+	if newcluster.Spec.Timezone != oldcluster.Spec.Timezone {
+		if err := clusteroperator.ReconcileTimezone(c.PgclusterClientset, c.PgclusterConfig,
+			newcluster); err != nil {
+			log.Error(err)
+		}
+	}
+
+	// if the image tag has changed, roll it out to the instances one at a time, and roll out
+	// the matching pgBouncer image if pgBouncer is enabled for the cluster
+	if oldcluster.Spec.CCPImageTag != newcluster.Spec.CCPImageTag {
+		if err := clusteroperator.ReconcileMinorUpgrade(c.PgclusterClientset, c.PgclusterClient,
+			c.PgclusterConfig, newcluster.DeepCopy()); err != nil {
+			log.Error(err)
+		}
+
+		if newcluster.Labels[config.LABEL_PGBOUNCER] == "true" {
+			if err := clusteroperator.UpdatePgBouncerImage(c.PgclusterClientset, c.PgclusterConfig,
+				newcluster); err != nil {
+				log.Error(err)
+			}
+		}
+	}
+
+	// if the pgBouncer spec has changed, e.g. its replicas, pool settings or resources, or the
+	// scheduling of the cluster that pgBouncer follows, bring pgBouncer in line with it. As this
+	// waits for the pgBouncer Pods, it is done in the background
+	if (!reflect.DeepEqual(oldcluster.Spec.PgBouncer, newcluster.Spec.PgBouncer) ||
+		!reflect.DeepEqual(oldcluster.Spec.NodeAffinity, newcluster.Spec.NodeAffinity) ||
+		!reflect.DeepEqual(oldcluster.Spec.Tolerations, newcluster.Spec.Tolerations) ||
+		oldcluster.Spec.PriorityClassName != newcluster.Spec.PriorityClassName) &&
+		newcluster.Labels[config.LABEL_PGBOUNCER] == "true" {
+		go func() {
+			// the keypair of pgBouncer is valid for the names of the read-only pgBouncer as well
+			if oldcluster.Spec.PgBouncer.ReadOnly != newcluster.Spec.PgBouncer.ReadOnly {
+				if err := clusteroperator.ReconcileTLSCertificates(c.PgclusterClientset, c.PgclusterClient,
+					newcluster.DeepCopy()); err != nil {
+					log.Error(err)
+				}
+			}
+
+			if err := clusteroperator.ReconcilePgBouncer(c.PgclusterClientset, c.PgclusterConfig,
+				newcluster); err != nil {
+				log.Error(err)
+			}
+		}()
+	}
+
+	// if the exporter spec has changed, e.g. it was enabled or it has other custom queries, bring
+	// the exporter in line with it. As this restarts the instances, it is done within the
+	// restart budget
+	if !reflect.DeepEqual(oldcluster.Spec.Exporter, newcluster.Spec.Exporter) {
+		clusteroperator.RestartWithinBudget(c.PgclusterClientset, newcluster, "exporter", func() error {
+			return clusteroperator.ReconcileExporter(c.PgclusterClientset, c.PgclusterConfig,
+				c.CredentialStore, newcluster)
+		})
+	}
+
+	// if the containers, init containers or volumes that are added to the instances have changed,
+	// roll out the instances with them, again within the restart budget
+	if !reflect.DeepEqual(oldcluster.Spec.InstancePod, newcluster.Spec.InstancePod) {
+		clusteroperator.RestartWithinBudget(c.PgclusterClientset, newcluster, "instance pod", func() error {
+			return clusteroperator.UpdateInstancePod(c.PgclusterClientset, c.PgclusterConfig, newcluster)
+		})
+	}
+
+	// if the monitor spec has changed, or the exporter was enabled or disabled, create, update
+	// or remove the ServiceMonitor or PodMonitor of the cluster
+	if !reflect.DeepEqual(oldcluster.Spec.Monitor, newcluster.Spec.Monitor) ||
+		operator.IsExporterEnabled(&oldcluster.Spec) != operator.IsExporterEnabled(&newcluster.Spec) {
+		if err := clusteroperator.ReconcileMonitor(c.PgclusterClientset, newcluster); err != nil {
+			log.Error(err)
+		}
+	}
+
+	// create, update or remove the NetworkPolicies of the cluster if their spec has changed
+	if !reflect.DeepEqual(oldcluster.Spec.NetworkPolicy, newcluster.Spec.NetworkPolicy) {
+		if err := clusteroperator.ReconcileNetworkPolicies(c.PgclusterClientset, newcluster); err != nil {
+			log.Error(err)
+		}
+	}
+
+	// likewise for the PodDisruptionBudgets of the cluster
+	if oldcluster.Spec.PodDisruptionBudget != newcluster.Spec.PodDisruptionBudget {
+		if err := clusteroperator.ReconcilePodDisruptionBudgets(c.PgclusterClientset, c.PgclusterClient,
+			newcluster); err != nil {
+			log.Error(err)
+		}
+	}
+
+	// set the custom labels and annotations of the cluster on its resources if they have changed
+	if !reflect.DeepEqual(oldcluster.Spec.Metadata, newcluster.Spec.Metadata) {
+		if err := clusteroperator.ReconcileCustomMetadata(c.PgclusterClientset, newcluster); err != nil {
+			log.Error(err)
+		}
+	}
+
+	// likewise, create, update or remove the PrometheusRule of the cluster if its alerts or the
+	// labels it shares with the monitor have changed, or the exporter was enabled or disabled
+	if !reflect.DeepEqual(oldcluster.Spec.Alerts, newcluster.Spec.Alerts) ||
+		!reflect.DeepEqual(oldcluster.Spec.Monitor.Labels, newcluster.Spec.Monitor.Labels) ||
+		operator.IsExporterEnabled(&oldcluster.Spec) != operator.IsExporterEnabled(&newcluster.Spec) {
+		if err := clusteroperator.ReconcileAlerts(c.PgclusterClientset, newcluster); err != nil {
+			log.Error(err)
+		}
+	}
+
 	// if we are not in a standby state, check to see if the tablespaces have
 	// differed, and if so, add the additional volumes to the primary and replicas
-	if !reflect.DeepEqual(oldcluster.Spec.TablespaceMounts, newcluster.Spec.TablespaceMounts) {
+	if !reflect.DeepEqual(oldcluster.Spec.GetTablespaces(), newcluster.Spec.GetTablespaces()) {
 		if err := updateTablespaces(c, oldcluster, newcluster); err != nil {
 			log.Error(err)
 			return
@@ -197,21 +710,85 @@ func (c *Controller) onUpdate(oldObj, newObj interface{}) {
 
 // onDelete is called when a pgcluster is deleted
 func (c *Controller) onDelete(obj interface{}) {
-	//cluster := obj.(*crv1.Pgcluster)
-	//	log.Debugf("[Controller] ns=%s onDelete %s", cluster.ObjectMeta.Namespace, cluster.ObjectMeta.SelfLink)
+	cluster, ok := obj.(*crv1.Pgcluster)
+	if !ok {
+		return
+	}
+	log.Debugf("[Controller] ns=%s onDelete %s", cluster.ObjectMeta.Namespace, cluster.ObjectMeta.SelfLink)
+
+	if key, err := cache.MetaNamespaceKeyFunc(cluster); err == nil {
+		c.releasePaused(key)
+	}
+	c.PauseGate.ForgetCluster(cluster.Name)
+
+	// the ExternalName Service may be in a namespace other than the one of the
+	// cluster, so it is not removed along with the other cluster resources. It is
+	// removed before the cluster goes by the service discovery finalizer, which
+	// this only backs up for a cluster that did not have the finalizer yet
+	if err := clusteroperator.DeleteServiceDiscovery(c.PgclusterClientset, cluster,
+		cluster.Spec.ServiceDiscovery); err != nil {
+		log.Error(err)
+	}
 
 	//handle pgcluster cleanup
 	//	clusteroperator.DeleteClusterBase(c.PgclusterClientset, c.PgclusterClient, cluster, cluster.ObjectMeta.Namespace)
 }
 
+// finalizeCluster removes the resources of a deleted cluster and then removes the cleanup
+// finalizer, which lets the pgcluster go. Until its resources are removed, the cluster is
+// queued again
+func (c *Controller) finalizeCluster(key string, cluster *crv1.Pgcluster) {
+	// the ExternalName Service is removed first. Removing its finalizer updates the cluster,
+	// which queues it again, so that the cleanup carries on from the updated cluster
+	if clusteroperator.HasServiceDiscoveryFinalizer(cluster) {
+		if err := clusteroperator.FinalizeServiceDiscovery(c.PgclusterClientset, c.PgclusterClient,
+			cluster.DeepCopy()); err != nil {
+			log.Errorf("service discovery: could not remove the service of cluster %s: %s",
+				cluster.Name, err)
+			c.Queue.AddRateLimited(key)
+			return
+		}
+		c.Queue.Forget(key)
+		return
+	}
+
+	if !clusteroperator.HasCleanupFinalizer(cluster) {
+		c.Queue.Forget(key)
+		return
+	}
+
+	done, err := clusteroperator.FinalizeCluster(c.PgclusterClientset, c.PgclusterClient, cluster)
+	if err != nil {
+		log.Errorf("cleanup: could not remove the resources of cluster %s: %s", cluster.Name, err)
+		c.Queue.AddRateLimited(key)
+		return
+	}
+
+	if !done {
+		c.Queue.AddAfter(key, finalizeRequeueDelay)
+		return
+	}
+
+	if err := clusteroperator.RemoveCleanupFinalizer(c.PgclusterClient, cluster.DeepCopy()); err != nil {
+		log.Errorf("cleanup: could not remove the finalizer of cluster %s: %s", cluster.Name, err)
+		c.Queue.AddRateLimited(key)
+		return
+	}
+
+	c.Queue.Forget(key)
+	log.Infof("cleanup: removed the resources of cluster %s", cluster.Name)
+}
+
 // AddPGClusterEventHandler adds the pgcluster event handler to the pgcluster informer
 func (c *Controller) AddPGClusterEventHandler() {
 
-	c.Informer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
-		AddFunc:    c.onAdd,
-		UpdateFunc: c.onUpdate,
-		DeleteFunc: c.onDelete,
-	})
+	c.Informer.Informer().AddEventHandler(c.PauseGate.EventHandler("pgcluster", nil,
+		operator.SafeEventHandler("pgcluster", nil,
+			cache.ResourceEventHandlerFuncs{
+				AddFunc:    c.onAdd,
+				UpdateFunc: c.onUpdate,
+				DeleteFunc: c.onDelete,
+			})))
 
 	log.Debugf("pgcluster Controller: added event handler to informer")
 }
@@ -225,6 +802,159 @@ func addIdentifier(clusterCopy *crv1.Pgcluster) {
 	clusterCopy.ObjectMeta.Labels[config.LABEL_PG_CLUSTER_IDENTIFIER] = string(u[:len(u)-1])
 }
 
+// reconcileNow reconciles a cluster that has a reconcile token set that it has not been
+// reconciled for yet. As the work of the controller is driven by what changed in a cluster, the
+// parts of the cluster that can be reconciled without disrupting it are reconciled as they are
+// now: whether it is shut down, its replica floor, service discovery, maintenance schedule,
+// connection logging, client certificate authentication, TLS certificates, pg_hba
+// rules, PostgreSQL parameters, chargeback labels, custom labels and annotations, pgBouncer,
+// monitor, alerts, network policies, pod disruption budgets, locale, time zone, health check,
+// synchronous replication, fencing and conditions. The token is then recorded in the status of the cluster, so that the
+// cluster is not reconciled again until the token changes
+func (c *Controller) reconcileNow(cluster *crv1.Pgcluster) {
+	token := cluster.Annotations[config.ANNOTATION_RECONCILE_NOW]
+
+	if token == "" || token == cluster.Status.ReconcileToken {
+		return
+	}
+
+	log.Infof("reconciling cluster %s for reconcile token %s", cluster.Name, token)
+
+	if cluster.Spec.Shutdown && cluster.Status.State != crv1.PgclusterStateShutdown {
+		if err := clusteroperator.ShutdownCluster(c.PgclusterClientset, c.PgclusterClient,
+			*cluster); err != nil {
+			log.Error(err)
+		}
+	} else if !cluster.Spec.Shutdown && cluster.Status.State == crv1.PgclusterStateShutdown {
+		if err := clusteroperator.StartupCluster(c.PgclusterClientset, *cluster); err != nil {
+			log.Error(err)
+		}
+	}
+
+	if err := clusteroperator.ReconcileReplicaFloor(c.PgclusterClientset, c.PgclusterClient,
+		cluster); err != nil {
+		log.Error(err)
+	}
+
+	if err := clusteroperator.UpdateServiceDiscovery(c.PgclusterClientset, c.PgclusterClient,
+		cluster); err != nil {
+		log.Error(err)
+	}
+
+	if err := clusteroperator.UpdateMaintenanceSchedule(c.PgclusterClientset, cluster,
+		cluster.Namespace); err != nil {
+		log.Error(err)
+	}
+
+	// the status of the cluster is patched along the way, so each reconcile works on a copy
+	if err := clusteroperator.ReconcileConnectionLogging(c.PgclusterClientset, c.PgclusterClient,
+		c.PgclusterConfig, cluster.DeepCopy()); err != nil {
+		log.Error(err)
+	}
+
+	if err := clusteroperator.ReconcileTLSClientAuth(c.PgclusterClientset, c.PgclusterClient,
+		c.PgclusterConfig, cluster); err != nil {
+		log.Error(err)
+	}
+
+	if err := clusteroperator.ReconcileTLSCertificates(c.PgclusterClientset, c.PgclusterClient,
+		cluster.DeepCopy()); err != nil {
+		log.Error(err)
+	}
+
+	if err := clusteroperator.ReconcileHBA(c.PgclusterClientset, cluster); err != nil {
+		log.Error(err)
+	}
+
+	if err := clusteroperator.ReconcilePostgresParams(c.PgclusterClientset, c.PgclusterClient,
+		c.PgclusterConfig, cluster.DeepCopy()); err != nil {
+		log.Error(err)
+	}
+
+	if err := clusteroperator.ReconcileChargebackLabels(c.PgclusterClientset, cluster); err != nil {
+		log.Error(err)
+	}
+
+	if err := clusteroperator.ReconcileCustomMetadata(c.PgclusterClientset, cluster); err != nil {
+		log.Error(err)
+	}
+
+	if cluster.Labels[config.LABEL_PGBOUNCER] == "true" {
+		if err := clusteroperator.ReconcilePgBouncer(c.PgclusterClientset, c.PgclusterConfig,
+			cluster); err != nil {
+			log.Error(err)
+		}
+	}
+
+	if cluster.Spec.Monitor.Kind != "" {
+		if err := clusteroperator.ReconcileMonitor(c.PgclusterClientset, cluster); err != nil {
+			log.Error(err)
+		}
+	}
+
+	if cluster.Spec.Alerts.Enabled {
+		if err := clusteroperator.ReconcileAlerts(c.PgclusterClientset, cluster); err != nil {
+			log.Error(err)
+		}
+	}
+
+	if cluster.Spec.NetworkPolicy.Enabled {
+		if err := clusteroperator.ReconcileNetworkPolicies(c.PgclusterClientset, cluster); err != nil {
+			log.Error(err)
+		}
+	}
+
+	if cluster.Spec.PodDisruptionBudget.Enabled {
+		if err := clusteroperator.ReconcilePodDisruptionBudgets(c.PgclusterClientset, c.PgclusterClient,
+			cluster); err != nil {
+			log.Error(err)
+		}
+	}
+
+	if err := clusteroperator.ReconcileLocale(c.PgclusterClientset, c.PgclusterClient,
+		cluster.DeepCopy()); err != nil {
+		log.Error(err)
+	}
+
+	if err := clusteroperator.ReconcileTimezone(c.PgclusterClientset, c.PgclusterConfig,
+		cluster); err != nil {
+		log.Error(err)
+	}
+
+	if cluster.Spec.HealthCheck.Query != "" {
+		if err := clusteroperator.ReconcileHealthCheck(c.PgclusterClientset, c.PgclusterClient,
+			c.PgclusterConfig, cluster.DeepCopy()); err != nil {
+			log.Error(err)
+		}
+	}
+
+	if operator.GetSyncReplication(cluster.Spec.SyncReplication) ||
+		!reflect.DeepEqual(cluster.Status.SyncReplication, crv1.SyncReplicationStatus{}) {
+		if err := clusteroperator.ReconcileSyncReplication(c.PgclusterClientset, c.PgclusterClient,
+			c.PgclusterConfig, cluster.DeepCopy()); err != nil {
+			log.Error(err)
+		}
+	}
+
+	if cluster.Spec.Fencing.Enabled || cluster.Status.Fencing != (crv1.FencingStatus{}) {
+		if err := clusteroperator.ReconcileFencing(c.PgclusterClientset, c.PgclusterClient,
+			c.PgclusterConfig, cluster.DeepCopy()); err != nil {
+			log.Error(err)
+		}
+	}
+
+	if err := clusteroperator.ReconcileConditions(c.PgclusterClientset, c.PgclusterClient,
+		cluster.DeepCopy()); err != nil {
+		log.Error(err)
+	}
+
+	if err := kubeapi.Patchpgcluster(c.PgclusterClient, cluster, cluster.Namespace, func(cluster *crv1.Pgcluster) {
+		cluster.Status.ReconcileToken = token
+	}); err != nil {
+		log.Errorf("could not record reconcile token of cluster %s: %s", cluster.Name, err)
+	}
+}
+
 // updateTablespaces updates the PostgreSQL instance Deployments to reflect the
 // new PostgreSQL tablespaces that should be added
 func updateTablespaces(c *Controller, oldCluster *crv1.Pgcluster, newCluster *crv1.Pgcluster) error {
@@ -235,22 +965,29 @@ func updateTablespaces(c *Controller, oldCluster *crv1.Pgcluster, newCluster *cr
 	// To do this, iterate through the the tablespace mount map that is present in
 	// the new cluster.
 	newTablespaces := map[string]crv1.PgStorageSpec{}
+	oldTablespaces := oldCluster.Spec.GetTablespaces()
 
-	for tablespaceName, storageSpec := range newCluster.Spec.TablespaceMounts {
+	for tablespaceName, storageSpec := range newCluster.Spec.GetTablespaces() {
 		// if the tablespace does not exist in the old version of the cluster,
 		// then add it in!
-		if _, ok := oldCluster.Spec.TablespaceMounts[tablespaceName]; !ok {
+		if _, ok := oldTablespaces[tablespaceName]; !ok {
 			log.Debugf("new tablespace found: [%s]", tablespaceName)
 
 			newTablespaces[tablespaceName] = storageSpec
 		}
 	}
 
-	// alright, update the tablespace entries for this cluster!
-	// if it returns an error, pass the error back up to the caller
-	if err := clusteroperator.UpdateTablespaces(c.PgclusterClientset, c.PgclusterConfig, newCluster, newTablespaces); err != nil {
-		return err
+	// the volumes of a tablespace are only created once, so a change to the storage of one
+	// that exists leaves the instances be
+	if len(newTablespaces) == 0 {
+		return nil
 	}
 
+	// alright, update the tablespace entries for this cluster! As this restarts the instances
+	// of the cluster, it is done within the restart budget
+	clusteroperator.RestartWithinBudget(c.PgclusterClientset, newCluster, "tablespaces", func() error {
+		return clusteroperator.UpdateTablespaces(c.PgclusterClientset, c.PgclusterConfig, newCluster, newTablespaces)
+	})
+
 	return nil
 }
@@ -0,0 +1,139 @@
+package pgdatabase
+
+/*
+Copyright 2020 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+import (
+	"reflect"
+	"time"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/controller"
+	"github.com/crunchydata/postgres-operator/kubeapi"
+	"github.com/crunchydata/postgres-operator/operator"
+	clusteroperator "github.com/crunchydata/postgres-operator/operator/cluster"
+	informers "github.com/crunchydata/postgres-operator/pkg/generated/informers/externalversions/crunchydata.com/v1"
+	log "github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+)
+
+// Controller holds connections for the controller
+type Controller struct {
+	PgdatabaseClient    *rest.RESTClient
+	PgdatabaseClientset *kubernetes.Clientset
+	PgdatabaseConfig    *rest.Config
+	Informer            informers.PgdatabaseInformer
+	// PauseGate holds back the controller while the namespace or the cluster it acts on is paused
+	PauseGate *controller.PauseGate
+}
+
+// onAdd is called when a pgdatabase is added
+func (c *Controller) onAdd(obj interface{}) {
+	database := obj.(*crv1.Pgdatabase)
+	log.Debugf("[pgdatabase Controller] onAdd ns=%s %s", database.ObjectMeta.Namespace, database.ObjectMeta.SelfLink)
+
+	c.sync(database)
+}
+
+// onUpdate is called when a pgdatabase is updated
+func (c *Controller) onUpdate(oldObj, newObj interface{}) {
+	oldDatabase := oldObj.(*crv1.Pgdatabase)
+	database := newObj.(*crv1.Pgdatabase)
+
+	// the status of a pgdatabase is set by the controller itself, so only a change of the spec
+	// or of the annotations, e.g. to have it reconciled again, is acted on
+	if !reflect.DeepEqual(oldDatabase.Spec, database.Spec) ||
+		!reflect.DeepEqual(oldDatabase.Annotations, database.Annotations) {
+		c.sync(database)
+	}
+}
+
+// onDelete is called when a pgdatabase is deleted. The database itself is kept, as dropping it
+// would take its data with it
+func (c *Controller) onDelete(obj interface{}) {
+	database, ok := obj.(*crv1.Pgdatabase)
+	if !ok {
+		return
+	}
+	log.Debugf("[pgdatabase Controller] onDelete ns=%s %s", database.ObjectMeta.Namespace, database.ObjectMeta.SelfLink)
+}
+
+// sync has the database of a pgdatabase match its spec, and records whether it does
+func (c *Controller) sync(database *crv1.Pgdatabase) {
+	if database.DeletionTimestamp != nil {
+		return
+	}
+
+	status := crv1.PgdatabaseStatus{}
+
+	if err := clusteroperator.ReconcilePgdatabase(c.PgdatabaseClientset, c.PgdatabaseClient,
+		c.PgdatabaseConfig, database); err != nil {
+		log.Errorf("could not reconcile pgdatabase %s: %s", database.Name, err)
+		status.State = crv1.PgdatabaseStateFailed
+		status.Message = err.Error()
+	} else {
+		status.State = crv1.PgdatabaseStateReconciled
+		status.LastReconciled = time.Now().UTC().Format(time.RFC3339)
+	}
+
+	// the pgdatabase is owned by the informer cache, so it is patched on a copy
+	if err := kubeapi.PatchpgdatabaseStatus(c.PgdatabaseClient, status, database.DeepCopy(),
+		database.Namespace); err != nil {
+		log.Errorf("could not update status of pgdatabase %s: %s", database.Name, err)
+	}
+}
+
+// RunPeriodic carries out the periodic work of the controller, which is retrying the pgdatabases
+// that could not be reconciled, e.g. as their cluster was not initialized yet or their owner did
+// not exist yet
+func (c *Controller) RunPeriodic() {
+	databases, err := c.Informer.Lister().List(labels.Everything())
+	if err != nil {
+		log.Error(err)
+		return
+	}
+
+	for _, database := range databases {
+		if database.Status.State == crv1.PgdatabaseStateFailed &&
+			!c.PauseGate.IsClusterPaused(database.Namespace, database.Spec.ClusterName) {
+			c.sync(database)
+		}
+	}
+}
+
+// clusterName returns the cluster of a pgdatabase, whose events are held while the cluster is paused
+func clusterName(obj interface{}) string {
+	if database, ok := obj.(*crv1.Pgdatabase); ok {
+		return database.Spec.ClusterName
+	}
+	return ""
+}
+
+// AddPGDatabaseEventHandler adds the pgdatabase event handler to the pgdatabase informer
+func (c *Controller) AddPGDatabaseEventHandler() {
+
+	c.Informer.Informer().AddEventHandler(c.PauseGate.EventHandler("pgdatabase", clusterName,
+		operator.SafeEventHandler("pgdatabase", nil,
+			cache.ResourceEventHandlerFuncs{
+				AddFunc:    c.onAdd,
+				UpdateFunc: c.onUpdate,
+				DeleteFunc: c.onDelete,
+			})))
+
+	log.Debugf("pgdatabase Controller: added event handler to informer")
+}
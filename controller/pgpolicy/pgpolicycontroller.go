@@ -19,7 +19,9 @@ import (
 	"time"
 
 	"github.com/crunchydata/postgres-operator/config"
+	"github.com/crunchydata/postgres-operator/controller"
 	"github.com/crunchydata/postgres-operator/kubeapi"
+	"github.com/crunchydata/postgres-operator/operator"
 	informers "github.com/crunchydata/postgres-operator/pkg/generated/informers/externalversions/crunchydata.com/v1"
 	log "github.com/sirupsen/logrus"
 	"k8s.io/client-go/kubernetes"
@@ -35,6 +37,8 @@ type Controller struct {
 	PgpolicyClient    *rest.RESTClient
 	PgpolicyClientset *kubernetes.Clientset
 	Informer          informers.PgpolicyInformer
+	// PauseGate holds back the controller while the namespace or the cluster it acts on is paused
+	PauseGate *controller.PauseGate
 }
 
 // onAdd is called when a pgpolicy is added
@@ -120,11 +124,13 @@ func (c *Controller) onDelete(obj interface{}) {
 // AddPGPolicyEventHandler adds the pgpolicy event handler to the pgpolicy informer
 func (c *Controller) AddPGPolicyEventHandler() {
 
-	c.Informer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
-		AddFunc:    c.onAdd,
-		UpdateFunc: c.onUpdate,
-		DeleteFunc: c.onDelete,
-	})
+	c.Informer.Informer().AddEventHandler(c.PauseGate.EventHandler("pgpolicy", controller.ClusterLabel,
+		operator.SafeEventHandler("pgpolicy", nil,
+			cache.ResourceEventHandlerFuncs{
+				AddFunc:    c.onAdd,
+				UpdateFunc: c.onUpdate,
+				DeleteFunc: c.onDelete,
+			})))
 
 	log.Debugf("pgpolicy Controller: added event handler to informer")
 }
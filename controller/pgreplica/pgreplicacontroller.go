@@ -20,10 +20,13 @@ import (
 
 	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
 	"github.com/crunchydata/postgres-operator/config"
+	"github.com/crunchydata/postgres-operator/controller"
 	"github.com/crunchydata/postgres-operator/kubeapi"
+	"github.com/crunchydata/postgres-operator/operator"
 	clusteroperator "github.com/crunchydata/postgres-operator/operator/cluster"
 	informers "github.com/crunchydata/postgres-operator/pkg/generated/informers/externalversions/crunchydata.com/v1"
 	log "github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/cache"
@@ -34,8 +37,13 @@ import (
 type Controller struct {
 	PgreplicaClient    *rest.RESTClient
 	PgreplicaClientset *kubernetes.Clientset
+	PgreplicaConfig    *rest.Config
 	Queue              workqueue.RateLimitingInterface
 	Informer           informers.PgreplicaInformer
+	// PauseGate holds back the controller while the namespace or the cluster it acts on is paused
+	PauseGate *controller.PauseGate
+	// WorkerCount is the number of workers that process the queue
+	WorkerCount int
 }
 
 func (c *Controller) RunWorker() {
@@ -45,6 +53,60 @@ func (c *Controller) RunWorker() {
 	}
 }
 
+// GetWorkerCount returns the number of workers that process the queue, which is at least one
+func (c *Controller) GetWorkerCount() int {
+	if c.WorkerCount < 1 {
+		return 1
+	}
+	return c.WorkerCount
+}
+
+// RunPeriodic carries out the periodic work of the controller, which is recording the node and the
+// availability zone that each processed replica runs on, as a replica may be rescheduled at any
+// time, following the resizes of the volumes of the replicas until they complete, and keeping
+// the minimum apply delay and the upstream of the replicas in place
+func (c *Controller) RunPeriodic() {
+	replicas, err := c.Informer.Lister().List(labels.Everything())
+	if err != nil {
+		log.Error(err)
+		return
+	}
+
+	// the replicas are owned by the informer cache, so work on copies of them
+	for _, replica := range replicas {
+		if replica.Status.State != crv1.PgreplicaStateProcessed || replica.DeletionTimestamp != nil ||
+			c.PauseGate.IsClusterPaused(replica.Namespace, replica.Spec.ClusterName) {
+			continue
+		}
+
+		if err := clusteroperator.ReconcileReplicaPlacement(c.PgreplicaClientset, c.PgreplicaClient,
+			replica.DeepCopy()); err != nil {
+			log.Errorf("could not record placement of replica %s: %s", replica.Name, err)
+		}
+
+		if replica.Status.ReplicaStorage.Resizing || replica.Status.ReplicaStorage.FileSystemResizePending {
+			if err := clusteroperator.ReconcileReplicaStorage(c.PgreplicaClientset, c.PgreplicaClient,
+				replica.DeepCopy()); err != nil {
+				log.Errorf("could not resize the volume of replica %s: %s", replica.Name, err)
+			}
+		}
+
+		if replica.Spec.RecoveryMinApplyDelay != "" || replica.Status.RecoveryMinApplyDelay != "" {
+			if err := clusteroperator.ReconcileReplicaDelay(c.PgreplicaClientset, c.PgreplicaClient,
+				c.PgreplicaConfig, replica.DeepCopy()); err != nil {
+				log.Errorf("could not delay replica %s: %s", replica.Name, err)
+			}
+		}
+
+		if replica.Spec.Upstream != "" || replica.Status.Upstream != "" {
+			if err := clusteroperator.ReconcileReplicaUpstream(c.PgreplicaClientset, c.PgreplicaClient,
+				c.PgreplicaConfig, replica.DeepCopy()); err != nil {
+				log.Errorf("could not stream replica %s from its upstream: %s", replica.Name, err)
+			}
+		}
+	}
+}
+
 func (c *Controller) processNextItem() bool {
 	// Wait until there is a new item in the working queue
 	key, quit := c.Queue.Get()
@@ -99,6 +161,8 @@ func (c *Controller) processNextItem() bool {
 		// only process pgreplica if cluster has been initialized
 		if cluster.Status.State == crv1.PgclusterStateInitialized {
 			clusteroperator.ScaleBase(c.PgreplicaClientset, c.PgreplicaClient, &replica, replica.ObjectMeta.Namespace)
+			operator.RecordObjectNormalEvent(c.PgreplicaClientset, operator.PgreplicaReference(&replica),
+				operator.EventReasonReplicaCreated, "created instance "+replica.Spec.Name)
 
 			state := crv1.PgreplicaStateProcessed
 			message := "Successfully processed Pgreplica by controller"
@@ -162,6 +226,8 @@ func (c *Controller) onUpdate(oldObj, newObj interface{}) {
 	if cluster.Status.State == crv1.PgclusterStateInitialized && newPgreplica.Spec.Status != "complete" {
 		clusteroperator.ScaleBase(c.PgreplicaClientset, c.PgreplicaClient, newPgreplica,
 			newPgreplica.ObjectMeta.Namespace)
+		operator.RecordObjectNormalEvent(c.PgreplicaClientset, operator.PgreplicaReference(newPgreplica),
+			operator.EventReasonReplicaCreated, "created instance "+newPgreplica.Spec.Name)
 
 		state := crv1.PgreplicaStateProcessed
 		message := "Successfully processed Pgreplica by controller"
@@ -171,6 +237,44 @@ func (c *Controller) onUpdate(oldObj, newObj interface{}) {
 			log.Errorf("ERROR updating pgreplica status: %s", err.Error())
 		}
 	}
+
+	// if the runtime class of the replica has changed, roll it out. As this restarts the
+	// replica, it is done within the restart budget
+	oldPgreplica := oldObj.(*crv1.Pgreplica)
+	if cluster.Status.State == crv1.PgclusterStateInitialized &&
+		oldPgreplica.Spec.RuntimeClassName != newPgreplica.Spec.RuntimeClassName {
+		clusteroperator.RestartWithinBudget(c.PgreplicaClientset, &cluster, "runtime class", func() error {
+			return clusteroperator.UpdateRuntimeClass(c.PgreplicaClientset, c.PgreplicaClient,
+				c.PgreplicaConfig, &cluster)
+		})
+	}
+
+	// if the storage size of the replica has changed, resize its volume
+	if newPgreplica.Status.State == crv1.PgreplicaStateProcessed &&
+		oldPgreplica.Spec.ReplicaStorage.Size != newPgreplica.Spec.ReplicaStorage.Size {
+		if err := clusteroperator.ReconcileReplicaStorage(c.PgreplicaClientset, c.PgreplicaClient,
+			newPgreplica.DeepCopy()); err != nil {
+			log.Error(err)
+		}
+	}
+
+	// if the minimum apply delay of the replica has changed, apply it
+	if newPgreplica.Status.State == crv1.PgreplicaStateProcessed &&
+		oldPgreplica.Spec.RecoveryMinApplyDelay != newPgreplica.Spec.RecoveryMinApplyDelay {
+		if err := clusteroperator.ReconcileReplicaDelay(c.PgreplicaClientset, c.PgreplicaClient,
+			c.PgreplicaConfig, newPgreplica.DeepCopy()); err != nil {
+			log.Error(err)
+		}
+	}
+
+	// if the upstream of the replica has changed, have it stream from the new one
+	if newPgreplica.Status.State == crv1.PgreplicaStateProcessed &&
+		oldPgreplica.Spec.Upstream != newPgreplica.Spec.Upstream {
+		if err := clusteroperator.ReconcileReplicaUpstream(c.PgreplicaClientset, c.PgreplicaClient,
+			c.PgreplicaConfig, newPgreplica.DeepCopy()); err != nil {
+			log.Error(err)
+		}
+	}
 }
 
 // onDelete is called when a pgreplica is deleted
@@ -188,6 +292,21 @@ func (c *Controller) onDelete(obj interface{}) {
 			log.Debugf("[pgreplica Controller] OnDelete not scaling down the replica since it is acting as a primary")
 		} else {
 			clusteroperator.ScaleDownBase(c.PgreplicaClientset, c.PgreplicaClient, replica, replica.ObjectMeta.Namespace)
+
+			// the pgreplica is gone, so the removal is told in the events of its cluster
+			cluster := crv1.Pgcluster{}
+			if found, _ := kubeapi.Getpgcluster(c.PgreplicaClient, &cluster, replica.Spec.ClusterName,
+				replica.ObjectMeta.Namespace); found {
+				operator.RecordNormalEvent(c.PgreplicaClientset, &cluster, operator.EventReasonReplicaRemoved,
+					"removed instance "+replica.Spec.Name)
+			}
+
+			// the replicas that streamed from the replica stream from elsewhere now
+			if err := clusteroperator.ReconcileDownstreamReplicas(c.PgreplicaClientset,
+				c.PgreplicaClient, c.PgreplicaConfig, replica.Spec.ClusterName, replica.Spec.Name,
+				replica.ObjectMeta.Namespace); err != nil {
+				log.Error(err)
+			}
 		}
 	}
 
@@ -197,11 +316,13 @@ func (c *Controller) onDelete(obj interface{}) {
 func (c *Controller) AddPGReplicaEventHandler() {
 
 	// Your custom resource event handlers.
-	c.Informer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
-		AddFunc:    c.onAdd,
-		UpdateFunc: c.onUpdate,
-		DeleteFunc: c.onDelete,
-	})
+	c.Informer.Informer().AddEventHandler(c.PauseGate.EventHandler("pgreplica", controller.ClusterLabel,
+		operator.SafeEventHandler("pgreplica", nil,
+			cache.ResourceEventHandlerFuncs{
+				AddFunc:    c.onAdd,
+				UpdateFunc: c.onUpdate,
+				DeleteFunc: c.onDelete,
+			})))
 
 	log.Debugf("pgreplica Controller: added event handler to informer")
 }
@@ -0,0 +1,165 @@
+package pgschedule
+
+/*
+Copyright 2020 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+import (
+	"time"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/controller"
+	"github.com/crunchydata/postgres-operator/kubeapi"
+	"github.com/crunchydata/postgres-operator/operator"
+	"github.com/crunchydata/postgres-operator/operator/backrest"
+	informers "github.com/crunchydata/postgres-operator/pkg/generated/informers/externalversions/crunchydata.com/v1"
+	log "github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+)
+
+// Controller holds connections for the controller
+type Controller struct {
+	PgscheduleClient    *rest.RESTClient
+	PgscheduleClientset *kubernetes.Clientset
+	Informer            informers.PgscheduleInformer
+	// PauseGate holds back the controller while the namespace or the cluster it acts on is paused
+	PauseGate *controller.PauseGate
+}
+
+// onAdd is called when a pgschedule is added
+func (c *Controller) onAdd(obj interface{}) {
+	schedule := obj.(*crv1.Pgschedule)
+	log.Debugf("[pgschedule Controller] onAdd ns=%s %s", schedule.ObjectMeta.Namespace, schedule.ObjectMeta.SelfLink)
+
+	c.recordValidation(schedule)
+}
+
+// onUpdate is called when a pgschedule is updated
+func (c *Controller) onUpdate(oldObj, newObj interface{}) {
+	oldSchedule := oldObj.(*crv1.Pgschedule)
+	schedule := newObj.(*crv1.Pgschedule)
+
+	if oldSchedule.Spec != schedule.Spec {
+		c.recordValidation(schedule)
+	}
+}
+
+// onDelete is called when a pgschedule is deleted
+func (c *Controller) onDelete(obj interface{}) {
+	schedule, ok := obj.(*crv1.Pgschedule)
+	if !ok {
+		return
+	}
+	log.Debugf("[pgschedule Controller] onDelete ns=%s %s", schedule.ObjectMeta.Namespace, schedule.ObjectMeta.SelfLink)
+}
+
+// recordValidation records on a pgschedule why it is invalid, or clears what was recorded once
+// it is valid again
+func (c *Controller) recordValidation(schedule *crv1.Pgschedule) {
+	message := ""
+	if err := backrest.ValidateSchedule(schedule); err != nil {
+		log.Errorf("pgschedule %s is invalid: %s", schedule.Name, err)
+		message = "invalid schedule: " + err.Error()
+	}
+
+	c.recordMessage(schedule, message)
+}
+
+// recordMessage records a message on the status of a pgschedule, if it is not already recorded
+func (c *Controller) recordMessage(schedule *crv1.Pgschedule, message string) {
+	if schedule.Status.Message == message {
+		return
+	}
+
+	// the pgschedule is owned by the informer cache, so it is patched on a copy
+	scheduleCopy := schedule.DeepCopy()
+	status := scheduleCopy.Status
+	status.Message = message
+
+	if err := kubeapi.PatchpgscheduleStatus(c.PgscheduleClient, status, scheduleCopy,
+		schedule.Namespace); err != nil {
+		log.Errorf("could not update status of pgschedule %s: %s", schedule.Name, err)
+	}
+}
+
+// RunPeriodic carries out the periodic work of the controller, which is taking the backups of the
+// pgschedules that are due. The schedules are evaluated on every period, so a backup is taken
+// within a period of when it is scheduled
+func (c *Controller) RunPeriodic() {
+	schedules, err := c.Informer.Lister().List(labels.Everything())
+	if err != nil {
+		log.Error(err)
+		return
+	}
+
+	now := time.Now()
+
+	for _, schedule := range schedules {
+		if schedule.Spec.Suspend || backrest.ValidateSchedule(schedule) != nil ||
+			c.PauseGate.IsClusterPaused(schedule.Namespace, schedule.Spec.ClusterName) {
+			continue
+		}
+
+		due, err := backrest.ScheduledBackupDue(schedule, now)
+		if err != nil {
+			log.Errorf("could not evaluate pgschedule %s: %s", schedule.Name, err)
+			continue
+		} else if !due {
+			continue
+		}
+
+		taskName, err := backrest.CreateScheduledBackup(c.PgscheduleClientset, c.PgscheduleClient, schedule)
+		if err != nil {
+			log.Errorf("could not take scheduled backup of pgschedule %s: %s", schedule.Name, err)
+			c.recordMessage(schedule, "could not take backup: "+err.Error())
+			continue
+		}
+
+		scheduleCopy := schedule.DeepCopy()
+		status := crv1.PgscheduleStatus{
+			LastScheduleTime: now.UTC().Format(time.RFC3339),
+			LastTask:         taskName,
+		}
+
+		if err := kubeapi.PatchpgscheduleStatus(c.PgscheduleClient, status, scheduleCopy,
+			schedule.Namespace); err != nil {
+			log.Errorf("could not update status of pgschedule %s: %s", schedule.Name, err)
+		}
+	}
+}
+
+// clusterName returns the cluster of a pgschedule, whose events are held while the cluster is paused
+func clusterName(obj interface{}) string {
+	if schedule, ok := obj.(*crv1.Pgschedule); ok {
+		return schedule.Spec.ClusterName
+	}
+	return ""
+}
+
+// AddPGScheduleEventHandler adds the pgschedule event handler to the pgschedule informer
+func (c *Controller) AddPGScheduleEventHandler() {
+
+	c.Informer.Informer().AddEventHandler(c.PauseGate.EventHandler("pgschedule", clusterName,
+		operator.SafeEventHandler("pgschedule", nil,
+			cache.ResourceEventHandlerFuncs{
+				AddFunc:    c.onAdd,
+				UpdateFunc: c.onUpdate,
+				DeleteFunc: c.onDelete,
+			})))
+
+	log.Debugf("pgschedule Controller: added event handler to informer")
+}
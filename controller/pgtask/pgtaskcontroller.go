@@ -16,23 +16,32 @@ limitations under the License.
 */
 
 import (
+	"fmt"
 	"strings"
+	"time"
 
 	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
 	"github.com/crunchydata/postgres-operator/config"
+	"github.com/crunchydata/postgres-operator/controller"
 	"github.com/crunchydata/postgres-operator/kubeapi"
+	"github.com/crunchydata/postgres-operator/operator"
 	backrestoperator "github.com/crunchydata/postgres-operator/operator/backrest"
 	clusteroperator "github.com/crunchydata/postgres-operator/operator/cluster"
 	pgdumpoperator "github.com/crunchydata/postgres-operator/operator/pgdump"
 	taskoperator "github.com/crunchydata/postgres-operator/operator/task"
 	informers "github.com/crunchydata/postgres-operator/pkg/generated/informers/externalversions/crunchydata.com/v1"
 	log "github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/util/workqueue"
 )
 
+// defaultIdempotencyWindow is how long after it completed a task keeps another
+// task with the same idempotency key from running, if no window is configured
+const defaultIdempotencyWindow = time.Hour
+
 // Controller holds connections for the controller
 type Controller struct {
 	PgtaskConfig    *rest.Config
@@ -40,6 +49,10 @@ type Controller struct {
 	PgtaskClientset *kubernetes.Clientset
 	Queue           workqueue.RateLimitingInterface
 	Informer        informers.PgtaskInformer
+	// PauseGate holds back the controller while the namespace or the cluster it acts on is paused
+	PauseGate *controller.PauseGate
+	// WorkerCount is the number of workers that process the queue
+	WorkerCount int
 }
 
 func (c *Controller) RunWorker() {
@@ -49,6 +62,14 @@ func (c *Controller) RunWorker() {
 	}
 }
 
+// GetWorkerCount returns the number of workers that process the queue, which is at least one
+func (c *Controller) GetWorkerCount() int {
+	if c.WorkerCount < 1 {
+		return 1
+	}
+	return c.WorkerCount
+}
+
 func (c *Controller) processNextItem() bool {
 	// Wait until there is a new item in the working queue
 	key, quit := c.Queue.Get()
@@ -70,25 +91,78 @@ func (c *Controller) processNextItem() bool {
 
 	tmpTask := crv1.Pgtask{}
 	found, err := kubeapi.Getpgtask(c.PgtaskClient, &tmpTask, keyResourceName, keyNamespace)
-	if !found {
+	if !found && operator.IsTransientError(err) {
+		log.Debugf("retrying pgtask %s after a transient error: %s", key, err.Error())
+		c.Queue.AddRateLimited(key)
+		return true
+	} else if !found {
 		log.Errorf("ERROR onAdd getting pgtask : %s", err.Error())
 		return false
 	}
 
-	//update pgtask
+	// do not run the task if it duplicates another task with the same idempotency key, and
+	// instead point to the existing task
+	if existing := c.getIdempotentTask(&tmpTask); existing != nil {
+		log.Infof("pgtask %s has the same idempotency key as pgtask %s and will not be run",
+			tmpTask.Name, existing.Name)
+
+		message := fmt.Sprintf("Duplicate of pgtask %s with idempotency key %s", existing.Name,
+			tmpTask.Spec.IdempotencyKey)
+		if err := kubeapi.PatchpgtaskPhase(c.PgtaskClient, crv1.PgtaskStateDuplicate,
+			crv1.PgtaskPhaseFailed, message, &tmpTask, keyNamespace); err != nil {
+			log.Errorf("ERROR onAdd updating pgtask status: %s", err.Error())
+			return false
+		}
+		operator.RecordObjectWarningEvent(c.PgtaskClientset, operator.PgtaskReference(&tmpTask),
+			operator.EventReasonTaskDuplicate, message)
+
+		return true
+	}
+
+	//update pgtask, which is running from here on unless it is a record the Operator keeps of a
+	//workflow or an automated failover
 	state := crv1.PgtaskStateProcessed
 	message := "Successfully processed Pgtask by controller"
-	err = kubeapi.PatchpgtaskStatus(c.PgtaskClient, state, message, &tmpTask, keyNamespace)
-	if err != nil {
+	if tmpTask.Spec.TaskType == crv1.PgtaskWorkflow || tmpTask.Spec.TaskType == crv1.PgtaskAutoFailover {
+		err = kubeapi.PatchpgtaskStatus(c.PgtaskClient, state, message, &tmpTask, keyNamespace)
+	} else {
+		err = kubeapi.PatchpgtaskPhase(c.PgtaskClient, state, crv1.PgtaskPhaseRunning, message,
+			&tmpTask, keyNamespace)
+	}
+	if err != nil && operator.IsTransientError(err) {
+		// e.g. a conflict with an update of the task by its submitter, which is gone by the
+		// time the task is tried again
+		log.Debugf("retrying pgtask %s after a transient error: %s", key, err.Error())
+		c.Queue.AddRateLimited(key)
+		return true
+	} else if err != nil {
 		log.Errorf("ERROR onAdd updating pgtask status: %s", err.Error())
 		return false
 	}
+	c.Queue.Forget(key)
+	operator.RecordObjectNormalEvent(c.PgtaskClientset, operator.PgtaskReference(&tmpTask),
+		operator.EventReasonTaskStarted, fmt.Sprintf("carrying out %s task", tmpTask.Spec.TaskType))
 
 	//process the incoming task
 	switch tmpTask.Spec.TaskType {
 	case crv1.PgtaskMinorUpgrade:
 		log.Debug("delete minor upgrade task added")
 		clusteroperator.AddUpgrade(c.PgtaskClientset, c.PgtaskClient, &tmpTask, keyNamespace)
+	case crv1.PgtaskMajorUpgrade:
+		log.Debug("major upgrade task added")
+		clusteroperator.AddMajorUpgrade(c.PgtaskClientset, c.PgtaskClient, c.PgtaskConfig, &tmpTask, keyNamespace)
+	case crv1.PgtaskEnableDataChecksums:
+		log.Debug("enable data checksums task added")
+		clusteroperator.AddEnableDataChecksums(c.PgtaskClientset, c.PgtaskClient, c.PgtaskConfig, &tmpTask, keyNamespace)
+	case crv1.PgtaskRollingRestart:
+		log.Debug("rolling restart task added")
+		clusteroperator.AddRollingRestart(c.PgtaskClientset, c.PgtaskClient, c.PgtaskConfig, &tmpTask, keyNamespace)
+	case crv1.PgtaskSwitchover:
+		log.Debug("switchover task added")
+		clusteroperator.AddSwitchover(c.PgtaskClientset, c.PgtaskClient, c.PgtaskConfig, &tmpTask, keyNamespace)
+	case crv1.PgtaskMaintenance:
+		log.Debug("maintenance task added")
+		clusteroperator.RunMaintenance(c.PgtaskClientset, c.PgtaskClient, c.PgtaskConfig, &tmpTask, keyNamespace)
 	case crv1.PgtaskDeletePgbouncer:
 		log.Debug("delete pgbouncer task added")
 		clusteroperator.DeletePgbouncerFromPgTask(c.PgtaskClientset, c.PgtaskClient, c.PgtaskConfig, &tmpTask)
@@ -116,12 +190,20 @@ func (c *Controller) processNextItem() bool {
 	case crv1.PgtaskDeleteBackups:
 		log.Debug("delete backups task added")
 		taskoperator.RemoveBackups(keyNamespace, c.PgtaskClientset, &tmpTask)
+		operator.UpdateTaskPhase(c.PgtaskClient, tmpTask.Name, keyNamespace, crv1.PgtaskPhaseSucceeded,
+			"backup jobs removed")
 	case crv1.PgtaskBackrest:
 		log.Debug("backrest task added")
-		backrestoperator.Backrest(keyNamespace, c.PgtaskClientset, &tmpTask)
+		backrestoperator.Backrest(keyNamespace, c.PgtaskClientset, c.PgtaskClient, &tmpTask)
 	case crv1.PgtaskBackrestRestore:
 		log.Debug("backrest restore task added")
 		backrestoperator.Restore(c.PgtaskClient, keyNamespace, c.PgtaskClientset, &tmpTask)
+	case crv1.PgtaskBackrestPITR:
+		log.Debug("backrest point-in-time recovery task added")
+		backrestoperator.AddPITRRestore(c.PgtaskClient, &tmpTask, keyNamespace)
+	case crv1.PgtaskPromoteStandby:
+		log.Debug("promote standby task added")
+		clusteroperator.PromoteStandby(c.PgtaskClientset, c.PgtaskClient, &tmpTask, keyNamespace)
 
 	case crv1.PgtaskpgDump:
 		log.Debug("pgDump task added")
@@ -147,13 +229,66 @@ func (c *Controller) processNextItem() bool {
 
 }
 
+// RunPeriodic carries out the periodic work of the controller, which is removing the tasks that
+// finished longer ago than the TTL of the tasks, if one is configured
+func (c *Controller) RunPeriodic() {
+	ttl := operator.GetTaskTTL()
+	if ttl <= 0 {
+		return
+	}
+
+	tasks, err := c.Informer.Lister().List(labels.Everything())
+	if err != nil {
+		log.Error(err)
+		return
+	}
+
+	now := time.Now()
+
+	for _, task := range tasks {
+		if !operator.IsTaskExpired(task, ttl, now) ||
+			c.PauseGate.IsClusterPaused(task.Namespace, task.Labels[config.LABEL_PG_CLUSTER]) {
+			continue
+		}
+
+		log.Debugf("removing pgtask %s, which finished more than %s ago", task.Name, ttl)
+
+		if err := kubeapi.Deletepgtask(c.PgtaskClient, task.Name, task.Namespace); err != nil {
+			log.Error(err)
+		}
+	}
+}
+
 // onAdd is called when a pgtask is added
 func (c *Controller) onAdd(obj interface{}) {
 	task := obj.(*crv1.Pgtask)
 
 	//handle the case of when the operator restarts, we do not want
-	//to process pgtasks already processed
-	if task.Status.State == crv1.PgtaskStateProcessed {
+	//to process pgtasks already processed, other than those that are
+	//waiting to be retried
+	if task.Status.Phase == crv1.PgtaskPhasePending && task.Status.Attempts > 0 {
+		c.onUpdate(&crv1.Pgtask{}, task)
+		return
+	}
+
+	// a rolling restart that was underway when the operator stopped is resumed
+	if task.Spec.TaskType == crv1.PgtaskRollingRestart &&
+		task.Spec.Status == crv1.PgtaskRollingRestartInProgress {
+		clusteroperator.AddRollingRestart(c.PgtaskClientset, c.PgtaskClient, c.PgtaskConfig,
+			task, task.Namespace)
+		return
+	}
+
+	// as is an "enable-data-checksums" pgtask that was in progress
+	if task.Spec.TaskType == crv1.PgtaskEnableDataChecksums &&
+		task.Spec.Status == crv1.PgtaskDataChecksumsInProgress {
+		clusteroperator.AddEnableDataChecksums(c.PgtaskClientset, c.PgtaskClient, c.PgtaskConfig,
+			task, task.Namespace)
+		return
+	}
+
+	if task.Status.State == crv1.PgtaskStateProcessed ||
+		task.Status.State == crv1.PgtaskStateDuplicate {
 		log.Debug("pgtask " + task.ObjectMeta.Name + " already processed")
 		return
 	}
@@ -166,24 +301,47 @@ func (c *Controller) onAdd(obj interface{}) {
 
 }
 
-// onUpdate is called when a pgtask is updated
+// onUpdate is called when a pgtask is updated. A task that is moved back into the Pending
+// phase, as it failed for a transient reason, is run again once its backoff has passed
 func (c *Controller) onUpdate(oldObj, newObj interface{}) {
-	//task := newObj.(*crv1.Pgtask)
-	//	log.Debugf("[Controller] onUpdate ns=%s %s", task.ObjectMeta.Namespace, task.ObjectMeta.SelfLink)
+	oldTask := oldObj.(*crv1.Pgtask)
+	task := newObj.(*crv1.Pgtask)
+
+	if task.Status.Phase != crv1.PgtaskPhasePending || oldTask.Status.Phase == crv1.PgtaskPhasePending {
+		return
+	}
+
+	key, err := cache.MetaNamespaceKeyFunc(newObj)
+	if err == nil {
+		backoff := operator.GetTaskRetryBackoff(task.Status.Attempts)
+		log.Debugf("task putting key in queue %s to retry in %s", key, backoff)
+		c.Queue.AddAfter(key, backoff)
+	}
 }
 
 // onDelete is called when a pgtask is deleted
 func (c *Controller) onDelete(obj interface{}) {
 }
 
+// classifyTask names the reconcile of a failover task "failover", so that a failover is not
+// held back by safe mode if it is exempt from it
+func classifyTask(oldObj, newObj interface{}) string {
+	if task, ok := newObj.(*crv1.Pgtask); ok && task.Spec.TaskType == crv1.PgtaskFailover {
+		return crv1.PgtaskFailover
+	}
+	return ""
+}
+
 // AddPGTaskEventHandler adds the pgtask event handler to the pgtask informer
 func (c *Controller) AddPGTaskEventHandler() {
 
-	c.Informer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
-		AddFunc:    c.onAdd,
-		UpdateFunc: c.onUpdate,
-		DeleteFunc: c.onDelete,
-	})
+	c.Informer.Informer().AddEventHandler(c.PauseGate.EventHandler("pgtask", controller.ClusterLabel,
+		operator.SafeEventHandler("pgtask", classifyTask,
+			cache.ResourceEventHandlerFuncs{
+				AddFunc:    c.onAdd,
+				UpdateFunc: c.onUpdate,
+				DeleteFunc: c.onDelete,
+			})))
 
 	log.Debugf("pgtask Controller: added event handler to informer")
 }
@@ -225,3 +383,77 @@ func dupeDeleteData(restClient *rest.RESTClient, task *crv1.Pgtask, ns string) b
 
 	return true
 }
+
+// getIdempotentTask returns the task with the same idempotency key as the task provided that keeps
+// it from running, i.e. a task that is still in progress, or that completed within the
+// idempotency window. Failed tasks, and tasks that were duplicates themselves, are ignored so
+// that a task can be retried with the same key
+func (c *Controller) getIdempotentTask(task *crv1.Pgtask) *crv1.Pgtask {
+	if task.Spec.IdempotencyKey == "" {
+		return nil
+	}
+
+	tasks, err := c.Informer.Lister().Pgtasks(task.Namespace).List(labels.Everything())
+	if err != nil {
+		log.Error(err)
+		return nil
+	}
+
+	window := defaultIdempotencyWindow
+	if seconds := operator.Pgo.Pgo.IdempotencyWindowSeconds; seconds > 0 {
+		window = time.Duration(seconds) * time.Second
+	}
+
+	for _, existing := range tasks {
+		if existing.Name == task.Name || existing.Spec.IdempotencyKey != task.Spec.IdempotencyKey ||
+			existing.Status.State == crv1.PgtaskStateDuplicate || isTaskFailed(existing) {
+			continue
+		}
+
+		// only tasks that were submitted before this one can be duplicated by it
+		if task.CreationTimestamp.Before(&existing.CreationTimestamp) {
+			continue
+		}
+
+		if !isTaskCompleted(existing) || time.Since(getTaskCompletionTime(existing)) < window {
+			return existing
+		}
+	}
+
+	return nil
+}
+
+// getTaskCompletionTime returns when a completed task completed. Tasks that do not record their
+// phase have no completion time, in which case the time they were submitted is returned
+func getTaskCompletionTime(task *crv1.Pgtask) time.Time {
+	if task.Status.CompletionTime != nil {
+		return task.Status.CompletionTime.Time
+	}
+
+	return task.CreationTimestamp.Time
+}
+
+// isTaskFailed determines whether or not the task has failed, based on its phase or, for the tasks
+// that do not record one, on its status
+func isTaskFailed(task *crv1.Pgtask) bool {
+	if task.Status.Phase == crv1.PgtaskPhaseFailed {
+		return true
+	}
+
+	switch task.Spec.Status {
+	case crv1.PgtaskMajorUpgradePrecheckFailed, crv1.PgtaskMajorUpgradeFailed,
+		crv1.PgtaskDataChecksumsFailed, crv1.PgtaskRollingRestartFailed, crv1.PgtaskSwitchoverFailed,
+		crv1.PgtaskPITRFailed, crv1.PgtaskPromoteStandbyFailed:
+		return true
+	}
+
+	return strings.HasPrefix(task.Spec.Status, crv1.JobErrorStatus)
+}
+
+// isTaskCompleted determines whether or not the task has completed based on its status
+func isTaskCompleted(task *crv1.Pgtask) bool {
+	return task.Status.Phase == crv1.PgtaskPhaseSucceeded ||
+		task.Spec.Status == crv1.CompletedStatus ||
+		task.Spec.Status == crv1.PgtaskWorkflowCompletedStatus ||
+		strings.HasPrefix(task.Spec.Status, crv1.JobCompletedStatus)
+}
@@ -0,0 +1,207 @@
+package pguser
+
+/*
+Copyright 2020 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+import (
+	"reflect"
+	"time"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/config"
+	"github.com/crunchydata/postgres-operator/controller"
+	"github.com/crunchydata/postgres-operator/kubeapi"
+	"github.com/crunchydata/postgres-operator/operator"
+	clusteroperator "github.com/crunchydata/postgres-operator/operator/cluster"
+	informers "github.com/crunchydata/postgres-operator/pkg/generated/informers/externalversions/crunchydata.com/v1"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+)
+
+// Controller holds connections for the controller
+type Controller struct {
+	PguserClient    *rest.RESTClient
+	PguserClientset *kubernetes.Clientset
+	PguserConfig    *rest.Config
+	CredentialStore operator.CredentialStore
+	Informer        informers.PguserInformer
+	// PauseGate holds back the controller while the namespace or the cluster it acts on is paused
+	PauseGate *controller.PauseGate
+}
+
+// onAdd is called when a pguser is added
+func (c *Controller) onAdd(obj interface{}) {
+	user := obj.(*crv1.Pguser)
+	log.Debugf("[pguser Controller] onAdd ns=%s %s", user.ObjectMeta.Namespace, user.ObjectMeta.SelfLink)
+
+	c.sync(user)
+}
+
+// onUpdate is called when a pguser is updated
+func (c *Controller) onUpdate(oldObj, newObj interface{}) {
+	oldUser := oldObj.(*crv1.Pguser)
+	user := newObj.(*crv1.Pguser)
+
+	// the status and the finalizers of a pguser are set by the controller itself, so only a
+	// change of the spec or of the annotations, e.g. to have a changed password applied, or a
+	// deletion is acted on
+	if !reflect.DeepEqual(oldUser.Spec, user.Spec) ||
+		!reflect.DeepEqual(oldUser.Annotations, user.Annotations) || user.DeletionTimestamp != nil {
+		c.sync(user)
+	}
+}
+
+// onDelete is called when a pguser is deleted
+func (c *Controller) onDelete(obj interface{}) {
+	user, ok := obj.(*crv1.Pguser)
+	if !ok {
+		return
+	}
+	log.Debugf("[pguser Controller] onDelete ns=%s %s", user.ObjectMeta.Namespace, user.ObjectMeta.SelfLink)
+}
+
+// sync has the role of a pguser match its spec, or drops the role once the pguser is deleted
+func (c *Controller) sync(user *crv1.Pguser) {
+	// the pguser is owned by the informer cache, so it is changed on a copy
+	user = user.DeepCopy()
+
+	if user.DeletionTimestamp != nil {
+		c.drop(user)
+		return
+	}
+
+	if err := clusteroperator.AddDropRoleFinalizer(c.PguserClient, user); err != nil {
+		log.Errorf("could not add drop role finalizer to pguser %s: %s", user.Name, err)
+		return
+	}
+
+	now := time.Now()
+	status := user.Status
+	rotated := false
+
+	if clusteroperator.IsPguserPasswordRotationDue(user, now) {
+		if err := clusteroperator.RotatePguserPassword(c.CredentialStore, user); err != nil {
+			log.Errorf("could not rotate password of pguser %s: %s", user.Name, err)
+			status.State = crv1.PguserStateFailed
+			status.Message = "could not rotate password: " + err.Error()
+			c.recordStatus(user, status)
+			return
+		}
+
+		status.LastPasswordRotation = now.UTC().Format(time.RFC3339)
+		status.PasswordRotateToken = user.Annotations[config.ANNOTATION_ROTATE_PASSWORD]
+		rotated = true
+	}
+
+	if err := clusteroperator.ReconcilePguser(c.PguserClientset, c.PguserClient, c.PguserConfig,
+		c.CredentialStore, user); err != nil {
+		log.Errorf("could not reconcile pguser %s: %s", user.Name, err)
+		status.State = crv1.PguserStateFailed
+		status.Message = err.Error()
+	} else {
+		status.State = crv1.PguserStateReconciled
+		status.Message = ""
+		status.Databases = clusteroperator.GetPguserDatabaseNames(user)
+		status.LastReconciled = now.UTC().Format(time.RFC3339)
+
+		if rotated {
+			operator.RecordObjectNormalEvent(c.PguserClientset, v1.ObjectReference{
+				APIVersion:      crv1.SchemeGroupVersion.String(),
+				Kind:            "Pguser",
+				Name:            user.Name,
+				Namespace:       user.Namespace,
+				UID:             user.UID,
+				ResourceVersion: user.ResourceVersion,
+			}, "PasswordRotated", "the password of the role was rotated")
+		}
+	}
+
+	c.recordStatus(user, status)
+}
+
+// recordStatus records the status of a pguser
+func (c *Controller) recordStatus(user *crv1.Pguser, status crv1.PguserStatus) {
+	if err := kubeapi.PatchpguserStatus(c.PguserClient, status, user, user.Namespace); err != nil {
+		log.Errorf("could not update status of pguser %s: %s", user.Name, err)
+	}
+}
+
+// drop drops the role of a pguser that is deleted, after which the pguser is let go
+func (c *Controller) drop(user *crv1.Pguser) {
+	if !clusteroperator.HasDropRoleFinalizer(user) {
+		return
+	}
+
+	if err := clusteroperator.DropPguser(c.PguserClientset, c.PguserClient, c.PguserConfig,
+		user); err != nil {
+		log.Errorf("could not drop role of pguser %s: %s", user.Name, err)
+		return
+	}
+
+	if err := clusteroperator.RemoveDropRoleFinalizer(c.PguserClient, user); err != nil {
+		log.Errorf("could not remove drop role finalizer from pguser %s: %s", user.Name, err)
+	}
+}
+
+// RunPeriodic carries out the periodic work of the controller, which is retrying the pgusers
+// that could not be reconciled, e.g. as their cluster was not initialized yet, and those whose
+// role could not be dropped when they were deleted, as well as rotating the passwords that are
+// due to be rotated
+func (c *Controller) RunPeriodic() {
+	users, err := c.Informer.Lister().List(labels.Everything())
+	if err != nil {
+		log.Error(err)
+		return
+	}
+
+	now := time.Now()
+
+	for _, user := range users {
+		if c.PauseGate.IsClusterPaused(user.Namespace, user.Spec.ClusterName) {
+			continue
+		}
+
+		if user.DeletionTimestamp != nil || user.Status.State == crv1.PguserStateFailed ||
+			clusteroperator.IsPguserPasswordRotationDue(user, now) {
+			c.sync(user)
+		}
+	}
+}
+
+// clusterName returns the cluster of a pguser, whose events are held while the cluster is paused
+func clusterName(obj interface{}) string {
+	if user, ok := obj.(*crv1.Pguser); ok {
+		return user.Spec.ClusterName
+	}
+	return ""
+}
+
+// AddPGUserEventHandler adds the pguser event handler to the pguser informer
+func (c *Controller) AddPGUserEventHandler() {
+
+	c.Informer.Informer().AddEventHandler(c.PauseGate.EventHandler("pguser", clusterName,
+		operator.SafeEventHandler("pguser", nil,
+			cache.ResourceEventHandlerFuncs{
+				AddFunc:    c.onAdd,
+				UpdateFunc: c.onUpdate,
+				DeleteFunc: c.onDelete,
+			})))
+
+	log.Debugf("pguser Controller: added event handler to informer")
+}
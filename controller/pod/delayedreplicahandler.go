@@ -0,0 +1,53 @@
+package pod
+
+/*
+Copyright 2020 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+import (
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/config"
+	clusteroperator "github.com/crunchydata/postgres-operator/operator/cluster"
+	apiv1 "k8s.io/api/core/v1"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// handleReplicaEndpointsUpdate keeps the Endpoints of the replica Service of a PG cluster in
+// line with its replicas while the Operator manages them, which it does as long as any replica
+// of the cluster is delayed, as well as those of the read-only Service of the cluster, if it has
+// one.  Any time a Pod of the cluster changes its role, becomes ready or unready, or gets a new
+// IP, the Endpoints are updated to the replicas that are not delayed.
+func (c *Controller) handleReplicaEndpointsUpdate(oldPod, newPod *apiv1.Pod,
+	cluster *crv1.Pgcluster) error {
+
+	if !isPostgresPod(newPod) {
+		return nil
+	}
+
+	if oldPod.ObjectMeta.Labels[config.LABEL_PGHA_ROLE] == newPod.ObjectMeta.Labels[config.LABEL_PGHA_ROLE] &&
+		oldPod.Status.PodIP == newPod.Status.PodIP && !isDBContainerReadinessChanged(oldPod, newPod) {
+		return nil
+	}
+
+	log.Debugf("Pod Controller: pod %s in namespace %s changed, updating replica endpoints",
+		newPod.Name, newPod.Namespace)
+
+	if err := clusteroperator.UpdateReplicaEndpoints(c.PodClientset, cluster.Name,
+		cluster.Namespace); err != nil {
+		return err
+	}
+
+	return clusteroperator.UpdateReadOnlyEndpoints(c.PodClientset, cluster)
+}
@@ -0,0 +1,79 @@
+package pod
+
+/*
+Copyright 2020 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+import (
+	"fmt"
+	"strings"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/kubeapi"
+	apiv1 "k8s.io/api/core/v1"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// podEvictedReason is the reason set in the status of a Pod by the kubelet when it evicts
+	// the Pod
+	podEvictedReason = "Evicted"
+	// ephemeralStorageEvictedMessage is the message set in the status of a pgcluster when one of
+	// its Pods has been evicted due to ephemeral storage pressure
+	ephemeralStorageEvictedMessage = "degraded: pod %s was evicted due to ephemeral storage pressure"
+	// ephemeralStorageEvictedPrefix is used to determine whether or not the message in the status
+	// of a pgcluster was set by the ephemeral storage eviction handler
+	ephemeralStorageEvictedPrefix = "degraded: pod "
+)
+
+// handleEphemeralStorageEviction is responsible for surfacing the eviction of a Pod of a PG
+// cluster due to ephemeral storage pressure.  Otherwise the eviction only shows up as a
+// replacement Pod, so instead the pgcluster status message is set to indicate that the cluster is
+// degraded, and then cleared once a PostgreSQL Pod of the cluster is ready again.
+func (c *Controller) handleEphemeralStorageEviction(oldPod, newPod *apiv1.Pod,
+	cluster *crv1.Pgcluster) error {
+
+	var message string
+
+	switch {
+	case !isEphemeralStorageEviction(oldPod) && isEphemeralStorageEviction(newPod):
+		log.Warnf("Pod Controller: pod %s in namespace %s was evicted due to ephemeral storage "+
+			"pressure", newPod.Name, newPod.Namespace)
+		message = fmt.Sprintf(ephemeralStorageEvictedMessage, newPod.Name)
+	case isPostgresPod(newPod) && isDBContainerBecomingReady(oldPod, newPod) &&
+		strings.HasPrefix(cluster.Status.Message, ephemeralStorageEvictedPrefix):
+		log.Debugf("Pod Controller: pod %s in namespace %s is ready, clearing ephemeral storage "+
+			"eviction", newPod.Name, newPod.Namespace)
+	default:
+		return nil
+	}
+
+	if err := kubeapi.PatchpgclusterStatus(c.PodClient, cluster.Status.State, message, cluster,
+		newPod.Namespace); err != nil {
+		log.Error(err)
+		return err
+	}
+
+	return nil
+}
+
+// isEphemeralStorageEviction determines whether or not the Pod provided has been evicted by the
+// kubelet due to ephemeral storage pressure, either on the node or as the result of exceeding its
+// ephemeral storage limit
+func isEphemeralStorageEviction(pod *apiv1.Pod) bool {
+	return pod.Status.Phase == apiv1.PodFailed && pod.Status.Reason == podEvictedReason &&
+		(strings.Contains(pod.Status.Message, "ephemeral") ||
+			strings.Contains(pod.Status.Message, "DiskPressure"))
+}
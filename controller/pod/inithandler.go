@@ -88,6 +88,62 @@ func (c *Controller) handleCommonInit(cluster *crv1.Pgcluster) error {
 	operator.UpdatePGHAConfigInitFlag(c.PodClientset, false, cluster.Name,
 		cluster.Namespace)
 
+	// publish the address of the primary, as its Service may have been recreated
+	if err := clusteroperator.UpdateServiceDiscovery(c.PodClientset, c.PodClient,
+		cluster); err != nil {
+		log.Error(err)
+	}
+
+	// ensure pgaudit is configured, e.g. when a cluster is created with audit logging enabled
+	if cluster.Spec.Audit.Enabled {
+		clusteroperator.UpdateAudit(c.PodClientset, c.PodConfig, cluster, crv1.AuditSpec{})
+	}
+
+	// ensure the users that authenticate with a client certificate have their pg_hba rules
+	if cluster.Spec.TLS.ClientAuth.UsesCertAuth() {
+		if err := clusteroperator.ReconcileTLSClientAuth(c.PodClientset, c.PodClient, c.PodConfig,
+			cluster); err != nil {
+			log.Error(err)
+		}
+	}
+
+	// apply the custom pg_hba rules of the cluster
+	if len(cluster.Spec.HBA.Rules) > 0 {
+		if err := clusteroperator.ReconcileHBA(c.PodClientset, cluster); err != nil {
+			log.Error(err)
+		}
+	}
+
+	// have Prometheus scrape the exporter of the cluster through the Prometheus Operator
+	if cluster.Spec.Monitor.Kind != "" {
+		if err := clusteroperator.ReconcileMonitor(c.PodClientset, cluster); err != nil {
+			log.Error(err)
+		}
+	}
+
+	// alert on the cluster through a PrometheusRule
+	if cluster.Spec.Alerts.Enabled {
+		if err := clusteroperator.ReconcileAlerts(c.PodClientset, cluster); err != nil {
+			log.Error(err)
+		}
+	}
+
+	// apply the time zone of the cluster, which, unlike its locale, is not set by initdb
+	if err := clusteroperator.ReconcileTimezone(c.PodClientset, c.PodConfig, cluster); err != nil {
+		log.Error(err)
+	}
+
+	// label the resources that were created along with the cluster with the chargeback labels
+	// of its Namespace, rather than waiting for the next periodic reconcile
+	if err := clusteroperator.ReconcileChargebackLabels(c.PodClientset, cluster); err != nil {
+		log.Error(err)
+	}
+
+	// and likewise with the custom labels and annotations of the cluster
+	if err := clusteroperator.ReconcileCustomMetadata(c.PodClientset, cluster); err != nil {
+		log.Error(err)
+	}
+
 	return nil
 }
 
@@ -119,6 +175,9 @@ func (c *Controller) handleRestoreInit(cluster *crv1.Pgcluster) error {
 	backrestoperator.CreateInitialBackup(c.PodClient, namespace,
 		clusterName, pods.Items[0].Name)
 
+	// the restored primary is ready, which completes a point-in-time recovery of the cluster
+	backrestoperator.CompletePITRRestore(c.PodClient, clusterName, namespace)
+
 	return nil
 }
 
@@ -20,7 +20,10 @@ import (
 
 	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
 	"github.com/crunchydata/postgres-operator/config"
+	"github.com/crunchydata/postgres-operator/controller"
 	"github.com/crunchydata/postgres-operator/kubeapi"
+	"github.com/crunchydata/postgres-operator/operator"
+	clusteroperator "github.com/crunchydata/postgres-operator/operator/cluster"
 
 	log "github.com/sirupsen/logrus"
 	apiv1 "k8s.io/api/core/v1"
@@ -36,6 +39,8 @@ type Controller struct {
 	PodClientset *kubernetes.Clientset
 	PodConfig    *rest.Config
 	Informer     coreinformers.PodInformer
+	// PauseGate holds back the controller while the namespace or the cluster it acts on is paused
+	PauseGate *controller.PauseGate
 }
 
 // onAdd is called when a pod is added
@@ -53,6 +58,11 @@ func (c *Controller) onAdd(obj interface{}) {
 	//handle the case when a pg database pod is added
 	if isPostgresPod(newPod) {
 		c.labelPostgresPodAndDeployment(newPod)
+
+		// a pod held behind the scheduling gate of the Operator is let go if there is room for it
+		if newPod.Annotations[config.ANNOTATION_SCHEDULING_GATE] == operator.OperatorSchedulingGate {
+			c.releaseSchedulingGates()
+		}
 		return
 	}
 }
@@ -114,6 +124,41 @@ func (c *Controller) onUpdate(oldObj, newObj interface{}) {
 		}
 	}
 
+	// If the cluster has a startup gate, reflect whether or not the pod is still waiting on it
+	// in the cluster status
+	if cluster.Spec.StartupGate != "" && isPostgresPod(newPod) {
+		if err := c.handleStartupGateUpdate(newPod, &cluster); err != nil {
+			log.Error(err)
+		}
+	}
+
+	// If the Pods of the cluster are held behind a scheduling gate, reflect whether or not the pod
+	// is still waiting on it in the cluster status
+	if operator.GetSchedulingGate(&cluster) != "" && isPostgresPod(newPod) {
+		if err := c.handleSchedulingGateUpdate(newPod, &cluster); err != nil {
+			log.Error(err)
+		}
+	}
+
+	// Reflect the eviction of any of the cluster's Pods due to ephemeral storage pressure in the
+	// cluster status, as this would otherwise be a silent Pod restart
+	if err := c.handleEphemeralStorageEviction(oldPod, newPod, &cluster); err != nil {
+		log.Error(err)
+	}
+
+	// Keep the number of ready replicas at or above the replica floor of the cluster, if set
+	if cluster.Spec.MinReadyReplicas > 0 {
+		if err := c.handleReplicaFloorUpdate(oldPod, newPod, &cluster); err != nil {
+			log.Error(err)
+		}
+	}
+
+	// Keep the replica and read-only Services of the cluster away from its delayed replicas, if
+	// it has any
+	if err := c.handleReplicaEndpointsUpdate(oldPod, newPod, &cluster); err != nil {
+		log.Error(err)
+	}
+
 	// For the following upgrade and cluster initialization scenarios we only care about updates
 	// where the database container within the pod is becoming ready.  We can therefore return
 	// at this point if this condition is false.
@@ -121,6 +166,10 @@ func (c *Controller) onUpdate(oldObj, newObj interface{}) {
 		return
 	}
 
+	// a pod that is ready frees its provisioning slot for the pods held behind the scheduling gate
+	// of the Operator
+	c.releaseSchedulingGates()
+
 	// First handle pod update as needed if the update was part of an ongoing upgrade
 	if cluster.Labels[config.LABEL_MINOR_UPGRADE] == config.LABEL_UPGRADE_IN_PROGRESS {
 		log.Debugf("Pod Controller: upgrade pod %s now ready, calling pod upgrade "+
@@ -152,16 +201,51 @@ func (c *Controller) onDelete(obj interface{}) {
 		log.Debugf("Pod Controller: onDelete skipping pod that is not crunchydata %s", pod.ObjectMeta.SelfLink)
 		return
 	}
+
+	// a pod that is gone frees its provisioning slot for the pods held behind the scheduling gate
+	// of the Operator
+	if isPostgresPod(pod) {
+		clusteroperator.FreeProvisioningSlot(pod)
+		c.releaseSchedulingGates()
+	}
+}
+
+// RunPeriodic carries out the periodic work of the controller, which is freeing the provisioning
+// slots of the pods that took too long to become ready and releasing the scheduling gate of the
+// Operator from the pods that are held behind it
+func (c *Controller) RunPeriodic() {
+	c.releaseSchedulingGates()
+}
+
+// classifyPod names the reconcile of a Pod that was promoted "failover", so that the recovery
+// from a failover is not held back by safe mode if it is exempt from it
+func classifyPod(oldObj, newObj interface{}) string {
+	oldPod, ok := oldObj.(*apiv1.Pod)
+	if !ok {
+		return ""
+	}
+
+	newPod, ok := newObj.(*apiv1.Pod)
+	if !ok {
+		return ""
+	}
+
+	if isPromotedPostgresPod(oldPod, newPod) || isPromotedStandby(oldPod, newPod) {
+		return "failover"
+	}
+	return ""
 }
 
 // AddPodEventHandler adds the pod event handler to the pod informer
 func (c *Controller) AddPodEventHandler() {
 
-	c.Informer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
-		AddFunc:    c.onAdd,
-		UpdateFunc: c.onUpdate,
-		DeleteFunc: c.onDelete,
-	})
+	c.Informer.Informer().AddEventHandler(c.PauseGate.EventHandler("pod", controller.ClusterLabel,
+		operator.SafeEventHandler("pod", classifyPod,
+			cache.ResourceEventHandlerFuncs{
+				AddFunc:    c.onAdd,
+				UpdateFunc: c.onUpdate,
+				DeleteFunc: c.onDelete,
+			})))
 
 	log.Debugf("Pod Controller: added event handler to informer")
 }
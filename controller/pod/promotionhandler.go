@@ -25,6 +25,7 @@ import (
 	"github.com/crunchydata/postgres-operator/config"
 	"github.com/crunchydata/postgres-operator/controller"
 	"github.com/crunchydata/postgres-operator/kubeapi"
+	"github.com/crunchydata/postgres-operator/operator"
 	"github.com/crunchydata/postgres-operator/operator/backrest"
 	clusteroperator "github.com/crunchydata/postgres-operator/operator/cluster"
 	log "github.com/sirupsen/logrus"
@@ -62,6 +63,17 @@ func (c *Controller) handlePostgresPodPromotion(newPod *apiv1.Pod, cluster crv1.
 	}
 
 	if cluster.Status.State == crv1.PgclusterStateInitialized {
+		operator.RecordNormalEvent(c.PodClientset, &cluster, operator.EventReasonFailoverPerformed,
+			fmt.Sprintf("instance %s was promoted to primary",
+				newPod.Labels[config.LABEL_DEPLOYMENT_NAME]))
+
+		// ramp up the connections to the new primary, so that its cold caches are not hit by
+		// the full traffic at once
+		if err := clusteroperator.StartTrafficRamp(c.PodClientset, c.PodClient, c.PodConfig,
+			&cluster, newPod); err != nil {
+			log.Error(err)
+		}
+
 		if err := cleanAndCreatePostFailoverBackup(c.PodClient, c.PodClientset,
 			cluster.Name, newPod.Namespace); err != nil {
 			log.Error(err)
@@ -87,6 +99,11 @@ func (c *Controller) handleStartupInit(cluster crv1.Pgcluster) error {
 	// now scale any replicas deployments to 1
 	clusteroperator.ScaleClusterDeployments(c.PodClientset, cluster, 1, false, true, false)
 
+	// and bring pgBouncer back now that there is a primary for it to connect to
+	if err := clusteroperator.StartupPgBouncer(c.PodClientset, c.PodConfig, &cluster); err != nil {
+		log.Error(err)
+	}
+
 	return nil
 }
 
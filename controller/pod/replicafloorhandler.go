@@ -0,0 +1,67 @@
+package pod
+
+/*
+Copyright 2020 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+import (
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	clusteroperator "github.com/crunchydata/postgres-operator/operator/cluster"
+	apiv1 "k8s.io/api/core/v1"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// handleReplicaFloorUpdate is responsible for keeping the number of ready replicas in a PG
+// cluster at or above the "minReadyReplicas" of the cluster.  Any time the database container of
+// a replica becomes ready or unready, e.g. because its node is being drained, the replicas of the
+// cluster are reconciled against the floor, adding or removing temporary replicas as needed.
+func (c *Controller) handleReplicaFloorUpdate(oldPod, newPod *apiv1.Pod,
+	cluster *crv1.Pgcluster) error {
+
+	if isPostgresPrimaryPod(newPod) || !isDBContainerReadinessChanged(oldPod, newPod) {
+		return nil
+	}
+
+	log.Debugf("Pod Controller: readiness of replica pod %s in namespace %s changed, reconciling "+
+		"replica floor", newPod.Name, newPod.Namespace)
+
+	return clusteroperator.ReconcileReplicaFloor(c.PodClientset, c.PodClient, cluster)
+}
+
+// isDBContainerReadinessChanged determines whether or not the database container of the Pod
+// has become either ready or unready, the latter including the Pod being deleted
+func isDBContainerReadinessChanged(oldPod, newPod *apiv1.Pod) bool {
+	if !isPostgresPod(newPod) {
+		return false
+	}
+
+	if oldPod.DeletionTimestamp == nil && newPod.DeletionTimestamp != nil {
+		return true
+	}
+
+	var oldReady, newReady bool
+	for _, v := range oldPod.Status.ContainerStatuses {
+		if v.Name == "database" {
+			oldReady = v.Ready
+		}
+	}
+	for _, v := range newPod.Status.ContainerStatuses {
+		if v.Name == "database" {
+			newReady = v.Ready
+		}
+	}
+
+	return oldReady != newReady
+}
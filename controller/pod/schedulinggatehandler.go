@@ -0,0 +1,91 @@
+package pod
+
+/*
+Copyright 2020 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+import (
+	"fmt"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/config"
+	"github.com/crunchydata/postgres-operator/kubeapi"
+	"github.com/crunchydata/postgres-operator/operator"
+	clusteroperator "github.com/crunchydata/postgres-operator/operator/cluster"
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// schedulingGateWaitingMessage is the message set in the status of a pgcluster while
+// one of its Pods is held behind the scheduling gate of the cluster
+const schedulingGateWaitingMessage = "waiting on scheduling gate %s"
+
+// handleSchedulingGateUpdate is responsible for reflecting whether or not the Pods of a PG cluster
+// are still held behind its scheduling gate.  Otherwise a gate that is not released would only
+// show up as a Pod that is stuck initializing, so instead the pgcluster status message is set to
+// indicate which gate the cluster is waiting on, and then cleared once the gate is released.
+func (c *Controller) handleSchedulingGateUpdate(newPod *apiv1.Pod, cluster *crv1.Pgcluster) error {
+
+	gate := operator.GetSchedulingGate(cluster)
+	message := fmt.Sprintf(schedulingGateWaitingMessage, gate)
+	waiting := newPod.Annotations[config.ANNOTATION_SCHEDULING_GATE] != ""
+
+	switch {
+	case waiting && cluster.Status.Message != message:
+		log.Debugf("Pod Controller: pod %s in namespace %s is waiting on scheduling gate %s",
+			newPod.Name, newPod.Namespace, gate)
+	case !waiting && cluster.Status.Message == message:
+		log.Debugf("Pod Controller: scheduling gate %s released for pod %s in namespace %s",
+			gate, newPod.Name, newPod.Namespace)
+		message = ""
+	default:
+		return nil
+	}
+
+	if err := kubeapi.PatchpgclusterStatus(c.PodClient, cluster.Status.State, message, cluster,
+		newPod.Namespace); err != nil {
+		log.Error(err)
+		return err
+	}
+
+	return nil
+}
+
+// releaseSchedulingGates releases the scheduling gate of the Operator from as many of the
+// PostgreSQL Pods of the namespace as it lets start at once, leaving out those of paused clusters
+func (c *Controller) releaseSchedulingGates() {
+	selector, err := labels.Parse(config.LABEL_PG_DATABASE)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+
+	pods, err := c.Informer.Lister().List(selector)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+
+	// the pods are owned by the informer cache, which the release only reads
+	unpaused := []*apiv1.Pod{}
+	for _, pod := range pods {
+		if !c.PauseGate.IsClusterPaused(pod.Namespace, pod.Labels[config.LABEL_PG_CLUSTER]) {
+			unpaused = append(unpaused, pod)
+		}
+	}
+
+	clusteroperator.ReleaseSchedulingGates(c.PodClientset, unpaused)
+}
@@ -0,0 +1,73 @@
+package pod
+
+/*
+Copyright 2020 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+import (
+	"fmt"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/kubeapi"
+	"github.com/crunchydata/postgres-operator/operator"
+	apiv1 "k8s.io/api/core/v1"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// startupGateWaitingMessage is the message set in the status of a pgcluster while
+// one of its Pods is waiting on the startup gate of the cluster
+const startupGateWaitingMessage = "waiting on %s"
+
+// handleStartupGateUpdate is responsible for reflecting whether or not the Pods of a PG cluster
+// with a startup gate are still being held back by the gate.  Otherwise a gate that is never
+// satisfied would only show up as a Pod that is stuck initializing, so instead the pgcluster
+// status message is set to indicate which gate the cluster is waiting on, and then cleared once
+// the gate has been satisfied.
+func (c *Controller) handleStartupGateUpdate(newPod *apiv1.Pod, cluster *crv1.Pgcluster) error {
+
+	message := fmt.Sprintf(startupGateWaitingMessage, cluster.Spec.StartupGate)
+	waiting := isWaitingOnStartupGate(newPod)
+
+	switch {
+	case waiting && cluster.Status.Message != message:
+		log.Debugf("Pod Controller: pod %s in namespace %s is waiting on startup gate %s",
+			newPod.Name, newPod.Namespace, cluster.Spec.StartupGate)
+	case !waiting && cluster.Status.Message == message:
+		log.Debugf("Pod Controller: startup gate %s satisfied for pod %s in namespace %s",
+			cluster.Spec.StartupGate, newPod.Name, newPod.Namespace)
+		message = ""
+	default:
+		return nil
+	}
+
+	if err := kubeapi.PatchpgclusterStatus(c.PodClient, cluster.Status.State, message, cluster,
+		newPod.Namespace); err != nil {
+		log.Error(err)
+		return err
+	}
+
+	return nil
+}
+
+// isWaitingOnStartupGate determines whether or not the startup gate init container of the Pod
+// provided has yet to complete, i.e. whether or not the Pod is still waiting on the gate
+func isWaitingOnStartupGate(pod *apiv1.Pod) bool {
+	for _, status := range pod.Status.InitContainerStatuses {
+		if status.Name == operator.StartupGateInitContainerName {
+			return status.State.Terminated == nil
+		}
+	}
+	return false
+}
@@ -18,12 +18,18 @@ $PGO_CMD --namespace=$PGO_OPERATOR_NAMESPACE delete pgreplicas --all
 $PGO_CMD --namespace=$PGO_OPERATOR_NAMESPACE delete pgclusters --all
 $PGO_CMD --namespace=$PGO_OPERATOR_NAMESPACE delete pgpolicies --all
 $PGO_CMD --namespace=$PGO_OPERATOR_NAMESPACE delete pgtasks --all
+$PGO_CMD --namespace=$PGO_OPERATOR_NAMESPACE delete pgschedules --all
+$PGO_CMD --namespace=$PGO_OPERATOR_NAMESPACE delete pgusers --all
+$PGO_CMD --namespace=$PGO_OPERATOR_NAMESPACE delete pgdatabases --all
 
 $PGO_CMD --namespace=$PGO_OPERATOR_NAMESPACE delete crd \
 	pgreplicas.crunchydata.com \
 	pgclusters.crunchydata.com \
 	pgpolicies.crunchydata.com \
-	pgtasks.crunchydata.com
+	pgtasks.crunchydata.com \
+	pgschedules.crunchydata.com \
+	pgusers.crunchydata.com \
+	pgdatabases.crunchydata.com
 
 $PGO_CMD --namespace=$PGO_OPERATOR_NAMESPACE delete jobs --selector=pgrmdata=true
 $PGO_CMD --namespace=$PGO_OPERATOR_NAMESPACE delete jobs --selector=pgo-load=true
@@ -18,3 +18,6 @@ $PGO_CMD --namespace=$PGO_OPERATOR_NAMESPACE get pgclusters
 $PGO_CMD --namespace=$PGO_OPERATOR_NAMESPACE get pgreplicas
 $PGO_CMD --namespace=$PGO_OPERATOR_NAMESPACE get pgpolicies
 $PGO_CMD --namespace=$PGO_OPERATOR_NAMESPACE get pgpolicylogs
+$PGO_CMD --namespace=$PGO_OPERATOR_NAMESPACE get pgschedules
+$PGO_CMD --namespace=$PGO_OPERATOR_NAMESPACE get pgusers
+$PGO_CMD --namespace=$PGO_OPERATOR_NAMESPACE get pgdatabases
@@ -0,0 +1,165 @@
+package kubeapi
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"encoding/json"
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// CertificateAPIVersion is the API version of the cert-manager Certificates
+	CertificateAPIVersion = "cert-manager.io/v1"
+	// CertificateKind is the kind of the cert-manager Certificates
+	CertificateKind = "Certificate"
+
+	// certificatePath is the path of the cert-manager Certificates in a
+	// namespace. cert-manager is not a dependency of the Operator, so the
+	// Certificates are managed through the REST API directly
+	certificatePath = "/apis/cert-manager.io/v1/namespaces/%s/certificates"
+)
+
+// Certificate is a cert-manager Certificate, limited to the fields that the
+// Operator sets and reads
+type Certificate struct {
+	meta_v1.TypeMeta   `json:",inline"`
+	meta_v1.ObjectMeta `json:"metadata"`
+	Spec               CertificateSpec `json:"spec"`
+}
+
+// CertificateSpec is the spec of a cert-manager Certificate
+type CertificateSpec struct {
+	SecretName  string               `json:"secretName"`
+	CommonName  string               `json:"commonName,omitempty"`
+	DNSNames    []string             `json:"dnsNames,omitempty"`
+	Duration    string               `json:"duration,omitempty"`
+	RenewBefore string               `json:"renewBefore,omitempty"`
+	Usages      []string             `json:"usages,omitempty"`
+	IssuerRef   CertificateIssuerRef `json:"issuerRef"`
+	// SecretTemplate has the labels that the Secret of the Certificate is
+	// created with
+	SecretTemplate *CertificateSecretTemplate `json:"secretTemplate,omitempty"`
+}
+
+// CertificateSecretTemplate contains the metadata of the Secret of a
+// Certificate
+type CertificateSecretTemplate struct {
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// CertificateIssuerRef references the issuer that signs a Certificate
+type CertificateIssuerRef struct {
+	Name  string `json:"name"`
+	Kind  string `json:"kind,omitempty"`
+	Group string `json:"group,omitempty"`
+}
+
+// GetCertificate gets a cert-manager Certificate by name
+func GetCertificate(clientset *kubernetes.Clientset, name, namespace string) (*Certificate, bool, error) {
+	certificate := &Certificate{}
+
+	body, err := clientset.CoreV1().RESTClient().Get().
+		AbsPath(fmt.Sprintf(certificatePath, namespace), name).
+		Do().
+		Raw()
+	if kerrors.IsNotFound(err) {
+		log.Debugf("certificate %s not found", name)
+		return certificate, false, err
+	}
+	if err != nil {
+		log.Error(err)
+		log.Error("error getting certificate " + name)
+		return certificate, false, err
+	}
+
+	if err := json.Unmarshal(body, certificate); err != nil {
+		return certificate, false, err
+	}
+
+	return certificate, true, nil
+}
+
+// CreateCertificate creates a cert-manager Certificate
+func CreateCertificate(clientset *kubernetes.Clientset, certificate *Certificate, namespace string) error {
+	certificate.APIVersion = CertificateAPIVersion
+	certificate.Kind = CertificateKind
+
+	body, err := json.Marshal(certificate)
+	if err != nil {
+		return err
+	}
+
+	if err := clientset.CoreV1().RESTClient().Post().
+		AbsPath(fmt.Sprintf(certificatePath, namespace)).
+		SetHeader("Content-Type", "application/json").
+		Body(body).
+		Do().
+		Error(); err != nil {
+		log.Error(err)
+		log.Error("error creating certificate " + certificate.Name)
+		return err
+	}
+
+	log.Debugf("created certificate %s", certificate.Name)
+
+	return nil
+}
+
+// UpdateCertificate updates a cert-manager Certificate
+func UpdateCertificate(clientset *kubernetes.Clientset, certificate *Certificate, namespace string) error {
+	certificate.APIVersion = CertificateAPIVersion
+	certificate.Kind = CertificateKind
+
+	body, err := json.Marshal(certificate)
+	if err != nil {
+		return err
+	}
+
+	if err := clientset.CoreV1().RESTClient().Put().
+		AbsPath(fmt.Sprintf(certificatePath, namespace), certificate.Name).
+		SetHeader("Content-Type", "application/json").
+		Body(body).
+		Do().
+		Error(); err != nil {
+		log.Error(err)
+		log.Error("error updating certificate " + certificate.Name)
+		return err
+	}
+
+	return nil
+}
+
+// DeleteCertificate deletes a cert-manager Certificate. The Secret it was
+// issued into is left as is
+func DeleteCertificate(clientset *kubernetes.Clientset, name, namespace string) error {
+	err := clientset.CoreV1().RESTClient().Delete().
+		AbsPath(fmt.Sprintf(certificatePath, namespace), name).
+		Do().
+		Error()
+	if err != nil {
+		log.Error(err)
+		log.Error("error deleting certificate " + name)
+	} else {
+		log.Debugf("deleted certificate %s", name)
+	}
+
+	return err
+}
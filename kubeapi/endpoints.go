@@ -67,3 +67,25 @@ func GetEndpoint(request *GetEndpointRequest) (*GetEndpointResponse, error) {
 
 	return response, nil
 }
+
+// CreateEndpoints creates the Endpoints of a Service that has no selector
+func CreateEndpoints(clientset *kubernetes.Clientset, endpoints *v1.Endpoints, namespace string) error {
+	if _, err := clientset.CoreV1().Endpoints(namespace).Create(endpoints); err != nil {
+		log.Error(err)
+		log.Errorf("error creating endpoints %s", endpoints.Name)
+		return err
+	}
+
+	return nil
+}
+
+// UpdateEndpoints updates the Endpoints of a Service that has no selector
+func UpdateEndpoints(clientset *kubernetes.Clientset, endpoints *v1.Endpoints, namespace string) error {
+	if _, err := clientset.CoreV1().Endpoints(namespace).Update(endpoints); err != nil {
+		log.Error(err)
+		log.Errorf("error updating endpoints %s", endpoints.Name)
+		return err
+	}
+
+	return nil
+}
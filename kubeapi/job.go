@@ -145,3 +145,13 @@ func IsJobDeleted(client *kubernetes.Clientset, namespace string, job *v1batch.J
 		}
 	}
 }
+
+// UpdateJob updates a Job
+func UpdateJob(clientset *kubernetes.Clientset, job *v1batch.Job, namespace string) error {
+	_, err := clientset.BatchV1().Jobs(namespace).Update(job)
+	if err != nil {
+		log.Error(err)
+		log.Errorf("error updating job %s", job.Name)
+	}
+	return err
+}
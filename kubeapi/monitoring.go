@@ -0,0 +1,313 @@
+package kubeapi
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"encoding/json"
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// MonitoringAPIVersion is the API version of the Prometheus Operator resources
+	MonitoringAPIVersion = "monitoring.coreos.com/v1"
+	// ServiceMonitorKind is the kind of the Prometheus Operator ServiceMonitors
+	ServiceMonitorKind = "ServiceMonitor"
+	// PodMonitorKind is the kind of the Prometheus Operator PodMonitors
+	PodMonitorKind = "PodMonitor"
+	// PrometheusRuleKind is the kind of the Prometheus Operator PrometheusRules
+	PrometheusRuleKind = "PrometheusRule"
+
+	// monitoringPath is the path of a kind of Prometheus Operator resources in a
+	// namespace. The Prometheus Operator is not a dependency of the Operator, so
+	// its resources are managed through the REST API directly
+	monitoringPath = "/apis/monitoring.coreos.com/v1/namespaces/%s/%s"
+
+	// the resources of the kinds of Prometheus Operator resources
+	serviceMonitorResource = "servicemonitors"
+	podMonitorResource     = "podmonitors"
+	prometheusRuleResource = "prometheusrules"
+)
+
+// ServiceMonitor is a Prometheus Operator ServiceMonitor, limited to the
+// fields that the Operator sets and reads
+type ServiceMonitor struct {
+	meta_v1.TypeMeta   `json:",inline"`
+	meta_v1.ObjectMeta `json:"metadata"`
+	Spec               ServiceMonitorSpec `json:"spec"`
+}
+
+// ServiceMonitorSpec is the spec of a ServiceMonitor, which has Prometheus
+// scrape the endpoints of the Services it selects
+type ServiceMonitorSpec struct {
+	Selector  meta_v1.LabelSelector `json:"selector"`
+	Endpoints []MonitorEndpoint     `json:"endpoints"`
+}
+
+// PodMonitor is a Prometheus Operator PodMonitor, limited to the fields that
+// the Operator sets and reads
+type PodMonitor struct {
+	meta_v1.TypeMeta   `json:",inline"`
+	meta_v1.ObjectMeta `json:"metadata"`
+	Spec               PodMonitorSpec `json:"spec"`
+}
+
+// PodMonitorSpec is the spec of a PodMonitor, which has Prometheus scrape the
+// Pods it selects
+type PodMonitorSpec struct {
+	Selector            meta_v1.LabelSelector `json:"selector"`
+	PodMetricsEndpoints []MonitorEndpoint     `json:"podMetricsEndpoints"`
+}
+
+// MonitorEndpoint is a named port that Prometheus scrapes, which is alike for
+// ServiceMonitors and PodMonitors
+type MonitorEndpoint struct {
+	Port        string                 `json:"port"`
+	Path        string                 `json:"path,omitempty"`
+	Interval    string                 `json:"interval,omitempty"`
+	Scheme      string                 `json:"scheme,omitempty"`
+	TLSConfig   *MonitorTLSConfig      `json:"tlsConfig,omitempty"`
+	Relabelings []MonitorRelabelConfig `json:"relabelings,omitempty"`
+}
+
+// MonitorTLSConfig is how Prometheus verifies the endpoint it scrapes over
+// HTTPS
+type MonitorTLSConfig struct {
+	CA                 *MonitorSecretOrConfigMap `json:"ca,omitempty"`
+	ServerName         string                    `json:"serverName,omitempty"`
+	InsecureSkipVerify bool                      `json:"insecureSkipVerify,omitempty"`
+}
+
+// MonitorSecretOrConfigMap references the key of a Secret that Prometheus
+// reads, e.g. a CA
+type MonitorSecretOrConfigMap struct {
+	Secret *v1.SecretKeySelector `json:"secret,omitempty"`
+}
+
+// MonitorRelabelConfig rewrites the labels of the metrics that Prometheus
+// scrapes from an endpoint
+type MonitorRelabelConfig struct {
+	SourceLabels []string `json:"sourceLabels,omitempty"`
+	TargetLabel  string   `json:"targetLabel,omitempty"`
+	Replacement  string   `json:"replacement,omitempty"`
+}
+
+// PrometheusRule is a Prometheus Operator PrometheusRule, limited to the
+// fields that the Operator sets and reads
+type PrometheusRule struct {
+	meta_v1.TypeMeta   `json:",inline"`
+	meta_v1.ObjectMeta `json:"metadata"`
+	Spec               PrometheusRuleSpec `json:"spec"`
+}
+
+// PrometheusRuleSpec is the spec of a PrometheusRule, i.e. the groups of rules
+// that Prometheus evaluates
+type PrometheusRuleSpec struct {
+	Groups []PrometheusRuleGroup `json:"groups"`
+}
+
+// PrometheusRuleGroup is a group of rules that are evaluated together
+type PrometheusRuleGroup struct {
+	Name  string               `json:"name"`
+	Rules []PrometheusRuleRule `json:"rules"`
+}
+
+// PrometheusRuleRule is an alerting rule of a PrometheusRule
+type PrometheusRuleRule struct {
+	Alert       string            `json:"alert"`
+	Expr        string            `json:"expr"`
+	For         string            `json:"for,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// GetServiceMonitor gets a ServiceMonitor by name
+func GetServiceMonitor(clientset *kubernetes.Clientset, name, namespace string) (*ServiceMonitor, bool, error) {
+	monitor := &ServiceMonitor{}
+	found, err := getMonitoringResource(clientset, serviceMonitorResource, name, namespace, monitor)
+
+	return monitor, found, err
+}
+
+// CreateServiceMonitor creates a ServiceMonitor
+func CreateServiceMonitor(clientset *kubernetes.Clientset, monitor *ServiceMonitor, namespace string) error {
+	monitor.APIVersion = MonitoringAPIVersion
+	monitor.Kind = ServiceMonitorKind
+
+	return createMonitoringResource(clientset, serviceMonitorResource, monitor.Name, namespace, monitor)
+}
+
+// UpdateServiceMonitor updates a ServiceMonitor
+func UpdateServiceMonitor(clientset *kubernetes.Clientset, monitor *ServiceMonitor, namespace string) error {
+	monitor.APIVersion = MonitoringAPIVersion
+	monitor.Kind = ServiceMonitorKind
+
+	return updateMonitoringResource(clientset, serviceMonitorResource, monitor.Name, namespace, monitor)
+}
+
+// DeleteServiceMonitor deletes a ServiceMonitor
+func DeleteServiceMonitor(clientset *kubernetes.Clientset, name, namespace string) error {
+	return deleteMonitoringResource(clientset, serviceMonitorResource, name, namespace)
+}
+
+// GetPodMonitor gets a PodMonitor by name
+func GetPodMonitor(clientset *kubernetes.Clientset, name, namespace string) (*PodMonitor, bool, error) {
+	monitor := &PodMonitor{}
+	found, err := getMonitoringResource(clientset, podMonitorResource, name, namespace, monitor)
+
+	return monitor, found, err
+}
+
+// CreatePodMonitor creates a PodMonitor
+func CreatePodMonitor(clientset *kubernetes.Clientset, monitor *PodMonitor, namespace string) error {
+	monitor.APIVersion = MonitoringAPIVersion
+	monitor.Kind = PodMonitorKind
+
+	return createMonitoringResource(clientset, podMonitorResource, monitor.Name, namespace, monitor)
+}
+
+// UpdatePodMonitor updates a PodMonitor
+func UpdatePodMonitor(clientset *kubernetes.Clientset, monitor *PodMonitor, namespace string) error {
+	monitor.APIVersion = MonitoringAPIVersion
+	monitor.Kind = PodMonitorKind
+
+	return updateMonitoringResource(clientset, podMonitorResource, monitor.Name, namespace, monitor)
+}
+
+// DeletePodMonitor deletes a PodMonitor
+func DeletePodMonitor(clientset *kubernetes.Clientset, name, namespace string) error {
+	return deleteMonitoringResource(clientset, podMonitorResource, name, namespace)
+}
+
+// GetPrometheusRule gets a PrometheusRule by name
+func GetPrometheusRule(clientset *kubernetes.Clientset, name, namespace string) (*PrometheusRule, bool, error) {
+	rule := &PrometheusRule{}
+	found, err := getMonitoringResource(clientset, prometheusRuleResource, name, namespace, rule)
+
+	return rule, found, err
+}
+
+// CreatePrometheusRule creates a PrometheusRule
+func CreatePrometheusRule(clientset *kubernetes.Clientset, rule *PrometheusRule, namespace string) error {
+	rule.APIVersion = MonitoringAPIVersion
+	rule.Kind = PrometheusRuleKind
+
+	return createMonitoringResource(clientset, prometheusRuleResource, rule.Name, namespace, rule)
+}
+
+// UpdatePrometheusRule updates a PrometheusRule
+func UpdatePrometheusRule(clientset *kubernetes.Clientset, rule *PrometheusRule, namespace string) error {
+	rule.APIVersion = MonitoringAPIVersion
+	rule.Kind = PrometheusRuleKind
+
+	return updateMonitoringResource(clientset, prometheusRuleResource, rule.Name, namespace, rule)
+}
+
+// DeletePrometheusRule deletes a PrometheusRule
+func DeletePrometheusRule(clientset *kubernetes.Clientset, name, namespace string) error {
+	return deleteMonitoringResource(clientset, prometheusRuleResource, name, namespace)
+}
+
+// getMonitoringResource gets a Prometheus Operator resource by name into obj
+func getMonitoringResource(clientset *kubernetes.Clientset, resource, name, namespace string,
+	obj interface{}) (bool, error) {
+	body, err := clientset.CoreV1().RESTClient().Get().
+		AbsPath(fmt.Sprintf(monitoringPath, namespace, resource), name).
+		Do().
+		Raw()
+	if kerrors.IsNotFound(err) {
+		log.Debugf("%s %s not found", resource, name)
+		return false, err
+	}
+	if err != nil {
+		log.Error(err)
+		log.Errorf("error getting %s %s", resource, name)
+		return false, err
+	}
+
+	if err := json.Unmarshal(body, obj); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// createMonitoringResource creates a Prometheus Operator resource
+func createMonitoringResource(clientset *kubernetes.Clientset, resource, name, namespace string,
+	obj interface{}) error {
+	body, err := json.Marshal(obj)
+	if err != nil {
+		return err
+	}
+
+	if err := clientset.CoreV1().RESTClient().Post().
+		AbsPath(fmt.Sprintf(monitoringPath, namespace, resource)).
+		SetHeader("Content-Type", "application/json").
+		Body(body).
+		Do().
+		Error(); err != nil {
+		log.Error(err)
+		log.Errorf("error creating %s %s", resource, name)
+		return err
+	}
+
+	log.Debugf("created %s %s", resource, name)
+
+	return nil
+}
+
+// updateMonitoringResource updates a Prometheus Operator resource
+func updateMonitoringResource(clientset *kubernetes.Clientset, resource, name, namespace string,
+	obj interface{}) error {
+	body, err := json.Marshal(obj)
+	if err != nil {
+		return err
+	}
+
+	if err := clientset.CoreV1().RESTClient().Put().
+		AbsPath(fmt.Sprintf(monitoringPath, namespace, resource), name).
+		SetHeader("Content-Type", "application/json").
+		Body(body).
+		Do().
+		Error(); err != nil {
+		log.Error(err)
+		log.Errorf("error updating %s %s", resource, name)
+		return err
+	}
+
+	return nil
+}
+
+// deleteMonitoringResource deletes a Prometheus Operator resource
+func deleteMonitoringResource(clientset *kubernetes.Clientset, resource, name, namespace string) error {
+	err := clientset.CoreV1().RESTClient().Delete().
+		AbsPath(fmt.Sprintf(monitoringPath, namespace, resource), name).
+		Do().
+		Error()
+	if err != nil && !kerrors.IsNotFound(err) {
+		log.Error(err)
+		log.Errorf("error deleting %s %s", resource, name)
+	} else if err == nil {
+		log.Debugf("deleted %s %s", resource, name)
+	}
+
+	return err
+}
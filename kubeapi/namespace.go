@@ -37,6 +37,20 @@ func GetNamespaces(clientset *kubernetes.Clientset) (*v1.NamespaceList, error) {
 	return namespaces, err
 }
 
+// GetNamespacesBySelector gets a list of the Namespaces that match a label selector
+func GetNamespacesBySelector(clientset *kubernetes.Clientset, selector string) (*v1.NamespaceList, error) {
+
+	lo := meta_v1.ListOptions{LabelSelector: selector}
+
+	namespaces, err := clientset.CoreV1().Namespaces().List(lo)
+	if err != nil {
+		log.Error(err)
+		return namespaces, err
+	}
+
+	return namespaces, err
+}
+
 // GetNamespace gets a Namespace by name
 func GetNamespace(clientset *kubernetes.Clientset, name string) (*v1.Namespace, bool, error) {
 	ns, err := clientset.CoreV1().Namespaces().Get(name, meta_v1.GetOptions{})
@@ -0,0 +1,73 @@
+package kubeapi
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	log "github.com/sirupsen/logrus"
+	networkingv1 "k8s.io/api/networking/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// GetNetworkPolicy gets a NetworkPolicy by name
+func GetNetworkPolicy(clientset *kubernetes.Clientset, name, namespace string) (*networkingv1.NetworkPolicy, bool, error) {
+	policy, err := clientset.NetworkingV1().NetworkPolicies(namespace).Get(name, meta_v1.GetOptions{})
+	if kerrors.IsNotFound(err) {
+		return policy, false, err
+	}
+	if err != nil {
+		log.Error(err)
+		return policy, false, err
+	}
+
+	return policy, true, err
+}
+
+// CreateNetworkPolicy creates a NetworkPolicy
+func CreateNetworkPolicy(clientset *kubernetes.Clientset, policy *networkingv1.NetworkPolicy, namespace string) error {
+	_, err := clientset.NetworkingV1().NetworkPolicies(namespace).Create(policy)
+	if err != nil {
+		log.Error(err)
+		log.Error("error creating networkpolicy " + policy.Name)
+		return err
+	}
+
+	log.Debugf("created networkpolicy %s", policy.Name)
+	return nil
+}
+
+// UpdateNetworkPolicy updates a NetworkPolicy
+func UpdateNetworkPolicy(clientset *kubernetes.Clientset, policy *networkingv1.NetworkPolicy, namespace string) error {
+	_, err := clientset.NetworkingV1().NetworkPolicies(namespace).Update(policy)
+	if err != nil {
+		log.Error(err)
+		log.Error("error updating networkpolicy " + policy.Name)
+	}
+
+	return err
+}
+
+// DeleteNetworkPolicy deletes a NetworkPolicy
+func DeleteNetworkPolicy(clientset *kubernetes.Clientset, name, namespace string) error {
+	err := clientset.NetworkingV1().NetworkPolicies(namespace).Delete(name, &meta_v1.DeleteOptions{})
+	if err != nil && !kerrors.IsNotFound(err) {
+		log.Error(err)
+		log.Error("error deleting networkpolicy " + name)
+	}
+
+	return err
+}
@@ -0,0 +1,87 @@
+package kubeapi
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"encoding/json"
+
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// GetNode gets a single Node
+func GetNode(clientset *kubernetes.Clientset, name string) (*v1.Node, bool, error) {
+	node, err := clientset.CoreV1().Nodes().Get(name, meta_v1.GetOptions{})
+	if kerrors.IsNotFound(err) {
+		log.Debugf("node %s not found", name)
+		return node, false, err
+	}
+	if err != nil {
+		log.Error(err)
+		log.Error("error getting Node " + name)
+		return node, false, err
+	}
+
+	return node, true, err
+}
+
+// VolumeStats is how full a volume that is mounted on a node is, as reported by the Kubelet of
+// the node. Only what the Operator reads of the stats summary of the Kubelet is decoded
+type VolumeStats struct {
+	Name   string `json:"name"`
+	PVCRef *struct {
+		Name      string `json:"name"`
+		Namespace string `json:"namespace"`
+	} `json:"pvcRef,omitempty"`
+	CapacityBytes *uint64 `json:"capacityBytes,omitempty"`
+	UsedBytes     *uint64 `json:"usedBytes,omitempty"`
+}
+
+// GetNodeVolumeStats gets the stats of the volumes of the Pods that run on a Node from the stats
+// summary of its Kubelet, which is reached through the proxy of the API server
+func GetNodeVolumeStats(clientset *kubernetes.Clientset, name string) ([]VolumeStats, error) {
+	body, err := clientset.CoreV1().RESTClient().Get().
+		Resource("nodes").
+		Name(name).
+		SubResource("proxy").
+		Suffix("stats/summary").
+		DoRaw()
+	if err != nil {
+		log.Error(err)
+		log.Error("error getting stats summary of Node " + name)
+		return nil, err
+	}
+
+	summary := struct {
+		Pods []struct {
+			Volumes []VolumeStats `json:"volume"`
+		} `json:"pods"`
+	}{}
+
+	if err := json.Unmarshal(body, &summary); err != nil {
+		return nil, err
+	}
+
+	stats := []VolumeStats{}
+	for _, pod := range summary.Pods {
+		stats = append(stats, pod.Volumes...)
+	}
+
+	return stats, nil
+}
@@ -151,6 +151,18 @@ func Updatepgcluster(client *rest.RESTClient, cluster *crv1.Pgcluster, name, nam
 }
 
 func PatchpgclusterStatus(restclient *rest.RESTClient, state crv1.PgclusterState, message string, oldCrd *crv1.Pgcluster, namespace string) error {
+	//change it, leaving the rest of the status as is
+	return Patchpgcluster(restclient, oldCrd, namespace, func(cluster *crv1.Pgcluster) {
+		cluster.Status.State = state
+		cluster.Status.Message = message
+	})
+}
+
+// Patchpgcluster applies the changes that update makes to a cluster, e.g. to
+// a part of its status, as a merge patch, so that only the fields that update
+// changed are sent and the rest of the cluster is left as is. The changes are
+// made to oldCrd as well
+func Patchpgcluster(restclient *rest.RESTClient, oldCrd *crv1.Pgcluster, namespace string, update func(cluster *crv1.Pgcluster)) error {
 
 	oldData, err := json.Marshal(oldCrd)
 	if err != nil {
@@ -158,10 +170,7 @@ func PatchpgclusterStatus(restclient *rest.RESTClient, state crv1.PgclusterState
 	}
 
 	//change it
-	oldCrd.Status = crv1.PgclusterStatus{
-		State:   state,
-		Message: message,
-	}
+	update(oldCrd)
 
 	//create the patch
 	var newData, patchBytes []byte
@@ -177,7 +186,7 @@ func PatchpgclusterStatus(restclient *rest.RESTClient, state crv1.PgclusterState
 	log.Debug(string(patchBytes))
 
 	//apply patch
-	_, err6 := restclient.Patch(types.MergePatchType).
+	_, err = restclient.Patch(types.MergePatchType).
 		Namespace(namespace).
 		Resource(crv1.PgclusterResourcePlural).
 		Name(oldCrd.Spec.Name).
@@ -185,6 +194,5 @@ func PatchpgclusterStatus(restclient *rest.RESTClient, state crv1.PgclusterState
 		Do().
 		Get()
 
-	return err6
-
+	return err
 }
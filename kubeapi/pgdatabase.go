@@ -0,0 +1,164 @@
+package kubeapi
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"encoding/json"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	jsonpatch "github.com/evanphx/json-patch"
+	log "github.com/sirupsen/logrus"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
+)
+
+// GetpgdatabasesBySelector gets a list of pgdatabases by selector
+func GetpgdatabasesBySelector(client *rest.RESTClient, databaseList *crv1.PgdatabaseList, selector, namespace string) error {
+
+	var err error
+
+	myselector := labels.Everything()
+
+	if selector != "" {
+		myselector, err = labels.Parse(selector)
+		if err != nil {
+			log.Error("could not parse selector value ")
+			log.Error(err)
+			return err
+		}
+	}
+
+	err = client.Get().
+		Resource(crv1.PgdatabaseResourcePlural).
+		Namespace(namespace).
+		Param("labelSelector", myselector.String()).
+		Do().
+		Into(databaseList)
+	if err != nil {
+		log.Error("error getting list of pgdatabases " + err.Error())
+	}
+
+	return err
+}
+
+// Getpgdatabase gets a pgdatabase by name
+func Getpgdatabase(client *rest.RESTClient, database *crv1.Pgdatabase, name, namespace string) (bool, error) {
+
+	err := client.Get().
+		Resource(crv1.PgdatabaseResourcePlural).
+		Namespace(namespace).
+		Name(name).
+		Do().Into(database)
+	if kerrors.IsNotFound(err) {
+		log.Debugf("pgdatabase %s not found", name)
+		return false, err
+	}
+	if err != nil {
+		log.Error("error getting pgdatabase " + err.Error())
+		return false, err
+	}
+
+	return true, err
+}
+
+// Deletepgdatabase deletes a pgdatabase by name
+func Deletepgdatabase(client *rest.RESTClient, name, namespace string) error {
+
+	err := client.Delete().
+		Resource(crv1.PgdatabaseResourcePlural).
+		Namespace(namespace).
+		Name(name).
+		Do().
+		Error()
+	if err != nil {
+		log.Error("error deleting pgdatabase " + err.Error())
+	}
+
+	return err
+}
+
+// Createpgdatabase creates a pgdatabase
+func Createpgdatabase(client *rest.RESTClient, database *crv1.Pgdatabase, namespace string) error {
+
+	result := crv1.Pgdatabase{}
+
+	err := client.Post().
+		Resource(crv1.PgdatabaseResourcePlural).
+		Namespace(namespace).
+		Body(database).
+		Do().
+		Into(&result)
+	if err != nil {
+		log.Error("error creating pgdatabase " + err.Error())
+	}
+
+	return err
+}
+
+// Updatepgdatabase updates a pgdatabase
+func Updatepgdatabase(client *rest.RESTClient, database *crv1.Pgdatabase, name, namespace string) error {
+
+	err := client.Put().
+		Name(name).
+		Namespace(namespace).
+		Resource(crv1.PgdatabaseResourcePlural).
+		Body(database).
+		Do().
+		Error()
+	if err != nil {
+		log.Error("error updating pgdatabase " + err.Error())
+	}
+
+	return err
+}
+
+// PatchpgdatabaseStatus records the status of a pgdatabase
+func PatchpgdatabaseStatus(restclient *rest.RESTClient, status crv1.PgdatabaseStatus, oldCrd *crv1.Pgdatabase, namespace string) error {
+
+	oldData, err := json.Marshal(oldCrd)
+	if err != nil {
+		return err
+	}
+
+	//change it
+	oldCrd.Status = status
+
+	//create the patch
+	var newData, patchBytes []byte
+	newData, err = json.Marshal(oldCrd)
+	if err != nil {
+		return err
+	}
+	patchBytes, err = jsonpatch.CreateMergePatch(oldData, newData)
+	if err != nil {
+		return err
+	}
+
+	log.Debug(string(patchBytes))
+
+	//apply patch
+	_, err = restclient.Patch(types.MergePatchType).
+		Namespace(namespace).
+		Resource(crv1.PgdatabaseResourcePlural).
+		Name(oldCrd.ObjectMeta.Name).
+		Body(patchBytes).
+		Do().
+		Get()
+
+	return err
+}
@@ -153,11 +153,9 @@ func PatchpgreplicaStatus(restclient *rest.RESTClient, state crv1.PgreplicaState
 		return err
 	}
 
-	//change it
-	oldCrd.Status = crv1.PgreplicaStatus{
-		State:   state,
-		Message: message,
-	}
+	//change it, keeping where the replica is placed
+	oldCrd.Status.State = state
+	oldCrd.Status.Message = message
 
 	//create the patch
 	var newData, patchBytes []byte
@@ -184,3 +182,188 @@ func PatchpgreplicaStatus(restclient *rest.RESTClient, state crv1.PgreplicaState
 	return err6
 
 }
+
+// PatchpgreplicaPlacement records the node and the zone that a replica runs on
+// in the status of the replica
+func PatchpgreplicaPlacement(restclient *rest.RESTClient, node, zone string, oldCrd *crv1.Pgreplica, namespace string) error {
+
+	oldData, err := json.Marshal(oldCrd)
+	if err != nil {
+		return err
+	}
+
+	//change it
+	oldCrd.Status.Node = node
+	oldCrd.Status.Zone = zone
+
+	//create the patch
+	var newData, patchBytes []byte
+	newData, err = json.Marshal(oldCrd)
+	if err != nil {
+		return err
+	}
+	patchBytes, err = jsonpatch.CreateMergePatch(oldData, newData)
+	if err != nil {
+		return err
+	}
+
+	log.Debug(string(patchBytes))
+
+	//apply patch
+	_, err = restclient.Patch(types.MergePatchType).
+		Namespace(namespace).
+		Resource(crv1.PgreplicaResourcePlural).
+		Name(oldCrd.Spec.Name).
+		Body(patchBytes).
+		Do().
+		Get()
+
+	return err
+}
+
+// PatchpgreplicaReplicaStorageStatus records the progress of the resize of the
+// volume of a replica
+func PatchpgreplicaReplicaStorageStatus(restclient *rest.RESTClient, status crv1.VolumeResizeStatus, oldCrd *crv1.Pgreplica, namespace string) error {
+
+	oldData, err := json.Marshal(oldCrd)
+	if err != nil {
+		return err
+	}
+
+	//change it
+	oldCrd.Status.ReplicaStorage = status
+
+	//create the patch
+	var newData, patchBytes []byte
+	newData, err = json.Marshal(oldCrd)
+	if err != nil {
+		return err
+	}
+	patchBytes, err = jsonpatch.CreateMergePatch(oldData, newData)
+	if err != nil {
+		return err
+	}
+
+	log.Debug(string(patchBytes))
+
+	//apply patch
+	_, err = restclient.Patch(types.MergePatchType).
+		Namespace(namespace).
+		Resource(crv1.PgreplicaResourcePlural).
+		Name(oldCrd.Spec.Name).
+		Body(patchBytes).
+		Do().
+		Get()
+
+	return err
+}
+
+// PatchpgreplicaRecoveryMinApplyDelayStatus records the minimum apply delay
+// that was last applied to a replica
+func PatchpgreplicaRecoveryMinApplyDelayStatus(restclient *rest.RESTClient, delay string, oldCrd *crv1.Pgreplica, namespace string) error {
+
+	oldData, err := json.Marshal(oldCrd)
+	if err != nil {
+		return err
+	}
+
+	//change it
+	oldCrd.Status.RecoveryMinApplyDelay = delay
+
+	//create the patch
+	var newData, patchBytes []byte
+	newData, err = json.Marshal(oldCrd)
+	if err != nil {
+		return err
+	}
+	patchBytes, err = jsonpatch.CreateMergePatch(oldData, newData)
+	if err != nil {
+		return err
+	}
+
+	log.Debug(string(patchBytes))
+
+	//apply patch
+	_, err = restclient.Patch(types.MergePatchType).
+		Namespace(namespace).
+		Resource(crv1.PgreplicaResourcePlural).
+		Name(oldCrd.Spec.Name).
+		Body(patchBytes).
+		Do().
+		Get()
+
+	return err
+}
+
+// PatchpgreplicaUpstreamStatus records the replica that a replica streams
+// from
+func PatchpgreplicaUpstreamStatus(restclient *rest.RESTClient, upstream string, oldCrd *crv1.Pgreplica, namespace string) error {
+
+	oldData, err := json.Marshal(oldCrd)
+	if err != nil {
+		return err
+	}
+
+	//change it
+	oldCrd.Status.Upstream = upstream
+
+	//create the patch
+	var newData, patchBytes []byte
+	newData, err = json.Marshal(oldCrd)
+	if err != nil {
+		return err
+	}
+	patchBytes, err = jsonpatch.CreateMergePatch(oldData, newData)
+	if err != nil {
+		return err
+	}
+
+	log.Debug(string(patchBytes))
+
+	//apply patch
+	_, err = restclient.Patch(types.MergePatchType).
+		Namespace(namespace).
+		Resource(crv1.PgreplicaResourcePlural).
+		Name(oldCrd.Spec.Name).
+		Body(patchBytes).
+		Do().
+		Get()
+
+	return err
+}
+
+// PatchpgreplicaLagStatus records how far a replica is behind the primary
+func PatchpgreplicaLagStatus(restclient *rest.RESTClient, status crv1.ReplicaLagStatus, oldCrd *crv1.Pgreplica, namespace string) error {
+
+	oldData, err := json.Marshal(oldCrd)
+	if err != nil {
+		return err
+	}
+
+	//change it
+	oldCrd.Status.Lag = status
+
+	//create the patch
+	var newData, patchBytes []byte
+	newData, err = json.Marshal(oldCrd)
+	if err != nil {
+		return err
+	}
+	patchBytes, err = jsonpatch.CreateMergePatch(oldData, newData)
+	if err != nil {
+		return err
+	}
+
+	log.Debug(string(patchBytes))
+
+	//apply patch
+	_, err = restclient.Patch(types.MergePatchType).
+		Namespace(namespace).
+		Resource(crv1.PgreplicaResourcePlural).
+		Name(oldCrd.Spec.Name).
+		Body(patchBytes).
+		Do().
+		Get()
+
+	return err
+}
@@ -0,0 +1,164 @@
+package kubeapi
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"encoding/json"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	jsonpatch "github.com/evanphx/json-patch"
+	log "github.com/sirupsen/logrus"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
+)
+
+// GetpgschedulesBySelector gets a list of pgschedules by selector
+func GetpgschedulesBySelector(client *rest.RESTClient, scheduleList *crv1.PgscheduleList, selector, namespace string) error {
+
+	var err error
+
+	myselector := labels.Everything()
+
+	if selector != "" {
+		myselector, err = labels.Parse(selector)
+		if err != nil {
+			log.Error("could not parse selector value ")
+			log.Error(err)
+			return err
+		}
+	}
+
+	err = client.Get().
+		Resource(crv1.PgscheduleResourcePlural).
+		Namespace(namespace).
+		Param("labelSelector", myselector.String()).
+		Do().
+		Into(scheduleList)
+	if err != nil {
+		log.Error("error getting list of pgschedules " + err.Error())
+	}
+
+	return err
+}
+
+// Getpgschedule gets a pgschedule by name
+func Getpgschedule(client *rest.RESTClient, schedule *crv1.Pgschedule, name, namespace string) (bool, error) {
+
+	err := client.Get().
+		Resource(crv1.PgscheduleResourcePlural).
+		Namespace(namespace).
+		Name(name).
+		Do().Into(schedule)
+	if kerrors.IsNotFound(err) {
+		log.Debugf("pgschedule %s not found", name)
+		return false, err
+	}
+	if err != nil {
+		log.Error("error getting pgschedule " + err.Error())
+		return false, err
+	}
+
+	return true, err
+}
+
+// Deletepgschedule deletes a pgschedule by name
+func Deletepgschedule(client *rest.RESTClient, name, namespace string) error {
+
+	err := client.Delete().
+		Resource(crv1.PgscheduleResourcePlural).
+		Namespace(namespace).
+		Name(name).
+		Do().
+		Error()
+	if err != nil {
+		log.Error("error deleting pgschedule " + err.Error())
+	}
+
+	return err
+}
+
+// Createpgschedule creates a pgschedule
+func Createpgschedule(client *rest.RESTClient, schedule *crv1.Pgschedule, namespace string) error {
+
+	result := crv1.Pgschedule{}
+
+	err := client.Post().
+		Resource(crv1.PgscheduleResourcePlural).
+		Namespace(namespace).
+		Body(schedule).
+		Do().
+		Into(&result)
+	if err != nil {
+		log.Error("error creating pgschedule " + err.Error())
+	}
+
+	return err
+}
+
+// Updatepgschedule updates a pgschedule
+func Updatepgschedule(client *rest.RESTClient, schedule *crv1.Pgschedule, name, namespace string) error {
+
+	err := client.Put().
+		Name(name).
+		Namespace(namespace).
+		Resource(crv1.PgscheduleResourcePlural).
+		Body(schedule).
+		Do().
+		Error()
+	if err != nil {
+		log.Error("error updating pgschedule " + err.Error())
+	}
+
+	return err
+}
+
+// PatchpgscheduleStatus records the status of a pgschedule
+func PatchpgscheduleStatus(restclient *rest.RESTClient, status crv1.PgscheduleStatus, oldCrd *crv1.Pgschedule, namespace string) error {
+
+	oldData, err := json.Marshal(oldCrd)
+	if err != nil {
+		return err
+	}
+
+	//change it
+	oldCrd.Status = status
+
+	//create the patch
+	var newData, patchBytes []byte
+	newData, err = json.Marshal(oldCrd)
+	if err != nil {
+		return err
+	}
+	patchBytes, err = jsonpatch.CreateMergePatch(oldData, newData)
+	if err != nil {
+		return err
+	}
+
+	log.Debug(string(patchBytes))
+
+	//apply patch
+	_, err = restclient.Patch(types.MergePatchType).
+		Namespace(namespace).
+		Resource(crv1.PgscheduleResourcePlural).
+		Name(oldCrd.ObjectMeta.Name).
+		Body(patchBytes).
+		Do().
+		Get()
+
+	return err
+}
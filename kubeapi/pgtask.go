@@ -23,6 +23,7 @@ import (
 	jsonpatch "github.com/evanphx/json-patch"
 	log "github.com/sirupsen/logrus"
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/rest"
@@ -174,11 +175,9 @@ func PatchpgtaskStatus(restclient *rest.RESTClient, state crv1.PgtaskState, mess
 		return err
 	}
 
-	//change it
-	oldCrd.Status = crv1.PgtaskStatus{
-		State:   state,
-		Message: message,
-	}
+	//change it, keeping the phase of the task
+	oldCrd.Status.State = state
+	oldCrd.Status.Message = message
 
 	//create the patch
 	var newData, patchBytes []byte
@@ -205,6 +204,44 @@ func PatchpgtaskStatus(restclient *rest.RESTClient, state crv1.PgtaskState, mess
 
 }
 
+// PatchpgtaskPhase records the state and phase of a task in its status, along
+// with a message if there is one. Moving the task into a phase also records
+// when it started or completed, see PgtaskStatus.SetPhase
+func PatchpgtaskPhase(restclient *rest.RESTClient, state crv1.PgtaskState, phase crv1.PgtaskPhase, message string, oldCrd *crv1.Pgtask, namespace string) error {
+
+	oldData, err := json.Marshal(oldCrd)
+	if err != nil {
+		return err
+	}
+
+	//change it
+	oldCrd.Status.State = state
+	oldCrd.Status.SetPhase(phase, message, metav1.Now())
+
+	//create the patch
+	var newData, patchBytes []byte
+	newData, err = json.Marshal(oldCrd)
+	if err != nil {
+		return err
+	}
+	patchBytes, err = jsonpatch.CreateMergePatch(oldData, newData)
+	if err != nil {
+		return err
+	}
+	log.Debug(string(patchBytes))
+
+	//apply patch
+	_, err = restclient.Patch(types.MergePatchType).
+		Namespace(namespace).
+		Resource(crv1.PgtaskResourcePlural).
+		Name(oldCrd.Name).
+		Body(patchBytes).
+		Do().
+		Get()
+
+	return err
+}
+
 func PatchpgtaskWorkflowStatus(restclient *rest.RESTClient, oldCrd *crv1.Pgtask, namespace string) error {
 
 	oldData, err := json.Marshal(oldCrd)
@@ -0,0 +1,200 @@
+package kubeapi
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"encoding/json"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	jsonpatch "github.com/evanphx/json-patch"
+	log "github.com/sirupsen/logrus"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
+)
+
+// GetpgusersBySelector gets a list of pgusers by selector
+func GetpgusersBySelector(client *rest.RESTClient, userList *crv1.PguserList, selector, namespace string) error {
+
+	var err error
+
+	myselector := labels.Everything()
+
+	if selector != "" {
+		myselector, err = labels.Parse(selector)
+		if err != nil {
+			log.Error("could not parse selector value ")
+			log.Error(err)
+			return err
+		}
+	}
+
+	err = client.Get().
+		Resource(crv1.PguserResourcePlural).
+		Namespace(namespace).
+		Param("labelSelector", myselector.String()).
+		Do().
+		Into(userList)
+	if err != nil {
+		log.Error("error getting list of pgusers " + err.Error())
+	}
+
+	return err
+}
+
+// Getpguser gets a pguser by name
+func Getpguser(client *rest.RESTClient, user *crv1.Pguser, name, namespace string) (bool, error) {
+
+	err := client.Get().
+		Resource(crv1.PguserResourcePlural).
+		Namespace(namespace).
+		Name(name).
+		Do().Into(user)
+	if kerrors.IsNotFound(err) {
+		log.Debugf("pguser %s not found", name)
+		return false, err
+	}
+	if err != nil {
+		log.Error("error getting pguser " + err.Error())
+		return false, err
+	}
+
+	return true, err
+}
+
+// Deletepguser deletes a pguser by name
+func Deletepguser(client *rest.RESTClient, name, namespace string) error {
+
+	err := client.Delete().
+		Resource(crv1.PguserResourcePlural).
+		Namespace(namespace).
+		Name(name).
+		Do().
+		Error()
+	if err != nil {
+		log.Error("error deleting pguser " + err.Error())
+	}
+
+	return err
+}
+
+// Createpguser creates a pguser
+func Createpguser(client *rest.RESTClient, user *crv1.Pguser, namespace string) error {
+
+	result := crv1.Pguser{}
+
+	err := client.Post().
+		Resource(crv1.PguserResourcePlural).
+		Namespace(namespace).
+		Body(user).
+		Do().
+		Into(&result)
+	if err != nil {
+		log.Error("error creating pguser " + err.Error())
+	}
+
+	return err
+}
+
+// Updatepguser updates a pguser
+func Updatepguser(client *rest.RESTClient, user *crv1.Pguser, name, namespace string) error {
+
+	err := client.Put().
+		Name(name).
+		Namespace(namespace).
+		Resource(crv1.PguserResourcePlural).
+		Body(user).
+		Do().
+		Error()
+	if err != nil {
+		log.Error("error updating pguser " + err.Error())
+	}
+
+	return err
+}
+
+// PatchpguserStatus records the status of a pguser
+func PatchpguserStatus(restclient *rest.RESTClient, status crv1.PguserStatus, oldCrd *crv1.Pguser, namespace string) error {
+
+	oldData, err := json.Marshal(oldCrd)
+	if err != nil {
+		return err
+	}
+
+	//change it
+	oldCrd.Status = status
+
+	//create the patch
+	var newData, patchBytes []byte
+	newData, err = json.Marshal(oldCrd)
+	if err != nil {
+		return err
+	}
+	patchBytes, err = jsonpatch.CreateMergePatch(oldData, newData)
+	if err != nil {
+		return err
+	}
+
+	log.Debug(string(patchBytes))
+
+	//apply patch
+	_, err = restclient.Patch(types.MergePatchType).
+		Namespace(namespace).
+		Resource(crv1.PguserResourcePlural).
+		Name(oldCrd.ObjectMeta.Name).
+		Body(patchBytes).
+		Do().
+		Get()
+
+	return err
+}
+
+// PatchpguserFinalizers sets the finalizers of a pguser
+func PatchpguserFinalizers(restclient *rest.RESTClient, finalizers []string, oldCrd *crv1.Pguser, namespace string) error {
+
+	oldData, err := json.Marshal(oldCrd)
+	if err != nil {
+		return err
+	}
+
+	//change it
+	oldCrd.ObjectMeta.Finalizers = finalizers
+
+	//create the patch
+	var newData, patchBytes []byte
+	newData, err = json.Marshal(oldCrd)
+	if err != nil {
+		return err
+	}
+	patchBytes, err = jsonpatch.CreateMergePatch(oldData, newData)
+	if err != nil {
+		return err
+	}
+
+	log.Debug(string(patchBytes))
+
+	//apply patch
+	_, err = restclient.Patch(types.MergePatchType).
+		Namespace(namespace).
+		Resource(crv1.PguserResourcePlural).
+		Name(oldCrd.ObjectMeta.Name).
+		Body(patchBytes).
+		Do().
+		Get()
+
+	return err
+}
@@ -96,3 +96,30 @@ func AddLabelToPod(clientset *kubernetes.Clientset, origPod *v1.Pod, key, value,
 	log.Debugf("add label to Pod %s %s=%v", origPod.Name, key, value)
 	return err
 }
+
+// GetPodLogs returns the last tailLines lines of the logs of a Pod's container
+func GetPodLogs(clientset *kubernetes.Clientset, name, container, namespace string, tailLines int64) (string, error) {
+	opts := &v1.PodLogOptions{
+		Container: container,
+		TailLines: &tailLines,
+	}
+
+	logs, err := clientset.CoreV1().Pods(namespace).GetLogs(name, opts).Do().Raw()
+	if err != nil {
+		log.Error(err)
+		log.Error("error getting logs for pod " + name)
+		return "", err
+	}
+
+	return string(logs), nil
+}
+
+// UpdatePod updates a Pod
+func UpdatePod(clientset *kubernetes.Clientset, pod *v1.Pod, namespace string) error {
+	_, err := clientset.CoreV1().Pods(namespace).Update(pod)
+	if err != nil {
+		log.Error(err)
+		log.Errorf("error updating pod %s", pod.Name)
+	}
+	return err
+}
@@ -0,0 +1,73 @@
+package kubeapi
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	log "github.com/sirupsen/logrus"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// GetPodDisruptionBudget gets a PodDisruptionBudget by name
+func GetPodDisruptionBudget(clientset *kubernetes.Clientset, name, namespace string) (*policyv1beta1.PodDisruptionBudget, bool, error) {
+	pdb, err := clientset.PolicyV1beta1().PodDisruptionBudgets(namespace).Get(name, meta_v1.GetOptions{})
+	if kerrors.IsNotFound(err) {
+		return pdb, false, err
+	}
+	if err != nil {
+		log.Error(err)
+		return pdb, false, err
+	}
+
+	return pdb, true, err
+}
+
+// CreatePodDisruptionBudget creates a PodDisruptionBudget
+func CreatePodDisruptionBudget(clientset *kubernetes.Clientset, pdb *policyv1beta1.PodDisruptionBudget, namespace string) error {
+	_, err := clientset.PolicyV1beta1().PodDisruptionBudgets(namespace).Create(pdb)
+	if err != nil {
+		log.Error(err)
+		log.Error("error creating poddisruptionbudget " + pdb.Name)
+		return err
+	}
+
+	log.Debugf("created poddisruptionbudget %s", pdb.Name)
+	return nil
+}
+
+// UpdatePodDisruptionBudget updates a PodDisruptionBudget
+func UpdatePodDisruptionBudget(clientset *kubernetes.Clientset, pdb *policyv1beta1.PodDisruptionBudget, namespace string) error {
+	_, err := clientset.PolicyV1beta1().PodDisruptionBudgets(namespace).Update(pdb)
+	if err != nil {
+		log.Error(err)
+		log.Error("error updating poddisruptionbudget " + pdb.Name)
+	}
+
+	return err
+}
+
+// DeletePodDisruptionBudget deletes a PodDisruptionBudget
+func DeletePodDisruptionBudget(clientset *kubernetes.Clientset, name, namespace string) error {
+	err := clientset.PolicyV1beta1().PodDisruptionBudgets(namespace).Delete(name, &meta_v1.DeleteOptions{})
+	if err != nil && !kerrors.IsNotFound(err) {
+		log.Error(err)
+		log.Error("error deleting poddisruptionbudget " + name)
+	}
+
+	return err
+}
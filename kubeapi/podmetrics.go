@@ -0,0 +1,68 @@
+package kubeapi
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"encoding/json"
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// podMetricsPath is the path of the metrics of the Pods in a namespace in the resource metrics
+// API, which is served by e.g. the metrics-server. The metrics client is not a dependency of the
+// Operator, so the metrics are read through the REST API directly
+const podMetricsPath = "/apis/metrics.k8s.io/v1beta1/namespaces/%s/pods"
+
+// PodMetrics is the resource usage of the containers of a Pod, as it was last sampled. Only what
+// the Operator reads of it is decoded
+type PodMetrics struct {
+	meta_v1.ObjectMeta `json:"metadata"`
+	Containers         []ContainerMetrics `json:"containers"`
+}
+
+// ContainerMetrics is the resource usage of a container, e.g. its CPU usage in cores
+type ContainerMetrics struct {
+	Name  string          `json:"name"`
+	Usage v1.ResourceList `json:"usage"`
+}
+
+// GetPodMetrics gets the resource usage of the Pods that match a selector
+func GetPodMetrics(clientset *kubernetes.Clientset, selector, namespace string) ([]PodMetrics, error) {
+	body, err := clientset.CoreV1().RESTClient().Get().
+		AbsPath(fmt.Sprintf(podMetricsPath, namespace)).
+		Param("labelSelector", selector).
+		Do().
+		Raw()
+	if err != nil {
+		log.Error(err)
+		log.Error("error getting pod metrics selector=[" + selector + "]")
+		return nil, err
+	}
+
+	list := struct {
+		Items []PodMetrics `json:"items"`
+	}{}
+
+	if err := json.Unmarshal(body, &list); err != nil {
+		return nil, err
+	}
+
+	return list.Items, nil
+}
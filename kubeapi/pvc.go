@@ -133,3 +133,13 @@ func IsPVCDeleted(client *kubernetes.Clientset, timeout time.Duration, pvcName,
 		}
 	}
 }
+
+// UpdatePVC updates a PVC
+func UpdatePVC(clientset *kubernetes.Clientset, pvc *v1.PersistentVolumeClaim, namespace string) error {
+	_, err := clientset.CoreV1().PersistentVolumeClaims(namespace).Update(pvc)
+	if err != nil {
+		log.Error(err)
+		log.Errorf("error updating pvc %s", pvc.Name)
+	}
+	return err
+}
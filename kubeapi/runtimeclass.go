@@ -0,0 +1,40 @@
+package kubeapi
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	log "github.com/sirupsen/logrus"
+	"k8s.io/api/node/v1beta1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// GetRuntimeClass gets a single RuntimeClass
+func GetRuntimeClass(clientset *kubernetes.Clientset, name string) (*v1beta1.RuntimeClass, bool, error) {
+	runtimeClass, err := clientset.NodeV1beta1().RuntimeClasses().Get(name, meta_v1.GetOptions{})
+	if kerrors.IsNotFound(err) {
+		log.Debugf("runtime class %s not found", name)
+		return runtimeClass, false, err
+	}
+	if err != nil {
+		log.Error(err)
+		log.Error("error getting RuntimeClass " + name)
+		return runtimeClass, false, err
+	}
+
+	return runtimeClass, true, err
+}
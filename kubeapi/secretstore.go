@@ -0,0 +1,92 @@
+package kubeapi
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"encoding/json"
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// secretProviderClassPath is the path of the SecretProviderClasses of the Secrets Store CSI
+	// Driver in a namespace. Neither the driver nor the External Secrets Operator is a dependency
+	// of the Operator, so their resources are read through the REST API directly
+	secretProviderClassPath = "/apis/secrets-store.csi.x-k8s.io/v1/namespaces/%s/secretproviderclasses"
+	// externalSecretPath is the path of the ExternalSecrets of the External Secrets Operator in
+	// a namespace
+	externalSecretPath = "/apis/external-secrets.io/v1beta1/namespaces/%s/externalsecrets"
+)
+
+// ExternalSecret is an ExternalSecret of the External Secrets Operator, limited
+// to the fields that the Operator reads
+type ExternalSecret struct {
+	meta_v1.TypeMeta   `json:",inline"`
+	meta_v1.ObjectMeta `json:"metadata"`
+	Spec               ExternalSecretSpec `json:"spec"`
+}
+
+// ExternalSecretSpec is the spec of an ExternalSecret
+type ExternalSecretSpec struct {
+	Target ExternalSecretTarget `json:"target"`
+}
+
+// ExternalSecretTarget is the Secret that an ExternalSecret keeps in sync
+// with its provider. Its name defaults to that of the ExternalSecret
+type ExternalSecretTarget struct {
+	Name string `json:"name"`
+}
+
+// GetSecretProviderClass returns whether a SecretProviderClass exists
+func GetSecretProviderClass(clientset *kubernetes.Clientset, name, namespace string) (bool, error) {
+	obj := map[string]interface{}{}
+	return getSecretStoreResource(clientset, secretProviderClassPath, name, namespace, &obj)
+}
+
+// GetExternalSecret gets an ExternalSecret by name
+func GetExternalSecret(clientset *kubernetes.Clientset, name, namespace string) (*ExternalSecret, bool, error) {
+	secret := &ExternalSecret{}
+	found, err := getSecretStoreResource(clientset, externalSecretPath, name, namespace, secret)
+	return secret, found, err
+}
+
+// getSecretStoreResource gets a resource of a secret store and decodes it into obj
+func getSecretStoreResource(clientset *kubernetes.Clientset, path, name, namespace string,
+	obj interface{}) (bool, error) {
+	body, err := clientset.CoreV1().RESTClient().Get().
+		AbsPath(fmt.Sprintf(path, namespace), name).
+		Do().
+		Raw()
+	if kerrors.IsNotFound(err) {
+		log.Debugf("%s not found", name)
+		return false, err
+	}
+	if err != nil {
+		log.Error(err)
+		log.Errorf("error getting %s", name)
+		return false, err
+	}
+
+	if err := json.Unmarshal(body, obj); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
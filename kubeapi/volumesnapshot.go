@@ -0,0 +1,194 @@
+package kubeapi
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"encoding/json"
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// VolumeSnapshotAPIGroup is the API group of the CSI VolumeSnapshots, which is also the
+	// group of the data source of a PVC that is created from one
+	VolumeSnapshotAPIGroup = "snapshot.storage.k8s.io"
+	// VolumeSnapshotAPIVersion is the API version of the CSI VolumeSnapshots
+	VolumeSnapshotAPIVersion = "snapshot.storage.k8s.io/v1beta1"
+	// VolumeSnapshotKind is the kind of the CSI VolumeSnapshots
+	VolumeSnapshotKind = "VolumeSnapshot"
+
+	// volumeSnapshotPath is the path of the VolumeSnapshots in a namespace. The snapshot
+	// client is not a dependency of the Operator, so the VolumeSnapshots are managed through
+	// the REST API directly
+	volumeSnapshotPath = "/apis/snapshot.storage.k8s.io/v1beta1/namespaces/%s/volumesnapshots"
+	// volumeSnapshotClassPath is the path of the VolumeSnapshotClasses, which are not
+	// namespaced
+	volumeSnapshotClassPath = "/apis/snapshot.storage.k8s.io/v1beta1/volumesnapshotclasses"
+)
+
+// VolumeSnapshot is a CSI VolumeSnapshot, limited to the fields that the Operator sets and
+// reads
+type VolumeSnapshot struct {
+	meta_v1.TypeMeta   `json:",inline"`
+	meta_v1.ObjectMeta `json:"metadata"`
+	Spec               VolumeSnapshotSpec    `json:"spec"`
+	Status             *VolumeSnapshotStatus `json:"status,omitempty"`
+}
+
+// VolumeSnapshotList is a list of CSI VolumeSnapshots
+type VolumeSnapshotList struct {
+	meta_v1.TypeMeta `json:",inline"`
+	meta_v1.ListMeta `json:"metadata"`
+	Items            []VolumeSnapshot `json:"items"`
+}
+
+// VolumeSnapshotSpec is the spec of a CSI VolumeSnapshot
+type VolumeSnapshotSpec struct {
+	Source                  VolumeSnapshotSource `json:"source"`
+	VolumeSnapshotClassName string               `json:"volumeSnapshotClassName,omitempty"`
+}
+
+// VolumeSnapshotSource is the PVC that a VolumeSnapshot is taken of
+type VolumeSnapshotSource struct {
+	PersistentVolumeClaimName string `json:"persistentVolumeClaimName"`
+}
+
+// VolumeSnapshotStatus is the status of a CSI VolumeSnapshot. The snapshot is taken once it
+// has a creation time, and can be restored from once it is ready to use
+type VolumeSnapshotStatus struct {
+	CreationTime *meta_v1.Time        `json:"creationTime,omitempty"`
+	ReadyToUse   bool                 `json:"readyToUse,omitempty"`
+	RestoreSize  *resource.Quantity   `json:"restoreSize,omitempty"`
+	Error        *VolumeSnapshotError `json:"error,omitempty"`
+}
+
+// VolumeSnapshotError is the last error that occurred while a VolumeSnapshot was taken
+type VolumeSnapshotError struct {
+	Message string `json:"message,omitempty"`
+}
+
+// GetVolumeSnapshot gets a CSI VolumeSnapshot by name
+func GetVolumeSnapshot(clientset *kubernetes.Clientset, name, namespace string) (*VolumeSnapshot, bool, error) {
+	snapshot := &VolumeSnapshot{}
+
+	body, err := clientset.CoreV1().RESTClient().Get().
+		AbsPath(fmt.Sprintf(volumeSnapshotPath, namespace), name).
+		Do().
+		Raw()
+	if kerrors.IsNotFound(err) {
+		log.Debugf("volume snapshot %s not found", name)
+		return snapshot, false, err
+	}
+	if err != nil {
+		log.Error(err)
+		log.Error("error getting volume snapshot " + name)
+		return snapshot, false, err
+	}
+
+	if err := json.Unmarshal(body, snapshot); err != nil {
+		return snapshot, false, err
+	}
+
+	return snapshot, true, nil
+}
+
+// GetVolumeSnapshots gets the CSI VolumeSnapshots of a namespace that match a selector
+func GetVolumeSnapshots(clientset *kubernetes.Clientset, selector, namespace string) (*VolumeSnapshotList, error) {
+	snapshots := &VolumeSnapshotList{}
+
+	body, err := clientset.CoreV1().RESTClient().Get().
+		AbsPath(fmt.Sprintf(volumeSnapshotPath, namespace)).
+		Param("labelSelector", selector).
+		Do().
+		Raw()
+	if err != nil {
+		log.Error(err)
+		log.Error("error getting volume snapshots selector=[" + selector + "]")
+		return snapshots, err
+	}
+
+	err = json.Unmarshal(body, snapshots)
+
+	return snapshots, err
+}
+
+// CreateVolumeSnapshot creates a CSI VolumeSnapshot
+func CreateVolumeSnapshot(clientset *kubernetes.Clientset, snapshot *VolumeSnapshot, namespace string) error {
+	snapshot.APIVersion = VolumeSnapshotAPIVersion
+	snapshot.Kind = VolumeSnapshotKind
+
+	body, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+
+	if err := clientset.CoreV1().RESTClient().Post().
+		AbsPath(fmt.Sprintf(volumeSnapshotPath, namespace)).
+		SetHeader("Content-Type", "application/json").
+		Body(body).
+		Do().
+		Error(); err != nil {
+		log.Error(err)
+		log.Error("error creating volume snapshot " + snapshot.Name)
+		return err
+	}
+
+	log.Debugf("created volume snapshot %s", snapshot.Name)
+
+	return nil
+}
+
+// DeleteVolumeSnapshot deletes a CSI VolumeSnapshot. The volumes that were created from it are
+// left as they are
+func DeleteVolumeSnapshot(clientset *kubernetes.Clientset, name, namespace string) error {
+	err := clientset.CoreV1().RESTClient().Delete().
+		AbsPath(fmt.Sprintf(volumeSnapshotPath, namespace), name).
+		Do().
+		Error()
+	if err != nil {
+		log.Error(err)
+		log.Error("error deleting volume snapshot " + name)
+	} else {
+		log.Debugf("deleted volume snapshot %s", name)
+	}
+
+	return err
+}
+
+// GetVolumeSnapshotClass determines whether a VolumeSnapshotClass exists. It does not if the
+// VolumeSnapshots are not served at all, i.e. no snapshot controller is installed
+func GetVolumeSnapshotClass(clientset *kubernetes.Clientset, name string) (bool, error) {
+	err := clientset.CoreV1().RESTClient().Get().
+		AbsPath(volumeSnapshotClassPath, name).
+		Do().
+		Error()
+	if kerrors.IsNotFound(err) {
+		log.Debugf("volume snapshot class %s not found", name)
+		return false, err
+	}
+	if err != nil {
+		log.Error(err)
+		log.Error("error getting volume snapshot class " + name)
+		return false, err
+	}
+
+	return true, nil
+}
@@ -591,6 +591,24 @@ func GetNamespaces(clientset *kubernetes.Clientset, installationName string) []s
 
 }
 
+// GetNamespacesBySelector returns the names of the namespaces that match a label selector, which
+// is how the namespaces to watch are found when the Operator discovers them by their labels
+func GetNamespacesBySelector(clientset *kubernetes.Clientset, selector string) []string {
+	ns := make([]string, 0)
+
+	nsList, err := kubeapi.GetNamespacesBySelector(clientset, selector)
+	if err != nil {
+		log.Error(err.Error())
+		return ns
+	}
+
+	for _, v := range nsList.Items {
+		ns = append(ns, v.Name)
+	}
+
+	return ns
+}
+
 func WatchingNamespace(clientset *kubernetes.Clientset, requestedNS, installationName string) bool {
 
 	log.Debugf("WatchingNamespace [%s]", requestedNS)
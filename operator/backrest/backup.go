@@ -54,18 +54,28 @@ type backrestJobTemplateFields struct {
 	BackrestLocalAndS3Storage     bool
 	PgbackrestRestoreVolumes      string
 	PgbackrestRestoreVolumeMounts string
+	NodeSelectorLabels            string
+	Tolerations                   string
 }
 
 var backrestPgHostRegex = regexp.MustCompile("--db-host|--pg1-host")
 var backrestPgPathRegex = regexp.MustCompile("--db-path|--pg1-path")
 
 // Backrest ...
-func Backrest(namespace string, clientset *kubernetes.Clientset, task *crv1.Pgtask) {
+func Backrest(namespace string, clientset *kubernetes.Clientset, restclient *rest.RESTClient, task *crv1.Pgtask) {
 
 	//create the Job to run the backrest command
 
 	cmd := task.Spec.Parameters[config.LABEL_BACKREST_COMMAND]
 
+	// the cluster provides the node selector and tolerations of the Job, so if it cannot be
+	// found, only the defaults are applied
+	cluster := crv1.Pgcluster{}
+	if _, err := kubeapi.Getpgcluster(restclient, &cluster,
+		task.Spec.Parameters[config.LABEL_PG_CLUSTER], namespace); err != nil {
+		log.Error(err)
+	}
+
 	jobFields := backrestJobTemplateFields{
 		JobName:                       task.Spec.Parameters[config.LABEL_JOB_NAME],
 		ClusterName:                   task.Spec.Parameters[config.LABEL_PG_CLUSTER],
@@ -83,14 +93,20 @@ func Backrest(namespace string, clientset *kubernetes.Clientset, task *crv1.Pgta
 		PgbackrestRestoreVolumeMounts: "",
 		PgbackrestRepoType:            operator.GetRepoType(task.Spec.Parameters[config.LABEL_BACKREST_STORAGE_TYPE]),
 		BackrestLocalAndS3Storage:     operator.IsLocalAndS3Storage(task.Spec.Parameters[config.LABEL_BACKREST_STORAGE_TYPE]),
+		NodeSelectorLabels:            operator.GetNodeSelectorJSON(&cluster),
+		Tolerations:                   operator.GetTolerationsJSON(&cluster),
 	}
 
-	podCommandOpts, err := getCommandOptsFromPod(clientset, task, namespace)
-	if err != nil {
-		log.Error(err.Error())
-		return
+	// a verification only reads the repository, so it is not given the options that point
+	// pgBackRest at the primary
+	if cmd != crv1.PgtaskBackrestVerify {
+		podCommandOpts, err := getCommandOptsFromPod(clientset, task, namespace)
+		if err != nil {
+			log.Error(err.Error())
+			return
+		}
+		jobFields.CommandOpts = jobFields.CommandOpts + " " + podCommandOpts
 	}
-	jobFields.CommandOpts = jobFields.CommandOpts + " " + podCommandOpts
 
 	var doc2 bytes.Buffer
 	if err := config.BackrestjobTemplate.Execute(&doc2, jobFields); err != nil {
@@ -103,7 +119,7 @@ func Backrest(namespace string, clientset *kubernetes.Clientset, task *crv1.Pgta
 	}
 
 	newjob := v1batch.Job{}
-	err = json.Unmarshal(doc2.Bytes(), &newjob)
+	err := json.Unmarshal(doc2.Bytes(), &newjob)
 	if err != nil {
 		log.Error("error unmarshalling json into Job " + err.Error())
 		return
@@ -113,6 +129,9 @@ func Backrest(namespace string, clientset *kubernetes.Clientset, task *crv1.Pgta
 	operator.SetContainerImageOverride(config.CONTAINER_IMAGE_PGO_BACKREST,
 		&newjob.Spec.Template.Spec.Containers[0])
 
+	// apply the node affinity and the PriorityClass of the cluster
+	operator.AddPodScheduling(&cluster, nil, &newjob.Spec.Template.Spec)
+
 	newjob.ObjectMeta.Labels[config.LABEL_PGOUSER] = task.ObjectMeta.Labels[config.LABEL_PGOUSER]
 	newjob.ObjectMeta.Labels[config.LABEL_PG_CLUSTER_IDENTIFIER] = task.ObjectMeta.Labels[config.LABEL_PG_CLUSTER_IDENTIFIER]
 
@@ -120,6 +139,14 @@ func Backrest(namespace string, clientset *kubernetes.Clientset, task *crv1.Pgta
 	if backupType != "" {
 		newjob.ObjectMeta.Labels[config.LABEL_PGHA_BACKUP_TYPE] = backupType
 	}
+	if task.Spec.Parameters[config.LABEL_PGBACKREST_REPOS] == "true" {
+		newjob.ObjectMeta.Labels[config.LABEL_PGBACKREST_REPOS] = "true"
+	}
+
+	// set the chargeback labels of the Namespace
+	operator.AddChargebackLabels(clientset, namespace, &newjob.ObjectMeta,
+		&newjob.Spec.Template.ObjectMeta)
+
 	kubeapi.CreateJob(clientset, &newjob, namespace)
 
 	//publish backrest backup event
@@ -165,6 +192,24 @@ func CreatePostFailoverBackup(restclient *rest.RESTClient, namespace, clusterNam
 	return CreateBackup(restclient, namespace, clusterName, podName, params, "")
 }
 
+// CreateFinalBackup creates a Pgtask in order to initiate the full pgBackRest backup that is
+// taken before a cluster with deletion protection is deleted
+func CreateFinalBackup(restclient *rest.RESTClient, namespace, clusterName, podName string) (*crv1.Pgtask, error) {
+	var params map[string]string
+	params = make(map[string]string)
+	params[config.LABEL_PGHA_BACKUP_TYPE] = crv1.BackupTypeFinal
+	return CreateBackup(restclient, namespace, clusterName, podName, params, "--type=full")
+}
+
+// CreateMajorUpgradeBackup creates a Pgtask in order to initiate the full pgBackRest backup that
+// is taken once a cluster is upgraded to a new major version, which its replicas are rebuilt from
+func CreateMajorUpgradeBackup(restclient *rest.RESTClient, namespace, clusterName, podName string) (*crv1.Pgtask, error) {
+	var params map[string]string
+	params = make(map[string]string)
+	params[config.LABEL_PGHA_BACKUP_TYPE] = crv1.BackupTypeMajorUpgrade
+	return CreateBackup(restclient, namespace, clusterName, podName, params, "--type=full")
+}
+
 // CreateBackup creates a Pgtask in order to initiate a pgBackRest backup
 func CreateBackup(restclient *rest.RESTClient, namespace, clusterName, podName string, params map[string]string,
 	backupOpts string) (*crv1.Pgtask, error) {
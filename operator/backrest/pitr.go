@@ -0,0 +1,311 @@
+package backrest
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/config"
+	"github.com/crunchydata/postgres-operator/kubeapi"
+	"github.com/crunchydata/postgres-operator/util"
+	log "github.com/sirupsen/logrus"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+)
+
+// pitrLSNRegex matches a PostgreSQL log sequence number, e.g. "0/3000060"
+var pitrLSNRegex = regexp.MustCompile(`^[0-9A-Fa-f]{1,8}/[0-9A-Fa-f]{1,8}$`)
+
+// AddPITRRestore handles a "backrest-pitr" pgtask, which restores a cluster to a point in time,
+// given as either a timestamp or an LSN. The pgtask drives the regular pgBackRest restore
+// workflow, and is itself the workflow of the restore: the primary is restored by a restore Job
+// with the "--type" and "--target" of the recovery, and once it is ready the replicas are
+// reinitialized from it. The progress of the restore is recorded in the pgtask as it goes
+func AddPITRRestore(restclient *rest.RESTClient, t *crv1.Pgtask, namespace string) {
+	clusterName := t.ObjectMeta.Labels[config.LABEL_PG_CLUSTER]
+
+	// get the latest version of the task in case it changed
+	task := crv1.Pgtask{}
+	if found, err := kubeapi.Getpgtask(restclient, &task, t.Spec.Name, namespace); !found {
+		log.Error("could not find pgtask for point-in-time recovery")
+		log.Error(err)
+		return
+	}
+
+	// have a guard -- if the task has already been started, don't proceed
+	switch task.Spec.Status {
+	case crv1.PgtaskPITRInProgress, crv1.PgtaskPITRCompleted, crv1.PgtaskPITRFailed:
+		log.Warnf("pgtask [%s] has already been processed", task.Spec.Name)
+		return
+	}
+
+	if task.Spec.Parameters == nil {
+		task.Spec.Parameters = make(map[string]string)
+	}
+
+	cluster := crv1.Pgcluster{}
+	found, err := kubeapi.Getpgcluster(restclient, &cluster, clusterName, namespace)
+	if !found {
+		failPITRRestore(restclient, &task, namespace, fmt.Sprintf("cluster %q not found", clusterName))
+		return
+	} else if err != nil {
+		log.Error(err)
+		return
+	}
+
+	if err := validatePITRRestore(&cluster, task.Spec.Parameters); err != nil {
+		failPITRRestore(restclient, &task, namespace, err.Error())
+		return
+	}
+
+	// the task is the workflow of the restore, so that the steps of the restore are recorded in it
+	workflowID := string(task.ObjectMeta.UID)
+	if task.ObjectMeta.Labels == nil {
+		task.ObjectMeta.Labels = make(map[string]string)
+	}
+	task.ObjectMeta.Labels[crv1.PgtaskWorkflowID] = workflowID
+	task.Spec.Parameters[crv1.PgtaskWorkflowID] = workflowID
+	task.Spec.Parameters[crv1.PgtaskWorkflowSubmittedStatus] = time.Now().Format(time.RFC3339)
+	task.Spec.Status = crv1.PgtaskPITRInProgress
+	task.Status.Message = "restoring cluster " + clusterName + " to " + pitrTarget(task.Spec.Parameters)
+
+	if err := kubeapi.Updatepgtask(restclient, &task, task.Spec.Name, namespace); err != nil {
+		log.Error("error in updating point-in-time recovery pgtask status " + err.Error())
+		return
+	}
+
+	restore := newPITRRestoreTask(&cluster, &task, namespace)
+	if err := kubeapi.Createpgtask(restclient, restore, namespace); err != nil {
+		// get the latest version of the task, which was just updated
+		if found, _ := kubeapi.Getpgtask(restclient, &task, task.Spec.Name, namespace); found {
+			failPITRRestore(restclient, &task, namespace, "could not create restore pgtask: "+err.Error())
+		}
+		return
+	}
+
+	log.Infof("point-in-time recovery: restoring cluster %s to %s", clusterName,
+		pitrTarget(task.Spec.Parameters))
+}
+
+// validatePITRRestore returns an error if a cluster cannot be restored to the target of a
+// point-in-time recovery pgtask
+func validatePITRRestore(cluster *crv1.Pgcluster, parameters map[string]string) error {
+	if cluster.Labels[config.LABEL_BACKREST] != "true" {
+		return fmt.Errorf("cluster %s does not have pgBackRest enabled", cluster.Name)
+	}
+
+	// a standby cluster follows a remote primary, which is what is restored instead
+	if cluster.Spec.Standby {
+		return fmt.Errorf("cluster %s is a standby cluster and cannot be restored", cluster.Name)
+	}
+
+	if cluster.Status.State == crv1.PgclusterStateRestore {
+		return fmt.Errorf("cluster %s is already being restored", cluster.Name)
+	}
+
+	if err := validatePITRTarget(parameters); err != nil {
+		return err
+	}
+
+	return util.ValidateBackrestStorageTypeOnBackupRestore(parameters[config.LABEL_BACKREST_STORAGE_TYPE],
+		cluster.Spec.UserLabels[config.LABEL_BACKREST_STORAGE_TYPE], true)
+}
+
+// validatePITRTarget returns an error unless the parameters of a point-in-time recovery pgtask
+// give exactly one valid target
+func validatePITRTarget(parameters map[string]string) error {
+	targetTime := parameters[crv1.PgtaskPITRTargetTime]
+	targetLSN := parameters[crv1.PgtaskPITRTargetLSN]
+
+	switch {
+	case targetTime == "" && targetLSN == "":
+		return fmt.Errorf("either %q or %q is required", crv1.PgtaskPITRTargetTime, crv1.PgtaskPITRTargetLSN)
+	case targetTime != "" && targetLSN != "":
+		return fmt.Errorf("only one of %q and %q can be set", crv1.PgtaskPITRTargetTime, crv1.PgtaskPITRTargetLSN)
+	case targetLSN != "" && !pitrLSNRegex.MatchString(targetLSN):
+		return fmt.Errorf("%q is not a valid LSN", targetLSN)
+	}
+
+	if exclusive := parameters[crv1.PgtaskPITRTargetExclusive]; exclusive != "" {
+		if _, err := strconv.ParseBool(exclusive); err != nil {
+			return fmt.Errorf("%q is not a valid value of %q", exclusive, crv1.PgtaskPITRTargetExclusive)
+		}
+	}
+
+	return nil
+}
+
+// pitrTarget returns the target of a point-in-time recovery pgtask
+func pitrTarget(parameters map[string]string) string {
+	if parameters[crv1.PgtaskPITRTargetLSN] != "" {
+		return parameters[crv1.PgtaskPITRTargetLSN]
+	}
+	return parameters[crv1.PgtaskPITRTargetTime]
+}
+
+// pitrRestoreOptions returns the pgBackRest options that restore to the target of a
+// point-in-time recovery pgtask. The target itself is passed to the restore Job on its own. The
+// restored primary is promoted once it reaches the target, rather than pausing there
+func pitrRestoreOptions(parameters map[string]string) string {
+	options := "--type=time"
+	if parameters[crv1.PgtaskPITRTargetLSN] != "" {
+		options = "--type=lsn"
+	}
+
+	if exclusive, _ := strconv.ParseBool(parameters[crv1.PgtaskPITRTargetExclusive]); exclusive {
+		options += " --target-exclusive"
+	}
+
+	return options + " --target-action=promote"
+}
+
+// ValidateCloneTarget returns an error unless a cluster that is cloned either has no point in
+// time to recover to, or has exactly one valid target
+func ValidateCloneTarget(clone crv1.CloneSpec) error {
+	if clone.TargetTime == "" && clone.TargetLSN == "" {
+		return nil
+	}
+
+	return validatePITRTarget(clonePITRParameters(clone))
+}
+
+// CloneRestoreOptions returns the pgBackRest options and the target of the restore that
+// bootstraps a cluster that is cloned. A clone with a point in time is restored to it the same
+// way as a point-in-time recovery
+func CloneRestoreOptions(clone crv1.CloneSpec) (string, string) {
+	// use a delta restore in order to optimize how the restore occurs
+	if clone.TargetTime == "" && clone.TargetLSN == "" {
+		return "--delta", ""
+	}
+
+	parameters := clonePITRParameters(clone)
+	return "--delta " + pitrRestoreOptions(parameters), pitrTarget(parameters)
+}
+
+// clonePITRParameters returns the parameters of a point-in-time recovery pgtask that have the
+// target of a cluster that is cloned
+func clonePITRParameters(clone crv1.CloneSpec) map[string]string {
+	return map[string]string{
+		crv1.PgtaskPITRTargetTime:      clone.TargetTime,
+		crv1.PgtaskPITRTargetLSN:       clone.TargetLSN,
+		crv1.PgtaskPITRTargetExclusive: strconv.FormatBool(clone.TargetExclusive),
+	}
+}
+
+// newPITRRestoreTask returns the pgBackRest restore pgtask of a point-in-time recovery pgtask,
+// which is carried out by the regular restore workflow
+func newPITRRestoreTask(cluster *crv1.Pgcluster, task *crv1.Pgtask, namespace string) *crv1.Pgtask {
+	toPVC := cluster.Name + "-" + util.RandStringBytesRmndr(4)
+
+	spec := crv1.PgtaskSpec{}
+	spec.Namespace = namespace
+	spec.Name = "backrest-restore-" + cluster.Name + "-to-" + toPVC
+	spec.TaskType = crv1.PgtaskBackrestRestore
+	spec.Parameters = make(map[string]string)
+	spec.Parameters[config.LABEL_BACKREST_RESTORE_FROM_CLUSTER] = cluster.Name
+	spec.Parameters[config.LABEL_BACKREST_RESTORE_TO_PVC] = toPVC
+	spec.Parameters[config.LABEL_BACKREST_RESTORE_OPTS] = pitrRestoreOptions(task.Spec.Parameters)
+	spec.Parameters[config.LABEL_BACKREST_PITR_TARGET] = pitrTarget(task.Spec.Parameters)
+	spec.Parameters[config.LABEL_PGBACKREST_STANZA] = "db"
+	spec.Parameters[config.LABEL_PGBACKREST_DB_PATH] = "/pgdata/" + toPVC
+	spec.Parameters[config.LABEL_PGBACKREST_REPO_PATH] = "/backrestrepo/" + cluster.Name + "-backrest-shared-repo"
+	spec.Parameters[config.LABEL_PGBACKREST_REPO_HOST] = cluster.Name + "-backrest-shared-repo"
+	spec.Parameters[config.LABEL_BACKREST_STORAGE_TYPE] = task.Spec.Parameters[config.LABEL_BACKREST_STORAGE_TYPE]
+	spec.Parameters[crv1.PgtaskWorkflowID] = task.Spec.Parameters[crv1.PgtaskWorkflowID]
+
+	return &crv1.Pgtask{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name: spec.Name,
+			Labels: map[string]string{
+				config.LABEL_PG_CLUSTER:            cluster.Name,
+				config.LABEL_PG_CLUSTER_IDENTIFIER: cluster.ObjectMeta.Labels[config.LABEL_PG_CLUSTER_IDENTIFIER],
+				config.LABEL_PGOUSER:               task.ObjectMeta.Labels[config.LABEL_PGOUSER],
+			},
+		},
+		Spec: spec,
+	}
+}
+
+// CompletePITRRestore records that the point-in-time recovery of a cluster, if one is in
+// progress, is completed. It is called once the restored primary is ready, at which point the
+// replicas are reinitialized from it
+func CompletePITRRestore(restclient *rest.RESTClient, clusterName, namespace string) {
+	taskList := crv1.PgtaskList{}
+	selector := config.LABEL_PG_CLUSTER + "=" + clusterName
+	if err := kubeapi.GetpgtasksBySelector(restclient, &taskList, selector, namespace); err != nil {
+		log.Error(err)
+		return
+	}
+
+	for i := range taskList.Items {
+		task := &taskList.Items[i]
+		if task.Spec.TaskType != crv1.PgtaskBackrestPITR || task.Spec.Status != crv1.PgtaskPITRInProgress {
+			continue
+		}
+
+		task.Spec.Parameters[crv1.PgtaskWorkflowCompletedStatus] = time.Now().Format(time.RFC3339)
+		task.Spec.Status = crv1.PgtaskPITRCompleted
+		task.Status.Message = "restored cluster " + clusterName + " to " + pitrTarget(task.Spec.Parameters) +
+			"; the replicas are reinitialized from the restored primary"
+
+		if err := kubeapi.Updatepgtask(restclient, task, task.Spec.Name, namespace); err != nil {
+			log.Error("error in updating point-in-time recovery pgtask status " + err.Error())
+			continue
+		}
+
+		log.Infof("point-in-time recovery: restored cluster %s", clusterName)
+	}
+}
+
+// FailPITRRestore records that the point-in-time recovery of a restore workflow failed, if the
+// workflow is that of a point-in-time recovery pgtask that is in progress
+func FailPITRRestore(restclient *rest.RESTClient, workflowID, namespace, message string) error {
+	if workflowID == "" {
+		return errors.New("a workflow ID is required")
+	}
+
+	taskList := crv1.PgtaskList{}
+	selector := crv1.PgtaskWorkflowID + "=" + workflowID
+	if err := kubeapi.GetpgtasksBySelector(restclient, &taskList, selector, namespace); err != nil {
+		return err
+	}
+
+	for i := range taskList.Items {
+		task := &taskList.Items[i]
+		if task.Spec.TaskType == crv1.PgtaskBackrestPITR && task.Spec.Status == crv1.PgtaskPITRInProgress {
+			failPITRRestore(restclient, task, namespace, message)
+		}
+	}
+
+	return nil
+}
+
+// failPITRRestore records the reason a point-in-time recovery pgtask failed
+func failPITRRestore(restclient *rest.RESTClient, task *crv1.Pgtask, namespace, message string) {
+	log.Errorf("point-in-time recovery: pgtask %s failed: %s", task.Spec.Name, message)
+
+	task.Spec.Status = crv1.PgtaskPITRFailed
+	task.Status.Message = message
+
+	if err := kubeapi.Updatepgtask(restclient, task, task.Spec.Name, namespace); err != nil {
+		log.Error("error in updating point-in-time recovery pgtask status " + err.Error())
+	}
+}
@@ -0,0 +1,85 @@
+package backrest
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"testing"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+)
+
+func TestValidatePITRTarget(t *testing.T) {
+	for _, test := range []struct {
+		parameters map[string]string
+		valid      bool
+	}{
+		{map[string]string{crv1.PgtaskPITRTargetTime: "2020-06-01 11:25:42.582117-04"}, true},
+		{map[string]string{crv1.PgtaskPITRTargetLSN: "0/3000060"}, true},
+		{map[string]string{crv1.PgtaskPITRTargetLSN: "16/B374D848", crv1.PgtaskPITRTargetExclusive: "true"}, true},
+		{map[string]string{}, false},
+		{map[string]string{crv1.PgtaskPITRTargetTime: "2020-06-01", crv1.PgtaskPITRTargetLSN: "0/3000060"}, false},
+		{map[string]string{crv1.PgtaskPITRTargetLSN: "3000060"}, false},
+		{map[string]string{crv1.PgtaskPITRTargetLSN: "0/3000060", crv1.PgtaskPITRTargetExclusive: "maybe"}, false},
+	} {
+		if err := validatePITRTarget(test.parameters); (err == nil) != test.valid {
+			t.Errorf("expected %v to be valid: %v, got %v", test.parameters, test.valid, err)
+		}
+	}
+}
+
+func TestPITRRestoreOptions(t *testing.T) {
+	for _, test := range []struct {
+		parameters map[string]string
+		expected   string
+	}{
+		{map[string]string{crv1.PgtaskPITRTargetTime: "2020-06-01 11:25:42.582117-04"},
+			"--type=time --target-action=promote"},
+		{map[string]string{crv1.PgtaskPITRTargetLSN: "0/3000060", crv1.PgtaskPITRTargetExclusive: "true"},
+			"--type=lsn --target-exclusive --target-action=promote"},
+	} {
+		if options := pitrRestoreOptions(test.parameters); options != test.expected {
+			t.Errorf("expected %q, got %q", test.expected, options)
+		}
+	}
+}
+
+func TestCloneRestoreOptions(t *testing.T) {
+	for _, test := range []struct {
+		clone   crv1.CloneSpec
+		options string
+		target  string
+		valid   bool
+	}{
+		{crv1.CloneSpec{SourceClusterName: "hippo"}, "--delta", "", true},
+		{crv1.CloneSpec{SourceClusterName: "hippo", TargetTime: "2020-06-01 11:25:42.582117-04"},
+			"--delta --type=time --target-action=promote", "2020-06-01 11:25:42.582117-04", true},
+		{crv1.CloneSpec{SourceClusterName: "hippo", TargetLSN: "0/3000060", TargetExclusive: true},
+			"--delta --type=lsn --target-exclusive --target-action=promote", "0/3000060", true},
+		{crv1.CloneSpec{SourceClusterName: "hippo", TargetLSN: "3000060"}, "", "", false},
+	} {
+		if err := ValidateCloneTarget(test.clone); (err == nil) != test.valid {
+			t.Errorf("expected %+v to be valid: %v, got %v", test.clone, test.valid, err)
+		}
+		if !test.valid {
+			continue
+		}
+
+		options, target := CloneRestoreOptions(test.clone)
+		if options != test.options || target != test.target {
+			t.Errorf("expected %q to %q, got %q to %q", test.options, test.target, options, target)
+		}
+	}
+}
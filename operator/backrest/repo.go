@@ -53,6 +53,8 @@ type RepoDeploymentTemplateFields struct {
 	PgbackrestS3EnvVars       string
 	Name                      string
 	ClusterName               string
+	NodeSelectorLabels        string
+	Tolerations               string
 	PodAntiAffinity           string
 	PodAntiAffinityLabelName  string
 	PodAntiAffinityLabelValue string
@@ -103,7 +105,7 @@ func CreateRepoDeployment(clientset *kubernetes.Clientset, namespace string, clu
 	fields := RepoDeploymentTemplateFields{
 		PGOImagePrefix:        operator.Pgo.Pgo.PGOImagePrefix,
 		PGOImageTag:           operator.Pgo.Pgo.PGOImageTag,
-		ContainerResources:    "",
+		ContainerResources:    operator.GetContainerResourcesWithEphemeralStorageJSON(&crv1.PgContainerResources{}, cluster.Spec.EphemeralStorage),
 		BackrestRepoClaimName: repoName,
 		SshdSecretsName:       "pgo-backrest-repo-config",
 		PGbackrestDBHost:      cluster.Name,
@@ -117,6 +119,8 @@ func CreateRepoDeployment(clientset *kubernetes.Clientset, namespace string, clu
 		Name:                  serviceName,
 		ClusterName:           cluster.Name,
 		SecurityContext:       util.GetPodSecurityContext(cluster.Spec.PrimaryStorage.GetSupplementalGroups()),
+		NodeSelectorLabels:    operator.GetNodeSelectorJSON(cluster),
+		Tolerations:           operator.GetTolerationsJSON(cluster),
 		PodAntiAffinity: operator.GetPodAntiAffinity(cluster,
 			crv1.PodAntiAffinityDeploymentPgBackRest, cluster.Spec.PodAntiAffinity.PgBackRest),
 		PodAntiAffinityLabelName: config.LABEL_POD_ANTI_AFFINITY,
@@ -146,6 +150,28 @@ func CreateRepoDeployment(clientset *kubernetes.Clientset, namespace string, clu
 	operator.SetContainerImageOverride(config.CONTAINER_IMAGE_PGO_BACKREST_REPO,
 		&deployment.Spec.Template.Spec.Containers[0])
 
+	// mount the configuration of the additional pgBackRest repositories, if there are any
+	operator.AddBackrestReposVolume(cluster, &deployment.Spec.Template.Spec)
+
+	// mount the credentials of the repository from its secret store, if it has one
+	operator.AddBackrestSecretStoreVolume(cluster, &deployment.Spec.Template.Spec)
+
+	// apply the scheduling of the cluster, along with that of the repository
+	operator.AddPodScheduling(cluster, &cluster.Spec.BackrestRepoScheduling, &deployment.Spec.Template.Spec)
+
+	// set the custom labels and annotations of the repository
+	operator.AddCustomMetadata(cluster, &cluster.Spec.Metadata.PgBackRest, &deployment.ObjectMeta,
+		&deployment.Spec.Template.ObjectMeta)
+
+	// set the chargeback labels of the Namespace
+	operator.AddChargebackLabels(clientset, namespace, &deployment.ObjectMeta,
+		&deployment.Spec.Template.ObjectMeta)
+
+	// the repository takes its workload identity from the ServiceAccount of pgBackRest
+	if operator.UsesBackrestWorkloadIdentity(cluster) {
+		deployment.Spec.Template.Spec.ServiceAccountName = operator.BackrestIdentityServiceAccount
+	}
+
 	err = kubeapi.CreateDeployment(clientset, &deployment, namespace)
 
 	return err
@@ -19,6 +19,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"os"
 	"strings"
 	"time"
@@ -55,9 +56,14 @@ type BackrestRestoreJobTemplateFields struct {
 	PgbackrestRepoType     string
 	PgbackrestS3EnvVars    string
 	NodeSelector           string
+	NodeSelectorLabels     string
+	Tolerations            string
 	Tablespaces            string
 	TablespaceVolumes      string
 	TablespaceVolumeMounts string
+	// WALPVCName is the name of the PVC of the write-ahead log of the instance
+	// that is restored, if its cluster has WAL storage
+	WALPVCName string
 }
 
 // Restore ...
@@ -148,7 +154,7 @@ func Restore(restclient *rest.RESTClient, namespace string, clientset *kubernete
 	// also use it as an opportunity to create the new PVCs for each tablespace
 	// if there is an error at any step in the process, return
 	tablespaceMountsMap := map[string]string{}
-	for tablespaceName, storageSpec := range cluster.Spec.TablespaceMounts {
+	for tablespaceName, storageSpec := range cluster.Spec.GetTablespaces() {
 		tablespaceMountsMap[tablespaceName] = storageSpec.StorageType
 
 		// get the tablespace PVC name!
@@ -161,6 +167,16 @@ func Restore(restclient *rest.RESTClient, namespace string, clientset *kubernete
 		}
 	}
 
+	// the write-ahead log of the restored instance is kept on a volume of its own, if the
+	// cluster has WAL storage
+	if walPVCName := operator.GetWALPVCName(&cluster, pvcName); walPVCName != "" {
+		if err := createPVC(clientset, restclient, namespace, clusterName, walPVCName,
+			cluster.Spec.WALStorage); err != nil {
+			log.Error(err)
+			return
+		}
+	}
+
 	//sleep for a bit to give the bounce time to take effect and let
 	//the backrest repo container come back and be able to service requests
 	time.Sleep(time.Second * time.Duration(30))
@@ -168,13 +184,15 @@ func Restore(restclient *rest.RESTClient, namespace string, clientset *kubernete
 	//create the Job to run the backrest restore container
 
 	workflowID := task.Spec.Parameters[crv1.PgtaskWorkflowID]
+	commandOpts := AddWALRestoreOptions(task.Spec.Parameters[config.LABEL_BACKREST_RESTORE_OPTS],
+		&cluster, pvcName)
 	jobFields := BackrestRestoreJobTemplateFields{
 		JobName:                "restore-" + task.Spec.Parameters[config.LABEL_BACKREST_RESTORE_FROM_CLUSTER] + "-" + util.RandStringBytesRmndr(4),
 		ClusterName:            task.Spec.Parameters[config.LABEL_BACKREST_RESTORE_FROM_CLUSTER],
 		SecurityContext:        util.GetPodSecurityContext(storage.GetSupplementalGroups()),
 		ToClusterPVCName:       pvcName,
 		WorkflowID:             workflowID,
-		CommandOpts:            task.Spec.Parameters[config.LABEL_BACKREST_RESTORE_OPTS],
+		CommandOpts:            commandOpts,
 		PITRTarget:             task.Spec.Parameters[config.LABEL_BACKREST_PITR_TARGET],
 		PGOImagePrefix:         operator.Pgo.Pgo.PGOImagePrefix,
 		PGOImageTag:            operator.Pgo.Pgo.PGOImageTag,
@@ -187,6 +205,9 @@ func Restore(restclient *rest.RESTClient, namespace string, clientset *kubernete
 		PgbackrestS3EnvVars:    operator.GetPgbackrestS3EnvVars(cluster, clientset, namespace),
 		TablespaceVolumes:      operator.GetTablespaceVolumesJSON(pvcName, tablespaceMountsMap),
 		TablespaceVolumeMounts: operator.GetTablespaceVolumeMountsJSON(tablespaceMountsMap),
+		WALPVCName:             operator.GetWALPVCName(&cluster, pvcName),
+		NodeSelectorLabels:     operator.GetNodeSelectorJSON(&cluster),
+		Tolerations:            operator.GetTolerationsJSON(&cluster),
 	}
 
 	jobTemplate := bytes.Buffer{}
@@ -211,6 +232,16 @@ func Restore(restclient *rest.RESTClient, namespace string, clientset *kubernete
 	operator.SetContainerImageOverride(config.CONTAINER_IMAGE_PGO_BACKREST_RESTORE,
 		&job.Spec.Template.Spec.Containers[0])
 
+	// mount the credentials of the repository from its secret store, if it has one
+	operator.AddBackrestSecretStoreVolume(&cluster, &job.Spec.Template.Spec)
+
+	// apply the node affinity and the PriorityClass of the cluster
+	operator.AddPodScheduling(&cluster, nil, &job.Spec.Template.Spec)
+
+	// set the chargeback labels of the Namespace
+	operator.AddChargebackLabels(clientset, namespace, &job.ObjectMeta,
+		&job.Spec.Template.ObjectMeta)
+
 	if jobName, err := kubeapi.CreateJob(clientset, &job, namespace); err != nil {
 		log.Error(err)
 		log.Error("restore workflow: error in creating restore job")
@@ -272,6 +303,12 @@ func updateWorkflow(restclient *rest.RESTClient, workflowID, namespace, status s
 
 	task := taskList.Items[0]
 	task.Spec.Parameters[status] = time.Now().Format(time.RFC3339)
+
+	// a point-in-time recovery pgtask is the workflow of its restore, and shows where it is at
+	if task.Spec.TaskType == crv1.PgtaskBackrestPITR && task.Spec.Status == crv1.PgtaskPITRInProgress {
+		task.Status.Message = "restoring cluster " + task.ObjectMeta.Labels[config.LABEL_PG_CLUSTER] +
+			" to " + pitrTarget(task.Spec.Parameters) + ": " + status
+	}
 	err = kubeapi.Updatepgtask(restclient, &task, task.Name, namespace)
 	if err != nil {
 		log.Errorf("restore workflow error: could not update workflow %s to status %s", workflowID, status)
@@ -280,6 +317,19 @@ func updateWorkflow(restclient *rest.RESTClient, workflowID, namespace, status s
 	return err
 }
 
+// AddWALRestoreOptions adds the pgBackRest restore option that links pg_wal of the data
+// directory that is restored to the directory of the instance on its WAL volume to the options
+// of a restore, if the cluster has WAL storage. The backups of such a cluster have pg_wal as a
+// link, which is otherwise restored to where it pointed to on the instance that was backed up
+func AddWALRestoreOptions(commandOpts string, cluster *crv1.Pgcluster, instanceName string) string {
+	walDir := operator.GetWALDir(cluster, instanceName)
+	if walDir == "" {
+		return commandOpts
+	}
+
+	return strings.TrimSpace(commandOpts + " --link-map=pg_wal=" + walDir)
+}
+
 // createPVC creates any persistent volume claims (PVCs) that are required to
 // restore a PostgreSQL cluster, including the PostgreSQL data volume as well
 // as tablespaces.
@@ -347,7 +397,13 @@ func CreateRestoredDeployment(restclient *rest.RESTClient, cluster *crv1.Pgclust
 	}
 
 	// set up a map of the names of the tablespaces as well as the storage classes
-	tablespaceStorageTypeMap := operator.GetTablespaceStorageTypeMap(cluster.Spec.TablespaceMounts)
+	tablespaceStorageTypeMap := operator.GetTablespaceStorageTypeMap(cluster.Spec.GetTablespaces())
+
+	runtimeClassName, err := operator.GetRuntimeClassName(clientset, cluster, "")
+	if err != nil {
+		log.Error(err)
+		return err
+	}
 
 	deploymentFields := operator.DeploymentTemplateFields{
 		Name:              restoreToName,
@@ -375,10 +431,14 @@ func CreateRestoredDeployment(restclient *rest.RESTClient, cluster *crv1.Pgclust
 		NodeSelector:      affinityStr,
 		PodAntiAffinity: operator.GetPodAntiAffinity(cluster,
 			crv1.PodAntiAffinityDeploymentDefault, cluster.Spec.PodAntiAffinity.Default),
-		ContainerResources: operator.GetContainerResourcesJSON(&cluster.Spec.ContainerResources),
+		ContainerResources: operator.GetContainerResourcesWithEphemeralStorageJSON(&cluster.Spec.ContainerResources, cluster.Spec.EphemeralStorage),
+		NodeSelectorLabels: operator.GetNodeSelectorJSON(cluster),
+		Tolerations:        operator.GetTolerationsJSON(cluster),
+		RuntimeClassName:   runtimeClassName,
 		ConfVolume:         operator.GetConfVolume(clientset, cluster, namespace),
-		CollectAddon:       operator.GetCollectAddon(clientset, namespace, &cluster.Spec),
+		CollectAddon:       operator.GetCollectAddon(&cluster.Spec),
 		CollectVolume:      operator.GetCollectVolume(clientset, cluster, namespace),
+		CollectQueries:     operator.GetCollectQueries(&cluster.Spec),
 		BadgerAddon:        operator.GetBadgerAddon(clientset, namespace, cluster, restoreToName),
 		ScopeLabel:         config.LABEL_PGHA_SCOPE,
 		Standby:            false, // always disabled since standby clusters cannot be restored
@@ -388,13 +448,18 @@ func CreateRestoredDeployment(restclient *rest.RESTClient, cluster *crv1.Pgclust
 		EnableCrunchyadm:         operator.Pgo.Cluster.EnableCrunchyadm,
 		ReplicaReinitOnStartFail: !operator.Pgo.Cluster.DisableReplicaStartFailReinit,
 		SyncReplication:          operator.GetSyncReplication(cluster.Spec.SyncReplication),
-		Tablespaces:              operator.GetTablespaceNames(cluster.Spec.TablespaceMounts),
+		Tablespaces:              operator.GetTablespaceNames(cluster.Spec.GetTablespaces()),
 		TablespaceVolumes:        operator.GetTablespaceVolumesJSON(restoreToName, tablespaceStorageTypeMap),
 		TablespaceVolumeMounts:   operator.GetTablespaceVolumeMountsJSON(tablespaceStorageTypeMap),
+		WALPVCName:               operator.GetWALPVCName(cluster, restoreToName),
+		WALDir:                   operator.GetWALDir(cluster, restoreToName),
 		TLSEnabled:               cluster.Spec.TLS.IsTLSEnabled(),
 		TLSOnly:                  cluster.Spec.TLSOnly,
-		TLSSecret:                cluster.Spec.TLS.TLSSecret,
-		CASecret:                 cluster.Spec.TLS.CASecret,
+		TLSSecret:                operator.GetTLSSecretName(cluster),
+		CASecret:                 operator.GetTLSCASecretName(cluster),
+		TLSReplicationSecret:     operator.GetTLSReplicationSecretName(cluster),
+		StartupGate: operator.GetStartupGateJSON(cluster, fmt.Sprintf("%s/%s:%s",
+			operator.Pgo.Cluster.CCPImagePrefix, cluster.Spec.CCPImage, cluster.Spec.CCPImageTag)),
 	}
 
 	log.Debug("collectaddon value is [" + deploymentFields.CollectAddon + "]")
@@ -419,6 +484,30 @@ func CreateRestoredDeployment(restclient *rest.RESTClient, cluster *crv1.Pgclust
 	// determine if any of the container images need to be overridden
 	operator.OverrideClusterContainerImages(deployment.Spec.Template.Spec.Containers)
 
+	// mount the credentials of the repository from its secret store, if it has one
+	operator.AddBackrestSecretStoreVolume(cluster, &deployment.Spec.Template.Spec)
+
+	// spread the instances of the cluster across nodes and zones
+	operator.AddInstancePlacement(cluster, &deployment.Spec.Template.Spec)
+
+	// apply the node affinity and the PriorityClass of the cluster
+	operator.AddPodScheduling(cluster, nil, &deployment.Spec.Template.Spec)
+
+	// set the custom labels and annotations of the instances
+	operator.AddCustomMetadata(cluster, &cluster.Spec.Metadata.Postgres, &deployment.ObjectMeta,
+		&deployment.Spec.Template.ObjectMeta)
+
+	// set the chargeback labels of the Namespace
+	operator.AddChargebackLabels(clientset, namespace, &deployment.ObjectMeta,
+		&deployment.Spec.Template.ObjectMeta)
+
+	// add the containers, init containers and volumes of the spec of the cluster
+	operator.SetInstancePod(cluster, &deployment.Spec.Template)
+
+	// hold back the Pods behind the scheduling gate of the cluster, if it has one
+	operator.AddSchedulingGate(cluster, fmt.Sprintf("%s/%s:%s", operator.Pgo.Cluster.CCPImagePrefix,
+		cluster.Spec.CCPImage, cluster.Spec.CCPImageTag), &deployment.Spec.Template)
+
 	err = kubeapi.CreateDeployment(clientset, &deployment, namespace)
 	if err != nil {
 		return err
@@ -0,0 +1,44 @@
+package backrest
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"testing"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+)
+
+func TestAddWALRestoreOptions(t *testing.T) {
+	cluster := &crv1.Pgcluster{}
+
+	// the WAL is restored into the data directory of a cluster without WAL storage
+	if opts := AddWALRestoreOptions("--delta", cluster, "hippo-abcd"); opts != "--delta" {
+		t.Errorf("expected the options to be left alone, got %q", opts)
+	}
+
+	cluster.Spec.WALStorage = crv1.PgStorageSpec{StorageType: "dynamic"}
+
+	for _, test := range []struct {
+		commandOpts, expected string
+	}{
+		{"", "--link-map=pg_wal=/pgwal/hippo-abcd-wal"},
+		{"--delta", "--delta --link-map=pg_wal=/pgwal/hippo-abcd-wal"},
+	} {
+		if opts := AddWALRestoreOptions(test.commandOpts, cluster, "hippo-abcd"); opts != test.expected {
+			t.Errorf("expected %q for %q, got %q", test.expected, test.commandOpts, opts)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package backrest
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/apiserver/backupoptions"
+	msgs "github.com/crunchydata/postgres-operator/apiservermsgs"
+	"github.com/crunchydata/postgres-operator/config"
+	"github.com/crunchydata/postgres-operator/kubeapi"
+	"github.com/crunchydata/postgres-operator/util"
+	log "github.com/sirupsen/logrus"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// scheduledBackupJobTimeout is how long the Job of the previous backup of a schedule is waited on
+// to be deleted before the next backup is taken
+const scheduledBackupJobTimeout = 60 * time.Second
+
+// scheduleOptionsDenyChars are the characters the options of a pgschedule cannot contain, as
+// they are passed on to a shell in the backup Job
+const scheduleOptionsDenyChars = "&|;<>$`\\\"'(){}\r\n"
+
+// ValidateSchedule returns an error if a pgschedule does not have a valid cron schedule, backup
+// type, retention, storage type or options. The options are held to the same pgBackRest backup
+// options as those of "pgo backup"
+func ValidateSchedule(schedule *crv1.Pgschedule) error {
+	spec := schedule.Spec
+
+	if spec.ClusterName == "" {
+		return errors.New("a cluster name is required")
+	}
+
+	if _, err := util.ScheduleParser.Parse(spec.Schedule); err != nil {
+		return fmt.Errorf("%q is not a valid schedule: %s", spec.Schedule, err)
+	}
+
+	switch spec.BackupType {
+	case crv1.PgscheduleBackupTypeFull, crv1.PgscheduleBackupTypeDiff, crv1.PgscheduleBackupTypeIncr:
+	default:
+		return fmt.Errorf("%q is not a valid backup type, it must be one of %s, %s or %s",
+			spec.BackupType, crv1.PgscheduleBackupTypeFull, crv1.PgscheduleBackupTypeDiff,
+			crv1.PgscheduleBackupTypeIncr)
+	}
+
+	if spec.Retention < 0 {
+		return fmt.Errorf("%d is not a valid retention, it cannot be negative", spec.Retention)
+	}
+
+	if spec.StorageType != "" && !util.IsValidBackrestStorageType(spec.StorageType) {
+		return fmt.Errorf("%q is not a valid storage type, it must be one of %s, of which only "+
+			"local and s3 can be combined", spec.StorageType, strings.Join(crv1.BackrestStorageTypes, ", "))
+	}
+
+	if strings.ContainsAny(spec.Options, scheduleOptionsDenyChars) {
+		return fmt.Errorf("%q are not valid options, they cannot contain any of %q", spec.Options,
+			scheduleOptionsDenyChars)
+	}
+
+	if err := backupoptions.ValidateBackupOpts(spec.Options,
+		&msgs.CreateBackrestBackupRequest{}); err != nil {
+		return fmt.Errorf("%q are not valid options: %s", spec.Options, err)
+	}
+
+	return nil
+}
+
+// ScheduledBackupDue returns whether a backup of a pgschedule is due at the given time, i.e.
+// whether the schedule has come up since the last backup was scheduled, or since the pgschedule
+// was created if none has been. A backup that is missed while the Operator is not running is
+// therefore taken once it runs again
+func ScheduledBackupDue(schedule *crv1.Pgschedule, now time.Time) (bool, error) {
+	sched, err := util.ScheduleParser.Parse(schedule.Spec.Schedule)
+	if err != nil {
+		return false, err
+	}
+
+	last := schedule.CreationTimestamp.Time
+	if schedule.Status.LastScheduleTime != "" {
+		if last, err = time.Parse(time.RFC3339, schedule.Status.LastScheduleTime); err != nil {
+			return false, err
+		}
+	}
+
+	return !sched.Next(last).After(now), nil
+}
+
+// scheduledBackupOptions returns the pgBackRest options of the backups of a pgschedule
+func scheduledBackupOptions(spec crv1.PgscheduleSpec) string {
+	options := []string{"--type=" + spec.BackupType}
+
+	if spec.Retention > 0 {
+		options = append(options, fmt.Sprintf("--repo1-retention-full=%d", spec.Retention))
+	}
+
+	if spec.Options != "" {
+		options = append(options, spec.Options)
+	}
+
+	return strings.Join(options, " ")
+}
+
+// CreateScheduledBackup creates the pgtask of a backup of a pgschedule, which backs up its
+// cluster from the pgBackRest repository, and returns the name of the pgtask. The pgtask and Job
+// of the previous backup of the schedule are removed first: as long as the previous backup is
+// still running the next one is not taken
+func CreateScheduledBackup(clientset *kubernetes.Clientset, restclient *rest.RESTClient,
+	schedule *crv1.Pgschedule) (string, error) {
+	namespace := schedule.Namespace
+	clusterName := schedule.Spec.ClusterName
+
+	cluster := crv1.Pgcluster{}
+	found, err := kubeapi.Getpgcluster(restclient, &cluster, clusterName, namespace)
+	if !found {
+		return "", fmt.Errorf("cluster %s not found", clusterName)
+	} else if err != nil {
+		return "", err
+	}
+
+	if cluster.Status.State != crv1.PgclusterStateInitialized {
+		return "", fmt.Errorf("cluster %s is not initialized", clusterName)
+	}
+
+	taskName := schedule.Name + "-scheduled-backup"
+
+	if job, found := kubeapi.GetJob(clientset, taskName, namespace); found {
+		if job.Status.Active > 0 {
+			return "", fmt.Errorf("the previous backup %s is still running", taskName)
+		}
+
+		if err := kubeapi.DeleteJob(clientset, taskName, namespace); err != nil {
+			return "", err
+		}
+
+		if err := kubeapi.IsJobDeleted(clientset, namespace, job, scheduledBackupJobTimeout); err != nil {
+			return "", err
+		}
+	}
+
+	task := crv1.Pgtask{}
+	if found, _ := kubeapi.Getpgtask(restclient, &task, taskName, namespace); found {
+		if err := kubeapi.Deletepgtask(restclient, taskName, namespace); err != nil {
+			return "", err
+		}
+	}
+
+	selector := fmt.Sprintf("%s=%s,%s=true", config.LABEL_PG_CLUSTER, clusterName,
+		config.LABEL_PGO_BACKREST_REPO)
+	pods, err := kubeapi.GetPods(clientset, selector, namespace)
+	if err != nil {
+		return "", err
+	}
+
+	if len(pods.Items) != 1 {
+		return "", fmt.Errorf("expected 1 pgBackRest repository pod for cluster %s, found %d",
+			clusterName, len(pods.Items))
+	}
+
+	storageType := schedule.Spec.StorageType
+	if storageType == "" {
+		storageType = cluster.Spec.UserLabels[config.LABEL_BACKREST_STORAGE_TYPE]
+	}
+
+	backup := &crv1.Pgtask{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name: taskName,
+			Labels: map[string]string{
+				config.LABEL_PG_CLUSTER: clusterName,
+				config.LABEL_PGOUSER:    cluster.ObjectMeta.Labels[config.LABEL_PGOUSER],
+			},
+		},
+		Spec: crv1.PgtaskSpec{
+			Name:     taskName,
+			TaskType: crv1.PgtaskBackrest,
+			Parameters: map[string]string{
+				config.LABEL_JOB_NAME:              taskName,
+				config.LABEL_PG_CLUSTER:            clusterName,
+				config.LABEL_POD_NAME:              pods.Items[0].Name,
+				config.LABEL_CONTAINER_NAME:        "database",
+				config.LABEL_BACKREST_COMMAND:      crv1.PgtaskBackrestBackup,
+				config.LABEL_BACKREST_OPTS:         "--stanza=db " + scheduledBackupOptions(schedule.Spec),
+				config.LABEL_BACKREST_STORAGE_TYPE: storageType,
+			},
+		},
+	}
+
+	if err := kubeapi.Createpgtask(restclient, backup, namespace); err != nil {
+		return "", err
+	}
+
+	log.Debugf("pgschedule %s: created backup %s of cluster %s", schedule.Name, taskName, clusterName)
+
+	return taskName, nil
+}
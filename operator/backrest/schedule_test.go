@@ -0,0 +1,75 @@
+package backrest
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"strings"
+	"testing"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+)
+
+func TestValidateSchedule(t *testing.T) {
+	valid := crv1.PgscheduleSpec{
+		ClusterName: "hacluster",
+		Schedule:    "0 1 * * *",
+		BackupType:  crv1.PgscheduleBackupTypeFull,
+		Retention:   2,
+		StorageType: "local,s3",
+		Options:     "--compress-level=3",
+	}
+
+	if err := ValidateSchedule(&crv1.Pgschedule{Spec: valid}); err != nil {
+		t.Fatalf("expected a valid schedule, got %s", err)
+	}
+
+	for _, test := range []struct {
+		change func(*crv1.PgscheduleSpec)
+		reason string
+	}{
+		{func(spec *crv1.PgscheduleSpec) { spec.ClusterName = "" }, "cluster name"},
+		{func(spec *crv1.PgscheduleSpec) { spec.BackupType = "snapshot" }, "backup type"},
+		{func(spec *crv1.PgscheduleSpec) { spec.Retention = -1 }, "retention"},
+		{func(spec *crv1.PgscheduleSpec) { spec.StorageType = "local,gcs" }, "storage type"},
+		{func(spec *crv1.PgscheduleSpec) { spec.Options = "--compress-level=3; rm -rf /" }, "options"},
+		{func(spec *crv1.PgscheduleSpec) { spec.Options = "--log-path=$(id)" }, "options"},
+		{func(spec *crv1.PgscheduleSpec) { spec.Options = "--config=/tmp/pgbackrest.conf" }, "options"},
+	} {
+		spec := valid
+		test.change(&spec)
+
+		err := ValidateSchedule(&crv1.Pgschedule{Spec: spec})
+		if err == nil || !strings.Contains(err.Error(), test.reason) {
+			t.Errorf("expected an invalid %s, got %v", test.reason, err)
+		}
+	}
+}
+
+func TestScheduledBackupOptions(t *testing.T) {
+	for _, test := range []struct {
+		spec     crv1.PgscheduleSpec
+		expected string
+	}{
+		{crv1.PgscheduleSpec{BackupType: "incr"}, "--type=incr"},
+		{crv1.PgscheduleSpec{BackupType: "full", Retention: 3}, "--type=full --repo1-retention-full=3"},
+		{crv1.PgscheduleSpec{BackupType: "diff", Options: "--compress-level=3"},
+			"--type=diff --compress-level=3"},
+	} {
+		if options := scheduledBackupOptions(test.spec); options != test.expected {
+			t.Errorf("expected %q, got %q", test.expected, options)
+		}
+	}
+}
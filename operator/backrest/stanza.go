@@ -16,6 +16,7 @@ package backrest
 */
 
 import (
+	"fmt"
 	"strings"
 
 	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
@@ -106,3 +107,68 @@ func StanzaCreate(namespace, clusterName string, clientset *kubernetes.Clientset
 	}
 
 }
+
+// CreateReposStanza creates the stanza of the cluster in its additional pgBackRest repositories.
+// pgBackRest creates the stanza in every repository it is configured with, leaving alone those
+// that already have it, so this is run whenever a repository is added. Any previous run of it is
+// replaced
+func CreateReposStanza(clientset *kubernetes.Clientset, restclient *rest.RESTClient,
+	cluster *crv1.Pgcluster) error {
+	namespace := cluster.Namespace
+	taskName := cluster.Name + "-repos-" + crv1.PgtaskBackrestStanzaCreate
+
+	if job, found := kubeapi.GetJob(clientset, taskName, namespace); found {
+		if err := kubeapi.DeleteJob(clientset, taskName, namespace); err != nil {
+			return err
+		}
+
+		if err := kubeapi.IsJobDeleted(clientset, namespace, job, scheduledBackupJobTimeout); err != nil {
+			return err
+		}
+	}
+
+	task := crv1.Pgtask{}
+	if found, _ := kubeapi.Getpgtask(restclient, &task, taskName, namespace); found {
+		if err := kubeapi.Deletepgtask(restclient, taskName, namespace); err != nil {
+			return err
+		}
+	}
+
+	selector := fmt.Sprintf("%s=%s,%s=true", config.LABEL_PG_CLUSTER, cluster.Name,
+		config.LABEL_PGO_BACKREST_REPO)
+	pods, err := kubeapi.GetPods(clientset, selector, namespace)
+	if err != nil {
+		return err
+	}
+
+	if len(pods.Items) != 1 {
+		return fmt.Errorf("expected 1 pgBackRest repository pod for cluster %s, found %d",
+			cluster.Name, len(pods.Items))
+	}
+
+	stanza := &crv1.Pgtask{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name: taskName,
+			Labels: map[string]string{
+				config.LABEL_PG_CLUSTER: cluster.Name,
+				config.LABEL_PGOUSER:    cluster.ObjectMeta.Labels[config.LABEL_PGOUSER],
+			},
+		},
+		Spec: crv1.PgtaskSpec{
+			Name:     taskName,
+			TaskType: crv1.PgtaskBackrest,
+			Parameters: map[string]string{
+				config.LABEL_JOB_NAME:              taskName,
+				config.LABEL_PG_CLUSTER:            cluster.Name,
+				config.LABEL_POD_NAME:              pods.Items[0].Name,
+				config.LABEL_CONTAINER_NAME:        "pgo-backrest-repo",
+				config.LABEL_BACKREST_COMMAND:      crv1.PgtaskBackrestStanzaCreate,
+				config.LABEL_BACKREST_OPTS:         "",
+				config.LABEL_BACKREST_STORAGE_TYPE: cluster.Spec.UserLabels[config.LABEL_BACKREST_STORAGE_TYPE],
+				config.LABEL_PGBACKREST_REPOS:      "true",
+			},
+		},
+	}
+
+	return kubeapi.Createpgtask(restclient, stanza, namespace)
+}
@@ -0,0 +1,93 @@
+package backrest
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"fmt"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/config"
+	"github.com/crunchydata/postgres-operator/kubeapi"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// BackrestVerifyJobFormat is the name of the pgtask and the Job that verify the pgBackRest
+// repository of a cluster
+const BackrestVerifyJobFormat = "%s-backrest-verify"
+
+// CreateVerification creates the pgtask of a Job that runs "pgbackrest verify" against the
+// pgBackRest repository of the cluster. Any previous verification of the cluster is replaced
+func CreateVerification(clientset *kubernetes.Clientset, restclient *rest.RESTClient,
+	cluster *crv1.Pgcluster) error {
+	namespace := cluster.Namespace
+	taskName := fmt.Sprintf(BackrestVerifyJobFormat, cluster.Name)
+
+	if job, found := kubeapi.GetJob(clientset, taskName, namespace); found {
+		if err := kubeapi.DeleteJob(clientset, taskName, namespace); err != nil {
+			return err
+		}
+
+		if err := kubeapi.IsJobDeleted(clientset, namespace, job, scheduledBackupJobTimeout); err != nil {
+			return err
+		}
+	}
+
+	task := crv1.Pgtask{}
+	if found, _ := kubeapi.Getpgtask(restclient, &task, taskName, namespace); found {
+		if err := kubeapi.Deletepgtask(restclient, taskName, namespace); err != nil {
+			return err
+		}
+	}
+
+	selector := fmt.Sprintf("%s=%s,%s=true", config.LABEL_PG_CLUSTER, cluster.Name,
+		config.LABEL_PGO_BACKREST_REPO)
+	pods, err := kubeapi.GetPods(clientset, selector, namespace)
+	if err != nil {
+		return err
+	}
+
+	if len(pods.Items) != 1 {
+		return fmt.Errorf("expected 1 pgBackRest repository pod for cluster %s, found %d",
+			cluster.Name, len(pods.Items))
+	}
+
+	verify := &crv1.Pgtask{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name: taskName,
+			Labels: map[string]string{
+				config.LABEL_PG_CLUSTER: cluster.Name,
+				config.LABEL_PGOUSER:    cluster.ObjectMeta.Labels[config.LABEL_PGOUSER],
+			},
+		},
+		Spec: crv1.PgtaskSpec{
+			Name:     taskName,
+			TaskType: crv1.PgtaskBackrest,
+			Parameters: map[string]string{
+				config.LABEL_JOB_NAME:              taskName,
+				config.LABEL_PG_CLUSTER:            cluster.Name,
+				config.LABEL_POD_NAME:              pods.Items[0].Name,
+				config.LABEL_CONTAINER_NAME:        "pgo-backrest-repo",
+				config.LABEL_BACKREST_COMMAND:      crv1.PgtaskBackrestVerify,
+				config.LABEL_BACKREST_OPTS:         "",
+				config.LABEL_BACKREST_STORAGE_TYPE: cluster.Spec.UserLabels[config.LABEL_BACKREST_STORAGE_TYPE],
+			},
+		},
+	}
+
+	return kubeapi.Createpgtask(restclient, verify, namespace)
+}
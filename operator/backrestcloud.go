@@ -0,0 +1,181 @@
+package operator
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/config"
+	"github.com/crunchydata/postgres-operator/util"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	// BackrestCloudEnvType is the environment variable of the type of the pgBackRest repository,
+	// which is set on every container that runs pgBackRest against GCS or Azure
+	BackrestCloudEnvType = "PGBACKREST_REPO1_TYPE"
+
+	backrestGCSEnvBucket        = "PGBACKREST_REPO1_GCS_BUCKET"
+	backrestGCSEnvEndpoint      = "PGBACKREST_REPO1_GCS_ENDPOINT"
+	backrestGCSEnvKey           = "PGBACKREST_REPO1_GCS_KEY"
+	backrestAzureEnvContainer   = "PGBACKREST_REPO1_AZURE_CONTAINER"
+	backrestAzureEnvEndpoint    = "PGBACKREST_REPO1_AZURE_ENDPOINT"
+	backrestAzureEnvAccount     = "PGBACKREST_REPO1_AZURE_ACCOUNT"
+	backrestAzureEnvKey         = "PGBACKREST_REPO1_AZURE_KEY"
+	backrestRepoSecretMountPath = "/sshd"
+
+	// EventReasonInvalidBackrestCloud is the reason of the Warning events that are recorded when
+	// the GCS or Azure settings of a cluster are invalid
+	EventReasonInvalidBackrestCloud = "InvalidBackrestCloud"
+)
+
+// GetBackrestCloudType returns the type of the object storage, "gcs" or "azure", that the
+// pgBackRest repository of the cluster is stored in, if it is stored in either
+func GetBackrestCloudType(cluster *crv1.Pgcluster) string {
+	switch storageType := cluster.Spec.UserLabels[config.LABEL_BACKREST_STORAGE_TYPE]; storageType {
+	case "gcs", "azure":
+		return storageType
+	}
+
+	return ""
+}
+
+// GetBackrestCloudEnvVars returns the pgBackRest environment variables of the GCS or Azure
+// repository of the cluster that can be changed once the cluster exists, i.e. where the repository
+// is stored. A variable with an empty value is not set
+func GetBackrestCloudEnvVars(cluster *crv1.Pgcluster) map[string]string {
+	switch GetBackrestCloudType(cluster) {
+	case "gcs":
+		return map[string]string{
+			backrestGCSEnvBucket:   cluster.Spec.BackrestGCS.Bucket,
+			backrestGCSEnvEndpoint: cluster.Spec.BackrestGCS.Endpoint,
+		}
+	case "azure":
+		return map[string]string{
+			backrestAzureEnvContainer: cluster.Spec.BackrestAzure.Container,
+			backrestAzureEnvEndpoint:  cluster.Spec.BackrestAzure.Endpoint,
+		}
+	}
+
+	return map[string]string{}
+}
+
+// GetPgbackrestCloudEnvVars returns the pgBackRest environment variables that have the PostgreSQL
+// instances, the pgBackRest repository and the restore Jobs of the cluster use a repository that
+// is stored in GCS or Azure, formatted for inclusion in the "env" of their templates in place of
+// those of S3. The credentials are projected from the pgBackRest Secret of the cluster: the
+// account and key of Azure as environment variables, and the service account key of GCS as the
+// file it is mounted as
+func GetPgbackrestCloudEnvVars(cluster crv1.Pgcluster) string {
+	storageType := GetBackrestCloudType(&cluster)
+	if storageType == "" {
+		return ""
+	}
+
+	secretName := fmt.Sprintf("%s-%s", cluster.Name, config.LABEL_BACKREST_REPO_SECRET)
+	envVars := []v1.EnvVar{{Name: BackrestCloudEnvType, Value: storageType}}
+
+	switch storageType {
+	case "gcs":
+		// with workload identity, the credentials come from the metadata server of GKE
+		if UsesBackrestWorkloadIdentity(&cluster) {
+			envVars = append(envVars, v1.EnvVar{Name: backrestGCSEnvKeyType, Value: backrestGCSKeyTypeAuto})
+			break
+		}
+
+		// with a secret store, the key is mounted along with the credentials of the store
+		if UsesBackrestSecretStore(&cluster) {
+			envVars = append(envVars, v1.EnvVar{Name: backrestGCSEnvKey, Value: getBackrestSecretStoreGCSKey()})
+			break
+		}
+
+		envVars = append(envVars, v1.EnvVar{
+			Name:  backrestGCSEnvKey,
+			Value: backrestRepoSecretMountPath + "/" + util.BackRestRepoSecretKeyGCSKey,
+		})
+	case "azure":
+		envVars = append(envVars,
+			newSecretKeyEnvVar(backrestAzureEnvAccount, secretName, util.BackRestRepoSecretKeyAzureAccount),
+			newSecretKeyEnvVar(backrestAzureEnvKey, secretName, util.BackRestRepoSecretKeyAzureKey))
+	}
+
+	values := GetBackrestCloudEnvVars(&cluster)
+	for _, name := range []string{backrestGCSEnvBucket, backrestGCSEnvEndpoint,
+		backrestAzureEnvContainer, backrestAzureEnvEndpoint} {
+		if values[name] != "" {
+			envVars = append(envVars, v1.EnvVar{Name: name, Value: values[name]})
+		}
+	}
+
+	doc := strings.Builder{}
+
+	for _, envVar := range envVars {
+		b, err := json.Marshal(envVar)
+		if err != nil {
+			log.Error(err)
+			return ""
+		}
+
+		doc.Write(b)
+		doc.WriteString(",\n")
+	}
+
+	return doc.String()
+}
+
+// ValidateBackrestCloud ensures that the GCS or Azure settings of the cluster can be used by
+// pgBackRest, so that a mistake is reported when the settings are applied rather than when WAL is
+// next archived. pgBackRest supports GCS as of version 2.33 and Azure as of version 2.28
+func ValidateBackrestCloud(cluster *crv1.Pgcluster) error {
+	switch GetBackrestCloudType(cluster) {
+	case "gcs":
+		if err := RequireBackrestVersion("pgBackRest storage type \"gcs\"",
+			BackrestVersionGCS); err != nil {
+			return err
+		}
+		if cluster.Spec.BackrestGCS.Bucket == "" {
+			return errors.New("a GCS bucket is required for pgBackRest storage type \"gcs\"")
+		}
+	case "azure":
+		if err := RequireBackrestVersion("pgBackRest storage type \"azure\"",
+			BackrestVersionAzure); err != nil {
+			return err
+		}
+		if cluster.Spec.BackrestAzure.Container == "" {
+			return errors.New("an Azure container is required for pgBackRest storage type \"azure\"")
+		}
+	}
+
+	return nil
+}
+
+// newSecretKeyEnvVar returns an environment variable whose value is the key of a Secret
+func newSecretKeyEnvVar(name, secretName, key string) v1.EnvVar {
+	return v1.EnvVar{
+		Name: name,
+		ValueFrom: &v1.EnvVarSource{
+			SecretKeyRef: &v1.SecretKeySelector{
+				LocalObjectReference: v1.LocalObjectReference{Name: secretName},
+				Key:                  key,
+			},
+		},
+	}
+}
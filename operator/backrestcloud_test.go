@@ -0,0 +1,124 @@
+package operator
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/config"
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestValidateBackrestCloud(t *testing.T) {
+	cluster := &crv1.Pgcluster{Spec: crv1.PgclusterSpec{
+		BackrestGCS: crv1.BackrestGCSSpec{Bucket: "backups"},
+		UserLabels:  map[string]string{config.LABEL_BACKREST_STORAGE_TYPE: "gcs"},
+	}}
+
+	if err := ValidateBackrestCloud(cluster); err == nil {
+		t.Error("expected the pgBackRest version of the images to be too early")
+	}
+
+	Pgo.Cluster.BackrestVersion = BackrestVersionGCS
+	defer func() { Pgo.Cluster.BackrestVersion = "" }()
+
+	for _, test := range []struct {
+		storageType string
+		spec        crv1.PgclusterSpec
+		valid       bool
+	}{
+		{"local", crv1.PgclusterSpec{}, true},
+		{"gcs", crv1.PgclusterSpec{BackrestGCS: crv1.BackrestGCSSpec{Bucket: "backups"}}, true},
+		{"gcs", crv1.PgclusterSpec{BackrestAzure: crv1.BackrestAzureSpec{Container: "backups"}}, false},
+		{"azure", crv1.PgclusterSpec{BackrestAzure: crv1.BackrestAzureSpec{Container: "backups"}}, true},
+		{"azure", crv1.PgclusterSpec{}, false},
+	} {
+		cluster := &crv1.Pgcluster{Spec: test.spec}
+		cluster.Spec.UserLabels = map[string]string{config.LABEL_BACKREST_STORAGE_TYPE: test.storageType}
+
+		if err := ValidateBackrestCloud(cluster); (err == nil) != test.valid {
+			t.Errorf("%s %+v: expected valid: %v, got %v", test.storageType, test.spec, test.valid, err)
+		}
+	}
+}
+
+func TestGetPgbackrestCloudEnvVars(t *testing.T) {
+	cluster := crv1.Pgcluster{}
+	cluster.Name = "hippo"
+	cluster.Spec.UserLabels = map[string]string{config.LABEL_BACKREST_STORAGE_TYPE: "s3"}
+
+	if envVars := GetPgbackrestCloudEnvVars(cluster); envVars != "" {
+		t.Errorf("expected no environment variables for S3, got %q", envVars)
+	}
+
+	cluster.Spec.UserLabels[config.LABEL_BACKREST_STORAGE_TYPE] = "azure"
+	cluster.Spec.BackrestAzure.Container = "backups"
+
+	// the environment variables are followed by those of the template, so they end with a comma
+	doc := GetPgbackrestCloudEnvVars(cluster)
+	if !strings.HasSuffix(doc, ",\n") {
+		t.Fatalf("expected a trailing comma, got %q", doc)
+	}
+
+	envVars := []v1.EnvVar{}
+	if err := json.Unmarshal([]byte("["+strings.TrimSuffix(doc, ",\n")+"]"), &envVars); err != nil {
+		t.Fatal(err)
+	}
+
+	values := map[string]v1.EnvVar{}
+	for _, envVar := range envVars {
+		values[envVar.Name] = envVar
+	}
+
+	if values["PGBACKREST_REPO1_TYPE"].Value != "azure" ||
+		values["PGBACKREST_REPO1_AZURE_CONTAINER"].Value != "backups" {
+		t.Errorf("unexpected environment variables %v", envVars)
+	}
+
+	if _, ok := values["PGBACKREST_REPO1_AZURE_ENDPOINT"]; ok {
+		t.Error("expected no endpoint when it is not set")
+	}
+
+	key := values["PGBACKREST_REPO1_AZURE_KEY"].ValueFrom
+	if key == nil || key.SecretKeyRef.Name != "hippo-backrest-repo-config" ||
+		key.SecretKeyRef.Key != "azure-key" {
+		t.Errorf("expected the key to be projected from the pgBackRest secret, got %v", key)
+	}
+}
+
+func TestGetPgbackrestCloudEnvVarsWorkloadIdentity(t *testing.T) {
+	cluster := crv1.Pgcluster{}
+	cluster.Spec.UserLabels = map[string]string{config.LABEL_BACKREST_STORAGE_TYPE: "gcs"}
+	cluster.Spec.BackrestGCS.Bucket = "backups"
+	cluster.Spec.BackrestIdentity = crv1.BackrestIdentitySpec{
+		UseWorkloadIdentity: true,
+		GCPServiceAccount:   "backup@project.iam.gserviceaccount.com",
+	}
+
+	doc := GetPgbackrestCloudEnvVars(cluster)
+
+	// the credentials come from the metadata server rather than the service account key
+	if strings.Contains(doc, "PGBACKREST_REPO1_GCS_KEY\"") {
+		t.Errorf("expected no service account key with workload identity, got %q", doc)
+	}
+
+	if !strings.Contains(doc, `{"name":"PGBACKREST_REPO1_GCS_KEY_TYPE","value":"auto"}`) {
+		t.Errorf("expected the key type to be auto with workload identity, got %q", doc)
+	}
+}
@@ -0,0 +1,152 @@
+package operator
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/config"
+	"github.com/crunchydata/postgres-operator/kubeapi"
+	log "github.com/sirupsen/logrus"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// BackrestIdentityServiceAccount is the ServiceAccount that pgBackRest runs with, which the
+	// pgBackRest repository runs with as well once it uses workload identity, as the default
+	// ServiceAccount is shared with e.g. pgBouncer
+	BackrestIdentityServiceAccount = "pgo-backrest"
+
+	// backrestIdentityPGServiceAccount is the ServiceAccount of the PostgreSQL instances, which
+	// archive WAL with pgBackRest
+	backrestIdentityPGServiceAccount = "pgo-pg"
+
+	// the annotations of a ServiceAccount that IAM Roles for Service Accounts and GKE Workload
+	// Identity take the identity of its Pods from
+	backrestIdentityAWSAnnotation = "eks.amazonaws.com/role-arn"
+	backrestIdentityGCPAnnotation = "iam.gke.io/gcp-service-account"
+
+	// the key types that have pgBackRest take its credentials from the environment of its Pod
+	// rather than from keys
+	backrestS3KeyTypeWebID = "web-id"
+	backrestGCSKeyTypeAuto = "auto"
+	backrestGCSEnvKeyType  = "PGBACKREST_REPO1_GCS_KEY_TYPE"
+
+	// EventReasonInvalidBackrestIdentity is the reason of the Warning events that are recorded
+	// when the workload identity of a cluster is invalid or cannot be applied
+	EventReasonInvalidBackrestIdentity = "InvalidBackrestIdentity"
+)
+
+// UsesBackrestWorkloadIdentity returns whether pgBackRest accesses the S3 or GCS repository of
+// the cluster with workload identity rather than with the keys of its Secret
+func UsesBackrestWorkloadIdentity(cluster *crv1.Pgcluster) bool {
+	return cluster.Spec.BackrestIdentity.UseWorkloadIdentity && getBackrestIdentityAnnotation(cluster) != ""
+}
+
+// ValidateBackrestIdentity ensures that the workload identity of the cluster names the identity of
+// the object storage that the repository of the cluster is stored in
+func ValidateBackrestIdentity(cluster *crv1.Pgcluster) error {
+	spec := cluster.Spec.BackrestIdentity
+	if !spec.UseWorkloadIdentity {
+		return nil
+	}
+
+	switch getBackrestIdentityAnnotation(cluster) {
+	case backrestIdentityAWSAnnotation:
+		if !strings.HasPrefix(spec.AWSRoleARN, "arn:") {
+			return fmt.Errorf("invalid AWS role ARN %q for workload identity", spec.AWSRoleARN)
+		}
+
+		return RequireBackrestVersion("workload identity for pgBackRest storage type \"s3\"",
+			BackrestVersionS3WebID)
+	case backrestIdentityGCPAnnotation:
+		if !strings.HasSuffix(spec.GCPServiceAccount, ".iam.gserviceaccount.com") {
+			return fmt.Errorf("invalid GCP service account %q for workload identity",
+				spec.GCPServiceAccount)
+		}
+
+		return RequireBackrestVersion("workload identity for pgBackRest storage type \"gcs\"",
+			BackrestVersionGCSAuto)
+	default:
+		return errors.New("workload identity is only supported for pgBackRest storage types \"s3\" and \"gcs\"")
+	}
+}
+
+// ReconcileBackrestIdentity annotates the ServiceAccounts of the PostgreSQL instances and of
+// pgBackRest in the namespace of the cluster with its workload identity. The ServiceAccounts are
+// shared by the clusters of the namespace, so one that is already annotated with another identity
+// is not changed and an error is returned instead
+func ReconcileBackrestIdentity(clientset *kubernetes.Clientset, cluster *crv1.Pgcluster) error {
+	if !UsesBackrestWorkloadIdentity(cluster) {
+		return nil
+	}
+
+	annotation := getBackrestIdentityAnnotation(cluster)
+	identity := getBackrestIdentity(cluster)
+
+	for _, name := range []string{backrestIdentityPGServiceAccount, BackrestIdentityServiceAccount} {
+		sa, found, err := kubeapi.GetServiceAccount(clientset, name, cluster.Namespace)
+		if !found {
+			return fmt.Errorf("service account %s not found for workload identity: %v", name, err)
+		}
+
+		if current := sa.Annotations[annotation]; current == identity {
+			continue
+		} else if current != "" {
+			return fmt.Errorf("service account %s is already annotated with %s %q, not %q", name,
+				annotation, current, identity)
+		}
+
+		if sa.Annotations == nil {
+			sa.Annotations = map[string]string{}
+		}
+		sa.Annotations[annotation] = identity
+
+		log.Debugf("annotating service account %s with %s %q", name, annotation, identity)
+
+		if err := kubeapi.UpdateServiceAccount(clientset, sa, cluster.Namespace); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// getBackrestIdentityAnnotation returns the annotation of a ServiceAccount that carries the
+// workload identity of the object storage of the cluster, which is empty if the repository of
+// the cluster is not stored in S3 or GCS
+func getBackrestIdentityAnnotation(cluster *crv1.Pgcluster) string {
+	switch storageType := cluster.Spec.UserLabels[config.LABEL_BACKREST_STORAGE_TYPE]; {
+	case GetBackrestCloudType(cluster) == "gcs":
+		return backrestIdentityGCPAnnotation
+	case GetBackrestCloudType(cluster) == "" && strings.Contains(storageType, "s3"):
+		return backrestIdentityAWSAnnotation
+	}
+
+	return ""
+}
+
+// getBackrestIdentity returns the workload identity of the object storage of the cluster
+func getBackrestIdentity(cluster *crv1.Pgcluster) string {
+	if getBackrestIdentityAnnotation(cluster) == backrestIdentityGCPAnnotation {
+		return cluster.Spec.BackrestIdentity.GCPServiceAccount
+	}
+
+	return cluster.Spec.BackrestIdentity.AWSRoleARN
+}
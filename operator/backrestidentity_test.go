@@ -0,0 +1,54 @@
+package operator
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"testing"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/config"
+)
+
+func TestValidateBackrestIdentity(t *testing.T) {
+	for _, test := range []struct {
+		storageType string
+		spec        crv1.BackrestIdentitySpec
+		version     string
+		valid       bool
+	}{
+		{"s3", crv1.BackrestIdentitySpec{}, "", true},
+		{"s3", crv1.BackrestIdentitySpec{UseWorkloadIdentity: true,
+			AWSRoleARN: "arn:aws:iam::123456789012:role/pgbackrest"}, "", false},
+		{"s3", crv1.BackrestIdentitySpec{UseWorkloadIdentity: true,
+			AWSRoleARN: "arn:aws:iam::123456789012:role/pgbackrest"}, BackrestVersionS3WebID, true},
+		{"gcs", crv1.BackrestIdentitySpec{UseWorkloadIdentity: true,
+			GCPServiceAccount: "backup@project.iam.gserviceaccount.com"}, BackrestVersionGCS, false},
+		{"gcs", crv1.BackrestIdentitySpec{UseWorkloadIdentity: true,
+			GCPServiceAccount: "backup@project.iam.gserviceaccount.com"}, BackrestVersionGCSAuto, true},
+	} {
+		cluster := &crv1.Pgcluster{Spec: crv1.PgclusterSpec{BackrestIdentity: test.spec}}
+		cluster.Spec.UserLabels = map[string]string{config.LABEL_BACKREST_STORAGE_TYPE: test.storageType}
+
+		Pgo.Cluster.BackrestVersion = test.version
+
+		if err := ValidateBackrestIdentity(cluster); (err == nil) != test.valid {
+			t.Errorf("%s %+v with pgBackRest %q: expected valid: %v, got %v", test.storageType,
+				test.spec, test.version, test.valid, err)
+		}
+	}
+
+	Pgo.Cluster.BackrestVersion = ""
+}
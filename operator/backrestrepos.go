@@ -0,0 +1,236 @@
+package operator
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/util"
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	// BackrestReposSecretFormat is the name of the Secret with the pgBackRest configuration of
+	// the additional repositories of a cluster
+	BackrestReposSecretFormat = "%s-pgbackrest-repos"
+	// BackrestReposConfKey is the key of the pgBackRest configuration in the Secret
+	BackrestReposConfKey = "repos.conf"
+	// BackrestReposMountPath is where the Secret is mounted. pgBackRest includes the
+	// configuration files of this directory by default
+	BackrestReposMountPath = "/etc/pgbackrest/conf.d"
+	// backrestReposVolumeName is the name of the volume of the Secret
+	backrestReposVolumeName = "pgbackrest-repos"
+
+	// EventReasonInvalidBackrestRepos is the reason of the Warning events that are recorded when
+	// the additional pgBackRest repositories of a cluster are invalid
+	EventReasonInvalidBackrestRepos = "InvalidBackrestRepos"
+
+	// backrestReposMinIndex and backrestReposMaxIndex are the numbers the additional repositories
+	// can have, repository 1 being the one of the cluster itself
+	backrestReposMinIndex = 2
+	backrestReposMaxIndex = 4
+)
+
+// the keys of the credentials in the Secret of an additional repository
+const (
+	BackrestRepoSecretKey       = "key"
+	BackrestRepoSecretKeySecret = "keySecret"
+	BackrestRepoSecretAccount   = "account"
+)
+
+// backrestRepoSecretKeys are the keys of the Secret of an additional repository that are required
+// for each type of storage
+var backrestRepoSecretKeys = map[string][]string{
+	"s3":    {BackrestRepoSecretKey, BackrestRepoSecretKeySecret},
+	"gcs":   {BackrestRepoSecretKey},
+	"azure": {BackrestRepoSecretAccount, BackrestRepoSecretKey},
+}
+
+// backrestRepoBackupTypes are the types of backups that can be scheduled for a repository
+var backrestRepoBackupTypes = map[string]bool{"": true, "full": true, "diff": true, "incr": true}
+
+// backrestReposContainers are the containers that run pgBackRest, being those of the PostgreSQL
+// instances and of the pgBackRest repository
+var backrestReposContainers = map[string]bool{"database": true}
+
+// BackrestRepoGCSKeyName is the key of the GCS service account key of a repository in the Secret,
+// which is also the name of the file it is mounted as
+func BackrestRepoGCSKeyName(index int) string {
+	return fmt.Sprintf("repo%d-gcs-key.json", index)
+}
+
+// BackrestRepoSecretKeys returns the keys of the Secret of an additional repository that are
+// required for its type of storage
+func BackrestRepoSecretKeys(repo crv1.BackrestRepoSpec) []string {
+	return backrestRepoSecretKeys[repo.Type]
+}
+
+// ValidateBackrestRepos ensures that the additional pgBackRest repositories of the cluster can be
+// configured, so that a mistake is reported when they are applied rather than when WAL is next
+// archived. pgBackRest only supports them as of version 2.33
+func ValidateBackrestRepos(cluster *crv1.Pgcluster) error {
+	if len(cluster.Spec.BackrestRepos) == 0 {
+		return nil
+	}
+
+	if err := RequireBackrestVersion("additional pgBackRest repositories",
+		BackrestVersionMultiRepo); err != nil {
+		return err
+	}
+
+	if max := backrestReposMaxIndex - backrestReposMinIndex + 1; len(cluster.Spec.BackrestRepos) > max {
+		return fmt.Errorf("a cluster can have at most %d additional pgBackRest repositories", max)
+	}
+
+	indexes := map[int]bool{}
+
+	for _, repo := range cluster.Spec.BackrestRepos {
+		if repo.Index < backrestReposMinIndex || repo.Index > backrestReposMaxIndex {
+			return fmt.Errorf("invalid pgBackRest repository index %d, must be between %d and %d",
+				repo.Index, backrestReposMinIndex, backrestReposMaxIndex)
+		}
+
+		if indexes[repo.Index] {
+			return fmt.Errorf("pgBackRest repository %d is specified more than once", repo.Index)
+		}
+		indexes[repo.Index] = true
+
+		if _, ok := backrestRepoSecretKeys[repo.Type]; !ok {
+			return fmt.Errorf("invalid type %q of pgBackRest repository %d, must be \"s3\", \"gcs\" or \"azure\"",
+				repo.Type, repo.Index)
+		}
+
+		if repo.Bucket == "" {
+			return fmt.Errorf("pgBackRest repository %d has no bucket", repo.Index)
+		}
+
+		if repo.SecretName == "" {
+			return fmt.Errorf("pgBackRest repository %d has no secret", repo.Index)
+		}
+
+		if repo.RetentionFull < 0 || repo.RetentionDiff < 0 {
+			return fmt.Errorf("invalid retention of pgBackRest repository %d", repo.Index)
+		}
+
+		if !backrestRepoBackupTypes[repo.BackupType] {
+			return fmt.Errorf("invalid backup type %q of pgBackRest repository %d, must be full, diff or incr",
+				repo.BackupType, repo.Index)
+		}
+	}
+
+	return nil
+}
+
+// BackrestReposConf returns the pgBackRest configuration of the additional repositories of the
+// cluster, given the credentials of each repository by its index. The repositories are written in
+// the order of their index so that the configuration only changes when the repositories do
+func BackrestReposConf(cluster *crv1.Pgcluster, credentials map[int]map[string][]byte) string {
+	repos := make([]crv1.BackrestRepoSpec, len(cluster.Spec.BackrestRepos))
+	copy(repos, cluster.Spec.BackrestRepos)
+	sort.Slice(repos, func(i, j int) bool { return repos[i].Index < repos[j].Index })
+
+	conf := strings.Builder{}
+	conf.WriteString("[global]\n")
+
+	for _, repo := range repos {
+		option := func(name, value string) {
+			if value != "" {
+				conf.WriteString(fmt.Sprintf("repo%d-%s=%s\n", repo.Index, name, value))
+			}
+		}
+		secret := credentials[repo.Index]
+
+		path := repo.Path
+		if path == "" {
+			path = util.GetPGBackRestRepoPath(*cluster)
+		}
+
+		option("type", repo.Type)
+		option("path", path)
+
+		switch repo.Type {
+		case "s3":
+			option("s3-bucket", repo.Bucket)
+			option("s3-endpoint", repo.Endpoint)
+			option("s3-region", repo.Region)
+			option("s3-key", string(secret[BackrestRepoSecretKey]))
+			option("s3-key-secret", string(secret[BackrestRepoSecretKeySecret]))
+		case "gcs":
+			option("gcs-bucket", repo.Bucket)
+			option("gcs-endpoint", repo.Endpoint)
+			option("gcs-key", BackrestReposMountPath+"/"+BackrestRepoGCSKeyName(repo.Index))
+		case "azure":
+			option("azure-container", repo.Bucket)
+			option("azure-endpoint", repo.Endpoint)
+			option("azure-account", string(secret[BackrestRepoSecretAccount]))
+			option("azure-key", string(secret[BackrestRepoSecretKey]))
+		}
+
+		if repo.RetentionFull > 0 {
+			option("retention-full", strconv.Itoa(repo.RetentionFull))
+		}
+		if repo.RetentionDiff > 0 {
+			option("retention-diff", strconv.Itoa(repo.RetentionDiff))
+		}
+	}
+
+	return conf.String()
+}
+
+// AddBackrestReposVolume mounts the pgBackRest configuration of the additional repositories of
+// a cluster in the containers of a pod that run pgBackRest, if the cluster has any. It returns
+// whether the pod was changed
+func AddBackrestReposVolume(cluster *crv1.Pgcluster, spec *v1.PodSpec) bool {
+	if len(cluster.Spec.BackrestRepos) == 0 {
+		return false
+	}
+
+	for _, volume := range spec.Volumes {
+		if volume.Name == backrestReposVolumeName {
+			return false
+		}
+	}
+
+	// the Secret may be replaced while the pods run, which they need not wait on
+	optional := true
+	spec.Volumes = append(spec.Volumes, v1.Volume{
+		Name: backrestReposVolumeName,
+		VolumeSource: v1.VolumeSource{
+			Secret: &v1.SecretVolumeSource{
+				SecretName: fmt.Sprintf(BackrestReposSecretFormat, cluster.Name),
+				Optional:   &optional,
+			},
+		},
+	})
+
+	for i := range spec.Containers {
+		if !backrestReposContainers[spec.Containers[i].Name] {
+			continue
+		}
+
+		spec.Containers[i].VolumeMounts = append(spec.Containers[i].VolumeMounts, v1.VolumeMount{
+			Name:      backrestReposVolumeName,
+			MountPath: BackrestReposMountPath,
+			ReadOnly:  true,
+		})
+	}
+
+	return true
+}
@@ -0,0 +1,135 @@
+package operator
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"testing"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestValidateBackrestRepos(t *testing.T) {
+	s3 := crv1.BackrestRepoSpec{Index: 2, Type: "s3", Bucket: "backups", SecretName: "s3-creds"}
+
+	cluster := &crv1.Pgcluster{Spec: crv1.PgclusterSpec{
+		BackrestRepos: []crv1.BackrestRepoSpec{s3},
+	}}
+
+	if err := ValidateBackrestRepos(cluster); err == nil {
+		t.Error("expected the pgBackRest version of the images to be too early")
+	}
+
+	Pgo.Cluster.BackrestVersion = BackrestVersionMultiRepo
+	defer func() { Pgo.Cluster.BackrestVersion = "" }()
+
+	for _, test := range []struct {
+		description string
+		repos       []crv1.BackrestRepoSpec
+		valid       bool
+	}{
+		{"none", nil, true},
+		{"one", []crv1.BackrestRepoSpec{s3}, true},
+		{"three", []crv1.BackrestRepoSpec{s3,
+			{Index: 3, Type: "gcs", Bucket: "backups", SecretName: "gcs-creds", BackupType: "diff"},
+			{Index: 4, Type: "azure", Bucket: "backups", SecretName: "azure-creds", RetentionFull: 2}}, true},
+		{"index 1", []crv1.BackrestRepoSpec{{Index: 1, Type: "s3", Bucket: "b", SecretName: "s"}}, false},
+		{"index 5", []crv1.BackrestRepoSpec{{Index: 5, Type: "s3", Bucket: "b", SecretName: "s"}}, false},
+		{"duplicate index", []crv1.BackrestRepoSpec{s3, s3}, false},
+		{"local", []crv1.BackrestRepoSpec{{Index: 2, Type: "local", Bucket: "b", SecretName: "s"}}, false},
+		{"no bucket", []crv1.BackrestRepoSpec{{Index: 2, Type: "s3", SecretName: "s"}}, false},
+		{"no secret", []crv1.BackrestRepoSpec{{Index: 2, Type: "s3", Bucket: "b"}}, false},
+		{"negative retention", []crv1.BackrestRepoSpec{{Index: 2, Type: "s3", Bucket: "b", SecretName: "s",
+			RetentionDiff: -1}}, false},
+		{"backup type", []crv1.BackrestRepoSpec{{Index: 2, Type: "s3", Bucket: "b", SecretName: "s",
+			BackupType: "snapshot"}}, false},
+	} {
+		cluster := &crv1.Pgcluster{Spec: crv1.PgclusterSpec{BackrestRepos: test.repos}}
+
+		if err := ValidateBackrestRepos(cluster); (err == nil) != test.valid {
+			t.Errorf("%s: expected valid: %v, got %v", test.description, test.valid, err)
+		}
+	}
+}
+
+func TestBackrestReposConf(t *testing.T) {
+	cluster := &crv1.Pgcluster{Spec: crv1.PgclusterSpec{
+		ClusterName:      "hippo",
+		BackrestRepoPath: "/backrestrepo/hippo-backrest-shared-repo",
+		BackrestRepos: []crv1.BackrestRepoSpec{
+			{Index: 4, Type: "azure", Bucket: "offsite", SecretName: "azure-creds"},
+			{Index: 2, Type: "s3", Bucket: "backups", Region: "us-east-1", SecretName: "s3-creds",
+				RetentionFull: 2},
+			{Index: 3, Type: "gcs", Bucket: "archive", Path: "/hippo", SecretName: "gcs-creds"},
+		},
+	}}
+
+	credentials := map[int]map[string][]byte{
+		2: {"key": []byte("AKIA"), "keySecret": []byte("secret")},
+		3: {"key": []byte("{}")},
+		4: {"account": []byte("hippo"), "key": []byte("YXp1cmU=")},
+	}
+
+	expected := `[global]
+repo2-type=s3
+repo2-path=/backrestrepo/hippo-backrest-shared-repo
+repo2-s3-bucket=backups
+repo2-s3-region=us-east-1
+repo2-s3-key=AKIA
+repo2-s3-key-secret=secret
+repo2-retention-full=2
+repo3-type=gcs
+repo3-path=/hippo
+repo3-gcs-bucket=archive
+repo3-gcs-key=/etc/pgbackrest/conf.d/repo3-gcs-key.json
+repo4-type=azure
+repo4-path=/backrestrepo/hippo-backrest-shared-repo
+repo4-azure-container=offsite
+repo4-azure-account=hippo
+repo4-azure-key=YXp1cmU=
+`
+
+	if conf := BackrestReposConf(cluster, credentials); conf != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, conf)
+	}
+}
+
+func TestAddBackrestReposVolume(t *testing.T) {
+	cluster := &crv1.Pgcluster{}
+	cluster.Name = "hippo"
+
+	spec := &v1.PodSpec{Containers: []v1.Container{{Name: "database"}, {Name: "collect"}}}
+
+	if AddBackrestReposVolume(cluster, spec) {
+		t.Error("expected no volume without additional repositories")
+	}
+
+	cluster.Spec.BackrestRepos = []crv1.BackrestRepoSpec{{Index: 2}}
+
+	if !AddBackrestReposVolume(cluster, spec) {
+		t.Fatal("expected the volume to be added")
+	}
+	if AddBackrestReposVolume(cluster, spec) {
+		t.Error("expected the volume to be added only once")
+	}
+
+	if len(spec.Volumes) != 1 || spec.Volumes[0].Secret.SecretName != "hippo-pgbackrest-repos" {
+		t.Errorf("unexpected volumes %v", spec.Volumes)
+	}
+	if len(spec.Containers[0].VolumeMounts) != 1 || len(spec.Containers[1].VolumeMounts) != 0 {
+		t.Errorf("expected only the database container to mount the volume, got %v", spec.Containers)
+	}
+}
@@ -0,0 +1,179 @@
+package operator
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/config"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+const (
+	// BackrestS3EnvBucket is the environment variable of the S3 bucket of a pgBackRest
+	// repository, which is set on every container that runs pgBackRest against S3
+	BackrestS3EnvBucket = "PGBACKREST_REPO1_S3_BUCKET"
+
+	backrestS3EnvEndpoint   = "PGBACKREST_REPO1_S3_ENDPOINT"
+	backrestS3EnvRegion     = "PGBACKREST_REPO1_S3_REGION"
+	backrestS3EnvURIStyle   = "PGBACKREST_REPO1_S3_URI_STYLE"
+	backrestS3EnvPartSize   = "PGBACKREST_REPO1_STORAGE_UPLOAD_CHUNK_SIZE"
+	backrestS3EnvProcessMax = "PGBACKREST_PROCESS_MAX"
+	backrestS3EnvIOTimeout  = "PGBACKREST_IO_TIMEOUT"
+
+	// EventReasonInvalidBackrestS3 is the reason of the Warning events that are recorded when the
+	// S3 settings of a cluster are invalid
+	EventReasonInvalidBackrestS3 = "InvalidBackrestS3"
+
+	// backrestS3MaxConcurrency is the largest number of processes pgBackRest can run
+	backrestS3MaxConcurrency = 999
+)
+
+var (
+	// backrestS3MinPartSize and backrestS3MaxPartSize are the limits S3 puts on the size of the
+	// parts of a multipart upload
+	backrestS3MinPartSize = resource.MustParse("5Mi")
+	backrestS3MaxPartSize = resource.MustParse("5Gi")
+
+	// backrestS3RegionRegex matches the name of a region, e.g. "us-east-1"
+	backrestS3RegionRegex = regexp.MustCompile(`^[A-Za-z0-9]+([-_][A-Za-z0-9]+)*$`)
+)
+
+// GetBackrestS3EnvVars returns the pgBackRest environment variables of the S3 repository of the
+// cluster that can be changed once the cluster exists, i.e. the endpoint and region of the object
+// storage as well as the tuning of the transfers. The endpoint and region default to those in the
+// pgo.yaml configuration file, and a variable with an empty value is not set
+func GetBackrestS3EnvVars(cluster *crv1.Pgcluster) map[string]string {
+	envVars := map[string]string{
+		backrestS3EnvEndpoint:   getBackrestS3Endpoint(cluster),
+		backrestS3EnvRegion:     getBackrestS3Region(cluster),
+		backrestS3EnvURIStyle:   cluster.Spec.BackrestS3.URIStyle,
+		backrestS3EnvPartSize:   "",
+		backrestS3EnvProcessMax: "",
+		backrestS3EnvIOTimeout:  "",
+	}
+
+	// pgBackRest does not understand the suffixes of a Kubernetes quantity, so the part size is
+	// passed as a number of bytes
+	if partSize, err := resource.ParseQuantity(cluster.Spec.BackrestS3.PartSize); err == nil {
+		envVars[backrestS3EnvPartSize] = strconv.FormatInt(partSize.Value(), 10)
+	}
+
+	if cluster.Spec.BackrestS3.Concurrency > 0 {
+		envVars[backrestS3EnvProcessMax] = strconv.Itoa(cluster.Spec.BackrestS3.Concurrency)
+	}
+
+	if cluster.Spec.BackrestS3.TimeoutSeconds > 0 {
+		envVars[backrestS3EnvIOTimeout] = strconv.Itoa(cluster.Spec.BackrestS3.TimeoutSeconds)
+	}
+
+	return envVars
+}
+
+// ValidateBackrestS3 ensures that the S3 settings of the cluster can be used by pgBackRest, so
+// that a mistake is reported when the settings are applied rather than when the next backup runs
+func ValidateBackrestS3(cluster *crv1.Pgcluster) error {
+	if !strings.Contains(cluster.Spec.UserLabels[config.LABEL_BACKREST_STORAGE_TYPE], "s3") {
+		return nil
+	}
+
+	if err := validateBackrestS3Endpoint(getBackrestS3Endpoint(cluster)); err != nil {
+		return err
+	}
+
+	if region := getBackrestS3Region(cluster); !backrestS3RegionRegex.MatchString(region) {
+		return fmt.Errorf("invalid S3 region %q", region)
+	}
+
+	s3 := cluster.Spec.BackrestS3
+
+	if s3.URIStyle != "" && s3.URIStyle != "host" && s3.URIStyle != "path" {
+		return fmt.Errorf("invalid S3 URI style %q, must be \"host\" or \"path\"", s3.URIStyle)
+	}
+
+	if s3.PartSize != "" {
+		partSize, err := resource.ParseQuantity(s3.PartSize)
+		if err != nil {
+			return fmt.Errorf("invalid S3 part size %q: %s", s3.PartSize, err)
+		}
+
+		if partSize.Cmp(backrestS3MinPartSize) < 0 || partSize.Cmp(backrestS3MaxPartSize) > 0 {
+			return fmt.Errorf("invalid S3 part size %q, must be between %s and %s", s3.PartSize,
+				backrestS3MinPartSize.String(), backrestS3MaxPartSize.String())
+		}
+	}
+
+	if s3.Concurrency < 0 || s3.Concurrency > backrestS3MaxConcurrency {
+		return fmt.Errorf("invalid S3 concurrency %d, must be between 0 and %d", s3.Concurrency,
+			backrestS3MaxConcurrency)
+	}
+
+	if s3.TimeoutSeconds < 0 {
+		return fmt.Errorf("invalid S3 timeout %d, must not be negative", s3.TimeoutSeconds)
+	}
+
+	return nil
+}
+
+// getBackrestS3Endpoint returns the S3 endpoint of the cluster, or the default one of the Operator
+func getBackrestS3Endpoint(cluster *crv1.Pgcluster) string {
+	if cluster.Spec.BackrestS3Endpoint != "" {
+		return cluster.Spec.BackrestS3Endpoint
+	}
+
+	return Pgo.Cluster.BackrestS3Endpoint
+}
+
+// getBackrestS3Region returns the S3 region of the cluster, or the default one of the Operator
+func getBackrestS3Region(cluster *crv1.Pgcluster) string {
+	if cluster.Spec.BackrestS3Region != "" {
+		return cluster.Spec.BackrestS3Region
+	}
+
+	return Pgo.Cluster.BackrestS3Region
+}
+
+// validateBackrestS3Endpoint ensures that the endpoint is a host name, optionally followed by a
+// port, which is what pgBackRest expects, e.g. "s3.amazonaws.com" rather than a URL
+func validateBackrestS3Endpoint(endpoint string) error {
+	if endpoint == "" {
+		return fmt.Errorf("no S3 endpoint is set")
+	}
+
+	if strings.Contains(endpoint, "://") || strings.Contains(endpoint, "/") {
+		return fmt.Errorf("invalid S3 endpoint %q, must be a host name without a scheme or path",
+			endpoint)
+	}
+
+	host := endpoint
+	if h, port, err := net.SplitHostPort(endpoint); err == nil {
+		if p, err := strconv.Atoi(port); err != nil || p < 1 || p > 65535 {
+			return fmt.Errorf("invalid port in S3 endpoint %q", endpoint)
+		}
+		host = h
+	}
+
+	if host == "" || strings.ContainsAny(host, " \t:") {
+		return fmt.Errorf("invalid S3 endpoint %q", endpoint)
+	}
+
+	return nil
+}
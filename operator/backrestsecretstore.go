@@ -0,0 +1,154 @@
+package operator
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"errors"
+	"fmt"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/kubeapi"
+	"github.com/crunchydata/postgres-operator/util"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// backrestSecretStoreVolumeName is the name of the volume that the credentials of the
+	// secret store are mounted from
+	backrestSecretStoreVolumeName = "pgbackrest-secret-store"
+
+	// backrestSecretStoreCSIDriver is the driver of the Secrets Store CSI Driver
+	backrestSecretStoreCSIDriver = "secrets-store.csi.k8s.io"
+
+	// EventReasonInvalidBackrestSecretStore is the reason of the Warning events that are recorded
+	// when the secret store of a cluster is invalid or does not exist
+	EventReasonInvalidBackrestSecretStore = "InvalidBackrestSecretStore"
+)
+
+// backrestSecretStoreContainers are the containers that run pgBackRest against the repository,
+// being those of the PostgreSQL instances, of the pgBackRest repository and of the restore Jobs
+var backrestSecretStoreContainers = map[string]bool{"database": true, "backrest": true}
+
+// UsesBackrestSecretStore returns whether the credentials of the repository of the cluster are
+// mounted from a secret store rather than taken from the pgBackRest Secret of the cluster
+func UsesBackrestSecretStore(cluster *crv1.Pgcluster) bool {
+	return cluster.Spec.BackrestSecretStore.SecretProviderClass != "" ||
+		cluster.Spec.BackrestSecretStore.ExternalSecret != ""
+}
+
+// ValidateBackrestSecretStore ensures that the secret store of the cluster can provide the
+// credentials of its repository. The credentials are mounted where pgBackRest includes its
+// configuration files from, which is where the additional repositories are configured as well,
+// so the two cannot be combined
+func ValidateBackrestSecretStore(cluster *crv1.Pgcluster) error {
+	if !UsesBackrestSecretStore(cluster) {
+		return nil
+	}
+
+	spec := cluster.Spec.BackrestSecretStore
+
+	switch {
+	case spec.SecretProviderClass != "" && spec.ExternalSecret != "":
+		return errors.New("only one of a SecretProviderClass and an ExternalSecret can provide " +
+			"the pgBackRest credentials")
+	case getBackrestIdentityAnnotation(cluster) == "":
+		return errors.New("a secret store is only supported for pgBackRest storage types \"s3\" and \"gcs\"")
+	case cluster.Spec.BackrestIdentity.UseWorkloadIdentity:
+		return errors.New("a secret store cannot be combined with workload identity")
+	case len(cluster.Spec.BackrestRepos) != 0:
+		return errors.New("a secret store cannot be combined with additional pgBackRest repositories")
+	}
+
+	return nil
+}
+
+// CheckBackrestSecretStore ensures that the SecretProviderClass or ExternalSecret of the cluster
+// exists, so that its pods do not wait on a volume that cannot be mounted. The Secret of an
+// ExternalSecret is mounted by the name of the ExternalSecret, so it cannot target another one
+func CheckBackrestSecretStore(clientset *kubernetes.Clientset, cluster *crv1.Pgcluster) error {
+	spec := cluster.Spec.BackrestSecretStore
+
+	switch {
+	case spec.SecretProviderClass != "":
+		if found, err := kubeapi.GetSecretProviderClass(clientset, spec.SecretProviderClass,
+			cluster.Namespace); !found {
+			return fmt.Errorf("secret provider class %s not found: %v", spec.SecretProviderClass, err)
+		}
+	case spec.ExternalSecret != "":
+		secret, found, err := kubeapi.GetExternalSecret(clientset, spec.ExternalSecret, cluster.Namespace)
+		if !found {
+			return fmt.Errorf("external secret %s not found: %v", spec.ExternalSecret, err)
+		}
+
+		if target := secret.Spec.Target.Name; target != "" && target != spec.ExternalSecret {
+			return fmt.Errorf("external secret %s targets secret %s rather than %s",
+				spec.ExternalSecret, target, spec.ExternalSecret)
+		}
+	}
+
+	return nil
+}
+
+// AddBackrestSecretStoreVolume mounts the credentials of the secret store of a cluster in the
+// containers of a pod that run pgBackRest, if the cluster has one. It returns whether the pod was
+// changed
+func AddBackrestSecretStoreVolume(cluster *crv1.Pgcluster, spec *v1.PodSpec) bool {
+	if !UsesBackrestSecretStore(cluster) {
+		return false
+	}
+
+	for _, volume := range spec.Volumes {
+		if volume.Name == backrestSecretStoreVolumeName {
+			return false
+		}
+	}
+
+	readOnly := true
+	volume := v1.Volume{Name: backrestSecretStoreVolumeName}
+
+	if name := cluster.Spec.BackrestSecretStore.SecretProviderClass; name != "" {
+		volume.CSI = &v1.CSIVolumeSource{
+			Driver:           backrestSecretStoreCSIDriver,
+			ReadOnly:         &readOnly,
+			VolumeAttributes: map[string]string{"secretProviderClass": name},
+		}
+	} else {
+		volume.Secret = &v1.SecretVolumeSource{SecretName: cluster.Spec.BackrestSecretStore.ExternalSecret}
+	}
+
+	spec.Volumes = append(spec.Volumes, volume)
+
+	for i := range spec.Containers {
+		if !backrestSecretStoreContainers[spec.Containers[i].Name] {
+			continue
+		}
+
+		spec.Containers[i].VolumeMounts = append(spec.Containers[i].VolumeMounts, v1.VolumeMount{
+			Name:      backrestSecretStoreVolumeName,
+			MountPath: BackrestReposMountPath,
+			ReadOnly:  true,
+		})
+	}
+
+	return true
+}
+
+// getBackrestSecretStoreGCSKey returns the path of the service account key of GCS in the
+// credentials of the secret store
+func getBackrestSecretStoreGCSKey() string {
+	return BackrestReposMountPath + "/" + util.BackRestRepoSecretKeyGCSKey
+}
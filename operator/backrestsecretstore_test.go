@@ -0,0 +1,67 @@
+package operator
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"testing"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestAddBackrestSecretStoreVolume(t *testing.T) {
+	cluster := &crv1.Pgcluster{}
+
+	newSpec := func() *v1.PodSpec {
+		return &v1.PodSpec{Containers: []v1.Container{{Name: "database"}, {Name: "collect"}}}
+	}
+
+	spec := newSpec()
+	if AddBackrestSecretStoreVolume(cluster, spec) {
+		t.Error("expected no volume without a secret store")
+	}
+
+	cluster.Spec.BackrestSecretStore.SecretProviderClass = "pgbackrest"
+
+	if !AddBackrestSecretStoreVolume(cluster, spec) {
+		t.Fatal("expected the volume to be added")
+	}
+	if AddBackrestSecretStoreVolume(cluster, spec) {
+		t.Error("expected the volume to be added only once")
+	}
+
+	if len(spec.Volumes) != 1 || spec.Volumes[0].CSI == nil ||
+		spec.Volumes[0].CSI.VolumeAttributes["secretProviderClass"] != "pgbackrest" {
+		t.Errorf("unexpected volumes %v", spec.Volumes)
+	}
+	if len(spec.Containers[0].VolumeMounts) != 1 || len(spec.Containers[1].VolumeMounts) != 0 {
+		t.Errorf("expected only the database container to mount the volume, got %v", spec.Containers)
+	}
+	if mount := spec.Containers[0].VolumeMounts[0]; mount.MountPath != BackrestReposMountPath {
+		t.Errorf("expected the volume to be mounted at %s, got %s", BackrestReposMountPath, mount.MountPath)
+	}
+
+	// the Secret of an ExternalSecret is mounted by its name
+	cluster.Spec.BackrestSecretStore = crv1.BackrestSecretStoreSpec{ExternalSecret: "pgbackrest-creds"}
+
+	spec = newSpec()
+	AddBackrestSecretStoreVolume(cluster, spec)
+
+	if len(spec.Volumes) != 1 || spec.Volumes[0].Secret == nil ||
+		spec.Volumes[0].Secret.SecretName != "pgbackrest-creds" {
+		t.Errorf("unexpected volumes %v", spec.Volumes)
+	}
+}
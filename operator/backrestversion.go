@@ -0,0 +1,92 @@
+package operator
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/crunchydata/postgres-operator/config"
+)
+
+// the first versions of pgBackRest that support the features the Operator uses beyond those of
+// the version in the images of this release
+const (
+	// BackrestVersionMultiRepo is the first version that archives to and backs up to several
+	// repositories, i.e. repo2 to repo4
+	BackrestVersionMultiRepo = "2.33"
+	// BackrestVersionAzure is the first version that stores repositories in Azure Blob Storage
+	BackrestVersionAzure = "2.28"
+	// BackrestVersionGCS is the first version that stores repositories in Google Cloud Storage
+	BackrestVersionGCS = "2.33"
+	// BackrestVersionVerify is the first version that verifies the backups and WAL archive of a
+	// repository against their checksums, i.e. runs "pgbackrest verify"
+	BackrestVersionVerify = "2.33"
+	// BackrestVersionS3WebID is the first version that takes the credentials of an S3 repository
+	// from the web identity token of its Pod, i.e. the "web-id" key type
+	BackrestVersionS3WebID = "2.33"
+	// BackrestVersionGCSAuto is the first version that takes the credentials of a GCS repository
+	// from the metadata server of its node, i.e. the "auto" key type
+	BackrestVersionGCSAuto = "2.40"
+)
+
+// GetBackrestVersion returns the version of pgBackRest in the images of the clusters, which is
+// that of the images of this release unless it is set in the configuration of the Operator
+func GetBackrestVersion() string {
+	if Pgo.Cluster.BackrestVersion != "" {
+		return Pgo.Cluster.BackrestVersion
+	}
+
+	return config.DEFAULT_BACKREST_VERSION
+}
+
+// RequireBackrestVersion returns an error if the version of pgBackRest in the images of the
+// clusters is earlier than the version that a feature needs
+func RequireBackrestVersion(feature, minimum string) error {
+	if version := GetBackrestVersion(); compareBackrestVersions(version, minimum) < 0 {
+		return fmt.Errorf("%s requires pgBackRest %s or later, but the images run pgBackRest %s; "+
+			"set BackrestVersion in pgo.yaml once they run a later version", feature, minimum, version)
+	}
+
+	return nil
+}
+
+// compareBackrestVersions returns whether a version of pgBackRest is earlier than, the same as or
+// later than another one as -1, 0 or 1. Versions are compared number by number, a missing number
+// being 0
+func compareBackrestVersions(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var x, y int
+		if i < len(as) {
+			x, _ = strconv.Atoi(as[i])
+		}
+		if i < len(bs) {
+			y, _ = strconv.Atoi(bs[i])
+		}
+
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+	}
+
+	return 0
+}
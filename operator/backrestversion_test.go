@@ -0,0 +1,43 @@
+package operator
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"testing"
+)
+
+func TestRequireBackrestVersion(t *testing.T) {
+	defer func() { Pgo.Cluster.BackrestVersion = "" }()
+
+	for _, test := range []struct {
+		version, minimum string
+		valid            bool
+	}{
+		{"", "2.24", true},
+		{"", "2.33", false},
+		{"2.33", "2.33", true},
+		{"2.33.1", "2.33", true},
+		{"2.4", "2.33", false},
+		{"3.0", "2.33", true},
+	} {
+		Pgo.Cluster.BackrestVersion = test.version
+
+		if err := RequireBackrestVersion("feature", test.minimum); (err == nil) != test.valid {
+			t.Errorf("version %q, minimum %q: expected valid: %v, got %v", test.version,
+				test.minimum, test.valid, err)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package operator
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"fmt"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/config"
+	"github.com/crunchydata/postgres-operator/kubeapi"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/yaml"
+)
+
+// GetPatroniBootstrapConfig returns the custom configuration of Patroni that the primary of a
+// cluster is bootstrapped with when the cluster is initialized with options of initdb, e.g. data
+// checksums or a locale. The options are added to the "bootstrap.initdb" section of the custom configuration
+// of the cluster, if it has one, as the PGHA configMap is projected over it. Nothing is returned
+// if the cluster has no such options
+func GetPatroniBootstrapConfig(clientset *kubernetes.Clientset, cluster *crv1.Pgcluster,
+	namespace string) (string, error) {
+	options := getInitdbOptions(cluster)
+	if len(options) == 0 {
+		return "", nil
+	}
+
+	// the custom configuration is looked up the same way as its volume is
+	custom := ""
+	for _, name := range []string{cluster.Spec.CustomConfig, config.GLOBAL_CUSTOM_CONFIGMAP} {
+		if name == "" {
+			continue
+		}
+
+		if configMap, found := kubeapi.GetConfigMap(clientset, name, namespace); found {
+			custom = configMap.Data[PGHAConfigPatroniSetting]
+			break
+		}
+	}
+
+	return setInitdbOptions(custom, options)
+}
+
+// getInitdbOptions returns the options of initdb that the primary of a cluster is initialized
+// with, in the form that Patroni takes them in
+func getInitdbOptions(cluster *crv1.Pgcluster) []interface{} {
+	options := []interface{}{}
+
+	if cluster.Spec.DataChecksums {
+		options = append(options, "data-checksums")
+	}
+
+	locale := cluster.Spec.Locale
+
+	for _, option := range []struct{ name, value string }{
+		{"encoding", locale.Encoding},
+		{"lc-collate", locale.Collate},
+		{"lc-ctype", locale.Ctype},
+	} {
+		if option.value != "" {
+			options = append(options, map[string]interface{}{option.name: option.value})
+		}
+	}
+
+	return options
+}
+
+// setInitdbOptions sets the options of initdb in the "bootstrap.initdb" section of a custom
+// configuration of Patroni, replacing any of the same name that it already has
+func setInitdbOptions(custom string, options []interface{}) (string, error) {
+	conf := map[string]interface{}{}
+	if err := yaml.Unmarshal([]byte(custom), &conf); err != nil {
+		return "", fmt.Errorf("invalid custom configuration of Patroni: %s", err)
+	}
+
+	if conf == nil {
+		conf = map[string]interface{}{}
+	}
+
+	bootstrap, _ := conf["bootstrap"].(map[string]interface{})
+	if bootstrap == nil {
+		bootstrap = map[string]interface{}{}
+	}
+
+	names := map[string]bool{}
+	for _, option := range options {
+		names[getInitdbOptionName(option)] = true
+	}
+
+	initdb := []interface{}{}
+	if current, ok := bootstrap["initdb"].([]interface{}); ok {
+		for _, option := range current {
+			if !names[getInitdbOptionName(option)] {
+				initdb = append(initdb, option)
+			}
+		}
+	}
+
+	bootstrap["initdb"] = append(initdb, options...)
+	conf["bootstrap"] = bootstrap
+
+	out, err := yaml.Marshal(conf)
+	if err != nil {
+		return "", err
+	}
+
+	return string(out), nil
+}
+
+// getInitdbOptionName returns the name of an option of initdb as Patroni takes it, which is
+// either the name of a flag or a map of the name of an option to its value
+func getInitdbOptionName(option interface{}) string {
+	switch option := option.(type) {
+	case string:
+		return option
+	case map[string]interface{}:
+		for name := range option {
+			return name
+		}
+	}
+
+	return ""
+}
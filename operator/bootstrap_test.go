@@ -0,0 +1,80 @@
+package operator
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"reflect"
+	"testing"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+)
+
+func TestGetInitdbOptions(t *testing.T) {
+	for _, test := range []struct {
+		spec     crv1.PgclusterSpec
+		expected []interface{}
+	}{
+		{crv1.PgclusterSpec{}, []interface{}{}},
+		{crv1.PgclusterSpec{DataChecksums: true}, []interface{}{"data-checksums"}},
+		{crv1.PgclusterSpec{Locale: crv1.LocaleSpec{Encoding: "UTF8"}},
+			[]interface{}{map[string]interface{}{"encoding": "UTF8"}}},
+		{crv1.PgclusterSpec{DataChecksums: true,
+			Locale: crv1.LocaleSpec{Collate: "C", Ctype: "de_DE.UTF-8", Encoding: "UTF8"}},
+			[]interface{}{"data-checksums", map[string]interface{}{"encoding": "UTF8"},
+				map[string]interface{}{"lc-collate": "C"},
+				map[string]interface{}{"lc-ctype": "de_DE.UTF-8"}}},
+	} {
+		cluster := &crv1.Pgcluster{Spec: test.spec}
+
+		if options := getInitdbOptions(cluster); !reflect.DeepEqual(options, test.expected) {
+			t.Errorf("%+v: expected %v, got %v", test.spec, test.expected, options)
+		}
+	}
+}
+
+func TestSetInitdbOptions(t *testing.T) {
+	for _, test := range []struct {
+		custom   string
+		options  []interface{}
+		expected string
+	}{
+		{"", []interface{}{"data-checksums"}, "bootstrap:\n  initdb:\n  - data-checksums\n"},
+		// the rest of the custom configuration is kept
+		{"postgresql:\n  pg_hba:\n  - local all postgres peer\n", []interface{}{"data-checksums"},
+			"bootstrap:\n  initdb:\n  - data-checksums\npostgresql:\n  pg_hba:\n  - local all postgres peer\n"},
+		// options of the same name are replaced, others are kept
+		{"bootstrap:\n  initdb:\n  - encoding: UTF8\n  - data-checksums\n",
+			[]interface{}{"data-checksums"},
+			"bootstrap:\n  initdb:\n  - encoding: UTF8\n  - data-checksums\n"},
+		{"bootstrap:\n  initdb:\n  - encoding: SQL_ASCII\n  - data-checksums\n",
+			[]interface{}{map[string]interface{}{"encoding": "UTF8"}},
+			"bootstrap:\n  initdb:\n  - data-checksums\n  - encoding: UTF8\n"},
+		{"bootstrap:\n  dcs:\n    ttl: 30\n  initdb:\n  - encoding: UTF8\n",
+			[]interface{}{"data-checksums"},
+			"bootstrap:\n  dcs:\n    ttl: 30\n  initdb:\n  - encoding: UTF8\n  - data-checksums\n"},
+	} {
+		out, err := setInitdbOptions(test.custom, test.options)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %s", test.custom, err)
+		} else if out != test.expected {
+			t.Errorf("%q: expected %q, got %q", test.custom, test.expected, out)
+		}
+	}
+
+	if _, err := setInitdbOptions("bootstrap: [", []interface{}{"data-checksums"}); err == nil {
+		t.Error("expected an error for an invalid custom configuration")
+	}
+}
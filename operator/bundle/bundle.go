@@ -0,0 +1,186 @@
+package bundle
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/pkg/generated/clientset/versioned"
+	informers "github.com/crunchydata/postgres-operator/pkg/generated/informers/externalversions"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/yaml"
+)
+
+// Version is the version of the format of a bundle
+const Version = "v1"
+
+// Bundle is the desired state of the clusters managed by the Operator, i.e. the Pgclusters,
+// Pgreplicas, Pgpolicies and Pgtasks of the namespaces it watches, which can be used to rebuild
+// that state in another Kubernetes cluster
+type Bundle struct {
+	// Version is the version of the format of the bundle
+	Version string `json:"version"`
+	// Pgclusters are the clusters, without the status the Operator keeps for them
+	Pgclusters []crv1.Pgcluster `json:"pgclusters"`
+	// Pgreplicas are the replicas, without the status the Operator keeps for them
+	Pgreplicas []crv1.Pgreplica `json:"pgreplicas"`
+	// Pgpolicies are the SQL policies
+	Pgpolicies []crv1.Pgpolicy `json:"pgpolicies"`
+	// Pgtasks are the tasks along with their status, so that a task that is done is not run
+	// again when the bundle is imported
+	Pgtasks []crv1.Pgtask `json:"pgtasks"`
+}
+
+// Export reads the Pgclusters, Pgreplicas, Pgpolicies and Pgtasks of the namespaces from the
+// listers of informers, and returns them as a bundle. The resources only keep what is needed to
+// create them again, i.e. their name, namespace, labels, annotations and spec
+func Export(clientset versioned.Interface, namespaces []string) (*Bundle, error) {
+	bundle := &Bundle{
+		Version:    Version,
+		Pgclusters: []crv1.Pgcluster{},
+		Pgreplicas: []crv1.Pgreplica{},
+		Pgpolicies: []crv1.Pgpolicy{},
+		Pgtasks:    []crv1.Pgtask{},
+	}
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	for _, namespace := range namespaces {
+		factory := informers.NewSharedInformerFactoryWithOptions(clientset, 0,
+			informers.WithNamespace(namespace))
+
+		// the informers need to be requested before the factory is started, so that it starts
+		// them
+		clusterInformer := factory.Crunchydata().V1().Pgclusters()
+		replicaInformer := factory.Crunchydata().V1().Pgreplicas()
+		policyInformer := factory.Crunchydata().V1().Pgpolicies()
+		taskInformer := factory.Crunchydata().V1().Pgtasks()
+
+		clusterInformer.Informer()
+		replicaInformer.Informer()
+		policyInformer.Informer()
+		taskInformer.Informer()
+
+		factory.Start(stopCh)
+
+		for informerType, synced := range factory.WaitForCacheSync(stopCh) {
+			if !synced {
+				return nil, fmt.Errorf("could not sync the %v informer of namespace %s",
+					informerType, namespace)
+			}
+		}
+
+		clusters, err := clusterInformer.Lister().Pgclusters(namespace).List(labels.Everything())
+		if err != nil {
+			return nil, err
+		}
+
+		for _, cluster := range clusters {
+			bundle.Pgclusters = append(bundle.Pgclusters, exportPgcluster(cluster))
+		}
+
+		replicas, err := replicaInformer.Lister().Pgreplicas(namespace).List(labels.Everything())
+		if err != nil {
+			return nil, err
+		}
+
+		for _, replica := range replicas {
+			bundle.Pgreplicas = append(bundle.Pgreplicas, exportPgreplica(replica))
+		}
+
+		policies, err := policyInformer.Lister().Pgpolicies(namespace).List(labels.Everything())
+		if err != nil {
+			return nil, err
+		}
+
+		for _, policy := range policies {
+			bundle.Pgpolicies = append(bundle.Pgpolicies, exportPgpolicy(policy))
+		}
+
+		tasks, err := taskInformer.Lister().Pgtasks(namespace).List(labels.Everything())
+		if err != nil {
+			return nil, err
+		}
+
+		for _, task := range tasks {
+			bundle.Pgtasks = append(bundle.Pgtasks, exportPgtask(task))
+		}
+	}
+
+	bundle.sort()
+
+	return bundle, nil
+}
+
+// Marshal serializes the bundle, either as "yaml" or as "json"
+func (b *Bundle) Marshal(format string) ([]byte, error) {
+	switch format {
+	case "yaml":
+		return yaml.Marshal(b)
+	case "json":
+		return json.MarshalIndent(b, "", "  ")
+	}
+
+	return nil, fmt.Errorf("unsupported bundle format %q, must be \"yaml\" or \"json\"", format)
+}
+
+// Unmarshal deserializes a bundle, which can be either YAML or JSON
+func Unmarshal(data []byte) (*Bundle, error) {
+	bundle := &Bundle{}
+
+	if err := yaml.Unmarshal(data, bundle); err != nil {
+		return nil, err
+	}
+
+	if bundle.Version == "" {
+		return nil, errors.New("not a bundle, it has no version")
+	} else if bundle.Version != Version {
+		return nil, fmt.Errorf("unsupported bundle version %q, must be %q", bundle.Version, Version)
+	}
+
+	return bundle, nil
+}
+
+// sort orders the resources of each kind by namespace and name, so that exporting the same state
+// always results in the same bundle
+func (b *Bundle) sort() {
+	sort.Slice(b.Pgclusters, func(i, j int) bool {
+		return key(b.Pgclusters[i].Namespace, b.Pgclusters[i].Name) <
+			key(b.Pgclusters[j].Namespace, b.Pgclusters[j].Name)
+	})
+	sort.Slice(b.Pgreplicas, func(i, j int) bool {
+		return key(b.Pgreplicas[i].Namespace, b.Pgreplicas[i].Name) <
+			key(b.Pgreplicas[j].Namespace, b.Pgreplicas[j].Name)
+	})
+	sort.Slice(b.Pgpolicies, func(i, j int) bool {
+		return key(b.Pgpolicies[i].Namespace, b.Pgpolicies[i].Name) <
+			key(b.Pgpolicies[j].Namespace, b.Pgpolicies[j].Name)
+	})
+	sort.Slice(b.Pgtasks, func(i, j int) bool {
+		return key(b.Pgtasks[i].Namespace, b.Pgtasks[i].Name) <
+			key(b.Pgtasks[j].Namespace, b.Pgtasks[j].Name)
+	})
+}
+
+// key identifies a resource within a bundle
+func key(namespace, name string) string {
+	return namespace + "/" + name
+}
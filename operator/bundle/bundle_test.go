@@ -0,0 +1,286 @@
+package bundle
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"reflect"
+	"testing"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/pkg/generated/clientset/versioned/fake"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// testObjects returns the resources of a populated Kubernetes cluster, along with the state the
+// Operator keeps for them
+func testObjects() []runtime.Object {
+	return []runtime.Object{
+		&crv1.Pgcluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "hippo", Namespace: "pgo", ResourceVersion: "7",
+				UID: "1234", Labels: map[string]string{"pg-cluster": "hippo"}},
+			Spec: crv1.PgclusterSpec{Name: "hippo", ClusterName: "hippo", Port: "5432",
+				Status: crv1.CompletedStatus},
+			Status: crv1.PgclusterStatus{State: crv1.PgclusterStateInitialized},
+		},
+		&crv1.Pgcluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "rhino", Namespace: "other"},
+			Spec:       crv1.PgclusterSpec{Name: "rhino", ClusterName: "rhino", Port: "5432"},
+		},
+		&crv1.Pgreplica{
+			ObjectMeta: metav1.ObjectMeta{Name: "hippo-abcd", Namespace: "pgo", ResourceVersion: "8"},
+			Spec: crv1.PgreplicaSpec{Name: "hippo-abcd", ClusterName: "hippo",
+				Status: crv1.CompletedStatus},
+			Status: crv1.PgreplicaStatus{State: crv1.PgreplicaStateProcessed},
+		},
+		&crv1.Pgpolicy{
+			ObjectMeta: metav1.ObjectMeta{Name: "audit", Namespace: "pgo"},
+			Spec:       crv1.PgpolicySpec{Name: "audit", SQL: "CREATE TABLE audit (id int);"},
+		},
+		&crv1.Pgtask{
+			ObjectMeta: metav1.ObjectMeta{Name: "hippo-backup", Namespace: "pgo"},
+			Spec: crv1.PgtaskSpec{Name: "hippo-backup", TaskType: crv1.PgtaskBackrest,
+				Status: crv1.CompletedStatus},
+			Status: crv1.PgtaskStatus{State: crv1.PgtaskStateProcessed},
+		},
+	}
+}
+
+func TestExport(t *testing.T) {
+	bundle, err := Export(fake.NewSimpleClientset(testObjects()...), []string{"pgo", "other"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(bundle.Pgclusters) != 2 || len(bundle.Pgreplicas) != 1 ||
+		len(bundle.Pgpolicies) != 1 || len(bundle.Pgtasks) != 1 {
+		t.Fatalf("unexpected bundle: %+v", bundle)
+	}
+
+	// the resources are ordered by namespace and name
+	if bundle.Pgclusters[0].Name != "rhino" || bundle.Pgclusters[1].Name != "hippo" {
+		t.Fatalf("unexpected order of clusters: %s, %s", bundle.Pgclusters[0].Name,
+			bundle.Pgclusters[1].Name)
+	}
+
+	// the state the Operator keeps for clusters and replicas is dropped, as is the metadata
+	// assigned by Kubernetes
+	cluster := bundle.Pgclusters[1]
+	if cluster.ResourceVersion != "" || cluster.UID != "" || cluster.Spec.Status != "" ||
+		cluster.Status.State != "" {
+		t.Fatalf("unexpected state in exported cluster: %+v", cluster)
+	}
+
+	if cluster.Labels["pg-cluster"] != "hippo" || cluster.Kind != "Pgcluster" {
+		t.Fatalf("unexpected metadata of exported cluster: %+v", cluster.ObjectMeta)
+	}
+
+	if replica := bundle.Pgreplicas[0]; replica.Spec.Status != "" || replica.Status.State != "" {
+		t.Fatalf("unexpected state in exported replica: %+v", replica)
+	}
+
+	// tasks keep their status, so that they are not run again
+	if task := bundle.Pgtasks[0]; task.Spec.Status != crv1.CompletedStatus ||
+		task.Status.State != crv1.PgtaskStateProcessed {
+		t.Fatalf("unexpected state in exported task: %+v", task)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, format := range []string{"yaml", "json"} {
+		exported, err := Export(fake.NewSimpleClientset(testObjects()...), []string{"pgo", "other"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		data, err := exported.Marshal(format)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		bundle, err := Unmarshal(data)
+		if err != nil {
+			t.Fatalf("%s: %s", format, err)
+		}
+
+		if !reflect.DeepEqual(bundle, exported) {
+			t.Fatalf("%s: bundle changed in the round trip. expected %+v, got %+v", format,
+				exported, bundle)
+		}
+
+		target := fake.NewSimpleClientset()
+
+		result, err := Import(target, bundle)
+		if err != nil {
+			t.Fatalf("%s: %s", format, err)
+		}
+
+		if expected := (ImportResult{Created: 5}); result != expected {
+			t.Fatalf("%s: unexpected result. expected %+v, got %+v", format, expected, result)
+		}
+
+		// exporting the imported state results in the same bundle
+		reexported, err := Export(target, []string{"pgo", "other"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(reexported, exported) {
+			t.Fatalf("%s: unexpected state after import. expected %+v, got %+v", format,
+				exported, reexported)
+		}
+	}
+}
+
+func TestImportOrder(t *testing.T) {
+	bundle, err := Export(fake.NewSimpleClientset(testObjects()...), []string{"pgo", "other"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	target := fake.NewSimpleClientset()
+
+	if _, err := Import(target, bundle); err != nil {
+		t.Fatal(err)
+	}
+
+	// the clusters are created first, followed by the replicas, policies and tasks
+	created := []string{}
+	for _, action := range target.Actions() {
+		if action.GetVerb() == "create" {
+			created = append(created, action.GetResource().Resource)
+		}
+	}
+
+	expected := []string{"pgclusters", "pgclusters", "pgreplicas", "pgpolicies", "pgtasks"}
+	if !reflect.DeepEqual(created, expected) {
+		t.Fatalf("unexpected order of creation. expected %v, got %v", expected, created)
+	}
+}
+
+func TestImportUpsert(t *testing.T) {
+	bundle, err := Export(fake.NewSimpleClientset(testObjects()...), []string{"pgo", "other"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the target already has one of the clusters, with an outdated spec, and the annotations
+	// and status of the Operator that is running there
+	target := fake.NewSimpleClientset(&crv1.Pgcluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "hippo", Namespace: "pgo",
+			Annotations: map[string]string{"current-primary": "hippo"}},
+		Spec: crv1.PgclusterSpec{Name: "hippo", ClusterName: "hippo", Port: "5433",
+			Status: crv1.CompletedStatus},
+		Status: crv1.PgclusterStatus{State: crv1.PgclusterStateInitialized},
+	})
+
+	result, err := Import(target, bundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := (ImportResult{Created: 4, Updated: 1}); result != expected {
+		t.Fatalf("unexpected result. expected %+v, got %+v", expected, result)
+	}
+
+	cluster, err := target.CrunchydataV1().Pgclusters("pgo").Get("hippo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cluster.Spec.Port != "5432" || cluster.Labels["pg-cluster"] != "hippo" {
+		t.Fatalf("cluster was not updated: %+v", cluster)
+	}
+
+	// what the Operator keeps for the existing cluster is left alone
+	if cluster.Spec.Status != crv1.CompletedStatus ||
+		cluster.Status.State != crv1.PgclusterStateInitialized ||
+		cluster.Annotations["current-primary"] != "hippo" {
+		t.Fatalf("state of the existing cluster was not kept: %+v", cluster)
+	}
+
+	// importing the same bundle again changes nothing
+	result, err = Import(target, bundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := (ImportResult{Unchanged: 5}); result != expected {
+		t.Fatalf("unexpected result of the second import. expected %+v, got %+v", expected, result)
+	}
+}
+
+func TestImportMissingCluster(t *testing.T) {
+	bundle := &Bundle{
+		Version: Version,
+		Pgreplicas: []crv1.Pgreplica{{
+			ObjectMeta: metav1.ObjectMeta{Name: "hippo-abcd", Namespace: "pgo"},
+			Spec:       crv1.PgreplicaSpec{Name: "hippo-abcd", ClusterName: "hippo"},
+		}},
+		Pgpolicies: []crv1.Pgpolicy{{
+			ObjectMeta: metav1.ObjectMeta{Name: "audit", Namespace: "pgo"},
+		}},
+	}
+
+	target := fake.NewSimpleClientset()
+
+	// the replica cannot be applied without its cluster, but the policy still is
+	result, err := Import(target, bundle)
+	if err == nil {
+		t.Fatal("expected an error for the replica without a cluster")
+	}
+
+	if expected := (ImportResult{Created: 1, Failed: 1}); result != expected {
+		t.Fatalf("unexpected result. expected %+v, got %+v", expected, result)
+	}
+
+	// once the cluster exists, the replica can be imported
+	if _, err := target.CrunchydataV1().Pgclusters("pgo").Create(&crv1.Pgcluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "hippo", Namespace: "pgo"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err = Import(target, bundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := (ImportResult{Created: 1, Unchanged: 1}); result != expected {
+		t.Fatalf("unexpected result. expected %+v, got %+v", expected, result)
+	}
+}
+
+func TestUnmarshal(t *testing.T) {
+	for _, data := range []string{
+		`{}`,
+		`version: v2`,
+		`pgclusters: []`,
+	} {
+		if _, err := Unmarshal([]byte(data)); err == nil {
+			t.Fatalf("expected an error for %q", data)
+		}
+	}
+
+	bundle, err := Unmarshal([]byte(`{"version": "v1", "pgclusters": [{"metadata": {"name": "hippo"}}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(bundle.Pgclusters) != 1 || bundle.Pgclusters[0].Name != "hippo" {
+		t.Fatalf("unexpected bundle: %+v", bundle)
+	}
+}
@@ -0,0 +1,244 @@
+package bundle
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/pkg/generated/clientset/versioned"
+	log "github.com/sirupsen/logrus"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ImportResult counts what happened to the resources of a bundle when it was imported
+type ImportResult struct {
+	// Created is the number of resources that did not exist and were created
+	Created int
+	// Updated is the number of resources that existed and were updated
+	Updated int
+	// Unchanged is the number of resources that existed as they are in the bundle
+	Unchanged int
+	// Failed is the number of resources that could not be applied
+	Failed int
+}
+
+// upsertResult is the outcome of applying a single resource
+type upsertResult int
+
+const (
+	upsertCreated upsertResult = iota
+	upsertUpdated
+	upsertUnchanged
+)
+
+// Import applies the resources of the bundle through the clientset. A resource that already exists
+// is updated rather than created, so that a bundle can be imported into a Kubernetes cluster that
+// already has some of its resources, e.g. when an earlier import was interrupted.
+//
+// The resources are applied in the order of their dependencies, i.e. the Pgclusters first, as the
+// other resources refer to them, followed by the Pgreplicas, Pgpolicies and Pgtasks. A Pgreplica
+// whose Pgcluster is neither in the bundle nor in the Kubernetes cluster is not applied. All of the
+// resources that can be applied are, and the errors of those that cannot are returned together
+func Import(clientset versioned.Interface, bundle *Bundle) (ImportResult, error) {
+	result := ImportResult{}
+	failures := []string{}
+
+	record := func(kind, namespace, name string, outcome upsertResult, err error) {
+		if err != nil {
+			log.Errorf("could not import %s %s: %s", kind, key(namespace, name), err)
+			failures = append(failures, fmt.Sprintf("%s %s: %s", kind, key(namespace, name), err))
+			result.Failed++
+			return
+		}
+
+		switch outcome {
+		case upsertCreated:
+			result.Created++
+		case upsertUpdated:
+			result.Updated++
+		case upsertUnchanged:
+			result.Unchanged++
+		}
+	}
+
+	// the clusters that the other resources can depend on
+	clusters := map[string]bool{}
+
+	for i := range bundle.Pgclusters {
+		cluster := &bundle.Pgclusters[i]
+		outcome, err := upsertPgcluster(clientset, cluster)
+		record("pgcluster", cluster.Namespace, cluster.Name, outcome, err)
+		clusters[key(cluster.Namespace, cluster.Name)] = err == nil
+	}
+
+	for i := range bundle.Pgreplicas {
+		replica := &bundle.Pgreplicas[i]
+
+		if err := checkPgcluster(clientset, clusters, replica.Namespace,
+			replica.Spec.ClusterName); err != nil {
+			record("pgreplica", replica.Namespace, replica.Name, 0, err)
+			continue
+		}
+
+		outcome, err := upsertPgreplica(clientset, replica)
+		record("pgreplica", replica.Namespace, replica.Name, outcome, err)
+	}
+
+	for i := range bundle.Pgpolicies {
+		policy := &bundle.Pgpolicies[i]
+		outcome, err := upsertPgpolicy(clientset, policy)
+		record("pgpolicy", policy.Namespace, policy.Name, outcome, err)
+	}
+
+	for i := range bundle.Pgtasks {
+		task := &bundle.Pgtasks[i]
+		outcome, err := upsertPgtask(clientset, task)
+		record("pgtask", task.Namespace, task.Name, outcome, err)
+	}
+
+	if len(failures) > 0 {
+		return result, errors.New(strings.Join(failures, "; "))
+	}
+
+	return result, nil
+}
+
+// checkPgcluster ensures that the Pgcluster a resource depends on is available, i.e. it has been
+// applied from the bundle, or it already exists
+func checkPgcluster(clientset versioned.Interface, clusters map[string]bool, namespace,
+	name string) error {
+	if applied, ok := clusters[key(namespace, name)]; ok {
+		if !applied {
+			return fmt.Errorf("pgcluster %s could not be imported", key(namespace, name))
+		}
+		return nil
+	}
+
+	_, err := clientset.CrunchydataV1().Pgclusters(namespace).Get(name, metav1.GetOptions{})
+	if kerrors.IsNotFound(err) {
+		return fmt.Errorf("pgcluster %s is neither in the bundle nor does it exist",
+			key(namespace, name))
+	}
+
+	return err
+}
+
+// upsertPgcluster creates the Pgcluster, or updates it if it exists. An update keeps the status
+// the Operator keeps in the spec of the existing cluster
+func upsertPgcluster(clientset versioned.Interface, desired *crv1.Pgcluster) (upsertResult, error) {
+	client := clientset.CrunchydataV1().Pgclusters(desired.Namespace)
+
+	existing, err := client.Get(desired.Name, metav1.GetOptions{})
+	if kerrors.IsNotFound(err) {
+		_, err := client.Create(desired.DeepCopy())
+		return upsertCreated, err
+	} else if err != nil {
+		return upsertUnchanged, err
+	}
+
+	updated := existing.DeepCopy()
+	mergeMetadata(&updated.ObjectMeta, desired.ObjectMeta)
+	updated.Spec = *desired.Spec.DeepCopy()
+	updated.Spec.Status = existing.Spec.Status
+
+	if reflect.DeepEqual(updated, existing) {
+		return upsertUnchanged, nil
+	}
+
+	_, err = client.Update(updated)
+	return upsertUpdated, err
+}
+
+// upsertPgreplica creates the Pgreplica, or updates it if it exists. An update keeps the status
+// the Operator keeps in the spec of the existing replica
+func upsertPgreplica(clientset versioned.Interface, desired *crv1.Pgreplica) (upsertResult, error) {
+	client := clientset.CrunchydataV1().Pgreplicas(desired.Namespace)
+
+	existing, err := client.Get(desired.Name, metav1.GetOptions{})
+	if kerrors.IsNotFound(err) {
+		_, err := client.Create(desired.DeepCopy())
+		return upsertCreated, err
+	} else if err != nil {
+		return upsertUnchanged, err
+	}
+
+	updated := existing.DeepCopy()
+	mergeMetadata(&updated.ObjectMeta, desired.ObjectMeta)
+	updated.Spec = *desired.Spec.DeepCopy()
+	updated.Spec.Status = existing.Spec.Status
+
+	if reflect.DeepEqual(updated, existing) {
+		return upsertUnchanged, nil
+	}
+
+	_, err = client.Update(updated)
+	return upsertUpdated, err
+}
+
+// upsertPgpolicy creates the Pgpolicy, or updates it if it exists
+func upsertPgpolicy(clientset versioned.Interface, desired *crv1.Pgpolicy) (upsertResult, error) {
+	client := clientset.CrunchydataV1().Pgpolicies(desired.Namespace)
+
+	existing, err := client.Get(desired.Name, metav1.GetOptions{})
+	if kerrors.IsNotFound(err) {
+		_, err := client.Create(desired.DeepCopy())
+		return upsertCreated, err
+	} else if err != nil {
+		return upsertUnchanged, err
+	}
+
+	updated := existing.DeepCopy()
+	mergeMetadata(&updated.ObjectMeta, desired.ObjectMeta)
+	updated.Spec = desired.Spec
+
+	if reflect.DeepEqual(updated, existing) {
+		return upsertUnchanged, nil
+	}
+
+	_, err = client.Update(updated)
+	return upsertUpdated, err
+}
+
+// upsertPgtask creates the Pgtask, or updates it if it exists. A task that already exists keeps
+// its own status, as it reflects what has happened to the task in this Kubernetes cluster
+func upsertPgtask(clientset versioned.Interface, desired *crv1.Pgtask) (upsertResult, error) {
+	client := clientset.CrunchydataV1().Pgtasks(desired.Namespace)
+
+	existing, err := client.Get(desired.Name, metav1.GetOptions{})
+	if kerrors.IsNotFound(err) {
+		_, err := client.Create(desired.DeepCopy())
+		return upsertCreated, err
+	} else if err != nil {
+		return upsertUnchanged, err
+	}
+
+	updated := existing.DeepCopy()
+	mergeMetadata(&updated.ObjectMeta, desired.ObjectMeta)
+	updated.Spec = *desired.Spec.DeepCopy()
+	updated.Spec.Status = existing.Spec.Status
+
+	if reflect.DeepEqual(updated, existing) {
+		return upsertUnchanged, nil
+	}
+
+	_, err = client.Update(updated)
+	return upsertUpdated, err
+}
@@ -0,0 +1,105 @@
+package bundle
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// exportObjectMeta keeps the parts of the metadata of a resource that are needed to create it
+// again, dropping those that are assigned by Kubernetes
+func exportObjectMeta(meta metav1.ObjectMeta) metav1.ObjectMeta {
+	return metav1.ObjectMeta{
+		Name:        meta.Name,
+		Namespace:   meta.Namespace,
+		Labels:      meta.Labels,
+		Annotations: meta.Annotations,
+	}
+}
+
+// exportTypeMeta returns the type of a resource of the given kind, which the objects returned by
+// a lister do not have set
+func exportTypeMeta(kind string) metav1.TypeMeta {
+	return metav1.TypeMeta{
+		APIVersion: crv1.SchemeGroupVersion.String(),
+		Kind:       kind,
+	}
+}
+
+// exportPgcluster returns the desired state of a Pgcluster. The status the Operator keeps for the
+// cluster, including the status in the spec, is dropped so that the cluster is created again
+// once the bundle is imported
+func exportPgcluster(cluster *crv1.Pgcluster) crv1.Pgcluster {
+	exported := cluster.DeepCopy()
+	exported.TypeMeta = exportTypeMeta("Pgcluster")
+	exported.ObjectMeta = exportObjectMeta(exported.ObjectMeta)
+	exported.Spec.Status = ""
+	exported.Status = crv1.PgclusterStatus{}
+
+	return *exported
+}
+
+// exportPgreplica returns the desired state of a Pgreplica. The status the Operator keeps for the
+// replica, including the status in the spec, is dropped so that the replica is created again once
+// the bundle is imported
+func exportPgreplica(replica *crv1.Pgreplica) crv1.Pgreplica {
+	exported := replica.DeepCopy()
+	exported.TypeMeta = exportTypeMeta("Pgreplica")
+	exported.ObjectMeta = exportObjectMeta(exported.ObjectMeta)
+	exported.Spec.Status = ""
+	exported.Status = crv1.PgreplicaStatus{}
+
+	return *exported
+}
+
+// exportPgpolicy returns the desired state of a Pgpolicy
+func exportPgpolicy(policy *crv1.Pgpolicy) crv1.Pgpolicy {
+	exported := policy.DeepCopy()
+	exported.TypeMeta = exportTypeMeta("Pgpolicy")
+	exported.ObjectMeta = exportObjectMeta(exported.ObjectMeta)
+
+	return *exported
+}
+
+// exportPgtask returns the desired state of a Pgtask, which keeps its status so that a task that
+// is done is not run again
+func exportPgtask(task *crv1.Pgtask) crv1.Pgtask {
+	exported := task.DeepCopy()
+	exported.TypeMeta = exportTypeMeta("Pgtask")
+	exported.ObjectMeta = exportObjectMeta(exported.ObjectMeta)
+
+	return *exported
+}
+
+// mergeMetadata applies the labels and annotations of a resource in a bundle to the metadata of
+// the resource that already exists, keeping those of its labels and annotations that are not in
+// the bundle
+func mergeMetadata(existing *metav1.ObjectMeta, desired metav1.ObjectMeta) {
+	for k, v := range desired.Labels {
+		if existing.Labels == nil {
+			existing.Labels = map[string]string{}
+		}
+		existing.Labels[k] = v
+	}
+
+	for k, v := range desired.Annotations {
+		if existing.Annotations == nil {
+			existing.Annotations = map[string]string{}
+		}
+		existing.Annotations[k] = v
+	}
+}
@@ -0,0 +1,86 @@
+package operator
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"github.com/crunchydata/postgres-operator/config"
+	"github.com/crunchydata/postgres-operator/kubeapi"
+	log "github.com/sirupsen/logrus"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// GetChargebackLabels returns the chargeback labels of a Namespace, i.e. those of its labels that
+// are configured to be copied onto the resources that the Operator creates in the Namespace
+func GetChargebackLabels(clientset *kubernetes.Clientset, namespace string) (map[string]string, error) {
+	if len(Pgo.Pgo.ChargebackLabels) == 0 {
+		return map[string]string{}, nil
+	}
+
+	ns, _, err := kubeapi.GetNamespace(clientset, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	return SelectChargebackLabels(ns.ObjectMeta.Labels, Pgo.Pgo.ChargebackLabels), nil
+}
+
+// SelectChargebackLabels returns the labels of a Namespace that have one of the given keys
+func SelectChargebackLabels(namespaceLabels map[string]string, keys []string) map[string]string {
+	chargeback := map[string]string{}
+
+	for _, key := range keys {
+		if value, ok := namespaceLabels[key]; ok {
+			chargeback[key] = value
+		}
+	}
+
+	return chargeback
+}
+
+// SetChargebackLabels sets the chargeback labels on the metadata of a resource, returning whether
+// the metadata changed. The keys of the labels that are set are recorded in an annotation, so that
+// a chargeback label that is no longer on the Namespace is removed, and so that a label that the
+// Operator itself, or anyone else, set on the resource is never overwritten
+func SetChargebackLabels(meta *meta_v1.ObjectMeta, chargeback map[string]string) bool {
+	return setTrackedMetadata(meta, &meta.Labels, chargeback, config.ANNOTATION_CHARGEBACK_LABELS)
+}
+
+// AddChargebackLabels sets the chargeback labels of a Namespace on the metadata of a resource
+// that is about to be created in it, e.g. on a Deployment and on the template of its Pods, so that
+// the resource and its children are labeled from the start. If the labels of the Namespace cannot
+// be looked up, they are left to the periodic reconcile of the chargeback labels
+func AddChargebackLabels(clientset *kubernetes.Clientset, namespace string, metas ...*meta_v1.ObjectMeta) {
+	chargeback, err := GetChargebackLabels(clientset, namespace)
+	if err != nil {
+		log.Errorf("chargeback: could not get the labels of namespace %s: %s", namespace, err)
+		return
+	}
+
+	addChargebackLabels(chargeback, metas...)
+}
+
+// addChargebackLabels sets chargeback labels on the metadata of the resources that are about to
+// be created
+func addChargebackLabels(chargeback map[string]string, metas ...*meta_v1.ObjectMeta) {
+	if len(chargeback) == 0 {
+		return
+	}
+
+	for _, meta := range metas {
+		SetChargebackLabels(meta, chargeback)
+	}
+}
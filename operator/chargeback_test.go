@@ -0,0 +1,231 @@
+package operator
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/crunchydata/postgres-operator/config"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestSelectChargebackLabels(t *testing.T) {
+	namespaceLabels := map[string]string{"cost-center": "cc-42", "team": "payments", "env": "prod"}
+
+	tests := []struct {
+		keys     []string
+		expected map[string]string
+	}{
+		{nil, map[string]string{}},
+		{[]string{"cost-center", "team"}, map[string]string{"cost-center": "cc-42", "team": "payments"}},
+		// a key that the Namespace does not have is skipped
+		{[]string{"team", "owner"}, map[string]string{"team": "payments"}},
+	}
+
+	for i, test := range tests {
+		chargeback := SelectChargebackLabels(namespaceLabels, test.keys)
+		if !reflect.DeepEqual(chargeback, test.expected) {
+			t.Fatalf("tests[%d] - unexpected chargeback labels. expected %v, got %v",
+				i, test.expected, chargeback)
+		}
+	}
+}
+
+func TestSetChargebackLabels(t *testing.T) {
+	keys := []string{"cost-center", "team"}
+	namespaceLabels := map[string]string{"cost-center": "cc-42", "team": "payments", "env": "prod"}
+
+	// the resources as they are created for a cluster, each with its functional labels
+	newMeta := func(name string) meta_v1.ObjectMeta {
+		return meta_v1.ObjectMeta{
+			Name: name,
+			Labels: map[string]string{
+				config.LABEL_PG_CLUSTER: "hippo",
+				config.LABEL_VENDOR:     config.LABEL_CRUNCHY,
+			},
+		}
+	}
+
+	pod := v1.Pod{ObjectMeta: newMeta("hippo-abcd")}
+	pvc := v1.PersistentVolumeClaim{ObjectMeta: newMeta("hippo")}
+	service := v1.Service{ObjectMeta: newMeta("hippo")}
+	secret := v1.Secret{ObjectMeta: newMeta("hippo-postgres-secret")}
+	job := batchv1.Job{ObjectMeta: newMeta("hippo-backrest-full-backup")}
+
+	resources := []*meta_v1.ObjectMeta{
+		&pod.ObjectMeta, &pvc.ObjectMeta, &service.ObjectMeta, &secret.ObjectMeta, &job.ObjectMeta,
+	}
+
+	check := func(step string, expected map[string]string) {
+		t.Helper()
+
+		for _, meta := range resources {
+			if !reflect.DeepEqual(meta.Labels, expected) {
+				t.Fatalf("%s - unexpected labels on %s. expected %v, got %v",
+					step, meta.Name, expected, meta.Labels)
+			}
+		}
+	}
+
+	t.Run("created", func(t *testing.T) {
+		chargeback := SelectChargebackLabels(namespaceLabels, keys)
+
+		for _, meta := range resources {
+			if !SetChargebackLabels(meta, chargeback) {
+				t.Fatalf("expected the labels of %s to change", meta.Name)
+			}
+		}
+
+		check("created", map[string]string{
+			config.LABEL_PG_CLUSTER: "hippo",
+			config.LABEL_VENDOR:     config.LABEL_CRUNCHY,
+			"cost-center":           "cc-42",
+			"team":                  "payments",
+		})
+
+		// applying the same labels again changes nothing
+		for _, meta := range resources {
+			if SetChargebackLabels(meta, chargeback) {
+				t.Fatalf("expected the labels of %s to be unchanged", meta.Name)
+			}
+		}
+	})
+
+	t.Run("namespace changed", func(t *testing.T) {
+		chargeback := SelectChargebackLabels(map[string]string{"cost-center": "cc-7"}, keys)
+
+		for _, meta := range resources {
+			if !SetChargebackLabels(meta, chargeback) {
+				t.Fatalf("expected the labels of %s to change", meta.Name)
+			}
+		}
+
+		// the updated label is propagated and the label removed from the Namespace is dropped
+		check("namespace changed", map[string]string{
+			config.LABEL_PG_CLUSTER: "hippo",
+			config.LABEL_VENDOR:     config.LABEL_CRUNCHY,
+			"cost-center":           "cc-7",
+		})
+	})
+
+	t.Run("functional labels", func(t *testing.T) {
+		meta := newMeta("hippo-repo")
+		meta.Labels["team"] = "dba"
+
+		chargeback := SelectChargebackLabels(map[string]string{
+			config.LABEL_PG_CLUSTER: "other",
+			"team":                  "payments",
+		}, []string{config.LABEL_PG_CLUSTER, "team"})
+
+		SetChargebackLabels(&meta, chargeback)
+
+		// labels that were not set from the Namespace are never overwritten
+		expected := map[string]string{
+			config.LABEL_PG_CLUSTER: "hippo",
+			config.LABEL_VENDOR:     config.LABEL_CRUNCHY,
+			"team":                  "dba",
+		}
+
+		if !reflect.DeepEqual(meta.Labels, expected) {
+			t.Fatalf("unexpected labels. expected %v, got %v", expected, meta.Labels)
+		}
+
+		// nor are they removed when the Namespace no longer has them
+		SetChargebackLabels(&meta, map[string]string{})
+
+		if !reflect.DeepEqual(meta.Labels, expected) {
+			t.Fatalf("unexpected labels. expected %v, got %v", expected, meta.Labels)
+		}
+	})
+}
+
+func TestAddChargebackLabels(t *testing.T) {
+	keys := []string{"cost-center", "team"}
+	chargeback := SelectChargebackLabels(map[string]string{"cost-center": "cc-42", "team": "payments"},
+		keys)
+
+	templateMeta := func() v1.PodTemplateSpec {
+		return v1.PodTemplateSpec{ObjectMeta: meta_v1.ObjectMeta{
+			Labels: map[string]string{config.LABEL_PG_CLUSTER: "hippo"},
+		}}
+	}
+
+	deployment := appsv1.Deployment{
+		ObjectMeta: meta_v1.ObjectMeta{Name: "hippo"},
+		Spec:       appsv1.DeploymentSpec{Template: templateMeta()},
+	}
+	job := batchv1.Job{
+		ObjectMeta: meta_v1.ObjectMeta{Name: "hippo-backrest-full-backup"},
+		Spec:       batchv1.JobSpec{Template: templateMeta()},
+	}
+	deployment.Labels = map[string]string{config.LABEL_PG_CLUSTER: "hippo"}
+	job.Labels = map[string]string{config.LABEL_PG_CLUSTER: "hippo"}
+
+	t.Run("no labels", func(t *testing.T) {
+		meta := meta_v1.ObjectMeta{Labels: map[string]string{config.LABEL_PG_CLUSTER: "hippo"}}
+
+		addChargebackLabels(map[string]string{}, &meta)
+
+		// nothing is recorded when the Namespace has no chargeback labels
+		if len(meta.Labels) != 1 || len(meta.Annotations) != 0 {
+			t.Fatalf("expected the metadata to be unchanged, got %v %v", meta.Labels, meta.Annotations)
+		}
+	})
+
+	t.Run("created", func(t *testing.T) {
+		addChargebackLabels(chargeback, &deployment.ObjectMeta, &deployment.Spec.Template.ObjectMeta)
+		addChargebackLabels(chargeback, &job.ObjectMeta, &job.Spec.Template.ObjectMeta)
+
+		expected := map[string]string{
+			config.LABEL_PG_CLUSTER: "hippo",
+			"cost-center":           "cc-42",
+			"team":                  "payments",
+		}
+
+		for _, meta := range []meta_v1.ObjectMeta{deployment.ObjectMeta, deployment.Spec.Template.ObjectMeta,
+			job.ObjectMeta, job.Spec.Template.ObjectMeta} {
+			if !reflect.DeepEqual(meta.Labels, expected) {
+				t.Fatalf("unexpected labels on %q. expected %v, got %v", meta.Name, expected, meta.Labels)
+			}
+		}
+	})
+
+	t.Run("child resources", func(t *testing.T) {
+		// the Pods of a Deployment or a Job are created from its template, so they are labeled
+		// from the start, and they record which of their labels are chargeback labels
+		for _, template := range []v1.PodTemplateSpec{deployment.Spec.Template, job.Spec.Template} {
+			pod := v1.Pod{ObjectMeta: *template.ObjectMeta.DeepCopy()}
+			pod.Name = "hippo-abcd"
+
+			if SetChargebackLabels(&pod.ObjectMeta, chargeback) {
+				t.Fatalf("expected the labels of a new pod to be unchanged, got %v", pod.Labels)
+			}
+
+			// so the periodic reconcile can later update and remove them on the Pod itself
+			SetChargebackLabels(&pod.ObjectMeta,
+				SelectChargebackLabels(map[string]string{"team": "ledger"}, keys))
+
+			expected := map[string]string{config.LABEL_PG_CLUSTER: "hippo", "team": "ledger"}
+			if !reflect.DeepEqual(pod.Labels, expected) {
+				t.Fatalf("unexpected labels on pod. expected %v, got %v", expected, pod.Labels)
+			}
+		}
+	})
+}
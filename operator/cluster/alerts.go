@@ -0,0 +1,201 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/config"
+	"github.com/crunchydata/postgres-operator/kubeapi"
+	"github.com/crunchydata/postgres-operator/operator"
+	log "github.com/sirupsen/logrus"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// alertsSuffix is the suffix of the name of the PrometheusRule of a cluster
+const alertsSuffix = "-alerts"
+
+// alertsTemplateFields are the fields of the template of the PrometheusRule of a cluster
+type alertsTemplateFields struct {
+	ClusterName string
+	Namespace   string
+	// PGCluster is the "pg_cluster" label of the metrics of the cluster, as the monitor of the
+	// cluster and the pgMonitor scrape configuration label them
+	PGCluster string
+	// PVCPattern matches the PVCs of the instances and the pgBackRest repository of the cluster
+	PVCPattern            string
+	ReplicationLagSeconds int
+	DiskUsagePercent      int
+	ConnectionsPercent    int
+	// BackupAgeHours is zero if the cluster is not backed up by pgBackRest, in which case the
+	// alert on the age of its last backup is left out
+	BackupAgeHours int
+}
+
+// ValidateAlerts returns an error if the alerts spec of a cluster cannot be applied, i.e. one of
+// its thresholds is negative or a percentage exceeds 100
+func ValidateAlerts(cluster *crv1.Pgcluster) error {
+	spec := cluster.Spec.Alerts
+
+	if spec.ReplicationLagSeconds < 0 || spec.BackupAgeHours < 0 {
+		return fmt.Errorf("invalid alerts thresholds, the replication lag and backup age must not be negative")
+	}
+
+	for name, percent := range map[string]int{
+		"disk usage":  spec.DiskUsagePercent,
+		"connections": spec.ConnectionsPercent,
+	} {
+		if percent < 0 || percent > 100 {
+			return fmt.Errorf("invalid alerts %s threshold %d, must be a percentage", name, percent)
+		}
+	}
+
+	return nil
+}
+
+// ReconcileAlerts brings the PrometheusRule of a cluster in line with its spec: it is created or
+// updated while both the alerts and the exporter are enabled, and removed otherwise
+func ReconcileAlerts(clientset *kubernetes.Clientset, cluster *crv1.Pgcluster) error {
+	if err := ValidateAlerts(cluster); err != nil {
+		return err
+	}
+
+	name := cluster.Name + alertsSuffix
+
+	if !cluster.Spec.Alerts.Enabled || !operator.IsExporterEnabled(&cluster.Spec) {
+		if err := kubeapi.DeletePrometheusRule(clientset, name,
+			cluster.Namespace); err != nil && !kerrors.IsNotFound(err) {
+			return err
+		}
+		return nil
+	}
+
+	desired, err := getPrometheusRule(cluster)
+	if err != nil {
+		return err
+	}
+
+	current, found, err := kubeapi.GetPrometheusRule(clientset, name, cluster.Namespace)
+	if err != nil && !kerrors.IsNotFound(err) {
+		return err
+	}
+
+	if !found {
+		log.Debugf("creating prometheusrule for cluster %s", cluster.Name)
+		return kubeapi.CreatePrometheusRule(clientset, desired, cluster.Namespace)
+	}
+
+	if reflect.DeepEqual(current.Spec, desired.Spec) && reflect.DeepEqual(current.Labels, desired.Labels) {
+		return nil
+	}
+
+	log.Debugf("updating prometheusrule of cluster %s", cluster.Name)
+
+	current.Labels = desired.Labels
+	current.Spec = desired.Spec
+
+	return kubeapi.UpdatePrometheusRule(clientset, current, cluster.Namespace)
+}
+
+// DeleteAlerts removes the PrometheusRule of a cluster, if it has one
+func DeleteAlerts(clientset *kubernetes.Clientset, cluster *crv1.Pgcluster) error {
+	// as with the monitor, a cluster that never alerted is not looked for
+	if !cluster.Spec.Alerts.Enabled {
+		return nil
+	}
+
+	if err := kubeapi.DeletePrometheusRule(clientset, cluster.Name+alertsSuffix,
+		cluster.Namespace); err != nil && !kerrors.IsNotFound(err) {
+		return err
+	}
+
+	return nil
+}
+
+// getPrometheusRule returns the PrometheusRule of a cluster. Its alerts are rendered from the
+// template with the thresholds of the cluster, and are labeled with the labels of the spec
+// along with the cluster they fire for
+func getPrometheusRule(cluster *crv1.Pgcluster) (*kubeapi.PrometheusRule, error) {
+	spec := cluster.Spec.Alerts
+
+	fields := alertsTemplateFields{
+		ClusterName:           cluster.Name,
+		Namespace:             cluster.Namespace,
+		PGCluster:             cluster.Namespace + ":" + cluster.Name,
+		PVCPattern:            fmt.Sprintf("%[1]s|%[1]s-[a-z]{4}|%[1]s-pgbr-repo", cluster.Name),
+		ReplicationLagSeconds: spec.GetReplicationLagSeconds(),
+		DiskUsagePercent:      spec.GetDiskUsagePercent(),
+		ConnectionsPercent:    spec.GetConnectionsPercent(),
+	}
+
+	if cluster.Labels[config.LABEL_BACKREST] == "true" {
+		fields.BackupAgeHours = spec.GetBackupAgeHours()
+	}
+
+	var doc bytes.Buffer
+	if err := config.PrometheusRulesTemplate.Execute(&doc, fields); err != nil {
+		return nil, err
+	}
+
+	if operator.CRUNCHY_DEBUG {
+		log.Debug(doc.String())
+	}
+
+	rule := &kubeapi.PrometheusRule{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   cluster.Name + alertsSuffix,
+			Labels: getMonitorLabels(cluster),
+		},
+	}
+
+	if err := json.Unmarshal(doc.Bytes(), &rule.Spec); err != nil {
+		return nil, err
+	}
+
+	for i := range rule.Spec.Groups {
+		for j := range rule.Spec.Groups[i].Rules {
+			rule.Spec.Groups[i].Rules[j].Labels = getAlertLabels(cluster,
+				rule.Spec.Groups[i].Rules[j].Labels)
+		}
+	}
+
+	return rule, nil
+}
+
+// getAlertLabels returns the labels of an alert of a cluster, which are the labels of the spec
+// along with the cluster the alert fires for. The labels of the template, e.g. its severity,
+// take precedence
+func getAlertLabels(cluster *crv1.Pgcluster, template map[string]string) map[string]string {
+	labels := map[string]string{}
+
+	for key, value := range cluster.Spec.Alerts.Labels {
+		labels[key] = value
+	}
+
+	labels["pg_cluster"] = cluster.Namespace + ":" + cluster.Name
+
+	for key, value := range template {
+		labels[key] = value
+	}
+
+	return labels
+}
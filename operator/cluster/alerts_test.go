@@ -0,0 +1,90 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"strings"
+	"testing"
+	"text/template"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/config"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGetPrometheusRule(t *testing.T) {
+	config.PrometheusRulesTemplate = template.Must(template.ParseFiles(
+		"../../conf/postgres-operator/prometheus-rules.json"))
+
+	cluster := &crv1.Pgcluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "hippo", Namespace: "pgo"},
+		Spec: crv1.PgclusterSpec{
+			Exporter: crv1.ExporterSpec{Enabled: true},
+			Alerts: crv1.AlertsSpec{
+				Enabled:               true,
+				Labels:                map[string]string{"team": "dba", "severity": "page"},
+				ReplicationLagSeconds: 60,
+			},
+		},
+	}
+
+	rule, err := getPrometheusRule(cluster)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rule.Name != "hippo-alerts" || rule.Labels[config.LABEL_PG_CLUSTER] != "hippo" {
+		t.Fatalf("expected the rule to belong to the cluster, got %v", rule.ObjectMeta)
+	}
+
+	// without pgBackRest, the cluster is not alerted on for the age of its backups
+	rules := rule.Spec.Groups[0].Rules
+	if len(rules) != 3 {
+		t.Fatalf("expected 3 alerts, got %v", rules)
+	}
+
+	lag := rules[0]
+	if lag.Alert != "PGReplicationLag" || !strings.Contains(lag.Expr, `pg_cluster="pgo:hippo"`) ||
+		!strings.HasSuffix(lag.Expr, "> 60") {
+		t.Fatalf("expected the replication lag to be alerted on past 60 seconds, got %v", lag)
+	}
+
+	// the severity of the template is kept
+	if lag.Labels["team"] != "dba" || lag.Labels["severity"] != "warning" || lag.Labels["pg_cluster"] != "pgo:hippo" {
+		t.Fatalf("expected the labels of the spec and the cluster, got %v", lag.Labels)
+	}
+
+	if summary := lag.Annotations["summary"]; !strings.HasPrefix(summary, "Replica {{ $labels.deployment }}") {
+		t.Fatalf("expected the summary to be templated by Prometheus, got %q", summary)
+	}
+
+	if disk := rules[1]; !strings.Contains(disk.Expr, `persistentvolumeclaim=~"hippo|hippo-[a-z]{4}|hippo-pgbr-repo"`) ||
+		!strings.HasSuffix(disk.Expr, "> 80") {
+		t.Fatalf("expected the volumes of the cluster to be alerted on past the default, got %v", disk)
+	}
+
+	cluster.Labels = map[string]string{config.LABEL_BACKREST: "true"}
+
+	rule, err = getPrometheusRule(cluster)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rules := rule.Spec.Groups[0].Rules; len(rules) != 4 || rules[3].Alert != "PGBackupAge" ||
+		!strings.HasSuffix(rules[3].Expr, "> 25 * 3600") {
+		t.Fatalf("expected the age of the backups to be alerted on, got %v", rules)
+	}
+}
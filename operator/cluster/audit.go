@@ -0,0 +1,306 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/util"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	// auditLibrary is the name of the pgaudit library, as it is set in
+	// "shared_preload_libraries"
+	auditLibrary = "pgaudit"
+	// auditLogNone is the value of "pgaudit.log" that turns off session audit logging
+	auditLogNone = "none"
+)
+
+const (
+	sqlAuditSharedPreloadLibraries = `SHOW shared_preload_libraries;`
+	sqlAuditCreateExtension        = `CREATE EXTENSION IF NOT EXISTS pgaudit;`
+	// sqlAuditCreateRole creates the audit role if it does not exist yet. The
+	// role is only used to hold the privileges of the object audit rules, so it
+	// cannot log in
+	sqlAuditCreateRole = `DO $$ BEGIN
+IF NOT EXISTS (SELECT 1 FROM pg_catalog.pg_roles WHERE rolname = %s) THEN CREATE ROLE %s NOLOGIN; END IF;
+END $$;`
+	sqlAuditGrant  = `GRANT %s ON TABLE %s TO %s;`
+	sqlAuditRevoke = `REVOKE %s ON TABLE %s FROM %s;`
+)
+
+// auditPrivileges are the privileges on a table that an object audit rule can grant
+var auditPrivileges = map[string]bool{
+	"ALL":            true,
+	"ALL PRIVILEGES": true,
+	"DELETE":         true,
+	"INSERT":         true,
+	"REFERENCES":     true,
+	"SELECT":         true,
+	"TRIGGER":        true,
+	"TRUNCATE":       true,
+	"UPDATE":         true,
+}
+
+// ValidateAudit returns an error if any of the object audit rules of a cluster grants a privilege
+// that is not one on a table, or is not on a table
+func ValidateAudit(cluster *crv1.Pgcluster) error {
+	for _, rule := range cluster.Spec.Audit.ObjectRules {
+		if _, _, err := getAuditObjectRuleSQL(rule); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// UpdateAudit reconciles the pgaudit settings of a cluster. Loading pgaudit requires a restart,
+// but all of its other settings can be reloaded, so the cluster is only restarted when audit
+// logging is first enabled. Given that a restart can take some time, the settings are applied in
+// the background
+func UpdateAudit(clientset *kubernetes.Clientset, restconfig *rest.Config, cluster *crv1.Pgcluster,
+	old crv1.AuditSpec) {
+	// the primary is looked up by the namespace in the spec
+	cluster.Spec.Namespace = cluster.Namespace
+
+	go func() {
+		if err := updateAudit(clientset, restconfig, cluster, old); err != nil {
+			log.Errorf("could not update audit logging of cluster %s: %s", cluster.Name, err)
+		}
+	}()
+}
+
+// updateAudit sets the pgaudit parameters of the cluster, and then creates the pgaudit extension
+// and the object audit rules as needed
+func updateAudit(clientset *kubernetes.Clientset, restconfig *rest.Config, cluster *crv1.Pgcluster,
+	old crv1.AuditSpec) error {
+	audit := cluster.Spec.Audit
+
+	pod, err := util.GetPrimaryPod(clientset, cluster)
+	if err != nil {
+		return err
+	}
+
+	parameters := map[string]string{
+		"pgaudit.log":  auditLogNone,
+		"pgaudit.role": audit.Role,
+	}
+
+	// turning off audit logging only turns off the logging, as unloading pgaudit would require
+	// another restart
+	if audit.Enabled {
+		if len(audit.Log) > 0 {
+			parameters["pgaudit.log"] = strings.Join(audit.Log, ",")
+		}
+
+		libraries, err := execMaintenanceSQL(clientset, restconfig, pod, "postgres",
+			sqlAuditSharedPreloadLibraries)
+		if err != nil {
+			return err
+		}
+
+		if !isAuditLibraryLoaded(libraries) {
+			if libraries != "" {
+				libraries += ","
+			}
+			parameters["shared_preload_libraries"] = libraries + auditLibrary
+		}
+	}
+
+	restarted, err := UpdatePostgreSQLParameters(clientset, restconfig, cluster, parameters)
+	if err != nil {
+		return err
+	}
+
+	log.Debugf("updated audit parameters of cluster %s, restarted: %t", cluster.Name, restarted)
+
+	if !audit.Enabled {
+		return nil
+	}
+
+	// the primary may have changed while the cluster was restarting
+	if restarted {
+		if pod, err = util.GetPrimaryPod(clientset, cluster); err != nil {
+			return err
+		}
+	}
+
+	if err := createAuditExtension(clientset, restconfig, pod); err != nil {
+		return err
+	}
+
+	return updateAuditObjectRules(clientset, restconfig, cluster, pod, old)
+}
+
+// createAuditExtension creates the pgaudit extension in every database of the cluster
+func createAuditExtension(clientset *kubernetes.Clientset, restconfig *rest.Config, pod *v1.Pod) error {
+	output, err := execMaintenanceSQL(clientset, restconfig, pod, "postgres", sqlMaintenanceDatabases)
+	if err != nil {
+		return err
+	}
+
+	for _, database := range strings.Split(output, "\n") {
+		if database == "" {
+			continue
+		}
+
+		if _, err := execMaintenanceSQL(clientset, restconfig, pod, database, sqlAuditCreateExtension); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// isAuditLibraryLoaded determines whether or not pgaudit is in the given list of shared preload
+// libraries, which may also be listed by its file name
+func isAuditLibraryLoaded(libraries string) bool {
+	for _, library := range strings.Split(libraries, ",") {
+		library = strings.Trim(strings.TrimSpace(library), `"'`)
+		if library == auditLibrary || library == auditLibrary+".so" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// updateAuditObjectRules grants the privileges of the object audit rules to the audit role, and
+// revokes those of rules that have been removed since the previous version of the settings
+func updateAuditObjectRules(clientset *kubernetes.Clientset, restconfig *rest.Config,
+	cluster *crv1.Pgcluster, pod *v1.Pod, old crv1.AuditSpec) error {
+	audit := cluster.Spec.Audit
+
+	if audit.Role == "" {
+		return nil
+	}
+
+	role := util.SQLQuoteIdentifier(audit.Role)
+
+	if _, err := execMaintenanceSQL(clientset, restconfig, pod, "postgres",
+		fmt.Sprintf(sqlAuditCreateRole, util.SQLQuoteLiteral(audit.Role), role)); err != nil {
+		return err
+	}
+
+	// only revoke the rules of the previous settings if they were granted to the same role
+	if old.Role == audit.Role {
+		for _, rule := range old.ObjectRules {
+			if containsAuditObjectRule(audit.ObjectRules, rule) {
+				continue
+			}
+
+			// a rule that was never valid was never granted either
+			privileges, object, err := getAuditObjectRuleSQL(rule)
+			if err != nil {
+				log.Debugf("audit: not revoking invalid object rule: %s", err)
+				continue
+			}
+
+			if _, err := execMaintenanceSQL(clientset, restconfig, pod, getAuditRuleDatabase(cluster, rule),
+				fmt.Sprintf(sqlAuditRevoke, privileges, object, role)); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, rule := range audit.ObjectRules {
+		privileges, object, err := getAuditObjectRuleSQL(rule)
+		if err != nil {
+			return err
+		}
+
+		if _, err := execMaintenanceSQL(clientset, restconfig, pod, getAuditRuleDatabase(cluster, rule),
+			fmt.Sprintf(sqlAuditGrant, privileges, object, role)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// containsAuditObjectRule determines whether or not the rule is in the list of rules
+func containsAuditObjectRule(rules []crv1.AuditObjectRule, rule crv1.AuditObjectRule) bool {
+	for _, r := range rules {
+		if reflect.DeepEqual(r, rule) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// getAuditRuleDatabase returns the database of an object audit rule, which defaults to the
+// database of the cluster
+func getAuditRuleDatabase(cluster *crv1.Pgcluster, rule crv1.AuditObjectRule) string {
+	if rule.Database != "" {
+		return rule.Database
+	}
+
+	return cluster.Spec.Database
+}
+
+// getAuditObjectRuleSQL returns the privileges and the quoted table of an object audit rule as
+// they are granted. The privileges are a list of the privileges on a table, and the object is a
+// table that may be qualified by its schema and may be preceded by "TABLE", e.g.
+// "TABLE public.accounts". Names that are quoted have their quotes taken off before they are
+// quoted again, and the others are taken as they are
+func getAuditObjectRuleSQL(rule crv1.AuditObjectRule) (string, string, error) {
+	privileges := []string{}
+	for _, privilege := range strings.Split(rule.Privileges, ",") {
+		privilege = strings.ToUpper(strings.Join(strings.Fields(privilege), " "))
+
+		if !auditPrivileges[privilege] {
+			return "", "", fmt.Errorf("invalid audit object rule privileges %q", rule.Privileges)
+		}
+
+		privileges = append(privileges, privilege)
+	}
+
+	fields := strings.Fields(rule.Object)
+	if len(fields) == 2 && strings.ToUpper(fields[0]) == "TABLE" {
+		fields = fields[1:]
+	}
+
+	if len(fields) != 1 {
+		return "", "", fmt.Errorf("invalid audit object rule object %q, must be a table", rule.Object)
+	}
+
+	names := strings.Split(fields[0], ".")
+	if len(names) > 2 {
+		return "", "", fmt.Errorf("invalid audit object rule object %q, must be a table", rule.Object)
+	}
+
+	for i, name := range names {
+		if len(name) > 1 && strings.HasPrefix(name, `"`) && strings.HasSuffix(name, `"`) {
+			name = strings.ReplaceAll(name[1:len(name)-1], `""`, `"`)
+		}
+
+		if name == "" {
+			return "", "", fmt.Errorf("invalid audit object rule object %q, must be a table", rule.Object)
+		}
+
+		names[i] = util.SQLQuoteIdentifier(name)
+	}
+
+	return strings.Join(privileges, ", "), strings.Join(names, "."), nil
+}
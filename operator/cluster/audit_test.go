@@ -0,0 +1,56 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"testing"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+)
+
+func TestGetAuditObjectRuleSQL(t *testing.T) {
+	for _, test := range []struct {
+		privileges, object string
+		expectedPrivileges string
+		expectedObject     string
+		valid              bool
+	}{
+		{"SELECT, UPDATE", "TABLE public.accounts", "SELECT, UPDATE", `"public"."accounts"`, true},
+		{"select", "accounts", "SELECT", `"accounts"`, true},
+		{"all  privileges", `public."Accounts"`, "ALL PRIVILEGES", `"public"."Accounts"`, true},
+		{"SELECT", `public."acc""ounts"`, "SELECT", `"public"."acc""ounts"`, true},
+		{"SELECT", `accounts"; DROP TABLE accounts; --`, "", "", false},
+		{"SELECT; DROP TABLE accounts", "accounts", "", "", false},
+		{"SELECT", "FUNCTION public.f()", "", "", false},
+		{"SELECT", "db.public.accounts", "", "", false},
+		{"SELECT", "public.", "", "", false},
+		{"", "accounts", "", "", false},
+	} {
+		rule := crv1.AuditObjectRule{Privileges: test.privileges, Object: test.object}
+
+		privileges, object, err := getAuditObjectRuleSQL(rule)
+		if (err == nil) != test.valid {
+			t.Errorf("%q on %q: expected valid: %v, got %v", test.privileges, test.object,
+				test.valid, err)
+			continue
+		}
+
+		if privileges != test.expectedPrivileges || object != test.expectedObject {
+			t.Errorf("%q on %q: expected %q on %q, got %q on %q", test.privileges, test.object,
+				test.expectedPrivileges, test.expectedObject, privileges, object)
+		}
+	}
+}
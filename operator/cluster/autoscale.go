@@ -0,0 +1,339 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/config"
+	"github.com/crunchydata/postgres-operator/kubeapi"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// sqlAutoscaleConnections returns the number of active client connections on an instance, along
+// with the maximum number of connections it allows
+const sqlAutoscaleConnections = `SELECT count(*), current_setting('max_connections')
+FROM pg_catalog.pg_stat_activity
+WHERE state = 'active' AND pid <> pg_catalog.pg_backend_pid();`
+
+// ValidateAutoscale returns an error if the autoscaling of a cluster is not set up in a way it
+// can be carried out, i.e. with an unknown metric, negative settings or bounds that overlap
+func ValidateAutoscale(cluster *crv1.Pgcluster) error {
+	autoscale := cluster.Spec.Autoscale
+
+	switch autoscale.Metric {
+	case "", crv1.AutoscaleMetricConnections, crv1.AutoscaleMetricCPU:
+	default:
+		return fmt.Errorf("invalid autoscale metric %q, must be %q or %q", autoscale.Metric,
+			crv1.AutoscaleMetricConnections, crv1.AutoscaleMetricCPU)
+	}
+
+	for _, setting := range []struct {
+		name  string
+		value int
+	}{
+		{"minimum replicas", autoscale.MinReplicas},
+		{"maximum replicas", autoscale.MaxReplicas},
+		{"connection threshold", autoscale.ConnectionThreshold},
+		{"CPU threshold", autoscale.CPUThreshold},
+		{"scale down percent", autoscale.ScaleDownPercent},
+		{"sustained seconds", autoscale.SustainedSeconds},
+		{"cooldown seconds", autoscale.CooldownSeconds},
+	} {
+		if setting.value < 0 {
+			return fmt.Errorf("invalid autoscale %s %d, it cannot be negative", setting.name,
+				setting.value)
+		}
+	}
+
+	if autoscale.MaxReplicas > 0 && autoscale.MinReplicas > autoscale.MaxReplicas {
+		return fmt.Errorf("the autoscale minimum of %d replicas is greater than the maximum of %d",
+			autoscale.MinReplicas, autoscale.MaxReplicas)
+	}
+
+	return nil
+}
+
+// ReconcileAutoscale measures the active connections across the ready instances of a cluster,
+// along with their CPU usage if the cluster is scaled on CPU, and publishes them in the status
+// of the cluster. If the metric of the cluster has been above its threshold for at least
+// "sustainedSeconds", and no replica has been added or removed within the last
+// "cooldownSeconds", a replica is added, up to "maxReplicas". Likewise, once the metric has been
+// below the scale down threshold for as long, the newest replica that the autoscaler added is
+// removed, down to "minReplicas". Replicas are added and removed one at a time, so none is added
+// or removed while another replica of the cluster is still on its way
+func ReconcileAutoscale(clientset *kubernetes.Clientset, restclient *rest.RESTClient,
+	restconfig *rest.Config, cluster *crv1.Pgcluster) error {
+	autoscale := cluster.Spec.Autoscale
+
+	if !autoscale.Enabled || cluster.Status.State != crv1.PgclusterStateInitialized {
+		return nil
+	}
+
+	// get the instances of the cluster, i.e. the Pods with a Patroni role
+	selector := fmt.Sprintf("%s=%s,%s", config.LABEL_PG_CLUSTER, cluster.Name, config.LABEL_PGHA_ROLE)
+
+	pods, err := kubeapi.GetPods(clientset, selector, cluster.Namespace)
+	if err != nil {
+		return err
+	}
+
+	active, maxConnections, instances := 0, 0, 0
+	ready := map[string]bool{}
+	readyPods := []*v1.Pod{}
+
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if !isReplicaPodReady(pod) {
+			continue
+		}
+
+		output, err := execMaintenanceSQL(clientset, restconfig, pod, "postgres", sqlAutoscaleConnections)
+		if err != nil {
+			return err
+		}
+
+		count, max, err := parseAutoscaleConnections(output)
+		if err != nil {
+			return err
+		}
+
+		active += count
+		maxConnections = max
+		instances++
+		ready[pod.Labels[config.LABEL_DEPLOYMENT_NAME]] = true
+		readyPods = append(readyPods, pod)
+	}
+
+	if instances == 0 || maxConnections == 0 {
+		return nil
+	}
+
+	status := cluster.Status.Autoscale
+	status.ActiveConnectionsPerInstance = active / instances
+	status.Saturation = status.ActiveConnectionsPerInstance * 100 / maxConnections
+
+	utilization := status.Saturation
+	if autoscale.GetMetric() == crv1.AutoscaleMetricCPU {
+		if status.CPUUtilization, err = getCPUUtilization(clientset, cluster, readyPods); err != nil {
+			return err
+		}
+		utilization = status.CPUUtilization
+	}
+
+	now := time.Now()
+
+	switch {
+	case utilization >= autoscale.GetThreshold():
+		status.UnderutilizedSince = ""
+		if status.SaturatedSince == "" {
+			status.SaturatedSince = now.Format(time.RFC3339)
+		}
+	case utilization < autoscale.GetScaleDownThreshold():
+		status.SaturatedSince = ""
+		if status.UnderutilizedSince == "" {
+			status.UnderutilizedSince = now.Format(time.RFC3339)
+		}
+	default:
+		status.SaturatedSince, status.UnderutilizedSince = "", ""
+	}
+
+	replicas := crv1.PgreplicaList{}
+	selector = fmt.Sprintf("%s=%s", config.LABEL_PG_CLUSTER, cluster.Name)
+
+	if err := kubeapi.GetpgreplicasBySelector(restclient, &replicas, selector, cluster.Namespace); err != nil {
+		return err
+	}
+
+	pending := false
+	autoscaled := []crv1.Pgreplica{}
+	for _, replica := range replicas.Items {
+		pending = pending || !ready[replica.Name]
+
+		if replica.Labels[config.LABEL_AUTOSCALED_REPLICA] == config.LABEL_TRUE {
+			autoscaled = append(autoscaled, replica)
+		}
+	}
+
+	log.Debugf("autoscale: cluster %s is at %d%% of its %s threshold with %d replicas, pending: %t",
+		cluster.Name, utilization, autoscale.GetMetric(), len(replicas.Items), pending)
+
+	switch getAutoscaleChange(autoscale, status, len(replicas.Items), len(autoscaled), pending, now) {
+	case 1:
+		log.Infof("autoscale: adding replica to cluster %s, which has %d replicas", cluster.Name,
+			len(replicas.Items))
+
+		if err := createReplica(restclient, cluster, config.LABEL_AUTOSCALED_REPLICA); err != nil {
+			return err
+		}
+
+		// the cluster needs to be saturated for another sustained period for the next replica
+		status.LastScaleTime = now.Format(time.RFC3339)
+		status.SaturatedSince = ""
+	case -1:
+		// the replica that was added last is the first to go
+		sort.Slice(autoscaled, func(i, j int) bool {
+			return autoscaled[j].CreationTimestamp.Before(&autoscaled[i].CreationTimestamp)
+		})
+
+		log.Infof("autoscale: removing replica %s from cluster %s, which has been underutilized "+
+			"since %s", autoscaled[0].Name, cluster.Name, status.UnderutilizedSince)
+
+		if err := removeReplica(restclient, cluster, autoscaled[0].Name); err != nil {
+			return err
+		}
+
+		status.LastScaleTime = now.Format(time.RFC3339)
+		status.UnderutilizedSince = ""
+	}
+
+	if status == cluster.Status.Autoscale {
+		return nil
+	}
+
+	return kubeapi.Patchpgcluster(restclient, cluster, cluster.Namespace, func(cluster *crv1.Pgcluster) {
+		cluster.Status.Autoscale = status
+	})
+}
+
+// getAutoscaleChange returns whether a replica is to be added to a cluster, i.e. 1, removed from
+// it, i.e. -1, or neither, i.e. 0. A cluster with fewer replicas than its minimum is given one
+// right away, while a saturated or an underutilized cluster is only scaled once it has been so
+// for long enough. Only the replicas that the autoscaler added are removed
+func getAutoscaleChange(autoscale crv1.AutoscaleSpec, status crv1.AutoscaleStatus,
+	replicas, autoscaled int, pending bool, now time.Time) int {
+	switch {
+	case pending:
+		return 0
+	case replicas < autoscale.MinReplicas:
+		return 1
+	case status.SaturatedSince != "" && replicas < autoscale.MaxReplicas &&
+		isAutoscaleDue(autoscale, status.SaturatedSince, status.LastScaleTime, now):
+		return 1
+	case status.UnderutilizedSince != "" && replicas > autoscale.MinReplicas && autoscaled > 0 &&
+		isAutoscaleDue(autoscale, status.UnderutilizedSince, status.LastScaleTime, now):
+		return -1
+	}
+
+	return 0
+}
+
+// isAutoscaleDue determines whether or not the cluster has been saturated or underutilized for
+// long enough, and whether or not the cooldown since the last replica was added or removed has
+// passed
+func isAutoscaleDue(autoscale crv1.AutoscaleSpec, since, lastScaleTime string, now time.Time) bool {
+	sinceTime, err := time.Parse(time.RFC3339, since)
+	if err != nil {
+		log.Error(err)
+		return false
+	}
+
+	if now.Sub(sinceTime) < time.Duration(autoscale.SustainedSeconds)*time.Second {
+		return false
+	}
+
+	if lastScaleTime == "" {
+		return true
+	}
+
+	last, err := time.Parse(time.RFC3339, lastScaleTime)
+	if err != nil {
+		log.Error(err)
+		return false
+	}
+
+	return now.Sub(last) >= time.Duration(autoscale.CooldownSeconds)*time.Second
+}
+
+// getCPUUtilization returns the average CPU usage of the database containers of the ready
+// instances of a cluster, as a percentage of their CPU request. An instance whose usage has not
+// been sampled yet, e.g. as it just started, is left out
+func getCPUUtilization(clientset *kubernetes.Clientset, cluster *crv1.Pgcluster,
+	pods []*v1.Pod) (int, error) {
+	selector := fmt.Sprintf("%s=%s,%s", config.LABEL_PG_CLUSTER, cluster.Name, config.LABEL_PGHA_ROLE)
+
+	metrics, err := kubeapi.GetPodMetrics(clientset, selector, cluster.Namespace)
+	if err != nil {
+		return 0, err
+	}
+
+	usage := map[string]resource.Quantity{}
+	for _, pod := range metrics {
+		for _, container := range pod.Containers {
+			if container.Name == "database" {
+				usage[pod.Name] = container.Usage[v1.ResourceCPU]
+			}
+		}
+	}
+
+	total, instances := int64(0), int64(0)
+	for _, pod := range pods {
+		used, ok := usage[pod.Name]
+		if !ok {
+			continue
+		}
+
+		var requested resource.Quantity
+		for _, container := range pod.Spec.Containers {
+			if container.Name == "database" {
+				requested = container.Resources.Requests[v1.ResourceCPU]
+			}
+		}
+
+		if requested.MilliValue() == 0 {
+			return 0, fmt.Errorf("instance %s has no CPU request to measure its CPU usage against",
+				pod.Name)
+		}
+
+		total += used.MilliValue() * 100 / requested.MilliValue()
+		instances++
+	}
+
+	if instances == 0 {
+		return 0, fmt.Errorf("the CPU usage of cluster %s has not been sampled", cluster.Name)
+	}
+
+	return int(total / instances), nil
+}
+
+// parseAutoscaleConnections parses the unaligned output of sqlAutoscaleConnections
+func parseAutoscaleConnections(output string) (int, int, error) {
+	fields := strings.Split(output, "|")
+	if len(fields) != 2 {
+		return 0, 0, fmt.Errorf("unexpected connection output %q", output)
+	}
+
+	active, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return 0, 0, err
+	}
+
+	max, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return active, max, nil
+}
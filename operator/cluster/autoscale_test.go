@@ -0,0 +1,90 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"testing"
+	"time"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+)
+
+func TestGetAutoscaleChange(t *testing.T) {
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	ago := func(d time.Duration) string { return now.Add(-d).Format(time.RFC3339) }
+
+	autoscale := crv1.AutoscaleSpec{
+		Enabled:          true,
+		MinReplicas:      1,
+		MaxReplicas:      3,
+		SustainedSeconds: 120,
+		CooldownSeconds:  300,
+	}
+
+	for _, test := range []struct {
+		name       string
+		status     crv1.AutoscaleStatus
+		replicas   int
+		autoscaled int
+		pending    bool
+		change     int
+	}{
+		{"steady", crv1.AutoscaleStatus{}, 2, 1, false, 0},
+		{"below minimum", crv1.AutoscaleStatus{}, 0, 0, false, 1},
+		{"pending", crv1.AutoscaleStatus{SaturatedSince: ago(time.Hour)}, 0, 0, true, 0},
+		{"saturated", crv1.AutoscaleStatus{SaturatedSince: ago(3 * time.Minute)}, 1, 0, false, 1},
+		{"briefly saturated", crv1.AutoscaleStatus{SaturatedSince: ago(time.Minute)}, 1, 0, false, 0},
+		{"cooling down", crv1.AutoscaleStatus{SaturatedSince: ago(3 * time.Minute),
+			LastScaleTime: ago(4 * time.Minute)}, 1, 0, false, 0},
+		{"at maximum", crv1.AutoscaleStatus{SaturatedSince: ago(time.Hour)}, 3, 2, false, 0},
+		{"underutilized", crv1.AutoscaleStatus{UnderutilizedSince: ago(3 * time.Minute)}, 2, 1, false, -1},
+		{"at minimum", crv1.AutoscaleStatus{UnderutilizedSince: ago(time.Hour)}, 1, 1, false, 0},
+		{"none autoscaled", crv1.AutoscaleStatus{UnderutilizedSince: ago(time.Hour)}, 2, 0, false, 0},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			change := getAutoscaleChange(autoscale, test.status, test.replicas, test.autoscaled,
+				test.pending, now)
+
+			if change != test.change {
+				t.Fatalf("expected change %d, got %d", test.change, change)
+			}
+		})
+	}
+}
+
+func TestAutoscaleThresholds(t *testing.T) {
+	for _, test := range []struct {
+		name      string
+		autoscale crv1.AutoscaleSpec
+		threshold int
+		scaleDown int
+	}{
+		{"defaults", crv1.AutoscaleSpec{}, 80, 40},
+		{"connections", crv1.AutoscaleSpec{ConnectionThreshold: 60, CPUThreshold: 90}, 60, 30},
+		{"cpu", crv1.AutoscaleSpec{Metric: crv1.AutoscaleMetricCPU, CPUThreshold: 90,
+			ScaleDownPercent: 20}, 90, 18},
+		{"whole threshold", crv1.AutoscaleSpec{ScaleDownPercent: 100}, 80, 80},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			if threshold := test.autoscale.GetThreshold(); threshold != test.threshold {
+				t.Errorf("expected threshold %d, got %d", test.threshold, threshold)
+			}
+			if scaleDown := test.autoscale.GetScaleDownThreshold(); scaleDown != test.scaleDown {
+				t.Errorf("expected scale down threshold %d, got %d", test.scaleDown, scaleDown)
+			}
+		})
+	}
+}
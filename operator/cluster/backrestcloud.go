@@ -0,0 +1,139 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"fmt"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/config"
+	"github.com/crunchydata/postgres-operator/kubeapi"
+	"github.com/crunchydata/postgres-operator/operator"
+	"github.com/crunchydata/postgres-operator/util"
+	log "github.com/sirupsen/logrus"
+	apps_v1 "k8s.io/api/apps/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// backrestCloudSecretKeys are the keys of the pgBackRest Secret of a cluster with the credentials
+// of each type of object storage
+var backrestCloudSecretKeys = map[string][]string{
+	"gcs":   {util.BackRestRepoSecretKeyGCSKey},
+	"azure": {util.BackRestRepoSecretKeyAzureAccount, util.BackRestRepoSecretKeyAzureKey},
+}
+
+// UpdateBackrestCloud applies the GCS or Azure settings of the pgBackRest repository of the
+// cluster to the pgBackRest configuration of its Deployments, i.e. those of the PostgreSQL
+// instances and of the pgBackRest repository. The settings are validated right away, and if they
+// are invalid a Warning event is recorded for the cluster and nothing is changed. Otherwise the
+// Deployments are updated within the restart budget, as this restarts the instances
+func UpdateBackrestCloud(clientset *kubernetes.Clientset, restConfig *rest.Config,
+	cluster *crv1.Pgcluster) error {
+	if err := ValidateBackrestCloud(clientset, cluster); err != nil {
+		return err
+	}
+
+	RestartWithinBudget(clientset, cluster, "pgBackRest GCS or Azure settings", func() error {
+		return updateBackrestCloudDeployments(clientset, restConfig, cluster)
+	})
+
+	return nil
+}
+
+// ValidateBackrestCloud validates the GCS or Azure settings of the cluster, as well as that the
+// pgBackRest Secret of the cluster has the credentials of the object storage, recording a Warning
+// event for the cluster if they are invalid
+func ValidateBackrestCloud(clientset *kubernetes.Clientset, cluster *crv1.Pgcluster) error {
+	err := operator.ValidateBackrestCloud(cluster)
+
+	// the credentials are not needed if the workload identity or the secret store of the cluster
+	// is used instead
+	if storageType := operator.GetBackrestCloudType(cluster); err == nil && storageType != "" &&
+		!operator.UsesBackrestWorkloadIdentity(cluster) && !operator.UsesBackrestSecretStore(cluster) {
+		err = validateBackrestCloudSecret(clientset, cluster, storageType)
+	}
+
+	if err != nil {
+		operator.RecordWarningEvent(clientset, cluster, operator.EventReasonInvalidBackrestCloud,
+			err.Error())
+		return err
+	}
+
+	return nil
+}
+
+// validateBackrestCloudSecret ensures that the pgBackRest Secret of the cluster has the
+// credentials of the type of object storage
+func validateBackrestCloudSecret(clientset *kubernetes.Clientset, cluster *crv1.Pgcluster,
+	storageType string) error {
+	name := fmt.Sprintf("%s-%s", cluster.Name, config.LABEL_BACKREST_REPO_SECRET)
+
+	secret, found, err := kubeapi.GetSecret(clientset, name, cluster.Namespace)
+	if !found {
+		return fmt.Errorf("pgBackRest secret %s not found", name)
+	} else if err != nil {
+		return err
+	}
+
+	for _, key := range backrestCloudSecretKeys[storageType] {
+		if len(secret.Data[key]) == 0 {
+			return fmt.Errorf("pgBackRest secret %s has no %q for storage type %q", name, key,
+				storageType)
+		}
+	}
+
+	return nil
+}
+
+// updateBackrestCloudDeployments sets the pgBackRest GCS or Azure environment variables of the
+// containers of the cluster that run pgBackRest against either
+func updateBackrestCloudDeployments(clientset *kubernetes.Clientset, restConfig *rest.Config,
+	cluster *crv1.Pgcluster) error {
+	envVars := operator.GetBackrestCloudEnvVars(cluster)
+
+	selector := fmt.Sprintf("%s=%s,%s=%s", config.LABEL_VENDOR, config.LABEL_CRUNCHY,
+		config.LABEL_PG_CLUSTER, cluster.Name)
+
+	deployments, err := kubeapi.GetDeployments(clientset, selector, cluster.Namespace)
+	if err != nil {
+		return err
+	}
+
+	return updateDeployments(clientset, restConfig, deployments.Items, func(deployment *apps_v1.Deployment) bool {
+		changed := false
+
+		// only the containers that run pgBackRest against GCS or Azure are updated
+		for i := range deployment.Spec.Template.Spec.Containers {
+			container := &deployment.Spec.Template.Spec.Containers[i]
+
+			if !hasEnvVar(container.Env, operator.BackrestCloudEnvType) {
+				continue
+			}
+
+			if env, ok := setEnvVars(container.Env, envVars); ok {
+				container.Env = env
+				changed = true
+			}
+		}
+
+		if changed {
+			log.Debugf("updating pgBackRest GCS or Azure settings of deployment %s", deployment.Name)
+		}
+
+		return changed
+	})
+}
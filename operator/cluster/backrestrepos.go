@@ -0,0 +1,388 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"time"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/config"
+	"github.com/crunchydata/postgres-operator/kubeapi"
+	"github.com/crunchydata/postgres-operator/operator"
+	"github.com/crunchydata/postgres-operator/operator/backrest"
+	log "github.com/sirupsen/logrus"
+	apps_v1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	// how long to wait, in seconds, for the pgBackRest repository to roll out with the
+	// configuration of the additional repositories
+	backrestReposRolloutTimeout = 300
+	backrestReposRolloutPeriod  = 5
+
+	// backrestRepoScheduleFormat is the name of the pgschedule of an additional repository
+	backrestRepoScheduleFormat = "%s-repo%d"
+)
+
+// UpdateBackrestRepos applies the additional pgBackRest repositories of the cluster: the Secret
+// with their pgBackRest configuration and the pgschedules of their backups are reconciled, and the
+// Secret is mounted in the Deployments of the cluster that do not have it yet. If a repository was
+// added, the stanza is created in it once the pgBackRest repository has rolled out with the new
+// configuration. The repositories are validated right away, and if they are invalid a Warning
+// event is recorded for the cluster and nothing is changed. As mounting the Secret restarts the
+// instances, the Deployments are updated within the restart budget
+func UpdateBackrestRepos(clientset *kubernetes.Clientset, restclient *rest.RESTClient,
+	restConfig *rest.Config, oldRepos []crv1.BackrestRepoSpec, cluster *crv1.Pgcluster) error {
+	if err := ValidateBackrestRepos(clientset, cluster); err != nil {
+		return err
+	}
+
+	checksum, err := ReconcileBackrestReposSecret(clientset, cluster)
+	if err != nil {
+		operator.RecordWarningEvent(clientset, cluster, operator.EventReasonInvalidBackrestRepos,
+			err.Error())
+		return err
+	}
+
+	if err := ReconcileBackrestRepoSchedules(restclient, cluster); err != nil {
+		return err
+	}
+
+	added := hasNewBackrestRepo(oldRepos, cluster.Spec.BackrestRepos)
+
+	RestartWithinBudget(clientset, cluster, "pgBackRest repositories", func() error {
+		rolled, err := updateBackrestReposDeployments(clientset, restConfig, cluster, checksum)
+		if err != nil {
+			return err
+		}
+
+		if !added {
+			return nil
+		}
+
+		if rolled {
+			if err := waitForBackrestRepoRollout(clientset, cluster); err != nil {
+				return err
+			}
+		}
+
+		return backrest.CreateReposStanza(clientset, restclient, cluster)
+	})
+
+	return nil
+}
+
+// ValidateBackrestRepos validates the additional pgBackRest repositories of the cluster,
+// recording a Warning event for the cluster if they are invalid
+func ValidateBackrestRepos(clientset *kubernetes.Clientset, cluster *crv1.Pgcluster) error {
+	if err := operator.ValidateBackrestRepos(cluster); err != nil {
+		operator.RecordWarningEvent(clientset, cluster, operator.EventReasonInvalidBackrestRepos,
+			err.Error())
+		return err
+	}
+
+	return nil
+}
+
+// ReconcileBackrestReposSecret creates or updates the Secret with the pgBackRest configuration of
+// the additional repositories of the cluster, including their credentials, which are read from
+// the Secret of each repository. If the cluster has no additional repositories, the Secret is
+// removed. The checksum of the contents of the Secret is returned, so that the pgBackRest
+// repository can be rolled out when they change
+func ReconcileBackrestReposSecret(clientset *kubernetes.Clientset, cluster *crv1.Pgcluster) (string, error) {
+	name := fmt.Sprintf(operator.BackrestReposSecretFormat, cluster.Name)
+
+	secret, found, err := kubeapi.GetSecret(clientset, name, cluster.Namespace)
+	if err != nil && !kerrors.IsNotFound(err) {
+		return "", err
+	}
+
+	if len(cluster.Spec.BackrestRepos) == 0 {
+		if found {
+			log.Debugf("removing the secret %s of the pgBackRest repositories of cluster %s",
+				name, cluster.Name)
+			return "", kubeapi.DeleteSecret(clientset, name, cluster.Namespace)
+		}
+		return "", nil
+	}
+
+	credentials := map[int]map[string][]byte{}
+	data := map[string][]byte{}
+
+	for _, repo := range cluster.Spec.BackrestRepos {
+		repoSecret, repoFound, err := kubeapi.GetSecret(clientset, repo.SecretName, cluster.Namespace)
+		if !repoFound {
+			return "", fmt.Errorf("secret %s of pgBackRest repository %d not found",
+				repo.SecretName, repo.Index)
+		} else if err != nil {
+			return "", err
+		}
+
+		for _, key := range operator.BackrestRepoSecretKeys(repo) {
+			if len(repoSecret.Data[key]) == 0 {
+				return "", fmt.Errorf("secret %s of pgBackRest repository %d has no %q",
+					repo.SecretName, repo.Index, key)
+			}
+		}
+
+		credentials[repo.Index] = repoSecret.Data
+
+		// the service account key of GCS is read from a file rather than the configuration
+		if repo.Type == "gcs" {
+			data[operator.BackrestRepoGCSKeyName(repo.Index)] = repoSecret.Data[operator.BackrestRepoSecretKey]
+		}
+	}
+
+	data[operator.BackrestReposConfKey] = []byte(operator.BackrestReposConf(cluster, credentials))
+	checksum := backrestReposChecksum(data)
+
+	if found {
+		if reflect.DeepEqual(secret.Data, data) {
+			return checksum, nil
+		}
+
+		secret.Data = data
+		return checksum, kubeapi.UpdateSecret(clientset, secret, cluster.Namespace)
+	}
+
+	secret = &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+			Labels: map[string]string{
+				config.LABEL_PG_CLUSTER: cluster.Name,
+				config.LABEL_VENDOR:     config.LABEL_CRUNCHY,
+			},
+		},
+		Data: data,
+	}
+
+	return checksum, kubeapi.CreateSecret(clientset, secret, cluster.Namespace)
+}
+
+// ReconcileBackrestRepoSchedules creates, updates or removes the pgschedule of each additional
+// pgBackRest repository of the cluster, so that every repository with a schedule is backed up on
+// it. Whether a pgschedule is suspended is left as it is, so that the backups to a repository
+// can be suspended without changing the cluster
+func ReconcileBackrestRepoSchedules(restclient *rest.RESTClient, cluster *crv1.Pgcluster) error {
+	selector := fmt.Sprintf("%s=%s,%s", config.LABEL_PG_CLUSTER, cluster.Name,
+		config.LABEL_PGBACKREST_REPO_INDEX)
+
+	schedules := crv1.PgscheduleList{}
+	if err := kubeapi.GetpgschedulesBySelector(restclient, &schedules, selector,
+		cluster.Namespace); err != nil {
+		return err
+	}
+
+	existing := map[string]crv1.Pgschedule{}
+	for _, schedule := range schedules.Items {
+		existing[schedule.Name] = schedule
+	}
+
+	for _, repo := range cluster.Spec.BackrestRepos {
+		if repo.Schedule == "" {
+			continue
+		}
+
+		name := fmt.Sprintf(backrestRepoScheduleFormat, cluster.Name, repo.Index)
+		spec := newBackrestRepoScheduleSpec(cluster, repo)
+
+		schedule, ok := existing[name]
+		delete(existing, name)
+
+		if !ok {
+			log.Debugf("creating pgschedule %s of pgBackRest repository %d", name, repo.Index)
+
+			if err := kubeapi.Createpgschedule(restclient, &crv1.Pgschedule{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: name,
+					Labels: map[string]string{
+						config.LABEL_PG_CLUSTER:            cluster.Name,
+						config.LABEL_PGBACKREST_REPO_INDEX: strconv.Itoa(repo.Index),
+					},
+				},
+				Spec: spec,
+			}, cluster.Namespace); err != nil {
+				return err
+			}
+			continue
+		}
+
+		spec.Suspend = schedule.Spec.Suspend
+		if reflect.DeepEqual(schedule.Spec, spec) {
+			continue
+		}
+
+		log.Debugf("updating pgschedule %s of pgBackRest repository %d", name, repo.Index)
+
+		schedule.Spec = spec
+		if err := kubeapi.Updatepgschedule(restclient, &schedule, name, cluster.Namespace); err != nil {
+			return err
+		}
+	}
+
+	// whatever is left over is the schedule of a repository that was removed or is no longer
+	// backed up on a schedule
+	for name := range existing {
+		log.Debugf("removing pgschedule %s of cluster %s", name, cluster.Name)
+
+		if err := kubeapi.Deletepgschedule(restclient, name, cluster.Namespace); err != nil &&
+			!kerrors.IsNotFound(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// newBackrestRepoScheduleSpec returns the pgschedule of the backups of an additional repository.
+// The retention is left to the pgBackRest configuration of the repository
+func newBackrestRepoScheduleSpec(cluster *crv1.Pgcluster, repo crv1.BackrestRepoSpec) crv1.PgscheduleSpec {
+	backupType := repo.BackupType
+	if backupType == "" {
+		backupType = "full"
+	}
+
+	return crv1.PgscheduleSpec{
+		ClusterName: cluster.Name,
+		Schedule:    repo.Schedule,
+		BackupType:  backupType,
+		Options:     fmt.Sprintf("--repo=%d", repo.Index),
+	}
+}
+
+// updateBackrestReposDeployments mounts the Secret of the additional repositories in the
+// Deployments of the cluster that do not have it yet, and has the pgBackRest repository roll out
+// when the contents of the Secret change. It returns whether the pgBackRest repository rolled out
+func updateBackrestReposDeployments(clientset *kubernetes.Clientset, restConfig *rest.Config,
+	cluster *crv1.Pgcluster, checksum string) (bool, error) {
+	selector := fmt.Sprintf("%s=%s,%s=%s", config.LABEL_VENDOR, config.LABEL_CRUNCHY,
+		config.LABEL_PG_CLUSTER, cluster.Name)
+
+	deployments, err := kubeapi.GetDeployments(clientset, selector, cluster.Namespace)
+	if err != nil {
+		return false, err
+	}
+
+	rolled := false
+
+	err = updateDeployments(clientset, restConfig, deployments.Items, func(deployment *apps_v1.Deployment) bool {
+		_, instance := deployment.ObjectMeta.Labels[config.LABEL_DEPLOYMENT_NAME]
+		repo := deployment.ObjectMeta.Labels[config.LABEL_PGO_BACKREST_REPO] == "true"
+
+		if !instance && !repo {
+			return false
+		}
+
+		changed := operator.AddBackrestReposVolume(cluster, &deployment.Spec.Template.Spec)
+
+		// the instances pick up changes of the Secret once it is synced to their pods, while
+		// the pgBackRest repository is restarted so that the stanza can be created right away
+		if repo && checksum != "" &&
+			deployment.Spec.Template.Annotations[config.ANNOTATION_BACKREST_REPOS_CHECKSUM] != checksum {
+			if deployment.Spec.Template.Annotations == nil {
+				deployment.Spec.Template.Annotations = map[string]string{}
+			}
+			deployment.Spec.Template.Annotations[config.ANNOTATION_BACKREST_REPOS_CHECKSUM] = checksum
+			changed = true
+		}
+
+		if !changed {
+			return false
+		}
+
+		log.Debugf("updating pgBackRest repositories of deployment %s", deployment.Name)
+
+		rolled = rolled || repo
+
+		return true
+	})
+
+	return rolled && err == nil, err
+}
+
+// waitForBackrestRepoRollout waits for the pod of the pgBackRest repository of the cluster to be
+// updated to the latest template of its Deployment and to be ready
+func waitForBackrestRepoRollout(clientset *kubernetes.Clientset, cluster *crv1.Pgcluster) error {
+	name := fmt.Sprintf(backrest.BackrestRepoServiceName, cluster.Name)
+	timeout := time.After(backrestReposRolloutTimeout * time.Second)
+	tick := time.Tick(backrestReposRolloutPeriod * time.Second)
+
+	for {
+		select {
+		case <-timeout:
+			return fmt.Errorf("timed out waiting for pgbackrest repository %s to roll out", name)
+		case <-tick:
+			deployment, found, err := kubeapi.GetDeployment(clientset, name, cluster.Namespace)
+			if !found {
+				log.Error(err)
+				continue
+			}
+
+			if deployment.Status.ObservedGeneration >= deployment.Generation &&
+				deployment.Status.UpdatedReplicas == deployment.Status.Replicas &&
+				deployment.Status.ReadyReplicas == deployment.Status.Replicas &&
+				deployment.Status.Replicas > 0 {
+				return nil
+			}
+		}
+	}
+}
+
+// hasNewBackrestRepo determines whether any of the repositories is new, i.e. it was not there
+// before or it is now stored elsewhere, so that the stanza has to be created in it
+func hasNewBackrestRepo(oldRepos, newRepos []crv1.BackrestRepoSpec) bool {
+	old := map[int]crv1.BackrestRepoSpec{}
+	for _, repo := range oldRepos {
+		old[repo.Index] = repo
+	}
+
+	for _, repo := range newRepos {
+		previous, ok := old[repo.Index]
+		if !ok || previous.Type != repo.Type || previous.Bucket != repo.Bucket ||
+			previous.Endpoint != repo.Endpoint || previous.Path != repo.Path {
+			return true
+		}
+	}
+
+	return false
+}
+
+// backrestReposChecksum returns the checksum of the contents of a Secret
+func backrestReposChecksum(data map[string][]byte) string {
+	keys := []string{}
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	hash := sha256.New()
+	for _, key := range keys {
+		hash.Write([]byte(key))
+		hash.Write(data[key])
+	}
+
+	return fmt.Sprintf("%x", hash.Sum(nil))
+}
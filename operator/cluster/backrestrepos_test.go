@@ -0,0 +1,59 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"testing"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+)
+
+func TestHasNewBackrestRepo(t *testing.T) {
+	s3 := crv1.BackrestRepoSpec{Index: 2, Type: "s3", Bucket: "backups", SecretName: "s3-creds"}
+	moved := s3
+	moved.Bucket = "elsewhere"
+	rescheduled := s3
+	rescheduled.Schedule = "0 1 * * *"
+	gcs := crv1.BackrestRepoSpec{Index: 3, Type: "gcs", Bucket: "backups", SecretName: "gcs-creds"}
+
+	for _, test := range []struct {
+		description string
+		old, new    []crv1.BackrestRepoSpec
+		expected    bool
+	}{
+		{"unchanged", []crv1.BackrestRepoSpec{s3}, []crv1.BackrestRepoSpec{s3}, false},
+		{"added", []crv1.BackrestRepoSpec{s3}, []crv1.BackrestRepoSpec{s3, gcs}, true},
+		{"removed", []crv1.BackrestRepoSpec{s3, gcs}, []crv1.BackrestRepoSpec{gcs}, false},
+		{"moved", []crv1.BackrestRepoSpec{s3}, []crv1.BackrestRepoSpec{moved}, true},
+		{"rescheduled", []crv1.BackrestRepoSpec{s3}, []crv1.BackrestRepoSpec{rescheduled}, false},
+	} {
+		if actual := hasNewBackrestRepo(test.old, test.new); actual != test.expected {
+			t.Errorf("%s: expected %v, got %v", test.description, test.expected, actual)
+		}
+	}
+}
+
+func TestNewBackrestRepoScheduleSpec(t *testing.T) {
+	cluster := &crv1.Pgcluster{}
+	cluster.Name = "hippo"
+
+	spec := newBackrestRepoScheduleSpec(cluster, crv1.BackrestRepoSpec{Index: 3, Schedule: "0 1 * * *"})
+
+	if spec.ClusterName != "hippo" || spec.Schedule != "0 1 * * *" || spec.BackupType != "full" ||
+		spec.Options != "--repo=3" || spec.Retention != 0 {
+		t.Errorf("unexpected schedule %+v", spec)
+	}
+}
@@ -0,0 +1,157 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"fmt"
+	"sort"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/config"
+	"github.com/crunchydata/postgres-operator/kubeapi"
+	"github.com/crunchydata/postgres-operator/operator"
+	log "github.com/sirupsen/logrus"
+	apps_v1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// UpdateBackrestS3 applies the endpoint, region and transfer tuning of the S3 repository of the
+// cluster to the pgBackRest configuration of its Deployments, i.e. those of the PostgreSQL
+// instances and of the pgBackRest repository. The settings are validated right away, and if they
+// are invalid a Warning event is recorded for the cluster and nothing is changed. Otherwise the
+// Deployments are updated within the restart budget, as this restarts the instances
+func UpdateBackrestS3(clientset *kubernetes.Clientset, restConfig *rest.Config,
+	cluster *crv1.Pgcluster) error {
+	if err := ValidateBackrestS3(clientset, cluster); err != nil {
+		return err
+	}
+
+	RestartWithinBudget(clientset, cluster, "pgBackRest S3 settings", func() error {
+		return updateBackrestS3Deployments(clientset, restConfig, cluster)
+	})
+
+	return nil
+}
+
+// ValidateBackrestS3 validates the S3 settings of the cluster, recording a Warning event for the
+// cluster if they are invalid
+func ValidateBackrestS3(clientset *kubernetes.Clientset, cluster *crv1.Pgcluster) error {
+	if err := operator.ValidateBackrestS3(cluster); err != nil {
+		operator.RecordWarningEvent(clientset, cluster, operator.EventReasonInvalidBackrestS3,
+			err.Error())
+		return err
+	}
+
+	return nil
+}
+
+// updateBackrestS3Deployments sets the pgBackRest S3 environment variables of the containers of
+// the cluster that run pgBackRest against S3
+func updateBackrestS3Deployments(clientset *kubernetes.Clientset, restConfig *rest.Config,
+	cluster *crv1.Pgcluster) error {
+	envVars := operator.GetBackrestS3EnvVars(cluster)
+
+	selector := fmt.Sprintf("%s=%s,%s=%s", config.LABEL_VENDOR, config.LABEL_CRUNCHY,
+		config.LABEL_PG_CLUSTER, cluster.Name)
+
+	deployments, err := kubeapi.GetDeployments(clientset, selector, cluster.Namespace)
+	if err != nil {
+		return err
+	}
+
+	return updateDeployments(clientset, restConfig, deployments.Items, func(deployment *apps_v1.Deployment) bool {
+		changed := false
+
+		// only the containers that run pgBackRest against S3 are updated
+		for i := range deployment.Spec.Template.Spec.Containers {
+			container := &deployment.Spec.Template.Spec.Containers[i]
+
+			if !hasEnvVar(container.Env, operator.BackrestS3EnvBucket) {
+				continue
+			}
+
+			if env, ok := setEnvVars(container.Env, envVars); ok {
+				container.Env = env
+				changed = true
+			}
+		}
+
+		if changed {
+			log.Debugf("updating pgBackRest S3 settings of deployment %s", deployment.Name)
+		}
+
+		return changed
+	})
+}
+
+// hasEnvVar determines whether or not the environment variable is set
+func hasEnvVar(env []v1.EnvVar, name string) bool {
+	for _, envVar := range env {
+		if envVar.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// setEnvVars sets the values of the environment variables, adding those that are missing and
+// removing those whose value is empty. The updated environment variables are returned, along with
+// whether or not any of them changed
+func setEnvVars(env []v1.EnvVar, values map[string]string) ([]v1.EnvVar, bool) {
+	updated := []v1.EnvVar{}
+	changed := false
+	found := map[string]bool{}
+
+	for _, envVar := range env {
+		value, ok := values[envVar.Name]
+		if !ok {
+			updated = append(updated, envVar)
+			continue
+		}
+
+		found[envVar.Name] = true
+
+		if value == "" {
+			changed = true
+			continue
+		}
+
+		if envVar.Value != value || envVar.ValueFrom != nil {
+			changed = true
+		}
+
+		updated = append(updated, v1.EnvVar{Name: envVar.Name, Value: value})
+	}
+
+	// add the missing variables in a stable order, so that every container ends up the same
+	names := []string{}
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if !found[name] && values[name] != "" {
+			updated = append(updated, v1.EnvVar{Name: name, Value: values[name]})
+			changed = true
+		}
+	}
+
+	return updated, changed
+}
@@ -0,0 +1,236 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/config"
+	"github.com/crunchydata/postgres-operator/kubeapi"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	// patroniConfigFile is the configuration of Patroni that the container generates each time
+	// it starts, which holds the tags of the instance
+	patroniConfigFile = "/tmp/postgres-ha-bootstrap.yaml"
+
+	// patroniReplicateFromScript sets the "replicatefrom" tag in the configuration of Patroni to
+	// the member that is passed, or removes it if none is, and prints "changed" if it did either
+	patroniReplicateFromScript = `import sys, yaml
+path, upstream = sys.argv[1], sys.argv[2]
+with open(path) as f:
+    conf = yaml.safe_load(f)
+tags = conf.get('tags') or {}
+if tags.get('replicatefrom', '') == upstream:
+    sys.exit(0)
+if upstream:
+    tags['replicatefrom'] = upstream
+else:
+    tags.pop('replicatefrom', None)
+conf['tags'] = tags
+with open(path, 'w') as f:
+    yaml.safe_dump(conf, f, default_flow_style=False)
+print('changed')`
+)
+
+// ReconcileReplicaUpstream has a replica stream from its upstream, another instance of the same
+// cluster, rather than from the primary. Patroni does this for a replica that is tagged with the
+// member to replicate from, which is the Pod of the upstream, so the tag is set on the replica
+// and updated whenever the upstream gets a new Pod. While the upstream is not available, e.g.
+// because it was removed or promoted, the replica streams from the nearest upstream of its
+// upstream that is, or else from the primary. An upstream that loops back on the replica is
+// refused, and the replica streams from the primary until it is fixed. The upstream that the
+// replica streams from is recorded in its status
+func ReconcileReplicaUpstream(clientset *kubernetes.Clientset, restclient *rest.RESTClient,
+	restconfig *rest.Config, replica *crv1.Pgreplica) error {
+	replicas := crv1.PgreplicaList{}
+	selector := fmt.Sprintf("%s=%s", config.LABEL_PG_CLUSTER, replica.Spec.ClusterName)
+
+	if err := kubeapi.GetpgreplicasBySelector(restclient, &replicas, selector,
+		replica.Namespace); err != nil {
+		return err
+	}
+
+	upstreams := map[string]string{}
+	for _, item := range replicas.Items {
+		if item.DeletionTimestamp == nil {
+			upstreams[item.Spec.Name] = item.Spec.Upstream
+		}
+	}
+
+	selector = fmt.Sprintf("%s=%s,%s=%s", config.LABEL_PG_CLUSTER, replica.Spec.ClusterName,
+		config.LABEL_PGHA_ROLE, config.LABEL_PGHA_ROLE_REPLICA)
+
+	pods, err := kubeapi.GetPods(clientset, selector, replica.Namespace)
+	if err != nil {
+		return err
+	}
+
+	// the replicas that can be streamed from are the ones that are ready
+	available := map[string]*v1.Pod{}
+	for i := range pods.Items {
+		if isReplicaPodReady(&pods.Items[i]) {
+			available[pods.Items[i].Labels[config.LABEL_DEPLOYMENT_NAME]] = &pods.Items[i]
+		}
+	}
+
+	upstream, topologyErr := getReplicaUpstream(replica.Spec.Name, upstreams, available)
+
+	// the upstream is set once the replica is ready
+	pod, ok := available[replica.Spec.Name]
+	if !ok {
+		log.Debugf("cascading replica: replica %s is not ready", replica.Spec.Name)
+		return topologyErr
+	}
+
+	member := ""
+	if upstream != "" {
+		member = available[upstream].Name
+	}
+
+	if err := setReplicateFrom(clientset, restconfig, pod, member); err != nil {
+		return err
+	}
+
+	if upstream != replica.Status.Upstream {
+		log.Infof("cascading replica: replica %s streams from %q", replica.Spec.Name, upstream)
+
+		if err := kubeapi.PatchpgreplicaUpstreamStatus(restclient, upstream, replica,
+			replica.Namespace); err != nil {
+			return err
+		}
+	}
+
+	return topologyErr
+}
+
+// ReconcileDownstreamReplicas reconciles the upstream of the replicas of a cluster that stream
+// from the given replica, e.g. once it is removed, so that they stream from elsewhere right away
+func ReconcileDownstreamReplicas(clientset *kubernetes.Clientset, restclient *rest.RESTClient,
+	restconfig *rest.Config, clusterName, instance, namespace string) error {
+	replicas := crv1.PgreplicaList{}
+	selector := fmt.Sprintf("%s=%s", config.LABEL_PG_CLUSTER, clusterName)
+
+	if err := kubeapi.GetpgreplicasBySelector(restclient, &replicas, selector, namespace); err != nil {
+		return err
+	}
+
+	for i := range replicas.Items {
+		replica := &replicas.Items[i]
+
+		if replica.Spec.Name == instance || replica.DeletionTimestamp != nil ||
+			(replica.Spec.Upstream != instance && replica.Status.Upstream != instance) {
+			continue
+		}
+
+		if err := ReconcileReplicaUpstream(clientset, restclient, restconfig, replica); err != nil {
+			log.Errorf("cascading replica: could not rewire replica %s: %s", replica.Spec.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// getCascadingReplicas returns the names of the replicas of a cluster that stream from another
+// replica rather than from the primary
+func getCascadingReplicas(restclient *rest.RESTClient, clusterName,
+	namespace string) ([]string, error) {
+	replicas := crv1.PgreplicaList{}
+	selector := fmt.Sprintf("%s=%s", config.LABEL_PG_CLUSTER, clusterName)
+
+	if err := kubeapi.GetpgreplicasBySelector(restclient, &replicas, selector, namespace); err != nil {
+		return nil, err
+	}
+
+	cascading := []string{}
+	for _, replica := range replicas.Items {
+		if replica.Status.Upstream != "" {
+			cascading = append(cascading, replica.Spec.Name)
+		}
+	}
+
+	sort.Strings(cascading)
+
+	return cascading, nil
+}
+
+// getReplicaUpstream returns the replica that a replica streams from, which is its upstream, or,
+// while that is not available, the nearest upstream of its upstream that is. An empty name is
+// the primary. An error is returned if the upstreams of the replica loop back on themselves,
+// whether or not they are available
+func getReplicaUpstream(name string, upstreams map[string]string,
+	available map[string]*v1.Pod) (string, error) {
+	seen := map[string]bool{name: true}
+	path := []string{name}
+
+	for upstream := upstreams[name]; upstream != ""; upstream = upstreams[upstream] {
+		path = append(path, upstream)
+
+		if seen[upstream] {
+			return "", fmt.Errorf("invalid upstream of replica %s, the upstreams form a loop: %s",
+				name, strings.Join(path, " -> "))
+		}
+
+		seen[upstream] = true
+	}
+
+	for _, upstream := range path[1:] {
+		if _, ok := available[upstream]; ok {
+			return upstream, nil
+		}
+	}
+
+	return "", nil
+}
+
+// setReplicateFrom tags the instance of a Pod with the Patroni member to stream from, or removes
+// the tag if there is none, and has Patroni reload its configuration to apply it. The
+// configuration is generated anew when the container restarts, after which the tag is set again
+func setReplicateFrom(clientset *kubernetes.Clientset, restconfig *rest.Config, pod *v1.Pod,
+	member string) error {
+	command := []string{"python3", "-c", patroniReplicateFromScript, patroniConfigFile, member}
+
+	stdout, stderr, err := kubeapi.ExecToPodThroughAPI(restconfig, clientset, command,
+		"database", pod.Name, pod.Namespace, nil)
+	if err != nil {
+		log.Errorf("cascading replica: could not tag %s: %s", pod.Name, stderr)
+		return err
+	}
+
+	if strings.TrimSpace(stdout) != "changed" {
+		return nil
+	}
+
+	log.Debugf("cascading replica: %s replicates from %q, reloading", pod.Name, member)
+
+	command = []string{"curl", "-s", "-XPOST",
+		fmt.Sprintf("http://127.0.0.1:%s/reload", config.DEFAULT_PATRONI_PORT)}
+
+	_, stderr, err = kubeapi.ExecToPodThroughAPI(restconfig, clientset, command,
+		"database", pod.Name, pod.Namespace, nil)
+	if err != nil {
+		log.Errorf("cascading replica: could not reload %s: %s", pod.Name, stderr)
+	}
+
+	return err
+}
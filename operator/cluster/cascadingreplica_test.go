@@ -0,0 +1,70 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestGetReplicaUpstream(t *testing.T) {
+	upstreams := map[string]string{
+		"hippo-abcd": "",
+		"hippo-efgh": "hippo-abcd",
+		"hippo-ijkl": "hippo-efgh",
+		"hippo-mnop": "hippo-gone",
+		"hippo-qrst": "hippo-uvwx",
+		"hippo-uvwx": "hippo-qrst",
+		"hippo-self": "hippo-self",
+	}
+
+	all := map[string]*v1.Pod{
+		"hippo-abcd": {}, "hippo-efgh": {}, "hippo-ijkl": {},
+		"hippo-qrst": {}, "hippo-uvwx": {}, "hippo-self": {},
+	}
+
+	for _, test := range []struct {
+		name      string
+		replica   string
+		available map[string]*v1.Pod
+		expected  string
+		valid     bool
+	}{
+		{"primary", "hippo-abcd", all, "", true},
+		{"upstream", "hippo-ijkl", all, "hippo-efgh", true},
+		{"upstream not available", "hippo-ijkl", map[string]*v1.Pod{"hippo-abcd": {}},
+			"hippo-abcd", true},
+		{"no upstream available", "hippo-ijkl", map[string]*v1.Pod{}, "", true},
+		{"upstream removed", "hippo-mnop", all, "", true},
+		{"cycle", "hippo-qrst", all, "", false},
+		{"itself", "hippo-self", all, "", false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			upstream, err := getReplicaUpstream(test.replica, upstreams, test.available)
+
+			if test.valid && err != nil {
+				t.Fatalf("expected a valid upstream, got %s", err)
+			}
+			if !test.valid && err == nil {
+				t.Fatal("expected an invalid upstream")
+			}
+			if upstream != test.expected {
+				t.Fatalf("expected %q, got %q", test.expected, upstream)
+			}
+		})
+	}
+}
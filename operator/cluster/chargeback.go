@@ -0,0 +1,128 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"fmt"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/config"
+	"github.com/crunchydata/postgres-operator/kubeapi"
+	"github.com/crunchydata/postgres-operator/operator"
+	log "github.com/sirupsen/logrus"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ReconcileChargebackLabels copies the chargeback labels of the Namespace of a cluster onto the
+// resources of the cluster, i.e. those that are labeled with the name of the cluster. This is
+// run periodically, so that a change to the labels of the Namespace is picked up. The Pods of a
+// Deployment are labeled directly rather than through the template of the Deployment, as changing
+// the template would restart them
+func ReconcileChargebackLabels(clientset *kubernetes.Clientset, cluster *crv1.Pgcluster) error {
+	if len(operator.Pgo.Pgo.ChargebackLabels) == 0 {
+		return nil
+	}
+
+	chargeback, err := operator.GetChargebackLabels(clientset, cluster.Namespace)
+	if err != nil {
+		return err
+	}
+
+	selector := fmt.Sprintf("%s=%s", config.LABEL_PG_CLUSTER, cluster.Name)
+
+	deployments, err := kubeapi.GetDeployments(clientset, selector, cluster.Namespace)
+	if err != nil {
+		return err
+	}
+
+	for i := range deployments.Items {
+		if operator.SetChargebackLabels(&deployments.Items[i].ObjectMeta, chargeback) {
+			log.Debugf("chargeback: labeling deployment %s", deployments.Items[i].Name)
+			_ = kubeapi.UpdateDeployment(clientset, &deployments.Items[i])
+		}
+	}
+
+	pods, err := kubeapi.GetPods(clientset, selector, cluster.Namespace)
+	if err != nil {
+		return err
+	}
+
+	for i := range pods.Items {
+		if operator.SetChargebackLabels(&pods.Items[i].ObjectMeta, chargeback) {
+			log.Debugf("chargeback: labeling pod %s", pods.Items[i].Name)
+			_ = kubeapi.UpdatePod(clientset, &pods.Items[i], cluster.Namespace)
+		}
+	}
+
+	pvcs, err := kubeapi.GetPVCs(clientset, selector, cluster.Namespace)
+	if err != nil {
+		return err
+	}
+
+	for i := range pvcs.Items {
+		if operator.SetChargebackLabels(&pvcs.Items[i].ObjectMeta, chargeback) {
+			log.Debugf("chargeback: labeling pvc %s", pvcs.Items[i].Name)
+			_ = kubeapi.UpdatePVC(clientset, &pvcs.Items[i], cluster.Namespace)
+		}
+	}
+
+	services, err := kubeapi.GetServices(clientset, selector, cluster.Namespace)
+	if err != nil {
+		return err
+	}
+
+	for i := range services.Items {
+		if operator.SetChargebackLabels(&services.Items[i].ObjectMeta, chargeback) {
+			log.Debugf("chargeback: labeling service %s", services.Items[i].Name)
+			_ = kubeapi.UpdateService(clientset, &services.Items[i], cluster.Namespace)
+		}
+	}
+
+	secrets, err := kubeapi.GetSecrets(clientset, selector, cluster.Namespace)
+	if err != nil {
+		return err
+	}
+
+	for i := range secrets.Items {
+		if operator.SetChargebackLabels(&secrets.Items[i].ObjectMeta, chargeback) {
+			log.Debugf("chargeback: labeling secret %s", secrets.Items[i].Name)
+			_ = kubeapi.UpdateSecret(clientset, &secrets.Items[i], cluster.Namespace)
+		}
+	}
+
+	jobs, err := kubeapi.GetJobs(clientset, selector, cluster.Namespace)
+	if err != nil {
+		return err
+	}
+
+	for i := range jobs.Items {
+		if operator.SetChargebackLabels(&jobs.Items[i].ObjectMeta, chargeback) {
+			log.Debugf("chargeback: labeling job %s", jobs.Items[i].Name)
+			_ = kubeapi.UpdateJob(clientset, &jobs.Items[i], cluster.Namespace)
+		}
+	}
+
+	if configMaps, found := kubeapi.ListConfigMap(clientset, selector, cluster.Namespace); found {
+		for i := range configMaps.Items {
+			if operator.SetChargebackLabels(&configMaps.Items[i].ObjectMeta, chargeback) {
+				log.Debugf("chargeback: labeling configmap %s", configMaps.Items[i].Name)
+				_ = kubeapi.UpdateConfigMap(clientset, &configMaps.Items[i], cluster.Namespace)
+			}
+		}
+	}
+
+	return nil
+}
@@ -234,17 +234,21 @@ func cloneStep1(clientset *kubernetes.Clientset, client *rest.RESTClient, namesp
 		return
 	}
 
-	// Ensure that there does *not* already exist a Pgcluster for the target
-	if found := checkTargetPgCluster(client, namespace, targetClusterName); found {
-		log.Errorf("[%s] already exists", targetClusterName)
-		errorMessage := fmt.Sprintf("Not cloning the cluster: %s already exists", targetClusterName)
-		PublishCloneEvent(events.EventCloneClusterFailure, namespace, task, errorMessage)
-		return
+	// Ensure that there does *not* already exist a Pgcluster for the target, unless it is the
+	// Pgcluster that is cloned by its clone spec
+	if _, isTarget := getCloneTarget(client, namespace, sourceClusterName, targetClusterName); !isTarget {
+		if found := checkTargetPgCluster(client, namespace, targetClusterName); found {
+			log.Errorf("[%s] already exists", targetClusterName)
+			errorMessage := fmt.Sprintf("Not cloning the cluster: %s already exists", targetClusterName)
+			PublishCloneEvent(events.EventCloneClusterFailure, namespace, task, errorMessage)
+			return
+		}
 	}
 
 	// first, create the PVC for the pgBackRest storage, as we will be needing
 	// that sooner
-	createPVCs(clientset, client, task, namespace, sourcePgcluster, targetClusterName)
+	createPVCs(clientset, client, task, namespace, sourcePgcluster, targetClusterName,
+		getCloneWALStorage(client, namespace, sourcePgcluster, targetClusterName))
 
 	log.Debug("clone step 1: created pvcs")
 
@@ -355,26 +359,49 @@ func cloneStep2(clientset *kubernetes.Clientset, client *rest.RESTClient, namesp
 		return
 	}
 
+	// a Pgcluster that is cloned by its clone spec may be restored to a point in time, and knows
+	// where the repository of its source is stored
+	commandOpts, pitrTarget := backrest.CloneRestoreOptions(crv1.CloneSpec{})
+	repoType := task.Spec.Parameters["backrestStorageType"]
+	if target, isTarget := getCloneTarget(client, namespace, sourceClusterName, targetClusterName); isTarget {
+		commandOpts, pitrTarget = backrest.CloneRestoreOptions(target.Spec.Clone)
+		repoType = target.Spec.Clone.BackrestStorageSource
+	}
+
+	// the backups of a source that keeps its WAL on a volume of its own have pg_wal as a link,
+	// which is restored to the WAL volume of the target
+	commandOpts = backrest.AddWALRestoreOptions(commandOpts, &sourcePgcluster, targetClusterName)
+
 	backrestRestoreJobFields := backrest.BackrestRestoreJobTemplateFields{
 		JobName:     fmt.Sprintf("restore-%s-%s", targetClusterName, util.RandStringBytesRmndr(4)),
 		ClusterName: targetClusterName,
 		SecurityContext: util.GetPodSecurityContext(
 			sourcePgcluster.Spec.PrimaryStorage.GetSupplementalGroups()),
-		ToClusterPVCName: targetClusterName, // the PVC name should match that of the target cluster
-		WorkflowID:       workflowID,
-		// use a delta restore in order to optimize how the restore occurs
-		CommandOpts: "--delta",
-		// PITRTarget is not supported in the first iteration of clone
+		ToClusterPVCName:    targetClusterName, // the PVC name should match that of the target cluster
+		WorkflowID:          workflowID,
+		CommandOpts:         commandOpts,
+		PITRTarget:          pitrTarget,
 		PGOImagePrefix:      operator.Pgo.Pgo.PGOImagePrefix,
 		PGOImageTag:         operator.Pgo.Pgo.PGOImageTag,
 		PgbackrestStanza:    pgBackRestStanza,
 		PgbackrestDBPath:    fmt.Sprintf(targetClusterPGDATAPath, targetClusterName),
 		PgbackrestRepo1Path: util.GetPGBackRestRepoPath(targetPgcluster),
 		PgbackrestRepo1Host: fmt.Sprintf(backrest.BackrestRepoServiceName, targetClusterName),
-		PgbackrestRepoType:  operator.GetRepoType(task.Spec.Parameters["backrestStorageType"]),
+		PgbackrestRepoType:  operator.GetRepoType(repoType),
 		PgbackrestS3EnvVars: operator.GetPgbackrestS3EnvVars(sourcePgcluster, clientset, namespace),
+		NodeSelectorLabels:  operator.GetNodeSelectorJSON(&targetPgcluster),
+		Tolerations:         operator.GetTolerationsJSON(&targetPgcluster),
+		WALPVCName:          operator.GetWALPVCName(&sourcePgcluster, targetClusterName),
 	}
 
+	// the tablespaces of the source are restored onto the tablespace volumes of the target, which
+	// are mounted where the backups link them to
+	tablespaceStorageTypeMap := operator.GetTablespaceStorageTypeMap(sourcePgcluster.Spec.GetTablespaces())
+	backrestRestoreJobFields.TablespaceVolumes = operator.GetTablespaceVolumesJSON(targetClusterName,
+		tablespaceStorageTypeMap)
+	backrestRestoreJobFields.TablespaceVolumeMounts = operator.GetTablespaceVolumeMountsJSON(
+		tablespaceStorageTypeMap)
+
 	// substitute the variables into the BackrestRestore job template
 	var backrestRestoreJobDoc bytes.Buffer
 
@@ -399,6 +426,9 @@ func cloneStep2(clientset *kubernetes.Clientset, client *rest.RESTClient, namesp
 	operator.SetContainerImageOverride(config.CONTAINER_IMAGE_PGO_BACKREST_RESTORE,
 		&job.Spec.Template.Spec.Containers[0])
 
+	// apply the node affinity and the PriorityClass of the cluster
+	operator.AddPodScheduling(&targetPgcluster, nil, &job.Spec.Template.Spec)
+
 	// update the job annotations to include information about the source and
 	// target cluster
 	if job.ObjectMeta.Annotations == nil {
@@ -465,8 +495,14 @@ func cloneStep3(clientset *kubernetes.Clientset, client *rest.RESTClient, namesp
 		return
 	}
 
-	// and go forth and create the cluster!
-	if err := createCluster(clientset, client, task, sourcePgcluster, namespace, targetClusterName, workflowID); err != nil {
+	// and go forth and create the cluster! A Pgcluster that is cloned by its clone spec already
+	// exists, and is added instead
+	if target, isTarget := getCloneTarget(client, namespace, sourceClusterName, targetClusterName); isTarget {
+		err = addClonedCluster(clientset, client, sourcePgcluster, &target, workflowID)
+	} else {
+		err = createCluster(clientset, client, task, sourcePgcluster, namespace, targetClusterName, workflowID)
+	}
+	if err != nil {
 		log.Error(err)
 		errorMessage := fmt.Sprintf("Could not create cloned cluster: %s", err.Error())
 		PublishCloneEvent(events.EventCloneClusterFailure, namespace, task, errorMessage)
@@ -695,7 +731,8 @@ func createPgBackRestRepoSyncJob(clientset *kubernetes.Clientset, namespace stri
 // if the user spceified a different PVCSize than what is in the storage spec,
 // then that gets passed to the "createPVC" function
 func createPVCs(clientset *kubernetes.Clientset, client *rest.RESTClient,
-	task *crv1.Pgtask, namespace string, sourcePgcluster crv1.Pgcluster, targetClusterName string) {
+	task *crv1.Pgtask, namespace string, sourcePgcluster crv1.Pgcluster, targetClusterName string,
+	walStorage crv1.PgStorageSpec) {
 	// first, create the PVC for the pgBackRest storage, as we will be needing
 	// that sooner
 	CreatePVC{
@@ -722,7 +759,7 @@ func createPVCs(clientset *kubernetes.Clientset, client *rest.RESTClient,
 	}.createPVC()
 
 	// if there are any tablespacs, create PVCs for those
-	for tablespaceName, storageSpec := range sourcePgcluster.Spec.TablespaceMounts {
+	for tablespaceName, storageSpec := range sourcePgcluster.Spec.GetTablespaces() {
 		// generate the tablespace PVC name from the name of the clone cluster and
 		// the name of this tablespace
 		tablespacePVCName := operator.GetTablespacePVCName(targetClusterName, tablespaceName)
@@ -738,6 +775,31 @@ func createPVCs(clientset *kubernetes.Clientset, client *rest.RESTClient,
 			Storage:    storageSpec,
 		}.createPVC()
 	}
+
+	// if the WAL is kept on a volume of its own, create the PVC for that, which
+	// the restore links pg_wal to
+	if walPVCName := operator.GetWALPVCName(&sourcePgcluster, targetClusterName); walPVCName != "" {
+		CreatePVC{
+			Clientset:   clientset,
+			ClusterName: targetClusterName,
+			Namespace:   namespace,
+			PVCName:     walPVCName,
+			RESTClient:  client,
+			Storage:     walStorage,
+		}.createPVC()
+	}
+}
+
+// getCloneWALStorage returns the WAL storage of the cluster that is cloned: that of the Pgcluster
+// that is cloned by its clone spec, or else that of the source, which "pgo clone" copies
+func getCloneWALStorage(client *rest.RESTClient, namespace string, sourcePgcluster crv1.Pgcluster,
+	targetClusterName string) crv1.PgStorageSpec {
+	if target, isTarget := getCloneTarget(client, namespace, sourcePgcluster.Name,
+		targetClusterName); isTarget {
+		return target.Spec.WALStorage
+	}
+
+	return sourcePgcluster.Spec.WALStorage
 }
 
 func createCluster(clientset *kubernetes.Clientset, client *rest.RESTClient, task *crv1.Pgtask, sourcePgcluster crv1.Pgcluster, namespace string, targetClusterName string, workflowID string) error {
@@ -825,6 +887,8 @@ func createCluster(clientset *kubernetes.Clientset, client *rest.RESTClient, tas
 				config.LABEL_BACKREST_STORAGE_TYPE: sourcePgcluster.Spec.UserLabels[config.LABEL_BACKREST_STORAGE_TYPE],
 			},
 			TablespaceMounts: sourcePgcluster.Spec.TablespaceMounts,
+			Tablespaces:      sourcePgcluster.Spec.Tablespaces,
+			WALStorage:       sourcePgcluster.Spec.WALStorage,
 		},
 		Status: crv1.PgclusterStatus{
 			State:   crv1.PgclusterStateCreated,
@@ -912,6 +976,8 @@ func patchPgtaskComplete(client *rest.RESTClient, namespace, taskName string) {
 	if err := util.Patch(client, patchURL, crv1.CompletedStatus, patchResource, taskName, namespace); err != nil {
 		log.Error("error in status patch " + err.Error())
 	}
+
+	operator.UpdateTaskPhase(client, taskName, namespace, crv1.PgtaskPhaseSucceeded, "")
 }
 
 // publishCloneClusterEvent publishes the event when the cluster clone process
@@ -0,0 +1,291 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/config"
+	"github.com/crunchydata/postgres-operator/kubeapi"
+	"github.com/crunchydata/postgres-operator/operator"
+	"github.com/crunchydata/postgres-operator/operator/backrest"
+	"github.com/crunchydata/postgres-operator/util"
+	log "github.com/sirupsen/logrus"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// EventReasonInvalidClone is the reason of the Warning events that are recorded when a cluster
+// cannot be cloned from the source in its clone spec
+const EventReasonInvalidClone = "InvalidClone"
+
+// IsClone determines whether a cluster that is being added is to be cloned from another cluster
+// rather than initialized empty
+func IsClone(cluster *crv1.Pgcluster) bool {
+	return cluster.Spec.Clone.SourceClusterName != ""
+}
+
+// StartClone starts the clone workflow that bootstraps a cluster from the pgBackRest repository of
+// the source in its clone spec. This is the same workflow as that of "pgo clone", except that the
+// cluster already exists: it is marked as being cloned until the workflow has restored the
+// repository into it, at which point it is added. A cluster that is cloned from a snapshot is
+// added right away, once the volume of its primary is created from a snapshot of the source
+func StartClone(clientset *kubernetes.Clientset, client *rest.RESTClient, cluster *crv1.Pgcluster) error {
+	namespace := cluster.Namespace
+
+	source := crv1.Pgcluster{}
+	found, err := kubeapi.Getpgcluster(client, &source, cluster.Spec.Clone.SourceClusterName, namespace)
+	if !found {
+		err = fmt.Errorf("source cluster %s not found", cluster.Spec.Clone.SourceClusterName)
+	}
+	if err == nil {
+		err = validateClone(cluster, &source)
+	}
+	if err != nil {
+		operator.RecordWarningEvent(clientset, cluster, EventReasonInvalidClone, err.Error())
+		publishClusterCreateFailure(cluster, err.Error())
+		return kubeapi.PatchpgclusterStatus(client, crv1.PgclusterStateCreated,
+			"could not clone cluster: "+err.Error(), cluster, namespace)
+	}
+
+	if err := ReconcileCleanupFinalizer(client, cluster); err != nil {
+		log.Errorf("could not add cleanup finalizer to cluster %s: %s", cluster.Name, err)
+	}
+
+	// the cluster is the subject of the workflow, so the workflow takes its identity
+	workflowID := string(cluster.UID)
+	if err := createCloneWorkflowTask(client, cluster, workflowID); err != nil {
+		return err
+	}
+
+	// a clone from a snapshot is done as soon as the volume of the new primary is created from
+	// it, while the repository of the source is restored if it cannot be
+	if cluster.Spec.Clone.Method == crv1.CloneMethodSnapshot {
+		err := cloneFromSnapshot(clientset, cluster, &source)
+		if err == nil {
+			log.Infof("cloning cluster %s from a snapshot of cluster %s", cluster.Name, source.Name)
+
+			// the cluster was updated with the cleanup finalizer since it was read
+			target := crv1.Pgcluster{}
+			if _, err := kubeapi.Getpgcluster(client, &target, cluster.Name, namespace); err != nil {
+				return err
+			}
+
+			return addClonedCluster(clientset, client, source, &target, workflowID)
+		}
+
+		log.Warnf("could not clone cluster %s from a snapshot: %s", cluster.Name, err)
+		operator.RecordWarningEvent(clientset, cluster, eventReasonVolumeSnapshotFallback,
+			fmt.Sprintf("could not clone from a snapshot of cluster %s, restoring its pgBackRest "+
+				"repository instead: %s", source.Name, err))
+	}
+
+	cloneTask := util.CloneTask{
+		BackrestPVCSize:       cluster.Spec.BackrestStorage.Size,
+		BackrestStorageSource: cluster.Spec.Clone.BackrestStorageSource,
+		EnableMetrics:         operator.IsExporterEnabled(&cluster.Spec),
+		PGOUser:               cluster.ObjectMeta.Labels[config.LABEL_PGOUSER],
+		PVCSize:               cluster.Spec.PrimaryStorage.Size,
+		SourceClusterName:     source.Name,
+		TargetClusterName:     cluster.Name,
+		TaskStepLabel:         config.LABEL_PGO_CLONE_STEP_1,
+		TaskType:              crv1.PgtaskCloneStep1,
+		Timestamp:             time.Now(),
+		WorkflowID:            workflowID,
+	}
+
+	if err := kubeapi.Createpgtask(client, cloneTask.Create(), namespace); err != nil {
+		return err
+	}
+
+	log.Infof("cloning cluster %s from cluster %s", cluster.Name, source.Name)
+
+	return kubeapi.PatchpgclusterStatus(client, crv1.PgclusterStateCloning,
+		"Cloning from cluster "+source.Name, cluster, namespace)
+}
+
+// validateClone ensures that a cluster can be cloned from the source in its clone spec
+func validateClone(cluster, source *crv1.Pgcluster) error {
+	if source.Name == cluster.Name {
+		return errors.New("a cluster cannot be cloned from itself")
+	}
+
+	if source.Labels[config.LABEL_BACKREST] != "true" {
+		return fmt.Errorf("source cluster %s does not have pgBackRest enabled", source.Name)
+	}
+
+	// the repository is copied from the source by the clone workflow, which supports local
+	// and S3 storage
+	switch storageSource := cluster.Spec.Clone.BackrestStorageSource; storageSource {
+	case "", "local", "s3":
+	default:
+		return fmt.Errorf("invalid pgBackRest storage source %q to clone from, must be \"local\" or \"s3\"",
+			storageSource)
+	}
+
+	switch cluster.Spec.Clone.Method {
+	case "", crv1.CloneMethodPgBackRest:
+	case crv1.CloneMethodSnapshot:
+		// a snapshot is of the volume as it is, so there is no WAL to recover to a target with
+		if cluster.Spec.Clone.TargetTime != "" || cluster.Spec.Clone.TargetLSN != "" {
+			return errors.New("a clone from a snapshot cannot be recovered to a target time or LSN")
+		}
+	default:
+		return fmt.Errorf("invalid clone method %q, must be %q or %q", cluster.Spec.Clone.Method,
+			crv1.CloneMethodPgBackRest, crv1.CloneMethodSnapshot)
+	}
+
+	if cluster.Spec.Clone.SnapshotName != "" && cluster.Spec.Clone.Method != crv1.CloneMethodSnapshot {
+		return fmt.Errorf("a clone from snapshot %s requires the %q method",
+			cluster.Spec.Clone.SnapshotName, crv1.CloneMethodSnapshot)
+	}
+
+	// pg_wal is a link in the backups of a source that keeps its WAL on a volume of its own, and a
+	// directory otherwise, which the restore cannot change
+	if operator.HasWALStorage(source) != operator.HasWALStorage(cluster) {
+		return fmt.Errorf("the WAL storage of the cluster must be set if and only if source cluster %s "+
+			"has WAL storage", source.Name)
+	}
+
+	if err := util.ValidateBackrestStorageTypeOnBackupRestore(cluster.Spec.Clone.BackrestStorageSource,
+		source.Spec.UserLabels[config.LABEL_BACKREST_STORAGE_TYPE], true); err != nil {
+		return err
+	}
+
+	return backrest.ValidateCloneTarget(cluster.Spec.Clone)
+}
+
+// createCloneWorkflowTask creates the workflow task that tracks the progress of the clone of a
+// cluster
+func createCloneWorkflowTask(client *rest.RESTClient, cluster *crv1.Pgcluster, workflowID string) error {
+	taskName := fmt.Sprintf("%s-%s-%s", cluster.Name, util.RandStringBytesRmndr(4),
+		crv1.PgtaskWorkflowCloneType)
+
+	task := &crv1.Pgtask{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name: taskName,
+			Labels: map[string]string{
+				config.LABEL_PG_CLUSTER: cluster.Name,
+				crv1.PgtaskWorkflowID:   workflowID,
+			},
+		},
+		Spec: crv1.PgtaskSpec{
+			Namespace: cluster.Namespace,
+			Name:      taskName,
+			TaskType:  crv1.PgtaskWorkflow,
+			Parameters: map[string]string{
+				crv1.PgtaskWorkflowSubmittedStatus: time.Now().Format(time.RFC3339),
+				config.LABEL_PG_CLUSTER:            cluster.Name,
+				crv1.PgtaskWorkflowID:              workflowID,
+			},
+		},
+	}
+
+	return kubeapi.Createpgtask(client, task, cluster.Namespace)
+}
+
+// getCloneTarget returns the cluster that is being cloned from the source by its clone spec, if
+// it is. Otherwise the clone is one of "pgo clone", which creates the cluster once it is done
+func getCloneTarget(client *rest.RESTClient, namespace, sourceClusterName,
+	targetClusterName string) (crv1.Pgcluster, bool) {
+	target := crv1.Pgcluster{}
+
+	if found, _ := kubeapi.Getpgcluster(client, &target, targetClusterName, namespace); !found {
+		return target, false
+	}
+
+	return target, target.Status.State == crv1.PgclusterStateCloning &&
+		target.Spec.Clone.SourceClusterName == sourceClusterName
+}
+
+// addClonedCluster adds a cluster that was cloned from the source by its clone spec, once its
+// PGDATA has been restored. It takes the credentials of the source, which are those that are in
+// the restored PGDATA
+func addClonedCluster(clientset *kubernetes.Clientset, client *rest.RESTClient,
+	source crv1.Pgcluster, target *crv1.Pgcluster, workflowID string) error {
+	namespace := target.Namespace
+
+	cloneClusterSecrets := util.CloneClusterSecrets{
+		// ensure the pgBackRest secret is not copied over, as we will need to
+		// initialize a new repository
+		AdditionalSelectors: []string{"pgo-backrest-repo!=true"},
+		ClientSet:           clientset,
+		Namespace:           namespace,
+		SourceClusterName:   source.Spec.ClusterName,
+		TargetClusterName:   target.Name,
+	}
+
+	if err := cloneClusterSecrets.Clone(); err != nil {
+		return err
+	}
+
+	setCloneDefaults(&source, target)
+	target.ObjectMeta.Labels[config.LABEL_WORKFLOW_ID] = workflowID
+
+	if err := kubeapi.Updatepgcluster(client, target, target.Name, namespace); err != nil {
+		return err
+	}
+
+	if err := UpdateCloneWorkflow(client, namespace, workflowID, crv1.PgtaskWorkflowCloneClusterCreate); err != nil {
+		log.Error(err)
+	}
+
+	if err := kubeapi.PatchpgclusterStatus(client, crv1.PgclusterStateProcessed,
+		"Successfully processed Pgcluster by controller", target, namespace); err != nil {
+		return err
+	}
+
+	AddClusterBase(clientset, client, target, namespace)
+
+	return nil
+}
+
+// setCloneDefaults fills in what a cluster that is cloned takes from its source: its users and
+// their Secrets, which are copied from the source, and pgBackRest, which the cluster needs to
+// take over the restored repository
+func setCloneDefaults(source, target *crv1.Pgcluster) {
+	if target.ObjectMeta.Labels == nil {
+		target.ObjectMeta.Labels = map[string]string{}
+	}
+	target.ObjectMeta.Labels[config.LABEL_BACKREST] = "true"
+
+	if target.Spec.UserLabels == nil {
+		target.Spec.UserLabels = map[string]string{}
+	}
+	if target.Spec.UserLabels[config.LABEL_BACKREST_STORAGE_TYPE] == "" {
+		target.Spec.UserLabels[config.LABEL_BACKREST_STORAGE_TYPE] =
+			source.Spec.UserLabels[config.LABEL_BACKREST_STORAGE_TYPE]
+	}
+
+	if target.Spec.Database == "" {
+		target.Spec.Database = source.Spec.Database
+	}
+
+	// the tablespaces are those of the source, whose volumes the restored PGDATA links to
+	target.Spec.TablespaceMounts = source.Spec.TablespaceMounts
+	target.Spec.Tablespaces = source.Spec.Tablespaces
+
+	// the user is the one of the source, as it is the one in the restored PGDATA
+	target.Spec.User = source.Spec.User
+	target.Spec.SecretFrom = source.Spec.ClusterName
+	target.Spec.RootSecretName = target.Name + crv1.RootSecretSuffix
+	target.Spec.PrimarySecretName = target.Name + crv1.PrimarySecretSuffix
+	target.Spec.UserSecretName = fmt.Sprintf("%s-%s%s", target.Name, target.Spec.User, crv1.UserSecretSuffix)
+}
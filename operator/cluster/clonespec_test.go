@@ -0,0 +1,112 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"testing"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/config"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestValidateClone(t *testing.T) {
+	source := func(storageType string) *crv1.Pgcluster {
+		return &crv1.Pgcluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "hacluster",
+				Labels: map[string]string{config.LABEL_BACKREST: "true"}},
+			Spec: crv1.PgclusterSpec{UserLabels: map[string]string{
+				config.LABEL_BACKREST_STORAGE_TYPE: storageType}},
+		}
+	}
+
+	for _, test := range []struct {
+		name   string
+		clone  crv1.CloneSpec
+		target string
+		source *crv1.Pgcluster
+		valid  bool
+	}{
+		{"valid", crv1.CloneSpec{SourceClusterName: "hacluster"}, "staging", source(""), true},
+		{"point in time", crv1.CloneSpec{SourceClusterName: "hacluster", TargetLSN: "0/3000060"},
+			"staging", source("local"), true},
+		{"from s3", crv1.CloneSpec{SourceClusterName: "hacluster", BackrestStorageSource: "s3"},
+			"staging", source("local,s3"), true},
+		{"itself", crv1.CloneSpec{SourceClusterName: "hacluster"}, "hacluster", source(""), false},
+		{"without pgBackRest", crv1.CloneSpec{SourceClusterName: "hacluster"}, "staging",
+			&crv1.Pgcluster{ObjectMeta: metav1.ObjectMeta{Name: "hacluster"}}, false},
+		{"s3 not enabled", crv1.CloneSpec{SourceClusterName: "hacluster", BackrestStorageSource: "s3"},
+			"staging", source("local"), false},
+		{"from gcs", crv1.CloneSpec{SourceClusterName: "hacluster", BackrestStorageSource: "gcs"},
+			"staging", source("gcs"), false},
+		{"invalid target", crv1.CloneSpec{SourceClusterName: "hacluster", TargetLSN: "3000060"},
+			"staging", source(""), false},
+		{"from a snapshot", crv1.CloneSpec{SourceClusterName: "hacluster", Method: crv1.CloneMethodSnapshot},
+			"staging", source(""), true},
+		{"snapshot to a point in time", crv1.CloneSpec{SourceClusterName: "hacluster",
+			Method: crv1.CloneMethodSnapshot, TargetTime: "2020-06-01 12:00:00+00"}, "staging", source(""), false},
+		{"invalid method", crv1.CloneSpec{SourceClusterName: "hacluster", Method: "rsync"},
+			"staging", source(""), false},
+		{"from a scheduled snapshot", crv1.CloneSpec{SourceClusterName: "hacluster",
+			Method: crv1.CloneMethodSnapshot, SnapshotName: "hacluster-20200601120000"}, "staging", source(""), true},
+		{"scheduled snapshot without the method", crv1.CloneSpec{SourceClusterName: "hacluster",
+			SnapshotName: "hacluster-20200601120000"}, "staging", source(""), false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			cluster := &crv1.Pgcluster{ObjectMeta: metav1.ObjectMeta{Name: test.target}}
+			cluster.Spec.Clone = test.clone
+
+			if err := validateClone(cluster, test.source); (err == nil) != test.valid {
+				t.Errorf("expected valid: %v, got %v", test.valid, err)
+			}
+		})
+	}
+}
+
+func TestSetCloneDefaults(t *testing.T) {
+	source := &crv1.Pgcluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "hacluster"},
+		Spec: crv1.PgclusterSpec{
+			ClusterName: "hacluster",
+			Database:    "hippo",
+			User:        "testuser",
+			UserLabels:  map[string]string{config.LABEL_BACKREST_STORAGE_TYPE: "local,s3"},
+		},
+	}
+	target := &crv1.Pgcluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "staging"},
+		Spec:       crv1.PgclusterSpec{User: "someone"},
+	}
+
+	setCloneDefaults(source, target)
+
+	if target.Labels[config.LABEL_BACKREST] != "true" {
+		t.Error("expected pgBackRest to be enabled")
+	}
+	if storageType := target.Spec.UserLabels[config.LABEL_BACKREST_STORAGE_TYPE]; storageType != "local,s3" {
+		t.Errorf("expected the storage type of the source, got %q", storageType)
+	}
+	if target.Spec.Database != "hippo" || target.Spec.User != "testuser" {
+		t.Errorf("expected the database and user of the source, got %q and %q",
+			target.Spec.Database, target.Spec.User)
+	}
+	if target.Spec.SecretFrom != "hacluster" {
+		t.Errorf("expected the secrets to be from the source, got %q", target.Spec.SecretFrom)
+	}
+	if target.Spec.UserSecretName != "staging-testuser-secret" {
+		t.Errorf("expected the user secret of the clone, got %q", target.Spec.UserSecretName)
+	}
+}
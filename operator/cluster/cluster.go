@@ -72,6 +72,73 @@ func AddClusterBase(clientset *kubernetes.Clientset, client *rest.RESTClient, cl
 		return
 	}
 
+	// an ephemeral storage limit that is too low is not fatal, but is likely to
+	// get the Pods of the cluster evicted, so warn about it
+	if err := operator.ValidateEphemeralStorage(cl); err != nil {
+		log.Warnf("cluster %s: %s", cl.Spec.Name, err)
+	}
+
+	// S3 settings that pgBackRest cannot use would only fail once the stanza is created, so
+	// reject them up front
+	if err := ValidateBackrestS3(clientset, cl); err != nil {
+		log.Error(err)
+		publishClusterCreateFailure(cl, err.Error())
+		return
+	}
+
+	// as do GCS or Azure settings, or missing credentials of either
+	if err := ValidateBackrestCloud(clientset, cl); err != nil {
+		log.Error(err)
+		publishClusterCreateFailure(cl, err.Error())
+		return
+	}
+
+	// the same goes for additional pgBackRest repositories, which WAL could not be archived to
+	if err := ValidateBackrestRepos(clientset, cl); err != nil {
+		log.Error(err)
+		publishClusterCreateFailure(cl, err.Error())
+		return
+	}
+
+	// a cluster that could not be created as it is specified, e.g. as there is no validating
+	// webhook to reject it in the first place, is rejected before anything is created
+	if reasons := ValidateCluster(cl); len(reasons) > 0 {
+		message := describeReasons(reasons)
+		log.Error(message)
+		publishClusterCreateFailure(cl, message)
+		return
+	}
+
+	// the Pods take their workload identity from their ServiceAccount as they are created, so
+	// it is annotated before any of them are
+	if err := operator.ReconcileBackrestIdentity(clientset, cl); err != nil {
+		log.Error(err)
+		operator.RecordWarningEvent(clientset, cl, operator.EventReasonInvalidBackrestIdentity, err.Error())
+		publishClusterCreateFailure(cl, err.Error())
+		return
+	}
+
+	// the pods of a cluster whose secret store is missing would wait on their volume forever
+	if err := operator.CheckBackrestSecretStore(clientset, cl); err != nil {
+		log.Error(err)
+		operator.RecordWarningEvent(clientset, cl, operator.EventReasonInvalidBackrestSecretStore, err.Error())
+		publishClusterCreateFailure(cl, err.Error())
+		return
+	}
+
+	// the NetworkPolicies are in place before the pods they isolate
+	if cl.Spec.NetworkPolicy.Enabled {
+		if err := ReconcileNetworkPolicies(clientset, cl); err != nil {
+			log.Error(err)
+		}
+	}
+
+	if cl.Spec.PodDisruptionBudget.Enabled {
+		if err := ReconcilePodDisruptionBudgets(clientset, client, cl); err != nil {
+			log.Error(err)
+		}
+	}
+
 	var pvcName string
 
 	_, found, err := kubeapi.GetPVC(clientset, cl.Spec.Name, namespace)
@@ -90,7 +157,7 @@ func AddClusterBase(clientset *kubernetes.Clientset, client *rest.RESTClient, cl
 
 	// iterate through all of the tablespaces and attempt to create their PVCs
 	// for this cluster
-	for tablespaceName, storageSpec := range cl.Spec.TablespaceMounts {
+	for tablespaceName, storageSpec := range cl.Spec.GetTablespaces() {
 		// first, generate the tablespace PVC name from the cluster deployment name
 		// and the name of the tablespace
 		tablespacePVCName := operator.GetTablespacePVCName(cl.Spec.Name, tablespaceName)
@@ -103,6 +170,22 @@ func AddClusterBase(clientset *kubernetes.Clientset, client *rest.RESTClient, cl
 		}
 	}
 
+	// the write-ahead log of the primary is kept on a PVC of its own, if the cluster has WAL
+	// storage
+	if err := CreateWALPVC(clientset, cl, cl.Spec.Name); err != nil {
+		log.Error(err)
+		publishClusterCreateFailure(cl, err.Error())
+		return
+	}
+
+	// the configuration of the additional pgBackRest repositories is mounted by the instances
+	// and the pgBackRest repository, so it needs to be there before they are created
+	if _, err := ReconcileBackrestReposSecret(clientset, cl); err != nil {
+		log.Error(err)
+		publishClusterCreateFailure(cl, err.Error())
+		return
+	}
+
 	//replaced with ccpimagetag instead of pg version
 
 	AddCluster(clientset, client, cl, namespace, pvcName)
@@ -116,6 +199,14 @@ func AddClusterBase(clientset *kubernetes.Clientset, client *rest.RESTClient, cl
 		log.Error("error in pvcname patch " + err.Error())
 	}
 
+	// the locale is fixed once the data directory is initialized, so it is recorded as the
+	// locale that any later change of it is checked against
+	if err := kubeapi.Patchpgcluster(client, cl, namespace, func(cluster *crv1.Pgcluster) {
+		cluster.Status.Locale = crv1.LocaleStatus{Initialized: cl.Spec.Locale}
+	}); err != nil {
+		log.Error("error in locale status patch " + err.Error())
+	}
+
 	//publish create cluster event
 	//capture the cluster creation event
 	pgouser := cl.ObjectMeta.Labels[config.LABEL_PGOUSER]
@@ -139,6 +230,18 @@ func AddClusterBase(clientset *kubernetes.Clientset, client *rest.RESTClient, cl
 		log.Error(err.Error())
 	}
 
+	// set up the routine maintenance schedule, if there is one
+	if cl.Spec.Maintenance.Schedule != "" {
+		if err := UpdateMaintenanceSchedule(clientset, cl, namespace); err != nil {
+			log.Error(err)
+		}
+	}
+
+	// set up the scheduled backups of the additional pgBackRest repositories, if there are any
+	if err := ReconcileBackrestRepoSchedules(client, cl); err != nil {
+		log.Error(err)
+	}
+
 	//add replicas if requested
 	if cl.Spec.Replicas != "" {
 		replicaCount, err := strconv.Atoi(cl.Spec.Replicas)
@@ -261,6 +364,8 @@ func ScaleBase(clientset *kubernetes.Clientset, client *rest.RESTClient, replica
 		log.Debugf("pvc [%s] already present for replica from previous cluster with this same name, will not recreate",
 			replica.Spec.Name)
 		pvcName = replica.Spec.Name
+	} else if createReplicaPVCFromSnapshot(clientset, &cluster, replica) {
+		pvcName = replica.Spec.Name
 	} else {
 		pvcName, err = pvc.CreatePVC(clientset, &replica.Spec.ReplicaStorage, replica.Spec.Name, cluster.Spec.Name, namespace)
 		if err != nil {
@@ -278,12 +383,12 @@ func ScaleBase(clientset *kubernetes.Clientset, client *rest.RESTClient, replica
 
 	//create the replica service if it doesnt exist
 
-	st := operator.Pgo.Cluster.ServiceType
+	st := getClusterServiceType(&cluster)
 
-	if replica.Spec.UserLabels[config.LABEL_SERVICE_TYPE] != "" {
+	if cluster.Spec.Services.Replica.ServiceType != "" {
+		st = cluster.Spec.Services.Replica.ServiceType
+	} else if replica.Spec.UserLabels[config.LABEL_SERVICE_TYPE] != "" {
 		st = replica.Spec.UserLabels[config.LABEL_SERVICE_TYPE]
-	} else if cluster.Spec.UserLabels[config.LABEL_SERVICE_TYPE] != "" {
-		st = cluster.Spec.UserLabels[config.LABEL_SERVICE_TYPE]
 	}
 
 	serviceName := replica.Spec.ClusterName + "-replica"
@@ -303,9 +408,21 @@ func ScaleBase(clientset *kubernetes.Clientset, client *rest.RESTClient, replica
 		return
 	}
 
+	if err := reconcileService(clientset, namespace, serviceName, cluster.Spec.Services.Replica,
+		st); err != nil {
+		log.Error(err)
+	}
+
 	//instantiate the replica
 	Scale(clientset, client, replica, namespace, pvcName, &cluster)
 
+	// the budget of the replicas is sized from their number
+	if cluster.Spec.PodDisruptionBudget.Enabled {
+		if err := ReconcilePodDisruptionBudgets(clientset, client, &cluster); err != nil {
+			log.Error(err)
+		}
+	}
+
 	//update the replica CRD status
 	err = util.Patch(client, "/spec/status", crv1.CompletedStatus, crv1.PgreplicaResourcePlural, replica.Spec.Name, namespace)
 	if err != nil {
@@ -328,6 +445,13 @@ func ScaleDownBase(clientset *kubernetes.Clientset, client *rest.RESTClient, rep
 
 	DeleteReplica(clientset, replica, namespace)
 
+	// the budget of the replicas is sized from their number
+	if cluster.Spec.PodDisruptionBudget.Enabled {
+		if err := ReconcilePodDisruptionBudgets(clientset, client, &cluster); err != nil {
+			log.Error(err)
+		}
+	}
+
 	//publish event for scale down
 	topics := make([]string, 1)
 	topics[0] = events.EventTopicCluster
@@ -351,17 +475,27 @@ func ScaleDownBase(clientset *kubernetes.Clientset, client *rest.RESTClient, rep
 
 }
 
-// UpdateResources updates the PostgreSQL instance Deployments to reflect the
-// update resources (i.e. CPU, memory)
-func UpdateResources(clientset *kubernetes.Clientset, restConfig *rest.Config, cluster *crv1.Pgcluster) error {
-	// put the resources in their proper format for updating the cluster
-	// the "remove*" bit lets us know that we will have the request be "unbounded"
-	cpu, err := resource.ParseQuantity(cluster.Spec.ContainerResources.RequestsCPU)
-	removeCPU := err != nil
+// ApplyResources updates the instances of a cluster to the resources of its spec in the
+// background, within the restart budget, once the maintenance window of the cluster is open
+func ApplyResources(clientset *kubernetes.Clientset, restclient *rest.RESTClient,
+	restconfig *rest.Config, cluster *crv1.Pgcluster) error {
+	deferred, err := deferMaintenanceAction(clientset, restclient, cluster, maintenanceActionResources)
+	if err != nil || deferred {
+		return err
+	}
 
-	memory, err := resource.ParseQuantity(cluster.Spec.ContainerResources.RequestsMemory)
-	removeMemory := err != nil
+	RestartWithinBudget(clientset, cluster, "resources", func() error {
+		return UpdateResources(clientset, restconfig, cluster)
+	})
 
+	return nil
+}
+
+// UpdateResources updates the PostgreSQL instance Deployments to reflect the
+// update resources (i.e. CPU, memory). An instance that has the resources
+// already, e.g. as they were rolled out to it by the resource recommendation,
+// is left alone
+func UpdateResources(clientset *kubernetes.Clientset, restConfig *rest.Config, cluster *crv1.Pgcluster) error {
 	// get a list of all of the instance deployments for the cluster
 	deployments, err := operator.GetInstanceDeployments(clientset, cluster)
 
@@ -376,34 +510,11 @@ func UpdateResources(clientset *kubernetes.Clientset, restConfig *rest.Config, c
 	// so that all the replicas are updated first, and then the primary gets the
 	// update
 	for _, deployment := range deployments.Items {
-		requestResourceList := v1.ResourceList{}
-
-		// if there is a request resource list available already, use that one
 		// NOTE: this works as the "database" container is always first
-		if deployment.Spec.Template.Spec.Containers[0].Resources.Requests != nil {
-			requestResourceList = deployment.Spec.Template.Spec.Containers[0].Resources.Requests
-		}
-
-		if removeCPU {
-			delete(requestResourceList, v1.ResourceCPU)
-		} else {
-			requestResourceList[v1.ResourceCPU] = cpu
-		}
+		resources := &deployment.Spec.Template.Spec.Containers[0].Resources
 
-		// handle the memory update
-		if removeMemory {
-			delete(requestResourceList, v1.ResourceMemory)
-		} else {
-			requestResourceList[v1.ResourceMemory] = memory
-		}
-
-		// update the requests resourcelist
-		deployment.Spec.Template.Spec.Containers[0].Resources.Requests = requestResourceList
-
-		// regardless, ensure the limits are gone
-		if deployment.Spec.Template.Spec.Containers[0].Resources.Limits != nil {
-			delete(deployment.Spec.Template.Spec.Containers[0].Resources.Limits, v1.ResourceCPU)
-			delete(deployment.Spec.Template.Spec.Containers[0].Resources.Limits, v1.ResourceMemory)
+		if !setContainerResources(resources, cluster.Spec.ContainerResources) {
+			continue
 		}
 
 		// Before applying the update, we want to explicitly stop PostgreSQL on each
@@ -424,6 +535,49 @@ func UpdateResources(clientset *kubernetes.Clientset, restConfig *rest.Config, c
 	return nil
 }
 
+// setContainerResources sets the CPU and memory requests and limits of a container to those of
+// the spec, and returns whether any of them changed. A request or a limit that is not set in
+// the spec, or does not parse, is removed, i.e. is "unbounded"
+func setContainerResources(resources *v1.ResourceRequirements, spec crv1.PgContainerResources) bool {
+	changed := false
+
+	for _, list := range []struct {
+		resources *v1.ResourceList
+		cpu       string
+		memory    string
+	}{
+		{&resources.Requests, spec.RequestsCPU, spec.RequestsMemory},
+		{&resources.Limits, spec.LimitsCPU, spec.LimitsMemory},
+	} {
+		if *list.resources == nil {
+			*list.resources = v1.ResourceList{}
+		}
+
+		for name, value := range map[v1.ResourceName]string{
+			v1.ResourceCPU:    list.cpu,
+			v1.ResourceMemory: list.memory,
+		} {
+			current, exists := (*list.resources)[name]
+
+			quantity, err := resource.ParseQuantity(value)
+			if err != nil {
+				if exists {
+					delete(*list.resources, name)
+					changed = true
+				}
+				continue
+			}
+
+			if !exists || current.Cmp(quantity) != 0 {
+				(*list.resources)[name] = quantity
+				changed = true
+			}
+		}
+	}
+
+	return changed
+}
+
 // UpdateTablespaces updates the PostgreSQL instance Deployments to update
 // what tablespaces are mounted.
 // Though any new tablespaces are present in the CRD, to attempt to do less work
@@ -500,7 +654,7 @@ func UpdateTablespaces(clientset *kubernetes.Clientset, restConfig *rest.Config,
 			// yup, it's an old fashioned linear time lookup
 			if envVar.Name == "PGHA_TABLESPACES" {
 				deployment.Spec.Template.Spec.Containers[0].Env[i].Value = operator.GetTablespaceNames(
-					cluster.Spec.TablespaceMounts)
+					cluster.Spec.GetTablespaces())
 				ok = true
 			}
 		}
@@ -509,7 +663,7 @@ func UpdateTablespaces(clientset *kubernetes.Clientset, restConfig *rest.Config,
 		if !ok {
 			envVar := v1.EnvVar{
 				Name:  "PGHA_TABLESPACES",
-				Value: operator.GetTablespaceNames(cluster.Spec.TablespaceMounts),
+				Value: operator.GetTablespaceNames(cluster.Spec.GetTablespaces()),
 			}
 			deployment.Spec.Template.Spec.Containers[0].Env = append(deployment.Spec.Template.Spec.Containers[0].Env, envVar)
 		}
@@ -631,3 +785,31 @@ func stopPostgreSQLInstance(clientset *kubernetes.Clientset, restConfig *rest.Co
 
 	return nil
 }
+
+// updateDeployments applies a change to each of the given Deployments and updates those that it
+// changed, which rolls out their Pods again. The change is made by mutate, which returns whether
+// it changed the Deployment. PostgreSQL is explicitly stopped on an instance before its Pod is
+// replaced, so that it does not boot up in crash recovery mode
+func updateDeployments(clientset *kubernetes.Clientset, restConfig *rest.Config,
+	deployments []apps_v1.Deployment, mutate func(deployment *apps_v1.Deployment) bool) error {
+	for i := range deployments {
+		deployment := &deployments[i]
+
+		if !mutate(deployment) {
+			continue
+		}
+
+		// if an error is returned, we only issue a warning
+		if _, ok := deployment.ObjectMeta.Labels[config.LABEL_DEPLOYMENT_NAME]; ok {
+			if err := stopPostgreSQLInstance(clientset, restConfig, *deployment); err != nil {
+				log.Warn(err)
+			}
+		}
+
+		if err := kubeapi.UpdateDeployment(clientset, deployment); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -35,6 +35,7 @@ import (
 	"github.com/crunchydata/postgres-operator/util"
 	log "github.com/sirupsen/logrus"
 	v1 "k8s.io/api/apps/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 )
@@ -47,10 +48,7 @@ func AddCluster(clientset *kubernetes.Clientset, client *rest.RESTClient, cl *cr
 	log.Info("creating Pgcluster object  in namespace " + namespace)
 	log.Info("created with Name=" + cl.Spec.Name + " in namespace " + namespace)
 
-	st := operator.Pgo.Cluster.ServiceType
-	if cl.Spec.UserLabels[config.LABEL_SERVICE_TYPE] != "" {
-		st = cl.Spec.UserLabels[config.LABEL_SERVICE_TYPE]
-	}
+	st := getServiceSpecType(cl.Spec.Services.Primary, getClusterServiceType(cl))
 
 	//create the primary service
 	serviceFields := ServiceTemplateFields{
@@ -70,6 +68,12 @@ func AddCluster(clientset *kubernetes.Clientset, client *rest.RESTClient, cl *cr
 		return err
 	}
 
+	// the template only has the type of the service, so the rest of its settings are applied
+	if err := reconcileService(clientset, namespace, cl.Spec.Name, cl.Spec.Services.Primary,
+		st); err != nil {
+		log.Error(err)
+	}
+
 	cl.Spec.UserLabels["name"] = cl.Spec.Name
 	cl.Spec.UserLabels[config.LABEL_PG_CLUSTER] = cl.Spec.ClusterName
 
@@ -105,7 +109,54 @@ func AddCluster(clientset *kubernetes.Clientset, client *rest.RESTClient, cl *cr
 	cl.Spec.UserLabels[config.LABEL_PGHA_SCOPE] = cl.Spec.Name
 
 	// set up a map of the names of the tablespaces as well as the storage classes
-	tablespaceStorageTypeMap := operator.GetTablespaceStorageTypeMap(cl.Spec.TablespaceMounts)
+	tablespaceStorageTypeMap := operator.GetTablespaceStorageTypeMap(cl.Spec.GetTablespaces())
+
+	// the certificates are issued before the deployment that mounts them, and the one of the
+	// server has to be issued before the bundle of trusted CAs is made of it, which cert-manager
+	// takes a moment for
+	if cl.Spec.TLS.IsManaged() {
+		if err := ReconcileTLSCertificates(clientset, client, cl); err != nil {
+			log.Error("error in issuing certificates " + err.Error())
+			publishClusterCreateFailure(cl, err.Error())
+			return err
+		}
+	}
+
+	if cl.Spec.TLS.CertManager.IsEnabled() {
+		if err := waitForTLSCertificate(clientset, operator.GetTLSSecretName(cl), namespace,
+			tlsCertificateIssueTimeout, tlsCertificateIssuePeriod); err != nil {
+			log.Error(err)
+			publishClusterCreateFailure(cl, err.Error())
+			return err
+		}
+	}
+
+	// the bundle of trusted CAs has to exist before the deployment that mounts it, and so does
+	// the client certificate of the replication user when it is issued from the client CA
+	if cl.Spec.TLS.ClientAuth.IsEnabled() && cl.Spec.TLS.IsTLSEnabled() {
+		if err := UpdateTLSClientCABundle(clientset, cl); err != nil {
+			log.Error("error in creating bundle of trusted CAs " + err.Error())
+			publishClusterCreateFailure(cl, err.Error())
+			return err
+		}
+
+		if cl.Spec.TLS.ClientAuth.IssueCertificates {
+			if err := issueTLSClientCertificates(clientset, cl); err != nil {
+				log.Error("error in issuing client certificates " + err.Error())
+				publishClusterCreateFailure(cl, err.Error())
+				return err
+			}
+		}
+	}
+
+	standbySSLMode, standbySSLRootCert := getStandbyReplicationSSL(cl)
+
+	runtimeClassName, err := operator.GetRuntimeClassName(clientset, cl, "")
+	if err != nil {
+		log.Error(err)
+		publishClusterCreateFailure(cl, err.Error())
+		return err
+	}
 
 	//create the primary deployment
 	deploymentFields := operator.DeploymentTemplateFields{
@@ -131,28 +182,39 @@ func AddCluster(clientset *kubernetes.Clientset, client *rest.RESTClient, cl *cr
 		PrimarySecretName:  cl.Spec.PrimarySecretName,
 		UserSecretName:     cl.Spec.UserSecretName,
 		NodeSelector:       operator.GetAffinity(cl.Spec.UserLabels["NodeLabelKey"], cl.Spec.UserLabels["NodeLabelValue"], "In"),
+		NodeSelectorLabels: operator.GetNodeSelectorJSON(cl),
+		Tolerations:        operator.GetTolerationsJSON(cl),
+		RuntimeClassName:   runtimeClassName,
 		PodAntiAffinity:    operator.GetPodAntiAffinity(cl, crv1.PodAntiAffinityDeploymentDefault, cl.Spec.PodAntiAffinity.Default),
-		ContainerResources: operator.GetContainerResourcesJSON(&cl.Spec.ContainerResources),
+		ContainerResources: operator.GetContainerResourcesWithEphemeralStorageJSON(&cl.Spec.ContainerResources, cl.Spec.EphemeralStorage),
 		ConfVolume:         operator.GetConfVolume(clientset, cl, namespace),
-		CollectAddon:       operator.GetCollectAddon(clientset, namespace, &cl.Spec),
+		CollectAddon:       operator.GetCollectAddon(&cl.Spec),
 		CollectVolume:      operator.GetCollectVolume(clientset, cl, namespace),
+		CollectQueries:     operator.GetCollectQueries(&cl.Spec),
 		BadgerAddon:        operator.GetBadgerAddon(clientset, namespace, cl, cl.Spec.Name),
-		PgmonitorEnvVars:   operator.GetPgmonitorEnvVars(cl.Spec.UserLabels[config.LABEL_COLLECT]),
+		PgmonitorEnvVars:   operator.GetPgmonitorEnvVars(&cl.Spec),
 		ScopeLabel:         config.LABEL_PGHA_SCOPE,
 		PgbackrestEnvVars: operator.GetPgbackrestEnvVars(cl, cl.Labels[config.LABEL_BACKREST], cl.Spec.Name,
 			cl.Spec.Port, cl.Spec.UserLabels[config.LABEL_BACKREST_STORAGE_TYPE]),
-		PgbackrestS3EnvVars:      operator.GetPgbackrestS3EnvVars(*cl, clientset, namespace),
-		EnableCrunchyadm:         operator.Pgo.Cluster.EnableCrunchyadm,
-		ReplicaReinitOnStartFail: !operator.Pgo.Cluster.DisableReplicaStartFailReinit,
-		SyncReplication:          operator.GetSyncReplication(cl.Spec.SyncReplication),
-		Tablespaces:              operator.GetTablespaceNames(cl.Spec.TablespaceMounts),
-		TablespaceVolumes:        operator.GetTablespaceVolumesJSON(cl.Spec.Name, tablespaceStorageTypeMap),
-		TablespaceVolumeMounts:   operator.GetTablespaceVolumeMountsJSON(tablespaceStorageTypeMap),
-		TLSEnabled:               cl.Spec.TLS.IsTLSEnabled(),
-		TLSOnly:                  cl.Spec.TLSOnly,
-		TLSSecret:                cl.Spec.TLS.TLSSecret,
-		CASecret:                 cl.Spec.TLS.CASecret,
-		Standby:                  cl.Spec.Standby,
+		PgbackrestS3EnvVars:           operator.GetPgbackrestS3EnvVars(*cl, clientset, namespace),
+		EnableCrunchyadm:              operator.Pgo.Cluster.EnableCrunchyadm,
+		ReplicaReinitOnStartFail:      !operator.Pgo.Cluster.DisableReplicaStartFailReinit,
+		SyncReplication:               operator.GetSyncReplication(cl.Spec.SyncReplication),
+		Tablespaces:                   operator.GetTablespaceNames(cl.Spec.GetTablespaces()),
+		TablespaceVolumes:             operator.GetTablespaceVolumesJSON(cl.Spec.Name, tablespaceStorageTypeMap),
+		TablespaceVolumeMounts:        operator.GetTablespaceVolumeMountsJSON(tablespaceStorageTypeMap),
+		WALPVCName:                    operator.GetWALPVCName(cl, cl.Spec.Name),
+		WALDir:                        operator.GetWALDir(cl, cl.Spec.Name),
+		TLSEnabled:                    cl.Spec.TLS.IsTLSEnabled(),
+		TLSOnly:                       cl.Spec.TLSOnly,
+		TLSSecret:                     operator.GetTLSSecretName(cl),
+		CASecret:                      operator.GetTLSCASecretName(cl),
+		TLSReplicationSecret:          operator.GetTLSReplicationSecretName(cl),
+		Standby:                       cl.Spec.Standby,
+		StandbyReplicationSSLMode:     standbySSLMode,
+		StandbyReplicationSSLRootCert: standbySSLRootCert,
+		StartupGate: operator.GetStartupGateJSON(cl, fmt.Sprintf("%s/%s:%s",
+			operator.Pgo.Cluster.CCPImagePrefix, cl.Spec.CCPImage, cl.Spec.CCPImageTag)),
 	}
 
 	// Create a configMap for the cluster that will be utilized to configure whether or not
@@ -189,6 +251,39 @@ func AddCluster(clientset *kubernetes.Clientset, client *rest.RESTClient, cl *cr
 	// determine if any of the container images need to be overridden
 	operator.OverrideClusterContainerImages(deployment.Spec.Template.Spec.Containers)
 
+	// mount the configuration of the additional pgBackRest repositories, if there are any
+	operator.AddBackrestReposVolume(cl, &deployment.Spec.Template.Spec)
+
+	// mount the credentials of the repository from its secret store, if it has one
+	operator.AddBackrestSecretStoreVolume(cl, &deployment.Spec.Template.Spec)
+
+	// spread the instances of the cluster across nodes and zones
+	operator.AddInstancePlacement(cl, &deployment.Spec.Template.Spec)
+
+	// apply the node affinity and the PriorityClass of the cluster
+	operator.AddPodScheduling(cl, nil, &deployment.Spec.Template.Spec)
+
+	// set the custom labels and annotations of the instances
+	operator.AddCustomMetadata(cl, &cl.Spec.Metadata.Postgres, &deployment.ObjectMeta,
+		&deployment.Spec.Template.ObjectMeta)
+
+	// set the chargeback labels of the Namespace
+	operator.AddChargebackLabels(clientset, namespace, &deployment.ObjectMeta,
+		&deployment.Spec.Template.ObjectMeta)
+
+	// add the containers, init containers and volumes of the spec of the cluster
+	operator.SetInstancePod(cl, &deployment.Spec.Template)
+
+	// hold back the Pods behind the scheduling gate of the cluster, if it has one
+	operator.AddSchedulingGate(cl, fmt.Sprintf("%s/%s:%s", operator.Pgo.Cluster.CCPImagePrefix,
+		cl.Spec.CCPImage, cl.Spec.CCPImageTag), &deployment.Spec.Template)
+
+	// move the data directory into place if the volume was created from a snapshot of another
+	// instance
+	addSnapshotDataInitContainer(clientset, primaryPVCName, cl.Spec.Name, namespace,
+		fmt.Sprintf("%s/%s:%s", operator.Pgo.Cluster.CCPImagePrefix, cl.Spec.CCPImage, cl.Spec.CCPImageTag),
+		&deployment.Spec.Template.Spec)
+
 	if _, found, _ := kubeapi.GetDeployment(clientset, cl.Spec.Name, namespace); !found {
 		err = kubeapi.CreateDeployment(clientset, &deployment, namespace)
 		if err != nil {
@@ -287,7 +382,7 @@ func Scale(clientset *kubernetes.Clientset, client *rest.RESTClient, replica *cr
 
 	// iterate through all of the tablespaces and attempt to create their PVCs
 	// for the replcia
-	for tablespaceName, storageSpec := range cluster.Spec.TablespaceMounts {
+	for tablespaceName, storageSpec := range cluster.Spec.GetTablespaces() {
 		// attempt to create the tablespace PVC. If it fails to create, log the
 		// error and publish the failure event
 		// Note that we specify **replica.Spec.Name** in order to create distinct
@@ -302,10 +397,28 @@ func Scale(clientset *kubernetes.Clientset, client *rest.RESTClient, replica *cr
 		}
 	}
 
+	// the write-ahead log of the replica is kept on a PVC of its own, if the cluster has WAL
+	// storage
+	if err := CreateWALPVC(clientset, cluster, replica.Spec.Name); err != nil {
+		log.Error(err)
+		publishScaleError(namespace, replica.ObjectMeta.Labels[config.LABEL_PGOUSER], cluster)
+		return err
+	}
+
 	// set up a map of the names of the tablespaces as well as the storage classes
-	tablespaceStorageTypeMap := operator.GetTablespaceStorageTypeMap(cluster.Spec.TablespaceMounts)
+	tablespaceStorageTypeMap := operator.GetTablespaceStorageTypeMap(cluster.Spec.GetTablespaces())
+
+	runtimeClassName, err := operator.GetRuntimeClassName(clientset, cluster,
+		replica.Spec.RuntimeClassName)
+	if err != nil {
+		log.Error(err)
+		publishScaleError(namespace, replica.ObjectMeta.Labels[config.LABEL_PGOUSER], cluster)
+		return err
+	}
 
 	//create the replica deployment
+	standbySSLMode, standbySSLRootCert := getStandbyReplicationSSL(cluster)
+
 	replicaDeploymentFields := operator.DeploymentTemplateFields{
 		Name:               replica.Spec.Name,
 		ClusterName:        replica.Spec.ClusterName,
@@ -328,27 +441,38 @@ func Scale(clientset *kubernetes.Clientset, client *rest.RESTClient, replica *cr
 		RootSecretName:     cluster.Spec.RootSecretName,
 		PrimarySecretName:  cluster.Spec.PrimarySecretName,
 		UserSecretName:     cluster.Spec.UserSecretName,
-		ContainerResources: operator.GetContainerResourcesJSON(&cs),
+		ContainerResources: operator.GetContainerResourcesWithEphemeralStorageJSON(&cs, cluster.Spec.EphemeralStorage),
 		NodeSelector:       operator.GetReplicaAffinity(cluster.Spec.UserLabels, replica.Spec.UserLabels),
+		NodeSelectorLabels: operator.GetNodeSelectorJSON(cluster),
+		Tolerations:        operator.GetTolerationsJSON(cluster),
+		RuntimeClassName:   runtimeClassName,
 		PodAntiAffinity:    operator.GetPodAntiAffinity(cluster, crv1.PodAntiAffinityDeploymentDefault, cluster.Spec.PodAntiAffinity.Default),
-		CollectAddon:       operator.GetCollectAddon(clientset, namespace, &cluster.Spec),
+		CollectAddon:       operator.GetCollectAddon(&cluster.Spec),
 		CollectVolume:      operator.GetCollectVolume(clientset, cluster, namespace),
+		CollectQueries:     operator.GetCollectQueries(&cluster.Spec),
 		BadgerAddon:        operator.GetBadgerAddon(clientset, namespace, cluster, replica.Spec.Name),
-		PgmonitorEnvVars:   operator.GetPgmonitorEnvVars(cluster.Spec.UserLabels[config.LABEL_COLLECT]),
+		PgmonitorEnvVars:   operator.GetPgmonitorEnvVars(&cluster.Spec),
 		ScopeLabel:         config.LABEL_PGHA_SCOPE,
 		PgbackrestEnvVars: operator.GetPgbackrestEnvVars(cluster, cluster.Labels[config.LABEL_BACKREST], replica.Spec.Name,
 			cluster.Spec.Port, cluster.Spec.UserLabels[config.LABEL_BACKREST_STORAGE_TYPE]),
-		PgbackrestS3EnvVars:      operator.GetPgbackrestS3EnvVars(*cluster, clientset, namespace),
-		EnableCrunchyadm:         operator.Pgo.Cluster.EnableCrunchyadm,
-		ReplicaReinitOnStartFail: !operator.Pgo.Cluster.DisableReplicaStartFailReinit,
-		SyncReplication:          operator.GetSyncReplication(cluster.Spec.SyncReplication),
-		Tablespaces:              operator.GetTablespaceNames(cluster.Spec.TablespaceMounts),
-		TablespaceVolumes:        operator.GetTablespaceVolumesJSON(replica.Spec.Name, tablespaceStorageTypeMap),
-		TablespaceVolumeMounts:   operator.GetTablespaceVolumeMountsJSON(tablespaceStorageTypeMap),
-		TLSEnabled:               cluster.Spec.TLS.IsTLSEnabled(),
-		TLSOnly:                  cluster.Spec.TLSOnly,
-		TLSSecret:                cluster.Spec.TLS.TLSSecret,
-		CASecret:                 cluster.Spec.TLS.CASecret,
+		PgbackrestS3EnvVars:           operator.GetPgbackrestS3EnvVars(*cluster, clientset, namespace),
+		EnableCrunchyadm:              operator.Pgo.Cluster.EnableCrunchyadm,
+		ReplicaReinitOnStartFail:      !operator.Pgo.Cluster.DisableReplicaStartFailReinit,
+		SyncReplication:               operator.GetSyncReplication(cluster.Spec.SyncReplication),
+		Tablespaces:                   operator.GetTablespaceNames(cluster.Spec.GetTablespaces()),
+		TablespaceVolumes:             operator.GetTablespaceVolumesJSON(replica.Spec.Name, tablespaceStorageTypeMap),
+		TablespaceVolumeMounts:        operator.GetTablespaceVolumeMountsJSON(tablespaceStorageTypeMap),
+		WALPVCName:                    operator.GetWALPVCName(cluster, replica.Spec.Name),
+		WALDir:                        operator.GetWALDir(cluster, replica.Spec.Name),
+		TLSEnabled:                    cluster.Spec.TLS.IsTLSEnabled(),
+		TLSOnly:                       cluster.Spec.TLSOnly,
+		TLSSecret:                     operator.GetTLSSecretName(cluster),
+		CASecret:                      operator.GetTLSCASecretName(cluster),
+		TLSReplicationSecret:          operator.GetTLSReplicationSecretName(cluster),
+		StandbyReplicationSSLMode:     standbySSLMode,
+		StandbyReplicationSSLRootCert: standbySSLRootCert,
+		StartupGate: operator.GetStartupGateJSON(cluster, fmt.Sprintf("%s/%s:%s",
+			operator.Pgo.Cluster.CCPImagePrefix, image, imageTag)),
 	}
 
 	switch replica.Spec.ReplicaStorage.StorageType {
@@ -381,6 +505,39 @@ func Scale(clientset *kubernetes.Clientset, client *rest.RESTClient, replica *cr
 	// determine if any of the container images need to be overridden
 	operator.OverrideClusterContainerImages(replicaDeployment.Spec.Template.Spec.Containers)
 
+	// mount the configuration of the additional pgBackRest repositories, if there are any
+	operator.AddBackrestReposVolume(cluster, &replicaDeployment.Spec.Template.Spec)
+
+	// mount the credentials of the repository from its secret store, if it has one
+	operator.AddBackrestSecretStoreVolume(cluster, &replicaDeployment.Spec.Template.Spec)
+
+	// spread the instances of the cluster across nodes and zones
+	operator.AddInstancePlacement(cluster, &replicaDeployment.Spec.Template.Spec)
+
+	// apply the node affinity and the PriorityClass of the cluster
+	operator.AddPodScheduling(cluster, nil, &replicaDeployment.Spec.Template.Spec)
+
+	// set the custom labels and annotations of the instances
+	operator.AddCustomMetadata(cluster, &cluster.Spec.Metadata.Postgres, &replicaDeployment.ObjectMeta,
+		&replicaDeployment.Spec.Template.ObjectMeta)
+
+	// set the chargeback labels of the Namespace
+	operator.AddChargebackLabels(clientset, namespace, &replicaDeployment.ObjectMeta,
+		&replicaDeployment.Spec.Template.ObjectMeta)
+
+	// add the containers, init containers and volumes of the spec of the cluster
+	operator.SetInstancePod(cluster, &replicaDeployment.Spec.Template)
+
+	// hold back the Pods behind the scheduling gate of the cluster, if it has one
+	operator.AddSchedulingGate(cluster, fmt.Sprintf("%s/%s:%s", operator.Pgo.Cluster.CCPImagePrefix,
+		image, imageTag), &replicaDeployment.Spec.Template)
+
+	// move the data directory of the primary into place if the volume was created from a
+	// snapshot of it
+	addSnapshotDataInitContainer(clientset, pvcName, replica.Spec.Name, namespace,
+		fmt.Sprintf("%s/%s:%s", operator.Pgo.Cluster.CCPImagePrefix, image, imageTag),
+		&replicaDeployment.Spec.Template.Spec)
+
 	// set the replica scope to the same scope as the primary, i.e. the scope defined using label
 	// 'crunchy-pgha-scope'
 	replicaDeployment.Labels[config.LABEL_PGHA_SCOPE] = cluster.Labels[config.LABEL_PGHA_SCOPE]
@@ -504,33 +661,45 @@ type ScaleClusterInfo struct {
 
 // ShutdownCluster is responsible for shutting down a cluster that is currently running.  This
 // includes changing the replica count for all clusters to 0, and then updating the pgcluster
-// with a shutdown status.
+// with a shutdown status.  The PVCs and Secrets of the cluster are kept, so that it can be
+// started again with StartupCluster.
 func ShutdownCluster(clientset *kubernetes.Clientset, restclient *rest.RESTClient,
 	cluster crv1.Pgcluster) error {
 
 	// first ensure the current primary deployment is properly recorded in the pg cluster.  This
-	// is needed to ensure the cluster can be
+	// is needed to ensure the cluster can be started with the same primary.  A shutdown that is
+	// repeated, e.g. as the update of the pgcluster below is seen again, may no longer find the
+	// primary pod, in which case the primary deployment that was already recorded is kept
 	selector := fmt.Sprintf("%s=%s,%s=%s", config.LABEL_PG_CLUSTER, cluster.Name,
 		config.LABEL_PGHA_ROLE, "master")
 	pods, err := kubeapi.GetPods(clientset, selector, cluster.Namespace)
 	if err != nil {
 		return err
 	}
-	if len(pods.Items) > 1 {
+
+	primaryDeployment := cluster.Annotations[config.ANNOTATION_PRIMARY_DEPLOYMENT]
+	switch {
+	case len(pods.Items) > 1:
 		return fmt.Errorf("Cluster Operator: Invalid number of primary pods (%d) found when "+
 			"shutting down cluster %s", len(pods.Items), cluster.Name)
+	case len(pods.Items) == 1:
+		primaryDeployment = pods.Items[0].Labels[config.LABEL_DEPLOYMENT_NAME]
+	case primaryDeployment == "":
+		return fmt.Errorf("Cluster Operator: No primary pod found when shutting down cluster %s",
+			cluster.Name)
 	}
-	primaryPod := pods.Items[0]
-	if cluster.Annotations == nil {
-		cluster.Annotations = make(map[string]string)
-	}
-	cluster.Annotations[config.ANNOTATION_PRIMARY_DEPLOYMENT] =
-		primaryPod.Labels[config.LABEL_DEPLOYMENT_NAME]
 
-	if err := kubeapi.Updatepgcluster(restclient, &cluster, cluster.Name,
-		cluster.Namespace); err != nil {
-		return fmt.Errorf("Cluster Operator: Unable to update the current primary deployment "+
-			"in the pgcluster when shutting down cluster %s", cluster.Name)
+	if cluster.Annotations[config.ANNOTATION_PRIMARY_DEPLOYMENT] != primaryDeployment {
+		if cluster.Annotations == nil {
+			cluster.Annotations = make(map[string]string)
+		}
+		cluster.Annotations[config.ANNOTATION_PRIMARY_DEPLOYMENT] = primaryDeployment
+
+		if err := kubeapi.Updatepgcluster(restclient, &cluster, cluster.Name,
+			cluster.Namespace); err != nil {
+			return fmt.Errorf("Cluster Operator: Unable to update the current primary deployment "+
+				"in the pgcluster when shutting down cluster %s", cluster.Name)
+		}
 	}
 
 	// disable autofailover to prevent failovers while shutting down deployments
@@ -552,7 +721,8 @@ func ShutdownCluster(clientset *kubernetes.Clientset, restclient *rest.RESTClien
 	}
 
 	if err := kubeapi.DeleteConfigMap(clientset, fmt.Sprintf("%s-leader",
-		cluster.Labels[config.LABEL_PGHA_SCOPE]), cluster.Namespace); err != nil {
+		cluster.Labels[config.LABEL_PGHA_SCOPE]), cluster.Namespace); err != nil &&
+		!kerrors.IsNotFound(err) {
 		return err
 	}
 
@@ -577,7 +747,8 @@ func StartupCluster(clientset *kubernetes.Clientset, cluster crv1.Pgcluster) err
 
 	// Scale up the primary and supporting services, but not the replicas.  Replicas will be
 	// scaled up after the primary is ready.  This ensures the primary at the time of shutdown
-	// is the primary when the cluster comes back online.
+	// is the primary when the cluster comes back online.  pgBouncer is started along with the
+	// replicas, as it has nothing to connect to before then.
 	clusterInfo, err := ScaleClusterDeployments(clientset, cluster, 1, true, false, true)
 	if err != nil {
 		return err
@@ -591,6 +762,19 @@ func StartupCluster(clientset *kubernetes.Clientset, cluster crv1.Pgcluster) err
 	return nil
 }
 
+// StartupPgBouncer scales the pgBouncer Deployments of a cluster that is being started back up
+// to the number of replicas of its pgBouncer, once its primary is ready
+func StartupPgBouncer(clientset *kubernetes.Clientset, restconfig *rest.Config,
+	cluster *crv1.Pgcluster) error {
+	if cluster.Labels[config.LABEL_PGBOUNCER] != "true" {
+		return nil
+	}
+
+	log.Debugf("Cluster Operator: starting pgBouncer of cluster %s", cluster.Name)
+
+	return ReconcilePgBouncerReplicas(clientset, restconfig, cluster)
+}
+
 // ScaleClusterDeployments scales all deployments for a cluster to the number of replicas
 // specified using the 'replicas' parameter.  This is typically used to scale-up or down the
 // primary deployment and any supporting services (pgBackRest and pgBouncer) when shutting down
@@ -622,8 +806,10 @@ func ScaleClusterDeployments(clientset *kubernetes.Clientset, cluster crv1.Pgclu
 			}
 		case deployment.Labels[config.LABEL_PGBOUNCER] == "true":
 			clusterInfo.Services = append(clusterInfo.Services, "pgBouncer")
-			// if not scaling services simply move on to the next deployment
-			if !scaleServices {
+			// if not scaling services simply move on to the next deployment.  pgBouncer is only
+			// scaled down here, and is scaled back up to its number of replicas by
+			// StartupPgBouncer once the primary is ready
+			if !scaleServices || replicas > 0 {
 				continue
 			}
 		case deployment.Labels[config.LABEL_PGO_BACKREST_REPO] == "true":
@@ -0,0 +1,261 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/config"
+	"github.com/crunchydata/postgres-operator/kubeapi"
+	"github.com/crunchydata/postgres-operator/operator/backrest"
+	v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// clusterObservation is what is observed of the parts of a cluster, which its conditions are
+// evaluated from
+type clusterObservation struct {
+	primaryReady    bool
+	replicas        int
+	readyReplicas   int
+	backrestEnabled bool
+	repoReady       bool
+}
+
+// ReconcileConditions observes the primary, the replicas and the pgBackRest repository of a
+// cluster and records the conditions of the cluster in its status if they changed. The time at
+// which a condition last transitioned is kept for as long as its status stays the same
+func ReconcileConditions(clientset *kubernetes.Clientset, restclient *rest.RESTClient, cluster *crv1.Pgcluster) error {
+	observation, err := observeCluster(clientset, restclient, cluster)
+	if err != nil {
+		return err
+	}
+
+	conditions := mergeConditions(cluster.Status.Conditions, evaluateConditions(cluster, observation),
+		meta_v1.Now())
+
+	if reflect.DeepEqual(conditions, cluster.Status.Conditions) {
+		return nil
+	}
+
+	return kubeapi.Patchpgcluster(restclient, cluster, cluster.Namespace, func(cluster *crv1.Pgcluster) {
+		cluster.Status.Conditions = conditions
+	})
+}
+
+// observeCluster observes which of the parts of a cluster are ready
+func observeCluster(clientset *kubernetes.Clientset, restclient *rest.RESTClient, cluster *crv1.Pgcluster) (clusterObservation, error) {
+	observation := clusterObservation{
+		backrestEnabled: cluster.Labels[config.LABEL_BACKREST] == "true",
+	}
+
+	replicas := crv1.PgreplicaList{}
+	selector := fmt.Sprintf("%s=%s", config.LABEL_PG_CLUSTER, cluster.Name)
+
+	if err := kubeapi.GetpgreplicasBySelector(restclient, &replicas, selector, cluster.Namespace); err != nil {
+		return observation, err
+	}
+
+	observation.replicas = len(replicas.Items)
+
+	pods, err := kubeapi.GetPods(clientset, selector, cluster.Namespace)
+	if err != nil {
+		return observation, err
+	}
+
+	for i := range pods.Items {
+		if !isReplicaPodReady(&pods.Items[i]) {
+			continue
+		}
+
+		switch pods.Items[i].Labels[config.LABEL_PGHA_ROLE] {
+		case "master":
+			observation.primaryReady = true
+		case config.LABEL_PGHA_ROLE_REPLICA:
+			observation.readyReplicas++
+		}
+	}
+
+	if observation.backrestEnabled {
+		deployment, found, _ := kubeapi.GetDeployment(clientset,
+			fmt.Sprintf(backrest.BackrestRepoServiceName, cluster.Name), cluster.Namespace)
+		observation.repoReady = found && deployment.Status.ReadyReplicas > 0
+	}
+
+	return observation, nil
+}
+
+// evaluateConditions returns the conditions of a cluster given what is observed of its parts.
+// The cluster is degraded if it is running but any of its parts is not ready
+func evaluateConditions(cluster *crv1.Pgcluster, observation clusterObservation) []crv1.PgclusterCondition {
+	condition := func(conditionType crv1.PgclusterConditionType, status bool, reason, message string) crv1.PgclusterCondition {
+		c := crv1.PgclusterCondition{
+			Type:               conditionType,
+			Status:             v1.ConditionFalse,
+			ObservedGeneration: cluster.Generation,
+			Reason:             reason,
+			Message:            message,
+		}
+		if status {
+			c.Status = v1.ConditionTrue
+		}
+		return c
+	}
+
+	running := cluster.Status.State == crv1.PgclusterStateInitialized
+	shutdown := cluster.Status.State == crv1.PgclusterStateShutdown
+	conditions := []crv1.PgclusterCondition{}
+
+	if running || shutdown {
+		conditions = append(conditions, condition(crv1.PgclusterConditionInitialized, true,
+			"Initialized", "the cluster has been initialized"))
+	} else {
+		conditions = append(conditions, condition(crv1.PgclusterConditionInitialized, false,
+			"NotInitialized", fmt.Sprintf("the cluster is in state %q", cluster.Status.State)))
+	}
+
+	switch {
+	case shutdown:
+		conditions = append(conditions, condition(crv1.PgclusterConditionPrimaryReady, false,
+			"Shutdown", "the cluster is shut down"))
+	case observation.primaryReady:
+		conditions = append(conditions, condition(crv1.PgclusterConditionPrimaryReady, true,
+			"PrimaryReady", "the primary is ready"))
+	default:
+		conditions = append(conditions, condition(crv1.PgclusterConditionPrimaryReady, false,
+			"PrimaryNotReady", "the primary is not ready"))
+	}
+
+	replicasMessage := fmt.Sprintf("%d of %d replicas are ready", observation.readyReplicas,
+		observation.replicas)
+
+	switch {
+	case observation.replicas == 0:
+		conditions = append(conditions, condition(crv1.PgclusterConditionReplicasReady, true,
+			"NoReplicas", "the cluster has no replicas"))
+	case shutdown:
+		conditions = append(conditions, condition(crv1.PgclusterConditionReplicasReady, false,
+			"Shutdown", "the cluster is shut down"))
+	case observation.readyReplicas >= observation.replicas:
+		conditions = append(conditions, condition(crv1.PgclusterConditionReplicasReady, true,
+			"ReplicasReady", replicasMessage))
+	default:
+		conditions = append(conditions, condition(crv1.PgclusterConditionReplicasReady, false,
+			"ReplicasNotReady", replicasMessage))
+	}
+
+	switch {
+	case !observation.backrestEnabled:
+		conditions = append(conditions, condition(crv1.PgclusterConditionBackupRepoReady, false,
+			"NotEnabled", "pgBackRest is not enabled for the cluster"))
+	case observation.repoReady:
+		conditions = append(conditions, condition(crv1.PgclusterConditionBackupRepoReady, true,
+			"RepoReady", "the pgBackRest repository is ready"))
+	default:
+		conditions = append(conditions, condition(crv1.PgclusterConditionBackupRepoReady, false,
+			"RepoNotReady", "the pgBackRest repository is not ready"))
+	}
+
+	// the verification of the repository is opt-in, so its condition is only there once it is
+	// enabled
+	if cluster.Spec.BackrestVerification.Enabled {
+		verification := cluster.Status.BackrestVerification
+
+		switch {
+		case verification.LastVerified == "":
+			conditions = append(conditions, condition(crv1.PgclusterConditionLastBackupVerified, false,
+				"NotVerified", "the pgBackRest repository has not been verified yet"))
+		case verification.Succeeded:
+			conditions = append(conditions, condition(crv1.PgclusterConditionLastBackupVerified, true,
+				"Verified", "the pgBackRest repository was verified at "+verification.LastVerified))
+		default:
+			conditions = append(conditions, condition(crv1.PgclusterConditionLastBackupVerified, false,
+				"VerificationFailed", verification.Message))
+		}
+	}
+
+	// the condition of the update to the image tag is only there once the Operator has rolled
+	// out an image tag
+	if upgrade := cluster.Status.MinorUpgrade; upgrade.ImageTag != "" {
+		message := fmt.Sprintf("%d of %d instances are updated to %s", len(upgrade.UpdatedInstances),
+			upgrade.Instances, upgrade.ImageTag)
+
+		switch {
+		case upgrade.InProgress:
+			conditions = append(conditions, condition(crv1.PgclusterConditionUpgrading, true,
+				"Upgrading", message))
+		case upgrade.Message != "":
+			conditions = append(conditions, condition(crv1.PgclusterConditionUpgrading, false,
+				"UpgradeFailed", upgrade.Message))
+		default:
+			conditions = append(conditions, condition(crv1.PgclusterConditionUpgrading, false,
+				"UpToDate", message))
+		}
+	}
+
+	if !running {
+		conditions = append(conditions, condition(crv1.PgclusterConditionDegraded, false,
+			"NotRunning", "the cluster is not running"))
+		return conditions
+	}
+
+	missing := []string{}
+	if !observation.primaryReady {
+		missing = append(missing, "the primary")
+	}
+	if observation.readyReplicas < observation.replicas {
+		missing = append(missing, replicasMessage)
+	}
+	if observation.backrestEnabled && !observation.repoReady {
+		missing = append(missing, "the pgBackRest repository")
+	}
+
+	if len(missing) > 0 {
+		conditions = append(conditions, condition(crv1.PgclusterConditionDegraded, true,
+			"Degraded", "not ready: "+strings.Join(missing, ", ")))
+	} else {
+		conditions = append(conditions, condition(crv1.PgclusterConditionDegraded, false,
+			"Healthy", "all parts of the cluster are ready"))
+	}
+
+	return conditions
+}
+
+// mergeConditions returns the conditions observed, keeping the time at which each last
+// transitioned from the existing conditions if its status did not change, and setting it to
+// now otherwise
+func mergeConditions(existing, observed []crv1.PgclusterCondition, now meta_v1.Time) []crv1.PgclusterCondition {
+	previous := map[crv1.PgclusterConditionType]crv1.PgclusterCondition{}
+	for _, condition := range existing {
+		previous[condition.Type] = condition
+	}
+
+	conditions := make([]crv1.PgclusterCondition, 0, len(observed))
+	for _, condition := range observed {
+		condition.LastTransitionTime = now
+		if p, ok := previous[condition.Type]; ok && p.Status == condition.Status {
+			condition.LastTransitionTime = p.LastTransitionTime
+		}
+		conditions = append(conditions, condition)
+	}
+
+	return conditions
+}
@@ -0,0 +1,136 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"testing"
+	"time"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// conditionStatus returns the status and reason of a condition by its type
+func conditionStatus(conditions []crv1.PgclusterCondition, conditionType crv1.PgclusterConditionType) (v1.ConditionStatus, string) {
+	for _, condition := range conditions {
+		if condition.Type == conditionType {
+			return condition.Status, condition.Reason
+		}
+	}
+	return "", ""
+}
+
+func TestEvaluateConditions(t *testing.T) {
+	healthy := clusterObservation{primaryReady: true, replicas: 2, readyReplicas: 2,
+		backrestEnabled: true, repoReady: true}
+
+	tests := []struct {
+		name        string
+		state       crv1.PgclusterState
+		observation clusterObservation
+		expected    map[crv1.PgclusterConditionType]v1.ConditionStatus
+		reason      string
+	}{
+		{"healthy", crv1.PgclusterStateInitialized, healthy,
+			map[crv1.PgclusterConditionType]v1.ConditionStatus{
+				crv1.PgclusterConditionInitialized:     v1.ConditionTrue,
+				crv1.PgclusterConditionPrimaryReady:    v1.ConditionTrue,
+				crv1.PgclusterConditionReplicasReady:   v1.ConditionTrue,
+				crv1.PgclusterConditionBackupRepoReady: v1.ConditionTrue,
+				crv1.PgclusterConditionDegraded:        v1.ConditionFalse,
+			}, "Healthy"},
+		{"replica not ready", crv1.PgclusterStateInitialized,
+			clusterObservation{primaryReady: true, replicas: 2, readyReplicas: 1, backrestEnabled: true, repoReady: true},
+			map[crv1.PgclusterConditionType]v1.ConditionStatus{
+				crv1.PgclusterConditionPrimaryReady:  v1.ConditionTrue,
+				crv1.PgclusterConditionReplicasReady: v1.ConditionFalse,
+				crv1.PgclusterConditionDegraded:      v1.ConditionTrue,
+			}, "Degraded"},
+		{"being created", crv1.PgclusterStateProcessed, clusterObservation{},
+			map[crv1.PgclusterConditionType]v1.ConditionStatus{
+				crv1.PgclusterConditionInitialized:  v1.ConditionFalse,
+				crv1.PgclusterConditionPrimaryReady: v1.ConditionFalse,
+				crv1.PgclusterConditionDegraded:     v1.ConditionFalse,
+			}, "NotRunning"},
+		{"shut down", crv1.PgclusterStateShutdown, clusterObservation{replicas: 1, backrestEnabled: true},
+			map[crv1.PgclusterConditionType]v1.ConditionStatus{
+				crv1.PgclusterConditionInitialized:   v1.ConditionTrue,
+				crv1.PgclusterConditionPrimaryReady:  v1.ConditionFalse,
+				crv1.PgclusterConditionReplicasReady: v1.ConditionFalse,
+				crv1.PgclusterConditionDegraded:      v1.ConditionFalse,
+			}, "NotRunning"},
+		{"without pgBackRest", crv1.PgclusterStateInitialized,
+			clusterObservation{primaryReady: true},
+			map[crv1.PgclusterConditionType]v1.ConditionStatus{
+				crv1.PgclusterConditionReplicasReady:   v1.ConditionTrue,
+				crv1.PgclusterConditionBackupRepoReady: v1.ConditionFalse,
+				crv1.PgclusterConditionDegraded:        v1.ConditionFalse,
+			}, "Healthy"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cluster := &crv1.Pgcluster{}
+			cluster.Generation = 3
+			cluster.Status.State = test.state
+
+			conditions := evaluateConditions(cluster, test.observation)
+
+			if len(conditions) != 5 {
+				t.Fatalf("expected five conditions, got %d", len(conditions))
+			}
+			for conditionType, expected := range test.expected {
+				if status, _ := conditionStatus(conditions, conditionType); status != expected {
+					t.Errorf("expected %s to be %s, got %s", conditionType, expected, status)
+				}
+			}
+			if _, reason := conditionStatus(conditions, crv1.PgclusterConditionDegraded); reason != test.reason {
+				t.Errorf("expected Degraded for reason %q, got %q", test.reason, reason)
+			}
+			if conditions[0].ObservedGeneration != 3 {
+				t.Errorf("expected the generation to be observed, got %d", conditions[0].ObservedGeneration)
+			}
+		})
+	}
+}
+
+func TestMergeConditions(t *testing.T) {
+	before := meta_v1.NewTime(time.Date(2020, 4, 1, 0, 0, 0, 0, time.UTC))
+	now := meta_v1.NewTime(before.Add(time.Hour))
+
+	existing := []crv1.PgclusterCondition{
+		{Type: crv1.PgclusterConditionPrimaryReady, Status: v1.ConditionTrue, LastTransitionTime: before},
+		{Type: crv1.PgclusterConditionDegraded, Status: v1.ConditionFalse, LastTransitionTime: before},
+	}
+	observed := []crv1.PgclusterCondition{
+		{Type: crv1.PgclusterConditionPrimaryReady, Status: v1.ConditionTrue, Reason: "PrimaryReady"},
+		{Type: crv1.PgclusterConditionDegraded, Status: v1.ConditionTrue, Reason: "Degraded"},
+		{Type: crv1.PgclusterConditionInitialized, Status: v1.ConditionTrue},
+	}
+
+	conditions := mergeConditions(existing, observed, now)
+
+	if !conditions[0].LastTransitionTime.Equal(&before) || conditions[0].Reason != "PrimaryReady" {
+		t.Errorf("expected an unchanged condition to keep its transition time, got %+v", conditions[0])
+	}
+	if !conditions[1].LastTransitionTime.Equal(&now) {
+		t.Errorf("expected a changed condition to transition now, got %+v", conditions[1])
+	}
+	if !conditions[2].LastTransitionTime.Equal(&now) {
+		t.Errorf("expected a new condition to transition now, got %+v", conditions[2])
+	}
+}
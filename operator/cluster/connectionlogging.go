@@ -0,0 +1,175 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"fmt"
+	"time"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/config"
+	"github.com/crunchydata/postgres-operator/kubeapi"
+	"github.com/crunchydata/postgres-operator/operator"
+	log "github.com/sirupsen/logrus"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	// maxConnectionLoggingDuration is the longest that the connections to a cluster are logged
+	// for, as the logging is verbose and is not meant to be left on
+	maxConnectionLoggingDuration = 24 * time.Hour
+
+	// eventReasonInvalidConnectionLogging is the reason of the Warning event that is recorded
+	// when the connection logging annotation of a cluster cannot be parsed
+	eventReasonInvalidConnectionLogging = "InvalidConnectionLogging"
+
+	// connectionLoggingParameterConnections and connectionLoggingParameterDisconnections are
+	// the parameters that are turned on while the connections are logged. Both of them are
+	// reloadable, so Patroni applies them without restarting PostgreSQL
+	connectionLoggingParameterConnections    = "log_connections"
+	connectionLoggingParameterDisconnections = "log_disconnections"
+)
+
+// ReconcileConnectionLogging turns the logging of the connections to a cluster on or off based on
+// its "connection-logging-until" annotation, which holds the time in RFC3339 format at which the
+// logging is to be turned off again. The time is capped to 24 hours from now. While the logging
+// is on, the time it ends is kept in the status of the cluster, along with the values the
+// parameters had before, which they are reverted to once the annotation is removed, or once the
+// time has passed, see ExpireConnectionLogging
+func ReconcileConnectionLogging(clientset *kubernetes.Clientset, restclient *rest.RESTClient,
+	restconfig *rest.Config, cluster *crv1.Pgcluster) error {
+	status := cluster.Status.ConnectionLogging
+	now := time.Now()
+
+	until, err := getConnectionLoggingUntil(cluster, now)
+	if err != nil {
+		operator.RecordWarningEvent(clientset, cluster, eventReasonInvalidConnectionLogging, err.Error())
+	}
+
+	// the primary is looked up by the namespace in the spec
+	cluster.Spec.Namespace = cluster.Namespace
+
+	if !until.After(now) {
+		return revertConnectionLogging(clientset, restclient, restconfig, cluster)
+	}
+
+	if status.ActiveUntil == "" {
+		parameters, err := GetPostgreSQLParameters(clientset, cluster)
+		if err != nil {
+			return err
+		}
+
+		status.LogConnections = parameters[connectionLoggingParameterConnections]
+		status.LogDisconnections = parameters[connectionLoggingParameterDisconnections]
+	}
+
+	activeUntil := until.UTC().Format(time.RFC3339)
+	if activeUntil == status.ActiveUntil {
+		return nil
+	}
+
+	log.Infof("logging the connections to cluster %s until %s", cluster.Name, activeUntil)
+
+	if _, err := UpdatePostgreSQLParameters(clientset, restconfig, cluster, map[string]string{
+		connectionLoggingParameterConnections:    "on",
+		connectionLoggingParameterDisconnections: "on",
+	}); err != nil {
+		return err
+	}
+
+	status.ActiveUntil = activeUntil
+
+	return kubeapi.Patchpgcluster(restclient, cluster, cluster.Namespace, func(cluster *crv1.Pgcluster) {
+		cluster.Status.ConnectionLogging = status
+	})
+}
+
+// ExpireConnectionLogging turns the logging of the connections to a cluster off once the time it
+// was turned on until has passed
+func ExpireConnectionLogging(clientset *kubernetes.Clientset, restclient *rest.RESTClient,
+	restconfig *rest.Config, cluster *crv1.Pgcluster) error {
+	if cluster.Status.ConnectionLogging.ActiveUntil == "" {
+		return nil
+	}
+
+	activeUntil, err := time.Parse(time.RFC3339, cluster.Status.ConnectionLogging.ActiveUntil)
+	if err == nil && time.Now().Before(activeUntil) {
+		return nil
+	}
+
+	// the primary is looked up by the namespace in the spec
+	cluster.Spec.Namespace = cluster.Namespace
+
+	return revertConnectionLogging(clientset, restclient, restconfig, cluster)
+}
+
+// revertConnectionLogging reverts the connection logging parameters of a cluster to the values
+// they had before the logging was turned on, if it is on, and clears the state of the logging
+// from the status of the cluster
+func revertConnectionLogging(clientset *kubernetes.Clientset, restclient *rest.RESTClient,
+	restconfig *rest.Config, cluster *crv1.Pgcluster) error {
+	status := cluster.Status.ConnectionLogging
+
+	if status.ActiveUntil == "" {
+		return nil
+	}
+
+	log.Infof("no longer logging the connections to cluster %s", cluster.Name)
+
+	if _, err := UpdatePostgreSQLParameters(clientset, restconfig, cluster, map[string]string{
+		connectionLoggingParameterConnections:    defaultConnectionLoggingValue(status.LogConnections),
+		connectionLoggingParameterDisconnections: defaultConnectionLoggingValue(status.LogDisconnections),
+	}); err != nil {
+		return err
+	}
+
+	return kubeapi.Patchpgcluster(restclient, cluster, cluster.Namespace, func(cluster *crv1.Pgcluster) {
+		cluster.Status.ConnectionLogging = crv1.ConnectionLoggingStatus{}
+	})
+}
+
+// getConnectionLoggingUntil returns the time until which the connections to a cluster are to be
+// logged, capped to the longest time the logging is allowed to be on. The zero time is returned
+// if the connections are not to be logged, including when the annotation cannot be parsed
+func getConnectionLoggingUntil(cluster *crv1.Pgcluster, now time.Time) (time.Time, error) {
+	value, ok := cluster.Annotations[config.ANNOTATION_CONNECTION_LOGGING_UNTIL]
+	if !ok || value == "" {
+		return time.Time{}, nil
+	}
+
+	until, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("annotation %s of cluster %s is not an RFC3339 time: %s",
+			config.ANNOTATION_CONNECTION_LOGGING_UNTIL, cluster.Name, value)
+	}
+
+	if max := now.Add(maxConnectionLoggingDuration); until.After(max) {
+		until = max.Truncate(time.Second)
+	}
+
+	return until, nil
+}
+
+// defaultConnectionLoggingValue returns the value a connection logging parameter is reverted to,
+// which is the PostgreSQL default if the parameter was not set before the logging was turned on
+func defaultConnectionLoggingValue(value string) string {
+	if value == "" {
+		return "off"
+	}
+
+	return value
+}
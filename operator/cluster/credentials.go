@@ -0,0 +1,86 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/config"
+	"github.com/crunchydata/postgres-operator/operator"
+	log "github.com/sirupsen/logrus"
+)
+
+// CreateClusterCredentials ensures that the credentials a cluster needs before its instances
+// can be created are in the credential store. The credentials of the PostgreSQL users are
+// created along with the cluster by the apiserver and are only checked for, whereas the
+// credential of the collect user is created here if collection is enabled. If the store cannot
+// be reached, a CredentialStoreUnavailableError is returned so that the cluster can be added
+// once the store is available again
+func CreateClusterCredentials(store operator.CredentialStore, cluster *crv1.Pgcluster) error {
+	namespace := cluster.ObjectMeta.Namespace
+
+	for _, name := range []string{cluster.Spec.RootSecretName, cluster.Spec.PrimarySecretName,
+		cluster.Spec.UserSecretName} {
+		if name == "" {
+			continue
+		}
+
+		if _, err := store.Get(namespace, name); operator.IsCredentialStoreUnavailable(err) {
+			return err
+		} else if err != nil {
+			// a missing credential is reported, but does not stop the cluster from being
+			// added, as the instances wait for it
+			log.Errorf("cluster %s: %s", cluster.Name, err)
+		}
+	}
+
+	// a standby cluster that streams the WAL of a remote primary replicates as its replication
+	// user, which has to be in place before the instances start
+	if err := setStandbyReplicationCredential(store, cluster); operator.IsCredentialStoreUnavailable(err) {
+		return err
+	} else if err != nil {
+		log.Errorf("cluster %s: %s", cluster.Name, err)
+	}
+
+	if !operator.IsExporterEnabled(&cluster.Spec) {
+		return nil
+	}
+
+	_, err := setExporterCredential(store, cluster)
+
+	return err
+}
+
+// setExporterCredential creates the credential of the collect user that the exporter logs in
+// with, and returns it. The credential is kept as is if it already exists, e.g. as the cluster
+// is a clone
+func setExporterCredential(store operator.CredentialStore, cluster *crv1.Pgcluster) (operator.Credential, error) {
+	name := operator.GetExporterSecretName(cluster)
+
+	credential, err := store.Get(cluster.Namespace, name)
+	if !operator.IsCredentialNotFound(err) {
+		return credential, err
+	}
+
+	log.Debugf("creating collect secret for cluster %s", cluster.Name)
+
+	credential = operator.Credential{
+		ClusterName: cluster.Spec.Name,
+		Username:    config.LABEL_COLLECT_PG_USER,
+		Password:    operator.Pgo.Cluster.PgmonitorPassword,
+	}
+
+	return credential, store.Set(cluster.Namespace, name, credential)
+}
@@ -0,0 +1,105 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"errors"
+	"testing"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/config"
+	"github.com/crunchydata/postgres-operator/operator"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// fakeCredentialStore is a credential store that keeps credentials in memory and can be made
+// unavailable
+type fakeCredentialStore struct {
+	credentials map[string]operator.Credential
+	unavailable bool
+}
+
+func (s *fakeCredentialStore) Get(namespace, name string) (operator.Credential, error) {
+	if s.unavailable {
+		return operator.Credential{}, operator.CredentialStoreUnavailableError{Err: errors.New("timeout")}
+	}
+
+	credential, ok := s.credentials[namespace+"/"+name]
+	if !ok {
+		return operator.Credential{}, operator.CredentialNotFoundError{Namespace: namespace, Name: name}
+	}
+
+	return credential, nil
+}
+
+func (s *fakeCredentialStore) Set(namespace, name string, credential operator.Credential) error {
+	if s.unavailable {
+		return operator.CredentialStoreUnavailableError{Err: errors.New("timeout")}
+	}
+
+	s.credentials[namespace+"/"+name] = credential
+
+	return nil
+}
+
+func (s *fakeCredentialStore) Rotate(namespace, name, password string) (operator.Credential, error) {
+	return operator.Credential{}, errors.New("not implemented")
+}
+
+func TestCreateClusterCredentials(t *testing.T) {
+	cluster := &crv1.Pgcluster{
+		ObjectMeta: meta_v1.ObjectMeta{Name: "hippo", Namespace: "pgo"},
+		Spec: crv1.PgclusterSpec{
+			Name:              "hippo",
+			RootSecretName:    "hippo-postgres-secret",
+			CollectSecretName: "hippo-collect-secret",
+			UserLabels:        map[string]string{config.LABEL_COLLECT: "true"},
+		},
+	}
+
+	store := &fakeCredentialStore{credentials: map[string]operator.Credential{
+		"pgo/hippo-postgres-secret": {ClusterName: "hippo", Username: "postgres", Password: "a"},
+	}}
+
+	// while the store is unavailable, the error is returned so the cluster is requeued
+	store.unavailable = true
+
+	if err := CreateClusterCredentials(store, cluster); !operator.IsCredentialStoreUnavailable(err) {
+		t.Fatalf("expected the store to be unavailable, got %v", err)
+	}
+
+	store.unavailable = false
+
+	if err := CreateClusterCredentials(store, cluster); err != nil {
+		t.Fatal(err)
+	}
+
+	collect, ok := store.credentials["pgo/hippo-collect-secret"]
+	if !ok || collect.Username != config.LABEL_COLLECT_PG_USER || collect.ClusterName != "hippo" {
+		t.Fatalf("expected collect credential to be created, got %v", collect)
+	}
+
+	// an existing collect credential is kept
+	store.credentials["pgo/hippo-collect-secret"] = operator.Credential{Username: "ccp_monitoring", Password: "kept"}
+
+	if err := CreateClusterCredentials(store, cluster); err != nil {
+		t.Fatal(err)
+	}
+
+	if store.credentials["pgo/hippo-collect-secret"].Password != "kept" {
+		t.Fatal("expected existing collect credential to be kept")
+	}
+}
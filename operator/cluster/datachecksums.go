@@ -0,0 +1,785 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/config"
+	"github.com/crunchydata/postgres-operator/kubeapi"
+	"github.com/crunchydata/postgres-operator/operator"
+	"github.com/crunchydata/postgres-operator/util"
+	log "github.com/sirupsen/logrus"
+	apps_v1 "k8s.io/api/apps/v1"
+	batch_v1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	// the name of the Job that enables the data checksums of an instance
+	dataChecksumsJobName = "%s-data-checksums"
+	// the name of the container that runs pg_checksums
+	dataChecksumsContainerName = "data-checksums"
+	// the number of log lines from a failed Job that are stored in the status
+	// of the pgtask
+	dataChecksumsLogLines = 20
+	// dataChecksumsMinVersion is the earliest version of PostgreSQL that can
+	// enable data checksums on an existing data directory
+	dataChecksumsMinVersion = 12
+	// how long to wait, in seconds, for an instance to stop, start, or to take
+	// over as the primary
+	dataChecksumsTimeout = 600
+	dataChecksumsPeriod  = 5
+)
+
+// dataChecksumsRunning holds the "enable-data-checksums" pgtasks that have an
+// instance being stopped or started, so that a pgtask that is in progress is
+// only resumed if it is not running already
+var dataChecksumsRunning sync.Map
+
+// sqlDataChecksums returns "on" or "off" depending on whether or not the data
+// checksums are enabled on the instance
+const sqlDataChecksums = `SHOW data_checksums;`
+
+// dataChecksumsScript is run against a data directory of an instance that has
+// been cleanly shut down. If the checksums were already enabled, e.g. by an
+// earlier attempt of the same pgtask, there is nothing left to do
+const dataChecksumsScript = `set -e
+if pg_controldata "${PGDATA}" | grep -Eq '^Data page checksum version:[[:space:]]+[1-9]'; then
+  echo "data checksums are already enabled in ${PGDATA}"
+  exit 0
+fi
+pg_checksums --enable --progress --pgdata="${PGDATA}"
+`
+
+// AddEnableDataChecksums handles an "enable-data-checksums" pgtask, which runs
+// pg_checksums against the data directory of each instance of the cluster, one
+// instance at a time. As pg_checksums requires the instance to be stopped, the
+// task must explicitly choose a strategy: either "offline", where the cluster
+// has already been shut down, or "replica-first", where each replica is
+// stopped in turn and the primary is only processed once it has been switched
+// over to a replica that already has checksums enabled
+func AddEnableDataChecksums(clientset *kubernetes.Clientset, restclient *rest.RESTClient, restconfig *rest.Config,
+	t *crv1.Pgtask, namespace string) {
+	clusterName := t.ObjectMeta.Labels[config.LABEL_PG_CLUSTER]
+
+	cluster := crv1.Pgcluster{}
+	if _, err := kubeapi.Getpgcluster(restclient, &cluster, clusterName, namespace); err != nil {
+		log.Error("could not find pgcluster for enabling data checksums")
+		log.Error(err)
+		return
+	}
+
+	// get the latest version of the task in case it changed
+	task := crv1.Pgtask{}
+	if found, err := kubeapi.Getpgtask(restclient, &task, t.Spec.Name, namespace); !found {
+		log.Error("could not find pgtask for enabling data checksums")
+		log.Error(err)
+		return
+	}
+
+	// the primary is looked up by the namespace in the spec
+	cluster.Spec.Namespace = namespace
+
+	// have a guard -- if the task is over or is being carried out, don't proceed. A task that is
+	// in progress but not running was underway when the Operator stopped, and is resumed from the
+	// instance it was working on
+	key := namespace + "/" + task.Spec.Name
+
+	switch task.Spec.Status {
+	case crv1.PgtaskDataChecksumsCompleted, crv1.PgtaskDataChecksumsFailed:
+		log.Warnf("pgtask [%s] has already been processed", task.Spec.Name)
+		return
+	case crv1.PgtaskDataChecksumsInProgress:
+		if _, running := dataChecksumsRunning.Load(key); running {
+			log.Warnf("pgtask [%s] is already running", task.Spec.Name)
+			return
+		}
+
+		log.Infof("data checksums: resuming pgtask %s after instances %v", task.Spec.Name,
+			getDataChecksumsCompletedInstances(&task))
+
+		resumeEnableDataChecksums(clientset, restclient, restconfig, cluster, &task, namespace)
+		return
+	}
+
+	if task.Spec.Parameters == nil {
+		task.Spec.Parameters = make(map[string]string)
+	}
+
+	if err := validateEnableDataChecksums(clientset, &cluster, &task); err != nil {
+		log.Errorf("data checksums: not enabling on cluster %s: %s", clusterName, err)
+		failEnableDataChecksums(clientset, restclient, &cluster, &task, "", err.Error())
+		return
+	}
+
+	task.Spec.Status = crv1.PgtaskDataChecksumsInProgress
+
+	if err := kubeapi.Updatepgtask(restclient, &task, task.Spec.Name, namespace); err != nil {
+		log.Error("error in updating data checksums pgtask status " + err.Error())
+		return
+	}
+
+	// stopping an instance and waiting for it can take a while, so this is done
+	// in the background so as to not hold up the processing of other pgtasks
+	runDataChecksums(key, func() {
+		enableNextDataChecksumsInstance(clientset, restclient, restconfig, cluster, task.Spec.Name, namespace)
+	})
+}
+
+// UpdateEnableDataChecksums is called when the Job running pg_checksums against
+// an instance has completed, successfully or not. On success the instance is
+// recorded as done and the next instance is started on; on failure the pgtask
+// is marked as failed and no further instances are modified
+func UpdateEnableDataChecksums(clientset *kubernetes.Clientset, restclient *rest.RESTClient, restconfig *rest.Config,
+	job *batch_v1.Job, succeeded bool) error {
+	namespace := job.Namespace
+	clusterName := job.ObjectMeta.Labels[config.LABEL_PG_CLUSTER]
+	taskName := job.ObjectMeta.Labels[config.LABEL_PGTASK]
+
+	cluster := crv1.Pgcluster{}
+	if _, err := kubeapi.Getpgcluster(restclient, &cluster, clusterName, namespace); err != nil {
+		log.Error(err)
+		return err
+	}
+
+	task := crv1.Pgtask{}
+	if found, err := kubeapi.Getpgtask(restclient, &task, taskName, namespace); !found {
+		log.Error(err)
+		return err
+	}
+
+	// guard against processing the same Job completion more than once
+	instance := task.Spec.Parameters[crv1.PgtaskDataChecksumsCurrentInstance]
+	if task.Spec.Status != crv1.PgtaskDataChecksumsInProgress || job.Name != fmt.Sprintf(dataChecksumsJobName, instance) {
+		log.Debugf("data checksums: job %s for task %s already processed", job.Name, taskName)
+		return nil
+	}
+
+	cluster.Spec.Namespace = namespace
+
+	if !succeeded {
+		message := fmt.Sprintf("pg_checksums failed on instance %s", instance)
+		if output, err := getDataChecksumsJobOutput(clientset, job); err != nil {
+			log.Warn(err)
+		} else if output != "" {
+			message = fmt.Sprintf("%s: %s", message, output)
+		}
+
+		failEnableDataChecksums(clientset, restclient, &cluster, &task, instance, message)
+		return nil
+	}
+
+	log.Debugf("data checksums: enabled on instance %s of cluster %s", instance, clusterName)
+
+	completed := getDataChecksumsCompletedInstances(&task)
+	completed = append(completed, instance)
+
+	task.Spec.Parameters[crv1.PgtaskDataChecksumsCompletedInstances] = strings.Join(completed, ",")
+	task.Spec.Parameters[crv1.PgtaskDataChecksumsCurrentInstance] = ""
+
+	if err := kubeapi.Updatepgtask(restclient, &task, task.Spec.Name, namespace); err != nil {
+		log.Error("error in updating data checksums pgtask " + err.Error())
+		return err
+	}
+
+	runDataChecksums(namespace+"/"+task.Spec.Name, func() {
+		// the instance is brought back before the next one is stopped, so at
+		// most one instance is ever unavailable at a time
+		if task.Spec.Parameters[crv1.PgtaskDataChecksumsStrategy] == crv1.PgtaskDataChecksumsStrategyReplicaFirst {
+			if err := startDataChecksumsInstance(clientset, instance, namespace); err != nil {
+				failEnableDataChecksums(clientset, restclient, &cluster, &task, instance, err.Error())
+				return
+			}
+		}
+
+		enableNextDataChecksumsInstance(clientset, restclient, restconfig, cluster, task.Spec.Name, namespace)
+	})
+
+	return nil
+}
+
+// resumeEnableDataChecksums carries on with an "enable-data-checksums" pgtask
+// that was in progress when the Operator stopped. The Job of the instance that
+// was being worked on is handled as if it had just finished, or is left to the
+// Job controller if it is still running. If the Job was never created, the
+// instance is worked on from the start. Otherwise the instance that was done
+// last is brought back, as it may not have been, before moving on to the next
+func resumeEnableDataChecksums(clientset *kubernetes.Clientset, restclient *rest.RESTClient, restconfig *rest.Config,
+	cluster crv1.Pgcluster, task *crv1.Pgtask, namespace string) {
+	key := namespace + "/" + task.Spec.Name
+	instance := task.Spec.Parameters[crv1.PgtaskDataChecksumsCurrentInstance]
+
+	if instance != "" {
+		job, found := kubeapi.GetJob(clientset, fmt.Sprintf(dataChecksumsJobName, instance), namespace)
+		if found {
+			succeeded := job.Status.CompletionTime != nil
+			if !succeeded && !isDataChecksumsJobFailed(job) {
+				return
+			}
+
+			if err := UpdateEnableDataChecksums(clientset, restclient, restconfig, job, succeeded); err != nil {
+				log.Error(err)
+			}
+			return
+		}
+
+		runDataChecksums(key, func() {
+			enableNextDataChecksumsInstance(clientset, restclient, restconfig, cluster, task.Spec.Name, namespace)
+		})
+		return
+	}
+
+	completed := getDataChecksumsCompletedInstances(task)
+
+	runDataChecksums(key, func() {
+		if len(completed) > 0 &&
+			task.Spec.Parameters[crv1.PgtaskDataChecksumsStrategy] == crv1.PgtaskDataChecksumsStrategyReplicaFirst {
+			last := completed[len(completed)-1]
+
+			if err := startDataChecksumsInstance(clientset, last, namespace); err != nil {
+				failEnableDataChecksums(clientset, restclient, &cluster, task, last, err.Error())
+				return
+			}
+		}
+
+		enableNextDataChecksumsInstance(clientset, restclient, restconfig, cluster, task.Spec.Name, namespace)
+	})
+}
+
+// runDataChecksums runs the part of an "enable-data-checksums" pgtask that
+// stops or starts an instance in the background, recording that the pgtask is
+// running until it returns
+func runDataChecksums(key string, f func()) {
+	dataChecksumsRunning.Store(key, true)
+
+	go func() {
+		defer dataChecksumsRunning.Delete(key)
+
+		f()
+	}()
+}
+
+// isDataChecksumsJobFailed returns whether the Job running pg_checksums
+// against an instance failed
+func isDataChecksumsJobFailed(job *batch_v1.Job) bool {
+	for _, condition := range job.Status.Conditions {
+		if condition.Type == batch_v1.JobFailed && condition.Status == v1.ConditionTrue {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ReconcileDataChecksums records whether or not the data checksums are enabled
+// on the primary in the status of the cluster. If the cluster is expected to
+// have data checksums but does not, e.g. because it was created before they
+// could be requested, a warning is recorded with how they can be enabled
+func ReconcileDataChecksums(clientset *kubernetes.Clientset, restclient *rest.RESTClient, restconfig *rest.Config,
+	cluster *crv1.Pgcluster) error {
+	// the primary is looked up by the namespace in the spec
+	cluster.Spec.Namespace = cluster.Namespace
+
+	pod, err := util.GetPrimaryPod(clientset, cluster)
+	if err != nil {
+		return err
+	}
+
+	status, err := execMaintenanceSQL(clientset, restconfig, pod, "postgres", sqlDataChecksums)
+	if err != nil {
+		return err
+	}
+
+	if err := kubeapi.Patchpgcluster(restclient, cluster, cluster.Namespace, func(cluster *crv1.Pgcluster) {
+		cluster.Status.DataChecksums = status
+	}); err != nil {
+		return err
+	}
+
+	if cluster.Spec.DataChecksums && status != "on" {
+		operator.RecordWarningEvent(clientset, cluster, "DataChecksumsDisabled",
+			fmt.Sprintf("data checksums are not enabled on cluster %s; they can be enabled with an %q pgtask",
+				cluster.Name, crv1.PgtaskEnableDataChecksums))
+	}
+
+	return nil
+}
+
+// validateEnableDataChecksums ensures that the data checksums can be enabled
+// on the cluster with the strategy that was chosen by the pgtask
+func validateEnableDataChecksums(clientset *kubernetes.Clientset, cluster *crv1.Pgcluster, task *crv1.Pgtask) error {
+	if version, err := strconv.ParseFloat(getPGMajorVersion(cluster.Spec.CCPImageTag), 64); err == nil &&
+		version < dataChecksumsMinVersion {
+		return fmt.Errorf("enabling data checksums requires PostgreSQL %d or later", dataChecksumsMinVersion)
+	}
+
+	switch task.Spec.Parameters[crv1.PgtaskDataChecksumsStrategy] {
+	case crv1.PgtaskDataChecksumsStrategyOffline:
+		if !cluster.Spec.Shutdown || cluster.Status.State != crv1.PgclusterStateShutdown {
+			return fmt.Errorf("the %q strategy requires cluster %s to be shut down",
+				crv1.PgtaskDataChecksumsStrategyOffline, cluster.Name)
+		}
+	case crv1.PgtaskDataChecksumsStrategyReplicaFirst:
+		if cluster.Status.State != crv1.PgclusterStateInitialized {
+			return fmt.Errorf("the %q strategy requires cluster %s to be running",
+				crv1.PgtaskDataChecksumsStrategyReplicaFirst, cluster.Name)
+		}
+
+		instances, err := operator.GetInstanceDeployments(clientset, cluster)
+		if err != nil {
+			return err
+		}
+
+		// the primary can only be stopped once it has been switched over to a
+		// replica
+		if len(instances.Items) < 2 {
+			return fmt.Errorf("the %q strategy requires cluster %s to have at least one replica",
+				crv1.PgtaskDataChecksumsStrategyReplicaFirst, cluster.Name)
+		}
+	default:
+		return fmt.Errorf("pg_checksums requires each instance to be stopped, so the %q parameter must be "+
+			"explicitly set to either %q or %q", crv1.PgtaskDataChecksumsStrategy,
+			crv1.PgtaskDataChecksumsStrategyOffline, crv1.PgtaskDataChecksumsStrategyReplicaFirst)
+	}
+
+	return nil
+}
+
+// enableNextDataChecksumsInstance stops the next instance that does not yet
+// have data checksums enabled, if needed, and creates the Job that enables
+// them. If every instance is done, the pgtask is completed
+func enableNextDataChecksumsInstance(clientset *kubernetes.Clientset, restclient *rest.RESTClient, restconfig *rest.Config,
+	cluster crv1.Pgcluster, taskName, namespace string) {
+	task := crv1.Pgtask{}
+	if found, err := kubeapi.Getpgtask(restclient, &task, taskName, namespace); !found {
+		log.Error(err)
+		return
+	}
+
+	strategy := task.Spec.Parameters[crv1.PgtaskDataChecksumsStrategy]
+
+	instances, err := getDataChecksumsInstances(clientset, &cluster, strategy)
+	if err != nil {
+		failEnableDataChecksums(clientset, restclient, &cluster, &task, "", err.Error())
+		return
+	}
+
+	completed := map[string]bool{}
+	for _, name := range getDataChecksumsCompletedInstances(&task) {
+		completed[name] = true
+	}
+
+	var next *apps_v1.Deployment
+	for i := range instances {
+		if !completed[instances[i].Name] {
+			next = &instances[i]
+			break
+		}
+	}
+
+	if next == nil {
+		finishEnableDataChecksums(restclient, &cluster, &task, len(instances))
+		return
+	}
+
+	// record the instance that is being worked on before anything is done to
+	// it, so a failure can bring it back
+	task.Spec.Parameters[crv1.PgtaskDataChecksumsCurrentInstance] = next.Name
+
+	if err := kubeapi.Updatepgtask(restclient, &task, task.Spec.Name, namespace); err != nil {
+		log.Error("error in updating data checksums pgtask " + err.Error())
+		return
+	}
+
+	message := fmt.Sprintf("enabling data checksums on instance %s (%d of %d)",
+		next.Name, len(completed)+1, len(instances))
+
+	if err := kubeapi.PatchpgtaskStatus(restclient, crv1.PgtaskStateProcessed, message, &task, namespace); err != nil {
+		log.Error(err)
+	}
+
+	if strategy == crv1.PgtaskDataChecksumsStrategyReplicaFirst {
+		if err := stopDataChecksumsInstance(clientset, restconfig, &cluster, next.Name, completed); err != nil {
+			failEnableDataChecksums(clientset, restclient, &cluster, &task, next.Name, err.Error())
+			return
+		}
+	}
+
+	if err := createDataChecksumsJob(clientset, &cluster, &task, next, namespace); err != nil {
+		failEnableDataChecksums(clientset, restclient, &cluster, &task, next.Name, err.Error())
+	}
+}
+
+// getDataChecksumsInstances returns the instance Deployments of the cluster in
+// the order they are processed. When the cluster is running, the primary is
+// always processed last
+func getDataChecksumsInstances(clientset *kubernetes.Clientset, cluster *crv1.Pgcluster, strategy string) ([]apps_v1.Deployment, error) {
+	deployments, err := operator.GetInstanceDeployments(clientset, cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	instances := deployments.Items
+
+	primary := ""
+	if strategy == crv1.PgtaskDataChecksumsStrategyReplicaFirst {
+		pod, err := util.GetPrimaryPod(clientset, cluster)
+		if err != nil {
+			return nil, err
+		}
+
+		primary = pod.ObjectMeta.Labels[config.LABEL_DEPLOYMENT_NAME]
+	}
+
+	sort.Slice(instances, func(i, j int) bool {
+		if (instances[i].Name == primary) != (instances[j].Name == primary) {
+			return instances[j].Name == primary
+		}
+		return instances[i].Name < instances[j].Name
+	})
+
+	return instances, nil
+}
+
+// getDataChecksumsCompletedInstances returns the names of the instances that
+// already have data checksums enabled by the pgtask
+func getDataChecksumsCompletedInstances(task *crv1.Pgtask) []string {
+	completed := []string{}
+
+	for _, name := range strings.Split(task.Spec.Parameters[crv1.PgtaskDataChecksumsCompletedInstances], ",") {
+		if name != "" {
+			completed = append(completed, name)
+		}
+	}
+
+	return completed
+}
+
+// stopDataChecksumsInstance scales down the Deployment of an instance and
+// waits for its Pod to be removed. If the instance is the primary, it is first
+// switched over to a replica that already has data checksums enabled
+func stopDataChecksumsInstance(clientset *kubernetes.Clientset, restconfig *rest.Config, cluster *crv1.Pgcluster,
+	instance string, completed map[string]bool) error {
+	primaryPod, err := util.GetPrimaryPod(clientset, cluster)
+	if err != nil {
+		return err
+	}
+
+	if primaryPod.ObjectMeta.Labels[config.LABEL_DEPLOYMENT_NAME] == instance {
+		if err := switchoverDataChecksumsPrimary(clientset, restconfig, cluster, primaryPod, completed); err != nil {
+			return err
+		}
+	}
+
+	deployment, found, err := kubeapi.GetDeployment(clientset, instance, cluster.Namespace)
+	if !found {
+		return err
+	}
+
+	log.Debugf("data checksums: stopping instance %s", instance)
+
+	if err := kubeapi.ScaleDeployment(clientset, *deployment, 0); err != nil {
+		return err
+	}
+
+	return waitForInstanceStopped(clientset, cluster.Namespace, instance, dataChecksumsTimeout,
+		dataChecksumsPeriod)
+}
+
+// startDataChecksumsInstance scales the Deployment of an instance back up and
+// waits for it to be ready
+func startDataChecksumsInstance(clientset *kubernetes.Clientset, instance, namespace string) error {
+	deployment, found, err := kubeapi.GetDeployment(clientset, instance, namespace)
+	if !found {
+		return err
+	}
+
+	log.Debugf("data checksums: starting instance %s", instance)
+
+	if err := kubeapi.ScaleDeployment(clientset, *deployment, 1); err != nil {
+		return err
+	}
+
+	return waitForDeploymentReady(clientset, namespace, instance, dataChecksumsTimeout, dataChecksumsPeriod)
+}
+
+// switchoverDataChecksumsPrimary has Patroni switch the primary over to a ready
+// replica that already has data checksums enabled, and waits for the replica
+// to take over. Delayed replicas are passed over
+func switchoverDataChecksumsPrimary(clientset *kubernetes.Clientset, restconfig *rest.Config, cluster *crv1.Pgcluster,
+	primaryPod *v1.Pod, completed map[string]bool) error {
+	var candidate *v1.Pod
+
+	delayed, err := getDelayedReplicas(clientset, cluster.Name, cluster.Namespace)
+	if err != nil {
+		return err
+	}
+
+	for instance := range completed {
+		if containsString(delayed, instance) {
+			continue
+		}
+
+		selector := fmt.Sprintf("%s=%s", config.LABEL_DEPLOYMENT_NAME, instance)
+
+		pods, err := kubeapi.GetPods(clientset, selector, cluster.Namespace)
+		if err != nil {
+			return err
+		}
+
+		for i := range pods.Items {
+			if isReplicaPodReady(&pods.Items[i]) {
+				candidate = &pods.Items[i]
+				break
+			}
+		}
+
+		if candidate != nil {
+			break
+		}
+	}
+
+	if candidate == nil {
+		return errors.New("no ready replica with data checksums enabled to switch the primary over to")
+	}
+
+	log.Debugf("data checksums: switching primary %s over to %s", primaryPod.Name, candidate.Name)
+
+	return switchoverPrimary(clientset, restconfig, cluster, primaryPod, candidate,
+		dataChecksumsTimeout, dataChecksumsPeriod)
+}
+
+// waitForInstanceStopped waits for the Pod of an instance to be removed, at
+// which point PostgreSQL has been shut down
+func waitForInstanceStopped(clientset *kubernetes.Clientset, namespace, instance string,
+	timeoutSecs, periodSecs time.Duration) error {
+	selector := fmt.Sprintf("%s=%s", config.LABEL_DEPLOYMENT_NAME, instance)
+	timeout := time.After(timeoutSecs * time.Second)
+	tick := time.Tick(periodSecs * time.Second)
+
+	for {
+		select {
+		case <-timeout:
+			return fmt.Errorf("timed out waiting for instance %s to stop", instance)
+		case <-tick:
+			if pods, err := kubeapi.GetPods(clientset, selector, namespace); err != nil {
+				log.Error(err)
+			} else if len(pods.Items) == 0 {
+				return nil
+			}
+		}
+	}
+}
+
+// createDataChecksumsJob creates the Job that runs pg_checksums against the
+// PVC of an instance. Any Job left behind by an earlier attempt is removed
+// first
+func createDataChecksumsJob(clientset *kubernetes.Clientset, cluster *crv1.Pgcluster, task *crv1.Pgtask,
+	deployment *apps_v1.Deployment, namespace string) error {
+	pvcName := ""
+	for _, volume := range deployment.Spec.Template.Spec.Volumes {
+		if volume.Name == config.VOLUME_POSTGRESQL_DATA && volume.PersistentVolumeClaim != nil {
+			pvcName = volume.PersistentVolumeClaim.ClaimName
+		}
+	}
+
+	if pvcName == "" {
+		return fmt.Errorf("could not find the data PVC for instance %s", deployment.Name)
+	}
+
+	jobName := fmt.Sprintf(dataChecksumsJobName, deployment.Name)
+
+	if oldJob, found := kubeapi.GetJob(clientset, jobName, namespace); found {
+		if err := kubeapi.DeleteJob(clientset, jobName, namespace); err != nil {
+			return err
+		}
+
+		if err := kubeapi.IsJobDeleted(clientset, namespace, oldJob,
+			dataChecksumsTimeout*time.Second); err != nil {
+			return err
+		}
+	}
+
+	// set the backoff limit to be 0 to match our other jobs
+	backoffLimit := int32(0)
+
+	labels := map[string]string{
+		config.LABEL_VENDOR:         config.LABEL_CRUNCHY,
+		config.LABEL_PG_CLUSTER:     cluster.Name,
+		config.LABEL_PGTASK:         task.Spec.Name,
+		config.LABEL_PGOUSER:        task.ObjectMeta.Labels[config.LABEL_PGOUSER],
+		config.LABEL_DATA_CHECKSUMS: config.LABEL_TRUE,
+	}
+
+	job := batch_v1.Job{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:   jobName,
+			Labels: labels,
+		},
+		Spec: batch_v1.JobSpec{
+			BackoffLimit: &backoffLimit,
+			Template: v1.PodTemplateSpec{
+				ObjectMeta: meta_v1.ObjectMeta{
+					Name:   jobName,
+					Labels: labels,
+				},
+				Spec: v1.PodSpec{
+					Containers: []v1.Container{
+						v1.Container{
+							Name: dataChecksumsContainerName,
+							Image: fmt.Sprintf("%s/%s:%s", operator.Pgo.Cluster.CCPImagePrefix,
+								cluster.Spec.CCPImage, cluster.Spec.CCPImageTag),
+							Command: []string{"bash", "-c", dataChecksumsScript},
+							Env: []v1.EnvVar{
+								v1.EnvVar{
+									Name:  "PGDATA",
+									Value: fmt.Sprintf("%s/%s", config.VOLUME_POSTGRESQL_DATA_MOUNT_PATH, pvcName),
+								},
+							},
+							VolumeMounts: []v1.VolumeMount{
+								v1.VolumeMount{
+									MountPath: config.VOLUME_POSTGRESQL_DATA_MOUNT_PATH,
+									Name:      config.VOLUME_POSTGRESQL_DATA,
+								},
+							},
+						},
+					},
+					NodeSelector:  operator.GetNodeSelector(cluster),
+					RestartPolicy: v1.RestartPolicyNever,
+					SecurityContext: &v1.PodSecurityContext{
+						FSGroup:            &crv1.PGFSGroup,
+						SupplementalGroups: cluster.Spec.PrimaryStorage.GetSupplementalGroups(),
+					},
+					Tolerations: operator.GetTolerations(cluster),
+					Volumes: []v1.Volume{
+						v1.Volume{
+							Name: config.VOLUME_POSTGRESQL_DATA,
+							VolumeSource: v1.VolumeSource{
+								PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{
+									ClaimName: pvcName,
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	// set the container image to an override value, if one exists
+	operator.SetContainerImageOverride(cluster.Spec.CCPImage, &job.Spec.Template.Spec.Containers[0])
+
+	// apply the node affinity and the PriorityClass of the cluster
+	operator.AddPodScheduling(cluster, nil, &job.Spec.Template.Spec)
+
+	_, err := kubeapi.CreateJob(clientset, &job, namespace)
+
+	return err
+}
+
+// finishEnableDataChecksums completes the pgtask once every instance has data
+// checksums enabled. The state recorded on the cluster is cleared so that it
+// is checked again the next time the cluster is running
+func finishEnableDataChecksums(restclient *rest.RESTClient, cluster *crv1.Pgcluster, task *crv1.Pgtask, instances int) {
+	log.Debugf("data checksums: enabled on all instances of cluster %s", cluster.Name)
+
+	task.Spec.Status = crv1.PgtaskDataChecksumsCompleted
+
+	if err := kubeapi.Updatepgtask(restclient, task, task.Spec.Name, cluster.Namespace); err != nil {
+		log.Error("error in updating data checksums pgtask status " + err.Error())
+	}
+
+	message := fmt.Sprintf("data checksums enabled on %d instances", instances)
+
+	if err := kubeapi.PatchpgtaskPhase(restclient, crv1.PgtaskStateProcessed, crv1.PgtaskPhaseSucceeded,
+		message, task, cluster.Namespace); err != nil {
+		log.Error(err)
+	}
+
+	// get the latest version of the cluster before updating its status
+	if _, err := kubeapi.Getpgcluster(restclient, cluster, cluster.Name, cluster.Namespace); err != nil {
+		log.Error(err)
+		return
+	}
+
+	if err := kubeapi.Patchpgcluster(restclient, cluster, cluster.Namespace, func(cluster *crv1.Pgcluster) {
+		cluster.Status.DataChecksums = ""
+	}); err != nil {
+		log.Error(err)
+	}
+}
+
+// failEnableDataChecksums marks the pgtask as failed and records why on both
+// the pgtask and the cluster. If an instance was stopped for the replica-first
+// strategy, it is brought back up
+func failEnableDataChecksums(clientset *kubernetes.Clientset, restclient *rest.RESTClient, cluster *crv1.Pgcluster,
+	task *crv1.Pgtask, instance, message string) {
+	log.Errorf("data checksums: failed on cluster %s: %s", cluster.Name, message)
+
+	task.Spec.Status = crv1.PgtaskDataChecksumsFailed
+
+	if err := kubeapi.Updatepgtask(restclient, task, task.Spec.Name, cluster.Namespace); err != nil {
+		log.Error("error in updating data checksums pgtask status " + err.Error())
+	}
+
+	if err := kubeapi.PatchpgtaskPhase(restclient, crv1.PgtaskStateProcessed, crv1.PgtaskPhaseFailed,
+		message, task, cluster.Namespace); err != nil {
+		log.Error(err)
+	}
+
+	operator.RecordWarningEvent(clientset, cluster, "DataChecksumsFailed", message)
+
+	if instance == "" || task.Spec.Parameters[crv1.PgtaskDataChecksumsStrategy] != crv1.PgtaskDataChecksumsStrategyReplicaFirst {
+		return
+	}
+
+	if deployment, found, err := kubeapi.GetDeployment(clientset, instance, cluster.Namespace); !found {
+		log.Error(err)
+	} else if err := kubeapi.ScaleDeployment(clientset, *deployment, 1); err != nil {
+		log.Error(err)
+	}
+}
+
+// getDataChecksumsJobOutput returns the tail end of the logs of the Pod that
+// ran pg_checksums
+func getDataChecksumsJobOutput(clientset *kubernetes.Clientset, job *batch_v1.Job) (string, error) {
+	selector := fmt.Sprintf("%s=%s", config.LABEL_JOB_NAME, job.Name)
+
+	pods, err := kubeapi.GetPods(clientset, selector, job.Namespace)
+	if err != nil {
+		return "", err
+	}
+
+	if len(pods.Items) == 0 {
+		return "", fmt.Errorf("no pods found for selector [%s]", selector)
+	}
+
+	output, err := kubeapi.GetPodLogs(clientset, pods.Items[0].Name, dataChecksumsContainerName,
+		job.Namespace, dataChecksumsLogLines)
+
+	return strings.TrimSpace(output), err
+}
@@ -0,0 +1,225 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/config"
+	"github.com/crunchydata/postgres-operator/kubeapi"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	// sqlRecoveryMinApplyDelay returns the minimum apply delay of an instance in milliseconds,
+	// which only PostgreSQL 12 and later have as a setting
+	sqlRecoveryMinApplyDelay = `SELECT setting FROM pg_catalog.pg_settings
+WHERE name = 'recovery_min_apply_delay';`
+
+	// sqlSetRecoveryMinApplyDelay sets the minimum apply delay of an instance in milliseconds
+	sqlSetRecoveryMinApplyDelay = `ALTER SYSTEM SET recovery_min_apply_delay = '%dms';`
+
+	// sqlResetRecoveryMinApplyDelay removes the minimum apply delay of an instance
+	sqlResetRecoveryMinApplyDelay = `ALTER SYSTEM RESET recovery_min_apply_delay;`
+)
+
+// recoveryMinApplyDelayPattern is the format of a minimum apply delay, which is a whole number
+// in one of the time units of PostgreSQL
+var recoveryMinApplyDelayPattern = regexp.MustCompile(`^([0-9]+)(ms|s|min|h|d)$`)
+
+// recoveryMinApplyDelayUnits are the milliseconds in each time unit of a minimum apply delay
+var recoveryMinApplyDelayUnits = map[string]int64{
+	"ms":  1,
+	"s":   1000,
+	"min": 60 * 1000,
+	"h":   60 * 60 * 1000,
+	"d":   24 * 60 * 60 * 1000,
+}
+
+// ReconcileReplicaDelay keeps the minimum apply delay of a replica in line with its spec. The
+// Deployment of a delayed replica is labeled, which keeps the replica from being failed over to
+// and takes it out of the replica Service of its cluster, before the delay is set with ALTER
+// SYSTEM on the replica itself. The delay that was applied is recorded in the status of the
+// replica. Once the delay is removed from the spec, it is reset and the replica is put back
+// into the replica Service
+func ReconcileReplicaDelay(clientset *kubernetes.Clientset, restclient *rest.RESTClient,
+	restconfig *rest.Config, replica *crv1.Pgreplica) error {
+	delay, err := parseRecoveryMinApplyDelay(replica.Spec.RecoveryMinApplyDelay)
+	if err != nil {
+		return err
+	}
+
+	deployment, found, err := kubeapi.GetDeployment(clientset, replica.Spec.Name, replica.Namespace)
+	if !found {
+		return err
+	}
+
+	delayed := delay > 0
+
+	if (deployment.ObjectMeta.Labels[config.LABEL_DELAYED_REPLICA] == "true") != delayed {
+		log.Infof("delayed replica: marking replica %s as delayed: %t", replica.Spec.Name, delayed)
+
+		if delayed {
+			err = kubeapi.AddLabelToDeployment(clientset, deployment, config.LABEL_DELAYED_REPLICA,
+				"true", replica.Namespace)
+		} else {
+			delete(deployment.ObjectMeta.Labels, config.LABEL_DELAYED_REPLICA)
+			err = kubeapi.UpdateDeployment(clientset, deployment)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := ReconcileReplicaService(clientset, replica.Spec.ClusterName,
+		replica.Namespace); err != nil {
+		return err
+	}
+
+	applied := ""
+	if delayed {
+		applied = replica.Spec.RecoveryMinApplyDelay
+	}
+
+	// a replica that was never delayed has nothing to reset
+	if !delayed && replica.Status.RecoveryMinApplyDelay == "" {
+		return nil
+	}
+
+	selector := fmt.Sprintf("%s=%s", config.LABEL_DEPLOYMENT_NAME, replica.Spec.Name)
+
+	pods, err := kubeapi.GetPods(clientset, selector, replica.Namespace)
+	if err != nil {
+		return err
+	}
+
+	var pod *v1.Pod
+	for i := range pods.Items {
+		if isReplicaPodReady(&pods.Items[i]) {
+			pod = &pods.Items[i]
+			break
+		}
+	}
+
+	// the delay is applied once the replica is ready
+	if pod == nil {
+		log.Debugf("delayed replica: replica %s is not ready", replica.Spec.Name)
+		return nil
+	}
+
+	if err := setRecoveryMinApplyDelay(clientset, restconfig, pod, delay); err != nil {
+		return err
+	}
+
+	if applied == replica.Status.RecoveryMinApplyDelay {
+		return nil
+	}
+
+	return kubeapi.PatchpgreplicaRecoveryMinApplyDelayStatus(restclient, applied, replica,
+		replica.Namespace)
+}
+
+// getDelayedReplicas returns the names of the replicas of a cluster that are held back by a
+// minimum apply delay, going by the label on their Deployments
+func getDelayedReplicas(clientset *kubernetes.Clientset, clusterName,
+	namespace string) ([]string, error) {
+	selector := fmt.Sprintf("%s=%s,%s=true", config.LABEL_PG_CLUSTER, clusterName,
+		config.LABEL_DELAYED_REPLICA)
+
+	deployments, err := kubeapi.GetDeployments(clientset, selector, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	delayed := []string{}
+	for _, deployment := range deployments.Items {
+		delayed = append(delayed, deployment.Name)
+	}
+
+	sort.Strings(delayed)
+
+	return delayed, nil
+}
+
+// setRecoveryMinApplyDelay sets the minimum apply delay of the instance of a Pod, in
+// milliseconds, unless it already has it, and reloads its configuration to apply it. A delay of
+// zero removes it
+func setRecoveryMinApplyDelay(clientset *kubernetes.Clientset, restconfig *rest.Config,
+	pod *v1.Pod, delay int64) error {
+	current, err := execMaintenanceSQL(clientset, restconfig, pod, "postgres",
+		sqlRecoveryMinApplyDelay)
+	if err != nil {
+		return err
+	}
+
+	if current == "" {
+		return errors.New("a minimum apply delay requires PostgreSQL 12 or later")
+	}
+
+	if current == strconv.FormatInt(delay, 10) {
+		return nil
+	}
+
+	log.Infof("delayed replica: setting minimum apply delay of %s to %dms", pod.Name, delay)
+
+	sql := sqlResetRecoveryMinApplyDelay
+	if delay > 0 {
+		sql = fmt.Sprintf(sqlSetRecoveryMinApplyDelay, delay)
+	}
+
+	// ALTER SYSTEM cannot run in a transaction, so it is run on its own
+	if _, err := execMaintenanceSQL(clientset, restconfig, pod, "postgres", sql); err != nil {
+		return err
+	}
+
+	_, err = execMaintenanceSQL(clientset, restconfig, pod, "postgres", sqlReloadConf)
+
+	return err
+}
+
+// parseRecoveryMinApplyDelay returns a minimum apply delay, e.g. "1h", in milliseconds, or zero
+// if it is not set. PostgreSQL does not take a delay of more than about 24 days
+func parseRecoveryMinApplyDelay(delay string) (int64, error) {
+	if delay == "" {
+		return 0, nil
+	}
+
+	match := recoveryMinApplyDelayPattern.FindStringSubmatch(delay)
+	if match == nil {
+		return 0, fmt.Errorf("invalid minimum apply delay %q, it must be a whole number of "+
+			"ms, s, min, h or d, e.g. \"1h\"", delay)
+	}
+
+	value, err := strconv.ParseInt(match[1], 10, 64)
+	unit := recoveryMinApplyDelayUnits[match[2]]
+
+	if err != nil || value > math.MaxInt32/unit {
+		return 0, fmt.Errorf("invalid minimum apply delay %q, it cannot be more than %dms",
+			delay, math.MaxInt32)
+	}
+
+	return value * unit, nil
+}
@@ -0,0 +1,55 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"testing"
+)
+
+func TestParseRecoveryMinApplyDelay(t *testing.T) {
+	for _, test := range []struct {
+		delay    string
+		expected int64
+		valid    bool
+	}{
+		{"", 0, true},
+		{"0s", 0, true},
+		{"500ms", 500, true},
+		{"30s", 30000, true},
+		{"15min", 900000, true},
+		{"1h", 3600000, true},
+		{"2d", 172800000, true},
+		{"1.5h", 0, false},
+		{"-1h", 0, false},
+		{"1 h", 0, false},
+		{"1w", 0, false},
+		{"25d", 0, false},
+	} {
+		t.Run(test.delay, func(t *testing.T) {
+			delay, err := parseRecoveryMinApplyDelay(test.delay)
+
+			if test.valid && err != nil {
+				t.Fatalf("expected %q to be valid, got %s", test.delay, err)
+			}
+			if !test.valid && err == nil {
+				t.Fatalf("expected %q to be invalid", test.delay)
+			}
+			if delay != test.expected {
+				t.Fatalf("expected %d, got %d", test.expected, delay)
+			}
+		})
+	}
+}
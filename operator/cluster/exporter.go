@@ -0,0 +1,362 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/config"
+	"github.com/crunchydata/postgres-operator/kubeapi"
+	"github.com/crunchydata/postgres-operator/operator"
+	"github.com/crunchydata/postgres-operator/util"
+	log "github.com/sirupsen/logrus"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	// the name of the exporter container in the instance Pods, and of the volumes it mounts
+	exporterContainerName     = "collect"
+	exporterVolumeName        = "collect-volume"
+	exporterQueriesVolumeName = "collect-queries"
+
+	// exporterQueriesKey is the key of the ConfigMap of custom queries with the queries that the
+	// exporter runs, which it finds in "/conf"
+	exporterQueriesKey = "queries.yml"
+
+	// sqlSetExporterPassword creates the collect user if the cluster was initialized without
+	// it, e.g. as the exporter was only enabled afterwards, and sets its password
+	// NOTE: the user is a constant and the password is hashed, and both are escaped in the
+	// util.SetPostgreSQLPassword function
+	sqlSetExporterPassword = `DO $$BEGIN IF to_regrole('%[1]s') IS NULL THEN CREATE ROLE %[1]s; END IF; END$$;
+GRANT pg_monitor TO %[1]s;
+ALTER ROLE %[1]s PASSWORD %[2]s LOGIN;`
+)
+
+var (
+	// cmdViewExporterQueries shows the custom queries as they are mounted into the exporter
+	cmdViewExporterQueries = []string{"cat", "/conf/" + exporterQueriesKey}
+
+	// cmdRestartExporter stops the first process of the exporter container, which has Kubernetes
+	// restart the container, and only that container
+	cmdRestartExporter = []string{"sh", "-c", "kill 1"}
+)
+
+// ValidateExporter returns an error if the exporter spec of a cluster cannot be applied, i.e. it
+// has custom queries while the exporter is not enabled
+func ValidateExporter(cluster *crv1.Pgcluster) error {
+	if cluster.Spec.Exporter.CustomQueries != "" && !operator.IsExporterEnabled(&cluster.Spec) {
+		return fmt.Errorf("the custom exporter queries %s require the exporter to be enabled",
+			cluster.Spec.Exporter.CustomQueries)
+	}
+
+	return nil
+}
+
+// ReconcileExporter brings the exporter of a cluster in line with its spec: the credential of the
+// collect user is created if it does not exist yet and set in PostgreSQL, and the instances are
+// rolled out again if the exporter was enabled or disabled, or its resources or custom queries
+// changed. As the instances are restarted by this, it is expected to be called within the
+// restart budget
+func ReconcileExporter(clientset *kubernetes.Clientset, restconfig *rest.Config,
+	store operator.CredentialStore, cluster *crv1.Pgcluster) error {
+	if err := ValidateExporter(cluster); err != nil {
+		return err
+	}
+
+	// the credential has to exist before the instances mount it
+	if operator.IsExporterEnabled(&cluster.Spec) {
+		if err := setExporterPassword(clientset, restconfig, store, cluster); err != nil {
+			return err
+		}
+	}
+
+	return updateExporterDeployments(clientset, restconfig, cluster)
+}
+
+// ReconcileExporterQueries restarts the exporter of each instance of a cluster once the custom
+// queries it runs have changed. The exporter is only restarted once the queries are mounted into
+// it, which Kubernetes takes a while to do, so an instance that does not have them yet is left
+// for the next time this is called
+func ReconcileExporterQueries(clientset *kubernetes.Clientset, restconfig *rest.Config,
+	cluster *crv1.Pgcluster) error {
+	name := operator.GetCollectQueries(&cluster.Spec)
+	if name == "" {
+		return nil
+	}
+
+	configMap, found := kubeapi.GetConfigMap(clientset, name, cluster.Namespace)
+	if !found {
+		return fmt.Errorf("could not find the custom exporter queries %s", name)
+	}
+
+	queries := configMap.Data[exporterQueriesKey]
+	hash := fmt.Sprintf("%x", sha256.Sum256([]byte(queries)))
+
+	deployments, err := operator.GetInstanceDeployments(clientset, cluster)
+	if err != nil {
+		return err
+	}
+
+	for i := range deployments.Items {
+		deployment := &deployments.Items[i]
+
+		previous, ok := deployment.Annotations[config.ANNOTATION_EXPORTER_QUERIES_HASH]
+		if previous == hash {
+			continue
+		}
+
+		// an instance that has not recorded its queries yet was started with the current ones
+		if ok {
+			restarted, err := restartExporter(clientset, restconfig, deployment, queries)
+			if err != nil {
+				return err
+			} else if !restarted {
+				continue
+			}
+		}
+
+		if deployment.Annotations == nil {
+			deployment.Annotations = map[string]string{}
+		}
+
+		deployment.Annotations[config.ANNOTATION_EXPORTER_QUERIES_HASH] = hash
+
+		if err := kubeapi.UpdateDeployment(clientset, deployment); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// getExporterContainer returns the exporter container of the instances of a cluster, or nil if
+// the exporter is not enabled
+func getExporterContainer(cluster *crv1.Pgcluster) (*v1.Container, error) {
+	doc := operator.GetCollectAddon(&cluster.Spec)
+	if doc == "" {
+		return nil, nil
+	}
+
+	// the container is rendered to be appended to the containers of the Deployment template
+	container := &v1.Container{}
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(strings.TrimSpace(doc), ",")), container); err != nil {
+		return nil, err
+	}
+
+	return container, nil
+}
+
+// getExporterVolumes returns the volumes of the exporter of the instances of a cluster, i.e. the
+// credential of the collect user and, if it runs custom queries, the ConfigMap of the queries. The
+// volume of the credential is only returned when the exporter is enabled, as the instances
+// otherwise have an empty volume in its place
+func getExporterVolumes(cluster *crv1.Pgcluster) []v1.Volume {
+	volumes := []v1.Volume{}
+
+	if !operator.IsExporterEnabled(&cluster.Spec) {
+		return volumes
+	}
+
+	volumes = append(volumes, v1.Volume{
+		Name: exporterVolumeName,
+		VolumeSource: v1.VolumeSource{
+			Secret: &v1.SecretVolumeSource{SecretName: operator.GetExporterSecretName(cluster)},
+		},
+	})
+
+	if name := operator.GetCollectQueries(&cluster.Spec); name != "" {
+		volumes = append(volumes, v1.Volume{
+			Name: exporterQueriesVolumeName,
+			VolumeSource: v1.VolumeSource{
+				ConfigMap: &v1.ConfigMapVolumeSource{
+					LocalObjectReference: v1.LocalObjectReference{Name: name},
+				},
+			},
+		})
+	}
+
+	return volumes
+}
+
+// getExporterHash returns the hash of the exporter container and volumes that the instances of
+// a cluster are rolled out with
+func getExporterHash(container *v1.Container, volumes []v1.Volume) string {
+	// these are plain structs, which always marshal
+	data, _ := json.Marshal([]interface{}{container, volumes})
+
+	return fmt.Sprintf("%x", sha256.Sum256(data))
+}
+
+// setExporter puts the exporter container and volumes into the Pod template of an instance,
+// replacing the ones it has
+func setExporter(spec *v1.PodSpec, container *v1.Container, volumes []v1.Volume) {
+	containers := []v1.Container{}
+	for _, c := range spec.Containers {
+		if c.Name != exporterContainerName {
+			containers = append(containers, c)
+		}
+	}
+
+	if container != nil {
+		containers = append(containers, *container)
+	}
+
+	spec.Containers = containers
+
+	desired := map[string]v1.Volume{}
+	for _, volume := range volumes {
+		desired[volume.Name] = volume
+	}
+
+	// the volume of the credential is kept in place, as the template of the instance always
+	// has it, while the volume of the queries is only kept while the exporter runs them
+	result := []v1.Volume{}
+	for _, volume := range spec.Volumes {
+		if replacement, ok := desired[volume.Name]; ok {
+			result = append(result, replacement)
+			delete(desired, volume.Name)
+		} else if volume.Name != exporterQueriesVolumeName {
+			result = append(result, volume)
+		}
+	}
+
+	for _, volume := range volumes {
+		if _, ok := desired[volume.Name]; ok {
+			result = append(result, volume)
+		}
+	}
+
+	spec.Volumes = result
+}
+
+// restartExporter restarts the exporter of each Pod of an instance Deployment once the given
+// queries are mounted into it, and returns whether it was restarted
+func restartExporter(clientset *kubernetes.Clientset, restconfig *rest.Config,
+	deployment *appsv1.Deployment, queries string) (bool, error) {
+	selector := fmt.Sprintf("%s=%s", config.LABEL_DEPLOYMENT_NAME, deployment.Name)
+
+	pods, err := kubeapi.GetPods(clientset, selector, deployment.Namespace)
+	if err != nil {
+		return false, err
+	}
+
+	for _, pod := range pods.Items {
+		stdout, _, err := kubeapi.ExecToPodThroughAPI(restconfig, clientset, cmdViewExporterQueries,
+			exporterContainerName, pod.Name, pod.Namespace, nil)
+		if err != nil {
+			return false, err
+		}
+
+		if stdout != queries {
+			log.Debugf("exporter: custom queries are not mounted into pod %s yet", pod.Name)
+			return false, nil
+		}
+	}
+
+	for _, pod := range pods.Items {
+		log.Debugf("exporter: restarting exporter of pod %s for its custom queries", pod.Name)
+
+		if _, stderr, err := kubeapi.ExecToPodThroughAPI(restconfig, clientset, cmdRestartExporter,
+			exporterContainerName, pod.Name, pod.Namespace, nil); err != nil {
+			return false, fmt.Errorf("could not restart exporter of pod %s: %s %s", pod.Name, err,
+				strings.TrimSpace(stderr))
+		}
+	}
+
+	return true, nil
+}
+
+// setExporterPassword creates the credential of the collect user if it does not exist yet, and
+// sets its password in PostgreSQL. A standby cluster has the user of the cluster it follows, so
+// it only gets the credential
+func setExporterPassword(clientset *kubernetes.Clientset, restconfig *rest.Config,
+	store operator.CredentialStore, cluster *crv1.Pgcluster) error {
+	credential, err := setExporterCredential(store, cluster)
+	if err != nil {
+		return err
+	}
+
+	if cluster.Spec.Standby {
+		return nil
+	}
+
+	if credential.Password == "" {
+		return fmt.Errorf("the credential in %s has no password", operator.GetExporterSecretName(cluster))
+	}
+
+	pod, err := util.GetPrimaryPod(clientset, cluster)
+	if err != nil {
+		return err
+	}
+
+	// the password is hashed so that it is not sent to PostgreSQL in plain text
+	hashedPassword, err := util.GeneratePostgreSQLPassword(cluster.Spec.GetPasswordEncryption(),
+		credential.Username, credential.Password)
+	if err != nil {
+		return err
+	}
+
+	return util.SetPostgreSQLPassword(clientset, restconfig, pod, credential.Username, hashedPassword,
+		sqlSetExporterPassword)
+}
+
+// updateExporterDeployments rolls out the instances of a cluster again if the exporter container
+// or volumes that follow from the spec of the cluster changed since they were last rolled out
+func updateExporterDeployments(clientset *kubernetes.Clientset, restconfig *rest.Config,
+	cluster *crv1.Pgcluster) error {
+	container, err := getExporterContainer(cluster)
+	if err != nil {
+		return err
+	}
+
+	volumes := getExporterVolumes(cluster)
+	hash := getExporterHash(container, volumes)
+
+	deployments, err := operator.GetInstanceDeployments(clientset, cluster)
+	if err != nil {
+		return err
+	}
+
+	return updateDeployments(clientset, restconfig, deployments.Items, func(deployment *appsv1.Deployment) bool {
+		template := &deployment.Spec.Template
+
+		if template.Annotations[config.ANNOTATION_EXPORTER_SPEC_HASH] == hash {
+			return false
+		}
+
+		log.Debugf("exporter: rolling out %s with its updated exporter", deployment.Name)
+
+		setExporter(&template.Spec, container, volumes)
+
+		if template.Annotations == nil {
+			template.Annotations = map[string]string{}
+		}
+
+		template.Annotations[config.ANNOTATION_EXPORTER_SPEC_HASH] = hash
+
+		// the new Pod is started with the queries as they are now
+		delete(deployment.Annotations, config.ANNOTATION_EXPORTER_QUERIES_HASH)
+
+		return true
+	})
+}
@@ -0,0 +1,90 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"testing"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/config"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestSetExporter(t *testing.T) {
+	cluster := &crv1.Pgcluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "hippo"},
+		Spec: crv1.PgclusterSpec{
+			CollectSecretName: "hippo-collect-secret",
+			Exporter:          crv1.ExporterSpec{Enabled: true, CustomQueries: "hippo-queries"},
+		},
+	}
+
+	spec := &v1.PodSpec{
+		Containers: []v1.Container{{Name: "database"}},
+		Volumes: []v1.Volume{
+			{Name: "pgdata"},
+			{Name: exporterVolumeName, VolumeSource: v1.VolumeSource{EmptyDir: &v1.EmptyDirVolumeSource{}}},
+		},
+	}
+
+	volumes := getExporterVolumes(cluster)
+	setExporter(spec, &v1.Container{Name: exporterContainerName}, volumes)
+
+	if len(spec.Containers) != 2 || spec.Containers[1].Name != exporterContainerName {
+		t.Fatalf("expected the exporter container to be added, got %v", spec.Containers)
+	}
+
+	if len(spec.Volumes) != 3 ||
+		spec.Volumes[1].Secret == nil || spec.Volumes[1].Secret.SecretName != "hippo-collect-secret" ||
+		spec.Volumes[2].ConfigMap == nil || spec.Volumes[2].ConfigMap.Name != "hippo-queries" {
+		t.Fatalf("expected the credential and the queries to be mounted, got %v", spec.Volumes)
+	}
+
+	// the instances are rolled out again once the volumes change
+	if getExporterHash(nil, volumes) == getExporterHash(nil, getExporterVolumes(&crv1.Pgcluster{})) {
+		t.Fatal("expected the hash to change along with the volumes")
+	}
+
+	// once the exporter is disabled, its container and queries are removed
+	setExporter(spec, nil, getExporterVolumes(&crv1.Pgcluster{}))
+
+	if len(spec.Containers) != 1 || len(spec.Volumes) != 2 || spec.Volumes[1].Name != exporterVolumeName {
+		t.Fatalf("expected the exporter to be removed, got %v and %v", spec.Containers, spec.Volumes)
+	}
+}
+
+func TestGetExporterVolumes(t *testing.T) {
+	cluster := &crv1.Pgcluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "hippo"},
+		Spec: crv1.PgclusterSpec{
+			UserLabels: map[string]string{config.LABEL_COLLECT: "true"},
+		},
+	}
+
+	// the exporter that is enabled with the user label logs in with the default credential
+	volumes := getExporterVolumes(cluster)
+
+	if len(volumes) != 1 || volumes[0].Secret.SecretName != "hippo"+crv1.CollectSecretSuffix {
+		t.Fatalf("expected the default credential to be mounted, got %v", volumes)
+	}
+
+	cluster.Spec.UserLabels = map[string]string{}
+
+	if volumes := getExporterVolumes(cluster); len(volumes) != 0 {
+		t.Fatalf("expected no volumes, got %v", volumes)
+	}
+}
@@ -26,6 +26,7 @@ import (
 	"github.com/crunchydata/postgres-operator/config"
 	"github.com/crunchydata/postgres-operator/events"
 	"github.com/crunchydata/postgres-operator/kubeapi"
+	"github.com/crunchydata/postgres-operator/operator"
 	jsonpatch "github.com/evanphx/json-patch"
 	log "github.com/sirupsen/logrus"
 	"k8s.io/apimachinery/pkg/types"
@@ -60,6 +61,28 @@ func FailoverBase(namespace string, clientset *kubernetes.Clientset, client *res
 		return
 	}
 
+	//without a target, fail over to the replica that is least behind the primary,
+	//which is recorded as the target of the task. unless the failover is forced, a
+	//cluster with synchronous replication only fails over to a synchronous replica
+	target, err := getRoleChangeTarget(clientset, restconfig, &cluster,
+		task.ObjectMeta.Labels[config.LABEL_TARGET],
+		task.ObjectMeta.Labels[config.LABEL_FORCE_FAILOVER] == "true")
+	if err != nil {
+		operator.RecordWarningEvent(clientset, &cluster, operator.EventReasonFailoverFailed,
+			"no failover target could be picked: "+err.Error())
+		operator.UpdateTaskPhase(client, task.Name, namespace, crv1.PgtaskPhaseFailed, err.Error())
+		return
+	}
+
+	if task.ObjectMeta.Labels[config.LABEL_TARGET] == "" {
+		if err := recordRoleChangeTarget(client, task, target); err != nil {
+			log.Errorf("could not record failover target %s of task %s: %s", target, task.Spec.Name, err)
+			return
+		}
+
+		log.Infof("failing cluster %s over to %s, the replica least behind the primary", clusterName, target)
+	}
+
 	//get initial count of replicas --selector=pg-cluster=clusterName
 	replicaList := crv1.PgreplicaList{}
 	selector := config.LABEL_PG_CLUSTER + "=" + clusterName
@@ -91,7 +114,15 @@ func FailoverBase(namespace string, clientset *kubernetes.Clientset, client *res
 		log.Error(err)
 	}
 
-	Failover(cluster.ObjectMeta.Labels[config.LABEL_PG_CLUSTER_IDENTIFIER], clientset, client, clusterName, task, namespace, restconfig)
+	if err := Failover(cluster.ObjectMeta.Labels[config.LABEL_PG_CLUSTER_IDENTIFIER], clientset, client,
+		clusterName, task, namespace, restconfig); err != nil {
+		operator.RecordWarningEvent(clientset, &cluster, operator.EventReasonFailoverFailed,
+			"failover to "+task.ObjectMeta.Labels[config.LABEL_TARGET]+" failed: "+err.Error())
+		operator.UpdateTaskPhase(client, task.Name, namespace, crv1.PgtaskPhaseFailed, err.Error())
+	} else {
+		operator.UpdateTaskPhase(client, task.Name, namespace, crv1.PgtaskPhaseSucceeded,
+			"failed over to "+task.ObjectMeta.Labels[config.LABEL_TARGET])
+	}
 
 	//publish event for failover completed
 	topics = make([]string, 1)
@@ -0,0 +1,295 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"fmt"
+	"time"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/config"
+	"github.com/crunchydata/postgres-operator/kubeapi"
+	"github.com/crunchydata/postgres-operator/operator"
+	"github.com/crunchydata/postgres-operator/util"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	// defaultFailoverHealthCheckCooldownSeconds is how long after a failover the probes do not
+	// trigger another one if no cooldown is set
+	defaultFailoverHealthCheckCooldownSeconds = 600
+
+	// eventReasonHealthCheckFailover is the reason of the Warning events that are recorded when
+	// the probes of the primary of a cluster trigger a failover
+	eventReasonHealthCheckFailover = "HealthCheckFailover"
+
+	// eventReasonHealthCheckFailoverFailed is the reason of the Warning events that are recorded
+	// when the probes of the primary of a cluster call for a failover that cannot be triggered
+	eventReasonHealthCheckFailoverFailed = "HealthCheckFailoverFailed"
+)
+
+// sqlFailoverHealthCheck is the probe of the primary, which has to return a row in time
+const sqlFailoverHealthCheck = `SELECT 1;`
+
+// ValidateFailoverHealthCheck returns an error if the timeout, the failure threshold or the
+// cooldown of the failover health check of a cluster is negative
+func ValidateFailoverHealthCheck(cluster *crv1.Pgcluster) error {
+	spec := cluster.Spec.FailoverHealthCheck
+
+	for _, setting := range []struct {
+		name  string
+		value int
+	}{
+		{"timeout", spec.TimeoutSeconds},
+		{"failure threshold", spec.FailureThreshold},
+		{"cooldown", spec.CooldownSeconds},
+	} {
+		if setting.value < 0 {
+			return fmt.Errorf("invalid failover health check %s of %d, it cannot be negative",
+				setting.name, setting.value)
+		}
+	}
+
+	return nil
+}
+
+// ReconcileFailoverHealthCheck probes the primary of a cluster with SQL, and once the probe has
+// failed the threshold of times in a row, fails the cluster over to the replica that is least
+// behind the primary. To keep the old primary from taking writes that the new primary does not
+// have, it is fenced first: its Deployment is scaled down, which stops PostgreSQL and Patroni
+// along with it, so that neither Patroni nor the old primary itself can take the primary role
+// back. The failover itself is carried out by a failover pgtask. This is independent of the
+// failover of Patroni, and catches a primary that keeps its leader lock while it does not answer
+// queries. A cluster without a primary, e.g. while Patroni fails it over, is not probed. The old
+// primary is unfenced, i.e. scaled back up to rejoin as a replica, once it is no longer the
+// primary
+func ReconcileFailoverHealthCheck(clientset *kubernetes.Clientset, restclient *rest.RESTClient,
+	restconfig *rest.Config, cluster *crv1.Pgcluster) error {
+	spec := cluster.Spec.FailoverHealthCheck
+	status := cluster.Status.FailoverHealthCheck
+
+	// the primary is looked up by the namespace in the spec
+	cluster.Spec.Namespace = cluster.Namespace
+
+	pod, err := util.GetPrimaryPod(clientset, cluster)
+	if err != nil {
+		log.Debugf("failover health check: no primary of cluster %s to probe: %s", cluster.Name, err)
+		return nil
+	}
+
+	primary := pod.Labels[config.LABEL_DEPLOYMENT_NAME]
+
+	if status.FencedInstance != "" && status.FencedInstance != primary {
+		log.Infof("failover health check: unfencing %s, the old primary of cluster %s",
+			status.FencedInstance, cluster.Name)
+
+		if err := unfenceUnhealthyPrimary(clientset, cluster, status.FencedInstance); err != nil {
+			return err
+		}
+
+		status.FencedInstance = ""
+	}
+
+	if spec.Enabled && cluster.Status.State == crv1.PgclusterStateInitialized &&
+		!cluster.Spec.Shutdown && !cluster.Spec.Standby {
+		timeout := spec.TimeoutSeconds
+		if timeout <= 0 {
+			timeout = defaultHealthCheckTimeoutSeconds
+		}
+
+		threshold := spec.FailureThreshold
+		if threshold <= 0 {
+			threshold = defaultHealthCheckFailureThreshold
+		}
+
+		cooldown := time.Duration(spec.CooldownSeconds) * time.Second
+		if spec.CooldownSeconds <= 0 {
+			cooldown = defaultFailoverHealthCheckCooldownSeconds * time.Second
+		}
+
+		now := time.Now()
+		status.LastCheck = now.Format(time.RFC3339)
+
+		if err := runHealthCheck(clientset, restconfig, pod, "postgres", sqlFailoverHealthCheck,
+			timeout); err != nil {
+			log.Debugf("failover health check: primary %s of cluster %s failed its probe: %s",
+				primary, cluster.Name, err)
+
+			status.ConsecutiveFailures++
+			status.Message = err.Error()
+		} else {
+			status.ConsecutiveFailures = 0
+			status.Message = ""
+		}
+
+		if status.ConsecutiveFailures >= threshold &&
+			!isFailoverCoolingDown(status.LastFailoverTime, cooldown, now) {
+			if err := triggerHealthCheckFailover(clientset, restclient, restconfig, cluster, pod,
+				&status); err != nil {
+				// the failure is only reported once, and the failover is tried again as the
+				// probe keeps failing
+				if status.ConsecutiveFailures == threshold {
+					operator.RecordWarningEvent(clientset, cluster, eventReasonHealthCheckFailoverFailed,
+						fmt.Sprintf("primary %s failed %d probes in a row, but the cluster could not be failed over: %s",
+							primary, status.ConsecutiveFailures, err))
+				}
+				log.Errorf("failover health check: could not fail cluster %s over: %s", cluster.Name, err)
+			} else {
+				status.ConsecutiveFailures = 0
+				status.LastFailoverTime = now.Format(time.RFC3339)
+			}
+		}
+	} else if !spec.Enabled {
+		// only the instance that is still fenced is kept track of once the probes are disabled
+		status = crv1.FailoverHealthCheckStatus{FencedInstance: status.FencedInstance}
+	}
+
+	if status == cluster.Status.FailoverHealthCheck {
+		return nil
+	}
+
+	return kubeapi.Patchpgcluster(restclient, cluster, cluster.Namespace, func(cluster *crv1.Pgcluster) {
+		cluster.Status.FailoverHealthCheck = status
+	})
+}
+
+// triggerHealthCheckFailover fences the primary of a cluster that failed its probes, and creates
+// the failover pgtask that promotes the replica that is least behind it. The fenced instance is
+// recorded in the status before it is fenced, so that it is unfenced in the end even if the
+// failover is never created. A failover pgtask that is still underway is left to finish instead
+func triggerHealthCheckFailover(clientset *kubernetes.Clientset, restclient *rest.RESTClient,
+	restconfig *rest.Config, cluster *crv1.Pgcluster, pod *v1.Pod,
+	status *crv1.FailoverHealthCheckStatus) error {
+	taskName := cluster.Name + "-" + config.LABEL_FAILOVER
+
+	existing := crv1.Pgtask{}
+	if found, _ := kubeapi.Getpgtask(restclient, &existing, taskName, cluster.Namespace); found {
+		switch existing.Status.Phase {
+		case crv1.PgtaskPhasePending, crv1.PgtaskPhaseRunning:
+			return fmt.Errorf("failover pgtask %s is already underway", taskName)
+		}
+
+		// previous failovers leave a pgtask behind, which is replaced
+		if err := kubeapi.Deletepgtask(restclient, taskName, cluster.Namespace); err != nil {
+			return err
+		}
+	}
+
+	target, err := getRoleChangeTarget(clientset, restconfig, cluster, "", false)
+	if err != nil {
+		return err
+	}
+
+	primary := pod.Labels[config.LABEL_DEPLOYMENT_NAME]
+
+	log.Warnf("failover health check: fencing primary %s of cluster %s and failing over to %s",
+		primary, cluster.Name, target)
+
+	status.FencedInstance = primary
+
+	if err := kubeapi.Patchpgcluster(restclient, cluster, cluster.Namespace, func(cluster *crv1.Pgcluster) {
+		cluster.Status.FailoverHealthCheck = *status
+	}); err != nil {
+		return err
+	}
+
+	if err := fenceUnhealthyPrimary(clientset, cluster, primary); err != nil {
+		return err
+	}
+
+	task := &crv1.Pgtask{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name: taskName,
+			Labels: map[string]string{
+				config.LABEL_PG_CLUSTER: cluster.Name,
+				config.LABEL_TARGET:     target,
+				config.LABEL_PGOUSER:    cluster.ObjectMeta.Labels[config.LABEL_PGOUSER],
+			},
+		},
+		Spec: crv1.PgtaskSpec{
+			Namespace: cluster.Namespace,
+			Name:      taskName,
+			TaskType:  crv1.PgtaskFailover,
+			Parameters: map[string]string{
+				cluster.Name: cluster.Name,
+			},
+		},
+	}
+
+	if err := kubeapi.Createpgtask(restclient, task, cluster.Namespace); err != nil {
+		return err
+	}
+
+	operator.RecordWarningEvent(clientset, cluster, eventReasonHealthCheckFailover,
+		fmt.Sprintf("primary %s failed %d probes in a row (%s), failing over to %s", primary,
+			status.ConsecutiveFailures, status.Message, target))
+
+	return nil
+}
+
+// fenceUnhealthyPrimary stops the old primary of a cluster by scaling its Deployment down, so
+// that the clients and replicas that are still connected to it move on to the new primary.
+// Unlike a label or a setting of PostgreSQL, which Patroni manages, this does not depend on the
+// old primary answering, and Patroni cannot undo it
+func fenceUnhealthyPrimary(clientset *kubernetes.Clientset, cluster *crv1.Pgcluster,
+	instance string) error {
+	deployment, _, err := kubeapi.GetDeployment(clientset, instance, cluster.Namespace)
+	if err != nil {
+		return err
+	}
+
+	return kubeapi.ScaleDeployment(clientset, *deployment, 0)
+}
+
+// unfenceUnhealthyPrimary scales the Deployment of the old primary of a cluster back up, which
+// has it rejoin the cluster as a replica of the new primary. An instance that no longer exists,
+// e.g. as it was scaled down for good, has nothing to unfence
+func unfenceUnhealthyPrimary(clientset *kubernetes.Clientset, cluster *crv1.Pgcluster,
+	instance string) error {
+	deployment, found, err := kubeapi.GetDeployment(clientset, instance, cluster.Namespace)
+	if !found {
+		if kerrors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+
+	if deployment.Spec.Replicas != nil && *deployment.Spec.Replicas > 0 {
+		return nil
+	}
+
+	return kubeapi.ScaleDeployment(clientset, *deployment, 1)
+}
+
+// isFailoverCoolingDown returns whether a failover that the probes triggered was recent enough
+// that they do not trigger another one
+func isFailoverCoolingDown(lastFailoverTime string, cooldown time.Duration, now time.Time) bool {
+	if lastFailoverTime == "" {
+		return false
+	}
+
+	last, err := time.Parse(time.RFC3339, lastFailoverTime)
+	if err != nil {
+		return false
+	}
+
+	return now.Sub(last) < cooldown
+}
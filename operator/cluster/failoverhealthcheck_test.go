@@ -0,0 +1,43 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIsFailoverCoolingDown(t *testing.T) {
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	cooldown := 10 * time.Minute
+
+	for _, test := range []struct {
+		name     string
+		last     string
+		expected bool
+	}{
+		{"never failed over", "", false},
+		{"recent", "2020-06-01T11:55:00Z", true},
+		{"over", "2020-06-01T11:50:00Z", false},
+		{"unparsable", "yesterday", false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			if cooling := isFailoverCoolingDown(test.last, cooldown, now); cooling != test.expected {
+				t.Fatalf("expected %t, got %t", test.expected, cooling)
+			}
+		})
+	}
+}
@@ -0,0 +1,253 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/config"
+	"github.com/crunchydata/postgres-operator/kubeapi"
+	"github.com/crunchydata/postgres-operator/operator"
+	"github.com/crunchydata/postgres-operator/util"
+	log "github.com/sirupsen/logrus"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	// defaultFencingGracePeriodSeconds is how long the quorum must be lost before the primary is
+	// fenced if no grace period is set
+	defaultFencingGracePeriodSeconds = 60
+
+	// eventReasonPrimaryFenced is the reason of the Warning event that is recorded when the
+	// primary of a cluster is fenced
+	eventReasonPrimaryFenced = "PrimaryFenced"
+
+	// sqlFencingConnectedReplicas returns the number of replicas that are streaming from the
+	// primary and, if a maximum lag is set, are not further behind than it
+	sqlFencingConnectedReplicas = `SELECT count(*) FROM pg_catalog.pg_stat_replication
+WHERE state = 'streaming' AND (%[1]d = 0 OR
+pg_catalog.pg_wal_lsn_diff(pg_catalog.pg_current_wal_lsn(), replay_lsn) <= %[1]d);`
+
+	// sqlFence and sqlUnfence make the new transactions of an instance read-only or no longer
+	// read-only. ALTER SYSTEM only changes the configuration of the instance it is run on, so a
+	// replica that is promoted while the primary is fenced is not fenced along with it
+	sqlFence   = `ALTER SYSTEM SET default_transaction_read_only = on;`
+	sqlUnfence = `ALTER SYSTEM RESET default_transaction_read_only;`
+	// sqlReloadConf has the instance reload its configuration, which applies the fencing to the
+	// sessions that are already open as well
+	sqlReloadConf = `SELECT pg_catalog.pg_reload_conf();`
+)
+
+// ReconcileFencing checks whether the primary of a cluster that has fencing enabled has the
+// quorum of its replicas connected to it, and makes the primary read-only once it has lost the
+// quorum for longer than the grace period. A single check that fails, or a loss of the quorum
+// that is shorter than the grace period, does not fence the primary. The primary is unfenced as
+// soon as the quorum is restored, when fencing is disabled, or when the fenced instance is no
+// longer the primary, e.g. after a failover. If the primary cannot be queried, nothing is
+// changed, as whether or not it has the quorum is not known
+func ReconcileFencing(clientset *kubernetes.Clientset, restclient *rest.RESTClient,
+	restconfig *rest.Config, cluster *crv1.Pgcluster) error {
+	fencing := cluster.Spec.Fencing
+	status := cluster.Status.Fencing
+
+	// the primary is looked up by the namespace in the spec
+	cluster.Spec.Namespace = cluster.Namespace
+
+	if !fencing.Enabled || cluster.Spec.Standby {
+		if status.Fenced {
+			if err := unfenceInstance(clientset, restconfig, cluster, status.Instance); err != nil {
+				return err
+			}
+		}
+
+		if status == (crv1.FencingStatus{}) {
+			return nil
+		}
+
+		return kubeapi.Patchpgcluster(restclient, cluster, cluster.Namespace, func(cluster *crv1.Pgcluster) {
+			cluster.Status.Fencing = crv1.FencingStatus{}
+		})
+	}
+
+	if cluster.Status.State != crv1.PgclusterStateInitialized {
+		return nil
+	}
+
+	pod, err := util.GetPrimaryPod(clientset, cluster)
+	if err != nil {
+		return err
+	}
+
+	primary := pod.Labels[config.LABEL_DEPLOYMENT_NAME]
+
+	// an instance that was fenced and is no longer the primary is unfenced, so that it is not
+	// read-only should it be promoted again
+	if status.Fenced && status.Instance != primary {
+		if err := unfenceInstance(clientset, restconfig, cluster, status.Instance); err != nil {
+			return err
+		}
+
+		status = crv1.FencingStatus{}
+	}
+
+	deployments, err := operator.GetInstanceDeployments(clientset, cluster)
+	if err != nil {
+		return err
+	}
+
+	replicas := len(deployments.Items) - 1
+	required := getFencingRequiredReplicas(fencing, replicas)
+
+	connected := 0
+	if required > 0 {
+		output, err := execMaintenanceSQL(clientset, restconfig, pod, "postgres",
+			getWALSQL(cluster, fmt.Sprintf(sqlFencingConnectedReplicas, fencing.MaxLagBytes)))
+		if err != nil {
+			return err
+		}
+
+		if connected, err = strconv.Atoi(output); err != nil {
+			return err
+		}
+	}
+
+	previous := cluster.Status.Fencing
+	status.ConnectedReplicas = connected
+	status.RequiredReplicas = required
+
+	if connected >= required {
+		if status.Fenced {
+			log.Infof("fencing: quorum of cluster %s is restored, unfencing primary %s",
+				cluster.Name, status.Instance)
+
+			if err := unfenceInstance(clientset, restconfig, cluster, status.Instance); err != nil {
+				return err
+			}
+		}
+
+		status = crv1.FencingStatus{ConnectedReplicas: connected, RequiredReplicas: required}
+	} else {
+		now := time.Now()
+
+		if status.QuorumLostSince == "" {
+			log.Warnf("fencing: primary %s of cluster %s lost its quorum, %d of %d replicas connected",
+				primary, cluster.Name, connected, required)
+
+			status.QuorumLostSince = now.Format(time.RFC3339)
+		}
+
+		since, err := time.Parse(time.RFC3339, status.QuorumLostSince)
+		if err != nil {
+			return err
+		}
+
+		gracePeriod := time.Duration(fencing.GracePeriodSeconds) * time.Second
+		if fencing.GracePeriodSeconds <= 0 {
+			gracePeriod = defaultFencingGracePeriodSeconds * time.Second
+		}
+
+		if !status.Fenced && now.Sub(since) >= gracePeriod {
+			message := fmt.Sprintf("primary %s is read-only as %d of %d replicas are connected to it",
+				primary, connected, required)
+
+			log.Warnf("fencing: cluster %s: %s", cluster.Name, message)
+
+			if err := fenceInstance(clientset, restconfig, cluster, primary); err != nil {
+				return err
+			}
+
+			operator.RecordWarningEvent(clientset, cluster, eventReasonPrimaryFenced, message)
+
+			status.Fenced = true
+			status.Instance = primary
+			status.FencedSince = now.Format(time.RFC3339)
+		}
+	}
+
+	if status == previous {
+		return nil
+	}
+
+	return kubeapi.Patchpgcluster(restclient, cluster, cluster.Namespace, func(cluster *crv1.Pgcluster) {
+		cluster.Status.Fencing = status
+	})
+}
+
+// getFencingRequiredReplicas returns the number of replicas that make up the quorum of the
+// primary, which is a majority of the members of the cluster other than the primary, unless a
+// number is set. No more replicas than the cluster has are required
+func getFencingRequiredReplicas(fencing crv1.FencingSpec, replicas int) int {
+	if replicas <= 0 {
+		return 0
+	}
+
+	required := fencing.MinReplicas
+	if required <= 0 {
+		required = (replicas + 1) / 2
+	}
+
+	if required > replicas {
+		required = replicas
+	}
+
+	return required
+}
+
+// fenceInstance makes the new transactions of an instance read-only
+func fenceInstance(clientset *kubernetes.Clientset, restconfig *rest.Config,
+	cluster *crv1.Pgcluster, instance string) error {
+	return setInstanceFencing(clientset, restconfig, cluster, instance, sqlFence)
+}
+
+// unfenceInstance has the new transactions of an instance no longer be read-only. An instance
+// that no longer exists has nothing to unfence
+func unfenceInstance(clientset *kubernetes.Clientset, restconfig *rest.Config,
+	cluster *crv1.Pgcluster, instance string) error {
+	return setInstanceFencing(clientset, restconfig, cluster, instance, sqlUnfence)
+}
+
+// setInstanceFencing runs the SQL that fences or unfences an instance on its pod, and reloads the
+// configuration of the instance to apply it
+func setInstanceFencing(clientset *kubernetes.Clientset, restconfig *rest.Config,
+	cluster *crv1.Pgcluster, instance, sql string) error {
+	selector := fmt.Sprintf("%s=%s,%s=%s", config.LABEL_PG_CLUSTER, cluster.Name,
+		config.LABEL_DEPLOYMENT_NAME, instance)
+
+	pods, err := kubeapi.GetPods(clientset, selector, cluster.Namespace)
+	if err != nil {
+		return err
+	}
+
+	if len(pods.Items) == 0 {
+		log.Debugf("fencing: no pod found for instance %s of cluster %s", instance, cluster.Name)
+		return nil
+	}
+
+	pod := &pods.Items[0]
+
+	// ALTER SYSTEM cannot run in a transaction, so it is run on its own
+	if _, err := execMaintenanceSQL(clientset, restconfig, pod, "postgres", sql); err != nil {
+		return err
+	}
+
+	_, err = execMaintenanceSQL(clientset, restconfig, pod, "postgres", sqlReloadConf)
+
+	return err
+}
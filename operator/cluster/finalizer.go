@@ -0,0 +1,388 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"fmt"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/config"
+	"github.com/crunchydata/postgres-operator/kubeapi"
+	"github.com/crunchydata/postgres-operator/operator/backrest"
+	"github.com/crunchydata/postgres-operator/util"
+	log "github.com/sirupsen/logrus"
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	// finalBackupPendingMessage is the message set in the status of a deleted cluster with
+	// deletion protection while its final backup is running
+	finalBackupPendingMessage = "deletion pending: waiting on final backup"
+	// finalBackupFailedMessage is the message set in the status of a deleted cluster with
+	// deletion protection when its final backup could not be taken
+	finalBackupFailedMessage = "deletion blocked: final backup failed, set the %q annotation " +
+		"to \"true\" to delete the cluster anyway"
+)
+
+// HasCleanupFinalizer returns whether a cluster is held on to until its resources are removed
+func HasCleanupFinalizer(cluster *crv1.Pgcluster) bool {
+	return hasFinalizer(cluster, config.FINALIZER_CLEANUP)
+}
+
+// HasOperatorFinalizer returns whether a cluster is held on to by any of the finalizers of the
+// Operator, i.e. whether a cluster that is deleted still has something to be removed
+func HasOperatorFinalizer(cluster *crv1.Pgcluster) bool {
+	return HasCleanupFinalizer(cluster) || HasServiceDiscoveryFinalizer(cluster)
+}
+
+// ReconcileCleanupFinalizer adds the cleanup finalizer to a cluster that opted into having its
+// resources removed when its pgcluster is deleted, and removes it from a cluster that opted out
+func ReconcileCleanupFinalizer(restclient *rest.RESTClient, cluster *crv1.Pgcluster) error {
+	return reconcileFinalizer(restclient, cluster, config.FINALIZER_CLEANUP,
+		cluster.Spec.DeletionProtection.Cleanup)
+}
+
+// RemoveCleanupFinalizer removes the cleanup finalizer from a cluster, which lets a cluster that
+// is deleted go
+func RemoveCleanupFinalizer(restclient *rest.RESTClient, cluster *crv1.Pgcluster) error {
+	return removeFinalizer(restclient, cluster, config.FINALIZER_CLEANUP)
+}
+
+// hasFinalizer returns whether a cluster is held on to by the finalizer provided
+func hasFinalizer(cluster *crv1.Pgcluster, name string) bool {
+	for _, finalizer := range cluster.Finalizers {
+		if finalizer == name {
+			return true
+		}
+	}
+	return false
+}
+
+// reconcileFinalizer adds the finalizer provided to a cluster that is not deleted if it is
+// wanted, and removes it if it is not
+func reconcileFinalizer(restclient *rest.RESTClient, cluster *crv1.Pgcluster, name string,
+	wanted bool) error {
+	if cluster.DeletionTimestamp != nil {
+		return nil
+	}
+
+	if !wanted {
+		return removeFinalizer(restclient, cluster, name)
+	}
+
+	if hasFinalizer(cluster, name) {
+		return nil
+	}
+
+	finalizers := append(append([]string{}, cluster.Finalizers...), name)
+
+	return kubeapi.Patchpgcluster(restclient, cluster, cluster.Namespace, func(cluster *crv1.Pgcluster) {
+		cluster.ObjectMeta.Finalizers = finalizers
+	})
+}
+
+// removeFinalizer removes the finalizer provided from a cluster
+func removeFinalizer(restclient *rest.RESTClient, cluster *crv1.Pgcluster, name string) error {
+	if !hasFinalizer(cluster, name) {
+		return nil
+	}
+
+	finalizers := []string{}
+	for _, finalizer := range cluster.Finalizers {
+		if finalizer != name {
+			finalizers = append(finalizers, finalizer)
+		}
+	}
+
+	return kubeapi.Patchpgcluster(restclient, cluster, cluster.Namespace, func(cluster *crv1.Pgcluster) {
+		cluster.ObjectMeta.Finalizers = finalizers
+	})
+}
+
+// FinalizeCluster removes the resources of a cluster whose pgcluster is deleted, in order:
+//
+// 0. if the cluster has deletion protection, its final backup is taken and waited on. If it
+// fails, the removal is blocked until it is forced with the force annotation
+// 1. autofailover is disabled and the schedules of the cluster are removed
+// 2. the replicas are removed, and waited on until their Pods are gone
+// 3. the primary and the other Deployments of the cluster, except for the pgBackRest
+// repository, are removed and waited on, so that no more WAL is archived
+// 4. the pgBackRest repository is removed and waited on
+// 5. the Services, cert-manager Certificates, Prometheus Operator monitors and rules, Jobs,
+// pgtasks and ConfigMaps of the cluster are removed
+// 6. if the data of the cluster is to be removed, its PVCs, the VolumeSnapshots they were
+// created from and its Secrets are removed
+//
+// Every step can be repeated, so the removal returns whether it is done; if it is not, e.g. as
+// it waits for Pods to terminate, it is carried on with once it is called again. Once it is
+// done, the cleanup finalizer can be removed from the cluster
+func FinalizeCluster(clientset *kubernetes.Clientset, restclient *rest.RESTClient, cluster *crv1.Pgcluster) (bool, error) {
+	namespace := cluster.Namespace
+	selector := fmt.Sprintf("%s=%s", config.LABEL_PG_CLUSTER, cluster.Name)
+
+	if done, err := finalBackupDone(clientset, restclient, cluster); !done || err != nil {
+		return false, err
+	}
+
+	log.Infof("cleanup: removing the resources of deleted cluster %s", cluster.Name)
+
+	// autofailover may already be off, or its ConfigMap gone, which is fine
+	if err := util.ToggleAutoFailover(clientset, false, cluster.Labels[config.LABEL_PGHA_SCOPE],
+		namespace); err != nil {
+		log.Debugf("cleanup: could not disable autofailover of cluster %s: %s", cluster.Name, err)
+	}
+
+	if err := kubeapi.DeleteConfigMaps(clientset, "crunchy-scheduler=true,"+selector, namespace); err != nil {
+		return false, err
+	}
+
+	// the pgschedules of the additional pgBackRest repositories are labeled with their index
+	schedules := crv1.PgscheduleList{}
+	if err := kubeapi.GetpgschedulesBySelector(restclient, &schedules,
+		selector+","+config.LABEL_PGBACKREST_REPO_INDEX, namespace); err != nil {
+		return false, err
+	}
+	for _, schedule := range schedules.Items {
+		if err := kubeapi.Deletepgschedule(restclient, schedule.Name, namespace); err != nil &&
+			!kerrors.IsNotFound(err) {
+			return false, err
+		}
+	}
+
+	// the replicas are removed by the pgreplica controller once their pgreplicas are deleted
+	replicas := crv1.PgreplicaList{}
+	if err := kubeapi.GetpgreplicasBySelector(restclient, &replicas, selector, namespace); err != nil {
+		return false, err
+	}
+	for _, replica := range replicas.Items {
+		if err := kubeapi.Deletepgreplica(restclient, replica.Name, namespace); err != nil &&
+			!kerrors.IsNotFound(err) {
+			return false, err
+		}
+	}
+
+	if done, err := podsGone(clientset, fmt.Sprintf("%s,%s=%s", selector, config.LABEL_PGHA_ROLE,
+		config.LABEL_PGHA_ROLE_REPLICA), namespace); !done || err != nil {
+		return false, err
+	}
+
+	if done, err := deploymentsGone(clientset, fmt.Sprintf("%s,%s!=true", selector,
+		config.LABEL_PGO_BACKREST_REPO), namespace); !done || err != nil {
+		return false, err
+	}
+	if done, err := deploymentsGone(clientset, fmt.Sprintf("%s,%s=true", selector,
+		config.LABEL_PGO_BACKREST_REPO), namespace); !done || err != nil {
+		return false, err
+	}
+
+	services, err := kubeapi.GetServices(clientset, selector, namespace)
+	if err != nil {
+		return false, err
+	}
+	for _, service := range services.Items {
+		if err := kubeapi.DeleteService(clientset, service.Name, namespace); err != nil &&
+			!kerrors.IsNotFound(err) {
+			return false, err
+		}
+	}
+
+	if err := DeleteTLSCertificates(clientset, cluster); err != nil {
+		return false, err
+	}
+
+	if err := DeleteMonitor(clientset, cluster); err != nil {
+		return false, err
+	}
+
+	if err := DeleteNetworkPolicies(clientset, cluster); err != nil {
+		return false, err
+	}
+
+	if err := DeletePodDisruptionBudgets(clientset, cluster); err != nil {
+		return false, err
+	}
+
+	if err := DeleteAlerts(clientset, cluster); err != nil {
+		return false, err
+	}
+
+	if err := kubeapi.DeleteJobs(clientset, selector, namespace); err != nil {
+		return false, err
+	}
+
+	if err := kubeapi.Deletepgtasks(restclient, selector, namespace); err != nil {
+		return false, err
+	}
+
+	// the ConfigMaps of Patroni are named after the cluster rather than labeled with it
+	if err := kubeapi.DeleteConfigMaps(clientset, selector, namespace); err != nil {
+		return false, err
+	}
+	for _, suffix := range []string{config.LABEL_PGHA_CONFIGMAP, "leader", "config", "failover"} {
+		name := cluster.Name + "-" + suffix
+		if _, found := kubeapi.GetConfigMap(clientset, name, namespace); !found {
+			continue
+		}
+		if err := kubeapi.DeleteConfigMap(clientset, name, namespace); err != nil {
+			return false, err
+		}
+	}
+
+	if !cluster.Spec.DeletionProtection.RemoveData {
+		log.Infof("cleanup: keeping the PVCs and Secrets of cluster %s", cluster.Name)
+		return true, nil
+	}
+
+	if err := kubeapi.DeletePVCs(clientset, selector, namespace); err != nil {
+		return false, err
+	}
+
+	if err := DeleteVolumeSnapshots(clientset, cluster); err != nil {
+		return false, err
+	}
+
+	// the PVC of the pgBackRest repository may predate it being labeled with the cluster
+	repoName := fmt.Sprintf(backrest.BackrestRepoPVCName, cluster.Name)
+	if _, found, _ := kubeapi.GetPVC(clientset, repoName, namespace); found {
+		if err := kubeapi.DeletePVC(clientset, repoName, namespace); err != nil {
+			return false, err
+		}
+	}
+
+	secrets, err := kubeapi.GetSecrets(clientset, selector, namespace)
+	if err != nil {
+		return false, err
+	}
+	for _, secret := range secrets.Items {
+		if err := kubeapi.DeleteSecret(clientset, secret.Name, namespace); err != nil &&
+			!kerrors.IsNotFound(err) {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+// finalBackupDone returns whether the removal of a deleted cluster can proceed as far as its
+// deletion protection is concerned, i.e. whether it has none, its final backup completed or the
+// removal was forced. The final backup is started if it has not been yet, and the status of the
+// cluster says what the removal waits on
+func finalBackupDone(clientset *kubernetes.Clientset, restclient *rest.RESTClient,
+	cluster *crv1.Pgcluster) (bool, error) {
+	if !cluster.Spec.DeletionProtection.BackupFirst {
+		return true, nil
+	}
+
+	if cluster.Annotations[config.ANNOTATION_DELETION_PROTECTION_FORCE] == "true" {
+		log.Warnf("cleanup: deletion protection of cluster %s overridden by the %s annotation",
+			cluster.Name, config.ANNOTATION_DELETION_PROTECTION_FORCE)
+		return true, nil
+	}
+
+	selector := fmt.Sprintf("%s=%s,%s=%s,%s=%s", config.LABEL_PG_CLUSTER, cluster.Name,
+		config.LABEL_BACKREST_COMMAND, crv1.PgtaskBackrestBackup,
+		config.LABEL_PGHA_BACKUP_TYPE, crv1.BackupTypeFinal)
+
+	jobs, err := kubeapi.GetJobs(clientset, selector, cluster.Namespace)
+	if err != nil {
+		return false, err
+	}
+
+	message := finalBackupPendingMessage
+
+	for _, job := range jobs.Items {
+		if job.Status.CompletionTime != nil {
+			return true, nil
+		}
+
+		for _, condition := range job.Status.Conditions {
+			if condition.Type == batchv1.JobFailed && condition.Status == v1.ConditionTrue {
+				message = fmt.Sprintf(finalBackupFailedMessage,
+					config.ANNOTATION_DELETION_PROTECTION_FORCE)
+			}
+		}
+	}
+
+	// the final backup is started once, i.e. unless its Job or the pgtask that creates it exists
+	task := crv1.Pgtask{}
+	found, _ := kubeapi.Getpgtask(restclient, &task, "backrest-backup-"+cluster.Name,
+		cluster.Namespace)
+
+	if len(jobs.Items) == 0 &&
+		(!found || task.Spec.Parameters[config.LABEL_PGHA_BACKUP_TYPE] != crv1.BackupTypeFinal) {
+		log.Infof("cleanup: taking the final backup of cluster %s before removing it", cluster.Name)
+
+		pod, err := util.GetPrimaryPod(clientset, cluster)
+		if err == nil {
+			err = backrest.CleanBackupResources(restclient, clientset, cluster.Namespace,
+				cluster.Name)
+		}
+		if err == nil {
+			_, err = backrest.CreateFinalBackup(restclient, cluster.Namespace, cluster.Name,
+				pod.Name)
+		}
+		if err != nil {
+			log.Errorf("cleanup: could not take the final backup of cluster %s: %s",
+				cluster.Name, err)
+			message = fmt.Sprintf(finalBackupFailedMessage,
+				config.ANNOTATION_DELETION_PROTECTION_FORCE)
+		}
+	}
+
+	if cluster.Status.Message != message {
+		if err := kubeapi.PatchpgclusterStatus(restclient, cluster.Status.State, message,
+			cluster.DeepCopy(), cluster.Namespace); err != nil {
+			return false, err
+		}
+	}
+
+	return false, nil
+}
+
+// deploymentsGone deletes the Deployments of a selector and returns whether they are all gone.
+// As they are deleted in the foreground, they are only gone once their Pods are gone
+func deploymentsGone(clientset *kubernetes.Clientset, selector, namespace string) (bool, error) {
+	deployments, err := kubeapi.GetDeployments(clientset, selector, namespace)
+	if err != nil {
+		return false, err
+	}
+
+	for _, deployment := range deployments.Items {
+		if deployment.DeletionTimestamp != nil {
+			continue
+		}
+		if err := kubeapi.DeleteDeployment(clientset, deployment.Name, namespace); err != nil &&
+			!kerrors.IsNotFound(err) {
+			return false, err
+		}
+	}
+
+	return len(deployments.Items) == 0, nil
+}
+
+// podsGone returns whether there are no Pods left of a selector
+func podsGone(clientset *kubernetes.Clientset, selector, namespace string) (bool, error) {
+	pods, err := kubeapi.GetPods(clientset, selector, namespace)
+	if err != nil {
+		return false, err
+	}
+
+	return len(pods.Items) == 0, nil
+}
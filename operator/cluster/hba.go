@@ -0,0 +1,186 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/kubeapi"
+	log "github.com/sirupsen/logrus"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// hbaRulesComment marks the rules in pg_hba.conf that come from the spec of the cluster
+	hbaRulesComment = "# hba.rules"
+	// hbaOverridePrefix comments out the default rules in pg_hba.conf while they are
+	// overridden by the rules of the spec, so that they can be restored afterwards
+	hbaOverridePrefix = "# hba.override: "
+)
+
+// hbaConnectionTypes are the types of connections that a rule in pg_hba.conf can match
+var hbaConnectionTypes = map[string]bool{
+	"local":        true,
+	"host":         true,
+	"hostssl":      true,
+	"hostnossl":    true,
+	"hostgssenc":   true,
+	"hostnogssenc": true,
+}
+
+// ValidateHBA returns an error if any of the custom pg_hba rules of a cluster cannot be used,
+// i.e. it does not start with a connection type or is missing its database, user, address or
+// method. The rules are only checked for their shape, PostgreSQL itself refuses to reload rules
+// it cannot parse
+func ValidateHBA(cluster *crv1.Pgcluster) error {
+	hba := cluster.Spec.HBA
+
+	if hba.Override && len(hba.Rules) == 0 {
+		return errors.New("the default pg_hba rules can only be overridden with custom rules")
+	}
+
+	for _, rule := range hba.Rules {
+		if strings.ContainsAny(rule, "\r\n") {
+			return fmt.Errorf("invalid pg_hba rule %q, it must be a single line", rule)
+		}
+
+		fields := strings.Fields(rule)
+
+		if len(fields) == 0 || !hbaConnectionTypes[fields[0]] {
+			return fmt.Errorf("invalid pg_hba rule %q, it must start with a connection type", rule)
+		}
+
+		// a local rule has no address
+		if minimum := map[bool]int{true: 4, false: 5}[fields[0] == "local"]; len(fields) < minimum {
+			return fmt.Errorf("invalid pg_hba rule %q, it is missing fields", rule)
+		}
+	}
+
+	return nil
+}
+
+// ReconcileHBA brings the custom pg_hba rules in the pg_hba.conf that Patroni manages in line
+// with the spec of the cluster. Patroni reloads PostgreSQL when the rules change
+func ReconcileHBA(clientset *kubernetes.Clientset, cluster *crv1.Pgcluster) error {
+	if err := ValidateHBA(cluster); err != nil {
+		return err
+	}
+
+	return updateHBA(clientset, cluster, func(hba []string) []string {
+		return setHBARules(hba, cluster.Spec.HBA)
+	})
+}
+
+// updateHBA applies a change to the pg_hba rules in the configuration that Patroni manages for
+// the cluster. The configuration is only updated if the rules actually change
+func updateHBA(clientset *kubernetes.Clientset, cluster *crv1.Pgcluster,
+	change func(hba []string) []string) error {
+	dcsConfigMap, configJSON, _, err := getDCSParameters(clientset, cluster)
+	if err != nil {
+		return err
+	}
+
+	postgresql := configJSON["postgresql"].(map[string]interface{})
+
+	current, ok := postgresql["pg_hba"].([]interface{})
+	if !ok {
+		return fmt.Errorf("no pg_hba rules found in the configuration of cluster %s", cluster.Name)
+	}
+
+	hba := make([]string, 0, len(current))
+	for _, rule := range current {
+		hba = append(hba, fmt.Sprint(rule))
+	}
+
+	rules := change(hba)
+
+	if strings.Join(rules, "\n") == strings.Join(hba, "\n") {
+		return nil
+	}
+
+	log.Debugf("updating pg_hba rules of cluster %s", cluster.Name)
+
+	postgresql["pg_hba"] = rules
+
+	configJSONStr, err := json.Marshal(configJSON)
+	if err != nil {
+		return err
+	}
+
+	dcsConfigMap.ObjectMeta.Annotations["config"] = string(configJSONStr)
+
+	return kubeapi.UpdateConfigMap(clientset, dcsConfigMap, cluster.Namespace)
+}
+
+// setHBARules returns the pg_hba rules with the custom rules of the spec in place of any that
+// were set before. The custom rules go after the rules for local connections, for client
+// certificate authentication and for the replication user, and before the default rules, so that
+// they take precedence over the defaults but cannot lock out or open up replication. When the
+// defaults are overridden, they are commented out rather than removed, so that they are restored
+// once they are no longer overridden
+func setHBARules(hba []string, spec crv1.HBASpec) []string {
+	rules := []string{}
+	for _, rule := range hba {
+		if strings.HasSuffix(rule, hbaRulesComment) {
+			continue
+		}
+		rules = append(rules, strings.TrimPrefix(rule, hbaOverridePrefix))
+	}
+
+	i := 0
+	for i < len(rules) {
+		if fields := strings.Fields(rules[i]); len(fields) > 0 && fields[0] != "local" &&
+			!strings.HasSuffix(rules[i], tlsClientAuthHBAComment) &&
+			!isReplicationUserHBARule(rules[i]) {
+			break
+		}
+		i++
+	}
+
+	result := append([]string{}, rules[:i]...)
+	for _, rule := range spec.Rules {
+		result = append(result, fmt.Sprintf("%s %s", strings.TrimSpace(rule), hbaRulesComment))
+	}
+
+	for _, rule := range rules[i:] {
+		if spec.Override && !strings.HasPrefix(rule, "#") && !isLocalHBARule(rule) &&
+			!strings.HasSuffix(rule, tlsClientAuthHBAComment) && !isReplicationUserHBARule(rule) {
+			rule = hbaOverridePrefix + rule
+		}
+		result = append(result, rule)
+	}
+
+	return result
+}
+
+// isLocalHBARule returns whether a pg_hba rule matches local connections, i.e. those over a
+// Unix-domain socket
+func isLocalHBARule(rule string) bool {
+	fields := strings.Fields(rule)
+	return len(fields) > 0 && fields[0] == "local"
+}
+
+// isReplicationUserHBARule returns whether a pg_hba rule is for the replication user, i.e. the
+// rule that lets it replicate or the one that keeps it out of the databases. These are never
+// overridden, as the replicas of the cluster depend on them
+func isReplicationUserHBARule(rule string) bool {
+	fields := strings.Fields(rule)
+	return len(fields) > 2 && strings.Trim(fields[2], `"`) == crv1.PGUserReplication
+}
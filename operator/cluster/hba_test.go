@@ -0,0 +1,98 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"reflect"
+	"testing"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+)
+
+func TestSetHBARules(t *testing.T) {
+	defaults := []string{
+		"local all postgres peer",
+		`hostssl all "app" 0.0.0.0/0 cert # tls.clientAuth`,
+		"host replication primaryuser 0.0.0.0/0 md5",
+		"host all primaryuser 0.0.0.0/0 reject",
+		"host all all 0.0.0.0/0 md5",
+	}
+
+	spec := crv1.HBASpec{Rules: []string{
+		"hostssl all all 10.0.0.0/8 md5",
+		" host all all 0.0.0.0/0 reject ",
+	}}
+
+	added := setHBARules(defaults, spec)
+
+	expected := []string{
+		"local all postgres peer",
+		`hostssl all "app" 0.0.0.0/0 cert # tls.clientAuth`,
+		"host replication primaryuser 0.0.0.0/0 md5",
+		"host all primaryuser 0.0.0.0/0 reject",
+		"hostssl all all 10.0.0.0/8 md5 # hba.rules",
+		"host all all 0.0.0.0/0 reject # hba.rules",
+		"host all all 0.0.0.0/0 md5",
+	}
+
+	if !reflect.DeepEqual(added, expected) {
+		t.Errorf("expected %v, got %v", expected, added)
+	}
+
+	spec.Override = true
+	overridden := setHBARules(added, spec)
+
+	expected = []string{
+		"local all postgres peer",
+		`hostssl all "app" 0.0.0.0/0 cert # tls.clientAuth`,
+		"host replication primaryuser 0.0.0.0/0 md5",
+		"host all primaryuser 0.0.0.0/0 reject",
+		"hostssl all all 10.0.0.0/8 md5 # hba.rules",
+		"host all all 0.0.0.0/0 reject # hba.rules",
+		"# hba.override: host all all 0.0.0.0/0 md5",
+	}
+
+	if !reflect.DeepEqual(overridden, expected) {
+		t.Errorf("expected %v, got %v", expected, overridden)
+	}
+
+	if restored := setHBARules(overridden, crv1.HBASpec{}); !reflect.DeepEqual(restored, defaults) {
+		t.Errorf("expected %v, got %v", defaults, restored)
+	}
+}
+
+func TestValidateHBA(t *testing.T) {
+	for _, test := range []struct {
+		name  string
+		spec  crv1.HBASpec
+		valid bool
+	}{
+		{"no rules", crv1.HBASpec{}, true},
+		{"rules", crv1.HBASpec{Rules: []string{"local all all trust", "host all all ::1/128 md5"}}, true},
+		{"override without rules", crv1.HBASpec{Override: true}, false},
+		{"no connection type", crv1.HBASpec{Rules: []string{"all all 0.0.0.0/0 md5"}}, false},
+		{"missing method", crv1.HBASpec{Rules: []string{"host all all 0.0.0.0/0"}}, false},
+		{"several lines", crv1.HBASpec{Rules: []string{"host all all 0.0.0.0/0 md5\nlocal all all trust"}}, false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			cluster := &crv1.Pgcluster{Spec: crv1.PgclusterSpec{HBA: test.spec}}
+
+			if err := ValidateHBA(cluster); (err == nil) != test.valid {
+				t.Errorf("expected valid: %v, got %v", test.valid, err)
+			}
+		})
+	}
+}
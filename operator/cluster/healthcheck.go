@@ -0,0 +1,158 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/kubeapi"
+	"github.com/crunchydata/postgres-operator/util"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	// defaultHealthCheckTimeoutSeconds is how long the health check query may run if no timeout
+	// is set
+	defaultHealthCheckTimeoutSeconds = 10
+	// defaultHealthCheckFailureThreshold is the number of consecutive failures after which the
+	// cluster is marked as degraded if no threshold is set
+	defaultHealthCheckFailureThreshold = 3
+	// healthCheckExecGracePeriod is how much longer than the statement timeout the exec into the
+	// primary may take, e.g. to connect, before the health check is abandoned
+	healthCheckExecGracePeriod = 5 * time.Second
+)
+
+// sqlHealthCheckTimeout bounds how long the health check query can run on the server
+const sqlHealthCheckTimeout = `SET statement_timeout = '%ds';`
+
+// ReconcileHealthCheck runs the custom health check of a cluster on its primary, and records the
+// outcome in the status of the cluster if it changed. A cluster is only ready once its health
+// check passes. As the server may well be healthy while the check fails, a persistently failing
+// check does not mark the cluster as failed, but rather marks its health check as degraded, which
+// is cleared once the check passes again
+func ReconcileHealthCheck(clientset *kubernetes.Clientset, restclient *rest.RESTClient,
+	restconfig *rest.Config, cluster *crv1.Pgcluster) error {
+	healthCheck := cluster.Spec.HealthCheck
+
+	if healthCheck.Query == "" || cluster.Status.State != crv1.PgclusterStateInitialized {
+		return nil
+	}
+
+	timeout := healthCheck.TimeoutSeconds
+	if timeout <= 0 {
+		timeout = defaultHealthCheckTimeoutSeconds
+	}
+
+	threshold := healthCheck.FailureThreshold
+	if threshold <= 0 {
+		threshold = defaultHealthCheckFailureThreshold
+	}
+
+	// the primary is looked up by the namespace in the spec
+	cluster.Spec.Namespace = cluster.Namespace
+
+	status := crv1.HealthCheckStatus{Ready: true}
+
+	pod, err := util.GetPrimaryPod(clientset, cluster)
+	if err == nil {
+		err = runHealthCheck(clientset, restconfig, pod, cluster.Spec.Database, healthCheck.Query, timeout)
+	}
+
+	if err != nil {
+		log.Debugf("health check of cluster %s failed: %s", cluster.Name, err)
+
+		status.Ready = false
+		status.ConsecutiveFailures = cluster.Status.HealthCheck.ConsecutiveFailures + 1
+		status.Message = err.Error()
+	}
+
+	status.Degraded = status.ConsecutiveFailures >= threshold
+
+	current := cluster.Status.HealthCheck
+	status = setHealthCheckTransitionTime(current, status, time.Now())
+
+	if status == current {
+		return nil
+	}
+
+	if status.Degraded && !current.Degraded {
+		log.Warnf("cluster %s is degraded, its health check failed %d times in a row: %s",
+			cluster.Name, status.ConsecutiveFailures, status.Message)
+	}
+
+	return kubeapi.Patchpgcluster(restclient, cluster, cluster.Namespace, func(cluster *crv1.Pgcluster) {
+		cluster.Status.HealthCheck = status
+	})
+}
+
+// setHealthCheckTransitionTime sets the time of the last transition of the outcome of a health
+// check, which is kept from the current outcome for as long as the check keeps passing or failing
+func setHealthCheckTransitionTime(current, status crv1.HealthCheckStatus,
+	now time.Time) crv1.HealthCheckStatus {
+	status.LastTransitionTime = current.LastTransitionTime
+
+	if status.Ready != current.Ready || status.LastTransitionTime == "" {
+		status.LastTransitionTime = now.Format(time.RFC3339)
+	}
+
+	return status
+}
+
+// runHealthCheck runs the health check query in the database, returning an error if the query
+// fails, does not complete within the timeout, or does not return a value other than false
+func runHealthCheck(clientset *kubernetes.Clientset, restconfig *rest.Config, pod *v1.Pod,
+	database, query string, timeout int) error {
+	type result struct {
+		output string
+		err    error
+	}
+
+	sql := fmt.Sprintf(sqlHealthCheckTimeout, timeout) + " " + query
+
+	// the statement timeout covers a hung query, but the exec itself can hang as well, so the
+	// health check is abandoned if it does not return in time
+	done := make(chan result, 1)
+	go func() {
+		output, err := execMaintenanceSQL(clientset, restconfig, pod, database, sql)
+		done <- result{output: output, err: err}
+	}()
+
+	select {
+	case r := <-done:
+		if r.err != nil {
+			return r.err
+		}
+
+		if r.output == "" {
+			return errors.New("query returned no rows")
+		}
+
+		if value := strings.SplitN(strings.SplitN(r.output, "\n", 2)[0], "|", 2)[0]; value == "f" {
+			return errors.New("query returned false")
+		}
+
+		return nil
+	case <-time.After(time.Duration(timeout)*time.Second + healthCheckExecGracePeriod):
+		return fmt.Errorf("query did not complete within %d seconds", timeout)
+	}
+}
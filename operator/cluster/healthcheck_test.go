@@ -0,0 +1,51 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"testing"
+	"time"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+)
+
+func TestSetHealthCheckTransitionTime(t *testing.T) {
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	earlier := "2020-06-01T11:00:00Z"
+
+	for _, test := range []struct {
+		name            string
+		current, status crv1.HealthCheckStatus
+		expected        string
+	}{
+		{"first check", crv1.HealthCheckStatus{}, crv1.HealthCheckStatus{Ready: true},
+			now.Format(time.RFC3339)},
+		{"still passing", crv1.HealthCheckStatus{Ready: true, LastTransitionTime: earlier},
+			crv1.HealthCheckStatus{Ready: true}, earlier},
+		{"still failing", crv1.HealthCheckStatus{ConsecutiveFailures: 1, LastTransitionTime: earlier},
+			crv1.HealthCheckStatus{ConsecutiveFailures: 2}, earlier},
+		{"started failing", crv1.HealthCheckStatus{Ready: true, LastTransitionTime: earlier},
+			crv1.HealthCheckStatus{ConsecutiveFailures: 1}, now.Format(time.RFC3339)},
+		{"passing again", crv1.HealthCheckStatus{Degraded: true, LastTransitionTime: earlier},
+			crv1.HealthCheckStatus{Ready: true}, now.Format(time.RFC3339)},
+	} {
+		status := setHealthCheckTransitionTime(test.current, test.status, now)
+
+		if status.LastTransitionTime != test.expected {
+			t.Errorf("%s: expected %q, got %q", test.name, test.expected, status.LastTransitionTime)
+		}
+	}
+}
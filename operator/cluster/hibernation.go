@@ -0,0 +1,165 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"fmt"
+	"time"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/kubeapi"
+	"github.com/crunchydata/postgres-operator/operator"
+	"github.com/crunchydata/postgres-operator/util"
+	log "github.com/sirupsen/logrus"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	// eventReasonHibernationShutdown is the reason of the Normal events that are recorded when a
+	// cluster is shut down on its hibernation schedule
+	eventReasonHibernationShutdown = "HibernationShutdown"
+	// eventReasonHibernationStartup is the reason of the Normal events that are recorded when a
+	// cluster is started back up on its hibernation schedule
+	eventReasonHibernationStartup = "HibernationStartup"
+)
+
+// ValidateHibernationSchedule returns an error if the hibernation schedule of a cluster does not
+// parse, or is in a time zone that is not known
+func ValidateHibernationSchedule(cluster *crv1.Pgcluster) error {
+	spec := cluster.Spec.HibernationSchedule
+
+	for _, schedule := range []struct{ name, spec string }{
+		{"shutdown", spec.Shutdown},
+		{"startup", spec.Startup},
+	} {
+		if schedule.spec == "" {
+			continue
+		}
+		if _, err := util.ScheduleParser.Parse(schedule.spec); err != nil {
+			return fmt.Errorf("%q is not a valid %s schedule: %s", schedule.spec, schedule.name, err)
+		}
+	}
+
+	if spec.Shutdown != "" && spec.Shutdown == spec.Startup {
+		return fmt.Errorf("the shutdown and startup schedules cannot both be %q", spec.Shutdown)
+	}
+
+	if _, err := time.LoadLocation(spec.GetTimeZone()); err != nil {
+		return fmt.Errorf("invalid hibernation time zone %q: %s", spec.TimeZone, err)
+	}
+
+	return nil
+}
+
+// ReconcileHibernationSchedule shuts a cluster down or starts it back up once its hibernation
+// schedule comes up, by setting its Shutdown, which the update of the cluster then carries out.
+// Only a cluster that is up or shut down is acted on, and not e.g. one that is being restored
+func ReconcileHibernationSchedule(clientset *kubernetes.Clientset, restclient *rest.RESTClient,
+	cluster *crv1.Pgcluster) error {
+	if cluster.Status.State != crv1.PgclusterStateInitialized &&
+		cluster.Status.State != crv1.PgclusterStateShutdown {
+		return nil
+	}
+
+	now := time.Now()
+	action, err := getHibernationAction(cluster.Spec.HibernationSchedule,
+		cluster.Status.Hibernation.LastScheduleTime, cluster.CreationTimestamp.Time, now)
+	if err != nil || action == "" {
+		return err
+	}
+
+	// a cluster that is already as the schedule calls for, e.g. as it was shut down by hand, is
+	// left be, and only the schedule is recorded
+	if shutdown := action == crv1.HibernationActionShutdown; cluster.Spec.Shutdown != shutdown {
+		log.Infof("hibernation: %s of cluster %s is scheduled", action, cluster.Name)
+
+		cluster.Spec.Shutdown = shutdown
+
+		if err := kubeapi.Updatepgcluster(restclient, cluster, cluster.Name,
+			cluster.Namespace); err != nil {
+			return err
+		}
+
+		if shutdown {
+			operator.RecordNormalEvent(clientset, cluster, eventReasonHibernationShutdown,
+				"shutting down the cluster on its hibernation schedule")
+		} else {
+			operator.RecordNormalEvent(clientset, cluster, eventReasonHibernationStartup,
+				"starting up the cluster on its hibernation schedule")
+		}
+	}
+
+	status := crv1.HibernationStatus{
+		LastScheduleTime: now.UTC().Format(time.RFC3339),
+		LastAction:       action,
+	}
+
+	return kubeapi.Patchpgcluster(restclient, cluster, cluster.Namespace, func(cluster *crv1.Pgcluster) {
+		cluster.Status.Hibernation = status
+	})
+}
+
+// getHibernationAction returns what the hibernation schedule of a cluster calls for at the given
+// time, if anything: whichever of the shutdown and the startup last came up since the schedule
+// was last acted on, or since the cluster was created if it has not been. The schedules are
+// evaluated in their time zone
+func getHibernationAction(spec crv1.HibernationScheduleSpec, lastScheduleTime string,
+	created, now time.Time) (crv1.HibernationAction, error) {
+	location, err := time.LoadLocation(spec.GetTimeZone())
+	if err != nil {
+		return "", err
+	}
+
+	last := created
+	if lastScheduleTime != "" {
+		if last, err = time.Parse(time.RFC3339, lastScheduleTime); err != nil {
+			return "", err
+		}
+	}
+
+	var action crv1.HibernationAction
+	var latest time.Time
+
+	for _, schedule := range []struct {
+		spec   string
+		action crv1.HibernationAction
+	}{
+		{spec.Shutdown, crv1.HibernationActionShutdown},
+		{spec.Startup, crv1.HibernationActionStartup},
+	} {
+		if schedule.spec == "" {
+			continue
+		}
+
+		sched, err := util.ScheduleParser.Parse(schedule.spec)
+		if err != nil {
+			return "", err
+		}
+
+		// the schedule may have come up several times, e.g. while the Operator was not running.
+		// A schedule that never comes up has no next time at all
+		next := sched.Next(last.In(location))
+		for !next.IsZero() && !next.After(now) {
+			if next.After(latest) {
+				action, latest = schedule.action, next
+			}
+			next = sched.Next(next)
+		}
+	}
+
+	return action, nil
+}
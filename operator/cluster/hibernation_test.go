@@ -0,0 +1,64 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"testing"
+	"time"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+)
+
+func TestGetHibernationAction(t *testing.T) {
+	// 2020-06-01 is a Monday, and Paris is two hours ahead of UTC in June
+	weekdays := crv1.HibernationScheduleSpec{
+		Shutdown: "0 20 * * 1-5",
+		Startup:  "0 7 * * 1-5",
+		TimeZone: "Europe/Paris",
+	}
+	created := time.Date(2020, 6, 1, 8, 0, 0, 0, time.UTC)
+
+	for _, test := range []struct {
+		name   string
+		spec   crv1.HibernationScheduleSpec
+		last   string
+		now    time.Time
+		action crv1.HibernationAction
+	}{
+		{"not yet", weekdays, "", time.Date(2020, 6, 1, 17, 59, 0, 0, time.UTC), ""},
+		{"evening", weekdays, "", time.Date(2020, 6, 1, 18, 0, 0, 0, time.UTC),
+			crv1.HibernationActionShutdown},
+		{"morning", weekdays, "2020-06-01T18:00:00Z", time.Date(2020, 6, 2, 5, 0, 0, 0, time.UTC),
+			crv1.HibernationActionStartup},
+		{"weekend", weekdays, "2020-06-05T18:00:00Z", time.Date(2020, 6, 6, 10, 0, 0, 0, time.UTC), ""},
+		{"missed", weekdays, "2020-06-01T18:00:00Z", time.Date(2020, 6, 3, 12, 0, 0, 0, time.UTC),
+			crv1.HibernationActionStartup},
+		{"UTC", crv1.HibernationScheduleSpec{Shutdown: "0 20 * * *"}, "",
+			time.Date(2020, 6, 1, 20, 0, 0, 0, time.UTC), crv1.HibernationActionShutdown},
+		{"shutdown only", crv1.HibernationScheduleSpec{Shutdown: "0 20 * * *"}, "2020-06-01T20:00:00Z",
+			time.Date(2020, 6, 2, 12, 0, 0, 0, time.UTC), ""},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			action, err := getHibernationAction(test.spec, test.last, created, test.now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if action != test.action {
+				t.Fatalf("expected action %q, got %q", test.action, action)
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/operator"
+	log "github.com/sirupsen/logrus"
+	apps_v1 "k8s.io/api/apps/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// UpdateInstancePod rolls out the instances of a cluster again if the containers, init
+// containers, volumes or volume mounts of its instance pod spec changed since they were last
+// rolled out
+func UpdateInstancePod(clientset *kubernetes.Clientset, restconfig *rest.Config,
+	cluster *crv1.Pgcluster) error {
+	if err := operator.ValidateInstancePod(cluster); err != nil {
+		return err
+	}
+
+	deployments, err := operator.GetInstanceDeployments(clientset, cluster)
+	if err != nil {
+		return err
+	}
+
+	return updateDeployments(clientset, restconfig, deployments.Items, func(deployment *apps_v1.Deployment) bool {
+		if !operator.SetInstancePod(cluster, &deployment.Spec.Template) {
+			return false
+		}
+
+		log.Debugf("instance pod: rolling out %s with its updated containers and volumes", deployment.Name)
+
+		return true
+	})
+}
@@ -0,0 +1,156 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"fmt"
+	"regexp"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/kubeapi"
+	"github.com/crunchydata/postgres-operator/operator"
+	log "github.com/sirupsen/logrus"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+var (
+	// localeFormat is what a locale or an encoding may consist of, e.g.
+	// "en_US.UTF-8" or "UTF8". This keeps them from being taken as anything
+	// other than the value of an option of initdb
+	localeFormat = regexp.MustCompile(`^[A-Za-z0-9_.@-]*$`)
+	// timezoneFormat is what a time zone may consist of, e.g. "Europe/Paris",
+	// "UTC" or "Etc/GMT+2"
+	timezoneFormat = regexp.MustCompile(`^[A-Za-z0-9_+/-]*$`)
+)
+
+// ValidateLocale returns an error if the locale or the time zone of a cluster
+// cannot be used to initialize it
+func ValidateLocale(cluster *crv1.Pgcluster) error {
+	settings := map[string]string{
+		"collate":  cluster.Spec.Locale.Collate,
+		"ctype":    cluster.Spec.Locale.Ctype,
+		"encoding": cluster.Spec.Locale.Encoding,
+	}
+
+	for name, value := range settings {
+		if !localeFormat.MatchString(value) {
+			return fmt.Errorf("invalid locale %s %q", name, value)
+		}
+	}
+
+	if !timezoneFormat.MatchString(cluster.Spec.Timezone) {
+		return fmt.Errorf("invalid timezone %q", cluster.Spec.Timezone)
+	}
+
+	return nil
+}
+
+// ReconcileLocale checks that the locale of a cluster is the one its data
+// directory was initialized with. The locale cannot be changed in place, so a
+// change of it is refused: it is recorded as an error in the status of the
+// cluster, along with a Warning event, and the cluster is left as it is. The
+// error is cleared once the locale is changed back
+func ReconcileLocale(clientset *kubernetes.Clientset, restclient *rest.RESTClient, cluster *crv1.Pgcluster) error {
+	status := cluster.Status.Locale
+	status.Error = ""
+
+	if err := validateLocaleChange(cluster); err != nil {
+		log.Errorf("locale: cluster %s: %s", cluster.Name, err)
+		operator.RecordWarningEvent(clientset, cluster, "LocaleImmutable", err.Error())
+		status.Error = err.Error()
+	}
+
+	if status == cluster.Status.Locale {
+		return nil
+	}
+
+	return kubeapi.Patchpgcluster(restclient, cluster, cluster.Namespace, func(cluster *crv1.Pgcluster) {
+		cluster.Status.Locale = status
+	})
+}
+
+// ReconcileTimezone sets the "timezone" of PostgreSQL to the time zone of a
+// cluster, if it is set. The time zone is applied with a reload, so this does
+// not restart PostgreSQL
+func ReconcileTimezone(clientset *kubernetes.Clientset, restconfig *rest.Config, cluster *crv1.Pgcluster) error {
+	if cluster.Spec.Timezone == "" {
+		return nil
+	}
+
+	if !timezoneFormat.MatchString(cluster.Spec.Timezone) {
+		return fmt.Errorf("invalid timezone %q", cluster.Spec.Timezone)
+	}
+
+	current, err := GetPostgreSQLParameters(clientset, cluster)
+	if err != nil {
+		return err
+	}
+
+	parameters := getTimezoneParameters(cluster, current)
+	if len(parameters) == 0 {
+		return nil
+	}
+
+	log.Debugf("locale: setting the timezone of cluster %s to %s", cluster.Name, cluster.Spec.Timezone)
+
+	restarted, err := UpdatePostgreSQLParameters(clientset, restconfig, cluster, parameters)
+	if restarted {
+		log.Warnf("locale: cluster %s was restarted to apply its timezone", cluster.Name)
+	}
+
+	return err
+}
+
+// getTimezoneParameters returns the PostgreSQL parameters that apply the time
+// zone of a cluster, given the current ones. There are none if the time zone
+// is not set or is already applied
+func getTimezoneParameters(cluster *crv1.Pgcluster, current map[string]string) map[string]string {
+	if cluster.Spec.Timezone == "" || current["timezone"] == cluster.Spec.Timezone {
+		return map[string]string{}
+	}
+
+	return map[string]string{"timezone": cluster.Spec.Timezone}
+}
+
+// validateLocaleChange returns an error if the locale of a cluster differs
+// from the one its data directory was initialized with
+func validateLocaleChange(cluster *crv1.Pgcluster) error {
+	initialized := cluster.Status.Locale.Initialized
+	requested := cluster.Spec.Locale
+
+	if requested == initialized {
+		return nil
+	}
+
+	return fmt.Errorf("the locale cannot be changed from %s to %s once the cluster is initialized; "+
+		"dump the databases and restore them into a new cluster with the new locale instead",
+		describeLocale(initialized), describeLocale(requested))
+}
+
+// describeLocale returns a locale in the form that is used in the status and
+// in the events of a cluster
+func describeLocale(locale crv1.LocaleSpec) string {
+	value := func(v string) string {
+		if v == "" {
+			return "default"
+		}
+		return v
+	}
+
+	return fmt.Sprintf("collate=%s ctype=%s encoding=%s",
+		value(locale.Collate), value(locale.Ctype), value(locale.Encoding))
+}
@@ -0,0 +1,119 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+)
+
+func TestValidateLocaleChange(t *testing.T) {
+	initialized := crv1.LocaleSpec{Collate: "de_DE.UTF-8", Ctype: "de_DE.UTF-8", Encoding: "UTF8"}
+
+	tests := []struct {
+		name     string
+		locale   crv1.LocaleSpec
+		rejected bool
+	}{
+		{"unchanged", initialized, false},
+		{"collate changed", crv1.LocaleSpec{Collate: "en_US.UTF-8", Ctype: "de_DE.UTF-8", Encoding: "UTF8"}, true},
+		{"ctype changed", crv1.LocaleSpec{Collate: "de_DE.UTF-8", Ctype: "C", Encoding: "UTF8"}, true},
+		{"encoding changed", crv1.LocaleSpec{Collate: "de_DE.UTF-8", Ctype: "de_DE.UTF-8", Encoding: "LATIN1"}, true},
+		{"removed", crv1.LocaleSpec{}, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cluster := &crv1.Pgcluster{
+				Spec:   crv1.PgclusterSpec{Locale: test.locale},
+				Status: crv1.PgclusterStatus{Locale: crv1.LocaleStatus{Initialized: initialized}},
+			}
+
+			err := validateLocaleChange(cluster)
+
+			if !test.rejected {
+				if err != nil {
+					t.Fatalf("expected the locale to be accepted, got %s", err)
+				}
+				return
+			}
+
+			if err == nil {
+				t.Fatalf("expected the locale change to be rejected")
+			}
+
+			// the error has to tell what to do instead
+			if !strings.Contains(err.Error(), "dump") || !strings.Contains(err.Error(), "restore") {
+				t.Fatalf("expected the error to explain that a dump and restore is required, got %s", err)
+			}
+		})
+	}
+
+	// a cluster that was initialized before its locale was recorded has the defaults of initdb
+	if err := validateLocaleChange(&crv1.Pgcluster{}); err != nil {
+		t.Fatalf("expected the default locale to be accepted, got %s", err)
+	}
+}
+
+func TestGetTimezoneParameters(t *testing.T) {
+	tests := []struct {
+		timezone string
+		current  map[string]string
+		expected map[string]string
+	}{
+		// a time zone that is not set is left as it is
+		{"", map[string]string{"timezone": "UTC"}, map[string]string{}},
+		{"Europe/Paris", map[string]string{"timezone": "UTC"}, map[string]string{"timezone": "Europe/Paris"}},
+		{"Europe/Paris", map[string]string{}, map[string]string{"timezone": "Europe/Paris"}},
+		// nothing is reloaded if the time zone is already applied
+		{"Europe/Paris", map[string]string{"timezone": "Europe/Paris"}, map[string]string{}},
+	}
+
+	for i, test := range tests {
+		cluster := &crv1.Pgcluster{Spec: crv1.PgclusterSpec{Timezone: test.timezone}}
+
+		parameters := getTimezoneParameters(cluster, test.current)
+		if !reflect.DeepEqual(parameters, test.expected) {
+			t.Fatalf("tests[%d] - unexpected parameters. expected %v, got %v", i, test.expected, parameters)
+		}
+	}
+}
+
+func TestValidateLocale(t *testing.T) {
+	valid := &crv1.Pgcluster{Spec: crv1.PgclusterSpec{
+		Locale:   crv1.LocaleSpec{Collate: "en_US.UTF-8", Ctype: "sr_RS.UTF-8@latin", Encoding: "UTF8"},
+		Timezone: "America/Argentina/Buenos_Aires",
+	}}
+
+	if err := ValidateLocale(valid); err != nil {
+		t.Fatalf("expected the locale to be valid, got %s", err)
+	}
+
+	invalid := []crv1.PgclusterSpec{
+		{Locale: crv1.LocaleSpec{Collate: `en_US", "x": "`}},
+		{Locale: crv1.LocaleSpec{Encoding: "UTF 8"}},
+		{Timezone: "UTC'; DROP"},
+	}
+
+	for i, spec := range invalid {
+		if err := ValidateLocale(&crv1.Pgcluster{Spec: spec}); err == nil {
+			t.Fatalf("invalid[%d] - expected the locale to be rejected", i)
+		}
+	}
+}
@@ -0,0 +1,426 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/config"
+	"github.com/crunchydata/postgres-operator/kubeapi"
+	"github.com/crunchydata/postgres-operator/operator"
+	"github.com/crunchydata/postgres-operator/util"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	// MaintenanceScheduleName is the name of both the schedule ConfigMap that
+	// is picked up by the pgo-scheduler, as well as the pgtask that the
+	// scheduler creates each time the maintenance is due
+	MaintenanceScheduleName = "%s-maintenance"
+	// MaintenanceScheduleType is the type of schedule understood by the
+	// pgo-scheduler for routine maintenance
+	MaintenanceScheduleType = "maintenance"
+	// defaultMaintenanceMaxActiveConnections is the number of active connections
+	// above which maintenance is skipped, if not set on the cluster
+	defaultMaintenanceMaxActiveConnections = 10
+)
+
+const (
+	// sqlMaintenanceActiveConnections returns the number of client connections
+	// that are currently running a query, not counting this one
+	sqlMaintenanceActiveConnections = `SELECT count(*) FROM pg_catalog.pg_stat_activity
+WHERE state = 'active' AND backend_type = 'client backend' AND pid <> pg_catalog.pg_backend_pid();`
+	// sqlMaintenanceActiveConnections96 is sqlMaintenanceActiveConnections for
+	// PostgreSQL 9.5 and 9.6, which only list the client connections in
+	// pg_stat_activity and do not have its backend_type
+	sqlMaintenanceActiveConnections96 = `SELECT count(*) FROM pg_catalog.pg_stat_activity
+WHERE state = 'active' AND pid <> pg_catalog.pg_backend_pid();`
+	// sqlMaintenanceDatabases returns all of the databases that can be connected to
+	sqlMaintenanceDatabases = `SELECT datname FROM pg_catalog.pg_database
+WHERE datallowconn AND NOT datistemplate;`
+	// sqlMaintenanceInvalidIndexes returns the invalid indexes that are left behind
+	// when a "REINDEX CONCURRENTLY" fails, which PostgreSQL suffixes with either
+	// "_ccnew" or "_ccold"
+	sqlMaintenanceInvalidIndexes = `SELECT pg_catalog.format('%I.%I', n.nspname, c.relname)
+FROM pg_catalog.pg_index i
+JOIN pg_catalog.pg_class c ON c.oid = i.indexrelid
+JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace
+WHERE NOT i.indisvalid AND c.relname ~ '_cc(new|old)[0-9]*$';`
+	sqlMaintenanceDropIndex = `DROP INDEX CONCURRENTLY IF EXISTS %s;`
+	sqlMaintenanceVacuum    = `VACUUM (ANALYZE);`
+	sqlMaintenanceReindex   = `REINDEX DATABASE CONCURRENTLY %s;`
+)
+
+// maintenanceReindexVersions are the major versions of PostgreSQL that cannot
+// reindex a database concurrently, which PostgreSQL 12 introduced
+var maintenanceReindexVersions = map[string]bool{
+	"9.5": true,
+	"9.6": true,
+	"10":  true,
+	"11":  true,
+}
+
+// maintenanceSchedule follows the format of the schedules that are read by the
+// pgo-scheduler
+type maintenanceSchedule struct {
+	Version   string `json:"version"`
+	Name      string `json:"name"`
+	Created   string `json:"created"`
+	Schedule  string `json:"schedule"`
+	Namespace string `json:"namespace"`
+	Type      string `json:"type"`
+	Cluster   string `json:"cluster"`
+}
+
+// ValidateMaintenance returns an error if the routine maintenance of a cluster
+// cannot be run as it is specified, i.e. on a schedule that the pgo-scheduler
+// cannot parse, or with a reindex on a version of PostgreSQL before 12. A custom
+// image tag that the version cannot be told from is left alone
+func ValidateMaintenance(cluster *crv1.Pgcluster) error {
+	spec := cluster.Spec.Maintenance
+
+	if spec.Schedule != "" {
+		if _, err := util.ScheduleParser.Parse(spec.Schedule); err != nil {
+			return fmt.Errorf("%q is not a valid maintenance schedule: %s", spec.Schedule, err)
+		}
+	}
+
+	if version := getPGMajorVersion(cluster.Spec.CCPImageTag); spec.Reindex &&
+		maintenanceReindexVersions[version] {
+		return fmt.Errorf("reindexing during maintenance requires PostgreSQL 12 or later, not %s",
+			version)
+	}
+
+	return nil
+}
+
+// UpdateMaintenanceSchedule reconciles the maintenance schedule of a cluster
+// with the ConfigMap that is used by the pgo-scheduler to create the
+// maintenance pgtasks. As the scheduler does not pick up on modifications to a
+// schedule, any existing schedule is removed and then recreated
+func UpdateMaintenanceSchedule(clientset *kubernetes.Clientset, cluster *crv1.Pgcluster, namespace string) error {
+	name := fmt.Sprintf(MaintenanceScheduleName, cluster.Name)
+
+	if _, found := kubeapi.GetConfigMap(clientset, name, namespace); found {
+		if err := kubeapi.DeleteConfigMap(clientset, name, namespace); err != nil {
+			return err
+		}
+	}
+
+	// if there is no longer a schedule, we are done
+	if cluster.Spec.Maintenance.Schedule == "" {
+		log.Debugf("no maintenance schedule for cluster %s", cluster.Name)
+		return nil
+	}
+
+	schedule := maintenanceSchedule{
+		Version:   "v1",
+		Name:      name,
+		Created:   time.Now().Format(time.RFC3339),
+		Schedule:  cluster.Spec.Maintenance.Schedule,
+		Namespace: namespace,
+		Type:      MaintenanceScheduleType,
+		Cluster:   cluster.Name,
+	}
+
+	blob, err := json.Marshal(schedule)
+	if err != nil {
+		return err
+	}
+
+	configMap := &v1.ConfigMap{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name: name,
+			Labels: map[string]string{
+				config.LABEL_PG_CLUSTER:        cluster.Name,
+				config.LABEL_CRUNCHY_SCHEDULER: config.LABEL_TRUE,
+			},
+		},
+		Data: map[string]string{
+			name: string(blob),
+		},
+	}
+
+	log.Debugf("creating maintenance schedule %s for cluster %s", cluster.Spec.Maintenance.Schedule,
+		cluster.Name)
+
+	return kubeapi.CreateConfigMap(clientset, configMap, namespace)
+}
+
+// RunMaintenance handles a maintenance pgtask, which runs a "VACUUM (ANALYZE)"
+// and optionally a "REINDEX DATABASE CONCURRENTLY" on every database in the
+// cluster. Given that this can take some time, the maintenance is run in the
+// background so as to not hold up the processing of other pgtasks
+func RunMaintenance(clientset *kubernetes.Clientset, restclient *rest.RESTClient, restconfig *rest.Config,
+	task *crv1.Pgtask, namespace string) {
+	// have a guard -- if the task is completed, don't proceed furter
+	if task.Spec.Status == crv1.CompletedStatus {
+		log.Warn(fmt.Sprintf("pgtask [%s] has already completed", task.Spec.Name))
+		return
+	}
+
+	clusterName := task.Spec.Parameters[config.LABEL_PG_CLUSTER]
+
+	cluster := crv1.Pgcluster{}
+	if _, err := kubeapi.Getpgcluster(restclient, &cluster, clusterName, namespace); err != nil {
+		log.Error(err)
+		return
+	}
+
+	// the primary is looked up by the namespace in the spec
+	cluster.Spec.Namespace = namespace
+
+	go runMaintenance(clientset, restclient, restconfig, cluster, task.Spec.Name, namespace)
+}
+
+// runMaintenance performs the maintenance of every database in the cluster and
+// records the outcome in the status of the pgcluster
+func runMaintenance(clientset *kubernetes.Clientset, restclient *rest.RESTClient, restconfig *rest.Config,
+	cluster crv1.Pgcluster, taskName, namespace string) {
+	start := time.Now()
+	status := crv1.MaintenanceStatus{
+		LastRun: start.Format(time.RFC3339),
+	}
+
+	result, err := maintainCluster(clientset, restconfig, &cluster)
+	status.Result = result
+	status.Duration = time.Since(start).Round(time.Second).String()
+
+	log.Debugf("maintenance of cluster %s finished in %s: %s", cluster.Name, status.Duration, status.Result)
+
+	// get the latest version of the cluster before updating its status
+	if _, err := kubeapi.Getpgcluster(restclient, &cluster, cluster.Name, namespace); err != nil {
+		log.Error(err)
+		return
+	}
+
+	if err := kubeapi.Patchpgcluster(restclient, &cluster, namespace, func(cluster *crv1.Pgcluster) {
+		cluster.Status.Maintenance = status
+	}); err != nil {
+		log.Error(err)
+	}
+
+	if err := util.Patch(restclient, patchURL, crv1.CompletedStatus, patchResource, taskName, namespace); err != nil {
+		log.Error(err)
+	}
+
+	// the maintenance failed if any of its steps did, even if it went on with
+	// the other databases
+	phase := crv1.PgtaskPhaseSucceeded
+	if err != nil {
+		phase = crv1.PgtaskPhaseFailed
+	}
+	operator.UpdateTaskPhase(restclient, taskName, namespace, phase, status.Result)
+}
+
+// maintainCluster runs the maintenance against the primary of the cluster and
+// returns a summary of how it went, along with an error if any of it failed.
+// Maintenance backs off if the cluster is too busy, and invalid indexes left
+// behind by an earlier failed reindex are removed before anything else is done
+func maintainCluster(clientset *kubernetes.Clientset, restconfig *rest.Config, cluster *crv1.Pgcluster) (string, error) {
+	pod, err := util.GetPrimaryPod(clientset, cluster)
+	if err != nil {
+		log.Error(err)
+		return fmt.Sprintf("failed: %s", err.Error()), err
+	}
+
+	// check on the load of the cluster before starting
+	maxActiveConnections := cluster.Spec.Maintenance.MaxActiveConnections
+	if maxActiveConnections <= 0 {
+		maxActiveConnections = defaultMaintenanceMaxActiveConnections
+	}
+
+	output, err := execMaintenanceSQL(clientset, restconfig, pod, "postgres",
+		getMaintenanceActiveConnectionsSQL(cluster))
+	if err != nil {
+		return fmt.Sprintf("failed: %s", err.Error()), err
+	}
+
+	activeConnections, err := strconv.Atoi(output)
+	if err != nil {
+		return fmt.Sprintf("failed: could not determine active connections: %s", err.Error()), err
+	}
+
+	if activeConnections > maxActiveConnections {
+		return fmt.Sprintf("skipped: %d active connections exceeds the maximum of %d",
+			activeConnections, maxActiveConnections), nil
+	}
+
+	output, err = execMaintenanceSQL(clientset, restconfig, pod, "postgres", sqlMaintenanceDatabases)
+	if err != nil {
+		return fmt.Sprintf("failed: %s", err.Error()), err
+	}
+
+	errs := []string{}
+
+	for _, database := range strings.Split(output, "\n") {
+		if database == "" {
+			continue
+		}
+
+		if err := maintainDatabase(clientset, restconfig, pod, database, cluster.Spec.Maintenance.Reindex); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %s", database, err.Error()))
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Sprintf("completed with errors: %s", strings.Join(errs, "; ")),
+			errors.New(strings.Join(errs, "; "))
+	}
+
+	return "completed", nil
+}
+
+// getMaintenanceActiveConnectionsSQL returns the query for the active client
+// connections that the version of PostgreSQL of the cluster can run. A custom
+// image tag that the version cannot be told from is taken to be PostgreSQL 10
+// or later
+func getMaintenanceActiveConnectionsSQL(cluster *crv1.Pgcluster) string {
+	switch getPGMajorVersion(cluster.Spec.CCPImageTag) {
+	case "9.5", "9.6":
+		return sqlMaintenanceActiveConnections96
+	}
+
+	return sqlMaintenanceActiveConnections
+}
+
+// maintainDatabase vacuums, and if requested reindexes, a single database. Any
+// invalid indexes from a failed concurrent reindex are dropped both before and
+// after the reindex
+func maintainDatabase(clientset *kubernetes.Clientset, restconfig *rest.Config, pod *v1.Pod,
+	database string, reindex bool) error {
+	if err := dropInvalidIndexes(clientset, restconfig, pod, database); err != nil {
+		return err
+	}
+
+	if _, err := execMaintenanceSQL(clientset, restconfig, pod, database, sqlMaintenanceVacuum); err != nil {
+		return err
+	}
+
+	if !reindex {
+		return nil
+	}
+
+	sql := fmt.Sprintf(sqlMaintenanceReindex, util.SQLQuoteIdentifier(database))
+	_, reindexErr := execMaintenanceSQL(clientset, restconfig, pod, database, sql)
+
+	// whether or not the reindex succeeded, make sure nothing invalid is left
+	// behind
+	if err := dropInvalidIndexes(clientset, restconfig, pod, database); err != nil {
+		log.Error(err)
+	}
+
+	return reindexErr
+}
+
+// dropInvalidIndexes removes any of the invalid indexes that are left over
+// from a "REINDEX CONCURRENTLY" that failed
+func dropInvalidIndexes(clientset *kubernetes.Clientset, restconfig *rest.Config, pod *v1.Pod, database string) error {
+	output, err := execMaintenanceSQL(clientset, restconfig, pod, database, sqlMaintenanceInvalidIndexes)
+	if err != nil {
+		return err
+	}
+
+	for _, index := range strings.Split(output, "\n") {
+		if index == "" {
+			continue
+		}
+
+		log.Debugf("dropping invalid index %s in database %s", index, database)
+
+		// the index name has already been quoted by the query above
+		if _, err := execMaintenanceSQL(clientset, restconfig, pod, database,
+			fmt.Sprintf(sqlMaintenanceDropIndex, index)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// walSQLReplacer renames the WAL functions and columns of PostgreSQL 10 and later to those of
+// PostgreSQL 9.5 and 9.6, which call the WAL "xlog" and a position in it a "location"
+var walSQLReplacer = strings.NewReplacer(
+	"pg_current_wal_lsn", "pg_current_xlog_location",
+	"pg_last_wal_receive_lsn", "pg_last_xlog_receive_location",
+	"pg_last_wal_replay_lsn", "pg_last_xlog_replay_location",
+	"pg_wal_lsn_diff", "pg_xlog_location_diff",
+	"replay_lsn", "replay_location",
+)
+
+// getWALSQL returns a statement that is written with the WAL functions of PostgreSQL 10 and
+// later as the cluster can run it, i.e. with those of PostgreSQL 9.5 and 9.6 if it runs either.
+// A custom image tag that the version cannot be told from is taken to be PostgreSQL 10 or later
+func getWALSQL(cluster *crv1.Pgcluster, sql string) string {
+	switch getPGMajorVersion(cluster.Spec.CCPImageTag) {
+	case "9.5", "9.6":
+		return walSQLReplacer.Replace(sql)
+	}
+
+	return sql
+}
+
+// execMaintenanceSQL runs a single SQL statement against a database via psql
+// in the database container of the Pod, returning the unaligned output
+func execMaintenanceSQL(clientset *kubernetes.Clientset, restconfig *rest.Config, pod *v1.Pod,
+	database, sql string) (string, error) {
+	cmd := []string{"psql", "-d", database, "-A", "-t", "-c", sql}
+
+	stdout, stderr, err := kubeapi.ExecToPodThroughAPI(restconfig, clientset,
+		cmd, "database", pod.Name, pod.ObjectMeta.Namespace, nil)
+
+	// psql can write notices and warnings to stderr, so only consider it an
+	// error if PostgreSQL reported one
+	if err != nil {
+		log.Error(err)
+		return "", err
+	} else if strings.Contains(stderr, "ERROR") {
+		log.Error(stderr)
+		return "", errors.New(strings.TrimSpace(stderr))
+	}
+
+	return strings.TrimSpace(stdout), nil
+}
+
+// execMaintenanceScript runs a script of several statements in a database of the instance,
+// stopping at the first error so that a script that is wrapped in a transaction is rolled back
+func execMaintenanceScript(clientset *kubernetes.Clientset, restconfig *rest.Config, pod *v1.Pod,
+	database, sql string) error {
+	cmd := []string{"psql", "-d", database, "-v", "ON_ERROR_STOP=1"}
+
+	_, stderr, err := kubeapi.ExecToPodThroughAPI(restconfig, clientset,
+		cmd, "database", pod.Name, pod.ObjectMeta.Namespace, strings.NewReader(sql))
+
+	if err != nil {
+		log.Error(err)
+		return err
+	} else if strings.Contains(stderr, "ERROR") {
+		log.Error(stderr)
+		return errors.New(strings.TrimSpace(stderr))
+	}
+
+	return nil
+}
@@ -0,0 +1,260 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/kubeapi"
+	"github.com/crunchydata/postgres-operator/operator"
+	log "github.com/sirupsen/logrus"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// the operations that wait for the maintenance window of a cluster, as they are listed in its
+// status
+const (
+	maintenanceActionMinorUpgrade         = "minor-upgrade"
+	maintenanceActionPostgresParams       = "postgres-params"
+	maintenanceActionPrimaryStorage       = "primary-storage"
+	maintenanceActionRecommendedResources = "recommended-resources"
+	maintenanceActionReplicaStorage       = "replica-storage"
+	maintenanceActionResources            = "resources"
+	maintenanceActionTLSCertificates      = "tls-certificates"
+)
+
+// maintenanceWindowTimeFormat is the format of the times that a maintenance window opens and
+// closes at
+const maintenanceWindowTimeFormat = "15:04"
+
+// eventReasonMaintenanceDeferred is the reason of the Normal events that are recorded when an
+// operation of a cluster waits for its maintenance window to open
+const eventReasonMaintenanceDeferred = "MaintenanceDeferred"
+
+// ValidateMaintenanceWindow returns an error if the maintenance window of a cluster cannot be
+// told, i.e. it lacks its start or end time, or one of its times, days or time zone is invalid
+func ValidateMaintenanceWindow(cluster *crv1.Pgcluster) error {
+	spec := cluster.Spec.MaintenanceWindow
+
+	if !spec.IsEnabled() {
+		if len(spec.Days) > 0 {
+			return errors.New("the maintenance window requires a start time and an end time")
+		}
+		return nil
+	}
+
+	if spec.StartTime == "" || spec.EndTime == "" {
+		return errors.New("the maintenance window requires a start time and an end time")
+	}
+
+	if _, _, err := getMaintenanceWindowTimes(spec); err != nil {
+		return err
+	}
+
+	if spec.StartTime == spec.EndTime {
+		return fmt.Errorf("the maintenance window cannot both open and close at %s", spec.StartTime)
+	}
+
+	for _, day := range spec.Days {
+		if _, ok := getMaintenanceWindowDay(day); !ok {
+			return fmt.Errorf("invalid maintenance window day %q, must be e.g. \"Mon\"", day)
+		}
+	}
+
+	if _, err := time.LoadLocation(spec.GetTimeZone()); err != nil {
+		return fmt.Errorf("invalid maintenance window time zone %q: %s", spec.TimeZone, err)
+	}
+
+	return nil
+}
+
+// ReconcileMaintenanceWindow carries out the operations of a cluster that wait for its
+// maintenance window once it is open. The operations that are retried as the cluster is
+// reconciled periodically anyway, such as the restart for its PostgreSQL parameters, are only
+// taken off of the status, and left for that
+func ReconcileMaintenanceWindow(clientset *kubernetes.Clientset, restclient *rest.RESTClient,
+	restconfig *rest.Config, cluster *crv1.Pgcluster) error {
+	if cluster.Status.State != crv1.PgclusterStateInitialized || cluster.Spec.Shutdown {
+		return nil
+	}
+
+	open, err := isMaintenanceWindowOpen(cluster.Spec.MaintenanceWindow, time.Now())
+	if err != nil || !open {
+		return err
+	}
+
+	// the actions are taken off of the status as they are carried out
+	for _, action := range append([]string{}, cluster.Status.MaintenanceWindow.PendingActions...) {
+		log.Debugf("maintenance window: carrying out %s of cluster %s", action, cluster.Name)
+
+		var err error
+
+		switch action {
+		case maintenanceActionMinorUpgrade:
+			err = ReconcileMinorUpgrade(clientset, restclient, restconfig, cluster)
+		case maintenanceActionPrimaryStorage:
+			err = ReconcilePrimaryStorage(clientset, restclient, cluster)
+		case maintenanceActionReplicaStorage:
+			err = UpdateReplicaStorageSize(clientset, restclient, cluster)
+		case maintenanceActionResources:
+			err = ApplyResources(clientset, restclient, restconfig, cluster)
+		default:
+			_, err = deferMaintenanceAction(clientset, restclient, cluster, action)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// deferMaintenanceAction returns whether an operation of a cluster has to wait for its
+// maintenance window, in which case it is listed as pending in the status of the cluster until
+// the window opens and the operation is carried out. A cluster without a window carries out its
+// operations right away
+func deferMaintenanceAction(clientset *kubernetes.Clientset, restclient *rest.RESTClient,
+	cluster *crv1.Pgcluster, action string) (bool, error) {
+	pending := cluster.Status.MaintenanceWindow.PendingActions
+	queued := containsString(pending, action)
+
+	open, err := isMaintenanceWindowOpen(cluster.Spec.MaintenanceWindow, time.Now())
+	if err != nil {
+		return false, err
+	}
+
+	if open != queued {
+		return !open, nil
+	}
+
+	status := crv1.MaintenanceWindowStatus{}
+
+	if open {
+		for _, name := range pending {
+			if name != action {
+				status.PendingActions = append(status.PendingActions, name)
+			}
+		}
+	} else {
+		log.Infof("maintenance window: %s of cluster %s waits for the maintenance window", action,
+			cluster.Name)
+
+		operator.RecordNormalEvent(clientset, cluster, eventReasonMaintenanceDeferred,
+			fmt.Sprintf("%s waits for the maintenance window", action))
+
+		status.PendingActions = append(append([]string{}, pending...), action)
+		sort.Strings(status.PendingActions)
+	}
+
+	return !open, kubeapi.Patchpgcluster(restclient, cluster, cluster.Namespace, func(cluster *crv1.Pgcluster) {
+		cluster.Status.MaintenanceWindow = status
+	})
+}
+
+// isMaintenanceWindowOpen returns whether a maintenance window is open at the given time, which
+// it always is if there is none. As a window can extend into the next day, the window that
+// opened the day before is checked as well as the one of the day itself
+func isMaintenanceWindowOpen(spec crv1.MaintenanceWindowSpec, now time.Time) (bool, error) {
+	if !spec.IsEnabled() {
+		return true, nil
+	}
+
+	start, end, err := getMaintenanceWindowTimes(spec)
+	if err != nil {
+		return false, err
+	}
+
+	location, err := time.LoadLocation(spec.GetTimeZone())
+	if err != nil {
+		return false, err
+	}
+
+	duration := end - start
+	if duration <= 0 {
+		duration += 24 * time.Hour
+	}
+
+	local := now.In(location)
+
+	for _, offset := range []int{0, -1} {
+		day := local.AddDate(0, 0, offset)
+
+		if !isMaintenanceWindowDay(spec.Days, day.Weekday()) {
+			continue
+		}
+
+		opens := time.Date(day.Year(), day.Month(), day.Day(), 0, int(start/time.Minute), 0, 0, location)
+
+		if !local.Before(opens) && local.Before(opens.Add(duration)) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// getMaintenanceWindowTimes returns when a maintenance window opens and closes, as the time since
+// midnight
+func getMaintenanceWindowTimes(spec crv1.MaintenanceWindowSpec) (time.Duration, time.Duration, error) {
+	times := make([]time.Duration, 0, 2)
+
+	for _, value := range []string{spec.StartTime, spec.EndTime} {
+		parsed, err := time.Parse(maintenanceWindowTimeFormat, value)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid maintenance window time %q, must be e.g. \"02:00\"", value)
+		}
+
+		times = append(times, time.Duration(parsed.Hour())*time.Hour+
+			time.Duration(parsed.Minute())*time.Minute)
+	}
+
+	return times[0], times[1], nil
+}
+
+// isMaintenanceWindowDay returns whether a maintenance window opens on a day of the week, which
+// it does every day if it has no days
+func isMaintenanceWindowDay(days []string, weekday time.Weekday) bool {
+	if len(days) == 0 {
+		return true
+	}
+
+	for _, day := range days {
+		if value, ok := getMaintenanceWindowDay(day); ok && value == weekday {
+			return true
+		}
+	}
+
+	return false
+}
+
+// getMaintenanceWindowDay returns the day of the week that is abbreviated by a day of a
+// maintenance window, e.g. "Mon", in any case
+func getMaintenanceWindowDay(day string) (time.Weekday, bool) {
+	for weekday := time.Sunday; weekday <= time.Saturday; weekday++ {
+		if strings.EqualFold(day, weekday.String()[:3]) {
+			return weekday, true
+		}
+	}
+
+	return 0, false
+}
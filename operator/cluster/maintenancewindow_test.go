@@ -0,0 +1,61 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"testing"
+	"time"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+)
+
+func TestIsMaintenanceWindowOpen(t *testing.T) {
+	// 2020-06-06 is a Saturday, and Paris is two hours ahead of UTC in June
+	weekends := crv1.MaintenanceWindowSpec{
+		Days:      []string{"Sat", "Sun"},
+		StartTime: "22:00",
+		EndTime:   "02:00",
+		TimeZone:  "Europe/Paris",
+	}
+	nightly := crv1.MaintenanceWindowSpec{StartTime: "02:00", EndTime: "04:00"}
+
+	for _, test := range []struct {
+		name string
+		spec crv1.MaintenanceWindowSpec
+		now  time.Time
+		open bool
+	}{
+		{"no window", crv1.MaintenanceWindowSpec{}, time.Date(2020, 6, 3, 12, 0, 0, 0, time.UTC), true},
+		{"not yet", weekends, time.Date(2020, 6, 6, 19, 59, 0, 0, time.UTC), false},
+		{"opens", weekends, time.Date(2020, 6, 6, 20, 0, 0, 0, time.UTC), true},
+		{"past midnight", weekends, time.Date(2020, 6, 6, 23, 30, 0, 0, time.UTC), true},
+		{"into monday", weekends, time.Date(2020, 6, 7, 23, 30, 0, 0, time.UTC), true},
+		{"closes", weekends, time.Date(2020, 6, 8, 0, 0, 0, 0, time.UTC), false},
+		{"weekday", weekends, time.Date(2020, 6, 10, 20, 30, 0, 0, time.UTC), false},
+		{"every day", nightly, time.Date(2020, 6, 10, 3, 0, 0, 0, time.UTC), true},
+		{"every day closed", nightly, time.Date(2020, 6, 10, 4, 0, 0, 0, time.UTC), false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			open, err := isMaintenanceWindowOpen(test.spec, test.now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if open != test.open {
+				t.Fatalf("expected open %t, got %t", test.open, open)
+			}
+		})
+	}
+}
@@ -0,0 +1,1286 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/config"
+	"github.com/crunchydata/postgres-operator/events"
+	"github.com/crunchydata/postgres-operator/kubeapi"
+	"github.com/crunchydata/postgres-operator/operator"
+	"github.com/crunchydata/postgres-operator/operator/backrest"
+	"github.com/crunchydata/postgres-operator/util"
+	log "github.com/sirupsen/logrus"
+	apps_v1 "k8s.io/api/apps/v1"
+	batch_v1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	// the name of the PVC that is cloned from the primary PVC, as well as the
+	// name of the Job that runs the precheck against it
+	majorUpgradePrecheckName = "%s-upgrade-precheck"
+	// the name of the container that runs the precheck
+	majorUpgradePrecheckContainerName = "upgrade-precheck"
+	// the scratch directory where the new PostgreSQL data directory is
+	// initialized so pg_upgrade has something to check against
+	majorUpgradePrecheckWorkPath   = "/pgupgrade"
+	majorUpgradePrecheckWorkVolume = "pgupgrade"
+	// the number of log lines from the precheck that are stored in the status
+	// of the pgtask
+	majorUpgradePrecheckLogLines = 20
+	// the name of the Job that runs pg_upgrade against the data directory of
+	// the primary, and the name of its container
+	majorUpgradeJobName       = "%s-upgrade"
+	majorUpgradeContainerName = "upgrade"
+	// how long to wait, in seconds, for an instance to stop or start, or for a
+	// replica to be rebuilt
+	majorUpgradeTimeout = 600
+	majorUpgradePeriod  = 5
+)
+
+const (
+	// eventReasonMajorUpgradeCompleted is the reason of the events that are recorded when a
+	// cluster was upgraded to a new major version
+	eventReasonMajorUpgradeCompleted = "MajorUpgradeCompleted"
+
+	// eventReasonMajorUpgradeFailed is the reason of the Warning events that are recorded when
+	// a cluster could not be upgraded to a new major version
+	eventReasonMajorUpgradeFailed = "MajorUpgradeFailed"
+)
+
+// sqlMajorUpgradeExtensions returns the statements that update the extensions
+// of a database that are not on the version that PostgreSQL now provides
+const sqlMajorUpgradeExtensions = `SELECT pg_catalog.format('ALTER EXTENSION %I UPDATE;', e.extname)
+FROM pg_catalog.pg_extension e
+JOIN pg_catalog.pg_available_extensions a ON a.name = e.extname
+WHERE a.default_version IS DISTINCT FROM e.extversion;`
+
+// majorUpgradePrecheckScript is run inside of the upgrade container against
+// the cloned data directory. The clone is a crash consistent copy of a running
+// primary, so the old version of PostgreSQL is first started and cleanly
+// stopped to bring it into a state that pg_upgrade will accept. A new data
+// directory is then initialized in scratch space and "pg_upgrade --check"
+// is run, which does not modify either data directory
+const majorUpgradePrecheckScript = `set -e
+OLD_BIN="/usr/pgsql-${PG_VERSION_OLD}/bin"
+NEW_BIN="/usr/pgsql-${PG_VERSION_NEW}/bin"
+NEW_PGDATA="${PGUPGRADE_WORK_PATH}/pgdata"
+rm -f "${PGDATA_OLD}/postmaster.pid" "${PGDATA_OLD}/standby.signal" "${PGDATA_OLD}/recovery.signal" "${PGDATA_OLD}/recovery.conf"
+"${OLD_BIN}/pg_ctl" -D "${PGDATA_OLD}" -w -t 600 -o "-c archive_mode=off -c listen_addresses='' -c unix_socket_directories=/tmp" start
+"${OLD_BIN}/pg_ctl" -D "${PGDATA_OLD}" -w -m fast stop
+"${NEW_BIN}/initdb" -D "${NEW_PGDATA}" -U postgres
+cd "${PGUPGRADE_WORK_PATH}"
+"${NEW_BIN}/pg_upgrade" --check --old-bindir="${OLD_BIN}" --new-bindir="${NEW_BIN}" \
+  --old-datadir="${PGDATA_OLD}" --new-datadir="${NEW_PGDATA}" --username=postgres --socketdir=/tmp
+`
+
+// majorUpgradeScript is run inside of the upgrade container against the data
+// directory of the primary once the cluster is stopped. The old version of
+// PostgreSQL is started and cleanly stopped in case it was not, which also
+// provides the encoding and locale that the new data directory is initialized
+// with. pg_upgrade then links the data files into the new data directory, which
+// replaces the old one once the upgrade succeeded. Until then, a failure rolls
+// the old data directory back: pg_upgrade only renames its pg_control once it
+// starts to link the files, and the old version of PostgreSQL can be started
+// again once it is renamed back. A data directory that is already upgraded,
+// e.g. by an earlier attempt, is left as is
+const majorUpgradeScript = `set -e
+OLD_BIN="/usr/pgsql-${PG_VERSION_OLD}/bin"
+NEW_BIN="/usr/pgsql-${PG_VERSION_NEW}/bin"
+NEW_PGDATA="${PGDATA_OLD}-${PG_VERSION_NEW}"
+if [ ! -d "${PGDATA_OLD}" ] && [ -d "${NEW_PGDATA}" ]; then
+  mv "${NEW_PGDATA}" "${PGDATA_OLD}"
+fi
+if [ "$(cat "${PGDATA_OLD}/PG_VERSION")" = "${PG_VERSION_NEW}" ]; then
+  echo "${PGDATA_OLD} is already upgraded to PostgreSQL ${PG_VERSION_NEW}"
+  exit 0
+fi
+rollback() {
+  if [ -f "${PGDATA_OLD}/global/pg_control.old" ]; then
+    mv "${PGDATA_OLD}/global/pg_control.old" "${PGDATA_OLD}/global/pg_control"
+  fi
+  rm -rf "${NEW_PGDATA}"
+  echo "the upgrade failed, ${PGDATA_OLD} is rolled back to PostgreSQL ${PG_VERSION_OLD}"
+}
+trap rollback ERR
+rm -f "${PGDATA_OLD}/postmaster.pid"
+"${OLD_BIN}/pg_ctl" -D "${PGDATA_OLD}" -w -t 600 -o "-c archive_mode=off -c listen_addresses='' -c unix_socket_directories=/tmp" start
+IFS='|' read -r ENCODING COLLATE CTYPE < <("${OLD_BIN}/psql" -h /tmp -U postgres -d postgres -A -t -c \
+  "SELECT pg_catalog.pg_encoding_to_char(encoding), datcollate, datctype FROM pg_catalog.pg_database WHERE datname = 'template0';")
+"${OLD_BIN}/pg_ctl" -D "${PGDATA_OLD}" -w -m fast stop
+CHECKSUMS=""
+if "${OLD_BIN}/pg_controldata" "${PGDATA_OLD}" | grep -Eq '^Data page checksum version:[[:space:]]+[1-9]'; then
+  CHECKSUMS="--data-checksums"
+fi
+rm -rf "${NEW_PGDATA}"
+"${NEW_BIN}/initdb" -D "${NEW_PGDATA}" -U postgres --encoding="${ENCODING}" \
+  --lc-collate="${COLLATE}" --lc-ctype="${CTYPE}" ${CHECKSUMS}
+cd "${PGUPGRADE_WORK_PATH}"
+"${NEW_BIN}/pg_upgrade" --link --old-bindir="${OLD_BIN}" --new-bindir="${NEW_BIN}" \
+  --old-datadir="${PGDATA_OLD}" --new-datadir="${NEW_PGDATA}" --username=postgres --socketdir=/tmp \
+  --old-options="-c archive_mode=off"
+trap - ERR
+./delete_old_cluster.sh
+mv "${NEW_PGDATA}" "${PGDATA_OLD}"
+`
+
+// majorUpgrading holds the clusters that a phase of a major upgrade is running
+// for in the background, so that the completion of the Job or of the backup
+// that the phase follows is only acted on once
+var majorUpgrading sync.Map
+
+// AddMajorUpgrade implements the first phase of the workflow for a major
+// upgrade of a cluster: a precheck that runs "pg_upgrade --check" against a
+// clone of the primary's PVC. The live cluster is not modified in any way while
+// the precheck runs; the result is handled by UpdateMajorUpgradePrecheck once
+// the precheck Job completes
+func AddMajorUpgrade(clientset *kubernetes.Clientset, restclient *rest.RESTClient, restconfig *rest.Config,
+	upgrade *crv1.Pgtask, namespace string) {
+	clusterName := upgrade.ObjectMeta.Labels[config.LABEL_PG_CLUSTER]
+
+	cluster := crv1.Pgcluster{}
+	if _, err := kubeapi.Getpgcluster(restclient, &cluster, clusterName, namespace); err != nil {
+		log.Error("could not find pgcluster for major upgrade")
+		log.Error(err)
+		return
+	}
+
+	// get the latest version of the task in case it changed
+	task := crv1.Pgtask{}
+	if found, err := kubeapi.Getpgtask(restclient, &task, upgrade.Spec.Name, namespace); !found {
+		log.Error("could not find pgtask for major upgrade")
+		log.Error(err)
+		return
+	}
+
+	if task.Spec.Parameters == nil {
+		task.Spec.Parameters = make(map[string]string)
+	}
+
+	// if the precheck cannot even be started, this is treated the same as a
+	// failed precheck: the cluster is left alone unless "force" is set
+	if err := createMajorUpgradePrecheck(clientset, &cluster, &task, namespace); err != nil {
+		log.Error(err)
+		finishMajorUpgradePrecheck(clientset, restclient, restconfig, &cluster, &task, namespace, false, err.Error())
+		return
+	}
+
+	log.Debugf("major upgrade: precheck started for cluster %s", clusterName)
+
+	task.Spec.Status = crv1.PgtaskMajorUpgradePrecheckInProgress
+
+	if err := kubeapi.Updatepgtask(restclient, &task, task.Spec.Name, namespace); err != nil {
+		log.Error("error in updating major upgrade pgtask to precheck status " + err.Error())
+	}
+}
+
+// UpdateMajorUpgradePrecheck is called when the Job running the major upgrade
+// precheck has completed, successfully or not. It records the result of the
+// precheck on the pgtask, removes the cloned PVC and, if the precheck passed or
+// "force" was set on the task, allows the upgrade to proceed
+func UpdateMajorUpgradePrecheck(clientset *kubernetes.Clientset, restclient *rest.RESTClient, restconfig *rest.Config,
+	job *batch_v1.Job, succeeded bool) error {
+	namespace := job.Namespace
+	clusterName := job.ObjectMeta.Labels[config.LABEL_PG_CLUSTER]
+	taskName := job.ObjectMeta.Labels[config.LABEL_PGTASK]
+
+	cluster := crv1.Pgcluster{}
+	if _, err := kubeapi.Getpgcluster(restclient, &cluster, clusterName, namespace); err != nil {
+		log.Error(err)
+		return err
+	}
+
+	task := crv1.Pgtask{}
+	if found, err := kubeapi.Getpgtask(restclient, &task, taskName, namespace); !found {
+		log.Error(err)
+		return err
+	}
+
+	// guard against processing the same Job completion more than once
+	if task.Spec.Status != crv1.PgtaskMajorUpgradePrecheckInProgress {
+		log.Debugf("major upgrade: precheck for task %s already processed", taskName)
+		return nil
+	}
+
+	if task.Spec.Parameters == nil {
+		task.Spec.Parameters = make(map[string]string)
+	}
+
+	// grab the output of pg_upgrade so the user has some idea of what failed
+	// without needing to go find the Pod, which may not have any output to grab
+	message := "pg_upgrade --check passed"
+	if !succeeded {
+		message = "pg_upgrade --check failed"
+
+		if output, err := getMajorUpgradePrecheckOutput(clientset, job); err != nil {
+			log.Warn(err)
+		} else if output != "" {
+			message = output
+		}
+	}
+
+	// the cloned PVC has served its purpose
+	if err := kubeapi.DeletePVC(clientset, fmt.Sprintf(majorUpgradePrecheckName, clusterName), namespace); err != nil {
+		log.Error(err)
+	}
+
+	finishMajorUpgradePrecheck(clientset, restclient, restconfig, &cluster, &task, namespace, succeeded, message)
+
+	return nil
+}
+
+// createMajorUpgradePrecheck clones the PVC of the current primary and creates
+// the Job that runs "pg_upgrade --check" against the clone
+func createMajorUpgradePrecheck(clientset *kubernetes.Clientset, cluster *crv1.Pgcluster, task *crv1.Pgtask, namespace string) error {
+	currentVersion, targetVersion, err := getMajorUpgradeVersions(cluster, task)
+	if err != nil {
+		return err
+	}
+
+	// the ccp image tag is needed to determine which image contains the
+	// PostgreSQL binaries for both versions
+	ccpImageTag := getMajorUpgradeImageTag(task)
+
+	// find the PVC that backs the data directory of the current primary, which
+	// may not be the PVC named after the cluster if a failover has occurred
+	cluster.Spec.Namespace = namespace
+	primaryPod, err := util.GetPrimaryPod(clientset, cluster)
+	if err != nil {
+		return err
+	}
+
+	primaryPVCName := ""
+	for _, volume := range primaryPod.Spec.Volumes {
+		if volume.Name == config.VOLUME_POSTGRESQL_DATA && volume.PersistentVolumeClaim != nil {
+			primaryPVCName = volume.PersistentVolumeClaim.ClaimName
+		}
+	}
+
+	if primaryPVCName == "" {
+		return fmt.Errorf("could not find the data PVC for primary pod %s", primaryPod.Name)
+	}
+
+	primaryPVC, found, err := kubeapi.GetPVC(clientset, primaryPVCName, namespace)
+	if !found {
+		return err
+	}
+
+	// clone the primary PVC. This requires a CSI driver that supports volume
+	// cloning; if it is not available the PVC will not be accepted and the
+	// precheck fails without having touched the cluster
+	cloneName := fmt.Sprintf(majorUpgradePrecheckName, cluster.Name)
+
+	clone := &v1.PersistentVolumeClaim{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name: cloneName,
+			Labels: map[string]string{
+				config.LABEL_VENDOR:                 config.LABEL_CRUNCHY,
+				config.LABEL_PG_CLUSTER:             cluster.Name,
+				config.LABEL_MAJOR_UPGRADE_PRECHECK: config.LABEL_TRUE,
+			},
+		},
+		Spec: v1.PersistentVolumeClaimSpec{
+			AccessModes:      primaryPVC.Spec.AccessModes,
+			Resources:        primaryPVC.Spec.Resources,
+			StorageClassName: primaryPVC.Spec.StorageClassName,
+			DataSource: &v1.TypedLocalObjectReference{
+				Kind: "PersistentVolumeClaim",
+				Name: primaryPVCName,
+			},
+		},
+	}
+
+	if err := kubeapi.CreatePVC(clientset, clone, namespace); err != nil {
+		return err
+	}
+
+	// set the backoff limit to be 0 to match our other jobs
+	backoffLimit := int32(0)
+
+	labels := map[string]string{
+		config.LABEL_VENDOR:                 config.LABEL_CRUNCHY,
+		config.LABEL_PG_CLUSTER:             cluster.Name,
+		config.LABEL_PGTASK:                 task.Spec.Name,
+		config.LABEL_PGOUSER:                task.ObjectMeta.Labels[config.LABEL_PGOUSER],
+		config.LABEL_MAJOR_UPGRADE_PRECHECK: config.LABEL_TRUE,
+	}
+
+	job := batch_v1.Job{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:   cloneName,
+			Labels: labels,
+		},
+		Spec: batch_v1.JobSpec{
+			BackoffLimit: &backoffLimit,
+			Template: v1.PodTemplateSpec{
+				ObjectMeta: meta_v1.ObjectMeta{
+					Name:   cloneName,
+					Labels: labels,
+				},
+				Spec: v1.PodSpec{
+					Containers: []v1.Container{
+						v1.Container{
+							Name: majorUpgradePrecheckContainerName,
+							Image: fmt.Sprintf("%s/%s:%s", operator.Pgo.Cluster.CCPImagePrefix,
+								config.CONTAINER_IMAGE_CRUNCHY_UPGRADE, ccpImageTag),
+							Command: []string{"bash", "-c", majorUpgradePrecheckScript},
+							Env: []v1.EnvVar{
+								v1.EnvVar{
+									Name:  "PGDATA_OLD",
+									Value: fmt.Sprintf("%s/%s", config.VOLUME_POSTGRESQL_DATA_MOUNT_PATH, primaryPVCName),
+								},
+								v1.EnvVar{Name: "PG_VERSION_OLD", Value: currentVersion},
+								v1.EnvVar{Name: "PG_VERSION_NEW", Value: targetVersion},
+								v1.EnvVar{Name: "PGUPGRADE_WORK_PATH", Value: majorUpgradePrecheckWorkPath},
+							},
+							VolumeMounts: []v1.VolumeMount{
+								v1.VolumeMount{
+									MountPath: config.VOLUME_POSTGRESQL_DATA_MOUNT_PATH,
+									Name:      config.VOLUME_POSTGRESQL_DATA,
+								},
+								v1.VolumeMount{
+									MountPath: majorUpgradePrecheckWorkPath,
+									Name:      majorUpgradePrecheckWorkVolume,
+								},
+							},
+						},
+					},
+					NodeSelector:  operator.GetNodeSelector(cluster),
+					RestartPolicy: v1.RestartPolicyNever,
+					SecurityContext: &v1.PodSecurityContext{
+						FSGroup:            &crv1.PGFSGroup,
+						SupplementalGroups: cluster.Spec.PrimaryStorage.GetSupplementalGroups(),
+					},
+					Tolerations: operator.GetTolerations(cluster),
+					Volumes: []v1.Volume{
+						v1.Volume{
+							Name: config.VOLUME_POSTGRESQL_DATA,
+							VolumeSource: v1.VolumeSource{
+								PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{
+									ClaimName: cloneName,
+								},
+							},
+						},
+						v1.Volume{
+							Name: majorUpgradePrecheckWorkVolume,
+							VolumeSource: v1.VolumeSource{
+								EmptyDir: &v1.EmptyDirVolumeSource{},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	// set the container image to an override value, if one exists
+	operator.SetContainerImageOverride(config.CONTAINER_IMAGE_CRUNCHY_UPGRADE,
+		&job.Spec.Template.Spec.Containers[0])
+
+	// apply the node affinity and the PriorityClass of the cluster
+	operator.AddPodScheduling(cluster, nil, &job.Spec.Template.Spec)
+
+	if _, err := kubeapi.CreateJob(clientset, &job, namespace); err != nil {
+		// clean up the clone so it is not left behind
+		if err := kubeapi.DeletePVC(clientset, cloneName, namespace); err != nil {
+			log.Error(err)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// finishMajorUpgradePrecheck records the result of the precheck on the pgtask.
+// If the precheck failed and "force" was not set on the task, the upgrade is
+// refused and the cluster is left as is
+func finishMajorUpgradePrecheck(clientset *kubernetes.Clientset, restclient *rest.RESTClient, restconfig *rest.Config,
+	cluster *crv1.Pgcluster, task *crv1.Pgtask, namespace string, passed bool, message string) {
+	force := task.Spec.Parameters[crv1.PgtaskMajorUpgradeForce] == config.LABEL_TRUE
+
+	switch {
+	case passed:
+		task.Spec.Status = crv1.PgtaskMajorUpgradePrecheckPassed
+	case force:
+		task.Spec.Status = crv1.PgtaskMajorUpgradePrecheckForced
+	default:
+		task.Spec.Status = crv1.PgtaskMajorUpgradePrecheckFailed
+	}
+
+	if err := kubeapi.Updatepgtask(restclient, task, task.Spec.Name, namespace); err != nil {
+		log.Error("error in updating major upgrade pgtask precheck status " + err.Error())
+	}
+
+	// a refused upgrade is where the task ends
+	phase := crv1.PgtaskPhaseRunning
+	if !passed && !force {
+		phase = crv1.PgtaskPhaseFailed
+	}
+
+	if err := kubeapi.PatchpgtaskPhase(restclient, crv1.PgtaskStateProcessed, phase, message, task,
+		namespace); err != nil {
+		log.Error(err)
+	}
+
+	if !passed && !force {
+		log.Errorf("major upgrade: precheck failed for cluster %s, not proceeding: %s", cluster.Name, message)
+		return
+	}
+
+	log.Debugf("major upgrade: precheck cleared for cluster %s", cluster.Name)
+
+	publishMajorUpgradeStartedEvent(task, cluster, namespace)
+
+	// stopping the instances and waiting for them can take a while, so this is
+	// done in the background so as to not hold up the processing of other
+	// pgtasks and Jobs
+	go startMajorUpgrade(clientset, restclient, *cluster, task.Spec.Name, namespace)
+}
+
+// UpdateMajorUpgrade is called when the Job running pg_upgrade against the
+// data directory of the primary has completed, successfully or not. On success
+// the primary is started on the new version of PostgreSQL; on failure the data
+// directory was rolled back by the Job, so the cluster is started again on the
+// version it was on and the pgtask is marked as failed
+func UpdateMajorUpgrade(clientset *kubernetes.Clientset, restclient *rest.RESTClient, restconfig *rest.Config,
+	job *batch_v1.Job, succeeded bool) error {
+	namespace := job.Namespace
+	clusterName := job.ObjectMeta.Labels[config.LABEL_PG_CLUSTER]
+	taskName := job.ObjectMeta.Labels[config.LABEL_PGTASK]
+
+	cluster := crv1.Pgcluster{}
+	if _, err := kubeapi.Getpgcluster(restclient, &cluster, clusterName, namespace); err != nil {
+		log.Error(err)
+		return err
+	}
+
+	task := crv1.Pgtask{}
+	if found, err := kubeapi.Getpgtask(restclient, &task, taskName, namespace); !found {
+		log.Error(err)
+		return err
+	}
+
+	// guard against processing the same Job completion more than once
+	instance := task.Spec.Parameters[crv1.PgtaskMajorUpgradePrimaryInstance]
+	if task.Spec.Status != crv1.PgtaskMajorUpgradeInProgress || job.Name != fmt.Sprintf(majorUpgradeJobName, instance) {
+		log.Debugf("major upgrade: job %s for task %s already processed", job.Name, taskName)
+		return nil
+	}
+
+	cluster.Spec.Namespace = namespace
+
+	key := namespace + "/" + clusterName
+	if _, running := majorUpgrading.LoadOrStore(key, true); running {
+		return nil
+	}
+
+	if !succeeded {
+		message := "pg_upgrade failed"
+		if output, err := getMajorUpgradeJobOutput(clientset, job); err != nil {
+			log.Warn(err)
+		} else if output != "" {
+			message = fmt.Sprintf("%s: %s", message, output)
+		}
+
+		go func() {
+			defer majorUpgrading.Delete(key)
+
+			rollbackMajorUpgrade(clientset, restclient, &cluster, &task, message)
+		}()
+		return nil
+	}
+
+	log.Debugf("major upgrade: pg_upgrade succeeded on instance %s of cluster %s", instance, clusterName)
+
+	go func() {
+		defer majorUpgrading.Delete(key)
+
+		upgradeMajorUpgradePrimary(clientset, restclient, restconfig, &cluster, &task)
+	}()
+
+	return nil
+}
+
+// UpdateMajorUpgradeBackup is called once the backup that is taken after a
+// major upgrade has finished. If the backup succeeded, the replicas of the
+// cluster are rebuilt from it one at a time, otherwise they are left stopped
+// and the pgtask is marked as failed
+func UpdateMajorUpgradeBackup(clientset *kubernetes.Clientset, restclient *rest.RESTClient, restconfig *rest.Config,
+	clusterName, namespace string, succeeded bool) error {
+	task, err := getMajorUpgradeTask(restclient, clusterName, namespace)
+	if err != nil {
+		return err
+	} else if task == nil {
+		log.Debugf("no major upgrade found for cluster %s after backup", clusterName)
+		return nil
+	}
+
+	cluster := crv1.Pgcluster{}
+	if _, err := kubeapi.Getpgcluster(restclient, &cluster, clusterName, namespace); err != nil {
+		return err
+	}
+
+	cluster.Spec.Namespace = namespace
+
+	if !succeeded {
+		failMajorUpgrade(clientset, restclient, &cluster, task,
+			"the backup to rebuild the replicas from failed, so they are left stopped")
+		return nil
+	}
+
+	key := namespace + "/" + clusterName
+	if _, running := majorUpgrading.LoadOrStore(key, true); running {
+		return nil
+	}
+
+	go func() {
+		defer majorUpgrading.Delete(key)
+
+		rebuildMajorUpgradeReplicas(clientset, restclient, restconfig, &cluster, task)
+	}()
+
+	return nil
+}
+
+// startMajorUpgrade implements the second phase of the workflow for a major
+// upgrade of a cluster, once its precheck is cleared: the instances are
+// stopped, replicas first, and the Job that runs "pg_upgrade --link" against
+// the data directory of the primary is created. The result is handled by
+// UpdateMajorUpgrade once the Job completes
+func startMajorUpgrade(clientset *kubernetes.Clientset, restclient *rest.RESTClient, cluster crv1.Pgcluster,
+	taskName, namespace string) {
+	// get the latest version of the task, as the precheck just updated it
+	task := crv1.Pgtask{}
+	if found, err := kubeapi.Getpgtask(restclient, &task, taskName, namespace); !found {
+		log.Error(err)
+		return
+	}
+
+	// the primary is looked up by the namespace in the spec
+	cluster.Spec.Namespace = namespace
+
+	if err := validateMajorUpgrade(&cluster, &task); err != nil {
+		failMajorUpgrade(clientset, restclient, &cluster, &task, err.Error())
+		return
+	}
+
+	deployments, err := operator.GetInstanceDeployments(clientset, &cluster)
+	if err != nil {
+		failMajorUpgrade(clientset, restclient, &cluster, &task, err.Error())
+		return
+	}
+
+	primaryPod, err := util.GetPrimaryPod(clientset, &cluster)
+	if err != nil {
+		failMajorUpgrade(clientset, restclient, &cluster, &task, err.Error())
+		return
+	}
+
+	primary := primaryPod.ObjectMeta.Labels[config.LABEL_DEPLOYMENT_NAME]
+
+	// record the primary before anything is done to the cluster, so it is the
+	// instance that is started again, whether the upgrade succeeds or not
+	task.Spec.Status = crv1.PgtaskMajorUpgradeInProgress
+	task.Spec.Parameters[crv1.PgtaskMajorUpgradePrimaryInstance] = primary
+
+	if err := kubeapi.Updatepgtask(restclient, &task, task.Spec.Name, namespace); err != nil {
+		log.Error("error in updating major upgrade pgtask status " + err.Error())
+		return
+	}
+
+	instances := make([]string, 0, len(deployments.Items))
+	for _, deployment := range deployments.Items {
+		instances = append(instances, deployment.Name)
+	}
+
+	// the replicas are stopped first so that none of them takes over as the
+	// primary
+	for _, instance := range getRollingRestartOrder(instances, primary) {
+		updateMajorUpgradeTask(restclient, &task, crv1.PgtaskMajorUpgradeInProgress,
+			fmt.Sprintf("stopping instance %s", instance))
+
+		if err := stopMajorUpgradeInstance(clientset, instance, namespace); err != nil {
+			rollbackMajorUpgrade(clientset, restclient, &cluster, &task, err.Error())
+			return
+		}
+	}
+
+	updateMajorUpgradeTask(restclient, &task, crv1.PgtaskMajorUpgradeInProgress,
+		fmt.Sprintf("running pg_upgrade on instance %s", primary))
+
+	if err := createMajorUpgradeJob(clientset, &cluster, &task, primary); err != nil {
+		rollbackMajorUpgrade(clientset, restclient, &cluster, &task, err.Error())
+	}
+}
+
+// upgradeMajorUpgradePrimary starts the primary on the new version of
+// PostgreSQL once pg_upgrade succeeded. All of the instances are updated to
+// the image of the new version, and Patroni is made to accept the upgraded
+// data directory, which has a new system identifier. Once the primary is
+// running, its extensions are updated, its statistics are gathered again and
+// the pgBackRest stanza is upgraded, after which a full backup is taken that
+// the replicas are rebuilt from
+func upgradeMajorUpgradePrimary(clientset *kubernetes.Clientset, restclient *rest.RESTClient, restconfig *rest.Config,
+	cluster *crv1.Pgcluster, task *crv1.Pgtask) {
+	primary := task.Spec.Parameters[crv1.PgtaskMajorUpgradePrimaryInstance]
+	ccpImageTag := getMajorUpgradeImageTag(task)
+	_, targetVersion, _ := getMajorUpgradeVersions(cluster, task)
+
+	deployments, err := operator.GetInstanceDeployments(clientset, cluster)
+	if err != nil {
+		failMajorUpgrade(clientset, restclient, cluster, task, err.Error())
+		return
+	}
+
+	patch, err := createImageNamePatch(*cluster, operator.Pgo.Cluster.CCPImagePrefix, ccpImageTag)
+	if err != nil {
+		failMajorUpgrade(clientset, restclient, cluster, task, err.Error())
+		return
+	}
+
+	for _, deployment := range deployments.Items {
+		if err := kubeapi.PatchDeploymentStrategicMerge(clientset, deployment.Name, cluster.Namespace,
+			patch); err != nil {
+			failMajorUpgrade(clientset, restclient, cluster, task, err.Error())
+			return
+		}
+	}
+
+	if err := resetPatroniInitialize(clientset, cluster); err != nil {
+		failMajorUpgrade(clientset, restclient, cluster, task, err.Error())
+		return
+	}
+
+	updateClusterCCPImage(restclient, ccpImageTag, cluster.Name, cluster.Namespace)
+
+	updateMajorUpgradeTask(restclient, task, crv1.PgtaskMajorUpgradeInProgress,
+		fmt.Sprintf("starting instance %s on PostgreSQL %s", primary, targetVersion))
+
+	if err := startMajorUpgradeInstance(clientset, primary, cluster.Namespace); err != nil {
+		failMajorUpgrade(clientset, restclient, cluster, task,
+			fmt.Sprintf("instance %s could not be started on PostgreSQL %s: %s", primary, targetVersion, err))
+		return
+	}
+
+	pod, err := util.GetPrimaryPod(clientset, cluster)
+	if err != nil {
+		failMajorUpgrade(clientset, restclient, cluster, task, err.Error())
+		return
+	}
+
+	// neither of these keep the cluster from being used, so a failure is only
+	// recorded
+	if err := updateMajorUpgradeExtensions(clientset, restconfig, pod); err != nil {
+		operator.RecordWarningEvent(clientset, cluster, eventReasonMajorUpgradeFailed,
+			fmt.Sprintf("the extensions could not be updated: %s", err))
+	}
+
+	go func() {
+		cmd := []string{"vacuumdb", "--all", "--analyze-in-stages"}
+		if _, stderr, err := kubeapi.ExecToPodThroughAPI(restconfig, clientset, cmd, "database",
+			pod.Name, pod.Namespace, nil); err != nil {
+			log.Errorf("major upgrade: could not analyze cluster %s: %s %s", cluster.Name, err, stderr)
+		}
+	}()
+
+	if err := execBackrestRepo(clientset, restconfig, cluster,
+		[]string{"pgbackrest", "stanza-upgrade"}); err != nil {
+		failMajorUpgrade(clientset, restclient, cluster, task,
+			fmt.Sprintf("the pgBackRest stanza could not be upgraded: %s", err))
+		return
+	}
+
+	if len(deployments.Items) == 1 {
+		completeMajorUpgrade(clientset, restclient, cluster, task, targetVersion)
+		return
+	}
+
+	updateMajorUpgradeTask(restclient, task, crv1.PgtaskMajorUpgradeRebuildingReplicas,
+		"taking a backup to rebuild the replicas from")
+
+	err = backrest.CleanBackupResources(restclient, clientset, cluster.Namespace, cluster.Name)
+	if err == nil {
+		_, err = backrest.CreateMajorUpgradeBackup(restclient, cluster.Namespace, cluster.Name, pod.Name)
+	}
+	if err != nil {
+		failMajorUpgrade(clientset, restclient, cluster, task,
+			fmt.Sprintf("the backup to rebuild the replicas from could not be taken: %s", err))
+	}
+}
+
+// rebuildMajorUpgradeReplicas starts the replicas of a cluster that was
+// upgraded to a new major version one at a time, and has Patroni rebuild each
+// of them from the backup that was taken after the upgrade
+func rebuildMajorUpgradeReplicas(clientset *kubernetes.Clientset, restclient *rest.RESTClient, restconfig *rest.Config,
+	cluster *crv1.Pgcluster, task *crv1.Pgtask) {
+	primary := task.Spec.Parameters[crv1.PgtaskMajorUpgradePrimaryInstance]
+	_, targetVersion, _ := getMajorUpgradeVersions(cluster, task)
+
+	deployments, err := operator.GetInstanceDeployments(clientset, cluster)
+	if err != nil {
+		failMajorUpgrade(clientset, restclient, cluster, task, err.Error())
+		return
+	}
+
+	replicas := []string{}
+	for _, deployment := range deployments.Items {
+		if deployment.Name != primary {
+			replicas = append(replicas, deployment.Name)
+		}
+	}
+
+	sort.Strings(replicas)
+
+	for i, replica := range replicas {
+		updateMajorUpgradeTask(restclient, task, crv1.PgtaskMajorUpgradeRebuildingReplicas,
+			fmt.Sprintf("rebuilding replica %s (%d of %d)", replica, i+1, len(replicas)))
+
+		if err := rebuildMajorUpgradeReplica(clientset, restconfig, cluster, replica); err != nil {
+			failMajorUpgrade(clientset, restclient, cluster, task,
+				fmt.Sprintf("replica %s could not be rebuilt: %s", replica, err))
+			return
+		}
+	}
+
+	completeMajorUpgrade(clientset, restclient, cluster, task, targetVersion)
+}
+
+// rebuildMajorUpgradeReplica starts a replica whose data directory is still on
+// the old version of PostgreSQL and has Patroni reinitialize it. Patroni has to
+// have registered the replica before it can be reinitialized, so this is tried
+// until it is
+func rebuildMajorUpgradeReplica(clientset *kubernetes.Clientset, restconfig *rest.Config,
+	cluster *crv1.Pgcluster, instance string) error {
+	deployment, found, err := kubeapi.GetDeployment(clientset, instance, cluster.Namespace)
+	if !found {
+		return err
+	}
+
+	if err := kubeapi.ScaleDeployment(clientset, *deployment, 1); err != nil {
+		return err
+	}
+
+	selector := fmt.Sprintf("%s=%s", config.LABEL_DEPLOYMENT_NAME, instance)
+	timeout := time.After(majorUpgradeTimeout * time.Second)
+	tick := time.Tick(majorUpgradePeriod * time.Second)
+
+	for reinitialized := false; !reinitialized; {
+		select {
+		case <-timeout:
+			return fmt.Errorf("timed out waiting for replica %s to be reinitialized", instance)
+		case <-tick:
+			pods, err := kubeapi.GetPods(clientset, selector, cluster.Namespace)
+			if err != nil || len(pods.Items) == 0 || pods.Items[0].Status.Phase != v1.PodRunning {
+				continue
+			}
+
+			cmd := []string{"patronictl", "reinit", "--force", cluster.Labels[config.LABEL_PGHA_SCOPE],
+				pods.Items[0].Name}
+
+			if _, stderr, err := kubeapi.ExecToPodThroughAPI(restconfig, clientset, cmd, "database",
+				pods.Items[0].Name, cluster.Namespace, nil); err != nil {
+				log.Debugf("major upgrade: replica %s not reinitialized yet: %s %s", instance, err, stderr)
+				continue
+			}
+
+			reinitialized = true
+		}
+	}
+
+	return waitForDeploymentReady(clientset, cluster.Namespace, instance, majorUpgradeTimeout,
+		majorUpgradePeriod)
+}
+
+// rollbackMajorUpgrade starts the instances of a cluster again on the version
+// of PostgreSQL they were on, primary first, after the upgrade failed before
+// the primary was started on the new version, and marks the pgtask as failed
+func rollbackMajorUpgrade(clientset *kubernetes.Clientset, restclient *rest.RESTClient, cluster *crv1.Pgcluster,
+	task *crv1.Pgtask, message string) {
+	primary := task.Spec.Parameters[crv1.PgtaskMajorUpgradePrimaryInstance]
+	currentVersion, _, _ := getMajorUpgradeVersions(cluster, task)
+
+	log.Errorf("major upgrade: rolling back cluster %s: %s", cluster.Name, message)
+
+	if deployments, err := operator.GetInstanceDeployments(clientset, cluster); err != nil {
+		log.Error(err)
+	} else {
+		instances := make([]string, 0, len(deployments.Items))
+		for _, deployment := range deployments.Items {
+			instances = append(instances, deployment.Name)
+		}
+
+		order := getRollingRestartOrder(instances, primary)
+
+		for i := len(order) - 1; i >= 0; i-- {
+			if err := startMajorUpgradeInstance(clientset, order[i], cluster.Namespace); err != nil {
+				log.Error(err)
+			}
+		}
+	}
+
+	failMajorUpgrade(clientset, restclient, cluster, task,
+		fmt.Sprintf("%s; the cluster was started again on PostgreSQL %s", message, currentVersion))
+}
+
+// validateMajorUpgrade ensures that the cluster is running and that the image
+// tag it is upgraded to is of the version it is upgraded to
+func validateMajorUpgrade(cluster *crv1.Pgcluster, task *crv1.Pgtask) error {
+	if cluster.Status.State != crv1.PgclusterStateInitialized || cluster.Spec.Shutdown {
+		return fmt.Errorf("cluster %s must be running to be upgraded", cluster.Name)
+	}
+
+	_, targetVersion, err := getMajorUpgradeVersions(cluster, task)
+	if err != nil {
+		return err
+	}
+
+	if ccpImageTag := getMajorUpgradeImageTag(task); getPGMajorVersion(ccpImageTag) != targetVersion {
+		return fmt.Errorf("image tag %s is not of PostgreSQL %s", ccpImageTag, targetVersion)
+	}
+
+	return nil
+}
+
+// getMajorUpgradeVersions returns the current and the target major versions
+// of PostgreSQL of a major upgrade. The current version is taken from the
+// image tag of the cluster unless the pgtask sets it
+func getMajorUpgradeVersions(cluster *crv1.Pgcluster, task *crv1.Pgtask) (string, string, error) {
+	currentVersion := task.Spec.Parameters[crv1.PgtaskMajorUpgradeCurrentVersion]
+	if currentVersion == "" {
+		currentVersion = getPGMajorVersion(cluster.Spec.CCPImageTag)
+	}
+
+	targetVersion := task.Spec.Parameters[crv1.PgtaskMajorUpgradeTargetVersion]
+
+	if currentVersion == "" || targetVersion == "" {
+		return currentVersion, targetVersion,
+			errors.New("could not determine the current and target PostgreSQL versions for the major upgrade")
+	}
+
+	if currentVersion == targetVersion {
+		return currentVersion, targetVersion,
+			fmt.Errorf("cluster %s is already on PostgreSQL %s", cluster.Name, currentVersion)
+	}
+
+	return currentVersion, targetVersion, nil
+}
+
+// getMajorUpgradeImageTag returns the image tag of a major upgrade, which is
+// that of the pgtask or else the one the Operator is configured with
+func getMajorUpgradeImageTag(task *crv1.Pgtask) string {
+	if ccpImageTag := task.Spec.Parameters[crv1.PgtaskMajorUpgradeCCPImageTag]; ccpImageTag != "" {
+		return ccpImageTag
+	}
+
+	return operator.Pgo.Cluster.CCPImageTag
+}
+
+// getMajorUpgradeTask returns the major upgrade pgtask of a cluster that is
+// rebuilding its replicas, or nil if there is none
+func getMajorUpgradeTask(restclient *rest.RESTClient, clusterName, namespace string) (*crv1.Pgtask, error) {
+	tasks := crv1.PgtaskList{}
+	selector := fmt.Sprintf("%s=%s", config.LABEL_PG_CLUSTER, clusterName)
+
+	if err := kubeapi.GetpgtasksBySelector(restclient, &tasks, selector, namespace); err != nil {
+		return nil, err
+	}
+
+	for i := range tasks.Items {
+		if tasks.Items[i].Spec.TaskType == crv1.PgtaskMajorUpgrade &&
+			tasks.Items[i].Spec.Status == crv1.PgtaskMajorUpgradeRebuildingReplicas {
+			return &tasks.Items[i], nil
+		}
+	}
+
+	return nil, nil
+}
+
+// getMajorUpgradeVolumes returns the volumes of an instance that pg_upgrade
+// needs, i.e. its data directory and its tablespaces, along with where they
+// are mounted in the database container, and the name of the PVC of the data
+// directory
+func getMajorUpgradeVolumes(deployment *apps_v1.Deployment) ([]v1.Volume, []v1.VolumeMount, string) {
+	volumes := []v1.Volume{}
+	names := map[string]bool{}
+	pvcName := ""
+
+	for _, volume := range deployment.Spec.Template.Spec.Volumes {
+		if volume.Name == config.VOLUME_POSTGRESQL_DATA && volume.PersistentVolumeClaim != nil {
+			pvcName = volume.PersistentVolumeClaim.ClaimName
+		} else if !strings.HasPrefix(volume.Name, config.VOLUME_TABLESPACE_NAME_PREFIX) {
+			continue
+		}
+
+		volumes = append(volumes, volume)
+		names[volume.Name] = true
+	}
+
+	mounts := []v1.VolumeMount{}
+	for _, container := range deployment.Spec.Template.Spec.Containers {
+		if container.Name != "database" {
+			continue
+		}
+
+		for _, mount := range container.VolumeMounts {
+			if names[mount.Name] {
+				mounts = append(mounts, mount)
+			}
+		}
+	}
+
+	return volumes, mounts, pvcName
+}
+
+// stopMajorUpgradeInstance scales down the Deployment of an instance and waits
+// for its Pod to be removed
+func stopMajorUpgradeInstance(clientset *kubernetes.Clientset, instance, namespace string) error {
+	deployment, found, err := kubeapi.GetDeployment(clientset, instance, namespace)
+	if !found {
+		return err
+	}
+
+	log.Debugf("major upgrade: stopping instance %s", instance)
+
+	if err := kubeapi.ScaleDeployment(clientset, *deployment, 0); err != nil {
+		return err
+	}
+
+	return waitForInstanceStopped(clientset, namespace, instance, majorUpgradeTimeout, majorUpgradePeriod)
+}
+
+// startMajorUpgradeInstance scales the Deployment of an instance back up and
+// waits for it to be ready
+func startMajorUpgradeInstance(clientset *kubernetes.Clientset, instance, namespace string) error {
+	deployment, found, err := kubeapi.GetDeployment(clientset, instance, namespace)
+	if !found {
+		return err
+	}
+
+	log.Debugf("major upgrade: starting instance %s", instance)
+
+	if err := kubeapi.ScaleDeployment(clientset, *deployment, 1); err != nil {
+		return err
+	}
+
+	return waitForDeploymentReady(clientset, namespace, instance, majorUpgradeTimeout, majorUpgradePeriod)
+}
+
+// resetPatroniInitialize removes the system identifier that Patroni recorded
+// when the cluster was initialized. pg_upgrade initializes a new data
+// directory, which Patroni would otherwise refuse to start, whereas this way
+// Patroni records the system identifier of the upgraded data directory once
+// the primary starts
+func resetPatroniInitialize(clientset *kubernetes.Clientset, cluster *crv1.Pgcluster) error {
+	configMapName := cluster.Labels[config.LABEL_PGHA_SCOPE] + "-config"
+
+	configMap, found := kubeapi.GetConfigMap(clientset, configMapName, cluster.Namespace)
+	if !found {
+		return fmt.Errorf("could not find configMap %s", configMapName)
+	}
+
+	if _, ok := configMap.ObjectMeta.Annotations["initialize"]; !ok {
+		return nil
+	}
+
+	delete(configMap.ObjectMeta.Annotations, "initialize")
+
+	return kubeapi.UpdateConfigMap(clientset, configMap, cluster.Namespace)
+}
+
+// updateMajorUpgradeExtensions updates the extensions of each database to the
+// version that the new version of PostgreSQL provides
+func updateMajorUpgradeExtensions(clientset *kubernetes.Clientset, restconfig *rest.Config, pod *v1.Pod) error {
+	databases, err := execMaintenanceSQL(clientset, restconfig, pod, "postgres", sqlMaintenanceDatabases)
+	if err != nil {
+		return err
+	}
+
+	for _, database := range strings.Fields(databases) {
+		statements, err := execMaintenanceSQL(clientset, restconfig, pod, database, sqlMajorUpgradeExtensions)
+		if err != nil {
+			return err
+		}
+
+		if statements == "" {
+			continue
+		}
+
+		log.Debugf("major upgrade: updating extensions of database %s: %s", database, statements)
+
+		if err := execMaintenanceScript(clientset, restconfig, pod, database, statements); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// createMajorUpgradeJob creates the Job that runs pg_upgrade against the data
+// directory of an instance. Any Job left behind by an earlier attempt is
+// removed first
+func createMajorUpgradeJob(clientset *kubernetes.Clientset, cluster *crv1.Pgcluster, task *crv1.Pgtask,
+	instance string) error {
+	currentVersion, targetVersion, err := getMajorUpgradeVersions(cluster, task)
+	if err != nil {
+		return err
+	}
+
+	deployment, found, err := kubeapi.GetDeployment(clientset, instance, cluster.Namespace)
+	if !found {
+		return err
+	}
+
+	volumes, mounts, pvcName := getMajorUpgradeVolumes(deployment)
+	if pvcName == "" {
+		return fmt.Errorf("could not find the data PVC for instance %s", instance)
+	}
+
+	jobName := fmt.Sprintf(majorUpgradeJobName, instance)
+
+	if oldJob, found := kubeapi.GetJob(clientset, jobName, cluster.Namespace); found {
+		if err := kubeapi.DeleteJob(clientset, jobName, cluster.Namespace); err != nil {
+			return err
+		}
+
+		if err := kubeapi.IsJobDeleted(clientset, cluster.Namespace, oldJob,
+			majorUpgradeTimeout*time.Second); err != nil {
+			return err
+		}
+	}
+
+	// set the backoff limit to be 0 to match our other jobs
+	backoffLimit := int32(0)
+
+	labels := map[string]string{
+		config.LABEL_VENDOR:        config.LABEL_CRUNCHY,
+		config.LABEL_PG_CLUSTER:    cluster.Name,
+		config.LABEL_PGTASK:        task.Spec.Name,
+		config.LABEL_PGOUSER:       task.ObjectMeta.Labels[config.LABEL_PGOUSER],
+		config.LABEL_MAJOR_UPGRADE: config.LABEL_TRUE,
+	}
+
+	job := batch_v1.Job{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:   jobName,
+			Labels: labels,
+		},
+		Spec: batch_v1.JobSpec{
+			BackoffLimit: &backoffLimit,
+			Template: v1.PodTemplateSpec{
+				ObjectMeta: meta_v1.ObjectMeta{
+					Name:   jobName,
+					Labels: labels,
+				},
+				Spec: v1.PodSpec{
+					Containers: []v1.Container{
+						v1.Container{
+							Name: majorUpgradeContainerName,
+							Image: fmt.Sprintf("%s/%s:%s", operator.Pgo.Cluster.CCPImagePrefix,
+								config.CONTAINER_IMAGE_CRUNCHY_UPGRADE, getMajorUpgradeImageTag(task)),
+							Command: []string{"bash", "-c", majorUpgradeScript},
+							Env: []v1.EnvVar{
+								v1.EnvVar{
+									Name:  "PGDATA_OLD",
+									Value: fmt.Sprintf("%s/%s", config.VOLUME_POSTGRESQL_DATA_MOUNT_PATH, pvcName),
+								},
+								v1.EnvVar{Name: "PG_VERSION_OLD", Value: currentVersion},
+								v1.EnvVar{Name: "PG_VERSION_NEW", Value: targetVersion},
+								v1.EnvVar{Name: "PGUPGRADE_WORK_PATH", Value: majorUpgradePrecheckWorkPath},
+							},
+							VolumeMounts: append(mounts, v1.VolumeMount{
+								MountPath: majorUpgradePrecheckWorkPath,
+								Name:      majorUpgradePrecheckWorkVolume,
+							}),
+						},
+					},
+					NodeSelector:  operator.GetNodeSelector(cluster),
+					RestartPolicy: v1.RestartPolicyNever,
+					SecurityContext: &v1.PodSecurityContext{
+						FSGroup:            &crv1.PGFSGroup,
+						SupplementalGroups: cluster.Spec.PrimaryStorage.GetSupplementalGroups(),
+					},
+					Tolerations: operator.GetTolerations(cluster),
+					Volumes: append(volumes, v1.Volume{
+						Name: majorUpgradePrecheckWorkVolume,
+						VolumeSource: v1.VolumeSource{
+							EmptyDir: &v1.EmptyDirVolumeSource{},
+						},
+					}),
+				},
+			},
+		},
+	}
+
+	// set the container image to an override value, if one exists
+	operator.SetContainerImageOverride(config.CONTAINER_IMAGE_CRUNCHY_UPGRADE,
+		&job.Spec.Template.Spec.Containers[0])
+
+	// apply the node affinity and the PriorityClass of the cluster
+	operator.AddPodScheduling(cluster, nil, &job.Spec.Template.Spec)
+
+	_, err = kubeapi.CreateJob(clientset, &job, cluster.Namespace)
+
+	return err
+}
+
+// updateMajorUpgradeTask records the status of a major upgrade on its pgtask,
+// along with a message of its progress
+func updateMajorUpgradeTask(restclient *rest.RESTClient, task *crv1.Pgtask, status, message string) {
+	// get the latest version of the task in case it changed
+	if found, err := kubeapi.Getpgtask(restclient, task, task.Spec.Name, task.Namespace); !found {
+		log.Error(err)
+		return
+	}
+
+	if task.Spec.Status != status {
+		task.Spec.Status = status
+
+		if err := kubeapi.Updatepgtask(restclient, task, task.Spec.Name, task.Namespace); err != nil {
+			log.Error("error in updating major upgrade pgtask status " + err.Error())
+		}
+	}
+
+	// the task finishes along with the major upgrade
+	phase := crv1.PgtaskPhaseRunning
+	switch status {
+	case crv1.PgtaskMajorUpgradeCompleted:
+		phase = crv1.PgtaskPhaseSucceeded
+	case crv1.PgtaskMajorUpgradeFailed:
+		phase = crv1.PgtaskPhaseFailed
+	}
+
+	if err := kubeapi.PatchpgtaskPhase(restclient, crv1.PgtaskStateProcessed, phase, message, task,
+		task.Namespace); err != nil {
+		log.Error(err)
+	}
+}
+
+// completeMajorUpgrade completes the pgtask once the primary runs the new
+// version of PostgreSQL and the replicas are rebuilt
+func completeMajorUpgrade(clientset *kubernetes.Clientset, restclient *rest.RESTClient, cluster *crv1.Pgcluster,
+	task *crv1.Pgtask, targetVersion string) {
+	log.Debugf("major upgrade: cluster %s upgraded to PostgreSQL %s", cluster.Name, targetVersion)
+
+	message := fmt.Sprintf("cluster upgraded to PostgreSQL %s", targetVersion)
+
+	updateMajorUpgradeTask(restclient, task, crv1.PgtaskMajorUpgradeCompleted, message)
+
+	operator.RecordNormalEvent(clientset, cluster, eventReasonMajorUpgradeCompleted, message)
+}
+
+// failMajorUpgrade marks the pgtask as failed and records why on both the
+// pgtask and the cluster
+func failMajorUpgrade(clientset *kubernetes.Clientset, restclient *rest.RESTClient, cluster *crv1.Pgcluster,
+	task *crv1.Pgtask, message string) {
+	log.Errorf("major upgrade: failed on cluster %s: %s", cluster.Name, message)
+
+	updateMajorUpgradeTask(restclient, task, crv1.PgtaskMajorUpgradeFailed, message)
+
+	operator.RecordWarningEvent(clientset, cluster, eventReasonMajorUpgradeFailed, message)
+}
+
+// getMajorUpgradeJobOutput returns the tail end of the logs of the Pod that
+// ran pg_upgrade
+func getMajorUpgradeJobOutput(clientset *kubernetes.Clientset, job *batch_v1.Job) (string, error) {
+	selector := fmt.Sprintf("%s=%s", config.LABEL_JOB_NAME, job.Name)
+
+	pods, err := kubeapi.GetPods(clientset, selector, job.Namespace)
+	if err != nil {
+		return "", err
+	}
+
+	if len(pods.Items) == 0 {
+		return "", fmt.Errorf("no pods found for selector [%s]", selector)
+	}
+
+	output, err := kubeapi.GetPodLogs(clientset, pods.Items[0].Name, majorUpgradeContainerName,
+		job.Namespace, majorUpgradePrecheckLogLines)
+
+	return strings.TrimSpace(output), err
+}
+
+// getMajorUpgradePrecheckOutput returns the tail end of the logs of the Pod
+// that ran the precheck
+func getMajorUpgradePrecheckOutput(clientset *kubernetes.Clientset, job *batch_v1.Job) (string, error) {
+	selector := fmt.Sprintf("%s=%s", config.LABEL_JOB_NAME, job.Name)
+
+	pods, err := kubeapi.GetPods(clientset, selector, job.Namespace)
+	if err != nil {
+		return "", err
+	}
+
+	if len(pods.Items) == 0 {
+		return "", fmt.Errorf("no pods found for selector [%s]", selector)
+	}
+
+	output, err := kubeapi.GetPodLogs(clientset, pods.Items[0].Name, majorUpgradePrecheckContainerName,
+		job.Namespace, majorUpgradePrecheckLogLines)
+
+	return strings.TrimSpace(output), err
+}
+
+// getPGMajorVersion determines the major version of PostgreSQL from a ccp
+// image tag, e.g. "centos7-12.2-4.3.0" returns "12" and "centos7-9.6.17-4.3.0"
+// returns "9.6". An empty string is returned if the version cannot be found
+func getPGMajorVersion(ccpImageTag string) string {
+	parts := strings.Split(ccpImageTag, "-")
+
+	if len(parts) < 2 {
+		return ""
+	}
+
+	version := strings.Split(parts[1], ".")
+
+	// prior to PostgreSQL 10, the major version consisted of two numbers
+	if len(version) > 1 && version[0] == "9" {
+		return version[0] + "." + version[1]
+	}
+
+	return version[0]
+}
+
+// publishMajorUpgradeStartedEvent indicates that the precheck for a major
+// upgrade has cleared and the upgrade may proceed
+func publishMajorUpgradeStartedEvent(upgradeTask *crv1.Pgtask, cluster *crv1.Pgcluster, namespace string) {
+	topics := make([]string, 1)
+	topics[0] = events.EventTopicCluster
+
+	f := events.EventUpgradeClusterFormat{
+		EventHeader: events.EventHeader{
+			Namespace: namespace,
+			Username:  upgradeTask.ObjectMeta.Labels[config.LABEL_PGOUSER],
+			Topic:     topics,
+			Timestamp: time.Now(),
+			EventType: events.EventUpgradeCluster,
+		},
+		Clustername: cluster.Name,
+	}
+
+	if err := events.Publish(f); err != nil {
+		log.Error(err)
+	}
+}
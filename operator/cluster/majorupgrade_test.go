@@ -0,0 +1,117 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"strings"
+	"testing"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/config"
+	apps_v1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestValidateMajorUpgrade(t *testing.T) {
+	tests := []struct {
+		name       string
+		state      crv1.PgclusterState
+		parameters map[string]string
+		err        string
+	}{
+		{"valid", crv1.PgclusterStateInitialized, map[string]string{
+			crv1.PgtaskMajorUpgradeTargetVersion: "12",
+			crv1.PgtaskMajorUpgradeCCPImageTag:   "centos7-12.3-4.3.0",
+		}, ""},
+		{"not running", crv1.PgclusterStateShutdown, map[string]string{
+			crv1.PgtaskMajorUpgradeTargetVersion: "12",
+			crv1.PgtaskMajorUpgradeCCPImageTag:   "centos7-12.3-4.3.0",
+		}, "must be running"},
+		{"no target version", crv1.PgclusterStateInitialized, map[string]string{
+			crv1.PgtaskMajorUpgradeCCPImageTag: "centos7-12.3-4.3.0",
+		}, "could not determine"},
+		{"same version", crv1.PgclusterStateInitialized, map[string]string{
+			crv1.PgtaskMajorUpgradeTargetVersion: "11",
+			crv1.PgtaskMajorUpgradeCCPImageTag:   "centos7-11.8-4.3.0",
+		}, "already on PostgreSQL 11"},
+		{"image tag of another version", crv1.PgclusterStateInitialized, map[string]string{
+			crv1.PgtaskMajorUpgradeTargetVersion: "12",
+			crv1.PgtaskMajorUpgradeCCPImageTag:   "centos7-13.0-4.3.0",
+		}, "is not of PostgreSQL 12"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cluster := &crv1.Pgcluster{
+				Spec:   crv1.PgclusterSpec{CCPImageTag: "centos7-11.8-4.3.0"},
+				Status: crv1.PgclusterStatus{State: test.state},
+			}
+			task := &crv1.Pgtask{Spec: crv1.PgtaskSpec{Parameters: test.parameters}}
+
+			err := validateMajorUpgrade(cluster, task)
+
+			if test.err == "" {
+				if err != nil {
+					t.Fatalf("expected no error, got %q", err)
+				}
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Fatalf("expected error containing %q, got %v", test.err, err)
+			}
+		})
+	}
+}
+
+func TestGetMajorUpgradeVolumes(t *testing.T) {
+	deployment := &apps_v1.Deployment{}
+	deployment.Spec.Template.Spec.Volumes = []v1.Volume{
+		{Name: config.VOLUME_POSTGRESQL_DATA, VolumeSource: v1.VolumeSource{
+			PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{ClaimName: "hippo-abcd"},
+		}},
+		{Name: "tablespace-lake", VolumeSource: v1.VolumeSource{
+			PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{ClaimName: "hippo-tablespace-lake"},
+		}},
+		{Name: "pgconf-volume"},
+	}
+	deployment.Spec.Template.Spec.Containers = []v1.Container{
+		{Name: "collect", VolumeMounts: []v1.VolumeMount{
+			{Name: config.VOLUME_POSTGRESQL_DATA, MountPath: "/elsewhere"},
+		}},
+		{Name: "database", VolumeMounts: []v1.VolumeMount{
+			{Name: config.VOLUME_POSTGRESQL_DATA, MountPath: config.VOLUME_POSTGRESQL_DATA_MOUNT_PATH},
+			{Name: "tablespace-lake", MountPath: "/tablespaces/lake"},
+			{Name: "pgconf-volume", MountPath: "/pgconf"},
+		}},
+	}
+
+	volumes, mounts, pvcName := getMajorUpgradeVolumes(deployment)
+
+	if pvcName != "hippo-abcd" {
+		t.Fatalf("expected data PVC %q, got %q", "hippo-abcd", pvcName)
+	}
+
+	if len(volumes) != 2 || volumes[0].Name != config.VOLUME_POSTGRESQL_DATA ||
+		volumes[1].Name != "tablespace-lake" {
+		t.Fatalf("expected the data and tablespace volumes, got %v", volumes)
+	}
+
+	if len(mounts) != 2 || mounts[0].MountPath != config.VOLUME_POSTGRESQL_DATA_MOUNT_PATH ||
+		mounts[1].MountPath != "/tablespaces/lake" {
+		t.Fatalf("expected the data and tablespace mounts of the database container, got %v", mounts)
+	}
+}
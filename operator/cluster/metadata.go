@@ -0,0 +1,154 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"fmt"
+	"strings"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/config"
+	"github.com/crunchydata/postgres-operator/kubeapi"
+	"github.com/crunchydata/postgres-operator/operator"
+	"github.com/crunchydata/postgres-operator/operator/backrest"
+	log "github.com/sirupsen/logrus"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ReconcileCustomMetadata sets the custom labels and annotations of the spec of a cluster on the
+// resources of the cluster, i.e. those that are labeled with the name of the cluster, and removes
+// those that were taken out of the spec. As with the chargeback labels, the Pods of a Deployment
+// are updated directly rather than through the template of the Deployment, as changing the
+// template would restart them
+func ReconcileCustomMetadata(clientset *kubernetes.Clientset, cluster *crv1.Pgcluster) error {
+	if err := operator.ValidateCustomMetadata(cluster); err != nil {
+		return err
+	}
+
+	selector := fmt.Sprintf("%s=%s", config.LABEL_PG_CLUSTER, cluster.Name)
+
+	// set reports whether the metadata of a resource changed, falling back to the metadata of
+	// the given component if the resource is not otherwise known to belong to a component
+	set := func(meta *meta_v1.ObjectMeta, fallback *crv1.ObjectMetadata) bool {
+		component := getCustomMetadataComponent(cluster, meta)
+		if component == nil {
+			component = fallback
+		}
+
+		labels, annotations := operator.GetCustomMetadata(cluster, component)
+
+		return operator.SetCustomMetadata(meta, labels, annotations)
+	}
+
+	deployments, err := kubeapi.GetDeployments(clientset, selector, cluster.Namespace)
+	if err != nil {
+		return err
+	}
+
+	for i := range deployments.Items {
+		if set(&deployments.Items[i].ObjectMeta, nil) {
+			log.Debugf("metadata: updating deployment %s", deployments.Items[i].Name)
+			_ = kubeapi.UpdateDeployment(clientset, &deployments.Items[i])
+		}
+	}
+
+	pods, err := kubeapi.GetPods(clientset, selector, cluster.Namespace)
+	if err != nil {
+		return err
+	}
+
+	for i := range pods.Items {
+		if set(&pods.Items[i].ObjectMeta, nil) {
+			log.Debugf("metadata: updating pod %s", pods.Items[i].Name)
+			_ = kubeapi.UpdatePod(clientset, &pods.Items[i], cluster.Namespace)
+		}
+	}
+
+	// the volumes that are not those of the repository are those of the instances
+	pvcs, err := kubeapi.GetPVCs(clientset, selector, cluster.Namespace)
+	if err != nil {
+		return err
+	}
+
+	for i := range pvcs.Items {
+		if set(&pvcs.Items[i].ObjectMeta, &cluster.Spec.Metadata.Postgres) {
+			log.Debugf("metadata: updating pvc %s", pvcs.Items[i].Name)
+			_ = kubeapi.UpdatePVC(clientset, &pvcs.Items[i], cluster.Namespace)
+		}
+	}
+
+	services, err := kubeapi.GetServices(clientset, selector, cluster.Namespace)
+	if err != nil {
+		return err
+	}
+
+	for i := range services.Items {
+		var fallback *crv1.ObjectMetadata
+
+		// the Services of the primary and of the replicas are those of the instances
+		if name := services.Items[i].Name; name == cluster.Name || name == cluster.Name+ReplicaSuffix ||
+			name == cluster.Name+ReadOnlySuffix {
+			fallback = &cluster.Spec.Metadata.Postgres
+		}
+
+		if set(&services.Items[i].ObjectMeta, fallback) {
+			log.Debugf("metadata: updating service %s", services.Items[i].Name)
+			_ = kubeapi.UpdateService(clientset, &services.Items[i], cluster.Namespace)
+		}
+	}
+
+	secrets, err := kubeapi.GetSecrets(clientset, selector, cluster.Namespace)
+	if err != nil {
+		return err
+	}
+
+	for i := range secrets.Items {
+		if set(&secrets.Items[i].ObjectMeta, nil) {
+			log.Debugf("metadata: updating secret %s", secrets.Items[i].Name)
+			_ = kubeapi.UpdateSecret(clientset, &secrets.Items[i], cluster.Namespace)
+		}
+	}
+
+	if configMaps, found := kubeapi.ListConfigMap(clientset, selector, cluster.Namespace); found {
+		for i := range configMaps.Items {
+			if set(&configMaps.Items[i].ObjectMeta, nil) {
+				log.Debugf("metadata: updating configmap %s", configMaps.Items[i].Name)
+				_ = kubeapi.UpdateConfigMap(clientset, &configMaps.Items[i], cluster.Namespace)
+			}
+		}
+	}
+
+	return nil
+}
+
+// getCustomMetadataComponent returns the metadata of the component of a cluster that a resource
+// belongs to, judging by its labels or its name, if it is known to belong to one
+func getCustomMetadataComponent(cluster *crv1.Pgcluster, meta *meta_v1.ObjectMeta) *crv1.ObjectMetadata {
+	switch {
+	case meta.Labels[config.LABEL_PGO_BACKREST_REPO] == config.LABEL_TRUE,
+		meta.Name == fmt.Sprintf(backrest.BackrestRepoPVCName, cluster.Name),
+		meta.Name == fmt.Sprintf("%s-%s", cluster.Name, config.LABEL_BACKREST_REPO_SECRET):
+		return &cluster.Spec.Metadata.PgBackRest
+	case meta.Labels[config.LABEL_PGBOUNCER] == config.LABEL_TRUE,
+		strings.HasPrefix(meta.Name, cluster.Name+"-pgbouncer"):
+		return &cluster.Spec.Metadata.PgBouncer
+	case meta.Labels[config.LABEL_PG_DATABASE] == config.LABEL_TRUE:
+		return &cluster.Spec.Metadata.Postgres
+	}
+
+	return nil
+}
@@ -0,0 +1,257 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/config"
+	"github.com/crunchydata/postgres-operator/kubeapi"
+	"github.com/crunchydata/postgres-operator/operator"
+	"github.com/crunchydata/postgres-operator/util"
+	log "github.com/sirupsen/logrus"
+	apps_v1 "k8s.io/api/apps/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	// eventReasonMinorUpgradeStarted is the reason of the events that are recorded when the
+	// instances of a cluster start to be updated to its image tag
+	eventReasonMinorUpgradeStarted = "MinorUpgradeStarted"
+
+	// eventReasonMinorUpgradeCompleted is the reason of the events that are recorded when all of
+	// the instances of a cluster were updated to its image tag
+	eventReasonMinorUpgradeCompleted = "MinorUpgradeCompleted"
+
+	// eventReasonMinorUpgradeFailed is the reason of the Warning events that are recorded when
+	// the instances of a cluster could not be updated to its image tag
+	eventReasonMinorUpgradeFailed = "MinorUpgradeFailed"
+)
+
+// minorUpgrading holds the clusters whose instances are being updated to their image tag, so
+// that an update is not started again while it runs
+var minorUpgrading sync.Map
+
+// ReconcileMinorUpgrade updates the instances of a running cluster that do not run the image
+// tag of its spec to it, by means of a rolling restart: the replicas are updated one at a time,
+// and the primary is switched over to an updated replica before it is updated last. The progress
+// is recorded in the status of the cluster, which the "Upgrading" condition of the cluster is
+// evaluated from. Instances that already run the image tag, e.g. as an earlier update failed
+// midway, are left as they are. A change of the major version of PostgreSQL is refused, as it
+// requires a major upgrade
+func ReconcileMinorUpgrade(clientset *kubernetes.Clientset, restclient *rest.RESTClient,
+	restconfig *rest.Config, cluster *crv1.Pgcluster) error {
+	if cluster.Status.State != crv1.PgclusterStateInitialized || cluster.Spec.Shutdown {
+		return nil
+	}
+
+	// a minor upgrade pgtask updates the instances itself, and sets the image tag once it is done
+	if cluster.Spec.UserLabels[config.LABEL_MINOR_UPGRADE] == config.LABEL_UPGRADE_IN_PROGRESS {
+		return nil
+	}
+
+	key := cluster.Namespace + "/" + cluster.Name
+	if _, running := minorUpgrading.Load(key); running {
+		return nil
+	}
+
+	deployments, err := operator.GetInstanceDeployments(clientset, cluster)
+	if err != nil {
+		return err
+	}
+
+	image := getDatabaseImage(*cluster, operator.Pgo.Cluster.CCPImagePrefix, cluster.Spec.CCPImageTag)
+	updated, pending := getMinorUpgradeInstances(deployments.Items, image)
+
+	status := crv1.MinorUpgradeStatus{
+		ImageTag:         cluster.Spec.CCPImageTag,
+		Instances:        len(deployments.Items),
+		UpdatedInstances: updated,
+	}
+
+	if len(pending) == 0 {
+		if status.ImageTag == cluster.Status.MinorUpgrade.ImageTag &&
+			!cluster.Status.MinorUpgrade.InProgress {
+			return nil
+		}
+
+		return kubeapi.Patchpgcluster(restclient, cluster, cluster.Namespace, func(cluster *crv1.Pgcluster) {
+			cluster.Status.MinorUpgrade = status
+		})
+	}
+
+	if err := validateMinorUpgrade(cluster, deployments.Items, pending); err != nil {
+		operator.RecordWarningEvent(clientset, cluster, eventReasonMinorUpgradeFailed, err.Error())
+
+		status.Message = err.Error()
+		if patchErr := kubeapi.Patchpgcluster(restclient, cluster, cluster.Namespace, func(cluster *crv1.Pgcluster) {
+			cluster.Status.MinorUpgrade = status
+		}); patchErr != nil {
+			log.Error(patchErr)
+		}
+		return err
+	}
+
+	if _, running := minorUpgrading.Load(key); running {
+		return nil
+	}
+
+	deferred, err := deferMaintenanceAction(clientset, restclient, cluster,
+		maintenanceActionMinorUpgrade)
+	if err != nil || deferred {
+		return err
+	}
+
+	if _, running := minorUpgrading.LoadOrStore(key, true); running {
+		return nil
+	}
+
+	patch, err := createImageNamePatch(*cluster, operator.Pgo.Cluster.CCPImagePrefix,
+		cluster.Spec.CCPImageTag)
+	if err != nil {
+		minorUpgrading.Delete(key)
+		return err
+	}
+
+	status.InProgress = true
+
+	if err := kubeapi.Patchpgcluster(restclient, cluster, cluster.Namespace, func(cluster *crv1.Pgcluster) {
+		cluster.Status.MinorUpgrade = status
+	}); err != nil {
+		minorUpgrading.Delete(key)
+		return err
+	}
+
+	log.Infof("updating instances %v of cluster %s to image tag %s", pending, cluster.Name,
+		cluster.Spec.CCPImageTag)
+
+	operator.RecordNormalEvent(clientset, cluster, eventReasonMinorUpgradeStarted,
+		fmt.Sprintf("updating %d of %d instances to image tag %s", len(pending), status.Instances,
+			status.ImageTag))
+
+	go func() {
+		defer minorUpgrading.Delete(key)
+
+		err := RollingRestart(clientset, restconfig, cluster, updated,
+			func(instance string) error {
+				return kubeapi.PatchDeploymentStrategicMerge(clientset, instance, cluster.Namespace, patch)
+			},
+			func(instance string) error {
+				status.UpdatedInstances = append(status.UpdatedInstances, instance)
+				return kubeapi.Patchpgcluster(restclient, cluster, cluster.Namespace, func(cluster *crv1.Pgcluster) {
+					cluster.Status.MinorUpgrade = status
+				})
+			})
+
+		status.InProgress = false
+
+		if err != nil {
+			log.Errorf("could not update cluster %s to image tag %s: %s", cluster.Name,
+				status.ImageTag, err)
+
+			operator.RecordWarningEvent(clientset, cluster, eventReasonMinorUpgradeFailed,
+				fmt.Sprintf("the instances could not be updated to image tag %s: %s", status.ImageTag, err))
+
+			status.Message = err.Error()
+		} else {
+			operator.RecordNormalEvent(clientset, cluster, eventReasonMinorUpgradeCompleted,
+				fmt.Sprintf("all instances were updated to image tag %s", status.ImageTag))
+		}
+
+		if err := kubeapi.Patchpgcluster(restclient, cluster, cluster.Namespace, func(cluster *crv1.Pgcluster) {
+			cluster.Status.MinorUpgrade = status
+		}); err != nil {
+			log.Error(err)
+		}
+	}()
+
+	return nil
+}
+
+// validateMinorUpgrade ensures that the instances of a cluster can be updated to the image tag
+// of its spec by a rolling restart, which relies on Patroni to start PostgreSQL on the updated
+// instances and to switch over to them, and which cannot change the major version of PostgreSQL
+func validateMinorUpgrade(cluster *crv1.Pgcluster, deployments []apps_v1.Deployment,
+	pending []string) error {
+	if !util.IsAutofailEnabled(cluster) {
+		return errors.New("the image tag can only be rolled out while autofail is enabled")
+	}
+
+	target := getPGMajorVersion(cluster.Spec.CCPImageTag)
+
+	for _, deployment := range deployments {
+		if !containsString(pending, deployment.Name) {
+			continue
+		}
+
+		image := getDeploymentDatabaseImage(&deployment)
+		tag := image[strings.LastIndex(image, ":")+1:]
+
+		if current := getPGMajorVersion(tag); current != "" && target != "" && current != target {
+			return fmt.Errorf("image tag %s changes the major version of PostgreSQL from %s to %s, "+
+				"which requires a major upgrade", cluster.Spec.CCPImageTag, current, target)
+		}
+	}
+
+	return nil
+}
+
+// getMinorUpgradeInstances returns the names of the instances that run the given image in their
+// database container, and of those that do not, each in order
+func getMinorUpgradeInstances(deployments []apps_v1.Deployment, image string) ([]string, []string) {
+	updated, pending := []string{}, []string{}
+
+	for i := range deployments {
+		if getDeploymentDatabaseImage(&deployments[i]) == image {
+			updated = append(updated, deployments[i].Name)
+		} else {
+			pending = append(pending, deployments[i].Name)
+		}
+	}
+
+	sort.Strings(updated)
+	sort.Strings(pending)
+
+	return updated, pending
+}
+
+// getDeploymentDatabaseImage returns the image of the database container of an instance
+func getDeploymentDatabaseImage(deployment *apps_v1.Deployment) string {
+	for _, container := range deployment.Spec.Template.Spec.Containers {
+		if container.Name == "database" {
+			return container.Image
+		}
+	}
+
+	return ""
+}
+
+// containsString returns whether the value is one of the values
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
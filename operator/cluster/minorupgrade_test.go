@@ -0,0 +1,100 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/config"
+	apps_v1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// minorUpgradeDeployment returns the Deployment of an instance that runs the given image
+func minorUpgradeDeployment(name, image string) apps_v1.Deployment {
+	deployment := apps_v1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: name}}
+	deployment.Spec.Template.Spec.Containers = []v1.Container{
+		{Name: "collect", Image: "registry/crunchy-collect:centos7-12.3-4.3.0"},
+		{Name: "database", Image: image},
+	}
+
+	return deployment
+}
+
+func TestGetMinorUpgradeInstances(t *testing.T) {
+	image := "registry/crunchy-postgres-ha:centos7-12.4-4.3.0"
+
+	deployments := []apps_v1.Deployment{
+		minorUpgradeDeployment("hippo-abcd", "registry/crunchy-postgres-ha:centos7-12.3-4.3.0"),
+		minorUpgradeDeployment("hippo", image),
+		minorUpgradeDeployment("hippo-efgh", image),
+		{ObjectMeta: metav1.ObjectMeta{Name: "hippo-ijkl"}},
+	}
+
+	updated, pending := getMinorUpgradeInstances(deployments, image)
+
+	if expected := []string{"hippo", "hippo-efgh"}; !reflect.DeepEqual(updated, expected) {
+		t.Fatalf("expected updated instances %v, got %v", expected, updated)
+	}
+
+	if expected := []string{"hippo-abcd", "hippo-ijkl"}; !reflect.DeepEqual(pending, expected) {
+		t.Fatalf("expected pending instances %v, got %v", expected, pending)
+	}
+}
+
+func TestValidateMinorUpgrade(t *testing.T) {
+	tests := []struct {
+		name     string
+		tag      string
+		autofail string
+		err      string
+	}{
+		{"minor version", "centos7-12.4-4.3.0", "true", ""},
+		{"unknown version", "latest", "true", ""},
+		{"major version", "centos7-13.0-4.3.0", "true", "requires a major upgrade"},
+		{"autofail disabled", "centos7-12.4-4.3.0", "false", "autofail is enabled"},
+	}
+
+	deployments := []apps_v1.Deployment{
+		minorUpgradeDeployment("hippo", "registry/crunchy-postgres-ha:centos7-12.3-4.3.0"),
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cluster := &crv1.Pgcluster{
+				ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{config.LABEL_AUTOFAIL: test.autofail}},
+				Spec:       crv1.PgclusterSpec{CCPImageTag: test.tag},
+			}
+
+			err := validateMinorUpgrade(cluster, deployments, []string{"hippo"})
+
+			if test.err == "" {
+				if err != nil {
+					t.Fatalf("expected no error, got %q", err)
+				}
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Fatalf("expected error containing %q, got %v", test.err, err)
+			}
+		})
+	}
+}
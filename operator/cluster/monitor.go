@@ -0,0 +1,277 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/config"
+	"github.com/crunchydata/postgres-operator/kubeapi"
+	"github.com/crunchydata/postgres-operator/operator"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// monitorPort is the name of the port of the exporter, both in the instance Pods and in
+	// the Services of the primary and the replicas
+	monitorPort = "postgres-exporter"
+
+	// monitorPath is the path the exporter serves its metrics on
+	monitorPath = "/metrics"
+)
+
+var (
+	// monitorInterval is what a Prometheus duration looks like, e.g. "30s" or "1m30s"
+	monitorInterval = regexp.MustCompile(`^([0-9]+(ms|s|m|h|d|w|y))+$`)
+
+	// monitorLabelChars are the characters of a label that Prometheus replaces with an
+	// underscore in the meta label it discovers the label as
+	monitorLabelChars = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+)
+
+// ValidateMonitor returns an error if the monitor spec of a cluster cannot be applied, i.e. its
+// kind is not known or its interval is not a Prometheus duration
+func ValidateMonitor(cluster *crv1.Pgcluster) error {
+	spec := cluster.Spec.Monitor
+
+	switch spec.Kind {
+	case "", crv1.MonitorKindServiceMonitor, crv1.MonitorKindPodMonitor:
+	default:
+		return fmt.Errorf("invalid monitor kind %q, must be %q or %q", spec.Kind,
+			crv1.MonitorKindServiceMonitor, crv1.MonitorKindPodMonitor)
+	}
+
+	if spec.Interval != "" && !monitorInterval.MatchString(spec.Interval) {
+		return fmt.Errorf("invalid monitor interval %q, must be a duration such as \"30s\"", spec.Interval)
+	}
+
+	return nil
+}
+
+// ReconcileMonitor brings the ServiceMonitor or PodMonitor of a cluster in line with its spec:
+// the one of the kind of the spec is created or updated while the exporter is enabled, and the
+// other one is removed, as is either one once the exporter is disabled
+func ReconcileMonitor(clientset *kubernetes.Clientset, cluster *crv1.Pgcluster) error {
+	if err := ValidateMonitor(cluster); err != nil {
+		return err
+	}
+
+	kind := cluster.Spec.Monitor.Kind
+	if !operator.IsExporterEnabled(&cluster.Spec) {
+		kind = ""
+	}
+
+	if kind != crv1.MonitorKindServiceMonitor {
+		if err := kubeapi.DeleteServiceMonitor(clientset, cluster.Name,
+			cluster.Namespace); err != nil && !kerrors.IsNotFound(err) {
+			return err
+		}
+	}
+
+	if kind != crv1.MonitorKindPodMonitor {
+		if err := kubeapi.DeletePodMonitor(clientset, cluster.Name,
+			cluster.Namespace); err != nil && !kerrors.IsNotFound(err) {
+			return err
+		}
+	}
+
+	switch kind {
+	case crv1.MonitorKindServiceMonitor:
+		return reconcileServiceMonitor(clientset, cluster)
+	case crv1.MonitorKindPodMonitor:
+		return reconcilePodMonitor(clientset, cluster)
+	}
+
+	return nil
+}
+
+// DeleteMonitor removes the ServiceMonitor or PodMonitor of a cluster, if it has one
+func DeleteMonitor(clientset *kubernetes.Clientset, cluster *crv1.Pgcluster) error {
+	// a cluster that never had one is not looked for, as the Prometheus Operator may not be
+	// installed at all
+	if cluster.Spec.Monitor.Kind == "" {
+		return nil
+	}
+
+	if err := kubeapi.DeleteServiceMonitor(clientset, cluster.Name,
+		cluster.Namespace); err != nil && !kerrors.IsNotFound(err) {
+		return err
+	}
+
+	if err := kubeapi.DeletePodMonitor(clientset, cluster.Name,
+		cluster.Namespace); err != nil && !kerrors.IsNotFound(err) {
+		return err
+	}
+
+	return nil
+}
+
+// getMonitorEndpoint returns the endpoint that Prometheus scrapes the exporter of a cluster at.
+// The metrics are labeled like the pgMonitor scrape configuration labels them, so that the
+// pgMonitor dashboards and alerts apply to them
+func getMonitorEndpoint(cluster *crv1.Pgcluster) kubeapi.MonitorEndpoint {
+	spec := cluster.Spec.Monitor
+
+	endpoint := kubeapi.MonitorEndpoint{
+		Port:     monitorPort,
+		Path:     monitorPath,
+		Interval: spec.Interval,
+		Relabelings: []kubeapi.MonitorRelabelConfig{
+			{TargetLabel: "pg_cluster", Replacement: cluster.Namespace + ":" + cluster.Name},
+			{SourceLabels: []string{getMonitorPodLabel(config.LABEL_DEPLOYMENT_NAME)}, TargetLabel: "deployment"},
+			{SourceLabels: []string{getMonitorPodLabel(config.LABEL_PGHA_ROLE)}, TargetLabel: "role"},
+		},
+	}
+
+	if spec.TLS.IsEnabled() {
+		endpoint.Scheme = "https"
+		endpoint.TLSConfig = &kubeapi.MonitorTLSConfig{
+			ServerName:         spec.TLS.ServerName,
+			InsecureSkipVerify: spec.TLS.InsecureSkipVerify,
+		}
+
+		if spec.TLS.CASecret != "" {
+			endpoint.TLSConfig.CA = &kubeapi.MonitorSecretOrConfigMap{
+				Secret: &v1.SecretKeySelector{
+					LocalObjectReference: v1.LocalObjectReference{Name: spec.TLS.CASecret},
+					Key:                  "ca.crt",
+				},
+			}
+		}
+	}
+
+	return endpoint
+}
+
+// getMonitorLabels returns the labels of the ServiceMonitor or PodMonitor of a cluster, which
+// are the labels of the spec along with the labels that tell the cluster it belongs to
+func getMonitorLabels(cluster *crv1.Pgcluster) map[string]string {
+	labels := map[string]string{}
+
+	for key, value := range cluster.Spec.Monitor.Labels {
+		labels[key] = value
+	}
+
+	labels[config.LABEL_VENDOR] = config.LABEL_CRUNCHY
+	labels[config.LABEL_PG_CLUSTER] = cluster.Name
+
+	return labels
+}
+
+// getMonitorPodLabel returns the meta label that Prometheus discovers a label of a Pod as
+func getMonitorPodLabel(label string) string {
+	return "__meta_kubernetes_pod_label_" + monitorLabelChars.ReplaceAllString(label, "_")
+}
+
+// getServiceMonitor returns the ServiceMonitor of a cluster, which selects the Services of the
+// primary and the replicas, as the other Services of the cluster lead to other Pods
+func getServiceMonitor(cluster *crv1.Pgcluster) *kubeapi.ServiceMonitor {
+	return &kubeapi.ServiceMonitor{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   cluster.Name,
+			Labels: getMonitorLabels(cluster),
+		},
+		Spec: kubeapi.ServiceMonitorSpec{
+			Selector: metav1.LabelSelector{
+				MatchLabels: map[string]string{config.LABEL_PG_CLUSTER: cluster.Name},
+				MatchExpressions: []metav1.LabelSelectorRequirement{{
+					Key:      config.LABEL_NAME,
+					Operator: metav1.LabelSelectorOpIn,
+					Values:   []string{cluster.Name, cluster.Name + ReplicaSuffix},
+				}},
+			},
+			Endpoints: []kubeapi.MonitorEndpoint{getMonitorEndpoint(cluster)},
+		},
+	}
+}
+
+// getPodMonitor returns the PodMonitor of a cluster, which selects the Pods of the cluster. Only
+// the instance Pods have the port of the exporter, so the other Pods are not scraped
+func getPodMonitor(cluster *crv1.Pgcluster) *kubeapi.PodMonitor {
+	return &kubeapi.PodMonitor{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   cluster.Name,
+			Labels: getMonitorLabels(cluster),
+		},
+		Spec: kubeapi.PodMonitorSpec{
+			Selector: metav1.LabelSelector{
+				MatchLabels: map[string]string{config.LABEL_PG_CLUSTER: cluster.Name},
+			},
+			PodMetricsEndpoints: []kubeapi.MonitorEndpoint{getMonitorEndpoint(cluster)},
+		},
+	}
+}
+
+// reconcileServiceMonitor creates the ServiceMonitor of a cluster, or updates it if it does not
+// match the spec of the cluster
+func reconcileServiceMonitor(clientset *kubernetes.Clientset, cluster *crv1.Pgcluster) error {
+	desired := getServiceMonitor(cluster)
+
+	current, found, err := kubeapi.GetServiceMonitor(clientset, desired.Name, cluster.Namespace)
+	if err != nil && !kerrors.IsNotFound(err) {
+		return err
+	}
+
+	if !found {
+		log.Debugf("creating servicemonitor for cluster %s", cluster.Name)
+		return kubeapi.CreateServiceMonitor(clientset, desired, cluster.Namespace)
+	}
+
+	if reflect.DeepEqual(current.Spec, desired.Spec) && reflect.DeepEqual(current.Labels, desired.Labels) {
+		return nil
+	}
+
+	log.Debugf("updating servicemonitor of cluster %s", cluster.Name)
+
+	current.Labels = desired.Labels
+	current.Spec = desired.Spec
+
+	return kubeapi.UpdateServiceMonitor(clientset, current, cluster.Namespace)
+}
+
+// reconcilePodMonitor creates the PodMonitor of a cluster, or updates it if it does not match
+// the spec of the cluster
+func reconcilePodMonitor(clientset *kubernetes.Clientset, cluster *crv1.Pgcluster) error {
+	desired := getPodMonitor(cluster)
+
+	current, found, err := kubeapi.GetPodMonitor(clientset, desired.Name, cluster.Namespace)
+	if err != nil && !kerrors.IsNotFound(err) {
+		return err
+	}
+
+	if !found {
+		log.Debugf("creating podmonitor for cluster %s", cluster.Name)
+		return kubeapi.CreatePodMonitor(clientset, desired, cluster.Namespace)
+	}
+
+	if reflect.DeepEqual(current.Spec, desired.Spec) && reflect.DeepEqual(current.Labels, desired.Labels) {
+		return nil
+	}
+
+	log.Debugf("updating podmonitor of cluster %s", cluster.Name)
+
+	current.Labels = desired.Labels
+	current.Spec = desired.Spec
+
+	return kubeapi.UpdatePodMonitor(clientset, current, cluster.Namespace)
+}
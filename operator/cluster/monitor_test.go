@@ -0,0 +1,88 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"reflect"
+	"testing"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/config"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGetMonitor(t *testing.T) {
+	cluster := &crv1.Pgcluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "hippo", Namespace: "pgo"},
+		Spec: crv1.PgclusterSpec{
+			Exporter: crv1.ExporterSpec{Enabled: true},
+			Monitor: crv1.MonitorSpec{
+				Kind:     crv1.MonitorKindServiceMonitor,
+				Labels:   map[string]string{"release": "prometheus", config.LABEL_PG_CLUSTER: "other"},
+				Interval: "30s",
+			},
+		},
+	}
+
+	t.Run("service monitor", func(t *testing.T) {
+		monitor := getServiceMonitor(cluster)
+
+		expected := map[string]string{
+			"release":               "prometheus",
+			config.LABEL_VENDOR:     config.LABEL_CRUNCHY,
+			config.LABEL_PG_CLUSTER: "hippo",
+		}
+		if !reflect.DeepEqual(monitor.Labels, expected) {
+			t.Fatalf("expected labels %v, got %v", expected, monitor.Labels)
+		}
+
+		// only the Services of the primary and the replicas lead to the exporter
+		selector := monitor.Spec.Selector
+		if len(selector.MatchExpressions) != 1 ||
+			!reflect.DeepEqual(selector.MatchExpressions[0].Values, []string{"hippo", "hippo-replica"}) {
+			t.Fatalf("expected the instance Services to be selected, got %v", selector)
+		}
+
+		endpoint := monitor.Spec.Endpoints[0]
+		if endpoint.Port != "postgres-exporter" || endpoint.Interval != "30s" || endpoint.Scheme != "" ||
+			endpoint.TLSConfig != nil {
+			t.Fatalf("expected the exporter to be scraped over HTTP, got %v", endpoint)
+		}
+
+		if relabeling := endpoint.Relabelings[0]; relabeling.TargetLabel != "pg_cluster" ||
+			relabeling.Replacement != "pgo:hippo" {
+			t.Fatalf("expected the metrics to be labeled with the cluster, got %v", relabeling)
+		}
+
+		if source := endpoint.Relabelings[1].SourceLabels; !reflect.DeepEqual(source,
+			[]string{"__meta_kubernetes_pod_label_deployment_name"}) {
+			t.Fatalf("expected the deployment to be taken from the pod label, got %v", source)
+		}
+	})
+
+	t.Run("pod monitor over TLS", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.Monitor.Kind = crv1.MonitorKindPodMonitor
+		cluster.Spec.Monitor.TLS = crv1.MonitorTLSSpec{CASecret: "hippo-metrics-ca", ServerName: "hippo"}
+
+		endpoint := getPodMonitor(cluster).Spec.PodMetricsEndpoints[0]
+
+		if endpoint.Scheme != "https" || endpoint.TLSConfig == nil || endpoint.TLSConfig.ServerName != "hippo" ||
+			endpoint.TLSConfig.CA.Secret.Name != "hippo-metrics-ca" || endpoint.TLSConfig.CA.Secret.Key != "ca.crt" {
+			t.Fatalf("expected the exporter to be scraped over HTTPS, got %v", endpoint)
+		}
+	})
+}
@@ -0,0 +1,227 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"reflect"
+	"strconv"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/config"
+	"github.com/crunchydata/postgres-operator/kubeapi"
+	"github.com/crunchydata/postgres-operator/operator"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// networkPolicyInstanceSuffix and networkPolicyRepoSuffix are appended to the name of a
+	// cluster to name the NetworkPolicies of its instances and of its pgBackRest repository
+	networkPolicyInstanceSuffix = "-pg"
+	networkPolicyRepoSuffix     = "-pgbackrest"
+
+	// networkPolicyDefaultPort is the port of PostgreSQL if the cluster does not set one
+	networkPolicyDefaultPort = 5432
+
+	// networkPolicyRestoreLabel is the label of the pods of the pgBackRest restore Jobs
+	networkPolicyRestoreLabel = "pgo-backrest-restore"
+	// networkPolicySQLRunnerLabel is the label of the pods of the Jobs that run SQL against a
+	// cluster, e.g. for its policies
+	networkPolicySQLRunnerLabel = "pgo-sqlrunner"
+)
+
+// networkPolicyClientLabels are the labels of the pods of the Operator that connect to PostgreSQL
+// on the instances of a cluster, i.e. pgBouncer and the Jobs of pg_dump, pg_restore, the policies
+// and the loads of the cluster
+var networkPolicyClientLabels = []string{
+	config.LABEL_PGBOUNCER,
+	config.LABEL_BACKUP_TYPE_PGDUMP,
+	config.LABEL_RESTORE_TYPE_PGRESTORE,
+	networkPolicySQLRunnerLabel,
+	config.LABEL_PGO_LOAD,
+}
+
+// ReconcileNetworkPolicies creates or updates the NetworkPolicies of a cluster while they are
+// enabled, and removes them once they are not
+func ReconcileNetworkPolicies(clientset *kubernetes.Clientset, cluster *crv1.Pgcluster) error {
+	if !cluster.Spec.NetworkPolicy.Enabled {
+		return DeleteNetworkPolicies(clientset, cluster)
+	}
+
+	for _, desired := range []*networkingv1.NetworkPolicy{
+		getInstanceNetworkPolicy(cluster),
+		getRepoNetworkPolicy(cluster),
+	} {
+		if err := reconcileNetworkPolicy(clientset, cluster, desired); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// DeleteNetworkPolicies removes the NetworkPolicies of a cluster, if it has any
+func DeleteNetworkPolicies(clientset *kubernetes.Clientset, cluster *crv1.Pgcluster) error {
+	for _, suffix := range []string{networkPolicyInstanceSuffix, networkPolicyRepoSuffix} {
+		if err := kubeapi.DeleteNetworkPolicy(clientset, cluster.Name+suffix,
+			cluster.Namespace); err != nil && !kerrors.IsNotFound(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// getNetworkPolicyPodSelector returns the selector of the pods of a cluster that have a label
+func getNetworkPolicyPodSelector(cluster *crv1.Pgcluster, label string) *metav1.LabelSelector {
+	return &metav1.LabelSelector{
+		MatchLabels: map[string]string{
+			config.LABEL_PG_CLUSTER: cluster.Name,
+			label:                   "true",
+		},
+	}
+}
+
+// getNetworkPolicyLabels returns the labels of the NetworkPolicies of a cluster
+func getNetworkPolicyLabels(cluster *crv1.Pgcluster) map[string]string {
+	return map[string]string{
+		config.LABEL_VENDOR:     config.LABEL_CRUNCHY,
+		config.LABEL_PG_CLUSTER: cluster.Name,
+	}
+}
+
+// getNetworkPolicyPostgresPort returns the port that PostgreSQL listens on in the instances of a
+// cluster
+func getNetworkPolicyPostgresPort(cluster *crv1.Pgcluster) intstr.IntOrString {
+	port, err := strconv.Atoi(cluster.Spec.Port)
+	if err != nil || port <= 0 {
+		port = networkPolicyDefaultPort
+	}
+
+	return intstr.FromInt(port)
+}
+
+// getNetworkPolicyBadgerPort returns the port of pgBadger in the instances of a cluster
+func getNetworkPolicyBadgerPort(cluster *crv1.Pgcluster) intstr.IntOrString {
+	for _, setting := range []string{cluster.Spec.PGBadgerPort, operator.Pgo.Cluster.PGBadgerPort} {
+		if port, err := strconv.Atoi(setting); err == nil && port > 0 {
+			return intstr.FromInt(port)
+		}
+	}
+
+	return intstr.Parse(config.DEFAULT_PGBADGER_PORT)
+}
+
+// getInstanceNetworkPolicy returns the NetworkPolicy of the instances of a cluster. The instances
+// accept any connection of one another, for replication and for Patroni, and of the pgBackRest
+// repository, which takes its backups over SSH. The components of the Operator that connect to
+// PostgreSQL, e.g. pgBouncer and the pg_dump Jobs, and the peers of the spec may only connect to
+// PostgreSQL, while the exporter and pgBadger may be reached from anywhere
+func getInstanceNetworkPolicy(cluster *crv1.Pgcluster) *networkingv1.NetworkPolicy {
+	tcp := v1.ProtocolTCP
+	postgresPort := getNetworkPolicyPostgresPort(cluster)
+	exporterPort := intstr.FromString(monitorPort)
+	badgerPort := getNetworkPolicyBadgerPort(cluster)
+
+	clients := []networkingv1.NetworkPolicyPeer{}
+	for _, label := range networkPolicyClientLabels {
+		clients = append(clients, networkingv1.NetworkPolicyPeer{
+			PodSelector: getNetworkPolicyPodSelector(cluster, label),
+		})
+	}
+	clients = append(clients, cluster.Spec.NetworkPolicy.AllowFrom...)
+
+	return &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   cluster.Name + networkPolicyInstanceSuffix,
+			Labels: getNetworkPolicyLabels(cluster),
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: *getNetworkPolicyPodSelector(cluster, config.LABEL_PG_DATABASE),
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+			Ingress: []networkingv1.NetworkPolicyIngressRule{
+				{
+					From: []networkingv1.NetworkPolicyPeer{
+						{PodSelector: getNetworkPolicyPodSelector(cluster, config.LABEL_PG_DATABASE)},
+						{PodSelector: getNetworkPolicyPodSelector(cluster, config.LABEL_PGO_BACKREST_REPO)},
+					},
+				},
+				{
+					Ports: []networkingv1.NetworkPolicyPort{{Protocol: &tcp, Port: &postgresPort}},
+					From:  clients,
+				},
+				{
+					Ports: []networkingv1.NetworkPolicyPort{
+						{Protocol: &tcp, Port: &exporterPort},
+						{Protocol: &tcp, Port: &badgerPort},
+					},
+				},
+			},
+		},
+	}
+}
+
+// getRepoNetworkPolicy returns the NetworkPolicy of the pgBackRest repository of a cluster, which
+// accepts the connections of the instances, that archive their WAL to it, and of the restore Jobs
+func getRepoNetworkPolicy(cluster *crv1.Pgcluster) *networkingv1.NetworkPolicy {
+	return &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   cluster.Name + networkPolicyRepoSuffix,
+			Labels: getNetworkPolicyLabels(cluster),
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: *getNetworkPolicyPodSelector(cluster, config.LABEL_PGO_BACKREST_REPO),
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+			Ingress: []networkingv1.NetworkPolicyIngressRule{{
+				From: []networkingv1.NetworkPolicyPeer{
+					{PodSelector: getNetworkPolicyPodSelector(cluster, config.LABEL_PG_DATABASE)},
+					{PodSelector: getNetworkPolicyPodSelector(cluster, networkPolicyRestoreLabel)},
+				},
+			}},
+		},
+	}
+}
+
+// reconcileNetworkPolicy creates a NetworkPolicy of a cluster, or updates it if it does not match
+// the spec of the cluster
+func reconcileNetworkPolicy(clientset *kubernetes.Clientset, cluster *crv1.Pgcluster,
+	desired *networkingv1.NetworkPolicy) error {
+	current, found, err := kubeapi.GetNetworkPolicy(clientset, desired.Name, cluster.Namespace)
+	if err != nil && !kerrors.IsNotFound(err) {
+		return err
+	}
+
+	if !found {
+		log.Debugf("creating networkpolicy %s for cluster %s", desired.Name, cluster.Name)
+		return kubeapi.CreateNetworkPolicy(clientset, desired, cluster.Namespace)
+	}
+
+	if reflect.DeepEqual(current.Spec, desired.Spec) && reflect.DeepEqual(current.Labels, desired.Labels) {
+		return nil
+	}
+
+	log.Debugf("updating networkpolicy %s of cluster %s", desired.Name, cluster.Name)
+
+	current.Labels = desired.Labels
+	current.Spec = desired.Spec
+
+	return kubeapi.UpdateNetworkPolicy(clientset, current, cluster.Namespace)
+}
@@ -0,0 +1,103 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"testing"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/config"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGetNetworkPolicies(t *testing.T) {
+	cluster := &crv1.Pgcluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "hippo", Namespace: "pgo"},
+		Spec: crv1.PgclusterSpec{
+			Port: "5433",
+			NetworkPolicy: crv1.NetworkPolicySpec{
+				Enabled: true,
+				AllowFrom: []networkingv1.NetworkPolicyPeer{{
+					PodSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+				}},
+			},
+		},
+	}
+
+	t.Run("instances", func(t *testing.T) {
+		policy := getInstanceNetworkPolicy(cluster)
+
+		if policy.Name != "hippo-pg" {
+			t.Fatalf("expected name hippo-pg, got %s", policy.Name)
+		}
+		if selector := policy.Spec.PodSelector.MatchLabels; selector[config.LABEL_PG_CLUSTER] != "hippo" ||
+			selector[config.LABEL_PG_DATABASE] != "true" {
+			t.Fatalf("expected the instances to be selected, got %v", selector)
+		}
+
+		if len(policy.Spec.Ingress) != 3 {
+			t.Fatalf("expected 3 ingress rules, got %d", len(policy.Spec.Ingress))
+		}
+
+		// the instances and the repository may connect to any port
+		if rule := policy.Spec.Ingress[0]; len(rule.Ports) != 0 || len(rule.From) != 2 {
+			t.Errorf("unexpected rule of the cluster %v", rule)
+		}
+
+		// the components of the Operator and the peers of the spec may only connect to PostgreSQL
+		rule := policy.Spec.Ingress[1]
+		if len(rule.Ports) != 1 || rule.Ports[0].Port.IntValue() != 5433 {
+			t.Errorf("expected the port of PostgreSQL, got %v", rule.Ports)
+		}
+		if len(rule.From) != 6 {
+			t.Fatalf("expected the components of the Operator and the peers of the spec, got %v", rule.From)
+		}
+		for i, label := range []string{config.LABEL_PGBOUNCER, config.LABEL_BACKUP_TYPE_PGDUMP,
+			config.LABEL_RESTORE_TYPE_PGRESTORE, "pgo-sqlrunner", config.LABEL_PGO_LOAD} {
+			if selector := rule.From[i].PodSelector.MatchLabels; selector[label] != "true" ||
+				selector[config.LABEL_PG_CLUSTER] != "hippo" {
+				t.Errorf("expected the %s pods of the cluster, got %v", label, selector)
+			}
+		}
+		if rule.From[5].PodSelector.MatchLabels["app"] != "web" {
+			t.Errorf("expected the peers of the spec, got %v", rule.From[5])
+		}
+
+		// the exporter and pgBadger may be reached from anywhere
+		if rule := policy.Spec.Ingress[2]; len(rule.From) != 0 || len(rule.Ports) != 2 ||
+			rule.Ports[0].Port.String() != monitorPort || rule.Ports[1].Port.IntValue() != 10000 {
+			t.Errorf("unexpected rule of the exporter and pgBadger %v", rule)
+		}
+	})
+
+	t.Run("repository", func(t *testing.T) {
+		policy := getRepoNetworkPolicy(cluster)
+
+		if policy.Spec.PodSelector.MatchLabels[config.LABEL_PGO_BACKREST_REPO] != "true" {
+			t.Fatalf("expected the repository to be selected, got %v", policy.Spec.PodSelector)
+		}
+		if len(policy.Spec.Ingress) != 1 || len(policy.Spec.Ingress[0].From) != 2 {
+			t.Fatalf("unexpected ingress %v", policy.Spec.Ingress)
+		}
+	})
+
+	t.Run("default port", func(t *testing.T) {
+		if port := getNetworkPolicyPostgresPort(&crv1.Pgcluster{}); port.IntValue() != 5432 {
+			t.Fatalf("expected 5432, got %s", port.String())
+		}
+	})
+}
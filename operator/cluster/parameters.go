@@ -0,0 +1,328 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/config"
+	"github.com/crunchydata/postgres-operator/kubeapi"
+	"github.com/crunchydata/postgres-operator/util"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	// sqlRestartRequiredParameters returns the names of the given settings that can only be
+	// changed by restarting PostgreSQL. Settings that PostgreSQL does not know about yet, e.g.
+	// those of a library that is not loaded, are treated as reloadable
+	sqlRestartRequiredParameters = `SELECT name FROM pg_settings WHERE context = 'postmaster' AND name IN (%s) ORDER BY name;`
+
+	// parametersPendingRestartTick is the duration of the tick used when waiting for Patroni to
+	// flag that PostgreSQL needs to be restarted
+	parametersPendingRestartTick = time.Second * 5
+	// parametersPendingRestartTimeout is the amount of time to wait for Patroni to flag that
+	// PostgreSQL needs to be restarted
+	parametersPendingRestartTimeout = time.Minute * 2
+	// parametersRestartTimeout is the amount of time to wait for PostgreSQL to be back up after
+	// it has been restarted
+	parametersRestartTimeout = time.Minute * 5
+)
+
+// UpdatePostgreSQLParameters sets the PostgreSQL parameters that are managed by Patroni for the
+// cluster, taking the least disruptive action that applies them. Patroni reloads PostgreSQL when
+// the parameters change, so if all of the changed parameters can be reloaded, nothing else is
+// done. Otherwise the cluster is restarted once Patroni has flagged that a restart is pending.
+// Whether or not the cluster was restarted is returned
+func UpdatePostgreSQLParameters(clientset *kubernetes.Clientset, restconfig *rest.Config,
+	cluster *crv1.Pgcluster, parameters map[string]string) (bool, error) {
+	pending, err := updatePostgreSQLParameters(clientset, restconfig, cluster, parameters, nil, true)
+
+	return len(pending) > 0, err
+}
+
+// updatePostgreSQLParameters sets the given PostgreSQL parameters that are managed by Patroni
+// for the cluster and removes the removed ones, which resets them to their defaults. The names
+// of the changed parameters that can only be applied with a restart are returned. If restart is
+// set, the cluster is restarted to apply them. Otherwise the restart is left to the caller, as
+// is growing the pools of pgBouncer, which has to wait for the restart
+func updatePostgreSQLParameters(clientset *kubernetes.Clientset, restconfig *rest.Config,
+	cluster *crv1.Pgcluster, parameters map[string]string, removed []string, restart bool) ([]string, error) {
+	dcsConfigMap, configJSON, current, err := getDCSParameters(clientset, cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	// keep the parameters as they are, as pgBouncer is sized from them
+	previous := make(map[string]string, len(current))
+	for name, value := range current {
+		previous[name] = fmt.Sprint(value)
+	}
+
+	// determine which of the parameters actually change
+	changed := []string{}
+	for name, value := range parameters {
+		if v, ok := current[name]; !ok || fmt.Sprint(v) != value {
+			changed = append(changed, name)
+			current[name] = value
+		}
+	}
+
+	for _, name := range removed {
+		if _, ok := current[name]; ok {
+			changed = append(changed, name)
+			delete(current, name)
+		}
+	}
+
+	if len(changed) == 0 {
+		return nil, nil
+	}
+
+	sort.Strings(changed)
+
+	pod, err := util.GetPrimaryPod(clientset, cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	pending, err := getRestartRequiredParameters(clientset, restconfig, pod, changed)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Debugf("updating parameters %v of cluster %s, restart required for: %v", changed,
+		cluster.Name, pending)
+
+	// pgBouncer must not open more connections than PostgreSQL allows for, so its pools are
+	// shrunk before PostgreSQL allows for fewer connections, and only grown once it allows for
+	// more of them
+	updated := make(map[string]string, len(current))
+	for name, value := range current {
+		updated[name] = fmt.Sprint(value)
+	}
+
+	pgBouncerBudget := 0
+	if cluster.Labels[config.LABEL_PGBOUNCER] == "true" {
+		replicas := cluster.Spec.PgBouncer.GetReplicas()
+		databases := getPgBouncerDatabaseCount(cluster)
+		pgBouncerBudget = getPgBouncerMaxDBConnections(updated, replicas, databases) -
+			getPgBouncerMaxDBConnections(previous, replicas, databases)
+	}
+
+	if pgBouncerBudget < 0 {
+		if err := updatePgBouncerPoolSizes(clientset, restconfig, cluster, updated); err != nil {
+			return nil, err
+		}
+	}
+
+	configJSONStr, err := json.Marshal(configJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	dcsConfigMap.ObjectMeta.Annotations["config"] = string(configJSONStr)
+
+	if err := kubeapi.UpdateConfigMap(clientset, dcsConfigMap, cluster.Namespace); err != nil {
+		return nil, err
+	}
+
+	if len(pending) > 0 {
+		if !restart {
+			return pending, nil
+		}
+
+		if err := restartPendingCluster(clientset, restconfig, cluster, pod); err != nil {
+			return pending, err
+		}
+	}
+
+	if pgBouncerBudget > 0 {
+		if err := updatePgBouncerPoolSizes(clientset, restconfig, cluster, updated); err != nil {
+			return pending, err
+		}
+	}
+
+	return pending, nil
+}
+
+// GetPostgreSQLParameters returns the values of the PostgreSQL parameters that are managed by
+// Patroni for the cluster
+func GetPostgreSQLParameters(clientset *kubernetes.Clientset, cluster *crv1.Pgcluster) (map[string]string, error) {
+	_, _, current, err := getDCSParameters(clientset, cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	parameters := make(map[string]string, len(current))
+	for name, value := range current {
+		parameters[name] = fmt.Sprint(value)
+	}
+
+	return parameters, nil
+}
+
+// getDCSParameters returns the DCS configMap of the cluster, the Patroni configuration stored in
+// it, and the PostgreSQL parameters within that configuration, which are added to the
+// configuration if it does not contain any yet
+func getDCSParameters(clientset *kubernetes.Clientset, cluster *crv1.Pgcluster) (*v1.ConfigMap,
+	map[string]interface{}, map[string]interface{}, error) {
+	dcsConfigMapName := cluster.Labels[config.LABEL_PGHA_SCOPE] + "-config"
+
+	dcsConfigMap, found := kubeapi.GetConfigMap(clientset, dcsConfigMapName, cluster.Namespace)
+	if !found {
+		return nil, nil, nil, fmt.Errorf("unable to find configMap %s when updating parameters",
+			dcsConfigMapName)
+	}
+
+	if _, ok := dcsConfigMap.ObjectMeta.Annotations["config"]; !ok {
+		return nil, nil, nil, util.ErrMissingConfigAnnotation
+	}
+
+	configJSON := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(dcsConfigMap.ObjectMeta.Annotations["config"]), &configJSON); err != nil {
+		return nil, nil, nil, err
+	}
+
+	postgresql, _ := configJSON["postgresql"].(map[string]interface{})
+	if postgresql == nil {
+		postgresql = map[string]interface{}{}
+		configJSON["postgresql"] = postgresql
+	}
+
+	current, _ := postgresql["parameters"].(map[string]interface{})
+	if current == nil {
+		current = map[string]interface{}{}
+		postgresql["parameters"] = current
+	}
+
+	return dcsConfigMap, configJSON, current, nil
+}
+
+// IsRestartRequired determines whether or not changing any of the given PostgreSQL parameters
+// requires a restart, i.e. if any of them can only be set at server start
+func IsRestartRequired(clientset *kubernetes.Clientset, restconfig *rest.Config, pod *v1.Pod,
+	names []string) (bool, error) {
+	pending, err := getRestartRequiredParameters(clientset, restconfig, pod, names)
+
+	return len(pending) > 0, err
+}
+
+// getRestartRequiredParameters returns those of the given PostgreSQL parameters that can only
+// be set at server start, in order of their names
+func getRestartRequiredParameters(clientset *kubernetes.Clientset, restconfig *rest.Config,
+	pod *v1.Pod, names []string) ([]string, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = "'" + strings.ReplaceAll(name, "'", "''") + "'"
+	}
+
+	result, err := execMaintenanceSQL(clientset, restconfig, pod, "postgres",
+		fmt.Sprintf(sqlRestartRequiredParameters, strings.Join(quoted, ",")))
+	if err != nil {
+		return nil, err
+	}
+
+	if result == "" {
+		return nil, nil
+	}
+
+	return strings.Split(result, "\n"), nil
+}
+
+// restartPendingCluster waits for Patroni to flag that the primary of the cluster needs to be
+// restarted to apply its parameters, restarts the members of the cluster that are pending a
+// restart, and then waits for the primary to be running again
+func restartPendingCluster(clientset *kubernetes.Clientset, restconfig *rest.Config,
+	cluster *crv1.Pgcluster, pod *v1.Pod) error {
+	if err := waitForPatroniState(clientset, restconfig, pod, parametersPendingRestartTimeout,
+		func(running, pendingRestart bool) bool { return pendingRestart }); err != nil {
+		return err
+	}
+
+	// the restart counts against the restart budget of the Operator
+	release := acquireRestartBudget(cluster)
+	defer release()
+
+	log.Infof("restarting cluster %s to apply parameters", cluster.Name)
+
+	cmd := []string{"patronictl", "restart", "--force", "--pending",
+		cluster.Labels[config.LABEL_PGHA_SCOPE]}
+
+	if _, stderr, err := kubeapi.ExecToPodThroughAPI(restconfig, clientset, cmd, "database",
+		pod.Name, pod.Namespace, nil); err != nil {
+		log.Error(stderr)
+		return err
+	}
+
+	return waitForPatroniState(clientset, restconfig, pod, parametersRestartTimeout,
+		func(running, pendingRestart bool) bool { return running && !pendingRestart })
+}
+
+// waitForPatroniState polls the Patroni API of the primary until the state it reports satisfies
+// the given condition, or the timeout is reached
+func waitForPatroniState(clientset *kubernetes.Clientset, restconfig *rest.Config, pod *v1.Pod,
+	timeout time.Duration, condition func(running, pendingRestart bool) bool) error {
+	duration := time.After(timeout)
+	tick := time.Tick(parametersPendingRestartTick)
+
+	for {
+		select {
+		case <-duration:
+			return errors.New("timed out waiting on patroni after updating parameters")
+		case <-tick:
+			running, pendingRestart, err := getPatroniState(clientset, restconfig, pod)
+			if err != nil {
+				continue
+			}
+
+			if condition(running, pendingRestart) {
+				return nil
+			}
+		}
+	}
+}
+
+// getPatroniState returns whether PostgreSQL is running on the primary, and whether Patroni has
+// flagged that it needs to be restarted to apply its parameters
+func getPatroniState(clientset *kubernetes.Clientset, restconfig *rest.Config,
+	pod *v1.Pod) (bool, bool, error) {
+	cmd := []string{"curl", fmt.Sprintf("localhost:%s/master", config.DEFAULT_PATRONI_PORT)}
+
+	stdout, _, _ := kubeapi.ExecToPodThroughAPI(restconfig, clientset, cmd, "database",
+		pod.Name, pod.Namespace, nil)
+
+	status := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(stdout), &status); err != nil {
+		return false, false, err
+	}
+
+	pendingRestart, _ := status["pending_restart"].(bool)
+
+	return status["state"] == "running", pendingRestart, nil
+}
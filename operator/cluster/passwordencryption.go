@@ -0,0 +1,251 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"fmt"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/config"
+	"github.com/crunchydata/postgres-operator/kubeapi"
+	"github.com/crunchydata/postgres-operator/operator"
+	"github.com/crunchydata/postgres-operator/util"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	// eventReasonPasswordEncryptionChanged is the reason of the events that are recorded when
+	// the users of a cluster are moved to another password encryption
+	eventReasonPasswordEncryptionChanged = "PasswordEncryptionChanged"
+
+	// eventReasonPasswordEncryptionFailed is the reason of the Warning events that are recorded
+	// when the users of a cluster could not be moved to another password encryption
+	eventReasonPasswordEncryptionFailed = "PasswordEncryptionFailed"
+)
+
+// ValidatePasswordEncryption returns an error if the password encryption of a cluster is not
+// supported, or not supported by its version of PostgreSQL. SCRAM-SHA-256 is only available as
+// of PostgreSQL 10
+func ValidatePasswordEncryption(cluster *crv1.Pgcluster) error {
+	switch encryption := cluster.Spec.GetPasswordEncryption(); encryption {
+	case crv1.PasswordEncryptionMD5:
+		return nil
+	case crv1.PasswordEncryptionSCRAMSHA256:
+		if version := getPGMajorVersion(cluster.Spec.CCPImageTag); version == "9.5" || version == "9.6" {
+			return fmt.Errorf("password encryption %q requires PostgreSQL 10 or later", encryption)
+		}
+		return nil
+	default:
+		return fmt.Errorf("invalid password encryption %q, must be %q or %q", encryption,
+			crv1.PasswordEncryptionMD5, crv1.PasswordEncryptionSCRAMSHA256)
+	}
+}
+
+// ReconcilePasswordEncryption moves the users of a cluster to the password encryption of its
+// spec once it differs from the one they were last moved to. PostgreSQL is set to encrypt new
+// passwords with it, the passwords of the users the Operator knows the passwords of are hashed
+// again with it and pgBouncer is given the matching authentication method. The password rules
+// of pg_hba.conf stay at md5, which also authenticates users with SCRAM-SHA-256 passwords, so
+// that the users the Operator does not know the passwords of can still log in. They need to be
+// given new passwords to be stored as SCRAM-SHA-256 verifiers
+func ReconcilePasswordEncryption(clientset *kubernetes.Clientset, restclient *rest.RESTClient,
+	restconfig *rest.Config, store operator.CredentialStore, cluster *crv1.Pgcluster) error {
+	// the users of a standby cluster are those of the cluster it replays the WAL of
+	if cluster.Status.State != crv1.PgclusterStateInitialized || cluster.Spec.Standby {
+		return nil
+	}
+
+	encryption := cluster.Spec.GetPasswordEncryption()
+
+	// the users of clusters that predate the setting have always been MD5 hashed
+	current := cluster.Status.PasswordEncryption
+	if current == "" {
+		current = crv1.PasswordEncryptionMD5
+	}
+
+	if current == encryption {
+		return nil
+	}
+
+	log.Infof("moving the users of cluster %s from %s to %s password encryption", cluster.Name,
+		current, encryption)
+
+	if err := updatePasswordEncryption(clientset, restclient, restconfig, store, cluster); err != nil {
+		operator.RecordWarningEvent(clientset, cluster, eventReasonPasswordEncryptionFailed,
+			fmt.Sprintf("the users could not be moved to %s password encryption: %s", encryption, err))
+		return err
+	}
+
+	operator.RecordNormalEvent(clientset, cluster, eventReasonPasswordEncryptionChanged,
+		fmt.Sprintf("the users were moved to %s password encryption", encryption))
+
+	return kubeapi.Patchpgcluster(restclient, cluster, cluster.Namespace, func(cluster *crv1.Pgcluster) {
+		cluster.Status.PasswordEncryption = encryption
+	})
+}
+
+// updatePasswordEncryption carries out the steps of moving the users of a cluster to the
+// password encryption of its spec. Each of the steps can be repeated, so a move that fails
+// midway is picked up again as a whole
+func updatePasswordEncryption(clientset *kubernetes.Clientset, restclient *rest.RESTClient,
+	restconfig *rest.Config, store operator.CredentialStore, cluster *crv1.Pgcluster) error {
+	encryption := cluster.Spec.GetPasswordEncryption()
+
+	if _, err := UpdatePostgreSQLParameters(clientset, restconfig, cluster, map[string]string{
+		"password_encryption": string(encryption),
+	}); err != nil {
+		return err
+	}
+
+	pod, err := util.GetPrimaryPod(clientset, cluster)
+	if err != nil {
+		return err
+	}
+
+	// the replication user is hashed again along with the others, as the replicas authenticate
+	// with the same method as every other client
+	for _, secretName := range []string{cluster.Spec.RootSecretName, cluster.Spec.UserSecretName,
+		cluster.Spec.PrimarySecretName} {
+		if secretName == "" {
+			continue
+		}
+
+		if err := rehashPassword(clientset, restconfig, store, pod, cluster.Namespace, secretName,
+			encryption); err != nil {
+			return err
+		}
+	}
+
+	if err := rehashPguserPasswords(clientset, restclient, restconfig, store, cluster); err != nil {
+		return err
+	}
+
+	if cluster.Labels[config.LABEL_PGBOUNCER] == "true" {
+		return updatePgBouncerPasswordEncryption(clientset, restconfig, cluster, pod)
+	}
+
+	return nil
+}
+
+// rehashPassword sets the password of the user of a credential again, hashed with the given
+// password encryption
+func rehashPassword(clientset *kubernetes.Clientset, restconfig *rest.Config,
+	store operator.CredentialStore, pod *v1.Pod, namespace, secretName string,
+	encryption crv1.PasswordEncryptionType) error {
+	credential, err := store.Get(namespace, secretName)
+	if err != nil {
+		return err
+	}
+
+	hashedPassword, err := util.GeneratePostgreSQLPassword(encryption, credential.Username,
+		credential.Password)
+	if err != nil {
+		return err
+	}
+
+	return util.SetPostgreSQLPassword(clientset, restconfig, pod, credential.Username,
+		hashedPassword, "")
+}
+
+// rehashPguserPasswords reconciles the pgusers of a cluster, which sets their passwords again
+// with the password encryption of the cluster
+func rehashPguserPasswords(clientset *kubernetes.Clientset, restclient *rest.RESTClient,
+	restconfig *rest.Config, store operator.CredentialStore, cluster *crv1.Pgcluster) error {
+	users := crv1.PguserList{}
+	if err := kubeapi.GetpgusersBySelector(restclient, &users, "", cluster.Namespace); err != nil {
+		return err
+	}
+
+	for i := range users.Items {
+		user := &users.Items[i]
+
+		if user.Spec.ClusterName != cluster.Name || user.DeletionTimestamp != nil ||
+			ValidatePguser(user) != nil {
+			continue
+		}
+
+		if err := ReconcilePguser(clientset, restclient, restconfig, store, user); err != nil {
+			return fmt.Errorf("could not set the password of pguser %s: %s", user.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// updatePgBouncerPasswordEncryption sets the password of the "pgbouncer" user again with the
+// password encryption of the cluster, and has pgBouncer authenticate its clients with it. The
+// pgBouncer Pods reload their configuration once it has propagated to them
+func updatePgBouncerPasswordEncryption(clientset *kubernetes.Clientset, restconfig *rest.Config,
+	cluster *crv1.Pgcluster, pod *v1.Pod) error {
+	secretName := util.GeneratePgBouncerSecretName(cluster.Name)
+
+	secret, _, err := kubeapi.GetSecret(clientset, secretName, cluster.Namespace)
+	if err != nil {
+		return err
+	}
+
+	password := string(secret.Data["password"])
+
+	if err := setPostgreSQLPassword(clientset, restconfig, pod, password,
+		cluster.Spec.GetPasswordEncryption()); err != nil {
+		return err
+	}
+
+	parameters, err := GetPostgreSQLParameters(clientset, cluster)
+	if err != nil {
+		return err
+	}
+
+	hba, err := generatePgBouncerHBA(cluster)
+	if err != nil {
+		return err
+	}
+
+	users, err := generatePgBouncerUsers(cluster, password)
+	if err != nil {
+		return err
+	}
+
+	pools := getPgBouncerPools(cluster)
+
+	for _, pool := range pools {
+		conf, err := getConnectionPooler(cluster).generateConf(cluster, parameters, password, pool.readOnly)
+		if err != nil {
+			return err
+		}
+
+		secret.Data[pool.confKey] = conf
+	}
+
+	secret.Data["pg_hba.conf"] = hba
+	secret.Data["users.txt"] = users
+
+	if err := kubeapi.UpdateSecret(clientset, secret, cluster.Namespace); err != nil {
+		return err
+	}
+
+	for _, pool := range pools {
+		if err := reloadPgBouncerConf(clientset, restconfig, cluster, pool,
+			secret.Data[pool.confKey]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,44 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"testing"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+)
+
+func TestGeneratePgBouncerUsers(t *testing.T) {
+	cluster := &crv1.Pgcluster{}
+
+	users, err := generatePgBouncerUsers(cluster, "datalake")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := `"pgbouncer" "md56294153764d389dc6830b6ce4f923cdb"`; string(users) != expected {
+		t.Errorf("expected %q, got %q", expected, users)
+	}
+
+	cluster.Spec.PasswordEncryption = crv1.PasswordEncryptionSCRAMSHA256
+
+	users, err = generatePgBouncerUsers(cluster, "datalake")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := `"pgbouncer" "datalake"`; string(users) != expected {
+		t.Errorf("expected %q, got %q", expected, users)
+	}
+}
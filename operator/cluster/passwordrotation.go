@@ -0,0 +1,202 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/config"
+	"github.com/crunchydata/postgres-operator/kubeapi"
+	"github.com/crunchydata/postgres-operator/operator"
+	"github.com/crunchydata/postgres-operator/util"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	// passwordRotationRetryInterval is how long a rotation of passwords that failed is waited on
+	// before it is tried again
+	passwordRotationRetryInterval = 5 * time.Minute
+
+	// eventReasonPasswordsRotated is the reason of the events that are recorded when passwords
+	// are rotated
+	eventReasonPasswordsRotated = "PasswordsRotated"
+
+	// eventReasonPasswordRotationFailed is the reason of the Warning events that are recorded
+	// when passwords could not be rotated
+	eventReasonPasswordRotationFailed = "PasswordRotationFailed"
+)
+
+// ReconcilePasswordRotation rotates the passwords of the PostgreSQL users of a cluster once its
+// rotation interval has passed since they were last rotated, or once the "rotate-password"
+// annotation of the cluster is set to a new value. Each user is given a new generated password
+// in PostgreSQL and then in its Secret, and the outcome is recorded in the status of the
+// cluster along with an event
+func ReconcilePasswordRotation(clientset *kubernetes.Clientset, restclient *rest.RESTClient,
+	restconfig *rest.Config, store operator.CredentialStore, cluster *crv1.Pgcluster) error {
+	// the users of a standby cluster are those of the cluster it replays the WAL of
+	if cluster.Status.State != crv1.PgclusterStateInitialized || cluster.Spec.Standby {
+		return nil
+	}
+
+	now := time.Now()
+	status := cluster.Status.PasswordRotation
+	token := cluster.Annotations[config.ANNOTATION_ROTATE_PASSWORD]
+
+	if !isClusterPasswordRotationDue(cluster, now) {
+		return nil
+	}
+
+	status.LastAttempt = now.UTC().Format(time.RFC3339)
+
+	if err := rotateClusterPasswords(clientset, restclient, restconfig, store, cluster); err != nil {
+		log.Errorf("could not rotate passwords of cluster %s: %s", cluster.Name, err)
+
+		status.Message = err.Error()
+
+		operator.RecordWarningEvent(clientset, cluster, eventReasonPasswordRotationFailed, err.Error())
+	} else {
+		status.LastRotation = status.LastAttempt
+		status.RotateToken = token
+		status.Message = ""
+
+		operator.RecordNormalEvent(clientset, cluster, eventReasonPasswordsRotated,
+			"the passwords of the PostgreSQL users were rotated")
+	}
+
+	return kubeapi.Patchpgcluster(restclient, cluster, cluster.Namespace, func(cluster *crv1.Pgcluster) {
+		cluster.Status.PasswordRotation = status
+	})
+}
+
+// isClusterPasswordRotationDue determines whether the passwords of a cluster are to be rotated,
+// i.e. as the "rotate-password" annotation has a value they were not rotated for, or as the
+// rotation interval has passed. A rotation that failed is tried again after a while rather than
+// on every period
+func isClusterPasswordRotationDue(cluster *crv1.Pgcluster, now time.Time) bool {
+	status := cluster.Status.PasswordRotation
+
+	if status.Message != "" {
+		if last, err := time.Parse(time.RFC3339, status.LastAttempt); err == nil &&
+			now.Before(last.Add(passwordRotationRetryInterval)) {
+			return false
+		}
+	}
+
+	if token := cluster.Annotations[config.ANNOTATION_ROTATE_PASSWORD]; token != "" &&
+		token != status.RotateToken {
+		return true
+	}
+
+	return isPasswordRotationDue(cluster.Spec.PasswordRotation.IntervalDays, status.LastRotation,
+		cluster.CreationTimestamp, now)
+}
+
+// isPasswordRotationDue determines whether the interval has passed since a password was last
+// rotated. Passwords that were never rotated are as old as what they belong to
+func isPasswordRotationDue(intervalDays int, lastRotation string, created meta_v1.Time,
+	now time.Time) bool {
+	if intervalDays <= 0 {
+		return false
+	}
+
+	last, err := time.Parse(time.RFC3339, lastRotation)
+	if err != nil {
+		last = created.Time
+	}
+
+	return !now.Before(last.Add(time.Duration(intervalDays) * 24 * time.Hour))
+}
+
+// rotateClusterPasswords gives the postgres superuser and the user of a cluster new passwords,
+// and optionally the pgBouncer user as well. The password is changed in PostgreSQL before it is
+// changed in the Secret, so that the Secret never holds a password PostgreSQL does not accept
+// yet; a rotation that fails in between is repeated with yet another password
+func rotateClusterPasswords(clientset *kubernetes.Clientset, restclient *rest.RESTClient,
+	restconfig *rest.Config, store operator.CredentialStore, cluster *crv1.Pgcluster) error {
+	pod, err := util.GetPrimaryPod(clientset, cluster)
+	if err != nil {
+		return err
+	}
+
+	rotated := []string{}
+
+	for _, secretName := range []string{cluster.Spec.RootSecretName, cluster.Spec.UserSecretName} {
+		if secretName == "" {
+			continue
+		}
+
+		username, err := rotatePassword(clientset, restconfig, store, pod, cluster.Namespace, secretName,
+			cluster.Spec.GetPasswordEncryption())
+		if err != nil {
+			return err
+		}
+
+		rotated = append(rotated, username)
+	}
+
+	if cluster.Spec.PasswordRotation.PgBouncer && cluster.Labels[config.LABEL_PGBOUNCER] == "true" {
+		if err := rotatePgBouncerPassword(clientset, restclient, restconfig, cluster); err != nil {
+			return err
+		}
+
+		rotated = append(rotated, crv1.PGUserPgBouncer)
+	}
+
+	log.Infof("rotated passwords of users %s of cluster %s", strings.Join(rotated, ", "), cluster.Name)
+
+	return nil
+}
+
+// rotatePassword gives the user of a credential a new generated password, first in PostgreSQL
+// and then in the credential store, returning the name of the user
+func rotatePassword(clientset *kubernetes.Clientset, restconfig *rest.Config,
+	store operator.CredentialStore, pod *v1.Pod, namespace, secretName string,
+	encryption crv1.PasswordEncryptionType) (string, error) {
+	credential, err := store.Get(namespace, secretName)
+	if err != nil {
+		return "", err
+	}
+
+	if credential.Username == "" {
+		return "", fmt.Errorf("the credential in %s has no username", secretName)
+	}
+
+	password := util.GeneratePassword(util.GeneratedPasswordLength(operator.Pgo.Cluster.PasswordLength))
+
+	// the password is hashed so that it is not sent to PostgreSQL in plain text
+	hashedPassword, err := util.GeneratePostgreSQLPassword(encryption, credential.Username, password)
+	if err != nil {
+		return "", err
+	}
+
+	if err := util.SetPostgreSQLPassword(clientset, restconfig, pod, credential.Username,
+		hashedPassword, ""); err != nil {
+		return "", err
+	}
+
+	if _, err := store.Rotate(namespace, secretName, password); err != nil {
+		return "", err
+	}
+
+	return credential.Username, nil
+}
@@ -0,0 +1,85 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"testing"
+	"time"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/config"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestIsPasswordRotationDue(t *testing.T) {
+	now := time.Date(2020, 6, 30, 12, 0, 0, 0, time.UTC)
+	created := metav1.NewTime(now.Add(-45 * 24 * time.Hour))
+
+	for _, test := range []struct {
+		name         string
+		intervalDays int
+		lastRotation string
+		expected     bool
+	}{
+		{"no interval", 0, "", false},
+		{"never rotated", 30, "", true},
+		{"never rotated within interval", 60, "", false},
+		{"rotated within interval", 30, "2020-06-15T12:00:00Z", false},
+		{"rotated before interval", 30, "2020-05-31T12:00:00Z", true},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			if due := isPasswordRotationDue(test.intervalDays, test.lastRotation, created, now); due != test.expected {
+				t.Errorf("expected due: %v, got %v", test.expected, due)
+			}
+		})
+	}
+}
+
+func TestIsClusterPasswordRotationDue(t *testing.T) {
+	now := time.Date(2020, 6, 30, 12, 0, 0, 0, time.UTC)
+
+	cluster := func(token string, status crv1.PasswordRotationStatus) *crv1.Pgcluster {
+		return &crv1.Pgcluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Annotations:       map[string]string{config.ANNOTATION_ROTATE_PASSWORD: token},
+				CreationTimestamp: metav1.NewTime(now.Add(-time.Hour)),
+			},
+			Status: crv1.PgclusterStatus{PasswordRotation: status},
+		}
+	}
+
+	for _, test := range []struct {
+		name     string
+		cluster  *crv1.Pgcluster
+		expected bool
+	}{
+		{"no rotation", cluster("", crv1.PasswordRotationStatus{}), false},
+		{"new token", cluster("1", crv1.PasswordRotationStatus{}), true},
+		{"rotated token", cluster("1", crv1.PasswordRotationStatus{RotateToken: "1"}), false},
+		{"failed recently", cluster("2", crv1.PasswordRotationStatus{
+			RotateToken: "1", LastAttempt: "2020-06-30T11:58:00Z", Message: "timeout",
+		}), false},
+		{"failed a while ago", cluster("2", crv1.PasswordRotationStatus{
+			RotateToken: "1", LastAttempt: "2020-06-30T11:50:00Z", Message: "timeout",
+		}), true},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			if due := isClusterPasswordRotationDue(test.cluster, now); due != test.expected {
+				t.Errorf("expected due: %v, got %v", test.expected, due)
+			}
+		})
+	}
+}
@@ -47,6 +47,24 @@ type PgbouncerPasswdFields struct {
 type PgbouncerConfFields struct {
 	PG_PRIMARY_SERVICE_NAME string
 	PG_PORT                 string
+	DefaultPoolSize         int
+	MaxDBConnections        int
+	MaxClientConn           int
+	PoolMode                string
+	AuthType                string
+	// Settings are the remaining settings of the "[pgbouncer]" section, i.e.
+	// the defaults along with the settings of the spec, as "key = value"
+	Settings []string
+	// TLSEnabled has pgBouncer accept TLS connections with the keypair that
+	// the Operator manages for it, which are the only ones it accepts if TLSOnly
+	TLSEnabled bool
+	TLSOnly    bool
+}
+
+// PgbouncerHBAFields are the fields of the pgBouncer host-based authentication
+// file
+type PgbouncerHBAFields struct {
+	AuthType string
 }
 
 type PgbouncerTemplateFields struct {
@@ -58,9 +76,20 @@ type PgbouncerTemplateFields struct {
 	Port                      string
 	PrimaryServiceName        string
 	ContainerResources        string
+	NodeSelectorLabels        string
+	Tolerations               string
 	PodAntiAffinity           string
 	PodAntiAffinityLabelName  string
 	PodAntiAffinityLabelValue string
+	Replicas                  int
+	// TLSSecret is the name of the Secret with the keypair of pgBouncer, if
+	// the Operator manages one
+	TLSSecret string
+	// ReadOnly has the Pods load the configuration of the pgBouncer that
+	// pools connections to the replicas
+	ReadOnly bool
+	// Image is the image of the connection pooler
+	Image string
 }
 
 // pgBouncerDeploymentFormat is the name of the Kubernetes Deployment that
@@ -102,7 +131,7 @@ const (
 
 	// sqlEnableLogin is the SQL to update the password
 	// NOTE: this is safe from SQL injection as we explicitly add the inerpolated
-	// string as a MD5 hash and we are using the crv1.PGUserPgBouncer constant
+	// string as a MD5 hash or SCRAM verifier and we are using the crv1.PGUserPgBouncer constant
 	// However, the escaping is handled in the util.SetPostgreSQLPassword function
 	sqlEnableLogin = `ALTER ROLE %s PASSWORD %s LOGIN;`
 
@@ -161,7 +190,8 @@ func AddPgbouncer(clientset *kubernetes.Clientset, restclient *rest.RESTClient,
 	if !cluster.Spec.Standby {
 		// attempt to update the password in PostgreSQL, as this is how pgBouncer
 		// will properly interface with PostgreSQL
-		if err := setPostgreSQLPassword(clientset, restconfig, pod, pgBouncerPassword); err != nil {
+		if err := setPostgreSQLPassword(clientset, restconfig, pod, pgBouncerPassword,
+			cluster.Spec.GetPasswordEncryption()); err != nil {
 			return err
 		}
 	}
@@ -171,14 +201,29 @@ func AddPgbouncer(clientset *kubernetes.Clientset, restclient *rest.RESTClient,
 		return err
 	}
 
-	// next, create the pgBouncer deployment
-	if err := createPgBouncerDeployment(clientset, cluster); err != nil {
+	// the certificate of pgBouncer is issued before the deployment that mounts
+	// it
+	if err := ReconcileTLSCertificates(clientset, restclient, cluster); err != nil {
 		return err
 	}
 
-	// finally, try to create the pgBouncer service
-	if err := createPgBouncerService(clientset, cluster); err != nil {
-		return err
+	// in the mTLS-only mode, pgBouncer is let in with its password
+	if cluster.Spec.TLS.ClientAuth.MTLSOnly {
+		if err := updateTLSClientAuthHBA(clientset, cluster); err != nil {
+			return err
+		}
+	}
+
+	// next, create the pgBouncer deployment and service, along with those of
+	// the read-only pgBouncer if there is one
+	for _, pool := range getPgBouncerPools(cluster) {
+		if err := createPgBouncerDeployment(clientset, cluster, pool); err != nil {
+			return err
+		}
+
+		if err := createPgBouncerService(clientset, cluster, pool); err != nil {
+			return err
+		}
 	}
 
 	log.Debugf("added pgbouncer to cluster [%s]", cluster.Spec.Name)
@@ -207,6 +252,7 @@ func AddPgbouncerFromPgTask(clientset *kubernetes.Clientset, restclient *rest.RE
 	// bring up the pgbouncer deployment and all of its trappings!
 	if err := AddPgbouncer(clientset, restclient, restconfig, &cluster); err != nil {
 		log.Error(err)
+		operator.UpdateTaskPhase(restclient, task.Name, namespace, crv1.PgtaskPhaseFailed, err.Error())
 		return
 	}
 
@@ -290,6 +336,13 @@ func DeletePgbouncer(clientset *kubernetes.Clientset, restclient *rest.RESTClien
 		return err
 	}
 
+	// in the mTLS-only mode, pgBouncer is no longer let in with its password
+	if cluster.Spec.TLS.ClientAuth.MTLSOnly {
+		if err := updateTLSClientAuthHBA(clientset, cluster); err != nil {
+			log.Warn(err)
+		}
+	}
+
 	// next, disable the pgbouncer user in the PostgreSQL cluster.
 	// first, get the primary pod. If we cannot do this, let's consider it an
 	// error and abort
@@ -318,15 +371,16 @@ func DeletePgbouncer(clientset *kubernetes.Clientset, restclient *rest.RESTClien
 	// these include the Service, Deployment, and the pgBouncer secret
 	// If these fail, we'll just pass through
 	//
-	// First, delete the Service and Deployment, which share the same naem
-	pgbouncerDeploymentName := fmt.Sprintf(pgBouncerDeploymentFormat, clusterName)
-
-	if err := kubeapi.DeleteService(clientset, pgbouncerDeploymentName, namespace); err != nil {
-		log.Warn(err)
-	}
+	// First, delete the Service and Deployment, which share the same naem, of
+	// each pgBouncer
+	for _, pool := range getPgBouncerPools(cluster) {
+		if err := kubeapi.DeleteService(clientset, pool.name, namespace); err != nil {
+			log.Warn(err)
+		}
 
-	if err := kubeapi.DeleteDeployment(clientset, pgbouncerDeploymentName, namespace); err != nil {
-		log.Warn(err)
+		if err := kubeapi.DeleteDeployment(clientset, pool.name, namespace); err != nil {
+			log.Warn(err)
+		}
 	}
 
 	// remove the secret. again, if this fails, just log the error and apss
@@ -337,6 +391,21 @@ func DeletePgbouncer(clientset *kubernetes.Clientset, restclient *rest.RESTClien
 		log.Warn(err)
 	}
 
+	// remove the certificate of pgBouncer, if the Operator manages one, along
+	// with the secret it was issued into. A keypair of the spec is left alone
+	if tlsSecretName := operator.GetTLSPgBouncerSecretName(cluster); tlsSecretName != "" &&
+		cluster.Spec.PgBouncer.TLSSecret == "" {
+		if cluster.Spec.TLS.CertManager.IsEnabled() {
+			if err := kubeapi.DeleteCertificate(clientset, tlsSecretName, namespace); err != nil {
+				log.Warn(err)
+			}
+		}
+
+		if err := kubeapi.DeleteSecret(clientset, tlsSecretName, namespace); err != nil {
+			log.Warn(err)
+		}
+	}
+
 	// lastly, if uninstall is set, remove the pgbouncer owned objects from the
 	// PostgreSQL cluster, and the pgbouncer as well
 	if uninstall {
@@ -371,6 +440,7 @@ func DeletePgbouncerFromPgTask(clientset *kubernetes.Clientset, restclient *rest
 	// attempt to delete the pgbouncer!
 	if err := DeletePgbouncer(clientset, restclient, restconfig, &cluster, uninstall); err != nil {
 		log.Error(err)
+		operator.UpdateTaskPhase(restclient, task.Name, namespace, crv1.PgtaskPhaseFailed, err.Error())
 		return
 	}
 
@@ -435,6 +505,7 @@ func UpdatePgbouncerFromPgTask(clientset *kubernetes.Clientset, restclient *rest
 	// attempt to delete the pgbouncer!
 	if err := UpdatePgbouncer(clientset, restclient, restconfig, &cluster, parameters); err != nil {
 		log.Error(err)
+		operator.UpdateTaskPhase(restclient, task.Name, namespace, crv1.PgtaskPhaseFailed, err.Error())
 		return
 	}
 
@@ -478,12 +549,28 @@ func checkPgBouncerInstall(clientset *kubernetes.Clientset, restconfig *rest.Con
 }
 
 // createPgBouncerDeployment creates the Kubernetes Deployment for pgBouncer
-func createPgBouncerDeployment(clientset *kubernetes.Clientset, cluster *crv1.Pgcluster) error {
-	log.Debugf("creating pgbouncer deployment: %s", cluster.Spec.Name)
+func createPgBouncerDeployment(clientset *kubernetes.Clientset, cluster *crv1.Pgcluster, pool pgBouncerPool) error {
+	log.Debugf("creating pgbouncer deployment: %s", pool.name)
+
+	deployment, err := generatePgBouncerDeployment(clientset, cluster, pool)
+	if err != nil {
+		return err
+	}
+
+	if err := kubeapi.CreateDeployment(clientset, deployment, cluster.Spec.Namespace); err != nil {
+		return err
+	}
+
+	return nil
+}
 
-	// derive the name of the Deployment...which is also used as the name of the
+// generatePgBouncerDeployment returns the Kubernetes Deployment for pgBouncer
+// as it follows from the spec of the cluster
+func generatePgBouncerDeployment(clientset *kubernetes.Clientset, cluster *crv1.Pgcluster,
+	pool pgBouncerPool) (*appsv1.Deployment, error) {
+	// the name of the Deployment...which is also used as the name of the
 	// service
-	pgbouncerDeploymentName := fmt.Sprintf(pgBouncerDeploymentFormat, cluster.Spec.Name)
+	pgbouncerDeploymentName := pool.name
 
 	// get the fields that will be substituted in the pgBouncer template
 	fields := PgbouncerTemplateFields{
@@ -494,16 +581,24 @@ func createPgBouncerDeployment(clientset *kubernetes.Clientset, cluster *crv1.Pg
 		Port:               operator.Pgo.Cluster.Port,
 		PGBouncerSecret:    util.GeneratePgBouncerSecretName(cluster.Spec.Name),
 		ContainerResources: "",
+		NodeSelectorLabels: operator.GetNodeSelectorJSON(cluster),
+		Tolerations:        operator.GetTolerationsJSON(cluster),
 		PodAntiAffinity: operator.GetPodAntiAffinity(cluster,
 			crv1.PodAntiAffinityDeploymentPgBouncer, cluster.Spec.PodAntiAffinity.PgBouncer),
 		PodAntiAffinityLabelName: config.LABEL_POD_ANTI_AFFINITY,
 		PodAntiAffinityLabelValue: string(operator.GetPodAntiAffinityType(cluster,
 			crv1.PodAntiAffinityDeploymentPgBouncer, cluster.Spec.PodAntiAffinity.PgBouncer)),
+		Replicas:  cluster.Spec.PgBouncer.GetReplicas(),
+		TLSSecret: operator.GetTLSPgBouncerSecretName(cluster),
+		ReadOnly:  pool.readOnly,
+		Image:     getPgBouncerImage(cluster),
 	}
 
 	// Determine if a custom resource profile should be used for the pgBouncer
-	// deployment
-	if operator.Pgo.DefaultPgbouncerResources != "" {
+	// deployment, which the resources of the spec take precedence over
+	if resources := cluster.Spec.PgBouncer.Resources; resources != (crv1.PgContainerResources{}) {
+		fields.ContainerResources = operator.GetContainerResourcesJSON(&resources)
+	} else if operator.Pgo.DefaultPgbouncerResources != "" {
 		pgBouncerResources, err := operator.Pgo.GetContainerResource(operator.Pgo.DefaultPgbouncerResources)
 
 		// if there is an error getting this, log it as an error, but continue on
@@ -514,34 +609,58 @@ func createPgBouncerDeployment(clientset *kubernetes.Clientset, cluster *crv1.Pg
 		fields.ContainerResources = operator.GetContainerResourcesJSON(&pgBouncerResources)
 	}
 
+	// the template depends on the connection pooler that is run
+	deploymentTemplate := getConnectionPooler(cluster).deploymentTemplate()
+
 	// For debugging purposes, put the template substitution in stdout
 	if operator.CRUNCHY_DEBUG {
-		config.PgbouncerTemplate.Execute(os.Stdout, fields)
+		deploymentTemplate.Execute(os.Stdout, fields)
 	}
 
 	// perform the actual template substitution
 	doc := bytes.Buffer{}
 
-	if err := config.PgbouncerTemplate.Execute(&doc, fields); err != nil {
-		return err
+	if err := deploymentTemplate.Execute(&doc, fields); err != nil {
+		return nil, err
 	}
 
 	// Set up the Kubernetes deployment for pgBouncer
-	deployment := appsv1.Deployment{}
+	deployment := &appsv1.Deployment{}
 
-	if err := json.Unmarshal(doc.Bytes(), &deployment); err != nil {
-		return err
+	if err := json.Unmarshal(doc.Bytes(), deployment); err != nil {
+		return nil, err
 	}
 
-	// set the container image to an override value, if one exists
-	operator.SetContainerImageOverride(config.CONTAINER_IMAGE_CRUNCHY_PGBOUNCER,
-		&deployment.Spec.Template.Spec.Containers[0])
+	// apply the scheduling of the cluster, along with that of pgBouncer
+	operator.AddPodScheduling(cluster, &cluster.Spec.PgBouncer.Scheduling, &deployment.Spec.Template.Spec)
 
-	if err := kubeapi.CreateDeployment(clientset, &deployment, cluster.Spec.Namespace); err != nil {
-		return err
+	// record the spec that pgBouncer is rolled out with, so that it is only
+	// rolled out again once the spec changes
+	deployment.Spec.Template.Annotations = map[string]string{
+		config.ANNOTATION_PGBOUNCER_SPEC_HASH: getPgBouncerDeploymentHash(deployment),
 	}
 
-	return nil
+	// record the certificate that pgBouncer starts with, so that it is only
+	// rolled out again once the certificate is renewed
+	if fields.TLSSecret != "" {
+		if secret, found, _ := kubeapi.GetSecret(clientset, fields.TLSSecret, cluster.Namespace); found {
+			deployment.Spec.Template.Annotations[config.ANNOTATION_TLS_CERT_HASH] = getTLSCertHash(secret)
+		}
+	}
+
+	// set the container image to the image of the spec or to an override
+	// value, if one exists
+	deployment.Spec.Template.Spec.Containers[0].Image = fields.Image
+
+	// set the custom labels and annotations of pgBouncer
+	operator.AddCustomMetadata(cluster, &cluster.Spec.Metadata.PgBouncer, &deployment.ObjectMeta,
+		&deployment.Spec.Template.ObjectMeta)
+
+	// set the chargeback labels of the Namespace
+	operator.AddChargebackLabels(clientset, cluster.Namespace, &deployment.ObjectMeta,
+		&deployment.Spec.Template.ObjectMeta)
+
+	return deployment, nil
 }
 
 // createPgbouncerSecret create a secret used by pgbouncer. Returns the
@@ -562,16 +681,34 @@ func createPgbouncerSecret(clientset *kubernetes.Clientset, cluster *crv1.Pgclus
 	// - the pgbouncer "users.txt" file that contains the credentials for the
 	// "pgbouncer" user
 
-	// first, generate the pgbouncer.ini information
-	pgBouncerConf, err := generatePgBouncerConf(cluster)
+	// first, generate the pgbouncer.ini information. The pool sizes are derived
+	// from the PostgreSQL parameters, and if these cannot be read, the defaults
+	// of PostgreSQL are assumed
+	parameters, err := GetPostgreSQLParameters(clientset, cluster)
+
+	if err != nil {
+		log.Warn(err)
+	}
+
+	pooler := getConnectionPooler(cluster)
+
+	pgBouncerConf, err := pooler.generateConf(cluster, parameters, password, false)
+
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+
+	// next, generate the pgbouncer HBA file
+	pgbouncerHBA, err := generatePgBouncerHBA(cluster)
 
 	if err != nil {
 		log.Error(err)
 		return err
 	}
 
-	// finally, generate the pgbouncer HBA file
-	pgbouncerHBA, err := generatePgBouncerHBA()
+	// finally, generate the "users.txt" file
+	pgbouncerUsers, err := generatePgBouncerUsers(cluster, password)
 
 	if err != nil {
 		log.Error(err)
@@ -589,14 +726,23 @@ func createPgbouncerSecret(clientset *kubernetes.Clientset, cluster *crv1.Pgclus
 			},
 		},
 		Data: map[string][]byte{
-			"password":      []byte(password),
-			"pgbouncer.ini": pgBouncerConf,
-			"pg_hba.conf":   pgbouncerHBA,
-			"users.txt": util.GeneratePgBouncerUsersFileBytes(
-				util.GeneratePostgreSQLMD5Password(crv1.PGUserPgBouncer, password)),
+			"password":            []byte(password),
+			pooler.confKey(false): pgBouncerConf,
+			"pg_hba.conf":         pgbouncerHBA,
+			"users.txt":           pgbouncerUsers,
 		},
 	}
 
+	// the read-only pgBouncer has a configuration of its own
+	if cluster.Spec.PgBouncer.ReadOnly {
+		conf, err := pooler.generateConf(cluster, parameters, password, true)
+		if err != nil {
+			return err
+		}
+
+		secret.Data[pooler.confKey(true)] = conf
+	}
+
 	if err := kubeapi.CreateSecret(clientset, &secret, cluster.Spec.Namespace); err != nil {
 		log.Error(err)
 		return err
@@ -606,10 +752,10 @@ func createPgbouncerSecret(clientset *kubernetes.Clientset, cluster *crv1.Pgclus
 }
 
 // createPgBouncerService creates the Kubernetes Service for pgBouncer
-func createPgBouncerService(clientset *kubernetes.Clientset, cluster *crv1.Pgcluster) error {
+func createPgBouncerService(clientset *kubernetes.Clientset, cluster *crv1.Pgcluster, pool pgBouncerPool) error {
 	// pgBouncerServiceName is the name of the Service of the pgBouncer, which
 	// matches that for the Deploymnt
-	pgBouncerServiceName := fmt.Sprintf(pgBouncerDeploymentFormat, cluster.Spec.Name)
+	pgBouncerServiceName := pool.name
 
 	// set up the service template fields
 	fields := ServiceTemplateFields{
@@ -618,14 +764,16 @@ func createPgBouncerService(clientset *kubernetes.Clientset, cluster *crv1.Pgclu
 		ClusterName: cluster.Spec.Name,
 		// TODO: I think "port" needs to be evaluated, but I think for now using
 		// the standard PostgreSQL port works
-		Port: operator.Pgo.Cluster.Port,
+		Port:        operator.Pgo.Cluster.Port,
+		ServiceType: getServiceSpecType(cluster.Spec.Services.PgBouncer, config.DEFAULT_SERVICE_TYPE),
 	}
 
 	if err := CreateService(clientset, &fields, cluster.Spec.Namespace); err != nil {
 		return err
 	}
 
-	return nil
+	return reconcileService(clientset, cluster.Spec.Namespace, pgBouncerServiceName,
+		cluster.Spec.Services.PgBouncer, fields.ServiceType)
 }
 
 // execPgBouncerScript runs a script pertaining to the management of pgBouncer
@@ -656,8 +804,11 @@ func generatePassword() string {
 }
 
 // generatePgBouncerConf generates the content that is stored in the secret
-// for the "pgbouncer.ini" file
-func generatePgBouncerConf(cluster *crv1.Pgcluster) ([]byte, error) {
+// for the "pgbouncer.ini" file, sizing the pools of each pgBouncer Pod so that
+// all of them together stay within the connections that the PostgreSQL
+// parameters allow for. If readOnly, pgBouncer connects to the replicas, each
+// of which has the same connection budget
+func generatePgBouncerConf(cluster *crv1.Pgcluster, parameters map[string]string, readOnly bool) ([]byte, error) {
 	// first, get the port
 	port := cluster.Spec.Port
 	// if the "port" value is not set, default to the PostgreSQL port.
@@ -665,10 +816,30 @@ func generatePgBouncerConf(cluster *crv1.Pgcluster) ([]byte, error) {
 		port = pgPort
 	}
 
+	// the read-only pgBouncer connects through the Service of the replicas
+	serviceName := cluster.Spec.Name
+	if readOnly {
+		serviceName += ReplicaSuffix
+	}
+
 	// set up the substitution fields for the pgbouncer.ini file
 	fields := PgbouncerConfFields{
-		PG_PRIMARY_SERVICE_NAME: cluster.Spec.Name,
+		PG_PRIMARY_SERVICE_NAME: serviceName,
 		PG_PORT:                 port,
+		MaxDBConnections: getPgBouncerMaxDBConnections(parameters,
+			cluster.Spec.PgBouncer.GetReplicas(), getPgBouncerDatabaseCount(cluster)),
+		MaxClientConn: cluster.Spec.PgBouncer.GetMaxClientConn(),
+		PoolMode:      cluster.Spec.PgBouncer.GetPoolMode(),
+		AuthType:      string(cluster.Spec.GetPasswordEncryption()),
+		Settings:      getPgBouncerSettings(cluster.Spec.PgBouncer),
+		TLSEnabled:    operator.GetTLSPgBouncerSecretName(cluster) != "",
+		TLSOnly:       cluster.Spec.TLSOnly,
+	}
+
+	// a pool is never larger than what pgBouncer may open for the database
+	fields.DefaultPoolSize = defaultPgBouncerPoolSize
+	if fields.DefaultPoolSize > fields.MaxDBConnections {
+		fields.DefaultPoolSize = fields.MaxDBConnections
 	}
 
 	// perform the substitution
@@ -687,14 +858,17 @@ func generatePgBouncerConf(cluster *crv1.Pgcluster) ([]byte, error) {
 	return doc.Bytes(), nil
 }
 
-// generatePgBouncerConf generates the pgBouncer host-based authentication file
-// using the template that is vailable
-func generatePgBouncerHBA() ([]byte, error) {
-	// ...apparently this is overkill, but this is here from the legacy method
-	// and it seems like it's "ok" to leave it like this for now...
+// generatePgBouncerHBA generates the pgBouncer host-based authentication file
+// using the template that is vailable, which has the clients authenticate with
+// the password encryption of the cluster
+func generatePgBouncerHBA(cluster *crv1.Pgcluster) ([]byte, error) {
+	fields := PgbouncerHBAFields{
+		AuthType: string(cluster.Spec.GetPasswordEncryption()),
+	}
+
 	doc := bytes.Buffer{}
 
-	if err := config.PgbouncerHBATemplate.Execute(&doc, struct{}{}); err != nil {
+	if err := config.PgbouncerHBATemplate.Execute(&doc, fields); err != nil {
 		log.Error(err)
 
 		return []byte{}, err
@@ -705,6 +879,27 @@ func generatePgBouncerHBA() ([]byte, error) {
 	return doc.Bytes(), nil
 }
 
+// generatePgBouncerUsers generates the "users.txt" file that has the
+// credentials of the "pgbouncer" user. With MD5 password encryption it holds
+// the MD5 hash of the password. pgBouncer can only log in to PostgreSQL with a
+// SCRAM verifier on behalf of a client that authenticated with SCRAM itself,
+// which the "pgbouncer" user running the auth_query does not, so with
+// SCRAM-SHA-256 password encryption it holds the password as is, as the Secret
+// does already
+func generatePgBouncerUsers(cluster *crv1.Pgcluster, password string) ([]byte, error) {
+	if cluster.Spec.GetPasswordEncryption() == crv1.PasswordEncryptionSCRAMSHA256 {
+		return util.GeneratePgBouncerUsersFileBytes(password), nil
+	}
+
+	hashedPassword, err := util.GeneratePostgreSQLPassword(cluster.Spec.GetPasswordEncryption(),
+		crv1.PGUserPgBouncer, password)
+	if err != nil {
+		return []byte{}, err
+	}
+
+	return util.GeneratePgBouncerUsersFileBytes(hashedPassword), nil
+}
+
 // generatePgtaskForPgBouncer generates a pgtask specific to a pgbouncer
 // deployment
 func generatePgtaskForPgBouncer(cluster *crv1.Pgcluster, pgouser, taskType, taskLabel string, parameters map[string]string) *crv1.Pgtask {
@@ -857,16 +1052,37 @@ func rotatePgBouncerPassword(clientset *kubernetes.Clientset, restclient *rest.R
 
 	// next, update the PostgreSQL primary with the new password. If this fails
 	// we definitely return an error
-	if err := setPostgreSQLPassword(clientset, restconfig, primaryPod, password); err != nil {
+	if err := setPostgreSQLPassword(clientset, restconfig, primaryPod, password,
+		cluster.Spec.GetPasswordEncryption()); err != nil {
 		return err
 	}
 
 	// next, update the users.txt and password fields of the secret. the important
 	// one to update is the users.txt, as that is used by pgbouncer to connect to
 	// PostgreSQL to perform its authentication
+	users, err := generatePgBouncerUsers(cluster, password)
+	if err != nil {
+		return err
+	}
+
 	secret.Data["password"] = []byte(password)
-	secret.Data["users.txt"] = util.GeneratePgBouncerUsersFileBytes(
-		util.GeneratePostgreSQLMD5Password(crv1.PGUserPgBouncer, password))
+	secret.Data["users.txt"] = users
+
+	// a connection pooler may keep the password in its configuration as well,
+	// which is loaded as its Pods are restarted
+	parameters, err := GetPostgreSQLParameters(clientset, cluster)
+	if err != nil {
+		log.Warn(err)
+	}
+
+	for _, pool := range getPgBouncerPools(cluster) {
+		conf, err := getConnectionPooler(cluster).generateConf(cluster, parameters, password, pool.readOnly)
+		if err != nil {
+			return err
+		}
+
+		secret.Data[pool.confKey] = conf
+	}
 
 	// update the secret
 	if err := kubeapi.UpdateSecret(clientset, secret, namspace); err != nil {
@@ -889,7 +1105,7 @@ func rotatePgBouncerPassword(clientset *kubernetes.Clientset, restclient *rest.R
 	// returns, restart the pod (i.e. deleted it)
 	for _, pod := range pods.Items {
 		waitForSecretPropagation(clientset, restconfig, pod, string(secret.Data["users.txt"]),
-			pgBouncerSecretPropagationTimeout, pgBouncerSecretPropagationPeriod)
+			cmdViewPgBouncerUsersSecret, pgBouncerSecretPropagationTimeout, pgBouncerSecretPropagationPeriod)
 
 		// after this waiting period has passed, delete Pod. If the pod fails to
 		// delete, warn but continue on
@@ -903,13 +1119,16 @@ func rotatePgBouncerPassword(clientset *kubernetes.Clientset, restclient *rest.R
 
 // setPostgreSQLPassword updates the pgBouncer password in the PostgreSQL
 // cluster by executing into the primary Pod and changing it
-func setPostgreSQLPassword(clientset *kubernetes.Clientset, restconfig *rest.Config, pod *v1.Pod, password string) error {
+func setPostgreSQLPassword(clientset *kubernetes.Clientset, restconfig *rest.Config, pod *v1.Pod, password string,
+	encryption crv1.PasswordEncryptionType) error {
 	log.Debug("set pgbouncer password in PostgreSQL")
 
-	// we use the PostgreSQL "md5" hashing mechanism here to pre-hash the
-	// password. This is hard coded, which will make moving up to SCRAM ever more
-	// so lovely, but at least it's better than sending it around as plaintext
-	sqlpgBouncerPassword := util.GeneratePostgreSQLMD5Password(crv1.PGUserPgBouncer, password)
+	// we pre-hash the password with the password encryption of the cluster, so
+	// that it is not sent around as plaintext
+	sqlpgBouncerPassword, err := util.GeneratePostgreSQLPassword(encryption, crv1.PGUserPgBouncer, password)
+	if err != nil {
+		return err
+	}
 
 	if err := util.SetPostgreSQLPassword(clientset, restconfig, pod, crv1.PGUserPgBouncer, sqlpgBouncerPassword, sqlEnableLogin); err != nil {
 		log.Error(err)
@@ -958,8 +1177,9 @@ func uninstallPgBouncer(clientset *kubernetes.Clientset, restconfig *rest.Config
 }
 
 // waitForSecretPropagation waits until the update to the pgbouncer secret has
-// propogated
-func waitForSecretPropagation(clientset *kubernetes.Clientset, restconfig *rest.Config, pod v1.Pod, expected string, timeoutSecs, periodSecs time.Duration) {
+// propogated, i.e. until the command that views the file in the secret returns
+// what is expected
+func waitForSecretPropagation(clientset *kubernetes.Clientset, restconfig *rest.Config, pod v1.Pod, expected string, cmd []string, timeoutSecs, periodSecs time.Duration) {
 	timeout := time.After(timeoutSecs * time.Second)
 	tick := time.Tick(periodSecs * time.Second)
 
@@ -972,9 +1192,10 @@ loop:
 			log.Warnf("timed out after [%d]s waiting for secret to propogate to pod [%s]", timeout, pod.Name)
 			return
 		case <-tick:
-			// exec into the pod to run the query
+			// exec into the pod to run the query, in the container of whichever
+			// connection pooler it runs
 			stdout, stderr, err := kubeapi.ExecToPodThroughAPI(restconfig, clientset,
-				cmdViewPgBouncerUsersSecret, "pgbouncer", pod.Name, pod.ObjectMeta.Namespace, nil)
+				cmd, pod.Spec.Containers[0].Name, pod.Name, pod.ObjectMeta.Namespace, nil)
 
 			// if there is an error, warn about it, but try again
 			if err != nil {
@@ -0,0 +1,119 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"fmt"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/kubeapi"
+	"github.com/crunchydata/postgres-operator/util"
+	log "github.com/sirupsen/logrus"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// pgBouncerReadOnlyDeploymentFormat is the name of the Kubernetes Deployment, and Service,
+	// of the pgBouncer that pools connections to the replicas, in the format
+	// "<clusterName>-pgbouncer-ro"
+	pgBouncerReadOnlyDeploymentFormat = "%s-pgbouncer-ro"
+
+	// the keys of the pgBouncer Secret with the pgbouncer.ini of each pgBouncer
+	pgBouncerConfKey         = "pgbouncer.ini"
+	pgBouncerReadOnlyConfKey = "pgbouncer-ro.ini"
+)
+
+// pgBouncerPool is one of the pgBouncer Deployments of a cluster, which is reached through the
+// Service of the same name
+type pgBouncerPool struct {
+	name string
+	// readOnly has pgBouncer pool connections to the replicas rather than to the primary
+	readOnly bool
+	// confKey is the key of the pgBouncer Secret with the configuration of this pgBouncer
+	confKey string
+}
+
+// getPgBouncerPools returns the pgBouncer Deployments that a cluster has according to its
+// spec: the one of the primary and, if it is enabled, the read-only one
+func getPgBouncerPools(cluster *crv1.Pgcluster) []pgBouncerPool {
+	pools := []pgBouncerPool{getPgBouncerPool(cluster, false)}
+
+	if cluster.Spec.PgBouncer.ReadOnly {
+		pools = append(pools, getPgBouncerPool(cluster, true))
+	}
+
+	return pools
+}
+
+// getPgBouncerPool returns the pgBouncer Deployment of a cluster that pools connections to the
+// primary or, if readOnly, to the replicas
+func getPgBouncerPool(cluster *crv1.Pgcluster, readOnly bool) pgBouncerPool {
+	pool := pgBouncerPool{
+		name:     fmt.Sprintf(pgBouncerDeploymentFormat, cluster.Name),
+		readOnly: readOnly,
+		confKey:  getConnectionPooler(cluster).confKey(readOnly),
+	}
+
+	if readOnly {
+		pool.name = fmt.Sprintf(pgBouncerReadOnlyDeploymentFormat, cluster.Name)
+	}
+
+	return pool
+}
+
+// reconcilePgBouncerReadOnly adds the read-only pgBouncer of a cluster once it is enabled, or
+// removes it once it is disabled. Its configuration is expected to be in the pgBouncer Secret
+// before it is added
+func reconcilePgBouncerReadOnly(clientset *kubernetes.Clientset, cluster *crv1.Pgcluster) error {
+	pool := getPgBouncerPool(cluster, true)
+
+	_, found, _ := kubeapi.GetDeployment(clientset, pool.name, cluster.Namespace)
+
+	if cluster.Spec.PgBouncer.ReadOnly && !found {
+		log.Debugf("pgbouncer: adding read-only pgbouncer %s", pool.name)
+
+		if err := createPgBouncerDeployment(clientset, cluster, pool); err != nil {
+			return err
+		}
+
+		return createPgBouncerService(clientset, cluster, pool)
+	}
+
+	if !cluster.Spec.PgBouncer.ReadOnly && found {
+		log.Debugf("pgbouncer: removing read-only pgbouncer %s", pool.name)
+
+		if err := kubeapi.DeleteService(clientset, pool.name, cluster.Namespace); err != nil {
+			log.Warn(err)
+		}
+
+		if err := kubeapi.DeleteDeployment(clientset, pool.name, cluster.Namespace); err != nil {
+			return err
+		}
+
+		// the configuration is removed only now, as the Pods of the Deployment mount it
+		secret, found, err := kubeapi.GetSecret(clientset, util.GeneratePgBouncerSecretName(cluster.Name),
+			cluster.Namespace)
+		if !found {
+			return err
+		}
+
+		delete(secret.Data, pool.confKey)
+
+		return kubeapi.UpdateSecret(clientset, secret, cluster.Namespace)
+	}
+
+	return nil
+}
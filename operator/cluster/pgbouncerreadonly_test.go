@@ -0,0 +1,55 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"reflect"
+	"testing"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGetPgBouncerPools(t *testing.T) {
+	cluster := &crv1.Pgcluster{ObjectMeta: metav1.ObjectMeta{Name: "hippo"}}
+
+	primary := pgBouncerPool{name: "hippo-pgbouncer", confKey: "pgbouncer.ini"}
+
+	if pools := getPgBouncerPools(cluster); !reflect.DeepEqual(pools, []pgBouncerPool{primary}) {
+		t.Fatalf("expected only the pgbouncer of the primary, got %+v", pools)
+	}
+
+	cluster.Spec.PgBouncer.ReadOnly = true
+
+	expected := []pgBouncerPool{primary, {name: "hippo-pgbouncer-ro", readOnly: true, confKey: "pgbouncer-ro.ini"}}
+
+	if pools := getPgBouncerPools(cluster); !reflect.DeepEqual(pools, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, pools)
+	}
+}
+
+func TestGetPgBouncerPoolsOdyssey(t *testing.T) {
+	cluster := &crv1.Pgcluster{ObjectMeta: metav1.ObjectMeta{Name: "hippo"}}
+	cluster.Spec.PgBouncer.Pooler = crv1.PoolerOdyssey
+	cluster.Spec.PgBouncer.ReadOnly = true
+
+	pools := getPgBouncerPools(cluster)
+
+	if pools[0].name != "hippo-pgbouncer" || pools[0].confKey != "odyssey.conf" ||
+		pools[1].name != "hippo-pgbouncer-ro" || pools[1].confKey != "odyssey-ro.conf" {
+		t.Fatalf("expected the configuration of odyssey behind the pgbouncer services, got %+v", pools)
+	}
+}
@@ -0,0 +1,287 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/kubeapi"
+	"github.com/crunchydata/postgres-operator/util"
+	log "github.com/sirupsen/logrus"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	// defaultPgBouncerPoolSize is the "default_pool_size" of a pgBouncer Pod,
+	// unless its share of the connection budget is smaller than that
+	defaultPgBouncerPoolSize = 20
+	// pgBouncerConnectionBudgetPercent is the percentage of the connections of
+	// PostgreSQL, less those reserved for superusers, that all of the pgBouncer
+	// Pods may open together. The remainder is left for the connections that do
+	// not go through pgBouncer, e.g. those of the Operator and of monitoring
+	pgBouncerConnectionBudgetPercent = 80
+
+	// the defaults of PostgreSQL for the parameters that the connection budget
+	// is derived from
+	defaultMaxConnections               = 100
+	defaultSuperuserReservedConnections = 3
+)
+
+// ReconcilePgBouncerReplicas scales the pgBouncer Deployments of a cluster to
+// the number of replicas of the cluster. As each pgBouncer Pod opens its own
+// connections to PostgreSQL, its pools shrink as Pods are added, so that all
+// of them together stay within the connection budget. The pools are shrunk
+// before Pods are added, and only grown once Pods have been removed
+func ReconcilePgBouncerReplicas(clientset *kubernetes.Clientset, restconfig *rest.Config, cluster *crv1.Pgcluster) error {
+	deployments := []*appsv1.Deployment{}
+
+	for _, pool := range getPgBouncerPools(cluster) {
+		deployment, found, err := kubeapi.GetDeployment(clientset, pool.name, cluster.Namespace)
+		if !found {
+			// the read-only pgBouncer is only added once its configuration is
+			// in place
+			if pool.readOnly {
+				continue
+			}
+			return err
+		}
+
+		deployments = append(deployments, deployment)
+	}
+
+	parameters, err := GetPostgreSQLParameters(clientset, cluster)
+	if err != nil {
+		return err
+	}
+
+	replicas := cluster.Spec.PgBouncer.GetReplicas()
+
+	current := map[string]int{}
+	grow := false
+
+	for _, deployment := range deployments {
+		current[deployment.Name] = 1
+		if deployment.Spec.Replicas != nil {
+			current[deployment.Name] = int(*deployment.Spec.Replicas)
+		}
+
+		grow = grow || replicas > current[deployment.Name]
+	}
+
+	if grow {
+		if err := updatePgBouncerPoolSizes(clientset, restconfig, cluster, parameters); err != nil {
+			return err
+		}
+	}
+
+	for _, deployment := range deployments {
+		if replicas == current[deployment.Name] {
+			continue
+		}
+
+		log.Debugf("pgbouncer: scaling %s from %d to %d replicas", deployment.Name,
+			current[deployment.Name], replicas)
+
+		if err := kubeapi.ScaleDeployment(clientset, *deployment, replicas); err != nil {
+			return err
+		}
+
+		if err := waitForPgBouncerRollout(clientset, cluster.Namespace, deployment.Name); err != nil {
+			return err
+		}
+	}
+
+	// this also picks up a change of the connection budget, e.g. when the
+	// pgBouncer configuration was generated before the Pods were scaled
+	return updatePgBouncerPoolSizes(clientset, restconfig, cluster, parameters)
+}
+
+// ReconcilePgBouncerDatabases counts the databases of a cluster that accept
+// connections and, once their number changed, records it in the status of
+// the cluster and resizes the pools of pgBouncer, as the connection budget of
+// each pgBouncer Pod is split among the databases it may connect to
+func ReconcilePgBouncerDatabases(clientset *kubernetes.Clientset, restclient *rest.RESTClient,
+	restconfig *rest.Config, cluster *crv1.Pgcluster) error {
+	// the primary is looked up by the namespace in the spec
+	cluster.Spec.Namespace = cluster.Namespace
+
+	pod, err := util.GetPrimaryPod(clientset, cluster)
+	if err != nil {
+		return err
+	}
+
+	output, err := execMaintenanceSQL(clientset, restconfig, pod, "postgres", sqlMaintenanceDatabases)
+	if err != nil {
+		return err
+	}
+
+	databases := 0
+	for _, database := range strings.Split(output, "\n") {
+		if database != "" {
+			databases++
+		}
+	}
+
+	if databases == 0 || databases == cluster.Status.Databases {
+		return nil
+	}
+
+	log.Debugf("pgbouncer: cluster %s has %d databases", cluster.Name, databases)
+
+	parameters, err := GetPostgreSQLParameters(clientset, cluster)
+	if err != nil {
+		return err
+	}
+
+	// the pools are resized for the databases there are now before they are recorded, so that
+	// the resize is retried if it fails
+	counted := cluster.DeepCopy()
+	counted.Status.Databases = databases
+
+	if err := updatePgBouncerPoolSizes(clientset, restconfig, counted, parameters); err != nil {
+		return err
+	}
+
+	return kubeapi.Patchpgcluster(restclient, cluster, cluster.Namespace, func(cluster *crv1.Pgcluster) {
+		cluster.Status.Databases = databases
+	})
+}
+
+// getPgBouncerDatabaseCount returns the number of databases of a cluster that
+// pgBouncer may connect to, which is the number that was last counted or, if
+// they were not counted yet, the "postgres" database and that of the spec
+func getPgBouncerDatabaseCount(cluster *crv1.Pgcluster) int {
+	if cluster.Status.Databases > 0 {
+		return cluster.Status.Databases
+	}
+
+	if cluster.Spec.Database == "" || cluster.Spec.Database == "postgres" {
+		return 1
+	}
+
+	return 2
+}
+
+// getPgBouncerMaxDBConnections returns the "max_db_connections" of each of the
+// pgBouncer Pods for each of the given number of databases, i.e. their even
+// share of the connection budget that follows from the given PostgreSQL
+// parameters. As "max_db_connections" applies to each database on its own,
+// the share of a Pod is split among the databases, so that the pools of all
+// of the databases together stay within it. Each Pod may open at least one
+// connection to each database, even if the budget does not allow for that
+func getPgBouncerMaxDBConnections(parameters map[string]string, replicas, databases int) int {
+	maxConnections, err := strconv.Atoi(parameters["max_connections"])
+	if err != nil {
+		maxConnections = defaultMaxConnections
+	}
+
+	reserved, err := strconv.Atoi(parameters["superuser_reserved_connections"])
+	if err != nil {
+		reserved = defaultSuperuserReservedConnections
+	}
+
+	budget := (maxConnections - reserved) * pgBouncerConnectionBudgetPercent / 100
+
+	if replicas < 1 {
+		replicas = 1
+	}
+
+	if databases < 1 {
+		databases = 1
+	}
+
+	if budget/replicas/databases < 1 {
+		return 1
+	}
+
+	return budget / replicas / databases
+}
+
+// updatePgBouncerPoolSizes regenerates the configuration of each pgBouncer of
+// a cluster from its pgBouncer spec and the given PostgreSQL parameters and,
+// where it changed, has each of the Pods of that pgBouncer reload it once it
+// has propagated to them
+func updatePgBouncerPoolSizes(clientset *kubernetes.Clientset, restconfig *rest.Config, cluster *crv1.Pgcluster,
+	parameters map[string]string) error {
+	secretName := util.GeneratePgBouncerSecretName(cluster.Name)
+
+	secret, _, err := kubeapi.GetSecret(clientset, secretName, cluster.Namespace)
+	if err != nil {
+		return err
+	}
+
+	changed := []pgBouncerPool{}
+	pooler := getConnectionPooler(cluster)
+
+	for _, pool := range getPgBouncerPools(cluster) {
+		conf, err := pooler.generateConf(cluster, parameters, string(secret.Data["password"]), pool.readOnly)
+		if err != nil {
+			return err
+		}
+
+		if !bytes.Equal(secret.Data[pool.confKey], conf) {
+			secret.Data[pool.confKey] = conf
+			changed = append(changed, pool)
+		}
+	}
+
+	if len(changed) == 0 {
+		return nil
+	}
+
+	log.Debugf("pgbouncer: updating the pool sizes of cluster %s", cluster.Name)
+
+	if err := kubeapi.UpdateSecret(clientset, secret, cluster.Namespace); err != nil {
+		return err
+	}
+
+	for _, pool := range changed {
+		if err := reloadPgBouncerConf(clientset, restconfig, cluster, pool,
+			secret.Data[pool.confKey]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// reloadPgBouncerConf has each of the Pods of a pgBouncer reload its
+// configuration once the given configuration has propagated to them
+func reloadPgBouncerConf(clientset *kubernetes.Clientset, restconfig *rest.Config, cluster *crv1.Pgcluster,
+	pool pgBouncerPool, conf []byte) error {
+	pods, err := getPgBouncerPods(clientset, cluster.Namespace, pool.name)
+	if err != nil {
+		return err
+	}
+
+	pooler := getConnectionPooler(cluster)
+
+	for i := range pods {
+		waitForSecretPropagation(clientset, restconfig, pods[i], strings.TrimSpace(string(conf)),
+			[]string{"cat", pooler.confPath()}, pgBouncerSecretPropagationTimeout, pgBouncerSecretPropagationPeriod)
+
+		if err := pooler.reload(clientset, restconfig, &pods[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,64 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"testing"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+)
+
+func TestGetPgBouncerMaxDBConnections(t *testing.T) {
+	parameters := map[string]string{"max_connections": "203", "superuser_reserved_connections": "3"}
+
+	for _, test := range []struct {
+		name                string
+		parameters          map[string]string
+		replicas, databases int
+		expected            int
+	}{
+		{"defaults", map[string]string{}, 1, 1, 77},
+		{"one pod", parameters, 1, 1, 160},
+		{"pods", parameters, 4, 1, 40},
+		// the pools of all of the databases together stay within the share of each Pod
+		{"databases", parameters, 4, 3, 13},
+		{"no databases counted", parameters, 4, 0, 40},
+		// each Pod may open at least one connection to each database
+		{"too many databases", parameters, 4, 100, 1},
+	} {
+		if max := getPgBouncerMaxDBConnections(test.parameters, test.replicas,
+			test.databases); max != test.expected {
+			t.Errorf("%s: expected %d, got %d", test.name, test.expected, max)
+		}
+	}
+}
+
+func TestGetPgBouncerDatabaseCount(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		cluster  crv1.Pgcluster
+		expected int
+	}{
+		{"not counted", crv1.Pgcluster{Spec: crv1.PgclusterSpec{Database: "hippo"}}, 2},
+		{"postgres only", crv1.Pgcluster{Spec: crv1.PgclusterSpec{Database: "postgres"}}, 1},
+		{"counted", crv1.Pgcluster{Spec: crv1.PgclusterSpec{Database: "hippo"},
+			Status: crv1.PgclusterStatus{Databases: 5}}, 5},
+	} {
+		if count := getPgBouncerDatabaseCount(&test.cluster); count != test.expected {
+			t.Errorf("%s: expected %d, got %d", test.name, test.expected, count)
+		}
+	}
+}
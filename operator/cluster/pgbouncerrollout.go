@@ -0,0 +1,318 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/config"
+	"github.com/crunchydata/postgres-operator/kubeapi"
+	"github.com/crunchydata/postgres-operator/operator"
+	log "github.com/sirupsen/logrus"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	// pgBouncerSurgeDeploymentFormat is the name of the temporary Deployment
+	// that takes on the new connections during a graceful rollout of a
+	// pgBouncer Deployment, in the format "<deploymentName>-surge"
+	pgBouncerSurgeDeploymentFormat = "%s-surge"
+	// defaultPgBouncerDrainTimeout is how long, in seconds, "PAUSE" is given to
+	// drain a pgBouncer Pod if it is not set on the cluster
+	defaultPgBouncerDrainTimeout = 30
+	// how long to wait, in seconds, for the pgBouncer Pods to be ready
+	pgBouncerRolloutTimeout = 300
+	pgBouncerRolloutPeriod  = 5
+)
+
+// cmdPgBouncerAdmin runs a command on the pgBouncer admin console from within
+// the pgBouncer container, giving up after the given number of seconds
+const cmdPgBouncerAdmin = `PGPASSWORD="${PG_PASSWORD}" timeout %d psql -h localhost -p %s -U %s -d pgbouncer -c '%s'`
+
+// UpdatePgBouncerImage rolls out the pgBouncer image that matches the image
+// tag of the cluster to each of its pgBouncer Deployments. Unless a graceful
+// rollout is requested, the Deployment is simply updated, which drops the
+// connections of the pgBouncer Pods as they are replaced. The rollout of a
+// graceful rollout can take a while, so it is run in the background
+func UpdatePgBouncerImage(clientset *kubernetes.Clientset, restconfig *rest.Config, cluster *crv1.Pgcluster) error {
+	image := getPgBouncerImage(cluster)
+
+	for _, pool := range getPgBouncerPools(cluster) {
+		deployment, found, err := kubeapi.GetDeployment(clientset, pool.name, cluster.Namespace)
+		if !found {
+			// the read-only pgBouncer is added with the image as it is
+			if pool.readOnly {
+				continue
+			}
+			return err
+		}
+
+		if deployment.Spec.Template.Spec.Containers[0].Image == image {
+			continue
+		}
+
+		log.Debugf("rolling out pgbouncer image %s for %s, graceful: %t", image, deployment.Name,
+			cluster.Spec.PgBouncer.GracefulRollout)
+
+		if !cluster.Spec.PgBouncer.GracefulRollout {
+			deployment.Spec.Template.Spec.Containers[0].Image = image
+
+			if err := kubeapi.UpdateDeployment(clientset, deployment); err != nil {
+				return err
+			}
+			continue
+		}
+
+		go func(deployment *appsv1.Deployment) {
+			if err := rolloutPgBouncerGracefully(clientset, restconfig, cluster, deployment, image); err != nil {
+				log.Errorf("pgbouncer: graceful rollout of %s failed: %s", deployment.Name, err)
+			}
+		}(deployment)
+	}
+
+	return nil
+}
+
+// rolloutPgBouncerGracefully replaces the pgBouncer Pods while keeping the
+// connections that are in the middle of a transaction intact:
+//
+//  1. a temporary pgBouncer with the new image is brought up behind the pgBouncer
+//     Service and resumed, so it takes on the new connections
+//  2. the existing pgBouncer Pods are drained with "PAUSE", which waits for the
+//     transactions in progress to finish, up to the drain timeout
+//  3. the pgBouncer Deployment is updated to the new image and, once its Pods
+//     are ready, they are resumed
+//  4. the temporary pgBouncer is drained in the same way and removed
+func rolloutPgBouncerGracefully(clientset *kubernetes.Clientset, restconfig *rest.Config, cluster *crv1.Pgcluster,
+	deployment *appsv1.Deployment, image string) error {
+	surgeName := fmt.Sprintf(pgBouncerSurgeDeploymentFormat, deployment.Name)
+
+	surge := newPgBouncerSurgeDeployment(deployment, surgeName, image)
+
+	// an earlier rollout may have left its temporary pgBouncer behind
+	if _, found, _ := kubeapi.GetDeployment(clientset, surgeName, cluster.Namespace); !found {
+		if err := kubeapi.CreateDeployment(clientset, surge, cluster.Namespace); err != nil {
+			return err
+		}
+	}
+
+	if err := waitForPgBouncerRollout(clientset, cluster.Namespace, surgeName); err != nil {
+		return err
+	}
+
+	if err := resumePgBouncerPods(clientset, restconfig, cluster.Namespace, surgeName); err != nil {
+		return err
+	}
+
+	drainPgBouncerPods(clientset, restconfig, cluster, deployment.Name)
+
+	// get the latest version of the Deployment before it is updated
+	deployment, found, err := kubeapi.GetDeployment(clientset, deployment.Name, cluster.Namespace)
+	if !found {
+		return err
+	}
+
+	deployment.Spec.Template.Spec.Containers[0].Image = image
+
+	if err := kubeapi.UpdateDeployment(clientset, deployment); err != nil {
+		return err
+	}
+
+	if err := waitForPgBouncerRollout(clientset, cluster.Namespace, deployment.Name); err != nil {
+		return err
+	}
+
+	if err := resumePgBouncerPods(clientset, restconfig, cluster.Namespace, deployment.Name); err != nil {
+		return err
+	}
+
+	drainPgBouncerPods(clientset, restconfig, cluster, surgeName)
+
+	log.Debugf("pgbouncer: graceful rollout of %s completed", deployment.Name)
+
+	return kubeapi.DeleteDeployment(clientset, surgeName, cluster.Namespace)
+}
+
+// newPgBouncerSurgeDeployment returns a copy of the pgBouncer Deployment with
+// the new image. Its Pods keep the labels that the pgBouncer Service selects
+// on, but not the "name" label that the pgBouncer Deployment selects on, so
+// that they are not mistaken for each others Pods
+func newPgBouncerSurgeDeployment(deployment *appsv1.Deployment, name, image string) *appsv1.Deployment {
+	labels := func(in map[string]string) map[string]string {
+		out := map[string]string{}
+		for k, v := range in {
+			out[k] = v
+		}
+		out[config.LABEL_NAME] = name
+		return out
+	}
+
+	surge := &appsv1.Deployment{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:   name,
+			Labels: labels(deployment.ObjectMeta.Labels),
+		},
+		Spec: *deployment.Spec.DeepCopy(),
+	}
+
+	surge.Spec.Selector.MatchLabels = labels(deployment.Spec.Selector.MatchLabels)
+	surge.Spec.Template.ObjectMeta.Labels = labels(deployment.Spec.Template.ObjectMeta.Labels)
+	surge.Spec.Template.Spec.Containers[0].Image = image
+
+	return surge
+}
+
+// drainPgBouncerPods has each of the pgBouncer Pods of a Deployment "PAUSE",
+// which waits for their server connections to be released. If this does not
+// happen within the drain timeout, the rollout proceeds anyway rather than
+// holding it up indefinitely, which drops whatever connections are left
+func drainPgBouncerPods(clientset *kubernetes.Clientset, restconfig *rest.Config, cluster *crv1.Pgcluster,
+	deploymentName string) {
+	timeout := cluster.Spec.PgBouncer.DrainTimeoutSeconds
+	if timeout <= 0 {
+		timeout = defaultPgBouncerDrainTimeout
+	}
+
+	pods, err := getPgBouncerPods(clientset, cluster.Namespace, deploymentName)
+	if err != nil {
+		log.Warn(err)
+		return
+	}
+
+	for i := range pods {
+		log.Debugf("pgbouncer: draining pod %s", pods[i].Name)
+
+		if _, err := execPgBouncerAdmin(clientset, restconfig, &pods[i], "PAUSE;", timeout); err != nil {
+			log.Warn(err)
+			operator.RecordWarningEvent(clientset, cluster, "PgBouncerDrainTimeout",
+				fmt.Sprintf("pgBouncer pod %s was not drained within %d seconds, so its remaining "+
+					"connections are dropped", pods[i].Name, timeout))
+		}
+	}
+}
+
+// resumePgBouncerPods has each of the pgBouncer Pods of a Deployment "RESUME",
+// in case they were paused, so they are known to serve queries before the
+// pgBouncer Pods they take over from are removed
+func resumePgBouncerPods(clientset *kubernetes.Clientset, restconfig *rest.Config, namespace, deploymentName string) error {
+	pods, err := getPgBouncerPods(clientset, namespace, deploymentName)
+	if err != nil {
+		return err
+	}
+
+	for i := range pods {
+		// pgBouncer returns an error if it is not paused, which is just fine
+		if stdout, err := execPgBouncerAdmin(clientset, restconfig, &pods[i], "RESUME;",
+			defaultPgBouncerDrainTimeout); err != nil && !strings.Contains(stdout, "not paused") {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// execPgBouncerAdmin runs a command on the admin console of pgBouncer,
+// returning the output of the command
+func execPgBouncerAdmin(clientset *kubernetes.Clientset, restconfig *rest.Config, pod *v1.Pod,
+	command string, timeout int) (string, error) {
+	cmd := []string{"bash", "-c", fmt.Sprintf(cmdPgBouncerAdmin, timeout, pgPort, crv1.PGUserPgBouncer, command)}
+
+	stdout, stderr, err := kubeapi.ExecToPodThroughAPI(restconfig, clientset,
+		cmd, "pgbouncer", pod.Name, pod.ObjectMeta.Namespace, nil)
+
+	if err != nil {
+		return stdout + stderr, fmt.Errorf("pgbouncer %s on pod %s failed: %s %s", strings.TrimSuffix(command, ";"),
+			pod.Name, err, strings.TrimSpace(stderr))
+	}
+
+	return stdout, nil
+}
+
+// getPgBouncerImage returns the image of the connection pooler of the spec or,
+// if there is none, the pgBouncer image that matches the image tag of the
+// cluster, taking any override of the image into account
+func getPgBouncerImage(cluster *crv1.Pgcluster) string {
+	if cluster.Spec.PgBouncer.Image != "" {
+		return cluster.Spec.PgBouncer.Image
+	}
+
+	container := v1.Container{
+		Image: fmt.Sprintf("%s/%s:%s", operator.Pgo.Cluster.CCPImagePrefix,
+			config.CONTAINER_IMAGE_CRUNCHY_PGBOUNCER, cluster.Spec.CCPImageTag),
+	}
+
+	operator.SetContainerImageOverride(config.CONTAINER_IMAGE_CRUNCHY_PGBOUNCER, &container)
+
+	return container.Image
+}
+
+// getPgBouncerPods returns the running Pods of a pgBouncer Deployment
+func getPgBouncerPods(clientset *kubernetes.Clientset, namespace, deploymentName string) ([]v1.Pod, error) {
+	selector := fmt.Sprintf("%s=%s", config.LABEL_NAME, deploymentName)
+
+	pods, err := kubeapi.GetPods(clientset, selector, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	running := []v1.Pod{}
+	for _, pod := range pods.Items {
+		if pod.Status.Phase == v1.PodRunning && pod.ObjectMeta.DeletionTimestamp == nil {
+			running = append(running, pod)
+		}
+	}
+
+	return running, nil
+}
+
+// waitForPgBouncerRollout waits for all of the Pods of a pgBouncer Deployment
+// to be updated to its latest template and to be ready
+func waitForPgBouncerRollout(clientset *kubernetes.Clientset, namespace, deploymentName string) error {
+	timeout := time.After(pgBouncerRolloutTimeout * time.Second)
+	tick := time.Tick(pgBouncerRolloutPeriod * time.Second)
+
+	for {
+		select {
+		case <-timeout:
+			return fmt.Errorf("timed out waiting for pgbouncer deployment %s to roll out", deploymentName)
+		case <-tick:
+			deployment, found, err := kubeapi.GetDeployment(clientset, deploymentName, namespace)
+			if !found {
+				log.Error(err)
+				continue
+			}
+
+			replicas := int32(1)
+			if deployment.Spec.Replicas != nil {
+				replicas = *deployment.Spec.Replicas
+			}
+
+			if deployment.Status.ObservedGeneration >= deployment.Generation &&
+				deployment.Status.UpdatedReplicas == replicas &&
+				deployment.Status.ReadyReplicas == replicas &&
+				deployment.Status.Replicas == replicas {
+				return nil
+			}
+		}
+	}
+}
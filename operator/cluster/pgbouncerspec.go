@@ -0,0 +1,248 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/config"
+	"github.com/crunchydata/postgres-operator/kubeapi"
+	log "github.com/sirupsen/logrus"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+var (
+	// pgBouncerDefaultSettings are the settings of pgbouncer.ini that apply unless the spec of the
+	// cluster sets them otherwise
+	pgBouncerDefaultSettings = map[string]string{
+		"min_pool_size":             "0",
+		"reserve_pool_size":         "0",
+		"reserve_pool_timeout":      "5",
+		"query_timeout":             "0",
+		"ignore_startup_parameters": "extra_float_digits",
+	}
+
+	// pgBouncerManagedSettings are the settings of pgbouncer.ini that the Operator manages itself,
+	// either as they are needed for pgBouncer to work within the cluster, or as they have their
+	// own field in the spec. The settings of TLS, which start with "client_tls_" or
+	// "server_tls_", are managed as well
+	pgBouncerManagedSettings = map[string]bool{
+		"listen_port":        true,
+		"listen_addr":        true,
+		"unix_socket_dir":    true,
+		"auth_type":          true,
+		"auth_file":          true,
+		"auth_query":         true,
+		"auth_user":          true,
+		"pidfile":            true,
+		"logfile":            true,
+		"admin_users":        true,
+		"stats_users":        true,
+		"default_pool_size":  true,
+		"max_db_connections": true,
+		"max_client_conn":    true,
+		"pool_mode":          true,
+	}
+
+	// pgBouncerSettingName is what the name of a setting of pgbouncer.ini looks like
+	pgBouncerSettingName = regexp.MustCompile(`^[a-z_]+$`)
+)
+
+// ValidatePgBouncer returns an error if the pgBouncer spec of a cluster cannot be applied, i.e.
+// its connection pooler or pool mode is not known, Odyssey is run without an image or with a
+// graceful rollout, it has a negative number of replicas or clients, or it sets a setting that
+// the Operator manages or that cannot be written to the configuration
+func ValidatePgBouncer(cluster *crv1.Pgcluster) error {
+	spec := cluster.Spec.PgBouncer
+
+	switch spec.GetPooler() {
+	case crv1.PoolerPgBouncer:
+	case crv1.PoolerOdyssey:
+		if spec.Image == "" {
+			return fmt.Errorf("the %s connection pooler requires an image", crv1.PoolerOdyssey)
+		}
+
+		// the Pods are drained with the admin console of pgBouncer
+		if spec.GracefulRollout {
+			return fmt.Errorf("a graceful rollout is not supported by the %s connection pooler",
+				crv1.PoolerOdyssey)
+		}
+	default:
+		return fmt.Errorf("invalid connection pooler %q, must be %q or %q", spec.Pooler,
+			crv1.PoolerPgBouncer, crv1.PoolerOdyssey)
+	}
+
+	switch spec.GetPoolMode() {
+	case crv1.PgBouncerPoolModeSession, crv1.PgBouncerPoolModeTransaction, crv1.PgBouncerPoolModeStatement:
+	default:
+		return fmt.Errorf("invalid pgBouncer pool mode %q, must be %q, %q or %q", spec.PoolMode,
+			crv1.PgBouncerPoolModeSession, crv1.PgBouncerPoolModeTransaction, crv1.PgBouncerPoolModeStatement)
+	}
+
+	if spec.Replicas < 0 || spec.MaxClientConn < 0 {
+		return fmt.Errorf("invalid pgBouncer replicas %d and max client connections %d, they cannot be negative",
+			spec.Replicas, spec.MaxClientConn)
+	}
+
+	for name, value := range spec.Config {
+		if !pgBouncerSettingName.MatchString(name) || strings.ContainsAny(value, "\r\n") {
+			return fmt.Errorf("invalid pgBouncer setting %q", name)
+		}
+
+		if getConnectionPooler(cluster).isManagedSetting(name) {
+			return fmt.Errorf("pgBouncer setting %q is managed by the Operator", name)
+		}
+	}
+
+	return nil
+}
+
+// ReconcilePgBouncer brings the pgBouncer of a cluster in line with its spec: the pgBouncer
+// Deployments are scaled to its replicas, the configuration is regenerated from its pool mode,
+// client connections and settings and reloaded, the read-only pgBouncer is added or removed,
+// and the Deployments are rolled out again if their image, resources or keypair changed. As this
+// waits for the pgBouncer Pods, it is expected to be run in the background
+func ReconcilePgBouncer(clientset *kubernetes.Clientset, restconfig *rest.Config, cluster *crv1.Pgcluster) error {
+	if cluster.Labels[config.LABEL_PGBOUNCER] != "true" {
+		return nil
+	}
+
+	if err := ValidatePgBouncer(cluster); err != nil {
+		return err
+	}
+
+	// this regenerates the configuration as well, which the read-only pgBouncer needs to be in
+	// place before it is added
+	if err := ReconcilePgBouncerReplicas(clientset, restconfig, cluster); err != nil {
+		return err
+	}
+
+	if err := reconcilePgBouncerReadOnly(clientset, cluster); err != nil {
+		return err
+	}
+
+	// the image of the spec may have changed
+	if err := UpdatePgBouncerImage(clientset, restconfig, cluster); err != nil {
+		return err
+	}
+
+	for _, pool := range getPgBouncerPools(cluster) {
+		if err := updatePgBouncerDeployment(clientset, cluster, pool); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// updatePgBouncerDeployment rolls out a pgBouncer Deployment of a cluster again if the
+// resources, the volumes or the scheduling that follow from the spec of the cluster changed
+// since it was last rolled out. The image is left as is, as it is rolled out by UpdatePgBouncerImage
+func updatePgBouncerDeployment(clientset *kubernetes.Clientset, cluster *crv1.Pgcluster, pool pgBouncerPool) error {
+	deployment, found, err := kubeapi.GetDeployment(clientset, pool.name, cluster.Namespace)
+	if !found {
+		return err
+	}
+
+	desired, err := generatePgBouncerDeployment(clientset, cluster, pool)
+	if err != nil {
+		return err
+	}
+
+	hash := desired.Spec.Template.Annotations[config.ANNOTATION_PGBOUNCER_SPEC_HASH]
+
+	if deployment.Spec.Template.Annotations[config.ANNOTATION_PGBOUNCER_SPEC_HASH] == hash {
+		return nil
+	}
+
+	log.Debugf("pgbouncer: rolling out %s with its updated spec", pool.name)
+
+	template := &deployment.Spec.Template
+	container := &template.Spec.Containers[0]
+
+	container.Resources = desired.Spec.Template.Spec.Containers[0].Resources
+	container.VolumeMounts = desired.Spec.Template.Spec.Containers[0].VolumeMounts
+	template.Spec.Volumes = desired.Spec.Template.Spec.Volumes
+	template.Spec.Affinity = desired.Spec.Template.Spec.Affinity
+	template.Spec.Tolerations = desired.Spec.Template.Spec.Tolerations
+	template.Spec.PriorityClassName = desired.Spec.Template.Spec.PriorityClassName
+
+	if template.Annotations == nil {
+		template.Annotations = map[string]string{}
+	}
+
+	template.Annotations[config.ANNOTATION_PGBOUNCER_SPEC_HASH] = hash
+
+	// the keypair may have been added or removed along with the volume it is mounted from
+	if tlsHash, ok := desired.Spec.Template.Annotations[config.ANNOTATION_TLS_CERT_HASH]; ok {
+		template.Annotations[config.ANNOTATION_TLS_CERT_HASH] = tlsHash
+	} else {
+		delete(template.Annotations, config.ANNOTATION_TLS_CERT_HASH)
+	}
+
+	return kubeapi.UpdateDeployment(clientset, deployment)
+}
+
+// getPgBouncerDeploymentHash returns the hash of the parts of a pgBouncer Deployment that follow
+// from the spec of the cluster and are rolled out by updatePgBouncerDeployment
+func getPgBouncerDeploymentHash(deployment *appsv1.Deployment) string {
+	container := deployment.Spec.Template.Spec.Containers[0]
+	spec := deployment.Spec.Template.Spec
+
+	// these are plain structs, which always marshal
+	data, _ := json.Marshal([]interface{}{
+		container.Resources,
+		container.VolumeMounts,
+		spec.Volumes,
+		spec.Affinity,
+		spec.Tolerations,
+		spec.PriorityClassName,
+	})
+
+	return fmt.Sprintf("%x", sha256.Sum256(data))
+}
+
+// getPgBouncerSettings returns the settings of the "[pgbouncer]" section of pgbouncer.ini that
+// are not managed by the Operator, i.e. the defaults along with the settings of the spec, as
+// sorted "key = value" lines
+func getPgBouncerSettings(spec crv1.PgBouncerSpec) []string {
+	settings := map[string]string{}
+
+	for name, value := range pgBouncerDefaultSettings {
+		settings[name] = value
+	}
+
+	for name, value := range spec.Config {
+		settings[name] = strings.TrimSpace(value)
+	}
+
+	lines := make([]string, 0, len(settings))
+	for name, value := range settings {
+		lines = append(lines, fmt.Sprintf("%s = %s", name, value))
+	}
+
+	sort.Strings(lines)
+
+	return lines
+}
@@ -0,0 +1,85 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"reflect"
+	"testing"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+)
+
+func TestGetPgBouncerSettings(t *testing.T) {
+	spec := crv1.PgBouncerSpec{Config: map[string]string{
+		"query_timeout":       " 30 ",
+		"server_idle_timeout": "60",
+	}}
+
+	expected := []string{
+		"ignore_startup_parameters = extra_float_digits",
+		"min_pool_size = 0",
+		"query_timeout = 30",
+		"reserve_pool_size = 0",
+		"reserve_pool_timeout = 5",
+		"server_idle_timeout = 60",
+	}
+
+	if settings := getPgBouncerSettings(spec); !reflect.DeepEqual(settings, expected) {
+		t.Fatalf("expected %v, got %v", expected, settings)
+	}
+}
+
+func TestValidatePgBouncer(t *testing.T) {
+	tests := []struct {
+		name  string
+		spec  crv1.PgBouncerSpec
+		valid bool
+	}{
+		{"defaults", crv1.PgBouncerSpec{}, true},
+		{"statement mode", crv1.PgBouncerSpec{PoolMode: crv1.PgBouncerPoolModeStatement}, true},
+		{"unknown pool mode", crv1.PgBouncerSpec{PoolMode: "Session"}, false},
+		{"negative clients", crv1.PgBouncerSpec{MaxClientConn: -1}, false},
+		{"setting", crv1.PgBouncerSpec{Config: map[string]string{"server_lifetime": "3600"}}, true},
+		{"setting with a new line", crv1.PgBouncerSpec{Config: map[string]string{
+			"server_lifetime": "3600\nlisten_port = 5432",
+		}}, false},
+		{"setting in another section", crv1.PgBouncerSpec{Config: map[string]string{"[databases]": ""}}, false},
+		{"managed setting", crv1.PgBouncerSpec{Config: map[string]string{"pool_mode": "transaction"}}, false},
+		{"TLS setting", crv1.PgBouncerSpec{Config: map[string]string{"client_tls_sslmode": "disable"}}, false},
+		{"unknown pooler", crv1.PgBouncerSpec{Pooler: "pgpool"}, false},
+		{"odyssey", crv1.PgBouncerSpec{Pooler: crv1.PoolerOdyssey, Image: "odyssey:1.1"}, true},
+		{"odyssey without an image", crv1.PgBouncerSpec{Pooler: crv1.PoolerOdyssey}, false},
+		{"odyssey rolled out gracefully", crv1.PgBouncerSpec{
+			Pooler: crv1.PoolerOdyssey, Image: "odyssey:1.1", GracefulRollout: true,
+		}, false},
+		{"odyssey setting", crv1.PgBouncerSpec{
+			Pooler: crv1.PoolerOdyssey, Image: "odyssey:1.1", Config: map[string]string{"workers": `"auto"`},
+		}, true},
+		{"managed odyssey setting", crv1.PgBouncerSpec{
+			Pooler: crv1.PoolerOdyssey, Image: "odyssey:1.1", Config: map[string]string{"listen": "{}"},
+		}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cluster := &crv1.Pgcluster{Spec: crv1.PgclusterSpec{PgBouncer: test.spec}}
+
+			if err := ValidatePgBouncer(cluster); (err == nil) != test.valid {
+				t.Fatalf("expected valid to be %t, got %v", test.valid, err)
+			}
+		})
+	}
+}
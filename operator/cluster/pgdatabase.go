@@ -0,0 +1,193 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/kubeapi"
+	"github.com/crunchydata/postgres-operator/util"
+	log "github.com/sirupsen/logrus"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// sqlPgdatabaseEncoding returns the encoding of the database, or nothing if it does not exist
+const sqlPgdatabaseEncoding = `SELECT pg_catalog.pg_encoding_to_char(encoding) FROM pg_catalog.pg_database WHERE datname = %s;`
+
+// GetPgdatabaseName returns the name of the database of a pgdatabase, which is the name of the
+// pgdatabase unless it is set
+func GetPgdatabaseName(database *crv1.Pgdatabase) string {
+	if database.Spec.Name != "" {
+		return database.Spec.Name
+	}
+
+	return database.Name
+}
+
+// ValidatePgdatabase returns an error if the database of a pgdatabase cannot be kept as its spec
+// describes
+func ValidatePgdatabase(database *crv1.Pgdatabase) error {
+	if database.Spec.ClusterName == "" {
+		return errors.New("the cluster of the database is not set")
+	}
+
+	// the templates are what every database is created from, and the Operator leaves them be
+	switch name := GetPgdatabaseName(database); name {
+	case "template0", "template1":
+		return fmt.Errorf("%q is a template database and cannot be managed by a pgdatabase", name)
+	}
+
+	for _, extension := range database.Spec.Extensions {
+		if extension == "" {
+			return errors.New("the name of an extension of the database is not set")
+		}
+	}
+
+	for _, schema := range database.Spec.Schemas {
+		if schema == "" {
+			return errors.New("the name of a schema of the database is not set")
+		}
+	}
+
+	return nil
+}
+
+// ReconcilePgdatabase creates or updates the database of a pgdatabase in the primary of its
+// cluster so that it matches the spec of the pgdatabase: the database is created if it does not
+// exist, it is given to its owner, and the extensions and schemas of the spec are created in it
+// if they do not exist. Nothing is dropped, so reconciling a pgdatabase again changes nothing
+// once it matches its spec. The encoding of a database that exists cannot be changed, so a
+// pgdatabase that asks for another one fails
+func ReconcilePgdatabase(clientset *kubernetes.Clientset, restclient *rest.RESTClient, restconfig *rest.Config,
+	database *crv1.Pgdatabase) error {
+	if err := ValidatePgdatabase(database); err != nil {
+		return err
+	}
+
+	name := GetPgdatabaseName(database)
+
+	cluster := crv1.Pgcluster{}
+	if found, err := kubeapi.Getpgcluster(restclient, &cluster, database.Spec.ClusterName,
+		database.Namespace); !found {
+		return fmt.Errorf("cluster %s not found: %v", database.Spec.ClusterName, err)
+	}
+
+	if cluster.Status.State != crv1.PgclusterStateInitialized {
+		return fmt.Errorf("cluster %s is not initialized, its state is %q", cluster.Name,
+			cluster.Status.State)
+	}
+
+	// the databases of a standby cluster are those of the cluster it replays the WAL of
+	if cluster.Spec.Standby {
+		return fmt.Errorf("cluster %s is a standby cluster, its databases cannot be changed", cluster.Name)
+	}
+
+	pod, err := util.GetPrimaryPod(clientset, &cluster)
+	if err != nil {
+		return err
+	}
+
+	encoding, err := execMaintenanceSQL(clientset, restconfig, pod, "postgres",
+		fmt.Sprintf(sqlPgdatabaseEncoding, util.SQLQuoteLiteral(name)))
+	if err != nil {
+		return err
+	}
+
+	// a database cannot be created in a transaction, so it is created on its own before what is
+	// created in it
+	if encoding == "" {
+		log.Infof("pgdatabase: creating database %s in cluster %s", name, cluster.Name)
+
+		if _, err := execMaintenanceSQL(clientset, restconfig, pod, "postgres",
+			pgdatabaseCreateSQL(database)); err != nil {
+			return err
+		}
+	} else if !isPgdatabaseEncoding(database.Spec.Encoding, encoding) {
+		return fmt.Errorf("database %s has encoding %s, which cannot be changed to %s", name,
+			encoding, database.Spec.Encoding)
+	}
+
+	log.Debugf("pgdatabase: reconciling database %s in cluster %s", name, cluster.Name)
+
+	return execMaintenanceScript(clientset, restconfig, pod, name, pgdatabaseSQL(database))
+}
+
+// isPgdatabaseEncoding determines whether the encoding of a database is the one in the spec of
+// its pgdatabase, which matches any encoding if it is not set. PostgreSQL accepts the names of
+// encodings in any case and with or without separators, e.g. "utf-8" for "UTF8"
+func isPgdatabaseEncoding(spec, encoding string) bool {
+	if spec == "" {
+		return true
+	}
+
+	normalize := strings.NewReplacer("-", "", "_", "")
+
+	return strings.EqualFold(normalize.Replace(spec), normalize.Replace(encoding))
+}
+
+// pgdatabaseCreateSQL returns the statement that creates the database of a pgdatabase. A database
+// with an encoding of its own is created from "template0", as "template1" can hold data that is
+// only valid in the encoding of the cluster
+func pgdatabaseCreateSQL(database *crv1.Pgdatabase) string {
+	sql := "CREATE DATABASE " + util.SQLQuoteIdentifier(GetPgdatabaseName(database))
+
+	if database.Spec.Owner != "" {
+		sql += " OWNER " + util.SQLQuoteIdentifier(database.Spec.Owner)
+	}
+
+	if database.Spec.Encoding != "" {
+		sql += " TEMPLATE template0 ENCODING " + util.SQLQuoteLiteral(database.Spec.Encoding)
+	}
+
+	return sql + ";"
+}
+
+// pgdatabaseSQL returns the script that has the database of a pgdatabase match its spec, which
+// is run in the database itself
+func pgdatabaseSQL(database *crv1.Pgdatabase) string {
+	name := util.SQLQuoteIdentifier(GetPgdatabaseName(database))
+	owner := ""
+	if database.Spec.Owner != "" {
+		owner = util.SQLQuoteIdentifier(database.Spec.Owner)
+	}
+
+	sql := []string{"BEGIN;"}
+
+	if owner != "" {
+		sql = append(sql, fmt.Sprintf("ALTER DATABASE %s OWNER TO %s;", name, owner))
+	}
+
+	for _, extension := range database.Spec.Extensions {
+		sql = append(sql, fmt.Sprintf("CREATE EXTENSION IF NOT EXISTS %s;",
+			util.SQLQuoteIdentifier(extension)))
+	}
+
+	for _, schema := range database.Spec.Schemas {
+		statement := "CREATE SCHEMA IF NOT EXISTS " + util.SQLQuoteIdentifier(schema)
+		if owner != "" {
+			statement += " AUTHORIZATION " + owner
+		}
+		sql = append(sql, statement+";")
+	}
+
+	sql = append(sql, "COMMIT;")
+
+	return strings.Join(sql, "\n")
+}
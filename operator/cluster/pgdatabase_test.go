@@ -0,0 +1,111 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"strings"
+	"testing"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestValidatePgdatabase(t *testing.T) {
+	for _, test := range []struct {
+		name  string
+		spec  crv1.PgdatabaseSpec
+		valid bool
+	}{
+		{"database", crv1.PgdatabaseSpec{ClusterName: "hippo"}, true},
+		{"no cluster", crv1.PgdatabaseSpec{}, false},
+		{"template", crv1.PgdatabaseSpec{ClusterName: "hippo", Name: "template1"}, false},
+		{"extensions", crv1.PgdatabaseSpec{ClusterName: "hippo", Extensions: []string{"pgaudit"}}, true},
+		{"no extension name", crv1.PgdatabaseSpec{ClusterName: "hippo", Extensions: []string{""}}, false},
+		{"no schema name", crv1.PgdatabaseSpec{ClusterName: "hippo", Schemas: []string{""}}, false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			database := &crv1.Pgdatabase{ObjectMeta: metav1.ObjectMeta{Name: "app"}, Spec: test.spec}
+			if err := ValidatePgdatabase(database); (err == nil) != test.valid {
+				t.Errorf("expected valid: %v, got %v", test.valid, err)
+			}
+		})
+	}
+}
+
+func TestIsPgdatabaseEncoding(t *testing.T) {
+	for _, test := range []struct {
+		spec, encoding string
+		expected       bool
+	}{
+		{"", "SQL_ASCII", true},
+		{"UTF8", "UTF8", true},
+		{"utf-8", "UTF8", true},
+		{"LATIN1", "UTF8", false},
+	} {
+		if actual := isPgdatabaseEncoding(test.spec, test.encoding); actual != test.expected {
+			t.Errorf("expected %q to match %q: %v, got %v", test.spec, test.encoding, test.expected, actual)
+		}
+	}
+}
+
+func TestPgdatabaseSQL(t *testing.T) {
+	database := &crv1.Pgdatabase{
+		ObjectMeta: metav1.ObjectMeta{Name: "app"},
+		Spec: crv1.PgdatabaseSpec{
+			ClusterName: "hippo",
+			Name:        "appdb",
+			Owner:       "app",
+			Encoding:    "UTF8",
+			Extensions:  []string{"pgcrypto"},
+			Schemas:     []string{"app"},
+		},
+	}
+
+	t.Run("create", func(t *testing.T) {
+		expected := `CREATE DATABASE "appdb" OWNER "app" TEMPLATE template0 ENCODING 'UTF8';`
+		if sql := pgdatabaseCreateSQL(database); sql != expected {
+			t.Errorf("expected %s, got %s", expected, sql)
+		}
+	})
+
+	t.Run("reconcile", func(t *testing.T) {
+		expected := strings.Join([]string{
+			`BEGIN;`,
+			`ALTER DATABASE "appdb" OWNER TO "app";`,
+			`CREATE EXTENSION IF NOT EXISTS "pgcrypto";`,
+			`CREATE SCHEMA IF NOT EXISTS "app" AUTHORIZATION "app";`,
+			`COMMIT;`,
+		}, "\n")
+
+		if sql := pgdatabaseSQL(database); sql != expected {
+			t.Errorf("expected:\n%s\ngot:\n%s", expected, sql)
+		}
+	})
+
+	t.Run("no owner", func(t *testing.T) {
+		database := database.DeepCopy()
+		database.Spec.Owner = ""
+		database.Spec.Encoding = ""
+
+		if sql := pgdatabaseCreateSQL(database); sql != `CREATE DATABASE "appdb";` {
+			t.Errorf("expected the database to be created as is, got %s", sql)
+		}
+		if sql := pgdatabaseSQL(database); strings.Contains(sql, "OWNER") ||
+			strings.Contains(sql, "AUTHORIZATION") {
+			t.Errorf("expected no owner, got:\n%s", sql)
+		}
+	})
+}
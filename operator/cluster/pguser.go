@@ -0,0 +1,453 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/config"
+	"github.com/crunchydata/postgres-operator/kubeapi"
+	"github.com/crunchydata/postgres-operator/operator"
+	"github.com/crunchydata/postgres-operator/util"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	// sqlPguserRoleExists returns "t" if the role exists
+	sqlPguserRoleExists = `SELECT EXISTS (SELECT 1 FROM pg_catalog.pg_roles WHERE rolname = %s);`
+
+	// sqlPguserDatabases returns the databases that can be connected to, one per line
+	sqlPguserDatabases = `SELECT datname FROM pg_catalog.pg_database WHERE datallowconn ORDER BY datname;`
+
+	// sqlPguserDropOwned has the objects of a role in a database taken over by the superuser, so
+	// that no data is lost with the role, and revokes what the role was granted in the database
+	sqlPguserDropOwned = `REASSIGN OWNED BY %[1]s TO CURRENT_USER; DROP OWNED BY %[1]s;`
+
+	// sqlPguserDropRole drops a role once nothing in the cluster depends on it anymore
+	sqlPguserDropRole = `DROP ROLE IF EXISTS %s;`
+)
+
+// pguserPrivileges are the privileges a pguser can grant on a database
+var pguserPrivileges = map[string]struct{}{
+	"ALL":       {},
+	"CONNECT":   {},
+	"CREATE":    {},
+	"TEMP":      {},
+	"TEMPORARY": {},
+}
+
+// GetPguserUsername returns the name of the role of a pguser, which is the name of the pguser
+// unless it is set
+func GetPguserUsername(user *crv1.Pguser) string {
+	if user.Spec.Username != "" {
+		return user.Spec.Username
+	}
+
+	return user.Name
+}
+
+// GetPguserSecretName returns the name of the Secret with the credential of the role of a
+// pguser, which follows the naming of the Secrets of the other users of the cluster unless it
+// is set
+func GetPguserSecretName(user *crv1.Pguser) string {
+	if user.Spec.SecretName != "" {
+		return user.Spec.SecretName
+	}
+
+	return fmt.Sprintf("%s-%s%s", user.Spec.ClusterName, GetPguserUsername(user), crv1.UserSecretSuffix)
+}
+
+// ValidatePguser returns an error if the role of a pguser cannot be kept as its spec describes
+func ValidatePguser(user *crv1.Pguser) error {
+	if user.Spec.ClusterName == "" {
+		return errors.New("the cluster of the user is not set")
+	}
+
+	// the users the Operator and the cluster rely on are managed by the Operator itself
+	if username := GetPguserUsername(user); util.IsPostgreSQLUserSystemAccount(username) {
+		return fmt.Errorf("%q is a system account and cannot be managed by a pguser", username)
+	}
+
+	if limit := user.Spec.ConnectionLimit; limit != nil && *limit < -1 {
+		return fmt.Errorf("invalid connection limit %d, must be -1 or more", *limit)
+	}
+
+	for _, database := range user.Spec.Databases {
+		if database.Name == "" {
+			return errors.New("the name of a database of the user is not set")
+		}
+
+		for _, privilege := range database.Privileges {
+			if _, ok := pguserPrivileges[strings.ToUpper(privilege)]; !ok {
+				return fmt.Errorf("invalid privilege %q on database %s, must be CONNECT, CREATE, TEMPORARY or ALL",
+					privilege, database.Name)
+			}
+		}
+	}
+
+	return nil
+}
+
+// ReconcilePguser creates or updates the role of a pguser in the primary of its cluster so that
+// it matches the spec of the pguser. The password of the role is the one in the Secret of the
+// pguser, which is created with a generated password if it does not exist. Privileges on the
+// databases that the pguser no longer lists are revoked. Everything the role is changed with
+// is applied in a single transaction, so the role is either fully reconciled or left as it was
+func ReconcilePguser(clientset *kubernetes.Clientset, restclient *rest.RESTClient, restconfig *rest.Config,
+	store operator.CredentialStore, user *crv1.Pguser) error {
+	if err := ValidatePguser(user); err != nil {
+		return err
+	}
+
+	namespace := user.Namespace
+	username := GetPguserUsername(user)
+
+	cluster := crv1.Pgcluster{}
+	if found, err := kubeapi.Getpgcluster(restclient, &cluster, user.Spec.ClusterName, namespace); !found {
+		return fmt.Errorf("cluster %s not found: %v", user.Spec.ClusterName, err)
+	}
+
+	if cluster.Status.State != crv1.PgclusterStateInitialized {
+		return fmt.Errorf("cluster %s is not initialized, its state is %q", cluster.Name,
+			cluster.Status.State)
+	}
+
+	// the roles of a standby cluster are those of the cluster it replays the WAL of
+	if cluster.Spec.Standby {
+		return fmt.Errorf("cluster %s is a standby cluster, its roles cannot be changed", cluster.Name)
+	}
+
+	credential, err := getPguserCredential(store, &cluster, user)
+	if err != nil {
+		return err
+	}
+
+	pod, err := util.GetPrimaryPod(clientset, &cluster)
+	if err != nil {
+		return err
+	}
+
+	exists, err := execMaintenanceSQL(clientset, restconfig, pod, "postgres",
+		fmt.Sprintf(sqlPguserRoleExists, util.SQLQuoteLiteral(username)))
+	if err != nil {
+		return err
+	}
+
+	databases, err := getPguserDatabases(clientset, restconfig, pod)
+	if err != nil {
+		return err
+	}
+
+	for _, database := range user.Spec.Databases {
+		if _, ok := databases[database.Name]; !ok {
+			return fmt.Errorf("database %s does not exist in cluster %s", database.Name, cluster.Name)
+		}
+	}
+
+	// privileges are only revoked on the databases that still exist, as those that were dropped
+	// took the privileges with them
+	revoke := []string{}
+	for _, database := range getPguserRevokedDatabases(user) {
+		if _, ok := databases[database]; ok {
+			revoke = append(revoke, database)
+		}
+	}
+
+	log.Debugf("pguser: reconciling role %s in cluster %s", username, cluster.Name)
+
+	hashedPassword, err := util.GeneratePostgreSQLPassword(cluster.Spec.GetPasswordEncryption(),
+		username, credential.Password)
+	if err != nil {
+		return err
+	}
+
+	return execMaintenanceScript(clientset, restconfig, pod, "postgres",
+		pguserSQL(user, hashedPassword, exists == "t", revoke))
+}
+
+// DropPguser drops the role of a pguser that is deleted. What the role owns is taken over by the
+// superuser rather than dropped, so that the data of the role is kept. The Secret of the role is
+// removed if it is the one the Operator named, as it is the one it created. There is nothing to
+// drop if the cluster is gone or is being removed itself
+func DropPguser(clientset *kubernetes.Clientset, restclient *rest.RESTClient, restconfig *rest.Config,
+	user *crv1.Pguser) error {
+	namespace := user.Namespace
+	username := GetPguserUsername(user)
+
+	// a role that could not be managed was never created
+	if ValidatePguser(user) != nil {
+		return nil
+	}
+
+	cluster := crv1.Pgcluster{}
+	found, err := kubeapi.Getpgcluster(restclient, &cluster, user.Spec.ClusterName, namespace)
+	if !found && kerrors.IsNotFound(err) {
+		return nil
+	} else if !found {
+		return err
+	}
+
+	if cluster.DeletionTimestamp != nil {
+		return nil
+	}
+
+	pod, err := util.GetPrimaryPod(clientset, &cluster)
+	if err != nil {
+		return err
+	}
+
+	exists, err := execMaintenanceSQL(clientset, restconfig, pod, "postgres",
+		fmt.Sprintf(sqlPguserRoleExists, util.SQLQuoteLiteral(username)))
+	if err != nil {
+		return err
+	}
+
+	if exists == "t" {
+		databases, err := getPguserDatabases(clientset, restconfig, pod)
+		if err != nil {
+			return err
+		}
+
+		// a role cannot be dropped as long as any database has objects or privileges of it
+		for database := range databases {
+			if _, err := execMaintenanceSQL(clientset, restconfig, pod, database,
+				fmt.Sprintf(sqlPguserDropOwned, util.SQLQuoteIdentifier(username))); err != nil {
+				return err
+			}
+		}
+
+		log.Infof("pguser: dropping role %s in cluster %s", username, cluster.Name)
+
+		if _, err := execMaintenanceSQL(clientset, restconfig, pod, "postgres",
+			fmt.Sprintf(sqlPguserDropRole, util.SQLQuoteIdentifier(username))); err != nil {
+			return err
+		}
+	}
+
+	if user.Spec.SecretName != "" {
+		return nil
+	}
+
+	if err := kubeapi.DeleteSecret(clientset, GetPguserSecretName(user), namespace); err != nil &&
+		!kerrors.IsNotFound(err) {
+		return err
+	}
+
+	return nil
+}
+
+// HasDropRoleFinalizer returns whether a pguser is held on to until its role is dropped
+func HasDropRoleFinalizer(user *crv1.Pguser) bool {
+	for _, finalizer := range user.Finalizers {
+		if finalizer == config.FINALIZER_DROP_ROLE {
+			return true
+		}
+	}
+	return false
+}
+
+// AddDropRoleFinalizer adds the drop role finalizer to a pguser that does not have it yet, so
+// that its role is dropped when the pguser is deleted
+func AddDropRoleFinalizer(restclient *rest.RESTClient, user *crv1.Pguser) error {
+	if HasDropRoleFinalizer(user) || user.DeletionTimestamp != nil {
+		return nil
+	}
+
+	finalizers := append(append([]string{}, user.Finalizers...), config.FINALIZER_DROP_ROLE)
+
+	return kubeapi.PatchpguserFinalizers(restclient, finalizers, user, user.Namespace)
+}
+
+// RemoveDropRoleFinalizer removes the drop role finalizer from a pguser, which lets a pguser
+// that is deleted go
+func RemoveDropRoleFinalizer(restclient *rest.RESTClient, user *crv1.Pguser) error {
+	if !HasDropRoleFinalizer(user) {
+		return nil
+	}
+
+	finalizers := []string{}
+	for _, finalizer := range user.Finalizers {
+		if finalizer != config.FINALIZER_DROP_ROLE {
+			finalizers = append(finalizers, finalizer)
+		}
+	}
+
+	return kubeapi.PatchpguserFinalizers(restclient, finalizers, user, user.Namespace)
+}
+
+// GetPguserDatabaseNames returns the names of the databases a pguser grants privileges on, which
+// are recorded on its status once it is reconciled
+func GetPguserDatabaseNames(user *crv1.Pguser) []string {
+	names := []string{}
+	for _, database := range user.Spec.Databases {
+		names = append(names, database.Name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// IsPguserPasswordRotationDue determines whether the password of the role of a pguser is to be
+// rotated, i.e. as the "rotate-password" annotation of the pguser has a value it was not rotated
+// for, or as the rotation interval of the pguser has passed
+func IsPguserPasswordRotationDue(user *crv1.Pguser, now time.Time) bool {
+	if token := user.Annotations[config.ANNOTATION_ROTATE_PASSWORD]; token != "" &&
+		token != user.Status.PasswordRotateToken {
+		return true
+	}
+
+	return isPasswordRotationDue(user.Spec.PasswordRotationIntervalDays, user.Status.LastPasswordRotation,
+		user.CreationTimestamp, now)
+}
+
+// RotatePguserPassword gives the role of a pguser a new generated password in its Secret, which
+// is set on the role as the pguser is reconciled. A role whose Secret does not exist yet is
+// given a generated password as it is reconciled anyway
+func RotatePguserPassword(store operator.CredentialStore, user *crv1.Pguser) error {
+	password := util.GeneratePassword(util.GeneratedPasswordLength(operator.Pgo.Cluster.PasswordLength))
+
+	if _, err := store.Rotate(user.Namespace, GetPguserSecretName(user), password); err != nil &&
+		!operator.IsCredentialNotFound(err) {
+		return err
+	}
+
+	return nil
+}
+
+// getPguserCredential returns the credential of the role of a pguser, creating it with a
+// generated password if it does not exist yet
+func getPguserCredential(store operator.CredentialStore, cluster *crv1.Pgcluster,
+	user *crv1.Pguser) (operator.Credential, error) {
+	namespace := user.Namespace
+	secretName := GetPguserSecretName(user)
+
+	credential, err := store.Get(namespace, secretName)
+	if err == nil || !operator.IsCredentialNotFound(err) {
+		return credential, err
+	}
+
+	log.Debugf("pguser: creating secret %s for role %s", secretName, GetPguserUsername(user))
+
+	credential = operator.Credential{
+		ClusterName: cluster.Spec.Name,
+		Username:    GetPguserUsername(user),
+		Password: util.GeneratePassword(
+			util.GeneratedPasswordLength(operator.Pgo.Cluster.PasswordLength)),
+	}
+
+	return credential, store.Set(namespace, secretName, credential)
+}
+
+// getPguserDatabases returns the databases of a cluster that can be connected to
+func getPguserDatabases(clientset *kubernetes.Clientset, restconfig *rest.Config,
+	pod *v1.Pod) (map[string]struct{}, error) {
+	stdout, err := execMaintenanceSQL(clientset, restconfig, pod, "postgres", sqlPguserDatabases)
+	if err != nil {
+		return nil, err
+	}
+
+	databases := map[string]struct{}{}
+	for _, database := range strings.Split(stdout, "\n") {
+		if database = strings.TrimSpace(database); database != "" {
+			databases[database] = struct{}{}
+		}
+	}
+
+	return databases, nil
+}
+
+// getPguserRevokedDatabases returns the databases a pguser granted privileges on when it was last
+// reconciled that it no longer lists
+func getPguserRevokedDatabases(user *crv1.Pguser) []string {
+	listed := map[string]struct{}{}
+	for _, database := range user.Spec.Databases {
+		listed[database.Name] = struct{}{}
+	}
+
+	revoked := []string{}
+	for _, database := range user.Status.Databases {
+		if _, ok := listed[database]; !ok {
+			revoked = append(revoked, database)
+		}
+	}
+
+	return revoked
+}
+
+// pguserSQL returns the script that has the role of a pguser match its spec: the role is created
+// if it does not exist, its options and password are set, and the privileges on every database
+// it lists are set to exactly those in the spec
+func pguserSQL(user *crv1.Pguser, hashedPassword string, exists bool, revoke []string) string {
+	role := util.SQLQuoteIdentifier(GetPguserUsername(user))
+
+	option := func(enabled bool, name string) string {
+		if enabled {
+			return name
+		}
+		return "NO" + name
+	}
+
+	limit := -1
+	if user.Spec.ConnectionLimit != nil {
+		limit = *user.Spec.ConnectionLimit
+	}
+
+	sql := []string{"BEGIN;"}
+
+	if !exists {
+		sql = append(sql, fmt.Sprintf("CREATE ROLE %s;", role))
+	}
+
+	sql = append(sql, fmt.Sprintf("ALTER ROLE %s %s %s %s %s CONNECTION LIMIT %s PASSWORD %s;", role,
+		option(user.Spec.Login, "LOGIN"), option(user.Spec.Superuser, "SUPERUSER"),
+		option(user.Spec.CreateDB, "CREATEDB"), option(user.Spec.CreateRole, "CREATEROLE"),
+		strconv.Itoa(limit), util.SQLQuoteLiteral(hashedPassword)))
+
+	for _, database := range revoke {
+		sql = append(sql, fmt.Sprintf("REVOKE ALL ON DATABASE %s FROM %s;",
+			util.SQLQuoteIdentifier(database), role))
+	}
+
+	for _, database := range user.Spec.Databases {
+		name := util.SQLQuoteIdentifier(database.Name)
+
+		sql = append(sql, fmt.Sprintf("REVOKE ALL ON DATABASE %s FROM %s;", name, role))
+
+		if len(database.Privileges) != 0 {
+			privileges := []string{}
+			for _, privilege := range database.Privileges {
+				privileges = append(privileges, strings.ToUpper(privilege))
+			}
+
+			sql = append(sql, fmt.Sprintf("GRANT %s ON DATABASE %s TO %s;",
+				strings.Join(privileges, ", "), name, role))
+		}
+	}
+
+	sql = append(sql, "COMMIT;")
+
+	return strings.Join(sql, "\n")
+}
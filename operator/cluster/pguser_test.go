@@ -0,0 +1,135 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestValidatePguser(t *testing.T) {
+	limit := func(limit int) *int { return &limit }
+
+	for _, test := range []struct {
+		name  string
+		spec  crv1.PguserSpec
+		valid bool
+	}{
+		{"user", crv1.PguserSpec{ClusterName: "hippo"}, true},
+		{"no cluster", crv1.PguserSpec{}, false},
+		{"system account", crv1.PguserSpec{ClusterName: "hippo", Username: "postgres"}, false},
+		{"unlimited connections", crv1.PguserSpec{ClusterName: "hippo", ConnectionLimit: limit(-1)}, true},
+		{"invalid connection limit", crv1.PguserSpec{ClusterName: "hippo", ConnectionLimit: limit(-2)}, false},
+		{"privileges", crv1.PguserSpec{ClusterName: "hippo", Databases: []crv1.PguserDatabaseSpec{
+			{Name: "app", Privileges: []string{"connect", "TEMPORARY"}},
+		}}, true},
+		{"invalid privilege", crv1.PguserSpec{ClusterName: "hippo", Databases: []crv1.PguserDatabaseSpec{
+			{Name: "app", Privileges: []string{"SELECT"}},
+		}}, false},
+		{"no database name", crv1.PguserSpec{ClusterName: "hippo", Databases: []crv1.PguserDatabaseSpec{
+			{Privileges: []string{"CONNECT"}},
+		}}, false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			user := &crv1.Pguser{ObjectMeta: metav1.ObjectMeta{Name: "app"}, Spec: test.spec}
+			if err := ValidatePguser(user); (err == nil) != test.valid {
+				t.Errorf("expected valid: %v, got %v", test.valid, err)
+			}
+		})
+	}
+}
+
+func TestGetPguserSecretName(t *testing.T) {
+	user := &crv1.Pguser{
+		ObjectMeta: metav1.ObjectMeta{Name: "app"},
+		Spec:       crv1.PguserSpec{ClusterName: "hippo"},
+	}
+
+	if name := GetPguserSecretName(user); name != "hippo-app-secret" {
+		t.Errorf("expected secret hippo-app-secret, got %q", name)
+	}
+
+	user.Spec.Username = "appadmin"
+	if name := GetPguserSecretName(user); name != "hippo-appadmin-secret" {
+		t.Errorf("expected secret hippo-appadmin-secret, got %q", name)
+	}
+
+	user.Spec.SecretName = "app-credentials"
+	if name := GetPguserSecretName(user); name != "app-credentials" {
+		t.Errorf("expected secret app-credentials, got %q", name)
+	}
+}
+
+func TestPguserSQL(t *testing.T) {
+	limit := 10
+	user := &crv1.Pguser{
+		ObjectMeta: metav1.ObjectMeta{Name: "app"},
+		Spec: crv1.PguserSpec{
+			ClusterName:     "hippo",
+			Login:           true,
+			CreateDB:        true,
+			ConnectionLimit: &limit,
+			Databases: []crv1.PguserDatabaseSpec{
+				{Name: "app", Privileges: []string{"connect", "create"}},
+				{Name: "reports"},
+			},
+		},
+	}
+
+	t.Run("new role", func(t *testing.T) {
+		expected := strings.Join([]string{
+			`BEGIN;`,
+			`CREATE ROLE "app";`,
+			`ALTER ROLE "app" LOGIN NOSUPERUSER CREATEDB NOCREATEROLE CONNECTION LIMIT 10 PASSWORD 'md5abc';`,
+			`REVOKE ALL ON DATABASE "app" FROM "app";`,
+			`GRANT CONNECT, CREATE ON DATABASE "app" TO "app";`,
+			`REVOKE ALL ON DATABASE "reports" FROM "app";`,
+			`COMMIT;`,
+		}, "\n")
+
+		if sql := pguserSQL(user, "md5abc", false, nil); sql != expected {
+			t.Errorf("expected:\n%s\ngot:\n%s", expected, sql)
+		}
+	})
+
+	t.Run("existing role", func(t *testing.T) {
+		sql := pguserSQL(user, "md5abc", true, []string{"old"})
+
+		if strings.Contains(sql, "CREATE ROLE") {
+			t.Errorf("expected the role not to be created, got:\n%s", sql)
+		}
+		if !strings.Contains(sql, `REVOKE ALL ON DATABASE "old" FROM "app";`) {
+			t.Errorf("expected privileges on old to be revoked, got:\n%s", sql)
+		}
+	})
+}
+
+func TestGetPguserRevokedDatabases(t *testing.T) {
+	user := &crv1.Pguser{
+		Spec: crv1.PguserSpec{Databases: []crv1.PguserDatabaseSpec{{Name: "app"}}},
+		Status: crv1.PguserStatus{
+			Databases: []string{"app", "old"},
+		},
+	}
+
+	if revoked := getPguserRevokedDatabases(user); !reflect.DeepEqual(revoked, []string{"old"}) {
+		t.Errorf("expected [old], got %v", revoked)
+	}
+}
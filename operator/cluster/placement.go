@@ -0,0 +1,80 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"fmt"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/config"
+	"github.com/crunchydata/postgres-operator/kubeapi"
+	"github.com/crunchydata/postgres-operator/operator"
+	log "github.com/sirupsen/logrus"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// ReconcileReplicaPlacement records where a replica runs: its Deployment is labeled with the
+// availability zone of its node, and the node and the zone are recorded in the status of its
+// pgreplica. A replica that is not scheduled yet is left as it is
+func ReconcileReplicaPlacement(clientset *kubernetes.Clientset, restclient *rest.RESTClient,
+	replica *crv1.Pgreplica) error {
+	selector := fmt.Sprintf("%s=%s", config.LABEL_DEPLOYMENT_NAME, replica.Spec.Name)
+
+	pods, err := kubeapi.GetPods(clientset, selector, replica.Namespace)
+	if err != nil {
+		return err
+	}
+
+	nodeName := ""
+	for _, pod := range pods.Items {
+		if pod.Spec.NodeName != "" && pod.DeletionTimestamp == nil {
+			nodeName = pod.Spec.NodeName
+			break
+		}
+	}
+
+	if nodeName == "" {
+		return nil
+	}
+
+	node, found, err := kubeapi.GetNode(clientset, nodeName)
+	if !found {
+		return err
+	}
+
+	zone := operator.GetNodeZone(node)
+
+	deployment, found, err := kubeapi.GetDeployment(clientset, replica.Spec.Name, replica.Namespace)
+	if !found {
+		return err
+	}
+
+	if zone != "" && deployment.Labels[config.LABEL_ZONE] != zone {
+		log.Debugf("labeling deployment %s with zone %s", deployment.Name, zone)
+
+		if err := kubeapi.AddLabelToDeployment(clientset, deployment, config.LABEL_ZONE, zone,
+			replica.Namespace); err != nil {
+			return err
+		}
+	}
+
+	if replica.Status.Node == nodeName && replica.Status.Zone == zone {
+		return nil
+	}
+
+	return kubeapi.PatchpgreplicaPlacement(restclient, nodeName, zone, replica, replica.Namespace)
+}
@@ -0,0 +1,169 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"fmt"
+	"reflect"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/config"
+	"github.com/crunchydata/postgres-operator/kubeapi"
+	log "github.com/sirupsen/logrus"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	// pdbPrimarySuffix and pdbReplicaSuffix are appended to the name of a cluster to name the
+	// PodDisruptionBudgets of its primary and of its replicas
+	pdbPrimarySuffix = "-primary"
+	pdbReplicaSuffix = "-replica"
+
+	// pdbPrimaryRole is the role that Patroni labels the primary with
+	pdbPrimaryRole = "master"
+)
+
+// ReconcilePodDisruptionBudgets creates or updates the PodDisruptionBudgets of a cluster while
+// they are enabled, and removes them once they are not. The budget of the replicas keeps all but
+// one of them available, so it is only created once there are at least two replicas, and it is
+// resized as replicas are added and removed
+func ReconcilePodDisruptionBudgets(clientset *kubernetes.Clientset, restclient *rest.RESTClient,
+	cluster *crv1.Pgcluster) error {
+	if !cluster.Spec.PodDisruptionBudget.Enabled {
+		return DeletePodDisruptionBudgets(clientset, cluster)
+	}
+
+	if err := reconcilePodDisruptionBudget(clientset, cluster, getPrimaryPodDisruptionBudget(cluster)); err != nil {
+		return err
+	}
+
+	replicas, err := getPodDisruptionBudgetReplicas(restclient, cluster)
+	if err != nil {
+		return err
+	}
+
+	if replicas < 2 {
+		if err := kubeapi.DeletePodDisruptionBudget(clientset, cluster.Name+pdbReplicaSuffix,
+			cluster.Namespace); err != nil && !kerrors.IsNotFound(err) {
+			return err
+		}
+		return nil
+	}
+
+	return reconcilePodDisruptionBudget(clientset, cluster, getReplicaPodDisruptionBudget(cluster, replicas))
+}
+
+// DeletePodDisruptionBudgets removes the PodDisruptionBudgets of a cluster, if it has any
+func DeletePodDisruptionBudgets(clientset *kubernetes.Clientset, cluster *crv1.Pgcluster) error {
+	for _, suffix := range []string{pdbPrimarySuffix, pdbReplicaSuffix} {
+		if err := kubeapi.DeletePodDisruptionBudget(clientset, cluster.Name+suffix,
+			cluster.Namespace); err != nil && !kerrors.IsNotFound(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// getPodDisruptionBudgetReplicas returns the number of replicas of a cluster, not counting those
+// that are being removed
+func getPodDisruptionBudgetReplicas(restclient *rest.RESTClient, cluster *crv1.Pgcluster) (int, error) {
+	replicas := crv1.PgreplicaList{}
+	selector := fmt.Sprintf("%s=%s", config.LABEL_PG_CLUSTER, cluster.Name)
+
+	if err := kubeapi.GetpgreplicasBySelector(restclient, &replicas, selector, cluster.Namespace); err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, replica := range replicas.Items {
+		if replica.DeletionTimestamp == nil {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+// getPodDisruptionBudget returns a PodDisruptionBudget of a cluster that keeps a number of the
+// instances of a role available
+func getPodDisruptionBudget(cluster *crv1.Pgcluster, suffix, role string,
+	minAvailable int) *policyv1beta1.PodDisruptionBudget {
+	available := intstr.FromInt(minAvailable)
+
+	return &policyv1beta1.PodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: cluster.Name + suffix,
+			Labels: map[string]string{
+				config.LABEL_VENDOR:     config.LABEL_CRUNCHY,
+				config.LABEL_PG_CLUSTER: cluster.Name,
+			},
+		},
+		Spec: policyv1beta1.PodDisruptionBudgetSpec{
+			MinAvailable: &available,
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					config.LABEL_PG_CLUSTER: cluster.Name,
+					config.LABEL_PGHA_ROLE:  role,
+				},
+			},
+		},
+	}
+}
+
+// getPrimaryPodDisruptionBudget returns the PodDisruptionBudget of the primary of a cluster, which
+// keeps it from being evicted. The primary is found by its role, so the budget follows it through
+// a failover
+func getPrimaryPodDisruptionBudget(cluster *crv1.Pgcluster) *policyv1beta1.PodDisruptionBudget {
+	return getPodDisruptionBudget(cluster, pdbPrimarySuffix, pdbPrimaryRole, 1)
+}
+
+// getReplicaPodDisruptionBudget returns the PodDisruptionBudget of the replicas of a cluster, which
+// lets one of them be evicted at a time
+func getReplicaPodDisruptionBudget(cluster *crv1.Pgcluster, replicas int) *policyv1beta1.PodDisruptionBudget {
+	return getPodDisruptionBudget(cluster, pdbReplicaSuffix, config.LABEL_PGHA_ROLE_REPLICA, replicas-1)
+}
+
+// reconcilePodDisruptionBudget creates a PodDisruptionBudget of a cluster, or updates it if it
+// does not match the spec of the cluster
+func reconcilePodDisruptionBudget(clientset *kubernetes.Clientset, cluster *crv1.Pgcluster,
+	desired *policyv1beta1.PodDisruptionBudget) error {
+	current, found, err := kubeapi.GetPodDisruptionBudget(clientset, desired.Name, cluster.Namespace)
+	if err != nil && !kerrors.IsNotFound(err) {
+		return err
+	}
+
+	if !found {
+		log.Debugf("creating poddisruptionbudget %s for cluster %s", desired.Name, cluster.Name)
+		return kubeapi.CreatePodDisruptionBudget(clientset, desired, cluster.Namespace)
+	}
+
+	if reflect.DeepEqual(current.Spec, desired.Spec) && reflect.DeepEqual(current.Labels, desired.Labels) {
+		return nil
+	}
+
+	log.Debugf("updating poddisruptionbudget %s of cluster %s", desired.Name, cluster.Name)
+
+	current.Labels = desired.Labels
+	current.Spec = desired.Spec
+
+	return kubeapi.UpdatePodDisruptionBudget(clientset, current, cluster.Namespace)
+}
@@ -0,0 +1,50 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"testing"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/config"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGetPodDisruptionBudgets(t *testing.T) {
+	cluster := &crv1.Pgcluster{ObjectMeta: metav1.ObjectMeta{Name: "hippo", Namespace: "pgo"}}
+
+	primary := getPrimaryPodDisruptionBudget(cluster)
+	if primary.Name != "hippo-primary" || primary.Spec.MinAvailable.IntValue() != 1 {
+		t.Fatalf("expected the primary to be kept available, got %s %v", primary.Name, primary.Spec.MinAvailable)
+	}
+	if selector := primary.Spec.Selector.MatchLabels; selector[config.LABEL_PG_CLUSTER] != "hippo" ||
+		selector[config.LABEL_PGHA_ROLE] != "master" {
+		t.Fatalf("expected the primary to be selected by its role, got %v", selector)
+	}
+
+	// all but one of the replicas are kept available
+	for replicas, expected := range map[int]int{2: 1, 3: 2, 5: 4} {
+		pdb := getReplicaPodDisruptionBudget(cluster, replicas)
+
+		if pdb.Name != "hippo-replica" || pdb.Spec.MinAvailable.IntValue() != expected {
+			t.Errorf("expected %d of %d replicas to be kept available, got %v", expected, replicas,
+				pdb.Spec.MinAvailable)
+		}
+		if pdb.Spec.Selector.MatchLabels[config.LABEL_PGHA_ROLE] != config.LABEL_PGHA_ROLE_REPLICA {
+			t.Errorf("expected the replicas to be selected by their role, got %v", pdb.Spec.Selector)
+		}
+	}
+}
@@ -0,0 +1,228 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/config"
+	"github.com/crunchydata/postgres-operator/kubeapi"
+	"github.com/crunchydata/postgres-operator/operator"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// connectionPooler is one of the connection poolers that a cluster can be pooled through. Each
+// of them is run from the pgBouncer Secret, and is reached through the pgBouncer Services, so
+// they only differ in how they are configured and run
+type connectionPooler interface {
+	// confKey returns the key of the pgBouncer Secret with the configuration of the pooler that
+	// pools connections to the primary or, if readOnly, to the replicas
+	confKey(readOnly bool) string
+	// confPath is where the pooler finds its configuration in its container
+	confPath() string
+	// deploymentTemplate is the template of the Deployment of the pooler, which is filled in
+	// with PgbouncerTemplateFields
+	deploymentTemplate() *template.Template
+	// generateConf generates the configuration of the pooler, which logs in as the "pgbouncer"
+	// user with the given password
+	generateConf(cluster *crv1.Pgcluster, parameters map[string]string, password string,
+		readOnly bool) ([]byte, error)
+	// isManagedSetting returns whether a setting of the configuration is managed by the
+	// Operator, and so cannot be set in the spec
+	isManagedSetting(name string) bool
+	// reload has a Pod of the pooler load its configuration again
+	reload(clientset *kubernetes.Clientset, restconfig *rest.Config, pod *v1.Pod) error
+}
+
+// OdysseyConfFields are the fields of the odyssey.conf file
+type OdysseyConfFields struct {
+	ServiceName     string
+	Port            string
+	AuthType        string
+	Password        string
+	PoolMode        string
+	DefaultPoolSize int
+	MaxClientConn   int
+	// Settings are the settings of the global section from the spec, as
+	// "key value"
+	Settings   []string
+	TLSEnabled bool
+	TLSOnly    bool
+}
+
+// cmdReloadOdyssey has Odyssey, which runs as the first process of its container, load its
+// configuration again
+var cmdReloadOdyssey = []string{"sh", "-c", "kill -HUP 1"}
+
+// odysseyManagedSettings are the settings of the global section of odyssey.conf that the
+// Operator manages itself, along with the sections that it writes
+var odysseyManagedSettings = map[string]bool{
+	"include":          true,
+	"daemonize":        true,
+	"pid_file":         true,
+	"log_file":         true,
+	"log_to_stdout":    true,
+	"unix_socket_dir":  true,
+	"unix_socket_mode": true,
+	"locks_dir":        true,
+	"client_max":       true,
+	"listen":           true,
+	"storage":          true,
+	"database":         true,
+}
+
+// getConnectionPooler returns the connection pooler that a cluster is pooled through
+func getConnectionPooler(cluster *crv1.Pgcluster) connectionPooler {
+	if cluster.Spec.PgBouncer.GetPooler() == crv1.PoolerOdyssey {
+		return odysseyPooler{}
+	}
+
+	return pgBouncerPooler{}
+}
+
+// pgBouncerPooler runs pgBouncer from the crunchy-pgbouncer image
+type pgBouncerPooler struct{}
+
+func (pgBouncerPooler) confKey(readOnly bool) string {
+	if readOnly {
+		return pgBouncerReadOnlyConfKey
+	}
+
+	return pgBouncerConfKey
+}
+
+func (pgBouncerPooler) confPath() string {
+	return "/pgconf/pgbouncer.ini"
+}
+
+func (pgBouncerPooler) deploymentTemplate() *template.Template {
+	return config.PgbouncerTemplate
+}
+
+func (pgBouncerPooler) generateConf(cluster *crv1.Pgcluster, parameters map[string]string, password string,
+	readOnly bool) ([]byte, error) {
+	// pgBouncer reads the password from "users.txt"
+	return generatePgBouncerConf(cluster, parameters, readOnly)
+}
+
+func (pgBouncerPooler) isManagedSetting(name string) bool {
+	return pgBouncerManagedSettings[name] ||
+		strings.HasPrefix(name, "client_tls_") || strings.HasPrefix(name, "server_tls_")
+}
+
+func (pgBouncerPooler) reload(clientset *kubernetes.Clientset, restconfig *rest.Config, pod *v1.Pod) error {
+	_, err := execPgBouncerAdmin(clientset, restconfig, pod, "RELOAD;", defaultPgBouncerDrainTimeout)
+	return err
+}
+
+// odysseyPooler runs Odyssey from the image of the spec. It looks the passwords of the clients
+// up in the same way as pgBouncer, i.e. with the functions that are installed for the
+// "pgbouncer" user
+type odysseyPooler struct{}
+
+const (
+	// the keys of the pgBouncer Secret with the odyssey.conf of each pgBouncer
+	odysseyConfKey         = "odyssey.conf"
+	odysseyReadOnlyConfKey = "odyssey-ro.conf"
+)
+
+func (odysseyPooler) confKey(readOnly bool) string {
+	if readOnly {
+		return odysseyReadOnlyConfKey
+	}
+
+	return odysseyConfKey
+}
+
+func (odysseyPooler) confPath() string {
+	return "/pgconf/odyssey.conf"
+}
+
+func (odysseyPooler) deploymentTemplate() *template.Template {
+	return config.OdysseyTemplate
+}
+
+func (odysseyPooler) generateConf(cluster *crv1.Pgcluster, parameters map[string]string, password string,
+	readOnly bool) ([]byte, error) {
+	port := cluster.Spec.Port
+	if port == "" {
+		port = pgPort
+	}
+
+	serviceName := cluster.Name
+	if readOnly {
+		serviceName += ReplicaSuffix
+	}
+
+	fields := OdysseyConfFields{
+		ServiceName:   serviceName,
+		Port:          port,
+		AuthType:      string(cluster.Spec.GetPasswordEncryption()),
+		Password:      password,
+		PoolMode:      cluster.Spec.PgBouncer.GetPoolMode(),
+		MaxClientConn: cluster.Spec.PgBouncer.GetMaxClientConn(),
+		Settings:      []string{},
+		TLSEnabled:    operator.GetTLSPgBouncerSecretName(cluster) != "",
+		TLSOnly:       cluster.Spec.TLSOnly,
+	}
+
+	// Odyssey has no limit of the connections to a database, so its pools are simply kept
+	// within the share of the connection budget of each Pod for each database
+	fields.DefaultPoolSize = getPgBouncerMaxDBConnections(parameters, cluster.Spec.PgBouncer.GetReplicas(),
+		getPgBouncerDatabaseCount(cluster))
+	if fields.DefaultPoolSize > defaultPgBouncerPoolSize {
+		fields.DefaultPoolSize = defaultPgBouncerPoolSize
+	}
+
+	for name, value := range cluster.Spec.PgBouncer.Config {
+		fields.Settings = append(fields.Settings, fmt.Sprintf("%s %s", name, strings.TrimSpace(value)))
+	}
+
+	sort.Strings(fields.Settings)
+
+	doc := bytes.Buffer{}
+
+	if err := config.OdysseyConfTemplate.Execute(&doc, fields); err != nil {
+		log.Error(err)
+
+		return []byte{}, err
+	}
+
+	return doc.Bytes(), nil
+}
+
+func (odysseyPooler) isManagedSetting(name string) bool {
+	return odysseyManagedSettings[name] || strings.HasPrefix(name, "tls")
+}
+
+func (odysseyPooler) reload(clientset *kubernetes.Clientset, restconfig *rest.Config, pod *v1.Pod) error {
+	_, stderr, err := kubeapi.ExecToPodThroughAPI(restconfig, clientset,
+		cmdReloadOdyssey, pod.Spec.Containers[0].Name, pod.Name, pod.Namespace, nil)
+
+	if err != nil {
+		return fmt.Errorf("odyssey reload on pod %s failed: %s %s", pod.Name, err, strings.TrimSpace(stderr))
+	}
+
+	return nil
+}
@@ -0,0 +1,407 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/config"
+	"github.com/crunchydata/postgres-operator/kubeapi"
+	"github.com/crunchydata/postgres-operator/operator"
+	"github.com/crunchydata/postgres-operator/util"
+	log "github.com/sirupsen/logrus"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	// eventReasonPostgresParamsRestarted is the reason of the events that are recorded when a
+	// cluster was restarted to apply the PostgreSQL parameters of its spec
+	eventReasonPostgresParamsRestarted = "PostgresParamsRestarted"
+
+	// eventReasonPostgresParamsFailed is the reason of the Warning events that are recorded when
+	// the PostgreSQL parameters of the spec of a cluster could not be applied
+	eventReasonPostgresParamsFailed = "PostgresParamsFailed"
+)
+
+// postgresParamNameFormat is the format of the name of a PostgreSQL parameter, which is either
+// a parameter of PostgreSQL itself or one of an extension, e.g. "pg_stat_statements.max"
+var postgresParamNameFormat = regexp.MustCompile(`^[a-z_][a-z0-9_]*(\.[a-z_][a-z0-9_]*)?$`)
+
+// reservedPostgresParams are the PostgreSQL parameters that cannot be set in the spec of a
+// cluster, as they are managed by the Operator or by Patroni, along with what manages them
+var reservedPostgresParams = map[string]string{
+	"archive_cleanup_command":   "Patroni",
+	"archive_command":           "pgBackRest",
+	"archive_mode":              "pgBackRest",
+	"cluster_name":              "Patroni",
+	"config_file":               "Patroni",
+	"data_directory":            "Patroni",
+	"hba_file":                  "Patroni",
+	"hot_standby":               "Patroni",
+	"ident_file":                "Patroni",
+	"listen_addresses":          "Patroni",
+	"log_connections":           "connection logging",
+	"log_disconnections":        "connection logging",
+	"password_encryption":       "passwordEncryption",
+	"pgaudit.log":               "audit",
+	"pgaudit.role":              "audit",
+	"port":                      "Patroni",
+	"primary_conninfo":          "Patroni",
+	"primary_slot_name":         "Patroni",
+	"promote_trigger_file":      "Patroni",
+	"recovery_end_command":      "Patroni",
+	"recovery_target":           "Patroni",
+	"recovery_target_action":    "Patroni",
+	"recovery_target_lsn":       "Patroni",
+	"recovery_target_name":      "Patroni",
+	"recovery_target_time":      "Patroni",
+	"recovery_target_xid":       "Patroni",
+	"restore_command":           "Patroni",
+	"ssl":                       "tls",
+	"ssl_ca_file":               "tls",
+	"ssl_cert_file":             "tls",
+	"ssl_key_file":              "tls",
+	"standby_mode":              "Patroni",
+	"synchronous_standby_names": "syncReplication",
+	"timezone":                  "timezone",
+}
+
+// postgresParamsRestarting holds the clusters that are being restarted to apply the parameters
+// of their spec, so that the parameters are not changed again while the restart runs
+var postgresParamsRestarting sync.Map
+
+// ValidatePostgresParams returns an error if any of the PostgreSQL parameters of the spec of a
+// cluster cannot be set, i.e. its name is not that of a parameter or it is managed by the
+// Operator or by Patroni. The values are left for PostgreSQL to check, which ignores a value it
+// does not accept
+func ValidatePostgresParams(cluster *crv1.Pgcluster) error {
+	names := make([]string, 0, len(cluster.Spec.PostgresParams))
+	for name := range cluster.Spec.PostgresParams {
+		names = append(names, name)
+	}
+
+	// the names are sorted to always be reported alike
+	sort.Strings(names)
+
+	for _, name := range names {
+		if !postgresParamNameFormat.MatchString(name) {
+			return fmt.Errorf("invalid postgres parameter name %q", name)
+		}
+
+		if manager, ok := reservedPostgresParams[name]; ok {
+			return fmt.Errorf("postgres parameter %q is managed by %s and cannot be set", name, manager)
+		}
+	}
+
+	return nil
+}
+
+// ReconcilePostgresParams applies the PostgreSQL parameters of the spec of a cluster, and resets
+// those that were removed from it, by writing them into the configuration that Patroni manages.
+// Patroni reloads PostgreSQL for them, so if all of the changed parameters can be reloaded they
+// are in effect right away. Otherwise the parameters that require a restart are recorded as
+// pending in the status of the cluster, and the cluster is restarted in the background, within
+// the restart budget, after which they are cleared. A restart that failed is tried again the
+// next time the cluster is reconciled
+func ReconcilePostgresParams(clientset *kubernetes.Clientset, restclient *rest.RESTClient,
+	restconfig *rest.Config, cluster *crv1.Pgcluster) error {
+	status := cluster.Status.PostgresParams
+
+	if cluster.Status.State != crv1.PgclusterStateInitialized || cluster.Spec.Shutdown ||
+		(len(cluster.Spec.PostgresParams) == 0 && cluster.Spec.Tuning != crv1.PostgresTuningAuto &&
+			len(status.Applied) == 0 && len(status.PendingRestart) == 0) {
+		return nil
+	}
+
+	key := cluster.Namespace + "/" + cluster.Name
+	if _, restarting := postgresParamsRestarting.Load(key); restarting {
+		return nil
+	}
+
+	if err := ValidatePostgresParams(cluster); err != nil {
+		status.Message = err.Error()
+		if patchErr := kubeapi.Patchpgcluster(restclient, cluster, cluster.Namespace, func(cluster *crv1.Pgcluster) {
+			cluster.Status.PostgresParams = status
+		}); patchErr != nil {
+			log.Error(patchErr)
+		}
+		return err
+	}
+
+	current, err := GetPostgreSQLParameters(clientset, cluster)
+	if err != nil {
+		return err
+	}
+
+	parameters := getPostgresParams(cluster)
+	replaced := getReplacedPostgresParams(parameters, status, current)
+
+	// the libraries that the cluster preloads otherwise, e.g. pg_stat_statements, stay loaded
+	if libraries, ok := parameters["shared_preload_libraries"]; ok {
+		parameters["shared_preload_libraries"] = mergePreloadLibraries(
+			replaced["shared_preload_libraries"], libraries)
+	}
+
+	restored, removed := getRemovedPostgresParams(parameters, status.Applied, status.Replaced)
+
+	updated := make(map[string]string, len(parameters)+len(restored))
+	for name, value := range parameters {
+		updated[name] = value
+	}
+	for name, value := range restored {
+		updated[name] = value
+	}
+
+	pending, err := updatePostgreSQLParameters(clientset, restconfig, cluster, updated, removed,
+		false)
+	if err != nil {
+		operator.RecordWarningEvent(clientset, cluster, eventReasonPostgresParamsFailed,
+			fmt.Sprintf("the postgres parameters could not be applied: %s", err))
+
+		status.Message = err.Error()
+		if patchErr := kubeapi.Patchpgcluster(restclient, cluster, cluster.Namespace, func(cluster *crv1.Pgcluster) {
+			cluster.Status.PostgresParams = status
+		}); patchErr != nil {
+			log.Error(patchErr)
+		}
+		return err
+	}
+
+	// parameters that were already pending a restart still are, unless the restart turns out
+	// to have happened in the meantime
+	retry := len(pending) == 0
+	pending = mergeParameterNames(status.PendingRestart, pending)
+
+	next := crv1.PostgresParamsStatus{
+		Applied:        getParameterNames(parameters),
+		Replaced:       replaced,
+		PendingRestart: pending,
+	}
+
+	if !reflect.DeepEqual(next, status) {
+		if err := kubeapi.Patchpgcluster(restclient, cluster, cluster.Namespace, func(cluster *crv1.Pgcluster) {
+			cluster.Status.PostgresParams = next
+		}); err != nil {
+			return err
+		}
+	}
+
+	if len(pending) == 0 {
+		return nil
+	}
+
+	// the parameters are applied right away, but the restart waits for the maintenance window
+	deferred, err := deferMaintenanceAction(clientset, restclient, cluster,
+		maintenanceActionPostgresParams)
+	if err != nil || deferred {
+		return err
+	}
+
+	if _, restarting := postgresParamsRestarting.LoadOrStore(key, true); restarting {
+		return nil
+	}
+
+	log.Infof("cluster %s needs to be restarted to apply postgres parameters %v", cluster.Name,
+		pending)
+
+	go func() {
+		defer postgresParamsRestarting.Delete(key)
+
+		if err := restartPostgresParams(clientset, restconfig, cluster, retry); err != nil {
+			log.Errorf("could not restart cluster %s to apply postgres parameters: %s",
+				cluster.Name, err)
+
+			operator.RecordWarningEvent(clientset, cluster, eventReasonPostgresParamsFailed,
+				fmt.Sprintf("the cluster could not be restarted to apply postgres parameters %s: %s",
+					strings.Join(pending, ", "), err))
+
+			next.Message = err.Error()
+		} else {
+			operator.RecordNormalEvent(clientset, cluster, eventReasonPostgresParamsRestarted,
+				fmt.Sprintf("the cluster was restarted to apply postgres parameters %s",
+					strings.Join(pending, ", ")))
+
+			next.PendingRestart = nil
+		}
+
+		if err := kubeapi.Patchpgcluster(restclient, cluster, cluster.Namespace, func(cluster *crv1.Pgcluster) {
+			cluster.Status.PostgresParams = next
+		}); err != nil {
+			log.Error(err)
+		}
+	}()
+
+	return nil
+}
+
+// restartPostgresParams restarts the members of a cluster that Patroni flagged as pending a
+// restart, and then grows the pools of pgBouncer in case PostgreSQL now allows for more
+// connections. When a restart is tried again, the cluster may have been restarted since, e.g.
+// as it failed over, in which case there is nothing left to restart
+func restartPostgresParams(clientset *kubernetes.Clientset, restconfig *rest.Config,
+	cluster *crv1.Pgcluster, retry bool) error {
+	pod, err := util.GetPrimaryPod(clientset, cluster)
+	if err != nil {
+		return err
+	}
+
+	if retry {
+		if _, pendingRestart, err := getPatroniState(clientset, restconfig, pod); err == nil && !pendingRestart {
+			return nil
+		}
+	}
+
+	if err := restartPendingCluster(clientset, restconfig, cluster, pod); err != nil {
+		return err
+	}
+
+	if cluster.Labels[config.LABEL_PGBOUNCER] != "true" {
+		return nil
+	}
+
+	parameters, err := GetPostgreSQLParameters(clientset, cluster)
+	if err != nil {
+		return err
+	}
+
+	return updatePgBouncerPoolSizes(clientset, restconfig, cluster, parameters)
+}
+
+// getPostgresParams returns the PostgreSQL parameters of the spec of a cluster as they are
+// applied, along with the memory settings that are derived for a tuned cluster, unless the spec
+// sets them itself. pgaudit stays loaded if the cluster has audit logging enabled, even if the
+// spec sets the libraries to preload, as do the libraries the cluster preloads otherwise, which
+// are merged in by the caller once they are known
+func getPostgresParams(cluster *crv1.Pgcluster) map[string]string {
+	parameters := getTunedPostgresParams(cluster)
+	if parameters == nil {
+		parameters = make(map[string]string, len(cluster.Spec.PostgresParams))
+	}
+
+	for name, value := range cluster.Spec.PostgresParams {
+		parameters[name] = value
+	}
+
+	if libraries, ok := parameters["shared_preload_libraries"]; ok && cluster.Spec.Audit.Enabled {
+		parameters["shared_preload_libraries"] = mergePreloadLibraries(libraries, auditLibrary)
+	}
+
+	return parameters
+}
+
+// getReplacedPostgresParams returns the values that the parameters of the spec of a cluster
+// replace in its configuration, or nil if they replace none. The value of a parameter is taken
+// from the configuration as the parameter is first applied, and kept for as long as it is applied
+func getReplacedPostgresParams(parameters map[string]string, status crv1.PostgresParamsStatus,
+	current map[string]string) map[string]string {
+	applied := make(map[string]bool, len(status.Applied))
+	for _, name := range status.Applied {
+		applied[name] = true
+	}
+
+	replaced := map[string]string{}
+	for name := range parameters {
+		if value, ok := status.Replaced[name]; ok && applied[name] {
+			replaced[name] = value
+		} else if value, ok := current[name]; ok && !applied[name] {
+			replaced[name] = value
+		}
+	}
+
+	if len(replaced) == 0 {
+		return nil
+	}
+
+	return replaced
+}
+
+// getRemovedPostgresParams returns the parameters that were applied from the spec of a cluster
+// before, but are no longer in it: the values of those that replaced a value, which is restored,
+// and the names of the others, which are reset to their defaults
+func getRemovedPostgresParams(parameters map[string]string, applied []string,
+	replaced map[string]string) (map[string]string, []string) {
+	restored := map[string]string{}
+	removed := []string{}
+
+	for _, name := range applied {
+		if _, ok := parameters[name]; ok {
+			continue
+		}
+
+		if value, ok := replaced[name]; ok {
+			restored[name] = value
+		} else {
+			removed = append(removed, name)
+		}
+	}
+
+	return restored, removed
+}
+
+// mergePreloadLibraries returns the libraries to preload, i.e. the value of
+// "shared_preload_libraries", followed by those of the other value that are not among them yet
+func mergePreloadLibraries(libraries, other string) string {
+	merged := []string{}
+	loaded := map[string]bool{}
+
+	for _, library := range append(strings.Split(libraries, ","), strings.Split(other, ",")...) {
+		library = strings.TrimSpace(library)
+		name := strings.TrimSuffix(strings.Trim(library, `"'`), ".so")
+
+		if name == "" || loaded[name] {
+			continue
+		}
+
+		loaded[name] = true
+		merged = append(merged, library)
+	}
+
+	return strings.Join(merged, ",")
+}
+
+// getParameterNames returns the names of the parameters in order, or nil if there are none
+func getParameterNames(parameters map[string]string) []string {
+	if len(parameters) == 0 {
+		return nil
+	}
+
+	names := make([]string, 0, len(parameters))
+	for name := range parameters {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// mergeParameterNames returns the names that are in either of the lists in order, each once,
+// or nil if there are none
+func mergeParameterNames(a, b []string) []string {
+	merged := map[string]string{}
+	for _, name := range append(append([]string{}, a...), b...) {
+		merged[name] = ""
+	}
+
+	return getParameterNames(merged)
+}
@@ -0,0 +1,202 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+)
+
+func TestValidatePostgresParams(t *testing.T) {
+	tests := []struct {
+		name       string
+		parameters map[string]string
+		err        string
+	}{
+		{"none", nil, ""},
+		{"valid", map[string]string{
+			"work_mem":                 "64MB",
+			"shared_preload_libraries": "pg_stat_statements",
+			"pg_stat_statements.max":   "10000",
+		}, ""},
+		{"invalid name", map[string]string{"work mem": "64MB"}, "invalid postgres parameter name"},
+		{"uppercase name", map[string]string{"Work_Mem": "64MB"}, "invalid postgres parameter name"},
+		{"managed by patroni", map[string]string{"port": "5433"}, "is managed by Patroni"},
+		{"managed by the operator", map[string]string{"password_encryption": "md5"},
+			"is managed by passwordEncryption"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cluster := &crv1.Pgcluster{Spec: crv1.PgclusterSpec{PostgresParams: test.parameters}}
+
+			err := ValidatePostgresParams(cluster)
+
+			if test.err == "" {
+				if err != nil {
+					t.Fatalf("expected no error, got %q", err)
+				}
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Fatalf("expected error containing %q, got %v", test.err, err)
+			}
+		})
+	}
+}
+
+func TestGetPostgresParams(t *testing.T) {
+	tests := []struct {
+		name      string
+		libraries string
+		audit     bool
+		expected  string
+	}{
+		{"no audit", "pg_stat_statements", false, "pg_stat_statements"},
+		{"audit", "pg_stat_statements", true, "pg_stat_statements,pgaudit"},
+		{"audit already loaded", "pgaudit,pg_stat_statements", true, "pgaudit,pg_stat_statements"},
+		{"no libraries", "", true, "pgaudit"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cluster := &crv1.Pgcluster{Spec: crv1.PgclusterSpec{
+				Audit: crv1.AuditSpec{Enabled: test.audit},
+				PostgresParams: map[string]string{
+					"shared_preload_libraries": test.libraries,
+					"work_mem":                 "64MB",
+				},
+			}}
+
+			parameters := getPostgresParams(cluster)
+
+			if parameters["shared_preload_libraries"] != test.expected {
+				t.Fatalf("expected %q, got %q", test.expected, parameters["shared_preload_libraries"])
+			}
+
+			if parameters["work_mem"] != "64MB" {
+				t.Fatalf("expected work_mem to be kept, got %q", parameters["work_mem"])
+			}
+
+			// the spec itself is left as is
+			if cluster.Spec.PostgresParams["shared_preload_libraries"] != test.libraries {
+				t.Fatalf("expected the spec to be unchanged, got %q",
+					cluster.Spec.PostgresParams["shared_preload_libraries"])
+			}
+		})
+	}
+}
+
+func TestGetReplacedPostgresParams(t *testing.T) {
+	parameters := map[string]string{
+		"shared_preload_libraries": "auto_explain",
+		"work_mem":                 "64MB",
+		"max_connections":          "200",
+	}
+	status := crv1.PostgresParamsStatus{
+		Applied:  []string{"shared_preload_libraries", "work_mem", "wal_buffers"},
+		Replaced: map[string]string{"shared_preload_libraries": "pgaudit.so,pg_stat_statements.so"},
+	}
+	current := map[string]string{
+		"shared_preload_libraries": "auto_explain",
+		"work_mem":                 "64MB",
+		"max_connections":          "100",
+	}
+
+	replaced := getReplacedPostgresParams(parameters, status, current)
+
+	// the value replaced before is kept, and that of a newly applied parameter is taken
+	expected := map[string]string{
+		"shared_preload_libraries": "pgaudit.so,pg_stat_statements.so",
+		"max_connections":          "100",
+	}
+	if !reflect.DeepEqual(replaced, expected) {
+		t.Fatalf("expected %v, got %v", expected, replaced)
+	}
+
+	if replaced := getReplacedPostgresParams(map[string]string{"work_mem": "64MB"},
+		crv1.PostgresParamsStatus{}, map[string]string{}); replaced != nil {
+		t.Fatalf("expected nothing to be replaced, got %v", replaced)
+	}
+}
+
+func TestGetRemovedPostgresParams(t *testing.T) {
+	parameters := map[string]string{"work_mem": "64MB", "max_connections": "200"}
+	applied := []string{"max_connections", "shared_buffers", "shared_preload_libraries", "work_mem",
+		"wal_buffers"}
+	replaced := map[string]string{
+		"max_connections":          "100",
+		"shared_preload_libraries": "pgaudit.so,pg_stat_statements.so",
+	}
+
+	restored, removed := getRemovedPostgresParams(parameters, applied, replaced)
+
+	expected := map[string]string{"shared_preload_libraries": "pgaudit.so,pg_stat_statements.so"}
+	if !reflect.DeepEqual(restored, expected) {
+		t.Fatalf("expected %v to be restored, got %v", expected, restored)
+	}
+
+	if expected := []string{"shared_buffers", "wal_buffers"}; !reflect.DeepEqual(removed, expected) {
+		t.Fatalf("expected %v to be removed, got %v", expected, removed)
+	}
+}
+
+func TestMergePreloadLibraries(t *testing.T) {
+	tests := []struct {
+		libraries, other string
+		expected         string
+	}{
+		{"", "", ""},
+		{"", "pgaudit", "pgaudit"},
+		{"pgaudit.so,pg_stat_statements.so", "", "pgaudit.so,pg_stat_statements.so"},
+		{"pgaudit.so,pg_stat_statements.so", "pg_stat_statements, auto_explain",
+			"pgaudit.so,pg_stat_statements.so,auto_explain"},
+		{"'pgaudit'", "pgaudit.so", "'pgaudit'"},
+	}
+
+	for _, test := range tests {
+		if merged := mergePreloadLibraries(test.libraries, test.other); merged != test.expected {
+			t.Errorf("%q and %q: expected %q, got %q", test.libraries, test.other, test.expected,
+				merged)
+		}
+	}
+}
+
+func TestMergeParameterNames(t *testing.T) {
+	tests := []struct {
+		name     string
+		a, b     []string
+		expected []string
+	}{
+		{"none", nil, nil, nil},
+		{"one side", []string{"shared_buffers"}, nil, []string{"shared_buffers"}},
+		{"both sides", []string{"shared_buffers", "max_connections"},
+			[]string{"max_connections", "huge_pages"},
+			[]string{"huge_pages", "max_connections", "shared_buffers"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if merged := mergeParameterNames(test.a, test.b); !reflect.DeepEqual(merged, test.expected) {
+				t.Fatalf("expected %v, got %v", test.expected, merged)
+			}
+		})
+	}
+}
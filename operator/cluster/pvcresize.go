@@ -0,0 +1,212 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"fmt"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/config"
+	"github.com/crunchydata/postgres-operator/kubeapi"
+	"github.com/crunchydata/postgres-operator/operator"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// ReconcilePrimaryStorage resizes the volume of the primary of a cluster to the size of its
+// primary storage, if the volume requests another size, and records the progress of the resize
+// in the status of the cluster until the volume has its new size
+func ReconcilePrimaryStorage(clientset *kubernetes.Clientset, restclient *rest.RESTClient,
+	cluster *crv1.Pgcluster) error {
+	// a resize that has not started yet waits for the maintenance window
+	if !cluster.Status.PrimaryStorage.Resizing && !cluster.Status.PrimaryStorage.FileSystemResizePending {
+		deferred, err := deferMaintenanceAction(clientset, restclient, cluster,
+			maintenanceActionPrimaryStorage)
+		if err != nil || deferred {
+			return err
+		}
+	}
+
+	status, err := resizeVolume(clientset, operator.PgclusterReference(cluster),
+		cluster.Spec.PrimaryStorage, cluster.Spec.Name, cluster.Namespace,
+		cluster.Status.PrimaryStorage)
+	if err != nil {
+		return err
+	}
+
+	if status == cluster.Status.PrimaryStorage {
+		return nil
+	}
+
+	return kubeapi.Patchpgcluster(restclient, cluster, cluster.Namespace, func(cluster *crv1.Pgcluster) {
+		cluster.Status.PrimaryStorage = status
+	})
+}
+
+// ReconcileReplicaStorage resizes the volume of a replica to the size of its replica storage,
+// if the volume requests another size, and records the progress of the resize in the status of
+// the pgreplica until the volume has its new size
+func ReconcileReplicaStorage(clientset *kubernetes.Clientset, restclient *rest.RESTClient,
+	replica *crv1.Pgreplica) error {
+	status, err := resizeVolume(clientset, operator.PgreplicaReference(replica),
+		replica.Spec.ReplicaStorage, replica.Spec.Name, replica.Namespace,
+		replica.Status.ReplicaStorage)
+	if err != nil {
+		return err
+	}
+
+	if status == replica.Status.ReplicaStorage {
+		return nil
+	}
+
+	return kubeapi.PatchpgreplicaReplicaStorageStatus(restclient, status, replica, replica.Namespace)
+}
+
+// UpdateReplicaStorageSize sets the replica storage size of a cluster on its pgreplicas, which
+// in turn resizes the volumes of the replicas
+func UpdateReplicaStorageSize(clientset *kubernetes.Clientset, restclient *rest.RESTClient,
+	cluster *crv1.Pgcluster) error {
+	deferred, err := deferMaintenanceAction(clientset, restclient, cluster,
+		maintenanceActionReplicaStorage)
+	if err != nil || deferred {
+		return err
+	}
+
+	replicas := crv1.PgreplicaList{}
+	selector := fmt.Sprintf("%s=%s", config.LABEL_PG_CLUSTER, cluster.Name)
+
+	if err := kubeapi.GetpgreplicasBySelector(restclient, &replicas, selector,
+		cluster.Namespace); err != nil {
+		return err
+	}
+
+	for i := range replicas.Items {
+		replica := &replicas.Items[i]
+
+		if replica.Spec.ReplicaStorage.Size == cluster.Spec.ReplicaStorage.Size {
+			continue
+		}
+
+		log.Debugf("setting the storage size of replica %s to %s", replica.Name,
+			cluster.Spec.ReplicaStorage.Size)
+
+		replica.Spec.ReplicaStorage.Size = cluster.Spec.ReplicaStorage.Size
+
+		if err := kubeapi.Updatepgreplica(restclient, replica, replica.Name,
+			cluster.Namespace); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// resizeVolume requests the size of the storage of an instance for its PVC, if the PVC requests a
+// smaller size and its StorageClass allows it to be expanded, and returns the progress of the
+// resize. Events are recorded for the object that is referred to once the resize starts, once it
+// completes, i.e. when the previous status still had it in progress, and if it cannot be done
+func resizeVolume(clientset *kubernetes.Clientset, object v1.ObjectReference,
+	storage crv1.PgStorageSpec, name, namespace string,
+	previous crv1.VolumeResizeStatus) (crv1.VolumeResizeStatus, error) {
+	// only the volumes that the Operator creates are resized, and not e.g. an existing volume
+	if storage.StorageType != "create" && storage.StorageType != "dynamic" {
+		return previous, nil
+	}
+
+	size, err := resource.ParseQuantity(storage.Size)
+	if err != nil {
+		return previous, err
+	}
+
+	pvc, found, err := kubeapi.GetPVC(clientset, name, namespace)
+	if !found {
+		return previous, err
+	}
+
+	status := getVolumeResizeStatus(pvc)
+	requested := pvc.Spec.Resources.Requests[v1.ResourceStorage]
+
+	// a failed resize is reported once, rather than every time the volume is checked
+	failed := func(message string) (crv1.VolumeResizeStatus, error) {
+		status.Message = message
+
+		if message != previous.Message {
+			operator.RecordObjectWarningEvent(clientset, object, operator.EventReasonPVCResizeFailed,
+				fmt.Sprintf("could not resize volume %s: %s", name, message))
+		}
+
+		return status, nil
+	}
+
+	switch size.Cmp(requested) {
+	case 0:
+		if (previous.Resizing || previous.FileSystemResizePending) &&
+			!status.Resizing && !status.FileSystemResizePending {
+			operator.RecordObjectNormalEvent(clientset, object, operator.EventReasonPVCResizeCompleted,
+				fmt.Sprintf("volume %s was resized to %s", name, status.Capacity))
+		}
+
+		return status, nil
+	case -1:
+		return failed(fmt.Sprintf("a volume cannot be shrunk from %s to %s", requested.String(),
+			size.String()))
+	}
+
+	if className := pvc.Spec.StorageClassName; className != nil && *className != "" {
+		if storageClass, found := kubeapi.GetStorageClass(clientset, *className); found &&
+			(storageClass.AllowVolumeExpansion == nil || !*storageClass.AllowVolumeExpansion) {
+			return failed(fmt.Sprintf("StorageClass %s does not allow volumes to be expanded", *className))
+		}
+	}
+
+	pvc.Spec.Resources.Requests[v1.ResourceStorage] = size
+
+	if err := kubeapi.UpdatePVC(clientset, pvc, namespace); err != nil {
+		return failed(err.Error())
+	}
+
+	operator.RecordObjectNormalEvent(clientset, object, operator.EventReasonPVCResizeStarted,
+		fmt.Sprintf("resizing volume %s from %s to %s", name, requested.String(), size.String()))
+
+	return getVolumeResizeStatus(pvc), nil
+}
+
+// getVolumeResizeStatus returns the progress of the resize of a PVC: it is being resized until it
+// has the capacity it requests, and its file system is pending to be resized while it has the
+// condition that says so
+func getVolumeResizeStatus(pvc *v1.PersistentVolumeClaim) crv1.VolumeResizeStatus {
+	requested := pvc.Spec.Resources.Requests[v1.ResourceStorage]
+	capacity := pvc.Status.Capacity[v1.ResourceStorage]
+
+	status := crv1.VolumeResizeStatus{
+		Name:      pvc.Name,
+		Requested: requested.String(),
+		Capacity:  capacity.String(),
+		Resizing:  capacity.Cmp(requested) < 0,
+	}
+
+	for _, condition := range pvc.Status.Conditions {
+		if condition.Type == v1.PersistentVolumeClaimFileSystemResizePending &&
+			condition.Status == v1.ConditionTrue {
+			status.FileSystemResizePending = true
+		}
+	}
+
+	return status
+}
@@ -0,0 +1,56 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"testing"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGetVolumeResizeStatus(t *testing.T) {
+	pvc := &v1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: "hippo"}}
+	pvc.Spec.Resources.Requests = v1.ResourceList{v1.ResourceStorage: resource.MustParse("20Gi")}
+	pvc.Status.Capacity = v1.ResourceList{v1.ResourceStorage: resource.MustParse("10Gi")}
+
+	expected := crv1.VolumeResizeStatus{Name: "hippo", Requested: "20Gi", Capacity: "10Gi", Resizing: true}
+	if status := getVolumeResizeStatus(pvc); status != expected {
+		t.Fatalf("expected the volume to be resizing, got %+v", status)
+	}
+
+	// the volume is expanded, but its file system waits for the Pod to be restarted
+	pvc.Status.Capacity[v1.ResourceStorage] = resource.MustParse("20Gi")
+	pvc.Status.Conditions = []v1.PersistentVolumeClaimCondition{{
+		Type:   v1.PersistentVolumeClaimFileSystemResizePending,
+		Status: v1.ConditionTrue,
+	}}
+
+	expected = crv1.VolumeResizeStatus{Name: "hippo", Requested: "20Gi", Capacity: "20Gi",
+		FileSystemResizePending: true}
+	if status := getVolumeResizeStatus(pvc); status != expected {
+		t.Fatalf("expected the file system resize to be pending, got %+v", status)
+	}
+
+	pvc.Status.Conditions = nil
+
+	expected = crv1.VolumeResizeStatus{Name: "hippo", Requested: "20Gi", Capacity: "20Gi"}
+	if status := getVolumeResizeStatus(pvc); status != expected {
+		t.Fatalf("expected the volume to be resized, got %+v", status)
+	}
+}
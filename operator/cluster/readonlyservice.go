@@ -0,0 +1,267 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"fmt"
+	"strconv"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/config"
+	"github.com/crunchydata/postgres-operator/kubeapi"
+	"github.com/crunchydata/postgres-operator/operator"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ReadOnlySuffix is the suffix of the name of the read-only Service of a cluster, which is
+// "<clusterName>-replicas"
+const ReadOnlySuffix = "-replicas"
+
+// maxSessionAffinityTimeoutSeconds is the longest that the connections of a client are kept on
+// the same replica, which is 3 hours
+const maxSessionAffinityTimeoutSeconds = int32(10800)
+
+// ValidateReadOnlyService returns an error if the read-only Service of a cluster has settings
+// or a session affinity that the Operator does not support
+func ValidateReadOnlyService(cluster *crv1.Pgcluster) error {
+	spec := cluster.Spec.ReadOnlyService
+
+	if err := validateServiceSpec("read-only", spec.ServiceSpec); err != nil {
+		return err
+	}
+
+	switch v1.ServiceAffinity(spec.SessionAffinity) {
+	case "", v1.ServiceAffinityNone, v1.ServiceAffinityClientIP:
+	default:
+		return fmt.Errorf("invalid read-only service session affinity %q, must be %s or %s",
+			spec.SessionAffinity, v1.ServiceAffinityNone, v1.ServiceAffinityClientIP)
+	}
+
+	if spec.SessionAffinityTimeoutSeconds < 0 ||
+		spec.SessionAffinityTimeoutSeconds > maxSessionAffinityTimeoutSeconds {
+		return fmt.Errorf("invalid read-only service session affinity timeout of %d seconds, it "+
+			"cannot be negative or more than %d", spec.SessionAffinityTimeoutSeconds,
+			maxSessionAffinityTimeoutSeconds)
+	}
+
+	if spec.SessionAffinityTimeoutSeconds > 0 &&
+		v1.ServiceAffinity(spec.SessionAffinity) != v1.ServiceAffinityClientIP {
+		return fmt.Errorf("a read-only service session affinity timeout requires a session "+
+			"affinity of %s", v1.ServiceAffinityClientIP)
+	}
+
+	return nil
+}
+
+// ReconcileReadOnlyService creates the read-only Service of a cluster once it is enabled, keeps
+// its type, annotations and session affinity in line with the spec, and removes it once it is
+// disabled. Unlike the replica Service, the read-only Service never selects its Pods: the
+// Operator keeps its Endpoints to the replicas that are ready and neither delayed nor lagging
+// too far behind the primary, and, if the primary is included, to the primary while there are
+// none
+func ReconcileReadOnlyService(clientset *kubernetes.Clientset, cluster *crv1.Pgcluster) error {
+	name := cluster.Name + ReadOnlySuffix
+
+	service, found, err := kubeapi.GetService(clientset, name, cluster.Namespace)
+	if err != nil && !kerrors.IsNotFound(err) {
+		return err
+	}
+
+	if !cluster.Spec.ReadOnlyService.Enabled {
+		if !found {
+			return nil
+		}
+
+		log.Infof("read-only service: removing service %s", name)
+
+		return kubeapi.DeleteService(clientset, name, cluster.Namespace)
+	}
+
+	if !found {
+		service = &v1.Service{
+			ObjectMeta: meta_v1.ObjectMeta{
+				Name:      name,
+				Namespace: cluster.Namespace,
+				Labels: map[string]string{
+					config.LABEL_VENDOR:     config.LABEL_CRUNCHY,
+					config.LABEL_PG_CLUSTER: cluster.Name,
+					config.LABEL_NAME:       name,
+				},
+			},
+		}
+	}
+
+	changed, err := setReadOnlyServiceSpec(service, cluster)
+	if err != nil {
+		return err
+	}
+
+	if !found {
+		log.Infof("read-only service: creating service %s", name)
+
+		if service, err = kubeapi.CreateService(clientset, service, cluster.Namespace); err != nil {
+			return err
+		}
+	} else if changed {
+		log.Debugf("read-only service: updating service %s", name)
+
+		if err := kubeapi.UpdateService(clientset, service, cluster.Namespace); err != nil {
+			return err
+		}
+	}
+
+	return updateReadOnlyEndpoints(clientset, service, cluster)
+}
+
+// UpdateReadOnlyEndpoints keeps the Endpoints of the read-only Service of a cluster, if it has
+// one, in line with its instances as they come and go
+func UpdateReadOnlyEndpoints(clientset *kubernetes.Clientset, cluster *crv1.Pgcluster) error {
+	if !cluster.Spec.ReadOnlyService.Enabled {
+		return nil
+	}
+
+	service, found, err := kubeapi.GetService(clientset, cluster.Name+ReadOnlySuffix,
+		cluster.Namespace)
+	if !found {
+		// the Service is created by the next reconcile of the cluster
+		if kerrors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+
+	return updateReadOnlyEndpoints(clientset, service, cluster)
+}
+
+// updateReadOnlyEndpoints sets the Endpoints of the read-only Service of a cluster to its
+// replicas that are not kept out of the replica Service
+func updateReadOnlyEndpoints(clientset *kubernetes.Clientset, service *v1.Service,
+	cluster *crv1.Pgcluster) error {
+	excluded, err := getExcludedReplicas(clientset, cluster.Name, cluster.Namespace)
+	if err != nil {
+		return err
+	}
+
+	selector := fmt.Sprintf("%s=%s", config.LABEL_PG_CLUSTER, cluster.Name)
+
+	pods, err := kubeapi.GetPods(clientset, selector, cluster.Namespace)
+	if err != nil {
+		return err
+	}
+
+	return setServiceEndpoints(clientset, service, getReadOnlyEndpointSubsets(service, pods.Items,
+		excluded, cluster.Spec.ReadOnlyService.IncludePrimary))
+}
+
+// getReadOnlyEndpointSubsets returns the Endpoints of the read-only Service of a cluster for the
+// Pods of its instances. These are the replicas that are not kept out of it or, if the primary is
+// included, the primary while none of them is ready
+func getReadOnlyEndpointSubsets(service *v1.Service, pods []v1.Pod, excluded []string,
+	includePrimary bool) []v1.EndpointSubset {
+	replicas := []v1.Pod{}
+	primaries := []v1.Pod{}
+
+	for i := range pods {
+		switch pods[i].ObjectMeta.Labels[config.LABEL_PGHA_ROLE] {
+		case config.LABEL_PGHA_ROLE_REPLICA:
+			replicas = append(replicas, pods[i])
+		case "master":
+			primaries = append(primaries, pods[i])
+		}
+	}
+
+	subsets := getReplicaEndpointSubsets(service, replicas, excluded)
+
+	if !includePrimary || (len(subsets) > 0 && len(subsets[0].Addresses) > 0) {
+		return subsets
+	}
+
+	return getReplicaEndpointSubsets(service, append(replicas, primaries...), excluded)
+}
+
+// setReadOnlyServiceSpec sets the ports, the settings and the session affinity of the spec of a
+// cluster on its read-only Service, returning whether the Service changed
+func setReadOnlyServiceSpec(service *v1.Service, cluster *crv1.Pgcluster) (bool, error) {
+	spec := cluster.Spec.ReadOnlyService
+	changed := false
+
+	port, err := strconv.Atoi(cluster.Spec.Port)
+	if err != nil {
+		return false, fmt.Errorf("invalid port %q of cluster %s", cluster.Spec.Port, cluster.Name)
+	}
+
+	if len(service.Spec.Ports) != 1 || service.Spec.Ports[0].Port != int32(port) ||
+		service.Spec.Ports[0].TargetPort.IntValue() != port {
+		service.Spec.Ports = []v1.ServicePort{{
+			Name:       "postgres",
+			Protocol:   v1.ProtocolTCP,
+			Port:       int32(port),
+			TargetPort: intstr.FromInt(port),
+		}}
+		changed = true
+	}
+
+	if setServiceSpec(service, spec.ServiceSpec, operator.Pgo.Cluster.ServiceType) {
+		changed = true
+	}
+
+	// Kubernetes fills in the default timeout of a client IP affinity, so it is set here as well
+	affinity := v1.ServiceAffinity(spec.SessionAffinity)
+	var affinityConfig *v1.SessionAffinityConfig
+
+	if affinity == v1.ServiceAffinityClientIP {
+		timeout := spec.SessionAffinityTimeoutSeconds
+		if timeout == 0 {
+			timeout = v1.DefaultClientIPServiceAffinitySeconds
+		}
+
+		affinityConfig = &v1.SessionAffinityConfig{
+			ClientIP: &v1.ClientIPConfig{TimeoutSeconds: &timeout},
+		}
+	} else {
+		affinity = v1.ServiceAffinityNone
+	}
+
+	if service.Spec.SessionAffinity != affinity {
+		service.Spec.SessionAffinity = affinity
+		changed = true
+	}
+
+	if !isSessionAffinityConfigEqual(service.Spec.SessionAffinityConfig, affinityConfig) {
+		service.Spec.SessionAffinityConfig = affinityConfig
+		changed = true
+	}
+
+	return changed, nil
+}
+
+// isSessionAffinityConfigEqual returns whether two session affinity configurations have the same
+// client IP timeout, if any
+func isSessionAffinityConfigEqual(a, b *v1.SessionAffinityConfig) bool {
+	timeout := func(config *v1.SessionAffinityConfig) int32 {
+		if config == nil || config.ClientIP == nil || config.ClientIP.TimeoutSeconds == nil {
+			return 0
+		}
+		return *config.ClientIP.TimeoutSeconds
+	}
+
+	return timeout(a) == timeout(b)
+}
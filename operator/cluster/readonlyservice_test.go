@@ -0,0 +1,152 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"testing"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/config"
+	v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func TestGetReadOnlyEndpointSubsets(t *testing.T) {
+	service := &v1.Service{
+		Spec: v1.ServiceSpec{
+			Ports: []v1.ServicePort{
+				{Name: "postgres", Port: 5432, TargetPort: intstr.FromInt(5432), Protocol: v1.ProtocolTCP},
+			},
+		},
+	}
+
+	pod := func(instance, role, ip string, ready bool) v1.Pod {
+		return v1.Pod{
+			ObjectMeta: meta_v1.ObjectMeta{
+				Name: instance + "-1",
+				Labels: map[string]string{
+					config.LABEL_DEPLOYMENT_NAME: instance,
+					config.LABEL_PGHA_ROLE:       role,
+				},
+			},
+			Status: v1.PodStatus{
+				PodIP: ip,
+				ContainerStatuses: []v1.ContainerStatus{
+					{Name: "database", Ready: ready},
+				},
+			},
+		}
+	}
+
+	t.Run("replicas", func(t *testing.T) {
+		subsets := getReadOnlyEndpointSubsets(service, []v1.Pod{
+			pod("hippo", "master", "10.0.0.1", true),
+			pod("hippo-abcd", "replica", "10.0.0.2", true),
+			pod("hippo-efgh", "replica", "10.0.0.3", true),
+		}, []string{"hippo-efgh"}, true)
+
+		if len(subsets) != 1 || len(subsets[0].Addresses) != 1 ||
+			subsets[0].Addresses[0].IP != "10.0.0.2" {
+			t.Fatalf("expected the replica that is not excluded, got %v", subsets)
+		}
+	})
+
+	t.Run("primary fallback", func(t *testing.T) {
+		subsets := getReadOnlyEndpointSubsets(service, []v1.Pod{
+			pod("hippo", "master", "10.0.0.1", true),
+			pod("hippo-abcd", "replica", "10.0.0.2", false),
+			pod("hippo-efgh", "replica", "10.0.0.3", true),
+		}, []string{"hippo-efgh"}, true)
+
+		if len(subsets) != 1 || len(subsets[0].Addresses) != 1 ||
+			subsets[0].Addresses[0].IP != "10.0.0.1" {
+			t.Fatalf("expected the primary, got %v", subsets)
+		}
+		if len(subsets[0].NotReadyAddresses) != 1 || subsets[0].NotReadyAddresses[0].IP != "10.0.0.2" {
+			t.Fatalf("expected the replica that is not ready, got %v", subsets[0].NotReadyAddresses)
+		}
+	})
+
+	t.Run("primary not included", func(t *testing.T) {
+		if subsets := getReadOnlyEndpointSubsets(service, []v1.Pod{
+			pod("hippo", "master", "10.0.0.1", true),
+			pod("hippo-efgh", "replica", "10.0.0.3", true),
+		}, []string{"hippo-efgh"}, false); subsets != nil {
+			t.Fatalf("expected no subsets, got %v", subsets)
+		}
+	})
+}
+
+func TestSetReadOnlyServiceSpec(t *testing.T) {
+	cluster := &crv1.Pgcluster{
+		ObjectMeta: meta_v1.ObjectMeta{Name: "hippo"},
+		Spec: crv1.PgclusterSpec{
+			Port: "5432",
+			ReadOnlyService: crv1.ReadOnlyServiceSpec{
+				Enabled: true,
+				ServiceSpec: crv1.ServiceSpec{
+					ServiceType: "LoadBalancer",
+					Annotations: map[string]string{"example.com/internal": "true"},
+				},
+				SessionAffinity: "ClientIP",
+			},
+		},
+	}
+
+	service := &v1.Service{}
+
+	changed, err := setReadOnlyServiceSpec(service, cluster)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !changed {
+		t.Fatal("expected the service to change")
+	}
+
+	if service.Spec.Type != v1.ServiceTypeLoadBalancer {
+		t.Errorf("expected a load balancer, got %q", service.Spec.Type)
+	}
+	if len(service.Spec.Ports) != 1 || service.Spec.Ports[0].Port != 5432 {
+		t.Errorf("expected the port of the cluster, got %v", service.Spec.Ports)
+	}
+	if service.Annotations["example.com/internal"] != "true" {
+		t.Errorf("expected the annotations of the spec, got %v", service.Annotations)
+	}
+	if service.Spec.SessionAffinity != v1.ServiceAffinityClientIP ||
+		*service.Spec.SessionAffinityConfig.ClientIP.TimeoutSeconds != v1.DefaultClientIPServiceAffinitySeconds {
+		t.Errorf("expected a client IP affinity with the default timeout, got %v",
+			service.Spec.SessionAffinityConfig)
+	}
+
+	if changed, _ := setReadOnlyServiceSpec(service, cluster); changed {
+		t.Error("expected the service to be unchanged")
+	}
+
+	cluster.Spec.ReadOnlyService.Annotations = nil
+	cluster.Spec.ReadOnlyService.SessionAffinity = ""
+
+	if changed, _ := setReadOnlyServiceSpec(service, cluster); !changed {
+		t.Fatal("expected the service to change")
+	}
+
+	if _, ok := service.Annotations["example.com/internal"]; ok {
+		t.Errorf("expected the annotation to be removed, got %v", service.Annotations)
+	}
+	if service.Spec.SessionAffinity != v1.ServiceAffinityNone || service.Spec.SessionAffinityConfig != nil {
+		t.Errorf("expected no session affinity, got %q", service.Spec.SessionAffinity)
+	}
+}
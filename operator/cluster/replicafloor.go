@@ -0,0 +1,214 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"fmt"
+	"strconv"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/config"
+	"github.com/crunchydata/postgres-operator/kubeapi"
+	"github.com/crunchydata/postgres-operator/util"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// ReconcileReplicaFloor keeps the number of ready replicas of a cluster at or above the
+// "minReadyReplicas" of the cluster. If there are too few ready replicas, e.g. because nodes are
+// being drained, temporary replicas are added. Once there are enough ready replicas without them,
+// the temporary replicas are removed again. Only temporary replicas are ever removed, so the
+// replicas that were configured for the cluster are never given up in favor of a temporary one
+func ReconcileReplicaFloor(clientset *kubernetes.Clientset, restclient *rest.RESTClient, cluster *crv1.Pgcluster) error {
+	// replicas can only be added to a running cluster
+	if cluster.Status.State != crv1.PgclusterStateInitialized {
+		return nil
+	}
+
+	replicas := crv1.PgreplicaList{}
+	selector := fmt.Sprintf("%s=%s", config.LABEL_PG_CLUSTER, cluster.Name)
+
+	if err := kubeapi.GetpgreplicasBySelector(restclient, &replicas, selector, cluster.Namespace); err != nil {
+		return err
+	}
+
+	// get the set of replicas that currently have a ready Pod
+	selector = fmt.Sprintf("%s=%s,%s=%s", config.LABEL_PG_CLUSTER, cluster.Name,
+		config.LABEL_PGHA_ROLE, config.LABEL_PGHA_ROLE_REPLICA)
+
+	pods, err := kubeapi.GetPods(clientset, selector, cluster.Namespace)
+	if err != nil {
+		return err
+	}
+
+	ready := map[string]bool{}
+	for _, pod := range pods.Items {
+		if isReplicaPodReady(&pod) {
+			ready[pod.Labels[config.LABEL_DEPLOYMENT_NAME]] = true
+		}
+	}
+
+	// sort the temporary replicas into those that are ready, and those that are still pending
+	readyTemporary, pendingTemporary := []string{}, []string{}
+	for _, replica := range replicas.Items {
+		if replica.Labels[config.LABEL_TEMPORARY_REPLICA] != config.LABEL_TRUE {
+			continue
+		}
+
+		if ready[replica.Name] {
+			readyTemporary = append(readyTemporary, replica.Name)
+		} else {
+			pendingTemporary = append(pendingTemporary, replica.Name)
+		}
+	}
+
+	readyCount := len(ready)
+	minReadyReplicas := cluster.Spec.MinReadyReplicas
+
+	log.Debugf("replica floor: cluster %s has %d ready replicas (floor %d), %d ready and %d "+
+		"pending temporary replicas", cluster.Name, readyCount, minReadyReplicas,
+		len(readyTemporary), len(pendingTemporary))
+
+	// if there are too few ready replicas, add enough temporary replicas to make up the
+	// difference, taking into account the temporary replicas that are on their way
+	if readyCount < minReadyReplicas {
+		for i := len(pendingTemporary); i < minReadyReplicas-readyCount; i++ {
+			log.Infof("replica floor: adding temporary replica to cluster %s", cluster.Name)
+
+			if err := createReplica(restclient, cluster, config.LABEL_TEMPORARY_REPLICA); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	// otherwise, the pending temporary replicas are no longer needed, and neither are as many of
+	// the ready temporary replicas as can be removed without dropping below the floor
+	remove := pendingTemporary
+	for i := 0; i < len(readyTemporary) && readyCount-i > minReadyReplicas; i++ {
+		remove = append(remove, readyTemporary[i])
+	}
+
+	for _, replicaName := range remove {
+		log.Infof("replica floor: removing temporary replica %s from cluster %s", replicaName,
+			cluster.Name)
+
+		if err := removeReplica(restclient, cluster, replicaName); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// createReplica creates a pgreplica for a replica that is added to the cluster by the Operator.
+// It is given the label provided, e.g. so that temporary replicas can be picked when scaling back
+func createReplica(restclient *rest.RESTClient, cluster *crv1.Pgcluster, label string) error {
+	replicaName := cluster.Name + "-" + util.RandStringBytesRmndr(4)
+
+	userLabels := map[string]string{}
+	for k, v := range cluster.Spec.UserLabels {
+		userLabels[k] = v
+	}
+	userLabels[config.LABEL_NODE_LABEL_KEY] = ""
+	userLabels[config.LABEL_NODE_LABEL_VALUE] = ""
+
+	replica := &crv1.Pgreplica{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name: replicaName,
+			Labels: map[string]string{
+				config.LABEL_NAME:                  replicaName,
+				config.LABEL_PG_CLUSTER:            cluster.Name,
+				config.LABEL_PG_CLUSTER_IDENTIFIER: cluster.Labels[config.LABEL_PG_CLUSTER_IDENTIFIER],
+				config.LABEL_PGOUSER:               cluster.Labels[config.LABEL_PGOUSER],
+				label:                              config.LABEL_TRUE,
+			},
+		},
+		Spec: crv1.PgreplicaSpec{
+			Namespace:      cluster.Namespace,
+			Name:           replicaName,
+			ClusterName:    cluster.Name,
+			ReplicaStorage: cluster.Spec.ReplicaStorage,
+			UserLabels:     userLabels,
+		},
+		Status: crv1.PgreplicaStatus{
+			State:   crv1.PgreplicaStateCreated,
+			Message: "Created, not processed yet",
+		},
+	}
+
+	log.Debugf("creating pgreplica %s for cluster %s", replicaName, cluster.Name)
+
+	return kubeapi.Createpgreplica(restclient, replica, cluster.Namespace)
+}
+
+// isReplicaPodReady returns true if the database container of the Pod is ready
+func isReplicaPodReady(pod *v1.Pod) bool {
+	if pod.DeletionTimestamp != nil {
+		return false
+	}
+
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.Name == "database" {
+			return status.Ready
+		}
+	}
+
+	return false
+}
+
+// removeReplica creates the pgtask that removes a replica that the Operator added, e.g. a
+// temporary one, along with its data, unless its removal is already underway
+func removeReplica(restclient *rest.RESTClient, cluster *crv1.Pgcluster, replicaName string) error {
+	taskName := replicaName + "-rmdata"
+
+	if found, _ := kubeapi.Getpgtask(restclient, &crv1.Pgtask{}, taskName, cluster.Namespace); found {
+		return nil
+	}
+
+	task := &crv1.Pgtask{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name: taskName,
+			Labels: map[string]string{
+				config.LABEL_PG_CLUSTER: cluster.Name,
+				config.LABEL_RMDATA:     config.LABEL_TRUE,
+			},
+		},
+		Spec: crv1.PgtaskSpec{
+			Namespace: cluster.Namespace,
+			Name:      taskName,
+			TaskType:  crv1.PgtaskDeleteData,
+			Parameters: map[string]string{
+				config.LABEL_DELETE_DATA:    strconv.FormatBool(true),
+				config.LABEL_DELETE_BACKUPS: strconv.FormatBool(false),
+				config.LABEL_IS_REPLICA:     strconv.FormatBool(true),
+				config.LABEL_IS_BACKUP:      strconv.FormatBool(false),
+				config.LABEL_PG_CLUSTER:     cluster.Name,
+				config.LABEL_REPLICA_NAME:   replicaName,
+				config.LABEL_PGHA_SCOPE:     cluster.Labels[config.LABEL_PGHA_SCOPE],
+			},
+		},
+	}
+
+	log.Debugf("creating pgtask %s to remove replica %s from cluster %s", taskName, replicaName,
+		cluster.Name)
+
+	return kubeapi.Createpgtask(restclient, task, cluster.Namespace)
+}
@@ -0,0 +1,279 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/config"
+	"github.com/crunchydata/postgres-operator/kubeapi"
+	"github.com/crunchydata/postgres-operator/operator"
+	"github.com/crunchydata/postgres-operator/util"
+	log "github.com/sirupsen/logrus"
+	apps_v1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	// eventReasonReplicaLagEvicted is the reason of the Warning events that are recorded when a
+	// replica is taken out of the replica Service as it lags too far behind the primary
+	eventReasonReplicaLagEvicted = "ReplicaLagEvicted"
+
+	// eventReasonReplicaLagReadmitted is the reason of the Normal events that are recorded when a
+	// replica that lagged too far behind the primary caught up, and is put back into the replica
+	// Service
+	eventReasonReplicaLagReadmitted = "ReplicaLagReadmitted"
+)
+
+const (
+	// sqlCurrentWALLSN returns the position in the WAL that the primary wrote up to
+	sqlCurrentWALLSN = `SELECT pg_catalog.pg_current_wal_lsn();`
+
+	// sqlReplicaLag returns how many bytes of WAL a replica has yet to replay up to the position
+	// of the primary, and how long ago the last transaction that it replayed was committed, which
+	// is 0 once it has replayed up to the position of the primary
+	sqlReplicaLag = `SELECT pg_catalog.pg_wal_lsn_diff('%[1]s', pg_catalog.pg_last_wal_replay_lsn())::bigint,
+CASE WHEN pg_catalog.pg_last_wal_replay_lsn() >= '%[1]s' THEN 0
+ELSE COALESCE(EXTRACT(EPOCH FROM pg_catalog.now() - pg_catalog.pg_last_xact_replay_timestamp()), 0)::bigint
+END;`
+)
+
+// walLSNPattern is the format of a position in the WAL
+var walLSNPattern = regexp.MustCompile(`^[0-9A-F]+/[0-9A-F]+$`)
+
+// ValidateMaxReplicaLag returns an error if either limit of the replica lag of a cluster is
+// negative
+func ValidateMaxReplicaLag(cluster *crv1.Pgcluster) error {
+	spec := cluster.Spec.MaxReplicaLag
+
+	if spec.Bytes < 0 {
+		return fmt.Errorf("invalid max replica lag of %d bytes, it cannot be negative", spec.Bytes)
+	}
+
+	if spec.Seconds < 0 {
+		return fmt.Errorf("invalid max replica lag of %d seconds, it cannot be negative",
+			spec.Seconds)
+	}
+
+	return nil
+}
+
+// ReconcileReplicaLag checks how far each ready replica of a cluster is behind its primary, in
+// bytes of WAL and in time, and records it in the status of the pgreplica of the replica. A
+// replica that lags further behind than the max replica lag of the cluster allows is taken out
+// of the replica Service, so that applications do not read data from it that is out of date,
+// and it is put back once it has caught up. Replicas are marked as such with a label on their
+// Deployments, which the replica Service is reconciled against
+func ReconcileReplicaLag(clientset *kubernetes.Clientset, restclient *rest.RESTClient,
+	restconfig *rest.Config, cluster *crv1.Pgcluster) error {
+	if cluster.Status.State != crv1.PgclusterStateInitialized || cluster.Spec.Shutdown ||
+		cluster.Spec.Standby {
+		return nil
+	}
+
+	selector := fmt.Sprintf("%s=%s,%s=%s", config.LABEL_PG_CLUSTER, cluster.Name,
+		config.LABEL_PGHA_ROLE, config.LABEL_PGHA_ROLE_REPLICA)
+
+	pods, err := kubeapi.GetPods(clientset, selector, cluster.Namespace)
+	if err != nil || len(pods.Items) == 0 {
+		return err
+	}
+
+	// the primary is looked up by the namespace in the spec
+	cluster.Spec.Namespace = cluster.Namespace
+
+	primary, err := util.GetPrimaryPod(clientset, cluster)
+	if err != nil {
+		log.Debugf("replica lag: no primary of cluster %s to compare to: %s", cluster.Name, err)
+		return nil
+	}
+
+	lsn, err := execMaintenanceSQL(clientset, restconfig, primary, "postgres",
+		getWALSQL(cluster, sqlCurrentWALLSN))
+	if err != nil {
+		return err
+	}
+
+	if !walLSNPattern.MatchString(lsn) {
+		return fmt.Errorf("unexpected WAL position %q of primary %s", lsn, primary.Name)
+	}
+
+	selector = fmt.Sprintf("%s=%s", config.LABEL_PG_CLUSTER, cluster.Name)
+
+	deploymentList, err := kubeapi.GetDeployments(clientset, selector, cluster.Namespace)
+	if err != nil {
+		return err
+	}
+
+	deployments := map[string]*apps_v1.Deployment{}
+	for i := range deploymentList.Items {
+		deployments[deploymentList.Items[i].Name] = &deploymentList.Items[i]
+	}
+
+	replicaList := crv1.PgreplicaList{}
+	if err := kubeapi.GetpgreplicasBySelector(restclient, &replicaList, selector,
+		cluster.Namespace); err != nil {
+		return err
+	}
+
+	replicas := map[string]*crv1.Pgreplica{}
+	for i := range replicaList.Items {
+		replicas[replicaList.Items[i].Spec.Name] = &replicaList.Items[i]
+	}
+
+	now := time.Now()
+	checked := map[string]bool{}
+	changed := false
+
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		instance := pod.Labels[config.LABEL_DEPLOYMENT_NAME]
+
+		deployment, ok := deployments[instance]
+		if !ok || !isReplicaPodReady(pod) {
+			continue
+		}
+
+		lag, err := getReplicaLag(clientset, restconfig, cluster, pod, lsn)
+		if err != nil {
+			log.Warnf("replica lag: could not check the lag of replica %s: %s", instance, err)
+			continue
+		}
+
+		checked[instance] = true
+
+		// delayed replicas lag behind on purpose, and are kept out of the replica Service anyway
+		lag.LastCheck = now.Format(time.RFC3339)
+		lag.Evicted = isReplicaLagging(lag, cluster.Spec.MaxReplicaLag) &&
+			deployment.ObjectMeta.Labels[config.LABEL_DELAYED_REPLICA] != "true"
+
+		if lag.Evicted != (deployment.ObjectMeta.Labels[config.LABEL_LAGGING_REPLICA] == "true") {
+			if err := setReplicaLagging(clientset, deployment, lag.Evicted); err != nil {
+				return err
+			}
+
+			changed = true
+
+			if lag.Evicted {
+				operator.RecordWarningEvent(clientset, cluster, eventReasonReplicaLagEvicted,
+					fmt.Sprintf("replica %s is %d bytes and %d seconds behind the primary, taking "+
+						"it out of the replica service until it catches up", instance, lag.Bytes,
+						lag.Seconds))
+			} else {
+				operator.RecordNormalEvent(clientset, cluster, eventReasonReplicaLagReadmitted,
+					fmt.Sprintf("replica %s caught up with the primary, putting it back into the "+
+						"replica service", instance))
+			}
+		}
+
+		if replica, ok := replicas[instance]; ok {
+			if err := kubeapi.PatchpgreplicaLagStatus(restclient, lag, replica,
+				cluster.Namespace); err != nil {
+				log.Errorf("replica lag: could not record the lag of replica %s: %s", instance, err)
+			}
+		}
+	}
+
+	// the replicas that were not checked keep their mark until they are, unless the max replica
+	// lag was removed or they were promoted in the meantime
+	primaryInstance := primary.Labels[config.LABEL_DEPLOYMENT_NAME]
+
+	for name, deployment := range deployments {
+		if deployment.ObjectMeta.Labels[config.LABEL_LAGGING_REPLICA] != "true" || checked[name] ||
+			(cluster.Spec.MaxReplicaLag != (crv1.MaxReplicaLagSpec{}) && name != primaryInstance) {
+			continue
+		}
+
+		if err := setReplicaLagging(clientset, deployment, false); err != nil {
+			return err
+		}
+
+		changed = true
+	}
+
+	if !changed {
+		return nil
+	}
+
+	if err := ReconcileReplicaService(clientset, cluster.Name, cluster.Namespace); err != nil {
+		return err
+	}
+
+	return UpdateReadOnlyEndpoints(clientset, cluster)
+}
+
+// getReplicaLag returns how far the replica of a Pod is behind the given position of the
+// primary in the WAL. The position of the primary is looked up before the lag of the replica,
+// so a replica that caught up in the meantime is not behind at all
+func getReplicaLag(clientset *kubernetes.Clientset, restconfig *rest.Config,
+	cluster *crv1.Pgcluster, pod *v1.Pod, lsn string) (crv1.ReplicaLagStatus, error) {
+	lag := crv1.ReplicaLagStatus{}
+
+	result, err := execMaintenanceSQL(clientset, restconfig, pod, "postgres",
+		getWALSQL(cluster, fmt.Sprintf(sqlReplicaLag, lsn)))
+	if err != nil {
+		return lag, err
+	}
+
+	fields := strings.Split(result, "|")
+	if len(fields) != 2 {
+		return lag, fmt.Errorf("unexpected replica lag %q", result)
+	}
+
+	if lag.Bytes, err = strconv.ParseInt(fields[0], 10, 64); err != nil {
+		return lag, fmt.Errorf("unexpected replica lag %q", result)
+	}
+
+	if lag.Seconds, err = strconv.ParseInt(fields[1], 10, 64); err != nil {
+		return lag, fmt.Errorf("unexpected replica lag %q", result)
+	}
+
+	if lag.Bytes < 0 {
+		lag.Bytes = 0
+	}
+
+	return lag, nil
+}
+
+// isReplicaLagging returns whether a replica lags further behind the primary than either limit
+// of the max replica lag, if it is set
+func isReplicaLagging(lag crv1.ReplicaLagStatus, limit crv1.MaxReplicaLagSpec) bool {
+	return (limit.Bytes > 0 && lag.Bytes > limit.Bytes) ||
+		(limit.Seconds > 0 && lag.Seconds > limit.Seconds)
+}
+
+// setReplicaLagging marks the Deployment of a replica as lagging too far behind the primary, or
+// removes the mark
+func setReplicaLagging(clientset *kubernetes.Clientset, deployment *apps_v1.Deployment,
+	lagging bool) error {
+	log.Infof("replica lag: marking replica %s as lagging: %t", deployment.Name, lagging)
+
+	if lagging {
+		return kubeapi.AddLabelToDeployment(clientset, deployment, config.LABEL_LAGGING_REPLICA,
+			"true", deployment.Namespace)
+	}
+
+	delete(deployment.ObjectMeta.Labels, config.LABEL_LAGGING_REPLICA)
+
+	return kubeapi.UpdateDeployment(clientset, deployment)
+}
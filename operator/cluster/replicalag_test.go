@@ -0,0 +1,43 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"testing"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+)
+
+func TestIsReplicaLagging(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		lag      crv1.ReplicaLagStatus
+		limit    crv1.MaxReplicaLagSpec
+		expected bool
+	}{
+		{"no limit", crv1.ReplicaLagStatus{Bytes: 1 << 30, Seconds: 3600}, crv1.MaxReplicaLagSpec{}, false},
+		{"bytes over", crv1.ReplicaLagStatus{Bytes: 2048}, crv1.MaxReplicaLagSpec{Bytes: 1024, Seconds: 60}, true},
+		{"seconds over", crv1.ReplicaLagStatus{Seconds: 61}, crv1.MaxReplicaLagSpec{Bytes: 1024, Seconds: 60}, true},
+		{"seconds only", crv1.ReplicaLagStatus{Bytes: 2048, Seconds: 30}, crv1.MaxReplicaLagSpec{Seconds: 60}, false},
+		{"at the limits", crv1.ReplicaLagStatus{Bytes: 1024, Seconds: 60}, crv1.MaxReplicaLagSpec{Bytes: 1024, Seconds: 60}, false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			if lagging := isReplicaLagging(test.lag, test.limit); lagging != test.expected {
+				t.Fatalf("expected %t, got %t", test.expected, lagging)
+			}
+		})
+	}
+}
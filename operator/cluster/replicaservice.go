@@ -0,0 +1,236 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/crunchydata/postgres-operator/config"
+	"github.com/crunchydata/postgres-operator/kubeapi"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ReconcileReplicaService has the replica Service of a cluster select its replicas as usual,
+// unless any of them is kept out of it, because it is delayed or lags too far behind the
+// primary. The selector of a Service cannot leave Pods out, so the Operator then takes over: the
+// selector is removed from the Service, and its Endpoints are kept to the other replicas. The
+// selector is put back once no replica of the cluster is kept out anymore
+func ReconcileReplicaService(clientset *kubernetes.Clientset, clusterName, namespace string) error {
+	service, found, err := kubeapi.GetService(clientset, clusterName+"-replica", namespace)
+	if !found {
+		// a cluster without replicas may not have a replica Service
+		if kerrors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+
+	return reconcileReplicaService(clientset, service, clusterName)
+}
+
+// UpdateReplicaEndpoints keeps the Endpoints of the replica Service of a cluster to its replicas
+// that are not kept out of it as they come and go, while the Operator manages them instead of
+// Kubernetes. Replicas that are not ready are listed as such, so that the Service does not send
+// them connections either
+func UpdateReplicaEndpoints(clientset *kubernetes.Clientset, clusterName, namespace string) error {
+	service, found, err := kubeapi.GetService(clientset, clusterName+"-replica", namespace)
+	if !found {
+		if kerrors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+
+	// Kubernetes manages the Endpoints of a Service that selects its Pods
+	if len(service.Spec.Selector) > 0 {
+		return nil
+	}
+
+	return reconcileReplicaService(clientset, service, clusterName)
+}
+
+// reconcileReplicaService sets the selector of the replica Service of a cluster, or its
+// Endpoints while any replica of the cluster is kept out of it
+func reconcileReplicaService(clientset *kubernetes.Clientset, service *v1.Service,
+	clusterName string) error {
+	excluded, err := getExcludedReplicas(clientset, clusterName, service.Namespace)
+	if err != nil {
+		return err
+	}
+
+	selector := map[string]string{
+		config.LABEL_PG_CLUSTER: clusterName,
+		config.LABEL_PGHA_ROLE:  config.LABEL_PGHA_ROLE_REPLICA,
+	}
+	if len(excluded) > 0 {
+		selector = nil
+	}
+
+	if len(service.Spec.Selector) != len(selector) {
+		log.Infof("replica service: replica service of cluster %s selects its replicas: %t",
+			clusterName, selector != nil)
+
+		service.Spec.Selector = selector
+		if err := kubeapi.UpdateService(clientset, service, service.Namespace); err != nil {
+			return err
+		}
+	}
+
+	if selector != nil {
+		return nil
+	}
+
+	return updateReplicaEndpoints(clientset, service, clusterName, excluded)
+}
+
+// updateReplicaEndpoints sets the Endpoints of the replica Service of a cluster to its replicas
+// other than the ones that are kept out of it
+func updateReplicaEndpoints(clientset *kubernetes.Clientset, service *v1.Service,
+	clusterName string, excluded []string) error {
+	selector := fmt.Sprintf("%s=%s,%s=%s", config.LABEL_PG_CLUSTER, clusterName,
+		config.LABEL_PGHA_ROLE, config.LABEL_PGHA_ROLE_REPLICA)
+
+	pods, err := kubeapi.GetPods(clientset, selector, service.Namespace)
+	if err != nil {
+		return err
+	}
+
+	return setServiceEndpoints(clientset, service, getReplicaEndpointSubsets(service, pods.Items,
+		excluded))
+}
+
+// setServiceEndpoints sets the Endpoints of a Service that does not select its Pods to the given
+// subsets, creating them if the Service does not have any yet
+func setServiceEndpoints(clientset *kubernetes.Clientset, service *v1.Service,
+	subsets []v1.EndpointSubset) error {
+	response, err := kubeapi.GetEndpoint(&kubeapi.GetEndpointRequest{
+		Clientset: clientset,
+		Name:      service.Name,
+		Namespace: service.Namespace,
+	})
+	if kerrors.IsNotFound(err) {
+		endpoints := &v1.Endpoints{
+			ObjectMeta: meta_v1.ObjectMeta{
+				Name:   service.Name,
+				Labels: service.ObjectMeta.Labels,
+			},
+			Subsets: subsets,
+		}
+
+		return kubeapi.CreateEndpoints(clientset, endpoints, service.Namespace)
+	} else if err != nil {
+		return err
+	}
+
+	if reflect.DeepEqual(response.Endpoint.Subsets, subsets) {
+		return nil
+	}
+
+	log.Debugf("updating endpoints of service %s", service.Name)
+
+	response.Endpoint.Subsets = subsets
+
+	return kubeapi.UpdateEndpoints(clientset, response.Endpoint, service.Namespace)
+}
+
+// getReplicaEndpointSubsets returns the Endpoints of the replica Service of a cluster for its
+// replica Pods that are not kept out of it, sorted as Kubernetes sorts them
+func getReplicaEndpointSubsets(service *v1.Service, pods []v1.Pod,
+	excluded []string) []v1.EndpointSubset {
+	subset := v1.EndpointSubset{}
+
+	for i := range pods {
+		pod := &pods[i]
+
+		if pod.DeletionTimestamp != nil || pod.Status.PodIP == "" ||
+			containsString(excluded, pod.ObjectMeta.Labels[config.LABEL_DEPLOYMENT_NAME]) {
+			continue
+		}
+
+		address := v1.EndpointAddress{
+			IP: pod.Status.PodIP,
+			TargetRef: &v1.ObjectReference{
+				Kind:      "Pod",
+				Namespace: pod.Namespace,
+				Name:      pod.Name,
+				UID:       pod.UID,
+			},
+		}
+		if pod.Spec.NodeName != "" {
+			nodeName := pod.Spec.NodeName
+			address.NodeName = &nodeName
+		}
+
+		if isReplicaPodReady(pod) {
+			subset.Addresses = append(subset.Addresses, address)
+		} else {
+			subset.NotReadyAddresses = append(subset.NotReadyAddresses, address)
+		}
+	}
+
+	// a subset without any address is not valid
+	if len(subset.Addresses) == 0 && len(subset.NotReadyAddresses) == 0 {
+		return nil
+	}
+
+	sort.Slice(subset.Addresses, func(i, j int) bool {
+		return subset.Addresses[i].IP < subset.Addresses[j].IP
+	})
+	sort.Slice(subset.NotReadyAddresses, func(i, j int) bool {
+		return subset.NotReadyAddresses[i].IP < subset.NotReadyAddresses[j].IP
+	})
+
+	for _, port := range service.Spec.Ports {
+		subset.Ports = append(subset.Ports, v1.EndpointPort{
+			Name:     port.Name,
+			Port:     int32(port.TargetPort.IntValue()),
+			Protocol: port.Protocol,
+		})
+	}
+
+	return []v1.EndpointSubset{subset}
+}
+
+// getExcludedReplicas returns the names of the replicas of a cluster that are kept out of its
+// replica Service, which are the ones that are delayed or lag too far behind the primary, going
+// by the labels on their Deployments
+func getExcludedReplicas(clientset *kubernetes.Clientset, clusterName,
+	namespace string) ([]string, error) {
+	selector := fmt.Sprintf("%s=%s", config.LABEL_PG_CLUSTER, clusterName)
+
+	deployments, err := kubeapi.GetDeployments(clientset, selector, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	excluded := []string{}
+	for _, deployment := range deployments.Items {
+		if deployment.ObjectMeta.Labels[config.LABEL_DELAYED_REPLICA] == "true" ||
+			deployment.ObjectMeta.Labels[config.LABEL_LAGGING_REPLICA] == "true" {
+			excluded = append(excluded, deployment.Name)
+		}
+	}
+
+	sort.Strings(excluded)
+
+	return excluded, nil
+}
@@ -0,0 +1,85 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"testing"
+
+	"github.com/crunchydata/postgres-operator/config"
+	v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func TestGetReplicaEndpointSubsets(t *testing.T) {
+	service := &v1.Service{
+		Spec: v1.ServiceSpec{
+			Ports: []v1.ServicePort{
+				{Name: "postgres", Port: 5432, TargetPort: intstr.FromInt(5432), Protocol: v1.ProtocolTCP},
+			},
+		},
+	}
+
+	pod := func(name, instance, ip string, ready bool) v1.Pod {
+		return v1.Pod{
+			ObjectMeta: meta_v1.ObjectMeta{
+				Name:   name,
+				Labels: map[string]string{config.LABEL_DEPLOYMENT_NAME: instance},
+			},
+			Status: v1.PodStatus{
+				PodIP: ip,
+				ContainerStatuses: []v1.ContainerStatus{
+					{Name: "database", Ready: ready},
+				},
+			},
+		}
+	}
+
+	t.Run("excluded", func(t *testing.T) {
+		subsets := getReplicaEndpointSubsets(service, []v1.Pod{
+			pod("hippo-efgh-1", "hippo-efgh", "10.0.0.2", true),
+			pod("hippo-abcd-1", "hippo-abcd", "10.0.0.1", true),
+			pod("hippo-ijkl-1", "hippo-ijkl", "10.0.0.3", false),
+			pod("hippo-mnop-1", "hippo-mnop", "10.0.0.4", true),
+		}, []string{"hippo-mnop"})
+
+		if len(subsets) != 1 {
+			t.Fatalf("expected one subset, got %d", len(subsets))
+		}
+
+		subset := subsets[0]
+
+		if len(subset.Addresses) != 2 || subset.Addresses[0].IP != "10.0.0.1" ||
+			subset.Addresses[1].IP != "10.0.0.2" {
+			t.Fatalf("expected the ready replicas that are not excluded, got %v", subset.Addresses)
+		}
+		if len(subset.NotReadyAddresses) != 1 || subset.NotReadyAddresses[0].IP != "10.0.0.3" {
+			t.Fatalf("expected the replica that is not ready, got %v", subset.NotReadyAddresses)
+		}
+		if len(subset.Ports) != 1 || subset.Ports[0].Port != 5432 {
+			t.Fatalf("expected the target port of the service, got %v", subset.Ports)
+		}
+	})
+
+	t.Run("only excluded", func(t *testing.T) {
+		if subsets := getReplicaEndpointSubsets(service, []v1.Pod{
+			pod("hippo-mnop-1", "hippo-mnop", "10.0.0.4", true),
+			pod("hippo-qrst-1", "hippo-qrst", "", false),
+		}, []string{"hippo-mnop"}); subsets != nil {
+			t.Fatalf("expected no subsets, got %v", subsets)
+		}
+	})
+}
@@ -0,0 +1,427 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/config"
+	"github.com/crunchydata/postgres-operator/kubeapi"
+	"github.com/crunchydata/postgres-operator/operator"
+	"github.com/crunchydata/postgres-operator/util"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	// eventReasonResourcesApplied is the reason of the Normal events that are recorded when the
+	// recommended resources of a cluster were rolled out to its instances
+	eventReasonResourcesApplied = "RecommendedResourcesApplied"
+	// eventReasonResourcesApplyFailed is the reason of the Warning events that are recorded when
+	// the recommended resources of a cluster could not be rolled out to its instances
+	eventReasonResourcesApplyFailed = "RecommendedResourcesApplyFailed"
+
+	// the recommended CPU is rounded up to a multiple of 10 millicores, and the recommended
+	// memory to a multiple of a mebibyte
+	recommendedCPUStep    = 10
+	recommendedMemoryStep = 1024 * 1024
+)
+
+// resourceRollouts tracks the clusters whose recommended resources are being rolled out, so that
+// a rollout is not started twice
+var resourceRollouts sync.Map
+
+// ValidateResourceRecommendation returns an error if the resources of a cluster cannot be
+// recommended as they are specified to, i.e. in an unknown mode, with negative settings or,
+// in "auto" mode, without a valid schedule to apply them on
+func ValidateResourceRecommendation(cluster *crv1.Pgcluster) error {
+	spec := cluster.Spec.ResourceRecommendation
+
+	switch spec.Mode {
+	case "", crv1.ResourceRecommendationOff, crv1.ResourceRecommendationRecommend,
+		crv1.ResourceRecommendationAuto:
+	default:
+		return fmt.Errorf("invalid resource recommendation mode %q, must be %q, %q or %q", spec.Mode,
+			crv1.ResourceRecommendationOff, crv1.ResourceRecommendationRecommend,
+			crv1.ResourceRecommendationAuto)
+	}
+
+	if spec.MarginPercent < 0 {
+		return fmt.Errorf("invalid resource recommendation margin of %d%%, it cannot be negative",
+			spec.MarginPercent)
+	}
+
+	if spec.HalfLifeHours < 0 {
+		return fmt.Errorf("invalid resource recommendation half-life of %d hours, it cannot be negative",
+			spec.HalfLifeHours)
+	}
+
+	if spec.Mode == crv1.ResourceRecommendationAuto && spec.ApplySchedule == "" {
+		return errors.New("applying the recommended resources requires an apply schedule")
+	}
+
+	if spec.ApplySchedule != "" {
+		if _, err := util.ScheduleParser.Parse(spec.ApplySchedule); err != nil {
+			return fmt.Errorf("%q is not a valid apply schedule: %s", spec.ApplySchedule, err)
+		}
+	}
+
+	return nil
+}
+
+// ReconcileResourceRecommendation samples the CPU and memory usage of the database containers of
+// a cluster and folds it into their decaying peaks, which are recorded in the status of the
+// cluster. Once the usage has been observed for a half-life, the resources that are recommended
+// from the peaks are set on the cluster as its "recommended-resources" annotation. In "auto" mode
+// the recommended resources are also rolled out to the instances, one at a time, whenever the
+// apply schedule comes up, after which they are set in the spec of the cluster
+func ReconcileResourceRecommendation(clientset *kubernetes.Clientset, restclient *rest.RESTClient,
+	restconfig *rest.Config, cluster *crv1.Pgcluster) error {
+	if cluster.Status.State != crv1.PgclusterStateInitialized || cluster.Spec.Shutdown {
+		return nil
+	}
+
+	spec := cluster.Spec.ResourceRecommendation
+	selector := fmt.Sprintf("%s=%s,%s", config.LABEL_PG_CLUSTER, cluster.Name, config.LABEL_PGHA_ROLE)
+
+	metrics, err := kubeapi.GetPodMetrics(clientset, selector, cluster.Namespace)
+	if err != nil {
+		return err
+	}
+
+	cpu, memory, sampled := getPeakContainerUsage(metrics)
+	if !sampled {
+		return nil
+	}
+
+	now := time.Now()
+
+	status, err := getResourceRecommendationStatus(spec, cluster.Status.ResourceRecommendation,
+		cpu, memory, now)
+	if err != nil {
+		return err
+	}
+
+	recommended, ok, err := getRecommendedResources(spec, status, now)
+	if err != nil {
+		return err
+	}
+
+	if ok {
+		if err := recommendResources(clientset, restclient, restconfig, cluster, &status,
+			recommended, now); err != nil {
+			return err
+		}
+	}
+
+	return kubeapi.Patchpgcluster(restclient, cluster, cluster.Namespace, func(cluster *crv1.Pgcluster) {
+		cluster.Status.ResourceRecommendation = status
+	})
+}
+
+// recommendResources annotates a cluster with the resources that are recommended for it, and in
+// "auto" mode applies them if the apply schedule has come up, which is recorded in the status
+func recommendResources(clientset *kubernetes.Clientset, restclient *rest.RESTClient,
+	restconfig *rest.Config, cluster *crv1.Pgcluster, status *crv1.ResourceRecommendationStatus,
+	recommended crv1.PgContainerResources, now time.Time) error {
+	value, err := json.Marshal(recommended)
+	if err != nil {
+		return err
+	}
+
+	if cluster.Annotations[config.ANNOTATION_RECOMMENDED_RESOURCES] != string(value) {
+		log.Debugf("resource recommendation: recommending %s for cluster %s", value, cluster.Name)
+
+		if cluster.Annotations == nil {
+			cluster.Annotations = map[string]string{}
+		}
+		cluster.Annotations[config.ANNOTATION_RECOMMENDED_RESOURCES] = string(value)
+
+		if err := kubeapi.Updatepgcluster(restclient, cluster, cluster.Name,
+			cluster.Namespace); err != nil {
+			return err
+		}
+	}
+
+	spec := cluster.Spec.ResourceRecommendation
+	if spec.Mode != crv1.ResourceRecommendationAuto {
+		return nil
+	}
+
+	due, err := isScheduleDue(spec.ApplySchedule, status.LastApplyTime,
+		cluster.CreationTimestamp.Time, now)
+	if err != nil || !due {
+		return err
+	}
+
+	if recommended != getAppliedResources(cluster.Spec.ContainerResources) {
+		// the schedule is not marked as done until the resources can be applied
+		deferred, err := deferMaintenanceAction(clientset, restclient, cluster,
+			maintenanceActionRecommendedResources)
+		if err != nil || deferred {
+			return err
+		}
+
+		applyRecommendedResources(clientset, restclient, restconfig, cluster, recommended)
+	}
+
+	status.LastApplyTime = now.UTC().Format(time.RFC3339)
+
+	return nil
+}
+
+// getPeakContainerUsage returns the highest CPU usage, in millicores, and the highest memory
+// usage, in bytes, of the database containers in the metrics of the instances, along with
+// whether any of them were sampled at all
+func getPeakContainerUsage(metrics []kubeapi.PodMetrics) (int64, int64, bool) {
+	cpu, memory, sampled := int64(0), int64(0), false
+
+	for _, pod := range metrics {
+		for _, container := range pod.Containers {
+			if container.Name != "database" {
+				continue
+			}
+
+			usedCPU, usedMemory := container.Usage[v1.ResourceCPU], container.Usage[v1.ResourceMemory]
+
+			if usedCPU.MilliValue() > cpu {
+				cpu = usedCPU.MilliValue()
+			}
+			if usedMemory.Value() > memory {
+				memory = usedMemory.Value()
+			}
+			sampled = true
+		}
+	}
+
+	return cpu, memory, sampled
+}
+
+// getResourceRecommendationStatus folds a sample of the usage into the peaks of the status. A
+// peak decays by half every half-life since it was last sampled, and is replaced by the sample
+// once the sample is higher, so that the peaks follow the usage down slowly and up right away
+func getResourceRecommendationStatus(spec crv1.ResourceRecommendationSpec,
+	status crv1.ResourceRecommendationStatus, cpu, memory int64,
+	now time.Time) (crv1.ResourceRecommendationStatus, error) {
+	decay := 1.0
+
+	if status.LastSampleTime != "" {
+		last, err := time.Parse(time.RFC3339, status.LastSampleTime)
+		if err != nil {
+			return status, err
+		}
+
+		halfLife := time.Duration(spec.GetHalfLifeHours()) * time.Hour
+		decay = math.Pow(0.5, now.Sub(last).Hours()/halfLife.Hours())
+	}
+
+	for _, peak := range []struct {
+		value  *string
+		sample int64
+		milli  bool
+		format resource.Format
+	}{
+		{&status.CPUPeak, cpu, true, resource.DecimalSI},
+		{&status.MemoryPeak, memory, false, resource.BinarySI},
+	} {
+		previous := int64(0)
+
+		if *peak.value != "" {
+			quantity, err := resource.ParseQuantity(*peak.value)
+			if err != nil {
+				return status, err
+			}
+
+			previous = quantity.Value()
+			if peak.milli {
+				previous = quantity.MilliValue()
+			}
+		}
+
+		value := int64(float64(previous) * decay)
+		if peak.sample > value {
+			value = peak.sample
+		}
+
+		if peak.milli {
+			*peak.value = resource.NewMilliQuantity(value, peak.format).String()
+		} else {
+			*peak.value = resource.NewQuantity(value, peak.format).String()
+		}
+	}
+
+	if status.ObservedSince == "" {
+		status.ObservedSince = now.UTC().Format(time.RFC3339)
+	}
+	status.LastSampleTime = now.UTC().Format(time.RFC3339)
+
+	return status, nil
+}
+
+// getRecommendedResources returns the resources that are recommended for the database
+// containers of a cluster, which are the peaks of the usage with the margin on top. The memory
+// limit is the same as the memory request, as PostgreSQL does not cope well with being killed
+// for exceeding its memory, while the CPU is not limited so as to not throttle it. Nothing is
+// recommended until the usage has been observed for a half-life
+func getRecommendedResources(spec crv1.ResourceRecommendationSpec,
+	status crv1.ResourceRecommendationStatus, now time.Time) (crv1.PgContainerResources, bool, error) {
+	recommended := crv1.PgContainerResources{}
+
+	since, err := time.Parse(time.RFC3339, status.ObservedSince)
+	if err != nil {
+		return recommended, false, err
+	}
+
+	if now.Sub(since) < time.Duration(spec.GetHalfLifeHours())*time.Hour {
+		return recommended, false, nil
+	}
+
+	cpuPeak, err := resource.ParseQuantity(status.CPUPeak)
+	if err != nil {
+		return recommended, false, err
+	}
+
+	memoryPeak, err := resource.ParseQuantity(status.MemoryPeak)
+	if err != nil {
+		return recommended, false, err
+	}
+
+	margin := int64(100 + spec.GetMarginPercent())
+
+	cpu := roundUpResource(cpuPeak.MilliValue()*margin/100, recommendedCPUStep)
+	memory := roundUpResource(memoryPeak.Value()*margin/100, recommendedMemoryStep)
+
+	recommended.RequestsCPU = resource.NewMilliQuantity(cpu, resource.DecimalSI).String()
+	recommended.RequestsMemory = resource.NewQuantity(memory, resource.BinarySI).String()
+	recommended.LimitsMemory = recommended.RequestsMemory
+
+	return recommended, true, nil
+}
+
+// roundUpResource rounds a quantity up to a multiple of the step, and to at least one step
+func roundUpResource(value, step int64) int64 {
+	if value < step {
+		return step
+	}
+	return (value + step - 1) / step * step
+}
+
+// getAppliedResources returns the resources of a spec that are recommended, i.e. all but the CPU
+// limit, to tell whether the recommended resources are applied already
+func getAppliedResources(resources crv1.PgContainerResources) crv1.PgContainerResources {
+	resources.LimitsCPU = ""
+	return resources
+}
+
+// applyRecommendedResources rolls the recommended resources out to the instances of a cluster
+// in the background, one at a time with a switchover before the primary, and then sets them in
+// the spec of the cluster, which the instances created afterward are given. As the instances
+// have them already, setting them in the spec does not restart the instances again. The
+// switchover relies on Patroni, so the resources are only rolled out while autofail is enabled
+func applyRecommendedResources(clientset *kubernetes.Clientset, restclient *rest.RESTClient,
+	restconfig *rest.Config, cluster *crv1.Pgcluster, recommended crv1.PgContainerResources) {
+	if !util.IsAutofailEnabled(cluster) {
+		operator.RecordWarningEvent(clientset, cluster, eventReasonResourcesApplyFailed,
+			"the recommended resources can only be applied while autofail is enabled")
+		return
+	}
+
+	key := cluster.Namespace + "/" + cluster.Name
+	if _, running := resourceRollouts.LoadOrStore(key, true); running {
+		return
+	}
+
+	resources := cluster.Spec.ContainerResources
+	resources.RequestsCPU = recommended.RequestsCPU
+	resources.RequestsMemory = recommended.RequestsMemory
+	resources.LimitsMemory = recommended.LimitsMemory
+
+	// a CPU limit below the new request would be rejected, so it is lifted along with it
+	if limit, err := resource.ParseQuantity(resources.LimitsCPU); err == nil &&
+		limit.Cmp(resource.MustParse(resources.RequestsCPU)) < 0 {
+		resources.LimitsCPU = ""
+	}
+
+	log.Infof("resource recommendation: applying requests of %s CPU and %s memory to cluster %s",
+		resources.RequestsCPU, resources.RequestsMemory, cluster.Name)
+
+	go func() {
+		defer resourceRollouts.Delete(key)
+
+		err := RollingRestart(clientset, restconfig, cluster, nil,
+			func(instance string) error {
+				return setInstanceResources(clientset, cluster.Namespace, instance, resources)
+			}, nil)
+
+		if err == nil {
+			err = updateClusterResources(restclient, cluster, resources)
+		}
+
+		if err != nil {
+			log.Errorf("resource recommendation: could not apply resources to cluster %s: %s",
+				cluster.Name, err)
+			operator.RecordWarningEvent(clientset, cluster, eventReasonResourcesApplyFailed,
+				fmt.Sprintf("the recommended resources could not be applied: %s", err))
+			return
+		}
+
+		operator.RecordNormalEvent(clientset, cluster, eventReasonResourcesApplied,
+			fmt.Sprintf("applied requests of %s CPU and %s memory to all instances",
+				resources.RequestsCPU, resources.RequestsMemory))
+	}()
+}
+
+// setInstanceResources sets the resources of the database container of an instance, which
+// restarts it if they changed
+func setInstanceResources(clientset *kubernetes.Clientset, namespace, instance string,
+	resources crv1.PgContainerResources) error {
+	deployment, _, err := kubeapi.GetDeployment(clientset, instance, namespace)
+	if err != nil {
+		return err
+	}
+
+	for i := range deployment.Spec.Template.Spec.Containers {
+		container := &deployment.Spec.Template.Spec.Containers[i]
+
+		if container.Name == "database" && setContainerResources(&container.Resources, resources) {
+			return kubeapi.UpdateDeployment(clientset, deployment)
+		}
+	}
+
+	return nil
+}
+
+// updateClusterResources sets the resources in the spec of the latest version of a cluster
+func updateClusterResources(restclient *rest.RESTClient, cluster *crv1.Pgcluster,
+	resources crv1.PgContainerResources) error {
+	latest := crv1.Pgcluster{}
+	if _, err := kubeapi.Getpgcluster(restclient, &latest, cluster.Name, cluster.Namespace); err != nil {
+		return err
+	}
+
+	latest.Spec.ContainerResources = resources
+
+	return kubeapi.Updatepgcluster(restclient, &latest, latest.Name, latest.Namespace)
+}
@@ -0,0 +1,123 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"testing"
+	"time"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestGetResourceRecommendationStatus(t *testing.T) {
+	spec := crv1.ResourceRecommendationSpec{HalfLifeHours: 1}
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	for _, test := range []struct {
+		name        string
+		status      crv1.ResourceRecommendationStatus
+		cpu, memory int64
+		cpuPeak     string
+		memoryPeak  string
+	}{
+		{"first sample", crv1.ResourceRecommendationStatus{}, 250, 512 * 1024 * 1024, "250m", "512Mi"},
+		{"higher sample", crv1.ResourceRecommendationStatus{CPUPeak: "100m", MemoryPeak: "1Gi",
+			LastSampleTime: "2020-06-01T12:00:00Z"}, 250, 64 * 1024 * 1024, "250m", "1Gi"},
+		{"decayed peak", crv1.ResourceRecommendationStatus{CPUPeak: "1", MemoryPeak: "1Gi",
+			LastSampleTime: "2020-06-01T11:00:00Z"}, 100, 64 * 1024 * 1024, "500m", "512Mi"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			status, err := getResourceRecommendationStatus(spec, test.status, test.cpu, test.memory, now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if status.CPUPeak != test.cpuPeak || status.MemoryPeak != test.memoryPeak {
+				t.Fatalf("expected peaks %s and %s, got %s and %s", test.cpuPeak, test.memoryPeak,
+					status.CPUPeak, status.MemoryPeak)
+			}
+			if status.LastSampleTime != "2020-06-01T12:00:00Z" {
+				t.Fatalf("expected the sample to be recorded, got %q", status.LastSampleTime)
+			}
+		})
+	}
+}
+
+func TestGetRecommendedResources(t *testing.T) {
+	now := time.Date(2020, 6, 2, 12, 0, 0, 0, time.UTC)
+	status := crv1.ResourceRecommendationStatus{
+		CPUPeak:       "123m",
+		MemoryPeak:    "500Mi",
+		ObservedSince: "2020-06-01T12:00:00Z",
+	}
+
+	t.Run("recommended", func(t *testing.T) {
+		recommended, ok, err := getRecommendedResources(crv1.ResourceRecommendationSpec{}, status, now)
+		if err != nil || !ok {
+			t.Fatalf("expected a recommendation, got %t: %v", ok, err)
+		}
+
+		expected := crv1.PgContainerResources{
+			RequestsCPU:    "150m",
+			RequestsMemory: "600Mi",
+			LimitsMemory:   "600Mi",
+		}
+		if recommended != expected {
+			t.Fatalf("expected %+v, got %+v", expected, recommended)
+		}
+	})
+
+	t.Run("not observed long enough", func(t *testing.T) {
+		_, ok, err := getRecommendedResources(crv1.ResourceRecommendationSpec{HalfLifeHours: 48},
+			status, now)
+		if err != nil || ok {
+			t.Fatalf("expected no recommendation, got %t: %v", ok, err)
+		}
+	})
+}
+
+func TestSetContainerResources(t *testing.T) {
+	resources := v1.ResourceRequirements{
+		Requests: v1.ResourceList{
+			v1.ResourceCPU:    resource.MustParse("500m"),
+			v1.ResourceMemory: resource.MustParse("1Gi"),
+		},
+		Limits: v1.ResourceList{
+			v1.ResourceCPU: resource.MustParse("2"),
+		},
+	}
+	spec := crv1.PgContainerResources{
+		RequestsCPU:    "500m",
+		RequestsMemory: "2Gi",
+		LimitsMemory:   "2Gi",
+	}
+
+	if !setContainerResources(&resources, spec) {
+		t.Fatal("expected the resources to change")
+	}
+
+	if _, ok := resources.Limits[v1.ResourceCPU]; ok {
+		t.Fatal("expected the CPU limit to be removed")
+	}
+	if memory := resources.Limits[v1.ResourceMemory]; memory.String() != "2Gi" {
+		t.Fatalf("expected a memory limit of 2Gi, got %s", memory.String())
+	}
+
+	if setContainerResources(&resources, spec) {
+		t.Fatal("expected the resources to be unchanged")
+	}
+}
@@ -0,0 +1,171 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/config"
+	"github.com/crunchydata/postgres-operator/operator"
+	log "github.com/sirupsen/logrus"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// defaultRestartBudgetTimeout is how long a restart waits for its turn before a warning is
+	// logged, and then again between warnings, if no timeout is configured
+	defaultRestartBudgetTimeout = 10 * time.Minute
+
+	// eventReasonRestartFailed is the reason of the Warning events that are recorded when a
+	// restart that waited for the restart budget failed
+	eventReasonRestartFailed = "RestartFailed"
+)
+
+// restartBudget limits the number of clusters that are restarted at the same time across all of
+// the namespaces watched by the Operator. Restarts that are over the budget wait in a queue,
+// ordered by the restart priority of their cluster and then by when they were queued
+type restartBudget struct {
+	mutex   sync.Mutex
+	running int
+	queue   []*restartRequest
+}
+
+// restartRequest is a restart that is waiting for its turn
+type restartRequest struct {
+	cluster  string
+	priority int
+	queued   time.Time
+	// started is closed once the restart can proceed
+	started chan struct{}
+}
+
+// budget is the restart budget that is shared by all of the controllers
+var budget = &restartBudget{}
+
+// RestartWithinBudget runs a restart of a cluster in the background once the restart budget
+// allows it, so that a change that restarts many clusters, e.g. a new default image, does not
+// restart all of them at once. As the restart runs after the change was handled, a restart that
+// fails is recorded as a Warning event on the cluster
+func RestartWithinBudget(clientset *kubernetes.Clientset, cluster *crv1.Pgcluster,
+	description string, restart func() error) {
+	go func() {
+		release := acquireRestartBudget(cluster)
+		defer release()
+
+		log.Debugf("restart budget: restarting cluster %s to apply %s", cluster.Name, description)
+
+		if err := restart(); err != nil {
+			log.Errorf("restart budget: could not restart cluster %s to apply %s: %s",
+				cluster.Name, description, err)
+
+			operator.RecordWarningEvent(clientset, cluster, eventReasonRestartFailed,
+				fmt.Sprintf("the cluster could not be restarted to apply %s: %s", description, err))
+		}
+	}()
+}
+
+// acquireRestartBudget waits until the restart of the cluster can proceed, and returns the
+// function that has to be called once the restart is done. A restart that does not get its turn
+// within the timeout keeps its place in the queue, so that the restarts that wait the longest
+// are not overtaken, and a warning is logged each time the timeout passes
+func acquireRestartBudget(cluster *crv1.Pgcluster) func() {
+	request := &restartRequest{
+		cluster:  cluster.Namespace + "/" + cluster.Name,
+		priority: getRestartPriority(cluster),
+		queued:   time.Now(),
+		started:  make(chan struct{}),
+	}
+
+	timeout := defaultRestartBudgetTimeout
+	if seconds := operator.Pgo.Pgo.RestartBudgetTimeoutSeconds; seconds > 0 {
+		timeout = time.Duration(seconds) * time.Second
+	}
+
+	budget.mutex.Lock()
+	budget.queue = append(budget.queue, request)
+	budget.dispatch()
+	budget.mutex.Unlock()
+
+	for {
+		select {
+		case <-request.started:
+			return budget.release
+		case <-time.After(timeout):
+			log.Warnf("restart budget: restart of cluster %s has not started after %s, still waiting",
+				request.cluster, time.Since(request.queued).Round(time.Second))
+		}
+	}
+}
+
+// dispatch starts as many of the queued restarts as the budget allows. The lock needs to be held
+func (b *restartBudget) dispatch() {
+	for len(b.queue) > 0 && (operator.Pgo.Pgo.RestartBudget <= 0 || b.running < operator.Pgo.Pgo.RestartBudget) {
+		next := b.queue[0]
+		for _, request := range b.queue[1:] {
+			if request.priority > next.priority ||
+				(request.priority == next.priority && request.queued.Before(next.queued)) {
+				next = request
+			}
+		}
+
+		b.remove(next)
+		b.running++
+		close(next.started)
+
+		log.Debugf("restart budget: starting restart of cluster %s, %d running, %d queued",
+			next.cluster, b.running, len(b.queue))
+	}
+}
+
+// release frees up the budget of a restart that is done, and starts the next queued restart
+func (b *restartBudget) release() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.running--
+	b.dispatch()
+}
+
+// remove takes the request out of the queue. The lock needs to be held
+func (b *restartBudget) remove(request *restartRequest) {
+	for i, r := range b.queue {
+		if r == request {
+			b.queue = append(b.queue[:i], b.queue[i+1:]...)
+			return
+		}
+	}
+}
+
+// getRestartPriority returns the restart priority of the cluster, which is set by the
+// "restart-priority" label. Clusters without a valid priority have a priority of 0
+func getRestartPriority(cluster *crv1.Pgcluster) int {
+	value, ok := cluster.Labels[config.LABEL_RESTART_PRIORITY]
+	if !ok {
+		return 0
+	}
+
+	priority, err := strconv.Atoi(value)
+	if err != nil {
+		log.Warnf("restart budget: invalid restart priority %q on cluster %s", value, cluster.Name)
+		return 0
+	}
+
+	return priority
+}
@@ -0,0 +1,67 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"testing"
+	"time"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/operator"
+)
+
+func TestAcquireRestartBudget(t *testing.T) {
+	operator.Pgo.Pgo.RestartBudget = 1
+	operator.Pgo.Pgo.RestartBudgetTimeoutSeconds = 1
+	defer func() {
+		operator.Pgo.Pgo.RestartBudget = 0
+		operator.Pgo.Pgo.RestartBudgetTimeoutSeconds = 0
+	}()
+
+	cluster := func(name string) *crv1.Pgcluster {
+		c := &crv1.Pgcluster{}
+		c.Name, c.Namespace = name, "pgo"
+		return c
+	}
+
+	started := make(chan string, 2)
+	restart := func(name string) {
+		release := acquireRestartBudget(cluster(name))
+		started <- name
+		release()
+	}
+
+	release := acquireRestartBudget(cluster("first"))
+
+	// the second restart waits past the timeout, and still goes before the one queued after it
+	go restart("second")
+	time.Sleep(500 * time.Millisecond)
+	go restart("third")
+	time.Sleep(1000 * time.Millisecond)
+
+	release()
+
+	for _, expected := range []string{"second", "third"} {
+		select {
+		case name := <-started:
+			if name != expected {
+				t.Fatalf("expected the restart of %s to start, got %s", expected, name)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("expected the restart of %s to start", expected)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/config"
+	"github.com/crunchydata/postgres-operator/kubeapi"
+	"github.com/crunchydata/postgres-operator/operator"
+	log "github.com/sirupsen/logrus"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	// defaultBackrestRetentionIntervalMinutes is how often the repository of a cluster is expired
+	// if no interval is set
+	defaultBackrestRetentionIntervalMinutes = 60
+
+	// eventReasonBackrestExpireFailed is the reason of the Warning events that are recorded when
+	// the repository of a cluster could not be expired
+	eventReasonBackrestExpireFailed = "BackrestExpireFailed"
+)
+
+// backrestRetentionArchiveTypes are the types of backups the archive retention can count
+var backrestRetentionArchiveTypes = map[string]bool{"": true, "full": true, "diff": true, "incr": true}
+
+// backrestExpiring holds the clusters whose repository is being expired, so that an expiry that
+// takes longer than the periodic interval is not started again while it runs
+var backrestExpiring sync.Map
+
+// ReconcileBackrestRetention expires the pgBackRest repository of the cluster according to its
+// retention once the interval has passed since it was last expired. The expiry runs in the
+// background on the pgBackRest repository, and its outcome is recorded in the status of the
+// cluster, along with a Warning event if it failed
+func ReconcileBackrestRetention(clientset *kubernetes.Clientset, restclient *rest.RESTClient,
+	restconfig *rest.Config, cluster *crv1.Pgcluster) error {
+	retention := cluster.Spec.BackrestRetention
+
+	if !isBackrestRetentionSet(retention) || cluster.Status.State != crv1.PgclusterStateInitialized {
+		return nil
+	}
+
+	if !isBackrestExpireDue(retention, cluster.Status.BackrestRetention, time.Now()) {
+		return nil
+	}
+
+	key := cluster.Namespace + "/" + cluster.Name
+	if _, running := backrestExpiring.LoadOrStore(key, true); running {
+		return nil
+	}
+
+	go func() {
+		defer backrestExpiring.Delete(key)
+
+		status := crv1.BackrestRetentionStatus{
+			LastExpire: time.Now().UTC().Format(time.RFC3339),
+			Succeeded:  true,
+		}
+
+		if err := expireBackrestRepo(clientset, restconfig, cluster); err != nil {
+			log.Errorf("could not expire pgBackRest repository of cluster %s: %s", cluster.Name, err)
+
+			status.Succeeded = false
+			status.Message = err.Error()
+
+			operator.RecordWarningEvent(clientset, cluster, eventReasonBackrestExpireFailed,
+				err.Error())
+		}
+
+		if err := kubeapi.Patchpgcluster(restclient, cluster, cluster.Namespace, func(cluster *crv1.Pgcluster) {
+			cluster.Status.BackrestRetention = status
+		}); err != nil {
+			log.Error(err)
+		}
+	}()
+
+	return nil
+}
+
+// isBackrestRetentionSet determines whether any of the retention of the repository is set
+func isBackrestRetentionSet(retention crv1.BackrestRetentionSpec) bool {
+	return retention.Full > 0 || retention.Diff > 0 || retention.Archive > 0
+}
+
+// isBackrestExpireDue determines whether the interval has passed since the repository was last
+// expired. A repository that has not been expired yet, or whose last expiry cannot be told, is
+// expired right away
+func isBackrestExpireDue(retention crv1.BackrestRetentionSpec, status crv1.BackrestRetentionStatus,
+	now time.Time) bool {
+	interval := retention.IntervalMinutes
+	if interval <= 0 {
+		interval = defaultBackrestRetentionIntervalMinutes
+	}
+
+	last, err := time.Parse(time.RFC3339, status.LastExpire)
+	if err != nil {
+		return true
+	}
+
+	return !now.Before(last.Add(time.Duration(interval) * time.Minute))
+}
+
+// validateBackrestRetention ensures that pgBackRest can expire the repository with the retention
+func validateBackrestRetention(retention crv1.BackrestRetentionSpec) error {
+	if retention.Full < 0 || retention.Diff < 0 || retention.Archive < 0 {
+		return errors.New("invalid pgBackRest retention, it cannot be negative")
+	}
+
+	if !backrestRetentionArchiveTypes[retention.ArchiveType] {
+		return fmt.Errorf("invalid pgBackRest archive retention type %q, must be full, diff or incr",
+			retention.ArchiveType)
+	}
+
+	return nil
+}
+
+// backrestExpireOptions returns the options of "pgbackrest expire" that apply the retention to
+// the repository
+func backrestExpireOptions(retention crv1.BackrestRetentionSpec) []string {
+	options := []string{"--stanza=db"}
+
+	if retention.Full > 0 {
+		options = append(options, fmt.Sprintf("--repo1-retention-full=%d", retention.Full))
+	}
+	if retention.Diff > 0 {
+		options = append(options, fmt.Sprintf("--repo1-retention-diff=%d", retention.Diff))
+	}
+	if retention.Archive > 0 {
+		options = append(options, fmt.Sprintf("--repo1-retention-archive=%d", retention.Archive))
+
+		if retention.ArchiveType != "" {
+			options = append(options, "--repo1-retention-archive-type="+retention.ArchiveType)
+		}
+	}
+
+	return options
+}
+
+// expireBackrestRepo runs "pgbackrest expire" on the pgBackRest repository of the cluster. A
+// cluster that stores its repository both locally and in S3 has both of them expired
+func expireBackrestRepo(clientset *kubernetes.Clientset, restconfig *rest.Config,
+	cluster *crv1.Pgcluster) error {
+	if err := validateBackrestRetention(cluster.Spec.BackrestRetention); err != nil {
+		return err
+	}
+
+	cmd := append([]string{"pgbackrest", "expire"}, backrestExpireOptions(cluster.Spec.BackrestRetention)...)
+
+	return execBackrestRepo(clientset, restconfig, cluster, cmd)
+}
+
+// execBackrestRepo runs a pgBackRest command in the pgBackRest repository Pod of the cluster. A
+// cluster that stores its repository both locally and in S3 has the command run against both
+func execBackrestRepo(clientset *kubernetes.Clientset, restconfig *rest.Config,
+	cluster *crv1.Pgcluster, cmd []string) error {
+	selector := fmt.Sprintf("%s=%s,%s=true", config.LABEL_PG_CLUSTER, cluster.Name,
+		config.LABEL_PGO_BACKREST_REPO)
+	pods, err := kubeapi.GetPods(clientset, selector, cluster.Namespace)
+	if err != nil {
+		return err
+	}
+
+	if len(pods.Items) != 1 {
+		return fmt.Errorf("expected 1 pgBackRest repository pod for cluster %s, found %d",
+			cluster.Name, len(pods.Items))
+	}
+
+	cmds := [][]string{}
+	storageType := cluster.Spec.UserLabels[config.LABEL_BACKREST_STORAGE_TYPE]
+
+	if storageType == "" || strings.Contains(storageType, "local") ||
+		operator.GetBackrestCloudType(cluster) != "" {
+		cmds = append(cmds, cmd)
+	}
+	if strings.Contains(storageType, "s3") {
+		cmds = append(cmds, append(cmd, "--repo-type=s3"))
+	}
+
+	for _, cmd := range cmds {
+		log.Debugf("running pgBackRest command on cluster %s: %v", cluster.Name, cmd)
+
+		if _, stderr, err := kubeapi.ExecToPodThroughAPI(restconfig, clientset, cmd, "database",
+			pods.Items[0].Name, cluster.Namespace, nil); err != nil {
+			if stderr = strings.TrimSpace(stderr); stderr != "" {
+				return fmt.Errorf("%s: %s", err, stderr)
+			}
+			return err
+		}
+	}
+
+	return nil
+}
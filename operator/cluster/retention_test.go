@@ -0,0 +1,83 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+)
+
+func TestIsBackrestExpireDue(t *testing.T) {
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	for _, test := range []struct {
+		interval   int
+		lastExpire string
+		expected   bool
+	}{
+		{0, "", true},
+		{0, "not a time", true},
+		{0, "2020-06-01T11:30:00Z", false},
+		{0, "2020-06-01T11:00:00Z", true},
+		{15, "2020-06-01T11:50:00Z", false},
+		{15, "2020-06-01T11:40:00Z", true},
+	} {
+		retention := crv1.BackrestRetentionSpec{Full: 2, IntervalMinutes: test.interval}
+		status := crv1.BackrestRetentionStatus{LastExpire: test.lastExpire}
+
+		if due := isBackrestExpireDue(retention, status, now); due != test.expected {
+			t.Errorf("interval %d, last expire %q: expected due: %v, got %v", test.interval,
+				test.lastExpire, test.expected, due)
+		}
+	}
+}
+
+func TestBackrestExpireOptions(t *testing.T) {
+	for _, test := range []struct {
+		retention crv1.BackrestRetentionSpec
+		expected  []string
+	}{
+		{crv1.BackrestRetentionSpec{Full: 2},
+			[]string{"--stanza=db", "--repo1-retention-full=2"}},
+		{crv1.BackrestRetentionSpec{Full: 2, Diff: 7, Archive: 3, ArchiveType: "diff"},
+			[]string{"--stanza=db", "--repo1-retention-full=2", "--repo1-retention-diff=7",
+				"--repo1-retention-archive=3", "--repo1-retention-archive-type=diff"}},
+		{crv1.BackrestRetentionSpec{Diff: 4, ArchiveType: "incr"},
+			[]string{"--stanza=db", "--repo1-retention-diff=4"}},
+	} {
+		if options := backrestExpireOptions(test.retention); !reflect.DeepEqual(options, test.expected) {
+			t.Errorf("expected %v, got %v", test.expected, options)
+		}
+	}
+}
+
+func TestValidateBackrestRetention(t *testing.T) {
+	for _, test := range []struct {
+		retention crv1.BackrestRetentionSpec
+		valid     bool
+	}{
+		{crv1.BackrestRetentionSpec{Full: 2, ArchiveType: "full"}, true},
+		{crv1.BackrestRetentionSpec{Full: -1}, false},
+		{crv1.BackrestRetentionSpec{Full: 2, ArchiveType: "weekly"}, false},
+	} {
+		if err := validateBackrestRetention(test.retention); (err == nil) != test.valid {
+			t.Errorf("%+v: expected valid: %v, got %v", test.retention, test.valid, err)
+		}
+	}
+}
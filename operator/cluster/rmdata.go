@@ -45,6 +45,8 @@ type RmdataJob struct {
 	IsBackup           string
 	IsReplica          string
 	ContainerResources string
+	NodeSelectorLabels string
+	Tolerations        string
 }
 
 // CreateService ...
@@ -74,6 +76,8 @@ func CreateRmdataJob(clientset *kubernetes.Clientset, cl *crv1.Pgcluster, namesp
 		IsBackup:           strconv.FormatBool(isReplica),
 		IsReplica:          strconv.FormatBool(isBackup),
 		ContainerResources: cr,
+		NodeSelectorLabels: operator.GetNodeSelectorJSON(cl),
+		Tolerations:        operator.GetTolerationsJSON(cl),
 	}
 
 	doc := bytes.Buffer{}
@@ -98,6 +102,9 @@ func CreateRmdataJob(clientset *kubernetes.Clientset, cl *crv1.Pgcluster, namesp
 	operator.SetContainerImageOverride(config.CONTAINER_IMAGE_PGO_RMDATA,
 		&newjob.Spec.Template.Spec.Containers[0])
 
+	// apply the node affinity and the PriorityClass of the cluster
+	operator.AddPodScheduling(cl, nil, &newjob.Spec.Template.Spec)
+
 	_, err = kubeapi.CreateJob(clientset, &newjob, namespace)
 	if err != nil {
 		return err
@@ -0,0 +1,522 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/config"
+	"github.com/crunchydata/postgres-operator/kubeapi"
+	"github.com/crunchydata/postgres-operator/operator"
+	"github.com/crunchydata/postgres-operator/util"
+	log "github.com/sirupsen/logrus"
+	apps_v1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	// how long to wait, in seconds, for an instance to be restarted and caught up, or for a
+	// replica to take over as the primary
+	rollingRestartTimeout = 600
+	rollingRestartPeriod  = 5
+
+	// rollingRestartMaxLag is how far behind the primary, in bytes, a restarted replica can be
+	// and still be considered caught up, which is a single WAL segment
+	rollingRestartMaxLag = 16 * 1024 * 1024
+
+	// eventReasonRollingRestartCompleted is the reason of the events that are recorded when all
+	// of the instances of a cluster were restarted by a rolling restart pgtask
+	eventReasonRollingRestartCompleted = "RollingRestartCompleted"
+
+	// eventReasonRollingRestartFailed is the reason of the Warning events that are recorded when
+	// a rolling restart pgtask failed
+	eventReasonRollingRestartFailed = "RollingRestartFailed"
+)
+
+// sqlReplicaCaughtUp returns 1 if the replica with the given application name, which Patroni
+// sets to the name of its Pod, is streaming from the primary and is not further behind than the
+// given number of bytes, and 0 otherwise
+const sqlReplicaCaughtUp = `SELECT count(*) FROM pg_catalog.pg_stat_replication
+WHERE application_name = '%s' AND state = 'streaming' AND
+pg_catalog.pg_wal_lsn_diff(pg_catalog.pg_current_wal_lsn(), replay_lsn) <= %d;`
+
+// rollingRestartsRunning holds the rolling restart pgtasks that are being carried out, so that a
+// pgtask that is in progress is only resumed if it is not running already
+var rollingRestartsRunning sync.Map
+
+// RollingRestartFunc restarts a single instance of a cluster, given the name of its Deployment,
+// e.g. by having Patroni restart PostgreSQL or by updating the Deployment. It returns once the
+// restart is underway, after which the rolling restart waits for the instance to be ready
+type RollingRestartFunc func(instance string) error
+
+// RollingRestart restarts the instances of a cluster one at a time with the given function, so
+// that the cluster stays available throughout. Each replica is restarted in turn, and has to be
+// ready and caught up with the primary before the next one is. The primary is then switched
+// over to one of the restarted replicas and restarted last, as a replica. A cluster without
+// replicas has its primary restarted in place. Instances that are completed already, e.g. by an
+// earlier attempt, are skipped, and each instance that is done is passed to progress, if it is
+// set. The rolling restart counts against the restart budget as a whole, so the caller must not
+// hold the budget itself
+func RollingRestart(clientset *kubernetes.Clientset, restconfig *rest.Config, cluster *crv1.Pgcluster,
+	completed []string, restart RollingRestartFunc, progress func(instance string) error) error {
+	release := acquireRestartBudget(cluster)
+	defer release()
+
+	// the primary is looked up by the namespace in the spec
+	cluster.Spec.Namespace = cluster.Namespace
+
+	deployments, err := operator.GetInstanceDeployments(clientset, cluster)
+	if err != nil {
+		return err
+	}
+
+	primaryPod, err := util.GetPrimaryPod(clientset, cluster)
+	if err != nil {
+		return err
+	}
+
+	instances := make([]string, 0, len(deployments.Items))
+	for _, deployment := range deployments.Items {
+		instances = append(instances, deployment.Name)
+	}
+
+	done := map[string]bool{}
+	for _, instance := range completed {
+		done[instance] = true
+	}
+
+	primary := primaryPod.ObjectMeta.Labels[config.LABEL_DEPLOYMENT_NAME]
+
+	for _, instance := range getRollingRestartOrder(instances, primary) {
+		if done[instance] {
+			continue
+		}
+
+		if instance == primary && len(instances) > 1 {
+			if err := switchoverRollingRestartPrimary(clientset, restconfig, cluster, primaryPod,
+				done); err != nil {
+				return err
+			}
+		}
+
+		log.Infof("rolling restart: restarting instance %s of cluster %s", instance, cluster.Name)
+
+		if err := restart(instance); err != nil {
+			return err
+		}
+
+		if err := waitForRollingRestartInstance(clientset, restconfig, cluster, instance); err != nil {
+			return err
+		}
+
+		done[instance] = true
+
+		if progress != nil {
+			if err := progress(instance); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// AddRollingRestart handles a "rolling-restart" pgtask, which restarts PostgreSQL on each of the
+// instances of a running cluster in turn, replicas first, with a switchover before the primary
+// is restarted. The restart runs in the background, and the instances that are done are
+// recorded in the pgtask as it progresses
+func AddRollingRestart(clientset *kubernetes.Clientset, restclient *rest.RESTClient,
+	restconfig *rest.Config, t *crv1.Pgtask, namespace string) {
+	clusterName := t.ObjectMeta.Labels[config.LABEL_PG_CLUSTER]
+
+	cluster := crv1.Pgcluster{}
+	if _, err := kubeapi.Getpgcluster(restclient, &cluster, clusterName, namespace); err != nil {
+		log.Error("could not find pgcluster for rolling restart")
+		log.Error(err)
+		return
+	}
+
+	// get the latest version of the task in case it changed
+	task := crv1.Pgtask{}
+	if found, err := kubeapi.Getpgtask(restclient, &task, t.Spec.Name, namespace); !found {
+		log.Error("could not find pgtask for rolling restart")
+		log.Error(err)
+		return
+	}
+
+	// have a guard -- if the task is over or is being carried out, don't proceed. A task that is
+	// in progress but not running was underway when the Operator stopped, and is resumed from the
+	// instances that are done
+	key := namespace + "/" + task.Spec.Name
+
+	switch task.Spec.Status {
+	case crv1.PgtaskRollingRestartCompleted, crv1.PgtaskRollingRestartFailed:
+		log.Warnf("pgtask [%s] has already been processed", task.Spec.Name)
+		return
+	case crv1.PgtaskRollingRestartInProgress:
+		if _, running := rollingRestartsRunning.Load(key); running {
+			log.Warnf("pgtask [%s] is already running", task.Spec.Name)
+			return
+		}
+
+		log.Infof("rolling restart: resuming pgtask %s after instances %v", task.Spec.Name,
+			getRollingRestartCompletedInstances(&task))
+	}
+
+	if task.Spec.Parameters == nil {
+		task.Spec.Parameters = make(map[string]string)
+	}
+
+	if cluster.Status.State != crv1.PgclusterStateInitialized || cluster.Spec.Shutdown {
+		failRollingRestart(clientset, restclient, &cluster, task.Spec.Name,
+			fmt.Sprintf("cluster %s is not running", cluster.Name))
+		return
+	}
+
+	if task.Spec.Status != crv1.PgtaskRollingRestartInProgress {
+		task.Spec.Status = crv1.PgtaskRollingRestartInProgress
+
+		if err := kubeapi.Updatepgtask(restclient, &task, task.Spec.Name, namespace); err != nil {
+			log.Error("error in updating rolling restart pgtask status " + err.Error())
+			return
+		}
+	}
+
+	if _, running := rollingRestartsRunning.LoadOrStore(key, true); running {
+		return
+	}
+
+	// waiting for each of the instances can take a while, so this is done in the background so
+	// as to not hold up the processing of other pgtasks
+	go func() {
+		defer rollingRestartsRunning.Delete(key)
+
+		runRollingRestart(clientset, restclient, restconfig, cluster,
+			getRollingRestartCompletedInstances(&task), task.Spec.Name)
+	}()
+}
+
+// runRollingRestart carries out the rolling restart of a pgtask, recording the instances that
+// are done in the pgtask, and completes or fails the pgtask once it is over
+func runRollingRestart(clientset *kubernetes.Clientset, restclient *rest.RESTClient,
+	restconfig *rest.Config, cluster crv1.Pgcluster, completed []string, taskName string) {
+	if err := RollingRestart(clientset, restconfig, &cluster, completed,
+		func(instance string) error {
+			return restartPatroniInstance(clientset, restconfig, &cluster, instance)
+		},
+		func(instance string) error {
+			completed = append(completed, instance)
+			return updateRollingRestartProgress(restclient, &cluster, taskName, completed)
+		}); err != nil {
+		failRollingRestart(clientset, restclient, &cluster, taskName, err.Error())
+		return
+	}
+
+	log.Infof("rolling restart: restarted all instances of cluster %s", cluster.Name)
+
+	task := crv1.Pgtask{}
+	if found, err := kubeapi.Getpgtask(restclient, &task, taskName, cluster.Namespace); !found {
+		log.Error(err)
+		return
+	}
+
+	task.Spec.Status = crv1.PgtaskRollingRestartCompleted
+
+	if err := kubeapi.Updatepgtask(restclient, &task, task.Spec.Name, cluster.Namespace); err != nil {
+		log.Error("error in updating rolling restart pgtask status " + err.Error())
+	}
+
+	message := fmt.Sprintf("restarted %d instances", len(completed))
+
+	if err := kubeapi.PatchpgtaskPhase(restclient, crv1.PgtaskStateProcessed, crv1.PgtaskPhaseSucceeded,
+		message, &task, cluster.Namespace); err != nil {
+		log.Error(err)
+	}
+
+	operator.RecordNormalEvent(clientset, &cluster, eventReasonRollingRestartCompleted,
+		fmt.Sprintf("all instances were restarted by pgtask %s", taskName))
+}
+
+// updateRollingRestartProgress records the instances that the rolling restart of a pgtask has
+// restarted so far
+func updateRollingRestartProgress(restclient *rest.RESTClient, cluster *crv1.Pgcluster,
+	taskName string, completed []string) error {
+	task := crv1.Pgtask{}
+	if found, err := kubeapi.Getpgtask(restclient, &task, taskName, cluster.Namespace); !found {
+		return err
+	}
+
+	if task.Spec.Parameters == nil {
+		task.Spec.Parameters = make(map[string]string)
+	}
+
+	task.Spec.Parameters[crv1.PgtaskRollingRestartCompletedInstances] = strings.Join(completed, ",")
+
+	if err := kubeapi.Updatepgtask(restclient, &task, task.Spec.Name, cluster.Namespace); err != nil {
+		return err
+	}
+
+	message := fmt.Sprintf("restarted instance %s (%d restarted)", completed[len(completed)-1],
+		len(completed))
+
+	return kubeapi.PatchpgtaskStatus(restclient, crv1.PgtaskStateProcessed, message, &task,
+		cluster.Namespace)
+}
+
+// failRollingRestart marks the pgtask as failed and records why on both the pgtask and the
+// cluster. The instances that were restarted stay restarted, and the instance that was being
+// restarted is left to Patroni and Kubernetes to bring back
+func failRollingRestart(clientset *kubernetes.Clientset, restclient *rest.RESTClient,
+	cluster *crv1.Pgcluster, taskName, message string) {
+	log.Errorf("rolling restart: failed on cluster %s: %s", cluster.Name, message)
+
+	task := crv1.Pgtask{}
+	if found, err := kubeapi.Getpgtask(restclient, &task, taskName, cluster.Namespace); !found {
+		log.Error(err)
+		return
+	}
+
+	task.Spec.Status = crv1.PgtaskRollingRestartFailed
+
+	if err := kubeapi.Updatepgtask(restclient, &task, task.Spec.Name, cluster.Namespace); err != nil {
+		log.Error("error in updating rolling restart pgtask status " + err.Error())
+	}
+
+	if err := kubeapi.PatchpgtaskPhase(restclient, crv1.PgtaskStateProcessed, crv1.PgtaskPhaseFailed,
+		message, &task, cluster.Namespace); err != nil {
+		log.Error(err)
+	}
+
+	operator.RecordWarningEvent(clientset, cluster, eventReasonRollingRestartFailed, message)
+}
+
+// getRollingRestartOrder returns the instances in the order they are restarted, which is by
+// name with the primary last
+func getRollingRestartOrder(instances []string, primary string) []string {
+	ordered := append([]string{}, instances...)
+
+	sort.Slice(ordered, func(i, j int) bool {
+		if (ordered[i] == primary) != (ordered[j] == primary) {
+			return ordered[j] == primary
+		}
+		return ordered[i] < ordered[j]
+	})
+
+	return ordered
+}
+
+// getRollingRestartCompletedInstances returns the names of the instances that the rolling
+// restart of a pgtask has already restarted
+func getRollingRestartCompletedInstances(task *crv1.Pgtask) []string {
+	completed := []string{}
+
+	for _, name := range strings.Split(task.Spec.Parameters[crv1.PgtaskRollingRestartCompletedInstances], ",") {
+		if name != "" {
+			completed = append(completed, name)
+		}
+	}
+
+	return completed
+}
+
+// restartPatroniInstance has Patroni restart PostgreSQL in the Pod of an instance. The Pod is
+// kept, and Patroni only returns once PostgreSQL is back up
+func restartPatroniInstance(clientset *kubernetes.Clientset, restconfig *rest.Config,
+	cluster *crv1.Pgcluster, instance string) error {
+	selector := fmt.Sprintf("%s=%s", config.LABEL_DEPLOYMENT_NAME, instance)
+
+	pods, err := kubeapi.GetPods(clientset, selector, cluster.Namespace)
+	if err != nil {
+		return err
+	}
+
+	if len(pods.Items) == 0 {
+		return fmt.Errorf("no pod found for instance %s", instance)
+	}
+
+	pod := pods.Items[0]
+
+	cmd := []string{"patronictl", "restart", "--force", cluster.Labels[config.LABEL_PGHA_SCOPE],
+		pod.Name}
+
+	if _, stderr, err := kubeapi.ExecToPodThroughAPI(restconfig, clientset, cmd, "database",
+		pod.Name, pod.Namespace, nil); err != nil {
+		log.Error(stderr)
+		return err
+	}
+
+	return nil
+}
+
+// switchoverRollingRestartPrimary switches the primary over to a ready replica that has already
+// been restarted, so that the primary can be restarted as a replica. Delayed replicas are passed
+// over, as they are behind the primary on purpose
+func switchoverRollingRestartPrimary(clientset *kubernetes.Clientset, restconfig *rest.Config,
+	cluster *crv1.Pgcluster, primaryPod *v1.Pod, done map[string]bool) error {
+	delayed, err := getDelayedReplicas(clientset, cluster.Name, cluster.Namespace)
+	if err != nil {
+		return err
+	}
+
+	instances := make([]string, 0, len(done))
+	for instance := range done {
+		if !containsString(delayed, instance) {
+			instances = append(instances, instance)
+		}
+	}
+
+	sort.Strings(instances)
+
+	for _, instance := range instances {
+		selector := fmt.Sprintf("%s=%s", config.LABEL_DEPLOYMENT_NAME, instance)
+
+		pods, err := kubeapi.GetPods(clientset, selector, cluster.Namespace)
+		if err != nil {
+			return err
+		}
+
+		for i := range pods.Items {
+			if isReplicaPodReady(&pods.Items[i]) && pods.Items[i].Name != primaryPod.Name {
+				return switchoverPrimary(clientset, restconfig, cluster, primaryPod, &pods.Items[i],
+					rollingRestartTimeout, rollingRestartPeriod)
+			}
+		}
+	}
+
+	return errors.New("no ready replica that was restarted to switch the primary over to")
+}
+
+// switchoverPrimary has Patroni switch the primary over to the given replica, and waits for the
+// replica to take over
+func switchoverPrimary(clientset *kubernetes.Clientset, restconfig *rest.Config, cluster *crv1.Pgcluster,
+	primaryPod, candidate *v1.Pod, timeoutSecs, periodSecs time.Duration) error {
+	log.Debugf("switching primary %s over to %s", primaryPod.Name, candidate.Name)
+
+	command := []string{"/bin/bash", "-c",
+		fmt.Sprintf("curl -s http://127.0.0.1:%s/switchover -XPOST "+
+			"-d '{\"leader\":\"%s\",\"candidate\":\"%s\"}'", config.DEFAULT_PATRONI_PORT, primaryPod.Name, candidate.Name)}
+
+	stdout, stderr, err := kubeapi.ExecToPodThroughAPI(restconfig, clientset, command,
+		primaryPod.Spec.Containers[0].Name, primaryPod.Name, cluster.Namespace, nil)
+	log.Debugf("stdout=[%s] stderr=[%s]", stdout, stderr)
+	if err != nil {
+		return err
+	}
+
+	timeout := time.After(timeoutSecs * time.Second)
+	tick := time.Tick(periodSecs * time.Second)
+
+	for {
+		select {
+		case <-timeout:
+			return fmt.Errorf("timed out waiting for %s to become the primary", candidate.Name)
+		case <-tick:
+			if pod, err := util.GetPrimaryPod(clientset, cluster); err != nil {
+				log.Debug(err)
+			} else if pod.Name == candidate.Name {
+				return nil
+			}
+		}
+	}
+}
+
+// waitForRollingRestartInstance waits for an instance that was restarted to be rolled out and
+// ready and, unless it is the primary, to have caught up with the primary
+func waitForRollingRestartInstance(clientset *kubernetes.Clientset, restconfig *rest.Config,
+	cluster *crv1.Pgcluster, instance string) error {
+	selector := fmt.Sprintf("%s=%s", config.LABEL_DEPLOYMENT_NAME, instance)
+	timeout := time.After(rollingRestartTimeout * time.Second)
+	tick := time.Tick(rollingRestartPeriod * time.Second)
+
+	for {
+		select {
+		case <-timeout:
+			return fmt.Errorf("timed out waiting for instance %s to be ready and caught up", instance)
+		case <-tick:
+			deployment, found, err := kubeapi.GetDeployment(clientset, instance, cluster.Namespace)
+			if !found {
+				log.Debug(err)
+				continue
+			}
+
+			if !isDeploymentRolledOut(deployment) {
+				continue
+			}
+
+			pods, err := kubeapi.GetPods(clientset, selector, cluster.Namespace)
+			if err != nil {
+				log.Debug(err)
+				continue
+			}
+
+			if caughtUp, err := isInstanceCaughtUp(clientset, restconfig, cluster,
+				pods.Items); err != nil {
+				log.Debug(err)
+			} else if caughtUp {
+				return nil
+			}
+		}
+	}
+}
+
+// isInstanceCaughtUp determines whether an instance, given its Pods, has a single ready Pod
+// that is either the primary or a replica that is no further behind the primary than the
+// maximum lag of a rolling restart
+func isInstanceCaughtUp(clientset *kubernetes.Clientset, restconfig *rest.Config,
+	cluster *crv1.Pgcluster, pods []v1.Pod) (bool, error) {
+	if len(pods) != 1 || !isReplicaPodReady(&pods[0]) {
+		return false, nil
+	}
+
+	if pods[0].ObjectMeta.Labels[config.LABEL_PGHA_ROLE] == "master" {
+		return true, nil
+	}
+
+	primaryPod, err := util.GetPrimaryPod(clientset, cluster)
+	if err != nil {
+		return false, err
+	}
+
+	result, err := execMaintenanceSQL(clientset, restconfig, primaryPod, "postgres",
+		getWALSQL(cluster, fmt.Sprintf(sqlReplicaCaughtUp, pods[0].Name, rollingRestartMaxLag)))
+	if err != nil {
+		return false, err
+	}
+
+	return result == "1", nil
+}
+
+// isDeploymentRolledOut determines whether the latest spec of a Deployment has been rolled out,
+// i.e. all of its Pods are up to date and ready, and none of its old Pods are left
+func isDeploymentRolledOut(deployment *apps_v1.Deployment) bool {
+	replicas := int32(1)
+	if deployment.Spec.Replicas != nil {
+		replicas = *deployment.Spec.Replicas
+	}
+
+	return deployment.Status.ObservedGeneration >= deployment.Generation &&
+		deployment.Status.Replicas == replicas &&
+		deployment.Status.UpdatedReplicas == replicas &&
+		deployment.Status.ReadyReplicas == replicas
+}
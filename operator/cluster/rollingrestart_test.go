@@ -0,0 +1,113 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	apps_v1 "k8s.io/api/apps/v1"
+)
+
+func TestGetRollingRestartOrder(t *testing.T) {
+	instances := []string{"hacluster-xyz", "hacluster", "hacluster-abc"}
+
+	ordered := getRollingRestartOrder(instances, "hacluster-abc")
+
+	if expected := []string{"hacluster", "hacluster-xyz", "hacluster-abc"}; !reflect.DeepEqual(ordered, expected) {
+		t.Fatalf("expected %v, got %v", expected, ordered)
+	}
+
+	// the instances that are passed in are left as they are
+	if instances[0] != "hacluster-xyz" {
+		t.Fatalf("expected the instances to be unchanged, got %v", instances)
+	}
+}
+
+func TestGetRollingRestartCompletedInstances(t *testing.T) {
+	task := &crv1.Pgtask{Spec: crv1.PgtaskSpec{Parameters: map[string]string{}}}
+
+	if completed := getRollingRestartCompletedInstances(task); len(completed) != 0 {
+		t.Fatalf("expected no completed instances, got %v", completed)
+	}
+
+	task.Spec.Parameters[crv1.PgtaskRollingRestartCompletedInstances] = "hacluster-abc,hacluster-xyz"
+
+	completed := getRollingRestartCompletedInstances(task)
+
+	if expected := []string{"hacluster-abc", "hacluster-xyz"}; !reflect.DeepEqual(completed, expected) {
+		t.Fatalf("expected %v, got %v", expected, completed)
+	}
+}
+
+func TestIsDeploymentRolledOut(t *testing.T) {
+	one := int32(1)
+
+	tests := []struct {
+		name     string
+		modify   func(*apps_v1.Deployment)
+		expected bool
+	}{
+		{"rolled out", func(d *apps_v1.Deployment) {}, true},
+		{"not observed", func(d *apps_v1.Deployment) { d.Generation = 3 }, false},
+		{"not updated", func(d *apps_v1.Deployment) { d.Status.UpdatedReplicas = 0 }, false},
+		{"not ready", func(d *apps_v1.Deployment) { d.Status.ReadyReplicas = 0 }, false},
+		{"old pod left", func(d *apps_v1.Deployment) { d.Status.Replicas = 2 }, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			deployment := &apps_v1.Deployment{}
+			deployment.Generation = 2
+			deployment.Spec.Replicas = &one
+			deployment.Status = apps_v1.DeploymentStatus{
+				ObservedGeneration: 2,
+				Replicas:           1,
+				UpdatedReplicas:    1,
+				ReadyReplicas:      1,
+			}
+
+			test.modify(deployment)
+
+			if rolledOut := isDeploymentRolledOut(deployment); rolledOut != test.expected {
+				t.Fatalf("expected %t, got %t", test.expected, rolledOut)
+			}
+		})
+	}
+}
+
+func TestGetWALSQL(t *testing.T) {
+	sql := fmt.Sprintf(sqlReplicaCaughtUp, "hacluster-abc", rollingRestartMaxLag)
+
+	for _, test := range []struct {
+		ccpImageTag string
+		expected    string
+	}{
+		{"centos7-12.4-4.5.0", sql},
+		{"centos7-9.6.19-4.5.0", `SELECT count(*) FROM pg_catalog.pg_stat_replication
+WHERE application_name = 'hacluster-abc' AND state = 'streaming' AND
+pg_catalog.pg_xlog_location_diff(pg_catalog.pg_current_xlog_location(), replay_location) <= 16777216;`},
+		{"custom", sql},
+	} {
+		cluster := &crv1.Pgcluster{Spec: crv1.PgclusterSpec{CCPImageTag: test.ccpImageTag}}
+
+		if actual := getWALSQL(cluster, sql); actual != test.expected {
+			t.Errorf("%s: expected %q, got %q", test.ccpImageTag, test.expected, actual)
+		}
+	}
+}
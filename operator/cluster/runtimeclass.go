@@ -0,0 +1,78 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/kubeapi"
+	"github.com/crunchydata/postgres-operator/operator"
+	log "github.com/sirupsen/logrus"
+	apps_v1 "k8s.io/api/apps/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// UpdateRuntimeClass rolls out the RuntimeClass of the cluster, or that of a replica which has one
+// of its own, to the PostgreSQL instance Deployments that are not already using it. An instance
+// whose RuntimeClass cannot be used is left as is, and a Warning event is recorded for the
+// cluster instead
+func UpdateRuntimeClass(clientset *kubernetes.Clientset, restclient *rest.RESTClient,
+	restConfig *rest.Config, cluster *crv1.Pgcluster) error {
+	deployments, err := operator.GetInstanceDeployments(clientset, cluster)
+	if err != nil {
+		return err
+	}
+
+	return updateDeployments(clientset, restConfig, deployments.Items, func(deployment *apps_v1.Deployment) bool {
+		name := cluster.Spec.RuntimeClassName
+
+		// a replica may override the RuntimeClass of the cluster
+		replica := crv1.Pgreplica{}
+		if found, _ := kubeapi.Getpgreplica(restclient, &replica, deployment.Name,
+			cluster.Namespace); found && replica.Spec.RuntimeClassName != "" {
+			name = replica.Spec.RuntimeClassName
+		}
+
+		current := ""
+		if deployment.Spec.Template.Spec.RuntimeClassName != nil {
+			current = *deployment.Spec.Template.Spec.RuntimeClassName
+		}
+
+		if name == current {
+			return false
+		}
+
+		if name != "" {
+			if err := operator.ValidateRuntimeClass(clientset, cluster, name); err != nil {
+				log.Warn(err)
+				operator.RecordWarningEvent(clientset, cluster, operator.EventReasonInvalidRuntimeClass,
+					err.Error())
+				return false
+			}
+		}
+
+		log.Debugf("updating runtime class of deployment %s from %q to %q", deployment.Name,
+			current, name)
+
+		if name == "" {
+			deployment.Spec.Template.Spec.RuntimeClassName = nil
+		} else {
+			deployment.Spec.Template.Spec.RuntimeClassName = &name
+		}
+
+		return true
+	})
+}
@@ -0,0 +1,129 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/crunchydata/postgres-operator/config"
+	"github.com/crunchydata/postgres-operator/operator"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+)
+
+// provisioningSlotTimeout is how long a Pod whose scheduling gate the Operator released holds on
+// to its provisioning slot while it is not ready, so that a Pod that never becomes ready does not
+// hold back the others for good
+const provisioningSlotTimeout = 10 * time.Minute
+
+// provisioning keeps track of the Pods, across every namespace, whose scheduling gate the Operator
+// released and that are not ready yet, by their namespace and name, along with when they were
+// released. Each of them holds one of the provisioning slots
+var provisioning = struct {
+	sync.Mutex
+	released map[string]time.Time
+}{released: map[string]time.Time{}}
+
+// ReleaseSchedulingGates releases the scheduling gate of the Operator from as many of the Pods
+// provided, the oldest first, as there are provisioning slots free. The slots of the Pods that
+// became ready, or that took too long to, are freed first
+func ReleaseSchedulingGates(clientset *kubernetes.Clientset, pods []*v1.Pod) {
+	provisioning.Lock()
+	defer provisioning.Unlock()
+
+	release := selectSchedulingGateReleases(pods, provisioning.released,
+		operator.Pgo.Cluster.ProvisioningConcurrency, time.Now())
+
+	for _, pod := range release {
+		patch, _ := json.Marshal(map[string]interface{}{
+			"metadata": map[string]interface{}{
+				"annotations": map[string]interface{}{config.ANNOTATION_SCHEDULING_GATE: nil},
+			},
+		})
+
+		if _, err := clientset.CoreV1().Pods(pod.Namespace).Patch(pod.Name, types.MergePatchType,
+			patch); err != nil {
+			log.Errorf("could not release the scheduling gate of pod %s: %s", pod.Name, err)
+			continue
+		}
+
+		log.Infof("released the scheduling gate of pod %s in namespace %s", pod.Name, pod.Namespace)
+		provisioning.released[provisioningKey(pod)] = time.Now()
+	}
+}
+
+// FreeProvisioningSlot frees the provisioning slot of a Pod that is deleted, if it holds one
+func FreeProvisioningSlot(pod *v1.Pod) {
+	provisioning.Lock()
+	defer provisioning.Unlock()
+
+	delete(provisioning.released, provisioningKey(pod))
+}
+
+// selectSchedulingGateReleases returns the Pods whose scheduling gate the Operator should release,
+// the oldest first, freeing the slots of the Pods that are ready or that were released more than
+// the slot timeout ago. A limit of zero or less releases every Pod held behind the gate
+func selectSchedulingGateReleases(pods []*v1.Pod, released map[string]time.Time, limit int,
+	now time.Time) []*v1.Pod {
+	for _, pod := range pods {
+		if _, ok := released[provisioningKey(pod)]; ok && isReplicaPodReady(pod) {
+			delete(released, provisioningKey(pod))
+		}
+	}
+
+	for key, releaseTime := range released {
+		if now.Sub(releaseTime) > provisioningSlotTimeout {
+			log.Warnf("pod %s is not ready after %s, freeing its provisioning slot", key,
+				provisioningSlotTimeout)
+			delete(released, key)
+		}
+	}
+
+	gated := []*v1.Pod{}
+	for _, pod := range pods {
+		if pod.DeletionTimestamp == nil &&
+			pod.Annotations[config.ANNOTATION_SCHEDULING_GATE] == operator.OperatorSchedulingGate {
+			gated = append(gated, pod)
+		}
+	}
+
+	sort.SliceStable(gated, func(i, j int) bool {
+		return gated[i].CreationTimestamp.Before(&gated[j].CreationTimestamp)
+	})
+
+	if limit <= 0 {
+		return gated
+	}
+
+	free := limit - len(released)
+	if free <= 0 {
+		return nil
+	}
+	if free < len(gated) {
+		return gated[:free]
+	}
+	return gated
+}
+
+// provisioningKey is how a Pod is known among those that hold a provisioning slot
+func provisioningKey(pod *v1.Pod) string {
+	return pod.Namespace + "/" + pod.Name
+}
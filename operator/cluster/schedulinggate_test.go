@@ -0,0 +1,138 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/crunchydata/postgres-operator/config"
+	"github.com/crunchydata/postgres-operator/operator"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestSelectSchedulingGateReleases(t *testing.T) {
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	pod := func(name, gate string, age time.Duration, ready bool) *v1.Pod {
+		pod := &v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              name,
+				Namespace:         "pgo",
+				CreationTimestamp: metav1.NewTime(now.Add(-age)),
+				Annotations:       map[string]string{},
+			},
+			Status: v1.PodStatus{
+				ContainerStatuses: []v1.ContainerStatus{{Name: "database", Ready: ready}},
+			},
+		}
+		if gate != "" {
+			pod.Annotations[config.ANNOTATION_SCHEDULING_GATE] = gate
+		}
+		return pod
+	}
+
+	for _, test := range []struct {
+		name      string
+		pods      []*v1.Pod
+		released  map[string]time.Time
+		limit     int
+		expected  []string
+		remaining []string
+	}{
+		{
+			name: "oldest first up to the limit",
+			pods: []*v1.Pod{
+				pod("c", operator.OperatorSchedulingGate, time.Minute, false),
+				pod("a", operator.OperatorSchedulingGate, 3*time.Minute, false),
+				pod("b", operator.OperatorSchedulingGate, 2*time.Minute, false),
+			},
+			released: map[string]time.Time{},
+			limit:    2,
+			expected: []string{"a", "b"},
+		},
+		{
+			name: "slots held by pods that are not ready",
+			pods: []*v1.Pod{
+				pod("a", "", 5*time.Minute, false),
+				pod("b", operator.OperatorSchedulingGate, time.Minute, false),
+			},
+			released:  map[string]time.Time{"pgo/a": now.Add(-time.Minute)},
+			limit:     1,
+			expected:  []string{},
+			remaining: []string{"pgo/a"},
+		},
+		{
+			name: "slot freed once the pod is ready",
+			pods: []*v1.Pod{
+				pod("a", "", 5*time.Minute, true),
+				pod("b", operator.OperatorSchedulingGate, time.Minute, false),
+			},
+			released: map[string]time.Time{"pgo/a": now.Add(-time.Minute)},
+			limit:    1,
+			expected: []string{"b"},
+		},
+		{
+			name: "slot freed once it times out",
+			pods: []*v1.Pod{
+				pod("b", operator.OperatorSchedulingGate, time.Minute, false),
+			},
+			released: map[string]time.Time{"other/a": now.Add(-provisioningSlotTimeout - time.Second)},
+			limit:    1,
+			expected: []string{"b"},
+		},
+		{
+			name: "gates of external coordinators are left alone",
+			pods: []*v1.Pod{
+				pod("a", "example.com/quota", time.Minute, false),
+			},
+			released: map[string]time.Time{},
+			limit:    1,
+			expected: []string{},
+		},
+		{
+			name: "no limit",
+			pods: []*v1.Pod{
+				pod("a", operator.OperatorSchedulingGate, time.Minute, false),
+				pod("b", operator.OperatorSchedulingGate, time.Minute, false),
+			},
+			released: map[string]time.Time{"pgo/c": now},
+			limit:    0,
+			expected: []string{"a", "b"},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			names := []string{}
+			for _, pod := range selectSchedulingGateReleases(test.pods, test.released, test.limit, now) {
+				names = append(names, pod.Name)
+			}
+
+			if !reflect.DeepEqual(names, test.expected) {
+				t.Fatalf("expected %v to be released, got %v", test.expected, names)
+			}
+
+			if test.remaining != nil {
+				for _, key := range test.remaining {
+					if _, ok := test.released[key]; !ok {
+						t.Fatalf("expected %s to still hold a slot", key)
+					}
+				}
+			}
+		})
+	}
+}
@@ -55,6 +55,9 @@ func CreateService(clientset *kubernetes.Clientset, fields *ServiceTemplateField
 			return err
 		}
 
+		// set the chargeback labels of the Namespace
+		operator.AddChargebackLabels(clientset, namespace, &service.ObjectMeta)
+
 		_, err = kubeapi.CreateService(clientset, &service, namespace)
 	}
 
@@ -0,0 +1,233 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"fmt"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/config"
+	"github.com/crunchydata/postgres-operator/kubeapi"
+	"github.com/crunchydata/postgres-operator/operator"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	// serviceDiscoverySecretHostKey is the key in the credential secrets of the
+	// cluster that contains the FQDN of the primary
+	serviceDiscoverySecretHostKey = "host"
+	// serviceDiscoverySecretPortKey is the key in the credential secrets of the
+	// cluster that contains the port of the primary
+	serviceDiscoverySecretPortKey = "port"
+)
+
+// UpdateServiceDiscovery reconciles the places where the in-cluster address of
+// the primary is published: the status of the cluster, the credential secrets
+// of the cluster and, if a consumer namespace is set, an ExternalName Service
+// in that namespace
+func UpdateServiceDiscovery(clientset *kubernetes.Clientset, restclient *rest.RESTClient, cluster *crv1.Pgcluster) error {
+	status := crv1.ServiceDiscoveryStatus{
+		Host: getPrimaryServiceFQDN(cluster),
+		Port: cluster.Spec.Port,
+	}
+
+	if cluster.Status.ServiceDiscovery != status {
+		if err := kubeapi.Patchpgcluster(restclient, cluster, cluster.Namespace, func(cluster *crv1.Pgcluster) {
+			cluster.Status.ServiceDiscovery = status
+		}); err != nil {
+			log.Error(err)
+			return err
+		}
+	}
+
+	if err := updateServiceDiscoverySecrets(clientset, cluster, status); err != nil {
+		return err
+	}
+
+	if cluster.Spec.ServiceDiscovery.Namespace == "" {
+		return nil
+	}
+
+	return updateExternalNameService(clientset, cluster, status.Host)
+}
+
+// DeleteServiceDiscovery removes the ExternalName Service described by the
+// given service discovery spec of a cluster. As the consumer namespace can be
+// different from the namespace of the cluster an owner reference cannot be
+// used, so the Service is only removed if its labels show that it belongs to
+// the cluster
+func DeleteServiceDiscovery(clientset *kubernetes.Clientset, cluster *crv1.Pgcluster, spec crv1.ServiceDiscoverySpec) error {
+	if spec.Namespace == "" {
+		return nil
+	}
+
+	name := getExternalNameServiceName(cluster, spec)
+
+	svc, _, err := kubeapi.GetService(clientset, name, spec.Namespace)
+	if kerrors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if !isServiceDiscoveryOwner(svc, cluster) {
+		log.Warnf("service discovery: not removing service %s in namespace %s as it does not "+
+			"belong to cluster %s", name, spec.Namespace, cluster.Name)
+		return nil
+	}
+
+	return kubeapi.DeleteService(clientset, name, spec.Namespace)
+}
+
+// HasServiceDiscoveryFinalizer returns whether a cluster is held on to until its ExternalName
+// Service is removed
+func HasServiceDiscoveryFinalizer(cluster *crv1.Pgcluster) bool {
+	return hasFinalizer(cluster, config.FINALIZER_SERVICE_DISCOVERY)
+}
+
+// ReconcileServiceDiscoveryFinalizer adds the service discovery finalizer to a cluster that
+// publishes its primary to a consumer namespace, and removes it from a cluster that does not
+func ReconcileServiceDiscoveryFinalizer(restclient *rest.RESTClient, cluster *crv1.Pgcluster) error {
+	return reconcileFinalizer(restclient, cluster, config.FINALIZER_SERVICE_DISCOVERY,
+		cluster.Spec.ServiceDiscovery.Namespace != "")
+}
+
+// FinalizeServiceDiscovery removes the ExternalName Service of a cluster whose pgcluster is
+// deleted, and then the service discovery finalizer, which lets the cluster go once nothing else
+// holds on to it. The finalizer is only removed once the Service is, so that the removal is
+// retried until it succeeds
+func FinalizeServiceDiscovery(clientset *kubernetes.Clientset, restclient *rest.RESTClient,
+	cluster *crv1.Pgcluster) error {
+	if err := DeleteServiceDiscovery(clientset, cluster, cluster.Spec.ServiceDiscovery); err != nil {
+		return err
+	}
+
+	return removeFinalizer(restclient, cluster, config.FINALIZER_SERVICE_DISCOVERY)
+}
+
+// getExternalNameServiceName returns the name of the ExternalName Service,
+// which defaults to the name of the cluster
+func getExternalNameServiceName(cluster *crv1.Pgcluster, spec crv1.ServiceDiscoverySpec) string {
+	if spec.ServiceName != "" {
+		return spec.ServiceName
+	}
+
+	return cluster.Name
+}
+
+// getPrimaryServiceFQDN returns the fully qualified domain name of the primary
+// Service, which shares the name of the cluster, in the cluster domain that is
+// configured
+func getPrimaryServiceFQDN(cluster *crv1.Pgcluster) string {
+	return fmt.Sprintf("%s.%s.svc.%s", cluster.Name, cluster.Namespace,
+		operator.Pgo.Cluster.ClusterDomain)
+}
+
+// isServiceDiscoveryOwner returns true if the Service is labeled as the
+// ExternalName Service of the cluster
+func isServiceDiscoveryOwner(svc *v1.Service, cluster *crv1.Pgcluster) bool {
+	return svc.Labels[config.LABEL_SERVICE_DISCOVERY_CLUSTER] == cluster.Name &&
+		svc.Labels[config.LABEL_SERVICE_DISCOVERY_NAMESPACE] == cluster.Namespace
+}
+
+// updateExternalNameService creates the ExternalName Service in the consumer
+// namespace, or points the existing one at the primary Service if it has
+// changed. A Service of the same name that does not belong to the cluster is
+// left alone
+func updateExternalNameService(clientset *kubernetes.Clientset, cluster *crv1.Pgcluster, host string) error {
+	namespace := cluster.Spec.ServiceDiscovery.Namespace
+	name := getExternalNameServiceName(cluster, cluster.Spec.ServiceDiscovery)
+
+	svc, _, err := kubeapi.GetService(clientset, name, namespace)
+	if err != nil && !kerrors.IsNotFound(err) {
+		return err
+	}
+
+	if kerrors.IsNotFound(err) {
+		svc := &v1.Service{
+			ObjectMeta: meta_v1.ObjectMeta{
+				Name: name,
+				Labels: map[string]string{
+					config.LABEL_VENDOR:                      config.LABEL_CRUNCHY,
+					config.LABEL_SERVICE_DISCOVERY_CLUSTER:   cluster.Name,
+					config.LABEL_SERVICE_DISCOVERY_NAMESPACE: cluster.Namespace,
+				},
+			},
+			Spec: v1.ServiceSpec{
+				Type:         v1.ServiceTypeExternalName,
+				ExternalName: host,
+			},
+		}
+
+		_, err := kubeapi.CreateService(clientset, svc, namespace)
+		return err
+	}
+
+	if !isServiceDiscoveryOwner(svc, cluster) {
+		return fmt.Errorf("service %s in namespace %s already exists and does not belong to cluster %s",
+			name, namespace, cluster.Name)
+	}
+
+	if svc.Spec.Type == v1.ServiceTypeExternalName && svc.Spec.ExternalName == host {
+		return nil
+	}
+
+	svc.Spec.Type = v1.ServiceTypeExternalName
+	svc.Spec.ExternalName = host
+
+	return kubeapi.UpdateService(clientset, svc, namespace)
+}
+
+// updateServiceDiscoverySecrets adds the host and port of the primary to the
+// credential secrets of the cluster, so they can be used to build a connection
+// string
+func updateServiceDiscoverySecrets(clientset *kubernetes.Clientset, cluster *crv1.Pgcluster, status crv1.ServiceDiscoveryStatus) error {
+	for _, secretName := range []string{cluster.Spec.UserSecretName, cluster.Spec.RootSecretName} {
+		if secretName == "" {
+			continue
+		}
+
+		secret, _, err := kubeapi.GetSecret(clientset, secretName, cluster.Namespace)
+		if kerrors.IsNotFound(err) {
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		if string(secret.Data[serviceDiscoverySecretHostKey]) == status.Host &&
+			string(secret.Data[serviceDiscoverySecretPortKey]) == status.Port {
+			continue
+		}
+
+		if secret.Data == nil {
+			secret.Data = map[string][]byte{}
+		}
+
+		secret.Data[serviceDiscoverySecretHostKey] = []byte(status.Host)
+		secret.Data[serviceDiscoverySecretPortKey] = []byte(status.Port)
+
+		if err := kubeapi.UpdateSecret(clientset, secret, cluster.Namespace); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
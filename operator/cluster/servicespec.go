@@ -0,0 +1,193 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/config"
+	"github.com/crunchydata/postgres-operator/kubeapi"
+	"github.com/crunchydata/postgres-operator/operator"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ValidateServices returns an error if the settings of any of the Services of a cluster are
+// invalid
+func ValidateServices(cluster *crv1.Pgcluster) error {
+	services := cluster.Spec.Services
+
+	if err := validateServiceSpec("primary", services.Primary); err != nil {
+		return err
+	}
+
+	if err := validateServiceSpec("replica", services.Replica); err != nil {
+		return err
+	}
+
+	return validateServiceSpec("pgbouncer", services.PgBouncer)
+}
+
+// ReconcileServices applies the settings of the Services of a cluster that changed from the
+// given ones to the Services they are for. A Service that does not exist yet gets its settings
+// once it is created
+func ReconcileServices(clientset *kubernetes.Clientset, cluster *crv1.Pgcluster,
+	old crv1.ServicesSpec) error {
+	type clusterService struct {
+		name        string
+		spec, old   crv1.ServiceSpec
+		defaultType string
+	}
+
+	services := []clusterService{
+		{cluster.Name, cluster.Spec.Services.Primary, old.Primary, getClusterServiceType(cluster)},
+		{cluster.Name + ReplicaSuffix, cluster.Spec.Services.Replica, old.Replica,
+			getClusterServiceType(cluster)},
+	}
+
+	for _, pool := range getPgBouncerPools(cluster) {
+		services = append(services, clusterService{pool.name, cluster.Spec.Services.PgBouncer,
+			old.PgBouncer, config.DEFAULT_SERVICE_TYPE})
+	}
+
+	for _, service := range services {
+		if reflect.DeepEqual(service.spec, service.old) {
+			continue
+		}
+
+		if err := reconcileService(clientset, cluster.Namespace, service.name, service.spec,
+			service.defaultType); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// getClusterServiceType returns the type of the primary and replica Services of a cluster when
+// its spec does not set one, which is that of its labels or else that of the Operator
+func getClusterServiceType(cluster *crv1.Pgcluster) string {
+	if serviceType := cluster.Spec.UserLabels[config.LABEL_SERVICE_TYPE]; serviceType != "" {
+		return serviceType
+	}
+
+	return operator.Pgo.Cluster.ServiceType
+}
+
+// getServiceSpecType returns the type of a Service according to its settings, or the given
+// default if they do not set one
+func getServiceSpecType(spec crv1.ServiceSpec, defaultType string) string {
+	if spec.ServiceType != "" {
+		return spec.ServiceType
+	}
+
+	return defaultType
+}
+
+// reconcileService applies the settings of a Service to it, if it exists
+func reconcileService(clientset *kubernetes.Clientset, namespace, name string,
+	spec crv1.ServiceSpec, defaultType string) error {
+	service, found, err := kubeapi.GetService(clientset, name, namespace)
+	if !found {
+		if kerrors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+
+	if !setServiceSpec(service, spec, defaultType) {
+		return nil
+	}
+
+	log.Debugf("updating service %s to type %s", name, service.Spec.Type)
+
+	return kubeapi.UpdateService(clientset, service, namespace)
+}
+
+// setServiceSpec sets the type, the node port of the PostgreSQL port, the load balancer source
+// ranges and the annotations of the settings of a Service on it, returning whether it changed.
+// The node ports of the other ports, and that of the PostgreSQL port if none is set, are left to
+// Kubernetes, as are the source ranges of a Service that is not a LoadBalancer
+func setServiceSpec(service *v1.Service, spec crv1.ServiceSpec, defaultType string) bool {
+	changed := setServiceType(service, getServiceSpecType(spec, defaultType))
+
+	if spec.NodePort != 0 && service.Spec.Type != v1.ServiceTypeClusterIP {
+		for i := range service.Spec.Ports {
+			if service.Spec.Ports[i].Name == "postgres" && service.Spec.Ports[i].NodePort != spec.NodePort {
+				service.Spec.Ports[i].NodePort = spec.NodePort
+				changed = true
+			}
+		}
+	}
+
+	ranges := spec.LoadBalancerSourceRanges
+	if service.Spec.Type != v1.ServiceTypeLoadBalancer {
+		ranges = nil
+	}
+
+	if (len(ranges) > 0 || len(service.Spec.LoadBalancerSourceRanges) > 0) &&
+		!reflect.DeepEqual(service.Spec.LoadBalancerSourceRanges, ranges) {
+		service.Spec.LoadBalancerSourceRanges = ranges
+		changed = true
+	}
+
+	if operator.SetServiceAnnotations(&service.ObjectMeta, spec.Annotations) {
+		changed = true
+	}
+
+	return changed
+}
+
+// validateServiceSpec returns an error if the settings of the named Service are invalid, i.e.
+// have a type that the Operator does not support, or a node port or source ranges that the
+// type of the Service cannot have
+func validateServiceSpec(name string, spec crv1.ServiceSpec) error {
+	switch spec.ServiceType {
+	case "", config.DEFAULT_SERVICE_TYPE, config.LOAD_BALANCER_SERVICE_TYPE, config.NODEPORT_SERVICE_TYPE:
+	default:
+		return fmt.Errorf("invalid %s service type %q, must be %s, %s or %s", name,
+			spec.ServiceType, config.DEFAULT_SERVICE_TYPE, config.LOAD_BALANCER_SERVICE_TYPE,
+			config.NODEPORT_SERVICE_TYPE)
+	}
+
+	if spec.NodePort < 0 || spec.NodePort > 65535 {
+		return fmt.Errorf("invalid %s service node port %d", name, spec.NodePort)
+	}
+
+	if spec.NodePort != 0 && spec.ServiceType != config.NODEPORT_SERVICE_TYPE &&
+		spec.ServiceType != config.LOAD_BALANCER_SERVICE_TYPE {
+		return fmt.Errorf("a %s service node port requires a service type of %s or %s", name,
+			config.NODEPORT_SERVICE_TYPE, config.LOAD_BALANCER_SERVICE_TYPE)
+	}
+
+	for _, cidr := range spec.LoadBalancerSourceRanges {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			return fmt.Errorf("invalid %s service load balancer source range %q", name, cidr)
+		}
+	}
+
+	if len(spec.LoadBalancerSourceRanges) > 0 && spec.ServiceType != config.LOAD_BALANCER_SERVICE_TYPE {
+		return fmt.Errorf("%s service load balancer source ranges require a service type of %s",
+			name, config.LOAD_BALANCER_SERVICE_TYPE)
+	}
+
+	return nil
+}
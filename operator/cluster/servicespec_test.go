@@ -0,0 +1,82 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"testing"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func TestSetServiceSpec(t *testing.T) {
+	service := &v1.Service{
+		Spec: v1.ServiceSpec{
+			Type: v1.ServiceTypeClusterIP,
+			Ports: []v1.ServicePort{
+				{Name: "patroni", Port: 8009, TargetPort: intstr.FromInt(8009)},
+				{Name: "postgres", Port: 5432, TargetPort: intstr.FromInt(5432)},
+			},
+		},
+	}
+
+	spec := crv1.ServiceSpec{
+		ServiceType:              "LoadBalancer",
+		NodePort:                 30432,
+		LoadBalancerSourceRanges: []string{"10.0.0.0/8"},
+		Annotations:              map[string]string{"example.com/internal": "true"},
+	}
+
+	if !setServiceSpec(service, spec, "ClusterIP") {
+		t.Fatal("expected the service to change")
+	}
+
+	if service.Spec.Type != v1.ServiceTypeLoadBalancer {
+		t.Errorf("expected a load balancer, got %q", service.Spec.Type)
+	}
+	if service.Spec.Ports[0].NodePort != 0 || service.Spec.Ports[1].NodePort != 30432 {
+		t.Errorf("expected the node port on the postgres port only, got %v", service.Spec.Ports)
+	}
+	if len(service.Spec.LoadBalancerSourceRanges) != 1 {
+		t.Errorf("expected the source ranges, got %v", service.Spec.LoadBalancerSourceRanges)
+	}
+	if service.Annotations["example.com/internal"] != "true" {
+		t.Errorf("expected the annotations, got %v", service.Annotations)
+	}
+
+	if setServiceSpec(service, spec, "ClusterIP") {
+		t.Error("expected the service to be unchanged")
+	}
+
+	// without settings, the service goes back to the default type
+	if !setServiceSpec(service, crv1.ServiceSpec{}, "ClusterIP") {
+		t.Fatal("expected the service to change")
+	}
+
+	if service.Spec.Type != v1.ServiceTypeClusterIP {
+		t.Errorf("expected the default type, got %q", service.Spec.Type)
+	}
+	if service.Spec.Ports[1].NodePort != 0 {
+		t.Errorf("expected no node port, got %d", service.Spec.Ports[1].NodePort)
+	}
+	if service.Spec.LoadBalancerSourceRanges != nil {
+		t.Errorf("expected no source ranges, got %v", service.Spec.LoadBalancerSourceRanges)
+	}
+	if _, ok := service.Annotations["example.com/internal"]; ok {
+		t.Errorf("expected the annotation to be removed, got %v", service.Annotations)
+	}
+}
@@ -204,6 +204,11 @@ func EnableStandby(clientset *kubernetes.Clientset, cluster crv1.Pgcluster) erro
 		configJSON["standby_cluster"] = standbyJSON
 	}
 
+	// stream the WAL of the remote primary, if any, on top of replaying it from the repo
+	if standbyJSON, ok := configJSON["standby_cluster"].(map[string]interface{}); ok {
+		setStandbyStreaming(standbyJSON, cluster.Spec.StandbyStreaming)
+	}
+
 	configJSONFinalStr, err := json.Marshal(configJSON)
 	if err != nil {
 		return err
@@ -0,0 +1,203 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"fmt"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/config"
+	"github.com/crunchydata/postgres-operator/kubeapi"
+	"github.com/crunchydata/postgres-operator/operator"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// PromoteStandby handles a "promote-standby" pgtask, which promotes a standby cluster that
+// replays the WAL of another cluster from its pgBackRest repository, e.g. when the Kubernetes
+// cluster of the other cluster is lost. Standby mode is disabled in the DCS configuration, which
+// has Patroni promote the standby leader, and the Services of the cluster are optionally changed
+// to the service type in the task so that the promoted cluster can take over the clients
+func PromoteStandby(clientset *kubernetes.Clientset, restclient *rest.RESTClient, t *crv1.Pgtask,
+	namespace string) {
+	clusterName := t.ObjectMeta.Labels[config.LABEL_PG_CLUSTER]
+
+	cluster := crv1.Pgcluster{}
+	if _, err := kubeapi.Getpgcluster(restclient, &cluster, clusterName, namespace); err != nil {
+		log.Error("could not find pgcluster for promoting standby")
+		log.Error(err)
+		return
+	}
+
+	// get the latest version of the task in case it changed
+	task := crv1.Pgtask{}
+	if found, err := kubeapi.Getpgtask(restclient, &task, t.Spec.Name, namespace); !found {
+		log.Error("could not find pgtask for promoting standby")
+		log.Error(err)
+		return
+	}
+
+	// have a guard -- if the task has already been started, don't proceed
+	switch task.Spec.Status {
+	case crv1.PgtaskPromoteStandbyInProgress, crv1.PgtaskPromoteStandbyCompleted,
+		crv1.PgtaskPromoteStandbyFailed:
+		log.Warnf("pgtask [%s] has already been processed", task.Spec.Name)
+		return
+	}
+
+	serviceType := task.Spec.Parameters[crv1.PgtaskPromoteStandbyServiceType]
+
+	if err := validatePromoteStandby(&cluster, serviceType); err != nil {
+		failPromoteStandby(clientset, restclient, &cluster, &task, err.Error())
+		return
+	}
+
+	task.Spec.Status = crv1.PgtaskPromoteStandbyInProgress
+
+	if err := kubeapi.Updatepgtask(restclient, &task, task.Spec.Name, namespace); err != nil {
+		log.Error("error in updating promote standby pgtask status " + err.Error())
+		return
+	}
+
+	log.Infof("promoting standby cluster %s", clusterName)
+
+	// rewriting the DCS configuration is what has Patroni promote the standby leader, after
+	// which the promotion is handled by the pod controller like any other
+	if err := DisableStandby(clientset, cluster); err != nil {
+		failPromoteStandby(clientset, restclient, &cluster, &task, err.Error())
+		return
+	}
+
+	if serviceType != "" {
+		if err := updateClusterServiceType(clientset, &cluster, serviceType); err != nil {
+			failPromoteStandby(clientset, restclient, &cluster, &task, err.Error())
+			return
+		}
+
+		// replicas that are added later get the same type of Service
+		if cluster.Spec.UserLabels == nil {
+			cluster.Spec.UserLabels = map[string]string{}
+		}
+		cluster.Spec.UserLabels[config.LABEL_SERVICE_TYPE] = serviceType
+	}
+
+	cluster.Spec.Standby = false
+
+	if err := kubeapi.Updatepgcluster(restclient, &cluster, clusterName, namespace); err != nil {
+		failPromoteStandby(clientset, restclient, &cluster, &task, err.Error())
+		return
+	}
+
+	task.Spec.Status = crv1.PgtaskPromoteStandbyCompleted
+
+	if err := kubeapi.Updatepgtask(restclient, &task, task.Spec.Name, namespace); err != nil {
+		log.Error("error in updating promote standby pgtask status " + err.Error())
+	}
+
+	if err := kubeapi.PatchpgtaskPhase(restclient, crv1.PgtaskStateProcessed, crv1.PgtaskPhaseSucceeded,
+		"standby mode disabled, the standby leader is being promoted", &task, namespace); err != nil {
+		log.Error(err)
+	}
+}
+
+// validatePromoteStandby ensures that the cluster is a standby that is running, and that the
+// service type to change its Services to, if any, is one that the Operator supports
+func validatePromoteStandby(cluster *crv1.Pgcluster, serviceType string) error {
+	if !cluster.Spec.Standby {
+		return fmt.Errorf("cluster %s is not a standby cluster: %w", cluster.Name, ErrStandbyNotEnabled)
+	}
+
+	if cluster.Status.State != crv1.PgclusterStateInitialized {
+		return fmt.Errorf("cluster %s is not initialized, its state is %q", cluster.Name,
+			cluster.Status.State)
+	}
+
+	switch serviceType {
+	case "", config.DEFAULT_SERVICE_TYPE, config.LOAD_BALANCER_SERVICE_TYPE, config.NODEPORT_SERVICE_TYPE:
+	default:
+		return fmt.Errorf("invalid service type %q, must be %s, %s or %s", serviceType,
+			config.DEFAULT_SERVICE_TYPE, config.LOAD_BALANCER_SERVICE_TYPE, config.NODEPORT_SERVICE_TYPE)
+	}
+
+	return nil
+}
+
+// updateClusterServiceType changes the primary and replica Services of the cluster to the
+// service type. The replica Service only exists if the cluster has replicas
+func updateClusterServiceType(clientset *kubernetes.Clientset, cluster *crv1.Pgcluster,
+	serviceType string) error {
+	for _, serviceName := range []string{cluster.Name, cluster.Name + "-replica"} {
+		svc, found, err := kubeapi.GetService(clientset, serviceName, cluster.Namespace)
+		if kerrors.IsNotFound(err) {
+			continue
+		} else if !found {
+			return err
+		}
+
+		if !setServiceType(svc, serviceType) {
+			continue
+		}
+
+		log.Debugf("changing type of service %s to %s", serviceName, serviceType)
+
+		if err := kubeapi.UpdateService(clientset, svc, cluster.Namespace); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// setServiceType sets the type of the Service, returning whether it changed. The node ports that
+// were allocated to a Service cannot be kept when it becomes a ClusterIP Service
+func setServiceType(svc *v1.Service, serviceType string) bool {
+	if svc.Spec.Type == v1.ServiceType(serviceType) {
+		return false
+	}
+
+	svc.Spec.Type = v1.ServiceType(serviceType)
+
+	if svc.Spec.Type == v1.ServiceTypeClusterIP {
+		for i := range svc.Spec.Ports {
+			svc.Spec.Ports[i].NodePort = 0
+		}
+	}
+
+	return true
+}
+
+// failPromoteStandby records that a standby cluster could not be promoted in the pgtask, along
+// with a Warning event on the cluster
+func failPromoteStandby(clientset *kubernetes.Clientset, restclient *rest.RESTClient,
+	cluster *crv1.Pgcluster, task *crv1.Pgtask, message string) {
+	log.Errorf("promote standby: failed on cluster %s: %s", cluster.Name, message)
+
+	task.Spec.Status = crv1.PgtaskPromoteStandbyFailed
+
+	if err := kubeapi.Updatepgtask(restclient, task, task.Spec.Name, cluster.Namespace); err != nil {
+		log.Error("error in updating promote standby pgtask status " + err.Error())
+	}
+
+	if err := kubeapi.PatchpgtaskPhase(restclient, crv1.PgtaskStateProcessed, crv1.PgtaskPhaseFailed,
+		message, task, cluster.Namespace); err != nil {
+		log.Error(err)
+	}
+
+	operator.RecordWarningEvent(clientset, cluster, "PromoteStandbyFailed", message)
+}
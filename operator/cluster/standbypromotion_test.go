@@ -0,0 +1,89 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"errors"
+	"testing"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestValidatePromoteStandby(t *testing.T) {
+	standby := func(standby bool, state crv1.PgclusterState) *crv1.Pgcluster {
+		return &crv1.Pgcluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "hacluster"},
+			Spec:       crv1.PgclusterSpec{Standby: standby},
+			Status:     crv1.PgclusterStatus{State: state},
+		}
+	}
+
+	for _, test := range []struct {
+		name        string
+		cluster     *crv1.Pgcluster
+		serviceType string
+		valid       bool
+	}{
+		{"standby", standby(true, crv1.PgclusterStateInitialized), "", true},
+		{"load balancer", standby(true, crv1.PgclusterStateInitialized), "LoadBalancer", true},
+		{"node port", standby(true, crv1.PgclusterStateInitialized), "NodePort", true},
+		{"not initialized", standby(true, crv1.PgclusterStateCreated), "", false},
+		{"invalid service type", standby(true, crv1.PgclusterStateInitialized), "ExternalName", false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			if err := validatePromoteStandby(test.cluster, test.serviceType); (err == nil) != test.valid {
+				t.Errorf("expected valid: %v, got %v", test.valid, err)
+			}
+		})
+	}
+
+	t.Run("not a standby", func(t *testing.T) {
+		err := validatePromoteStandby(standby(false, crv1.PgclusterStateInitialized), "")
+		if !errors.Is(err, ErrStandbyNotEnabled) {
+			t.Errorf("expected %v, got %v", ErrStandbyNotEnabled, err)
+		}
+	})
+}
+
+func TestSetServiceType(t *testing.T) {
+	svc := &v1.Service{Spec: v1.ServiceSpec{
+		Type:  v1.ServiceTypeNodePort,
+		Ports: []v1.ServicePort{{Name: "postgres", Port: 5432, NodePort: 30432}},
+	}}
+
+	if setServiceType(svc, "NodePort") {
+		t.Error("expected the service type to be unchanged")
+	}
+	if svc.Spec.Ports[0].NodePort != 30432 {
+		t.Errorf("expected the node port to be kept, got %d", svc.Spec.Ports[0].NodePort)
+	}
+
+	if !setServiceType(svc, "LoadBalancer") || svc.Spec.Type != v1.ServiceTypeLoadBalancer {
+		t.Errorf("expected a LoadBalancer service, got %q", svc.Spec.Type)
+	}
+	if svc.Spec.Ports[0].NodePort != 30432 {
+		t.Errorf("expected the node port to be kept, got %d", svc.Spec.Ports[0].NodePort)
+	}
+
+	if !setServiceType(svc, "ClusterIP") || svc.Spec.Type != v1.ServiceTypeClusterIP {
+		t.Errorf("expected a ClusterIP service, got %q", svc.Spec.Type)
+	}
+	if svc.Spec.Ports[0].NodePort != 0 {
+		t.Errorf("expected the node port to be cleared, got %d", svc.Spec.Ports[0].NodePort)
+	}
+}
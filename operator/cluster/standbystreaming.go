@@ -0,0 +1,231 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/config"
+	"github.com/crunchydata/postgres-operator/kubeapi"
+	"github.com/crunchydata/postgres-operator/operator"
+	"github.com/crunchydata/postgres-operator/util"
+	log "github.com/sirupsen/logrus"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// defaultStandbyStreamingPort is the port of the remote primary if none is set
+	defaultStandbyStreamingPort = "5432"
+
+	// defaultStandbyStreamingSSLMode is the sslmode the WAL is streamed with if none is set,
+	// which encrypts the stream without verifying the remote primary
+	defaultStandbyStreamingSSLMode = "require"
+
+	// standbyStreamingSSLRootCert is where the CA of the cluster is mounted in its instances
+	standbyStreamingSSLRootCert = "/pgconf/tls/ca.crt"
+)
+
+// standbyStreamingSSLModes are the sslmodes the WAL can be streamed with, and whether they
+// verify the remote primary against the CA of the cluster
+var standbyStreamingSSLModes = map[string]bool{
+	"require":     false,
+	"verify-ca":   true,
+	"verify-full": true,
+}
+
+// ValidateStandbyStreaming returns an error if the cluster cannot stream the WAL of the remote
+// primary in its spec, if it is to
+func ValidateStandbyStreaming(cluster *crv1.Pgcluster) error {
+	streaming := cluster.Spec.StandbyStreaming
+
+	if streaming.Host == "" {
+		return nil
+	}
+
+	if !cluster.Spec.Standby {
+		return errors.New("only a standby cluster can stream the WAL of a remote primary")
+	}
+
+	if streaming.ReplicationSecretName == "" {
+		return errors.New("streaming the WAL of a remote primary requires the Secret of its replication user")
+	}
+
+	// the instances of the cluster stream the WAL from each other with the same sslmode as the
+	// standby leader streams it from the remote primary, so they have to accept TLS as well
+	if !cluster.Spec.TLS.IsTLSEnabled() {
+		return errors.New("streaming the WAL of a remote primary requires TLS to be enabled on the cluster")
+	}
+
+	if sslMode := getStandbyStreamingSSLMode(streaming); !isStandbyStreamingSSLMode(sslMode) {
+		return fmt.Errorf("invalid standby streaming sslmode %q, must be require, verify-ca or verify-full",
+			sslMode)
+	}
+
+	return nil
+}
+
+// ReconcileStandbyStreaming points the standby leader of a standby cluster at the remote primary
+// in its spec by setting it in the "standby_cluster" configuration in the DCS, which Patroni
+// applies without a restart. When streaming is turned off, the remote primary is removed and the
+// standby leader only replays the WAL from the pgBackRest repository again
+func ReconcileStandbyStreaming(clientset *kubernetes.Clientset, cluster *crv1.Pgcluster) error {
+	if !cluster.Spec.Standby || cluster.Status.State != crv1.PgclusterStateInitialized {
+		return nil
+	}
+
+	dcsConfigMapName := cluster.Labels[config.LABEL_PGHA_SCOPE] + "-config"
+	dcsConfigMap, found := kubeapi.GetConfigMap(clientset, dcsConfigMapName, cluster.Namespace)
+	if !found {
+		return fmt.Errorf("unable to find configMap %s when setting up standby streaming",
+			dcsConfigMapName)
+	}
+
+	configJSONStr, ok := dcsConfigMap.ObjectMeta.Annotations["config"]
+	if !ok {
+		return util.ErrMissingConfigAnnotation
+	}
+
+	var configJSON map[string]interface{}
+	if err := json.Unmarshal([]byte(configJSONStr), &configJSON); err != nil {
+		return err
+	}
+
+	// standby mode is not set up in the DCS yet, e.g. as it is being enabled, which sets up
+	// the streaming as it does
+	standbyJSON, ok := configJSON["standby_cluster"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	if !setStandbyStreaming(standbyJSON, cluster.Spec.StandbyStreaming) {
+		return nil
+	}
+
+	configJSONFinalStr, err := json.Marshal(configJSON)
+	if err != nil {
+		return err
+	}
+
+	log.Debugf("standby streaming: updating remote primary of cluster %s to %q", cluster.Name,
+		cluster.Spec.StandbyStreaming.Host)
+
+	dcsConfigMap.ObjectMeta.Annotations["config"] = string(configJSONFinalStr)
+
+	return kubeapi.UpdateConfigMap(clientset, dcsConfigMap, cluster.Namespace)
+}
+
+// setStandbyStreaming sets the remote primary in the "standby_cluster" configuration of Patroni,
+// or removes it if streaming is turned off, returning whether the configuration changed. The
+// restore command and the replica creation methods are left as they are, as they are what the
+// standby falls back to
+func setStandbyStreaming(standbyJSON map[string]interface{}, streaming crv1.StandbyStreamingSpec) bool {
+	changed := false
+
+	set := func(key, value string) {
+		current, ok := standbyJSON[key]
+		switch {
+		case value == "" && ok:
+			delete(standbyJSON, key)
+			changed = true
+		case value != "" && current != value:
+			standbyJSON[key] = value
+			changed = true
+		}
+	}
+
+	port := ""
+	if streaming.Host != "" {
+		port = streaming.Port
+		if port == "" {
+			port = defaultStandbyStreamingPort
+		}
+	}
+
+	set("host", streaming.Host)
+	set("port", port)
+
+	return changed
+}
+
+// setStandbyReplicationCredential has a standby cluster that streams the WAL of a remote primary
+// take the replication user of the remote primary as its own, as it is the user the instances
+// stream the WAL as. The credential is kept as is if it already matches
+func setStandbyReplicationCredential(store operator.CredentialStore, cluster *crv1.Pgcluster) error {
+	streaming := cluster.Spec.StandbyStreaming
+
+	if !cluster.Spec.Standby || streaming.Host == "" || streaming.ReplicationSecretName == "" ||
+		cluster.Spec.PrimarySecretName == "" {
+		return nil
+	}
+
+	namespace := cluster.ObjectMeta.Namespace
+
+	remote, err := store.Get(namespace, streaming.ReplicationSecretName)
+	if err != nil {
+		return err
+	}
+
+	current, err := store.Get(namespace, cluster.Spec.PrimarySecretName)
+	if err == nil && current.Username == remote.Username && current.Password == remote.Password {
+		return nil
+	} else if err != nil && !operator.IsCredentialNotFound(err) {
+		return err
+	}
+
+	log.Debugf("standby streaming: taking replication user of the remote primary of cluster %s",
+		cluster.Name)
+
+	return store.Set(namespace, cluster.Spec.PrimarySecretName, operator.Credential{
+		ClusterName: cluster.Spec.Name,
+		Username:    remote.Username,
+		Password:    remote.Password,
+	})
+}
+
+// getStandbyStreamingSSLMode returns the sslmode the WAL of the remote primary is streamed with
+func getStandbyStreamingSSLMode(streaming crv1.StandbyStreamingSpec) string {
+	if streaming.SSLMode == "" {
+		return defaultStandbyStreamingSSLMode
+	}
+
+	return streaming.SSLMode
+}
+
+// isStandbyStreamingSSLMode determines whether the WAL can be streamed with the sslmode
+func isStandbyStreamingSSLMode(sslMode string) bool {
+	_, ok := standbyStreamingSSLModes[sslMode]
+	return ok
+}
+
+// getStandbyReplicationSSL returns the sslmode and the root certificate that the instances of a
+// cluster stream the WAL with, if the cluster streams the WAL of a remote primary. As any instance
+// can become the standby leader, all of them are set up alike
+func getStandbyReplicationSSL(cluster *crv1.Pgcluster) (string, string) {
+	if !cluster.Spec.Standby || cluster.Spec.StandbyStreaming.Host == "" {
+		return "", ""
+	}
+
+	sslMode := getStandbyStreamingSSLMode(cluster.Spec.StandbyStreaming)
+
+	if standbyStreamingSSLModes[sslMode] {
+		return sslMode, standbyStreamingSSLRootCert
+	}
+
+	return sslMode, ""
+}
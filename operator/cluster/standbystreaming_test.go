@@ -0,0 +1,148 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"testing"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/operator"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestValidateStandbyStreaming(t *testing.T) {
+	tls := crv1.TLSSpec{CASecret: "hippo-ca", TLSSecret: "hippo-tls"}
+	streaming := crv1.StandbyStreamingSpec{Host: "primary.example.com", ReplicationSecretName: "remote"}
+
+	for _, test := range []struct {
+		name  string
+		spec  crv1.PgclusterSpec
+		valid bool
+	}{
+		{"not streaming", crv1.PgclusterSpec{}, true},
+		{"streaming", crv1.PgclusterSpec{Standby: true, TLS: tls, StandbyStreaming: streaming}, true},
+		{"verify-full", crv1.PgclusterSpec{Standby: true, TLS: tls, StandbyStreaming: crv1.StandbyStreamingSpec{
+			Host: "primary.example.com", ReplicationSecretName: "remote", SSLMode: "verify-full"}}, true},
+		{"not a standby", crv1.PgclusterSpec{TLS: tls, StandbyStreaming: streaming}, false},
+		{"without TLS", crv1.PgclusterSpec{Standby: true, StandbyStreaming: streaming}, false},
+		{"without replication secret", crv1.PgclusterSpec{Standby: true, TLS: tls,
+			StandbyStreaming: crv1.StandbyStreamingSpec{Host: "primary.example.com"}}, false},
+		{"invalid sslmode", crv1.PgclusterSpec{Standby: true, TLS: tls, StandbyStreaming: crv1.StandbyStreamingSpec{
+			Host: "primary.example.com", ReplicationSecretName: "remote", SSLMode: "disable"}}, false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			cluster := &crv1.Pgcluster{Spec: test.spec}
+
+			if err := ValidateStandbyStreaming(cluster); (err == nil) != test.valid {
+				t.Errorf("expected valid: %v, got %v", test.valid, err)
+			}
+		})
+	}
+}
+
+func TestSetStandbyStreaming(t *testing.T) {
+	standbyJSON := map[string]interface{}{
+		"create_replica_methods": []interface{}{"pgbackrest_standby"},
+		"restore_command":        "pgbackrest archive-get %f \"%p\"",
+	}
+
+	if !setStandbyStreaming(standbyJSON, crv1.StandbyStreamingSpec{Host: "primary.example.com"}) {
+		t.Fatal("expected the remote primary to be set")
+	}
+	if standbyJSON["host"] != "primary.example.com" || standbyJSON["port"] != "5432" {
+		t.Fatalf("expected the remote primary with the default port, got %v", standbyJSON)
+	}
+
+	if setStandbyStreaming(standbyJSON, crv1.StandbyStreamingSpec{Host: "primary.example.com", Port: "5432"}) {
+		t.Error("expected the remote primary to be unchanged")
+	}
+
+	if !setStandbyStreaming(standbyJSON, crv1.StandbyStreamingSpec{}) {
+		t.Fatal("expected the remote primary to be removed")
+	}
+	if _, ok := standbyJSON["host"]; ok {
+		t.Errorf("expected no remote primary, got %v", standbyJSON)
+	}
+	if _, ok := standbyJSON["port"]; ok {
+		t.Errorf("expected no remote port, got %v", standbyJSON)
+	}
+
+	// the standby still falls back to the repository
+	if _, ok := standbyJSON["restore_command"]; !ok {
+		t.Error("expected the restore command to be kept")
+	}
+}
+
+func TestGetStandbyReplicationSSL(t *testing.T) {
+	cluster := &crv1.Pgcluster{Spec: crv1.PgclusterSpec{
+		Standby: true,
+		TLS:     crv1.TLSSpec{CASecret: "hippo-ca", TLSSecret: "hippo-tls"},
+	}}
+
+	if mode, rootCert := getStandbyReplicationSSL(cluster); mode != "" || rootCert != "" {
+		t.Errorf("expected no sslmode without streaming, got %q %q", mode, rootCert)
+	}
+
+	cluster.Spec.StandbyStreaming.Host = "primary.example.com"
+
+	if mode, rootCert := getStandbyReplicationSSL(cluster); mode != "require" || rootCert != "" {
+		t.Errorf("expected require without a root certificate, got %q %q", mode, rootCert)
+	}
+
+	cluster.Spec.StandbyStreaming.SSLMode = "verify-ca"
+
+	if mode, rootCert := getStandbyReplicationSSL(cluster); mode != "verify-ca" ||
+		rootCert != standbyStreamingSSLRootCert {
+		t.Errorf("expected verify-ca with the CA of the cluster, got %q %q", mode, rootCert)
+	}
+}
+
+func TestSetStandbyReplicationCredential(t *testing.T) {
+	cluster := &crv1.Pgcluster{
+		ObjectMeta: meta_v1.ObjectMeta{Name: "hippo", Namespace: "pgo"},
+		Spec: crv1.PgclusterSpec{
+			Name:              "hippo",
+			PrimarySecretName: "hippo-primaryuser-secret",
+			Standby:           true,
+			StandbyStreaming: crv1.StandbyStreamingSpec{
+				Host:                  "primary.example.com",
+				ReplicationSecretName: "remote-primaryuser-secret",
+			},
+		},
+	}
+
+	store := &fakeCredentialStore{credentials: map[string]operator.Credential{
+		"pgo/hippo-primaryuser-secret":  {ClusterName: "hippo", Username: "primaryuser", Password: "local"},
+		"pgo/remote-primaryuser-secret": {ClusterName: "remote", Username: "primaryuser", Password: "remote"},
+	}}
+
+	if err := setStandbyReplicationCredential(store, cluster); err != nil {
+		t.Fatal(err)
+	}
+
+	primary := store.credentials["pgo/hippo-primaryuser-secret"]
+	if primary.Password != "remote" || primary.ClusterName != "hippo" {
+		t.Fatalf("expected the replication user of the remote primary, got %v", primary)
+	}
+
+	// the credential is not needed once streaming is turned off
+	cluster.Spec.StandbyStreaming = crv1.StandbyStreamingSpec{}
+	delete(store.credentials, "pgo/remote-primaryuser-secret")
+
+	if err := setStandbyReplicationCredential(store, cluster); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,238 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/config"
+	"github.com/crunchydata/postgres-operator/kubeapi"
+	"github.com/crunchydata/postgres-operator/operator"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	// eventReasonStorageAutogrowFailed is the reason of the Warning events that are recorded
+	// when a volume of a cluster that filled up cannot be grown
+	eventReasonStorageAutogrowFailed = "StorageAutogrowFailed"
+
+	// storageAutogrowMinimumIncrement is the least that a volume is grown by, so that a small
+	// volume is not grown again and again
+	storageAutogrowMinimumIncrement = 1 << 30
+)
+
+// ValidateStorageAutogrow returns an error if the volumes of a cluster cannot be grown as they
+// are specified to, i.e. they are to be grown without a valid maximum size
+func ValidateStorageAutogrow(cluster *crv1.Pgcluster) error {
+	spec := cluster.Spec.StorageAutogrow
+
+	if !spec.Enabled {
+		return nil
+	}
+
+	if spec.MaxSize == "" {
+		return errors.New("growing the volumes requires a maximum size")
+	}
+
+	if reason := validateQuantity("maximum size of the volumes", spec.MaxSize); reason != "" {
+		return errors.New(reason)
+	}
+
+	return nil
+}
+
+// ReconcileStorageAutogrow checks how full the volumes of the instances of a cluster are, as
+// reported by the Kubelets of the nodes they are mounted on, and requests a larger size for
+// those whose usage crossed the threshold, up to the maximum size. The usage is recorded in the
+// status of the cluster, and a volume that is grown, or cannot be, is recorded in an event
+func ReconcileStorageAutogrow(clientset *kubernetes.Clientset, restclient *rest.RESTClient,
+	cluster *crv1.Pgcluster) error {
+	spec := cluster.Spec.StorageAutogrow
+
+	if !spec.Enabled || cluster.Status.State != crv1.PgclusterStateInitialized {
+		return nil
+	}
+
+	if err := ValidateStorageAutogrow(cluster); err != nil {
+		return err
+	}
+
+	maxSize := resource.MustParse(spec.MaxSize)
+
+	selector := fmt.Sprintf("%s=%s,%s=%s", config.LABEL_PG_CLUSTER, cluster.Name,
+		config.LABEL_PG_DATABASE, config.LABEL_TRUE)
+
+	pods, err := kubeapi.GetPods(clientset, selector, cluster.Namespace)
+	if err != nil {
+		return err
+	}
+
+	// the volumes of the instances are those that their Pods claim, and their usage is read
+	// from each node that runs one of the Pods
+	claims := map[string]bool{}
+	nodes := map[string]bool{}
+	for _, pod := range pods.Items {
+		for _, volume := range pod.Spec.Volumes {
+			if volume.PersistentVolumeClaim != nil {
+				claims[volume.PersistentVolumeClaim.ClaimName] = true
+			}
+		}
+
+		if pod.Spec.NodeName != "" {
+			nodes[pod.Spec.NodeName] = true
+		}
+	}
+
+	usage := map[string]kubeapi.VolumeStats{}
+	for node := range nodes {
+		stats, err := kubeapi.GetNodeVolumeStats(clientset, node)
+		if err != nil {
+			log.Warnf("storage autogrow: could not read the volumes on node %s: %s", node, err)
+			continue
+		}
+
+		for _, stat := range stats {
+			if stat.PVCRef != nil && stat.PVCRef.Namespace == cluster.Namespace &&
+				claims[stat.PVCRef.Name] && stat.CapacityBytes != nil && stat.UsedBytes != nil &&
+				*stat.CapacityBytes > 0 {
+				usage[stat.PVCRef.Name] = stat
+			}
+		}
+	}
+
+	previous := map[string]crv1.VolumeUsageStatus{}
+	for _, volume := range cluster.Status.StorageAutogrow.Volumes {
+		previous[volume.Name] = volume
+	}
+
+	names := []string{}
+	for name := range usage {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	status := crv1.StorageAutogrowStatus{}
+
+	for _, name := range names {
+		stat := usage[name]
+
+		pvc, found, err := kubeapi.GetPVC(clientset, name, cluster.Namespace)
+		if !found {
+			log.Warnf("storage autogrow: could not get pvc %s: %v", name, err)
+			continue
+		}
+
+		requested := pvc.Spec.Resources.Requests[v1.ResourceStorage]
+
+		volume := crv1.VolumeUsageStatus{
+			Name:        name,
+			UsedPercent: int(*stat.UsedBytes * 100 / *stat.CapacityBytes),
+			Capacity:    requested.String(),
+			LastGrown:   previous[name].LastGrown,
+		}
+
+		if volume.UsedPercent >= spec.GetThresholdPercent() {
+			size, reason := getStorageAutogrowSize(spec, pvc, maxSize)
+
+			if reason == "" {
+				log.Debugf("storage autogrow: growing pvc %s to %s", name, size.String())
+
+				pvc.Spec.Resources.Requests[v1.ResourceStorage] = size
+
+				if err := kubeapi.UpdatePVC(clientset, pvc, cluster.Namespace); err != nil {
+					reason = err.Error()
+				} else {
+					operator.RecordNormalEvent(clientset, cluster, operator.EventReasonPVCResizeStarted,
+						fmt.Sprintf("growing volume %s from %s to %s as it is %d%% full", name,
+							requested.String(), size.String(), volume.UsedPercent))
+
+					volume.Capacity = size.String()
+					volume.LastGrown = time.Now().UTC().Format(time.RFC3339)
+				}
+			}
+
+			volume.Message = reason
+
+			// a volume that cannot be grown is only recorded once, rather than every time it
+			// is checked
+			if reason != "" && reason != previous[name].Message {
+				operator.RecordWarningEvent(clientset, cluster, eventReasonStorageAutogrowFailed,
+					fmt.Sprintf("volume %s is %d%% full and cannot be grown: %s", name,
+						volume.UsedPercent, reason))
+			}
+		}
+
+		status.Volumes = append(status.Volumes, volume)
+	}
+
+	if reflect.DeepEqual(status, cluster.Status.StorageAutogrow) {
+		return nil
+	}
+
+	return kubeapi.Patchpgcluster(restclient, cluster, cluster.Namespace, func(cluster *crv1.Pgcluster) {
+		cluster.Status.StorageAutogrow = status
+	})
+}
+
+// getStorageAutogrowSize returns the size that a volume that filled up is grown to, which is its
+// current size increased by the increment, rounded up to whole gibibytes and capped at the
+// maximum size. If the volume cannot be grown, the reason is returned instead, e.g. it is already
+// at its maximum size or a previous expansion is still in progress
+func getStorageAutogrowSize(spec crv1.StorageAutogrowSpec, pvc *v1.PersistentVolumeClaim,
+	maxSize resource.Quantity) (resource.Quantity, string) {
+	requested, ok := pvc.Spec.Resources.Requests[v1.ResourceStorage]
+	if !ok {
+		return resource.Quantity{}, "the volume does not request a size"
+	}
+
+	if capacity, ok := pvc.Status.Capacity[v1.ResourceStorage]; ok && capacity.Cmp(requested) < 0 {
+		return resource.Quantity{}, fmt.Sprintf("the volume is still being expanded to %s",
+			requested.String())
+	}
+
+	if requested.Cmp(maxSize) >= 0 {
+		return resource.Quantity{}, fmt.Sprintf("the volume is at its maximum size of %s",
+			maxSize.String())
+	}
+
+	current := requested.Value()
+
+	increment := current * int64(spec.GetIncrementPercent()) / 100
+	if increment < storageAutogrowMinimumIncrement {
+		increment = storageAutogrowMinimumIncrement
+	}
+
+	// round up to whole gibibytes, so that the size reads as e.g. "12Gi"
+	size := current + increment
+	if remainder := size % storageAutogrowMinimumIncrement; remainder != 0 {
+		size += storageAutogrowMinimumIncrement - remainder
+	}
+
+	if size > maxSize.Value() {
+		return maxSize, ""
+	}
+
+	return *resource.NewQuantity(size, resource.BinarySI), ""
+}
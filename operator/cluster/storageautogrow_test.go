@@ -0,0 +1,66 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"strings"
+	"testing"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestGetStorageAutogrowSize(t *testing.T) {
+	maxSize := resource.MustParse("50Gi")
+
+	for _, test := range []struct {
+		name      string
+		increment int
+		requested string
+		capacity  string
+		size      string
+		reason    string
+	}{
+		{"default increment", 0, "10Gi", "10Gi", "12Gi", ""},
+		{"rounded up", 50, "5Gi", "5Gi", "8Gi", ""},
+		{"at least a gibibyte", 0, "1Gi", "1Gi", "2Gi", ""},
+		{"capped at the maximum", 0, "45Gi", "45Gi", "50Gi", ""},
+		{"at the maximum", 0, "50Gi", "50Gi", "", "at its maximum size of 50Gi"},
+		{"being expanded", 0, "12Gi", "10Gi", "", "still being expanded to 12Gi"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			pvc := &v1.PersistentVolumeClaim{}
+			pvc.Spec.Resources.Requests = v1.ResourceList{v1.ResourceStorage: resource.MustParse(test.requested)}
+			pvc.Status.Capacity = v1.ResourceList{v1.ResourceStorage: resource.MustParse(test.capacity)}
+
+			spec := crv1.StorageAutogrowSpec{Enabled: true, IncrementPercent: test.increment}
+
+			size, reason := getStorageAutogrowSize(spec, pvc, maxSize)
+
+			if test.reason != "" {
+				if !strings.Contains(reason, test.reason) {
+					t.Fatalf("expected the volume not to be grown as %q, got %q", test.reason, reason)
+				}
+				return
+			}
+
+			if reason != "" || size.String() != test.size {
+				t.Fatalf("expected the volume to be grown to %s, got %s (%s)", test.size, size.String(), reason)
+			}
+		})
+	}
+}
@@ -0,0 +1,339 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/config"
+	"github.com/crunchydata/postgres-operator/kubeapi"
+	"github.com/crunchydata/postgres-operator/operator"
+	"github.com/crunchydata/postgres-operator/util"
+	log "github.com/sirupsen/logrus"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	// eventReasonSwitchoverCompleted is the reason of the events that are recorded when the
+	// primary of a cluster was switched over to a replica by a switchover pgtask
+	eventReasonSwitchoverCompleted = "SwitchoverCompleted"
+
+	// eventReasonSwitchoverFailed is the reason of the Warning events that are recorded when a
+	// switchover pgtask failed
+	eventReasonSwitchoverFailed = "SwitchoverFailed"
+)
+
+// replicaStatesRunning are the states that Patroni reports a replica in when it is up, which
+// is "running" or, as of Patroni 3, "streaming" while it streams from the primary
+var replicaStatesRunning = []string{"running", "streaming"}
+
+// AddSwitchover handles a "switchover" pgtask, which has the primary of a running cluster step
+// down in favor of a replica, e.g. ahead of maintenance of the node the primary runs on. The
+// replica is the target of the pgtask, or the replica that is least behind the primary if the
+// pgtask does not name one, which is then recorded as its target. Unlike a failover, the primary
+// is shut down cleanly and follows the new primary as a replica
+func AddSwitchover(clientset *kubernetes.Clientset, restclient *rest.RESTClient,
+	restconfig *rest.Config, t *crv1.Pgtask, namespace string) {
+	clusterName := t.ObjectMeta.Labels[config.LABEL_PG_CLUSTER]
+
+	cluster := crv1.Pgcluster{}
+	if _, err := kubeapi.Getpgcluster(restclient, &cluster, clusterName, namespace); err != nil {
+		log.Error("could not find pgcluster for switchover")
+		log.Error(err)
+		return
+	}
+
+	// get the latest version of the task in case it changed
+	task := crv1.Pgtask{}
+	if found, err := kubeapi.Getpgtask(restclient, &task, t.Spec.Name, namespace); !found {
+		log.Error("could not find pgtask for switchover")
+		log.Error(err)
+		return
+	}
+
+	// have a guard -- if the task has already been started, don't proceed
+	switch task.Spec.Status {
+	case crv1.PgtaskSwitchoverInProgress, crv1.PgtaskSwitchoverCompleted,
+		crv1.PgtaskSwitchoverFailed:
+		log.Warnf("pgtask [%s] has already been processed", task.Spec.Name)
+		return
+	}
+
+	if cluster.Status.State != crv1.PgclusterStateInitialized || cluster.Spec.Shutdown {
+		failSwitchover(clientset, restclient, &cluster, task.Spec.Name,
+			fmt.Sprintf("cluster %s is not running", cluster.Name))
+		return
+	}
+
+	if cluster.Spec.Standby {
+		failSwitchover(clientset, restclient, &cluster, task.Spec.Name,
+			fmt.Sprintf("cluster %s is a standby cluster", cluster.Name))
+		return
+	}
+
+	// unlike a failover, a switchover waits for the replica to catch up, so it does not lose
+	// transactions even if the replica is not synchronous
+	target, err := getRoleChangeTarget(clientset, restconfig, &cluster,
+		task.ObjectMeta.Labels[config.LABEL_TARGET], true)
+	if err != nil {
+		failSwitchover(clientset, restclient, &cluster, task.Spec.Name, err.Error())
+		return
+	}
+
+	if task.ObjectMeta.Labels == nil {
+		task.ObjectMeta.Labels = make(map[string]string)
+	}
+
+	task.ObjectMeta.Labels[config.LABEL_TARGET] = target
+	task.Spec.Status = crv1.PgtaskSwitchoverInProgress
+
+	if err := kubeapi.Updatepgtask(restclient, &task, task.Spec.Name, namespace); err != nil {
+		log.Error("error in updating switchover pgtask status " + err.Error())
+		return
+	}
+
+	// waiting for the replica to take over can take a while, so this is done in the background
+	// so as to not hold up the processing of other pgtasks
+	go runSwitchover(clientset, restclient, restconfig, cluster, target, task.Spec.Name)
+}
+
+// runSwitchover switches the primary of a cluster over to the target replica and records the
+// replica as the current primary of the cluster, and completes or fails the pgtask once it is
+// over. The Services of the cluster follow the role that Patroni labels the Pods with, and the
+// Pod controller takes the same steps for the new primary as it does after any failover
+func runSwitchover(clientset *kubernetes.Clientset, restclient *rest.RESTClient,
+	restconfig *rest.Config, cluster crv1.Pgcluster, target, taskName string) {
+	log.Infof("switchover: switching cluster %s over to %s", cluster.Name, target)
+
+	// the primary is looked up by the namespace in the spec
+	cluster.Spec.Namespace = cluster.Namespace
+
+	primaryPod, err := util.GetPrimaryPod(clientset, &cluster)
+	if err != nil {
+		failSwitchover(clientset, restclient, &cluster, taskName, err.Error())
+		return
+	}
+
+	candidate, err := util.GetPod(clientset, target, cluster.Namespace)
+	if err != nil {
+		failSwitchover(clientset, restclient, &cluster, taskName, err.Error())
+		return
+	}
+
+	if err := switchoverPrimary(clientset, restconfig, &cluster, primaryPod, candidate,
+		rollingRestartTimeout, rollingRestartPeriod); err != nil {
+		failSwitchover(clientset, restclient, &cluster, taskName, err.Error())
+		return
+	}
+
+	if err := setCurrentPrimary(restclient, &cluster, target); err != nil {
+		log.Errorf("switchover: could not record %s as the primary of cluster %s: %s", target,
+			cluster.Name, err)
+	}
+
+	task := crv1.Pgtask{}
+	if found, err := kubeapi.Getpgtask(restclient, &task, taskName, cluster.Namespace); !found {
+		log.Error(err)
+		return
+	}
+
+	task.Spec.Status = crv1.PgtaskSwitchoverCompleted
+
+	if err := kubeapi.Updatepgtask(restclient, &task, task.Spec.Name, cluster.Namespace); err != nil {
+		log.Error("error in updating switchover pgtask status " + err.Error())
+	}
+
+	message := fmt.Sprintf("switched over from %s to %s",
+		primaryPod.ObjectMeta.Labels[config.LABEL_DEPLOYMENT_NAME], target)
+
+	if err := kubeapi.PatchpgtaskPhase(restclient, crv1.PgtaskStateProcessed, crv1.PgtaskPhaseSucceeded,
+		message, &task, cluster.Namespace); err != nil {
+		log.Error(err)
+	}
+
+	operator.RecordNormalEvent(clientset, &cluster, eventReasonSwitchoverCompleted,
+		fmt.Sprintf("%s by pgtask %s", message, taskName))
+}
+
+// failSwitchover marks the pgtask as failed and records why on both the pgtask and the cluster.
+// A switchover that Patroni could not carry out leaves the primary as it was
+func failSwitchover(clientset *kubernetes.Clientset, restclient *rest.RESTClient,
+	cluster *crv1.Pgcluster, taskName, message string) {
+	log.Errorf("switchover: failed on cluster %s: %s", cluster.Name, message)
+
+	task := crv1.Pgtask{}
+	if found, err := kubeapi.Getpgtask(restclient, &task, taskName, cluster.Namespace); !found {
+		log.Error(err)
+		return
+	}
+
+	task.Spec.Status = crv1.PgtaskSwitchoverFailed
+
+	if err := kubeapi.Updatepgtask(restclient, &task, task.Spec.Name, cluster.Namespace); err != nil {
+		log.Error("error in updating switchover pgtask status " + err.Error())
+	}
+
+	if err := kubeapi.PatchpgtaskPhase(restclient, crv1.PgtaskStateProcessed, crv1.PgtaskPhaseFailed,
+		message, &task, cluster.Namespace); err != nil {
+		log.Error(err)
+	}
+
+	operator.RecordWarningEvent(clientset, cluster, eventReasonSwitchoverFailed, message)
+}
+
+// getRoleChangeTarget returns the replica that a switchover or a failover of a cluster promotes,
+// given the name of its Deployment. A target that is named has to be a replica of the cluster,
+// and otherwise the running replica that is least behind the primary is picked. Unless forced,
+// only the synchronous replicas of a cluster with synchronous replication that are sure to have
+// every commit are promoted. Delayed
+// replicas are never promoted, as they are behind the primary on purpose
+func getRoleChangeTarget(clientset *kubernetes.Clientset, restconfig *rest.Config,
+	cluster *crv1.Pgcluster, target string, force bool) (string, error) {
+	synchronous, restricted, err := getSyncReplicationTargets(clientset, restconfig, cluster, force)
+	if err != nil {
+		return "", err
+	}
+
+	delayed, err := getDelayedReplicas(clientset, cluster.Name, cluster.Namespace)
+	if err != nil {
+		return "", err
+	}
+
+	if target != "" {
+		selector := fmt.Sprintf("%s=%s,%s=%s", config.LABEL_PG_CLUSTER, cluster.Name,
+			config.LABEL_DEPLOYMENT_NAME, target)
+
+		deployments, err := kubeapi.GetDeployments(clientset, selector, cluster.Namespace)
+		if err != nil {
+			return "", err
+		}
+		if len(deployments.Items) == 0 {
+			return "", fmt.Errorf("no instance %s found in cluster %s", target, cluster.Name)
+		}
+
+		if _, err := util.GetPod(clientset, target, cluster.Namespace); err != nil {
+			return "", fmt.Errorf("instance %s is not a replica that can be promoted: %s", target, err)
+		}
+
+		if containsString(delayed, target) {
+			return "", fmt.Errorf("instance %s is a delayed replica of cluster %s, and cannot be "+
+				"promoted", target, cluster.Name)
+		}
+
+		if restricted && !containsString(synchronous, target) {
+			return "", fmt.Errorf("instance %s is not a synchronous replica of cluster %s that "+
+				"is sure to have every commit, and can only be promoted by a forced failover",
+				target, cluster.Name)
+		}
+
+		return target, nil
+	}
+
+	status, err := util.ReplicationStatus(util.ReplicationStatusRequest{
+		RESTConfig:  restconfig,
+		Clientset:   clientset,
+		Namespace:   cluster.Namespace,
+		ClusterName: cluster.Name,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	instances := []util.InstanceReplicationInfo{}
+	for _, instance := range status.Instances {
+		if !containsString(delayed, instance.Name) &&
+			(!restricted || containsString(synchronous, instance.Name)) {
+			instances = append(instances, instance)
+		}
+	}
+
+	if !restricted {
+		return getLeastLaggedReplica(instances)
+	}
+
+	target, err = getLeastLaggedReplica(instances)
+	if err != nil {
+		return "", errors.New("no running synchronous replica to promote, only a forced " +
+			"failover promotes one that is not")
+	}
+
+	return target, nil
+}
+
+// getLeastLaggedReplica returns the running replica that is least behind the primary, going by
+// the name of the replica when several are as far behind
+func getLeastLaggedReplica(instances []util.InstanceReplicationInfo) (string, error) {
+	running := []util.InstanceReplicationInfo{}
+
+	for _, instance := range instances {
+		if instance.Name != "" && containsString(replicaStatesRunning, instance.Status) {
+			running = append(running, instance)
+		}
+	}
+
+	if len(running) == 0 {
+		return "", errors.New("no running replica to promote")
+	}
+
+	sort.Slice(running, func(i, j int) bool {
+		if running[i].ReplicationLag != running[j].ReplicationLag {
+			return running[i].ReplicationLag < running[j].ReplicationLag
+		}
+		return running[i].Name < running[j].Name
+	})
+
+	return running[0].Name, nil
+}
+
+// recordRoleChangeTarget records the replica that a switchover or a failover pgtask promotes as
+// the target of the pgtask, on the latest version of the pgtask as well as the one given
+func recordRoleChangeTarget(restclient *rest.RESTClient, task *crv1.Pgtask, target string) error {
+	latest := crv1.Pgtask{}
+	if found, err := kubeapi.Getpgtask(restclient, &latest, task.Spec.Name, task.Namespace); !found {
+		return err
+	}
+
+	for _, t := range []*crv1.Pgtask{&latest, task} {
+		if t.ObjectMeta.Labels == nil {
+			t.ObjectMeta.Labels = make(map[string]string)
+		}
+		t.ObjectMeta.Labels[config.LABEL_TARGET] = target
+	}
+
+	return kubeapi.Updatepgtask(restclient, &latest, latest.Spec.Name, latest.Namespace)
+}
+
+// setCurrentPrimary records an instance as the current primary of a cluster, which is the
+// instance that e.g. an upgrade or a restore of the cluster starts from
+func setCurrentPrimary(restclient *rest.RESTClient, cluster *crv1.Pgcluster, instance string) error {
+	latest := crv1.Pgcluster{}
+	if found, err := kubeapi.Getpgcluster(restclient, &latest, cluster.Name,
+		cluster.Namespace); !found {
+		return err
+	}
+
+	if latest.Spec.UserLabels == nil {
+		latest.Spec.UserLabels = make(map[string]string)
+	}
+
+	latest.Spec.UserLabels[config.LABEL_CURRENT_PRIMARY] = instance
+
+	return util.PatchClusterCRD(restclient, latest.Spec.UserLabels, &latest, latest.Namespace)
+}
@@ -0,0 +1,65 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"testing"
+
+	"github.com/crunchydata/postgres-operator/util"
+)
+
+func TestGetLeastLaggedReplica(t *testing.T) {
+	for _, test := range []struct {
+		name      string
+		instances []util.InstanceReplicationInfo
+		expected  string
+	}{
+		{"least lag", []util.InstanceReplicationInfo{
+			{Name: "hippo-abcd", Status: "running", ReplicationLag: 4},
+			{Name: "hippo-efgh", Status: "running", ReplicationLag: 1},
+		}, "hippo-efgh"},
+		{"tie by name", []util.InstanceReplicationInfo{
+			{Name: "hippo-efgh", Status: "running"},
+			{Name: "hippo-abcd", Status: "streaming"},
+		}, "hippo-abcd"},
+		{"not running", []util.InstanceReplicationInfo{
+			{Name: "hippo-abcd", Status: "stopped"},
+			{Name: "hippo-efgh", Status: "running", ReplicationLag: 16},
+		}, "hippo-efgh"},
+		{"unknown instance", []util.InstanceReplicationInfo{
+			{Status: "running"},
+			{Name: "hippo-abcd", Status: "running", ReplicationLag: 2},
+		}, "hippo-abcd"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			target, err := getLeastLaggedReplica(test.instances)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if target != test.expected {
+				t.Fatalf("expected %q, got %q", test.expected, target)
+			}
+		})
+	}
+
+	t.Run("none running", func(t *testing.T) {
+		if _, err := getLeastLaggedReplica([]util.InstanceReplicationInfo{
+			{Name: "hippo-abcd", Status: "starting"},
+		}); err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+}
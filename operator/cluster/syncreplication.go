@@ -0,0 +1,330 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/config"
+	"github.com/crunchydata/postgres-operator/kubeapi"
+	"github.com/crunchydata/postgres-operator/operator"
+	"github.com/crunchydata/postgres-operator/util"
+	log "github.com/sirupsen/logrus"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	// syncReplicationModeSetting is the setting of the Patroni configuration that has Patroni
+	// pick a synchronous replica and set "synchronous_standby_names" for it
+	syncReplicationModeSetting = "synchronous_mode"
+
+	// syncReplicationStandbyNamesParam is the PostgreSQL parameter that lists the replicas that
+	// the commits on the primary wait for
+	syncReplicationStandbyNamesParam = "synchronous_standby_names"
+)
+
+// sqlReceivedWALLSN returns the position in the WAL that a replica received up to, or replayed up
+// to if it does not stream. A replica that has neither is at the start of the WAL
+const sqlReceivedWALLSN = `SELECT GREATEST(COALESCE(pg_catalog.pg_last_wal_receive_lsn(), '0/0'),
+COALESCE(pg_catalog.pg_last_wal_replay_lsn(), '0/0'));`
+
+// syncReplicationPatroniRoles are the roles that Patroni reports a synchronous replica in, which
+// is "Sync Standby" or, as of Patroni 3.2, "Quorum Standby"
+var syncReplicationPatroniRoles = []string{"Sync Standby", "Quorum Standby"}
+
+// ValidateSyncReplication returns an error if the synchronous replication quorum of a cluster is
+// negative, or it is set while synchronous replication is not enabled or on a version of
+// PostgreSQL that predates quorum commit, which is only available as of PostgreSQL 10
+func ValidateSyncReplication(cluster *crv1.Pgcluster) error {
+	quorum := cluster.Spec.SyncReplicationQuorum
+
+	if quorum < 0 {
+		return fmt.Errorf("invalid synchronous replication quorum of %d, it cannot be negative", quorum)
+	}
+
+	if quorum > 0 && !operator.GetSyncReplication(cluster.Spec.SyncReplication) {
+		return errors.New("a synchronous replication quorum requires synchronous replication")
+	}
+
+	if version := getPGMajorVersion(cluster.Spec.CCPImageTag); quorum > 0 &&
+		(version == "9.5" || version == "9.6") {
+		return errors.New("a synchronous replication quorum requires PostgreSQL 10 or later")
+	}
+
+	return nil
+}
+
+// ReconcileSyncReplication keeps the synchronous replication of a cluster in line with its spec
+// and its replicas. Without a quorum, Patroni picks the synchronous replica itself, which is only
+// recorded. With a quorum, the Operator takes over from Patroni and sets
+// "synchronous_standby_names" so that each commit waits for any quorum of the replicas, which it
+// updates as replicas are added and removed, or their Pods are replaced. The quorum is no more
+// than the cluster has replicas, so that commits do not wait for replicas that do not exist. The
+// synchronous replicas are recorded in the status of the cluster, as they are the only replicas
+// that the cluster is failed over to unless the failover is forced. With a quorum of fewer than
+// all of them, only as many as the quorum are sure to have each commit, so the cluster is then
+// only failed over to the one of them that received the most WAL
+func ReconcileSyncReplication(clientset *kubernetes.Clientset, restclient *rest.RESTClient,
+	restconfig *rest.Config, cluster *crv1.Pgcluster) error {
+	if cluster.Status.State != crv1.PgclusterStateInitialized || cluster.Spec.Shutdown ||
+		cluster.Spec.Standby {
+		return nil
+	}
+
+	enabled := operator.GetSyncReplication(cluster.Spec.SyncReplication)
+	status := crv1.SyncReplicationStatus{}
+
+	switch {
+	case enabled && cluster.Spec.SyncReplicationQuorum > 0:
+		selector := fmt.Sprintf("%s=%s,%s=replica", config.LABEL_PG_CLUSTER, cluster.Name,
+			config.LABEL_PGHA_ROLE)
+
+		pods, err := kubeapi.GetPods(clientset, selector, cluster.Namespace)
+		if err != nil {
+			return err
+		}
+
+		// delayed replicas would hold up every commit, and replicas that stream from another
+		// replica do not stream from the primary at all, so neither is part of the quorum
+		delayed, err := getDelayedReplicas(clientset, cluster.Name, cluster.Namespace)
+		if err != nil {
+			return err
+		}
+
+		cascading, err := getCascadingReplicas(restclient, cluster.Name, cluster.Namespace)
+		if err != nil {
+			return err
+		}
+
+		// Patroni names the replicas after their Pods
+		names := []string{}
+		for _, pod := range pods.Items {
+			instance := pod.Labels[config.LABEL_DEPLOYMENT_NAME]
+
+			if pod.DeletionTimestamp == nil && !containsString(delayed, instance) &&
+				!containsString(cascading, instance) {
+				names = append(names, pod.Name)
+				status.Replicas = append(status.Replicas, instance)
+			}
+		}
+
+		sort.Strings(names)
+		sort.Strings(status.Replicas)
+
+		status.Quorum = getSyncReplicationQuorum(cluster.Spec.SyncReplicationQuorum, len(names))
+		status.StandbyNames = getSynchronousStandbyNames(status.Quorum, names)
+	case enabled:
+		replication, err := util.ReplicationStatus(util.ReplicationStatusRequest{
+			RESTConfig:  restconfig,
+			Clientset:   clientset,
+			Namespace:   cluster.Namespace,
+			ClusterName: cluster.Name,
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, instance := range replication.Instances {
+			if instance.Name != "" && containsString(syncReplicationPatroniRoles, instance.Role) {
+				status.Replicas = append(status.Replicas, instance.Name)
+			}
+		}
+
+		sort.Strings(status.Replicas)
+	}
+
+	if err := updateSyncReplicationConfig(clientset, cluster,
+		enabled && cluster.Spec.SyncReplicationQuorum == 0, status.StandbyNames); err != nil {
+		return err
+	}
+
+	if reflect.DeepEqual(status, cluster.Status.SyncReplication) {
+		return nil
+	}
+
+	log.Infof("sync replication: synchronous replicas of cluster %s are %v, quorum %d",
+		cluster.Name, status.Replicas, status.Quorum)
+
+	return kubeapi.Patchpgcluster(restclient, cluster, cluster.Namespace, func(cluster *crv1.Pgcluster) {
+		cluster.Status.SyncReplication = status
+	})
+}
+
+// updateSyncReplicationConfig sets whether Patroni manages the synchronous replication of a
+// cluster, and the "synchronous_standby_names" that the Operator manages otherwise, in the
+// configuration that Patroni keeps in the DCS. Patroni reloads PostgreSQL for them. An empty
+// value removes "synchronous_standby_names", so that commits do not wait for any replica
+func updateSyncReplicationConfig(clientset *kubernetes.Clientset, cluster *crv1.Pgcluster,
+	synchronousMode bool, standbyNames string) error {
+	dcsConfigMap, configJSON, parameters, err := getDCSParameters(clientset, cluster)
+	if err != nil {
+		return err
+	}
+
+	mode, _ := configJSON[syncReplicationModeSetting].(bool)
+	current, set := parameters[syncReplicationStandbyNamesParam]
+
+	if mode == synchronousMode &&
+		((standbyNames == "" && !set) || (set && fmt.Sprint(current) == standbyNames)) {
+		return nil
+	}
+
+	log.Debugf("sync replication: setting synchronous mode %t and standby names %q for cluster %s",
+		synchronousMode, standbyNames, cluster.Name)
+
+	configJSON[syncReplicationModeSetting] = synchronousMode
+
+	if standbyNames == "" {
+		delete(parameters, syncReplicationStandbyNamesParam)
+	} else {
+		parameters[syncReplicationStandbyNamesParam] = standbyNames
+	}
+
+	configJSONStr, err := json.Marshal(configJSON)
+	if err != nil {
+		return err
+	}
+
+	dcsConfigMap.ObjectMeta.Annotations["config"] = string(configJSONStr)
+
+	return kubeapi.UpdateConfigMap(clientset, dcsConfigMap, cluster.Namespace)
+}
+
+// getSyncReplicationQuorum returns the number of replicas that each commit waits for, which is
+// the quorum of the spec, but no more than the cluster has replicas
+func getSyncReplicationQuorum(quorum, replicas int) int {
+	if quorum > replicas {
+		return replicas
+	}
+
+	return quorum
+}
+
+// getSynchronousStandbyNames returns the "synchronous_standby_names" that has each commit wait
+// for any quorum of the given replicas, or an empty value if it waits for none of them
+func getSynchronousStandbyNames(quorum int, names []string) string {
+	if quorum <= 0 || len(names) == 0 {
+		return ""
+	}
+
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+	}
+
+	return fmt.Sprintf("ANY %d (%s)", quorum, strings.Join(quoted, ", "))
+}
+
+// getSyncReplicationTargets returns the replicas that a cluster can be failed over to without
+// losing transactions that were committed, and whether the failover is restricted to them, which
+// it is when the cluster has synchronous replication enabled and the failover is not forced. With
+// a quorum of fewer than all of the synchronous replicas, any one of them can be missing commits,
+// so the targets are narrowed down to the one that received the most WAL
+func getSyncReplicationTargets(clientset *kubernetes.Clientset, restconfig *rest.Config,
+	cluster *crv1.Pgcluster, force bool) ([]string, bool, error) {
+	if force || !operator.GetSyncReplication(cluster.Spec.SyncReplication) {
+		return nil, false, nil
+	}
+
+	status := cluster.Status.SyncReplication
+
+	if status.Quorum == 0 || status.Quorum >= len(status.Replicas) {
+		return status.Replicas, true, nil
+	}
+
+	selector := fmt.Sprintf("%s=%s,%s=%s", config.LABEL_PG_CLUSTER, cluster.Name,
+		config.LABEL_PGHA_ROLE, config.LABEL_PGHA_ROLE_REPLICA)
+
+	pods, err := kubeapi.GetPods(clientset, selector, cluster.Namespace)
+	if err != nil {
+		return nil, true, err
+	}
+
+	received := map[string]uint64{}
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		instance := pod.Labels[config.LABEL_DEPLOYMENT_NAME]
+
+		if !containsString(status.Replicas, instance) || pod.DeletionTimestamp != nil {
+			continue
+		}
+
+		lsn, err := execMaintenanceSQL(clientset, restconfig, pod, "postgres", sqlReceivedWALLSN)
+		if err != nil {
+			log.Debugf("sync replication: could not get the WAL position of replica %s: %s",
+				instance, err)
+			continue
+		}
+
+		position, err := parseWALLSN(lsn)
+		if err != nil {
+			return nil, true, err
+		}
+
+		received[instance] = position
+	}
+
+	return getMostReceivedReplicas(received), true, nil
+}
+
+// getMostReceivedReplicas returns the replicas that received the most WAL, given the position in
+// the WAL that each of them received up to
+func getMostReceivedReplicas(received map[string]uint64) []string {
+	replicas := []string{}
+	most := uint64(0)
+
+	for instance, position := range received {
+		switch {
+		case len(replicas) == 0 || position > most:
+			replicas, most = []string{instance}, position
+		case position == most:
+			replicas = append(replicas, instance)
+		}
+	}
+
+	sort.Strings(replicas)
+
+	return replicas
+}
+
+// parseWALLSN returns a position in the WAL, which PostgreSQL formats as two hexadecimal numbers
+// of 32 bits, as a single number
+func parseWALLSN(lsn string) (uint64, error) {
+	parts := strings.Split(lsn, "/")
+	if len(parts) != 2 || !walLSNPattern.MatchString(lsn) {
+		return 0, fmt.Errorf("unexpected WAL position %q", lsn)
+	}
+
+	high, err := strconv.ParseUint(parts[0], 16, 32)
+	if err != nil {
+		return 0, err
+	}
+
+	low, err := strconv.ParseUint(parts[1], 16, 32)
+	if err != nil {
+		return 0, err
+	}
+
+	return high<<32 | low, nil
+}
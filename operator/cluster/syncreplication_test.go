@@ -0,0 +1,123 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"reflect"
+	"testing"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+)
+
+func TestGetSynchronousStandbyNames(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		quorum   int
+		replicas int
+		names    []string
+		expected string
+	}{
+		{"quorum", 1, 2, []string{"hippo-abcd-12345", "hippo-efgh-67890"},
+			`ANY 1 ("hippo-abcd-12345", "hippo-efgh-67890")`},
+		{"more than the replicas", 3, 2, []string{"hippo-abcd-12345", "hippo-efgh-67890"},
+			`ANY 2 ("hippo-abcd-12345", "hippo-efgh-67890")`},
+		{"no replicas", 2, 0, nil, ""},
+		{"quoted", 1, 1, []string{`hippo"abcd`}, `ANY 1 ("hippo""abcd")`},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			quorum := getSyncReplicationQuorum(test.quorum, test.replicas)
+
+			if names := getSynchronousStandbyNames(quorum, test.names); names != test.expected {
+				t.Fatalf("expected %q, got %q", test.expected, names)
+			}
+		})
+	}
+}
+
+func TestGetSyncReplicationTargets(t *testing.T) {
+	enabled := true
+
+	cluster := &crv1.Pgcluster{
+		Spec: crv1.PgclusterSpec{SyncReplication: &enabled},
+		Status: crv1.PgclusterStatus{
+			SyncReplication: crv1.SyncReplicationStatus{Replicas: []string{"hippo-abcd"}},
+		},
+	}
+
+	if targets, restricted, err := getSyncReplicationTargets(nil, nil, cluster, false); err != nil ||
+		!restricted || !reflect.DeepEqual(targets, []string{"hippo-abcd"}) {
+		t.Fatalf("expected only the synchronous replicas, got %v, %t, %v", targets, restricted, err)
+	}
+
+	if _, restricted, _ := getSyncReplicationTargets(nil, nil, cluster, true); restricted {
+		t.Fatal("expected a forced failover to promote any replica")
+	}
+
+	enabled = false
+
+	if _, restricted, _ := getSyncReplicationTargets(nil, nil, cluster, false); restricted {
+		t.Fatal("expected any replica without synchronous replication")
+	}
+}
+
+func TestGetMostReceivedReplicas(t *testing.T) {
+	received := map[string]uint64{"hippo-abcd": 100, "hippo-efgh": 300, "hippo-ijkl": 300}
+
+	if replicas := getMostReceivedReplicas(received); !reflect.DeepEqual(replicas,
+		[]string{"hippo-efgh", "hippo-ijkl"}) {
+		t.Fatalf("expected the replicas that received the most WAL, got %v", replicas)
+	}
+
+	if replicas := getMostReceivedReplicas(map[string]uint64{}); len(replicas) != 0 {
+		t.Fatalf("expected no replicas, got %v", replicas)
+	}
+}
+
+func TestParseWALLSN(t *testing.T) {
+	for lsn, expected := range map[string]uint64{
+		"0/0":         0,
+		"0/3000060":   0x3000060,
+		"16/B374D848": 0x16<<32 | 0xB374D848,
+	} {
+		if position, err := parseWALLSN(lsn); err != nil || position != expected {
+			t.Errorf("expected %d for %q, got %d, %v", expected, lsn, position, err)
+		}
+	}
+
+	if _, err := parseWALLSN("not a position"); err == nil {
+		t.Error("expected an invalid WAL position")
+	}
+}
+
+func TestValidateSyncReplication(t *testing.T) {
+	enabled := true
+
+	cluster := &crv1.Pgcluster{Spec: crv1.PgclusterSpec{
+		SyncReplication:       &enabled,
+		SyncReplicationQuorum: 2,
+		CCPImageTag:           "centos7-12.2-4.3.0",
+	}}
+
+	if err := ValidateSyncReplication(cluster); err != nil {
+		t.Fatalf("expected a valid quorum, got %s", err)
+	}
+
+	cluster.Spec.CCPImageTag = "centos7-9.6.17-4.3.0"
+
+	if err := ValidateSyncReplication(cluster); err == nil {
+		t.Fatal("expected a quorum to require PostgreSQL 10")
+	}
+}
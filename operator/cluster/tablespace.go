@@ -0,0 +1,201 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/config"
+	"github.com/crunchydata/postgres-operator/kubeapi"
+	"github.com/crunchydata/postgres-operator/operator"
+	"github.com/crunchydata/postgres-operator/util"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// sqlTablespaces returns the names of the tablespaces that are not built into PostgreSQL
+const sqlTablespaces = `SELECT spcname FROM pg_catalog.pg_tablespace WHERE spcname NOT IN ('pg_default', 'pg_global') ORDER BY spcname;`
+
+// ValidateTablespaces returns an error if the tablespaces of a cluster cannot be created as they
+// are specified: each needs a name that its volumes can be named after and that no other
+// tablespace has, and volumes that the Operator creates for every instance
+func ValidateTablespaces(cluster *crv1.Pgcluster) error {
+	names := map[string]bool{}
+	for name := range cluster.Spec.TablespaceMounts {
+		names[name] = true
+	}
+
+	for _, tablespace := range cluster.Spec.Tablespaces {
+		if tablespace.Name == "" {
+			return errors.New("the name of a tablespace is not set")
+		}
+
+		if errs := validation.IsDNS1123Label(operator.GetTablespaceVolumeName(tablespace.Name)); len(errs) != 0 {
+			return fmt.Errorf("invalid tablespace name %q: %s", tablespace.Name, strings.Join(errs, ", "))
+		}
+
+		if names[tablespace.Name] {
+			return fmt.Errorf("duplicate tablespace %q", tablespace.Name)
+		}
+		names[tablespace.Name] = true
+
+		switch storageType := tablespace.Storage.StorageType; storageType {
+		case "create", "dynamic":
+		default:
+			return fmt.Errorf("invalid storage type %q of tablespace %q, must be \"create\" or \"dynamic\"",
+				storageType, tablespace.Name)
+		}
+	}
+
+	return nil
+}
+
+// ReconcileTablespaces creates the tablespaces of a cluster in PostgreSQL that do not exist there
+// yet, once the primary mounts their volumes, and lets the user of the cluster create objects in
+// them. The tablespaces that exist are recorded in the status of the cluster, so that PostgreSQL
+// is only checked again once the cluster has a tablespace that is not among them
+func ReconcileTablespaces(clientset *kubernetes.Clientset, restclient *rest.RESTClient,
+	restconfig *rest.Config, cluster *crv1.Pgcluster) error {
+	// the tablespaces of a standby cluster are those of the cluster it replays the WAL of
+	if cluster.Status.State != crv1.PgclusterStateInitialized || cluster.Spec.Standby {
+		return nil
+	}
+
+	recorded := map[string]bool{}
+	for _, name := range cluster.Status.Tablespaces {
+		recorded[name] = true
+	}
+
+	missing := []string{}
+	for name := range cluster.Spec.GetTablespaces() {
+		if !recorded[name] {
+			missing = append(missing, name)
+		}
+	}
+
+	if len(missing) == 0 {
+		return nil
+	}
+
+	sort.Strings(missing)
+
+	pod, err := util.GetPrimaryPod(clientset, cluster)
+	if err != nil {
+		return err
+	}
+
+	output, err := execMaintenanceSQL(clientset, restconfig, pod, "postgres", sqlTablespaces)
+	if err != nil {
+		return err
+	}
+
+	existing := map[string]bool{}
+	for _, name := range strings.Fields(output) {
+		existing[name] = true
+	}
+
+	for _, name := range missing {
+		if existing[name] {
+			continue
+		}
+
+		// until the primary is restarted with the volume of the tablespace, its location would
+		// be in the file system of the container
+		if !hasTablespaceVolumeMount(pod, name) {
+			log.Debugf("tablespace %s is not mounted by primary %s yet", name, pod.Name)
+			continue
+		}
+
+		log.Infof("creating tablespace %s in cluster %s", name, cluster.Name)
+
+		location := getTablespaceLocation(name)
+
+		// PostgreSQL only creates a tablespace in an empty directory that it owns
+		if _, stderr, err := kubeapi.ExecToPodThroughAPI(restconfig, clientset,
+			[]string{"mkdir", "-p", "-m", "0700", location}, "database", pod.Name,
+			pod.Namespace, nil); err != nil {
+			return fmt.Errorf("could not create the location of tablespace %s: %v %s", name, err, stderr)
+		}
+
+		if err := execMaintenanceScript(clientset, restconfig, pod, "postgres",
+			tablespaceSQL(name, location, cluster.Spec.User)); err != nil {
+			return err
+		}
+
+		existing[name] = true
+	}
+
+	tablespaces := []string{}
+	for name := range existing {
+		tablespaces = append(tablespaces, name)
+	}
+	sort.Strings(tablespaces)
+
+	if reflect.DeepEqual(tablespaces, cluster.Status.Tablespaces) {
+		return nil
+	}
+
+	return kubeapi.Patchpgcluster(restclient, cluster, cluster.Namespace, func(cluster *crv1.Pgcluster) {
+		cluster.Status.Tablespaces = tablespaces
+	})
+}
+
+// getTablespaceLocation returns the directory that a tablespace is created in, which is on its
+// volume and is the same on every instance, as a replica follows the location of the primary
+func getTablespaceLocation(name string) string {
+	return fmt.Sprintf("%s%s/%s", config.VOLUME_TABLESPACE_PATH_PREFIX, name, name)
+}
+
+// hasTablespaceVolumeMount determines whether the database container of a Pod mounts the volume
+// of a tablespace
+func hasTablespaceVolumeMount(pod *v1.Pod, name string) bool {
+	for _, container := range pod.Spec.Containers {
+		if container.Name != "database" {
+			continue
+		}
+
+		for _, mount := range container.VolumeMounts {
+			if mount.Name == operator.GetTablespaceVolumeName(name) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// tablespaceSQL returns the script that creates a tablespace and, if the cluster has a user, lets
+// the user create objects in it. CREATE TABLESPACE cannot run in a transaction, so the statements
+// are run one by one
+func tablespaceSQL(name, location, user string) string {
+	sql := []string{fmt.Sprintf("CREATE TABLESPACE %s LOCATION %s;",
+		util.SQLQuoteIdentifier(name), util.SQLQuoteLiteral(location))}
+
+	if user != "" {
+		sql = append(sql, fmt.Sprintf("GRANT CREATE ON TABLESPACE %s TO %s;",
+			util.SQLQuoteIdentifier(name), util.SQLQuoteIdentifier(user)))
+	}
+
+	return strings.Join(sql, "\n")
+}
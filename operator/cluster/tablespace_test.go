@@ -0,0 +1,61 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestGetTablespaceLocation(t *testing.T) {
+	if location := getTablespaceLocation("fast"); location != "/tablespaces/fast/fast" {
+		t.Fatalf("expected the location to be on the volume of the tablespace, got %q", location)
+	}
+}
+
+func TestHasTablespaceVolumeMount(t *testing.T) {
+	pod := &v1.Pod{Spec: v1.PodSpec{Containers: []v1.Container{
+		{Name: "database", VolumeMounts: []v1.VolumeMount{{Name: "tablespace-fast"}}},
+		{Name: "crunchyadm", VolumeMounts: []v1.VolumeMount{{Name: "tablespace-slow"}}},
+	}}}
+
+	if !hasTablespaceVolumeMount(pod, "fast") {
+		t.Fatal("expected the volume of tablespace fast to be mounted")
+	}
+
+	if hasTablespaceVolumeMount(pod, "slow") {
+		t.Fatal("expected only the database container to count")
+	}
+}
+
+func TestTablespaceSQL(t *testing.T) {
+	for _, test := range []struct {
+		name, user, expected string
+	}{
+		{"without a user", "",
+			`CREATE TABLESPACE "fast" LOCATION '/tablespaces/fast/fast';`},
+		{"with a user", "testuser",
+			`CREATE TABLESPACE "fast" LOCATION '/tablespaces/fast/fast';` + "\n" +
+				`GRANT CREATE ON TABLESPACE "fast" TO "testuser";`},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			if sql := tablespaceSQL("fast", "/tablespaces/fast/fast", test.user); sql != test.expected {
+				t.Fatalf("expected %q, got %q", test.expected, sql)
+			}
+		})
+	}
+}
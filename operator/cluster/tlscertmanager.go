@@ -0,0 +1,377 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"reflect"
+	"time"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/config"
+	"github.com/crunchydata/postgres-operator/kubeapi"
+	"github.com/crunchydata/postgres-operator/operator"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	// eventReasonTLSCertificatesReloaded is the reason of the events that are recorded when
+	// PostgreSQL is reloaded with a renewed certificate
+	eventReasonTLSCertificatesReloaded = "TLSCertificatesReloaded"
+
+	// defaultTLSIssuerKind is the kind of the cert-manager issuer if none is set
+	defaultTLSIssuerKind = "Issuer"
+
+	// tlsIssuerGroup is the API group of the cert-manager issuers
+	tlsIssuerGroup = "cert-manager.io"
+
+	// tlsServerCertFile is the file in the database container that the certificate of the
+	// server is read from
+	tlsServerCertFile = "/pgconf/tls/tls.crt"
+
+	// tlsCertificateIssueTimeout is the maximum amount of time in seconds to wait for
+	// cert-manager to issue the certificate of the server of a new cluster
+	tlsCertificateIssueTimeout = 120
+	// tlsCertificateIssuePeriod is the number of seconds between each check of whether the
+	// certificate of the server was issued
+	tlsCertificateIssuePeriod = 2
+)
+
+// ReconcileTLSCertificates issues the certificates of a cluster, if the Operator manages them:
+// the certificate of the PostgreSQL server, which is valid for the Services of the cluster, the
+// client certificate of the replication user and, if pgBouncer is enabled, the certificate of
+// pgBouncer. They are either requested from cert-manager or generated by the Operator
+func ReconcileTLSCertificates(clientset *kubernetes.Clientset, restclient *rest.RESTClient,
+	cluster *crv1.Pgcluster) error {
+	switch {
+	case cluster.Spec.TLS.CertManager.IsEnabled():
+		return requestTLSCertificates(clientset, cluster)
+	case cluster.Spec.TLS.Generate.Enabled:
+		return generateTLSCertificates(clientset, restclient, cluster)
+	}
+
+	return nil
+}
+
+// requestTLSCertificates requests the certificates of a cluster from cert-manager. A Certificate
+// that already exists is updated if the issuer or the validity in the spec of the cluster
+// changed, which cert-manager then issues it again for
+func requestTLSCertificates(clientset *kubernetes.Clientset, cluster *crv1.Pgcluster) error {
+	for _, certificate := range getTLSCertificates(cluster) {
+		current, found, err := kubeapi.GetCertificate(clientset, certificate.Name, cluster.Namespace)
+		if err != nil && !kerrors.IsNotFound(err) {
+			return err
+		}
+
+		if !found {
+			log.Debugf("requesting certificate %s for cluster %s", certificate.Name, cluster.Name)
+
+			if err := kubeapi.CreateCertificate(clientset, &certificate, cluster.Namespace); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if reflect.DeepEqual(current.Spec, certificate.Spec) {
+			continue
+		}
+
+		log.Debugf("updating certificate %s of cluster %s", certificate.Name, cluster.Name)
+
+		current.Spec = certificate.Spec
+
+		if err := kubeapi.UpdateCertificate(clientset, current, cluster.Namespace); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ReloadTLSCertificates reloads PostgreSQL once a renewed certificate of the server has made it
+// into all of the instances of the cluster, as PostgreSQL only reads its certificate when it is
+// reloaded. The instances are reloaded one at a time. pgBouncer does not pick up a renewed
+// certificate by itself, so it is rolled out again instead. The client certificate of the
+// replication user is read whenever an instance connects to replicate, so it does not need either
+func ReloadTLSCertificates(clientset *kubernetes.Clientset, restclient *rest.RESTClient, restconfig *rest.Config,
+	cluster *crv1.Pgcluster) error {
+	hash := ""
+
+	if cluster.Spec.TLS.IsManaged() {
+		if cluster.Labels[config.LABEL_PGBOUNCER] == "true" {
+			if err := reloadTLSPgBouncerCertificate(clientset, restclient, cluster); err != nil {
+				return err
+			}
+		}
+
+		secret, found, err := kubeapi.GetSecret(clientset, operator.GetTLSSecretName(cluster), cluster.Namespace)
+		if !found {
+			// the certificate may not be issued yet
+			if kerrors.IsNotFound(err) {
+				return nil
+			}
+			return err
+		}
+
+		hash = getTLSCertHash(secret)
+
+		if hash == cluster.Status.TLSCertHash {
+			return nil
+		}
+
+		// a renewed certificate waits for the maintenance window, though not the first one
+		if cluster.Status.TLSCertHash != "" {
+			deferred, err := deferMaintenanceAction(clientset, restclient, cluster,
+				maintenanceActionTLSCertificates)
+			if err != nil || deferred {
+				return err
+			}
+		}
+
+		deployments, err := operator.GetInstanceDeployments(clientset, cluster)
+		if err != nil {
+			return err
+		}
+
+		pods := []v1.Pod{}
+
+		for _, deployment := range deployments.Items {
+			selector := fmt.Sprintf("%s=%s", config.LABEL_DEPLOYMENT_NAME, deployment.Name)
+
+			instancePods, err := kubeapi.GetPods(clientset, selector, cluster.Namespace)
+			if err != nil {
+				return err
+			}
+
+			for _, pod := range instancePods.Items {
+				if pod.Status.Phase != v1.PodRunning {
+					continue
+				}
+
+				cmd := []string{"cat", tlsServerCertFile}
+
+				stdout, _, err := kubeapi.ExecToPodThroughAPI(restconfig, clientset,
+					cmd, "database", pod.Name, cluster.Namespace, nil)
+				if err != nil {
+					return err
+				}
+
+				if stdout != string(secret.Data[v1.TLSCertKey]) {
+					log.Debugf("waiting for the certificate of pod %s to be updated", pod.Name)
+					return nil
+				}
+
+				pods = append(pods, pod)
+			}
+		}
+
+		for i := range pods {
+			if _, err := execMaintenanceSQL(clientset, restconfig, &pods[i], "postgres",
+				"SELECT pg_catalog.pg_reload_conf();"); err != nil {
+				return err
+			}
+		}
+
+		// the first hash is recorded right after the cluster is created, which is not a renewal
+		if cluster.Status.TLSCertHash != "" {
+			operator.RecordNormalEvent(clientset, cluster, eventReasonTLSCertificatesReloaded,
+				fmt.Sprintf("reloaded %d instances with the renewed certificate of the server", len(pods)))
+		}
+
+		log.Debugf("reloaded certificate of cluster %s", cluster.Name)
+	}
+
+	return kubeapi.Patchpgcluster(restclient, cluster, cluster.Namespace, func(cluster *crv1.Pgcluster) {
+		cluster.Status.TLSCertHash = hash
+	})
+}
+
+// DeleteTLSCertificates removes the cert-manager Certificates of a cluster. The Secrets they
+// were issued into are labeled with the cluster, and are removed along with its other Secrets
+func DeleteTLSCertificates(clientset *kubernetes.Clientset, cluster *crv1.Pgcluster) error {
+	if !cluster.Spec.TLS.CertManager.IsEnabled() {
+		return nil
+	}
+
+	for _, certificate := range getTLSCertificates(cluster) {
+		if err := kubeapi.DeleteCertificate(clientset, certificate.Name,
+			cluster.Namespace); err != nil && !kerrors.IsNotFound(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// waitForTLSCertificate waits for cert-manager to issue a certificate into its Secret
+func waitForTLSCertificate(clientset *kubernetes.Clientset, name, namespace string,
+	timeoutSecs, periodSecs time.Duration) error {
+	timeout := time.After(timeoutSecs * time.Second)
+	tick := time.Tick(periodSecs * time.Second)
+
+	for {
+		select {
+		case <-timeout:
+			return fmt.Errorf("timed out waiting for certificate %s to be issued", name)
+		case <-tick:
+			if secret, found, _ := kubeapi.GetSecret(clientset, name, namespace); found &&
+				len(secret.Data[v1.TLSCertKey]) > 0 {
+				return nil
+			}
+		}
+	}
+}
+
+// reloadTLSPgBouncerCertificate rolls out pgBouncer again when its certificate was renewed,
+// by recording the hash of the certificate on the Pods of pgBouncer. A rollout for a renewed
+// certificate waits for the maintenance window of the cluster
+func reloadTLSPgBouncerCertificate(clientset *kubernetes.Clientset, restclient *rest.RESTClient,
+	cluster *crv1.Pgcluster) error {
+	secret, found, err := kubeapi.GetSecret(clientset, operator.GetTLSPgBouncerSecretName(cluster),
+		cluster.Namespace)
+	if !found {
+		if kerrors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+
+	hash := getTLSCertHash(secret)
+
+	for _, pool := range getPgBouncerPools(cluster) {
+		deployment, found, err := kubeapi.GetDeployment(clientset, pool.name, cluster.Namespace)
+		if !found {
+			// the read-only pgBouncer may not have been added yet
+			if pool.readOnly {
+				continue
+			}
+			return err
+		}
+
+		current := deployment.Spec.Template.Annotations[config.ANNOTATION_TLS_CERT_HASH]
+		if current == hash {
+			continue
+		}
+
+		if current != "" {
+			deferred, err := deferMaintenanceAction(clientset, restclient, cluster,
+				maintenanceActionTLSCertificates)
+			if err != nil || deferred {
+				return err
+			}
+		}
+
+		log.Debugf("rolling out pgBouncer %s with its renewed certificate", pool.name)
+
+		if deployment.Spec.Template.Annotations == nil {
+			deployment.Spec.Template.Annotations = map[string]string{}
+		}
+		deployment.Spec.Template.Annotations[config.ANNOTATION_TLS_CERT_HASH] = hash
+
+		if err := kubeapi.UpdateDeployment(clientset, deployment); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// getTLSCertificates returns the cert-manager Certificates that are requested for a cluster. The
+// certificates that the Operator generates itself are described by them as well
+func getTLSCertificates(cluster *crv1.Pgcluster) []kubeapi.Certificate {
+	certManager := cluster.Spec.TLS.CertManager
+
+	issuerKind := certManager.IssuerKind
+	if issuerKind == "" {
+		issuerKind = defaultTLSIssuerKind
+	}
+
+	labels := map[string]string{
+		config.LABEL_VENDOR:     config.LABEL_CRUNCHY,
+		config.LABEL_PG_CLUSTER: cluster.Name,
+	}
+
+	newCertificate := func(name, commonName string, dnsNames, usages []string) kubeapi.Certificate {
+		return kubeapi.Certificate{
+			ObjectMeta: meta_v1.ObjectMeta{Name: name, Labels: labels},
+			Spec: kubeapi.CertificateSpec{
+				SecretName:  name,
+				CommonName:  commonName,
+				DNSNames:    dnsNames,
+				Duration:    certManager.Duration,
+				RenewBefore: certManager.RenewBefore,
+				Usages:      usages,
+				IssuerRef: kubeapi.CertificateIssuerRef{
+					Name:  certManager.IssuerName,
+					Kind:  issuerKind,
+					Group: tlsIssuerGroup,
+				},
+				SecretTemplate: &kubeapi.CertificateSecretTemplate{Labels: labels},
+			},
+		}
+	}
+
+	serverUsages := []string{"server auth", "digital signature", "key encipherment"}
+
+	certificates := []kubeapi.Certificate{
+		newCertificate(operator.GetTLSSecretName(cluster), cluster.Name,
+			getTLSDNSNames(cluster, cluster.Name, cluster.Name+ReplicaSuffix), serverUsages),
+		newCertificate(operator.GetTLSReplicationSecretName(cluster), crv1.PGUserReplication, nil,
+			[]string{"client auth", "digital signature", "key encipherment"}),
+	}
+
+	// pgBouncer may have a keypair of its own instead. The read-only pgBouncer
+	// shares the keypair, so it is valid for the names of its Service as well
+	if cluster.Labels[config.LABEL_PGBOUNCER] == "true" && cluster.Spec.PgBouncer.TLSSecret == "" {
+		names := []string{}
+		for _, pool := range getPgBouncerPools(cluster) {
+			names = append(names, pool.name)
+		}
+
+		certificates = append(certificates, newCertificate(operator.GetTLSPgBouncerSecretName(cluster),
+			names[0], getTLSDNSNames(cluster, names...), serverUsages))
+	}
+
+	return certificates
+}
+
+// getTLSDNSNames returns the names that the Services of a cluster are reached at from within
+// the Kubernetes cluster
+func getTLSDNSNames(cluster *crv1.Pgcluster, services ...string) []string {
+	names := []string{}
+
+	for _, service := range services {
+		names = append(names, service,
+			fmt.Sprintf("%s.%s", service, cluster.Namespace),
+			fmt.Sprintf("%s.%s.svc", service, cluster.Namespace))
+	}
+
+	return names
+}
+
+// getTLSCertHash returns the hash of the certificate and the CA in a Secret of a certificate that
+// the Operator manages
+func getTLSCertHash(secret *v1.Secret) string {
+	data := append(append([]byte{}, secret.Data[v1.TLSCertKey]...), secret.Data[tlsCACertKey]...)
+
+	return fmt.Sprintf("%x", sha256.Sum256(data))
+}
@@ -0,0 +1,132 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"reflect"
+	"testing"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/config"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGetTLSCertificates(t *testing.T) {
+	cluster := &crv1.Pgcluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "hippo", Namespace: "pgo", Labels: map[string]string{}},
+		Spec: crv1.PgclusterSpec{TLS: crv1.TLSSpec{CertManager: crv1.TLSCertManagerSpec{
+			IssuerName: "pgo-ca",
+			Duration:   "2160h",
+		}}},
+	}
+
+	t.Run("without pgbouncer", func(t *testing.T) {
+		certificates := getTLSCertificates(cluster)
+
+		if len(certificates) != 2 {
+			t.Fatalf("expected the server and replication certificates, got %d", len(certificates))
+		}
+
+		server := certificates[0]
+		if server.Name != "hippo-tls" || server.Spec.SecretName != "hippo-tls" {
+			t.Fatalf("expected server certificate hippo-tls, got %q", server.Name)
+		}
+
+		expected := []string{"hippo", "hippo.pgo", "hippo.pgo.svc",
+			"hippo-replica", "hippo-replica.pgo", "hippo-replica.pgo.svc"}
+		if !reflect.DeepEqual(server.Spec.DNSNames, expected) {
+			t.Fatalf("expected DNS names %v, got %v", expected, server.Spec.DNSNames)
+		}
+
+		if server.Spec.IssuerRef.Kind != defaultTLSIssuerKind || server.Spec.IssuerRef.Name != "pgo-ca" {
+			t.Fatalf("expected issuer Issuer/pgo-ca, got %s/%s", server.Spec.IssuerRef.Kind,
+				server.Spec.IssuerRef.Name)
+		}
+
+		if server.Spec.Duration != "2160h" {
+			t.Fatalf("expected duration 2160h, got %q", server.Spec.Duration)
+		}
+
+		if server.Spec.SecretTemplate.Labels[config.LABEL_PG_CLUSTER] != "hippo" {
+			t.Fatalf("expected the secret to be labeled with the cluster, got %v",
+				server.Spec.SecretTemplate.Labels)
+		}
+
+		replication := certificates[1]
+		if replication.Name != "hippo-replication-tls" ||
+			replication.Spec.CommonName != crv1.PGUserReplication {
+			t.Fatalf("expected replication certificate for %s, got %q for %q", crv1.PGUserReplication,
+				replication.Name, replication.Spec.CommonName)
+		}
+	})
+
+	t.Run("with pgbouncer", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Labels[config.LABEL_PGBOUNCER] = "true"
+		cluster.Spec.TLS.CertManager.IssuerKind = "ClusterIssuer"
+
+		certificates := getTLSCertificates(cluster)
+
+		if len(certificates) != 3 {
+			t.Fatalf("expected the pgbouncer certificate as well, got %d", len(certificates))
+		}
+
+		pgbouncer := certificates[2]
+		if pgbouncer.Name != "hippo-pgbouncer-tls" || pgbouncer.Spec.CommonName != "hippo-pgbouncer" {
+			t.Fatalf("expected pgbouncer certificate hippo-pgbouncer-tls, got %q", pgbouncer.Name)
+		}
+
+		if pgbouncer.Spec.IssuerRef.Kind != "ClusterIssuer" {
+			t.Fatalf("expected issuer kind ClusterIssuer, got %q", pgbouncer.Spec.IssuerRef.Kind)
+		}
+	})
+
+	t.Run("with read-only pgbouncer", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Labels[config.LABEL_PGBOUNCER] = "true"
+		cluster.Spec.PgBouncer.ReadOnly = true
+
+		pgbouncer := getTLSCertificates(cluster)[2]
+
+		expected := []string{"hippo-pgbouncer", "hippo-pgbouncer.pgo", "hippo-pgbouncer.pgo.svc",
+			"hippo-pgbouncer-ro", "hippo-pgbouncer-ro.pgo", "hippo-pgbouncer-ro.pgo.svc"}
+		if pgbouncer.Spec.CommonName != "hippo-pgbouncer" || !reflect.DeepEqual(pgbouncer.Spec.DNSNames, expected) {
+			t.Fatalf("expected DNS names %v, got %v", expected, pgbouncer.Spec.DNSNames)
+		}
+	})
+}
+
+func TestGetTLSCertHash(t *testing.T) {
+	secret := &v1.Secret{Data: map[string][]byte{
+		v1.TLSCertKey:       []byte("cert"),
+		v1.TLSPrivateKeyKey: []byte("key"),
+		tlsCACertKey:        []byte("ca"),
+	}}
+
+	hash := getTLSCertHash(secret)
+
+	// a new key alone does not need a reload, as it comes with a new certificate
+	secret.Data[v1.TLSPrivateKeyKey] = []byte("other key")
+	if getTLSCertHash(secret) != hash {
+		t.Fatal("expected the hash to only depend on the certificate and the CA")
+	}
+
+	secret.Data[tlsCACertKey] = []byte("other ca")
+	if getTLSCertHash(secret) == hash {
+		t.Fatal("expected the hash to change along with the CA")
+	}
+}
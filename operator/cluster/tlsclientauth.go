@@ -0,0 +1,672 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/config"
+	"github.com/crunchydata/postgres-operator/kubeapi"
+	"github.com/crunchydata/postgres-operator/operator"
+	"github.com/crunchydata/postgres-operator/tlsutil"
+	log "github.com/sirupsen/logrus"
+	apps_v1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	// the keys of the CA secrets, which follow the format of the CASecret of
+	// the server
+	tlsCACertKey = "ca.crt"
+	tlsCACRLKey  = "ca.crl"
+	// tlsClientCAKey is the key in the bundle of trusted CAs that keeps the
+	// client CA on its own, so a rotation of it can be detected
+	tlsClientCAKey = "client-ca.crt"
+	// tlsClientCertSecretName is the name of the Secret that a client
+	// certificate issued by the Operator is stored in
+	tlsClientCertSecretName = "%s-%s-client-tls"
+	// tlsClientCertRenewBefore is how long before it expires that a client
+	// certificate issued by the Operator is renewed
+	tlsClientCertRenewBefore = 30 * 24 * time.Hour
+	// tlsServerVolumeName is the name of the volume that the TLS keypair of
+	// the server and its trusted CAs are mounted from
+	tlsServerVolumeName = "tls-server"
+	// tlsReplicationVolumeName is the name of the volume that the client
+	// certificate of the replication user is mounted from, at
+	// tlsReplicationMountPath
+	tlsReplicationVolumeName = "tls-replication"
+	tlsReplicationMountPath  = "/pgconf/tls-replication"
+	// tlsTrustedCAFile is the file in the database container that PostgreSQL
+	// verifies the client certificates against
+	tlsTrustedCAFile = "/pgconf/tls/ca.crt"
+	// tlsClientAuthHBAComment marks the rules in pg_hba.conf that the Operator
+	// manages for client certificate authentication
+	tlsClientAuthHBAComment = "# tls.clientAuth"
+)
+
+// ErrTLSClientCARotationNotStaged is returned when the client CA of a cluster
+// was changed without the previous client CA being kept
+var ErrTLSClientCARotationNotStaged = errors.New("rotation of the client CA is not staged")
+
+// ValidateTLSClientAuth returns an error if the client certificate
+// authentication of a cluster cannot be set up, i.e. the replication user is to
+// authenticate with a client certificate that the Operator does not issue, or
+// the superuser or every connection is to authenticate with one without a
+// client CA to verify them against
+func ValidateTLSClientAuth(cluster *crv1.Pgcluster) error {
+	clientAuth := cluster.Spec.TLS.ClientAuth
+
+	if clientAuth.Replication && !cluster.Spec.TLS.IsManaged() &&
+		!(clientAuth.IsEnabled() && clientAuth.IssueCertificates) {
+		return errors.New("the replication user can only authenticate with a client certificate that the " +
+			"Operator issues, which requires the certificates of the cluster to be managed or " +
+			"client certificates to be issued from the client CA")
+	}
+
+	if (clientAuth.Superuser || clientAuth.MTLSOnly) && !clientAuth.IsEnabled() {
+		return errors.New("the superuser and the mTLS-only mode require a client CA to verify the " +
+			"client certificates against")
+	}
+
+	return nil
+}
+
+// ReconcileTLSClientAuth sets up the client certificate authentication of a
+// cluster: the bundle of trusted CAs is updated, the client certificates are
+// issued if requested, and the "cert" rules in pg_hba.conf are brought in line
+// with the users, the replication user and the mTLS-only mode, which Patroni
+// applies with a reload. When client certificate authentication is turned off,
+// its rules are removed
+func ReconcileTLSClientAuth(clientset *kubernetes.Clientset, restclient *rest.RESTClient, restconfig *rest.Config,
+	cluster *crv1.Pgcluster) error {
+	clientAuth := cluster.Spec.TLS.ClientAuth
+
+	if clientAuth.UsesCertAuth() && !cluster.Spec.TLS.IsTLSEnabled() {
+		operator.RecordWarningEvent(clientset, cluster, "InvalidTLSClientAuth",
+			"client certificate authentication requires TLS to be enabled on the cluster")
+		return nil
+	}
+
+	if clientAuth.IsEnabled() {
+		if err := UpdateTLSClientCABundle(clientset, cluster); err != nil {
+			return err
+		}
+
+		if clientAuth.IssueCertificates {
+			if err := issueTLSClientCertificates(clientset, cluster); err != nil {
+				return err
+			}
+		}
+	}
+
+	return updateTLSClientAuthHBA(clientset, cluster)
+}
+
+// UpdateTLSClientCABundle creates or updates the Secret that bundles the CAs
+// that PostgreSQL trusts: the CA of the server, the CA of the clients and,
+// while a rotation of the client CA is staged, the previous CA of the clients.
+// Rotating the client CA without staging it would lock out every client that
+// has yet to migrate, so such a rotation is not applied until the previous CA
+// is set as well
+func UpdateTLSClientCABundle(clientset *kubernetes.Clientset, cluster *crv1.Pgcluster) error {
+	clientAuth := cluster.Spec.TLS.ClientAuth
+	name := operator.GetTLSCASecretName(cluster)
+
+	serverCA, err := getTLSCASecret(clientset, operator.GetTLSServerCASecretName(cluster), cluster.Namespace)
+	if err != nil {
+		return err
+	}
+
+	clientCA, err := getTLSCASecret(clientset, clientAuth.CASecret, cluster.Namespace)
+	if err != nil {
+		return err
+	}
+
+	bundle, found, err := kubeapi.GetSecret(clientset, name, cluster.Namespace)
+	if err != nil && !kerrors.IsNotFound(err) {
+		return err
+	}
+
+	if found && clientAuth.PreviousCASecret == "" &&
+		!bytes.Equal(bundle.Data[tlsClientCAKey], clientCA.Data[tlsCACertKey]) {
+		operator.RecordWarningEvent(clientset, cluster, "TLSClientCARotationNotStaged",
+			fmt.Sprintf("the client CA of cluster %s changed without %q being set, so the clients that have "+
+				"yet to migrate keep being accepted; set it to the secret of the previous client CA to "+
+				"rotate the client CA", cluster.Name, "previousCASecret"))
+		return ErrTLSClientCARotationNotStaged
+	}
+
+	trusted := [][]byte{serverCA.Data[tlsCACertKey], clientCA.Data[tlsCACertKey]}
+
+	if clientAuth.PreviousCASecret != "" {
+		previousCA, err := getTLSCASecret(clientset, clientAuth.PreviousCASecret, cluster.Namespace)
+		if err != nil {
+			return err
+		}
+
+		trusted = append(trusted, previousCA.Data[tlsCACertKey])
+	}
+
+	for i := range trusted {
+		trusted[i] = bytes.TrimSpace(trusted[i])
+	}
+
+	data := map[string][]byte{
+		tlsCACertKey:   append(bytes.Join(trusted, []byte("\n")), '\n'),
+		tlsClientCAKey: clientCA.Data[tlsCACertKey],
+	}
+
+	// keep the revocation list of the server, which is mounted alongside
+	if crl, ok := serverCA.Data[tlsCACRLKey]; ok {
+		data[tlsCACRLKey] = crl
+	}
+
+	if !found {
+		log.Debugf("creating bundle of trusted CAs %s for cluster %s", name, cluster.Name)
+
+		return kubeapi.CreateSecret(clientset, &v1.Secret{
+			ObjectMeta: meta_v1.ObjectMeta{
+				Name: name,
+				Labels: map[string]string{
+					config.LABEL_VENDOR:     config.LABEL_CRUNCHY,
+					config.LABEL_PG_CLUSTER: cluster.Name,
+				},
+			},
+			Data: data,
+		}, cluster.Namespace)
+	}
+
+	if bytes.Equal(bundle.Data[tlsCACertKey], data[tlsCACertKey]) &&
+		bytes.Equal(bundle.Data[tlsCACRLKey], data[tlsCACRLKey]) {
+		return nil
+	}
+
+	log.Debugf("updating bundle of trusted CAs %s for cluster %s", name, cluster.Name)
+
+	bundle.Data = data
+
+	return kubeapi.UpdateSecret(clientset, bundle, cluster.Namespace)
+}
+
+// UpdateTLSClientCAVolume points the instances of a cluster at the Secret of
+// trusted CAs they should be using, i.e. the bundle that is maintained by the
+// Operator when client certificate authentication is turned on, and the CA of
+// the server otherwise. As the instances are restarted by this, it is expected
+// to be called within the restart budget
+func UpdateTLSClientCAVolume(clientset *kubernetes.Clientset, restConfig *rest.Config, cluster *crv1.Pgcluster) error {
+	if !cluster.Spec.TLS.IsTLSEnabled() {
+		return nil
+	}
+
+	// the bundle has to exist before the instances are pointed at it
+	if cluster.Spec.TLS.ClientAuth.IsEnabled() {
+		if err := UpdateTLSClientCABundle(clientset, cluster); err != nil {
+			return err
+		}
+	}
+
+	name := operator.GetTLSCASecretName(cluster)
+
+	deployments, err := operator.GetInstanceDeployments(clientset, cluster)
+	if err != nil {
+		return err
+	}
+
+	return updateDeployments(clientset, restConfig, deployments.Items, func(deployment *apps_v1.Deployment) bool {
+		changed := false
+
+		for _, volume := range deployment.Spec.Template.Spec.Volumes {
+			if volume.Name != tlsServerVolumeName || volume.Projected == nil {
+				continue
+			}
+
+			// the keypair of the server comes first and is left as is, which leaves
+			// the CA. When cert-manager issues the keypair, both are the same Secret
+			for i, source := range volume.Projected.Sources {
+				if i > 0 && source.Secret != nil && source.Secret.Name != name {
+					source.Secret.Name = name
+					changed = true
+				}
+			}
+		}
+
+		if changed {
+			log.Debugf("updating trusted CAs of deployment %s to %s", deployment.Name, name)
+		}
+
+		return changed
+	})
+}
+
+// UpdateTLSReplicationVolume mounts the client certificate of the replication
+// user into the instances of a cluster that were created without it, and has
+// Patroni replicate with it. The certificate is issued first if the Operator
+// issues it from the client CA. As the instances are restarted by this, it is
+// expected to be called within the restart budget
+func UpdateTLSReplicationVolume(clientset *kubernetes.Clientset, restConfig *rest.Config,
+	cluster *crv1.Pgcluster) error {
+	name := operator.GetTLSReplicationSecretName(cluster)
+	if name == "" || !cluster.Spec.TLS.IsTLSEnabled() {
+		return nil
+	}
+
+	// the certificate has to exist before the instances mount it
+	if !cluster.Spec.TLS.IsManaged() {
+		if err := issueTLSClientCertificates(clientset, cluster); err != nil {
+			return err
+		}
+	}
+
+	deployments, err := operator.GetInstanceDeployments(clientset, cluster)
+	if err != nil {
+		return err
+	}
+
+	// the same mode as the volume of the template, i.e. 0440
+	defaultMode := int32(288)
+
+	return updateDeployments(clientset, restConfig, deployments.Items, func(deployment *apps_v1.Deployment) bool {
+		if hasTLSReplicationVolume(*deployment) {
+			return false
+		}
+
+		template := &deployment.Spec.Template.Spec
+
+		template.Volumes = append(template.Volumes, v1.Volume{
+			Name: tlsReplicationVolumeName,
+			VolumeSource: v1.VolumeSource{
+				Secret: &v1.SecretVolumeSource{
+					SecretName:  name,
+					DefaultMode: &defaultMode,
+				},
+			},
+		})
+
+		for i := range template.Containers {
+			if template.Containers[i].Name != "database" {
+				continue
+			}
+
+			template.Containers[i].VolumeMounts = append(template.Containers[i].VolumeMounts, v1.VolumeMount{
+				Name:      tlsReplicationVolumeName,
+				MountPath: tlsReplicationMountPath,
+			})
+
+			template.Containers[i].Env = append(template.Containers[i].Env,
+				v1.EnvVar{Name: "PATRONI_REPLICATION_SSLCERT", Value: tlsReplicationMountPath + "/tls.crt"},
+				v1.EnvVar{Name: "PATRONI_REPLICATION_SSLKEY", Value: tlsReplicationMountPath + "/tls.key"})
+		}
+
+		log.Debugf("mounting replication certificate %s into deployment %s", name, deployment.Name)
+
+		return true
+	})
+}
+
+// ReloadTLSClientCA reloads PostgreSQL once a change to the bundle of trusted
+// CAs has made it into all of the instances of the cluster, as it only reads
+// the trusted CAs when it is reloaded. The Secret can take a while to be
+// updated within the Pods, so this is checked periodically until it has
+func ReloadTLSClientCA(clientset *kubernetes.Clientset, restclient *rest.RESTClient, restconfig *rest.Config,
+	cluster *crv1.Pgcluster) error {
+	hash := ""
+
+	if cluster.Spec.TLS.ClientAuth.IsEnabled() {
+		bundle, found, err := kubeapi.GetSecret(clientset, operator.GetTLSCASecretName(cluster), cluster.Namespace)
+		if !found {
+			return err
+		}
+
+		hash = fmt.Sprintf("%x", sha256.Sum256(bundle.Data[tlsCACertKey]))
+
+		if hash == cluster.Status.TLSClientCAHash {
+			return nil
+		}
+
+		deployments, err := operator.GetInstanceDeployments(clientset, cluster)
+		if err != nil {
+			return err
+		}
+
+		pods := []v1.Pod{}
+
+		for _, deployment := range deployments.Items {
+			selector := fmt.Sprintf("%s=%s", config.LABEL_DEPLOYMENT_NAME, deployment.Name)
+
+			instancePods, err := kubeapi.GetPods(clientset, selector, cluster.Namespace)
+			if err != nil {
+				return err
+			}
+
+			for _, pod := range instancePods.Items {
+				if pod.Status.Phase != v1.PodRunning {
+					continue
+				}
+
+				cmd := []string{"cat", tlsTrustedCAFile}
+
+				stdout, _, err := kubeapi.ExecToPodThroughAPI(restconfig, clientset,
+					cmd, "database", pod.Name, cluster.Namespace, nil)
+				if err != nil {
+					return err
+				}
+
+				if stdout != string(bundle.Data[tlsCACertKey]) {
+					log.Debugf("waiting for the trusted CAs of pod %s to be updated", pod.Name)
+					return nil
+				}
+
+				pods = append(pods, pod)
+			}
+		}
+
+		for i := range pods {
+			if _, err := execMaintenanceSQL(clientset, restconfig, &pods[i], "postgres",
+				"SELECT pg_catalog.pg_reload_conf();"); err != nil {
+				return err
+			}
+		}
+
+		log.Debugf("reloaded trusted CAs of cluster %s", cluster.Name)
+	}
+
+	return kubeapi.Patchpgcluster(restclient, cluster, cluster.Namespace, func(cluster *crv1.Pgcluster) {
+		cluster.Status.TLSClientCAHash = hash
+	})
+}
+
+// issueTLSClientCertificates issues a client certificate from the client CA
+// for each of the users that authenticate with one, and for the replication
+// user if it does while the certificates of the cluster are not managed by the
+// Operator. A certificate is issued again when it is about to expire or was not
+// issued by the current client CA, e.g. after the client CA was rotated
+func issueTLSClientCertificates(clientset *kubernetes.Clientset, cluster *crv1.Pgcluster) error {
+	clientCA, err := getTLSCASecret(clientset, cluster.Spec.TLS.ClientAuth.CASecret, cluster.Namespace)
+	if err != nil {
+		return err
+	}
+
+	serverCA, err := getTLSCASecret(clientset, operator.GetTLSServerCASecretName(cluster), cluster.Namespace)
+	if err != nil {
+		return err
+	}
+
+	caCert, err := tlsutil.ParsePEMEncodedCert(clientCA.Data[tlsCACertKey])
+	if err != nil {
+		return err
+	}
+
+	caKey, err := tlsutil.ParsePEMEncodedPrivateKey(clientCA.Data[v1.TLSPrivateKeyKey])
+	if err != nil {
+		return fmt.Errorf("client certificates cannot be issued from secret %s: %s",
+			cluster.Spec.TLS.ClientAuth.CASecret, err)
+	}
+
+	certificates := map[string]string{}
+	for _, user := range cluster.Spec.TLS.ClientAuth.GetUsers() {
+		certificates[fmt.Sprintf(tlsClientCertSecretName, cluster.Name, user)] = user
+	}
+
+	if cluster.Spec.TLS.ClientAuth.Replication && !cluster.Spec.TLS.IsManaged() {
+		certificates[operator.GetTLSReplicationSecretName(cluster)] = crv1.PGUserReplication
+	}
+
+	for name, user := range certificates {
+		if err := issueTLSClientCertificate(clientset, cluster, name, user, caCert, caKey,
+			serverCA.Data[tlsCACertKey]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// issueTLSClientCertificate issues the client certificate of a user into a
+// Secret, unless the one in there is issued by the client CA and not about to
+// expire
+func issueTLSClientCertificate(clientset *kubernetes.Clientset, cluster *crv1.Pgcluster, name, user string,
+	caCert *x509.Certificate, caKey *rsa.PrivateKey, serverCA []byte) error {
+	secret, found, err := kubeapi.GetSecret(clientset, name, cluster.Namespace)
+	if err != nil && !kerrors.IsNotFound(err) {
+		return err
+	}
+
+	if found {
+		if cert, err := tlsutil.ParsePEMEncodedCert(secret.Data[v1.TLSCertKey]); err == nil &&
+			cert.CheckSignatureFrom(caCert) == nil &&
+			time.Until(cert.NotAfter) > tlsClientCertRenewBefore {
+			return nil
+		}
+	}
+
+	key, err := tlsutil.NewPrivateKey()
+	if err != nil {
+		return err
+	}
+
+	cert, err := tlsutil.NewClientCertificate(key, user, caCert, caKey)
+	if err != nil {
+		return err
+	}
+
+	data := map[string][]byte{
+		v1.TLSCertKey:       tlsutil.EncodeCertificatePEM(cert),
+		v1.TLSPrivateKeyKey: tlsutil.EncodePrivateKeyPEM(key),
+		// the clients verify the server against its CA
+		tlsCACertKey: serverCA,
+	}
+
+	log.Debugf("issuing client certificate %s for user %s", name, user)
+
+	if found {
+		secret.Data = data
+		return kubeapi.UpdateSecret(clientset, secret, cluster.Namespace)
+	}
+
+	return kubeapi.CreateSecret(clientset, &v1.Secret{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name: name,
+			Labels: map[string]string{
+				config.LABEL_VENDOR:     config.LABEL_CRUNCHY,
+				config.LABEL_PG_CLUSTER: cluster.Name,
+				config.LABEL_USERNAME:   user,
+			},
+		},
+		Type: v1.SecretTypeTLS,
+		Data: data,
+	}, cluster.Namespace)
+}
+
+// updateTLSClientAuthHBA sets the "cert" rules for the users that authenticate
+// with a client certificate in the pg_hba.conf that Patroni manages. Patroni
+// reloads PostgreSQL when these rules change. The replication user is only
+// moved to its client certificate once all of the instances have it mounted,
+// as the instances that do not would no longer be able to replicate
+func updateTLSClientAuthHBA(clientset *kubernetes.Clientset, cluster *crv1.Pgcluster) error {
+	dcsConfigMap, configJSON, _, err := getDCSParameters(clientset, cluster)
+	if err != nil {
+		return err
+	}
+
+	postgresql := configJSON["postgresql"].(map[string]interface{})
+
+	clientAuth := cluster.Spec.TLS.ClientAuth
+	enabled := clientAuth.UsesCertAuth() && cluster.Spec.TLS.IsTLSEnabled()
+
+	// without any rules there are no "cert" rules to remove either
+	current, ok := postgresql["pg_hba"].([]interface{})
+	if !ok && !enabled {
+		return nil
+	} else if !ok {
+		return fmt.Errorf("no pg_hba rules found in the configuration of cluster %s", cluster.Name)
+	}
+
+	hba := make([]string, 0, len(current))
+	for _, rule := range current {
+		hba = append(hba, fmt.Sprint(rule))
+	}
+
+	replication := false
+	if enabled && clientAuth.Replication {
+		deployments, err := operator.GetInstanceDeployments(clientset, cluster)
+		if err != nil {
+			return err
+		}
+
+		replication = operator.GetTLSReplicationSecretName(cluster) != ""
+		for _, deployment := range deployments.Items {
+			replication = replication && hasTLSReplicationVolume(deployment)
+		}
+
+		if !replication {
+			operator.RecordWarningEvent(clientset, cluster, "TLSReplicationCertificateNotMounted",
+				"the replication user keeps authenticating with its password until all of the instances "+
+					"have its client certificate mounted")
+		}
+	}
+
+	authRules := []string{}
+	if enabled {
+		authRules = getTLSClientAuthHBA(cluster, hba, replication)
+	}
+
+	rules := setTLSClientAuthHBA(hba, authRules)
+
+	if strings.Join(rules, "\n") == strings.Join(hba, "\n") {
+		return nil
+	}
+
+	log.Debugf("updating client certificate rules of cluster %s to %v", cluster.Name, authRules)
+
+	postgresql["pg_hba"] = rules
+
+	configJSONStr, err := json.Marshal(configJSON)
+	if err != nil {
+		return err
+	}
+
+	dcsConfigMap.ObjectMeta.Annotations["config"] = string(configJSONStr)
+
+	return kubeapi.UpdateConfigMap(clientset, dcsConfigMap, cluster.Namespace)
+}
+
+// getTLSClientAuthHBA returns the pg_hba rules for client certificate
+// authentication: a "cert" rule for each of the users that authenticate with a
+// client certificate and, if the instances have its certificate, for the
+// replication user. In the mTLS-only mode, every other connection over TLS
+// has to authenticate with a client certificate and connections without TLS
+// are rejected, except for pgBouncer, which authenticates with its password,
+// and the rules for the loopback interface, which are repeated ahead of them
+func getTLSClientAuthHBA(cluster *crv1.Pgcluster, hba []string, replication bool) []string {
+	clientAuth := cluster.Spec.TLS.ClientAuth
+	rules := []string{}
+
+	if clientAuth.IsEnabled() {
+		for _, user := range clientAuth.GetUsers() {
+			rules = append(rules, fmt.Sprintf(`hostssl all "%s" 0.0.0.0/0 cert`, user))
+		}
+	}
+
+	if replication {
+		rules = append(rules, fmt.Sprintf(`hostssl replication "%s" 0.0.0.0/0 cert`, crv1.PGUserReplication))
+	}
+
+	if !clientAuth.IsEnabled() || !clientAuth.MTLSOnly {
+		return rules
+	}
+
+	for _, rule := range hba {
+		if fields := strings.Fields(rule); len(fields) > 3 && !strings.HasSuffix(rule, tlsClientAuthHBAComment) &&
+			(fields[3] == "127.0.0.1/32" || fields[3] == "::1/128") {
+			rules = append(rules, rule)
+		}
+	}
+
+	// "md5" accepts the password of pgBouncer whether it is stored as MD5 or SCRAM-SHA-256
+	if cluster.Labels[config.LABEL_PGBOUNCER] == "true" {
+		rules = append(rules, fmt.Sprintf(`host all "%s" all md5`, crv1.PGUserPgBouncer))
+	}
+
+	return append(rules, "hostssl all all all cert", "hostnossl all all all reject")
+}
+
+// setTLSClientAuthHBA returns the pg_hba rules with the given rules for client
+// certificate authentication in place of any that were set before. The rules
+// are matched in order, so they go right after the rules for local connections
+// and before any other rule, so that a fallback such as password
+// authentication for all users does not match first
+func setTLSClientAuthHBA(hba []string, authRules []string) []string {
+	rules := []string{}
+	for _, rule := range hba {
+		if !strings.HasSuffix(rule, tlsClientAuthHBAComment) {
+			rules = append(rules, rule)
+		}
+	}
+
+	i := 0
+	for i < len(rules) {
+		if fields := strings.Fields(rules[i]); len(fields) > 0 && fields[0] != "local" {
+			break
+		}
+		i++
+	}
+
+	result := append([]string{}, rules[:i]...)
+	for _, rule := range authRules {
+		result = append(result, fmt.Sprintf("%s %s", rule, tlsClientAuthHBAComment))
+	}
+
+	return append(result, rules[i:]...)
+}
+
+// getTLSCASecret returns a Secret containing a CA, ensuring that it does
+func getTLSCASecret(clientset *kubernetes.Clientset, name, namespace string) (*v1.Secret, error) {
+	secret, found, err := kubeapi.GetSecret(clientset, name, namespace)
+	if !found {
+		return nil, fmt.Errorf("could not find CA secret %s: %v", name, err)
+	}
+
+	if len(secret.Data[tlsCACertKey]) == 0 {
+		return nil, fmt.Errorf("CA secret %s does not contain %q", name, tlsCACertKey)
+	}
+
+	return secret, nil
+}
+
+// hasTLSReplicationVolume returns whether the instance of a deployment has the
+// client certificate of the replication user mounted
+func hasTLSReplicationVolume(deployment apps_v1.Deployment) bool {
+	for _, volume := range deployment.Spec.Template.Spec.Volumes {
+		if volume.Name == tlsReplicationVolumeName {
+			return true
+		}
+	}
+
+	return false
+}
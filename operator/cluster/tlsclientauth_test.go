@@ -0,0 +1,99 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"reflect"
+	"testing"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/config"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGetTLSClientAuthHBA(t *testing.T) {
+	hba := []string{
+		"local all postgres peer",
+		"host all all 127.0.0.1/32 md5",
+		"host replication primaryuser 0.0.0.0/0 md5",
+		"host all all 0.0.0.0/0 md5",
+	}
+
+	cluster := &crv1.Pgcluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "hippo", Labels: map[string]string{}},
+		Spec: crv1.PgclusterSpec{TLS: crv1.TLSSpec{ClientAuth: crv1.TLSClientAuthSpec{
+			CASecret:  "hippo-client-ca",
+			Users:     []string{"app"},
+			Superuser: true,
+		}}},
+	}
+
+	t.Run("users", func(t *testing.T) {
+		expected := []string{
+			`hostssl all "app" 0.0.0.0/0 cert`,
+			`hostssl all "postgres" 0.0.0.0/0 cert`,
+		}
+
+		if rules := getTLSClientAuthHBA(cluster, hba, false); !reflect.DeepEqual(rules, expected) {
+			t.Fatalf("expected %v, got %v", expected, rules)
+		}
+	})
+
+	t.Run("replication", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.TLS.ClientAuth = crv1.TLSClientAuthSpec{Replication: true}
+
+		expected := []string{`hostssl replication "primaryuser" 0.0.0.0/0 cert`}
+
+		if rules := getTLSClientAuthHBA(cluster, hba, true); !reflect.DeepEqual(rules, expected) {
+			t.Fatalf("expected %v, got %v", expected, rules)
+		}
+
+		// the instances do not all have the certificate yet
+		if rules := getTLSClientAuthHBA(cluster, hba, false); len(rules) != 0 {
+			t.Fatalf("expected no rules, got %v", rules)
+		}
+	})
+
+	t.Run("mTLS-only", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.TLS.ClientAuth.MTLSOnly = true
+		cluster.Labels[config.LABEL_PGBOUNCER] = "true"
+
+		expected := []string{
+			`hostssl all "app" 0.0.0.0/0 cert`,
+			`hostssl all "postgres" 0.0.0.0/0 cert`,
+			"host all all 127.0.0.1/32 md5",
+			`host all "pgbouncer" all md5`,
+			"hostssl all all all cert",
+			"hostnossl all all all reject",
+		}
+
+		rules := getTLSClientAuthHBA(cluster, hba, false)
+		if !reflect.DeepEqual(rules, expected) {
+			t.Fatalf("expected %v, got %v", expected, rules)
+		}
+
+		// the rules go ahead of the defaults, and replace the ones that were set before
+		set := setTLSClientAuthHBA(setTLSClientAuthHBA(hba, rules), rules)
+
+		if len(set) != len(hba)+len(rules) || set[0] != hba[0] ||
+			set[1] != `hostssl all "app" 0.0.0.0/0 cert # tls.clientAuth` ||
+			set[len(rules)+1] != hba[1] {
+			t.Fatalf("expected the rules after the local rules, got %v", set)
+		}
+	})
+}
@@ -0,0 +1,342 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/config"
+	"github.com/crunchydata/postgres-operator/kubeapi"
+	"github.com/crunchydata/postgres-operator/operator"
+	"github.com/crunchydata/postgres-operator/tlsutil"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	// eventReasonTLSCertificatesRotated is the reason of the events that are recorded when the
+	// Operator issues the certificates of a cluster again before they expire
+	eventReasonTLSCertificatesRotated = "TLSCertificatesRotated"
+	// eventReasonTLSCARotated is the reason of the events that are recorded when the Operator
+	// generates a new CA for a cluster
+	eventReasonTLSCARotated = "TLSCARotated"
+
+	// tlsPreviousCACertKey is the key in the CA secret of a cluster that keeps the CA it
+	// replaced, which is trusted until it expires so that the instances can be reloaded with
+	// the certificates of the new CA one at a time
+	tlsPreviousCACertKey = "previous-ca.crt"
+
+	// tlsCAValidity is how long the CA that the Operator generates for a cluster is valid for
+	tlsCAValidity = 10 * 365 * 24 * time.Hour
+)
+
+// ValidateTLS returns an error if the certificates of a cluster cannot be managed as set up, i.e.
+// they are both requested from cert-manager and generated, or the generated certificates would
+// have to be renewed as soon as they are issued
+func ValidateTLS(cluster *crv1.Pgcluster) error {
+	tls := cluster.Spec.TLS
+
+	if !tls.Generate.Enabled {
+		return nil
+	}
+
+	if tls.CertManager.IsEnabled() {
+		return errors.New("the certificates can either be requested from cert-manager or generated, not both")
+	}
+
+	if tls.Generate.ValidityDays < 0 || tls.Generate.RenewBeforeDays < 0 {
+		return fmt.Errorf("invalid certificate validity of %d days renewed %d days before, they cannot be negative",
+			tls.Generate.ValidityDays, tls.Generate.RenewBeforeDays)
+	}
+
+	if validity, renewBefore := tls.Generate.GetValidityDays(), tls.Generate.GetRenewBeforeDays(); renewBefore >= validity {
+		return fmt.Errorf("certificates valid for %d days cannot be renewed %d days before they expire",
+			validity, renewBefore)
+	}
+
+	return nil
+}
+
+// generateTLSCertificates generates the CA of a cluster and issues the certificates of the
+// cluster from it, storing them in the same Secrets that cert-manager would issue them into.
+// A certificate is issued again when it is about to expire or was not issued by the current CA,
+// and the CA is generated again when it would expire before a certificate issued from it. The
+// instances are then reloaded with the new certificates by ReloadTLSCertificates
+func generateTLSCertificates(clientset *kubernetes.Clientset, restclient *rest.RESTClient,
+	cluster *crv1.Pgcluster) error {
+	generate := cluster.Spec.TLS.Generate
+	validity := time.Duration(generate.GetValidityDays()) * 24 * time.Hour
+	renewBefore := time.Duration(generate.GetRenewBeforeDays()) * 24 * time.Hour
+
+	caCert, caKey, bundle, err := reconcileTLSCA(clientset, cluster, validity)
+	if err != nil {
+		return err
+	}
+
+	notAfter := caCert.NotAfter
+	rotated := 0
+
+	for _, certificate := range getTLSCertificates(cluster) {
+		cert, issued, err := reconcileTLSCertificate(clientset, cluster, certificate, caCert, caKey,
+			bundle, validity, renewBefore)
+		if err != nil {
+			return err
+		}
+
+		if issued {
+			rotated++
+		}
+
+		if cert.NotAfter.Before(notAfter) {
+			notAfter = cert.NotAfter
+		}
+	}
+
+	status := crv1.TLSCertificatesStatus{
+		CANotAfter: caCert.NotAfter.UTC().Format(time.RFC3339),
+		NotAfter:   notAfter.UTC().Format(time.RFC3339),
+	}
+
+	// the certificates that are issued when the cluster is created are not a rotation
+	if rotated > 0 && cluster.Status.TLSCertificates.NotAfter != "" {
+		operator.RecordNormalEvent(clientset, cluster, eventReasonTLSCertificatesRotated,
+			fmt.Sprintf("issued %d certificates again, which expire on %s", rotated, status.NotAfter))
+	}
+
+	if status == cluster.Status.TLSCertificates {
+		return nil
+	}
+
+	if err := kubeapi.Patchpgcluster(restclient, cluster, cluster.Namespace, func(cluster *crv1.Pgcluster) {
+		cluster.Status.TLSCertificates = status
+	}); err != nil {
+		return err
+	}
+
+	cluster.Status.TLSCertificates = status
+
+	return nil
+}
+
+// reconcileTLSCA returns the CA of a cluster, generating it if there is none yet or if it
+// expires before a certificate issued from it would. Along with the CA, the bundle of the CAs
+// that the certificates are trusted with is returned, which keeps the CA that was replaced
+// until it expires. A Secret of the same name that the Operator did not generate is never
+// overwritten, and an error is returned instead
+func reconcileTLSCA(clientset *kubernetes.Clientset, cluster *crv1.Pgcluster,
+	validity time.Duration) (*x509.Certificate, *rsa.PrivateKey, []byte, error) {
+	name := fmt.Sprintf(operator.TLSCASecretFormat, cluster.Name)
+
+	secret, found, err := kubeapi.GetSecret(clientset, name, cluster.Namespace)
+	if err != nil && !kerrors.IsNotFound(err) {
+		return nil, nil, nil, err
+	}
+
+	if found && secret.Labels[config.LABEL_PGO_GENERATED_CA] != config.LABEL_TRUE {
+		return nil, nil, nil, fmt.Errorf("secret %s of cluster %s was not generated by the "+
+			"Operator, so it is not used as the CA of the generated certificates", name, cluster.Name)
+	}
+
+	if found {
+		caCert, certErr := tlsutil.ParsePEMEncodedCert(secret.Data[tlsCACertKey])
+		caKey, keyErr := tlsutil.ParsePEMEncodedPrivateKey(secret.Data[v1.TLSPrivateKeyKey])
+
+		if certErr == nil && keyErr == nil && isTLSCACurrent(caCert, validity) {
+			return caCert, caKey, getTLSCABundle(secret.Data[tlsCACertKey],
+				secret.Data[tlsPreviousCACertKey]), nil
+		}
+	}
+
+	caKey, err := tlsutil.NewPrivateKey()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	caCert, err := tlsutil.NewCACertificate(caKey, fmt.Sprintf(operator.TLSCASecretFormat, cluster.Name),
+		tlsCAValidity)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	data := map[string][]byte{
+		tlsCACertKey:        tlsutil.EncodeCertificatePEM(caCert),
+		v1.TLSPrivateKeyKey: tlsutil.EncodePrivateKeyPEM(caKey),
+	}
+
+	if !found {
+		log.Debugf("generating CA %s for cluster %s", name, cluster.Name)
+
+		if err := kubeapi.CreateSecret(clientset, &v1.Secret{
+			ObjectMeta: meta_v1.ObjectMeta{
+				Name: name,
+				Labels: map[string]string{
+					config.LABEL_VENDOR:           config.LABEL_CRUNCHY,
+					config.LABEL_PG_CLUSTER:       cluster.Name,
+					config.LABEL_PGO_GENERATED_CA: config.LABEL_TRUE,
+				},
+			},
+			Data: data,
+		}, cluster.Namespace); err != nil {
+			return nil, nil, nil, err
+		}
+
+		return caCert, caKey, data[tlsCACertKey], nil
+	}
+
+	log.Debugf("rotating CA %s of cluster %s", name, cluster.Name)
+
+	// the CA that is replaced keeps being trusted, as long as it is valid
+	data[tlsPreviousCACertKey] = secret.Data[tlsCACertKey]
+
+	secret.Data = data
+	if err := kubeapi.UpdateSecret(clientset, secret, cluster.Namespace); err != nil {
+		return nil, nil, nil, err
+	}
+
+	operator.RecordNormalEvent(clientset, cluster, eventReasonTLSCARotated,
+		fmt.Sprintf("generated a new CA, which expires on %s", caCert.NotAfter.UTC().Format(time.RFC3339)))
+
+	return caCert, caKey, getTLSCABundle(data[tlsCACertKey], data[tlsPreviousCACertKey]), nil
+}
+
+// reconcileTLSCertificate issues a certificate of a cluster from its CA, if it was not issued
+// yet, is about to expire, was not issued by the current CA or is not valid for the names it is
+// requested for. The bundle of trusted CAs in its Secret is kept up to date as well. It returns
+// the current certificate and whether it was issued again
+func reconcileTLSCertificate(clientset *kubernetes.Clientset, cluster *crv1.Pgcluster,
+	certificate kubeapi.Certificate, caCert *x509.Certificate, caKey *rsa.PrivateKey, bundle []byte,
+	validity, renewBefore time.Duration) (*x509.Certificate, bool, error) {
+	name := certificate.Spec.SecretName
+
+	secret, found, err := kubeapi.GetSecret(clientset, name, cluster.Namespace)
+	if err != nil && !kerrors.IsNotFound(err) {
+		return nil, false, err
+	}
+
+	if found {
+		if cert, err := tlsutil.ParsePEMEncodedCert(secret.Data[v1.TLSCertKey]); err == nil &&
+			isTLSCertificateCurrent(cert, certificate, caCert, renewBefore) {
+			if bytes.Equal(secret.Data[tlsCACertKey], bundle) {
+				return cert, false, nil
+			}
+
+			log.Debugf("updating trusted CAs of certificate %s", name)
+
+			secret.Data[tlsCACertKey] = bundle
+
+			return cert, false, kubeapi.UpdateSecret(clientset, secret, cluster.Namespace)
+		}
+	}
+
+	key, err := tlsutil.NewPrivateKey()
+	if err != nil {
+		return nil, false, err
+	}
+
+	var cert *x509.Certificate
+	if isTLSServerCertificate(certificate) {
+		cert, err = tlsutil.NewServerCertificate(key, certificate.Spec.CommonName, certificate.Spec.DNSNames,
+			validity, caCert, caKey)
+	} else {
+		cert, err = tlsutil.NewClientCertificateValidFor(key, certificate.Spec.CommonName, validity,
+			caCert, caKey)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	data := map[string][]byte{
+		v1.TLSCertKey:       tlsutil.EncodeCertificatePEM(cert),
+		v1.TLSPrivateKeyKey: tlsutil.EncodePrivateKeyPEM(key),
+		tlsCACertKey:        bundle,
+	}
+
+	log.Debugf("issuing certificate %s for cluster %s", name, cluster.Name)
+
+	if found {
+		secret.Data = data
+		return cert, true, kubeapi.UpdateSecret(clientset, secret, cluster.Namespace)
+	}
+
+	return cert, true, kubeapi.CreateSecret(clientset, &v1.Secret{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:   name,
+			Labels: certificate.Spec.SecretTemplate.Labels,
+		},
+		Type: v1.SecretTypeTLS,
+		Data: data,
+	}, cluster.Namespace)
+}
+
+// isTLSCACurrent returns true if a CA is valid for longer than the certificates issued from it
+func isTLSCACurrent(caCert *x509.Certificate, validity time.Duration) bool {
+	return caCert.IsCA && time.Until(caCert.NotAfter) > validity
+}
+
+// isTLSCertificateCurrent returns true if a certificate was issued by the current CA, is valid
+// for the names it is requested for and does not have to be renewed yet
+func isTLSCertificateCurrent(cert *x509.Certificate, certificate kubeapi.Certificate,
+	caCert *x509.Certificate, renewBefore time.Duration) bool {
+	dnsNames := certificate.Spec.DNSNames
+	if len(dnsNames) == 0 {
+		dnsNames = nil
+	}
+
+	certDNSNames := cert.DNSNames
+	if len(certDNSNames) == 0 {
+		certDNSNames = nil
+	}
+
+	return cert.CheckSignatureFrom(caCert) == nil &&
+		cert.Subject.CommonName == certificate.Spec.CommonName &&
+		reflect.DeepEqual(certDNSNames, dnsNames) &&
+		time.Until(cert.NotAfter) > renewBefore
+}
+
+// isTLSServerCertificate returns true if a certificate is requested for a TLS server rather than
+// for a client
+func isTLSServerCertificate(certificate kubeapi.Certificate) bool {
+	for _, usage := range certificate.Spec.Usages {
+		if usage == "server auth" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// getTLSCABundle returns the PEM encoded CAs that the certificates of a cluster are trusted with,
+// i.e. the current CA and the one it replaced, as long as that has not expired
+func getTLSCABundle(current, previous []byte) []byte {
+	bundle := append([]byte{}, current...)
+
+	if cert, err := tlsutil.ParsePEMEncodedCert(previous); err == nil && time.Now().Before(cert.NotAfter) {
+		bundle = append(bundle, tlsutil.EncodeCertificatePEM(cert)...)
+	}
+
+	return bundle
+}
@@ -0,0 +1,139 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"crypto/x509"
+	"testing"
+	"time"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/tlsutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newTestTLSCA(t *testing.T, validity time.Duration) (*x509.Certificate, *rsa.PrivateKey) {
+	key, err := tlsutil.NewPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := tlsutil.NewCACertificate(key, "hippo-ca", validity)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return cert, key
+}
+
+func TestIsTLSCACurrent(t *testing.T) {
+	validity := 90 * 24 * time.Hour
+
+	caCert, _ := newTestTLSCA(t, tlsCAValidity)
+	if !isTLSCACurrent(caCert, validity) {
+		t.Fatal("expected a new CA to be current")
+	}
+
+	// a certificate issued from this CA would outlive it
+	caCert, _ = newTestTLSCA(t, 60*24*time.Hour)
+	if isTLSCACurrent(caCert, validity) {
+		t.Fatal("expected a CA that expires before its certificates to be rotated")
+	}
+}
+
+func TestIsTLSCertificateCurrent(t *testing.T) {
+	cluster := &crv1.Pgcluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "hippo", Namespace: "pgo", Labels: map[string]string{}},
+		Spec:       crv1.PgclusterSpec{TLS: crv1.TLSSpec{Generate: crv1.TLSGenerateSpec{Enabled: true}}},
+	}
+
+	certificates := getTLSCertificates(cluster)
+	server, replication := certificates[0], certificates[1]
+
+	if !isTLSServerCertificate(server) || isTLSServerCertificate(replication) {
+		t.Fatal("expected only the certificate of the server to be a server certificate")
+	}
+
+	caCert, caKey := newTestTLSCA(t, tlsCAValidity)
+	renewBefore := 30 * 24 * time.Hour
+
+	issue := func(validity time.Duration, dnsNames []string, caCert *x509.Certificate,
+		caKey *rsa.PrivateKey) *x509.Certificate {
+		key, err := tlsutil.NewPrivateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		cert, err := tlsutil.NewServerCertificate(key, server.Spec.CommonName, dnsNames, validity, caCert, caKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return cert
+	}
+
+	t.Run("current", func(t *testing.T) {
+		cert := issue(90*24*time.Hour, server.Spec.DNSNames, caCert, caKey)
+
+		if !isTLSCertificateCurrent(cert, server, caCert, renewBefore) {
+			t.Fatal("expected a new certificate to be current")
+		}
+	})
+
+	t.Run("due for renewal", func(t *testing.T) {
+		cert := issue(20*24*time.Hour, server.Spec.DNSNames, caCert, caKey)
+
+		if isTLSCertificateCurrent(cert, server, caCert, renewBefore) {
+			t.Fatal("expected a certificate within its renewal period to be issued again")
+		}
+	})
+
+	t.Run("other CA", func(t *testing.T) {
+		otherCert, otherKey := newTestTLSCA(t, tlsCAValidity)
+		cert := issue(90*24*time.Hour, server.Spec.DNSNames, otherCert, otherKey)
+
+		if isTLSCertificateCurrent(cert, server, caCert, renewBefore) {
+			t.Fatal("expected a certificate of a rotated CA to be issued again")
+		}
+	})
+
+	t.Run("other names", func(t *testing.T) {
+		cert := issue(90*24*time.Hour, server.Spec.DNSNames[:3], caCert, caKey)
+
+		if isTLSCertificateCurrent(cert, server, caCert, renewBefore) {
+			t.Fatal("expected a certificate missing a name to be issued again")
+		}
+	})
+}
+
+func TestGetTLSCABundle(t *testing.T) {
+	current, _ := newTestTLSCA(t, tlsCAValidity)
+	previous, _ := newTestTLSCA(t, 24*time.Hour)
+
+	currentPEM := tlsutil.EncodeCertificatePEM(current)
+	previousPEM := tlsutil.EncodeCertificatePEM(previous)
+
+	bundle := getTLSCABundle(currentPEM, previousPEM)
+	if !bytes.Equal(bundle, append(append([]byte{}, currentPEM...), previousPEM...)) {
+		t.Fatal("expected the previous CA to be trusted along with the current one")
+	}
+
+	if !bytes.Equal(getTLSCABundle(currentPEM, nil), currentPEM) {
+		t.Fatal("expected only the current CA without a previous one")
+	}
+}
@@ -0,0 +1,328 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/config"
+	"github.com/crunchydata/postgres-operator/kubeapi"
+	"github.com/crunchydata/postgres-operator/operator"
+	"github.com/crunchydata/postgres-operator/util"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	// defaultTrafficRampDurationSeconds is how long a traffic ramp takes if no duration is set
+	defaultTrafficRampDurationSeconds = 300
+	// defaultTrafficRampInitialPercent is the percentage of the available connections that are
+	// allowed at the start of a traffic ramp if no percentage is set
+	defaultTrafficRampInitialPercent = 10
+	// defaultTrafficRampReservedConnections is the number of connections that are kept free of
+	// application connections during a traffic ramp if no number is set
+	defaultTrafficRampReservedConnections = 5
+
+	// eventReasonTrafficRampHalted is the reason of the Warning event that is recorded when a
+	// traffic ramp halts
+	eventReasonTrafficRampHalted = "TrafficRampHalted"
+
+	// sqlTrafficRampDatabases returns the databases that accept connections, along with their
+	// connection limits
+	sqlTrafficRampDatabases = `SELECT datname, datconnlimit FROM pg_catalog.pg_database
+WHERE datallowconn AND NOT datistemplate;`
+
+	// sqlTrafficRampAvailableConnections returns the number of connections that are available to
+	// users other than superusers
+	sqlTrafficRampAvailableConnections = `SELECT current_setting('max_connections')::int -
+current_setting('superuser_reserved_connections')::int;`
+
+	// sqlTrafficRampConnectionLimit sets the connection limit of a database
+	sqlTrafficRampConnectionLimit = `ALTER DATABASE %s CONNECTION LIMIT %d;`
+)
+
+// StartTrafficRamp starts ramping up the connections to the databases of a newly promoted primary,
+// if the cluster has a traffic ramp enabled. The current connection limits of the databases are
+// recorded so that they can be restored once the ramp is done. If a ramp is already in progress,
+// e.g. because of a second failover, it starts over on the new primary and keeps the limits that
+// were recorded when the first ramp started
+func StartTrafficRamp(clientset *kubernetes.Clientset, restclient *rest.RESTClient,
+	restconfig *rest.Config, cluster *crv1.Pgcluster, pod *v1.Pod) error {
+	ramp := cluster.Spec.TrafficRamp
+
+	if !ramp.Enabled {
+		return nil
+	}
+
+	limits := cluster.Status.TrafficRamp.ConnectionLimits
+
+	if cluster.Status.TrafficRamp.StartTime == "" {
+		output, err := execMaintenanceSQL(clientset, restconfig, pod, "postgres", sqlTrafficRampDatabases)
+		if err != nil {
+			return err
+		}
+
+		if limits, err = parseTrafficRampDatabases(output); err != nil {
+			return err
+		}
+	}
+
+	available, err := getTrafficRampAvailableConnections(clientset, restconfig, pod, ramp)
+	if err != nil {
+		return err
+	}
+
+	status := crv1.TrafficRampStatus{
+		Primary:          pod.Labels[config.LABEL_DEPLOYMENT_NAME],
+		StartTime:        time.Now().Format(time.RFC3339),
+		ConnectionLimit:  getTrafficRampLimit(ramp, available, 0),
+		ConnectionLimits: limits,
+	}
+
+	log.Infof("traffic ramp: allowing %d connections per database on primary %s of cluster %s",
+		status.ConnectionLimit, status.Primary, cluster.Name)
+
+	if err := setTrafficRampLimits(clientset, restconfig, pod, status.ConnectionLimit,
+		status.ConnectionLimits); err != nil {
+		return err
+	}
+
+	return kubeapi.Patchpgcluster(restclient, cluster, cluster.Namespace, func(cluster *crv1.Pgcluster) {
+		cluster.Status.TrafficRamp = status
+	})
+}
+
+// ReconcileTrafficRamp raises the connection limits of the databases of a cluster that is in the
+// middle of a traffic ramp according to how much of the ramp has passed, and restores the limits
+// the databases had before the ramp once it is done, or once it is disabled. If the primary is not
+// healthy, i.e. it is not ready, it cannot be queried, or the custom health check of the cluster
+// has marked it as degraded, the ramp is halted where it is and a Warning event is recorded
+func ReconcileTrafficRamp(clientset *kubernetes.Clientset, restclient *rest.RESTClient,
+	restconfig *rest.Config, cluster *crv1.Pgcluster) error {
+	ramp := cluster.Spec.TrafficRamp
+	status := cluster.Status.TrafficRamp
+
+	if status.StartTime == "" || (ramp.Enabled && status.Halted) {
+		return nil
+	}
+
+	// the primary is looked up by the namespace in the spec
+	cluster.Spec.Namespace = cluster.Namespace
+
+	pod, err := util.GetPrimaryPod(clientset, cluster)
+	if err == nil && !isReplicaPodReady(pod) {
+		err = fmt.Errorf("primary %s is not ready", pod.Name)
+	}
+
+	if err == nil && cluster.Status.HealthCheck.Degraded {
+		err = fmt.Errorf("cluster is degraded: health check failed: %s",
+			cluster.Status.HealthCheck.Message)
+	}
+
+	available := 0
+	if err == nil {
+		available, err = getTrafficRampAvailableConnections(clientset, restconfig, pod, ramp)
+	}
+
+	// a ramp that is disabled cannot be halted, so the limits are restored once the primary is
+	// healthy again
+	if err != nil && !ramp.Enabled {
+		return err
+	} else if err != nil {
+		return haltTrafficRamp(clientset, restclient, cluster, err)
+	}
+
+	startTime, err := time.Parse(time.RFC3339, status.StartTime)
+	if err != nil {
+		return err
+	}
+
+	duration := time.Duration(ramp.DurationSeconds) * time.Second
+	if ramp.DurationSeconds <= 0 {
+		duration = defaultTrafficRampDurationSeconds * time.Second
+	}
+
+	elapsed := time.Since(startTime)
+
+	if !ramp.Enabled || elapsed >= duration {
+		log.Infof("traffic ramp: restoring the connection limits of cluster %s", cluster.Name)
+
+		if err := restoreTrafficRampLimits(clientset, restconfig, pod, status.ConnectionLimits); err != nil {
+			return err
+		}
+
+		return kubeapi.Patchpgcluster(restclient, cluster, cluster.Namespace, func(cluster *crv1.Pgcluster) {
+			cluster.Status.TrafficRamp = crv1.TrafficRampStatus{}
+		})
+	}
+
+	limit := getTrafficRampLimit(ramp, available, float64(elapsed)/float64(duration))
+
+	if limit == status.ConnectionLimit {
+		return nil
+	}
+
+	log.Debugf("traffic ramp: allowing %d connections per database on cluster %s", limit,
+		cluster.Name)
+
+	if err := setTrafficRampLimits(clientset, restconfig, pod, limit, status.ConnectionLimits); err != nil {
+		return err
+	}
+
+	status.ConnectionLimit = limit
+
+	return kubeapi.Patchpgcluster(restclient, cluster, cluster.Namespace, func(cluster *crv1.Pgcluster) {
+		cluster.Status.TrafficRamp = status
+	})
+}
+
+// getTrafficRampAvailableConnections returns the number of connections that are available to the
+// applications during a traffic ramp, i.e. those that are neither reserved for superusers by
+// PostgreSQL, nor reserved by the ramp
+func getTrafficRampAvailableConnections(clientset *kubernetes.Clientset, restconfig *rest.Config,
+	pod *v1.Pod, ramp crv1.TrafficRampSpec) (int, error) {
+	output, err := execMaintenanceSQL(clientset, restconfig, pod, "postgres",
+		sqlTrafficRampAvailableConnections)
+	if err != nil {
+		return 0, err
+	}
+
+	available, err := strconv.Atoi(output)
+	if err != nil {
+		return 0, fmt.Errorf("unexpected connection output %q", output)
+	}
+
+	reserved := ramp.ReservedConnections
+	if reserved <= 0 {
+		reserved = defaultTrafficRampReservedConnections
+	}
+
+	return available - reserved, nil
+}
+
+// getTrafficRampLimit returns the connection limit of the databases once the given fraction of
+// the ramp has passed. At least one connection is always allowed, so that the applications are
+// never locked out entirely
+func getTrafficRampLimit(ramp crv1.TrafficRampSpec, available int, progress float64) int {
+	initial := ramp.InitialPercent
+	if initial <= 0 || initial > 100 {
+		initial = defaultTrafficRampInitialPercent
+	}
+
+	percent := float64(initial) + float64(100-initial)*progress
+	limit := int(float64(available) * percent / 100)
+
+	if limit < 1 {
+		return 1
+	}
+
+	return limit
+}
+
+// haltTrafficRamp halts the traffic ramp of the cluster, leaving the connection limits where they
+// are, and surfaces why in the status of the cluster as well as in a Warning event
+func haltTrafficRamp(clientset *kubernetes.Clientset, restclient *rest.RESTClient,
+	cluster *crv1.Pgcluster, reason error) error {
+	status := cluster.Status.TrafficRamp
+	status.Halted = true
+	status.Message = fmt.Sprintf("halted at %d connections per database: %s",
+		status.ConnectionLimit, reason)
+
+	log.Warnf("traffic ramp of cluster %s %s", cluster.Name, status.Message)
+
+	operator.RecordWarningEvent(clientset, cluster, eventReasonTrafficRampHalted,
+		"traffic ramp "+status.Message)
+
+	return kubeapi.Patchpgcluster(restclient, cluster, cluster.Namespace, func(cluster *crv1.Pgcluster) {
+		cluster.Status.TrafficRamp = status
+	})
+}
+
+// setTrafficRampLimits sets the connection limit of each of the databases, unless the limit the
+// database had before the ramp is lower
+func setTrafficRampLimits(clientset *kubernetes.Clientset, restconfig *rest.Config, pod *v1.Pod,
+	limit int, limits map[string]int) error {
+	for database, original := range limits {
+		databaseLimit := limit
+		if original >= 0 && original < limit {
+			databaseLimit = original
+		}
+
+		if err := setTrafficRampLimit(clientset, restconfig, pod, database, databaseLimit); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// restoreTrafficRampLimits sets the connection limits of the databases back to what they were
+// before the ramp
+func restoreTrafficRampLimits(clientset *kubernetes.Clientset, restconfig *rest.Config, pod *v1.Pod,
+	limits map[string]int) error {
+	for database, original := range limits {
+		if err := setTrafficRampLimit(clientset, restconfig, pod, database, original); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// setTrafficRampLimit sets the connection limit of a database. A database that has been dropped
+// since the ramp started is skipped
+func setTrafficRampLimit(clientset *kubernetes.Clientset, restconfig *rest.Config, pod *v1.Pod,
+	database string, limit int) error {
+	sql := fmt.Sprintf(sqlTrafficRampConnectionLimit, util.SQLQuoteIdentifier(database), limit)
+
+	if _, err := execMaintenanceSQL(clientset, restconfig, pod, "postgres", sql); err != nil &&
+		!strings.Contains(err.Error(), "does not exist") {
+		return err
+	}
+
+	return nil
+}
+
+// parseTrafficRampDatabases parses the unaligned output of sqlTrafficRampDatabases
+func parseTrafficRampDatabases(output string) (map[string]int, error) {
+	limits := map[string]int{}
+
+	for _, line := range strings.Split(output, "\n") {
+		if line == "" {
+			continue
+		}
+
+		i := strings.LastIndex(line, "|")
+		if i < 0 {
+			return nil, fmt.Errorf("unexpected database output %q", line)
+		}
+
+		limit, err := strconv.Atoi(line[i+1:])
+		if err != nil {
+			return nil, err
+		}
+
+		limits[line[:i]] = limit
+	}
+
+	return limits, nil
+}
@@ -0,0 +1,122 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+const (
+	// tuningDefaultMaxConnections is the "max_connections" of PostgreSQL when it is not set,
+	// which the memory of each operation is divided by
+	tuningDefaultMaxConnections = 100
+	// tuningMaxMaintenanceWorkMem is the most memory, in kB, that is set aside for maintenance
+	// operations, as PostgreSQL does not make use of more than that for a vacuum
+	tuningMaxMaintenanceWorkMem = 2 * 1024 * 1024
+	// tuningMinWorkMem is the least memory, in kB, that is set aside for an operation, which is
+	// the default of PostgreSQL
+	tuningMinWorkMem = 4 * 1024
+)
+
+// ValidateTuning returns an error if the memory settings of a cluster cannot be derived, i.e.
+// the tuning mode is not known, or the database container does not have any memory to derive
+// them from
+func ValidateTuning(cluster *crv1.Pgcluster) error {
+	switch cluster.Spec.Tuning {
+	case "", crv1.PostgresTuningOff:
+		return nil
+	case crv1.PostgresTuningAuto:
+	default:
+		return fmt.Errorf("invalid tuning %q, must be %q or %q", cluster.Spec.Tuning,
+			crv1.PostgresTuningOff, crv1.PostgresTuningAuto)
+	}
+
+	if getTuningMemory(cluster.Spec.ContainerResources) == 0 {
+		return errors.New("tuning requires a memory limit or a memory request")
+	}
+
+	return nil
+}
+
+// getTunedPostgresParams returns the memory settings of PostgreSQL that are derived from the
+// memory of the database container of a cluster in the way pgtune does, if the cluster is tuned:
+//
+//   - "shared_buffers" is a quarter of the memory
+//   - "effective_cache_size" is three quarters of it
+//   - "maintenance_work_mem" is a sixteenth of it, up to 2GB
+//   - "work_mem" is what is left besides the shared buffers, divided across three operations
+//     of each of the "max_connections"
+func getTunedPostgresParams(cluster *crv1.Pgcluster) map[string]string {
+	if cluster.Spec.Tuning != crv1.PostgresTuningAuto {
+		return nil
+	}
+
+	memory := getTuningMemory(cluster.Spec.ContainerResources) / 1024
+	if memory == 0 {
+		return nil
+	}
+
+	maxConnections, err := strconv.ParseInt(cluster.Spec.PostgresParams["max_connections"], 10, 64)
+	if err != nil || maxConnections <= 0 {
+		maxConnections = tuningDefaultMaxConnections
+	}
+
+	sharedBuffers := memory / 4
+
+	maintenanceWorkMem := memory / 16
+	if maintenanceWorkMem > tuningMaxMaintenanceWorkMem {
+		maintenanceWorkMem = tuningMaxMaintenanceWorkMem
+	}
+
+	workMem := (memory - sharedBuffers) / (maxConnections * 3)
+	if workMem < tuningMinWorkMem {
+		workMem = tuningMinWorkMem
+	}
+
+	return map[string]string{
+		"effective_cache_size": formatTuningMemory(memory * 3 / 4),
+		"maintenance_work_mem": formatTuningMemory(maintenanceWorkMem),
+		"shared_buffers":       formatTuningMemory(sharedBuffers),
+		"work_mem":             formatTuningMemory(workMem),
+	}
+}
+
+// getTuningMemory returns the memory, in bytes, that the settings of PostgreSQL are derived
+// from, which is the memory limit of the database container, or its memory request if it has
+// no limit. It is zero if neither is set
+func getTuningMemory(resources crv1.PgContainerResources) int64 {
+	for _, value := range []string{resources.LimitsMemory, resources.RequestsMemory} {
+		if quantity, err := resource.ParseQuantity(value); err == nil && quantity.Sign() > 0 {
+			return quantity.Value()
+		}
+	}
+
+	return 0
+}
+
+// formatTuningMemory formats an amount of memory in kB as a PostgreSQL setting, in MB if it is
+// a whole number of them
+func formatTuningMemory(kilobytes int64) string {
+	if kilobytes%1024 == 0 {
+		return fmt.Sprintf("%dMB", kilobytes/1024)
+	}
+	return fmt.Sprintf("%dkB", kilobytes)
+}
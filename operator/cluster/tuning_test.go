@@ -0,0 +1,98 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"reflect"
+	"testing"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+)
+
+func TestGetTunedPostgresParams(t *testing.T) {
+	for _, test := range []struct {
+		name       string
+		resources  crv1.PgContainerResources
+		params     map[string]string
+		parameters map[string]string
+	}{
+		{"limit", crv1.PgContainerResources{RequestsMemory: "1Gi", LimitsMemory: "4Gi"}, nil,
+			map[string]string{
+				"effective_cache_size": "3072MB",
+				"maintenance_work_mem": "256MB",
+				"shared_buffers":       "1024MB",
+				"work_mem":             "10485kB",
+			}},
+		{"request", crv1.PgContainerResources{RequestsMemory: "4Gi"},
+			map[string]string{"max_connections": "200"},
+			map[string]string{
+				"effective_cache_size": "3072MB",
+				"maintenance_work_mem": "256MB",
+				"shared_buffers":       "1024MB",
+				"work_mem":             "5242kB",
+			}},
+		{"small", crv1.PgContainerResources{LimitsMemory: "1Gi"}, nil,
+			map[string]string{
+				"effective_cache_size": "768MB",
+				"maintenance_work_mem": "64MB",
+				"shared_buffers":       "256MB",
+				"work_mem":             "4MB",
+			}},
+		{"large", crv1.PgContainerResources{LimitsMemory: "64Gi"}, nil,
+			map[string]string{
+				"effective_cache_size": "49152MB",
+				"maintenance_work_mem": "2048MB",
+				"shared_buffers":       "16384MB",
+				"work_mem":             "167772kB",
+			}},
+		{"no memory", crv1.PgContainerResources{}, nil, nil},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			cluster := &crv1.Pgcluster{Spec: crv1.PgclusterSpec{
+				ContainerResources: test.resources,
+				PostgresParams:     test.params,
+				Tuning:             crv1.PostgresTuningAuto,
+			}}
+
+			if parameters := getTunedPostgresParams(cluster); !reflect.DeepEqual(parameters, test.parameters) {
+				t.Fatalf("expected %v, got %v", test.parameters, parameters)
+			}
+		})
+	}
+
+	t.Run("overridden", func(t *testing.T) {
+		cluster := &crv1.Pgcluster{Spec: crv1.PgclusterSpec{
+			ContainerResources: crv1.PgContainerResources{LimitsMemory: "4Gi"},
+			PostgresParams:     map[string]string{"shared_buffers": "512MB"},
+			Tuning:             crv1.PostgresTuningAuto,
+		}}
+
+		parameters := getPostgresParams(cluster)
+		if parameters["shared_buffers"] != "512MB" || parameters["work_mem"] != "10485kB" {
+			t.Fatalf("expected the spec to take precedence over the tuning, got %v", parameters)
+		}
+	})
+
+	t.Run("off", func(t *testing.T) {
+		cluster := &crv1.Pgcluster{Spec: crv1.PgclusterSpec{
+			ContainerResources: crv1.PgContainerResources{LimitsMemory: "4Gi"},
+		}}
+
+		if parameters := getTunedPostgresParams(cluster); parameters != nil {
+			t.Fatalf("expected no parameters, got %v", parameters)
+		}
+	})
+}
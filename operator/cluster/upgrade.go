@@ -332,15 +332,7 @@ func createImageNamePatch(cluster crv1.Pgcluster, ccpImagePrefix, ccpImageTag st
 	// add the database container, which will always be patched
 	databaseContainer := patchDeploymentContainers{
 		Name:  "database",
-		Image: ccpImagePrefix + "/" + cluster.Spec.CCPImage + ":" + ccpImageTag,
-	}
-
-	// see if the image name is overridden
-	if strings.Contains(cluster.Spec.CCPImage, "gis-ha") &&
-		operator.ContainerImageOverrides[config.CONTAINER_IMAGE_CRUNCHY_POSTGRES_GIS_HA] != "" {
-		databaseContainer.Image = operator.ContainerImageOverrides[config.CONTAINER_IMAGE_CRUNCHY_POSTGRES_GIS_HA]
-	} else if operator.ContainerImageOverrides[config.CONTAINER_IMAGE_CRUNCHY_POSTGRES_HA] != "" {
-		databaseContainer.Image = operator.ContainerImageOverrides[config.CONTAINER_IMAGE_CRUNCHY_POSTGRES_HA]
+		Image: getDatabaseImage(cluster, ccpImagePrefix, ccpImageTag),
 	}
 
 	containersToPatch = append(containersToPatch, databaseContainer)
@@ -349,7 +341,7 @@ func createImageNamePatch(cluster crv1.Pgcluster, ccpImagePrefix, ccpImageTag st
 	collectEnabled, err := strconv.ParseBool(cluster.Labels[config.LABEL_COLLECT])
 	if err != nil {
 		return "", err
-	} else if collectEnabled {
+	} else if collectEnabled || cluster.Spec.Exporter.Enabled {
 		collectContainer := patchDeploymentContainers{
 			Name:  "collect",
 			Image: ccpImagePrefix + "/" + collectCCPImage + ":" + ccpImageTag,
@@ -413,6 +405,19 @@ func createImageNamePatch(cluster crv1.Pgcluster, ccpImagePrefix, ccpImageTag st
 	return string(data), nil
 }
 
+// getDatabaseImage returns the image of the database container of the instances of a cluster
+// for the given image tag, unless the image is overridden
+func getDatabaseImage(cluster crv1.Pgcluster, ccpImagePrefix, ccpImageTag string) string {
+	if strings.Contains(cluster.Spec.CCPImage, "gis-ha") &&
+		operator.ContainerImageOverrides[config.CONTAINER_IMAGE_CRUNCHY_POSTGRES_GIS_HA] != "" {
+		return operator.ContainerImageOverrides[config.CONTAINER_IMAGE_CRUNCHY_POSTGRES_GIS_HA]
+	} else if operator.ContainerImageOverrides[config.CONTAINER_IMAGE_CRUNCHY_POSTGRES_HA] != "" {
+		return operator.ContainerImageOverrides[config.CONTAINER_IMAGE_CRUNCHY_POSTGRES_HA]
+	}
+
+	return ccpImagePrefix + "/" + cluster.Spec.CCPImage + ":" + ccpImageTag
+}
+
 // completeUpgrade - makes any finishing changes required to complete the upgrade and
 // does final updates to the task and cluster.
 func completeUpgrade(clientset *kubernetes.Clientset, restclient *rest.RESTClient, upgradeTask *crv1.Pgtask, autoFail bool, clusterName, namespace string) {
@@ -0,0 +1,352 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/config"
+	"github.com/crunchydata/postgres-operator/operator"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// maxClusterNameLength is the longest a cluster name may be. The names of the objects of the
+// cluster are derived from it, the longest of which is the Service of the pgBackRest
+// repository, "<cluster>-backrest-shared-repo", which has to fit in a DNS label of 63 characters
+const maxClusterNameLength = 42
+
+// supportedPostgresVersions are the major versions of PostgreSQL that the Operator can run
+var supportedPostgresVersions = map[string]bool{
+	"9.5": true,
+	"9.6": true,
+	"10":  true,
+	"11":  true,
+	"12":  true,
+}
+
+// ValidateCluster returns the reasons why a cluster cannot be created as it is specified, if
+// any: whether its name, storage sizes, resources, PostgreSQL version, locale, standby
+// streaming, password encryption, pg_hba rules and pgBouncer settings can be used
+func ValidateCluster(cluster *crv1.Pgcluster) []string {
+	reasons := []string{}
+
+	for _, err := range validation.IsDNS1035Label(cluster.Name) {
+		reasons = append(reasons, fmt.Sprintf("invalid cluster name %q: %s", cluster.Name, err))
+	}
+	if len(cluster.Name) > maxClusterNameLength {
+		reasons = append(reasons, fmt.Sprintf("cluster name %q is longer than %d characters",
+			cluster.Name, maxClusterNameLength))
+	}
+
+	storage := map[string]crv1.PgStorageSpec{
+		"primary storage":    cluster.Spec.PrimaryStorage,
+		"replica storage":    cluster.Spec.ReplicaStorage,
+		"archive storage":    cluster.Spec.ArchiveStorage,
+		"pgBackRest storage": cluster.Spec.BackrestStorage,
+		"WAL storage":        cluster.Spec.WALStorage,
+	}
+	for name, spec := range cluster.Spec.GetTablespaces() {
+		storage["tablespace "+name+" storage"] = spec
+	}
+	for name, spec := range storage {
+		if reason := validateQuantity(name+" size", spec.Size); reason != "" {
+			reasons = append(reasons, reason)
+		}
+	}
+
+	resources := cluster.Spec.ContainerResources
+	reasons = append(reasons, validateResource("memory", resources.RequestsMemory, resources.LimitsMemory)...)
+	reasons = append(reasons, validateResource("CPU", resources.RequestsCPU, resources.LimitsCPU)...)
+	reasons = append(reasons, validateResource("ephemeral storage",
+		cluster.Spec.EphemeralStorage.Requests, cluster.Spec.EphemeralStorage.Limits)...)
+
+	resources = cluster.Spec.PgBouncer.Resources
+	reasons = append(reasons, validateResource("pgBouncer memory",
+		resources.RequestsMemory, resources.LimitsMemory)...)
+	reasons = append(reasons, validateResource("pgBouncer CPU",
+		resources.RequestsCPU, resources.LimitsCPU)...)
+
+	resources = cluster.Spec.Exporter.Resources
+	reasons = append(reasons, validateResource("exporter memory",
+		resources.RequestsMemory, resources.LimitsMemory)...)
+	reasons = append(reasons, validateResource("exporter CPU",
+		resources.RequestsCPU, resources.LimitsCPU)...)
+
+	// a custom image tag that the version cannot be told from is left alone
+	if version := getPGMajorVersion(cluster.Spec.CCPImageTag); version != "" &&
+		!supportedPostgresVersions[version] {
+		reasons = append(reasons, fmt.Sprintf("PostgreSQL %s of image tag %q is not supported",
+			version, cluster.Spec.CCPImageTag))
+	}
+
+	if err := ValidateLocale(cluster); err != nil {
+		reasons = append(reasons, err.Error())
+	}
+
+	if err := ValidateStandbyStreaming(cluster); err != nil {
+		reasons = append(reasons, err.Error())
+	}
+
+	if days := cluster.Spec.PasswordRotation.IntervalDays; days < 0 {
+		reasons = append(reasons, fmt.Sprintf("invalid password rotation interval of %d days, it cannot be negative",
+			days))
+	}
+
+	if err := ValidatePasswordEncryption(cluster); err != nil {
+		reasons = append(reasons, err.Error())
+	}
+
+	if err := ValidateHBA(cluster); err != nil {
+		reasons = append(reasons, err.Error())
+	}
+
+	if err := ValidatePostgresParams(cluster); err != nil {
+		reasons = append(reasons, err.Error())
+	}
+
+	if err := ValidateTuning(cluster); err != nil {
+		reasons = append(reasons, err.Error())
+	}
+
+	if err := ValidateTLS(cluster); err != nil {
+		reasons = append(reasons, err.Error())
+	}
+
+	if err := ValidateTLSClientAuth(cluster); err != nil {
+		reasons = append(reasons, err.Error())
+	}
+
+	if err := ValidatePgBouncer(cluster); err != nil {
+		reasons = append(reasons, err.Error())
+	}
+
+	if err := ValidateExporter(cluster); err != nil {
+		reasons = append(reasons, err.Error())
+	}
+
+	if err := ValidateMonitor(cluster); err != nil {
+		reasons = append(reasons, err.Error())
+	}
+
+	if err := ValidateAlerts(cluster); err != nil {
+		reasons = append(reasons, err.Error())
+	}
+
+	if err := ValidateAudit(cluster); err != nil {
+		reasons = append(reasons, err.Error())
+	}
+
+	if err := ValidateBackrestVerification(cluster); err != nil {
+		reasons = append(reasons, err.Error())
+	}
+
+	if err := operator.ValidateBackrestIdentity(cluster); err != nil {
+		reasons = append(reasons, err.Error())
+	}
+
+	if err := operator.ValidateBackrestSecretStore(cluster); err != nil {
+		reasons = append(reasons, err.Error())
+	}
+
+	if err := operator.ValidateInstancePlacement(cluster); err != nil {
+		reasons = append(reasons, err.Error())
+	}
+
+	if err := operator.ValidateCustomMetadata(cluster); err != nil {
+		reasons = append(reasons, err.Error())
+	}
+
+	if err := operator.ValidateInstancePod(cluster); err != nil {
+		reasons = append(reasons, err.Error())
+	}
+
+	if err := ValidateStorageAutogrow(cluster); err != nil {
+		reasons = append(reasons, err.Error())
+	}
+
+	if err := ValidateWALStorage(cluster); err != nil {
+		reasons = append(reasons, err.Error())
+	}
+
+	if err := ValidateTablespaces(cluster); err != nil {
+		reasons = append(reasons, err.Error())
+	}
+
+	if err := ValidateVolumeSnapshots(cluster); err != nil {
+		reasons = append(reasons, err.Error())
+	}
+
+	if err := ValidateHibernationSchedule(cluster); err != nil {
+		reasons = append(reasons, err.Error())
+	}
+
+	if err := ValidateAutoscale(cluster); err != nil {
+		reasons = append(reasons, err.Error())
+	}
+
+	if err := ValidateResourceRecommendation(cluster); err != nil {
+		reasons = append(reasons, err.Error())
+	}
+
+	if err := ValidateMaintenanceWindow(cluster); err != nil {
+		reasons = append(reasons, err.Error())
+	}
+
+	if err := ValidateMaintenance(cluster); err != nil {
+		reasons = append(reasons, err.Error())
+	}
+
+	if err := ValidateFailoverHealthCheck(cluster); err != nil {
+		reasons = append(reasons, err.Error())
+	}
+
+	if err := ValidateSyncReplication(cluster); err != nil {
+		reasons = append(reasons, err.Error())
+	}
+
+	if err := ValidateMaxReplicaLag(cluster); err != nil {
+		reasons = append(reasons, err.Error())
+	}
+
+	if err := ValidateReadOnlyService(cluster); err != nil {
+		reasons = append(reasons, err.Error())
+	}
+
+	if err := ValidateServices(cluster); err != nil {
+		reasons = append(reasons, err.Error())
+	}
+
+	// the storage is checked by name, so the reasons are sorted to always be reported alike
+	sort.Strings(reasons)
+
+	return reasons
+}
+
+// validateQuantity returns why a quantity that is set is invalid, i.e. does not parse or is not
+// greater than zero, if it is
+func validateQuantity(name, value string) string {
+	if value == "" {
+		return ""
+	}
+
+	quantity, err := resource.ParseQuantity(value)
+	if err != nil {
+		return fmt.Sprintf("invalid %s %q: %s", name, value, err)
+	}
+	if quantity.Sign() <= 0 {
+		return fmt.Sprintf("invalid %s %q: must be greater than zero", name, value)
+	}
+
+	return ""
+}
+
+// validateResource returns why the request or the limit of a resource is invalid, which includes
+// a limit that is lower than the request
+func validateResource(name, request, limit string) []string {
+	reasons := []string{}
+
+	for _, reason := range []string{
+		validateQuantity(name+" request", request),
+		validateQuantity(name+" limit", limit),
+	} {
+		if reason != "" {
+			reasons = append(reasons, reason)
+		}
+	}
+
+	if len(reasons) > 0 || request == "" || limit == "" {
+		return reasons
+	}
+
+	if limitQuantity := resource.MustParse(limit); limitQuantity.Cmp(resource.MustParse(request)) < 0 {
+		reasons = append(reasons, fmt.Sprintf("%s limit %q is lower than the %s request %q",
+			name, limit, name, request))
+	}
+
+	return reasons
+}
+
+// ValidateClusterChange returns the reasons why an update of a cluster cannot be made, which are
+// those that the cluster was not already invalid for, so that a cluster that predates a check
+// can still be updated, e.g. by the Operator recording its status, along with a change of the
+// connection pooler while it is running
+func ValidateClusterChange(oldCluster, newCluster *crv1.Pgcluster) []string {
+	existing := map[string]bool{}
+	for _, reason := range ValidateCluster(oldCluster) {
+		existing[reason] = true
+	}
+
+	reasons := []string{}
+	for _, reason := range ValidateCluster(newCluster) {
+		if !existing[reason] {
+			reasons = append(reasons, reason)
+		}
+	}
+
+	// the Deployments of one connection pooler are not rolled out to another
+	if oldCluster.Labels[config.LABEL_PGBOUNCER] == "true" &&
+		oldCluster.Spec.PgBouncer.GetPooler() != newCluster.Spec.PgBouncer.GetPooler() {
+		reasons = append(reasons, fmt.Sprintf("the connection pooler cannot be changed from %q to %q "+
+			"while the cluster has one", oldCluster.Spec.PgBouncer.GetPooler(),
+			newCluster.Spec.PgBouncer.GetPooler()))
+	}
+
+	// the volumes of a tablespace are mounted by every instance, which would not start without
+	// them once objects are created in the tablespace
+	removed := []string{}
+	newTablespaces := newCluster.Spec.GetTablespaces()
+	for name := range oldCluster.Spec.GetTablespaces() {
+		if _, ok := newTablespaces[name]; !ok {
+			removed = append(removed, name)
+		}
+	}
+	sort.Strings(removed)
+	for _, name := range removed {
+		reasons = append(reasons, fmt.Sprintf("tablespace %q cannot be removed", name))
+	}
+
+	// pg_wal is put on the WAL volume when the data directory is created, so the WAL cannot be
+	// moved on or off of the volumes of its own afterward
+	if operator.HasWALStorage(oldCluster) != operator.HasWALStorage(newCluster) {
+		reasons = append(reasons, fmt.Sprintf("the WAL storage type cannot be changed from %q to %q",
+			oldCluster.Spec.WALStorage.StorageType, newCluster.Spec.WALStorage.StorageType))
+	}
+
+	// the volumes of the instances can be expanded, but not shrunk
+	for name, sizes := range map[string][2]string{
+		"primary storage": {oldCluster.Spec.PrimaryStorage.Size, newCluster.Spec.PrimaryStorage.Size},
+		"replica storage": {oldCluster.Spec.ReplicaStorage.Size, newCluster.Spec.ReplicaStorage.Size},
+	} {
+		oldSize, oldErr := resource.ParseQuantity(sizes[0])
+		newSize, newErr := resource.ParseQuantity(sizes[1])
+
+		if oldErr == nil && newErr == nil && newSize.Cmp(oldSize) < 0 {
+			reasons = append(reasons, fmt.Sprintf("the %s size cannot be decreased from %q to %q",
+				name, sizes[0], sizes[1]))
+		}
+	}
+
+	return reasons
+}
+
+// describeReasons joins the reasons why a cluster is invalid into one message
+func describeReasons(reasons []string) string {
+	return strings.Join(reasons, "; ")
+}
@@ -0,0 +1,494 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"strings"
+	"testing"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/config"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// validCluster returns a cluster that passes validation, for the tests to break
+func validCluster() *crv1.Pgcluster {
+	return &crv1.Pgcluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "hacluster"},
+		Spec: crv1.PgclusterSpec{
+			CCPImageTag:     "centos7-12.2-4.3.0",
+			PrimaryStorage:  crv1.PgStorageSpec{Size: "1G"},
+			ReplicaStorage:  crv1.PgStorageSpec{Size: "1G"},
+			BackrestStorage: crv1.PgStorageSpec{Size: "2Gi"},
+			ContainerResources: crv1.PgContainerResources{
+				RequestsMemory: "512Mi",
+				LimitsMemory:   "1Gi",
+				RequestsCPU:    "500m",
+			},
+		},
+	}
+}
+
+func TestValidateCluster(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*crv1.Pgcluster)
+		reason string
+	}{
+		{"valid", func(*crv1.Pgcluster) {}, ""},
+		{"emptydir storage without a size", func(c *crv1.Pgcluster) { c.Spec.ReplicaStorage.Size = "" }, ""},
+		{"custom image tag", func(c *crv1.Pgcluster) { c.Spec.CCPImageTag = "latest" }, ""},
+		{"PostgreSQL 9.6", func(c *crv1.Pgcluster) { c.Spec.CCPImageTag = "centos7-9.6.17-4.3.0" }, ""},
+		{"name not a DNS label", func(c *crv1.Pgcluster) { c.Name = "ha_cluster" },
+			`invalid cluster name "ha_cluster"`},
+		{"name too long", func(c *crv1.Pgcluster) { c.Name = strings.Repeat("a", 43) },
+			"is longer than 42 characters"},
+		{"storage size does not parse", func(c *crv1.Pgcluster) { c.Spec.PrimaryStorage.Size = "1 GB" },
+			`invalid primary storage size "1 GB"`},
+		{"tablespace storage size not positive", func(c *crv1.Pgcluster) {
+			c.Spec.TablespaceMounts = map[string]crv1.PgStorageSpec{"ts1": {Size: "0"}}
+		}, `invalid tablespace ts1 storage size "0": must be greater than zero`},
+		{"memory request does not parse", func(c *crv1.Pgcluster) {
+			c.Spec.ContainerResources.RequestsMemory = "lots"
+		}, `invalid memory request "lots"`},
+		{"memory limit below the request", func(c *crv1.Pgcluster) {
+			c.Spec.ContainerResources.LimitsMemory = "256Mi"
+		}, `memory limit "256Mi" is lower than the memory request "512Mi"`},
+		{"CPU request not positive", func(c *crv1.Pgcluster) { c.Spec.ContainerResources.RequestsCPU = "-1" },
+			`invalid CPU request "-1": must be greater than zero`},
+		{"unsupported PostgreSQL version", func(c *crv1.Pgcluster) { c.Spec.CCPImageTag = "centos7-9.4.26-4.3.0" },
+			"PostgreSQL 9.4 of image tag"},
+		{"invalid locale", func(c *crv1.Pgcluster) { c.Spec.Locale.Collate = "en_US'" },
+			"invalid locale collate"},
+		{"invalid password encryption", func(c *crv1.Pgcluster) { c.Spec.PasswordEncryption = "password" },
+			`invalid password encryption "password"`},
+		{"SCRAM on PostgreSQL 9.6", func(c *crv1.Pgcluster) {
+			c.Spec.CCPImageTag = "centos7-9.6.17-4.3.0"
+			c.Spec.PasswordEncryption = crv1.PasswordEncryptionSCRAMSHA256
+		}, "requires PostgreSQL 10 or later"},
+		{"invalid pg_hba rule", func(c *crv1.Pgcluster) {
+			c.Spec.HBA.Rules = []string{"all all 10.0.0.0/8 md5"}
+		}, "it must start with a connection type"},
+		{"reserved postgres parameter", func(c *crv1.Pgcluster) {
+			c.Spec.PostgresParams = map[string]string{"port": "5433"}
+		}, "is managed by Patroni"},
+		{"generated certificates", func(c *crv1.Pgcluster) { c.Spec.TLS.Generate.Enabled = true }, ""},
+		{"certificates both requested and generated", func(c *crv1.Pgcluster) {
+			c.Spec.TLS.Generate.Enabled = true
+			c.Spec.TLS.CertManager.IssuerName = "pgo-ca"
+		}, "either be requested from cert-manager or generated"},
+		{"certificates renewed before they are issued", func(c *crv1.Pgcluster) {
+			c.Spec.TLS.Generate.Enabled = true
+			c.Spec.TLS.Generate.ValidityDays = 30
+		}, "certificates valid for 30 days cannot be renewed 30 days before"},
+		{"replication with generated certificates", func(c *crv1.Pgcluster) {
+			c.Spec.TLS.Generate.Enabled = true
+			c.Spec.TLS.ClientAuth.Replication = true
+		}, ""},
+		{"replication with a certificate that is not issued", func(c *crv1.Pgcluster) {
+			c.Spec.TLS.ClientAuth.CASecret = "hippo-client-ca"
+			c.Spec.TLS.ClientAuth.Replication = true
+		}, "the replication user can only authenticate with a client certificate that the Operator issues"},
+		{"mTLS-only without a client CA", func(c *crv1.Pgcluster) {
+			c.Spec.TLS.Generate.Enabled = true
+			c.Spec.TLS.ClientAuth.MTLSOnly = true
+		}, "require a client CA"},
+		{"pgBouncer in transaction mode", func(c *crv1.Pgcluster) {
+			c.Spec.PgBouncer.PoolMode = crv1.PgBouncerPoolModeTransaction
+			c.Spec.PgBouncer.Config = map[string]string{"server_idle_timeout": "60"}
+		}, ""},
+		{"unknown pgBouncer pool mode", func(c *crv1.Pgcluster) { c.Spec.PgBouncer.PoolMode = "query" },
+			`invalid pgBouncer pool mode "query"`},
+		{"managed pgBouncer setting", func(c *crv1.Pgcluster) {
+			c.Spec.PgBouncer.Config = map[string]string{"listen_port": "6432"}
+		}, `pgBouncer setting "listen_port" is managed by the Operator`},
+		{"pgBouncer CPU limit below the request", func(c *crv1.Pgcluster) {
+			c.Spec.PgBouncer.Resources = crv1.PgContainerResources{RequestsCPU: "1", LimitsCPU: "500m"}
+		}, `pgBouncer CPU limit "500m" is lower than the pgBouncer CPU request "1"`},
+		{"exporter with custom queries", func(c *crv1.Pgcluster) {
+			c.Spec.Exporter = crv1.ExporterSpec{Enabled: true, CustomQueries: "hippo-queries"}
+		}, ""},
+		{"custom queries without the exporter", func(c *crv1.Pgcluster) {
+			c.Spec.Exporter.CustomQueries = "hippo-queries"
+		}, "require the exporter to be enabled"},
+		{"exporter memory limit below the request", func(c *crv1.Pgcluster) {
+			c.Spec.Exporter.Resources = crv1.PgContainerResources{RequestsMemory: "64Mi", LimitsMemory: "32Mi"}
+		}, `exporter memory limit "32Mi" is lower than the exporter memory request "64Mi"`},
+		{"pod monitor", func(c *crv1.Pgcluster) {
+			c.Spec.Monitor = crv1.MonitorSpec{Kind: crv1.MonitorKindPodMonitor, Interval: "1m30s"}
+		}, ""},
+		{"unknown monitor kind", func(c *crv1.Pgcluster) { c.Spec.Monitor.Kind = "Probe" },
+			`invalid monitor kind "Probe"`},
+		{"invalid monitor interval", func(c *crv1.Pgcluster) {
+			c.Spec.Monitor = crv1.MonitorSpec{Kind: crv1.MonitorKindServiceMonitor, Interval: "30"}
+		}, `invalid monitor interval "30"`},
+		{"alerts thresholds", func(c *crv1.Pgcluster) {
+			c.Spec.Alerts = crv1.AlertsSpec{Enabled: true, DiskUsagePercent: 90, BackupAgeHours: 170}
+		}, ""},
+		{"alerts percentage", func(c *crv1.Pgcluster) { c.Spec.Alerts.ConnectionsPercent = 120 },
+			"invalid alerts connections threshold 120"},
+		{"negative alerts threshold", func(c *crv1.Pgcluster) { c.Spec.Alerts.ReplicationLagSeconds = -1 },
+			"invalid alerts thresholds"},
+		{"workload identity for S3", func(c *crv1.Pgcluster) {
+			c.Spec.UserLabels = map[string]string{config.LABEL_BACKREST_STORAGE_TYPE: "s3"}
+			c.Spec.BackrestIdentity = crv1.BackrestIdentitySpec{UseWorkloadIdentity: true,
+				AWSRoleARN: "arn:aws:iam::123456789012:role/pgbackrest"}
+		}, `workload identity for pgBackRest storage type "s3" requires pgBackRest 2.33`},
+		{"workload identity without a role", func(c *crv1.Pgcluster) {
+			c.Spec.UserLabels = map[string]string{config.LABEL_BACKREST_STORAGE_TYPE: "s3"}
+			c.Spec.BackrestIdentity = crv1.BackrestIdentitySpec{UseWorkloadIdentity: true,
+				GCPServiceAccount: "backup@project.iam.gserviceaccount.com"}
+		}, `invalid AWS role ARN ""`},
+		{"workload identity for local storage", func(c *crv1.Pgcluster) {
+			c.Spec.BackrestIdentity = crv1.BackrestIdentitySpec{UseWorkloadIdentity: true}
+		}, "workload identity is only supported"},
+		{"secret store for S3", func(c *crv1.Pgcluster) {
+			c.Spec.UserLabels = map[string]string{config.LABEL_BACKREST_STORAGE_TYPE: "s3"}
+			c.Spec.BackrestSecretStore = crv1.BackrestSecretStoreSpec{SecretProviderClass: "pgbackrest"}
+		}, ""},
+		{"secret store with two sources", func(c *crv1.Pgcluster) {
+			c.Spec.UserLabels = map[string]string{config.LABEL_BACKREST_STORAGE_TYPE: "s3"}
+			c.Spec.BackrestSecretStore = crv1.BackrestSecretStoreSpec{SecretProviderClass: "pgbackrest",
+				ExternalSecret: "pgbackrest"}
+		}, "only one of a SecretProviderClass and an ExternalSecret"},
+		{"secret store for local storage", func(c *crv1.Pgcluster) {
+			c.Spec.BackrestSecretStore = crv1.BackrestSecretStoreSpec{ExternalSecret: "pgbackrest"}
+		}, "a secret store is only supported"},
+		{"zone anti-affinity", func(c *crv1.Pgcluster) {
+			c.Spec.ZoneAntiAffinity = crv1.PodAntiAffinityRequired
+		}, ""},
+		{"invalid zone anti-affinity", func(c *crv1.Pgcluster) {
+			c.Spec.ZoneAntiAffinity = "sometimes"
+		}, "invalid zone anti-affinity"},
+		{"topology spread constraint without a key", func(c *crv1.Pgcluster) {
+			c.Spec.TopologySpreadConstraints = []v1.TopologySpreadConstraint{
+				{MaxSkew: 1, WhenUnsatisfiable: v1.DoNotSchedule}}
+		}, "requires a topologyKey"},
+		{"custom metadata", func(c *crv1.Pgcluster) {
+			c.Spec.Metadata = crv1.MetadataSpec{
+				Labels:    map[string]string{"cost-center": "cc-42"},
+				PgBouncer: crv1.ObjectMetadata{Annotations: map[string]string{"sidecar.istio.io/inject": "true"}},
+			}
+		}, ""},
+		{"invalid label value", func(c *crv1.Pgcluster) {
+			c.Spec.Metadata.Postgres.Labels = map[string]string{"team": "db admins"}
+		}, "invalid value \"db admins\" of postgres label"},
+		{"reserved annotation", func(c *crv1.Pgcluster) {
+			c.Spec.Metadata.Annotations = map[string]string{"crunchydata.com/paused": "true"}
+		}, "is reserved for the Operator"},
+		{"instance sidecar", func(c *crv1.Pgcluster) {
+			c.Spec.InstancePod = crv1.InstancePodSpec{
+				Containers:   []v1.Container{{Name: "log-shipper", Image: "fluent/fluent-bit"}},
+				Volumes:      []v1.Volume{{Name: "shipper-config"}},
+				VolumeMounts: []v1.VolumeMount{{Name: "shipper-config", MountPath: "/etc/shipper"}},
+			}
+		}, ""},
+		{"reserved instance container", func(c *crv1.Pgcluster) {
+			c.Spec.InstancePod.Containers = []v1.Container{{Name: "collect", Image: "exporter"}}
+		}, "instance container \"collect\" is reserved"},
+		{"duplicate instance container", func(c *crv1.Pgcluster) {
+			c.Spec.InstancePod.InitContainers = []v1.Container{{Name: "prepare", Image: "busybox"}}
+			c.Spec.InstancePod.Containers = []v1.Container{{Name: "prepare", Image: "busybox"}}
+		}, "is declared more than once"},
+		{"storage autogrow", func(c *crv1.Pgcluster) {
+			c.Spec.StorageAutogrow = crv1.StorageAutogrowSpec{Enabled: true, MaxSize: "100Gi"}
+		}, ""},
+		{"storage autogrow without maximum", func(c *crv1.Pgcluster) {
+			c.Spec.StorageAutogrow.Enabled = true
+		}, "growing the volumes requires a maximum size"},
+		{"WAL storage", func(c *crv1.Pgcluster) {
+			c.Spec.WALStorage = crv1.PgStorageSpec{StorageType: "dynamic", Size: "5Gi"}
+		}, ""},
+		{"WAL storage in an emptyDir", func(c *crv1.Pgcluster) {
+			c.Spec.WALStorage = crv1.PgStorageSpec{StorageType: "emptydir"}
+		}, "invalid WAL storage type"},
+		{"WAL storage size", func(c *crv1.Pgcluster) {
+			c.Spec.WALStorage = crv1.PgStorageSpec{StorageType: "create", Size: "0"}
+		}, "invalid WAL storage size"},
+		{"tablespace", func(c *crv1.Pgcluster) {
+			c.Spec.Tablespaces = []crv1.TablespaceSpec{
+				{Name: "fast", Storage: crv1.PgStorageSpec{StorageType: "dynamic", Size: "5Gi"}},
+			}
+		}, ""},
+		{"tablespace name", func(c *crv1.Pgcluster) {
+			c.Spec.Tablespaces = []crv1.TablespaceSpec{
+				{Name: "Fast_SSD", Storage: crv1.PgStorageSpec{StorageType: "dynamic", Size: "5Gi"}},
+			}
+		}, "invalid tablespace name"},
+		{"duplicate tablespace", func(c *crv1.Pgcluster) {
+			c.Spec.TablespaceMounts = map[string]crv1.PgStorageSpec{
+				"fast": {StorageType: "dynamic", Size: "5Gi"},
+			}
+			c.Spec.Tablespaces = []crv1.TablespaceSpec{
+				{Name: "fast", Storage: crv1.PgStorageSpec{StorageType: "dynamic", Size: "5Gi"}},
+			}
+		}, "duplicate tablespace"},
+		{"tablespace storage type", func(c *crv1.Pgcluster) {
+			c.Spec.Tablespaces = []crv1.TablespaceSpec{
+				{Name: "fast", Storage: crv1.PgStorageSpec{StorageType: "emptydir"}},
+			}
+		}, "invalid storage type"},
+		{"replicas from snapshots", func(c *crv1.Pgcluster) {
+			c.Spec.VolumeSnapshots = crv1.VolumeSnapshotSpec{ClassName: "csi-snapclass", Replicas: true}
+		}, ""},
+		{"replicas from snapshots without a class", func(c *crv1.Pgcluster) {
+			c.Spec.VolumeSnapshots = crv1.VolumeSnapshotSpec{Replicas: true}
+		}, "requires a VolumeSnapshotClass"},
+		{"scheduled snapshots", func(c *crv1.Pgcluster) {
+			c.Spec.VolumeSnapshots = crv1.VolumeSnapshotSpec{ClassName: "csi-snapclass",
+				Schedule: "0 */6 * * *", Retention: 4}
+		}, ""},
+		{"scheduled snapshots without a class", func(c *crv1.Pgcluster) {
+			c.Spec.VolumeSnapshots = crv1.VolumeSnapshotSpec{Schedule: "0 */6 * * *"}
+		}, "requires a VolumeSnapshotClass"},
+		{"snapshot schedule", func(c *crv1.Pgcluster) {
+			c.Spec.VolumeSnapshots = crv1.VolumeSnapshotSpec{ClassName: "csi-snapclass", Schedule: "hourly"}
+		}, "not a valid snapshot schedule"},
+		{"snapshot retention", func(c *crv1.Pgcluster) {
+			c.Spec.VolumeSnapshots = crv1.VolumeSnapshotSpec{ClassName: "csi-snapclass",
+				Schedule: "0 */6 * * *", Retention: -1}
+		}, "not a valid snapshot retention"},
+		{"hibernation schedule", func(c *crv1.Pgcluster) {
+			c.Spec.HibernationSchedule = crv1.HibernationScheduleSpec{Shutdown: "0 20 * * 1-5",
+				Startup: "0 7 * * 1-5", TimeZone: "Europe/Paris"}
+		}, ""},
+		{"hibernation shutdown schedule", func(c *crv1.Pgcluster) {
+			c.Spec.HibernationSchedule = crv1.HibernationScheduleSpec{Shutdown: "nightly"}
+		}, "not a valid shutdown schedule"},
+		{"hibernation schedules alike", func(c *crv1.Pgcluster) {
+			c.Spec.HibernationSchedule = crv1.HibernationScheduleSpec{Shutdown: "0 20 * * *",
+				Startup: "0 20 * * *"}
+		}, "cannot both be"},
+		{"hibernation time zone", func(c *crv1.Pgcluster) {
+			c.Spec.HibernationSchedule = crv1.HibernationScheduleSpec{Shutdown: "0 20 * * *",
+				TimeZone: "Europe/Atlantis"}
+		}, "invalid hibernation time zone"},
+		{"autoscale", func(c *crv1.Pgcluster) {
+			c.Spec.Autoscale = crv1.AutoscaleSpec{Enabled: true, Metric: crv1.AutoscaleMetricCPU,
+				MinReplicas: 1, MaxReplicas: 3}
+		}, ""},
+		{"autoscale metric", func(c *crv1.Pgcluster) {
+			c.Spec.Autoscale = crv1.AutoscaleSpec{Enabled: true, Metric: "memory"}
+		}, "invalid autoscale metric"},
+		{"autoscale negative", func(c *crv1.Pgcluster) {
+			c.Spec.Autoscale = crv1.AutoscaleSpec{Enabled: true, CooldownSeconds: -30}
+		}, "invalid autoscale cooldown seconds"},
+		{"autoscale bounds", func(c *crv1.Pgcluster) {
+			c.Spec.Autoscale = crv1.AutoscaleSpec{Enabled: true, MinReplicas: 3, MaxReplicas: 2}
+		}, "greater than the maximum"},
+		{"resource recommendation", func(c *crv1.Pgcluster) {
+			c.Spec.ResourceRecommendation = crv1.ResourceRecommendationSpec{
+				Mode: crv1.ResourceRecommendationAuto, ApplySchedule: "0 3 * * 0"}
+		}, ""},
+		{"resource recommendation mode", func(c *crv1.Pgcluster) {
+			c.Spec.ResourceRecommendation = crv1.ResourceRecommendationSpec{Mode: "apply"}
+		}, "invalid resource recommendation mode"},
+		{"resource recommendation without schedule", func(c *crv1.Pgcluster) {
+			c.Spec.ResourceRecommendation = crv1.ResourceRecommendationSpec{
+				Mode: crv1.ResourceRecommendationAuto}
+		}, "requires an apply schedule"},
+		{"resource recommendation schedule", func(c *crv1.Pgcluster) {
+			c.Spec.ResourceRecommendation = crv1.ResourceRecommendationSpec{
+				Mode: crv1.ResourceRecommendationAuto, ApplySchedule: "weekly"}
+		}, "not a valid apply schedule"},
+		{"tuning", func(c *crv1.Pgcluster) {
+			c.Spec.Tuning = crv1.PostgresTuningAuto
+			c.Spec.ContainerResources.LimitsMemory = "2Gi"
+		}, ""},
+		{"tuning mode", func(c *crv1.Pgcluster) {
+			c.Spec.Tuning = "pgtune"
+		}, "invalid tuning"},
+		{"tuning without memory", func(c *crv1.Pgcluster) {
+			c.Spec.Tuning = crv1.PostgresTuningAuto
+			c.Spec.ContainerResources = crv1.PgContainerResources{}
+		}, "tuning requires a memory limit"},
+		{"maintenance window", func(c *crv1.Pgcluster) {
+			c.Spec.MaintenanceWindow = crv1.MaintenanceWindowSpec{Days: []string{"Sat", "sun"},
+				StartTime: "22:00", EndTime: "02:00", TimeZone: "Europe/Paris"}
+		}, ""},
+		{"maintenance window without end", func(c *crv1.Pgcluster) {
+			c.Spec.MaintenanceWindow = crv1.MaintenanceWindowSpec{StartTime: "22:00"}
+		}, "requires a start time and an end time"},
+		{"maintenance window time", func(c *crv1.Pgcluster) {
+			c.Spec.MaintenanceWindow = crv1.MaintenanceWindowSpec{StartTime: "10pm", EndTime: "02:00"}
+		}, "invalid maintenance window time"},
+		{"maintenance window day", func(c *crv1.Pgcluster) {
+			c.Spec.MaintenanceWindow = crv1.MaintenanceWindowSpec{Days: []string{"Saturday"},
+				StartTime: "22:00", EndTime: "02:00"}
+		}, "invalid maintenance window day"},
+		{"maintenance window time zone", func(c *crv1.Pgcluster) {
+			c.Spec.MaintenanceWindow = crv1.MaintenanceWindowSpec{StartTime: "22:00", EndTime: "02:00",
+				TimeZone: "Mars/Olympus"}
+		}, "invalid maintenance window time zone"},
+		{"failover health check", func(c *crv1.Pgcluster) {
+			c.Spec.FailoverHealthCheck = crv1.FailoverHealthCheckSpec{Enabled: true, FailureThreshold: 5}
+		}, ""},
+		{"failover health check threshold", func(c *crv1.Pgcluster) {
+			c.Spec.FailoverHealthCheck = crv1.FailoverHealthCheckSpec{Enabled: true, FailureThreshold: -1}
+		}, "invalid failover health check failure threshold"},
+		{"sync replication quorum", func(c *crv1.Pgcluster) {
+			enabled := true
+			c.Spec.SyncReplication = &enabled
+			c.Spec.SyncReplicationQuorum = 2
+		}, ""},
+		{"sync replication quorum without sync replication", func(c *crv1.Pgcluster) {
+			c.Spec.SyncReplicationQuorum = 2
+		}, "a synchronous replication quorum requires synchronous replication"},
+		{"max replica lag", func(c *crv1.Pgcluster) {
+			c.Spec.MaxReplicaLag = crv1.MaxReplicaLagSpec{Bytes: 16 * 1024 * 1024, Seconds: 60}
+		}, ""},
+		{"max replica lag seconds", func(c *crv1.Pgcluster) {
+			c.Spec.MaxReplicaLag = crv1.MaxReplicaLagSpec{Seconds: -1}
+		}, "invalid max replica lag of -1 seconds"},
+		{"read-only service", func(c *crv1.Pgcluster) {
+			c.Spec.ReadOnlyService = crv1.ReadOnlyServiceSpec{
+				Enabled:                       true,
+				ServiceSpec:                   crv1.ServiceSpec{ServiceType: "LoadBalancer"},
+				SessionAffinity:               "ClientIP",
+				SessionAffinityTimeoutSeconds: 600,
+			}
+		}, ""},
+		{"read-only service type", func(c *crv1.Pgcluster) {
+			c.Spec.ReadOnlyService.ServiceType = "ExternalName"
+		}, "invalid read-only service type"},
+		{"read-only service timeout", func(c *crv1.Pgcluster) {
+			c.Spec.ReadOnlyService.SessionAffinityTimeoutSeconds = 600
+		}, "requires a session affinity of ClientIP"},
+		{"services", func(c *crv1.Pgcluster) {
+			c.Spec.Services = crv1.ServicesSpec{
+				Primary: crv1.ServiceSpec{
+					ServiceType:              "LoadBalancer",
+					NodePort:                 30432,
+					LoadBalancerSourceRanges: []string{"10.0.0.0/8"},
+				},
+				PgBouncer: crv1.ServiceSpec{ServiceType: "NodePort", NodePort: 30433},
+			}
+		}, ""},
+		{"maintenance schedule does not parse", func(c *crv1.Pgcluster) {
+			c.Spec.Maintenance.Schedule = "0 3 * *"
+		}, `"0 3 * *" is not a valid maintenance schedule`},
+		{"maintenance reindex on PostgreSQL 12", func(c *crv1.Pgcluster) {
+			c.Spec.CCPImageTag = "centos7-12.2-4.3.0"
+			c.Spec.Maintenance = crv1.MaintenanceSpec{Schedule: "0 3 * * 0", Reindex: true}
+		}, ""},
+		{"maintenance reindex on PostgreSQL 11", func(c *crv1.Pgcluster) {
+			c.Spec.CCPImageTag = "centos7-11.7-4.3.0"
+			c.Spec.Maintenance = crv1.MaintenanceSpec{Schedule: "0 3 * * 0", Reindex: true}
+		}, "reindexing during maintenance requires PostgreSQL 12 or later, not 11"},
+		{"services node port", func(c *crv1.Pgcluster) {
+			c.Spec.Services.Replica.NodePort = 30432
+		}, "a replica service node port requires a service type of NodePort or LoadBalancer"},
+		{"services source range", func(c *crv1.Pgcluster) {
+			c.Spec.Services.PgBouncer = crv1.ServiceSpec{
+				ServiceType:              "LoadBalancer",
+				LoadBalancerSourceRanges: []string{"10.0.0.1"},
+			}
+		}, "invalid pgbouncer service load balancer source range"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cluster := validCluster()
+			test.modify(cluster)
+
+			reasons := ValidateCluster(cluster)
+
+			if test.reason == "" {
+				if len(reasons) != 0 {
+					t.Fatalf("expected the cluster to be valid, got %q", reasons)
+				}
+				return
+			}
+
+			if len(reasons) != 1 || !strings.Contains(reasons[0], test.reason) {
+				t.Fatalf("expected the cluster to be invalid for %q, got %q", test.reason, reasons)
+			}
+		})
+	}
+}
+
+func TestValidateClusterChange(t *testing.T) {
+	oldCluster := validCluster()
+	oldCluster.Spec.ContainerResources.RequestsCPU = "a lot"
+
+	// the cluster predates the check of the CPU request, so it can still be updated
+	newCluster := oldCluster.DeepCopy()
+	newCluster.Spec.Shutdown = true
+
+	if reasons := ValidateClusterChange(oldCluster, newCluster); len(reasons) != 0 {
+		t.Fatalf("expected the update to be allowed, got %q", reasons)
+	}
+
+	// the connection pooler is only changed while the cluster has none
+	newCluster.Spec.PgBouncer.Pooler = crv1.PoolerOdyssey
+	newCluster.Spec.PgBouncer.Image = "odyssey:1.1"
+
+	if reasons := ValidateClusterChange(oldCluster, newCluster); len(reasons) != 0 {
+		t.Fatalf("expected the update to be allowed, got %q", reasons)
+	}
+
+	oldCluster.Labels = map[string]string{config.LABEL_PGBOUNCER: "true"}
+
+	if reasons := ValidateClusterChange(oldCluster, newCluster); len(reasons) != 1 ||
+		!strings.Contains(reasons[0], "the connection pooler cannot be changed") {
+		t.Fatalf("expected the update to be refused for its connection pooler, got %q", reasons)
+	}
+
+	newCluster.Spec.PgBouncer = oldCluster.Spec.PgBouncer
+	newCluster.Spec.PrimaryStorage.Size = "big"
+
+	if reasons := ValidateClusterChange(oldCluster, newCluster); len(reasons) != 1 ||
+		!strings.Contains(reasons[0], "invalid primary storage size") {
+		t.Fatalf("expected the update to be refused for its storage size, got %q", reasons)
+	}
+
+	// the volumes can be expanded, but not shrunk
+	oldCluster.Spec.PrimaryStorage.Size = "10Gi"
+	newCluster.Spec.PrimaryStorage.Size = "20Gi"
+
+	if reasons := ValidateClusterChange(oldCluster, newCluster); len(reasons) != 0 {
+		t.Fatalf("expected the update to be allowed, got %q", reasons)
+	}
+
+	newCluster.Spec.PrimaryStorage.Size = "5Gi"
+
+	if reasons := ValidateClusterChange(oldCluster, newCluster); len(reasons) != 1 ||
+		!strings.Contains(reasons[0], "the primary storage size cannot be decreased") {
+		t.Fatalf("expected the update to be refused for shrinking its storage, got %q", reasons)
+	}
+
+	// the WAL is only put on a volume of its own when the data directory is created
+	newCluster.Spec.PrimaryStorage.Size = "20Gi"
+	newCluster.Spec.WALStorage = crv1.PgStorageSpec{StorageType: "dynamic", Size: "5Gi"}
+
+	if reasons := ValidateClusterChange(oldCluster, newCluster); len(reasons) != 1 ||
+		!strings.Contains(reasons[0], "the WAL storage type cannot be changed") {
+		t.Fatalf("expected the update to be refused for adding WAL storage, got %q", reasons)
+	}
+
+	// the volumes of a tablespace stay with the instances once it exists
+	oldCluster = validCluster()
+	oldCluster.Spec.Tablespaces = []crv1.TablespaceSpec{
+		{Name: "fast", Storage: crv1.PgStorageSpec{StorageType: "dynamic", Size: "5Gi"}},
+	}
+	newCluster = validCluster()
+
+	if reasons := ValidateClusterChange(oldCluster, newCluster); len(reasons) != 1 ||
+		!strings.Contains(reasons[0], `tablespace "fast" cannot be removed`) {
+		t.Fatalf("expected the update to be refused for removing a tablespace, got %q", reasons)
+	}
+}
@@ -0,0 +1,154 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/config"
+	"github.com/crunchydata/postgres-operator/kubeapi"
+	"github.com/crunchydata/postgres-operator/operator"
+	"github.com/crunchydata/postgres-operator/operator/backrest"
+	log "github.com/sirupsen/logrus"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	// defaultBackrestVerificationIntervalMinutes is how often the repository of a cluster is
+	// verified if no interval is set
+	defaultBackrestVerificationIntervalMinutes = 24 * 60
+
+	// eventReasonBackrestVerifyFailed is the reason of the Warning events that are recorded when
+	// the verification of the repository of a cluster failed
+	eventReasonBackrestVerifyFailed = "BackrestVerifyFailed"
+)
+
+// backrestVerifying holds the clusters whose verification is being started, as replacing a
+// previous verification waits for its Job to be deleted
+var backrestVerifying sync.Map
+
+// ValidateBackrestVerification returns an error if the verification of the pgBackRest repository
+// of a cluster is enabled while the version of pgBackRest in its images cannot verify it
+func ValidateBackrestVerification(cluster *crv1.Pgcluster) error {
+	if !cluster.Spec.BackrestVerification.Enabled {
+		return nil
+	}
+
+	return operator.RequireBackrestVersion("pgBackRest repository verification",
+		operator.BackrestVersionVerify)
+}
+
+// ReconcileBackrestVerification starts a verification of the pgBackRest repository of the cluster
+// once the interval has passed since it was last verified, unless a verification is still running.
+// The outcome is recorded by RecordBackrestVerification when the Job of the verification completes
+func ReconcileBackrestVerification(clientset *kubernetes.Clientset, restclient *rest.RESTClient,
+	cluster *crv1.Pgcluster) error {
+	if !cluster.Spec.BackrestVerification.Enabled ||
+		cluster.Status.State != crv1.PgclusterStateInitialized ||
+		cluster.Labels[config.LABEL_BACKREST] != "true" {
+		return nil
+	}
+
+	// a cluster that was enabled before BackrestVersion was lowered is left unverified
+	if err := ValidateBackrestVerification(cluster); err != nil {
+		return err
+	}
+
+	if !isBackrestVerificationDue(cluster.Spec.BackrestVerification,
+		cluster.Status.BackrestVerification, time.Now()) {
+		return nil
+	}
+
+	jobName := fmt.Sprintf(backrest.BackrestVerifyJobFormat, cluster.Name)
+	if job, found := kubeapi.GetJob(clientset, jobName, cluster.Namespace); found && job.Status.Active > 0 {
+		return nil
+	}
+
+	key := cluster.Namespace + "/" + cluster.Name
+	if _, running := backrestVerifying.LoadOrStore(key, true); running {
+		return nil
+	}
+
+	go func() {
+		defer backrestVerifying.Delete(key)
+
+		log.Debugf("verifying pgBackRest repository of cluster %s", cluster.Name)
+
+		if err := backrest.CreateVerification(clientset, restclient, cluster); err != nil {
+			log.Errorf("could not verify pgBackRest repository of cluster %s: %s", cluster.Name, err)
+		}
+	}()
+
+	return nil
+}
+
+// RecordBackrestVerification records the outcome of a verification of the pgBackRest repository
+// of a cluster that completed at the time given in its status and conditions, along with a
+// Warning event if it failed, i.e. if verifyErr is not nil. A verification that has already been
+// recorded is not recorded again
+func RecordBackrestVerification(clientset *kubernetes.Clientset, restclient *rest.RESTClient,
+	clusterName, namespace string, completed time.Time, verifyErr error) error {
+	cluster := crv1.Pgcluster{}
+	if _, err := kubeapi.Getpgcluster(restclient, &cluster, clusterName, namespace); err != nil {
+		return err
+	}
+
+	status := crv1.BackrestVerificationStatus{
+		LastVerified: completed.UTC().Format(time.RFC3339),
+		Succeeded:    verifyErr == nil,
+	}
+
+	if status.LastVerified == cluster.Status.BackrestVerification.LastVerified {
+		return nil
+	}
+
+	if verifyErr != nil {
+		status.Message = verifyErr.Error()
+
+		operator.RecordWarningEvent(clientset, &cluster, eventReasonBackrestVerifyFailed,
+			verifyErr.Error())
+	}
+
+	if err := kubeapi.Patchpgcluster(restclient, &cluster, namespace, func(cluster *crv1.Pgcluster) {
+		cluster.Status.BackrestVerification = status
+	}); err != nil {
+		return err
+	}
+
+	return ReconcileConditions(clientset, restclient, &cluster)
+}
+
+// isBackrestVerificationDue determines whether the interval has passed since the repository was
+// last verified. A repository that has not been verified yet, or whose last verification cannot be
+// told, is verified right away
+func isBackrestVerificationDue(verification crv1.BackrestVerificationSpec,
+	status crv1.BackrestVerificationStatus, now time.Time) bool {
+	interval := verification.IntervalMinutes
+	if interval <= 0 {
+		interval = defaultBackrestVerificationIntervalMinutes
+	}
+
+	last, err := time.Parse(time.RFC3339, status.LastVerified)
+	if err != nil {
+		return true
+	}
+
+	return !now.Before(last.Add(time.Duration(interval) * time.Minute))
+}
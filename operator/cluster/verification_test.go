@@ -0,0 +1,116 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"testing"
+	"time"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/operator"
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestValidateBackrestVerification(t *testing.T) {
+	cluster := &crv1.Pgcluster{}
+
+	if err := ValidateBackrestVerification(cluster); err != nil {
+		t.Errorf("expected no error when disabled, got %s", err)
+	}
+
+	cluster.Spec.BackrestVerification.Enabled = true
+
+	if err := ValidateBackrestVerification(cluster); err == nil {
+		t.Error("expected an error for the pgBackRest version of the images of this release")
+	}
+
+	operator.Pgo.Cluster.BackrestVersion = operator.BackrestVersionVerify
+	defer func() { operator.Pgo.Cluster.BackrestVersion = "" }()
+
+	if err := ValidateBackrestVerification(cluster); err != nil {
+		t.Errorf("expected no error for pgBackRest %s, got %s", operator.BackrestVersionVerify, err)
+	}
+}
+
+func TestIsBackrestVerificationDue(t *testing.T) {
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	for _, test := range []struct {
+		interval     int
+		lastVerified string
+		expected     bool
+	}{
+		{0, "", true},
+		{0, "not a time", true},
+		{0, "2020-06-01T00:00:00Z", false},
+		{0, "2020-05-31T12:00:00Z", true},
+		{60, "2020-06-01T11:30:00Z", false},
+		{60, "2020-06-01T10:30:00Z", true},
+	} {
+		verification := crv1.BackrestVerificationSpec{Enabled: true, IntervalMinutes: test.interval}
+		status := crv1.BackrestVerificationStatus{LastVerified: test.lastVerified}
+
+		if due := isBackrestVerificationDue(verification, status, now); due != test.expected {
+			t.Errorf("interval %d, last verified %q: expected due: %v, got %v", test.interval,
+				test.lastVerified, test.expected, due)
+		}
+	}
+}
+
+func TestEvaluateConditionsBackrestVerification(t *testing.T) {
+	healthy := clusterObservation{primaryReady: true, backrestEnabled: true, repoReady: true}
+
+	t.Run("not enabled", func(t *testing.T) {
+		cluster := &crv1.Pgcluster{}
+		cluster.Status.State = crv1.PgclusterStateInitialized
+
+		conditions := evaluateConditions(cluster, healthy)
+
+		if status, _ := conditionStatus(conditions, crv1.PgclusterConditionLastBackupVerified); status != "" {
+			t.Errorf("expected no LastBackupVerified condition, got %s", status)
+		}
+	})
+
+	for _, test := range []struct {
+		status   crv1.BackrestVerificationStatus
+		expected v1.ConditionStatus
+		reason   string
+	}{
+		{crv1.BackrestVerificationStatus{}, v1.ConditionFalse, "NotVerified"},
+		{crv1.BackrestVerificationStatus{LastVerified: "2020-06-01T12:00:00Z", Succeeded: true},
+			v1.ConditionTrue, "Verified"},
+		{crv1.BackrestVerificationStatus{LastVerified: "2020-06-01T12:00:00Z", Message: "failed"},
+			v1.ConditionFalse, "VerificationFailed"},
+	} {
+		t.Run(test.reason, func(t *testing.T) {
+			cluster := &crv1.Pgcluster{}
+			cluster.Spec.BackrestVerification.Enabled = true
+			cluster.Status.State = crv1.PgclusterStateInitialized
+			cluster.Status.BackrestVerification = test.status
+
+			conditions := evaluateConditions(cluster, healthy)
+
+			status, reason := conditionStatus(conditions, crv1.PgclusterConditionLastBackupVerified)
+			if status != test.expected || reason != test.reason {
+				t.Errorf("expected LastBackupVerified to be %s for reason %q, got %s for reason %q",
+					test.expected, test.reason, status, reason)
+			}
+			if _, reason := conditionStatus(conditions, crv1.PgclusterConditionDegraded); reason != "Healthy" {
+				t.Errorf("expected the verification not to degrade the cluster, got %q", reason)
+			}
+		})
+	}
+}
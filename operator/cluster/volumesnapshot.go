@@ -0,0 +1,589 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"reflect"
+	"sort"
+	"time"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/config"
+	"github.com/crunchydata/postgres-operator/kubeapi"
+	"github.com/crunchydata/postgres-operator/operator"
+	"github.com/crunchydata/postgres-operator/operator/pvc"
+	"github.com/crunchydata/postgres-operator/util"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	// eventReasonVolumeSnapshotFallback is the reason of the Warning events that are recorded
+	// when the volume of a new instance cannot be created from a snapshot, and is restored from
+	// the pgBackRest repository instead
+	eventReasonVolumeSnapshotFallback = "VolumeSnapshotFallback"
+
+	// eventReasonVolumeSnapshotFailed is the reason of the Warning events that are recorded when
+	// a scheduled snapshot of the volume of the primary cannot be taken
+	eventReasonVolumeSnapshotFailed = "VolumeSnapshotFailed"
+
+	// snapshotDataInitContainerName is the name of the init container that moves the data
+	// directory of a volume that was created from a snapshot to where the instance expects it
+	snapshotDataInitContainerName = "snapshot-data"
+
+	// volumeSnapshotTimeout is how many seconds a snapshot is waited on to be taken before it is
+	// given up on
+	volumeSnapshotTimeout = 120
+)
+
+// snapshotDataScript moves the data directory of the instance that a snapshot was taken of to
+// the data directory of the instance whose volume was created from it, unless the instance
+// already has one. PostgreSQL was running when the snapshot was taken, so its postmaster.pid
+// goes along with it and is removed
+const snapshotDataScript = `if [[ -d "/pgdata/${SNAPSHOT_DATA_DIRECTORY}" && ! -e "/pgdata/${DATA_DIRECTORY}" ]]; then
+  echo "moving ${SNAPSHOT_DATA_DIRECTORY} of the snapshot to ${DATA_DIRECTORY}"
+  mv "/pgdata/${SNAPSHOT_DATA_DIRECTORY}" "/pgdata/${DATA_DIRECTORY}"
+  rm -f "/pgdata/${DATA_DIRECTORY}/postmaster.pid"
+fi
+`
+
+// ValidateVolumeSnapshots returns an error if the snapshots of a cluster cannot be taken as they
+// are specified to, i.e. without a VolumeSnapshotClass to take them with, or on a schedule that
+// does not parse
+func ValidateVolumeSnapshots(cluster *crv1.Pgcluster) error {
+	spec := cluster.Spec.VolumeSnapshots
+
+	if spec.Replicas && spec.ClassName == "" {
+		return errors.New("creating the replicas from snapshots requires a VolumeSnapshotClass")
+	}
+
+	if spec.Schedule == "" {
+		return nil
+	}
+
+	if spec.ClassName == "" {
+		return errors.New("scheduling snapshots requires a VolumeSnapshotClass")
+	}
+
+	if _, err := util.ScheduleParser.Parse(spec.Schedule); err != nil {
+		return fmt.Errorf("%q is not a valid snapshot schedule: %s", spec.Schedule, err)
+	}
+
+	if spec.Retention < 0 {
+		return fmt.Errorf("%d is not a valid snapshot retention, it cannot be negative", spec.Retention)
+	}
+
+	return nil
+}
+
+// ReconcileVolumeSnapshotSchedule takes a snapshot of the volume of the primary of a cluster once
+// its schedule comes up, removes the scheduled snapshots beyond its retention, and records those
+// that are kept in the status of the cluster. The snapshot is not waited on, as its progress is
+// recorded the next time the schedule is reconciled
+func ReconcileVolumeSnapshotSchedule(clientset *kubernetes.Clientset, restclient *rest.RESTClient,
+	cluster *crv1.Pgcluster) error {
+	if cluster.Status.State != crv1.PgclusterStateInitialized {
+		return nil
+	}
+
+	spec := cluster.Spec.VolumeSnapshots
+	previous := cluster.Status.VolumeSnapshots
+	status := crv1.VolumeSnapshotScheduleStatus{
+		LastScheduleTime: previous.LastScheduleTime,
+		Message:          previous.Message,
+	}
+
+	now := time.Now()
+	due, err := isScheduleDue(spec.Schedule, previous.LastScheduleTime,
+		cluster.CreationTimestamp.Time, now)
+	if err != nil {
+		return err
+	}
+
+	if due {
+		status.LastScheduleTime = now.UTC().Format(time.RFC3339)
+		status.Message = ""
+
+		if err := takeScheduledVolumeSnapshot(clientset, cluster, now); err != nil {
+			status.Message = err.Error()
+
+			log.Warnf("could not take scheduled snapshot of cluster %s: %s", cluster.Name, err)
+			operator.RecordWarningEvent(clientset, cluster, eventReasonVolumeSnapshotFailed,
+				fmt.Sprintf("could not take scheduled snapshot: %s", err))
+		}
+	}
+
+	selector := fmt.Sprintf("%s=%s,%s=%s", config.LABEL_PG_CLUSTER, cluster.Name,
+		config.LABEL_PGO_SCHEDULED_SNAPSHOT, config.LABEL_TRUE)
+
+	snapshots, err := kubeapi.GetVolumeSnapshots(clientset, selector, cluster.Namespace)
+	if err != nil {
+		return err
+	}
+
+	kept, expired := getExpiredVolumeSnapshots(snapshots.Items, spec.GetRetention())
+
+	for _, snapshot := range expired {
+		log.Debugf("removing scheduled snapshot %s of cluster %s", snapshot.Name, cluster.Name)
+
+		if err := kubeapi.DeleteVolumeSnapshot(clientset, snapshot.Name,
+			cluster.Namespace); err != nil && !kerrors.IsNotFound(err) {
+			return err
+		}
+	}
+
+	// a snapshot that cannot be taken is reported once, rather than every time it is checked
+	failed := map[string]string{}
+	for _, snapshot := range previous.Snapshots {
+		failed[snapshot.Name] = snapshot.Error
+	}
+
+	for _, snapshot := range kept {
+		snapshotStatus := getScheduledVolumeSnapshotStatus(snapshot)
+
+		if snapshotStatus.Error != "" && snapshotStatus.Error != failed[snapshot.Name] {
+			operator.RecordWarningEvent(clientset, cluster, eventReasonVolumeSnapshotFailed,
+				fmt.Sprintf("scheduled snapshot %s cannot be taken: %s", snapshot.Name,
+					snapshotStatus.Error))
+		}
+
+		status.Snapshots = append(status.Snapshots, snapshotStatus)
+	}
+
+	if reflect.DeepEqual(status, previous) {
+		return nil
+	}
+
+	return kubeapi.Patchpgcluster(restclient, cluster, cluster.Namespace, func(cluster *crv1.Pgcluster) {
+		cluster.Status.VolumeSnapshots = status
+	})
+}
+
+// isScheduleDue returns whether a schedule of a cluster, e.g. of its snapshots, is due at the
+// given time, i.e. whether it has come up since it was last acted on, or since the cluster was
+// created if it has not been
+func isScheduleDue(schedule, lastScheduleTime string, created, now time.Time) (bool, error) {
+	sched, err := util.ScheduleParser.Parse(schedule)
+	if err != nil {
+		return false, err
+	}
+
+	last := created
+	if lastScheduleTime != "" {
+		if last, err = time.Parse(time.RFC3339, lastScheduleTime); err != nil {
+			return false, err
+		}
+	}
+
+	return !sched.Next(last).After(now), nil
+}
+
+// takeScheduledVolumeSnapshot takes a snapshot of the volume of the primary of a cluster that is
+// named after the time it is scheduled at. The snapshot is annotated with the data directory of
+// the primary, for the volume of a clone to be created from it later on
+func takeScheduledVolumeSnapshot(clientset *kubernetes.Clientset, cluster *crv1.Pgcluster,
+	now time.Time) error {
+	className := cluster.Spec.VolumeSnapshots.ClassName
+
+	if reason := getVolumeSnapshotUnavailableReason(cluster, className,
+		cluster.Spec.PrimaryStorage); reason != "" {
+		return errors.New(reason)
+	}
+
+	if found, err := kubeapi.GetVolumeSnapshotClass(clientset, className); !found {
+		return fmt.Errorf("VolumeSnapshotClass %s is not available: %v", className, err)
+	}
+
+	pod, err := util.GetPrimaryPod(clientset, cluster)
+	if err != nil {
+		return err
+	}
+
+	claimName, dataDirectory := getDataVolume(pod)
+	if claimName == "" || dataDirectory == "" {
+		return fmt.Errorf("primary %s does not keep its data on a volume", pod.Name)
+	}
+
+	snapshot := &kubeapi.VolumeSnapshot{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name: fmt.Sprintf("%s-%s", cluster.Name, now.UTC().Format("20060102150405")),
+			Labels: map[string]string{
+				config.LABEL_VENDOR:                 config.LABEL_CRUNCHY,
+				config.LABEL_PG_CLUSTER:             cluster.Name,
+				config.LABEL_PGO_SCHEDULED_SNAPSHOT: config.LABEL_TRUE,
+			},
+			Annotations: map[string]string{
+				config.ANNOTATION_SNAPSHOT_DATA_DIRECTORY: dataDirectory,
+			},
+		},
+		Spec: kubeapi.VolumeSnapshotSpec{
+			Source:                  kubeapi.VolumeSnapshotSource{PersistentVolumeClaimName: claimName},
+			VolumeSnapshotClassName: className,
+		},
+	}
+
+	log.Debugf("taking scheduled snapshot %s of volume %s", snapshot.Name, claimName)
+
+	return kubeapi.CreateVolumeSnapshot(clientset, snapshot, cluster.Namespace)
+}
+
+// getExpiredVolumeSnapshots sorts the scheduled snapshots of a cluster from the newest to the
+// oldest, and returns the number of them that are kept according to the retention along with
+// the older ones that are to be removed
+func getExpiredVolumeSnapshots(snapshots []kubeapi.VolumeSnapshot,
+	retention int) ([]kubeapi.VolumeSnapshot, []kubeapi.VolumeSnapshot) {
+	sorted := make([]kubeapi.VolumeSnapshot, len(snapshots))
+	copy(sorted, snapshots)
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[j].CreationTimestamp.Before(&sorted[i].CreationTimestamp)
+	})
+
+	if len(sorted) <= retention {
+		return sorted, nil
+	}
+
+	return sorted[:retention], sorted[retention:]
+}
+
+// getScheduledVolumeSnapshotStatus returns the state of a scheduled snapshot as it is recorded in
+// the status of the cluster
+func getScheduledVolumeSnapshotStatus(snapshot kubeapi.VolumeSnapshot) crv1.ScheduledVolumeSnapshotStatus {
+	status := crv1.ScheduledVolumeSnapshotStatus{
+		Name: snapshot.Name,
+		PVC:  snapshot.Spec.Source.PersistentVolumeClaimName,
+	}
+
+	if snapshot.Status == nil {
+		return status
+	}
+
+	status.ReadyToUse = snapshot.Status.ReadyToUse
+
+	if snapshot.Status.CreationTime != nil {
+		status.CreationTime = snapshot.Status.CreationTime.UTC().Format(time.RFC3339)
+	}
+	if snapshot.Status.RestoreSize != nil {
+		status.RestoreSize = snapshot.Status.RestoreSize.String()
+	}
+	if snapshot.Status.Error != nil {
+		status.Error = snapshot.Status.Error.Message
+	}
+
+	return status
+}
+
+// DeleteVolumeSnapshots removes the VolumeSnapshots that the volumes of a cluster were created
+// from. There are none to remove if the VolumeSnapshots are not served at all
+func DeleteVolumeSnapshots(clientset *kubernetes.Clientset, cluster *crv1.Pgcluster) error {
+	selector := fmt.Sprintf("%s=%s", config.LABEL_PG_CLUSTER, cluster.Name)
+
+	snapshots, err := kubeapi.GetVolumeSnapshots(clientset, selector, cluster.Namespace)
+	if kerrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, snapshot := range snapshots.Items {
+		if err := kubeapi.DeleteVolumeSnapshot(clientset, snapshot.Name,
+			cluster.Namespace); err != nil && !kerrors.IsNotFound(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// cloneFromSnapshot creates the volume of the primary of a cluster that is cloned from a
+// snapshot of the volume of the primary of the source, which is either a scheduled snapshot of
+// the source or one that is taken for the clone. The VolumeSnapshotClass of the cluster is used
+// to take it if it has one, and that of the source otherwise
+func cloneFromSnapshot(clientset *kubernetes.Clientset, cluster, source *crv1.Pgcluster) error {
+	if cluster.Spec.Clone.SnapshotName != "" {
+		return cloneFromScheduledSnapshot(clientset, cluster, source)
+	}
+
+	className := cluster.Spec.VolumeSnapshots.ClassName
+	if className == "" {
+		className = source.Spec.VolumeSnapshots.ClassName
+	}
+
+	if reason := getVolumeSnapshotUnavailableReason(source, className,
+		cluster.Spec.PrimaryStorage); reason != "" {
+		return errors.New(reason)
+	}
+
+	return createPVCFromSnapshot(clientset, source, className, cluster.Spec.PrimaryStorage,
+		cluster.Name, cluster.Name, cluster.Namespace)
+}
+
+// cloneFromScheduledSnapshot creates the volume of the primary of a cluster that is cloned from
+// the scheduled snapshot of the source that is named in its clone spec. The snapshot belongs to
+// the source, so it is kept even if the volume cannot be created from it
+func cloneFromScheduledSnapshot(clientset *kubernetes.Clientset, cluster, source *crv1.Pgcluster) error {
+	name := cluster.Spec.Clone.SnapshotName
+
+	snapshot, found, err := kubeapi.GetVolumeSnapshot(clientset, name, cluster.Namespace)
+	if !found {
+		return fmt.Errorf("snapshot %s is not available: %v", name, err)
+	}
+
+	if snapshot.Labels[config.LABEL_PG_CLUSTER] != source.Name ||
+		snapshot.Labels[config.LABEL_PGO_SCHEDULED_SNAPSHOT] != config.LABEL_TRUE {
+		return fmt.Errorf("snapshot %s is not a scheduled snapshot of cluster %s", name, source.Name)
+	}
+
+	if reason := getVolumeSnapshotUnavailableReason(source, snapshot.Spec.VolumeSnapshotClassName,
+		cluster.Spec.PrimaryStorage); reason != "" {
+		return errors.New(reason)
+	}
+
+	if snapshot.Status == nil || (snapshot.Status.CreationTime == nil && !snapshot.Status.ReadyToUse) {
+		return fmt.Errorf("snapshot %s has not been taken", name)
+	}
+
+	dataDirectory := snapshot.Annotations[config.ANNOTATION_SNAPSHOT_DATA_DIRECTORY]
+	if dataDirectory == "" {
+		return fmt.Errorf("snapshot %s does not record the data directory it contains", name)
+	}
+
+	return restoreVolumeSnapshot(clientset, snapshot, cluster.Spec.PrimaryStorage, cluster.Name,
+		cluster.Name, dataDirectory, cluster.Namespace)
+}
+
+// createReplicaPVCFromSnapshot creates the volume of a new replica from a snapshot of the volume
+// of the primary, if the cluster has its replicas created from snapshots. It returns whether it
+// did; if it could not, why is recorded in an event, and the replica is restored from the
+// pgBackRest repository instead
+func createReplicaPVCFromSnapshot(clientset *kubernetes.Clientset, cluster *crv1.Pgcluster,
+	replica *crv1.Pgreplica) bool {
+	if !cluster.Spec.VolumeSnapshots.Replicas {
+		return false
+	}
+
+	var err error
+	if reason := getVolumeSnapshotUnavailableReason(cluster, cluster.Spec.VolumeSnapshots.ClassName,
+		replica.Spec.ReplicaStorage); reason != "" {
+		err = errors.New(reason)
+	} else {
+		err = createPVCFromSnapshot(clientset, cluster, cluster.Spec.VolumeSnapshots.ClassName,
+			replica.Spec.ReplicaStorage, replica.Spec.Name, cluster.Name, replica.Namespace)
+	}
+
+	if err != nil {
+		log.Warnf("could not create the volume of replica %s from a snapshot: %s", replica.Spec.Name, err)
+		operator.RecordObjectWarningEvent(clientset, operator.PgreplicaReference(replica),
+			eventReasonVolumeSnapshotFallback, fmt.Sprintf("could not create volume %s from a snapshot "+
+				"of the primary, restoring it from the pgBackRest repository instead: %s",
+				replica.Spec.Name, err))
+		return false
+	}
+
+	log.Infof("created the volume of replica %s from a snapshot of the primary", replica.Spec.Name)
+
+	return true
+}
+
+// getVolumeSnapshotUnavailableReason returns why the volume of a new instance cannot be created
+// from a snapshot of the volume of the primary of a cluster, if it cannot. Only the data
+// directory is in the snapshot, so neither a WAL volume nor the volumes of tablespaces would be
+// brought along
+func getVolumeSnapshotUnavailableReason(cluster *crv1.Pgcluster, className string,
+	storage crv1.PgStorageSpec) string {
+	switch {
+	case className == "":
+		return "no VolumeSnapshotClass is set"
+	case storage.StorageType != "dynamic":
+		return "only a dynamically provisioned volume can be created from a snapshot"
+	case operator.HasWALStorage(cluster):
+		return fmt.Sprintf("cluster %s keeps its WAL on a volume of its own", cluster.Name)
+	case len(cluster.Spec.GetTablespaces()) > 0:
+		return fmt.Sprintf("cluster %s has tablespaces", cluster.Name)
+	}
+
+	return ""
+}
+
+// createPVCFromSnapshot takes a snapshot of the volume of the primary of a cluster, waits for it
+// to be taken, and creates a PVC that is restored from it. The snapshot is labeled with the
+// cluster that the PVC is for, so that it is removed along with it. If the PVC cannot be
+// created from the snapshot, the snapshot is removed again
+func createPVCFromSnapshot(clientset *kubernetes.Clientset, cluster *crv1.Pgcluster, className string,
+	storage crv1.PgStorageSpec, pvcName, clusterName, namespace string) error {
+	if found, err := kubeapi.GetVolumeSnapshotClass(clientset, className); !found {
+		return fmt.Errorf("VolumeSnapshotClass %s is not available: %v", className, err)
+	}
+
+	pod, err := util.GetPrimaryPod(clientset, cluster)
+	if err != nil {
+		return err
+	}
+
+	claimName, dataDirectory := getDataVolume(pod)
+	if claimName == "" || dataDirectory == "" {
+		return fmt.Errorf("primary %s does not keep its data on a volume", pod.Name)
+	}
+
+	snapshot := &kubeapi.VolumeSnapshot{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name: fmt.Sprintf("%s-%s", pvcName, util.RandStringBytesRmndr(4)),
+			Labels: map[string]string{
+				config.LABEL_VENDOR:     config.LABEL_CRUNCHY,
+				config.LABEL_PG_CLUSTER: clusterName,
+			},
+		},
+		Spec: kubeapi.VolumeSnapshotSpec{
+			Source:                  kubeapi.VolumeSnapshotSource{PersistentVolumeClaimName: claimName},
+			VolumeSnapshotClassName: className,
+		},
+	}
+
+	log.Debugf("taking snapshot %s of volume %s", snapshot.Name, claimName)
+
+	if err := kubeapi.CreateVolumeSnapshot(clientset, snapshot, namespace); err != nil {
+		return err
+	}
+
+	// the snapshot is of no use once the volume cannot be created from it
+	abandon := func(err error) error {
+		if err := kubeapi.DeleteVolumeSnapshot(clientset, snapshot.Name, namespace); err != nil {
+			log.Warnf("could not remove snapshot %s: %s", snapshot.Name, err)
+		}
+		return err
+	}
+
+	taken, err := waitForVolumeSnapshot(clientset, snapshot.Name, namespace, volumeSnapshotTimeout, 2)
+	if err != nil {
+		return abandon(err)
+	}
+
+	if err := restoreVolumeSnapshot(clientset, taken, storage, pvcName, clusterName, dataDirectory,
+		namespace); err != nil {
+		return abandon(err)
+	}
+
+	return nil
+}
+
+// restoreVolumeSnapshot creates a PVC from a snapshot that has been taken, annotated with the
+// data directory that the snapshot contains
+func restoreVolumeSnapshot(clientset *kubernetes.Clientset, snapshot *kubeapi.VolumeSnapshot,
+	storage crv1.PgStorageSpec, pvcName, clusterName, dataDirectory, namespace string) error {
+	// a volume that is smaller than the snapshot would never be provisioned
+	if size, err := resource.ParseQuantity(storage.Size); err == nil &&
+		snapshot.Status.RestoreSize != nil && size.Cmp(*snapshot.Status.RestoreSize) < 0 {
+		return fmt.Errorf("the size of %s is less than the size %s of snapshot %s",
+			storage.Size, snapshot.Status.RestoreSize.String(), snapshot.Name)
+	}
+
+	return pvc.CreateFromSnapshot(clientset, pvcName, clusterName, &storage, snapshot.Name,
+		dataDirectory, namespace)
+}
+
+// waitForVolumeSnapshot waits for a VolumeSnapshot to be taken, i.e. for it to have a creation
+// time, which is as soon as a volume can be created from it; the volume is only provisioned once
+// the snapshot is ready to use. It stops waiting if the snapshot cannot be taken
+func waitForVolumeSnapshot(clientset *kubernetes.Clientset, name, namespace string,
+	timeoutSecs, periodSecs time.Duration) (*kubeapi.VolumeSnapshot, error) {
+	timeout := time.After(timeoutSecs * time.Second)
+	tick := time.Tick(periodSecs * time.Second)
+
+	for {
+		select {
+		case <-timeout:
+			return nil, fmt.Errorf("timed out waiting for snapshot %s to be taken", name)
+		case <-tick:
+			snapshot, found, _ := kubeapi.GetVolumeSnapshot(clientset, name, namespace)
+			if !found || snapshot.Status == nil {
+				continue
+			}
+			if snapshot.Status.Error != nil && snapshot.Status.Error.Message != "" {
+				return nil, fmt.Errorf("snapshot %s cannot be taken: %s", name,
+					snapshot.Status.Error.Message)
+			}
+			if snapshot.Status.CreationTime != nil || snapshot.Status.ReadyToUse {
+				return snapshot, nil
+			}
+		}
+	}
+}
+
+// getDataVolume returns the name of the PVC that an instance Pod keeps its data on, and the
+// directory of the data on it, which is named after the instance
+func getDataVolume(pod *v1.Pod) (string, string) {
+	claimName, dataDirectory := "", ""
+
+	for _, volume := range pod.Spec.Volumes {
+		if volume.Name == "pgdata" && volume.PersistentVolumeClaim != nil {
+			claimName = volume.PersistentVolumeClaim.ClaimName
+		}
+	}
+
+	for _, container := range pod.Spec.Containers {
+		if container.Name != "database" {
+			continue
+		}
+
+		for _, env := range container.Env {
+			if env.Name == "PATRONI_POSTGRESQL_DATA_DIR" && env.Value != "" {
+				dataDirectory = path.Base(env.Value)
+			}
+		}
+	}
+
+	return claimName, dataDirectory
+}
+
+// addSnapshotDataInitContainer adds the init container that moves the data directory into place
+// to the Pod of an instance whose volume was created from a snapshot of another instance, whose
+// data directory is named after that other instance. The move is only done once, so the init
+// container is left be once it is done
+func addSnapshotDataInitContainer(clientset *kubernetes.Clientset, pvcName, dataDirectory,
+	namespace, image string, spec *v1.PodSpec) {
+	claim, found, _ := kubeapi.GetPVC(clientset, pvcName, namespace)
+	if !found {
+		return
+	}
+
+	snapshotDataDirectory := claim.Annotations[config.ANNOTATION_SNAPSHOT_DATA_DIRECTORY]
+	if snapshotDataDirectory == "" || snapshotDataDirectory == dataDirectory {
+		return
+	}
+
+	spec.InitContainers = append(spec.InitContainers, v1.Container{
+		Name:    snapshotDataInitContainerName,
+		Image:   image,
+		Command: []string{"bash", "-c", snapshotDataScript},
+		Env: []v1.EnvVar{
+			{Name: "SNAPSHOT_DATA_DIRECTORY", Value: snapshotDataDirectory},
+			{Name: "DATA_DIRECTORY", Value: dataDirectory},
+		},
+		VolumeMounts: []v1.VolumeMount{
+			{Name: "pgdata", MountPath: "/pgdata"},
+		},
+		ImagePullPolicy: v1.PullIfNotPresent,
+	})
+}
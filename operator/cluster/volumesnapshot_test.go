@@ -0,0 +1,155 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/kubeapi"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGetVolumeSnapshotUnavailableReason(t *testing.T) {
+	dynamic := crv1.PgStorageSpec{StorageType: "dynamic", Size: "10Gi"}
+
+	for _, test := range []struct {
+		name      string
+		modify    func(*crv1.Pgcluster)
+		className string
+		storage   crv1.PgStorageSpec
+		reason    string
+	}{
+		{"available", func(*crv1.Pgcluster) {}, "csi-snapclass", dynamic, ""},
+		{"without a class", func(*crv1.Pgcluster) {}, "", dynamic, "no VolumeSnapshotClass"},
+		{"existing volume", func(*crv1.Pgcluster) {}, "csi-snapclass",
+			crv1.PgStorageSpec{StorageType: "existing", Name: "data"}, "dynamically provisioned"},
+		{"WAL storage", func(c *crv1.Pgcluster) {
+			c.Spec.WALStorage = crv1.PgStorageSpec{StorageType: "dynamic", Size: "5Gi"}
+		}, "csi-snapclass", dynamic, "WAL on a volume of its own"},
+		{"tablespaces", func(c *crv1.Pgcluster) {
+			c.Spec.Tablespaces = []crv1.TablespaceSpec{{Name: "fast", Storage: dynamic}}
+		}, "csi-snapclass", dynamic, "has tablespaces"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			cluster := &crv1.Pgcluster{ObjectMeta: metav1.ObjectMeta{Name: "hacluster"}}
+			test.modify(cluster)
+
+			reason := getVolumeSnapshotUnavailableReason(cluster, test.className, test.storage)
+
+			if test.reason == "" && reason != "" {
+				t.Fatalf("expected a snapshot to be usable, got %q", reason)
+			}
+			if !strings.Contains(reason, test.reason) {
+				t.Fatalf("expected a reason containing %q, got %q", test.reason, reason)
+			}
+		})
+	}
+}
+
+func TestGetDataVolume(t *testing.T) {
+	pod := &v1.Pod{Spec: v1.PodSpec{
+		Volumes: []v1.Volume{
+			{Name: "pgwal-volume", VolumeSource: v1.VolumeSource{
+				PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{ClaimName: "hacluster-wal"}}},
+			{Name: "pgdata", VolumeSource: v1.VolumeSource{
+				PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{ClaimName: "hacluster-abcd"}}},
+		},
+		Containers: []v1.Container{
+			{Name: "database", Env: []v1.EnvVar{
+				{Name: "PATRONI_POSTGRESQL_DATA_DIR", Value: "/pgdata/hacluster-abcd"},
+			}},
+		},
+	}}
+
+	claimName, dataDirectory := getDataVolume(pod)
+
+	if claimName != "hacluster-abcd" || dataDirectory != "hacluster-abcd" {
+		t.Fatalf("expected the data volume of the instance, got %q and %q", claimName, dataDirectory)
+	}
+
+	// the data of an instance in an emptyDir cannot be snapshotted
+	pod.Spec.Volumes[1].PersistentVolumeClaim = nil
+
+	if claimName, _ := getDataVolume(pod); claimName != "" {
+		t.Fatalf("expected no data volume, got %q", claimName)
+	}
+}
+
+func TestIsScheduleDue(t *testing.T) {
+	created := time.Date(2020, 6, 1, 10, 30, 0, 0, time.UTC)
+
+	for _, test := range []struct {
+		name string
+		last string
+		now  time.Time
+		due  bool
+	}{
+		{"not yet", "", time.Date(2020, 6, 1, 11, 59, 0, 0, time.UTC), false},
+		{"first", "", time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC), true},
+		{"already taken", "2020-06-01T12:00:00Z", time.Date(2020, 6, 1, 13, 0, 0, 0, time.UTC), false},
+		{"next", "2020-06-01T12:00:00Z", time.Date(2020, 6, 1, 18, 0, 0, 0, time.UTC), true},
+		{"missed", "2020-06-01T12:00:00Z", time.Date(2020, 6, 3, 9, 0, 0, 0, time.UTC), true},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			due, err := isScheduleDue("0 */6 * * *", test.last, created, test.now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if due != test.due {
+				t.Fatalf("expected due: %v, got %v", test.due, due)
+			}
+		})
+	}
+
+	if _, err := isScheduleDue("hourly", "", created, created); err == nil {
+		t.Fatal("expected an invalid schedule to be reported")
+	}
+}
+
+func TestGetExpiredVolumeSnapshots(t *testing.T) {
+	snapshot := func(name string, hour int) kubeapi.VolumeSnapshot {
+		return kubeapi.VolumeSnapshot{ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			CreationTimestamp: metav1.NewTime(time.Date(2020, 6, 1, hour, 0, 0, 0, time.UTC)),
+		}}
+	}
+
+	snapshots := []kubeapi.VolumeSnapshot{
+		snapshot("hacluster-20200601060000", 6),
+		snapshot("hacluster-20200601180000", 18),
+		snapshot("hacluster-20200601000000", 0),
+		snapshot("hacluster-20200601120000", 12),
+	}
+
+	kept, expired := getExpiredVolumeSnapshots(snapshots, 2)
+
+	if len(kept) != 2 || kept[0].Name != "hacluster-20200601180000" ||
+		kept[1].Name != "hacluster-20200601120000" {
+		t.Fatalf("expected the two newest snapshots to be kept, got %v", kept)
+	}
+	if len(expired) != 2 || expired[0].Name != "hacluster-20200601060000" ||
+		expired[1].Name != "hacluster-20200601000000" {
+		t.Fatalf("expected the two oldest snapshots to expire, got %v", expired)
+	}
+
+	if kept, expired := getExpiredVolumeSnapshots(snapshots, 7); len(kept) != 4 || len(expired) != 0 {
+		t.Fatalf("expected all of the snapshots to be kept, got %d and %d", len(kept), len(expired))
+	}
+}
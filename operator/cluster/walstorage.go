@@ -0,0 +1,63 @@
+package cluster
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"fmt"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/kubeapi"
+	"github.com/crunchydata/postgres-operator/operator"
+	"github.com/crunchydata/postgres-operator/operator/pvc"
+	log "github.com/sirupsen/logrus"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ValidateWALStorage returns an error if the write-ahead log of the instances of a cluster cannot
+// be kept on the volumes it is specified to be. Each instance needs a PVC of its own that
+// outlives its Pod, so neither an emptyDir nor an existing PVC will do
+func ValidateWALStorage(cluster *crv1.Pgcluster) error {
+	switch storageType := cluster.Spec.WALStorage.StorageType; storageType {
+	case "", "create", "dynamic":
+		return nil
+	default:
+		return fmt.Errorf("invalid WAL storage type %q, must be \"create\" or \"dynamic\"", storageType)
+	}
+}
+
+// CreateWALPVC creates the PVC that the write-ahead log of an instance is kept on, if the
+// cluster has WAL storage and the PVC does not exist yet, e.g. from a previous instance of the
+// same name
+func CreateWALPVC(clientset *kubernetes.Clientset, cluster *crv1.Pgcluster, instanceName string) error {
+	walPVCName := operator.GetWALPVCName(cluster, instanceName)
+	if walPVCName == "" {
+		return nil
+	}
+
+	if _, found, _ := kubeapi.GetPVC(clientset, walPVCName, cluster.Namespace); found {
+		log.Debugf("wal pvc %s found, will NOT recreate", walPVCName)
+		return nil
+	}
+
+	if _, err := pvc.CreatePVC(clientset, &cluster.Spec.WALStorage, walPVCName,
+		cluster.Spec.ClusterName, cluster.Namespace); err != nil {
+		return err
+	}
+
+	log.Debugf("created wal pvc [%s]", walPVCName)
+
+	return nil
+}
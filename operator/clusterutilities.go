@@ -31,11 +31,21 @@ import (
 	log "github.com/sirupsen/logrus"
 	apps_v1 "k8s.io/api/apps/v1"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/client-go/kubernetes"
 )
 
+const (
+	// ephemeralStorageFloor is the minimum ephemeral storage limit for the
+	// containers of a cluster, which covers logs and other temporary files
+	ephemeralStorageFloor = "512Mi"
+	// ephemeralStorageFloorS3 is the additional ephemeral storage needed by
+	// pgBackRest when it pushes WAL to S3
+	ephemeralStorageFloorS3 = "1Gi"
+)
+
 // consolidate with cluster.affinityTemplateFields
 const AffinityInOperator = "In"
 const AFFINITY_NOTINOperator = "NotIn"
@@ -44,6 +54,28 @@ const AFFINITY_NOTINOperator = "NotIn"
 // cluster
 const PGHAConfigMapSuffix = "pgha-config"
 
+// TLSClientCASecretSuffix defines the suffix for the name of the Secret that
+// bundles all of the CAs the PostgreSQL server trusts when client certificate
+// authentication is set up
+const TLSClientCASecretSuffix = "client-ca"
+
+// the following constants define the names of the Secrets that the
+// certificates of a cluster are issued into, when they are requested from
+// cert-manager or generated by the Operator
+const (
+	// TLSCASecretFormat is the Secret with the CA that the Operator generates
+	// for a cluster, along with its key
+	TLSCASecretFormat = "%s-ca"
+	// TLSServerSecretFormat is the Secret with the keypair of the PostgreSQL
+	// server, along with the CA of the issuer
+	TLSServerSecretFormat = "%s-tls"
+	// TLSReplicationSecretFormat is the Secret with the client certificate of
+	// the replication user
+	TLSReplicationSecretFormat = "%s-replication-tls"
+	// TLSPgBouncerSecretFormat is the Secret with the keypair of pgBouncer
+	TLSPgBouncerSecretFormat = "%s-pgbouncer-tls"
+)
+
 // the following constants define the settings in the PGHA configMap that is created for each PG
 // cluster
 const (
@@ -56,8 +88,32 @@ const (
 	// backrest repo type without requiring container restarts (as would be required to update
 	// PGBACKREST_REPO_TYPE).
 	PGHAConfigReplicaBootstrapRepoTye = "replica-bootstrap-repo-type"
+	// PGHAConfigPatroniSetting is the custom configuration of Patroni that the crunchy-postgres-ha
+	// container reads from /pgconf. It is only set while the cluster is initialized, to bootstrap
+	// the primary with options of initdb, and takes the place of that of the custom configMap of
+	// the cluster until then
+	PGHAConfigPatroniSetting = "postgres-ha.yaml"
 )
 
+// StartupGateInitContainerName is the name of the init container that holds
+// back the start of PostgreSQL until the startup gate of a cluster is satisfied
+const StartupGateInitContainerName = "startup-gate"
+
+// startupGateScript polls the Kubernetes API for the Pod that it is running in
+// until the condition named by STARTUP_GATE has a status of "True". The Pod
+// status is serialized with the condition "type" immediately followed by its
+// "status", which allows for the check to be done without needing any tooling
+// beyond what is available in the PostgreSQL container
+const startupGateScript = `SA=/var/run/secrets/kubernetes.io/serviceaccount
+URL="https://${KUBERNETES_SERVICE_HOST}:${KUBERNETES_SERVICE_PORT}/api/v1/namespaces/${POD_NAMESPACE}/pods/${POD_NAME}"
+echo "waiting on startup gate ${STARTUP_GATE}"
+until curl -sf --cacert "${SA}/ca.crt" -H "Authorization: Bearer $(cat ${SA}/token)" "${URL}" | \
+  grep -q "\"type\":\"${STARTUP_GATE}\",\"status\":\"True\""; do
+  sleep 2
+done
+echo "startup gate ${STARTUP_GATE} satisfied"
+`
+
 // affinityType represents the two affinity types provided by Kubernetes, specifically
 // either preferredDuringSchedulingIgnoredDuringExecution or
 // requiredDuringSchedulingIgnoredDuringExecution
@@ -90,6 +146,10 @@ type collectTemplateFields struct {
 	CCPImagePrefix string
 	PgPort         string
 	ExporterPort   string
+	// ContainerResources are the resources of the exporter from the spec, if any
+	ContainerResources string
+	// CustomQueries mounts the ConfigMap of custom queries into the exporter
+	CustomQueries bool
 }
 
 //consolidate
@@ -118,6 +178,13 @@ type PgbackrestS3EnvVarsTemplateFields struct {
 	PgbackrestS3Key        string
 	PgbackrestS3KeySecret  string
 	PgbackrestS3SecretName string
+	// PgbackrestS3KeyType, if set, has pgBackRest take its credentials from the workload
+	// identity of its Pod rather than from the key and key secret
+	PgbackrestS3KeyType  string
+	PgbackrestS3URIStyle string
+	PgbackrestS3PartSize string
+	PgbackrestProcessMax string
+	PgbackrestIOTimeout  string
 }
 
 type PgmonitorEnvVarsTemplateFields struct {
@@ -148,9 +215,13 @@ type DeploymentTemplateFields struct {
 	SecurityContext     string
 	ContainerResources  string
 	NodeSelector        string
+	NodeSelectorLabels  string
+	Tolerations         string
+	RuntimeClassName    string
 	ConfVolume          string
 	CollectAddon        string
 	CollectVolume       string
+	CollectQueries      string
 	BadgerAddon         string
 	PgbackrestEnvVars   string
 	PgbackrestS3EnvVars string
@@ -165,6 +236,11 @@ type DeploymentTemplateFields struct {
 	PodAntiAffinity          string
 	SyncReplication          bool
 	Standby                  bool
+	// StandbyReplicationSSLMode and StandbyReplicationSSLRootCert are the
+	// sslmode and the CA that the instances of a standby cluster that streams
+	// the WAL of a remote primary replicate with
+	StandbyReplicationSSLMode     string
+	StandbyReplicationSSLRootCert string
 	// A comma-separated list of tablespace names...this could be an array, but
 	// given how this would ultimately be interpreted in a shell script tsomewhere
 	// down the line, it's easier for the time being to do it this way. In the
@@ -172,6 +248,12 @@ type DeploymentTemplateFields struct {
 	Tablespaces            string
 	TablespaceVolumes      string
 	TablespaceVolumeMounts string
+	// WALPVCName is the name of the PVC that the write-ahead log of the
+	// instance is kept on, and WALDir the directory on it that pg_wal links to,
+	// if the cluster has WAL storage. initdb puts the WAL there when it creates
+	// the data directory
+	WALPVCName string
+	WALDir     string
 	// The following fields set the TLS requirements as well as provide
 	// information on how to configure TLS in a PostgreSQL cluster
 	// TLSEnabled enables TLS in a cluster if set to true. Only works in actuality
@@ -186,6 +268,12 @@ type DeploymentTemplateFields struct {
 	// CASecret is the name of the Secret that has the trusted CA that the
 	// PostgreSQL server is using
 	CASecret string
+	// TLSReplicationSecret is the name of the Secret with the client
+	// certificate that the instances replicate with, if the Operator manages one
+	TLSReplicationSecret string
+	// StartupGate contains the readiness gate and init container entries that
+	// hold back the start of PostgreSQL, if the cluster has a startup gate
+	StartupGate string
 }
 
 // tablespaceVolumeFields are the fields used to create the volumes in a
@@ -271,14 +359,39 @@ func GetBadgerAddon(clientset *kubernetes.Clientset, namespace string, cluster *
 	return ""
 }
 
-func GetCollectAddon(clientset *kubernetes.Clientset, namespace string, spec *crv1.PgclusterSpec) string {
+// IsExporterEnabled returns whether the pgMonitor exporter is run alongside the instances of a
+// cluster, i.e. whether it is enabled in the spec or with the "crunchy_collect" user label
+func IsExporterEnabled(spec *crv1.PgclusterSpec) bool {
+	return spec.Exporter.Enabled || spec.UserLabels[config.LABEL_COLLECT] == "true"
+}
 
-	if spec.UserLabels[config.LABEL_COLLECT] == "true" {
-		log.Debug("crunchy_collect was found as a label on cluster create")
+// GetExporterSecretName returns the name of the Secret with the credential that the exporter
+// logs in to PostgreSQL with
+func GetExporterSecretName(cluster *crv1.Pgcluster) string {
+	if cluster.Spec.CollectSecretName != "" {
+		return cluster.Spec.CollectSecretName
+	}
 
-		log.Debug("creating collect secret for cluster %s", spec.Name)
-		err := util.CreateSecret(clientset, spec.Name, spec.CollectSecretName, config.LABEL_COLLECT_PG_USER,
-			Pgo.Cluster.PgmonitorPassword, namespace)
+	return cluster.Name + crv1.CollectSecretSuffix
+}
+
+// GetCollectQueries returns the name of the ConfigMap with the custom queries of the exporter,
+// if the exporter is run with them
+func GetCollectQueries(spec *crv1.PgclusterSpec) string {
+	if !IsExporterEnabled(spec) {
+		return ""
+	}
+
+	return spec.Exporter.CustomQueries
+}
+
+// GetCollectAddon returns the crunchy-collect container of a cluster, if collection is enabled.
+// The secret of the collect user is created when the cluster is added, see
+// cluster.CreateClusterCredentials
+func GetCollectAddon(spec *crv1.PgclusterSpec) string {
+
+	if IsExporterEnabled(spec) {
+		log.Debug("the exporter is enabled for the cluster")
 
 		collectTemplateFields := collectTemplateFields{}
 		collectTemplateFields.Name = spec.Name
@@ -287,9 +400,14 @@ func GetCollectAddon(clientset *kubernetes.Clientset, namespace string, spec *cr
 		collectTemplateFields.ExporterPort = spec.ExporterPort
 		collectTemplateFields.CCPImagePrefix = Pgo.Cluster.CCPImagePrefix
 		collectTemplateFields.PgPort = spec.Port
+		collectTemplateFields.CustomQueries = GetCollectQueries(spec) != ""
+
+		if resources := spec.Exporter.Resources; resources != (crv1.PgContainerResources{}) {
+			collectTemplateFields.ContainerResources = GetContainerResourcesJSON(&resources)
+		}
 
 		var collectDoc bytes.Buffer
-		err = config.CollectTemplate.Execute(&collectDoc, collectTemplateFields)
+		err := config.CollectTemplate.Execute(&collectDoc, collectTemplateFields)
 		if err != nil {
 			log.Error(err.Error())
 			return ""
@@ -335,9 +453,10 @@ func GetConfVolume(clientset *kubernetes.Clientset, cl *crv1.Pgcluster, namespac
 // utilized by the crunchy-postgres-ha container (or GIS equivilant) to determine whether or not
 // initialization logic should be executed when the container is run.  This ensures that the
 // original primary in a PostgreSQL cluster does not attempt to run any initialization logic more
-// than once, such as following a restart of the container.  In the future this configMap can also
-// be leveraged to manage other configuration settings for the PostgreSQL cluster and its
-// associated containers.
+// than once, such as following a restart of the container.  It also provides the custom
+// configuration of Patroni that the primary is bootstrapped with, if the cluster is initialized
+// with options of initdb.  In the future this configMap can also be leveraged to manage other
+// configuration settings for the PostgreSQL cluster and its associated containers.
 func CreatePGHAConfigMap(clientset *kubernetes.Clientset, cluster *crv1.Pgcluster,
 	namespace string) error {
 
@@ -356,6 +475,15 @@ func CreatePGHAConfigMap(clientset *kubernetes.Clientset, cluster *crv1.Pgcluste
 		data[PGHAConfigReplicaBootstrapRepoTye] = "s3"
 	}
 
+	bootstrap, err := GetPatroniBootstrapConfig(clientset, cluster, namespace)
+	if err != nil {
+		return err
+	}
+
+	if bootstrap != "" {
+		data[PGHAConfigPatroniSetting] = bootstrap
+	}
+
 	configmap := &v1.ConfigMap{
 		ObjectMeta: meta_v1.ObjectMeta{
 			Name:   cluster.Name + "-" + PGHAConfigMapSuffix,
@@ -373,13 +501,79 @@ func CreatePGHAConfigMap(clientset *kubernetes.Clientset, cluster *crv1.Pgcluste
 
 // sets the proper collect secret in the deployment spec if collect is enabled
 func GetCollectVolume(clientset *kubernetes.Clientset, cl *crv1.Pgcluster, namespace string) string {
-	if cl.Spec.UserLabels[config.LABEL_COLLECT] == "true" {
-		return "\"secret\": { \"secretName\": \"" + cl.Spec.CollectSecretName + "\" }"
+	if IsExporterEnabled(&cl.Spec) {
+		return "\"secret\": { \"secretName\": \"" + GetExporterSecretName(cl) + "\" }"
 	}
 
 	return "\"emptyDir\": { \"secretName\": \"Memory\" }"
 }
 
+// GetTLSCASecretName returns the name of the Secret that provides the trusted
+// CAs to the PostgreSQL server. When client certificate authentication is set
+// up, this is the bundle that the Operator maintains of the CA of the server
+// and the CAs of the clients
+func GetTLSCASecretName(cluster *crv1.Pgcluster) string {
+	if cluster.Spec.TLS.ClientAuth.IsEnabled() {
+		return fmt.Sprintf("%s-%s", cluster.Name, TLSClientCASecretSuffix)
+	}
+
+	return GetTLSServerCASecretName(cluster)
+}
+
+// GetTLSSecretName returns the name of the Secret with the TLS keypair of the
+// PostgreSQL server, which is the one it is issued into when the certificates
+// of the cluster are managed by the Operator
+func GetTLSSecretName(cluster *crv1.Pgcluster) string {
+	if cluster.Spec.TLS.IsManaged() {
+		return fmt.Sprintf(TLSServerSecretFormat, cluster.Name)
+	}
+
+	return cluster.Spec.TLS.TLSSecret
+}
+
+// GetTLSReplicationSecretName returns the name of the Secret with the client
+// certificate that the instances of a cluster replicate with, which is only
+// issued when the certificates of the cluster are managed by the Operator, or
+// when the replication user authenticates with a client certificate that the
+// Operator issues from the client CA
+func GetTLSReplicationSecretName(cluster *crv1.Pgcluster) string {
+	clientAuth := cluster.Spec.TLS.ClientAuth
+
+	if cluster.Spec.TLS.IsManaged() ||
+		(clientAuth.Replication && clientAuth.IsEnabled() && clientAuth.IssueCertificates) {
+		return fmt.Sprintf(TLSReplicationSecretFormat, cluster.Name)
+	}
+
+	return ""
+}
+
+// GetTLSPgBouncerSecretName returns the name of the Secret with the keypair of
+// pgBouncer, which is either the one of its spec or the one that is issued when
+// the certificates of the cluster are managed by the Operator
+func GetTLSPgBouncerSecretName(cluster *crv1.Pgcluster) string {
+	if cluster.Spec.PgBouncer.TLSSecret != "" {
+		return cluster.Spec.PgBouncer.TLSSecret
+	}
+
+	if cluster.Spec.TLS.IsManaged() {
+		return fmt.Sprintf(TLSPgBouncerSecretFormat, cluster.Name)
+	}
+
+	return ""
+}
+
+// GetTLSServerCASecretName returns the name of the Secret with the CA of the
+// PostgreSQL server. The CA is kept alongside the keypair when the
+// certificates are managed by the Operator, so this is the same Secret as the
+// keypair then
+func GetTLSServerCASecretName(cluster *crv1.Pgcluster) string {
+	if cluster.Spec.TLS.IsManaged() {
+		return fmt.Sprintf(TLSServerSecretFormat, cluster.Name)
+	}
+
+	return cluster.Spec.TLS.CASecret
+}
+
 // GetTablespaceNamePVCMap returns a map of the tablespace name to the PVC name
 func GetTablespaceNamePVCMap(clusterName string, tablespaceStorageTypeMap map[string]string) map[string]string {
 	tablespacePVCMap := map[string]string{}
@@ -523,6 +717,64 @@ func GetTablespaceVolumesJSON(clusterName string, tablespaceStorageTypeMap map[s
 	return volumes.String()
 }
 
+// GetStartupGateJSON returns the "readinessGates" and "initContainers" entries
+// of a PostgreSQL Pod spec that implement the startup gate of a cluster, or an
+// empty string if the cluster does not have one. The init container uses the
+// same image as the PostgreSQL container
+func GetStartupGateJSON(cluster *crv1.Pgcluster, image string) string {
+	if cluster.Spec.StartupGate == "" {
+		return ""
+	}
+
+	readinessGates := []v1.PodReadinessGate{
+		v1.PodReadinessGate{
+			ConditionType: v1.PodConditionType(cluster.Spec.StartupGate),
+		},
+	}
+
+	initContainers := []v1.Container{
+		v1.Container{
+			Name:    StartupGateInitContainerName,
+			Image:   image,
+			Command: []string{"bash", "-c", startupGateScript},
+			Env: []v1.EnvVar{
+				v1.EnvVar{
+					Name:  "STARTUP_GATE",
+					Value: cluster.Spec.StartupGate,
+				},
+				v1.EnvVar{
+					Name: "POD_NAME",
+					ValueFrom: &v1.EnvVarSource{
+						FieldRef: &v1.ObjectFieldSelector{FieldPath: "metadata.name"},
+					},
+				},
+				v1.EnvVar{
+					Name: "POD_NAMESPACE",
+					ValueFrom: &v1.EnvVarSource{
+						FieldRef: &v1.ObjectFieldSelector{FieldPath: "metadata.namespace"},
+					},
+				},
+			},
+			ImagePullPolicy: v1.PullIfNotPresent,
+		},
+	}
+
+	readinessGatesJSON, err := json.Marshal(readinessGates)
+	if err != nil {
+		log.Error(err)
+		return ""
+	}
+
+	initContainersJSON, err := json.Marshal(initContainers)
+	if err != nil {
+		log.Error(err)
+		return ""
+	}
+
+	return fmt.Sprintf(`"readinessGates": %s, "initContainers": %s,`,
+		readinessGatesJSON, initContainersJSON)
+}
+
 // GetTableSpaceVolumeName returns the name that is used to identify the volume
 // that is used to mount the tablespace
 func GetTablespaceVolumeName(tablespaceName string) string {
@@ -697,8 +949,10 @@ func GetPodAntiAffinityType(cluster *crv1.Pgcluster, deploymentType crv1.PodAnti
 	return crv1.PodAntiAffinityType(Pgo.Cluster.PodAntiAffinity)
 }
 
-func GetPgmonitorEnvVars(metricsEnabled string) string {
-	if metricsEnabled == "true" {
+// GetPgmonitorEnvVars returns the environment variables that have pgMonitor set up in the
+// database container, if the exporter is enabled for the cluster
+func GetPgmonitorEnvVars(spec *crv1.PgclusterSpec) string {
+	if IsExporterEnabled(spec) {
 		fields := PgmonitorEnvVarsTemplateFields{
 			PgmonitorPassword: Pgo.Cluster.PgmonitorPassword,
 		}
@@ -722,10 +976,16 @@ func GetPgmonitorEnvVars(metricsEnabled string) string {
 // values have been obtained, they are used to populate a template containing the various
 // pgBackRest environment variables required to enable S3 support.  After the template has been
 // executed with the proper values, the result is then returned a string for inclusion in the PG
-// and pgBackRest deployments.
+// and pgBackRest deployments. A repository that is stored in GCS or Azure rather than S3 is
+// configured in its place, and one that is accessed with workload identity or whose keys come from
+// a secret store has no keys.
 func GetPgbackrestS3EnvVars(cluster crv1.Pgcluster, clientset *kubernetes.Clientset,
 	ns string) string {
 
+	if GetBackrestCloudType(&cluster) != "" {
+		return GetPgbackrestCloudEnvVars(cluster)
+	}
+
 	if !strings.Contains(cluster.Spec.UserLabels[config.LABEL_BACKREST_STORAGE_TYPE], "s3") {
 		return ""
 	}
@@ -750,16 +1010,26 @@ func GetPgbackrestS3EnvVars(cluster crv1.Pgcluster, clientset *kubernetes.Client
 		s3EnvVars.PgbackrestS3Bucket = Pgo.Cluster.BackrestS3Bucket
 	}
 
-	if cluster.Spec.BackrestS3Endpoint != "" {
-		s3EnvVars.PgbackrestS3Endpoint = cluster.Spec.BackrestS3Endpoint
-	} else {
-		s3EnvVars.PgbackrestS3Endpoint = Pgo.Cluster.BackrestS3Endpoint
+	// the endpoint, region and transfer tuning can be changed once the cluster exists, so they
+	// come from the same place as when they are updated
+	envVars := GetBackrestS3EnvVars(&cluster)
+	s3EnvVars.PgbackrestS3Endpoint = envVars[backrestS3EnvEndpoint]
+	s3EnvVars.PgbackrestS3Region = envVars[backrestS3EnvRegion]
+	s3EnvVars.PgbackrestS3URIStyle = envVars[backrestS3EnvURIStyle]
+	s3EnvVars.PgbackrestS3PartSize = envVars[backrestS3EnvPartSize]
+	s3EnvVars.PgbackrestProcessMax = envVars[backrestS3EnvProcessMax]
+	s3EnvVars.PgbackrestIOTimeout = envVars[backrestS3EnvIOTimeout]
+
+	// a web identity token is projected into the Pods of a ServiceAccount that is annotated
+	// with an IAM role, in place of the keys
+	if UsesBackrestWorkloadIdentity(&cluster) {
+		s3EnvVars.PgbackrestS3KeyType = backrestS3KeyTypeWebID
 	}
 
-	if cluster.Spec.BackrestS3Region != "" {
-		s3EnvVars.PgbackrestS3Region = cluster.Spec.BackrestS3Region
-	} else {
-		s3EnvVars.PgbackrestS3Region = Pgo.Cluster.BackrestS3Region
+	// the keys of a secret store are read from the configuration file it provides, which the
+	// environment would otherwise take precedence over
+	if UsesBackrestSecretStore(&cluster) {
+		s3EnvVars.PgbackrestS3SecretName = ""
 	}
 
 	doc := bytes.Buffer{}
@@ -796,6 +1066,11 @@ func UpdatePGHAConfigInitFlag(clientset *kubernetes.Clientset, initVal bool, clu
 	configMap := &configMapList.Items[0]
 	configMap.Data[PGHAConfigInitSetting] = strconv.FormatBool(initVal)
 
+	// once the cluster is initialized, the custom configMap of the cluster applies as it is
+	if !initVal {
+		delete(configMap.Data, PGHAConfigPatroniSetting)
+	}
+
 	if err := kubeapi.UpdateConfigMap(clientset, configMap, namespace); err != nil {
 		return err
 	}
@@ -816,6 +1091,58 @@ func GetSyncReplication(specSyncReplication *bool) bool {
 	return false
 }
 
+// ValidateEphemeralStorage checks that the ephemeral storage settings of the
+// cluster can be parsed, and that the limit, if set, leaves enough room for the
+// temporary files written as part of the backup settings of the cluster, which
+// can otherwise fill up the ephemeral storage and get the Pod evicted
+func ValidateEphemeralStorage(cluster *crv1.Pgcluster) error {
+	ephemeralStorage := cluster.Spec.EphemeralStorage
+
+	var request *resource.Quantity
+
+	if ephemeralStorage.Requests != "" {
+		quantity, err := resource.ParseQuantity(ephemeralStorage.Requests)
+		if err != nil {
+			return fmt.Errorf("invalid ephemeral storage request %q: %s", ephemeralStorage.Requests, err)
+		}
+		request = &quantity
+	}
+
+	if ephemeralStorage.Limits == "" {
+		return nil
+	}
+
+	limit, err := resource.ParseQuantity(ephemeralStorage.Limits)
+	if err != nil {
+		return fmt.Errorf("invalid ephemeral storage limit %q: %s", ephemeralStorage.Limits, err)
+	}
+
+	if request != nil && request.Cmp(limit) > 0 {
+		return fmt.Errorf("ephemeral storage request %s is greater than the limit %s",
+			ephemeralStorage.Requests, ephemeralStorage.Limits)
+	}
+
+	if floor := GetEphemeralStorageFloor(cluster); limit.Cmp(floor) < 0 {
+		return fmt.Errorf("ephemeral storage limit %s is below %s, the minimum for the backup "+
+			"settings of the cluster", ephemeralStorage.Limits, floor.String())
+	}
+
+	return nil
+}
+
+// GetEphemeralStorageFloor returns the smallest ephemeral storage limit that
+// is considered safe for the cluster. pgBackRest spools WAL to local storage
+// before pushing it to S3, so clusters that use S3 need additional room
+func GetEphemeralStorageFloor(cluster *crv1.Pgcluster) resource.Quantity {
+	floor := resource.MustParse(ephemeralStorageFloor)
+
+	if strings.Contains(cluster.Spec.UserLabels[config.LABEL_BACKREST_STORAGE_TYPE], "s3") {
+		floor.Add(resource.MustParse(ephemeralStorageFloorS3))
+	}
+
+	return floor
+}
+
 // OverrideClusterContainerImages is a helper function that provides the
 // appropriate hooks to override any of the container images that might be
 // deployed with a PostgreSQL cluster
@@ -43,8 +43,8 @@ var Pgo config.PgoConfig
 var ContainerImageOverrides = map[string]string{}
 
 type containerResourcesTemplateFields struct {
-	RequestsMemory, RequestsCPU string
-	LimitsMemory, LimitsCPU     string
+	RequestsMemory, RequestsCPU, RequestsEphemeralStorage string
+	LimitsMemory, LimitsCPU, LimitsEphemeralStorage       string
 }
 
 func Initialize(clientset *kubernetes.Clientset) {
@@ -127,11 +127,19 @@ func Initialize(clientset *kubernetes.Clientset) {
 // GetContainerResources is a legacy method that  creates the JSON snippet that
 // is applied for setting the CPU and memory in a container.
 func GetContainerResourcesJSON(resources *crv1.PgContainerResources) string {
+	return GetContainerResourcesWithEphemeralStorageJSON(resources, crv1.PgEphemeralStorageSpec{})
+}
+
+// GetContainerResourcesWithEphemeralStorageJSON creates the JSON snippet that
+// is applied for setting the CPU, memory and ephemeral storage in a container
+func GetContainerResourcesWithEphemeralStorageJSON(resources *crv1.PgContainerResources, ephemeralStorage crv1.PgEphemeralStorageSpec) string {
 	fields := containerResourcesTemplateFields{
-		LimitsCPU:      resources.LimitsCPU,
-		LimitsMemory:   resources.LimitsMemory,
-		RequestsCPU:    resources.RequestsCPU,
-		RequestsMemory: resources.RequestsMemory,
+		LimitsCPU:                resources.LimitsCPU,
+		LimitsMemory:             resources.LimitsMemory,
+		LimitsEphemeralStorage:   ephemeralStorage.Limits,
+		RequestsCPU:              resources.RequestsCPU,
+		RequestsMemory:           resources.RequestsMemory,
+		RequestsEphemeralStorage: ephemeralStorage.Requests,
 	}
 
 	doc := bytes.Buffer{}
@@ -153,6 +161,8 @@ func GetContainerResourcesJSON(resources *crv1.PgContainerResources) string {
 func GetRepoType(backrestStorageType string) string {
 	if backrestStorageType != "" && backrestStorageType == "s3" {
 		return "s3"
+	} else if backrestStorageType == "gcs" || backrestStorageType == "azure" {
+		return backrestStorageType
 	} else {
 		return "posix"
 	}
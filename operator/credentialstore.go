@@ -0,0 +1,295 @@
+package operator
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/crunchydata/postgres-operator/config"
+	"github.com/crunchydata/postgres-operator/kubeapi"
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// DefaultCredentialCacheTTL is how long a credential that is read from a credential store is
+// cached for, so that the store is not queried on every reconcile
+const DefaultCredentialCacheTTL = 30 * time.Second
+
+// Credential is a username and password of a PostgreSQL cluster
+type Credential struct {
+	// ClusterName is the name of the cluster the credential belongs to
+	ClusterName string
+	Username    string
+	Password    string
+}
+
+// CredentialStore is where the Operator keeps the credentials of the PostgreSQL clusters.
+// Credentials are identified by the namespace and name they are stored under, e.g. the
+// namespace of the cluster and the name of its Secret
+type CredentialStore interface {
+	// Get returns a credential. A CredentialNotFoundError is returned if it does not exist
+	Get(namespace, name string) (Credential, error)
+	// Set creates a credential, or replaces it if it already exists
+	Set(namespace, name string, credential Credential) error
+	// Rotate replaces the password of an existing credential and returns the credential
+	Rotate(namespace, name, password string) (Credential, error)
+}
+
+// CredentialNotFoundError is returned by a credential store if a credential does not exist
+type CredentialNotFoundError struct {
+	Namespace string
+	Name      string
+}
+
+func (e CredentialNotFoundError) Error() string {
+	return fmt.Sprintf("credential %s/%s not found", e.Namespace, e.Name)
+}
+
+// CredentialStoreUnavailableError is returned by a credential store if the backend cannot be
+// reached at the moment. Work that fails with it can be retried later
+type CredentialStoreUnavailableError struct {
+	Err error
+}
+
+func (e CredentialStoreUnavailableError) Error() string {
+	return fmt.Sprintf("credential store unavailable: %s", e.Err)
+}
+
+// IsCredentialNotFound returns true if the error is a CredentialNotFoundError
+func IsCredentialNotFound(err error) bool {
+	_, ok := err.(CredentialNotFoundError)
+	return ok
+}
+
+// IsCredentialStoreUnavailable returns true if the error is a
+// CredentialStoreUnavailableError
+func IsCredentialStoreUnavailable(err error) bool {
+	_, ok := err.(CredentialStoreUnavailableError)
+	return ok
+}
+
+// kubernetesCredentialStore keeps credentials in Kubernetes Secrets, with the username and
+// password in the "username" and "password" keys of the Secret, which is how the Operator has
+// always stored them
+type kubernetesCredentialStore struct {
+	clientset *kubernetes.Clientset
+}
+
+// NewKubernetesCredentialStore returns a credential store that keeps credentials in Kubernetes
+// Secrets
+func NewKubernetesCredentialStore(clientset *kubernetes.Clientset) CredentialStore {
+	return &kubernetesCredentialStore{clientset: clientset}
+}
+
+// Get returns the credential held by a Secret
+func (s *kubernetesCredentialStore) Get(namespace, name string) (Credential, error) {
+	secret, _, err := kubeapi.GetSecret(s.clientset, name, namespace)
+	if err != nil {
+		return Credential{}, kubernetesCredentialError(err, namespace, name)
+	}
+
+	return Credential{
+		ClusterName: secret.ObjectMeta.Labels[config.LABEL_PG_CLUSTER],
+		Username:    string(secret.Data["username"]),
+		Password:    string(secret.Data["password"]),
+	}, nil
+}
+
+// Set creates the Secret that holds a credential, or updates it if it already exists
+func (s *kubernetesCredentialStore) Set(namespace, name string, credential Credential) error {
+	secret, found, err := kubeapi.GetSecret(s.clientset, name, namespace)
+	if err != nil && !kerrors.IsNotFound(err) {
+		return kubernetesCredentialError(err, namespace, name)
+	}
+
+	if !found {
+		secret = &v1.Secret{
+			ObjectMeta: meta_v1.ObjectMeta{
+				Name: name,
+				Labels: map[string]string{
+					config.LABEL_PG_CLUSTER: credential.ClusterName,
+					config.LABEL_VENDOR:     config.LABEL_CRUNCHY,
+				},
+			},
+			Data: map[string][]byte{
+				"username": []byte(credential.Username),
+				"password": []byte(credential.Password),
+			},
+		}
+
+		return kubernetesCredentialError(kubeapi.CreateSecret(s.clientset, secret, namespace),
+			namespace, name)
+	}
+
+	if secret.Data == nil {
+		secret.Data = map[string][]byte{}
+	}
+
+	secret.Data["username"] = []byte(credential.Username)
+	secret.Data["password"] = []byte(credential.Password)
+
+	return kubernetesCredentialError(kubeapi.UpdateSecret(s.clientset, secret, namespace),
+		namespace, name)
+}
+
+// Rotate updates the password in the Secret that holds a credential
+func (s *kubernetesCredentialStore) Rotate(namespace, name, password string) (Credential, error) {
+	secret, _, err := kubeapi.GetSecret(s.clientset, name, namespace)
+	if err != nil {
+		return Credential{}, kubernetesCredentialError(err, namespace, name)
+	}
+
+	if secret.Data == nil {
+		secret.Data = map[string][]byte{}
+	}
+
+	secret.Data["password"] = []byte(password)
+
+	if err := kubeapi.UpdateSecret(s.clientset, secret, namespace); err != nil {
+		return Credential{}, kubernetesCredentialError(err, namespace, name)
+	}
+
+	return Credential{
+		ClusterName: secret.ObjectMeta.Labels[config.LABEL_PG_CLUSTER],
+		Username:    string(secret.Data["username"]),
+		Password:    password,
+	}, nil
+}
+
+// kubernetesCredentialError translates an error of the Kubernetes API into the errors of a
+// credential store, i.e. whether the credential does not exist or the API cannot be reached
+func kubernetesCredentialError(err error, namespace, name string) error {
+	switch {
+	case err == nil:
+		return nil
+	case kerrors.IsNotFound(err):
+		return CredentialNotFoundError{Namespace: namespace, Name: name}
+	case kerrors.IsServerTimeout(err), kerrors.IsTimeout(err), kerrors.IsTooManyRequests(err),
+		kerrors.IsServiceUnavailable(err), kerrors.IsInternalError(err),
+		kerrors.IsUnexpectedServerError(err):
+		return CredentialStoreUnavailableError{Err: err}
+	}
+
+	// errors that do not come from the API server, e.g. those of the connection to it, mean
+	// that the API cannot be reached
+	if _, ok := err.(kerrors.APIStatus); !ok {
+		return CredentialStoreUnavailableError{Err: err}
+	}
+
+	return err
+}
+
+// cachedCredential is a credential that is cached along with when it expires
+type cachedCredential struct {
+	credential Credential
+	expires    time.Time
+}
+
+// cachedCredentialStore caches the credentials that are read from another credential store for
+// a short amount of time. Credentials that are set or rotated through it are cached as well
+type cachedCredentialStore struct {
+	store   CredentialStore
+	ttl     time.Duration
+	now     func() time.Time
+	mutex   sync.Mutex
+	entries map[string]cachedCredential
+}
+
+// NewCachedCredentialStore returns a credential store that caches the credentials of the given
+// store for the given amount of time
+func NewCachedCredentialStore(store CredentialStore, ttl time.Duration) CredentialStore {
+	return &cachedCredentialStore{
+		store:   store,
+		ttl:     ttl,
+		now:     time.Now,
+		entries: map[string]cachedCredential{},
+	}
+}
+
+// Get returns a credential from the cache, or from the underlying store if it is not cached or
+// its cache entry has expired
+func (s *cachedCredentialStore) Get(namespace, name string) (Credential, error) {
+	key := namespace + "/" + name
+
+	s.mutex.Lock()
+	entry, ok := s.entries[key]
+	s.mutex.Unlock()
+
+	if ok && s.now().Before(entry.expires) {
+		return entry.credential, nil
+	}
+
+	credential, err := s.store.Get(namespace, name)
+	if err != nil {
+		// a credential that cannot be read is not served from the cache, as it may have
+		// been removed or rotated in the meantime
+		s.forget(key)
+		return Credential{}, err
+	}
+
+	s.remember(key, credential)
+
+	return credential, nil
+}
+
+// Set sets a credential in the underlying store and caches it
+func (s *cachedCredentialStore) Set(namespace, name string, credential Credential) error {
+	key := namespace + "/" + name
+
+	if err := s.store.Set(namespace, name, credential); err != nil {
+		s.forget(key)
+		return err
+	}
+
+	s.remember(key, credential)
+
+	return nil
+}
+
+// Rotate rotates a credential in the underlying store and caches the rotated credential
+func (s *cachedCredentialStore) Rotate(namespace, name, password string) (Credential, error) {
+	key := namespace + "/" + name
+
+	credential, err := s.store.Rotate(namespace, name, password)
+	if err != nil {
+		s.forget(key)
+		return Credential{}, err
+	}
+
+	s.remember(key, credential)
+
+	return credential, nil
+}
+
+// forget removes a credential from the cache
+func (s *cachedCredentialStore) forget(key string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.entries, key)
+}
+
+// remember caches a credential
+func (s *cachedCredentialStore) remember(key string, credential Credential) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.entries[key] = cachedCredential{credential: credential, expires: s.now().Add(s.ttl)}
+}
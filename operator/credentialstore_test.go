@@ -0,0 +1,224 @@
+package operator
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// fakeCredentialStore is a credential store that keeps credentials in memory, counts how often
+// it is read and can be made unavailable
+type fakeCredentialStore struct {
+	credentials map[string]Credential
+	gets        int
+	unavailable bool
+}
+
+func newFakeCredentialStore() *fakeCredentialStore {
+	return &fakeCredentialStore{credentials: map[string]Credential{}}
+}
+
+func (s *fakeCredentialStore) Get(namespace, name string) (Credential, error) {
+	s.gets++
+
+	if s.unavailable {
+		return Credential{}, CredentialStoreUnavailableError{Err: errors.New("connection refused")}
+	}
+
+	credential, ok := s.credentials[namespace+"/"+name]
+	if !ok {
+		return Credential{}, CredentialNotFoundError{Namespace: namespace, Name: name}
+	}
+
+	return credential, nil
+}
+
+func (s *fakeCredentialStore) Set(namespace, name string, credential Credential) error {
+	if s.unavailable {
+		return CredentialStoreUnavailableError{Err: errors.New("connection refused")}
+	}
+
+	s.credentials[namespace+"/"+name] = credential
+
+	return nil
+}
+
+func (s *fakeCredentialStore) Rotate(namespace, name, password string) (Credential, error) {
+	credential, err := s.Get(namespace, name)
+	if err != nil {
+		return Credential{}, err
+	}
+
+	credential.Password = password
+	s.credentials[namespace+"/"+name] = credential
+
+	return credential, nil
+}
+
+// newTestCachedCredentialStore returns a cached credential store around a fake store, along with
+// a function that moves the clock of the cache forward
+func newTestCachedCredentialStore(store CredentialStore, ttl time.Duration) (*cachedCredentialStore, func(time.Duration)) {
+	now := time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	cached := NewCachedCredentialStore(store, ttl).(*cachedCredentialStore)
+	cached.now = func() time.Time { return now }
+
+	return cached, func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestCachedCredentialStoreGet(t *testing.T) {
+	fake := newFakeCredentialStore()
+	fake.credentials["pgo/hippo-postgres-secret"] = Credential{Username: "postgres", Password: "a"}
+
+	store, advance := newTestCachedCredentialStore(fake, time.Minute)
+
+	for i := 0; i < 3; i++ {
+		credential, err := store.Get("pgo", "hippo-postgres-secret")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if credential.Password != "a" {
+			t.Fatalf("expected password %q, got %q", "a", credential.Password)
+		}
+	}
+
+	// the credential is read from the store once while it is cached
+	if fake.gets != 1 {
+		t.Fatalf("expected 1 read of the store, got %d", fake.gets)
+	}
+
+	// once the cache entry expires, the credential is read from the store again
+	fake.credentials["pgo/hippo-postgres-secret"] = Credential{Username: "postgres", Password: "b"}
+	advance(time.Minute)
+
+	credential, err := store.Get("pgo", "hippo-postgres-secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if credential.Password != "b" || fake.gets != 2 {
+		t.Fatalf("expected password %q after 2 reads, got %q after %d", "b", credential.Password,
+			fake.gets)
+	}
+}
+
+func TestCachedCredentialStoreUnavailable(t *testing.T) {
+	fake := newFakeCredentialStore()
+	fake.credentials["pgo/hippo-postgres-secret"] = Credential{Username: "postgres", Password: "a"}
+
+	store, advance := newTestCachedCredentialStore(fake, time.Minute)
+
+	if _, err := store.Get("pgo", "hippo-postgres-secret"); err != nil {
+		t.Fatal(err)
+	}
+
+	// a cached credential is served while the store is unavailable
+	fake.unavailable = true
+
+	if _, err := store.Get("pgo", "hippo-postgres-secret"); err != nil {
+		t.Fatalf("expected cached credential, got %v", err)
+	}
+
+	// but once the entry expires, the unavailability is returned so the caller can retry
+	advance(time.Minute)
+
+	if _, err := store.Get("pgo", "hippo-postgres-secret"); !IsCredentialStoreUnavailable(err) {
+		t.Fatalf("expected the store to be unavailable, got %v", err)
+	}
+
+	if err := store.Set("pgo", "hippo-postgres-secret", Credential{}); !IsCredentialStoreUnavailable(err) {
+		t.Fatalf("expected the store to be unavailable, got %v", err)
+	}
+
+	// the store recovers
+	fake.unavailable = false
+
+	credential, err := store.Get("pgo", "hippo-postgres-secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if credential.Password != "a" {
+		t.Fatalf("expected password %q, got %q", "a", credential.Password)
+	}
+}
+
+func TestCachedCredentialStoreNotFound(t *testing.T) {
+	fake := newFakeCredentialStore()
+	store, _ := newTestCachedCredentialStore(fake, time.Minute)
+
+	if _, err := store.Get("pgo", "hippo-ccp-monitoring-secret"); !IsCredentialNotFound(err) {
+		t.Fatalf("expected credential not to be found, got %v", err)
+	}
+
+	if IsCredentialStoreUnavailable(CredentialNotFoundError{}) {
+		t.Fatal("a missing credential does not mean the store is unavailable")
+	}
+
+	// a credential that is set is cached, so reading it does not go to the store
+	if err := store.Set("pgo", "hippo-ccp-monitoring-secret",
+		Credential{ClusterName: "hippo", Username: "ccp_monitoring", Password: "a"}); err != nil {
+		t.Fatal(err)
+	}
+
+	gets := fake.gets
+
+	credential, err := store.Get("pgo", "hippo-ccp-monitoring-secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if credential.Username != "ccp_monitoring" || fake.gets != gets {
+		t.Fatalf("expected cached credential, got %v after %d reads", credential, fake.gets-gets)
+	}
+}
+
+func TestCachedCredentialStoreRotate(t *testing.T) {
+	fake := newFakeCredentialStore()
+	fake.credentials["pgo/hippo-postgres-secret"] = Credential{Username: "postgres", Password: "a"}
+
+	store, _ := newTestCachedCredentialStore(fake, time.Minute)
+
+	if _, err := store.Get("pgo", "hippo-postgres-secret"); err != nil {
+		t.Fatal(err)
+	}
+
+	rotated, err := store.Rotate("pgo", "hippo-postgres-secret", "b")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rotated.Username != "postgres" || rotated.Password != "b" {
+		t.Fatalf("unexpected rotated credential %v", rotated)
+	}
+
+	// the rotated credential replaces the cached one
+	credential, err := store.Get("pgo", "hippo-postgres-secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if credential.Password != "b" {
+		t.Fatalf("expected password %q, got %q", "b", credential.Password)
+	}
+
+	if _, err := store.Rotate("pgo", "hippo-missing-secret", "b"); !IsCredentialNotFound(err) {
+		t.Fatalf("expected credential not to be found, got %v", err)
+	}
+}
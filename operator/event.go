@@ -0,0 +1,147 @@
+package operator
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/kubeapi"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// the reasons of the events that are recorded by more than one controller, so that the
+// operational story of a cluster reads alike wherever it is told from
+const (
+	// EventReasonBackupCompleted is recorded for a cluster once a backup of it completes
+	EventReasonBackupCompleted = "BackupCompleted"
+	// EventReasonBackupFailed is recorded for a cluster once a backup of it fails
+	EventReasonBackupFailed = "BackupFailed"
+	// EventReasonRestoreCompleted is recorded for a cluster once a restore into it completes
+	EventReasonRestoreCompleted = "RestoreCompleted"
+	// EventReasonRestoreFailed is recorded for a cluster once a restore into it fails
+	EventReasonRestoreFailed = "RestoreFailed"
+	// EventReasonFailoverPerformed is recorded for a cluster once a replica of it is promoted
+	EventReasonFailoverPerformed = "FailoverPerformed"
+	// EventReasonFailoverFailed is recorded for a cluster if a requested failover fails
+	EventReasonFailoverFailed = "FailoverFailed"
+	// EventReasonReplicaCreated is recorded for a pgreplica once its instance is created
+	EventReasonReplicaCreated = "ReplicaCreated"
+	// EventReasonReplicaRemoved is recorded for a cluster once a pgreplica of it is removed
+	// along with its instance
+	EventReasonReplicaRemoved = "ReplicaRemoved"
+	// EventReasonPVCResizeStarted is recorded for a cluster once the PVCs of it are resized
+	EventReasonPVCResizeStarted = "PVCResizeStarted"
+	// EventReasonPVCResizeCompleted is recorded for a cluster or a pgreplica once the PVC of an
+	// instance of it has its new size
+	EventReasonPVCResizeCompleted = "PVCResizeCompleted"
+	// EventReasonPVCResizeFailed is recorded for a cluster or a pgreplica if the PVC of an
+	// instance of it cannot be resized
+	EventReasonPVCResizeFailed = "PVCResizeFailed"
+	// EventReasonTaskStarted is recorded for a pgtask once the Operator starts to carry it out
+	EventReasonTaskStarted = "TaskStarted"
+	// EventReasonTaskDuplicate is recorded for a pgtask that is not carried out, as it has the
+	// same idempotency key as another pgtask
+	EventReasonTaskDuplicate = "TaskDuplicate"
+	// EventReasonTaskRetrying is recorded for a pgtask that failed for a transient reason and
+	// is run again once its backoff has passed
+	EventReasonTaskRetrying = "TaskRetrying"
+)
+
+// RecordWarningEvent records a Warning event for the cluster, e.g. about a setting of the cluster
+// that cannot be applied, so that it shows up when the cluster is described
+func RecordWarningEvent(clientset *kubernetes.Clientset, cluster *crv1.Pgcluster, reason, message string) {
+	RecordObjectWarningEvent(clientset, PgclusterReference(cluster), reason, message)
+}
+
+// RecordNormalEvent records a Normal event for the cluster, e.g. about work the Operator carried
+// out on its own, so that it shows up when the cluster is described
+func RecordNormalEvent(clientset *kubernetes.Clientset, cluster *crv1.Pgcluster, reason, message string) {
+	RecordObjectNormalEvent(clientset, PgclusterReference(cluster), reason, message)
+}
+
+// PgclusterReference returns the reference that the events of a cluster are recorded for
+func PgclusterReference(cluster *crv1.Pgcluster) v1.ObjectReference {
+	return v1.ObjectReference{
+		APIVersion:      crv1.SchemeGroupVersion.String(),
+		Kind:            "Pgcluster",
+		Name:            cluster.Name,
+		Namespace:       cluster.Namespace,
+		UID:             cluster.UID,
+		ResourceVersion: cluster.ResourceVersion,
+	}
+}
+
+// PgreplicaReference returns the reference that the events of a pgreplica are recorded for
+func PgreplicaReference(replica *crv1.Pgreplica) v1.ObjectReference {
+	return v1.ObjectReference{
+		APIVersion:      crv1.SchemeGroupVersion.String(),
+		Kind:            "Pgreplica",
+		Name:            replica.Name,
+		Namespace:       replica.Namespace,
+		UID:             replica.UID,
+		ResourceVersion: replica.ResourceVersion,
+	}
+}
+
+// PgtaskReference returns the reference that the events of a pgtask are recorded for
+func PgtaskReference(task *crv1.Pgtask) v1.ObjectReference {
+	return v1.ObjectReference{
+		APIVersion:      crv1.SchemeGroupVersion.String(),
+		Kind:            "Pgtask",
+		Name:            task.Name,
+		Namespace:       task.Namespace,
+		UID:             task.UID,
+		ResourceVersion: task.ResourceVersion,
+	}
+}
+
+// RecordObjectWarningEvent records a Warning event for the object that is referred to, e.g. for a
+// custom resource that only its namespace and name are known of
+func RecordObjectWarningEvent(clientset *kubernetes.Clientset, object v1.ObjectReference, reason, message string) {
+	recordObjectEvent(clientset, object, v1.EventTypeWarning, reason, message)
+}
+
+// RecordObjectNormalEvent records a Normal event for the object that is referred to
+func RecordObjectNormalEvent(clientset *kubernetes.Clientset, object v1.ObjectReference, reason, message string) {
+	recordObjectEvent(clientset, object, v1.EventTypeNormal, reason, message)
+}
+
+// recordObjectEvent records an event of the type for the object that is referred to
+func recordObjectEvent(clientset *kubernetes.Clientset, object v1.ObjectReference, eventType, reason,
+	message string) {
+	now := metav1.Now()
+
+	event := &v1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: object.Name + "-",
+			Namespace:    object.Namespace,
+		},
+		InvolvedObject: object,
+		Reason:         reason,
+		Message:        message,
+		Type:           eventType,
+		Source:         v1.EventSource{Component: "postgres-operator"},
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Count:          1,
+	}
+
+	if err := kubeapi.CreateEvent(clientset, event, object.Namespace); err != nil {
+		log.Error(err)
+	}
+}
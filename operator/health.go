@@ -0,0 +1,86 @@
+package operator
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// HealthChecker reports on the health of the controllers of the Operator
+type HealthChecker interface {
+	// Healthy returns an error if the controllers are stuck, in which case the Operator has to
+	// be restarted
+	Healthy() error
+	// Ready returns an error if the controllers are not ready to process events yet
+	Ready() error
+}
+
+// health holds the HealthChecker of the Operator. Until one is set, e.g. while a replica of the
+// Operator stands by for the leader, the Operator is healthy and ready
+var health = struct {
+	mutex   sync.Mutex
+	checker HealthChecker
+}{}
+
+// SetHealthChecker sets what the health and the readiness of the Operator are checked with
+func SetHealthChecker(checker HealthChecker) {
+	health.mutex.Lock()
+	defer health.mutex.Unlock()
+
+	health.checker = checker
+}
+
+// ServeHealth serves the health of the Operator at /healthz and its readiness at /readyz on the
+// given port, for the liveness and readiness probes of its Pod
+func ServeHealth(port string) {
+	log.Infof("serving health checks on port %s", port)
+
+	if err := http.ListenAndServe(":"+port, newHealthHandler()); err != nil {
+		log.Errorf("could not serve health checks: %s", err)
+	}
+}
+
+// newHealthHandler returns the handler of the health endpoints
+func newHealthHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", healthHandler(HealthChecker.Healthy))
+	mux.HandleFunc("/readyz", healthHandler(HealthChecker.Ready))
+
+	return mux
+}
+
+// healthHandler returns a handler that responds with the result of a check of the HealthChecker
+func healthHandler(check func(HealthChecker) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		health.mutex.Lock()
+		checker := health.checker
+		health.mutex.Unlock()
+
+		if checker != nil {
+			if err := check(checker); err != nil {
+				log.Warnf("health check %s failed: %s", r.URL.Path, err)
+				http.Error(w, err.Error(), http.StatusServiceUnavailable)
+				return
+			}
+		}
+
+		fmt.Fprintln(w, "ok")
+	}
+}
@@ -0,0 +1,68 @@
+package operator
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// fakeHealthChecker is a HealthChecker that returns the errors it is given
+type fakeHealthChecker struct {
+	healthy, ready error
+}
+
+func (f fakeHealthChecker) Healthy() error { return f.healthy }
+func (f fakeHealthChecker) Ready() error   { return f.ready }
+
+func TestHealthHandler(t *testing.T) {
+	defer SetHealthChecker(nil)
+
+	handler := newHealthHandler()
+
+	status := func(path string) int {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest("GET", path, nil))
+		return recorder.Code
+	}
+
+	tests := []struct {
+		name            string
+		checker         HealthChecker
+		healthz, readyz int
+	}{
+		// a replica that stands by for the leader has no controllers to check
+		{"standing by", nil, http.StatusOK, http.StatusOK},
+		{"syncing", fakeHealthChecker{ready: errors.New("not synced")},
+			http.StatusOK, http.StatusServiceUnavailable},
+		{"stalled", fakeHealthChecker{healthy: errors.New("stalled"), ready: errors.New("stalled")},
+			http.StatusServiceUnavailable, http.StatusServiceUnavailable},
+		{"running", fakeHealthChecker{}, http.StatusOK, http.StatusOK},
+	}
+
+	for _, test := range tests {
+		SetHealthChecker(test.checker)
+
+		if code := status("/healthz"); code != test.healthz {
+			t.Fatalf("%s - expected /healthz to respond %d, got %d", test.name, test.healthz, code)
+		}
+		if code := status("/readyz"); code != test.readyz {
+			t.Fatalf("%s - expected /readyz to respond %d, got %d", test.name, test.readyz, code)
+		}
+	}
+}
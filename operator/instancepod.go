@@ -0,0 +1,267 @@
+package operator
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/config"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// instanceContainerName is the name of the PostgreSQL container of the instance Pods, which the
+// volume mounts of the instance pod spec are added to
+const instanceContainerName = "database"
+
+// reservedInstanceContainerNames are the names of the containers that the Operator puts into the
+// instance Pods itself, i.e. PostgreSQL, the exporter and pgBadger
+var reservedInstanceContainerNames = map[string]bool{
+	instanceContainerName: true,
+	"collect":             true,
+	"pgbadger":            true,
+}
+
+// ValidateInstancePod ensures that the containers, init containers, volumes and volume mounts of
+// the instance pod spec of a cluster can be added to the Pods of its instances
+func ValidateInstancePod(cluster *crv1.Pgcluster) error {
+	spec := cluster.Spec.InstancePod
+	containers := map[string]bool{}
+
+	for _, container := range append(append([]v1.Container{}, spec.InitContainers...), spec.Containers...) {
+		if errs := validation.IsDNS1123Label(container.Name); len(errs) != 0 {
+			return fmt.Errorf("invalid name %q of an instance container: %s", container.Name,
+				strings.Join(errs, "; "))
+		}
+		if reservedInstanceContainerNames[container.Name] {
+			return fmt.Errorf("instance container %q is reserved for the Operator", container.Name)
+		}
+		if containers[container.Name] {
+			return fmt.Errorf("instance container %q is declared more than once", container.Name)
+		}
+		if container.Image == "" {
+			return fmt.Errorf("instance container %q requires an image", container.Name)
+		}
+
+		containers[container.Name] = true
+	}
+
+	volumes := map[string]bool{}
+	for _, volume := range spec.Volumes {
+		if errs := validation.IsDNS1123Label(volume.Name); len(errs) != 0 {
+			return fmt.Errorf("invalid name %q of an instance volume: %s", volume.Name, strings.Join(errs, "; "))
+		}
+		if volumes[volume.Name] {
+			return fmt.Errorf("instance volume %q is declared more than once", volume.Name)
+		}
+
+		volumes[volume.Name] = true
+	}
+
+	for _, mount := range spec.VolumeMounts {
+		if mount.Name == "" || mount.MountPath == "" {
+			return errors.New("an instance volume mount requires a name and a mountPath")
+		}
+	}
+
+	return nil
+}
+
+// SetInstancePod adds the containers, init containers, volumes and volume mounts of the instance
+// pod spec of a cluster to the Pod template of one of its instances, replacing the ones that
+// were added before. What is added is recorded in an annotation of the template, so that what is
+// taken out of the spec is removed again, while a container or a volume of the Operator with the
+// same name is never replaced. It returns whether the template changed
+func SetInstancePod(cluster *crv1.Pgcluster, template *v1.PodTemplateSpec) bool {
+	previous := crv1.InstancePodSpec{}
+	if doc := template.Annotations[config.ANNOTATION_INSTANCE_POD]; doc != "" {
+		if err := json.Unmarshal([]byte(doc), &previous); err != nil {
+			log.Warnf("could not read the instance pod spec that %s was rolled out with: %s", template.Name, err)
+		}
+	}
+
+	removeInstancePod(&template.Spec, previous)
+	applied := addInstancePod(&template.Spec, cluster.Spec.InstancePod)
+
+	record := ""
+	if !reflect.DeepEqual(applied, crv1.InstancePodSpec{}) {
+		// this is a plain struct, which always marshals
+		doc, _ := json.Marshal(applied)
+		record = string(doc)
+	}
+
+	if record == template.Annotations[config.ANNOTATION_INSTANCE_POD] {
+		return false
+	}
+
+	if record == "" {
+		delete(template.Annotations, config.ANNOTATION_INSTANCE_POD)
+		return true
+	}
+
+	if template.Annotations == nil {
+		template.Annotations = map[string]string{}
+	}
+
+	template.Annotations[config.ANNOTATION_INSTANCE_POD] = record
+
+	return true
+}
+
+// addInstancePod adds the containers, init containers, volumes and volume mounts of an instance
+// pod spec to a Pod, and returns those that were added. Those that would replace what the Pod
+// already has are skipped
+func addInstancePod(spec *v1.PodSpec, instancePod crv1.InstancePodSpec) crv1.InstancePodSpec {
+	applied := crv1.InstancePodSpec{}
+
+	for _, container := range instancePod.InitContainers {
+		if hasContainer(spec.InitContainers, container.Name) || hasContainer(spec.Containers, container.Name) {
+			log.Warnf("skipping instance init container %q, which the pod already has", container.Name)
+			continue
+		}
+
+		spec.InitContainers = append(spec.InitContainers, *container.DeepCopy())
+		applied.InitContainers = append(applied.InitContainers, container)
+	}
+
+	for _, container := range instancePod.Containers {
+		if hasContainer(spec.InitContainers, container.Name) || hasContainer(spec.Containers, container.Name) {
+			log.Warnf("skipping instance container %q, which the pod already has", container.Name)
+			continue
+		}
+
+		spec.Containers = append(spec.Containers, *container.DeepCopy())
+		applied.Containers = append(applied.Containers, container)
+	}
+
+	for _, volume := range instancePod.Volumes {
+		if hasVolume(spec.Volumes, volume.Name) {
+			log.Warnf("skipping instance volume %q, which the pod already has", volume.Name)
+			continue
+		}
+
+		spec.Volumes = append(spec.Volumes, *volume.DeepCopy())
+		applied.Volumes = append(applied.Volumes, volume)
+	}
+
+	for i := range spec.Containers {
+		if spec.Containers[i].Name != instanceContainerName {
+			continue
+		}
+
+		container := &spec.Containers[i]
+
+		for _, mount := range instancePod.VolumeMounts {
+			if hasMountPath(container.VolumeMounts, mount.MountPath) {
+				log.Warnf("skipping instance volume mount at %q, which is already mounted", mount.MountPath)
+				continue
+			}
+
+			container.VolumeMounts = append(container.VolumeMounts, mount)
+			applied.VolumeMounts = append(applied.VolumeMounts, mount)
+		}
+	}
+
+	return applied
+}
+
+// removeInstancePod removes the containers, init containers, volumes and volume mounts of an
+// instance pod spec from a Pod
+func removeInstancePod(spec *v1.PodSpec, instancePod crv1.InstancePodSpec) {
+	initContainers := []v1.Container{}
+	for _, container := range spec.InitContainers {
+		if !hasContainer(instancePod.InitContainers, container.Name) {
+			initContainers = append(initContainers, container)
+		}
+	}
+
+	containers := []v1.Container{}
+	for _, container := range spec.Containers {
+		if !hasContainer(instancePod.Containers, container.Name) {
+			containers = append(containers, container)
+		}
+	}
+
+	volumes := []v1.Volume{}
+	for _, volume := range spec.Volumes {
+		if !hasVolume(instancePod.Volumes, volume.Name) {
+			volumes = append(volumes, volume)
+		}
+	}
+
+	for i := range containers {
+		if containers[i].Name != instanceContainerName {
+			continue
+		}
+
+		mounts := []v1.VolumeMount{}
+		for _, mount := range containers[i].VolumeMounts {
+			if !hasMountPath(instancePod.VolumeMounts, mount.MountPath) {
+				mounts = append(mounts, mount)
+			}
+		}
+
+		containers[i].VolumeMounts = mounts
+	}
+
+	// a Pod without init containers is left without the field rather than with an empty list
+	if len(initContainers) == 0 {
+		initContainers = nil
+	}
+
+	spec.InitContainers = initContainers
+	spec.Containers = containers
+	spec.Volumes = volumes
+}
+
+// hasContainer determines whether or not one of the containers has the name
+func hasContainer(containers []v1.Container, name string) bool {
+	for _, container := range containers {
+		if container.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// hasVolume determines whether or not one of the volumes has the name
+func hasVolume(volumes []v1.Volume, name string) bool {
+	for _, volume := range volumes {
+		if volume.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// hasMountPath determines whether or not one of the volume mounts is mounted at the path
+func hasMountPath(mounts []v1.VolumeMount, path string) bool {
+	for _, mount := range mounts {
+		if mount.MountPath == path {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,91 @@
+package operator
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"testing"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/config"
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestSetInstancePod(t *testing.T) {
+	template := &v1.PodTemplateSpec{}
+	template.Spec.Containers = []v1.Container{
+		{Name: "database", VolumeMounts: []v1.VolumeMount{{Name: "pgdata", MountPath: "/pgdata"}}},
+		{Name: "collect"},
+	}
+	template.Spec.Volumes = []v1.Volume{{Name: "pgdata"}}
+
+	cluster := &crv1.Pgcluster{}
+	cluster.Spec.InstancePod = crv1.InstancePodSpec{
+		InitContainers: []v1.Container{{Name: "prepare", Image: "busybox"}},
+		Containers: []v1.Container{
+			{Name: "log-shipper", Image: "fluent/fluent-bit"},
+			// the exporter of the Operator is never replaced
+			{Name: "collect", Image: "other"},
+		},
+		Volumes: []v1.Volume{{Name: "shipper-config"}, {Name: "pgdata"}},
+		VolumeMounts: []v1.VolumeMount{
+			{Name: "shipper-config", MountPath: "/etc/shipper"},
+			{Name: "pgdata", MountPath: "/pgdata"},
+		},
+	}
+
+	if !SetInstancePod(cluster, template) {
+		t.Fatal("expected the template to change")
+	}
+
+	spec := template.Spec
+	if len(spec.InitContainers) != 1 || spec.InitContainers[0].Name != "prepare" {
+		t.Errorf("expected the init container to be added, got %v", spec.InitContainers)
+	}
+	if len(spec.Containers) != 3 || spec.Containers[0].Name != "database" ||
+		spec.Containers[1].Image != "" || spec.Containers[2].Name != "log-shipper" {
+		t.Errorf("expected only the log shipper to be added, got %v", spec.Containers)
+	}
+	if len(spec.Volumes) != 2 || spec.Volumes[1].Name != "shipper-config" {
+		t.Errorf("expected only the shipper volume to be added, got %v", spec.Volumes)
+	}
+	if mounts := spec.Containers[0].VolumeMounts; len(mounts) != 2 || mounts[1].MountPath != "/etc/shipper" {
+		t.Errorf("expected only the shipper volume to be mounted, got %v", mounts)
+	}
+
+	// nothing changes when the same spec is set again
+	if SetInstancePod(cluster, template) {
+		t.Error("expected the template not to change")
+	}
+	if len(template.Spec.Containers) != 3 || len(template.Spec.Volumes) != 2 {
+		t.Errorf("expected nothing to be added twice, got %v", template.Spec)
+	}
+
+	// taking everything out of the spec removes only what was added
+	cluster.Spec.InstancePod = crv1.InstancePodSpec{}
+
+	if !SetInstancePod(cluster, template) {
+		t.Fatal("expected the template to change")
+	}
+
+	spec = template.Spec
+	if len(spec.InitContainers) != 0 || len(spec.Containers) != 2 || len(spec.Volumes) != 1 ||
+		len(spec.Containers[0].VolumeMounts) != 1 {
+		t.Errorf("expected the template to be as it was, got %v", spec)
+	}
+	if _, ok := template.Annotations[config.ANNOTATION_INSTANCE_POD]; ok {
+		t.Error("expected the record of the instance pod spec to be removed")
+	}
+}
@@ -0,0 +1,255 @@
+package operator
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	coordination_v1 "k8s.io/api/coordination/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+// leaderElectionLeaseFormat is the name of the Lease that the replicas of an installation of the
+// Operator elect their leader with
+const leaderElectionLeaseFormat = "postgres-operator-%s"
+
+// leaderMetrics keeps the metrics of the leader election. They are served in the text format of
+// Prometheus when MetricsPort is set
+type leaderMetrics struct {
+	mutex sync.Mutex
+	// leader is whether this replica currently leads, by the name of the Lease
+	leader map[string]bool
+	// acquisitions is how many times this replica became the leader, by the name of the Lease
+	acquisitions map[string]int
+	// transitions is how many times this replica saw the leader change, by the name of the Lease
+	transitions map[string]int
+}
+
+// leadership holds the metrics of the leader election of the Operator
+var leadership = newLeaderMetrics()
+
+// newLeaderMetrics returns metrics of the leader election that have not recorded anything yet
+func newLeaderMetrics() *leaderMetrics {
+	return &leaderMetrics{
+		leader:       map[string]bool{},
+		acquisitions: map[string]int{},
+		transitions:  map[string]int{},
+	}
+}
+
+// RunAsLeader runs the Operator, i.e. calls run, once this replica is elected the leader among
+// the replicas of its installation, or right away if LeaderElection is not set. It returns once
+// run does or stopCh is closed, along with a function that releases the Lease the leader holds
+// in the namespace of the Operator, so that one of the replicas that stand by can take over right
+// away once the leader has shut down. A leader that fails to renew its Lease exits, as its
+// controllers cannot be stopped and restarted, so that it comes back as a replica that stands by
+func RunAsLeader(clientset *kubernetes.Clientset, stopCh <-chan struct{}, run func()) (func(), error) {
+	if !Pgo.Pgo.LeaderElection {
+		run()
+		return func() {}, nil
+	}
+
+	identity, err := LeaderIdentity()
+	if err != nil {
+		return nil, err
+	}
+
+	name := fmt.Sprintf(leaderElectionLeaseFormat, InstallationName)
+
+	lock := &resourcelock.LeaseLock{
+		LeaseMeta: meta_v1.ObjectMeta{
+			Name:      name,
+			Namespace: PgoNamespace,
+		},
+		Client:     clientset.CoordinationV1(),
+		LockConfig: resourcelock.ResourceLockConfig{Identity: identity},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	leading := make(chan struct{})
+
+	leaderelection.SetProvider(leadership)
+
+	elector, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+		Lock:            lock,
+		Name:            name,
+		LeaseDuration:   time.Duration(Pgo.Pgo.LeaseDurationSeconds) * time.Second,
+		RenewDeadline:   time.Duration(Pgo.Pgo.RenewDeadlineSeconds) * time.Second,
+		RetryPeriod:     time.Duration(Pgo.Pgo.RetryPeriodSeconds) * time.Second,
+		ReleaseOnCancel: true,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(context.Context) {
+				log.Infof("leader election: %s is now the leader", identity)
+				close(leading)
+			},
+			OnStoppedLeading: func() {
+				select {
+				case <-ctx.Done():
+					log.Infof("leader election: %s released the leadership", identity)
+				default:
+					log.Fatalf("leader election: %s lost the leadership, exiting", identity)
+				}
+			},
+			OnNewLeader: func(leader string) {
+				leadership.observe(name)
+				if leader != identity {
+					log.Infof("leader election: %s is the leader, %s stands by", leader, identity)
+				}
+			},
+		},
+	})
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	log.Infof("leader election: %s is waiting to acquire Lease %s/%s", identity, PgoNamespace, name)
+
+	// the elector releases the Lease as it returns once it is canceled
+	stopped := make(chan struct{})
+	go func() {
+		elector.Run(ctx)
+		close(stopped)
+	}()
+
+	release := func() {
+		cancel()
+		<-stopped
+	}
+
+	select {
+	case <-leading:
+		run()
+	case <-stopCh:
+	}
+
+	return release, nil
+}
+
+// LeaderIdentity returns the identity that this replica holds the Lease with, i.e. the name of its
+// Pod, which the containers of the Pod share
+func LeaderIdentity() (string, error) {
+	if identity := os.Getenv("MY_POD_NAME"); identity != "" {
+		return identity, nil
+	}
+	return os.Hostname()
+}
+
+// IsLeader returns whether the replica of identity holds the Lease of the installation of the
+// Operator in namespace, so that the other containers of its Pod, e.g. the scheduler, only act on
+// the leader. A Lease that has not been renewed within its duration is held by no one
+func IsLeader(clientset kubernetes.Interface, namespace, installationName, identity string) (bool, error) {
+	name := fmt.Sprintf(leaderElectionLeaseFormat, installationName)
+
+	lease, err := clientset.CoordinationV1().Leases(namespace).Get(name, meta_v1.GetOptions{})
+	if kerrors.IsNotFound(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	return isLeaseHeldBy(lease, identity, time.Now()), nil
+}
+
+// isLeaseHeldBy returns whether identity holds lease at now, i.e. it is its holder and has
+// renewed it within its duration
+func isLeaseHeldBy(lease *coordination_v1.Lease, identity string, now time.Time) bool {
+	if lease.Spec.HolderIdentity == nil || *lease.Spec.HolderIdentity != identity ||
+		lease.Spec.RenewTime == nil || lease.Spec.LeaseDurationSeconds == nil {
+		return false
+	}
+
+	duration := time.Duration(*lease.Spec.LeaseDurationSeconds) * time.Second
+	return now.Before(lease.Spec.RenewTime.Add(duration))
+}
+
+// NewLeaderMetric returns the metric that records whether this replica is the leader
+func (m *leaderMetrics) NewLeaderMetric() leaderelection.SwitchMetric {
+	return m
+}
+
+// On records that this replica became the leader of a Lease
+func (m *leaderMetrics) On(name string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if !m.leader[name] {
+		m.acquisitions[name]++
+	}
+	m.leader[name] = true
+}
+
+// Off records that this replica is not the leader of a Lease
+func (m *leaderMetrics) Off(name string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.leader[name] = false
+}
+
+// observe records that this replica saw the leader of a Lease change
+func (m *leaderMetrics) observe(name string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.transitions[name]++
+}
+
+// write writes the metrics in the text format of Prometheus
+func (m *leaderMetrics) write(w io.Writer) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	names := []string{}
+	for name := range m.leader {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintln(w, "# HELP pgo_leader_election_is_leader Whether this replica of the Operator is the leader.")
+	fmt.Fprintln(w, "# TYPE pgo_leader_election_is_leader gauge")
+	for _, name := range names {
+		value := 0
+		if m.leader[name] {
+			value = 1
+		}
+		fmt.Fprintf(w, "pgo_leader_election_is_leader{name=%q} %d\n", name, value)
+	}
+
+	fmt.Fprintln(w, "# HELP pgo_leader_election_acquisitions_total How many times this replica became the leader.")
+	fmt.Fprintln(w, "# TYPE pgo_leader_election_acquisitions_total counter")
+	for _, name := range names {
+		fmt.Fprintf(w, "pgo_leader_election_acquisitions_total{name=%q} %d\n", name, m.acquisitions[name])
+	}
+
+	fmt.Fprintln(w, "# HELP pgo_leader_election_transitions_total How many times this replica saw the leader change.")
+	fmt.Fprintln(w, "# TYPE pgo_leader_election_transitions_total counter")
+	for _, name := range names {
+		fmt.Fprintf(w, "pgo_leader_election_transitions_total{name=%q} %d\n", name, m.transitions[name])
+	}
+}
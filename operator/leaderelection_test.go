@@ -0,0 +1,97 @@
+package operator
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	coordination_v1 "k8s.io/api/coordination/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestLeaderMetrics(t *testing.T) {
+	m := newLeaderMetrics()
+	name := "postgres-operator-devtest"
+
+	// the leader elector turns the metric off as it is created
+	m.Off(name)
+	// another replica is the leader at first
+	m.observe(name)
+	// this replica takes over, and the metric stays on while it renews its Lease
+	m.observe(name)
+	m.On(name)
+	m.On(name)
+	// it loses the Lease and gets it back later on
+	m.Off(name)
+	m.observe(name)
+	m.observe(name)
+	m.On(name)
+
+	var out bytes.Buffer
+	m.write(&out)
+
+	for _, expected := range []string{
+		`pgo_leader_election_is_leader{name="postgres-operator-devtest"} 1`,
+		`pgo_leader_election_acquisitions_total{name="postgres-operator-devtest"} 2`,
+		`pgo_leader_election_transitions_total{name="postgres-operator-devtest"} 4`,
+	} {
+		if !strings.Contains(out.String(), expected+"\n") {
+			t.Fatalf("expected metric %q, got:\n%s", expected, out.String())
+		}
+	}
+
+	m.Off(name)
+	out.Reset()
+	m.write(&out)
+
+	if !strings.Contains(out.String(), `pgo_leader_election_is_leader{name="postgres-operator-devtest"} 0`) {
+		t.Fatalf("expected this replica not to be the leader, got:\n%s", out.String())
+	}
+}
+
+func TestIsLeaseHeldBy(t *testing.T) {
+	now := time.Date(2020, 5, 1, 12, 0, 0, 0, time.UTC)
+	holder, duration := "postgres-operator-abc", int32(15)
+
+	for _, test := range []struct {
+		name      string
+		holder    *string
+		renewTime time.Time
+		identity  string
+		expected  bool
+	}{
+		{"renewed", &holder, now.Add(-10 * time.Second), "postgres-operator-abc", true},
+		{"expired", &holder, now.Add(-20 * time.Second), "postgres-operator-abc", false},
+		{"other holder", &holder, now.Add(-10 * time.Second), "postgres-operator-def", false},
+		{"released", nil, now.Add(-10 * time.Second), "postgres-operator-abc", false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			renewTime := meta_v1.NewMicroTime(test.renewTime)
+			lease := &coordination_v1.Lease{Spec: coordination_v1.LeaseSpec{
+				HolderIdentity:       test.holder,
+				LeaseDurationSeconds: &duration,
+				RenewTime:            &renewTime,
+			}}
+
+			if held := isLeaseHeldBy(lease, test.identity, now); held != test.expected {
+				t.Fatalf("expected %t, got %t", test.expected, held)
+			}
+		})
+	}
+}
@@ -0,0 +1,190 @@
+package operator
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/config"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// reservedAnnotationPrefix is the prefix of the annotations that the Operator manages itself,
+// which cannot be set from the metadata of a cluster
+const reservedAnnotationPrefix = "crunchydata.com/"
+
+// ValidateCustomMetadata ensures that the labels and annotations of the metadata of a cluster
+// can be set on its resources
+func ValidateCustomMetadata(cluster *crv1.Pgcluster) error {
+	spec := cluster.Spec.Metadata
+
+	for _, component := range []struct {
+		name     string
+		metadata crv1.ObjectMetadata
+	}{
+		{"", crv1.ObjectMetadata{Labels: spec.Labels, Annotations: spec.Annotations}},
+		{"postgres ", spec.Postgres},
+		{"pgbackrest ", spec.PgBackRest},
+		{"pgbouncer ", spec.PgBouncer},
+	} {
+		for key, value := range component.metadata.Labels {
+			if errs := validation.IsQualifiedName(key); len(errs) != 0 {
+				return fmt.Errorf("invalid %slabel %q: %s", component.name, key, strings.Join(errs, "; "))
+			}
+			if errs := validation.IsValidLabelValue(value); len(errs) != 0 {
+				return fmt.Errorf("invalid value %q of %slabel %q: %s", value, component.name, key,
+					strings.Join(errs, "; "))
+			}
+		}
+
+		for key := range component.metadata.Annotations {
+			if errs := validation.IsQualifiedName(key); len(errs) != 0 {
+				return fmt.Errorf("invalid %sannotation %q: %s", component.name, key, strings.Join(errs, "; "))
+			}
+			if strings.HasPrefix(key, reservedAnnotationPrefix) {
+				return fmt.Errorf("%sannotation %q is reserved for the Operator", component.name, key)
+			}
+		}
+	}
+
+	return nil
+}
+
+// GetCustomMetadata returns the labels and annotations that are set on the resources of a
+// component of a cluster, i.e. those of the cluster merged with those of the component, which
+// take precedence. Those of the cluster alone are returned if there is no component
+func GetCustomMetadata(cluster *crv1.Pgcluster, component *crv1.ObjectMetadata) (map[string]string, map[string]string) {
+	labels := map[string]string{}
+	annotations := map[string]string{}
+
+	for key, value := range cluster.Spec.Metadata.Labels {
+		labels[key] = value
+	}
+	for key, value := range cluster.Spec.Metadata.Annotations {
+		annotations[key] = value
+	}
+
+	if component != nil {
+		for key, value := range component.Labels {
+			labels[key] = value
+		}
+		for key, value := range component.Annotations {
+			annotations[key] = value
+		}
+	}
+
+	return labels, annotations
+}
+
+// SetCustomMetadata sets the custom labels and annotations of a cluster on the metadata of a
+// resource, returning whether the metadata changed. Like the chargeback labels, the keys that are
+// set are recorded in annotations, so that a label or an annotation that is removed from the spec
+// is removed from the resource, and so that one that the resource has for another reason is
+// never overwritten
+func SetCustomMetadata(meta *meta_v1.ObjectMeta, labels, annotations map[string]string) bool {
+	changedLabels := setTrackedMetadata(meta, &meta.Labels, labels, config.ANNOTATION_CUSTOM_LABELS)
+	changedAnnotations := setTrackedMetadata(meta, &meta.Annotations, annotations,
+		config.ANNOTATION_CUSTOM_ANNOTATIONS)
+
+	return changedLabels || changedAnnotations
+}
+
+// SetServiceAnnotations sets the annotations that the spec of a cluster has for one of its
+// Services on the metadata of the Service, returning whether the metadata changed. The keys that
+// are set are recorded apart from the custom annotations, so that either can be removed from the
+// spec on its own
+func SetServiceAnnotations(meta *meta_v1.ObjectMeta, annotations map[string]string) bool {
+	return setTrackedMetadata(meta, &meta.Annotations, annotations,
+		config.ANNOTATION_SERVICE_ANNOTATIONS)
+}
+
+// setTrackedMetadata sets values on the labels or the annotations of a resource, recording their
+// keys in an annotation of the resource. A key that was recorded before but is no longer among
+// the values is removed, and a key that the resource has but that was not recorded is left as it
+// is. It returns whether the metadata changed
+func setTrackedMetadata(meta *meta_v1.ObjectMeta, target *map[string]string, values map[string]string,
+	annotation string) bool {
+	changed := false
+
+	previous := map[string]bool{}
+	for _, key := range strings.Split(meta.Annotations[annotation], ",") {
+		if key != "" {
+			previous[key] = true
+		}
+	}
+
+	if *target == nil {
+		*target = map[string]string{}
+	}
+
+	for key := range previous {
+		if _, ok := values[key]; !ok {
+			delete(*target, key)
+			changed = true
+		}
+	}
+
+	keys := []string{}
+	for key, value := range values {
+		current, ok := (*target)[key]
+
+		// the key was not set by the Operator, so it is left as it is
+		if ok && !previous[key] {
+			continue
+		}
+
+		if !ok || current != value {
+			(*target)[key] = value
+			changed = true
+		}
+
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+	tracked := strings.Join(keys, ",")
+
+	if tracked != meta.Annotations[annotation] {
+		if meta.Annotations == nil {
+			meta.Annotations = map[string]string{}
+		}
+
+		if tracked == "" {
+			delete(meta.Annotations, annotation)
+		} else {
+			meta.Annotations[annotation] = tracked
+		}
+
+		changed = true
+	}
+
+	return changed
+}
+
+// AddCustomMetadata sets the custom labels and annotations of a component of a cluster on the
+// metadata of a resource that is about to be created, e.g. on a Deployment and on the template of
+// its Pods
+func AddCustomMetadata(cluster *crv1.Pgcluster, component *crv1.ObjectMetadata, metas ...*meta_v1.ObjectMeta) {
+	labels, annotations := GetCustomMetadata(cluster, component)
+
+	for _, meta := range metas {
+		SetCustomMetadata(meta, labels, annotations)
+	}
+}
@@ -0,0 +1,106 @@
+package operator
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"reflect"
+	"testing"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/config"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGetCustomMetadata(t *testing.T) {
+	cluster := &crv1.Pgcluster{}
+	cluster.Spec.Metadata = crv1.MetadataSpec{
+		Labels:      map[string]string{"team": "payments", "tier": "data"},
+		Annotations: map[string]string{"owner": "dba"},
+		PgBouncer: crv1.ObjectMetadata{
+			Labels:      map[string]string{"tier": "pooler"},
+			Annotations: map[string]string{"sidecar.istio.io/inject": "true"},
+		},
+	}
+
+	labels, annotations := GetCustomMetadata(cluster, nil)
+	if !reflect.DeepEqual(labels, cluster.Spec.Metadata.Labels) ||
+		!reflect.DeepEqual(annotations, cluster.Spec.Metadata.Annotations) {
+		t.Fatalf("expected the metadata of the cluster, got %v %v", labels, annotations)
+	}
+
+	// the metadata of a component takes precedence over that of the cluster
+	labels, annotations = GetCustomMetadata(cluster, &cluster.Spec.Metadata.PgBouncer)
+	if expected := map[string]string{"team": "payments", "tier": "pooler"}; !reflect.DeepEqual(labels, expected) {
+		t.Errorf("unexpected labels. expected %v, got %v", expected, labels)
+	}
+	if expected := map[string]string{"owner": "dba", "sidecar.istio.io/inject": "true"}; !reflect.DeepEqual(
+		annotations, expected) {
+		t.Errorf("unexpected annotations. expected %v, got %v", expected, annotations)
+	}
+
+	if cluster.Spec.Metadata.Labels["tier"] != "data" {
+		t.Errorf("expected the spec of the cluster not to be modified")
+	}
+}
+
+func TestSetCustomMetadata(t *testing.T) {
+	meta := meta_v1.ObjectMeta{
+		Name:        "hippo",
+		Labels:      map[string]string{config.LABEL_PG_CLUSTER: "hippo", "team": "dba"},
+		Annotations: map[string]string{"owner": "someone"},
+	}
+
+	labels := map[string]string{"team": "payments", "cost-center": "cc-42"}
+	annotations := map[string]string{"owner": "dba", "sidecar.istio.io/inject": "true"}
+
+	if !SetCustomMetadata(&meta, labels, annotations) {
+		t.Fatalf("expected the metadata to change")
+	}
+
+	// what the resource had for another reason is left as it is
+	if expected := map[string]string{
+		config.LABEL_PG_CLUSTER: "hippo",
+		"team":                  "dba",
+		"cost-center":           "cc-42",
+	}; !reflect.DeepEqual(meta.Labels, expected) {
+		t.Fatalf("unexpected labels. expected %v, got %v", expected, meta.Labels)
+	}
+	if expected := map[string]string{
+		"owner":                              "someone",
+		"sidecar.istio.io/inject":            "true",
+		config.ANNOTATION_CUSTOM_LABELS:      "cost-center",
+		config.ANNOTATION_CUSTOM_ANNOTATIONS: "sidecar.istio.io/inject",
+	}; !reflect.DeepEqual(meta.Annotations, expected) {
+		t.Fatalf("unexpected annotations. expected %v, got %v", expected, meta.Annotations)
+	}
+
+	if SetCustomMetadata(&meta, labels, annotations) {
+		t.Fatalf("expected the metadata to be unchanged")
+	}
+
+	// what is taken out of the spec is removed, along with the record of it
+	if !SetCustomMetadata(&meta, map[string]string{}, map[string]string{}) {
+		t.Fatalf("expected the metadata to change")
+	}
+
+	if expected := map[string]string{config.LABEL_PG_CLUSTER: "hippo", "team": "dba"}; !reflect.DeepEqual(
+		meta.Labels, expected) {
+		t.Fatalf("unexpected labels. expected %v, got %v", expected, meta.Labels)
+	}
+	if expected := map[string]string{"owner": "someone"}; !reflect.DeepEqual(meta.Annotations, expected) {
+		t.Fatalf("unexpected annotations. expected %v, got %v", expected, meta.Annotations)
+	}
+}
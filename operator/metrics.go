@@ -0,0 +1,349 @@
+package operator
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// queueDurationBuckets are the upper bounds, in seconds, of the buckets of the histograms of how
+// long items wait in and are processed off the work queues
+var queueDurationBuckets = []float64{.001, .01, .1, 1, 10, 60, 300}
+
+// gauge is a metric with a single value that can go up and down. It is used for the counters as
+// well, which only ever go up
+type gauge struct {
+	mutex sync.Mutex
+	value float64
+}
+
+// Inc adds one to the gauge
+func (g *gauge) Inc() { g.add(1) }
+
+// Dec takes one off the gauge
+func (g *gauge) Dec() { g.add(-1) }
+
+// Set sets the value of the gauge
+func (g *gauge) Set(value float64) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	g.value = value
+}
+
+// add adds a delta to the value of the gauge
+func (g *gauge) add(delta float64) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	g.value += delta
+}
+
+// get returns the value of the gauge
+func (g *gauge) get() float64 {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	return g.value
+}
+
+// histogram counts observations in buckets by their upper bounds
+type histogram struct {
+	mutex sync.Mutex
+	// counts are the number of observations in each bucket, i.e. that are no greater than the
+	// bound of the bucket, and are not cumulative
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// newHistogram returns a histogram with the buckets of queueDurationBuckets
+func newHistogram() *histogram {
+	return &histogram{counts: make([]uint64, len(queueDurationBuckets))}
+}
+
+// Observe adds an observation to the histogram
+func (h *histogram) Observe(value float64) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.count++
+	h.sum += value
+
+	if i := sort.SearchFloat64s(queueDurationBuckets, value); i < len(h.counts) {
+		h.counts[i]++
+	}
+}
+
+// queueMetricSet holds the metrics of a single work queue
+type queueMetricSet struct {
+	depth, adds, retries           *gauge
+	unfinishedWork, longestRunning *gauge
+	queueDuration, workDuration    *histogram
+}
+
+// queueMetrics keeps the metrics of the work queues of the controllers, by the name of their
+// queue, as well as whether their informers have synced their caches. They are served in the
+// text format of Prometheus when MetricsPort is set
+type queueMetrics struct {
+	mutex  sync.Mutex
+	queues map[string]*queueMetricSet
+	// informers report whether the informers have synced their caches, by the same names as the
+	// queues are
+	informers map[string]func() bool
+}
+
+// queues holds the metrics of the work queues and informers of the Operator
+var queues = newQueueMetrics()
+
+// newQueueMetrics returns metrics of the work queues that have not recorded anything yet
+func newQueueMetrics() *queueMetrics {
+	return &queueMetrics{
+		queues:    map[string]*queueMetricSet{},
+		informers: map[string]func() bool{},
+	}
+}
+
+// QueueMetricsProvider returns what the work queues of the controllers record their metrics with.
+// Only the queues that are named, by the controller and then the namespace they are for separated
+// by a "/", record any metrics
+func QueueMetricsProvider() workqueue.MetricsProvider {
+	return queues
+}
+
+// RegisterInformerMetric records whether the informer of a controller for a namespace has synced
+// its cache
+func RegisterInformerMetric(controller, namespace string, synced func() bool) {
+	queues.mutex.Lock()
+	defer queues.mutex.Unlock()
+
+	queues.informers[controller+"/"+namespace] = synced
+}
+
+// DeleteNamespaceMetrics stops serving the metrics of the work queues and informers of the
+// controllers for a namespace, e.g. once the namespace is no longer watched
+func DeleteNamespaceMetrics(namespace string) {
+	queues.mutex.Lock()
+	defer queues.mutex.Unlock()
+
+	for name := range queues.queues {
+		if _, ns := splitQueueName(name); ns == namespace {
+			delete(queues.queues, name)
+		}
+	}
+	for name := range queues.informers {
+		if _, ns := splitQueueName(name); ns == namespace {
+			delete(queues.informers, name)
+		}
+	}
+}
+
+// queue returns the metrics of a work queue, which are created along with the queue the first
+// time any of them is asked for
+func (m *queueMetrics) queue(name string) *queueMetricSet {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	set, ok := m.queues[name]
+	if !ok {
+		set = &queueMetricSet{
+			depth:          &gauge{},
+			adds:           &gauge{},
+			retries:        &gauge{},
+			unfinishedWork: &gauge{},
+			longestRunning: &gauge{},
+			queueDuration:  newHistogram(),
+			workDuration:   newHistogram(),
+		}
+		m.queues[name] = set
+	}
+
+	return set
+}
+
+// NewDepthMetric returns the metric of how many items are in a work queue
+func (m *queueMetrics) NewDepthMetric(name string) workqueue.GaugeMetric {
+	return m.queue(name).depth
+}
+
+// NewAddsMetric returns the metric of how many items were added to a work queue
+func (m *queueMetrics) NewAddsMetric(name string) workqueue.CounterMetric {
+	return m.queue(name).adds
+}
+
+// NewLatencyMetric returns the metric of how long items wait in a work queue
+func (m *queueMetrics) NewLatencyMetric(name string) workqueue.HistogramMetric {
+	return m.queue(name).queueDuration
+}
+
+// NewWorkDurationMetric returns the metric of how long items of a work queue take to process
+func (m *queueMetrics) NewWorkDurationMetric(name string) workqueue.HistogramMetric {
+	return m.queue(name).workDuration
+}
+
+// NewUnfinishedWorkSecondsMetric returns the metric of how long the items of a work queue that
+// are being processed have been processed for altogether
+func (m *queueMetrics) NewUnfinishedWorkSecondsMetric(name string) workqueue.SettableGaugeMetric {
+	return m.queue(name).unfinishedWork
+}
+
+// NewLongestRunningProcessorSecondsMetric returns the metric of how long the item of a work
+// queue that has been processed for the longest has been processed for
+func (m *queueMetrics) NewLongestRunningProcessorSecondsMetric(name string) workqueue.SettableGaugeMetric {
+	return m.queue(name).longestRunning
+}
+
+// NewRetriesMetric returns the metric of how many items were added back to a work queue to retry
+func (m *queueMetrics) NewRetriesMetric(name string) workqueue.CounterMetric {
+	return m.queue(name).retries
+}
+
+// write writes the metrics in the text format of Prometheus
+func (m *queueMetrics) write(w io.Writer) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	names := []string{}
+	for name := range m.queues {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	gauges := []struct {
+		name, help, kind string
+		value            func(*queueMetricSet) *gauge
+	}{
+		{"pgo_workqueue_depth", "How many items are in the work queue.", "gauge",
+			func(s *queueMetricSet) *gauge { return s.depth }},
+		{"pgo_workqueue_adds_total", "How many items were added to the work queue.", "counter",
+			func(s *queueMetricSet) *gauge { return s.adds }},
+		{"pgo_workqueue_retries_total", "How many items were added back to the work queue to retry.", "counter",
+			func(s *queueMetricSet) *gauge { return s.retries }},
+		{"pgo_workqueue_unfinished_work_seconds", "How long the items being processed have been processed for altogether.", "gauge",
+			func(s *queueMetricSet) *gauge { return s.unfinishedWork }},
+		{"pgo_workqueue_longest_running_processor_seconds", "How long the longest running item has been processed for.", "gauge",
+			func(s *queueMetricSet) *gauge { return s.longestRunning }},
+	}
+
+	for _, metric := range gauges {
+		fmt.Fprintf(w, "# HELP %s %s\n", metric.name, metric.help)
+		fmt.Fprintf(w, "# TYPE %s %s\n", metric.name, metric.kind)
+		for _, name := range names {
+			fmt.Fprintf(w, "%s{%s} %s\n", metric.name, queueLabels(name),
+				formatValue(metric.value(m.queues[name]).get()))
+		}
+	}
+
+	histograms := []struct {
+		name, help string
+		value      func(*queueMetricSet) *histogram
+	}{
+		{"pgo_workqueue_queue_duration_seconds", "How long items wait in the work queue before they are processed.",
+			func(s *queueMetricSet) *histogram { return s.queueDuration }},
+		{"pgo_workqueue_work_duration_seconds", "How long items of the work queue take to process.",
+			func(s *queueMetricSet) *histogram { return s.workDuration }},
+	}
+
+	for _, metric := range histograms {
+		fmt.Fprintf(w, "# HELP %s %s\n", metric.name, metric.help)
+		fmt.Fprintf(w, "# TYPE %s histogram\n", metric.name)
+		for _, name := range names {
+			writeHistogram(w, metric.name, queueLabels(name), metric.value(m.queues[name]))
+		}
+	}
+
+	informers := []string{}
+	for name := range m.informers {
+		informers = append(informers, name)
+	}
+	sort.Strings(informers)
+
+	fmt.Fprintln(w, "# HELP pgo_informer_synced Whether the informer has synced its cache.")
+	fmt.Fprintln(w, "# TYPE pgo_informer_synced gauge")
+	for _, name := range informers {
+		value := 0
+		if m.informers[name]() {
+			value = 1
+		}
+		fmt.Fprintf(w, "pgo_informer_synced{%s} %d\n", queueLabels(name), value)
+	}
+}
+
+// writeHistogram writes a histogram in the text format of Prometheus, whose buckets are cumulative
+func writeHistogram(w io.Writer, name, labels string, h *histogram) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	cumulative := uint64(0)
+	for i, bound := range queueDurationBuckets {
+		cumulative += h.counts[i]
+		fmt.Fprintf(w, "%s_bucket{%s,le=%q} %d\n", name, labels, formatValue(bound), cumulative)
+	}
+	fmt.Fprintf(w, "%s_bucket{%s,le=\"+Inf\"} %d\n", name, labels, h.count)
+	fmt.Fprintf(w, "%s_sum{%s} %s\n", name, labels, formatValue(h.sum))
+	fmt.Fprintf(w, "%s_count{%s} %d\n", name, labels, h.count)
+}
+
+// splitQueueName returns the controller and the namespace of the name of a work queue
+func splitQueueName(name string) (string, string) {
+	parts := strings.SplitN(name, "/", 2)
+	if len(parts) < 2 {
+		return name, ""
+	}
+	return parts[0], parts[1]
+}
+
+// queueLabels returns the labels of the metrics of a work queue or informer by its name
+func queueLabels(name string) string {
+	controller, namespace := splitQueueName(name)
+	return fmt.Sprintf("controller=%q,namespace=%q", controller, namespace)
+}
+
+// formatValue formats the value of a metric the way Prometheus parses it
+func formatValue(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// ServeMetrics serves the metrics of the leader election and of the work queues and informers of
+// the controllers at /metrics on the given port, in the text format of Prometheus
+func ServeMetrics(port string) {
+	log.Infof("serving metrics on port %s", port)
+
+	if err := http.ListenAndServe(":"+port, newMetricsHandler()); err != nil {
+		log.Errorf("could not serve metrics: %s", err)
+	}
+}
+
+// newMetricsHandler returns the handler of the metrics endpoint
+func newMetricsHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		leadership.write(w)
+		queues.write(w)
+	})
+
+	return mux
+}
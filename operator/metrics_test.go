@@ -0,0 +1,98 @@
+package operator
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestQueueMetrics(t *testing.T) {
+	m := newQueueMetrics()
+	name := "pgcluster/pgouser1"
+
+	// the work queue records its metrics the way a named rate limited queue does
+	depth, adds, retries := m.NewDepthMetric(name), m.NewAddsMetric(name), m.NewRetriesMetric(name)
+	latency, work := m.NewLatencyMetric(name), m.NewWorkDurationMetric(name)
+
+	for _, wait := range []float64{0.0005, 0.05, 5} {
+		adds.Inc()
+		depth.Inc()
+		depth.Dec()
+		latency.Observe(wait)
+	}
+	depth.Inc()
+	adds.Inc()
+	retries.Inc()
+	work.Observe(100)
+	m.NewLongestRunningProcessorSecondsMetric(name).Set(2.5)
+
+	synced := false
+	m.informers[name] = func() bool { return synced }
+
+	var out bytes.Buffer
+	m.write(&out)
+
+	for _, expected := range []string{
+		`pgo_workqueue_depth{controller="pgcluster",namespace="pgouser1"} 1`,
+		`pgo_workqueue_adds_total{controller="pgcluster",namespace="pgouser1"} 4`,
+		`pgo_workqueue_retries_total{controller="pgcluster",namespace="pgouser1"} 1`,
+		`pgo_workqueue_longest_running_processor_seconds{controller="pgcluster",namespace="pgouser1"} 2.5`,
+		`pgo_workqueue_queue_duration_seconds_bucket{controller="pgcluster",namespace="pgouser1",le="0.001"} 1`,
+		`pgo_workqueue_queue_duration_seconds_bucket{controller="pgcluster",namespace="pgouser1",le="1"} 2`,
+		`pgo_workqueue_queue_duration_seconds_bucket{controller="pgcluster",namespace="pgouser1",le="+Inf"} 3`,
+		`pgo_workqueue_queue_duration_seconds_count{controller="pgcluster",namespace="pgouser1"} 3`,
+		`pgo_workqueue_work_duration_seconds_bucket{controller="pgcluster",namespace="pgouser1",le="300"} 1`,
+		`pgo_workqueue_work_duration_seconds_sum{controller="pgcluster",namespace="pgouser1"} 100`,
+		`pgo_informer_synced{controller="pgcluster",namespace="pgouser1"} 0`,
+	} {
+		if !strings.Contains(out.String(), expected+"\n") {
+			t.Fatalf("expected metric %q, got:\n%s", expected, out.String())
+		}
+	}
+
+	synced = true
+	out.Reset()
+	m.write(&out)
+
+	if !strings.Contains(out.String(), `pgo_informer_synced{controller="pgcluster",namespace="pgouser1"} 1`) {
+		t.Fatalf("expected the informer to have synced, got:\n%s", out.String())
+	}
+}
+
+func TestDeleteNamespaceMetrics(t *testing.T) {
+	defer func() { queues = newQueueMetrics() }()
+
+	queues = newQueueMetrics()
+	queues.NewAddsMetric("pgtask/pgouser1").Inc()
+	queues.NewAddsMetric("pgtask/pgouser2").Inc()
+	RegisterInformerMetric("pod", "pgouser1", func() bool { return true })
+	RegisterInformerMetric("pod", "pgouser2", func() bool { return true })
+
+	DeleteNamespaceMetrics("pgouser1")
+
+	var out bytes.Buffer
+	queues.write(&out)
+
+	if strings.Contains(out.String(), `namespace="pgouser1"`) {
+		t.Fatalf("expected no metrics for the deleted namespace, got:\n%s", out.String())
+	}
+	if !strings.Contains(out.String(), `pgo_workqueue_adds_total{controller="pgtask",namespace="pgouser2"} 1`) ||
+		!strings.Contains(out.String(), `pgo_informer_synced{controller="pod",namespace="pgouser2"} 1`) {
+		t.Fatalf("expected the metrics of the other namespace, got:\n%s", out.String())
+	}
+}
@@ -53,6 +53,8 @@ type pgDumpJobTemplateFields struct {
 	PgDumpAll          string
 	PgDumpPVC          string
 	ContainerResources string
+	NodeSelectorLabels string
+	Tolerations        string
 }
 
 // Dump ...
@@ -93,6 +95,14 @@ func Dump(namespace string, clientset *kubernetes.Clientset, client *rest.RESTCl
 
 	}
 
+	// the cluster provides the node selector and tolerations of the Job, so if it cannot be
+	// found, only the defaults are applied
+	cluster := crv1.Pgcluster{}
+	if _, err := kubeapi.Getpgcluster(client, &cluster,
+		task.Spec.Parameters[config.LABEL_PG_CLUSTER], namespace); err != nil {
+		log.Error(err)
+	}
+
 	// this task name should match
 	taskName := task.Name
 	jobName := taskName + "-" + util.RandStringBytesRmndr(4)
@@ -115,6 +125,8 @@ func Dump(namespace string, clientset *kubernetes.Clientset, client *rest.RESTCl
 		PgDumpAll:          task.Spec.Parameters[config.LABEL_PGDUMP_ALL],
 		PgDumpPVC:          pvcName,
 		ContainerResources: cr,
+		NodeSelectorLabels: operator.GetNodeSelectorJSON(&cluster),
+		Tolerations:        operator.GetTolerationsJSON(&cluster),
 	}
 
 	var doc2 bytes.Buffer
@@ -139,6 +151,13 @@ func Dump(namespace string, clientset *kubernetes.Clientset, client *rest.RESTCl
 	operator.SetContainerImageOverride(config.CONTAINER_IMAGE_CRUNCHY_PGDUMP,
 		&newjob.Spec.Template.Spec.Containers[0])
 
+	// apply the node affinity and the PriorityClass of the cluster
+	operator.AddPodScheduling(&cluster, nil, &newjob.Spec.Template.Spec)
+
+	// set the chargeback labels of the Namespace
+	operator.AddChargebackLabels(clientset, namespace, &newjob.ObjectMeta,
+		&newjob.Spec.Template.ObjectMeta)
+
 	_, err = kubeapi.CreateJob(clientset, &newjob, namespace)
 
 	if err != nil {
@@ -48,6 +48,8 @@ type restorejobTemplateFields struct {
 	CCPImageTag         string
 	PgPort              string
 	NodeSelector        string
+	NodeSelectorLabels  string
+	Tolerations         string
 }
 
 // Restore ...
@@ -92,6 +94,8 @@ func Restore(namespace string, clientset *kubernetes.Clientset, restclient *rest
 		CCPImagePrefix:      operator.Pgo.Cluster.CCPImagePrefix,
 		CCPImageTag:         operator.Pgo.Cluster.CCPImageTag,
 		NodeSelector:        operator.GetAffinity(task.Spec.Parameters["NodeLabelKey"], task.Spec.Parameters["NodeLabelValue"], "In"),
+		NodeSelectorLabels:  operator.GetNodeSelectorJSON(&cluster),
+		Tolerations:         operator.GetTolerationsJSON(&cluster),
 	}
 
 	var doc2 bytes.Buffer
@@ -117,6 +121,13 @@ func Restore(namespace string, clientset *kubernetes.Clientset, restclient *rest
 	operator.SetContainerImageOverride(config.CONTAINER_IMAGE_CRUNCHY_PGRESTORE,
 		&newjob.Spec.Template.Spec.Containers[0])
 
+	// apply the node affinity and the PriorityClass of the cluster
+	operator.AddPodScheduling(&cluster, nil, &newjob.Spec.Template.Spec)
+
+	// set the chargeback labels of the Namespace
+	operator.AddChargebackLabels(clientset, namespace, &newjob.ObjectMeta,
+		&newjob.Spec.Template.ObjectMeta)
+
 	var jobName string
 	jobName, err = kubeapi.CreateJob(clientset, &newjob, namespace)
 	if err != nil {
@@ -123,6 +123,9 @@ func Create(clientset *kubernetes.Clientset, name, clusterName string, storageSp
 		return err
 	}
 
+	// set the chargeback labels of the Namespace
+	operator.AddChargebackLabels(clientset, namespace, &newpvc.ObjectMeta)
+
 	err = kubeapi.CreatePVC(clientset, &newpvc, namespace)
 	if err != nil {
 		return err
@@ -134,6 +137,50 @@ func Create(clientset *kubernetes.Clientset, name, clusterName string, storageSp
 
 }
 
+// CreateFromSnapshot creates a dynamically provisioned PVC whose volume is restored from a CSI
+// VolumeSnapshot rather than provisioned empty. The directory in the snapshot that the data is
+// in is recorded in an annotation, so that the instance that mounts it can move it into place
+func CreateFromSnapshot(clientset *kubernetes.Clientset, name, clusterName string, storageSpec *crv1.PgStorageSpec,
+	snapshotName, dataDirectory, namespace string) error {
+	var doc bytes.Buffer
+
+	pvcFields := TemplateFields{
+		Name:         name,
+		AccessMode:   storageSpec.AccessMode,
+		StorageClass: storageSpec.StorageClass,
+		ClusterName:  clusterName,
+		Size:         storageSpec.Size,
+	}
+
+	if err := config.PVCStorageClassTemplate.Execute(&doc, pvcFields); err != nil {
+		log.Error("error in pvc create exec" + err.Error())
+		return err
+	}
+
+	newpvc := v1.PersistentVolumeClaim{}
+	if err := json.Unmarshal(doc.Bytes(), &newpvc); err != nil {
+		log.Error("error unmarshalling json into PVC " + err.Error())
+		return err
+	}
+
+	apiGroup := kubeapi.VolumeSnapshotAPIGroup
+	newpvc.Spec.DataSource = &v1.TypedLocalObjectReference{
+		APIGroup: &apiGroup,
+		Kind:     kubeapi.VolumeSnapshotKind,
+		Name:     snapshotName,
+	}
+
+	if newpvc.Annotations == nil {
+		newpvc.Annotations = map[string]string{}
+	}
+	newpvc.Annotations[config.ANNOTATION_SNAPSHOT_DATA_DIRECTORY] = dataDirectory
+
+	// set the chargeback labels of the Namespace
+	operator.AddChargebackLabels(clientset, namespace, &newpvc.ObjectMeta)
+
+	return kubeapi.CreatePVC(clientset, &newpvc, namespace)
+}
+
 // Delete a pvc
 func Delete(clientset *kubernetes.Clientset, name string, namespace string) error {
 	var err error
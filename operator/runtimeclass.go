@@ -0,0 +1,107 @@
+package operator
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/config"
+	"github.com/crunchydata/postgres-operator/kubeapi"
+	log "github.com/sirupsen/logrus"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// runtimeClassHandlerGVisor is the handler of a RuntimeClass that runs pods in the gVisor
+	// sandbox
+	runtimeClassHandlerGVisor = "runsc"
+
+	// EventReasonInvalidRuntimeClass is the reason of the Warning events that are recorded when
+	// the RuntimeClass of a cluster cannot be used
+	EventReasonInvalidRuntimeClass = "InvalidRuntimeClass"
+)
+
+// GetRuntimeClassName returns the RuntimeClass that the pods of a PostgreSQL instance are run
+// with, i.e. the RuntimeClass of the replica if it has one, otherwise that of the cluster. If the
+// RuntimeClass cannot be used, a Warning event is recorded for the cluster and an error is
+// returned, so that the instance is not created with a runtime other than the one requested
+func GetRuntimeClassName(clientset *kubernetes.Clientset, cluster *crv1.Pgcluster,
+	replicaRuntimeClassName string) (string, error) {
+	name := cluster.Spec.RuntimeClassName
+
+	if replicaRuntimeClassName != "" {
+		name = replicaRuntimeClassName
+	}
+
+	if name == "" {
+		return "", nil
+	}
+
+	if err := ValidateRuntimeClass(clientset, cluster, name); err != nil {
+		RecordWarningEvent(clientset, cluster, EventReasonInvalidRuntimeClass, err.Error())
+		return "", err
+	}
+
+	return name, nil
+}
+
+// ValidateRuntimeClass ensures that the RuntimeClass exists, and that it is compatible with the
+// configuration of the cluster, e.g. gVisor cannot provide PostgreSQL with huge pages
+func ValidateRuntimeClass(clientset *kubernetes.Clientset, cluster *crv1.Pgcluster, name string) error {
+	runtimeClass, _, err := kubeapi.GetRuntimeClass(clientset, name)
+	if kerrors.IsNotFound(err) {
+		return fmt.Errorf("runtime class %q of cluster %s does not exist", name, cluster.Name)
+	} else if err != nil {
+		return err
+	}
+
+	if runtimeClass.Handler == runtimeClassHandlerGVisor && requiresHugePages(clientset, cluster) {
+		return fmt.Errorf("runtime class %q of cluster %s uses gVisor, which does not support "+
+			"\"huge_pages = on\"", name, cluster.Name)
+	}
+
+	return nil
+}
+
+// requiresHugePages determines whether or not PostgreSQL fails to start without huge pages, i.e.
+// if "huge_pages" is set to "on" in the configuration that Patroni manages for the cluster
+func requiresHugePages(clientset *kubernetes.Clientset, cluster *crv1.Pgcluster) bool {
+	dcsConfigMapName := cluster.Labels[config.LABEL_PGHA_SCOPE] + "-config"
+
+	dcsConfigMap, found := kubeapi.GetConfigMap(clientset, dcsConfigMapName, cluster.Namespace)
+	if !found {
+		return false
+	}
+
+	configJSON := struct {
+		PostgreSQL struct {
+			Parameters map[string]interface{} `json:"parameters"`
+		} `json:"postgresql"`
+	}{}
+
+	if err := json.Unmarshal([]byte(dcsConfigMap.ObjectMeta.Annotations["config"]), &configJSON); err != nil {
+		log.Error(err)
+		return false
+	}
+
+	hugePages := fmt.Sprint(configJSON.PostgreSQL.Parameters["huge_pages"])
+
+	return strings.EqualFold(hugePages, "on") || strings.EqualFold(hugePages, "true")
+}
@@ -0,0 +1,289 @@
+package operator
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/crunchydata/postgres-operator/config"
+	"github.com/crunchydata/postgres-operator/kubeapi"
+	jsonpatch "github.com/evanphx/json-patch"
+	log "github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	// safeModeReleasePeriod is how often the go-ahead to end safe mode is checked for
+	safeModeReleasePeriod = 10 * time.Second
+	// safeModeIntentLength is the length to which an intended change is cut when it is logged
+	safeModeIntentLength = 512
+)
+
+// safeMode holds back the reconciles of the Operator while it is active. A reconcile that is
+// held back is logged and deferred rather than dropped, and the deferred reconciles are carried
+// out in the order they came in once safe mode ends, so that no change is lost
+type safeMode struct {
+	mutex sync.Mutex
+	// active is whether the reconciles are held back
+	active bool
+	// draining is whether the deferred reconciles are being carried out, during which new
+	// reconciles are deferred as well so that they do not overtake the deferred ones
+	draining bool
+	// deferred are the reconciles that were held back
+	deferred []func()
+	// exemptions are the names of the reconciles that are never held back
+	exemptions map[string]bool
+}
+
+// safe is the safe mode of the Operator
+var safe = &safeMode{}
+
+// newSafeMode returns a safe mode that is active, exempting the given reconciles
+func newSafeMode(exemptions []string) *safeMode {
+	s := &safeMode{active: true, exemptions: map[string]bool{}}
+
+	for _, name := range exemptions {
+		s.exemptions[name] = true
+	}
+
+	return s
+}
+
+// StartSafeMode puts the Operator in safe mode if it is configured to start in it, i.e. if
+// SafeModeSeconds is set. Until the grace period is over, or the Operator is given the go-ahead
+// by annotating the pgo-config ConfigMap with "crunchydata.com/safe-mode-release", the
+// reconciles that are not exempt are only logged. This way an upgraded Operator can be checked
+// for what it intends to change in the existing clusters before it changes anything
+func StartSafeMode(clientset *kubernetes.Clientset) {
+	if Pgo.Pgo.SafeModeSeconds <= 0 {
+		return
+	}
+
+	safe = newSafeMode(Pgo.Pgo.SafeModeExemptions)
+
+	log.Infof("safe mode: holding back reconciles for %d seconds, except for %v",
+		Pgo.Pgo.SafeModeSeconds, Pgo.Pgo.SafeModeExemptions)
+
+	go func() {
+		timeout := time.After(time.Duration(Pgo.Pgo.SafeModeSeconds) * time.Second)
+		tick := time.NewTicker(safeModeReleasePeriod)
+		defer tick.Stop()
+
+		for {
+			select {
+			case <-timeout:
+				EndSafeMode("the grace period is over")
+				return
+			case <-tick.C:
+				if isSafeModeReleased(clientset) {
+					EndSafeMode("the go-ahead was given")
+					return
+				}
+			}
+		}
+	}()
+}
+
+// EndSafeMode ends safe mode, carrying out the reconciles that were held back
+func EndSafeMode(reason string) {
+	s := safe
+
+	s.mutex.Lock()
+	if !s.active {
+		s.mutex.Unlock()
+		return
+	}
+	s.active = false
+	s.draining = true
+	pending := len(s.deferred)
+	s.mutex.Unlock()
+
+	log.Infof("safe mode: ending as %s, carrying out %d deferred reconciles", reason, pending)
+
+	s.drain()
+}
+
+// InSafeMode returns whether the reconcile with the given name is held back by safe mode
+func InSafeMode(name string) bool {
+	return safe.holds(name)
+}
+
+// SafeReconcile carries out a reconcile, unless it is held back by safe mode, in which case
+// the intended change is logged and the reconcile is deferred until safe mode ends
+func SafeReconcile(name, intent string, reconcile func()) {
+	if safe.hold(name, intent, reconcile) {
+		return
+	}
+
+	reconcile()
+}
+
+// SafeEventHandler wraps the event handlers of a controller so that the events are subject to
+// safe mode. The reconciles are named after the controller, unless classify returns a more
+// specific name for an event, e.g. "failover", which lets such events be exempt from safe mode.
+// The old object of an add event, and the new object of a delete event, are nil
+func SafeEventHandler(controller string, classify func(oldObj, newObj interface{}) string,
+	handler cache.ResourceEventHandlerFuncs) cache.ResourceEventHandlerFuncs {
+	name := func(oldObj, newObj interface{}) string {
+		if classify != nil {
+			if name := classify(oldObj, newObj); name != "" {
+				return name
+			}
+		}
+		return controller
+	}
+
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			SafeReconcile(name(nil, obj), "add "+describeObject(controller, obj), func() {
+				handler.OnAdd(obj)
+			})
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			// a resync does not change anything, and is repeated anyway once safe mode ends
+			if isResync(oldObj, newObj) && InSafeMode(name(oldObj, newObj)) {
+				return
+			}
+
+			SafeReconcile(name(oldObj, newObj), "update "+describeObject(controller, newObj)+
+				": "+describeChange(oldObj, newObj), func() {
+				handler.OnUpdate(oldObj, newObj)
+			})
+		},
+		DeleteFunc: func(obj interface{}) {
+			SafeReconcile(name(obj, nil), "delete "+describeObject(controller, obj), func() {
+				handler.OnDelete(obj)
+			})
+		},
+	}
+}
+
+// hold defers a reconcile if it is held back, returning whether it was
+func (s *safeMode) hold(name, intent string, reconcile func()) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.exemptions[name] || !(s.active || s.draining) {
+		return false
+	}
+
+	if len(intent) > safeModeIntentLength {
+		intent = intent[:safeModeIntentLength] + "..."
+	}
+
+	log.Infof("safe mode: dry run of %s reconcile, deferring it: %s", name, intent)
+
+	s.deferred = append(s.deferred, reconcile)
+
+	return true
+}
+
+// holds returns whether a reconcile is held back
+func (s *safeMode) holds(name string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.active && !s.exemptions[name]
+}
+
+// drain carries out the deferred reconciles in order, including those that are deferred while
+// it does so
+func (s *safeMode) drain() {
+	for {
+		s.mutex.Lock()
+		if len(s.deferred) == 0 {
+			s.draining = false
+			s.mutex.Unlock()
+			return
+		}
+		reconcile := s.deferred[0]
+		s.deferred = s.deferred[1:]
+		s.mutex.Unlock()
+
+		reconcile()
+	}
+}
+
+// isSafeModeReleased returns whether the go-ahead to end safe mode was given. The annotation
+// that gives it is removed, so that it does not release the safe mode of the next start
+func isSafeModeReleased(clientset *kubernetes.Clientset) bool {
+	configMap, found := kubeapi.GetConfigMap(clientset, config.CustomConfigMapName, PgoNamespace)
+	if !found {
+		return false
+	}
+
+	if _, ok := configMap.ObjectMeta.Annotations[config.ANNOTATION_SAFE_MODE_RELEASE]; !ok {
+		return false
+	}
+
+	delete(configMap.ObjectMeta.Annotations, config.ANNOTATION_SAFE_MODE_RELEASE)
+
+	if err := kubeapi.UpdateConfigMap(clientset, configMap, PgoNamespace); err != nil {
+		log.Warn(err)
+	}
+
+	return true
+}
+
+// describeObject returns the kind and key of an object for the log
+func describeObject(controller string, obj interface{}) string {
+	key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+	if err != nil {
+		key = "unknown"
+	}
+
+	return fmt.Sprintf("%s %s", controller, key)
+}
+
+// describeChange returns the change between two versions of an object as a JSON merge patch
+func describeChange(oldObj, newObj interface{}) string {
+	oldJSON, err := json.Marshal(oldObj)
+	if err != nil {
+		return err.Error()
+	}
+
+	newJSON, err := json.Marshal(newObj)
+	if err != nil {
+		return err.Error()
+	}
+
+	patch, err := jsonpatch.CreateMergePatch(oldJSON, newJSON)
+	if err != nil {
+		return err.Error()
+	}
+
+	return string(patch)
+}
+
+// isResync returns whether an update is a resync of the informer, i.e. the object is unchanged
+func isResync(oldObj, newObj interface{}) bool {
+	oldMeta, err := meta.Accessor(oldObj)
+	if err != nil {
+		return false
+	}
+
+	newMeta, err := meta.Accessor(newObj)
+	if err != nil {
+		return false
+	}
+
+	return oldMeta.GetResourceVersion() == newMeta.GetResourceVersion()
+}
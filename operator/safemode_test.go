@@ -0,0 +1,123 @@
+package operator
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"reflect"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestSafeMode(t *testing.T) {
+	defer func(s *safeMode) { safe = s }(safe)
+
+	safe = newSafeMode([]string{"failover"})
+
+	handled := []string{}
+	reconcile := func(name string) func() {
+		return func() { handled = append(handled, name) }
+	}
+
+	SafeReconcile("pgcluster", "add pgcluster ns/hippo", reconcile("add"))
+	SafeReconcile("failover", "update pod ns/hippo-abcd", reconcile("failover"))
+	SafeReconcile("pgcluster", "update pgcluster ns/hippo", reconcile("update"))
+
+	// only the exempt reconcile is carried out in safe mode
+	if !reflect.DeepEqual(handled, []string{"failover"}) {
+		t.Fatalf("unexpected reconciles in safe mode: %v", handled)
+	}
+
+	if !InSafeMode("periodic") || InSafeMode("failover") {
+		t.Fatalf("unexpected exemptions from safe mode")
+	}
+
+	EndSafeMode("testing")
+
+	// the deferred reconciles are carried out in the order they came in
+	if !reflect.DeepEqual(handled, []string{"failover", "add", "update"}) {
+		t.Fatalf("unexpected reconciles once safe mode ended: %v", handled)
+	}
+
+	SafeReconcile("pgcluster", "delete pgcluster ns/hippo", reconcile("delete"))
+
+	if !reflect.DeepEqual(handled, []string{"failover", "add", "update", "delete"}) {
+		t.Fatalf("unexpected reconciles after safe mode: %v", handled)
+	}
+
+	if InSafeMode("periodic") {
+		t.Fatalf("expected safe mode to have ended")
+	}
+
+	// ending it again does not carry out anything twice
+	EndSafeMode("testing")
+
+	if len(handled) != 4 {
+		t.Fatalf("unexpected reconciles after safe mode ended twice: %v", handled)
+	}
+}
+
+func TestSafeEventHandler(t *testing.T) {
+	defer func(s *safeMode) { safe = s }(safe)
+
+	safe = newSafeMode([]string{"failover"})
+
+	handled := []string{}
+	handler := SafeEventHandler("pod", func(oldObj, newObj interface{}) string {
+		if pod, ok := newObj.(*v1.Pod); ok && pod.Labels["role"] == "master" {
+			return "failover"
+		}
+		return ""
+	}, cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			handled = append(handled, "add "+obj.(*v1.Pod).ResourceVersion)
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			handled = append(handled, "update "+newObj.(*v1.Pod).ResourceVersion)
+		},
+		DeleteFunc: func(obj interface{}) {
+			handled = append(handled, "delete "+obj.(*v1.Pod).ResourceVersion)
+		},
+	})
+
+	pod := func(resourceVersion, role string) *v1.Pod {
+		return &v1.Pod{ObjectMeta: meta_v1.ObjectMeta{
+			Name: "hippo-abcd", Namespace: "ns", ResourceVersion: resourceVersion,
+			Labels: map[string]string{"role": role},
+		}}
+	}
+
+	handler.OnAdd(pod("1", "replica"))
+	handler.OnUpdate(pod("1", "replica"), pod("2", "replica"))
+	// a resync is dropped rather than deferred
+	handler.OnUpdate(pod("2", "replica"), pod("2", "replica"))
+	// a promotion is exempt
+	handler.OnUpdate(pod("2", "replica"), pod("3", "master"))
+	handler.OnDelete(pod("3", "master"))
+
+	if !reflect.DeepEqual(handled, []string{"update 3"}) {
+		t.Fatalf("unexpected events in safe mode: %v", handled)
+	}
+
+	EndSafeMode("testing")
+
+	expected := []string{"update 3", "add 1", "update 2", "delete 3"}
+	if !reflect.DeepEqual(handled, expected) {
+		t.Fatalf("unexpected events once safe mode ended. expected %v, got %v", expected, handled)
+	}
+}
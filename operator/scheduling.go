@@ -0,0 +1,275 @@
+package operator
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/config"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// ZoneTopologyKey is the label of a node with its availability zone
+	ZoneTopologyKey = "topology.kubernetes.io/zone"
+	// zoneTopologyKeyBeta is the label of the zone of a node before Kubernetes 1.17
+	zoneTopologyKeyBeta = "failure-domain.beta.kubernetes.io/zone"
+)
+
+// GetNodeSelectorJSON creates the JSON snippet of the node selector that is applied to the Pods of
+// a cluster, i.e. the default node selector of the Operator merged with that of the cluster
+func GetNodeSelectorJSON(cluster *crv1.Pgcluster) string {
+	doc, err := json.Marshal(GetNodeSelector(cluster))
+	if err != nil {
+		log.Error(err)
+		return "{}"
+	}
+
+	return string(doc)
+}
+
+// GetTolerationsJSON creates the JSON snippet of the tolerations that are applied to the Pods of a
+// cluster, i.e. the default tolerations of the Operator merged with those of the cluster
+func GetTolerationsJSON(cluster *crv1.Pgcluster) string {
+	doc, err := json.Marshal(GetTolerations(cluster))
+	if err != nil {
+		log.Error(err)
+		return "[]"
+	}
+
+	return string(doc)
+}
+
+// GetNodeSelector returns the node selector that is applied to the Pods of a cluster, i.e. the
+// default node selector of the Operator merged with that of the cluster
+func GetNodeSelector(cluster *crv1.Pgcluster) map[string]string {
+	return MergeNodeSelector(Pgo.Cluster.NodeSelector, cluster.Spec.NodeSelector)
+}
+
+// GetTolerations returns the tolerations that are applied to the Pods of a cluster, i.e. the
+// default tolerations of the Operator merged with those of the cluster
+func GetTolerations(cluster *crv1.Pgcluster) []v1.Toleration {
+	return MergeTolerations(Pgo.GetTolerations(), cluster.Spec.Tolerations,
+		cluster.Spec.ClearDefaultTolerations)
+}
+
+// AddPodScheduling applies the node affinity and the PriorityClass of a cluster to one of its
+// Pods, along with the scheduling of the component the Pod belongs to, if any. The node
+// affinity and the PriorityClass of the component replace those of the cluster, while its
+// tolerations are merged into the tolerations that the Pod already has. The node selector and
+// the tolerations of the cluster are expected to be set on the Pod by its template
+func AddPodScheduling(cluster *crv1.Pgcluster, component *crv1.SchedulingSpec, spec *v1.PodSpec) {
+	nodeAffinity := cluster.Spec.NodeAffinity
+	priorityClassName := cluster.Spec.PriorityClassName
+
+	if component != nil {
+		if component.NodeAffinity != nil {
+			nodeAffinity = component.NodeAffinity
+		}
+		if component.PriorityClassName != "" {
+			priorityClassName = component.PriorityClassName
+		}
+		if len(component.Tolerations) != 0 {
+			spec.Tolerations = MergeTolerations(spec.Tolerations, component.Tolerations, false)
+		}
+	}
+
+	if priorityClassName != "" {
+		spec.PriorityClassName = priorityClassName
+	}
+
+	if nodeAffinity == nil {
+		return
+	}
+
+	if spec.Affinity == nil {
+		spec.Affinity = &v1.Affinity{}
+	}
+	if spec.Affinity.NodeAffinity == nil {
+		spec.Affinity.NodeAffinity = &v1.NodeAffinity{}
+	}
+
+	// the template may prefer the node of the cluster, which the preferences of the node
+	// affinity are added to
+	affinity := nodeAffinity.DeepCopy()
+
+	if affinity.RequiredDuringSchedulingIgnoredDuringExecution != nil {
+		spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution =
+			affinity.RequiredDuringSchedulingIgnoredDuringExecution
+	}
+
+	spec.Affinity.NodeAffinity.PreferredDuringSchedulingIgnoredDuringExecution = append(
+		spec.Affinity.NodeAffinity.PreferredDuringSchedulingIgnoredDuringExecution,
+		affinity.PreferredDuringSchedulingIgnoredDuringExecution...)
+}
+
+// MergeNodeSelector merges the node selector of a cluster into the default node selector. The
+// values of the cluster take precedence, and a key with an empty value removes that key from the
+// defaults altogether
+func MergeNodeSelector(defaults, overrides map[string]string) map[string]string {
+	nodeSelector := map[string]string{}
+
+	for key, value := range defaults {
+		nodeSelector[key] = value
+	}
+
+	for key, value := range overrides {
+		if value == "" {
+			delete(nodeSelector, key)
+			continue
+		}
+
+		nodeSelector[key] = value
+	}
+
+	return nodeSelector
+}
+
+// MergeTolerations merges the tolerations of a cluster into the default tolerations. A toleration
+// of the cluster replaces any default toleration with the same key and effect, and none of the
+// defaults are kept if clearDefaults is set. The result does not contain any duplicates
+func MergeTolerations(defaults, overrides []v1.Toleration, clearDefaults bool) []v1.Toleration {
+	tolerations := []v1.Toleration{}
+
+	if !clearDefaults {
+		for _, toleration := range defaults {
+			if !isTolerationOverridden(toleration, overrides) {
+				tolerations = appendToleration(tolerations, toleration)
+			}
+		}
+	}
+
+	for _, toleration := range overrides {
+		tolerations = appendToleration(tolerations, toleration)
+	}
+
+	return tolerations
+}
+
+// appendToleration appends the toleration unless an identical toleration is already in the list
+func appendToleration(tolerations []v1.Toleration, toleration v1.Toleration) []v1.Toleration {
+	for _, t := range tolerations {
+		if reflect.DeepEqual(t, toleration) {
+			return tolerations
+		}
+	}
+
+	return append(tolerations, toleration)
+}
+
+// isTolerationOverridden determines whether or not one of the overrides has the same key and
+// effect as the toleration
+func isTolerationOverridden(toleration v1.Toleration, overrides []v1.Toleration) bool {
+	for _, t := range overrides {
+		if t.Key == toleration.Key && t.Effect == toleration.Effect {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ValidateInstancePlacement ensures that the topology spread constraints and the zone anti-affinity
+// of a cluster can be applied to the pods of its instances
+func ValidateInstancePlacement(cluster *crv1.Pgcluster) error {
+	if t := cluster.Spec.ZoneAntiAffinity; t != "" {
+		if err := t.Validate(); err != nil {
+			return fmt.Errorf("invalid zone anti-affinity: %v", err)
+		}
+	}
+
+	for _, constraint := range cluster.Spec.TopologySpreadConstraints {
+		switch {
+		case constraint.MaxSkew < 1:
+			return errors.New("the maxSkew of a topology spread constraint must be at least 1")
+		case constraint.TopologyKey == "":
+			return errors.New("a topology spread constraint requires a topologyKey")
+		case constraint.WhenUnsatisfiable != v1.DoNotSchedule &&
+			constraint.WhenUnsatisfiable != v1.ScheduleAnyway:
+			return fmt.Errorf("invalid whenUnsatisfiable %q of a topology spread constraint, must be %q or %q",
+				constraint.WhenUnsatisfiable, v1.DoNotSchedule, v1.ScheduleAnyway)
+		}
+	}
+
+	return nil
+}
+
+// AddInstancePlacement applies the topology spread constraints and the zone anti-affinity of a
+// cluster to the pod of one of its instances, on top of the pod anti-affinity of the template
+func AddInstancePlacement(cluster *crv1.Pgcluster, spec *v1.PodSpec) {
+	for _, constraint := range cluster.Spec.TopologySpreadConstraints {
+		constraint := *constraint.DeepCopy()
+		if constraint.LabelSelector == nil {
+			constraint.LabelSelector = getInstanceLabelSelector(cluster)
+		}
+
+		spec.TopologySpreadConstraints = append(spec.TopologySpreadConstraints, constraint)
+	}
+
+	switch cluster.Spec.ZoneAntiAffinity {
+	case crv1.PodAntiAffinityRequired, crv1.PodAntiAffinityPreffered:
+	default:
+		return
+	}
+
+	if spec.Affinity == nil {
+		spec.Affinity = &v1.Affinity{}
+	}
+	if spec.Affinity.PodAntiAffinity == nil {
+		spec.Affinity.PodAntiAffinity = &v1.PodAntiAffinity{}
+	}
+
+	term := v1.PodAffinityTerm{
+		LabelSelector: getInstanceLabelSelector(cluster),
+		TopologyKey:   ZoneTopologyKey,
+	}
+	antiAffinity := spec.Affinity.PodAntiAffinity
+
+	if cluster.Spec.ZoneAntiAffinity == crv1.PodAntiAffinityRequired {
+		antiAffinity.RequiredDuringSchedulingIgnoredDuringExecution = append(
+			antiAffinity.RequiredDuringSchedulingIgnoredDuringExecution, term)
+		return
+	}
+
+	antiAffinity.PreferredDuringSchedulingIgnoredDuringExecution = append(
+		antiAffinity.PreferredDuringSchedulingIgnoredDuringExecution,
+		v1.WeightedPodAffinityTerm{Weight: 1, PodAffinityTerm: term})
+}
+
+// GetNodeZone returns the availability zone that a node is labeled with, if any
+func GetNodeZone(node *v1.Node) string {
+	if zone := node.Labels[ZoneTopologyKey]; zone != "" {
+		return zone
+	}
+
+	return node.Labels[zoneTopologyKeyBeta]
+}
+
+// getInstanceLabelSelector returns the selector of the pods of the instances of a cluster
+func getInstanceLabelSelector(cluster *crv1.Pgcluster) *metav1.LabelSelector {
+	return &metav1.LabelSelector{
+		MatchLabels: map[string]string{
+			config.LABEL_PG_CLUSTER:  cluster.Name,
+			config.LABEL_PG_DATABASE: config.LABEL_TRUE,
+		},
+	}
+}
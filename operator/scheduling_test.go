@@ -0,0 +1,248 @@
+package operator
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"reflect"
+	"testing"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/config"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestMergeNodeSelector(t *testing.T) {
+	defaults := map[string]string{"node-role": "database", "disk": "ssd"}
+
+	tests := []struct {
+		defaults  map[string]string
+		overrides map[string]string
+		expected  map[string]string
+	}{
+		{nil, nil, map[string]string{}},
+		{defaults, nil, map[string]string{"node-role": "database", "disk": "ssd"}},
+		{nil, map[string]string{"zone": "a"}, map[string]string{"zone": "a"}},
+		// the cluster adds to the defaults
+		{defaults, map[string]string{"zone": "a"},
+			map[string]string{"node-role": "database", "disk": "ssd", "zone": "a"}},
+		// the cluster takes precedence over the defaults
+		{defaults, map[string]string{"disk": "nvme"},
+			map[string]string{"node-role": "database", "disk": "nvme"}},
+		// an empty value clears a default
+		{defaults, map[string]string{"disk": ""}, map[string]string{"node-role": "database"}},
+		{nil, map[string]string{"disk": ""}, map[string]string{}},
+	}
+
+	for i, test := range tests {
+		nodeSelector := MergeNodeSelector(test.defaults, test.overrides)
+		if !reflect.DeepEqual(nodeSelector, test.expected) {
+			t.Fatalf("tests[%d] - unexpected node selector. expected %v, got %v",
+				i, test.expected, nodeSelector)
+		}
+	}
+
+	// the defaults are not modified by the merge
+	if len(defaults) != 2 || defaults["disk"] != "ssd" {
+		t.Fatalf("defaults were modified: %v", defaults)
+	}
+}
+
+func TestMergeTolerations(t *testing.T) {
+	dedicated := v1.Toleration{Key: "dedicated", Operator: v1.TolerationOpEqual,
+		Value: "database", Effect: v1.TaintEffectNoSchedule}
+	dedicatedOther := v1.Toleration{Key: "dedicated", Operator: v1.TolerationOpEqual,
+		Value: "reporting", Effect: v1.TaintEffectNoSchedule}
+	dedicatedExecute := v1.Toleration{Key: "dedicated", Operator: v1.TolerationOpEqual,
+		Value: "database", Effect: v1.TaintEffectNoExecute}
+	unreachable := v1.Toleration{Key: "node.kubernetes.io/unreachable",
+		Operator: v1.TolerationOpExists, Effect: v1.TaintEffectNoExecute}
+
+	tests := []struct {
+		defaults      []v1.Toleration
+		overrides     []v1.Toleration
+		clearDefaults bool
+		expected      []v1.Toleration
+	}{
+		{nil, nil, false, []v1.Toleration{}},
+		{[]v1.Toleration{dedicated}, nil, false, []v1.Toleration{dedicated}},
+		// the cluster adds to the defaults
+		{[]v1.Toleration{dedicated}, []v1.Toleration{unreachable}, false,
+			[]v1.Toleration{dedicated, unreachable}},
+		// the same toleration is not added twice
+		{[]v1.Toleration{dedicated}, []v1.Toleration{dedicated}, false,
+			[]v1.Toleration{dedicated}},
+		{[]v1.Toleration{dedicated, dedicated}, nil, false, []v1.Toleration{dedicated}},
+		{nil, []v1.Toleration{unreachable, unreachable}, false, []v1.Toleration{unreachable}},
+		// the cluster replaces a default with the same key and effect
+		{[]v1.Toleration{dedicated, unreachable}, []v1.Toleration{dedicatedOther}, false,
+			[]v1.Toleration{unreachable, dedicatedOther}},
+		// but not one with a different effect
+		{[]v1.Toleration{dedicated}, []v1.Toleration{dedicatedExecute}, false,
+			[]v1.Toleration{dedicated, dedicatedExecute}},
+		// the defaults can be cleared
+		{[]v1.Toleration{dedicated, unreachable}, nil, true, []v1.Toleration{}},
+		{[]v1.Toleration{dedicated, unreachable}, []v1.Toleration{dedicatedOther}, true,
+			[]v1.Toleration{dedicatedOther}},
+	}
+
+	for i, test := range tests {
+		tolerations := MergeTolerations(test.defaults, test.overrides, test.clearDefaults)
+		if !reflect.DeepEqual(tolerations, test.expected) {
+			t.Fatalf("tests[%d] - unexpected tolerations. expected %v, got %v",
+				i, test.expected, tolerations)
+		}
+	}
+}
+
+func TestAddInstancePlacement(t *testing.T) {
+	cluster := &crv1.Pgcluster{}
+	cluster.Name = "hippo"
+	cluster.Spec.TopologySpreadConstraints = []v1.TopologySpreadConstraint{
+		{MaxSkew: 1, TopologyKey: ZoneTopologyKey, WhenUnsatisfiable: v1.ScheduleAnyway},
+		{MaxSkew: 2, TopologyKey: "kubernetes.io/hostname", WhenUnsatisfiable: v1.DoNotSchedule,
+			LabelSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "other"}}},
+	}
+
+	spec := &v1.PodSpec{}
+	AddInstancePlacement(cluster, spec)
+
+	if len(spec.TopologySpreadConstraints) != 2 {
+		t.Fatalf("expected 2 constraints, got %v", spec.TopologySpreadConstraints)
+	}
+	// a constraint without a selector spreads the instances of the cluster
+	if selector := spec.TopologySpreadConstraints[0].LabelSelector; selector == nil ||
+		selector.MatchLabels[config.LABEL_PG_CLUSTER] != "hippo" {
+		t.Errorf("expected the instances to be selected, got %v", selector)
+	}
+	if selector := spec.TopologySpreadConstraints[1].LabelSelector; selector.MatchLabels["app"] != "other" {
+		t.Errorf("expected the selector to be kept, got %v", selector)
+	}
+	if cluster.Spec.TopologySpreadConstraints[0].LabelSelector != nil {
+		t.Errorf("expected the spec of the cluster not to be modified")
+	}
+	if spec.Affinity != nil {
+		t.Errorf("expected no affinity without zone anti-affinity, got %v", spec.Affinity)
+	}
+
+	// the zone anti-affinity is added to that of the template
+	cluster.Spec.TopologySpreadConstraints = nil
+	cluster.Spec.ZoneAntiAffinity = crv1.PodAntiAffinityRequired
+
+	spec = &v1.PodSpec{Affinity: &v1.Affinity{PodAntiAffinity: &v1.PodAntiAffinity{
+		PreferredDuringSchedulingIgnoredDuringExecution: []v1.WeightedPodAffinityTerm{{Weight: 1}},
+	}}}
+	AddInstancePlacement(cluster, spec)
+
+	antiAffinity := spec.Affinity.PodAntiAffinity
+	if len(antiAffinity.PreferredDuringSchedulingIgnoredDuringExecution) != 1 ||
+		len(antiAffinity.RequiredDuringSchedulingIgnoredDuringExecution) != 1 ||
+		antiAffinity.RequiredDuringSchedulingIgnoredDuringExecution[0].TopologyKey != ZoneTopologyKey {
+		t.Errorf("unexpected anti-affinity %v", antiAffinity)
+	}
+
+	cluster.Spec.ZoneAntiAffinity = crv1.PodAntiAffinityPreffered
+
+	spec = &v1.PodSpec{}
+	AddInstancePlacement(cluster, spec)
+
+	if terms := spec.Affinity.PodAntiAffinity.PreferredDuringSchedulingIgnoredDuringExecution; len(terms) != 1 ||
+		terms[0].PodAffinityTerm.TopologyKey != ZoneTopologyKey {
+		t.Errorf("unexpected anti-affinity %v", spec.Affinity.PodAntiAffinity)
+	}
+}
+
+func TestGetNodeZone(t *testing.T) {
+	node := &v1.Node{}
+
+	if zone := GetNodeZone(node); zone != "" {
+		t.Fatalf("expected no zone, got %q", zone)
+	}
+
+	node.Labels = map[string]string{zoneTopologyKeyBeta: "us-east-1a"}
+	if zone := GetNodeZone(node); zone != "us-east-1a" {
+		t.Fatalf("expected the beta label to be read, got %q", zone)
+	}
+
+	node.Labels[ZoneTopologyKey] = "us-east-1b"
+	if zone := GetNodeZone(node); zone != "us-east-1b" {
+		t.Fatalf("expected the zone label to take precedence, got %q", zone)
+	}
+}
+
+func TestAddPodScheduling(t *testing.T) {
+	required := &v1.NodeSelector{NodeSelectorTerms: []v1.NodeSelectorTerm{{
+		MatchExpressions: []v1.NodeSelectorRequirement{
+			{Key: "node-role", Operator: v1.NodeSelectorOpIn, Values: []string{"database"}},
+		},
+	}}}
+
+	cluster := &crv1.Pgcluster{}
+	cluster.Spec.NodeAffinity = &v1.NodeAffinity{RequiredDuringSchedulingIgnoredDuringExecution: required}
+	cluster.Spec.PriorityClassName = "database"
+
+	// the node affinity is added to the preferred node of the template
+	spec := &v1.PodSpec{Affinity: &v1.Affinity{NodeAffinity: &v1.NodeAffinity{
+		PreferredDuringSchedulingIgnoredDuringExecution: []v1.PreferredSchedulingTerm{{Weight: 10}},
+	}}}
+	AddPodScheduling(cluster, nil, spec)
+
+	if spec.PriorityClassName != "database" {
+		t.Errorf("expected the PriorityClass of the cluster, got %q", spec.PriorityClassName)
+	}
+	affinity := spec.Affinity.NodeAffinity
+	if !reflect.DeepEqual(affinity.RequiredDuringSchedulingIgnoredDuringExecution, required) ||
+		len(affinity.PreferredDuringSchedulingIgnoredDuringExecution) != 1 {
+		t.Errorf("unexpected node affinity %v", affinity)
+	}
+
+	// a component replaces the node affinity and the PriorityClass, and adds tolerations
+	component := &crv1.SchedulingSpec{
+		NodeAffinity: &v1.NodeAffinity{PreferredDuringSchedulingIgnoredDuringExecution: []v1.PreferredSchedulingTerm{
+			{Weight: 1, Preference: v1.NodeSelectorTerm{}},
+		}},
+		Tolerations: []v1.Toleration{
+			{Key: "dedicated", Operator: v1.TolerationOpEqual, Value: "backup", Effect: v1.TaintEffectNoSchedule},
+		},
+		PriorityClassName: "backup",
+	}
+
+	spec = &v1.PodSpec{Tolerations: []v1.Toleration{
+		{Key: "dedicated", Operator: v1.TolerationOpEqual, Value: "database", Effect: v1.TaintEffectNoSchedule},
+		{Key: "spot", Operator: v1.TolerationOpExists},
+	}}
+	AddPodScheduling(cluster, component, spec)
+
+	if spec.PriorityClassName != "backup" {
+		t.Errorf("expected the PriorityClass of the component, got %q", spec.PriorityClassName)
+	}
+	affinity = spec.Affinity.NodeAffinity
+	if affinity.RequiredDuringSchedulingIgnoredDuringExecution != nil ||
+		len(affinity.PreferredDuringSchedulingIgnoredDuringExecution) != 1 {
+		t.Errorf("expected the node affinity of the component, got %v", affinity)
+	}
+	if len(spec.Tolerations) != 2 || spec.Tolerations[0].Key != "spot" || spec.Tolerations[1].Value != "backup" {
+		t.Errorf("expected the toleration of the component to replace that of the cluster, got %v", spec.Tolerations)
+	}
+
+	// nothing is set without any scheduling
+	spec = &v1.PodSpec{}
+	AddPodScheduling(&crv1.Pgcluster{}, &crv1.SchedulingSpec{}, spec)
+
+	if spec.Affinity != nil || spec.PriorityClassName != "" || spec.Tolerations != nil {
+		t.Errorf("expected no scheduling, got %v", spec)
+	}
+}
@@ -0,0 +1,94 @@
+package operator
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/config"
+	v1 "k8s.io/api/core/v1"
+)
+
+// SchedulingGateInitContainerName is the name of the init container that holds back a PostgreSQL
+// Pod until its scheduling gate is released
+const SchedulingGateInitContainerName = "scheduling-gate"
+
+// OperatorSchedulingGate is the scheduling gate the Operator holds the PostgreSQL Pods behind when
+// it limits how many of them start at once, which it releases itself
+const OperatorSchedulingGate = "crunchydata.com/provisioning"
+
+// schedulingGateScript polls the Kubernetes API for the Pod that it is running in until the
+// scheduling gate annotation is removed from it. A Pod that cannot be read is still waited on
+const schedulingGateScript = `SA=/var/run/secrets/kubernetes.io/serviceaccount
+URL="https://${KUBERNETES_SERVICE_HOST}:${KUBERNETES_SERVICE_PORT}/api/v1/namespaces/${POD_NAMESPACE}/pods/${POD_NAME}"
+echo "waiting on scheduling gate ${SCHEDULING_GATE}"
+until POD="$(curl -sf --cacert "${SA}/ca.crt" -H "Authorization: Bearer $(cat ${SA}/token)" "${URL}")" && \
+  ! echo "${POD}" | grep -q '"` + config.ANNOTATION_SCHEDULING_GATE + `":'; do
+  sleep 2
+done
+echo "scheduling gate ${SCHEDULING_GATE} released"
+`
+
+// GetSchedulingGate returns the scheduling gate the Pods of a cluster are held behind, which is
+// the gate of the cluster if it has one, or else that of the Operator if it limits how many
+// PostgreSQL Pods start at once. An empty string means the Pods are not held back
+func GetSchedulingGate(cluster *crv1.Pgcluster) string {
+	if cluster.Spec.SchedulingGate != "" {
+		return cluster.Spec.SchedulingGate
+	}
+	if Pgo.Cluster.ProvisioningConcurrency > 0 {
+		return OperatorSchedulingGate
+	}
+	return ""
+}
+
+// AddSchedulingGate holds back the PostgreSQL Pods of a template behind the scheduling gate of the
+// cluster, if it has one. The gate is set as an annotation on the Pods, and an init container that
+// uses the image provided waits until it is removed, before any of the other containers start
+func AddSchedulingGate(cluster *crv1.Pgcluster, image string, template *v1.PodTemplateSpec) {
+	gate := GetSchedulingGate(cluster)
+	if gate == "" {
+		return
+	}
+
+	if template.Annotations == nil {
+		template.Annotations = map[string]string{}
+	}
+	template.Annotations[config.ANNOTATION_SCHEDULING_GATE] = gate
+
+	container := v1.Container{
+		Name:    SchedulingGateInitContainerName,
+		Image:   image,
+		Command: []string{"bash", "-c", schedulingGateScript},
+		Env: []v1.EnvVar{
+			{Name: "SCHEDULING_GATE", Value: gate},
+			{
+				Name: "POD_NAME",
+				ValueFrom: &v1.EnvVarSource{
+					FieldRef: &v1.ObjectFieldSelector{FieldPath: "metadata.name"},
+				},
+			},
+			{
+				Name: "POD_NAMESPACE",
+				ValueFrom: &v1.EnvVarSource{
+					FieldRef: &v1.ObjectFieldSelector{FieldPath: "metadata.namespace"},
+				},
+			},
+		},
+		ImagePullPolicy: v1.PullIfNotPresent,
+	}
+
+	template.Spec.InitContainers = append([]v1.Container{container}, template.Spec.InitContainers...)
+}
@@ -18,6 +18,7 @@ package task
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"os"
 	"time"
 
@@ -26,6 +27,7 @@ import (
 	"github.com/crunchydata/postgres-operator/events"
 	"github.com/crunchydata/postgres-operator/kubeapi"
 	"github.com/crunchydata/postgres-operator/operator"
+	"github.com/crunchydata/postgres-operator/operator/backrest"
 	"github.com/crunchydata/postgres-operator/util"
 	jsonpatch "github.com/evanphx/json-patch"
 	log "github.com/sirupsen/logrus"
@@ -35,6 +37,18 @@ import (
 	"k8s.io/client-go/rest"
 )
 
+const (
+	// rmdataTaskName is the name of the pgtask that is created to remove a cluster
+	rmdataTaskName = "%s-rmdata"
+	// finalBackupPendingMessage is the message set in the status of a cluster with deletion
+	// protection while its final backup is running
+	finalBackupPendingMessage = "deletion pending: waiting on final backup"
+	// finalBackupFailedMessage is the message set in the status of a cluster with deletion
+	// protection when its final backup could not be taken
+	finalBackupFailedMessage = "deletion blocked: final backup failed, set the %q annotation " +
+		"to \"true\" to delete the cluster anyway"
+)
+
 type rmdatajobTemplateFields struct {
 	JobName            string
 	Name               string
@@ -49,11 +63,22 @@ type rmdatajobTemplateFields struct {
 	RemoveBackup       string
 	IsBackup           string
 	IsReplica          string
+	NodeSelectorLabels string
+	Tolerations        string
 }
 
 // RemoveData ...
 func RemoveData(namespace string, clientset *kubernetes.Clientset, restclient *rest.RESTClient, task *crv1.Pgtask) {
 
+	// a cluster with deletion protection is only removed once its final backup has completed,
+	// so take that backup first. The removal resumes once the backup job completes
+	if cluster, ok := getFinalBackupCluster(restclient, task, namespace); ok {
+		if err := startFinalBackup(clientset, restclient, cluster, namespace); err != nil {
+			log.Error(err)
+		}
+		return
+	}
+
 	//create marker (clustername, namespace)
 	err := PatchpgtaskDeleteDataStatus(restclient, task, namespace)
 	if err != nil {
@@ -82,6 +107,11 @@ func RemoveData(namespace string, clientset *kubernetes.Clientset, restclient *r
 
 	}
 
+	// the cluster has usually been deleted by now, in which case only the default node selector
+	// and tolerations are applied
+	cluster := crv1.Pgcluster{}
+	kubeapi.Getpgcluster(restclient, &cluster, clusterName, namespace)
+
 	jobName := clusterName + "-rmdata-" + util.RandStringBytesRmndr(4)
 
 	jobFields := rmdatajobTemplateFields{
@@ -98,6 +128,8 @@ func RemoveData(namespace string, clientset *kubernetes.Clientset, restclient *r
 		PGOImageTag:        operator.Pgo.Pgo.PGOImageTag,
 		SecurityContext:    util.GetPodSecurityContext(task.Spec.StorageSpec.GetSupplementalGroups()),
 		ContainerResources: cr,
+		NodeSelectorLabels: operator.GetNodeSelectorJSON(&cluster),
+		Tolerations:        operator.GetTolerationsJSON(&cluster),
 	}
 	log.Debugf("creating rmdata job %s for cluster %s ", jobName, task.Spec.Name)
 
@@ -123,6 +155,9 @@ func RemoveData(namespace string, clientset *kubernetes.Clientset, restclient *r
 	operator.SetContainerImageOverride(config.CONTAINER_IMAGE_PGO_RMDATA,
 		&newjob.Spec.Template.Spec.Containers[0])
 
+	// apply the node affinity and the PriorityClass of the cluster
+	operator.AddPodScheduling(&cluster, nil, &newjob.Spec.Template.Spec)
+
 	var jobname string
 	jobname, err = kubeapi.CreateJob(clientset, &newjob, namespace)
 	if err != nil {
@@ -135,6 +170,128 @@ func RemoveData(namespace string, clientset *kubernetes.Clientset, restclient *r
 		task.ObjectMeta.Labels[config.LABEL_PGOUSER], namespace)
 }
 
+// UpdateFinalBackup is called once the final backup of a cluster with deletion protection has
+// finished. If the backup succeeded, the removal of the cluster proceeds, otherwise the removal
+// stays blocked and the cluster status is updated to say why
+func UpdateFinalBackup(clientset *kubernetes.Clientset, restclient *rest.RESTClient, clusterName,
+	namespace string, succeeded bool) error {
+
+	task := crv1.Pgtask{}
+	taskName := fmt.Sprintf(rmdataTaskName, clusterName)
+
+	found, err := kubeapi.Getpgtask(restclient, &task, taskName, namespace)
+	if !found {
+		log.Debugf("no pending removal found for cluster %s after final backup", clusterName)
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if !succeeded {
+		cluster := crv1.Pgcluster{}
+		if _, err := kubeapi.Getpgcluster(restclient, &cluster, clusterName, namespace); err != nil {
+			return err
+		}
+
+		log.Errorf("final backup failed for cluster %s, deletion is blocked", clusterName)
+
+		return kubeapi.PatchpgclusterStatus(restclient, cluster.Status.State,
+			fmt.Sprintf(finalBackupFailedMessage, config.ANNOTATION_DELETION_PROTECTION_FORCE),
+			&cluster, namespace)
+	}
+
+	task.Spec.Parameters[config.LABEL_FINAL_BACKUP_COMPLETED] = time.Now().Format(time.RFC3339)
+
+	if err := kubeapi.Updatepgtask(restclient, &task, taskName, namespace); err != nil {
+		return err
+	}
+
+	log.Debugf("final backup complete for cluster %s, proceeding with removal", clusterName)
+
+	RemoveData(namespace, clientset, restclient, &task)
+
+	return nil
+}
+
+// ResumeRemoveData proceeds with a removal of a cluster that is blocked on the final backup of the
+// cluster, once the removal has been forced
+func ResumeRemoveData(clientset *kubernetes.Clientset, restclient *rest.RESTClient,
+	cluster *crv1.Pgcluster) {
+
+	task := crv1.Pgtask{}
+	taskName := fmt.Sprintf(rmdataTaskName, cluster.Name)
+
+	if found, _ := kubeapi.Getpgtask(restclient, &task, taskName, cluster.Namespace); !found {
+		return
+	}
+
+	// nothing to do if the removal is already underway
+	if task.Spec.Parameters[config.LABEL_DELETE_DATA_STARTED] != "" {
+		return
+	}
+
+	log.Warnf("removal of cluster %s forced without a final backup", cluster.Name)
+
+	RemoveData(cluster.Namespace, clientset, restclient, &task)
+}
+
+// getFinalBackupCluster returns the cluster being removed by the pgtask if a final backup has to be
+// taken before it is removed, i.e. if it is a full cluster removal of a cluster with deletion
+// protection that has not been forced and has not already been backed up
+func getFinalBackupCluster(restclient *rest.RESTClient, task *crv1.Pgtask, namespace string) (*crv1.Pgcluster, bool) {
+	if task.Spec.Parameters[config.LABEL_IS_REPLICA] == "true" ||
+		task.Spec.Parameters[config.LABEL_IS_BACKUP] == "true" ||
+		task.Spec.Parameters[config.LABEL_FINAL_BACKUP_COMPLETED] != "" {
+		return nil, false
+	}
+
+	cluster := crv1.Pgcluster{}
+	if found, _ := kubeapi.Getpgcluster(restclient, &cluster,
+		task.Spec.Parameters[config.LABEL_PG_CLUSTER], namespace); !found {
+		return nil, false
+	}
+
+	if !cluster.Spec.DeletionProtection.BackupFirst {
+		return nil, false
+	}
+
+	if cluster.Annotations[config.ANNOTATION_DELETION_PROTECTION_FORCE] == "true" {
+		log.Warnf("deletion protection of cluster %s overridden by the %s annotation",
+			cluster.Name, config.ANNOTATION_DELETION_PROTECTION_FORCE)
+		return nil, false
+	}
+
+	return &cluster, true
+}
+
+// startFinalBackup takes the final backup of a cluster with deletion protection. If the backup
+// cannot be started the removal of the cluster is blocked
+func startFinalBackup(clientset *kubernetes.Clientset, restclient *rest.RESTClient,
+	cluster *crv1.Pgcluster, namespace string) error {
+
+	log.Debugf("taking final backup of cluster %s before removing it", cluster.Name)
+
+	pod, err := util.GetPrimaryPod(clientset, cluster)
+	if err == nil {
+		err = backrest.CleanBackupResources(restclient, clientset, namespace, cluster.Name)
+	}
+	if err == nil {
+		_, err = backrest.CreateFinalBackup(restclient, namespace, cluster.Name, pod.Name)
+	}
+
+	message := finalBackupPendingMessage
+	if err != nil {
+		message = fmt.Sprintf(finalBackupFailedMessage, config.ANNOTATION_DELETION_PROTECTION_FORCE)
+	}
+
+	if err := kubeapi.PatchpgclusterStatus(restclient, cluster.Status.State, message, cluster,
+		namespace); err != nil {
+		log.Error(err)
+	}
+
+	return err
+}
+
 func PatchpgtaskDeleteDataStatus(restclient *rest.RESTClient, oldCrd *crv1.Pgtask, namespace string) error {
 
 	oldData, err := json.Marshal(oldCrd)
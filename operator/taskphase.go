@@ -0,0 +1,49 @@
+package operator
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/kubeapi"
+	log "github.com/sirupsen/logrus"
+	"k8s.io/client-go/rest"
+)
+
+// UpdateTaskPhase moves the pgtask of the name into a phase, for the places that only know the
+// task by its name, e.g. from the labels of the Job that carries it out. A task that is not
+// found, e.g. as the Job was not started for a task, or that has already finished is left as
+// it is
+func UpdateTaskPhase(restclient *rest.RESTClient, name, namespace string, phase crv1.PgtaskPhase,
+	message string) {
+	task := crv1.Pgtask{}
+	if found, _ := kubeapi.Getpgtask(restclient, &task, name, namespace); !found {
+		log.Debugf("pgtask %s not found, not moving it to phase %s", name, phase)
+		return
+	}
+
+	if task.Status.IsFinished() {
+		return
+	}
+
+	state := task.Status.State
+	if state == "" {
+		state = crv1.PgtaskStateProcessed
+	}
+
+	if err := kubeapi.PatchpgtaskPhase(restclient, state, phase, message, &task, namespace); err != nil {
+		log.Error(err)
+	}
+}
@@ -0,0 +1,150 @@
+package operator
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"fmt"
+	"time"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/kubeapi"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/rest"
+)
+
+// the reasons of a Pod or of the waiting of its container that are down to the node or the
+// registry rather than to the work of the Pod, so that the work can be tried again elsewhere or
+// later
+var (
+	transientPodReasons = map[string]bool{
+		"Evicted":                  true,
+		"NodeLost":                 true,
+		"Preempting":               true,
+		"Shutdown":                 true,
+		"Terminated":               true,
+		"OutOfcpu":                 true,
+		"OutOfmemory":              true,
+		"OutOfpods":                true,
+		"UnexpectedAdmissionError": true,
+	}
+
+	transientContainerReasons = map[string]bool{
+		"ErrImagePull":     true,
+		"ImagePullBackOff": true,
+	}
+)
+
+// GetTransientPodFailure returns the transient reason that one of the Pods of a failed Job
+// failed for, e.g. as it was evicted under node pressure or its image could not be pulled. An
+// empty reason means the Job failed at its own work, which is not helped by trying again
+func GetTransientPodFailure(pods []v1.Pod) string {
+	for _, pod := range pods {
+		if transientPodReasons[pod.Status.Reason] {
+			return pod.Status.Reason
+		}
+
+		for _, status := range append(pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses...) {
+			if status.State.Waiting != nil && transientContainerReasons[status.State.Waiting.Reason] {
+				return status.State.Waiting.Reason
+			}
+		}
+	}
+
+	return ""
+}
+
+// GetPendingPodFailure returns the transient reason that one of the Pods of a Job that has not
+// finished has been waiting for longer than the timeout, e.g. as its image cannot be pulled. A Job
+// does not fail by itself while its Pod waits, so it is up to the caller to fail it
+func GetPendingPodFailure(pods []v1.Pod, timeout time.Duration, now time.Time) string {
+	for _, pod := range pods {
+		if pod.Status.Phase != v1.PodPending || now.Sub(pod.CreationTimestamp.Time) < timeout {
+			continue
+		}
+
+		for _, status := range append(pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses...) {
+			if status.State.Waiting != nil && transientContainerReasons[status.State.Waiting.Reason] {
+				return status.State.Waiting.Reason
+			}
+		}
+	}
+
+	return ""
+}
+
+// GetTaskPendingTimeout returns how long the Pod of a Job of the Operator can wait for a
+// transient reason before the Job is failed
+func GetTaskPendingTimeout() time.Duration {
+	return time.Duration(Pgo.Pgo.TaskPendingTimeoutSeconds) * time.Second
+}
+
+// IsTransientError returns whether an error of the Kubernetes API is likely to go away by
+// itself, e.g. a conflict with another update of the same object
+func IsTransientError(err error) bool {
+	return kerrors.IsConflict(err) || kerrors.IsServerTimeout(err) || kerrors.IsTimeout(err) ||
+		kerrors.IsTooManyRequests(err) || kerrors.IsServiceUnavailable(err)
+}
+
+// GetTaskRetryLimit returns the number of times a pgtask of the type is retried, which is zero
+// for the types that are not configured to be retried
+func GetTaskRetryLimit(taskType string) int {
+	return Pgo.Pgo.TaskRetryLimits[taskType]
+}
+
+// GetTaskRetryBackoff returns how long a pgtask waits before it is retried after the number of
+// attempts, which doubles with each attempt up to the configured maximum
+func GetTaskRetryBackoff(attempts int) time.Duration {
+	backoff := time.Duration(Pgo.Pgo.TaskRetryBackoffSeconds) * time.Second
+	max := time.Duration(Pgo.Pgo.TaskRetryMaxBackoffSeconds) * time.Second
+
+	for i := 1; i < attempts && backoff < max; i++ {
+		backoff *= 2
+	}
+
+	if backoff > max {
+		return max
+	}
+	return backoff
+}
+
+// RetryTask moves the pgtask of the name back into the Pending phase after it failed for a
+// transient reason, from which the pgtask controller runs it again once its backoff has passed.
+// It returns false if the pgtask is not retried, i.e. its type is not retried or it has used up
+// its retries, in which case it is up to the caller to mark it as failed
+func RetryTask(restclient *rest.RESTClient, name, namespace, reason string) bool {
+	task := crv1.Pgtask{}
+	if found, _ := kubeapi.Getpgtask(restclient, &task, name, namespace); !found {
+		return false
+	}
+
+	if task.Status.IsFinished() || task.Status.Attempts > GetTaskRetryLimit(task.Spec.TaskType) {
+		return false
+	}
+
+	message := fmt.Sprintf("attempt %d failed as %s, retrying in %s", task.Status.Attempts, reason,
+		GetTaskRetryBackoff(task.Status.Attempts))
+
+	if err := kubeapi.PatchpgtaskPhase(restclient, task.Status.State, crv1.PgtaskPhasePending,
+		message, &task, namespace); err != nil {
+		log.Error(err)
+		return false
+	}
+
+	log.Infof("pgtask %s %s", name, message)
+	return true
+}
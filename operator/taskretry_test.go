@@ -0,0 +1,117 @@
+package operator
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/crunchydata/postgres-operator/config"
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestGetTransientPodFailure(t *testing.T) {
+	waiting := func(reason string) v1.Pod {
+		return v1.Pod{Status: v1.PodStatus{ContainerStatuses: []v1.ContainerStatus{{
+			State: v1.ContainerState{Waiting: &v1.ContainerStateWaiting{Reason: reason}},
+		}}}}
+	}
+
+	for _, test := range []struct {
+		name     string
+		pods     []v1.Pod
+		expected string
+	}{
+		{"evicted", []v1.Pod{{Status: v1.PodStatus{Reason: "Evicted"}}}, "Evicted"},
+		{"image pull", []v1.Pod{waiting("ImagePullBackOff")}, "ImagePullBackOff"},
+		{"failed at its work", []v1.Pod{{Status: v1.PodStatus{Phase: v1.PodFailed}}}, ""},
+		{"crash loop", []v1.Pod{waiting("CrashLoopBackOff")}, ""},
+		{"no pods", nil, ""},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			if reason := GetTransientPodFailure(test.pods); reason != test.expected {
+				t.Fatalf("expected %q, got %q", test.expected, reason)
+			}
+		})
+	}
+}
+
+func TestGetPendingPodFailure(t *testing.T) {
+	now := time.Now()
+
+	pending := func(age time.Duration, reason string) v1.Pod {
+		return v1.Pod{
+			ObjectMeta: meta_v1.ObjectMeta{CreationTimestamp: meta_v1.NewTime(now.Add(-age))},
+			Status: v1.PodStatus{Phase: v1.PodPending, ContainerStatuses: []v1.ContainerStatus{{
+				State: v1.ContainerState{Waiting: &v1.ContainerStateWaiting{Reason: reason}},
+			}}},
+		}
+	}
+
+	for _, test := range []struct {
+		name     string
+		pods     []v1.Pod
+		expected string
+	}{
+		{"image pull", []v1.Pod{pending(10*time.Minute, "ErrImagePull")}, "ErrImagePull"},
+		{"image pull backoff", []v1.Pod{pending(10*time.Minute, "ImagePullBackOff")},
+			"ImagePullBackOff"},
+		{"within the timeout", []v1.Pod{pending(time.Minute, "ImagePullBackOff")}, ""},
+		{"creating", []v1.Pod{pending(10*time.Minute, "ContainerCreating")}, ""},
+		{"no pods", nil, ""},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			if reason := GetPendingPodFailure(test.pods, 5*time.Minute, now); reason != test.expected {
+				t.Fatalf("expected %q, got %q", test.expected, reason)
+			}
+		})
+	}
+}
+
+func TestIsTransientError(t *testing.T) {
+	resource := schema.GroupResource{Resource: "pgtasks"}
+
+	if !IsTransientError(kerrors.NewConflict(resource, "backup", errors.New("modified"))) {
+		t.Fatalf("expected a conflict to be transient")
+	}
+
+	if IsTransientError(kerrors.NewForbidden(resource, "backup", errors.New("denied"))) {
+		t.Fatalf("expected a forbidden request not to be transient")
+	}
+}
+
+func TestGetTaskRetryBackoff(t *testing.T) {
+	defer func(pgo config.PgoStruct) { Pgo.Pgo = pgo }(Pgo.Pgo)
+
+	Pgo.Pgo.TaskRetryBackoffSeconds = 30
+	Pgo.Pgo.TaskRetryMaxBackoffSeconds = 100
+
+	for attempts, expected := range map[int]time.Duration{
+		0: 30 * time.Second,
+		1: 30 * time.Second,
+		2: 60 * time.Second,
+		3: 100 * time.Second,
+		9: 100 * time.Second,
+	} {
+		if backoff := GetTaskRetryBackoff(attempts); backoff != expected {
+			t.Errorf("expected %s after %d attempts, got %s", expected, attempts, backoff)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package operator
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"time"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/config"
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+)
+
+// GetTaskTTL returns how long the finished pgtasks and Jobs are kept before they are removed,
+// which is zero if they are kept until they are removed by hand
+func GetTaskTTL() time.Duration {
+	return time.Duration(Pgo.Pgo.TaskTTLSeconds) * time.Second
+}
+
+// IsTaskExpired returns whether a pgtask finished more than the TTL ago. The tasks that have not
+// finished, or have no phase at all, e.g. the records of the workflows, are never expired
+func IsTaskExpired(task *crv1.Pgtask, ttl time.Duration, now time.Time) bool {
+	if ttl <= 0 || !task.Status.IsFinished() || task.Status.CompletionTime == nil {
+		return false
+	}
+
+	return now.Sub(task.Status.CompletionTime.Time) > ttl
+}
+
+// IsJobExpired returns whether a Job of the Operator completed or failed more than the TTL ago.
+// A failed Job has no completion time, so the time of its failure is taken from its condition
+func IsJobExpired(job *batchv1.Job, ttl time.Duration, now time.Time) bool {
+	if ttl <= 0 || job.Labels[config.LABEL_VENDOR] != config.LABEL_CRUNCHY {
+		return false
+	}
+
+	for _, condition := range job.Status.Conditions {
+		if (condition.Type == batchv1.JobComplete || condition.Type == batchv1.JobFailed) &&
+			condition.Status == v1.ConditionTrue {
+			return now.Sub(condition.LastTransitionTime.Time) > ttl
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,90 @@
+package operator
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"testing"
+	"time"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/config"
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestIsTaskExpired(t *testing.T) {
+	now := time.Now()
+	finished := meta_v1.NewTime(now.Add(-2 * time.Hour))
+
+	for _, test := range []struct {
+		name     string
+		status   crv1.PgtaskStatus
+		ttl      time.Duration
+		expected bool
+	}{
+		{"finished", crv1.PgtaskStatus{Phase: crv1.PgtaskPhaseSucceeded, CompletionTime: &finished}, time.Hour, true},
+		{"failed", crv1.PgtaskStatus{Phase: crv1.PgtaskPhaseFailed, CompletionTime: &finished}, time.Hour, true},
+		{"within the ttl", crv1.PgtaskStatus{Phase: crv1.PgtaskPhaseSucceeded, CompletionTime: &finished}, 3 * time.Hour, false},
+		{"no ttl", crv1.PgtaskStatus{Phase: crv1.PgtaskPhaseSucceeded, CompletionTime: &finished}, 0, false},
+		{"waiting to be retried", crv1.PgtaskStatus{Phase: crv1.PgtaskPhasePending, CompletionTime: &finished}, time.Hour, false},
+		{"no phase", crv1.PgtaskStatus{}, time.Hour, false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			task := &crv1.Pgtask{Status: test.status}
+			if expired := IsTaskExpired(task, test.ttl, now); expired != test.expected {
+				t.Fatalf("expected %t, got %t", test.expected, expired)
+			}
+		})
+	}
+}
+
+func TestIsJobExpired(t *testing.T) {
+	now := time.Now()
+
+	job := func(vendor string, condition batchv1.JobConditionType) *batchv1.Job {
+		return &batchv1.Job{
+			ObjectMeta: meta_v1.ObjectMeta{Labels: map[string]string{config.LABEL_VENDOR: vendor}},
+			Status: batchv1.JobStatus{Conditions: []batchv1.JobCondition{{
+				Type:               condition,
+				Status:             v1.ConditionTrue,
+				LastTransitionTime: meta_v1.NewTime(now.Add(-2 * time.Hour)),
+			}}},
+		}
+	}
+
+	if !IsJobExpired(job(config.LABEL_CRUNCHY, batchv1.JobComplete), time.Hour, now) {
+		t.Fatalf("expected a completed job to expire")
+	}
+
+	if !IsJobExpired(job(config.LABEL_CRUNCHY, batchv1.JobFailed), time.Hour, now) {
+		t.Fatalf("expected a failed job to expire")
+	}
+
+	if IsJobExpired(job(config.LABEL_CRUNCHY, batchv1.JobComplete), 3*time.Hour, now) {
+		t.Fatalf("expected a job within the ttl not to expire")
+	}
+
+	// only the jobs of the Operator are removed
+	if IsJobExpired(job("other", batchv1.JobComplete), time.Hour, now) {
+		t.Fatalf("expected a job of another vendor not to expire")
+	}
+
+	if IsJobExpired(&batchv1.Job{ObjectMeta: meta_v1.ObjectMeta{
+		Labels: map[string]string{config.LABEL_VENDOR: config.LABEL_CRUNCHY}}}, time.Hour, now) {
+		t.Fatalf("expected a running job not to expire")
+	}
+}
@@ -0,0 +1,52 @@
+package operator
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"fmt"
+	"path"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/config"
+)
+
+// HasWALStorage returns whether the write-ahead log of the instances of a cluster is kept on
+// volumes of their own, which is the case once its WAL storage has a storage type
+func HasWALStorage(cluster *crv1.Pgcluster) bool {
+	return cluster.Spec.WALStorage.StorageType != ""
+}
+
+// GetWALPVCName returns the name of the PVC that the write-ahead log of an instance is kept on,
+// or an empty string if the WAL of the instances of the cluster is kept in their data directories
+func GetWALPVCName(cluster *crv1.Pgcluster, instanceName string) string {
+	if !HasWALStorage(cluster) {
+		return ""
+	}
+
+	return fmt.Sprintf(config.VOLUME_POSTGRESQL_WAL_PVC_NAME_FORMAT, instanceName)
+}
+
+// GetWALDir returns the directory on its WAL volume that the write-ahead log of an instance is
+// kept in, which pg_wal in its data directory links to, or an empty string if the WAL of the
+// instances of the cluster is kept in their data directories
+func GetWALDir(cluster *crv1.Pgcluster, instanceName string) string {
+	if !HasWALStorage(cluster) {
+		return ""
+	}
+
+	return path.Join(config.VOLUME_POSTGRESQL_WAL_MOUNT_PATH,
+		fmt.Sprintf(config.VOLUME_POSTGRESQL_WAL_PVC_NAME_FORMAT, instanceName))
+}
@@ -0,0 +1,275 @@
+package webhook
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/config"
+	"github.com/crunchydata/postgres-operator/operator"
+	"k8s.io/api/admission/v1beta1"
+)
+
+// defaultCCPImage is the image that the clusters run unless they say otherwise, as is the
+// default of "pgo create cluster"
+const defaultCCPImage = "crunchy-postgres-ha"
+
+// patchOperation is an operation of a JSON patch
+type patchOperation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value"`
+}
+
+// defaulter fills in the fields of a custom resource that are omitted, keeping track of them as
+// the operations of a JSON patch. Only the fields that are filled in are patched, so that the
+// fields that are omitted otherwise stay omitted
+type defaulter struct {
+	patch []patchOperation
+}
+
+// add records that a value is added at a path
+func (d *defaulter) add(path string, value interface{}) {
+	d.patch = append(d.patch, patchOperation{Op: "add", Path: path, Value: value})
+}
+
+// setString sets a field to its default if it is empty
+func (d *defaulter) setString(field *string, path, value string) {
+	if *field == "" && value != "" {
+		*field = value
+		d.add(path, value)
+	}
+}
+
+// setLabel sets a label to its default if it is not set
+func (d *defaulter) setLabel(labels *map[string]string, key, value string) {
+	if (*labels)[key] != "" || value == "" {
+		return
+	}
+
+	if *labels == nil {
+		*labels = map[string]string{}
+		d.add("/metadata/labels", map[string]string{})
+	}
+
+	(*labels)[key] = value
+	d.add("/metadata/labels/"+escapePath(key), value)
+}
+
+// setStorage fills in the storage of a custom resource from the storage configuration of
+// pgo.yaml by the name given. The configuration applies to a storage that does not set its type
+// or that has the type of the configuration, and only fills in what the storage leaves empty
+func (d *defaulter) setStorage(storage *crv1.PgStorageSpec, path, name string) {
+	defaults, ok := operator.Pgo.Storage[name]
+	if !ok || (storage.StorageType != "" && storage.StorageType != defaults.StorageType) {
+		return
+	}
+
+	original := *storage
+
+	for field, value := range map[*string]string{
+		&storage.StorageType:        defaults.StorageType,
+		&storage.StorageClass:       defaults.StorageClass,
+		&storage.AccessMode:         defaults.AccessMode,
+		&storage.Size:               defaults.Size,
+		&storage.SupplementalGroups: defaults.SupplementalGroups,
+		&storage.MatchLabels:        defaults.MatchLabels,
+	} {
+		if *field == "" {
+			*field = value
+		}
+	}
+
+	if *storage != original {
+		d.add(path, *storage)
+	}
+}
+
+// setContainerResources sets the resources of a custom resource to the default container
+// resources of pgo.yaml if it sets none of them
+func (d *defaulter) setContainerResources(resources *crv1.PgContainerResources, path string) {
+	if *resources != (crv1.PgContainerResources{}) || operator.Pgo.DefaultContainerResources == "" {
+		return
+	}
+
+	defaults, ok := operator.Pgo.ContainerResources[operator.Pgo.DefaultContainerResources]
+	if !ok {
+		return
+	}
+
+	*resources = crv1.PgContainerResources{
+		RequestsMemory: defaults.RequestsMemory,
+		RequestsCPU:    defaults.RequestsCPU,
+		LimitsMemory:   defaults.LimitsMemory,
+		LimitsCPU:      defaults.LimitsCPU,
+	}
+	d.add(path, *resources)
+}
+
+// withSpec returns the patch, which starts by adding the spec if the custom resource has none,
+// as the fields of the spec cannot be added to it otherwise
+func (d *defaulter) withSpec(raw []byte) []patchOperation {
+	if len(d.patch) == 0 {
+		return d.patch
+	}
+
+	object := map[string]json.RawMessage{}
+	if err := json.Unmarshal(raw, &object); err == nil {
+		if spec, ok := object["spec"]; !ok || string(spec) == "null" {
+			return append([]patchOperation{{Op: "add", Path: "/spec", Value: map[string]string{}}}, d.patch...)
+		}
+	}
+
+	return d.patch
+}
+
+// escapePath escapes a key for a path of a JSON patch
+func escapePath(key string) string {
+	return strings.Replace(strings.Replace(key, "~", "~0", -1), "/", "~1", -1)
+}
+
+// defaultPgcluster fills in the fields of a pgcluster that are omitted with the defaults of
+// "pgo create cluster" and pgo.yaml: its names, image, ports, PostgreSQL user and database,
+// storage and resources
+func defaultPgcluster(cluster *crv1.Pgcluster, namespace string) *defaulter {
+	d := &defaulter{}
+	spec := &cluster.Spec
+
+	d.setString(&spec.Name, "/spec/name", cluster.Name)
+	d.setString(&spec.ClusterName, "/spec/clustername", cluster.Name)
+	d.setString(&spec.Namespace, "/spec/namespace", namespace)
+	d.setString(&spec.PrimaryHost, "/spec/primaryhost", cluster.Name)
+	d.setString(&spec.CCPImage, "/spec/ccpimage", defaultCCPImage)
+	d.setString(&spec.CCPImageTag, "/spec/ccpimagetag", operator.Pgo.Cluster.CCPImageTag)
+	d.setString(&spec.Port, "/spec/port", operator.Pgo.Cluster.Port)
+	d.setString(&spec.PGBadgerPort, "/spec/pgbadgerport", operator.Pgo.Cluster.PGBadgerPort)
+	d.setString(&spec.ExporterPort, "/spec/exporterport", operator.Pgo.Cluster.ExporterPort)
+	d.setString(&spec.User, "/spec/user", operator.Pgo.Cluster.User)
+
+	database := operator.Pgo.Cluster.Database
+	if database == "" {
+		database = cluster.Name
+	}
+	d.setString(&spec.Database, "/spec/database", database)
+
+	replicas := operator.Pgo.Cluster.Replicas
+	if replicas == "" {
+		replicas = "0"
+	}
+	d.setString(&spec.Replicas, "/spec/replicas", replicas)
+
+	// the storage and resources of the spec are not tagged in lower case, so they are found by
+	// the names of their fields
+	d.setStorage(&spec.PrimaryStorage, "/spec/PrimaryStorage", operator.Pgo.PrimaryStorage)
+	d.setStorage(&spec.ReplicaStorage, "/spec/ReplicaStorage", operator.Pgo.ReplicaStorage)
+	d.setStorage(&spec.BackrestStorage, "/spec/BackrestStorage", operator.Pgo.BackrestStorage)
+	d.setContainerResources(&spec.ContainerResources, "/spec/ContainerResources")
+
+	d.setLabel(&cluster.Labels, config.LABEL_NAME, cluster.Name)
+	d.setLabel(&cluster.Labels, config.LABEL_PG_CLUSTER, cluster.Name)
+
+	return d
+}
+
+// defaultPgreplica fills in the fields of a pgreplica that are omitted: its names, and the
+// storage and resources of pgo.yaml
+func defaultPgreplica(replica *crv1.Pgreplica, namespace string) *defaulter {
+	d := &defaulter{}
+	spec := &replica.Spec
+
+	d.setString(&spec.Name, "/spec/name", replica.Name)
+	d.setString(&spec.Namespace, "/spec/namespace", namespace)
+	d.setString(&spec.ClusterName, "/spec/clustername", replica.Labels[config.LABEL_PG_CLUSTER])
+	d.setStorage(&spec.ReplicaStorage, "/spec/replicastorage", operator.Pgo.ReplicaStorage)
+	d.setContainerResources(&spec.ContainerResources, "/spec/containerresources")
+
+	d.setLabel(&replica.Labels, config.LABEL_NAME, replica.Name)
+	d.setLabel(&replica.Labels, config.LABEL_PG_CLUSTER, spec.ClusterName)
+
+	return d
+}
+
+// defaultPgpolicy fills in the names of a pgpolicy if they are omitted
+func defaultPgpolicy(policy *crv1.Pgpolicy, namespace string) *defaulter {
+	d := &defaulter{}
+
+	d.setString(&policy.Spec.Name, "/spec/name", policy.Name)
+	d.setString(&policy.Spec.Namespace, "/spec/namespace", namespace)
+
+	return d
+}
+
+// mutatePgcluster fills in the omitted fields of a pgcluster as it is created
+func mutatePgcluster(request *v1beta1.AdmissionRequest) *v1beta1.AdmissionResponse {
+	if request.Operation != v1beta1.Create {
+		return &v1beta1.AdmissionResponse{Allowed: true}
+	}
+
+	cluster := &crv1.Pgcluster{}
+	if err := json.Unmarshal(request.Object.Raw, cluster); err != nil {
+		return deny(fmt.Sprintf("could not decode pgcluster: %s", err))
+	}
+
+	return patch(request.Object.Raw, defaultPgcluster(cluster, request.Namespace))
+}
+
+// mutatePgreplica fills in the omitted fields of a pgreplica as it is created
+func mutatePgreplica(request *v1beta1.AdmissionRequest) *v1beta1.AdmissionResponse {
+	if request.Operation != v1beta1.Create {
+		return &v1beta1.AdmissionResponse{Allowed: true}
+	}
+
+	replica := &crv1.Pgreplica{}
+	if err := json.Unmarshal(request.Object.Raw, replica); err != nil {
+		return deny(fmt.Sprintf("could not decode pgreplica: %s", err))
+	}
+
+	return patch(request.Object.Raw, defaultPgreplica(replica, request.Namespace))
+}
+
+// mutatePgpolicy fills in the omitted fields of a pgpolicy as it is created
+func mutatePgpolicy(request *v1beta1.AdmissionRequest) *v1beta1.AdmissionResponse {
+	if request.Operation != v1beta1.Create {
+		return &v1beta1.AdmissionResponse{Allowed: true}
+	}
+
+	policy := &crv1.Pgpolicy{}
+	if err := json.Unmarshal(request.Object.Raw, policy); err != nil {
+		return deny(fmt.Sprintf("could not decode pgpolicy: %s", err))
+	}
+
+	return patch(request.Object.Raw, defaultPgpolicy(policy, request.Namespace))
+}
+
+// patch returns a response that admits an object with the fields that the defaulter filled in
+func patch(raw []byte, d *defaulter) *v1beta1.AdmissionResponse {
+	operations := d.withSpec(raw)
+	if len(operations) == 0 {
+		return &v1beta1.AdmissionResponse{Allowed: true}
+	}
+
+	data, err := json.Marshal(operations)
+	if err != nil {
+		return deny(fmt.Sprintf("could not encode patch: %s", err))
+	}
+
+	patchType := v1beta1.PatchTypeJSONPatch
+
+	return &v1beta1.AdmissionResponse{Allowed: true, Patch: data, PatchType: &patchType}
+}
@@ -0,0 +1,191 @@
+package webhook
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"encoding/json"
+	"testing"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/config"
+	"github.com/crunchydata/postgres-operator/operator"
+	"k8s.io/api/admission/v1beta1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// withDefaults sets the configuration of the Operator that the defaults come from for a test,
+// returning a function that restores it
+func withDefaults() func() {
+	original := operator.Pgo
+
+	operator.Pgo = config.PgoConfig{}
+	operator.Pgo.Cluster.CCPImageTag = "centos7-12.2-4.3.0"
+	operator.Pgo.Cluster.Port = "5432"
+	operator.Pgo.Cluster.User = "testuser"
+	operator.Pgo.PrimaryStorage = "standard"
+	operator.Pgo.ReplicaStorage = "standard"
+	operator.Pgo.DefaultContainerResources = "small"
+	operator.Pgo.Storage = map[string]config.StorageStruct{
+		"standard": {StorageType: "dynamic", StorageClass: "fast", AccessMode: "ReadWriteOnce", Size: "1G"},
+	}
+	operator.Pgo.ContainerResources = map[string]config.ContainerResourcesStruct{
+		"small": {RequestsMemory: "512Mi", LimitsMemory: "1Gi"},
+	}
+
+	return func() { operator.Pgo = original }
+}
+
+// mutate sends the review of an object to a defaulting function and returns the patch of its
+// response
+func mutate(t *testing.T, reviewFunc func(*v1beta1.AdmissionRequest) *v1beta1.AdmissionResponse,
+	operation v1beta1.Operation, raw string) []patchOperation {
+	response := reviewFunc(&v1beta1.AdmissionRequest{
+		Operation: operation,
+		Namespace: "pgouser1",
+		Object:    runtime.RawExtension{Raw: []byte(raw)},
+	})
+
+	if !response.Allowed {
+		t.Fatalf("expected the object to be admitted, got %+v", response.Result)
+	}
+
+	patch := []patchOperation{}
+	if len(response.Patch) == 0 {
+		return patch
+	}
+
+	if response.PatchType == nil || *response.PatchType != v1beta1.PatchTypeJSONPatch {
+		t.Fatalf("expected a JSON patch, got %v", response.PatchType)
+	}
+	if err := json.Unmarshal(response.Patch, &patch); err != nil {
+		t.Fatal(err)
+	}
+
+	return patch
+}
+
+// paths returns the values of a patch by their path
+func paths(patch []patchOperation) map[string]interface{} {
+	values := map[string]interface{}{}
+	for _, operation := range patch {
+		values[operation.Path] = operation.Value
+	}
+	return values
+}
+
+func TestMutatePgcluster(t *testing.T) {
+	defer withDefaults()()
+
+	patch := mutate(t, mutatePgcluster, v1beta1.Create, `{"metadata":{"name":"hacluster"}}`)
+	values := paths(patch)
+
+	if patch[0].Path != "/spec" {
+		t.Fatalf("expected the spec to be added first, got %q", patch[0].Path)
+	}
+
+	for path, expected := range map[string]string{
+		"/spec/name":                  "hacluster",
+		"/spec/clustername":           "hacluster",
+		"/spec/namespace":             "pgouser1",
+		"/spec/ccpimage":              defaultCCPImage,
+		"/spec/ccpimagetag":           "centos7-12.2-4.3.0",
+		"/spec/port":                  "5432",
+		"/spec/user":                  "testuser",
+		"/spec/database":              "hacluster",
+		"/spec/replicas":              "0",
+		"/metadata/labels/name":       "hacluster",
+		"/metadata/labels/pg-cluster": "hacluster",
+	} {
+		if values[path] != expected {
+			t.Errorf("expected %s to be %q, got %v", path, expected, values[path])
+		}
+	}
+
+	storage, ok := values["/spec/PrimaryStorage"].(map[string]interface{})
+	if !ok || storage["storageclass"] != "fast" || storage["size"] != "1G" {
+		t.Errorf("expected the default primary storage, got %v", values["/spec/PrimaryStorage"])
+	}
+	if _, ok := values["/spec/BackrestStorage"]; ok {
+		t.Errorf("expected no pgBackRest storage without a configuration of it")
+	}
+
+	resources, ok := values["/spec/ContainerResources"].(map[string]interface{})
+	if !ok || resources["requestsmemory"] != "512Mi" {
+		t.Errorf("expected the default container resources, got %v", values["/spec/ContainerResources"])
+	}
+}
+
+func TestMutatePgclusterKeepsFields(t *testing.T) {
+	defer withDefaults()()
+
+	cluster := &crv1.Pgcluster{}
+	cluster.Name = "hacluster"
+	cluster.Labels = map[string]string{config.LABEL_NAME: "hacluster"}
+	cluster.Spec.CCPImageTag = "centos7-11.7-4.3.0"
+	cluster.Spec.PrimaryStorage = crv1.PgStorageSpec{StorageType: "dynamic", Size: "5G"}
+	cluster.Spec.ReplicaStorage = crv1.PgStorageSpec{StorageType: "emptydir"}
+	cluster.Spec.ContainerResources.LimitsCPU = "2"
+
+	raw, err := json.Marshal(cluster)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	values := paths(mutate(t, mutatePgcluster, v1beta1.Create, string(raw)))
+
+	for _, path := range []string{"/spec", "/spec/ccpimagetag", "/spec/ReplicaStorage",
+		"/spec/ContainerResources", "/metadata/labels", "/metadata/labels/name"} {
+		if value, ok := values[path]; ok {
+			t.Errorf("expected %s to be kept, got %v", path, value)
+		}
+	}
+
+	storage, ok := values["/spec/PrimaryStorage"].(map[string]interface{})
+	if !ok || storage["storageclass"] != "fast" || storage["size"] != "5G" {
+		t.Errorf("expected the primary storage to be filled in, got %v", values["/spec/PrimaryStorage"])
+	}
+
+	if patch := mutate(t, mutatePgcluster, v1beta1.Update, `{"metadata":{"name":"hacluster"}}`); len(patch) != 0 {
+		t.Errorf("expected an update not to be defaulted, got %+v", patch)
+	}
+}
+
+func TestMutatePgreplicaAndPgpolicy(t *testing.T) {
+	defer withDefaults()()
+
+	values := paths(mutate(t, mutatePgreplica, v1beta1.Create,
+		`{"metadata":{"name":"hacluster-abcd","labels":{"pg-cluster":"hacluster"}},"spec":{}}`))
+
+	for path, expected := range map[string]string{
+		"/spec/name":            "hacluster-abcd",
+		"/spec/namespace":       "pgouser1",
+		"/spec/clustername":     "hacluster",
+		"/metadata/labels/name": "hacluster-abcd",
+	} {
+		if values[path] != expected {
+			t.Errorf("expected %s to be %q, got %v", path, expected, values[path])
+		}
+	}
+	if _, ok := values["/spec/replicastorage"]; !ok {
+		t.Errorf("expected the default replica storage")
+	}
+
+	values = paths(mutate(t, mutatePgpolicy, v1beta1.Create, `{"metadata":{"name":"policy1"}}`))
+
+	if values["/spec/name"] != "policy1" || values["/spec/namespace"] != "pgouser1" {
+		t.Errorf("expected the names of the pgpolicy, got %v", values)
+	}
+}
@@ -0,0 +1,213 @@
+package webhook
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/kubeapi"
+	"github.com/crunchydata/postgres-operator/operator"
+	clusteroperator "github.com/crunchydata/postgres-operator/operator/cluster"
+	log "github.com/sirupsen/logrus"
+	"k8s.io/api/admission/v1beta1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// ValidatePgclusterPath is the path at which the pgclusters are validated
+	ValidatePgclusterPath = "/validate-pgcluster"
+	// MutatePgclusterPath is the path at which the omitted fields of the pgclusters are defaulted
+	MutatePgclusterPath = "/mutate-pgcluster"
+	// MutatePgreplicaPath is the path at which the omitted fields of the pgreplicas are defaulted
+	MutatePgreplicaPath = "/mutate-pgreplica"
+	// MutatePgpolicyPath is the path at which the omitted fields of the pgpolicies are defaulted
+	MutatePgpolicyPath = "/mutate-pgpolicy"
+)
+
+// certificateRefreshInterval is how often the certificate of the webhook is read again from its
+// Secret, so that a certificate that is rotated is picked up without restarting the Operator
+const certificateRefreshInterval = time.Minute
+
+// Serve serves the admission webhooks of the custom resources on the given port in the
+// background: the validating webhook of the pgclusters, and the defaulting webhooks of the
+// pgclusters, pgreplicas and pgpolicies. It is served over TLS with the certificate and key of
+// the given Secret in the namespace of the Operator, which the webhook configuration has to trust.
+// An error is returned if the certificate cannot be loaded, as the webhook configuration ignores
+// a webhook that cannot be reached and the resources would go unvalidated
+func Serve(clientset *kubernetes.Clientset, port, secretName string) error {
+	certificates := &certificateLoader{load: func() (*tls.Certificate, error) {
+		return loadCertificate(clientset, secretName)
+	}}
+
+	if _, err := certificates.GetCertificate(nil); err != nil {
+		return fmt.Errorf("could not serve the admission webhook: %s", err)
+	}
+
+	server := &http.Server{
+		Addr:    ":" + port,
+		Handler: newHandler(),
+		TLSConfig: &tls.Config{
+			GetCertificate: certificates.GetCertificate,
+			MinVersion:     tls.VersionTLS12,
+		},
+	}
+
+	log.Infof("serving the admission webhook on port %s", port)
+
+	go func() {
+		if err := server.ListenAndServeTLS("", ""); err != nil {
+			log.Errorf("could not serve the admission webhook: %s", err)
+		}
+	}()
+
+	return nil
+}
+
+// certificateLoader hands out the certificate of the webhook, which it loads again once it is
+// older than the refresh interval
+type certificateLoader struct {
+	mutex       sync.Mutex
+	load        func() (*tls.Certificate, error)
+	certificate *tls.Certificate
+	loaded      time.Time
+}
+
+// GetCertificate returns the certificate of the webhook. If it cannot be loaded again, the
+// certificate that was loaded before is kept until the next refresh
+func (l *certificateLoader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.certificate != nil && time.Since(l.loaded) < certificateRefreshInterval {
+		return l.certificate, nil
+	}
+
+	certificate, err := l.load()
+	if err != nil && l.certificate == nil {
+		return nil, err
+	}
+
+	l.loaded = time.Now()
+
+	if err != nil {
+		log.Errorf("admission webhook: could not reload the certificate, keeping the one loaded "+
+			"before: %s", err)
+		return l.certificate, nil
+	}
+
+	l.certificate = certificate
+	return certificate, nil
+}
+
+// loadCertificate reads the certificate and key of the webhook from its Secret
+func loadCertificate(clientset *kubernetes.Clientset, secretName string) (*tls.Certificate, error) {
+	secret, _, err := kubeapi.GetSecret(clientset, secretName, operator.PgoNamespace)
+	if err != nil {
+		return nil, fmt.Errorf("could not read Secret %s: %s", secretName, err)
+	}
+
+	certificate, err := tls.X509KeyPair(secret.Data[v1.TLSCertKey], secret.Data[v1.TLSPrivateKeyKey])
+	if err != nil {
+		return nil, fmt.Errorf("invalid certificate in Secret %s: %s", secretName, err)
+	}
+
+	return &certificate, nil
+}
+
+// newHandler returns the handler of the admission webhook
+func newHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(ValidatePgclusterPath, admissionHandler(reviewPgcluster))
+	mux.HandleFunc(MutatePgclusterPath, admissionHandler(mutatePgcluster))
+	mux.HandleFunc(MutatePgreplicaPath, admissionHandler(mutatePgreplica))
+	mux.HandleFunc(MutatePgpolicyPath, admissionHandler(mutatePgpolicy))
+
+	return mux
+}
+
+// admissionHandler returns a handler that responds to an admission review with the response of
+// the given review function
+func admissionHandler(reviewFunc func(*v1beta1.AdmissionRequest) *v1beta1.AdmissionResponse) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		review := v1beta1.AdmissionReview{}
+
+		if err := json.NewDecoder(r.Body).Decode(&review); err != nil || review.Request == nil {
+			http.Error(w, "invalid admission review", http.StatusBadRequest)
+			return
+		}
+
+		review.Response = reviewFunc(review.Request)
+		review.Response.UID = review.Request.UID
+
+		w.Header().Set("Content-Type", "application/json")
+
+		if err := json.NewEncoder(w).Encode(review); err != nil {
+			log.Error(err)
+		}
+	}
+}
+
+// reviewPgcluster allows a pgcluster to be created if it is valid, and to be updated unless the
+// update makes it invalid
+func reviewPgcluster(request *v1beta1.AdmissionRequest) *v1beta1.AdmissionResponse {
+	cluster := &crv1.Pgcluster{}
+	if err := json.Unmarshal(request.Object.Raw, cluster); err != nil {
+		return deny(fmt.Sprintf("could not decode pgcluster: %s", err))
+	}
+
+	reasons := []string{}
+
+	switch request.Operation {
+	case v1beta1.Create:
+		reasons = clusteroperator.ValidateCluster(cluster)
+	case v1beta1.Update:
+		oldCluster := &crv1.Pgcluster{}
+		if err := json.Unmarshal(request.OldObject.Raw, oldCluster); err != nil {
+			return deny(fmt.Sprintf("could not decode pgcluster: %s", err))
+		}
+		reasons = clusteroperator.ValidateClusterChange(oldCluster, cluster)
+	}
+
+	if len(reasons) > 0 {
+		log.Infof("admission webhook: refused pgcluster %s/%s: %s", request.Namespace, cluster.Name,
+			strings.Join(reasons, "; "))
+		return deny("invalid pgcluster: " + strings.Join(reasons, "; "))
+	}
+
+	return &v1beta1.AdmissionResponse{Allowed: true}
+}
+
+// deny returns a response that refuses the admission of an object for the given reason
+func deny(message string) *v1beta1.AdmissionResponse {
+	return &v1beta1.AdmissionResponse{
+		Allowed: false,
+		Result: &metav1.Status{
+			Status:  metav1.StatusFailure,
+			Reason:  metav1.StatusReasonInvalid,
+			Code:    http.StatusUnprocessableEntity,
+			Message: message,
+		},
+	}
+}
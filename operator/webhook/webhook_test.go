@@ -0,0 +1,151 @@
+package webhook
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"k8s.io/api/admission/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// review sends an admission review of a pgcluster to the webhook and returns its response
+func review(t *testing.T, operation v1beta1.Operation, oldCluster, cluster *crv1.Pgcluster) *v1beta1.AdmissionResponse {
+	raw := func(cluster *crv1.Pgcluster) runtime.RawExtension {
+		if cluster == nil {
+			return runtime.RawExtension{}
+		}
+		data, err := json.Marshal(cluster)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return runtime.RawExtension{Raw: data}
+	}
+
+	body, err := json.Marshal(v1beta1.AdmissionReview{
+		Request: &v1beta1.AdmissionRequest{
+			UID:       "review-1",
+			Operation: operation,
+			Namespace: "pgouser1",
+			Object:    raw(cluster),
+			OldObject: raw(oldCluster),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := httptest.NewRecorder()
+	newHandler().ServeHTTP(recorder, httptest.NewRequest("POST", ValidatePgclusterPath, bytes.NewReader(body)))
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected the review to succeed, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+
+	response := v1beta1.AdmissionReview{}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Response == nil || response.Response.UID != "review-1" {
+		t.Fatalf("expected a response to the review, got %+v", response.Response)
+	}
+
+	return response.Response
+}
+
+func TestValidatePgcluster(t *testing.T) {
+	cluster := &crv1.Pgcluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "hacluster"},
+		Spec: crv1.PgclusterSpec{
+			CCPImageTag:    "centos7-12.2-4.3.0",
+			PrimaryStorage: crv1.PgStorageSpec{Size: "1G"},
+		},
+	}
+
+	if response := review(t, v1beta1.Create, nil, cluster); !response.Allowed {
+		t.Fatalf("expected a valid pgcluster to be allowed, got %+v", response.Result)
+	}
+
+	invalid := cluster.DeepCopy()
+	invalid.Spec.PrimaryStorage.Size = "1 GB"
+
+	response := review(t, v1beta1.Create, nil, invalid)
+	if response.Allowed || response.Result == nil ||
+		!strings.Contains(response.Result.Message, `invalid primary storage size "1 GB"`) {
+		t.Fatalf("expected an invalid pgcluster to be refused, got %+v", response.Result)
+	}
+
+	if response := review(t, v1beta1.Update, invalid, invalid); !response.Allowed {
+		t.Fatalf("expected an update that does not make the pgcluster invalid to be allowed, got %+v",
+			response.Result)
+	}
+
+	if response := review(t, v1beta1.Update, cluster, invalid); response.Allowed {
+		t.Fatalf("expected an update that makes the pgcluster invalid to be refused")
+	}
+}
+
+func TestValidatePgclusterInvalidReview(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	newHandler().ServeHTTP(recorder, httptest.NewRequest("POST", ValidatePgclusterPath, strings.NewReader("{}")))
+
+	if recorder.Code != http.StatusBadRequest {
+		t.Fatalf("expected a review without a request to be refused, got %d", recorder.Code)
+	}
+}
+
+func TestCertificateLoader(t *testing.T) {
+	first, second := &tls.Certificate{}, &tls.Certificate{}
+	var certificate *tls.Certificate
+	var err error
+
+	loader := &certificateLoader{load: func() (*tls.Certificate, error) { return certificate, err }}
+
+	err = errors.New("secret not found")
+	if _, err := loader.GetCertificate(nil); err == nil {
+		t.Fatalf("expected an error while no certificate was ever loaded")
+	}
+
+	certificate, err = first, nil
+	if loaded, _ := loader.GetCertificate(nil); loaded != first {
+		t.Fatalf("expected the certificate to be loaded")
+	}
+
+	certificate = second
+	if loaded, _ := loader.GetCertificate(nil); loaded != first {
+		t.Fatalf("expected the certificate to be kept until the refresh interval passed")
+	}
+
+	loader.loaded = loader.loaded.Add(-certificateRefreshInterval)
+	if loaded, _ := loader.GetCertificate(nil); loaded != second {
+		t.Fatalf("expected the rotated certificate to be loaded")
+	}
+
+	loader.loaded = loader.loaded.Add(-certificateRefreshInterval)
+	err = errors.New("secret not found")
+	if loaded, err := loader.GetCertificate(nil); loaded != second || err != nil {
+		t.Fatalf("expected the certificate to be kept when it cannot be loaded again, got %v", err)
+	}
+}
@@ -30,6 +30,7 @@ const backrestCommand = "pgbackrest"
 const backrestBackupCommand = `backup`
 const backrestInfoCommand = `info`
 const backrestStanzaCreateCommand = `stanza-create`
+const backrestVerifyCommand = `verify`
 const containername = "database"
 const repoTypeFlagS3 = "--repo-type=s3"
 
@@ -102,6 +103,11 @@ func main() {
 		cmdStrs = append(cmdStrs, backrestCommand)
 		cmdStrs = append(cmdStrs, backrestBackupCommand)
 		cmdStrs = append(cmdStrs, COMMAND_OPTS)
+	case crv1.PgtaskBackrestVerify:
+		log.Info("backrest verify command requested")
+		cmdStrs = append(cmdStrs, backrestCommand)
+		cmdStrs = append(cmdStrs, backrestVerifyCommand)
+		cmdStrs = append(cmdStrs, COMMAND_OPTS)
 	default:
 		log.Error("unsupported backup command specified " + COMMAND)
 		os.Exit(2)
@@ -40,6 +40,8 @@ const (
 	tablespacePathFormat = "/tablespaces/%s/%s"
 	// the tablespace on a replcia follows the pattern "<replicaName-tablespace-.."
 	tablespaceReplicaPVCPattern = "%s-tablespace-"
+	// the WAL of a replica that keeps it on a volume of its own is on "<replicaName>-wal"
+	walReplicaPVCPattern = "%s-wal"
 
 	// the following constants define the suffixes for the various configMaps created by Patroni
 	configConfigMapSuffix   = "config"
@@ -121,6 +123,7 @@ func Delete(request Request) {
 
 	//handle the case of 'pgo delete cluster mycluster'
 	removeCluster(request)
+	removeCleanupFinalizer(request)
 	if err := kubeapi.Deletepgcluster(request.RESTClient, request.ClusterName, request.Namespace); err != nil {
 		log.Error(err)
 	}
@@ -161,6 +164,33 @@ func Delete(request Request) {
 	}
 }
 
+// removeCleanupFinalizer removes the cleanup finalizer from the pgcluster, as the resources of
+// the cluster are removed here rather than by the Operator
+func removeCleanupFinalizer(request Request) {
+	cluster := crv1.Pgcluster{}
+	if found, _ := kubeapi.Getpgcluster(request.RESTClient, &cluster, request.ClusterName,
+		request.Namespace); !found {
+		return
+	}
+
+	finalizers := []string{}
+	for _, finalizer := range cluster.Finalizers {
+		if finalizer != config.FINALIZER_CLEANUP {
+			finalizers = append(finalizers, finalizer)
+		}
+	}
+
+	if len(finalizers) == len(cluster.Finalizers) {
+		return
+	}
+
+	if err := kubeapi.Patchpgcluster(request.RESTClient, &cluster, request.Namespace, func(cluster *crv1.Pgcluster) {
+		cluster.ObjectMeta.Finalizers = finalizers
+	}); err != nil {
+		log.Error(err)
+	}
+}
+
 // removeBackRestRepo removes the pgBackRest repo that is associated with the
 // PostgreSQL cluster
 func removeBackrestRepo(request Request) {
@@ -367,21 +397,24 @@ func removeUserSecrets(request Request) {
 }
 
 func removeAddons(request Request) {
-	//remove pgbouncer
+	//remove pgbouncer, along with the read-only pgbouncer
 
-	pgbouncerDepName := request.ClusterName + "-pgbouncer"
-
-	_, found, _ := kubeapi.GetDeployment(request.Clientset, pgbouncerDepName, request.Namespace)
-	if found {
+	for _, pgbouncerDepName := range []string{
+		request.ClusterName + "-pgbouncer",
+		request.ClusterName + "-pgbouncer-ro",
+	} {
+		_, found, _ := kubeapi.GetDeployment(request.Clientset, pgbouncerDepName, request.Namespace)
+		if found {
 
-		kubeapi.DeleteDeployment(request.Clientset, pgbouncerDepName, request.Namespace)
-	}
+			kubeapi.DeleteDeployment(request.Clientset, pgbouncerDepName, request.Namespace)
+		}
 
-	//delete the service name=<clustename>-pgbouncer
+		//delete the service name=<clustename>-pgbouncer
 
-	_, found, _ = kubeapi.GetService(request.Clientset, pgbouncerDepName, request.Namespace)
-	if found {
-		kubeapi.DeleteService(request.Clientset, pgbouncerDepName, request.Namespace)
+		_, found, _ = kubeapi.GetService(request.Clientset, pgbouncerDepName, request.Namespace)
+		if found {
+			kubeapi.DeleteService(request.Clientset, pgbouncerDepName, request.Namespace)
+		}
 	}
 
 }
@@ -518,13 +551,14 @@ func getReplicaPVC(request Request) ([]string, error) {
 
 	// ...and where the fun begins
 	tablespaceReplicaPVCPrefix := fmt.Sprintf(tablespaceReplicaPVCPattern, request.ReplicaName)
+	walReplicaPVCName := fmt.Sprintf(walReplicaPVCPattern, request.ReplicaName)
 
-	// iterate over the PVC list and append the tablespace PVCs
+	// iterate over the PVC list and append the tablespace and WAL PVCs
 	for _, pvc := range pvcs.Items {
 		pvcName := pvc.ObjectMeta.Name
 
-		// it does not start with the tablespace replica PVC pattern, continue
-		if !strings.HasPrefix(pvcName, tablespaceReplicaPVCPrefix) {
+		// it is neither a tablespace nor the WAL PVC of the replica, continue
+		if !strings.HasPrefix(pvcName, tablespaceReplicaPVCPrefix) && pvcName != walReplicaPVCName {
 			continue
 		}
 
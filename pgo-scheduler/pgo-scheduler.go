@@ -28,6 +28,7 @@ import (
 	"github.com/crunchydata/postgres-operator/kubeapi"
 	crunchylog "github.com/crunchydata/postgres-operator/logging"
 	"github.com/crunchydata/postgres-operator/ns"
+	"github.com/crunchydata/postgres-operator/operator"
 	"github.com/crunchydata/postgres-operator/pgo-scheduler/scheduler"
 	sched "github.com/crunchydata/postgres-operator/pgo-scheduler/scheduler"
 	log "github.com/sirupsen/logrus"
@@ -52,6 +53,7 @@ var namespaceList []string
 var timeout time.Duration
 var seconds int
 var kubeClient *kubernetes.Clientset
+var leaderElection bool
 
 // this is used to prevent a race condition where an informer is being created
 // twice when a new scheduler-enabled ConfigMap is added.
@@ -107,6 +109,7 @@ func init() {
 	if err := Pgo.GetConfig(kubeClient, pgoNamespace); err != nil {
 		log.WithFields(log.Fields{}).Fatalf("error in Pgo configuration: %s", err)
 	}
+	leaderElection = Pgo.Pgo.LeaderElection
 	namespaceList = ns.GetNamespaces(kubeClient, installationName)
 	log.Debugf("watching the following namespaces: [%v]", namespaceList)
 
@@ -118,6 +121,14 @@ func main() {
 	time.Sleep(time.Duration(5) * time.Second)
 
 	scheduler := scheduler.New(schedulerLabel, pgoNamespace, namespaceList, kubeClient)
+	if leaderElection {
+		identity, err := operator.LeaderIdentity()
+		if err != nil {
+			log.WithFields(log.Fields{}).Fatalf("Failed to get the leader election identity: %s", err)
+		}
+		log.WithFields(log.Fields{}).Infof("Running schedules only while %s is the leader", identity)
+		scheduler.SetLeaderElection(installationName, identity)
+	}
 	scheduler.CronClient.Start()
 
 	sigs := make(chan os.Signal, 1)
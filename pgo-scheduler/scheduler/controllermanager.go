@@ -167,6 +167,21 @@ func (c *ControllerManager) StopGroup(namespace string) {
 	log.Debugf("Controller Manager: the controller group for ns %s has been stopped", namespace)
 }
 
+// PauseGroup pauses the controller group for the namespace specified, e.g. during maintenance.
+// Its controllers keep tracking the schedules of the namespace, but the schedules are skipped
+// when they are due until the group is resumed
+func (c *ControllerManager) PauseGroup(namespace string) {
+	c.Scheduler.setPaused(namespace, true)
+	log.Infof("Controller Manager: the controller group for ns %s is now paused", namespace)
+}
+
+// ResumeGroup resumes the controller group for the namespace specified, whose schedules then run
+// again when they are next due
+func (c *ControllerManager) ResumeGroup(namespace string) {
+	c.Scheduler.setPaused(namespace, false)
+	log.Infof("Controller Manager: the controller group for ns %s is now resumed", namespace)
+}
+
 // RemoveAll removes all controller groups managed by the controller manager, first stopping all
 // controllers within each controller group managed by the controller manager.
 func (c *ControllerManager) RemoveAll() {
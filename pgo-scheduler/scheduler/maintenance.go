@@ -0,0 +1,103 @@
+package scheduler
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"fmt"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+	"github.com/crunchydata/postgres-operator/kubeapi"
+	log "github.com/sirupsen/logrus"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+type MaintenanceJob struct {
+	namespace string
+	cluster   string
+}
+
+func (s *ScheduleTemplate) NewMaintenanceSchedule() MaintenanceJob {
+	return MaintenanceJob{
+		namespace: s.Namespace,
+		cluster:   s.Cluster,
+	}
+}
+
+// Run creates the pgtask that has the Operator carry out the routine
+// maintenance of a cluster. If the maintenance from the previous run is still
+// going, this run is skipped
+func (m MaintenanceJob) Run() {
+	contextLogger := log.WithFields(log.Fields{
+		"namespace": m.namespace,
+		"cluster":   m.cluster})
+
+	contextLogger.Info("Running maintenance")
+
+	cluster := crv1.Pgcluster{}
+	found, err := kubeapi.Getpgcluster(restClient, &cluster, m.cluster, m.namespace)
+
+	if !found {
+		contextLogger.WithFields(log.Fields{
+			"error": err,
+		}).Error("pgCluster not found")
+		return
+	} else if err != nil {
+		contextLogger.WithFields(log.Fields{
+			"error": err,
+		}).Error("error retrieving pgCluster")
+		return
+	}
+
+	taskName := fmt.Sprintf("%s-maintenance", m.cluster)
+
+	result := crv1.Pgtask{}
+	found, err = kubeapi.Getpgtask(restClient, &result, taskName, m.namespace)
+
+	if found {
+		if result.Spec.Status != crv1.CompletedStatus {
+			contextLogger.WithFields(log.Fields{
+				"task": taskName,
+			}).Warn("previous maintenance still running, skipping")
+			return
+		}
+
+		if err := kubeapi.Deletepgtask(restClient, taskName, m.namespace); err != nil {
+			contextLogger.WithFields(log.Fields{
+				"task":  taskName,
+				"error": err,
+			}).Error("error deleting pgTask")
+			return
+		}
+	} else if err != nil && !kerrors.IsNotFound(err) {
+		contextLogger.WithFields(log.Fields{
+			"task":  taskName,
+			"error": err,
+		}).Error("error getting pgTask")
+		return
+	}
+
+	task := maintenanceTask{
+		clusterName: cluster.Name,
+		taskName:    taskName,
+	}
+
+	if err := kubeapi.Createpgtask(restClient, task.NewMaintenanceTask(), m.namespace); err != nil {
+		contextLogger.WithFields(log.Fields{
+			"error": err,
+		}).Error("could not create new pgtask")
+		return
+	}
+}
@@ -23,6 +23,7 @@ import (
 	"time"
 
 	"github.com/crunchydata/postgres-operator/apiserver"
+	"github.com/crunchydata/postgres-operator/operator"
 	log "github.com/sirupsen/logrus"
 
 	cv2 "github.com/robfig/cron"
@@ -44,6 +45,7 @@ func New(label, namespace string, nsList []string, client *kubernetes.Clientset)
 		CronClient:    cronClient,
 		entries:       make(map[string]cv2.EntryID),
 		namespaceList: nsList,
+		paused:        make(map[string]bool),
 	}
 }
 
@@ -105,11 +107,74 @@ func (s *Scheduler) schedule(st ScheduleTemplate) (cv2.EntryID, error) {
 		job = st.NewBackRestSchedule()
 	case "policy":
 		job = st.NewPolicySchedule()
+	case "maintenance":
+		job = st.NewMaintenanceSchedule()
 	default:
 		var id cv2.EntryID
 		return id, fmt.Errorf("schedule type not implemented yet")
 	}
-	return s.CronClient.AddJob(st.Schedule, job)
+	return s.CronClient.AddJob(st.Schedule, cv2.FuncJob(func() {
+		if s.isPaused(st.Namespace) {
+			log.WithFields(log.Fields{
+				"namespace": st.Namespace,
+				"type":      st.Type,
+				"name":      st.Name,
+			}).Info("Skipped schedule of paused namespace")
+			return
+		}
+		if !s.isLeader() {
+			log.WithFields(log.Fields{
+				"namespace": st.Namespace,
+				"type":      st.Type,
+				"name":      st.Name,
+			}).Debug("Skipped schedule as this replica of the Operator is not the leader")
+			return
+		}
+		job.Run()
+	}))
+}
+
+// setPaused sets whether the schedules of a namespace are skipped, e.g. during maintenance
+func (s *Scheduler) setPaused(namespace string, paused bool) {
+	s.pausedMutex.Lock()
+	defer s.pausedMutex.Unlock()
+
+	if paused {
+		s.paused[namespace] = true
+	} else {
+		delete(s.paused, namespace)
+	}
+}
+
+// isPaused returns whether the schedules of a namespace are skipped
+func (s *Scheduler) isPaused(namespace string) bool {
+	s.pausedMutex.Lock()
+	defer s.pausedMutex.Unlock()
+
+	return s.paused[namespace]
+}
+
+// SetLeaderElection has the schedules run only while the replica of the Operator in the same Pod,
+// i.e. with identity, holds the Lease of installationName, so that they run once rather than once
+// per replica
+func (s *Scheduler) SetLeaderElection(installationName, identity string) {
+	s.installationName = installationName
+	s.identity = identity
+}
+
+// isLeader returns whether the schedules run on this replica, i.e. whether it is the leader or
+// there is no leader election. A Lease that cannot be read is taken as held by another replica
+func (s *Scheduler) isLeader() bool {
+	if s.identity == "" {
+		return true
+	}
+
+	leader, err := operator.IsLeader(kubeClient, s.namespace, s.installationName, s.identity)
+	if err != nil {
+		log.Errorf("leader election: could not get the Lease of %s: %s", s.installationName, err)
+		return false
+	}
+	return leader
 }
 
 // phony implements a no-op schedule job to prevent a bug that runs newly
@@ -53,3 +53,26 @@ func (p pgBackRestTask) NewBackRestTask() *crv1.Pgtask {
 		},
 	}
 }
+
+type maintenanceTask struct {
+	clusterName string
+	taskName    string
+}
+
+func (m maintenanceTask) NewMaintenanceTask() *crv1.Pgtask {
+	return &crv1.Pgtask{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name: m.taskName,
+			Labels: map[string]string{
+				config.LABEL_PG_CLUSTER: m.clusterName,
+			},
+		},
+		Spec: crv1.PgtaskSpec{
+			Name:     m.taskName,
+			TaskType: crv1.PgtaskMaintenance,
+			Parameters: map[string]string{
+				config.LABEL_PG_CLUSTER: m.clusterName,
+			},
+		},
+	}
+}
@@ -16,6 +16,7 @@ package scheduler
 */
 
 import (
+	"sync"
 	"time"
 
 	"k8s.io/client-go/kubernetes"
@@ -34,6 +35,13 @@ type Scheduler struct {
 	namespace     string
 	namespaceList []string
 	scheduleTypes []string
+	// pausedMutex guards paused, the namespaces whose schedules are skipped while they are paused
+	pausedMutex sync.Mutex
+	paused      map[string]bool
+	// installationName and identity, if set, are those the replica of the Operator in the same
+	// Pod holds the Lease of its installation with, so that only the leader runs the schedules
+	installationName string
+	identity         string
 }
 
 type ScheduleTemplate struct {
@@ -59,6 +59,7 @@ func ValidateScheduleType(schedule string) error {
 	scheduleTypes := []string{
 		"pgbackrest",
 		"policy",
+		"maintenance",
 	}
 
 	schedule = strings.ToLower(schedule)
@@ -57,6 +57,8 @@ func TestValidScheduleType(t *testing.T) {
 		{"POLICY", true},
 		{"pgBackRest", true},
 		{"PoLiCY", true},
+		{"maintenance", true},
+		{"MAINTENANCE", true},
 		{"FOO", false},
 		{"BAR", false},
 		{"foo", false},
@@ -257,6 +257,7 @@ func createCluster(args []string, ns string, createClusterCmd *cobra.Command) {
 	r.CustomConfig = CustomConfig
 	r.StorageConfig = StorageConfig
 	r.ReplicaStorageConfig = ReplicaStorageConfig
+	r.WALStorageConfig = WALStorageConfig
 	r.ContainerResources = ContainerResources
 	r.ClientVersion = msgs.PGO_VERSION
 	r.PodAntiAffinity = PodAntiAffinity
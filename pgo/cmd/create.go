@@ -26,7 +26,7 @@ var ClusterReplicaCount int
 var ManagedUser bool
 var AllNamespaces bool
 var ContainerResources string
-var BackrestStorageConfig, ReplicaStorageConfig, StorageConfig string
+var BackrestStorageConfig, ReplicaStorageConfig, StorageConfig, WALStorageConfig string
 var CustomConfig string
 var ArchiveFlag, DisableAutofailFlag, EnableAutofailFlag, PgbouncerFlag, MetricsFlag, BadgerFlag bool
 var BackrestRestoreFrom string
@@ -302,6 +302,9 @@ func init() {
 	createClusterCmd.Flags().BoolVarP(&BadgerFlag, "pgbadger", "", false, "Adds the crunchy-pgbadger container to the database pod.")
 	createClusterCmd.Flags().BoolVarP(&PgbouncerFlag, "pgbouncer", "", false, "Adds a crunchy-pgbouncer deployment to the cluster.")
 	createClusterCmd.Flags().StringVarP(&ReplicaStorageConfig, "replica-storage-config", "", "", "The name of a Storage config in pgo.yaml to use for the cluster replica storage.")
+	createClusterCmd.Flags().StringVar(&WALStorageConfig, "wal-storage-config", "",
+		"The name of a storage config in pgo.yaml to use for volumes of their own that the "+
+			"write-ahead log of the instances is kept on. By default the WAL is kept in the data directory.")
 	createClusterCmd.Flags().StringVarP(&PodAntiAffinity, "pod-anti-affinity", "", "",
 		"Specifies the type of anti-affinity that should be utilized when applying  "+
 			"default pod anti-affinity rules to PG clusters (default \"preferred\")")
@@ -27,12 +27,17 @@ import (
 	"github.com/spf13/cobra"
 )
 
+// ForceFailover allows for a failover to a replica that is not synchronous
+var ForceFailover bool
+
 var failoverCmd = &cobra.Command{
 	Use:   "failover",
 	Short: "Performs a manual failover",
-	Long: `Performs a manual failover. For example:
+	Long: `Performs a manual failover. Without a target, the replica that is least behind the primary is promoted. A cluster with synchronous replication is only failed over to a synchronous replica, unless the failover is forced. For example:
 
-	pgo failover mycluster`,
+	pgo failover mycluster
+	pgo failover mycluster --target=mycluster-abcd
+	pgo failover mycluster --target=mycluster-abcd --force`,
 	Run: func(cmd *cobra.Command, args []string) {
 		if Namespace == "" {
 			Namespace = PGONamespace
@@ -44,10 +49,6 @@ var failoverCmd = &cobra.Command{
 			if Query {
 				queryFailover(args, Namespace)
 			} else if util.AskForConfirmation(NoPrompt, "") {
-				if Target == "" {
-					fmt.Println(`Error: The --target flag is required for failover.`)
-					return
-				}
 				createFailover(args, Namespace)
 			} else {
 				fmt.Println("Aborting...")
@@ -61,8 +62,9 @@ func init() {
 	RootCmd.AddCommand(failoverCmd)
 
 	failoverCmd.Flags().BoolVarP(&Query, "query", "", false, "Prints the list of failover candidates.")
+	failoverCmd.Flags().BoolVar(&ForceFailover, "force", false, "Allows for failing over to a replica that is not synchronous, which can lose transactions.")
 	failoverCmd.Flags().BoolVar(&NoPrompt, "no-prompt", false, "No command line confirmation.")
-	failoverCmd.Flags().StringVarP(&Target, "target", "", "", "The replica target which the failover will occur on.")
+	failoverCmd.Flags().StringVarP(&Target, "target", "", "", "The replica target which the failover will occur on. Defaults to the replica that is least behind the primary.")
 
 }
 
@@ -74,6 +76,7 @@ func createFailover(args []string, ns string) {
 	request.Namespace = ns
 	request.ClusterName = args[0]
 	request.Target = Target
+	request.Force = ForceFailover
 	request.ClientVersion = msgs.PGO_VERSION
 
 	response, err := api.CreateFailover(httpclient, &SessionCredentials, request)
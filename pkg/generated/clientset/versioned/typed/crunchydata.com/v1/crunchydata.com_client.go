@@ -26,9 +26,12 @@ import (
 type CrunchydataV1Interface interface {
 	RESTClient() rest.Interface
 	PgclustersGetter
+	PgdatabasesGetter
 	PgpoliciesGetter
 	PgreplicasGetter
+	PgschedulesGetter
 	PgtasksGetter
+	PgusersGetter
 }
 
 // CrunchydataV1Client is used to interact with features provided by the crunchydata.com group.
@@ -40,6 +43,10 @@ func (c *CrunchydataV1Client) Pgclusters(namespace string) PgclusterInterface {
 	return newPgclusters(c, namespace)
 }
 
+func (c *CrunchydataV1Client) Pgdatabases(namespace string) PgdatabaseInterface {
+	return newPgdatabases(c, namespace)
+}
+
 func (c *CrunchydataV1Client) Pgpolicies(namespace string) PgpolicyInterface {
 	return newPgpolicies(c, namespace)
 }
@@ -48,10 +55,18 @@ func (c *CrunchydataV1Client) Pgreplicas(namespace string) PgreplicaInterface {
 	return newPgreplicas(c, namespace)
 }
 
+func (c *CrunchydataV1Client) Pgschedules(namespace string) PgscheduleInterface {
+	return newPgschedules(c, namespace)
+}
+
 func (c *CrunchydataV1Client) Pgtasks(namespace string) PgtaskInterface {
 	return newPgtasks(c, namespace)
 }
 
+func (c *CrunchydataV1Client) Pgusers(namespace string) PguserInterface {
+	return newPgusers(c, namespace)
+}
+
 // NewForConfig creates a new CrunchydataV1Client for the given config.
 func NewForConfig(c *rest.Config) (*CrunchydataV1Client, error) {
 	config := *c
@@ -31,6 +31,10 @@ func (c *FakeCrunchydataV1) Pgclusters(namespace string) v1.PgclusterInterface {
 	return &FakePgclusters{c, namespace}
 }
 
+func (c *FakeCrunchydataV1) Pgdatabases(namespace string) v1.PgdatabaseInterface {
+	return &FakePgdatabases{c, namespace}
+}
+
 func (c *FakeCrunchydataV1) Pgpolicies(namespace string) v1.PgpolicyInterface {
 	return &FakePgpolicies{c, namespace}
 }
@@ -39,10 +43,18 @@ func (c *FakeCrunchydataV1) Pgreplicas(namespace string) v1.PgreplicaInterface {
 	return &FakePgreplicas{c, namespace}
 }
 
+func (c *FakeCrunchydataV1) Pgschedules(namespace string) v1.PgscheduleInterface {
+	return &FakePgschedules{c, namespace}
+}
+
 func (c *FakeCrunchydataV1) Pgtasks(namespace string) v1.PgtaskInterface {
 	return &FakePgtasks{c, namespace}
 }
 
+func (c *FakeCrunchydataV1) Pgusers(namespace string) v1.PguserInterface {
+	return &FakePgusers{c, namespace}
+}
+
 // RESTClient returns a RESTClient that is used to communicate
 // with API server by this client implementation.
 func (c *FakeCrunchydataV1) RESTClient() rest.Interface {
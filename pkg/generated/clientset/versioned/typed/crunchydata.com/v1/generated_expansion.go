@@ -19,8 +19,14 @@ package v1
 
 type PgclusterExpansion interface{}
 
+type PgdatabaseExpansion interface{}
+
 type PgpolicyExpansion interface{}
 
 type PgreplicaExpansion interface{}
 
+type PgscheduleExpansion interface{}
+
 type PgtaskExpansion interface{}
+
+type PguserExpansion interface{}
@@ -25,12 +25,18 @@ import (
 type Interface interface {
 	// Pgclusters returns a PgclusterInformer.
 	Pgclusters() PgclusterInformer
+	// Pgdatabases returns a PgdatabaseInformer.
+	Pgdatabases() PgdatabaseInformer
 	// Pgpolicies returns a PgpolicyInformer.
 	Pgpolicies() PgpolicyInformer
 	// Pgreplicas returns a PgreplicaInformer.
 	Pgreplicas() PgreplicaInformer
+	// Pgschedules returns a PgscheduleInformer.
+	Pgschedules() PgscheduleInformer
 	// Pgtasks returns a PgtaskInformer.
 	Pgtasks() PgtaskInformer
+	// Pgusers returns a PguserInformer.
+	Pgusers() PguserInformer
 }
 
 type version struct {
@@ -49,6 +55,11 @@ func (v *version) Pgclusters() PgclusterInformer {
 	return &pgclusterInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
 }
 
+// Pgdatabases returns a PgdatabaseInformer.
+func (v *version) Pgdatabases() PgdatabaseInformer {
+	return &pgdatabaseInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
+}
+
 // Pgpolicies returns a PgpolicyInformer.
 func (v *version) Pgpolicies() PgpolicyInformer {
 	return &pgpolicyInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
@@ -59,7 +70,17 @@ func (v *version) Pgreplicas() PgreplicaInformer {
 	return &pgreplicaInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
 }
 
+// Pgschedules returns a PgscheduleInformer.
+func (v *version) Pgschedules() PgscheduleInformer {
+	return &pgscheduleInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
+}
+
 // Pgtasks returns a PgtaskInformer.
 func (v *version) Pgtasks() PgtaskInformer {
 	return &pgtaskInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
 }
+
+// Pgusers returns a PguserInformer.
+func (v *version) Pgusers() PguserInformer {
+	return &pguserInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
+}
@@ -54,12 +54,18 @@ func (f *sharedInformerFactory) ForResource(resource schema.GroupVersionResource
 	// Group=crunchydata.com, Version=v1
 	case v1.SchemeGroupVersion.WithResource("pgclusters"):
 		return &genericInformer{resource: resource.GroupResource(), informer: f.Crunchydata().V1().Pgclusters().Informer()}, nil
+	case v1.SchemeGroupVersion.WithResource("pgdatabases"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Crunchydata().V1().Pgdatabases().Informer()}, nil
 	case v1.SchemeGroupVersion.WithResource("pgpolicies"):
 		return &genericInformer{resource: resource.GroupResource(), informer: f.Crunchydata().V1().Pgpolicies().Informer()}, nil
 	case v1.SchemeGroupVersion.WithResource("pgreplicas"):
 		return &genericInformer{resource: resource.GroupResource(), informer: f.Crunchydata().V1().Pgreplicas().Informer()}, nil
+	case v1.SchemeGroupVersion.WithResource("pgschedules"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Crunchydata().V1().Pgschedules().Informer()}, nil
 	case v1.SchemeGroupVersion.WithResource("pgtasks"):
 		return &genericInformer{resource: resource.GroupResource(), informer: f.Crunchydata().V1().Pgtasks().Informer()}, nil
+	case v1.SchemeGroupVersion.WithResource("pgusers"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Crunchydata().V1().Pgusers().Informer()}, nil
 
 	}
 
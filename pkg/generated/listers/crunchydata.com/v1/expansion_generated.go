@@ -25,6 +25,14 @@ type PgclusterListerExpansion interface{}
 // PgclusterNamespaceLister.
 type PgclusterNamespaceListerExpansion interface{}
 
+// PgdatabaseListerExpansion allows custom methods to be added to
+// PgdatabaseLister.
+type PgdatabaseListerExpansion interface{}
+
+// PgdatabaseNamespaceListerExpansion allows custom methods to be added to
+// PgdatabaseNamespaceLister.
+type PgdatabaseNamespaceListerExpansion interface{}
+
 // PgpolicyListerExpansion allows custom methods to be added to
 // PgpolicyLister.
 type PgpolicyListerExpansion interface{}
@@ -41,6 +49,14 @@ type PgreplicaListerExpansion interface{}
 // PgreplicaNamespaceLister.
 type PgreplicaNamespaceListerExpansion interface{}
 
+// PgscheduleListerExpansion allows custom methods to be added to
+// PgscheduleLister.
+type PgscheduleListerExpansion interface{}
+
+// PgscheduleNamespaceListerExpansion allows custom methods to be added to
+// PgscheduleNamespaceLister.
+type PgscheduleNamespaceListerExpansion interface{}
+
 // PgtaskListerExpansion allows custom methods to be added to
 // PgtaskLister.
 type PgtaskListerExpansion interface{}
@@ -48,3 +64,11 @@ type PgtaskListerExpansion interface{}
 // PgtaskNamespaceListerExpansion allows custom methods to be added to
 // PgtaskNamespaceLister.
 type PgtaskNamespaceListerExpansion interface{}
+
+// PguserListerExpansion allows custom methods to be added to
+// PguserLister.
+type PguserListerExpansion interface{}
+
+// PguserNamespaceListerExpansion allows custom methods to be added to
+// PguserNamespaceLister.
+type PguserNamespaceListerExpansion interface{}
@@ -16,6 +16,8 @@ limitations under the License.
 */
 
 import (
+	"flag"
+	"io/ioutil"
 	"os"
 	"time"
 
@@ -27,11 +29,16 @@ import (
 	"github.com/crunchydata/postgres-operator/operator/operatorupgrade"
 	log "github.com/sirupsen/logrus"
 
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	kubeinformers "k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
 
 	"github.com/crunchydata/postgres-operator/kubeapi"
 	"github.com/crunchydata/postgres-operator/ns"
 	"github.com/crunchydata/postgres-operator/operator"
+	"github.com/crunchydata/postgres-operator/operator/bundle"
+	"github.com/crunchydata/postgres-operator/operator/webhook"
 )
 
 func main() {
@@ -46,6 +53,11 @@ func main() {
 		log.Info("debug flag set to false")
 	}
 
+	// run an admin command instead of the Operator if one is given
+	if len(os.Args) > 1 {
+		os.Exit(runAdminCommand(os.Args[1:]))
+	}
+
 	//give time for pgo-event to start up
 	time.Sleep(time.Duration(5) * time.Second)
 
@@ -56,26 +68,91 @@ func main() {
 	}
 
 	kubeClientset := clients.Kubeclientset
-	pgoRESTclient := clients.PGORestclient
 
 	operator.Initialize(kubeClientset)
 
-	namespaceList := ns.GetNamespaces(kubeClientset, operator.InstallationName)
-	log.Debugf("watching the following namespaces: [%v]", namespaceList)
+	// set up signals so we handle the first shutdown signal gracefully
+	stopCh := signals.SetupSignalHandler()
+
+	// the probes of the Pod check on the controllers, which also covers a replica that stands by
+	go operator.ServeHealth(operator.Pgo.Pgo.HealthPort)
+
+	if operator.Pgo.Pgo.MetricsPort != "" {
+		go operator.ServeMetrics(operator.Pgo.Pgo.MetricsPort)
+	}
 
-	//validate the NAMESPACE env var
-	err = ns.ValidateNamespaces(kubeClientset, operator.InstallationName, operator.PgoNamespace)
+	// the validation of the pgclusters does not change anything, so every replica serves it. The
+	// Operator does not start without it, rather than letting the resources go unvalidated
+	if operator.Pgo.Pgo.WebhookPort != "" {
+		if err := webhook.Serve(kubeClientset, operator.Pgo.Pgo.WebhookPort,
+			operator.Pgo.Pgo.WebhookTLSSecret); err != nil {
+			log.Error(err)
+			os.Exit(2)
+		}
+	}
+
+	// with leader election, the replicas of the Operator that are not the leader stand by until
+	// one of them takes over from it
+	var controllerManager *manager.ControllerManager
+
+	releaseLeadership, err := operator.RunAsLeader(kubeClientset, stopCh, func() {
+		controllerManager = runOperator(clients, stopCh)
+	})
 	if err != nil {
 		log.Error(err)
 		os.Exit(2)
 	}
 
-	// set up signals so we handle the first shutdown signal gracefully
-	stopCh := signals.SetupSignalHandler()
+	log.Info("PostgreSQL Operator initialized and running, waiting for signal to exit")
+	<-stopCh
+	log.Infof("Signal received, now exiting")
+
+	// let the controllers finish the work they have queued, e.g. as the Operator is upgraded
+	if controllerManager != nil {
+		controllerManager.ShutdownWithTimeout(
+			time.Duration(operator.Pgo.Pgo.ShutdownTimeoutSeconds) * time.Second)
+	}
+
+	// the Lease is only released once the controllers are shut down, so that the replica that
+	// takes over does not process the same resources at the same time
+	releaseLeadership()
+}
+
+// runOperator runs the controllers of the Operator for the namespaces it watches, along with the
+// namespace controller that adds and removes them as the namespaces change, and returns the
+// controller manager that runs them. The controllers share the clients provided
+func runOperator(clients *kubeapi.ControllerClients, stopCh <-chan struct{}) *manager.ControllerManager {
+	kubeClientset := clients.Kubeclientset
+	pgoRESTclient := clients.PGORestclient
+
+	// hold back the reconciles for a while if the Operator is to start in safe mode, which has
+	// to happen before any of the controllers run
+	operator.StartSafeMode(kubeClientset)
+
+	// the namespaces that match the namespace selector, if one is set, are picked up and dropped
+	// as they are labeled
+	namespaceList, namespaceSelector, err := getWatchedNamespaces(kubeClientset)
+	if err != nil {
+		log.Error(err)
+		os.Exit(2)
+	}
+
+	if namespaceSelector != nil {
+		log.Infof("watching the namespaces that match %q, the NAMESPACE environment variable is ignored",
+			namespaceSelector.String())
+	} else {
+		//validate the NAMESPACE env var
+		err = ns.ValidateNamespaces(kubeClientset, operator.InstallationName, operator.PgoNamespace)
+		if err != nil {
+			log.Error(err)
+			os.Exit(2)
+		}
+	}
+	log.Debugf("watching the following namespaces: [%v]", namespaceList)
 
 	// create a new controller manager with controllers for all current namespaces and then run
 	// all of those controllers
-	controllerManager, err := manager.NewControllerManager(namespaceList)
+	controllerManager, err := manager.NewControllerManager(clients, namespaceList)
 	if err != nil {
 		log.Error(err)
 		os.Exit(2)
@@ -83,13 +160,19 @@ func main() {
 	controllerManager.RunAll()
 	log.Debug("controller manager created and all included controllers are now running")
 
+	operator.SetHealthChecker(controllerManager)
+
+	// with a namespace selector, only the namespaces that match it are watched. A namespace that
+	// stops matching it is then reported as deleted, which removes its controllers
 	nsKubeInformerFactory := kubeinformers.NewSharedInformerFactory(kubeClientset, 0)
-	if err != nil {
-		log.Error(err)
-		os.Exit(2)
+	if namespaceSelector != nil {
+		nsKubeInformerFactory = kubeinformers.NewSharedInformerFactoryWithOptions(kubeClientset, 0,
+			kubeinformers.WithTweakListOptions(func(options *metav1.ListOptions) {
+				options.LabelSelector = namespaceSelector.String()
+			}))
 	}
 	nsController, err := namespace.NewNamespaceController(controllerManager,
-		nsKubeInformerFactory.Core().V1().Namespaces())
+		nsKubeInformerFactory.Core().V1().Namespaces(), kubeClientset, namespaceSelector)
 	if err != nil {
 		log.Error(err)
 		os.Exit(2)
@@ -100,11 +183,124 @@ func main() {
 	nsKubeInformerFactory.Start(stopCh)
 	log.Debug("namespace controller is now running")
 
-	defer controllerManager.StopAll()
+	operator.SafeReconcile("operator-upgrade", "update the Operator version of the custom resources",
+		func() {
+			operatorupgrade.OperatorUpdateCRPgoVersion(kubeClientset, pgoRESTclient, namespaceList)
+		})
 
-	operatorupgrade.OperatorUpdateCRPgoVersion(kubeClientset, pgoRESTclient, namespaceList)
+	return controllerManager
+}
 
-	log.Info("PostgreSQL Operator initialized and running, waiting for signal to exit")
-	<-stopCh
-	log.Infof("Signal received, now exiting")
+// getWatchedNamespaces returns the namespaces the Operator watches, which are either those that
+// belong to this installation, or, if a namespace selector is set, those that match it, along
+// with the selector if there is one
+func getWatchedNamespaces(kubeClientset *kubernetes.Clientset) ([]string, labels.Selector, error) {
+	if operator.Pgo.Pgo.NamespaceSelector == "" {
+		return ns.GetNamespaces(kubeClientset, operator.InstallationName), nil, nil
+	}
+
+	namespaceSelector, err := labels.Parse(operator.Pgo.Pgo.NamespaceSelector)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return ns.GetNamespacesBySelector(kubeClientset, namespaceSelector.String()), namespaceSelector, nil
+}
+
+// runAdminCommand runs one of the admin commands of the Operator and returns its exit code. The
+// commands are used to rebuild the state of the Operator in another Kubernetes cluster:
+//
+//	export [-o file] [-format yaml|json]  writes the Pgclusters, Pgreplicas, Pgpolicies and
+//	                                      Pgtasks of the watched namespaces to a bundle
+//	import [-f file]                      applies a bundle to the Kubernetes cluster
+func runAdminCommand(args []string) int {
+	// keep the log apart from a bundle that is written to stdout
+	log.SetOutput(os.Stderr)
+
+	clients, err := kubeapi.NewControllerClients()
+	if err != nil {
+		log.Error(err)
+		return 1
+	}
+
+	switch args[0] {
+	case "export":
+		flags := flag.NewFlagSet("export", flag.ContinueOnError)
+		output := flags.String("o", "-", "the file the bundle is written to, - for stdout")
+		format := flags.String("format", "yaml", "the format of the bundle, yaml or json")
+		if err := flags.Parse(args[1:]); err != nil {
+			return 2
+		}
+
+		operator.Initialize(clients.Kubeclientset)
+		namespaceList, _, err := getWatchedNamespaces(clients.Kubeclientset)
+		if err != nil {
+			log.Error(err)
+			return 1
+		}
+
+		exported, err := bundle.Export(clients.PGOClientset, namespaceList)
+		if err != nil {
+			log.Error(err)
+			return 1
+		}
+
+		data, err := exported.Marshal(*format)
+		if err != nil {
+			log.Error(err)
+			return 1
+		}
+
+		if *output == "-" {
+			_, err = os.Stdout.Write(data)
+		} else {
+			err = ioutil.WriteFile(*output, data, 0600)
+		}
+		if err != nil {
+			log.Error(err)
+			return 1
+		}
+
+		log.Infof("exported %d pgclusters, %d pgreplicas, %d pgpolicies and %d pgtasks",
+			len(exported.Pgclusters), len(exported.Pgreplicas), len(exported.Pgpolicies),
+			len(exported.Pgtasks))
+	case "import":
+		flags := flag.NewFlagSet("import", flag.ContinueOnError)
+		input := flags.String("f", "-", "the file the bundle is read from, - for stdin")
+		if err := flags.Parse(args[1:]); err != nil {
+			return 2
+		}
+
+		var data []byte
+		if *input == "-" {
+			data, err = ioutil.ReadAll(os.Stdin)
+		} else {
+			data, err = ioutil.ReadFile(*input)
+		}
+		if err != nil {
+			log.Error(err)
+			return 1
+		}
+
+		imported, err := bundle.Unmarshal(data)
+		if err != nil {
+			log.Error(err)
+			return 1
+		}
+
+		result, err := bundle.Import(clients.PGOClientset, imported)
+
+		log.Infof("imported bundle: %d created, %d updated, %d unchanged, %d failed",
+			result.Created, result.Updated, result.Unchanged, result.Failed)
+
+		if err != nil {
+			log.Error(err)
+			return 1
+		}
+	default:
+		log.Errorf("unknown command %q, must be \"export\" or \"import\"", args[0])
+		return 2
+	}
+
+	return 0
 }
@@ -19,6 +19,7 @@ import (
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/pem"
 	"errors"
 	"io"
@@ -95,6 +96,74 @@ func NewSelfSignedCACertificate(key *rsa.PrivateKey) (*x509.Certificate, error)
 	return x509.ParseCertificate(certDERBytes)
 }
 
+// NewCACertificate returns a self-signed CA certificate with the given common
+// name and private key, valid for the given amount of time.
+func NewCACertificate(key *rsa.PrivateKey, commonName string, validity time.Duration) (*x509.Certificate, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).SetInt64(math.MaxInt64))
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	tmpl := x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             now.UTC(),
+		NotAfter:              now.Add(validity).UTC(),
+		KeyUsage:              x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	certDERBytes, err := x509.CreateCertificate(rand.Reader, &tmpl, &tmpl, key.Public(), key)
+	if err != nil {
+		return nil, err
+	}
+	return x509.ParseCertificate(certDERBytes)
+}
+
+// NewClientCertificate returns a certificate for TLS client authentication
+// with the given common name, signed by the given CA certificate and key. The
+// certificate has one-year lease.
+func NewClientCertificate(key *rsa.PrivateKey, commonName string, caCert *x509.Certificate, caKey *rsa.PrivateKey) (*x509.Certificate, error) {
+	return NewClientCertificateValidFor(key, commonName, duration365d, caCert, caKey)
+}
+
+// NewClientCertificateValidFor returns a certificate for TLS client
+// authentication like NewClientCertificate, valid for the given amount of time.
+func NewClientCertificateValidFor(key *rsa.PrivateKey, commonName string, validity time.Duration, caCert *x509.Certificate, caKey *rsa.PrivateKey) (*x509.Certificate, error) {
+	return newLeafCertificate(key, commonName, nil, x509.ExtKeyUsageClientAuth, validity, caCert, caKey)
+}
+
+// NewServerCertificate returns a certificate for a TLS server with the given
+// common name and DNS names, signed by the given CA certificate and key and
+// valid for the given amount of time.
+func NewServerCertificate(key *rsa.PrivateKey, commonName string, dnsNames []string, validity time.Duration, caCert *x509.Certificate, caKey *rsa.PrivateKey) (*x509.Certificate, error) {
+	return newLeafCertificate(key, commonName, dnsNames, x509.ExtKeyUsageServerAuth, validity, caCert, caKey)
+}
+
+// newLeafCertificate returns a certificate for the given usage, signed by the
+// given CA certificate and key.
+func newLeafCertificate(key *rsa.PrivateKey, commonName string, dnsNames []string, usage x509.ExtKeyUsage, validity time.Duration, caCert *x509.Certificate, caKey *rsa.PrivateKey) (*x509.Certificate, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).SetInt64(math.MaxInt64))
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	tmpl := x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: commonName},
+		DNSNames:     dnsNames,
+		NotBefore:    now.UTC(),
+		NotAfter:     now.Add(validity).UTC(),
+		KeyUsage:     x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+	certDERBytes, err := x509.CreateCertificate(rand.Reader, &tmpl, caCert, key.Public(), caKey)
+	if err != nil {
+		return nil, err
+	}
+	return x509.ParseCertificate(certDERBytes)
+}
+
 // ExtendTrust extends the provided certpool with the PEM-encoded certificates
 // presented by certSource. If reading from certSource produces an error
 // the base pool remains unmodified
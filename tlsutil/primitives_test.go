@@ -26,6 +26,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 )
 
 func TestKeyPEMSymmetry(t *testing.T) {
@@ -70,6 +71,106 @@ func TestCertPEMSymmetry(t *testing.T) {
 	}
 }
 
+func TestClientCertificate(t *testing.T) {
+	caKey, err := NewPrivateKey()
+	if err != nil {
+		t.Fatalf("unable to generate new key - %s", err)
+	}
+
+	caCert, err := NewSelfSignedCACertificate(caKey)
+	if err != nil {
+		t.Fatalf("unable to generate cert - %s", err)
+	}
+
+	key, err := NewPrivateKey()
+	if err != nil {
+		t.Fatalf("unable to generate new key - %s", err)
+	}
+
+	cert, err := NewClientCertificate(key, "testuser", caCert, caKey)
+	if err != nil {
+		t.Fatalf("unable to generate client cert - %s", err)
+	}
+
+	if cert.Subject.CommonName != "testuser" {
+		t.Fatalf("expected common name [testuser], got [%s] instead", cert.Subject.CommonName)
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(caCert)
+
+	if _, err := cert.Verify(x509.VerifyOptions{
+		Roots:     roots,
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}); err != nil {
+		t.Fatalf("client cert is not trusted by its CA - %s", err)
+	}
+
+	otherKey, err := NewPrivateKey()
+	if err != nil {
+		t.Fatalf("unable to generate new key - %s", err)
+	}
+
+	otherCert, err := NewSelfSignedCACertificate(otherKey)
+	if err != nil {
+		t.Fatalf("unable to generate cert - %s", err)
+	}
+
+	if err := cert.CheckSignatureFrom(otherCert); err == nil {
+		t.Fatal("client cert should not be signed by an unrelated CA")
+	}
+}
+
+func TestServerCertificate(t *testing.T) {
+	caKey, err := NewPrivateKey()
+	if err != nil {
+		t.Fatalf("unable to generate new key - %s", err)
+	}
+
+	caCert, err := NewCACertificate(caKey, "hippo-ca", 10*duration365d)
+	if err != nil {
+		t.Fatalf("unable to generate cert - %s", err)
+	}
+
+	if caCert.Subject.CommonName != "hippo-ca" || !caCert.IsCA {
+		t.Fatalf("expected CA certificate [hippo-ca], got [%s] instead", caCert.Subject.CommonName)
+	}
+
+	key, err := NewPrivateKey()
+	if err != nil {
+		t.Fatalf("unable to generate new key - %s", err)
+	}
+
+	validity := 90 * 24 * time.Hour
+
+	cert, err := NewServerCertificate(key, "hippo", []string{"hippo", "hippo.pgo.svc"}, validity, caCert, caKey)
+	if err != nil {
+		t.Fatalf("unable to generate server cert - %s", err)
+	}
+
+	if lease := cert.NotAfter.Sub(cert.NotBefore); lease != validity {
+		t.Fatalf("expected a lease of [%s], got [%s] instead", validity, lease)
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(caCert)
+
+	if _, err := cert.Verify(x509.VerifyOptions{
+		DNSName:   "hippo.pgo.svc",
+		Roots:     roots,
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}); err != nil {
+		t.Fatalf("server cert is not trusted by its CA - %s", err)
+	}
+
+	if _, err := cert.Verify(x509.VerifyOptions{
+		Roots:     roots,
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}); err == nil {
+		t.Fatal("server cert should not be valid for client authentication")
+	}
+}
+
 func TestExtendedTrust(t *testing.T) {
 	expected := "You do that very well. It's as if i was looking in a mirror."
 
@@ -44,13 +44,17 @@ func ValidateBackrestStorageTypeOnBackupRestore(newBackRestStorageType,
 		!strings.Contains(currentBackRestStorageType, "s3") {
 		return errors.New("Storage type 's3' not allowed. S3 storage is not enabled for " +
 			"pgBackRest in this cluster")
+	} else if (newBackRestStorageType == "gcs" || newBackRestStorageType == "azure") &&
+		newBackRestStorageType != currentBackRestStorageType {
+		return fmt.Errorf("Storage type '%s' not allowed. It is not the storage type of "+
+			"pgBackRest in this cluster", newBackRestStorageType)
 	} else if (newBackRestStorageType == "" ||
 		strings.Contains(newBackRestStorageType, "local")) &&
 		(currentBackRestStorageType != "" &&
 			!strings.Contains(currentBackRestStorageType, "local")) {
 		return errors.New("Storage type 'local' not allowed. Local storage is not enabled for " +
-			"pgBackRest in this cluster. If this cluster uses S3 storage only, specify 's3' " +
-			"for the pgBackRest storage type.")
+			"pgBackRest in this cluster. If this cluster uses S3, GCS or Azure storage only, " +
+			"specify 's3', 'gcs' or 'azure' for the pgBackRest storage type.")
 	}
 
 	// storage type validation that is only applicable for restores
@@ -65,14 +69,20 @@ func ValidateBackrestStorageTypeOnBackupRestore(newBackRestStorageType,
 }
 
 // IsValidBackrestStorageType determines if the storage source string contains valid pgBackRest
-// storage type values
+// storage type values. GCS and Azure storage are only valid on their own, as the repository is
+// then stored there rather than locally
 func IsValidBackrestStorageType(storageType string) bool {
 	isValid := true
-	for _, storageType := range strings.Split(storageType, ",") {
+	storageTypes := strings.Split(storageType, ",")
+	for _, storageType := range storageTypes {
 		if !IsStringOneOf(storageType, crv1.BackrestStorageTypes...) {
 			isValid = false
 			break
 		}
+		if IsStringOneOf(storageType, "gcs", "azure") && len(storageTypes) > 1 {
+			isValid = false
+			break
+		}
 	}
 	return isValid
 }
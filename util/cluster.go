@@ -72,6 +72,11 @@ const (
 	BackRestRepoSecretKeyAWSS3KeyAWSS3CACert    = "aws-s3-ca.crt"
 	BackRestRepoSecretKeyAWSS3KeyAWSS3Key       = "aws-s3-key"
 	BackRestRepoSecretKeyAWSS3KeyAWSS3KeySecret = "aws-s3-key-secret"
+	// the credentials of a repository that is stored in GCS or Azure, which
+	// are exported for the same reason
+	BackRestRepoSecretKeyGCSKey       = "gcs-key"
+	BackRestRepoSecretKeyAzureAccount = "azure-account"
+	BackRestRepoSecretKeyAzureKey     = "azure-key"
 	// the rest are private
 	backRestRepoSecretKeyAuthorizedKeys    = "authorized_keys"
 	backRestRepoSecretKeySSHConfig         = "config"
@@ -174,6 +179,15 @@ func CreateBackrestRepoSecrets(clientset *kubernetes.Clientset,
 		},
 	}
 
+	// the default credentials of GCS and Azure, if the Operator pgBackRest secret has any, are
+	// available to the cluster as well
+	for _, key := range []string{BackRestRepoSecretKeyGCSKey, BackRestRepoSecretKeyAzureAccount,
+		BackRestRepoSecretKeyAzureKey} {
+		if len(configs.Data[key]) > 0 {
+			secret.Data[key] = configs.Data[key]
+		}
+	}
+
 	return kubeapi.CreateSecret(clientset, &secret, backrestRepoConfig.ClusterNamespace)
 }
 
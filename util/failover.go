@@ -36,6 +36,7 @@ type InstanceReplicationInfo struct {
 	Name           string
 	Node           string
 	ReplicationLag int
+	Role           string
 	Status         string
 	Timeline       int
 }
@@ -194,6 +195,7 @@ func ReplicationStatus(request ReplicationStatusRequest) (ReplicationStatusRespo
 		// set up the instance that will be returned
 		instance := InstanceReplicationInfo{
 			ReplicationLag: rawInstance.ReplicationLag,
+			Role:           rawInstance.Type,
 			Status:         rawInstance.State,
 			Timeline:       rawInstance.Timeline,
 		}
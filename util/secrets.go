@@ -16,7 +16,12 @@ package util
 */
 
 import (
+	"crypto/hmac"
 	"crypto/md5"
+	cryptorand "crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
 	"encoding/hex"
 	"fmt"
 	"math/rand"
@@ -38,6 +43,15 @@ const charset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
 
 const charsetNumbers = "0123456789"
 
+const (
+	// scramIterations is the number of iterations of the SCRAM-SHA-256 verifiers,
+	// which is what PostgreSQL uses by default
+	scramIterations = 4096
+	// scramSaltLength is the length of the salt of the SCRAM-SHA-256 verifiers,
+	// which is what PostgreSQL uses by default
+	scramSaltLength = 16
+)
+
 var seededRand = rand.New(
 	rand.NewSource(time.Now().UnixNano()))
 
@@ -91,6 +105,72 @@ func GeneratePostgreSQLMD5Password(username, password string) string {
 	return fmt.Sprintf("md5%s", hex.EncodeToString(hasher.Sum(nil)))
 }
 
+// GeneratePostgreSQLSCRAMPassword takes a plaintext password and returns the
+// PostgreSQL formatted SCRAM-SHA-256 verifier of it with a random salt, which
+// is:
+// "SCRAM-SHA-256$<iterations>:<salt>$<StoredKey>:<ServerKey>"
+func GeneratePostgreSQLSCRAMPassword(password string) (string, error) {
+	salt := make([]byte, scramSaltLength)
+	if _, err := cryptorand.Read(salt); err != nil {
+		return "", err
+	}
+
+	return generateSCRAMVerifier(password, salt, scramIterations), nil
+}
+
+// GeneratePostgreSQLPassword takes a username and a plaintext password and
+// returns the password hashed as the given password encryption has PostgreSQL
+// store it, i.e. as a MD5 hash or as a SCRAM-SHA-256 verifier
+func GeneratePostgreSQLPassword(encryption crv1.PasswordEncryptionType, username, password string) (string, error) {
+	switch encryption {
+	case crv1.PasswordEncryptionSCRAMSHA256:
+		return GeneratePostgreSQLSCRAMPassword(password)
+	case crv1.PasswordEncryptionMD5, "":
+		return GeneratePostgreSQLMD5Password(username, password), nil
+	default:
+		return "", fmt.Errorf("unsupported password encryption %q", encryption)
+	}
+}
+
+// generateSCRAMVerifier returns the SCRAM-SHA-256 verifier of a password as
+// described in RFC 5802 and RFC 7677. The password is expected to be ASCII, as
+// the generated ones are, so it is not normalized with SASLprep
+func generateSCRAMVerifier(password string, salt []byte, iterations int) string {
+	// the salted password is PBKDF2 with HMAC-SHA-256, of which a single block
+	// is needed as it is as long as the output of SHA-256
+	mac := hmac.New(sha256.New, []byte(password))
+	block := make([]byte, 4)
+	binary.BigEndian.PutUint32(block, 1)
+	mac.Write(salt)
+	mac.Write(block)
+	u := mac.Sum(nil)
+	salted := append([]byte{}, u...)
+	for i := 1; i < iterations; i++ {
+		mac.Reset()
+		mac.Write(u)
+		u = mac.Sum(u[:0])
+		for j := range salted {
+			salted[j] ^= u[j]
+		}
+	}
+
+	clientKey := scramHMAC(salted, "Client Key")
+	storedKey := sha256.Sum256(clientKey)
+	serverKey := scramHMAC(salted, "Server Key")
+
+	return fmt.Sprintf("SCRAM-SHA-256$%d:%s$%s:%s", iterations,
+		base64.StdEncoding.EncodeToString(salt),
+		base64.StdEncoding.EncodeToString(storedKey[:]),
+		base64.StdEncoding.EncodeToString(serverKey))
+}
+
+// scramHMAC returns the HMAC-SHA-256 of a message with the given key
+func scramHMAC(key []byte, message string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(message))
+	return mac.Sum(nil)
+}
+
 // GenerateRandString generate a rand lowercase string of a given length
 func GenerateRandString(length int) string {
 	return stringWithCharset(length, lowercharset)
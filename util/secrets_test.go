@@ -0,0 +1,67 @@
+package util
+
+/*
+ Copyright 2020 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"strings"
+	"testing"
+
+	crv1 "github.com/crunchydata/postgres-operator/apis/crunchydata.com/v1"
+)
+
+func TestGenerateSCRAMVerifier(t *testing.T) {
+	expected := "SCRAM-SHA-256$4096:MDEyMzQ1Njc4OWFiY2RlZg==" +
+		"$1AL1dBRZ9/eJG8bmNseQLnzjdvsVePVUZefUO2zLONc=:lYnY/+xcgTu3yqB7ndBZ0cDFtWQ92dTTGMSQbKNm/Gc="
+
+	if verifier := generateSCRAMVerifier("datalake", []byte("0123456789abcdef"), 4096); verifier != expected {
+		t.Errorf("expected %q, got %q", expected, verifier)
+	}
+}
+
+func TestGeneratePostgreSQLPassword(t *testing.T) {
+	t.Run("md5", func(t *testing.T) {
+		for _, encryption := range []crv1.PasswordEncryptionType{"", crv1.PasswordEncryptionMD5} {
+			hashed, err := GeneratePostgreSQLPassword(encryption, "hippo", "datalake")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if expected := GeneratePostgreSQLMD5Password("hippo", "datalake"); hashed != expected {
+				t.Errorf("expected %q, got %q", expected, hashed)
+			}
+		}
+	})
+
+	t.Run("scram-sha-256", func(t *testing.T) {
+		first, err := GeneratePostgreSQLPassword(crv1.PasswordEncryptionSCRAMSHA256, "hippo", "datalake")
+		if err != nil {
+			t.Fatal(err)
+		}
+		second, _ := GeneratePostgreSQLPassword(crv1.PasswordEncryptionSCRAMSHA256, "hippo", "datalake")
+
+		if !strings.HasPrefix(first, "SCRAM-SHA-256$4096:") {
+			t.Errorf("expected a SCRAM-SHA-256 verifier, got %q", first)
+		}
+		if first == second {
+			t.Errorf("expected verifiers with different salts, got %q twice", first)
+		}
+	})
+
+	t.Run("unsupported", func(t *testing.T) {
+		if _, err := GeneratePostgreSQLPassword("password", "hippo", "datalake"); err == nil {
+			t.Error("expected an error")
+		}
+	})
+}